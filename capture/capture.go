@@ -0,0 +1,228 @@
+// Package capture records the raw OCPP-J frames exchanged with charge points and replays them later on,
+// for reproducing issues observed in the field.
+//
+// A Recorder wraps the websocket server of a central system and writes every frame as a single JSON line:
+//
+//	recorder, err := capture.NewFileRecorder("/var/log/ocpp")
+//	centralSystem := ocpp16.NewCentralSystem(nil, recorder.WrapServer(ws.NewServer()))
+//	defer recorder.Close()
+//
+// A recorded session may then be loaded and replayed against a central system running on the in-memory transport,
+// comparing the responses of the central system to the recorded ones (refer to Replayer).
+package capture
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/clock"
+	"github.com/lorenzodonini/ocpp-go/ws"
+)
+
+// Direction of a recorded frame, as seen from the central system.
+type Direction string
+
+const (
+	Inbound  Direction = "in"  // The frame was received from the charge point.
+	Outbound Direction = "out" // The frame was sent to the charge point.
+)
+
+// FileExtension is the extension of the files created by a file recorder.
+const FileExtension = ".ndjson"
+
+// Frame is a single recorded websocket message.
+type Frame struct {
+	Timestamp   time.Time `json:"timestamp"`
+	ClientID    string    `json:"clientId"`
+	Direction   Direction `json:"direction"`
+	Subprotocol string    `json:"subprotocol,omitempty"` // The negotiated subprotocol. Only set for inbound frames.
+	Data        string    `json:"data"`                  // The raw frame. Kept as string, since it may be malformed.
+}
+
+// Recorder writes frames as newline-delimited JSON.
+//
+// A Recorder is safe for concurrent use. Write errors don't interrupt the message flow,
+// the first one is returned by Close instead.
+type Recorder struct {
+	open     func(clientID string) (io.Writer, error)
+	writers  map[string]io.Writer
+	closers  []io.Closer
+	redactor func(frame Frame) Frame
+	clock    clock.Clock
+	err      error
+	mutex    sync.Mutex
+}
+
+// NewRecorder creates a recorder, writing the frames of all charge points to w.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{
+		open: func(clientID string) (io.Writer, error) {
+			return w, nil
+		},
+		writers: map[string]io.Writer{},
+		clock:   clock.New(),
+	}
+}
+
+// NewFileRecorder creates a recorder, writing the frames of each charge point to a dedicated file
+// in the given directory. Files are named after the charge point ID and appended to, if they exist already.
+func NewFileRecorder(dir string) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	r := NewRecorder(nil)
+	r.open = func(clientID string) (io.Writer, error) {
+		f, err := os.OpenFile(filepath.Join(dir, url.PathEscape(clientID)+FileExtension), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		r.closers = append(r.closers, f)
+		return f, nil
+	}
+	return r, nil
+}
+
+// SetRedactor sets a function, which is applied to every frame before writing it.
+// Refer to RedactFields for a default implementation.
+func (r *Recorder) SetRedactor(redactor func(frame Frame) Frame) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.redactor = redactor
+}
+
+// SetClock sets the clock used for timestamping frames. Passing nil restores the real clock.
+func (r *Recorder) SetClock(c clock.Clock) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.clock = clock.OrDefault(c)
+}
+
+// Record writes a single frame.
+func (r *Recorder) Record(clientID string, subprotocol string, direction Direction, data []byte) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	frame := Frame{Timestamp: r.clock.Now(), ClientID: clientID, Direction: direction, Subprotocol: subprotocol, Data: string(data)}
+	if r.redactor != nil {
+		frame = r.redactor(frame)
+	}
+	err := r.write(frame)
+	if err != nil && r.err == nil {
+		r.err = err
+	}
+	return err
+}
+
+// Must be invoked while holding the lock.
+func (r *Recorder) write(frame Frame) error {
+	w, ok := r.writers[frame.ClientID]
+	if !ok {
+		var err error
+		if w, err = r.open(frame.ClientID); err != nil {
+			return fmt.Errorf("couldn't open capture for %s: %w", frame.ClientID, err)
+		}
+		r.writers[frame.ClientID] = w
+	}
+	line, err := json.Marshal(frame)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes all files opened by the recorder and returns the first error that occurred while recording.
+func (r *Recorder) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	err := r.err
+	for _, c := range r.closers {
+		if closeErr := c.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	r.closers = nil
+	r.writers = map[string]io.Writer{}
+	return err
+}
+
+// WrapServer returns a websocket server, which records all frames received and sent by server.
+// The returned server must be passed to the central system in place of the original one.
+func (r *Recorder) WrapServer(server ws.WsServer) ws.WsServer {
+	return &recordingServer{WsServer: server, recorder: r}
+}
+
+type recordingServer struct {
+	ws.WsServer
+	recorder *Recorder
+}
+
+func (s *recordingServer) SetMessageHandler(handler func(ws ws.Channel, data []byte) error) {
+	s.WsServer.SetMessageHandler(func(channel ws.Channel, data []byte) error {
+		_ = s.recorder.Record(channel.ID(), ws.ChannelSubprotocol(channel), Inbound, data)
+		return handler(channel, data)
+	})
+}
+
+func (s *recordingServer) Write(webSocketId string, data []byte) error {
+	if err := s.WsServer.Write(webSocketId, data); err != nil {
+		return err
+	}
+	_ = s.recorder.Record(webSocketId, "", Outbound, data)
+	return nil
+}
+
+// Redacted replaces the values removed by RedactFields.
+const Redacted = "REDACTED"
+
+// RedactFields returns a redactor, which replaces the values of all payload fields with the given names
+// (e.g. "idTag" or "idToken"), at any depth. Frames that aren't valid JSON are recorded unchanged.
+func RedactFields(fields ...string) func(frame Frame) Frame {
+	names := map[string]bool{}
+	for _, field := range fields {
+		names[field] = true
+	}
+	return func(frame Frame) Frame {
+		var message interface{}
+		if err := json.Unmarshal([]byte(frame.Data), &message); err != nil {
+			return frame
+		}
+		if !redact(message, names) {
+			return frame
+		}
+		data, err := json.Marshal(message)
+		if err != nil {
+			return frame
+		}
+		frame.Data = string(data)
+		return frame
+	}
+}
+
+// Replaces the matching fields in place. Returns true if any field was replaced.
+func redact(value interface{}, names map[string]bool) bool {
+	redacted := false
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, element := range v {
+			if names[key] {
+				v[key] = Redacted
+				redacted = true
+			} else if redact(element, names) {
+				redacted = true
+			}
+		}
+	case []interface{}:
+		for _, element := range v {
+			if redact(element, names) {
+				redacted = true
+			}
+		}
+	}
+	return redacted
+}
@@ -0,0 +1,160 @@
+package capture_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/capture"
+	ocpp16 "github.com/lorenzodonini/ocpp-go/ocpp1.6"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/lorenzodonini/ocpp-go/ws"
+	"github.com/lorenzodonini/ocpp-go/ws/wstest"
+)
+
+var fixedTime = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// Handles boot notifications and heartbeats with fixed values, all other messages would panic.
+type coreHandler struct {
+	core.CentralSystemHandler
+	status core.RegistrationStatus
+}
+
+func (h *coreHandler) OnBootNotification(chargePointId string, request *core.BootNotificationRequest) (*core.BootNotificationConfirmation, error) {
+	return core.NewBootNotificationConfirmation(types.NewDateTime(fixedTime), 60, h.status), nil
+}
+
+func (h *coreHandler) OnHeartbeat(chargePointId string, request *core.HeartbeatRequest) (*core.HeartbeatConfirmation, error) {
+	return core.NewHeartbeatConfirmation(types.NewDateTime(fixedTime)), nil
+}
+
+// Starts a central system on an in-memory server, which is optionally recorded.
+func startCentralSystem(t *testing.T, handler *coreHandler, recorder *capture.Recorder) *wstest.Server {
+	server := wstest.NewServer()
+	var network ws.WsServer = server
+	if recorder != nil {
+		network = recorder.WrapServer(network)
+	}
+	centralSystem := ocpp16.NewCentralSystem(nil, network)
+	centralSystem.SetCoreHandler(handler)
+	require.NoError(t, wstest.ConnectInMemory(server, centralSystem))
+	t.Cleanup(centralSystem.Stop)
+	return server
+}
+
+// Records a scripted session of a single charge point.
+func recordSession(t *testing.T) []capture.Frame {
+	var buf bytes.Buffer
+	recorder := capture.NewRecorder(&buf)
+	server := startCentralSystem(t, &coreHandler{status: core.RegistrationStatusAccepted}, recorder)
+	chargePoint := ocpp16.NewChargePoint("cp1", nil, wstest.NewClient(server))
+	require.NoError(t, chargePoint.Start(wstest.URL))
+	_, err := chargePoint.BootNotification("model1", "vendor1")
+	require.NoError(t, err)
+	_, err = chargePoint.Heartbeat()
+	require.NoError(t, err)
+	chargePoint.Stop()
+	require.NoError(t, recorder.Close())
+	frames, err := capture.Load(&buf)
+	require.NoError(t, err)
+	return frames
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	frames := recordSession(t)
+	require.Len(t, frames, 4)
+	directions := []capture.Direction{capture.Inbound, capture.Outbound, capture.Inbound, capture.Outbound}
+	for i, frame := range frames {
+		assert.Equal(t, "cp1", frame.ClientID)
+		assert.Equal(t, directions[i], frame.Direction)
+		assert.False(t, frame.Timestamp.IsZero())
+	}
+	assert.Equal(t, types.V16Subprotocol, frames[0].Subprotocol)
+	assert.Contains(t, frames[0].Data, "BootNotification")
+	// Replay verbatim against a fresh central system
+	server := startCentralSystem(t, &coreHandler{status: core.RegistrationStatusAccepted}, nil)
+	replayer := capture.NewReplayer(frames)
+	replayer.SetSpeed(0)
+	report, err := replayer.Replay(server)
+	require.NoError(t, err)
+	assert.True(t, report.OK(), report.String())
+	assert.Equal(t, 2, report.Sent)
+	assert.Equal(t, 2, report.Compared)
+}
+
+func TestReplayMismatch(t *testing.T) {
+	frames := recordSession(t)
+	// The central system now rejects the charge point
+	server := startCentralSystem(t, &coreHandler{status: core.RegistrationStatusRejected}, nil)
+	replayer := capture.NewReplayer(frames)
+	replayer.SetSpeed(0)
+	report, err := replayer.Replay(server)
+	require.NoError(t, err)
+	require.False(t, report.OK())
+	require.Len(t, report.Mismatches, 1)
+	mismatch := report.Mismatches[0]
+	assert.Equal(t, "cp1", mismatch.ClientID)
+	assert.Equal(t, frames[1].Data, mismatch.Expected)
+	assert.Equal(t, []string{`$[2].status: expected "Accepted", got "Rejected"`}, mismatch.Differences)
+	assert.Contains(t, report.String(), "1 mismatches")
+	// Ignored fields aren't compared
+	server = startCentralSystem(t, &coreHandler{status: core.RegistrationStatusRejected}, nil)
+	replayer.IgnoreFields("status")
+	report, err = replayer.Replay(server)
+	require.NoError(t, err)
+	assert.True(t, report.OK(), report.String())
+}
+
+func TestReplayMissingResponse(t *testing.T) {
+	frames := []capture.Frame{
+		{ClientID: "cp1", Direction: capture.Inbound, Subprotocol: types.V16Subprotocol, Data: `[2,"1","Heartbeat",{}]`},
+		{ClientID: "cp1", Direction: capture.Outbound, Data: `[3,"1",{"currentTime":"2024-01-01T12:00:00Z"}]`},
+		{ClientID: "cp1", Direction: capture.Outbound, Data: `[3,"2",{"currentTime":"2024-01-01T12:00:00Z"}]`},
+	}
+	server := startCentralSystem(t, &coreHandler{}, nil)
+	replayer := capture.NewReplayer(frames)
+	replayer.SetSpeed(0)
+	replayer.SetResponseTimeout(50 * time.Millisecond)
+	report, err := replayer.Replay(server)
+	require.NoError(t, err)
+	require.Len(t, report.Mismatches, 1)
+	assert.Equal(t, frames[2].Data, report.Mismatches[0].Expected)
+	assert.Empty(t, report.Mismatches[0].Actual)
+}
+
+func TestRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	recorder := capture.NewRecorder(&buf)
+	recorder.SetRedactor(capture.RedactFields("idTag"))
+	require.NoError(t, recorder.Record("cp1", "", capture.Inbound, []byte(`[2,"1","Authorize",{"idTag":"secret"}]`)))
+	require.NoError(t, recorder.Record("cp1", "", capture.Inbound, []byte(`not json`)))
+	frames, err := capture.Load(&buf)
+	require.NoError(t, err)
+	require.Len(t, frames, 2)
+	assert.Equal(t, `[2,"1","Authorize",{"idTag":"REDACTED"}]`, frames[0].Data)
+	assert.Equal(t, `not json`, frames[1].Data)
+}
+
+func TestFileRecorder(t *testing.T) {
+	dir := t.TempDir()
+	recorder, err := capture.NewFileRecorder(dir)
+	require.NoError(t, err)
+	require.NoError(t, recorder.Record("cp1", "", capture.Inbound, []byte(`[2,"1","Heartbeat",{}]`)))
+	require.NoError(t, recorder.Record("cp2", "", capture.Inbound, []byte(`[2,"1","Heartbeat",{}]`)))
+	require.NoError(t, recorder.Record("cp1", "", capture.Outbound, []byte(`[3,"1",{}]`)))
+	require.NoError(t, recorder.Close())
+	for id, expected := range map[string]int{"cp1": 2, "cp2": 1} {
+		f, err := os.Open(filepath.Join(dir, id+capture.FileExtension))
+		require.NoError(t, err)
+		frames, err := capture.Load(f)
+		_ = f.Close()
+		require.NoError(t, err)
+		assert.Len(t, frames, expected)
+	}
+}
@@ -0,0 +1,315 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/clock"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/lorenzodonini/ocpp-go/ws/wstest"
+)
+
+// The default time to wait for a frame of the central system, before reporting it as missing.
+const defaultResponseTimeout = 5 * time.Second
+
+// Load reads all frames of a recording.
+func Load(r io.Reader) ([]Frame, error) {
+	var frames []Frame
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var frame Frame
+		if err := json.Unmarshal(scanner.Bytes(), &frame); err != nil {
+			return nil, fmt.Errorf("invalid frame on line %d: %w", line, err)
+		}
+		frames = append(frames, frame)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return frames, nil
+}
+
+// Mismatch describes a frame sent by the central system during a replay, which differs from the recorded one.
+type Mismatch struct {
+	ClientID    string
+	Expected    string   // The recorded frame. Empty, if the central system sent an unexpected frame.
+	Actual      string   // The frame sent during the replay. Empty, if no frame was sent.
+	Differences []string // The differing fields, as JSON paths with the expected and actual values.
+}
+
+// Report is the outcome of a replay.
+type Report struct {
+	Sent       int // The amount of inbound frames sent to the central system.
+	Compared   int // The amount of outbound frames compared to the recorded ones.
+	Mismatches []Mismatch
+}
+
+// OK returns true if the central system sent exactly the recorded frames.
+func (r *Report) OK() bool {
+	return len(r.Mismatches) == 0
+}
+
+// String returns a human-readable diff report.
+func (r *Report) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "replayed %d frames, compared %d responses, %d mismatches\n", r.Sent, r.Compared, len(r.Mismatches))
+	for _, m := range r.Mismatches {
+		fmt.Fprintf(&sb, "--- %s\n", m.ClientID)
+		fmt.Fprintf(&sb, "expected: %s\n", m.Expected)
+		fmt.Fprintf(&sb, "actual:   %s\n", m.Actual)
+		for _, d := range m.Differences {
+			fmt.Fprintf(&sb, "  %s\n", d)
+		}
+	}
+	return sb.String()
+}
+
+// Replayer feeds the inbound frames of a recording into a central system, running on the in-memory transport,
+// and compares the outbound frames of the central system to the recorded ones.
+//
+// Every charge point of the recording is connected via a dedicated in-memory client, using the recorded subprotocol.
+// Responses are matched in order. Requests initiated by the central system are compared regardless of their message ID;
+// recorded responses of the charge point to such requests are rewritten to the message ID used during the replay.
+type Replayer struct {
+	frames          []Frame
+	speed           float64
+	responseTimeout time.Duration
+	ignored         map[string]bool
+	clock           clock.Clock
+}
+
+// NewReplayer creates a replayer for the given frames, which replays them at their original timing.
+func NewReplayer(frames []Frame) *Replayer {
+	return &Replayer{
+		frames:          frames,
+		speed:           1,
+		responseTimeout: defaultResponseTimeout,
+		ignored:         map[string]bool{},
+		clock:           clock.New(),
+	}
+}
+
+// SetSpeed sets the replay speed relative to the recording, e.g. 10 replays ten times faster.
+// A speed of zero or less sends all frames without any delay.
+func (r *Replayer) SetSpeed(speed float64) {
+	r.speed = speed
+}
+
+// SetResponseTimeout sets the time to wait for each recorded frame of the central system, before reporting it as missing.
+func (r *Replayer) SetResponseTimeout(timeout time.Duration) {
+	r.responseTimeout = timeout
+}
+
+// IgnoreFields excludes payload fields with the given names from the comparison, at any depth.
+// This is useful for values that change on every run, such as "currentTime".
+func (r *Replayer) IgnoreFields(fields ...string) {
+	for _, field := range fields {
+		r.ignored[field] = true
+	}
+}
+
+// SetClock sets the clock used for delays and timeouts. Passing nil restores the real clock.
+func (r *Replayer) SetClock(c clock.Clock) {
+	r.clock = clock.OrDefault(c)
+}
+
+// A charge point connected during a replay.
+type replayClient struct {
+	client *wstest.Client
+	liveC  chan string
+	ids    map[string]string // Maps recorded message IDs of requests sent by the central system to the replayed ones.
+}
+
+// Replay connects the recorded charge points to server and replays the recording.
+// The central system must have been started with server already.
+//
+// An error is returned only if the replay couldn't be performed. Differing responses are contained in the report.
+func (r *Replayer) Replay(server *wstest.Server) (*Report, error) {
+	report := &Report{}
+	clients := map[string]*replayClient{}
+	defer func() {
+		for _, c := range clients {
+			c.client.Stop()
+		}
+	}()
+	var previous time.Time
+	for _, frame := range r.frames {
+		if r.speed > 0 && !previous.IsZero() && frame.Timestamp.After(previous) {
+			<-r.clock.After(time.Duration(float64(frame.Timestamp.Sub(previous)) / r.speed))
+		}
+		previous = frame.Timestamp
+		c, ok := clients[frame.ClientID]
+		switch frame.Direction {
+		case Inbound:
+			if !ok {
+				var err error
+				if c, err = r.connect(server, frame); err != nil {
+					return report, err
+				}
+				clients[frame.ClientID] = c
+			}
+			if err := c.client.Write([]byte(c.rewrite(frame.Data))); err != nil {
+				return report, fmt.Errorf("couldn't replay frame of %s: %w", frame.ClientID, err)
+			}
+			report.Sent++
+		case Outbound:
+			report.Compared++
+			if !ok {
+				report.Mismatches = append(report.Mismatches, Mismatch{ClientID: frame.ClientID, Expected: frame.Data})
+				continue
+			}
+			select {
+			case live := <-c.liveC:
+				if differences := r.compare(c, frame.Data, live); len(differences) > 0 {
+					report.Mismatches = append(report.Mismatches, Mismatch{ClientID: frame.ClientID, Expected: frame.Data, Actual: live, Differences: differences})
+				}
+			case <-r.clock.After(r.responseTimeout):
+				report.Mismatches = append(report.Mismatches, Mismatch{ClientID: frame.ClientID, Expected: frame.Data})
+			}
+		default:
+			return report, fmt.Errorf("invalid direction %q of frame for %s", frame.Direction, frame.ClientID)
+		}
+	}
+	// Any further frame was not part of the recording
+	ids := make([]string, 0, len(clients))
+	for id := range clients {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		for drained := false; !drained; {
+			select {
+			case live := <-clients[id].liveC:
+				report.Mismatches = append(report.Mismatches, Mismatch{ClientID: id, Actual: live})
+			default:
+				drained = true
+			}
+		}
+	}
+	return report, nil
+}
+
+func (r *Replayer) connect(server *wstest.Server, frame Frame) (*replayClient, error) {
+	c := &replayClient{client: wstest.NewClient(server), liveC: make(chan string, 64), ids: map[string]string{}}
+	if frame.Subprotocol != "" {
+		c.client.SetRequestedSubProtocol(frame.Subprotocol)
+	}
+	c.client.SetMessageHandler(func(data []byte) error {
+		c.liveC <- string(data)
+		return nil
+	})
+	if err := c.client.Start(wstest.URL + "/" + frame.ClientID); err != nil {
+		return nil, fmt.Errorf("couldn't connect %s: %w", frame.ClientID, err)
+	}
+	return c, nil
+}
+
+// Replaces the recorded message ID of responses to requests of the central system with the replayed one.
+func (c *replayClient) rewrite(data string) string {
+	var message []interface{}
+	if err := json.Unmarshal([]byte(data), &message); err != nil || len(message) < 2 {
+		return data
+	}
+	if typeId, _ := message[0].(float64); ocppj.MessageType(typeId) == ocppj.CALL {
+		return data
+	}
+	recordedID, _ := message[1].(string)
+	replayedID, ok := c.ids[recordedID]
+	if !ok {
+		return data
+	}
+	message[1] = replayedID
+	rewritten, err := json.Marshal(message)
+	if err != nil {
+		return data
+	}
+	return string(rewritten)
+}
+
+// Returns the differences between a recorded and a replayed frame of the central system.
+func (r *Replayer) compare(c *replayClient, expected string, actual string) []string {
+	var expectedValue, actualValue interface{}
+	if json.Unmarshal([]byte(expected), &expectedValue) != nil || json.Unmarshal([]byte(actual), &actualValue) != nil {
+		if expected == actual {
+			return nil
+		}
+		return []string{"frames differ"}
+	}
+	expectedMessage, ok1 := expectedValue.([]interface{})
+	actualMessage, ok2 := actualValue.([]interface{})
+	if ok1 && ok2 && len(expectedMessage) > 2 && len(actualMessage) > 2 {
+		if typeId, _ := expectedMessage[0].(float64); ocppj.MessageType(typeId) == ocppj.CALL && reflect.DeepEqual(expectedMessage[0], actualMessage[0]) {
+			// Requests of the central system carry a new message ID on every run
+			recordedID, _ := expectedMessage[1].(string)
+			replayedID, _ := actualMessage[1].(string)
+			c.ids[recordedID] = replayedID
+			actualMessage[1] = expectedMessage[1]
+		}
+	}
+	return r.diff("$", expectedValue, actualValue, nil)
+}
+
+// Appends the paths at which two decoded JSON values differ.
+func (r *Replayer) diff(path string, expected interface{}, actual interface{}, differences []string) []string {
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := map[string]bool{}
+		for key := range e {
+			keys[key] = true
+		}
+		for key := range a {
+			keys[key] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for key := range keys {
+			if !r.ignored[key] {
+				sorted = append(sorted, key)
+			}
+		}
+		sort.Strings(sorted)
+		for _, key := range sorted {
+			differences = r.diff(path+"."+key, e[key], a[key], differences)
+		}
+		return differences
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok || len(a) != len(e) {
+			break
+		}
+		for i := range e {
+			differences = r.diff(fmt.Sprintf("%s[%d]", path, i), e[i], a[i], differences)
+		}
+		return differences
+	}
+	if reflect.DeepEqual(expected, actual) {
+		return differences
+	}
+	return append(differences, fmt.Sprintf("%s: expected %s, got %s", path, toJSON(expected), toJSON(actual)))
+}
+
+func toJSON(value interface{}) string {
+	if value == nil {
+		return "<missing>"
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
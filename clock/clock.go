@@ -0,0 +1,131 @@
+// Package clock abstracts the time-dependent behavior of the library, such as timeouts, back-off delays and
+// periodic tasks, so that it may be driven by a fake clock in tests.
+//
+// All components default to the real clock. A different clock may be injected via the respective SetClock method.
+// Refer to the clocktest package for a fake clock, which is advanced manually.
+package clock
+
+import "time"
+
+// Clock provides the current time and creates timers based on it.
+//
+// Implementations must be safe for concurrent use.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// After waits for the duration to elapse and then sends the current time on the returned channel.
+	After(d time.Duration) <-chan time.Time
+	// NewTimer creates a new Timer, which sends the current time on its channel after at least duration d.
+	NewTimer(d time.Duration) Timer
+	// NewTicker creates a new Ticker, which sends the current time on its channel after each period d.
+	NewTicker(d time.Duration) Ticker
+	// AfterFunc waits for the duration to elapse and then calls f in its own goroutine.
+	// The returned Timer may be used to cancel the call; its channel is nil.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer represents a single event, equivalent to time.Timer.
+type Timer interface {
+	// C returns the channel on which the time is delivered.
+	C() <-chan time.Time
+	// Stop prevents the timer from firing. Returns false if the timer already expired or was stopped.
+	Stop() bool
+	// Reset changes the timer to expire after duration d. Returns true if the timer had been active.
+	Reset(d time.Duration) bool
+}
+
+// Ticker delivers ticks at intervals, equivalent to time.Ticker.
+type Ticker interface {
+	// C returns the channel on which the ticks are delivered.
+	C() <-chan time.Time
+	// Stop turns off the ticker. No more ticks will be sent.
+	Stop()
+	// Reset stops the ticker and resets its period to the specified duration.
+	Reset(d time.Duration)
+}
+
+// New returns the real clock, backed by the time package.
+func New() Clock {
+	return realClock{}
+}
+
+// OrDefault returns c, or the real clock if c is nil.
+func OrDefault(c Clock) Clock {
+	if c == nil {
+		return New()
+	}
+	return c
+}
+
+// WithNow returns a clock, which retrieves the current time from now, while timers are backed by the real clock.
+// It adapts plain time sources, such as those passed to the deprecated SetTimeSource methods.
+// If now is nil, the real clock is returned.
+func WithNow(now func() time.Time) Clock {
+	if now == nil {
+		return New()
+	}
+	return nowClock{realClock: realClock{}, now: now}
+}
+
+type nowClock struct {
+	realClock
+	now func() time.Time
+}
+
+func (c nowClock) Now() time.Time {
+	return c.now()
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return &realTimer{timer: time.NewTimer(d)}
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return &realTicker{ticker: time.NewTicker(d)}
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return &realTimer{timer: time.AfterFunc(d, f)}
+}
+
+type realTimer struct {
+	timer *time.Timer
+}
+
+func (t *realTimer) C() <-chan time.Time {
+	return t.timer.C
+}
+
+func (t *realTimer) Stop() bool {
+	return t.timer.Stop()
+}
+
+func (t *realTimer) Reset(d time.Duration) bool {
+	return t.timer.Reset(d)
+}
+
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+func (t *realTicker) C() <-chan time.Time {
+	return t.ticker.C
+}
+
+func (t *realTicker) Stop() {
+	t.ticker.Stop()
+}
+
+func (t *realTicker) Reset(d time.Duration) {
+	t.ticker.Reset(d)
+}
@@ -0,0 +1,232 @@
+// Package clocktest provides a fake clock.Clock for deterministic tests of time-dependent behavior.
+//
+// The time of a FakeClock only moves when Advance or Set is invoked, firing all timers and tickers that expire
+// in the meantime, in chronological order:
+//
+//	fake := clocktest.NewFakeClock(time.Now())
+//	dispatcher.SetClock(fake)
+//	// ... send a request
+//	fake.BlockUntil(1) // Wait for the timeout timer to be started
+//	fake.Advance(30 * time.Second)
+package clocktest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/clock"
+)
+
+// FakeClock is a clock.Clock, whose time is advanced manually.
+//
+// Unlike the real clock, functions passed to AfterFunc are invoked synchronously by Advance/Set,
+// so that their effects are visible as soon as Advance returns.
+//
+// A FakeClock is safe for concurrent use.
+type FakeClock struct {
+	now      time.Time
+	waiters  []*fakeTimer
+	blockers []*blocker
+	mutex    sync.Mutex
+}
+
+// A pending BlockUntil invocation.
+type blocker struct {
+	count int
+	doneC chan struct{}
+}
+
+// NewFakeClock creates a fake clock, set to the given time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (f *FakeClock) Now() time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.now
+}
+
+func (f *FakeClock) After(d time.Duration) <-chan time.Time {
+	return f.NewTimer(d).C()
+}
+
+func (f *FakeClock) NewTimer(d time.Duration) clock.Timer {
+	t := &fakeTimer{clock: f, c: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+func (f *FakeClock) NewTicker(d time.Duration) clock.Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	t := &fakeTimer{clock: f, c: make(chan time.Time, 1), period: d}
+	t.Reset(d)
+	return &fakeTicker{timer: t}
+}
+
+func (f *FakeClock) AfterFunc(d time.Duration, fn func()) clock.Timer {
+	t := &fakeTimer{clock: f, fn: fn}
+	t.Reset(d)
+	return t
+}
+
+// Advance moves the time forward by d, firing all timers and tickers expiring in the meantime.
+func (f *FakeClock) Advance(d time.Duration) {
+	f.mutex.Lock()
+	target := f.now.Add(d)
+	f.mutex.Unlock()
+	f.Set(target)
+}
+
+// Set moves the time forward to t, firing all timers and tickers expiring in the meantime.
+// Setting a time in the past changes the current time without firing any timer.
+func (f *FakeClock) Set(t time.Time) {
+	for {
+		f.mutex.Lock()
+		next := f.nextExpired(t)
+		if next == nil {
+			f.now = t
+			f.mutex.Unlock()
+			return
+		}
+		if next.when.After(f.now) {
+			f.now = next.when
+		}
+		fn := next.fire()
+		f.mutex.Unlock()
+		if fn != nil {
+			fn()
+		}
+	}
+}
+
+// Waiters returns the amount of active timers and tickers.
+func (f *FakeClock) Waiters() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return len(f.waiters)
+}
+
+// BlockUntil blocks until at least n timers and tickers are active.
+//
+// This is useful for waiting until a component under test, running on a different goroutine, started a timer,
+// before advancing the time.
+func (f *FakeClock) BlockUntil(n int) {
+	f.mutex.Lock()
+	if len(f.waiters) >= n {
+		f.mutex.Unlock()
+		return
+	}
+	b := &blocker{count: n, doneC: make(chan struct{})}
+	f.blockers = append(f.blockers, b)
+	f.mutex.Unlock()
+	<-b.doneC
+}
+
+// Returns the earliest timer expiring at or before t, if any. Must be invoked while holding the lock.
+func (f *FakeClock) nextExpired(t time.Time) *fakeTimer {
+	if len(f.waiters) == 0 || f.waiters[0].when.After(t) {
+		return nil
+	}
+	return f.waiters[0]
+}
+
+// Adds a timer to the active timers. Must be invoked while holding the lock.
+func (f *FakeClock) schedule(t *fakeTimer) {
+	f.unschedule(t)
+	f.waiters = append(f.waiters, t)
+	sort.SliceStable(f.waiters, func(i, j int) bool {
+		return f.waiters[i].when.Before(f.waiters[j].when)
+	})
+	remaining := f.blockers[:0]
+	for _, b := range f.blockers {
+		if len(f.waiters) >= b.count {
+			close(b.doneC)
+		} else {
+			remaining = append(remaining, b)
+		}
+	}
+	f.blockers = remaining
+}
+
+// Removes a timer from the active timers. Returns true if the timer was active. Must be invoked while holding the lock.
+func (f *FakeClock) unschedule(t *fakeTimer) bool {
+	for i, w := range f.waiters {
+		if w == t {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// A timer, ticker or function call scheduled on a FakeClock.
+type fakeTimer struct {
+	clock  *FakeClock
+	when   time.Time
+	c      chan time.Time
+	fn     func()
+	period time.Duration // Only set for tickers
+}
+
+func (t *fakeTimer) C() <-chan time.Time {
+	return t.c
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+	return t.clock.unschedule(t)
+}
+
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+	active := t.clock.unschedule(t)
+	t.when = t.clock.now.Add(d)
+	t.clock.schedule(t)
+	return active
+}
+
+// Delivers the expiration of the timer and reschedules tickers.
+// Returns the function to invoke, once the lock was released. Must be invoked while holding the lock.
+func (t *fakeTimer) fire() func() {
+	t.clock.unschedule(t)
+	if t.period > 0 {
+		t.when = t.when.Add(t.period)
+		t.clock.schedule(t)
+	}
+	if t.c != nil {
+		// As for the real timers, ticks are dropped if the receiver doesn't keep up
+		select {
+		case t.c <- t.clock.now:
+		default:
+		}
+	}
+	return t.fn
+}
+
+type fakeTicker struct {
+	timer *fakeTimer
+}
+
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.timer.c
+}
+
+func (t *fakeTicker) Stop() {
+	t.timer.Stop()
+}
+
+func (t *fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("non-positive interval for Ticker.Reset")
+	}
+	t.timer.clock.mutex.Lock()
+	t.timer.period = d
+	t.timer.clock.mutex.Unlock()
+	t.timer.Reset(d)
+}
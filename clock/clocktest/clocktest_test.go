@@ -0,0 +1,103 @@
+package clocktest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestFakeTimer(t *testing.T) {
+	fake := NewFakeClock(epoch)
+	timer := fake.NewTimer(10 * time.Second)
+	assert.Equal(t, 1, fake.Waiters())
+	fake.Advance(9 * time.Second)
+	select {
+	case <-timer.C():
+		t.Fatal("timer fired early")
+	default:
+	}
+	fake.Advance(time.Second)
+	select {
+	case fired := <-timer.C():
+		assert.Equal(t, epoch.Add(10*time.Second), fired)
+	default:
+		t.Fatal("timer didn't fire")
+	}
+	assert.Equal(t, 0, fake.Waiters())
+	assert.False(t, timer.Stop())
+	// Reset an expired timer
+	assert.False(t, timer.Reset(time.Second))
+	assert.True(t, timer.Stop())
+	fake.Advance(time.Minute)
+	assert.Len(t, timer.C(), 0)
+}
+
+func TestFakeTicker(t *testing.T) {
+	fake := NewFakeClock(epoch)
+	ticker := fake.NewTicker(time.Second)
+	fake.Advance(time.Second)
+	assert.Equal(t, epoch.Add(time.Second), <-ticker.C())
+	// Ticks are dropped if the receiver doesn't keep up
+	fake.Advance(3 * time.Second)
+	assert.Equal(t, epoch.Add(2*time.Second), <-ticker.C())
+	assert.Len(t, ticker.C(), 0)
+	ticker.Reset(time.Minute)
+	fake.Advance(time.Second)
+	assert.Len(t, ticker.C(), 0)
+	ticker.Stop()
+	fake.Advance(time.Hour)
+	assert.Len(t, ticker.C(), 0)
+	assert.Equal(t, 0, fake.Waiters())
+}
+
+func TestFakeAfterFunc(t *testing.T) {
+	fake := NewFakeClock(epoch)
+	var order []int
+	fake.AfterFunc(2*time.Second, func() {
+		order = append(order, 2)
+		assert.Equal(t, epoch.Add(2*time.Second), fake.Now())
+	})
+	fake.AfterFunc(time.Second, func() {
+		order = append(order, 1)
+		// Timers scheduled by a callback fire within the same Advance, if expired
+		fake.AfterFunc(500*time.Millisecond, func() {
+			order = append(order, 3)
+		})
+	})
+	stopped := fake.AfterFunc(time.Second, func() {
+		order = append(order, 4)
+	})
+	assert.True(t, stopped.Stop())
+	fake.Advance(3 * time.Second)
+	assert.Equal(t, []int{1, 3, 2}, order)
+	assert.Equal(t, epoch.Add(3*time.Second), fake.Now())
+}
+
+func TestFakeBlockUntil(t *testing.T) {
+	fake := NewFakeClock(epoch)
+	resultC := make(chan time.Time, 1)
+	go func() {
+		resultC <- <-fake.After(time.Minute)
+	}()
+	fake.BlockUntil(1)
+	fake.Advance(time.Minute)
+	select {
+	case result := <-resultC:
+		assert.Equal(t, epoch.Add(time.Minute), result)
+	case <-time.After(time.Second):
+		require.Fail(t, "timer didn't fire")
+	}
+}
+
+func TestFakeSetPast(t *testing.T) {
+	fake := NewFakeClock(epoch)
+	timer := fake.NewTimer(time.Second)
+	fake.Set(epoch.Add(-time.Hour))
+	assert.Equal(t, epoch.Add(-time.Hour), fake.Now())
+	assert.Len(t, timer.C(), 0)
+	assert.Equal(t, 1, fake.Waiters())
+}
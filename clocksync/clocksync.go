@@ -0,0 +1,204 @@
+// Package clocksync allows charge points to keep track of the authoritative time, as reported by the central system
+// in BootNotification and Heartbeat responses.
+//
+// This is useful on targets, which cannot set their system clock: the library learns the offset between
+// the local and the authoritative clock and offers a corrected time for message timestamps.
+package clocksync
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Mode defines how the authoritative time received from the central system is used.
+type Mode int
+
+const (
+	// Clock synchronization is disabled. Now returns the local time.
+	ModeOff Mode = iota
+	// The offset between the local and the authoritative clock is learned. Now returns the corrected time.
+	ModeTrackOffset
+	// The authoritative time is passed to a user-defined hook, which is expected to adjust the local clock.
+	// Now returns the local time.
+	ModeCallback
+)
+
+const (
+	// The default amount of samples, over which the offset is smoothed.
+	DefaultWindowSize = 5
+	// The default maximum deviation of a sample from the current offset. Samples deviating more are treated as outliers.
+	DefaultMaxDeviation = 10 * time.Second
+)
+
+// Clock tracks the offset between the local clock and the authoritative clock of the central system.
+//
+// The offset is the median of the most recent samples. A sample deviating from the current offset by more than
+// the maximum deviation is discarded, unless it is confirmed by the following sample.
+// This way, a single bogus response cannot yank the offset, while an actual clock jump is applied after two responses.
+//
+// A Clock is safe for concurrent use.
+type Clock struct {
+	mode         Mode
+	now          func() time.Time
+	hook         func(authoritative time.Time)
+	windowSize   int
+	maxDeviation time.Duration
+	samples      []time.Duration
+	candidate    *time.Duration
+	offset       time.Duration
+	mutex        sync.Mutex
+}
+
+// NewClock creates a new clock with clock synchronization disabled.
+// The local time is retrieved via time.Now, unless a different time source is set via SetTimeSource.
+func NewClock() *Clock {
+	return &Clock{
+		mode:         ModeOff,
+		now:          time.Now,
+		windowSize:   DefaultWindowSize,
+		maxDeviation: DefaultMaxDeviation,
+	}
+}
+
+// SetMode sets the clock synchronization mode. Learned samples are retained across mode changes.
+func (c *Clock) SetMode(mode Mode) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.mode = mode
+}
+
+// Mode returns the current clock synchronization mode.
+func (c *Clock) Mode() Mode {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.mode
+}
+
+// SetHook sets the function invoked with the authoritative time, when running in ModeCallback.
+func (c *Clock) SetHook(hook func(authoritative time.Time)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.hook = hook
+}
+
+// SetTimeSource replaces the function used for retrieving the local time. Passing nil restores time.Now.
+func (c *Clock) SetTimeSource(now func() time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if now == nil {
+		now = time.Now
+	}
+	c.now = now
+}
+
+// SetSmoothing configures the amount of samples, over which the offset is smoothed,
+// and the maximum deviation after which a sample is treated as an outlier.
+func (c *Clock) SetSmoothing(windowSize int, maxDeviation time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if windowSize < 1 {
+		windowSize = 1
+	}
+	c.windowSize = windowSize
+	c.maxDeviation = maxDeviation
+}
+
+// Now returns the current time. In ModeTrackOffset, the learned offset is applied to the local time.
+func (c *Clock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.mode == ModeTrackOffset {
+		return c.now().Add(c.offset)
+	}
+	return c.now()
+}
+
+// Offset returns the currently learned offset between the authoritative and the local clock.
+func (c *Clock) Offset() time.Duration {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.offset
+}
+
+// Observe feeds an authoritative time received from the central system to the clock.
+// The roundTrip is the time elapsed between sending the request and receiving the response:
+// the authoritative time is assumed to have been generated halfway through.
+//
+// In ModeCallback, the hook is invoked with the authoritative time, unless the sample was discarded as an outlier.
+// The function has no effect in ModeOff.
+func (c *Clock) Observe(authoritative time.Time, roundTrip time.Duration) {
+	if roundTrip < 0 {
+		roundTrip = 0
+	}
+	c.mutex.Lock()
+	if c.mode == ModeOff {
+		c.mutex.Unlock()
+		return
+	}
+	received := c.now()
+	sample := authoritative.Add(roundTrip / 2).Sub(received)
+	if !c.addSample(sample) {
+		c.mutex.Unlock()
+		return
+	}
+	offset := c.offset
+	hook := c.hook
+	if c.mode == ModeCallback && hook != nil {
+		// The hook is expected to adjust the local clock, so previous samples are rebased onto the adjusted clock
+		for i := range c.samples {
+			c.samples[i] -= offset
+		}
+		c.offset = 0
+	} else {
+		hook = nil
+	}
+	c.mutex.Unlock()
+	if hook != nil {
+		hook(received.Add(offset))
+	}
+}
+
+// Adds a sample to the window and recomputes the offset. Returns false if the sample was discarded as an outlier.
+func (c *Clock) addSample(sample time.Duration) bool {
+	if len(c.samples) == 0 {
+		c.samples = []time.Duration{sample}
+		c.offset = sample
+		return true
+	}
+	if abs(sample-c.offset) > c.maxDeviation {
+		if c.candidate == nil || abs(sample-*c.candidate) > c.maxDeviation {
+			// Wait for confirmation by the next sample
+			c.candidate = &sample
+			return false
+		}
+		// Confirmed by two consecutive samples: the clock actually jumped
+		c.samples = []time.Duration{*c.candidate}
+		c.candidate = nil
+	}
+	c.candidate = nil
+	c.samples = append(c.samples, sample)
+	if len(c.samples) > c.windowSize {
+		c.samples = c.samples[len(c.samples)-c.windowSize:]
+	}
+	c.offset = median(c.samples)
+	return true
+}
+
+func median(samples []time.Duration) time.Duration {
+	sorted := make([]time.Duration, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
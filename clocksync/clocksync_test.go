@@ -0,0 +1,100 @@
+package clocksync_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lorenzodonini/ocpp-go/clocksync"
+)
+
+type fakeClock struct {
+	current time.Time
+}
+
+func (f *fakeClock) now() time.Time {
+	return f.current
+}
+
+func (f *fakeClock) advance(d time.Duration) {
+	f.current = f.current.Add(d)
+}
+
+func newTestClock(mode clocksync.Mode) (*clocksync.Clock, *fakeClock) {
+	fake := &fakeClock{current: time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)}
+	clock := clocksync.NewClock()
+	clock.SetTimeSource(fake.now)
+	clock.SetMode(mode)
+	return clock, fake
+}
+
+func TestClockOff(t *testing.T) {
+	clock, fake := newTestClock(clocksync.ModeOff)
+	clock.Observe(fake.now().Add(time.Hour), 0)
+	assert.Equal(t, time.Duration(0), clock.Offset())
+	assert.Equal(t, fake.now(), clock.Now())
+}
+
+func TestClockTrackOffset(t *testing.T) {
+	clock, fake := newTestClock(clocksync.ModeTrackOffset)
+	// Server is one hour ahead, response was generated halfway through the round trip
+	clock.Observe(fake.now().Add(time.Hour-time.Second), 2*time.Second)
+	assert.Equal(t, time.Hour, clock.Offset())
+	assert.Equal(t, fake.now().Add(time.Hour), clock.Now())
+	// Offset is retained while the local clock advances
+	fake.advance(30 * time.Second)
+	assert.Equal(t, fake.now().Add(time.Hour), clock.Now())
+	// Small deviations are smoothed via median
+	clock.Observe(fake.now().Add(time.Hour+2*time.Second), 0)
+	clock.Observe(fake.now().Add(time.Hour+4*time.Second), 0)
+	assert.Equal(t, time.Hour+2*time.Second, clock.Offset())
+}
+
+func TestClockDiscardsSingleOutlier(t *testing.T) {
+	clock, fake := newTestClock(clocksync.ModeTrackOffset)
+	clock.Observe(fake.now().Add(time.Minute), 0)
+	// A single bogus response doesn't affect the offset
+	clock.Observe(fake.now().Add(365*24*time.Hour), 0)
+	assert.Equal(t, time.Minute, clock.Offset())
+	clock.Observe(fake.now().Add(time.Minute+time.Second), 0)
+	assert.Equal(t, time.Minute+time.Second/2, clock.Offset())
+	assert.Equal(t, fake.now().Add(time.Minute+time.Second/2), clock.Now())
+}
+
+func TestClockAcceptsConfirmedJump(t *testing.T) {
+	clock, fake := newTestClock(clocksync.ModeTrackOffset)
+	clock.Observe(fake.now(), 0)
+	assert.Equal(t, time.Duration(0), clock.Offset())
+	// The server clock was actually adjusted: two consistent responses are required
+	clock.Observe(fake.now().Add(-2*time.Hour), 0)
+	assert.Equal(t, time.Duration(0), clock.Offset())
+	fake.advance(time.Minute)
+	clock.Observe(fake.now().Add(-2*time.Hour), 0)
+	assert.Equal(t, -2*time.Hour, clock.Offset())
+}
+
+func TestClockCallback(t *testing.T) {
+	clock, fake := newTestClock(clocksync.ModeCallback)
+	var received []time.Time
+	clock.SetHook(func(authoritative time.Time) {
+		received = append(received, authoritative)
+		// Simulate clock adjustment
+		fake.current = authoritative
+	})
+	serverTime := fake.now().Add(time.Hour)
+	clock.Observe(serverTime, 0)
+	assert.Equal(t, []time.Time{serverTime}, received)
+	// In callback mode, Now always returns the local time
+	assert.Equal(t, fake.now(), clock.Now())
+	assert.Equal(t, time.Duration(0), clock.Offset())
+	// After adjustment, further samples are consistent with the adjusted clock
+	fake.advance(time.Minute)
+	local := fake.now()
+	clock.Observe(local.Add(time.Second), 0)
+	assert.Len(t, received, 2)
+	assert.Equal(t, local.Add(time.Second/2), received[1])
+	// Outliers don't trigger the hook
+	clock.Observe(fake.now().Add(-24*time.Hour), 0)
+	assert.Len(t, received, 2)
+}
@@ -0,0 +1,196 @@
+// Package events provides a Bus, through which the layers of the library publish their significant state changes
+// (connections, request timeouts, queue overflows, registrations, transactions, ...) as typed events.
+//
+// Instead of polling connection states, queue sizes or state trackers, an application attaches a bus to the components
+// it is interested in, and subscribes to the events it needs:
+//
+//	bus := events.NewBus(64)
+//	centralSystem.SetEventBus(bus)
+//	eventC, unsubscribe := bus.Subscribe(events.ConnectionUp, events.ConnectionDown, events.RequestTimedOut)
+//	defer unsubscribe()
+//	for event := range eventC {
+//		switch e := event.(type) {
+//		case events.ConnectionUpEvent:
+//			log.Printf("%v connected", e.Peer)
+//		case events.RequestTimedOutEvent:
+//			log.Printf("%v request %v to %v timed out", e.Action, e.RequestID, e.Peer)
+//		}
+//	}
+//
+// Publishing never blocks: every subscriber has its own buffer, and events are dropped for subscribers,
+// which don't keep up. The amount of dropped events is available via Bus.Dropped.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType identifies the kind of an event, and is used for filtering subscriptions.
+type EventType string
+
+const (
+	ConnectionUp           EventType = "ConnectionUp"           // A websocket connection was established.
+	ConnectionDown         EventType = "ConnectionDown"         // A websocket connection was closed, for whatever reason.
+	RequestTimedOut        EventType = "RequestTimedOut"        // No response was received for an outgoing request in time.
+	QueueOverflow          EventType = "QueueOverflow"          // An outgoing request was refused, because the request queue was full.
+	BootAccepted           EventType = "BootAccepted"           // A charging station was accepted via BootNotification.
+	TransactionStarted     EventType = "TransactionStarted"     // A transaction was started.
+	TransactionEnded       EventType = "TransactionEnded"       // A transaction was ended.
+	ConnectorStatusChanged EventType = "ConnectorStatusChanged" // The status of a connector changed.
+)
+
+// Event is implemented by all events published on a Bus. Subscribers may switch on the concrete type.
+type Event interface {
+	Type() EventType
+}
+
+// ConnectionUpEvent is published by websocket servers and clients, after a connection was established.
+type ConnectionUpEvent struct {
+	Peer string // The ID of the client, if published by a server. Empty, if published by a client.
+}
+
+// ConnectionDownEvent is published by websocket servers and clients, after a connection was closed.
+type ConnectionDownEvent struct {
+	Peer string // The ID of the client, if published by a server. Empty, if published by a client.
+	Err  error  // The reason, if the connection was lost. Nil, if it was closed on purpose, or the reason is unknown.
+}
+
+// RequestTimedOutEvent is published by the ocppj dispatchers, when a request was canceled after its timeout expired.
+type RequestTimedOutEvent struct {
+	Peer      string // The ID of the client, if published by a server. Empty, if published by a client.
+	RequestID string
+	Action    string
+}
+
+// QueueOverflowEvent is published by the ocppj dispatchers, when a request couldn't be enqueued, because the queue was full.
+type QueueOverflowEvent struct {
+	Peer      string // The ID of the client, if published by a server. Empty, if published by a client.
+	RequestID string
+	Action    string
+}
+
+// BootAcceptedEvent is published once a charging station was accepted by the central system.
+type BootAcceptedEvent struct {
+	StationID string        // The ID of the charging station. Empty, if published by the charging station itself.
+	Interval  time.Duration // The heartbeat interval returned by the central system.
+}
+
+// TransactionStartedEvent is published once a transaction was started.
+type TransactionStartedEvent struct {
+	StationID     string // The ID of the charging station. Empty, if published by the charging station itself.
+	TransactionID string
+	EvseID        int // The EVSE (OCPP 2.0.1) or connector (OCPP 1.6) the transaction takes place on, 0 if unknown.
+}
+
+// TransactionEndedEvent is published once a transaction was ended.
+type TransactionEndedEvent struct {
+	StationID     string // The ID of the charging station. Empty, if published by the charging station itself.
+	TransactionID string
+	EvseID        int // The EVSE (OCPP 2.0.1) or connector (OCPP 1.6) the transaction took place on, 0 if unknown.
+}
+
+// ConnectorStatusChangedEvent is published once the status of a connector changed.
+type ConnectorStatusChangedEvent struct {
+	StationID   string // The ID of the charging station. Empty, if published by the charging station itself.
+	EvseID      int    // The EVSE of the connector. Always 0 for OCPP 1.6.
+	ConnectorID int
+	Status      string
+}
+
+func (e ConnectionUpEvent) Type() EventType           { return ConnectionUp }
+func (e ConnectionDownEvent) Type() EventType         { return ConnectionDown }
+func (e RequestTimedOutEvent) Type() EventType        { return RequestTimedOut }
+func (e QueueOverflowEvent) Type() EventType          { return QueueOverflow }
+func (e BootAcceptedEvent) Type() EventType           { return BootAccepted }
+func (e TransactionStartedEvent) Type() EventType     { return TransactionStarted }
+func (e TransactionEndedEvent) Type() EventType       { return TransactionEnded }
+func (e ConnectorStatusChangedEvent) Type() EventType { return ConnectorStatusChanged }
+
+// DefaultBufferSize is the amount of events buffered per subscriber, if no buffer size is passed to NewBus.
+const DefaultBufferSize = 64
+
+type subscriber struct {
+	c       chan Event
+	filter  map[EventType]bool // Nil if all events are delivered.
+	dropped uint64
+}
+
+// Bus delivers published events to its subscribers.
+//
+// Publishing never blocks: each subscriber has a buffered channel, and events which don't fit into the buffer
+// are dropped and counted. Subscribers don't use any goroutine, so unsubscribing releases all resources.
+//
+// A nil *Bus is valid and discards all events, so that components may publish unconditionally.
+// A Bus is safe for concurrent use.
+type Bus struct {
+	bufferSize  int
+	subscribers map[<-chan Event]*subscriber
+	mutex       sync.RWMutex
+}
+
+// NewBus creates a new bus, buffering up to bufferSize events per subscriber.
+// If bufferSize isn't positive, DefaultBufferSize is used.
+func NewBus(bufferSize int) *Bus {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+	return &Bus{bufferSize: bufferSize, subscribers: map[<-chan Event]*subscriber{}}
+}
+
+// Subscribe returns a channel, receiving all published events of the passed types, or all events if no type is passed.
+// The returned function cancels the subscription and closes the channel. It may be invoked multiple times.
+func (b *Bus) Subscribe(filter ...EventType) (<-chan Event, func()) {
+	s := &subscriber{c: make(chan Event, b.bufferSize)}
+	if len(filter) > 0 {
+		s.filter = make(map[EventType]bool, len(filter))
+		for _, t := range filter {
+			s.filter[t] = true
+		}
+	}
+	b.mutex.Lock()
+	b.subscribers[s.c] = s
+	b.mutex.Unlock()
+	var once sync.Once
+	return s.c, func() {
+		once.Do(func() {
+			b.mutex.Lock()
+			delete(b.subscribers, s.c)
+			close(s.c)
+			b.mutex.Unlock()
+		})
+	}
+}
+
+// Publish delivers an event to all matching subscribers, without blocking.
+// The event is dropped for subscribers whose buffer is full.
+func (b *Bus) Publish(event Event) {
+	if b == nil || event == nil {
+		return
+	}
+	t := event.Type()
+	// Sending while holding the read lock prevents subscriptions from being closed concurrently
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	for _, s := range b.subscribers {
+		if s.filter != nil && !s.filter[t] {
+			continue
+		}
+		select {
+		case s.c <- event:
+		default:
+			s.dropped++
+		}
+	}
+}
+
+// Dropped returns the amount of events dropped for a subscriber, because its buffer was full.
+// Returns 0 for channels not subscribed to the bus.
+func (b *Bus) Dropped(c <-chan Event) uint64 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if s, ok := b.subscribers[c]; ok {
+		return s.dropped
+	}
+	return 0
+}
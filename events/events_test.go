@@ -0,0 +1,108 @@
+package events_test
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/events"
+)
+
+func TestSubscribeFilter(t *testing.T) {
+	bus := events.NewBus(10)
+	connectionC, unsubscribeConnections := bus.Subscribe(events.ConnectionUp, events.ConnectionDown)
+	defer unsubscribeConnections()
+	allC, unsubscribeAll := bus.Subscribe()
+	defer unsubscribeAll()
+	reason := errors.New("connection lost")
+	bus.Publish(events.ConnectionUpEvent{Peer: "cp1"})
+	bus.Publish(events.RequestTimedOutEvent{Peer: "cp1", RequestID: "1234", Action: "Reset"})
+	bus.Publish(events.ConnectionDownEvent{Peer: "cp1", Err: reason})
+	require.Len(t, connectionC, 2)
+	assert.Equal(t, events.ConnectionUpEvent{Peer: "cp1"}, <-connectionC)
+	assert.Equal(t, events.ConnectionDownEvent{Peer: "cp1", Err: reason}, <-connectionC)
+	require.Len(t, allC, 3)
+	assert.Equal(t, events.ConnectionUp, (<-allC).Type())
+	assert.Equal(t, events.RequestTimedOut, (<-allC).Type())
+	assert.Equal(t, events.ConnectionDown, (<-allC).Type())
+}
+
+func TestPublishDropsWhenFull(t *testing.T) {
+	bus := events.NewBus(2)
+	slowC, unsubscribeSlow := bus.Subscribe()
+	defer unsubscribeSlow()
+	otherC, unsubscribeOther := bus.Subscribe(events.QueueOverflow)
+	defer unsubscribeOther()
+	for i := 0; i < 5; i++ {
+		bus.Publish(events.QueueOverflowEvent{Peer: "cp1", RequestID: "1234", Action: "Reset"})
+	}
+	// Publishing never blocks, so events exceeding the buffer are dropped for each subscriber
+	assert.Len(t, slowC, 2)
+	assert.Equal(t, uint64(3), bus.Dropped(slowC))
+	assert.Len(t, otherC, 2)
+	assert.Equal(t, uint64(3), bus.Dropped(otherC))
+	<-slowC
+	bus.Publish(events.ConnectionUpEvent{Peer: "cp1"})
+	assert.Len(t, slowC, 2)
+	assert.Equal(t, uint64(3), bus.Dropped(slowC))
+	assert.Equal(t, uint64(3), bus.Dropped(otherC))
+}
+
+func TestUnsubscribe(t *testing.T) {
+	bus := events.NewBus(0)
+	eventC, unsubscribe := bus.Subscribe()
+	bus.Publish(events.ConnectionUpEvent{Peer: "cp1"})
+	unsubscribe()
+	unsubscribe()
+	bus.Publish(events.ConnectionDownEvent{Peer: "cp1"})
+	// Buffered events are still received, then the channel is closed
+	event, ok := <-eventC
+	assert.True(t, ok)
+	assert.Equal(t, events.ConnectionUpEvent{Peer: "cp1"}, event)
+	_, ok = <-eventC
+	assert.False(t, ok)
+	assert.Equal(t, uint64(0), bus.Dropped(eventC))
+}
+
+func TestNilBus(t *testing.T) {
+	var bus *events.Bus
+	assert.NotPanics(t, func() {
+		bus.Publish(events.ConnectionUpEvent{Peer: "cp1"})
+	})
+}
+
+func TestUnsubscribeDoesntLeak(t *testing.T) {
+	bus := events.NewBus(4)
+	before := runtime.NumGoroutine()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		eventC, unsubscribe := bus.Subscribe(events.ConnectionUp)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range eventC {
+			}
+		}()
+		bus.Publish(events.ConnectionUpEvent{Peer: "cp1"})
+		unsubscribe()
+	}
+	// Concurrent publishing and unsubscribing is safe
+	_, unsubscribe := bus.Subscribe()
+	go func() {
+		for i := 0; i < 100; i++ {
+			bus.Publish(events.ConnectionUpEvent{Peer: "cp1"})
+		}
+	}()
+	unsubscribe()
+	wg.Wait()
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before)
+}
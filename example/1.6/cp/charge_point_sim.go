@@ -10,6 +10,7 @@ import (
 
 	"github.com/sirupsen/logrus"
 
+	"github.com/lorenzodonini/ocpp-go/logging/logrusadapter"
 	ocpp16 "github.com/lorenzodonini/ocpp-go/ocpp1.6"
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/localauth"
@@ -166,8 +167,8 @@ func main() {
 	chargePoint.SetReservationHandler(handler)
 	chargePoint.SetRemoteTriggerHandler(handler)
 	chargePoint.SetSmartChargingHandler(handler)
-	ocppj.SetLogger(log.WithField("logger", "ocppj"))
-	ws.SetLogger(log.WithField("logger", "websocket"))
+	ocppj.SetLogger(logrusadapter.New(log.WithField("logger", "ocppj")))
+	ws.SetLogger(logrusadapter.New(log.WithField("logger", "websocket")))
 	// Connects to central system
 	err := chargePoint.Start(csUrl)
 	if err != nil {
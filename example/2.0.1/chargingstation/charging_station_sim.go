@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/lorenzodonini/ocpp-go/logging/logrusadapter"
 	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1"
 	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/availability"
 	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/localauth"
@@ -243,7 +244,7 @@ func main() {
 	chargingStation.SetSmartChargingHandler(handler)
 	chargingStation.SetTariffCostHandler(handler)
 	chargingStation.SetTransactionsHandler(handler)
-	ocppj.SetLogger(log)
+	ocppj.SetLogger(logrusadapter.New(log))
 	// Connects to central system
 	err := chargingStation.Start(csmsUrl)
 	if err != nil {
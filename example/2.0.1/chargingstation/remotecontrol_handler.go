@@ -144,3 +144,15 @@ func (handler *ChargingStationHandler) OnUnlockConnector(request *remotecontrol.
 	logDefault(request.GetFeatureName()).Infof("unlocked connector %v for EVSE %d", request.ConnectorID, request.EvseID)
 	return remotecontrol.NewUnlockConnectorResponse(remotecontrol.UnlockStatusUnlocked), nil
 }
+
+func (handler *ChargingStationHandler) OnUsePriorityCharging(request *remotecontrol.UsePriorityChargingRequest) (response *remotecontrol.UsePriorityChargingResponse, err error) {
+	for _, evse := range handler.evse {
+		if evse.currentTransaction == request.TransactionID {
+			// No priority charging profile is installed on the simulated charging station
+			logDefault(request.GetFeatureName()).Warnf("no priority charging profile available for transaction %v", request.TransactionID)
+			return remotecontrol.NewUsePriorityChargingResponse(remotecontrol.PriorityChargingStatusNoProfile), nil
+		}
+	}
+	logDefault(request.GetFeatureName()).Errorf("couldn't use priority charging for unknown transaction %v", request.TransactionID)
+	return remotecontrol.NewUsePriorityChargingResponse(remotecontrol.PriorityChargingStatusRejected), nil
+}
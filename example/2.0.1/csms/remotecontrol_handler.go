@@ -0,0 +1,13 @@
+package main
+
+import "github.com/lorenzodonini/ocpp-go/ocpp2.0.1/remotecontrol"
+
+func (c *CSMSHandler) OnNotifyPriorityCharging(chargingStationID string, request *remotecontrol.NotifyPriorityChargingRequest) (response *remotecontrol.NotifyPriorityChargingResponse, err error) {
+	if request.Activated {
+		logDefault(chargingStationID, request.GetFeatureName()).Infof("priority charging activated for transaction %v", request.TransactionID)
+	} else {
+		logDefault(chargingStationID, request.GetFeatureName()).Infof("priority charging stopped for transaction %v", request.TransactionID)
+	}
+	response = remotecontrol.NewNotifyPriorityChargingResponse()
+	return
+}
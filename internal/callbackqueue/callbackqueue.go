@@ -6,24 +6,39 @@ import (
 	"github.com/lorenzodonini/ocpp-go/ocpp"
 )
 
+type callbackEntry struct {
+	requestID string
+	callback  func(confirmation ocpp.Response, err error)
+}
+
 type CallbackQueue struct {
 	callbacksMutex sync.RWMutex
-	callbacks      map[string][]func(confirmation ocpp.Response, err error)
+	callbacks      map[string][]callbackEntry
 }
 
 func New() CallbackQueue {
 	return CallbackQueue{
-		callbacks: make(map[string][]func(confirmation ocpp.Response, err error)),
+		callbacks: make(map[string][]callbackEntry),
 	}
 }
 
 func (cq *CallbackQueue) TryQueue(id string, try func() error, callback func(confirmation ocpp.Response, err error)) error {
+	_, err := cq.TryQueueWithRequestID(id, func() (string, error) {
+		return "", try()
+	}, callback)
+	return err
+}
+
+// TryQueueWithRequestID works like TryQueue, but additionally associates the callback to the request ID returned by try.
+// The callback may later be retrieved out of order, using DequeueRequest.
+func (cq *CallbackQueue) TryQueueWithRequestID(id string, try func() (string, error), callback func(confirmation ocpp.Response, err error)) (string, error) {
 	cq.callbacksMutex.Lock()
 	defer cq.callbacksMutex.Unlock()
 
-	cq.callbacks[id] = append(cq.callbacks[id], callback)
+	cq.callbacks[id] = append(cq.callbacks[id], callbackEntry{callback: callback})
 
-	if err := try(); err != nil {
+	requestID, err := try()
+	if err != nil {
 		// pop off last element
 		callbacks := cq.callbacks[id]
 		cq.callbacks[id] = callbacks[:len(callbacks)-1]
@@ -31,10 +46,11 @@ func (cq *CallbackQueue) TryQueue(id string, try func() error, callback func(con
 			delete(cq.callbacks, id)
 		}
 
-		return err
+		return "", err
 	}
+	cq.callbacks[id][len(cq.callbacks[id])-1].requestID = requestID
 
-	return nil
+	return requestID, nil
 }
 
 func (cq *CallbackQueue) Dequeue(id string) (func(confirmation ocpp.Response, err error), bool) {
@@ -50,13 +66,32 @@ func (cq *CallbackQueue) Dequeue(id string) (func(confirmation ocpp.Response, er
 		panic("Internal CallbackQueue inconsistency")
 	}
 
-	callback := callbacks[0]
+	return cq.remove(id, 0), ok
+}
+
+// DequeueRequest retrieves and removes the callback associated to the given request ID.
+// If no callback was queued using TryQueueWithRequestID for the request ID, the function returns false.
+func (cq *CallbackQueue) DequeueRequest(id string, requestID string) (func(confirmation ocpp.Response, err error), bool) {
+	cq.callbacksMutex.Lock()
+	defer cq.callbacksMutex.Unlock()
+
+	for i, entry := range cq.callbacks[id] {
+		if entry.requestID == requestID {
+			return cq.remove(id, i), true
+		}
+	}
+	return nil, false
+}
+
+func (cq *CallbackQueue) remove(id string, index int) func(confirmation ocpp.Response, err error) {
+	callbacks := cq.callbacks[id]
+	callback := callbacks[index].callback
 
 	if len(callbacks) == 1 {
 		delete(cq.callbacks, id)
 	} else {
-		cq.callbacks[id] = callbacks[1:]
+		cq.callbacks[id] = append(callbacks[:index:index], callbacks[index+1:]...)
 	}
 
-	return callback, ok
+	return callback
 }
@@ -0,0 +1,193 @@
+// Package ftp contains a minimal FTP client, supporting passive mode binary transfers only.
+// It is shared by the file transfer helpers of the OCPP 2.0.1 functional blocks (e.g. log uploads and firmware downloads).
+package ftp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidResponse is returned if the server sent a malformed response.
+var ErrInvalidResponse = errors.New("invalid FTP response")
+
+// Error is returned if the server replied with an unexpected reply code.
+type Error struct {
+	Code    int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%v %v", e.Code, e.Message)
+}
+
+// Conn is a logged in control connection to an FTP server.
+type Conn struct {
+	ctx     context.Context
+	host    string
+	dialer  net.Dialer
+	conn    net.Conn
+	control *textproto.Conn
+	stopC   chan struct{}
+}
+
+// Dial connects to the server of the passed location and logs in. If the URL contains no credentials,
+// an anonymous login is performed. The connection is closed once ctx is canceled, aborting any blocking operation.
+// The timeout applies to establishing connections. Zero means no timeout.
+func Dial(ctx context.Context, location *url.URL, timeout time.Duration) (*Conn, error) {
+	host := location.Host
+	if location.Port() == "" {
+		host = net.JoinHostPort(location.Hostname(), "21")
+	}
+	c := &Conn{ctx: ctx, host: location.Hostname(), dialer: net.Dialer{Timeout: timeout}, stopC: make(chan struct{})}
+	conn, err := c.dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	c.conn = conn
+	c.control = textproto.NewConn(conn)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-c.stopC:
+		}
+	}()
+	if err = c.login(location); err != nil {
+		c.close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Conn) login(location *url.URL) error {
+	if _, err := c.response(2); err != nil {
+		return err
+	}
+	username, password := "anonymous", "anonymous"
+	if location.User != nil {
+		username = location.User.Username()
+		if p, ok := location.User.Password(); ok {
+			password = p
+		}
+	}
+	code, err := c.command(0, "USER %s", username)
+	if err != nil {
+		return err
+	}
+	if code == 331 || code == 332 {
+		code, err = c.command(0, "PASS %s", password)
+		if err != nil {
+			return err
+		}
+	}
+	if code != 230 && code != 202 {
+		return &Error{Code: code, Message: "login failed"}
+	}
+	_, err = c.command(2, "TYPE I")
+	return err
+}
+
+// Store uploads content to the passed path.
+func (c *Conn) Store(path string, content []byte) error {
+	data, err := c.passive()
+	if err != nil {
+		return err
+	}
+	defer data.Close()
+	if _, err = c.command(1, "STOR %s", path); err != nil {
+		return err
+	}
+	if _, err = data.Write(content); err != nil {
+		return err
+	}
+	if err = data.Close(); err != nil {
+		return err
+	}
+	_, err = c.response(2)
+	return err
+}
+
+// Retrieve downloads the file at the passed path.
+func (c *Conn) Retrieve(path string) ([]byte, error) {
+	data, err := c.passive()
+	if err != nil {
+		return nil, err
+	}
+	defer data.Close()
+	if _, err = c.command(1, "RETR %s", path); err != nil {
+		return nil, err
+	}
+	content, err := io.ReadAll(data)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = c.response(2); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+// Close logs out and closes the connection.
+func (c *Conn) Close() error {
+	_, _ = c.command(0, "QUIT")
+	return c.close()
+}
+
+func (c *Conn) close() error {
+	close(c.stopC)
+	return c.conn.Close()
+}
+
+// Sends a command on the control connection and reads the response, expecting the passed reply code class (see textproto.Reader.ReadResponse).
+func (c *Conn) command(expectCode int, format string, args ...interface{}) (int, error) {
+	if _, err := c.control.Cmd(format, args...); err != nil {
+		return 0, err
+	}
+	return c.response(expectCode)
+}
+
+func (c *Conn) response(expectCode int) (int, error) {
+	code, message, err := c.control.ReadResponse(expectCode)
+	if protocolErr, ok := err.(*textproto.Error); ok {
+		return code, &Error{Code: protocolErr.Code, Message: message}
+	}
+	return code, err
+}
+
+// Enters passive mode and opens the data connection. The address announced by the server is ignored,
+// as the data connection is always established to the host of the control connection.
+func (c *Conn) passive() (net.Conn, error) {
+	if _, err := c.control.Cmd("PASV"); err != nil {
+		return nil, err
+	}
+	_, message, err := c.control.ReadResponse(227)
+	if err != nil {
+		if protocolErr, ok := err.(*textproto.Error); ok {
+			return nil, &Error{Code: protocolErr.Code, Message: message}
+		}
+		return nil, err
+	}
+	start := strings.Index(message, "(")
+	end := strings.LastIndex(message, ")")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("%w: passive mode response %v", ErrInvalidResponse, message)
+	}
+	fields := strings.Split(message[start+1:end], ",")
+	if len(fields) != 6 {
+		return nil, fmt.Errorf("%w: passive mode response %v", ErrInvalidResponse, message)
+	}
+	high, err1 := strconv.Atoi(strings.TrimSpace(fields[4]))
+	low, err2 := strconv.Atoi(strings.TrimSpace(fields[5]))
+	if err1 != nil || err2 != nil {
+		return nil, fmt.Errorf("%w: passive mode response %v", ErrInvalidResponse, message)
+	}
+	return c.dialer.DialContext(c.ctx, "tcp", net.JoinHostPort(c.host, strconv.Itoa(high<<8|low)))
+}
@@ -0,0 +1,319 @@
+// Package golden guards the wire format of OCPP payloads against accidental changes.
+//
+// For every feature of the passed profiles, a fully populated and a minimally populated request and response
+// are generated via reflection, marshaled and compared against the checked-in golden files of the calling test package.
+// Newly added features are picked up automatically, since the features are taken from the profiles themselves:
+//
+//	var updateGolden = flag.Bool("update", false, "update the golden payloads in testdata/golden")
+//
+//	func TestGoldenPayloads(t *testing.T) {
+//		golden.Run(t, filepath.Join("testdata", "golden"), *updateGolden, ocpp16.Profiles()...)
+//	}
+//
+// Intentional changes to the wire format are recorded by running the tests with the -update flag,
+// and reviewing the resulting diff of the golden files.
+package golden
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+)
+
+// The base time of all generated timestamps. Generated timestamps differ by whole minutes.
+var baseTime = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// The contents of a golden file, one per feature.
+type file struct {
+	Request  pair `json:"request"`
+	Response pair `json:"response"`
+}
+
+type pair struct {
+	Full    json.RawMessage `json:"full"`
+	Minimal json.RawMessage `json:"minimal"`
+}
+
+// Run compares the payloads of all features of the passed profiles against the golden files in dir,
+// stored as <profile>/<feature>.json. If update is set, the golden files are rewritten instead.
+//
+// Golden files without a matching feature are reported as well, so that removed or renamed features are noticed.
+func Run(t *testing.T, dir string, update bool, profiles ...*ocpp.Profile) {
+	expectedFiles := map[string]bool{}
+	for _, profile := range profiles {
+		names := make([]string, 0, len(profile.Features))
+		for name := range profile.Features {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			feature := profile.Features[name]
+			path := filepath.Join(dir, profile.Name, name+".json")
+			expectedFiles[path] = true
+			t.Run(profile.Name+"/"+name, func(t *testing.T) {
+				runFeature(t, path, feature, update)
+			})
+		}
+	}
+	if update {
+		return
+	}
+	_ = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && !expectedFiles[path] {
+			assert.Fail(t, "stale golden file", "%v doesn't belong to any registered feature", path)
+		}
+		return nil
+	})
+}
+
+func runFeature(t *testing.T, path string, feature ocpp.Feature, update bool) {
+	payloads := []struct {
+		name        string
+		payloadType reflect.Type
+		golden      func(f *file) *pair
+	}{
+		{"request", feature.GetRequestType(), func(f *file) *pair { return &f.Request }},
+		{"response", feature.GetResponseType(), func(f *file) *pair { return &f.Response }},
+	}
+	var actual file
+	for _, payload := range payloads {
+		full := Populate(payload.payloadType, true)
+		minimal := Populate(payload.payloadType, false)
+		p := payload.golden(&actual)
+		p.Full = marshal(t, full)
+		p.Minimal = marshal(t, minimal)
+		checkOmitted(t, full, p.Full, payload.name+", fully populated")
+		checkOmitted(t, minimal, p.Minimal, payload.name+", minimally populated")
+	}
+	if update {
+		data, err := json.MarshalIndent(actual, "", "  ")
+		require.NoError(t, err)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, append(data, '\n'), 0o644))
+		return
+	}
+	data, err := os.ReadFile(path)
+	require.NoError(t, err, "golden file missing, run the tests with -update to create it")
+	var golden file
+	require.NoError(t, json.Unmarshal(data, &golden))
+	for _, payload := range payloads {
+		expected, p := payload.golden(&golden), payload.golden(&actual)
+		assertSemanticEqual(t, expected.Full, p.Full, payload.name+", fully populated")
+		assertSemanticEqual(t, expected.Minimal, p.Minimal, payload.name+", minimally populated")
+		decode(t, expected.Full, Populate(payload.payloadType, true), payload.name+", fully populated")
+		decode(t, expected.Minimal, Populate(payload.payloadType, false), payload.name+", minimally populated")
+	}
+}
+
+// Decodes a golden payload, which must yield the generated value.
+// Unknown fields are rejected, so that renamed fields are noticed even if the marshaled payload was regenerated.
+func decode(t *testing.T, data json.RawMessage, expected reflect.Value, name string) {
+	decoded := reflect.New(expected.Type().Elem())
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if assert.NoError(t, decoder.Decode(decoded.Interface()), "decoding golden %v", name) {
+		assert.Equal(t, expected.Interface(), decoded.Interface(), "decoded golden %v", name)
+	}
+}
+
+func marshal(t *testing.T, v reflect.Value) json.RawMessage {
+	data, err := json.Marshal(v.Interface())
+	require.NoError(t, err)
+	return data
+}
+
+func assertSemanticEqual(t *testing.T, expected json.RawMessage, actual json.RawMessage, name string) {
+	var expectedValue, actualValue interface{}
+	require.NoError(t, json.Unmarshal(expected, &expectedValue), name)
+	require.NoError(t, json.Unmarshal(actual, &actualValue), name)
+	assert.Equal(t, expectedValue, actualValue, "wire format of the %v changed, run the tests with -update if intended", name)
+}
+
+// Populate creates a new value of the passed struct type and returns a pointer to it.
+//
+// If full is set, all exported fields are populated recursively. Otherwise only mandatory fields are populated,
+// i.e. fields which are always marshaled, since they lack the omitempty option.
+// Generated values are derived from the JSON path of each field, so they don't change if fields are reordered.
+func Populate(payloadType reflect.Type, full bool) reflect.Value {
+	v := reflect.New(payloadType)
+	populate(v.Elem(), full, "$", map[reflect.Type]bool{})
+	return v
+}
+
+func populate(v reflect.Value, full bool, path string, visiting map[reflect.Type]bool) {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(path[strings.LastIndex(path, ".")+1:])
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(int64(seed(path)))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(uint64(seed(path)))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(float64(seed(path)) + 0.5)
+	case reflect.Interface:
+		if v.NumMethod() == 0 {
+			v.Set(reflect.ValueOf(path[strings.LastIndex(path, ".")+1:]))
+		}
+	case reflect.Ptr:
+		if visiting[v.Type().Elem()] {
+			return
+		}
+		elem := reflect.New(v.Type().Elem())
+		populate(elem.Elem(), full, path, visiting)
+		v.Set(elem)
+	case reflect.Slice:
+		if visiting[v.Type().Elem()] {
+			return
+		}
+		slice := reflect.MakeSlice(v.Type(), 1, 1)
+		populate(slice.Index(0), full, path, visiting)
+		v.Set(slice)
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			populate(v.Index(i), full, fmt.Sprintf("%v.%v", path, i), visiting)
+		}
+	case reflect.Map:
+		m := reflect.MakeMap(v.Type())
+		key := reflect.New(v.Type().Key()).Elem()
+		populate(key, full, path+".key", visiting)
+		value := reflect.New(v.Type().Elem()).Elem()
+		populate(value, full, path+".value", visiting)
+		m.SetMapIndex(key, value)
+		v.Set(m)
+	case reflect.Struct:
+		if v.Type() == reflect.TypeOf(time.Time{}) {
+			v.Set(reflect.ValueOf(baseTime.Add(time.Duration(seed(path)) * time.Minute)))
+			return
+		}
+		visiting[v.Type()] = true
+		defer delete(visiting, v.Type())
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			name, omitEmpty, skip := jsonField(field)
+			if skip || (!full && omitEmpty) {
+				continue
+			}
+			populate(v.Field(i), full, path+"."+name, visiting)
+		}
+	}
+}
+
+// Derives a deterministic number between 1 and 100 from the path of a field.
+func seed(path string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(path))
+	return h.Sum32()%100 + 1
+}
+
+// Returns the JSON name of a struct field, and whether it is omitted if empty. Unexported fields are skipped.
+func jsonField(field reflect.StructField) (name string, omitEmpty bool, skip bool) {
+	if field.PkgPath != "" && !field.Anonymous {
+		return "", false, true
+	}
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, true
+	}
+	parts := strings.Split(tag, ",")
+	name = parts[0]
+	if name == "" {
+		name = field.Name
+	}
+	for _, option := range parts[1:] {
+		if option == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return name, omitEmpty, false
+}
+
+// Asserts that optional fields, which are empty, are omitted from the marshaled payload instead of being emitted
+// as null or as zero value. A field is optional if it is marked with omitempty for either marshaling or validation.
+func checkOmitted(t *testing.T, v reflect.Value, data json.RawMessage, name string) {
+	var decoded interface{}
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	checkOmittedValue(t, v, decoded, name, "$")
+}
+
+func checkOmittedValue(t *testing.T, v reflect.Value, decoded interface{}, name string, path string) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		list, ok := decoded.([]interface{})
+		if !ok {
+			return
+		}
+		for i := 0; i < v.Len() && i < len(list); i++ {
+			checkOmittedValue(t, v.Index(i), list[i], name, fmt.Sprintf("%v[%v]", path, i))
+		}
+	case reflect.Struct:
+		if _, ok := v.Addr().Interface().(json.Marshaler); ok {
+			return
+		}
+		object, ok := decoded.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			jsonName, omitEmpty, skip := jsonField(field)
+			if skip {
+				continue
+			}
+			if field.Anonymous && field.Tag.Get("json") == "" {
+				// Fields of embedded structs are promoted to the enclosing object
+				checkOmittedValue(t, v.Field(i), decoded, name, path)
+				continue
+			}
+			fieldPath := path + "." + jsonName
+			value, present := object[jsonName]
+			optional := omitEmpty || strings.HasPrefix(field.Tag.Get("validate"), "omitempty")
+			if optional && isEmpty(v.Field(i)) {
+				assert.False(t, present, "%v: optional field %v is emitted as %v instead of being omitted", name, fieldPath, value)
+				continue
+			}
+			if present {
+				checkOmittedValue(t, v.Field(i), value, name, fieldPath)
+			}
+		}
+	}
+}
+
+// Reports whether a value is omitted by the omitempty option of encoding/json.
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
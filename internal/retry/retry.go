@@ -0,0 +1,56 @@
+// Package retry implements the retry loop shared by the firmware and log transfer managers,
+// which follows the optional retries and retryInterval fields of the respective OCPP requests.
+package retry
+
+import (
+	"context"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/clock"
+)
+
+// Policy defines how often and in which interval an operation is attempted.
+type Policy struct {
+	Attempts int           // The total amount of attempts, including the first one.
+	Interval time.Duration // The delay between two attempts.
+	Clock    clock.Clock   // The clock used for waiting between attempts. Defaults to the real clock.
+}
+
+// FromRequest creates a policy from the optional retries and retryInterval (in seconds) fields of a request.
+func FromRequest(retries *int, retryInterval *int, clk clock.Clock) Policy {
+	policy := Policy{Attempts: 1, Clock: clk}
+	if retries != nil {
+		policy.Attempts += *retries
+	}
+	if retryInterval != nil {
+		policy.Interval = time.Duration(*retryInterval) * time.Second
+	}
+	return policy
+}
+
+// Run invokes attempt until it returns true, or until all attempts were made, waiting for the interval in between.
+// The index of the attempt, starting at 0, is passed to attempt.
+//
+// Returns false if ctx was done before attempt returned true, or before all attempts were made.
+func (p Policy) Run(ctx context.Context, attempt func(n int) bool) bool {
+	clk := clock.OrDefault(p.Clock)
+	for n := 0; n < p.Attempts; n++ {
+		if n > 0 && p.Interval > 0 {
+			timer := clk.NewTimer(p.Interval)
+			select {
+			case <-timer.C():
+			case <-ctx.Done():
+				timer.Stop()
+				return false
+			}
+		}
+		done := attempt(n)
+		if ctx.Err() != nil {
+			return false
+		}
+		if done {
+			return true
+		}
+	}
+	return ctx.Err() == nil
+}
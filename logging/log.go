@@ -1,9 +1,43 @@
 package logging
 
-// Logger is the adapter interface that needs to be implemented, if the library should internally print logs.
+import (
+	"fmt"
+	"strings"
+)
+
+// Keys of the fields attached to log entries by the library.
+const (
+	FieldChargePointID = "charge_point_id" // The ID of the charge point/charging station the entry refers to.
+	FieldAction        = "action"          // The OCPP action (feature name) of the message the entry refers to.
+	FieldUniqueID      = "unique_id"       // The unique ID of the message the entry refers to.
+)
+
+// Field is a key-value pair, which is attached to all log entries of a Logger.
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// ChargePointID returns a field containing the ID of a charge point/charging station.
+func ChargePointID(id string) Field {
+	return Field{Key: FieldChargePointID, Value: id}
+}
+
+// Action returns a field containing the action of an OCPP message.
+func Action(action string) Field {
+	return Field{Key: FieldAction, Value: action}
+}
+
+// UniqueID returns a field containing the unique ID of an OCPP message.
+func UniqueID(id string) Field {
+	return Field{Key: FieldUniqueID, Value: id}
+}
+
+// BasicLogger is the minimal adapter interface that needs to be implemented, if the library should internally print logs.
 //
-// This allows to hook up your logger of choice.
-type Logger interface {
+// This allows to hook up your logger of choice. Loggers not supporting structured fields may be converted
+// into a Logger via FromBasic.
+type BasicLogger interface {
 	Debug(args ...interface{})
 	Debugf(format string, args ...interface{})
 	Info(args ...interface{})
@@ -12,6 +46,64 @@ type Logger interface {
 	Errorf(format string, args ...interface{})
 }
 
+// Logger is a structured logger, which is used internally by the library.
+//
+// The library enriches log entries with contextual fields, such as the charge point ID and the action or unique ID
+// of the processed message, by deriving new loggers via With.
+// Adapters for log/slog and logrus are available in the slogadapter and logrusadapter subpackages.
+type Logger interface {
+	BasicLogger
+	// With returns a logger attaching the passed fields to every entry, in addition to the fields of the original logger.
+	// The original logger must not be modified.
+	With(fields ...Field) Logger
+}
+
+// FromBasic converts a BasicLogger into a Logger. If the logger already is a Logger, it is returned as is.
+//
+// Fields are appended to each log message in the key=value format.
+func FromBasic(logger BasicLogger) Logger {
+	if l, ok := logger.(Logger); ok {
+		return l
+	}
+	return &basicLogger{logger: logger}
+}
+
+type basicLogger struct {
+	logger       BasicLogger
+	suffix       string
+	formatSuffix string // The suffix, escaped for use within a format string
+}
+
+func (l *basicLogger) Debug(args ...interface{}) { l.logger.Debug(l.args(args)...) }
+func (l *basicLogger) Debugf(format string, args ...interface{}) {
+	l.logger.Debugf(format+l.formatSuffix, args...)
+}
+func (l *basicLogger) Info(args ...interface{}) { l.logger.Info(l.args(args)...) }
+func (l *basicLogger) Infof(format string, args ...interface{}) {
+	l.logger.Infof(format+l.formatSuffix, args...)
+}
+func (l *basicLogger) Error(args ...interface{}) { l.logger.Error(l.args(args)...) }
+func (l *basicLogger) Errorf(format string, args ...interface{}) {
+	l.logger.Errorf(format+l.formatSuffix, args...)
+}
+
+func (l *basicLogger) With(fields ...Field) Logger {
+	var sb strings.Builder
+	sb.WriteString(l.suffix)
+	for _, f := range fields {
+		sb.WriteString(fmt.Sprintf(" %s=%v", f.Key, f.Value))
+	}
+	suffix := sb.String()
+	return &basicLogger{logger: l.logger, suffix: suffix, formatSuffix: strings.ReplaceAll(suffix, "%", "%%")}
+}
+
+func (l *basicLogger) args(args []interface{}) []interface{} {
+	if l.suffix == "" {
+		return args
+	}
+	return append(args[:len(args):len(args)], l.suffix)
+}
+
 // VoidLogger is an empty implementation of the Logger interface, which doesn't actually process any logs.
 // It may be used as a dummy implementation, if no logs should be visible.
 type VoidLogger struct{}
@@ -22,3 +114,4 @@ func (l *VoidLogger) Info(args ...interface{})                  {}
 func (l *VoidLogger) Infof(format string, args ...interface{})  {}
 func (l *VoidLogger) Error(args ...interface{})                 {}
 func (l *VoidLogger) Errorf(format string, args ...interface{}) {}
+func (l *VoidLogger) With(fields ...Field) Logger               { return l }
@@ -0,0 +1,28 @@
+// Package logrusadapter provides a logging.Logger backed by logrus.
+package logrusadapter
+
+import (
+	"github.com/sirupsen/logrus"
+
+	"github.com/lorenzodonini/ocpp-go/logging"
+)
+
+type logger struct {
+	logrus.FieldLogger
+}
+
+// New returns a logging.Logger writing to the passed logrus logger or entry.
+// Fields are attached to the entries as logrus fields.
+//
+//	ocppj.SetLogger(logrusadapter.New(logrus.StandardLogger()))
+func New(l logrus.FieldLogger) logging.Logger {
+	return &logger{FieldLogger: l}
+}
+
+func (l *logger) With(fields ...logging.Field) logging.Logger {
+	logrusFields := make(logrus.Fields, len(fields))
+	for _, f := range fields {
+		logrusFields[f.Key] = f.Value
+	}
+	return &logger{FieldLogger: l.FieldLogger.WithFields(logrusFields)}
+}
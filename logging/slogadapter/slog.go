@@ -0,0 +1,66 @@
+//go:build go1.21
+
+// Package slogadapter provides a logging.Logger backed by log/slog.
+package slogadapter
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/lorenzodonini/ocpp-go/logging"
+)
+
+type logger struct {
+	logger *slog.Logger
+}
+
+// New returns a logging.Logger writing to the passed slog logger. If l is nil, slog.Default() is used.
+// Fields are attached to the records as attributes.
+//
+//	ocppj.SetLogger(slogadapter.New(slog.Default()))
+func New(l *slog.Logger) logging.Logger {
+	if l == nil {
+		l = slog.Default()
+	}
+	return &logger{logger: l}
+}
+
+func (l *logger) log(level slog.Level, msg func() string) {
+	ctx := context.Background()
+	if l.logger.Enabled(ctx, level) {
+		l.logger.Log(ctx, level, msg())
+	}
+}
+
+func (l *logger) Debug(args ...interface{}) {
+	l.log(slog.LevelDebug, func() string { return fmt.Sprint(args...) })
+}
+
+func (l *logger) Debugf(format string, args ...interface{}) {
+	l.log(slog.LevelDebug, func() string { return fmt.Sprintf(format, args...) })
+}
+
+func (l *logger) Info(args ...interface{}) {
+	l.log(slog.LevelInfo, func() string { return fmt.Sprint(args...) })
+}
+
+func (l *logger) Infof(format string, args ...interface{}) {
+	l.log(slog.LevelInfo, func() string { return fmt.Sprintf(format, args...) })
+}
+
+func (l *logger) Error(args ...interface{}) {
+	l.log(slog.LevelError, func() string { return fmt.Sprint(args...) })
+}
+
+func (l *logger) Errorf(format string, args ...interface{}) {
+	l.log(slog.LevelError, func() string { return fmt.Sprintf(format, args...) })
+}
+
+func (l *logger) With(fields ...logging.Field) logging.Logger {
+	args := make([]interface{}, 0, len(fields))
+	for _, f := range fields {
+		args = append(args, slog.Any(f.Key, f.Value))
+	}
+	return &logger{logger: l.logger.With(args...)}
+}
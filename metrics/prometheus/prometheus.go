@@ -0,0 +1,353 @@
+// Package prometheus exposes the metrics of an OCPP server to Prometheus.
+//
+// It implements the ws.MetricsCollector, ws.WriteDurationCollector, ocppj.MetricsCollector, ocppj.ThrottleCollector,
+// ocppj.UnknownActionCollector and ocppj.HandlerDeadlineCollector interfaces, and samples the state of
+// connected clients via the Stats function of the server. It is a separate module,
+// so that the core library doesn't depend on the Prometheus client.
+//
+// To instrument a 1.6 central system or a 2.0.1 CSMS, use:
+//
+//	collector, err := prometheus.Instrument(csms, prometheus.Options{MaxChargePoints: 100})
+//
+// The function must be invoked before starting the server.
+package prometheus
+
+import (
+	"sync"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/lorenzodonini/ocpp-go/ws"
+)
+
+// Label values of the direction label.
+const (
+	DirectionOutgoing = "outgoing" // Sent to a charge point.
+	DirectionIncoming = "incoming" // Received from a charge point.
+)
+
+// OtherChargePoints is the value of the charge_point_id label for all charge points exceeding Options.MaxChargePoints.
+const OtherChargePoints = "other"
+
+const defaultNamespace = "ocpp"
+
+// Options configures the metrics exposed by a Collector.
+type Options struct {
+	// The registerer the collector is registered with. Defaults to prometheus.DefaultRegisterer.
+	Registerer prom.Registerer
+	// The namespace of all metric names. Defaults to "ocpp".
+	Namespace string
+	// If greater than zero, metrics are labelled with the ID of the charge point.
+	// To guard against unbounded cardinality, only the first MaxChargePoints IDs get a dedicated label value,
+	// all further charge points are aggregated under OtherChargePoints.
+	MaxChargePoints int
+	// The buckets of the request duration histogram. Defaults to prometheus.DefBuckets.
+	DurationBuckets []float64
+}
+
+// Server is the interface needed for instrumenting a server.
+// It is implemented by the 1.6 CentralSystem, the 2.0.1 CSMS and the ocppj.Server.
+type Server interface {
+	SetMetricsCollector(collector ocppj.MetricsCollector)
+	Stats() []ocppj.ClientStats
+}
+
+// Collector records the metrics of an OCPP server.
+// It implements ws.MetricsCollector, ws.WriteDurationCollector, ocppj.MetricsCollector, ocppj.ThrottleCollector,
+// ocppj.UnknownActionCollector, ocppj.HandlerDeadlineCollector and prometheus.Collector.
+type Collector struct {
+	requests        *prom.CounterVec
+	requestDuration *prom.HistogramVec
+	throttled       *prom.CounterVec
+	unknownActions  *prom.CounterVec
+	slowHandlers    *prom.CounterVec
+	lateResponses   *prom.CounterVec
+	connections     *prom.CounterVec
+	messages        *prom.CounterVec
+	messageBytes    *prom.CounterVec
+	writeDuration   *prom.CounterVec
+	connected       *prom.Desc
+	queued          *prom.Desc
+	pending         *prom.Desc
+	stats           func() []ocppj.ClientStats
+	guard           *labelGuard
+}
+
+// New creates a Collector and registers it with the configured registerer.
+//
+// The collector doesn't sample the state of connected clients. Use Instrument for that.
+func New(opts Options) (*Collector, error) {
+	return newCollector(opts, nil)
+}
+
+// Instrument creates a Collector, registers it and sets it as the metrics collector of the server.
+// In addition, the amount of connected charge points, queued requests and pending requests
+// is sampled from the server whenever the metrics are scraped.
+//
+// The function must be invoked before starting the server.
+func Instrument(server Server, opts Options) (*Collector, error) {
+	c, err := newCollector(opts, server.Stats)
+	if err != nil {
+		return nil, err
+	}
+	server.SetMetricsCollector(c)
+	return c, nil
+}
+
+func newCollector(opts Options, stats func() []ocppj.ClientStats) (*Collector, error) {
+	namespace := opts.Namespace
+	if namespace == "" {
+		namespace = defaultNamespace
+	}
+	registerer := opts.Registerer
+	if registerer == nil {
+		registerer = prom.DefaultRegisterer
+	}
+	buckets := opts.DurationBuckets
+	if buckets == nil {
+		buckets = prom.DefBuckets
+	}
+	guard := &labelGuard{max: opts.MaxChargePoints, seen: map[string]struct{}{}}
+	// The charge point label is appended to all per-charge point metrics, if enabled
+	labels := func(names ...string) []string {
+		if guard.enabled() {
+			return append(names, "charge_point_id")
+		}
+		return names
+	}
+	c := &Collector{
+		requests: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "requests_total",
+			Help:      "Total number of completed OCPP requests.",
+		}, labels("direction", "action", "outcome", "error_code")),
+		requestDuration: prom.NewHistogramVec(prom.HistogramOpts{
+			Namespace: namespace,
+			Name:      "request_duration_seconds",
+			Help:      "Duration of OCPP requests, from enqueuing or receiving the request until the response.",
+			Buckets:   buckets,
+		}, []string{"direction", "action", "outcome"}),
+		throttled: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "request_throttled_seconds_total",
+			Help:      "Total time outgoing OCPP requests were held back by the minimum send interval.",
+		}, labels("action")),
+		unknownActions: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "unknown_actions_total",
+			Help:      "Total number of received OCPP requests with an unsupported action.",
+		}, labels("action")),
+		slowHandlers: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "handler_deadline_exceeded_total",
+			Help:      "Total number of received OCPP requests, whose handler didn't respond before the deadline.",
+		}, labels("action")),
+		lateResponses: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "late_responses_discarded_total",
+			Help:      "Total number of responses discarded, since the handler responded after the deadline.",
+		}, labels("action")),
+		connections: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "websocket_connection_events_total",
+			Help:      "Total number of opened and closed websocket connections.",
+		}, labels("event")),
+		messages: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "websocket_messages_total",
+			Help:      "Total number of websocket messages exchanged with charge points.",
+		}, labels("direction")),
+		messageBytes: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "websocket_message_bytes_total",
+			Help:      "Total size of the websocket messages exchanged with charge points.",
+		}, labels("direction")),
+		writeDuration: prom.NewCounterVec(prom.CounterOpts{
+			Namespace: namespace,
+			Name:      "websocket_write_seconds_total",
+			Help:      "Total time spent writing websocket messages to charge points.",
+		}, labels()),
+		connected: prom.NewDesc(prom.BuildFQName(namespace, "", "connected_charge_points"),
+			"Number of currently connected charge points.", nil, nil),
+		queued: prom.NewDesc(prom.BuildFQName(namespace, "", "queued_requests"),
+			"Number of requests waiting to be sent to charge points.", labels(), nil),
+		pending: prom.NewDesc(prom.BuildFQName(namespace, "", "pending_requests"),
+			"Number of requests sent to charge points, awaiting a response.", labels(), nil),
+		stats: stats,
+		guard: guard,
+	}
+	if err := registerer.Register(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Returns the label values for a metric, appending the charge point label if enabled.
+func (c *Collector) labelValues(chargePointID string, values ...string) []string {
+	if c.guard.enabled() {
+		return append(values, c.guard.value(chargePointID))
+	}
+	return values
+}
+
+// RequestCompleted implements ocppj.MetricsCollector.
+func (c *Collector) RequestCompleted(info ocppj.SpanInfo, outcome ocppj.RequestOutcome, errorCode ocpp.ErrorCode, duration time.Duration) {
+	direction := DirectionOutgoing
+	if info.Kind == ocppj.SpanKindIncoming {
+		direction = DirectionIncoming
+	}
+	c.requests.WithLabelValues(c.labelValues(info.ClientID, direction, info.Action, string(outcome), string(errorCode))...).Inc()
+	c.requestDuration.WithLabelValues(direction, info.Action, string(outcome)).Observe(duration.Seconds())
+}
+
+// RequestThrottled implements ocppj.ThrottleCollector.
+func (c *Collector) RequestThrottled(info ocppj.SpanInfo, delay time.Duration) {
+	c.throttled.WithLabelValues(c.labelValues(info.ClientID, info.Action)...).Add(delay.Seconds())
+}
+
+// UnknownAction implements ocppj.UnknownActionCollector.
+func (c *Collector) UnknownAction(info ocppj.SpanInfo) {
+	c.unknownActions.WithLabelValues(c.labelValues(info.ClientID, info.Action)...).Inc()
+}
+
+// HandlerDeadlineExceeded implements ocppj.HandlerDeadlineCollector.
+func (c *Collector) HandlerDeadlineExceeded(info ocppj.SpanInfo, deadline time.Duration) {
+	c.slowHandlers.WithLabelValues(c.labelValues(info.ClientID, info.Action)...).Inc()
+}
+
+// LateResponseDiscarded implements ocppj.HandlerDeadlineCollector.
+func (c *Collector) LateResponseDiscarded(info ocppj.SpanInfo, elapsed time.Duration) {
+	c.lateResponses.WithLabelValues(c.labelValues(info.ClientID, info.Action)...).Inc()
+}
+
+// ConnectionOpened implements ws.MetricsCollector.
+func (c *Collector) ConnectionOpened(id string) {
+	c.connections.WithLabelValues(c.labelValues(id, "opened")...).Inc()
+}
+
+// ConnectionClosed implements ws.MetricsCollector.
+func (c *Collector) ConnectionClosed(id string) {
+	c.connections.WithLabelValues(c.labelValues(id, "closed")...).Inc()
+}
+
+// MessageReceived implements ws.MetricsCollector.
+func (c *Collector) MessageReceived(id string, size int) {
+	labels := c.labelValues(id, DirectionIncoming)
+	c.messages.WithLabelValues(labels...).Inc()
+	c.messageBytes.WithLabelValues(labels...).Add(float64(size))
+}
+
+// MessageSent implements ws.MetricsCollector.
+func (c *Collector) MessageSent(id string, size int) {
+	labels := c.labelValues(id, DirectionOutgoing)
+	c.messages.WithLabelValues(labels...).Inc()
+	c.messageBytes.WithLabelValues(labels...).Add(float64(size))
+}
+
+// MessageWriteDuration implements ws.WriteDurationCollector.
+// Dividing the rate of the counter by the rate of outgoing messages yields the average write duration.
+func (c *Collector) MessageWriteDuration(id string, duration time.Duration) {
+	c.writeDuration.WithLabelValues(c.labelValues(id)...).Add(duration.Seconds())
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prom.Desc) {
+	c.requests.Describe(ch)
+	c.requestDuration.Describe(ch)
+	c.throttled.Describe(ch)
+	c.unknownActions.Describe(ch)
+	c.slowHandlers.Describe(ch)
+	c.lateResponses.Describe(ch)
+	c.connections.Describe(ch)
+	c.messages.Describe(ch)
+	c.messageBytes.Describe(ch)
+	c.writeDuration.Describe(ch)
+	ch <- c.connected
+	ch <- c.queued
+	ch <- c.pending
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prom.Metric) {
+	c.requests.Collect(ch)
+	c.requestDuration.Collect(ch)
+	c.throttled.Collect(ch)
+	c.unknownActions.Collect(ch)
+	c.slowHandlers.Collect(ch)
+	c.lateResponses.Collect(ch)
+	c.connections.Collect(ch)
+	c.messages.Collect(ch)
+	c.messageBytes.Collect(ch)
+	c.writeDuration.Collect(ch)
+	if c.stats == nil {
+		return
+	}
+	stats := c.stats()
+	ch <- prom.MustNewConstMetric(c.connected, prom.GaugeValue, float64(len(stats)))
+	// Charge points sharing a label value are summed up
+	type sample struct{ queued, pending int }
+	samples := map[string]*sample{}
+	var order []string
+	for _, s := range stats {
+		key := ""
+		if c.guard.enabled() {
+			key = c.guard.value(s.ClientID)
+		}
+		entry, ok := samples[key]
+		if !ok {
+			entry = &sample{}
+			samples[key] = entry
+			order = append(order, key)
+		}
+		entry.queued += s.QueuedRequests
+		entry.pending += s.PendingRequests
+	}
+	if !c.guard.enabled() && len(order) == 0 {
+		// Always expose the aggregated gauges, even without connected charge points
+		samples[""] = &sample{}
+		order = append(order, "")
+	}
+	for _, key := range order {
+		var labelValues []string
+		if c.guard.enabled() {
+			labelValues = []string{key}
+		}
+		ch <- prom.MustNewConstMetric(c.queued, prom.GaugeValue, float64(samples[key].queued), labelValues...)
+		ch <- prom.MustNewConstMetric(c.pending, prom.GaugeValue, float64(samples[key].pending), labelValues...)
+	}
+}
+
+// labelGuard limits the amount of distinct charge point IDs used as label values.
+type labelGuard struct {
+	max   int
+	seen  map[string]struct{}
+	mutex sync.Mutex
+}
+
+func (g *labelGuard) enabled() bool {
+	return g.max > 0
+}
+
+// Returns the label value for a charge point. IDs exceeding the maximum are mapped to OtherChargePoints.
+func (g *labelGuard) value(chargePointID string) string {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if _, ok := g.seen[chargePointID]; ok {
+		return chargePointID
+	}
+	if len(g.seen) >= g.max {
+		return OtherChargePoints
+	}
+	g.seen[chargePointID] = struct{}{}
+	return chargePointID
+}
+
+var _ ws.MetricsCollector = (*Collector)(nil)
+var _ ws.WriteDurationCollector = (*Collector)(nil)
+var _ ocppj.MetricsCollector = (*Collector)(nil)
+var _ ocppj.ThrottleCollector = (*Collector)(nil)
+var _ ocppj.UnknownActionCollector = (*Collector)(nil)
+var _ ocppj.HandlerDeadlineCollector = (*Collector)(nil)
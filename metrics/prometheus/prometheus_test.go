@@ -0,0 +1,225 @@
+package prometheus
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ocpp2 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+)
+
+type provisioningHandler struct{}
+
+func (h *provisioningHandler) OnBootNotification(chargingStationID string, request *provisioning.BootNotificationRequest) (*provisioning.BootNotificationResponse, error) {
+	return provisioning.NewBootNotificationResponse(types.NewDateTime(time.Now()), 60, provisioning.RegistrationStatusAccepted), nil
+}
+
+func (h *provisioningHandler) OnNotifyReport(chargingStationID string, request *provisioning.NotifyReportRequest) (*provisioning.NotifyReportResponse, error) {
+	return provisioning.NewNotifyReportResponse(), nil
+}
+
+func freePort(t *testing.T) int {
+	ln, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+// Returns all series of a metric family, which contain the passed labels.
+func findSeries(families []*dto.MetricFamily, name string, labels map[string]string) []*dto.Metric {
+	var result []*dto.Metric
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			values := map[string]string{}
+			for _, pair := range metric.GetLabel() {
+				values[pair.GetName()] = pair.GetValue()
+			}
+			matches := true
+			for k, v := range labels {
+				if values[k] != v {
+					matches = false
+				}
+			}
+			if matches {
+				result = append(result, metric)
+			}
+		}
+	}
+	return result
+}
+
+func TestInstrumentCSMS(t *testing.T) {
+	registry := prom.NewRegistry()
+	csms := ocpp2.NewCSMS(nil, nil)
+	csms.SetProvisioningHandler(&provisioningHandler{})
+	_, err := Instrument(csms, Options{Registerer: registry, MaxChargePoints: 10})
+	require.NoError(t, err)
+	port := freePort(t)
+	go csms.Start(port, "/{ws}")
+	defer csms.Stop()
+
+	stationID := "station1"
+	station := ocpp2.NewChargingStation(stationID, nil, nil)
+	require.Eventually(t, func() bool {
+		return station.Start(fmt.Sprintf("ws://localhost:%v", port)) == nil
+	}, time.Second, 10*time.Millisecond)
+	defer station.Stop()
+	// Incoming request, answered by the CSMS
+	_, err = station.BootNotification(provisioning.BootReasonPowerUp, "model", "vendor")
+	require.NoError(t, err)
+	// Outgoing request, rejected by the station, which has no provisioning handler
+	resultC := make(chan error, 1)
+	err = csms.Reset(stationID, func(response *provisioning.ResetResponse, err error) {
+		resultC <- err
+	}, provisioning.ResetTypeImmediate)
+	require.NoError(t, err)
+	select {
+	case err = <-resultC:
+		require.Error(t, err)
+	case <-time.After(time.Second):
+		require.Fail(t, "no response received")
+	}
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	boot := findSeries(families, "ocpp_requests_total", map[string]string{
+		"direction": DirectionIncoming, "action": provisioning.BootNotificationFeatureName,
+		"outcome": string(ocppj.OutcomeResponse), "error_code": "", "charge_point_id": stationID,
+	})
+	require.Len(t, boot, 1)
+	assert.Equal(t, 1.0, boot[0].GetCounter().GetValue())
+	reset := findSeries(families, "ocpp_requests_total", map[string]string{
+		"direction": DirectionOutgoing, "action": provisioning.ResetFeatureName,
+		"outcome": string(ocppj.OutcomeError), "error_code": string(ocppj.NotSupported), "charge_point_id": stationID,
+	})
+	require.Len(t, reset, 1)
+	assert.Equal(t, 1.0, reset[0].GetCounter().GetValue())
+	duration := findSeries(families, "ocpp_request_duration_seconds", map[string]string{
+		"direction": DirectionOutgoing, "action": provisioning.ResetFeatureName,
+	})
+	require.Len(t, duration, 1)
+	assert.Equal(t, uint64(1), duration[0].GetHistogram().GetSampleCount())
+	opened := findSeries(families, "ocpp_websocket_connection_events_total", map[string]string{"event": "opened", "charge_point_id": stationID})
+	require.Len(t, opened, 1)
+	assert.Equal(t, 1.0, opened[0].GetCounter().GetValue())
+	for _, direction := range []string{DirectionIncoming, DirectionOutgoing} {
+		messages := findSeries(families, "ocpp_websocket_messages_total", map[string]string{"direction": direction, "charge_point_id": stationID})
+		require.Len(t, messages, 1)
+		assert.Equal(t, 2.0, messages[0].GetCounter().GetValue())
+		bytes := findSeries(families, "ocpp_websocket_message_bytes_total", map[string]string{"direction": direction, "charge_point_id": stationID})
+		require.Len(t, bytes, 1)
+		assert.Greater(t, bytes[0].GetCounter().GetValue(), 0.0)
+	}
+	// Sampled gauges
+	connected := findSeries(families, "ocpp_connected_charge_points", nil)
+	require.Len(t, connected, 1)
+	assert.Equal(t, 1.0, connected[0].GetGauge().GetValue())
+	pending := findSeries(families, "ocpp_pending_requests", map[string]string{"charge_point_id": stationID})
+	require.Len(t, pending, 1)
+	assert.Equal(t, 0.0, pending[0].GetGauge().GetValue())
+	queued := findSeries(families, "ocpp_queued_requests", map[string]string{"charge_point_id": stationID})
+	require.Len(t, queued, 1)
+	assert.Equal(t, 0.0, queued[0].GetGauge().GetValue())
+}
+
+// Stub server, exposing a fixed snapshot.
+type statsServer struct {
+	collector ocppj.MetricsCollector
+	stats     []ocppj.ClientStats
+}
+
+func (s *statsServer) SetMetricsCollector(collector ocppj.MetricsCollector) {
+	s.collector = collector
+}
+
+func (s *statsServer) Stats() []ocppj.ClientStats {
+	return s.stats
+}
+
+func TestCardinalityGuard(t *testing.T) {
+	registry := prom.NewRegistry()
+	server := &statsServer{stats: []ocppj.ClientStats{
+		{ClientID: "cp1", QueuedRequests: 1, PendingRequests: 1},
+		{ClientID: "cp2", QueuedRequests: 2, PendingRequests: 1},
+		{ClientID: "cp3", QueuedRequests: 3, PendingRequests: 0},
+	}}
+	c, err := Instrument(server, Options{Registerer: registry, MaxChargePoints: 1})
+	require.NoError(t, err)
+	assert.Equal(t, c, server.collector)
+	c.ConnectionOpened("cp1")
+	c.ConnectionOpened("cp2")
+	c.ConnectionOpened("cp3")
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	opened := findSeries(families, "ocpp_websocket_connection_events_total", nil)
+	require.Len(t, opened, 2)
+	other := findSeries(families, "ocpp_websocket_connection_events_total", map[string]string{"charge_point_id": OtherChargePoints})
+	require.Len(t, other, 1)
+	assert.Equal(t, 2.0, other[0].GetCounter().GetValue())
+	// Gauges of charge points exceeding the limit are summed up
+	queued := findSeries(families, "ocpp_queued_requests", map[string]string{"charge_point_id": OtherChargePoints})
+	require.Len(t, queued, 1)
+	assert.Equal(t, 5.0, queued[0].GetGauge().GetValue())
+	pending := findSeries(families, "ocpp_pending_requests", map[string]string{"charge_point_id": "cp1"})
+	require.Len(t, pending, 1)
+	assert.Equal(t, 1.0, pending[0].GetGauge().GetValue())
+}
+
+func TestWithoutChargePointLabel(t *testing.T) {
+	registry := prom.NewRegistry()
+	c, err := Instrument(&statsServer{}, Options{Registerer: registry, Namespace: "csms"})
+	require.NoError(t, err)
+	c.MessageSent("cp1", 10)
+	c.MessageSent("cp2", 20)
+	c.MessageWriteDuration("cp1", 300*time.Millisecond)
+	c.MessageWriteDuration("cp2", 200*time.Millisecond)
+	c.RequestThrottled(ocppj.SpanInfo{Kind: ocppj.SpanKindOutgoing, ClientID: "cp1", Action: "Reset"}, 2*time.Second)
+	c.RequestThrottled(ocppj.SpanInfo{Kind: ocppj.SpanKindOutgoing, ClientID: "cp2", Action: "Reset"}, 500*time.Millisecond)
+	c.UnknownAction(ocppj.SpanInfo{Kind: ocppj.SpanKindIncoming, ClientID: "cp1", Action: "Bootnotification"})
+	c.HandlerDeadlineExceeded(ocppj.SpanInfo{Kind: ocppj.SpanKindIncoming, ClientID: "cp1", Action: "Authorize"}, 30*time.Second)
+	c.LateResponseDiscarded(ocppj.SpanInfo{Kind: ocppj.SpanKindIncoming, ClientID: "cp1", Action: "Authorize"}, 31*time.Second)
+
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	sent := findSeries(families, "csms_websocket_message_bytes_total", map[string]string{"direction": DirectionOutgoing})
+	require.Len(t, sent, 1)
+	assert.Empty(t, findSeries(families, "csms_websocket_message_bytes_total", map[string]string{"charge_point_id": "cp1"}))
+	assert.Equal(t, 30.0, sent[0].GetCounter().GetValue())
+	written := findSeries(families, "csms_websocket_write_seconds_total", nil)
+	require.Len(t, written, 1)
+	assert.InDelta(t, 0.5, written[0].GetCounter().GetValue(), 1e-9)
+	throttled := findSeries(families, "csms_request_throttled_seconds_total", map[string]string{"action": "Reset"})
+	require.Len(t, throttled, 1)
+	assert.Equal(t, 2.5, throttled[0].GetCounter().GetValue())
+	unknown := findSeries(families, "csms_unknown_actions_total", map[string]string{"action": "Bootnotification"})
+	require.Len(t, unknown, 1)
+	assert.Equal(t, 1.0, unknown[0].GetCounter().GetValue())
+	for _, name := range []string{"csms_handler_deadline_exceeded_total", "csms_late_responses_discarded_total"} {
+		series := findSeries(families, name, map[string]string{"action": "Authorize"})
+		require.Len(t, series, 1)
+		assert.Equal(t, 1.0, series[0].GetCounter().GetValue())
+	}
+	// Aggregated gauges are exposed without connected charge points
+	queued := findSeries(families, "csms_queued_requests", nil)
+	require.Len(t, queued, 1)
+	assert.Equal(t, 0.0, queued[0].GetGauge().GetValue())
+	connected := findSeries(families, "csms_connected_charge_points", nil)
+	require.Len(t, connected, 1)
+	assert.Equal(t, 0.0, connected[0].GetGauge().GetValue())
+	// Registering twice fails
+	_, err = New(Options{Registerer: registry, Namespace: "csms"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,265 @@
+// Package multiproto serves OCPP 1.6 and OCPP 2.0.1 charging stations on a single websocket endpoint.
+//
+// Each incoming connection is routed to either protocol stack, based on the subprotocol negotiated during the
+// websocket handshake. Both stacks keep their own handlers, state and API:
+//
+//	server := multiproto.NewServer(nil)
+//	server.CentralSystem().SetCoreHandler(coreHandler16)
+//	server.CSMS().SetProvisioningHandler(provisioningHandler201)
+//	server.Start(8887, "/{ws}")
+package multiproto
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	ocpp16 "github.com/lorenzodonini/ocpp-go/ocpp1.6"
+	types16 "github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	ocpp2 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1"
+	types2 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+	"github.com/lorenzodonini/ocpp-go/ws"
+)
+
+// Station describes a charging station connected to a Server.
+type Station struct {
+	ID          string
+	Version     ocpp.Dialect // The OCPP version spoken by the charging station, either ocpp.V16 or ocpp.V2.
+	Subprotocol string       // The negotiated websocket subprotocol.
+}
+
+// Server owns a websocket listener and hands each connection to an OCPP 1.6 central system
+// or to an OCPP 2.0.1 CSMS, depending on the negotiated subprotocol.
+//
+// Connections negotiating neither "ocpp1.6" nor "ocpp2.0.1" are rejected, hence clients must always request
+// a subprotocol. If a client offers both, the first one in its list is picked.
+//
+// Settings shared by both stacks, such as TLS, basic authentication and timeouts, must be configured
+// on the websocket server passed to NewServer. The embedded central system and CSMS must be started
+// and stopped via the Server.
+type Server struct {
+	network       ws.WsServer
+	centralSystem ocpp16.CentralSystem
+	csms          ocpp2.CSMS
+	v16           *versionServer
+	v201          *versionServer
+	stations      map[string]Station
+	mutex         sync.RWMutex
+}
+
+// NewServer creates a server for both OCPP versions, using the default endpoint configuration of each version.
+//
+// The network parameter may be omitted, in order to use a default websocket server.
+// If you need a TLS server, you may use the following:
+//
+//	server := multiproto.NewServer(ws.NewTLSServer("certificatePath", "privateKeyPath"))
+func NewServer(network ws.WsServer) *Server {
+	if network == nil {
+		network = ws.NewServer()
+	}
+	s := &Server{
+		network:  network,
+		stations: map[string]Station{},
+	}
+	s.v16 = &versionServer{parent: s, version: ocpp.V16}
+	s.v201 = &versionServer{parent: s, version: ocpp.V2}
+	s.centralSystem = ocpp16.NewCentralSystem(nil, s.v16)
+	s.csms = ocpp2.NewCSMS(nil, s.v201)
+	network.SetCheckClientHandler(s.checkClient)
+	network.SetNewClientHandler(s.onConnected)
+	network.SetDisconnectedClientHandler(s.onDisconnected)
+	network.SetMessageHandler(s.onMessage)
+	return s
+}
+
+// CentralSystem returns the OCPP 1.6 stack, serving all charge points negotiating the "ocpp1.6" subprotocol.
+func (s *Server) CentralSystem() ocpp16.CentralSystem {
+	return s.centralSystem
+}
+
+// CSMS returns the OCPP 2.0.1 stack, serving all charging stations negotiating the "ocpp2.0.1" subprotocol.
+func (s *Server) CSMS() ocpp2.CSMS {
+	return s.csms
+}
+
+// Start starts both protocol stacks and listens for incoming connections on the given port and path.
+// The function blocks until Stop is invoked.
+func (s *Server) Start(listenPort int, listenPath string) {
+	// The stacks return right away, since the listener is owned by the server
+	s.centralSystem.Start(listenPort, listenPath)
+	s.csms.Start(listenPort, listenPath)
+	s.network.Start(listenPort, listenPath)
+}
+
+// Stop stops both protocol stacks and closes all connections.
+func (s *Server) Stop() {
+	s.centralSystem.Stop()
+	s.csms.Stop()
+	s.network.Stop()
+}
+
+// Stations returns all connected charging stations of both versions, sorted by ID.
+func (s *Server) Stations() []Station {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	stations := make([]Station, 0, len(s.stations))
+	for _, station := range s.stations {
+		stations = append(stations, station)
+	}
+	sort.Slice(stations, func(i, j int) bool {
+		return stations[i].ID < stations[j].ID
+	})
+	return stations
+}
+
+// Returns the stack for the given subprotocol, or nil if neither stack supports it.
+func (s *Server) route(subprotocol string) *versionServer {
+	switch subprotocol {
+	case types16.V16Subprotocol:
+		return s.v16
+	case types2.V201Subprotocol:
+		return s.v201
+	default:
+		return nil
+	}
+}
+
+// Returns the stack a connected charging station was routed to, or nil if the station is unknown.
+func (s *Server) routeStation(id string) *versionServer {
+	s.mutex.RLock()
+	station, ok := s.stations[id]
+	s.mutex.RUnlock()
+	if !ok {
+		return nil
+	}
+	return s.route(station.Subprotocol)
+}
+
+func (s *Server) checkClient(id string, r *http.Request) bool {
+	// Mirrors the negotiation of the websocket server, in order to pick the check handler of the right stack
+	for _, requested := range websocket.Subprotocols(r) {
+		if target := s.route(requested); target != nil {
+			return target.checkClientHandler == nil || target.checkClientHandler(id, r)
+		}
+	}
+	// Rejected during the handshake anyway
+	return true
+}
+
+func (s *Server) onConnected(channel ws.Channel) {
+	subprotocol := ws.ChannelSubprotocol(channel)
+	target := s.route(subprotocol)
+	if target == nil {
+		// May only happen if further subprotocols were added to the websocket server
+		_ = s.network.StopConnection(channel.ID(), websocket.CloseError{Code: websocket.CloseProtocolError, Text: "invalid or unsupported subprotocol"})
+		return
+	}
+	s.mutex.Lock()
+	s.stations[channel.ID()] = Station{ID: channel.ID(), Version: target.version, Subprotocol: subprotocol}
+	s.mutex.Unlock()
+	if target.newClientHandler != nil {
+		target.newClientHandler(channel)
+	}
+}
+
+func (s *Server) onDisconnected(channel ws.Channel) {
+	target := s.route(ws.ChannelSubprotocol(channel))
+	if target == nil {
+		return
+	}
+	s.mutex.Lock()
+	delete(s.stations, channel.ID())
+	s.mutex.Unlock()
+	if target.disconnectedHandler != nil {
+		target.disconnectedHandler(channel)
+	}
+}
+
+func (s *Server) onMessage(channel ws.Channel, data []byte) error {
+	subprotocol := ws.ChannelSubprotocol(channel)
+	target := s.route(subprotocol)
+	if target == nil {
+		return fmt.Errorf("no protocol stack for subprotocol %q of %s", subprotocol, channel.ID())
+	}
+	if target.messageHandler == nil {
+		return nil
+	}
+	return target.messageHandler(channel, data)
+}
+
+// versionServer is the view of a single protocol stack onto the shared websocket server.
+// It only receives the connections and messages of its version, and may only write to them.
+type versionServer struct {
+	parent              *Server
+	version             ocpp.Dialect
+	messageHandler      func(ws ws.Channel, data []byte) error
+	newClientHandler    func(ws ws.Channel)
+	disconnectedHandler func(ws ws.Channel)
+	checkClientHandler  func(id string, r *http.Request) bool
+}
+
+// Start is a no-op, since the listener is started by the parent server.
+func (v *versionServer) Start(port int, listenPath string) {}
+
+// Stop is a no-op, since the listener is stopped by the parent server.
+func (v *versionServer) Stop() {}
+
+func (v *versionServer) StopConnection(id string, closeError websocket.CloseError) error {
+	if v.parent.routeStation(id) != v {
+		return fmt.Errorf("couldn't stop websocket connection %s: %w", id, ws.ErrNotConnected)
+	}
+	return v.parent.network.StopConnection(id, closeError)
+}
+
+func (v *versionServer) Errors() <-chan error {
+	return v.parent.network.Errors()
+}
+
+func (v *versionServer) SetMessageHandler(handler func(ws ws.Channel, data []byte) error) {
+	v.messageHandler = handler
+}
+
+func (v *versionServer) SetNewClientHandler(handler func(ws ws.Channel)) {
+	v.newClientHandler = handler
+}
+
+func (v *versionServer) SetDisconnectedClientHandler(handler func(ws ws.Channel)) {
+	v.disconnectedHandler = handler
+}
+
+func (v *versionServer) SetTimeoutConfig(config ws.ServerTimeoutConfig) {
+	v.parent.network.SetTimeoutConfig(config)
+}
+
+func (v *versionServer) Write(webSocketId string, data []byte) error {
+	// Never deliver messages of one version to a charging station speaking the other one
+	if v.parent.routeStation(webSocketId) != v {
+		return fmt.Errorf("couldn't write to websocket %v: %w", webSocketId, ws.ErrNotConnected)
+	}
+	return v.parent.network.Write(webSocketId, data)
+}
+
+func (v *versionServer) AddSupportedSubprotocol(subProto string) {
+	v.parent.network.AddSupportedSubprotocol(subProto)
+}
+
+func (v *versionServer) SetBasicAuthHandler(handler func(username string, password string) bool) {
+	v.parent.network.SetBasicAuthHandler(handler)
+}
+
+func (v *versionServer) SetCheckOriginHandler(handler func(r *http.Request) bool) {
+	v.parent.network.SetCheckOriginHandler(handler)
+}
+
+func (v *versionServer) SetCheckClientHandler(handler func(id string, r *http.Request) bool) {
+	v.checkClientHandler = handler
+}
+
+func (v *versionServer) Addr() *net.TCPAddr {
+	return v.parent.network.Addr()
+}
@@ -0,0 +1,124 @@
+package multiproto_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/multiproto"
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	ocpp16 "github.com/lorenzodonini/ocpp-go/ocpp1.6"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	types16 "github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	ocpp2 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/authorization"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	types2 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+	"github.com/lorenzodonini/ocpp-go/ws"
+)
+
+const multiprotoPort = 8896
+
+// Only handles boot notifications, all other messages would panic.
+type coreHandler16 struct {
+	core.CentralSystemHandler
+	bootC chan string
+}
+
+func (h *coreHandler16) OnBootNotification(chargePointId string, request *core.BootNotificationRequest) (*core.BootNotificationConfirmation, error) {
+	h.bootC <- chargePointId
+	return core.NewBootNotificationConfirmation(types16.NewDateTime(time.Now()), 60, core.RegistrationStatusAccepted), nil
+}
+
+// Only handles boot notifications, all other messages would panic.
+type provisioningHandler201 struct {
+	provisioning.CSMSHandler
+	bootC chan string
+}
+
+func (h *provisioningHandler201) OnBootNotification(chargingStationID string, request *provisioning.BootNotificationRequest) (*provisioning.BootNotificationResponse, error) {
+	h.bootC <- chargingStationID
+	return provisioning.NewBootNotificationResponse(types2.NewDateTime(time.Now()), 60, provisioning.RegistrationStatusAccepted), nil
+}
+
+func TestMixedFleet(t *testing.T) {
+	server := multiproto.NewServer(nil)
+	handler16 := &coreHandler16{bootC: make(chan string, 1)}
+	handler201 := &provisioningHandler201{bootC: make(chan string, 1)}
+	server.CentralSystem().SetCoreHandler(handler16)
+	server.CSMS().SetProvisioningHandler(handler201)
+	connected16 := make(chan string, 1)
+	connected201 := make(chan string, 1)
+	server.CentralSystem().SetNewChargePointHandler(func(chargePoint ocpp16.ChargePointConnection) {
+		connected16 <- chargePoint.ID()
+	})
+	server.CSMS().SetNewChargingStationHandler(func(chargingStation ocpp2.ChargingStationConnection) {
+		connected201 <- chargingStation.ID()
+	})
+	go server.Start(multiprotoPort, "/{ws}")
+	defer server.Stop()
+	url := fmt.Sprintf("ws://localhost:%v", multiprotoPort)
+
+	// Both versions connect to the same port
+	chargePoint := ocpp16.NewChargePoint("cp16", nil, nil)
+	require.Eventually(t, func() bool {
+		return chargePoint.Start(url) == nil
+	}, 5*time.Second, 50*time.Millisecond)
+	chargingStation := ocpp2.NewChargingStation("cs201", nil, nil)
+	require.NoError(t, chargingStation.Start(url))
+	defer chargingStation.Stop()
+	assert.Equal(t, "cp16", <-connected16)
+	assert.Equal(t, "cs201", <-connected201)
+	assert.Equal(t, []multiproto.Station{
+		{ID: "cp16", Version: ocpp.V16, Subprotocol: types16.V16Subprotocol},
+		{ID: "cs201", Version: ocpp.V2, Subprotocol: types2.V201Subprotocol},
+	}, server.Stations())
+
+	// Each stack handles the messages of its own version
+	confirmation, err := chargePoint.BootNotification("model1", "vendor1")
+	require.NoError(t, err)
+	assert.Equal(t, core.RegistrationStatusAccepted, confirmation.Status)
+	assert.Equal(t, "cp16", <-handler16.bootC)
+	response, err := chargingStation.BootNotification(provisioning.BootReasonPowerUp, "model1", "vendor1")
+	require.NoError(t, err)
+	assert.Equal(t, provisioning.RegistrationStatusAccepted, response.Status)
+	assert.Equal(t, "cs201", <-handler201.bootC)
+
+	// A stack cannot reach the charging stations of the other version
+	err = server.CSMS().ClearCache("cp16", func(response *authorization.ClearCacheResponse, err error) {})
+	assert.ErrorIs(t, err, ws.ErrNotConnected)
+	err = server.CentralSystem().ClearCache("cs201", func(confirmation *core.ClearCacheConfirmation, err error) {})
+	assert.ErrorIs(t, err, ws.ErrNotConnected)
+
+	// Disconnections are tracked per version
+	chargePoint.Stop()
+	assert.Eventually(t, func() bool {
+		return len(server.Stations()) == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "cs201", server.Stations()[0].ID)
+}
+
+func TestUnsupportedSubprotocol(t *testing.T) {
+	server := multiproto.NewServer(nil)
+	go server.Start(multiprotoPort+1, "/{ws}")
+	defer server.Stop()
+	url := fmt.Sprintf("ws://localhost:%v/station1", multiprotoPort+1)
+	for _, subprotocol := range []string{"ocpp2.0", ""} {
+		client := ws.NewClient()
+		if subprotocol != "" {
+			client.SetRequestedSubProtocol(subprotocol)
+		}
+		require.Eventually(t, func() bool {
+			return client.Start(url) == nil
+		}, 5*time.Second, 50*time.Millisecond)
+		// The connection is closed right after the handshake
+		assert.Eventually(t, func() bool {
+			return !client.IsConnected()
+		}, time.Second, 10*time.Millisecond)
+		assert.Empty(t, server.Stations())
+		client.Stop()
+	}
+}
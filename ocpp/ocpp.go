@@ -34,10 +34,13 @@ type Response interface {
 type ErrorCode string
 
 // Error wraps an OCPP error, containing an ErrorCode, a Description and the ID of the message.
+//
+// Errors created locally (e.g. for requests that timed out) may wrap a cause, which is accessible via errors.Is and errors.As.
 type Error struct {
 	Code        ErrorCode
 	Description string
 	MessageId   string
+	cause       error
 }
 
 // Creates a new OCPP Error.
@@ -54,6 +57,62 @@ func (err *Error) Error() string {
 	return fmt.Sprintf("ocpp message (%s): %v - %v", err.MessageId, err.Code, err.Description)
 }
 
+// WithCause sets the underlying cause of the error and returns the error itself.
+func (err *Error) WithCause(cause error) *Error {
+	err.cause = cause
+	return err
+}
+
+// Unwrap returns the underlying cause of the error, if any.
+func (err *Error) Unwrap() error {
+	return err.cause
+}
+
+// -------------------- Typed responses --------------------
+
+// ResponseTypeError is returned by the typed request helpers, whenever a received response doesn't match the expected type.
+type ResponseTypeError struct {
+	Expected reflect.Type
+	Actual   reflect.Type // Nil if no response was received.
+}
+
+func (e *ResponseTypeError) Error() string {
+	return fmt.Sprintf("unexpected response type: expected %v, got %v", e.Expected, e.Actual)
+}
+
+// CastResponse converts a generic response to the concrete response type T, e.g. *core.HeartbeatConfirmation.
+// If the response is of a different type, the zero value of T is returned together with a *ResponseTypeError.
+func CastResponse[T Response](response Response) (T, error) {
+	typed, ok := response.(T)
+	if !ok {
+		var actual reflect.Type
+		if response != nil {
+			actual = reflect.TypeOf(response)
+		}
+		return typed, &ResponseTypeError{Expected: reflect.TypeOf((*T)(nil)).Elem(), Actual: actual}
+	}
+	return typed, nil
+}
+
+// TypedCallback adapts a callback expecting a concrete response type to the generic callback of the asynchronous send functions:
+//
+//	centralSystem.SendRequestAsync(clientId, request, ocpp.TypedCallback(func(confirmation *core.ResetConfirmation, err error) {
+//		...
+//	}))
+//
+// If an error was received, the callback is invoked with the zero value of T.
+// If the received response is of a different type, the callback is invoked with a *ResponseTypeError.
+func TypedCallback[T Response](callback func(response T, err error)) func(Response, error) {
+	return func(response Response, err error) {
+		if err != nil {
+			var zero T
+			callback(zero, err)
+			return
+		}
+		callback(CastResponse[T](response))
+	}
+}
+
 // -------------------- Profile --------------------
 
 // Profile defines a specific set of features, grouped by functionality.
@@ -121,3 +180,20 @@ const (
 	V16
 	V2
 )
+
+// String returns the OCPP version number of the dialect, e.g. "1.6".
+func (d Dialect) String() string {
+	switch d {
+	case V16:
+		return "1.6"
+	case V2:
+		return "2.0.1"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText encodes the dialect as its OCPP version number.
+func (d Dialect) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
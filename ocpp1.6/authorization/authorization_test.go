@@ -0,0 +1,85 @@
+package authorization_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/clock/clocktest"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/authorization"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+)
+
+func idTagInfo(status types.AuthorizationStatus, parentIdTag string) types.IdTagInfo {
+	return types.IdTagInfo{Status: status, ParentIdTag: parentIdTag}
+}
+
+func TestSameGroup16(t *testing.T) {
+	var testTable = []struct {
+		name      string
+		start     string
+		candidate string
+		expected  bool
+	}{
+		{"same parent", "fleet", "fleet", true},
+		{"hex UID case difference", "04a2b3c4", "04A2B3C4", true},
+		{"surrounding whitespace", "fleet", " fleet ", true},
+		{"different parent", "fleet", "other", false},
+		{"missing start parent", "", "fleet", false},
+		{"missing candidate parent", "fleet", "", false},
+		{"missing parents", "", "", false},
+	}
+	for _, tc := range testTable {
+		t.Run(tc.name, func(t *testing.T) {
+			start := idTagInfo(types.AuthorizationStatusAccepted, tc.start)
+			candidate := idTagInfo(types.AuthorizationStatusAccepted, tc.candidate)
+			assert.Equal(t, tc.expected, authorization.SameGroup16(start, candidate))
+			assert.Equal(t, tc.expected, authorization.SameGroup16(candidate, start))
+		})
+	}
+}
+
+func TestCacheAuthorizeStop(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	cache := authorization.NewCache()
+	cache.SetClock(clocktest.NewFakeClock(now))
+	startInfo := idTagInfo(types.AuthorizationStatusAccepted, "FLEET")
+	cache.Store("member", idTagInfo(types.AuthorizationStatusAccepted, "fleet"))
+	cache.Store("blocked", idTagInfo(types.AuthorizationStatusBlocked, "fleet"))
+	cache.Store("stranger", idTagInfo(types.AuthorizationStatusAccepted, "other"))
+	cache.Store("orphan", idTagInfo(types.AuthorizationStatusAccepted, ""))
+	expired := idTagInfo(types.AuthorizationStatusAccepted, "fleet")
+	expired.ExpiryDate = types.NewDateTime(now.Add(-time.Minute))
+	cache.Store("expired", expired)
+	var testTable = []struct {
+		idTag    string
+		expected bool
+	}{
+		{"starter", true},
+		{"STARTER", true},
+		{"member", true},
+		{"MEMBER", true},
+		{"blocked", false},
+		{"stranger", false},
+		{"orphan", false},
+		{"expired", false},
+		{"unknown", false},
+	}
+	for _, tc := range testTable {
+		assert.Equal(t, tc.expected, cache.AuthorizeStop("starter", startInfo, tc.idTag), tc.idTag)
+	}
+	// Without a parent, only the idTag which started the transaction may stop it
+	assert.False(t, cache.AuthorizeStop("starter", idTagInfo(types.AuthorizationStatusAccepted, ""), "orphan"))
+	info, ok := cache.Lookup("expired")
+	require.True(t, ok)
+	assert.Equal(t, types.AuthorizationStatusExpired, info.Status)
+	// Clearing the cache forgets all group members
+	confirmation, err := cache.OnClearCache(core.NewClearCacheRequest())
+	require.NoError(t, err)
+	assert.Equal(t, core.ClearCacheStatusAccepted, confirmation.Status)
+	assert.False(t, cache.AuthorizeStop("starter", startInfo, "member"))
+	assert.True(t, cache.AuthorizeStop("starter", startInfo, "starter"))
+}
@@ -0,0 +1,92 @@
+// Package authorization contains helpers for authorizing idTags on an OCPP 1.6 charge point.
+//
+// The Cache keeps the IdTagInfo of recently authorized idTags, and decides offline whether an idTag may stop
+// a transaction, which was started by another idTag of the same group:
+//
+//	cache := authorization.NewCache()
+//	// After every Authorize, StartTransaction and StopTransaction confirmation
+//	cache.Store(idTag, *confirmation.IdTagInfo)
+//	// While offline
+//	if cache.AuthorizeStop(startIdTag, startInfo, idTag) {
+//		// Stop the transaction
+//	}
+//
+// The cache implements the OnClearCache callback of the core.ChargePointHandler.
+package authorization
+
+import (
+	"sync"
+
+	"github.com/lorenzodonini/ocpp-go/clock"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+)
+
+// Cache is a non-persistent authorization cache, keeping the IdTagInfo of each idTag in memory.
+// IdTags are looked up in their normalized form (see NormalizeIdTag).
+//
+// A Cache is safe for concurrent use.
+type Cache struct {
+	entries map[string]types.IdTagInfo
+	clock   clock.Clock
+	mutex   sync.Mutex
+}
+
+// NewCache creates a new, empty cache.
+func NewCache() *Cache {
+	return &Cache{entries: map[string]types.IdTagInfo{}, clock: clock.New()}
+}
+
+// SetClock sets the clock used for checking the expiry date of entries. Passing nil restores the real clock.
+func (c *Cache) SetClock(clk clock.Clock) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.clock = clock.OrDefault(clk)
+}
+
+// Store records the IdTagInfo received from the central system for an idTag, replacing any previous entry.
+func (c *Cache) Store(idTag string, info types.IdTagInfo) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries[NormalizeIdTag(idTag)] = info
+}
+
+// Lookup returns the cached IdTagInfo of an idTag. Once the expiry date of an entry passed, its status is Expired.
+func (c *Cache) Lookup(idTag string) (types.IdTagInfo, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	info, ok := c.entries[NormalizeIdTag(idTag)]
+	if !ok {
+		return types.IdTagInfo{}, false
+	}
+	if info.ExpiryDate != nil && !c.clock.Now().Before(info.ExpiryDate.Time) {
+		info.Status = types.AuthorizationStatusExpired
+	}
+	return info, true
+}
+
+// Clear removes all entries from the cache.
+func (c *Cache) Clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries = map[string]types.IdTagInfo{}
+}
+
+// OnClearCache clears the cache on request of the central system. It matches the core.ChargePointHandler OnClearCache callback.
+func (c *Cache) OnClearCache(request *core.ClearCacheRequest) (*core.ClearCacheConfirmation, error) {
+	c.Clear()
+	return core.NewClearCacheConfirmation(core.ClearCacheStatusAccepted), nil
+}
+
+// AuthorizeStop decides offline whether an idTag may stop a transaction, which was started by startIdTag.
+// The start info is the IdTagInfo received when starting the transaction.
+//
+// The idTag which started the transaction is always accepted. Any other idTag is accepted only if it is cached as Accepted,
+// and belongs to the same group as the start idTag (see SameGroup16).
+func (c *Cache) AuthorizeStop(startIdTag string, startInfo types.IdTagInfo, idTag string) bool {
+	if NormalizeIdTag(startIdTag) == NormalizeIdTag(idTag) {
+		return true
+	}
+	info, ok := c.Lookup(idTag)
+	return ok && info.Status == types.AuthorizationStatusAccepted && SameGroup16(startInfo, info)
+}
@@ -0,0 +1,22 @@
+package authorization
+
+import (
+	"strings"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+)
+
+// NormalizeIdTag returns the canonical notation of an idTag. IdTags are case-insensitive strings,
+// hence they are uppercased, which also covers hex-encoded UIDs read in lowercase. Surrounding whitespace is removed.
+func NormalizeIdTag(idTag string) string {
+	return strings.ToUpper(strings.TrimSpace(idTag))
+}
+
+// SameGroup16 returns true if the candidate idTag belongs to the same group as the idTag which started a transaction,
+// i.e. if both IdTagInfo carry the same parentIdTag, ignoring case differences.
+//
+// IdTags without a parentIdTag never match, even if the parentIdTag is only missing on one side.
+func SameGroup16(start types.IdTagInfo, candidate types.IdTagInfo) bool {
+	parent := NormalizeIdTag(start.ParentIdTag)
+	return parent != "" && parent == NormalizeIdTag(candidate.ParentIdTag)
+}
@@ -0,0 +1,199 @@
+// Package availability keeps track of the availability of the connectors of an OCPP 1.6 charge point,
+// and reports connector statuses to the central system accordingly.
+//
+// The specification requires a charge point to keep connectors, which were set to Inoperative via ChangeAvailability,
+// inoperative across reboots, and to report them as Unavailable in the StatusNotification requests sent after booting.
+// Hence, the AvailabilityManager persists every accepted change to a Store:
+//
+//	availabilityManager := availability.NewAvailabilityManager(2, availability.NewFileStore("/var/lib/cp/availability.json"), logger)
+//	statusManager := availability.NewStatusManager(availabilityManager, func(request *core.StatusNotificationRequest) error {
+//		return chargePoint.SendRequestAsync(request, callback)
+//	})
+//	// Inside OnChangeAvailability
+//	return availabilityManager.OnChangeAvailability(request)
+//	// Once the BootNotification was accepted
+//	statusManager.ReportBootStatus()
+package availability
+
+import (
+	"sync"
+
+	"github.com/lorenzodonini/ocpp-go/logging"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+)
+
+// AvailabilityManager keeps track of the availability of the connectors of a charge point, persisting it to a Store.
+//
+// The persisted availability is restored at construction. Changing a connector to Inoperative while a transaction
+// is ongoing on it is Scheduled, and applied once the connector is free (see SetConnectorBusy).
+// Connector 0 refers to the whole charge point: all connectors are inoperative while it is.
+//
+// An AvailabilityManager is safe for concurrent use.
+type AvailabilityManager struct {
+	store       Store
+	connectors  int
+	inoperative map[int]bool
+	scheduled   map[int]bool
+	busy        map[int]bool
+	logger      logging.Logger
+	// Invoked after the availability of any connector changed
+	onChange func()
+	mutex    sync.Mutex
+}
+
+// NewAvailabilityManager creates a manager for a charge point with the given amount of connectors,
+// and restores the availability persisted in the store. The store is mandatory.
+//
+// Failing to restore the availability doesn't prevent the charge point from booting:
+// all connectors are Operative and a warning is logged. A nil logger discards log entries.
+func NewAvailabilityManager(connectors int, store Store, logger logging.Logger) *AvailabilityManager {
+	if store == nil {
+		panic("store parameter cannot be nil")
+	}
+	if logger == nil {
+		logger = &logging.VoidLogger{}
+	}
+	m := &AvailabilityManager{
+		store:       store,
+		connectors:  connectors,
+		inoperative: map[int]bool{},
+		scheduled:   map[int]bool{},
+		busy:        map[int]bool{},
+		logger:      logger,
+	}
+	_ = m.RestoreAvailability()
+	return m
+}
+
+// RestoreAvailability replaces the current availability with the one persisted in the store.
+// It is invoked at construction already, but may be invoked again explicitly, e.g. if the store only becomes
+// available later during startup. Scheduled changes are discarded.
+//
+// If the persisted availability can't be loaded, all connectors are Operative, a warning is logged and the error is returned.
+func (m *AvailabilityManager) RestoreAvailability() error {
+	availability, err := m.store.Load()
+	m.mutex.Lock()
+	m.inoperative = map[int]bool{}
+	m.scheduled = map[int]bool{}
+	for connectorId, availabilityType := range availability {
+		if err == nil && availabilityType == core.AvailabilityTypeInoperative && m.isKnown(connectorId) {
+			m.inoperative[connectorId] = true
+		}
+	}
+	onChange := m.onChange
+	m.mutex.Unlock()
+	if err != nil {
+		m.logger.Errorf("couldn't restore connector availability, all connectors are Operative: %v", err)
+	}
+	if onChange != nil {
+		onChange()
+	}
+	return err
+}
+
+// Availability returns the availability of a connector. A connector is inoperative,
+// if either the connector itself or the whole charge point (connector 0) is inoperative.
+func (m *AvailabilityManager) Availability(connectorId int) core.AvailabilityType {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.inoperative[0] || m.inoperative[connectorId] {
+		return core.AvailabilityTypeInoperative
+	}
+	return core.AvailabilityTypeOperative
+}
+
+// Connectors returns the amount of connectors of the charge point, excluding connector 0.
+func (m *AvailabilityManager) Connectors() int {
+	return m.connectors
+}
+
+// OnChangeAvailability applies a ChangeAvailabilityRequest and persists the result.
+// The request is rejected for unknown connectors, or if the availability couldn't be persisted.
+// It matches the core.ChargePointHandler OnChangeAvailability callback.
+func (m *AvailabilityManager) OnChangeAvailability(request *core.ChangeAvailabilityRequest) (*core.ChangeAvailabilityConfirmation, error) {
+	m.mutex.Lock()
+	if !m.isKnown(request.ConnectorId) {
+		m.mutex.Unlock()
+		return core.NewChangeAvailabilityConfirmation(core.AvailabilityStatusRejected), nil
+	}
+	inoperative := request.Type == core.AvailabilityTypeInoperative
+	if inoperative && m.isBusy(request.ConnectorId) {
+		m.scheduled[request.ConnectorId] = true
+		m.mutex.Unlock()
+		return core.NewChangeAvailabilityConfirmation(core.AvailabilityStatusScheduled), nil
+	}
+	err := m.apply(request.ConnectorId, inoperative)
+	onChange := m.onChange
+	m.mutex.Unlock()
+	if err != nil {
+		m.logger.Errorf("couldn't persist availability of connector %v: %v", request.ConnectorId, err)
+		return core.NewChangeAvailabilityConfirmation(core.AvailabilityStatusRejected), nil
+	}
+	if onChange != nil {
+		onChange()
+	}
+	return core.NewChangeAvailabilityConfirmation(core.AvailabilityStatusAccepted), nil
+}
+
+// SetConnectorBusy marks whether a transaction is ongoing on a connector.
+// Scheduled availability changes are applied, once all affected connectors are free.
+func (m *AvailabilityManager) SetConnectorBusy(connectorId int, busy bool) {
+	m.mutex.Lock()
+	if busy {
+		m.busy[connectorId] = true
+		m.mutex.Unlock()
+		return
+	}
+	delete(m.busy, connectorId)
+	changed := false
+	for scheduledId := range m.scheduled {
+		if m.isBusy(scheduledId) {
+			continue
+		}
+		if err := m.apply(scheduledId, true); err != nil {
+			m.logger.Errorf("couldn't persist scheduled availability of connector %v: %v", scheduledId, err)
+			continue
+		}
+		changed = true
+	}
+	onChange := m.onChange
+	m.mutex.Unlock()
+	if changed && onChange != nil {
+		onChange()
+	}
+}
+
+// Persists the availability of a connector and applies it, discarding any scheduled change.
+// Nothing is applied, if persisting fails. Must be invoked while holding the lock.
+func (m *AvailabilityManager) apply(connectorId int, inoperative bool) error {
+	availability := map[int]core.AvailabilityType{}
+	for id := range m.inoperative {
+		availability[id] = core.AvailabilityTypeInoperative
+	}
+	if inoperative {
+		availability[connectorId] = core.AvailabilityTypeInoperative
+	} else {
+		delete(availability, connectorId)
+	}
+	if err := m.store.Save(availability); err != nil {
+		return err
+	}
+	delete(m.scheduled, connectorId)
+	if inoperative {
+		m.inoperative[connectorId] = true
+	} else {
+		delete(m.inoperative, connectorId)
+	}
+	return nil
+}
+
+func (m *AvailabilityManager) isKnown(connectorId int) bool {
+	return connectorId >= 0 && connectorId <= m.connectors
+}
+
+func (m *AvailabilityManager) isBusy(connectorId int) bool {
+	if connectorId == 0 {
+		return len(m.busy) > 0
+	}
+	return m.busy[connectorId]
+}
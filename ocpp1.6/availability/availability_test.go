@@ -0,0 +1,138 @@
+package availability_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/availability"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+)
+
+type recordingSender struct {
+	requests []*core.StatusNotificationRequest
+	err      error
+}
+
+func (s *recordingSender) send(request *core.StatusNotificationRequest) error {
+	s.requests = append(s.requests, request)
+	return s.err
+}
+
+func (s *recordingSender) statuses() map[int]core.ChargePointStatus {
+	statuses := map[int]core.ChargePointStatus{}
+	for _, request := range s.requests {
+		statuses[request.ConnectorId] = request.Status
+	}
+	s.requests = nil
+	return statuses
+}
+
+// Builds the managers of a charge point with two connectors, as done when booting.
+func boot(store availability.Store) (*availability.AvailabilityManager, *availability.StatusManager, *recordingSender) {
+	sender := &recordingSender{}
+	availabilityManager := availability.NewAvailabilityManager(2, store, nil)
+	statusManager := availability.NewStatusManager(availabilityManager, sender.send)
+	return availabilityManager, statusManager, sender
+}
+
+func changeAvailability(t *testing.T, m *availability.AvailabilityManager, connectorId int, availabilityType core.AvailabilityType) core.AvailabilityStatus {
+	confirmation, err := m.OnChangeAvailability(core.NewChangeAvailabilityRequest(connectorId, availabilityType))
+	require.NoError(t, err)
+	return confirmation.Status
+}
+
+func TestInoperativeSurvivesReboot(t *testing.T) {
+	store := availability.NewFileStore(filepath.Join(t.TempDir(), "state", "availability.json"))
+	availabilityManager, statusManager, sender := boot(store)
+	statusManager.ReportBootStatus()
+	assert.Equal(t, map[int]core.ChargePointStatus{0: core.ChargePointStatusAvailable, 1: core.ChargePointStatusAvailable, 2: core.ChargePointStatusAvailable}, sender.statuses())
+	assert.Equal(t, core.AvailabilityStatusAccepted, changeAvailability(t, availabilityManager, 2, core.AvailabilityTypeInoperative))
+	assert.Equal(t, map[int]core.ChargePointStatus{2: core.ChargePointStatusUnavailable}, sender.statuses())
+
+	// Reboot
+	availabilityManager, statusManager, sender = boot(store)
+	assert.Equal(t, core.AvailabilityTypeInoperative, availabilityManager.Availability(2))
+	assert.Equal(t, core.AvailabilityTypeOperative, availabilityManager.Availability(1))
+	// Nothing is sent before the boot status is reported
+	statusManager.SetConnectorStatus(1, core.ChargePointStatusPreparing, core.NoError)
+	assert.Empty(t, sender.requests)
+	statusManager.ReportBootStatus()
+	assert.Equal(t, map[int]core.ChargePointStatus{0: core.ChargePointStatusAvailable, 1: core.ChargePointStatusPreparing, 2: core.ChargePointStatusUnavailable}, sender.statuses())
+	// Faulted connectors are reported as such
+	statusManager.SetConnectorStatus(2, core.ChargePointStatusFaulted, core.GroundFailure)
+	require.Len(t, sender.requests, 1)
+	assert.Equal(t, core.GroundFailure, sender.requests[0].ErrorCode)
+	assert.Equal(t, map[int]core.ChargePointStatus{2: core.ChargePointStatusFaulted}, sender.statuses())
+	statusManager.SetConnectorStatus(2, core.ChargePointStatusAvailable, core.NoError)
+	assert.Equal(t, map[int]core.ChargePointStatus{2: core.ChargePointStatusUnavailable}, sender.statuses())
+
+	// Back to Operative, which survives a reboot as well
+	assert.Equal(t, core.AvailabilityStatusAccepted, changeAvailability(t, availabilityManager, 2, core.AvailabilityTypeOperative))
+	assert.Equal(t, map[int]core.ChargePointStatus{2: core.ChargePointStatusAvailable}, sender.statuses())
+	availabilityManager, _, _ = boot(store)
+	assert.Equal(t, core.AvailabilityTypeOperative, availabilityManager.Availability(2))
+}
+
+func TestInoperativeChargePoint(t *testing.T) {
+	store := availability.NewFileStore(filepath.Join(t.TempDir(), "availability.json"))
+	availabilityManager, statusManager, sender := boot(store)
+	statusManager.ReportBootStatus()
+	sender.statuses()
+	assert.Equal(t, core.AvailabilityStatusRejected, changeAvailability(t, availabilityManager, 3, core.AvailabilityTypeInoperative))
+	// A transaction on connector 1 delays the change
+	availabilityManager.SetConnectorBusy(1, true)
+	assert.Equal(t, core.AvailabilityStatusScheduled, changeAvailability(t, availabilityManager, 0, core.AvailabilityTypeInoperative))
+	assert.Empty(t, sender.requests)
+	availabilityManager.SetConnectorBusy(1, false)
+	assert.Equal(t, map[int]core.ChargePointStatus{0: core.ChargePointStatusUnavailable, 1: core.ChargePointStatusUnavailable, 2: core.ChargePointStatusUnavailable}, sender.statuses())
+	// After a reboot, all connectors are reported as Unavailable
+	_, statusManager, sender = boot(store)
+	statusManager.ReportBootStatus()
+	assert.Equal(t, map[int]core.ChargePointStatus{0: core.ChargePointStatusUnavailable, 1: core.ChargePointStatusUnavailable, 2: core.ChargePointStatusUnavailable}, sender.statuses())
+}
+
+func TestCorruptedStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "availability.json")
+	for _, content := range []string{`{"1":`, `{"1":"Broken"}`, `{"-1":"Inoperative"}`} {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		store := availability.NewFileStore(path)
+		availabilityManager, statusManager, sender := boot(store)
+		// Boot isn't blocked, all connectors are Operative
+		assert.Error(t, availabilityManager.RestoreAvailability(), content)
+		assert.Equal(t, core.AvailabilityTypeOperative, availabilityManager.Availability(1))
+		statusManager.ReportBootStatus()
+		assert.Equal(t, map[int]core.ChargePointStatus{0: core.ChargePointStatusAvailable, 1: core.ChargePointStatusAvailable, 2: core.ChargePointStatusAvailable}, sender.statuses())
+		// The next accepted change overwrites the corrupted file
+		assert.Equal(t, core.AvailabilityStatusAccepted, changeAvailability(t, availabilityManager, 1, core.AvailabilityTypeInoperative))
+		availabilityManager, _, _ = boot(store)
+		assert.NoError(t, availabilityManager.RestoreAvailability())
+		assert.Equal(t, core.AvailabilityTypeInoperative, availabilityManager.Availability(1))
+	}
+}
+
+type failingStore struct {
+	availability.Store
+	saveErr error
+}
+
+func (s *failingStore) Save(availability map[int]core.AvailabilityType) error {
+	if s.saveErr != nil {
+		return s.saveErr
+	}
+	return s.Store.Save(availability)
+}
+
+func TestUnpersistedChangeIsRejected(t *testing.T) {
+	store := &failingStore{Store: availability.NewFileStore(filepath.Join(t.TempDir(), "availability.json")), saveErr: errors.New("disk full")}
+	availabilityManager, statusManager, sender := boot(store)
+	statusManager.ReportBootStatus()
+	sender.statuses()
+	assert.Equal(t, core.AvailabilityStatusRejected, changeAvailability(t, availabilityManager, 1, core.AvailabilityTypeInoperative))
+	assert.Equal(t, core.AvailabilityTypeOperative, availabilityManager.Availability(1))
+	assert.Empty(t, sender.requests)
+}
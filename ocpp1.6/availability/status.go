@@ -0,0 +1,143 @@
+package availability
+
+import (
+	"sync"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+)
+
+type connectorStatus struct {
+	status    core.ChargePointStatus // The status, as set by the charge point.
+	errorCode core.ChargePointErrorCode
+	reported  core.ChargePointStatus // The status last reported to the central system. Empty if none was reported yet.
+}
+
+// StatusManager reports the statuses of the connectors of a charge point to the central system,
+// taking their availability into account: connectors which are inoperative are reported as Unavailable, unless they are Faulted.
+//
+// Statuses are only recorded until ReportBootStatus sends the initial set of StatusNotification requests
+// after booting. From then on, a StatusNotificationRequest is sent whenever the status reported for a connector changes,
+// either via SetConnectorStatus or due to an availability change.
+//
+// A StatusManager is safe for concurrent use.
+type StatusManager struct {
+	// Sends a StatusNotificationRequest to the central system. The function shouldn't block, since it may be invoked
+	// from within OnChangeAvailability, e.g. by using ChargePoint.SendRequestAsync.
+	SendStatusNotification func(request *core.StatusNotificationRequest) error
+	// Invoked whenever a request couldn't be sent. Optional.
+	OnSendError  func(err error)
+	availability *AvailabilityManager
+	connectors   map[int]*connectorStatus
+	booted       bool
+	mutex        sync.Mutex
+}
+
+// NewStatusManager creates a manager for all connectors known to the availability manager, including connector 0.
+// All connectors are initially Available.
+//
+// Only a single StatusManager may be created per AvailabilityManager.
+func NewStatusManager(availability *AvailabilityManager, sendStatusNotification func(request *core.StatusNotificationRequest) error) *StatusManager {
+	m := &StatusManager{
+		SendStatusNotification: sendStatusNotification,
+		availability:           availability,
+		connectors:             map[int]*connectorStatus{},
+	}
+	for connectorId := 0; connectorId <= availability.Connectors(); connectorId++ {
+		m.connectors[connectorId] = &connectorStatus{status: core.ChargePointStatusAvailable, errorCode: core.NoError}
+	}
+	availability.mutex.Lock()
+	availability.onChange = m.onAvailabilityChanged
+	availability.mutex.Unlock()
+	return m
+}
+
+// ReportBootStatus sends a StatusNotificationRequest with the current status of every connector, including connector 0,
+// and enables reporting status changes. Should be invoked once the BootNotification was accepted.
+func (m *StatusManager) ReportBootStatus() {
+	m.mutex.Lock()
+	m.booted = true
+	notifications := m.collectNotifications(func(int) bool { return true }, true)
+	m.mutex.Unlock()
+	m.sendNotifications(notifications)
+}
+
+// SetConnectorStatus sets the status of a connector, as detected by the charge point.
+// A StatusNotificationRequest is sent, if the status reported to the central system changes. Unknown connectors are ignored.
+func (m *StatusManager) SetConnectorStatus(connectorId int, status core.ChargePointStatus, errorCode core.ChargePointErrorCode) {
+	m.mutex.Lock()
+	state, ok := m.connectors[connectorId]
+	if !ok {
+		m.mutex.Unlock()
+		return
+	}
+	state.status = status
+	state.errorCode = errorCode
+	notifications := m.collectNotifications(func(id int) bool { return id == connectorId }, false)
+	m.mutex.Unlock()
+	m.sendNotifications(notifications)
+}
+
+// ConnectorStatus returns the status of a connector, as reported to the central system. Returns false for unknown connectors.
+func (m *StatusManager) ConnectorStatus(connectorId int) (core.ChargePointStatus, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	state, ok := m.connectors[connectorId]
+	if !ok {
+		return "", false
+	}
+	return m.effectiveStatus(connectorId, state), true
+}
+
+func (m *StatusManager) onAvailabilityChanged() {
+	m.mutex.Lock()
+	notifications := m.collectNotifications(func(int) bool { return true }, false)
+	m.mutex.Unlock()
+	m.sendNotifications(notifications)
+}
+
+func (m *StatusManager) effectiveStatus(connectorId int, state *connectorStatus) core.ChargePointStatus {
+	if state.status != core.ChargePointStatusFaulted && m.availability.Availability(connectorId) == core.AvailabilityTypeInoperative {
+		return core.ChargePointStatusUnavailable
+	}
+	return state.status
+}
+
+// Returns a notification for every matching connector, whose reported status changed, and marks it as reported.
+// If force is set, a notification is returned for every matching connector. Nothing is returned before booting.
+func (m *StatusManager) collectNotifications(match func(connectorId int) bool, force bool) []*core.StatusNotificationRequest {
+	if !m.booted {
+		return nil
+	}
+	var notifications []*core.StatusNotificationRequest
+	for connectorId := 0; connectorId < len(m.connectors); connectorId++ {
+		state := m.connectors[connectorId]
+		if !match(connectorId) {
+			continue
+		}
+		status := m.effectiveStatus(connectorId, state)
+		if status == state.reported && !force {
+			continue
+		}
+		state.reported = status
+		notifications = append(notifications, core.NewStatusNotificationRequest(connectorId, state.errorCode, status))
+	}
+	return notifications
+}
+
+func (m *StatusManager) sendNotifications(notifications []*core.StatusNotificationRequest) {
+	for _, request := range notifications {
+		err := m.SendStatusNotification(request)
+		if err == nil {
+			continue
+		}
+		m.mutex.Lock()
+		// Allow the status to be reported again
+		if state, ok := m.connectors[request.ConnectorId]; ok && state.reported == request.Status {
+			state.reported = ""
+		}
+		m.mutex.Unlock()
+		if m.OnSendError != nil {
+			m.OnSendError(err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package availability
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+)
+
+// Store persists the availability of the connectors of a charge point, so that it survives reboots.
+// Connector 0 refers to the whole charge point.
+type Store interface {
+	// Load returns the persisted availability. A store without persisted state returns an empty map.
+	Load() (map[int]core.AvailabilityType, error)
+	// Save replaces the persisted availability.
+	Save(availability map[int]core.AvailabilityType) error
+}
+
+// FileStore is a Store keeping the availability of all connectors as JSON object in a single file.
+type FileStore struct {
+	path string
+}
+
+// NewFileStore creates a store writing to the passed file. The directory of the file is created on the first write, if needed.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+func (f *FileStore) Load() (map[int]core.AvailabilityType, error) {
+	availability := map[int]core.AvailabilityType{}
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return availability, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &availability); err != nil {
+		return nil, fmt.Errorf("corrupted availability file %v: %w", f.path, err)
+	}
+	for connectorId, availabilityType := range availability {
+		if connectorId < 0 || (availabilityType != core.AvailabilityTypeOperative && availabilityType != core.AvailabilityTypeInoperative) {
+			return nil, fmt.Errorf("corrupted availability file %v: invalid entry %v: %q", f.path, connectorId, availabilityType)
+		}
+	}
+	return availability, nil
+}
+
+func (f *FileStore) Save(availability map[int]core.AvailabilityType) error {
+	data, err := json.Marshal(availability)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(f.path)
+	if err = os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	// Write to a temporary file first, so that a crash never leaves a partially written file behind
+	tmp, err := os.CreateTemp(dir, "tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
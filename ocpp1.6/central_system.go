@@ -1,10 +1,15 @@
 package ocpp16
 
 import (
+	"context"
 	"fmt"
 	"reflect"
+	"sync"
+	"time"
 
+	"github.com/lorenzodonini/ocpp-go/events"
 	"github.com/lorenzodonini/ocpp-go/internal/callbackqueue"
+	"github.com/lorenzodonini/ocpp-go/logging"
 	"github.com/lorenzodonini/ocpp-go/ocpp"
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/firmware"
@@ -19,6 +24,7 @@ import (
 
 type centralSystem struct {
 	server               *ocppj.Server
+	network              ws.WsServer
 	coreHandler          core.CentralSystemHandler
 	localAuthListHandler localauth.CentralSystemHandler
 	firmwareHandler      firmware.CentralSystemHandler
@@ -26,6 +32,11 @@ type centralSystem struct {
 	remoteTriggerHandler remotetrigger.CentralSystemHandler
 	smartChargingHandler smartcharging.CentralSystemHandler
 	callbackQueue        callbackqueue.CallbackQueue
+	featureProfiles      *featureProfileStore
+	defaultHandlers      DefaultHandler
+	defaultObserver      RequestObserver
+	deduplicator         *transactionDeduplicator
+	deduplicatorMutex    sync.RWMutex
 	errC                 chan error
 }
 
@@ -35,8 +46,9 @@ func newCentralSystem(server *ocppj.Server) centralSystem {
 	}
 	server.SetDialect(ocpp.V16)
 	return centralSystem{
-		server:        server,
-		callbackQueue: callbackqueue.New(),
+		server:          server,
+		callbackQueue:   callbackqueue.New(),
+		featureProfiles: newFeatureProfileStore(),
 	}
 }
 
@@ -53,6 +65,41 @@ func (cs *centralSystem) Errors() <-chan error {
 	return cs.errC
 }
 
+// Sets the logger of the ocppj endpoint and of the websocket server, if the latter supports it.
+func (cs *centralSystem) SetLogger(logger logging.Logger) {
+	cs.server.SetLogger(logger)
+	if network, ok := cs.network.(interface{ SetLogger(logger logging.Logger) }); ok {
+		network.SetLogger(logger)
+	}
+}
+
+// Sets the collector of the ocppj endpoint and, if both support it, of the websocket server.
+func (cs *centralSystem) SetMetricsCollector(collector ocppj.MetricsCollector) {
+	cs.server.SetMetricsCollector(collector)
+	network, ok := cs.network.(interface {
+		SetMetricsCollector(collector ws.MetricsCollector)
+	})
+	if !ok {
+		return
+	}
+	if wsCollector, ok := collector.(ws.MetricsCollector); ok {
+		network.SetMetricsCollector(wsCollector)
+	} else {
+		network.SetMetricsCollector(nil)
+	}
+}
+
+func (cs *centralSystem) SetEventBus(bus *events.Bus) {
+	cs.server.SetEventBus(bus)
+	if network, ok := cs.network.(interface{ SetEventBus(bus *events.Bus) }); ok {
+		network.SetEventBus(bus)
+	}
+}
+
+func (cs *centralSystem) Stats() []ocppj.ClientStats {
+	return cs.server.Stats()
+}
+
 func (cs *centralSystem) ChangeAvailability(clientId string, callback func(confirmation *core.ChangeAvailabilityConfirmation, err error), connectorId int, availabilityType core.AvailabilityType, props ...func(request *core.ChangeAvailabilityRequest)) error {
 	request := core.NewChangeAvailabilityRequest(connectorId, availabilityType)
 	for _, fn := range props {
@@ -75,7 +122,11 @@ func (cs *centralSystem) ChangeConfiguration(clientId string, callback func(conf
 	}
 	genericCallback := func(confirmation ocpp.Response, protoError error) {
 		if confirmation != nil {
-			callback(confirmation.(*core.ChangeConfigurationConfirmation), protoError)
+			c := confirmation.(*core.ChangeConfigurationConfirmation)
+			if c.Status == core.ConfigurationStatusAccepted && request.Key == heartbeatIntervalKey {
+				cs.setHeartbeatInterval(clientId, request.Value)
+			}
+			callback(c, protoError)
 		} else {
 			callback(nil, protoError)
 		}
@@ -375,17 +426,50 @@ func (cs *centralSystem) SetNewChargePointHandler(handler ChargePointConnectionH
 func (cs *centralSystem) SetChargePointDisconnectedHandler(handler ChargePointConnectionHandler) {
 	cs.server.SetDisconnectedClientHandler(func(chargePoint ws.Channel) {
 		for cb, ok := cs.callbackQueue.Dequeue(chargePoint.ID()); ok; cb, ok = cs.callbackQueue.Dequeue(chargePoint.ID()) {
-			err := ocpp.NewError(ocppj.GenericError, "client disconnected, no response received from client", "")
-			cb(nil, err)
+			err := ocpp.NewError(ocppj.GenericError, "client disconnected, no response received from client", "").WithCause(ws.ErrNotConnected)
+			cs.invokeCallback(chargePoint.ID(), "", cb, nil, err)
 		}
 		handler(chargePoint)
 	})
 }
 
+func (cs *centralSystem) EnableLenientDecoding(hook func(coercion ocppj.Coercion)) {
+	cs.server.SetLenientDecoding(true, hook)
+}
+
+func (cs *centralSystem) HoldRequestsUntilHandlersReady(maxHold time.Duration, maxQueued int) {
+	cs.server.HoldRequestsUntilHandlersReady(maxHold, maxQueued)
+}
+
+func (cs *centralSystem) HandlersReady() {
+	cs.server.HandlersReady()
+}
+
+func (cs *centralSystem) SetClientInbox(inboxSize int, maxWorkers int) {
+	cs.server.SetClientInbox(inboxSize, maxWorkers)
+}
+
+func (cs *centralSystem) SetAuditHandler(handler func(entry ocppj.AuditEntry)) {
+	cs.server.SetAuditHandler(handler)
+}
+
+func (cs *centralSystem) DroppedAuditEntries() uint64 {
+	return cs.server.DroppedAuditEntries()
+}
+
 func (cs *centralSystem) SendRequestAsync(clientId string, request ocpp.Request, callback func(confirmation ocpp.Response, err error)) error {
+	_, err := cs.SendRequestAsyncWithID(clientId, request, callback)
+	return err
+}
+
+func (cs *centralSystem) SendRequestAsyncWithID(clientId string, request ocpp.Request, callback func(confirmation ocpp.Response, err error)) (string, error) {
+	return cs.SendRequestAsyncWithContext(context.Background(), clientId, request, callback)
+}
+
+func (cs *centralSystem) SendRequestAsyncWithContext(ctx context.Context, clientId string, request ocpp.Request, callback func(confirmation ocpp.Response, err error)) (string, error) {
 	featureName := request.GetFeatureName()
 	if _, found := cs.server.GetProfileForFeature(featureName); !found {
-		return fmt.Errorf("feature %v is unsupported on central system (missing profile), cannot send request", featureName)
+		return "", fmt.Errorf("%w %v on central system (missing profile), cannot send request", ocppj.ErrUnsupportedFeature, featureName)
 	}
 	switch featureName {
 	case core.ChangeAvailabilityFeatureName, core.ChangeConfigurationFeatureName, core.ClearCacheFeatureName, core.DataTransferFeatureName, core.GetConfigurationFeatureName, core.RemoteStartTransactionFeatureName, core.RemoteStopTransactionFeatureName, core.ResetFeatureName, core.UnlockConnectorFeatureName,
@@ -395,13 +479,35 @@ func (cs *centralSystem) SendRequestAsync(clientId string, request ocpp.Request,
 		remotetrigger.TriggerMessageFeatureName,
 		smartcharging.SetChargingProfileFeatureName, smartcharging.ClearChargingProfileFeatureName, smartcharging.GetCompositeScheduleFeatureName:
 	default:
-		return fmt.Errorf("unsupported action %v on central system, cannot send request", featureName)
+		return "", fmt.Errorf("%w %v on central system, cannot send request", ocppj.ErrUnsupportedFeature, featureName)
+	}
+	if sendLocalList, ok := request.(*localauth.SendLocalListRequest); ok {
+		if maxLength, known := cs.featureProfiles.maxListLength(clientId); known && len(sendLocalList.LocalAuthorizationList) > maxLength {
+			return "", &localauth.MaxLengthError{Length: len(sendLocalList.LocalAuthorizationList), MaxLength: maxLength}
+		}
+	}
+	// Checked regardless of message validation, since charge points may otherwise ignore or misinterpret the connector
+	if triggerMessage, ok := request.(*remotetrigger.TriggerMessageRequest); ok {
+		if err := triggerMessage.ValidateConnectorScope(); err != nil {
+			return "", err
+		}
+	}
+
+	send := func() (string, error) {
+		return cs.server.SendRequestWithContext(ctx, clientId, request)
 	}
+	return cs.callbackQueue.TryQueueWithRequestID(clientId, send, callback)
+}
 
-	send := func() error {
-		return cs.server.SendRequest(clientId, request)
+func (cs *centralSystem) CancelRequest(clientId string, requestId string) error {
+	if err := cs.server.CancelRequest(clientId, requestId); err != nil {
+		return err
+	}
+	if callback, ok := cs.callbackQueue.DequeueRequest(clientId, requestId); ok {
+		// Execute in separate goroutine, so the caller goroutine is available
+		go cs.invokeCallback(clientId, "", callback, nil, &ocppj.RequestCanceledError{ClientID: clientId, RequestID: requestId})
 	}
-	return cs.callbackQueue.TryQueue(clientId, send, callback)
+	return nil
 }
 
 func (cs *centralSystem) Start(listenPort int, listenPath string) {
@@ -439,6 +545,7 @@ func (cs *centralSystem) sendResponse(chargePointId string, confirmation ocpp.Re
 		return
 	}
 
+	cs.observeHeartbeatInterval(chargePointId, confirmation)
 	// send confirmation response
 	err = cs.server.SendResponse(chargePointId, requestId, confirmation)
 	if err != nil {
@@ -472,6 +579,12 @@ func (cs *centralSystem) handleIncomingRequest(chargePoint ChargePointConnection
 	if !found {
 		cs.notImplementedError(chargePoint.ID(), requestId, action)
 		return
+	} else if confirmation, ok := cs.defaultResponse(action); ok {
+		if cs.defaultObserver != nil {
+			cs.defaultObserver.ObserveRequest(chargePoint.ID(), request)
+		}
+		cs.sendResponse(chargePoint.ID(), confirmation, nil, requestId)
+		return
 	} else {
 		switch profile.Name {
 		case core.ProfileName:
@@ -510,6 +623,11 @@ func (cs *centralSystem) handleIncomingRequest(chargePoint ChargePointConnection
 	var err error
 	// Execute in separate goroutine, so the caller goroutine is available
 	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				cs.server.HandlePanic(chargePoint.ID(), requestId, ocppj.NewHandlerPanicError(chargePoint.ID(), action, r))
+			}
+		}()
 		switch action {
 		case core.BootNotificationFeatureName:
 			confirmation, err = cs.coreHandler.OnBootNotification(chargePoint.ID(), request.(*core.BootNotificationRequest))
@@ -522,9 +640,13 @@ func (cs *centralSystem) handleIncomingRequest(chargePoint ChargePointConnection
 		case core.MeterValuesFeatureName:
 			confirmation, err = cs.coreHandler.OnMeterValues(chargePoint.ID(), request.(*core.MeterValuesRequest))
 		case core.StartTransactionFeatureName:
-			confirmation, err = cs.coreHandler.OnStartTransaction(chargePoint.ID(), request.(*core.StartTransactionRequest))
+			confirmation, err = cs.handleTransactionRequest(chargePoint.ID(), request, func() (ocpp.Response, error) {
+				return cs.coreHandler.OnStartTransaction(chargePoint.ID(), request.(*core.StartTransactionRequest))
+			})
 		case core.StopTransactionFeatureName:
-			confirmation, err = cs.coreHandler.OnStopTransaction(chargePoint.ID(), request.(*core.StopTransactionRequest))
+			confirmation, err = cs.handleTransactionRequest(chargePoint.ID(), request, func() (ocpp.Response, error) {
+				return cs.coreHandler.OnStopTransaction(chargePoint.ID(), request.(*core.StopTransactionRequest))
+			})
 		case core.StatusNotificationFeatureName:
 			confirmation, err = cs.coreHandler.OnStatusNotification(chargePoint.ID(), request.(*core.StatusNotificationRequest))
 		case firmware.DiagnosticsStatusNotificationFeatureName:
@@ -540,9 +662,13 @@ func (cs *centralSystem) handleIncomingRequest(chargePoint ChargePointConnection
 }
 
 func (cs *centralSystem) handleIncomingConfirmation(chargePoint ChargePointConnection, confirmation ocpp.Response, requestId string) {
-	if callback, ok := cs.callbackQueue.Dequeue(chargePoint.ID()); ok {
+	if getConfiguration, ok := confirmation.(*core.GetConfigurationConfirmation); ok {
+		cs.featureProfiles.observe(chargePoint.ID(), getConfiguration, cs.server.Clock().Now())
+	}
+	cs.observeHeartbeatInterval(chargePoint.ID(), confirmation)
+	if callback, ok := cs.callbackQueue.DequeueRequest(chargePoint.ID(), requestId); ok {
 		// Execute in separate goroutine, so the caller goroutine is available
-		go callback(confirmation, nil)
+		go cs.invokeCallback(chargePoint.ID(), confirmation.GetFeatureName(), callback, confirmation, nil)
 	} else {
 		err := fmt.Errorf("no handler available for call of type %v from client %s for request %s", confirmation.GetFeatureName(), chargePoint.ID(), requestId)
 		cs.error(err)
@@ -550,22 +676,32 @@ func (cs *centralSystem) handleIncomingConfirmation(chargePoint ChargePointConne
 }
 
 func (cs *centralSystem) handleIncomingError(chargePoint ChargePointConnection, err *ocpp.Error, details interface{}) {
-	if callback, ok := cs.callbackQueue.Dequeue(chargePoint.ID()); ok {
+	if callback, ok := cs.callbackQueue.DequeueRequest(chargePoint.ID(), err.MessageId); ok {
 		// Execute in separate goroutine, so the caller goroutine is available
-		go callback(nil, err)
+		go cs.invokeCallback(chargePoint.ID(), "", callback, nil, err)
 	} else {
 		err := fmt.Errorf("no handler available for call error %w from client %s", err, chargePoint.ID())
 		cs.error(err)
 	}
 }
 
-func (cs *centralSystem) handleCanceledRequest(chargePointID string, request ocpp.Request, err *ocpp.Error) {
-	if callback, ok := cs.callbackQueue.Dequeue(chargePointID); ok {
+func (cs *centralSystem) handleCanceledRequest(chargePointID string, requestID string, request ocpp.Request, err *ocpp.Error) {
+	if callback, ok := cs.callbackQueue.DequeueRequest(chargePointID, requestID); ok {
 		// Execute in separate goroutine, so the caller goroutine is available
-		go callback(nil, err)
+		go cs.invokeCallback(chargePointID, request.GetFeatureName(), callback, nil, err)
 	} else {
 		err := fmt.Errorf("no handler available for canceled request %s for client %s: %w",
 			request.GetFeatureName(), chargePointID, err)
 		cs.error(err)
 	}
 }
+
+// Invokes a result callback. Panics are recovered and reported, without affecting the connection.
+func (cs *centralSystem) invokeCallback(chargePointID string, action string, callback func(confirmation ocpp.Response, err error), confirmation ocpp.Response, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			cs.server.HandlePanic(chargePointID, "", ocppj.NewHandlerPanicError(chargePointID, action, r))
+		}
+	}()
+	callback(confirmation, err)
+}
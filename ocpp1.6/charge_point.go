@@ -3,8 +3,15 @@ package ocpp16
 import (
 	"fmt"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/lorenzodonini/ocpp-go/clocksync"
+	"github.com/lorenzodonini/ocpp-go/events"
 	"github.com/lorenzodonini/ocpp-go/internal/callbackqueue"
+	"github.com/lorenzodonini/ocpp-go/logging"
 	"github.com/lorenzodonini/ocpp-go/ocpp"
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/firmware"
@@ -14,10 +21,12 @@ import (
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/smartcharging"
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
 	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/lorenzodonini/ocpp-go/ws"
 )
 
 type chargePoint struct {
 	client               *ocppj.Client
+	network              ws.WsClient
 	coreHandler          core.ChargePointHandler
 	localAuthListHandler localauth.ChargePointHandler
 	firmwareHandler      firmware.ChargePointHandler
@@ -27,6 +36,9 @@ type chargePoint struct {
 	confirmationHandler  chan ocpp.Response
 	errorHandler         chan error
 	callbacks            callbackqueue.CallbackQueue
+	clock                *clocksync.Clock
+	sendTimes            map[ocpp.Request]time.Time // The send times of BootNotification and Heartbeat requests, awaiting a confirmation.
+	sendTimesMutex       sync.Mutex
 	stopC                chan struct{}
 	errC                 chan error // external error channel
 }
@@ -51,6 +63,24 @@ func (cp *chargePoint) Errors() <-chan error {
 	return cp.errC
 }
 
+// Sets the logger of the ocppj endpoint and of the websocket client, if the latter supports it.
+func (cp *chargePoint) SetLogger(logger logging.Logger) {
+	cp.client.SetLogger(logger)
+	if network, ok := cp.network.(interface{ SetLogger(logger logging.Logger) }); ok {
+		if logger != nil {
+			logger = logger.With(logging.ChargePointID(cp.client.Id))
+		}
+		network.SetLogger(logger)
+	}
+}
+
+func (cp *chargePoint) SetEventBus(bus *events.Bus) {
+	cp.client.SetEventBus(bus)
+	if network, ok := cp.network.(interface{ SetEventBus(bus *events.Bus) }); ok {
+		network.SetEventBus(bus)
+	}
+}
+
 func (cp *chargePoint) BootNotification(chargePointModel string, chargePointVendor string, props ...func(request *core.BootNotificationRequest)) (*core.BootNotificationConfirmation, error) {
 	request := core.NewBootNotificationRequest(chargePointModel, chargePointVendor)
 	for _, fn := range props {
@@ -147,6 +177,9 @@ func (cp *chargePoint) StatusNotification(connectorId int, errorCode core.Charge
 	for _, fn := range props {
 		fn(request)
 	}
+	if request.Timestamp == nil && cp.clock.Mode() == clocksync.ModeTrackOffset {
+		request.Timestamp = types.NewDateTime(cp.clock.Now())
+	}
 	confirmation, err := cp.SendRequest(request)
 	if err != nil {
 		return nil, err
@@ -208,7 +241,7 @@ func (cp *chargePoint) SetSmartChargingHandler(handler smartcharging.ChargePoint
 func (cp *chargePoint) SendRequest(request ocpp.Request) (ocpp.Response, error) {
 	featureName := request.GetFeatureName()
 	if _, found := cp.client.GetProfileForFeature(featureName); !found {
-		return nil, fmt.Errorf("feature %v is unsupported on charge point (missing profile), cannot send request", featureName)
+		return nil, fmt.Errorf("%w %v on charge point (missing profile), cannot send request", ocppj.ErrUnsupportedFeature, featureName)
 	}
 
 	// Wraps an asynchronous response
@@ -221,9 +254,9 @@ func (cp *chargePoint) SendRequest(request ocpp.Request) (ocpp.Response, error)
 	send := func() error {
 		return cp.client.SendRequest(request)
 	}
-	err := cp.callbacks.TryQueue("main", send, func(confirmation ocpp.Response, err error) {
+	err := cp.callbacks.TryQueue("main", send, cp.observeCurrentTime(request, func(confirmation ocpp.Response, err error) {
 		asyncResponseC <- asyncResponse{r: confirmation, e: err}
-	})
+	}))
 	if err != nil {
 		return nil, err
 	}
@@ -234,37 +267,104 @@ func (cp *chargePoint) SendRequest(request ocpp.Request) (ocpp.Response, error)
 		}
 		return asyncResult.r, asyncResult.e
 	case <-cp.stopC:
-		return nil, fmt.Errorf("client stopped while waiting for response to %v", request.GetFeatureName())
+		return nil, fmt.Errorf("%w while waiting for response to %v", ocppj.ErrClientStopped, request.GetFeatureName())
 	}
 }
 
 func (cp *chargePoint) SendRequestAsync(request ocpp.Request, callback func(confirmation ocpp.Response, err error)) error {
 	featureName := request.GetFeatureName()
 	if _, found := cp.client.GetProfileForFeature(featureName); !found {
-		return fmt.Errorf("feature %v is unsupported on charge point (missing profile), cannot send request", featureName)
+		return fmt.Errorf("%w %v on charge point (missing profile), cannot send request", ocppj.ErrUnsupportedFeature, featureName)
 	}
 	switch featureName {
 	case core.AuthorizeFeatureName, core.BootNotificationFeatureName, core.DataTransferFeatureName, core.HeartbeatFeatureName, core.MeterValuesFeatureName, core.StartTransactionFeatureName, core.StopTransactionFeatureName, core.StatusNotificationFeatureName,
 		firmware.DiagnosticsStatusNotificationFeatureName, firmware.FirmwareStatusNotificationFeatureName:
 		break
 	default:
-		return fmt.Errorf("unsupported action %v on charge point, cannot send request", featureName)
+		return fmt.Errorf("%w %v on charge point, cannot send request", ocppj.ErrUnsupportedFeature, featureName)
 	}
 	// Response will be retrieved asynchronously via asyncHandler
 	send := func() error {
 		return cp.client.SendRequest(request)
 	}
-	err := cp.callbacks.TryQueue("main", send, callback)
+	err := cp.callbacks.TryQueue("main", send, cp.observeCurrentTime(request, callback))
+	if err != nil {
+		cp.forgetSendTime(request)
+	}
 	return err
 }
 
+// Wraps the callback for BootNotification and Heartbeat requests,
+// feeding the current time contained in the confirmation to the clock synchronization mechanism.
+// Callbacks for all other requests are returned as is.
+func (cp *chargePoint) observeCurrentTime(request ocpp.Request, callback func(confirmation ocpp.Response, err error)) func(confirmation ocpp.Response, err error) {
+	switch request.GetFeatureName() {
+	case core.BootNotificationFeatureName, core.HeartbeatFeatureName:
+	default:
+		return callback
+	}
+	// The enqueue time is replaced by the actual send time, once the dispatcher sends the request
+	cp.sendTimesMutex.Lock()
+	cp.sendTimes[request] = cp.client.Clock().Now()
+	cp.sendTimesMutex.Unlock()
+	return func(confirmation ocpp.Response, err error) {
+		var currentTime *types.DateTime
+		switch c := confirmation.(type) {
+		case *core.BootNotificationConfirmation:
+			currentTime = c.CurrentTime
+		case *core.HeartbeatConfirmation:
+			currentTime = c.CurrentTime
+		}
+		sent := cp.forgetSendTime(request)
+		if err == nil && currentTime != nil {
+			cp.clock.Observe(currentTime.Time, cp.client.Clock().Now().Sub(sent))
+		}
+		callback(confirmation, err)
+	}
+}
+
+// Records the time a BootNotification or Heartbeat request was sent at, as reported by the dispatcher.
+func (cp *chargePoint) onRequestSent(requestID string, request ocpp.Request, sentAt time.Time) {
+	switch request.GetFeatureName() {
+	case core.BootNotificationFeatureName, core.HeartbeatFeatureName:
+	default:
+		return
+	}
+	cp.sendTimesMutex.Lock()
+	defer cp.sendTimesMutex.Unlock()
+	if _, ok := cp.sendTimes[request]; ok {
+		cp.sendTimes[request] = sentAt
+	}
+}
+
+// Removes the recorded send time of a request and returns it.
+func (cp *chargePoint) forgetSendTime(request ocpp.Request) time.Time {
+	cp.sendTimesMutex.Lock()
+	defer cp.sendTimesMutex.Unlock()
+	sent := cp.sendTimes[request]
+	delete(cp.sendTimes, request)
+	return sent
+}
+
+func (cp *chargePoint) SetClockSyncMode(mode clocksync.Mode) {
+	cp.clock.SetMode(mode)
+}
+
+func (cp *chargePoint) SetClockSyncHandler(handler func(authoritativeTime time.Time)) {
+	cp.clock.SetHook(handler)
+}
+
+func (cp *chargePoint) Now() time.Time {
+	return cp.clock.Now()
+}
+
 func (cp *chargePoint) asyncCallbackHandler() {
 	for {
 		select {
 		case confirmation := <-cp.confirmationHandler:
 			// Get and invoke callback
 			if callback, ok := cp.callbacks.Dequeue("main"); ok {
-				callback(confirmation, nil)
+				cp.invokeCallback(confirmation.GetFeatureName(), callback, confirmation, nil)
 			} else {
 				err := fmt.Errorf("no handler available for incoming response %v", confirmation.GetFeatureName())
 				cp.error(err)
@@ -272,7 +372,7 @@ func (cp *chargePoint) asyncCallbackHandler() {
 		case protoError := <-cp.errorHandler:
 			// Get and invoke callback
 			if callback, ok := cp.callbacks.Dequeue("main"); ok {
-				callback(nil, protoError)
+				cp.invokeCallback("", callback, nil, protoError)
 			} else {
 				err := fmt.Errorf("no handler available for error %v", protoError.Error())
 				cp.error(err)
@@ -290,11 +390,21 @@ func (cp *chargePoint) clearCallbacks(invokeCallback bool) {
 	for cb, ok := cp.callbacks.Dequeue("main"); ok; cb, ok = cp.callbacks.Dequeue("main") {
 		if invokeCallback {
 			err := ocpp.NewError(ocppj.GenericError, "client stopped, no response received from server", "")
-			cb(nil, err)
+			cp.invokeCallback("", cb, nil, err)
 		}
 	}
 }
 
+// Invokes a result callback. Panics are recovered and reported, without affecting the connection.
+func (cp *chargePoint) invokeCallback(action string, callback func(confirmation ocpp.Response, err error), confirmation ocpp.Response, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			cp.client.HandlePanic("", ocppj.NewHandlerPanicError(cp.client.Id, action, r))
+		}
+	}()
+	callback(confirmation, err)
+}
+
 func (cp *chargePoint) sendResponse(confirmation ocpp.Response, err error, requestId string) {
 	if err != nil {
 		// Send error response
@@ -357,6 +467,44 @@ func (cp *chargePoint) IsConnected() bool {
 	return cp.client.IsConnected()
 }
 
+func (cp *chargePoint) EnableResponseWatchdog(maxSilence time.Duration) {
+	cp.client.EnableResponseWatchdog(maxSilence)
+}
+
+func (cp *chargePoint) SetResponseWatchdogHandler(handler func(silence time.Duration)) {
+	cp.client.SetOnResponseWatchdogTriggered(handler)
+}
+
+func (cp *chargePoint) ApplyWebSocketPingInterval(seconds int) error {
+	if seconds < 0 {
+		return fmt.Errorf("invalid %v %v, must be >= 0", core.WebSocketPingIntervalKey, seconds)
+	}
+	return cp.client.SetWebSocketPingPeriod(time.Duration(seconds) * time.Second)
+}
+
+// Validates and applies changes to configuration keys, which are handled directly by the library.
+// All other requests are passed on to the core handler as is.
+func (cp *chargePoint) handleChangeConfiguration(request *core.ChangeConfigurationRequest) (*core.ChangeConfigurationConfirmation, error) {
+	if request.Key != core.WebSocketPingIntervalKey {
+		return cp.coreHandler.OnChangeConfiguration(request)
+	}
+	seconds, err := strconv.Atoi(strings.TrimSpace(request.Value))
+	if err != nil || seconds < 0 {
+		return core.NewChangeConfigurationConfirmation(core.ConfigurationStatusRejected), nil
+	}
+	confirmation, err := cp.coreHandler.OnChangeConfiguration(request)
+	if err == nil && confirmation != nil && confirmation.Status == core.ConfigurationStatusAccepted {
+		if err := cp.ApplyWebSocketPingInterval(seconds); err != nil {
+			cp.error(err)
+		}
+	}
+	return confirmation, err
+}
+
+func (cp *chargePoint) EnableLenientDecoding(hook func(coercion ocppj.Coercion)) {
+	cp.client.SetLenientDecoding(true, hook)
+}
+
 func (cp *chargePoint) notImplementedError(requestId string, action string) {
 	err := cp.client.SendError(requestId, ocppj.NotImplemented, fmt.Sprintf("no handler for action %v implemented", action), nil)
 	if err != nil {
@@ -421,7 +569,7 @@ func (cp *chargePoint) handleIncomingRequest(request ocpp.Request, requestId str
 	case core.ChangeAvailabilityFeatureName:
 		confirmation, err = cp.coreHandler.OnChangeAvailability(request.(*core.ChangeAvailabilityRequest))
 	case core.ChangeConfigurationFeatureName:
-		confirmation, err = cp.coreHandler.OnChangeConfiguration(request.(*core.ChangeConfigurationRequest))
+		confirmation, err = cp.handleChangeConfiguration(request.(*core.ChangeConfigurationRequest))
 	case core.ClearCacheFeatureName:
 		confirmation, err = cp.coreHandler.OnClearCache(request.(*core.ClearCacheRequest))
 	case core.DataTransferFeatureName:
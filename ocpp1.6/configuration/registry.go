@@ -0,0 +1,159 @@
+// Package configuration contains helpers for managing the configuration keys of an OCPP 1.6 charge point.
+//
+// The Registry keeps the configuration keys known to the charge point and answers GetConfiguration requests:
+//
+//	registry := configuration.NewRegistry()
+//	registry.Register(configuration.Entry{Key: "HeartbeatInterval", Value: "300"})
+//	registry.Register(configuration.Entry{Key: "AuthorizationKey", Sensitive: true})
+//	// In the OnGetConfiguration callback
+//	return registry.BuildGetConfigurationResponse(request.Key, maxKeys)
+//
+// On the central system side, MergeGetConfigurationResponses combines multiple (partial) responses into a single map.
+package configuration
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+)
+
+var (
+	// ErrUnknownKey is returned when accessing a key, which wasn't registered.
+	ErrUnknownKey = errors.New("unknown configuration key")
+	// ErrReadonlyKey is returned when attempting to change a readonly key.
+	ErrReadonlyKey = errors.New("readonly configuration key")
+)
+
+// Entry is a configuration key known to the charge point.
+type Entry struct {
+	Key      string
+	Value    string
+	Readonly bool
+	// Sensitive values (e.g. passwords) are write-only: the key is reported to the central system, but its value never is.
+	Sensitive bool
+}
+
+// Registry holds the configuration keys of a charge point. Keys are case-sensitive.
+//
+// A Registry is safe for concurrent use.
+type Registry struct {
+	entries map[string]Entry
+	mutex   sync.RWMutex
+}
+
+// NewRegistry creates a new, empty registry.
+func NewRegistry() *Registry {
+	return &Registry{entries: map[string]Entry{}}
+}
+
+// Register adds a configuration key to the registry, replacing any previous entry with the same key.
+func (r *Registry) Register(entry Entry) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.entries[entry.Key] = entry
+}
+
+// Get returns the entry of a configuration key.
+func (r *Registry) Get(key string) (Entry, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	entry, ok := r.entries[key]
+	return entry, ok
+}
+
+// Set changes the value of a configuration key. Returns an error wrapping ErrUnknownKey or ErrReadonlyKey,
+// if the key may not be changed.
+func (r *Registry) Set(key string, value string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	entry, ok := r.entries[key]
+	if !ok {
+		return fmt.Errorf("cannot set %v: %w", key, ErrUnknownKey)
+	}
+	if entry.Readonly {
+		return fmt.Errorf("cannot set %v: %w", key, ErrReadonlyKey)
+	}
+	entry.Value = value
+	r.entries[key] = entry
+	return nil
+}
+
+// BuildGetConfigurationResponse builds the confirmation to a GetConfigurationRequest for the requested keys.
+//
+// If no keys are requested, all keys are reported, sorted by name. Otherwise the recognized keys are reported in request order,
+// while unrecognized keys are listed as unknownKey, also in request order. Duplicate requested keys are reported once.
+// Values of sensitive keys are omitted.
+//
+// If maxKeys is positive, at most maxKeys keys (including unknown keys) are reported, i.e. the first maxKeys keys
+// in the order described above. This matches the GetConfigurationMaxKeys configuration key.
+//
+// An error is returned if the confirmation violates the limits of the message, e.g. because a value is too long.
+func (r *Registry) BuildGetConfigurationResponse(requestedKeys []string, maxKeys int) (*core.GetConfigurationConfirmation, error) {
+	r.mutex.RLock()
+	if len(requestedKeys) == 0 {
+		requestedKeys = make([]string, 0, len(r.entries))
+		for key := range r.entries {
+			requestedKeys = append(requestedKeys, key)
+		}
+		sort.Strings(requestedKeys)
+	}
+	confirmation := core.NewGetConfigurationConfirmation(nil)
+	seen := map[string]bool{}
+	for _, key := range requestedKeys {
+		if maxKeys > 0 && len(seen) >= maxKeys {
+			break
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		entry, ok := r.entries[key]
+		if !ok {
+			confirmation.UnknownKey = append(confirmation.UnknownKey, key)
+			continue
+		}
+		configurationKey := core.ConfigurationKey{Key: entry.Key, Readonly: entry.Readonly}
+		if !entry.Sensitive {
+			value := entry.Value
+			configurationKey.Value = &value
+		}
+		confirmation.ConfigurationKey = append(confirmation.ConfigurationKey, configurationKey)
+	}
+	r.mutex.RUnlock()
+	if err := types.Validate.Struct(confirmation); err != nil {
+		return nil, fmt.Errorf("invalid GetConfiguration confirmation: %w", err)
+	}
+	return confirmation, nil
+}
+
+// MergeGetConfigurationResponses merges multiple GetConfiguration confirmations of the same charge point into a single map,
+// e.g. when the keys were requested in several batches due to GetConfigurationMaxKeys. Later confirmations take precedence.
+//
+// The unknown keys of all confirmations are returned sorted, excluding keys which were reported by any of the confirmations.
+// Nil confirmations are ignored.
+func MergeGetConfigurationResponses(confirmations ...*core.GetConfigurationConfirmation) (keys map[string]core.ConfigurationKey, unknownKeys []string) {
+	keys = map[string]core.ConfigurationKey{}
+	unknown := map[string]bool{}
+	for _, confirmation := range confirmations {
+		if confirmation == nil {
+			continue
+		}
+		for _, configurationKey := range confirmation.ConfigurationKey {
+			keys[configurationKey.Key] = configurationKey
+		}
+		for _, key := range confirmation.UnknownKey {
+			unknown[key] = true
+		}
+	}
+	for key := range unknown {
+		if _, ok := keys[key]; !ok {
+			unknownKeys = append(unknownKeys, key)
+		}
+	}
+	sort.Strings(unknownKeys)
+	return keys, unknownKeys
+}
@@ -0,0 +1,108 @@
+package configuration_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/configuration"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+)
+
+func newString(s string) *string {
+	return &s
+}
+
+func newTestRegistry() *configuration.Registry {
+	registry := configuration.NewRegistry()
+	registry.Register(configuration.Entry{Key: "HeartbeatInterval", Value: "300"})
+	registry.Register(configuration.Entry{Key: "NumberOfConnectors", Value: "2", Readonly: true})
+	registry.Register(configuration.Entry{Key: "AuthorizationKey", Value: "secret", Sensitive: true})
+	registry.Register(configuration.Entry{Key: "MeterValueSampleInterval", Value: "60"})
+	return registry
+}
+
+func TestBuildGetConfigurationResponseAllKeys(t *testing.T) {
+	registry := newTestRegistry()
+	confirmation, err := registry.BuildGetConfigurationResponse(nil, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []core.ConfigurationKey{
+		{Key: "AuthorizationKey"},
+		{Key: "HeartbeatInterval", Value: newString("300")},
+		{Key: "MeterValueSampleInterval", Value: newString("60")},
+		{Key: "NumberOfConnectors", Readonly: true, Value: newString("2")},
+	}, confirmation.ConfigurationKey)
+	assert.Empty(t, confirmation.UnknownKey)
+}
+
+func TestBuildGetConfigurationResponseSubset(t *testing.T) {
+	registry := newTestRegistry()
+	confirmation, err := registry.BuildGetConfigurationResponse([]string{"NumberOfConnectors", "Foo", "HeartbeatInterval", "Bar", "Foo"}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, []core.ConfigurationKey{
+		{Key: "NumberOfConnectors", Readonly: true, Value: newString("2")},
+		{Key: "HeartbeatInterval", Value: newString("300")},
+	}, confirmation.ConfigurationKey)
+	assert.Equal(t, []string{"Foo", "Bar"}, confirmation.UnknownKey)
+	// Keys are case-sensitive
+	confirmation, err = registry.BuildGetConfigurationResponse([]string{"heartbeatinterval"}, 0)
+	require.NoError(t, err)
+	assert.Empty(t, confirmation.ConfigurationKey)
+	assert.Equal(t, []string{"heartbeatinterval"}, confirmation.UnknownKey)
+}
+
+func TestBuildGetConfigurationResponseTruncation(t *testing.T) {
+	registry := newTestRegistry()
+	confirmation, err := registry.BuildGetConfigurationResponse(nil, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []core.ConfigurationKey{
+		{Key: "AuthorizationKey"},
+		{Key: "HeartbeatInterval", Value: newString("300")},
+	}, confirmation.ConfigurationKey)
+	// Unknown keys count towards the limit
+	confirmation, err = registry.BuildGetConfigurationResponse([]string{"Foo", "Foo", "MeterValueSampleInterval", "HeartbeatInterval"}, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []core.ConfigurationKey{{Key: "MeterValueSampleInterval", Value: newString("60")}}, confirmation.ConfigurationKey)
+	assert.Equal(t, []string{"Foo"}, confirmation.UnknownKey)
+	// Values exceeding the message limits are reported
+	registry.Register(configuration.Entry{Key: "LongValue", Value: strings.Repeat("x", 501)})
+	_, err = registry.BuildGetConfigurationResponse([]string{"LongValue"}, 0)
+	assert.Error(t, err)
+}
+
+func TestBuildGetConfigurationResponseMasking(t *testing.T) {
+	registry := newTestRegistry()
+	require.NoError(t, registry.Set("AuthorizationKey", "changed"))
+	confirmation, err := registry.BuildGetConfigurationResponse([]string{"AuthorizationKey"}, 0)
+	require.NoError(t, err)
+	require.Len(t, confirmation.ConfigurationKey, 1)
+	assert.Nil(t, confirmation.ConfigurationKey[0].Value)
+	// The value is still available locally
+	entry, ok := registry.Get("AuthorizationKey")
+	require.True(t, ok)
+	assert.Equal(t, "changed", entry.Value)
+	assert.ErrorIs(t, registry.Set("NumberOfConnectors", "3"), configuration.ErrReadonlyKey)
+	assert.ErrorIs(t, registry.Set("Foo", "bar"), configuration.ErrUnknownKey)
+}
+
+func TestMergeGetConfigurationResponses(t *testing.T) {
+	first := core.NewGetConfigurationConfirmation([]core.ConfigurationKey{
+		{Key: "HeartbeatInterval", Value: newString("300")},
+		{Key: "NumberOfConnectors", Readonly: true, Value: newString("2")},
+	})
+	first.UnknownKey = []string{"Foo", "MeterValueSampleInterval"}
+	second := core.NewGetConfigurationConfirmation([]core.ConfigurationKey{
+		{Key: "HeartbeatInterval", Value: newString("600")},
+		{Key: "MeterValueSampleInterval", Value: newString("60")},
+	})
+	second.UnknownKey = []string{"Bar", "Foo"}
+	keys, unknownKeys := configuration.MergeGetConfigurationResponses(first, nil, second)
+	assert.Equal(t, map[string]core.ConfigurationKey{
+		"HeartbeatInterval":        {Key: "HeartbeatInterval", Value: newString("600")},
+		"NumberOfConnectors":       {Key: "NumberOfConnectors", Readonly: true, Value: newString("2")},
+		"MeterValueSampleInterval": {Key: "MeterValueSampleInterval", Value: newString("60")},
+	}, keys)
+	assert.Equal(t, []string{"Bar", "Foo"}, unknownKeys)
+}
@@ -91,5 +91,5 @@ func NewBootNotificationConfirmation(currentTime *types.DateTime, interval int,
 }
 
 func init() {
-	_ = types.Validate.RegisterValidation("registrationStatus16", isValidRegistrationStatus)
+	_ = types.RegisterValidation("registrationStatus16", isValidRegistrationStatus)
 }
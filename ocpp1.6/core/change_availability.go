@@ -97,6 +97,6 @@ func NewChangeAvailabilityConfirmation(status AvailabilityStatus) *ChangeAvailab
 }
 
 func init() {
-	_ = types.Validate.RegisterValidation("availabilityType", isValidAvailabilityType)
-	_ = types.Validate.RegisterValidation("availabilityStatus", isValidAvailabilityStatus)
+	_ = types.RegisterValidation("availabilityType", isValidAvailabilityType)
+	_ = types.RegisterValidation("availabilityStatus", isValidAvailabilityStatus)
 }
@@ -10,6 +10,11 @@ import (
 
 const ChangeConfigurationFeatureName = "ChangeConfiguration"
 
+// The configuration key defining the interval in seconds, at which the charge point sends websocket pings. 0 disables client pings.
+//
+// Changes to this key are applied automatically to the live connection of a charge point.
+const WebSocketPingIntervalKey = "WebSocketPingInterval"
+
 // Status in ChangeConfigurationConfirmation.
 type ConfigurationStatus string
 
@@ -85,5 +90,5 @@ func NewChangeConfigurationConfirmation(status ConfigurationStatus) *ChangeConfi
 }
 
 func init() {
-	_ = types.Validate.RegisterValidation("configurationStatus", isValidConfigurationStatus)
+	_ = types.RegisterValidation("configurationStatus", isValidConfigurationStatus)
 }
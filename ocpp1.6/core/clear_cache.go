@@ -75,5 +75,5 @@ func NewClearCacheConfirmation(status ClearCacheStatus) *ClearCacheConfirmation
 }
 
 func init() {
-	_ = types.Validate.RegisterValidation("cacheStatus16", isValidClearCacheStatus)
+	_ = types.RegisterValidation("cacheStatus16", isValidClearCacheStatus)
 }
@@ -79,5 +79,5 @@ func NewDataTransferConfirmation(status DataTransferStatus) *DataTransferConfirm
 }
 
 func init() {
-	_ = types.Validate.RegisterValidation("dataTransferStatus16", isValidDataTransferStatus)
+	_ = types.RegisterValidation("dataTransferStatus16", isValidDataTransferStatus)
 }
@@ -67,5 +67,5 @@ func validateHeartbeatConfirmation(sl validator.StructLevel) {
 }
 
 func init() {
-	types.Validate.RegisterStructValidation(validateHeartbeatConfirmation, HeartbeatConfirmation{})
+	types.RegisterStructValidation(validateHeartbeatConfirmation, HeartbeatConfirmation{})
 }
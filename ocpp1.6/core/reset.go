@@ -98,6 +98,6 @@ func NewResetConfirmation(status ResetStatus) *ResetConfirmation {
 }
 
 func init() {
-	_ = types.Validate.RegisterValidation("resetType16", isValidResetType)
-	_ = types.Validate.RegisterValidation("resetStatus16", isValidResetStatus)
+	_ = types.RegisterValidation("resetType16", isValidResetType)
+	_ = types.RegisterValidation("resetStatus16", isValidResetStatus)
 }
@@ -132,6 +132,6 @@ func NewStatusNotificationConfirmation() *StatusNotificationConfirmation {
 }
 
 func init() {
-	_ = types.Validate.RegisterValidation("chargePointErrorCode", isValidChargePointErrorCode)
-	_ = types.Validate.RegisterValidation("chargePointStatus", isValidChargePointStatus)
+	_ = types.RegisterValidation("chargePointErrorCode", isValidChargePointErrorCode)
+	_ = types.RegisterValidation("chargePointStatus", isValidChargePointStatus)
 }
@@ -109,5 +109,5 @@ func NewStopTransactionConfirmation() *StopTransactionConfirmation {
 
 //TODO: advanced validation
 func init() {
-	_ = types.Validate.RegisterValidation("reason", isValidReason)
+	_ = types.RegisterValidation("reason", isValidReason)
 }
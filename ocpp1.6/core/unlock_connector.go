@@ -81,5 +81,5 @@ func NewUnlockConnectorConfirmation(status UnlockStatus) *UnlockConnectorConfirm
 }
 
 func init() {
-	_ = types.Validate.RegisterValidation("unlockStatus16", isValidUnlockStatus)
+	_ = types.RegisterValidation("unlockStatus16", isValidUnlockStatus)
 }
@@ -0,0 +1,124 @@
+// The datatransfer package contains typed helpers for sending vendor-specific DataTransfer messages.
+//
+// OCPP 1.6 DataTransfer messages carry an arbitrary data field, which is usually a JSON object defined by a vendor.
+// The helpers in this package take care of marshaling a typed payload into the data field of the request,
+// and of unmarshaling the data field of the confirmation into a typed response.
+//
+// Example usage from a central system:
+//
+//	status, resp, err := datatransfer.Send[MyRequest, MyResponse](centralSystem, "cp0001", "myVendor", "myMessage", MyRequest{...})
+//
+// Example usage from a charge point:
+//
+//	status, resp, err := datatransfer.SendFromChargePoint[MyRequest, MyResponse](chargePoint, "myVendor", "myMessage", MyRequest{...})
+package datatransfer
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+)
+
+// CentralSystem is the subset of the ocpp16.CentralSystem interface required for sending DataTransfer requests to a charge point.
+type CentralSystem interface {
+	DataTransfer(clientId string, callback func(*core.DataTransferConfirmation, error), vendorId string, props ...func(*core.DataTransferRequest)) error
+}
+
+// ChargePoint is the subset of the ocpp16.ChargePoint interface required for sending DataTransfer requests to a central system.
+type ChargePoint interface {
+	DataTransfer(vendorId string, props ...func(request *core.DataTransferRequest)) (*core.DataTransferConfirmation, error)
+}
+
+// StatusError is returned whenever the remote endpoint replied to a DataTransfer request with a status other than Accepted.
+type StatusError struct {
+	VendorId  string
+	MessageId string
+	Status    core.DataTransferStatus
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("data transfer %v/%v not accepted: %v", e.VendorId, e.MessageId, e.Status)
+}
+
+// Send marshals the payload into the data field of a DataTransferRequest and sends it to the charge point identified by clientID.
+// The function blocks until a confirmation is received, or until the request fails.
+//
+// If the charge point replies with an Accepted status, the data field of the confirmation is unmarshaled into a TResp.
+// A confirmation with no data field yields a nil response and no error.
+// If the charge point replies with any other status, the status is returned together with a *StatusError.
+//
+// Additional request fields may be set via the optional opts functions, which are applied after the payload was set.
+func Send[TReq, TResp any](cs CentralSystem, clientID, vendorID, messageID string, payload TReq, opts ...func(*core.DataTransferRequest)) (core.DataTransferStatus, *TResp, error) {
+	props, err := requestProps(messageID, payload, opts)
+	if err != nil {
+		return "", nil, err
+	}
+	type result struct {
+		confirmation *core.DataTransferConfirmation
+		err          error
+	}
+	resultC := make(chan result, 1)
+	err = cs.DataTransfer(clientID, func(confirmation *core.DataTransferConfirmation, err error) {
+		resultC <- result{confirmation: confirmation, err: err}
+	}, vendorID, props...)
+	if err != nil {
+		return "", nil, err
+	}
+	res := <-resultC
+	if res.err != nil {
+		return "", nil, res.err
+	}
+	return parseConfirmation[TResp](vendorID, messageID, res.confirmation)
+}
+
+// SendFromChargePoint is the charge point-initiated counterpart of Send.
+// The payload is marshaled into the data field of a DataTransferRequest, which is then sent to the central system.
+//
+// The same rules as for Send apply to the returned status, response and error.
+func SendFromChargePoint[TReq, TResp any](cp ChargePoint, vendorID, messageID string, payload TReq, opts ...func(*core.DataTransferRequest)) (core.DataTransferStatus, *TResp, error) {
+	props, err := requestProps(messageID, payload, opts)
+	if err != nil {
+		return "", nil, err
+	}
+	confirmation, err := cp.DataTransfer(vendorID, props...)
+	if err != nil {
+		return "", nil, err
+	}
+	return parseConfirmation[TResp](vendorID, messageID, confirmation)
+}
+
+func requestProps[TReq any](messageID string, payload TReq, opts []func(*core.DataTransferRequest)) ([]func(*core.DataTransferRequest), error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't marshal data transfer payload: %w", err)
+	}
+	props := make([]func(*core.DataTransferRequest), 0, len(opts)+1)
+	props = append(props, func(request *core.DataTransferRequest) {
+		request.MessageId = messageID
+		request.Data = json.RawMessage(data)
+	})
+	return append(props, opts...), nil
+}
+
+func parseConfirmation[TResp any](vendorID, messageID string, confirmation *core.DataTransferConfirmation) (core.DataTransferStatus, *TResp, error) {
+	if confirmation == nil {
+		return "", nil, fmt.Errorf("empty data transfer confirmation for %v/%v", vendorID, messageID)
+	}
+	if confirmation.Status != core.DataTransferStatusAccepted {
+		return confirmation.Status, nil, &StatusError{VendorId: vendorID, MessageId: messageID, Status: confirmation.Status}
+	}
+	if confirmation.Data == nil {
+		return confirmation.Status, nil, nil
+	}
+	// Data was decoded generically, so it needs to be re-encoded before parsing it into the typed response
+	data, err := json.Marshal(confirmation.Data)
+	if err != nil {
+		return confirmation.Status, nil, fmt.Errorf("couldn't marshal data transfer response data: %w", err)
+	}
+	var resp TResp
+	if err = json.Unmarshal(data, &resp); err != nil {
+		return confirmation.Status, nil, fmt.Errorf("couldn't unmarshal data transfer response data: %w", err)
+	}
+	return confirmation.Status, &resp, nil
+}
@@ -0,0 +1,175 @@
+package ocpp16
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+)
+
+const (
+	// The default duration, for which the response to a StartTransaction or StopTransaction request is retained.
+	DefaultDeduplicationWindow = 15 * time.Minute
+	// The default maximum amount of retained responses.
+	DefaultDeduplicationMaxEntries = 10000
+)
+
+// TransactionDeduplication configures the detection of repeated StartTransaction and StopTransaction requests.
+// Refer to CentralSystem.SetTransactionDeduplication.
+type TransactionDeduplication struct {
+	// The duration, for which a response is retained after the request was handled. Defaults to DefaultDeduplicationWindow.
+	Window time.Duration
+	// The maximum amount of retained responses. Once reached, the oldest response is discarded.
+	// Defaults to DefaultDeduplicationMaxEntries.
+	MaxEntries int
+	// Identifies identical StartTransaction requests. Defaults to DefaultStartTransactionKey.
+	// Returning an empty key disables deduplication for the request.
+	StartTransactionKey func(chargePointID string, request *core.StartTransactionRequest) string
+	// Identifies identical StopTransaction requests. Defaults to DefaultStopTransactionKey.
+	// Returning an empty key disables deduplication for the request.
+	StopTransactionKey func(chargePointID string, request *core.StopTransactionRequest) string
+}
+
+// DefaultStartTransactionKey identifies a StartTransaction request by the charge point, connector, idTag, meterStart and timestamp,
+// which the OCPP 1.6 specification lists for detecting repeated requests.
+func DefaultStartTransactionKey(chargePointID string, request *core.StartTransactionRequest) string {
+	return fmt.Sprintf("%v|%v|%v|%v|%v", chargePointID, request.ConnectorId, request.IdTag, request.MeterStart, formatKeyTimestamp(request.Timestamp))
+}
+
+// DefaultStopTransactionKey identifies a StopTransaction request by the charge point, transactionId, meterStop and timestamp.
+func DefaultStopTransactionKey(chargePointID string, request *core.StopTransactionRequest) string {
+	return fmt.Sprintf("%v|%v|%v|%v", chargePointID, request.TransactionId, request.MeterStop, formatKeyTimestamp(request.Timestamp))
+}
+
+func formatKeyTimestamp(timestamp *types.DateTime) string {
+	if timestamp == nil {
+		return ""
+	}
+	return timestamp.FormatTimestamp()
+}
+
+type deduplicationEntry struct {
+	key      string
+	response ocpp.Response
+	expires  time.Time
+}
+
+// Retains the responses to StartTransaction and StopTransaction requests, so that repeated requests
+// are answered without invoking the handler again.
+//
+// Entries are kept in insertion order. Since all entries share the same window, this is also the order of expiry.
+type transactionDeduplicator struct {
+	config  TransactionDeduplication
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+func newTransactionDeduplicator(config TransactionDeduplication) *transactionDeduplicator {
+	if config.Window <= 0 {
+		config.Window = DefaultDeduplicationWindow
+	}
+	if config.MaxEntries <= 0 {
+		config.MaxEntries = DefaultDeduplicationMaxEntries
+	}
+	if config.StartTransactionKey == nil {
+		config.StartTransactionKey = DefaultStartTransactionKey
+	}
+	if config.StopTransactionKey == nil {
+		config.StopTransactionKey = DefaultStopTransactionKey
+	}
+	return &transactionDeduplicator{
+		config:  config,
+		entries: map[string]*list.Element{},
+		order:   list.New(),
+	}
+}
+
+// Returns the retained response for the key, if it didn't expire yet.
+func (d *transactionDeduplicator) get(key string, now time.Time) (ocpp.Response, bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.expire(now)
+	if element, ok := d.entries[key]; ok {
+		return element.Value.(*deduplicationEntry).response, true
+	}
+	return nil, false
+}
+
+// Retains the response for the key, discarding the oldest response if the maximum amount of entries is reached.
+func (d *transactionDeduplicator) put(key string, response ocpp.Response, now time.Time) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.expire(now)
+	if element, ok := d.entries[key]; ok {
+		d.order.Remove(element)
+	}
+	for d.order.Len() >= d.config.MaxEntries {
+		oldest := d.order.Front()
+		d.order.Remove(oldest)
+		delete(d.entries, oldest.Value.(*deduplicationEntry).key)
+	}
+	d.entries[key] = d.order.PushBack(&deduplicationEntry{key: key, response: response, expires: now.Add(d.config.Window)})
+}
+
+func (d *transactionDeduplicator) expire(now time.Time) {
+	for element := d.order.Front(); element != nil; element = d.order.Front() {
+		entry := element.Value.(*deduplicationEntry)
+		if now.Before(entry.expires) {
+			return
+		}
+		d.order.Remove(element)
+		delete(d.entries, entry.key)
+	}
+}
+
+func (cs *centralSystem) SetTransactionDeduplication(config *TransactionDeduplication) {
+	var deduplicator *transactionDeduplicator
+	if config != nil {
+		deduplicator = newTransactionDeduplicator(*config)
+	}
+	cs.deduplicatorMutex.Lock()
+	defer cs.deduplicatorMutex.Unlock()
+	cs.deduplicator = deduplicator
+}
+
+func (cs *centralSystem) getDeduplicator() *transactionDeduplicator {
+	cs.deduplicatorMutex.RLock()
+	defer cs.deduplicatorMutex.RUnlock()
+	return cs.deduplicator
+}
+
+// Invokes the handler for a StartTransaction or StopTransaction request, unless the response to an identical request
+// is retained. Responses are only retained, if the handler didn't return an error.
+func (cs *centralSystem) handleTransactionRequest(chargePointID string, request ocpp.Request, handler func() (ocpp.Response, error)) (ocpp.Response, error) {
+	deduplicator := cs.getDeduplicator()
+	if deduplicator == nil {
+		return handler()
+	}
+	var key string
+	switch req := request.(type) {
+	case *core.StartTransactionRequest:
+		if key = deduplicator.config.StartTransactionKey(chargePointID, req); key != "" {
+			key = core.StartTransactionFeatureName + "|" + key
+		}
+	case *core.StopTransactionRequest:
+		if key = deduplicator.config.StopTransactionKey(chargePointID, req); key != "" {
+			key = core.StopTransactionFeatureName + "|" + key
+		}
+	}
+	if key == "" {
+		return handler()
+	}
+	if response, ok := deduplicator.get(key, cs.server.Clock().Now()); ok {
+		return response, nil
+	}
+	response, err := handler()
+	if err == nil {
+		deduplicator.put(key, response, cs.server.Clock().Now())
+	}
+	return response, err
+}
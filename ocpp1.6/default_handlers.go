@@ -0,0 +1,65 @@
+package ocpp16
+
+import (
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/firmware"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+)
+
+// DefaultHandler identifies a request, which the central system may answer on its own. Values may be combined.
+// Refer to CentralSystem.UseDefaultHandlers.
+type DefaultHandler int
+
+const (
+	DefaultHeartbeat                     DefaultHandler = 1 << iota // Heartbeat, answered with the current time.
+	DefaultStatusNotification                                       // StatusNotification, answered with an empty confirmation.
+	DefaultDiagnosticsStatusNotification                            // DiagnosticsStatusNotification, answered with an empty confirmation.
+	DefaultFirmwareStatusNotification                               // FirmwareStatusNotification, answered with an empty confirmation.
+)
+
+// RequestObserver receives the requests answered by default handlers. It is implemented by statetracker.StateTracker.
+type RequestObserver interface {
+	ObserveRequest(chargePointID string, request ocpp.Request)
+}
+
+var defaultHandlerActions = map[string]DefaultHandler{
+	core.HeartbeatFeatureName:                         DefaultHeartbeat,
+	core.StatusNotificationFeatureName:                DefaultStatusNotification,
+	firmware.DiagnosticsStatusNotificationFeatureName: DefaultDiagnosticsStatusNotification,
+	firmware.FirmwareStatusNotificationFeatureName:    DefaultFirmwareStatusNotification,
+}
+
+func (cs *centralSystem) UseDefaultHandlers(handlers DefaultHandler) {
+	cs.defaultHandlers = handlers
+}
+
+func (cs *centralSystem) SetDefaultHandlerObserver(observer RequestObserver) {
+	cs.defaultObserver = observer
+}
+
+// Returns the response of a default handler, if one is enabled for the action and no handler was set for its profile.
+func (cs *centralSystem) defaultResponse(action string) (ocpp.Response, bool) {
+	if cs.defaultHandlers&defaultHandlerActions[action] == 0 {
+		return nil, false
+	}
+	switch action {
+	case core.HeartbeatFeatureName:
+		if cs.coreHandler == nil {
+			return core.NewHeartbeatConfirmation(types.NewDateTime(cs.server.Clock().Now())), true
+		}
+	case core.StatusNotificationFeatureName:
+		if cs.coreHandler == nil {
+			return core.NewStatusNotificationConfirmation(), true
+		}
+	case firmware.DiagnosticsStatusNotificationFeatureName:
+		if cs.firmwareHandler == nil {
+			return firmware.NewDiagnosticsStatusNotificationConfirmation(), true
+		}
+	case firmware.FirmwareStatusNotificationFeatureName:
+		if cs.firmwareHandler == nil {
+			return firmware.NewFirmwareStatusNotificationConfirmation(), true
+		}
+	}
+	return nil, false
+}
@@ -75,5 +75,5 @@ func NewDiagnosticsStatusNotificationConfirmation() *DiagnosticsStatusNotificati
 }
 
 func init() {
-	_ = types.Validate.RegisterValidation("diagnosticsStatus", isValidDiagnosticsStatus)
+	_ = types.RegisterValidation("diagnosticsStatus", isValidDiagnosticsStatus)
 }
@@ -0,0 +1,219 @@
+package firmware
+
+import (
+	"context"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/clock"
+	"github.com/lorenzodonini/ocpp-go/internal/retry"
+)
+
+// DiagnosticsCollector collects the diagnostics information, limited to the entries between the start and stop time.
+// Either bound may be nil, meaning that the diagnostics are not limited in that direction.
+//
+// An empty filename indicates that no diagnostics information is available.
+type DiagnosticsCollector func(startTime *time.Time, stopTime *time.Time) (filename string, content []byte, err error)
+
+// DiagnosticsUploader uploads a diagnostics file to a remote location. The upload must be aborted once ctx is canceled.
+// Failed uploads are retried, as requested by the central system.
+//
+// The HTTPUploader and FTPUploader of the OCPP 2.0.1 diagnostics package implement this interface.
+type DiagnosticsUploader interface {
+	Upload(ctx context.Context, location *url.URL, filename string, content []byte) error
+}
+
+type diagnosticsUpload struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// DiagnosticsUploadManager implements the diagnostics upload flow on the charge point side.
+//
+// OnGetDiagnostics matches the respective ChargePointHandler method, so a firmware management handler may delegate to it directly.
+// The requested diagnostics are collected synchronously, then uploaded in the background to the remote location,
+// using the uploader registered for its URL scheme. Progress is reported via DiagnosticsStatusNotification messages:
+// Uploading, followed by Uploaded or UploadFailed.
+//
+// ReportStatus sends the current status on demand, e.g. when the central system triggers a DiagnosticsStatusNotification
+// via TriggerMessage: Uploading while an upload is ongoing, Idle otherwise (also if no upload was ever requested).
+// The remotetrigger.AutoResponder16 wires this in via UseDiagnosticsUploadManager.
+//
+// Notifications are sent in order. Automatic notifications repeating the last reported status are suppressed,
+// e.g. when a new request supersedes an ongoing upload. Triggered notifications are always sent, since the central system explicitly asked for them.
+//
+// Only one upload is performed at a time: a new GetDiagnosticsRequest cancels the ongoing upload,
+// in which case no further status is reported for the canceled upload.
+//
+// A DiagnosticsUploadManager is safe for concurrent use.
+type DiagnosticsUploadManager struct {
+	// Collects the diagnostics. If the collector fails, no file name is returned and no upload is performed.
+	Collect DiagnosticsCollector
+	// Sends a DiagnosticsStatusNotificationRequest to the central system. Typically invokes DiagnosticsStatusNotification on the charge point.
+	SendStatus func(request *DiagnosticsStatusNotificationRequest) error
+	// Invoked if a status notification couldn't be sent. Optional.
+	OnSendError func(status DiagnosticsStatus, err error)
+	// Invoked whenever the reported status changes, e.g. for updating the UI of the charge point. Optional.
+	OnStatusChange func(status DiagnosticsStatus)
+	// The uploaders per URL scheme. Requests for locations with an unsupported scheme are not uploaded.
+	Uploaders map[string]DiagnosticsUploader
+	status    DiagnosticsStatus
+	current   *diagnosticsUpload
+	clock     clock.Clock
+	mutex     sync.Mutex
+	sendMutex sync.Mutex
+}
+
+// NewDiagnosticsUploadManager creates a new manager without any uploaders.
+func NewDiagnosticsUploadManager(collect DiagnosticsCollector, sendStatus func(request *DiagnosticsStatusNotificationRequest) error) *DiagnosticsUploadManager {
+	return &DiagnosticsUploadManager{
+		Collect:    collect,
+		SendStatus: sendStatus,
+		Uploaders:  map[string]DiagnosticsUploader{},
+		status:     DiagnosticsStatusIdle,
+		clock:      clock.New(),
+	}
+}
+
+// SetClock sets the clock used for waiting between upload attempts. Passing nil restores the real clock.
+// Uploads, which are already ongoing, are not affected.
+func (m *DiagnosticsUploadManager) SetClock(c clock.Clock) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.clock = clock.OrDefault(c)
+}
+
+// CurrentDiagnosticsStatus returns the last status reported to the central system. If no upload was requested yet, Idle is returned.
+func (m *DiagnosticsUploadManager) CurrentDiagnosticsStatus() DiagnosticsStatus {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.status == "" {
+		return DiagnosticsStatusIdle
+	}
+	return m.status
+}
+
+// ReportStatus sends the current status to the central system: Uploading while an upload is ongoing, Idle otherwise.
+// To be invoked after accepting a TriggerMessageRequest for DiagnosticsStatusNotification.
+func (m *DiagnosticsUploadManager) ReportStatus() error {
+	m.sendMutex.Lock()
+	defer m.sendMutex.Unlock()
+	m.mutex.Lock()
+	status := DiagnosticsStatusIdle
+	if m.current != nil {
+		select {
+		case <-m.current.done:
+		default:
+			status = DiagnosticsStatusUploading
+		}
+	}
+	changed := m.updateStatus(status)
+	m.mutex.Unlock()
+	return m.notify(status, changed)
+}
+
+func (m *DiagnosticsUploadManager) OnGetDiagnostics(request *GetDiagnosticsRequest) (*GetDiagnosticsConfirmation, error) {
+	confirmation := NewGetDiagnosticsConfirmation()
+	if m.Collect == nil {
+		return confirmation, nil
+	}
+	location, err := url.Parse(request.Location)
+	if err != nil {
+		return confirmation, nil
+	}
+	uploader, ok := m.Uploaders[location.Scheme]
+	if !ok {
+		return confirmation, nil
+	}
+	var startTime, stopTime *time.Time
+	if request.StartTime != nil {
+		startTime = &request.StartTime.Time
+	}
+	if request.StopTime != nil {
+		stopTime = &request.StopTime.Time
+	}
+	filename, content, err := m.Collect(startTime, stopTime)
+	if err != nil || filename == "" {
+		return confirmation, nil
+	}
+	confirmation.FileName = filename
+	m.mutex.Lock()
+	previous := m.current
+	if previous != nil {
+		previous.cancel()
+	}
+	clk := clock.OrDefault(m.clock)
+	ctx, cancel := context.WithCancel(context.Background())
+	upload := &diagnosticsUpload{cancel: cancel, done: make(chan struct{})}
+	m.current = upload
+	m.mutex.Unlock()
+	go func() {
+		if previous != nil {
+			<-previous.done
+		}
+		m.upload(ctx, clk, upload, uploader, location, request, filename, content)
+	}()
+	return confirmation, nil
+}
+
+func (m *DiagnosticsUploadManager) upload(ctx context.Context, clk clock.Clock, upload *diagnosticsUpload, uploader DiagnosticsUploader, location *url.URL, request *GetDiagnosticsRequest, filename string, content []byte) {
+	defer close(upload.done)
+	defer upload.cancel()
+	if !m.setStatus(ctx, DiagnosticsStatusUploading) {
+		return
+	}
+	status := DiagnosticsStatusUploadFailed
+	completed := retry.FromRequest(request.Retries, request.RetryInterval, clk).Run(ctx, func(n int) bool {
+		if err := uploader.Upload(ctx, location, filename, content); err != nil {
+			return false
+		}
+		status = DiagnosticsStatusUploaded
+		return true
+	})
+	if !completed {
+		return
+	}
+	m.setStatus(ctx, status)
+}
+
+// Updates the status of an upload and notifies the central system, unless the upload was canceled
+// or the status was already reported.
+func (m *DiagnosticsUploadManager) setStatus(ctx context.Context, status DiagnosticsStatus) bool {
+	m.sendMutex.Lock()
+	defer m.sendMutex.Unlock()
+	m.mutex.Lock()
+	if ctx.Err() != nil {
+		m.mutex.Unlock()
+		return false
+	}
+	changed := m.updateStatus(status)
+	m.mutex.Unlock()
+	if !changed {
+		return true
+	}
+	if err := m.notify(status, changed); err != nil && m.OnSendError != nil {
+		m.OnSendError(status, err)
+	}
+	return true
+}
+
+// Records the reported status and returns true if it changed. Must be invoked while holding the mutex.
+func (m *DiagnosticsUploadManager) updateStatus(status DiagnosticsStatus) bool {
+	if m.status == status {
+		return false
+	}
+	m.status = status
+	return true
+}
+
+// Invokes the status change callback, if needed, then sends the status. Must be invoked while holding the send mutex.
+func (m *DiagnosticsUploadManager) notify(status DiagnosticsStatus, changed bool) error {
+	if changed && m.OnStatusChange != nil {
+		m.OnStatusChange(status)
+	}
+	if m.SendStatus == nil {
+		return nil
+	}
+	return m.SendStatus(NewDiagnosticsStatusNotificationRequest(status))
+}
@@ -79,5 +79,5 @@ func NewFirmwareStatusNotificationConfirmation() *FirmwareStatusNotificationConf
 }
 
 func init() {
-	_ = types.Validate.RegisterValidation("firmwareStatus16", isValidFirmwareStatus)
+	_ = types.RegisterValidation("firmwareStatus16", isValidFirmwareStatus)
 }
@@ -0,0 +1,105 @@
+package firmware
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Legal firmware status transitions, as per the OCPP 1.6 firmware update state diagram.
+//
+// Non-terminal states may be repeated, since a charge point reports its current status when triggered via TriggerMessage.
+// Idle is reported by a charge point, which isn't busy with a firmware update, and is therefore legal after any terminal state.
+// A failed download may be retried by the charge point.
+var legalFirmwareTransitions = map[FirmwareStatus][]FirmwareStatus{
+	FirmwareStatusIdle:               {FirmwareStatusIdle, FirmwareStatusDownloading},
+	FirmwareStatusDownloading:        {FirmwareStatusDownloading, FirmwareStatusDownloaded, FirmwareStatusDownloadFailed},
+	FirmwareStatusDownloaded:         {FirmwareStatusDownloaded, FirmwareStatusInstalling},
+	FirmwareStatusDownloadFailed:     {FirmwareStatusIdle, FirmwareStatusDownloading},
+	FirmwareStatusInstalling:         {FirmwareStatusInstalling, FirmwareStatusInstalled, FirmwareStatusInstallationFailed},
+	FirmwareStatusInstalled:          {FirmwareStatusIdle},
+	FirmwareStatusInstallationFailed: {FirmwareStatusIdle},
+}
+
+// IsLegalFirmwareTransition returns true if a charge point may report the status to, after having reported the status from.
+func IsLegalFirmwareTransition(from FirmwareStatus, to FirmwareStatus) bool {
+	for _, status := range legalFirmwareTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IllegalTransitionWarning is returned by a StatusSequenceValidator, when a charge point reports a firmware status,
+// which is not a legal successor of the previously reported status.
+type IllegalTransitionWarning struct {
+	ChargePointId string
+	From          FirmwareStatus
+	To            FirmwareStatus
+}
+
+func (w *IllegalTransitionWarning) Error() string {
+	return fmt.Sprintf("illegal firmware status transition for charge point %v: %v -> %v", w.ChargePointId, w.From, w.To)
+}
+
+// StatusSequenceValidator keeps track of the firmware status reported by each charge point,
+// and flags illegal transitions (e.g. Installed before Downloaded, or a repeated terminal state).
+//
+// The validator is meant to be used on the central system side: each received FirmwareStatusNotificationRequest
+// should be passed to Validate, while OnUpdateFirmware should be invoked whenever an UpdateFirmwareRequest is sent.
+// The validator never rejects a status: the reported status is always recorded as the current one.
+//
+// A StatusSequenceValidator is safe for concurrent use.
+type StatusSequenceValidator struct {
+	statuses map[string]FirmwareStatus
+	mutex    sync.Mutex
+}
+
+// NewStatusSequenceValidator creates a new validator. Charge points are initially considered Idle.
+func NewStatusSequenceValidator() *StatusSequenceValidator {
+	return &StatusSequenceValidator{statuses: map[string]FirmwareStatus{}}
+}
+
+// OnUpdateFirmware resets the state of a charge point to Idle. To be invoked when an UpdateFirmwareRequest is sent.
+func (v *StatusSequenceValidator) OnUpdateFirmware(chargePointId string) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.statuses[chargePointId] = FirmwareStatusIdle
+}
+
+// Status returns the last firmware status reported by a charge point.
+func (v *StatusSequenceValidator) Status(chargePointId string) FirmwareStatus {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	status, ok := v.statuses[chargePointId]
+	if !ok {
+		return FirmwareStatusIdle
+	}
+	return status
+}
+
+// Validate records the status reported by a charge point and checks whether the transition from the previous status is legal.
+// Returns nil for legal transitions, or an IllegalTransitionWarning otherwise.
+func (v *StatusSequenceValidator) Validate(chargePointId string, request *FirmwareStatusNotificationRequest) *IllegalTransitionWarning {
+	if request == nil {
+		return nil
+	}
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	from, ok := v.statuses[chargePointId]
+	if !ok {
+		from = FirmwareStatusIdle
+	}
+	v.statuses[chargePointId] = request.Status
+	if IsLegalFirmwareTransition(from, request.Status) {
+		return nil
+	}
+	return &IllegalTransitionWarning{ChargePointId: chargePointId, From: from, To: request.Status}
+}
+
+// Remove discards the state of a charge point.
+func (v *StatusSequenceValidator) Remove(chargePointId string) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	delete(v.statuses, chargePointId)
+}
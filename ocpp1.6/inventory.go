@@ -0,0 +1,90 @@
+package ocpp16
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+)
+
+// The configuration key, from which the capabilities of charge points are read.
+const supportedFeatureProfilesKey = "SupportedFeatureProfiles"
+
+// The configuration key, from which the maximum length of SendLocalList requests is read.
+const sendLocalListMaxLengthKey = "SendLocalListMaxLength"
+
+type reportedProfiles struct {
+	profiles   []string
+	reportedAt time.Time
+}
+
+// Keeps the feature profiles reported by charge points in GetConfiguration confirmations,
+// as well as the SendLocalListMaxLength, if reported.
+// Entries are retained after a charge point disconnects, so they may be marked as stale once it reconnects.
+type featureProfileStore struct {
+	entries        map[string]reportedProfiles
+	maxListLengths map[string]int
+	mutex          sync.RWMutex
+}
+
+func newFeatureProfileStore() *featureProfileStore {
+	return &featureProfileStore{entries: map[string]reportedProfiles{}, maxListLengths: map[string]int{}}
+}
+
+// Records the SupportedFeatureProfiles and SendLocalListMaxLength keys, if contained in the confirmation. Other keys are ignored.
+func (s *featureProfileStore) observe(chargePointID string, confirmation *core.GetConfigurationConfirmation, receivedAt time.Time) {
+	for _, key := range confirmation.ConfigurationKey {
+		if key.Value == nil {
+			continue
+		}
+		switch {
+		case strings.EqualFold(key.Key, supportedFeatureProfilesKey):
+			profiles := []string{}
+			for _, profile := range strings.Split(*key.Value, ",") {
+				if profile = strings.TrimSpace(profile); profile != "" {
+					profiles = append(profiles, profile)
+				}
+			}
+			s.mutex.Lock()
+			s.entries[chargePointID] = reportedProfiles{profiles: profiles, reportedAt: receivedAt}
+			s.mutex.Unlock()
+		case strings.EqualFold(key.Key, sendLocalListMaxLengthKey):
+			maxLength, err := strconv.Atoi(strings.TrimSpace(*key.Value))
+			if err != nil || maxLength <= 0 {
+				continue
+			}
+			s.mutex.Lock()
+			s.maxListLengths[chargePointID] = maxLength
+			s.mutex.Unlock()
+		}
+	}
+}
+
+// Returns the SendLocalListMaxLength reported by a charge point.
+func (s *featureProfileStore) maxListLength(chargePointID string) (int, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	maxLength, ok := s.maxListLengths[chargePointID]
+	return maxLength, ok
+}
+
+func (s *featureProfileStore) get(chargePointID string) (reportedProfiles, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	entry, ok := s.entries[chargePointID]
+	return entry, ok
+}
+
+func (cs *centralSystem) Inventory() []ocppj.EndpointInfo {
+	inventory := cs.server.Inventory()
+	for i := range inventory {
+		info := &inventory[i]
+		if entry, ok := cs.featureProfiles.get(info.ID); ok {
+			info.Capabilities = ocppj.NewCapabilities(supportedFeatureProfilesKey, entry.profiles, entry.reportedAt, info.ConnectedAt)
+		}
+	}
+	return inventory
+}
@@ -0,0 +1,53 @@
+package ocpp16
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/remotetrigger"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+)
+
+const heartbeatIntervalKey = "HeartbeatInterval"
+
+func (cs *centralSystem) EnableLivenessMonitor(config ocppj.LivenessConfig) {
+	if _, ok := cs.server.GetProfile(remotetrigger.ProfileName); ok {
+		cs.server.SetLivenessProbe(cs.probeLiveness)
+	}
+	cs.server.EnableLivenessMonitor(config)
+}
+
+func (cs *centralSystem) SetLivenessHandler(handler func(chargePointID string, liveness ocppj.Liveness)) {
+	cs.server.SetLivenessHandler(handler)
+}
+
+func (cs *centralSystem) Liveness(chargePointID string) (ocppj.Liveness, bool) {
+	return cs.server.Liveness(chargePointID)
+}
+
+// Triggers a heartbeat. Both the TriggerMessage confirmation and the heartbeat itself prove that the charge point is alive.
+func (cs *centralSystem) probeLiveness(chargePointID string) error {
+	return cs.TriggerMessage(chargePointID, func(*remotetrigger.TriggerMessageConfirmation, error) {}, remotetrigger.MessageTrigger(core.HeartbeatFeatureName))
+}
+
+// Updates the heartbeat interval known to the liveness monitor, from a BootNotification or GetConfiguration confirmation.
+func (cs *centralSystem) observeHeartbeatInterval(chargePointID string, confirmation ocpp.Response) {
+	switch c := confirmation.(type) {
+	case *core.BootNotificationConfirmation:
+		cs.server.SetHeartbeatInterval(chargePointID, time.Duration(c.Interval)*time.Second)
+	case *core.GetConfigurationConfirmation:
+		for _, key := range c.ConfigurationKey {
+			if key.Key == heartbeatIntervalKey && key.Value != nil {
+				cs.setHeartbeatInterval(chargePointID, *key.Value)
+			}
+		}
+	}
+}
+
+func (cs *centralSystem) setHeartbeatInterval(chargePointID string, value string) {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		cs.server.SetHeartbeatInterval(chargePointID, time.Duration(seconds)*time.Second)
+	}
+}
@@ -1,9 +1,12 @@
 package localauth
 
 import (
+	"fmt"
+	"reflect"
+	"strings"
+
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
 	"gopkg.in/go-playground/validator.v9"
-	"reflect"
 )
 
 // -------------------- Send Local List (CS -> CP) --------------------
@@ -44,7 +47,7 @@ func isValidUpdateStatus(fl validator.FieldLevel) bool {
 
 type AuthorizationData struct {
 	IdTag     string           `json:"idTag" validate:"required,max=20"`
-	IdTagInfo *types.IdTagInfo `json:"idTagInfo,omitempty"` //TODO: validate required if update type is Full
+	IdTagInfo *types.IdTagInfo `json:"idTagInfo,omitempty"` // Required for Full updates. In Differential updates, entries without it are removed from the list.
 }
 
 // The field definition of the SendLocalList request payload sent by the Central System to the Charge Point.
@@ -52,8 +55,9 @@ type AuthorizationData struct {
 //
 // Requesting a Differential update without (empty) localAuthorizationList will have no effect on the list.
 // All idTags in the localAuthorizationList MUST be unique, no duplicate values are allowed.
+// For Full updates, every entry MUST contain an idTagInfo.
 type SendLocalListRequest struct {
-	ListVersion            int                 `json:"listVersion" validate:"gte=0"`
+	ListVersion            int                 `json:"listVersion" validate:"gt=0"`
 	LocalAuthorizationList []AuthorizationData `json:"localAuthorizationList,omitempty" validate:"omitempty,dive"`
 	UpdateType             UpdateType          `json:"updateType" validate:"required,updateType16"`
 }
@@ -104,8 +108,63 @@ func NewSendLocalListConfirmation(status UpdateStatus) *SendLocalListConfirmatio
 	return &SendLocalListConfirmation{Status: status}
 }
 
+// MaxLengthError is returned when a SendLocalListRequest contains more entries than the SendLocalListMaxLength
+// reported by the charge point. Refer to SplitSendLocalList for sending large lists.
+type MaxLengthError struct {
+	Length    int
+	MaxLength int
+}
+
+func (e *MaxLengthError) Error() string {
+	return fmt.Sprintf("local authorization list contains %d entries, charge point accepts at most %d", e.Length, e.MaxLength)
+}
+
+// SplitSendLocalList splits a request into batches of at most maxLength entries each.
+// The first batch keeps the update type of the request, all following ones are Differential updates adding the remaining entries.
+//
+// Since charge points only accept Differential updates with a list version greater than their current one,
+// the batches are numbered consecutively, starting at the list version of the request.
+// Batches must be sent in order, each one after the previous was accepted.
+//
+// If the request doesn't exceed maxLength, or maxLength isn't positive, the request is returned as the only batch.
+func SplitSendLocalList(request *SendLocalListRequest, maxLength int) []*SendLocalListRequest {
+	if maxLength <= 0 || len(request.LocalAuthorizationList) <= maxLength {
+		return []*SendLocalListRequest{request}
+	}
+	var batches []*SendLocalListRequest
+	updateType := request.UpdateType
+	for i := 0; i < len(request.LocalAuthorizationList); i += maxLength {
+		end := i + maxLength
+		if end > len(request.LocalAuthorizationList) {
+			end = len(request.LocalAuthorizationList)
+		}
+		batch := NewSendLocalListRequest(request.ListVersion+len(batches), updateType)
+		batch.LocalAuthorizationList = request.LocalAuthorizationList[i:end]
+		batches = append(batches, batch)
+		updateType = UpdateTypeDifferential
+	}
+	return batches
+}
+
+// Reports duplicate idTags (which are case-insensitive) and, for Full updates, entries without idTagInfo.
+func isValidSendLocalListRequest(sl validator.StructLevel) {
+	request := sl.Current().Interface().(SendLocalListRequest)
+	seen := make(map[string]bool, len(request.LocalAuthorizationList))
+	for i, data := range request.LocalAuthorizationList {
+		field := fmt.Sprintf("LocalAuthorizationList[%d]", i)
+		idTag := strings.ToLower(data.IdTag)
+		if seen[idTag] {
+			sl.ReportError(data.IdTag, field+".IdTag", "idTag", "unique", "")
+		}
+		seen[idTag] = true
+		if request.UpdateType == UpdateTypeFull && data.IdTagInfo == nil {
+			sl.ReportError(data.IdTagInfo, field+".IdTagInfo", "idTagInfo", "required", "")
+		}
+	}
+}
+
 func init() {
-	_ = types.Validate.RegisterValidation("updateStatus", isValidUpdateStatus)
-	_ = types.Validate.RegisterValidation("updateType16", isValidUpdateType)
-	//TODO: validation for SendLocalListMaxLength
+	_ = types.RegisterValidation("updateStatus", isValidUpdateStatus)
+	_ = types.RegisterValidation("updateType16", isValidUpdateType)
+	types.RegisterStructValidation(isValidSendLocalListRequest, SendLocalListRequest{})
 }
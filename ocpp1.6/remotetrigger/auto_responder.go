@@ -0,0 +1,144 @@
+package remotetrigger
+
+import (
+	"sync"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/firmware"
+)
+
+// TriggerProducer sends the message requested by a TriggerMessageRequest to the central system.
+// The connectorId is only passed for triggers referring to a connector (MeterValues and StatusNotification),
+// and is nil if the central system didn't request a specific connector.
+type TriggerProducer func(connectorId *int) error
+
+// TriggerCondition reports whether the message requested by a TriggerMessageRequest can currently be sent,
+// e.g. whether the subsystem producing it is operational. The connectorId is passed as for a TriggerProducer.
+type TriggerCondition func(connectorId *int) bool
+
+// AutoResponder16 answers TriggerMessageRequest messages on the charge point side.
+//
+// The application registers a TriggerProducer per supported trigger (see Register). OnTriggerMessage matches the
+// respective ChargePointHandler method, so a remote trigger handler may delegate to it directly.
+// A request is answered with:
+//
+// - NotImplemented, if the requested message is unknown, or neither a producer is registered for it nor is it declared
+// as supported (see Support)
+//
+// - Rejected, if the requested message is supported but can't be sent right now: no producer is registered yet,
+// the requested connector doesn't exist, or the condition set for the trigger doesn't hold (see SetCondition)
+//
+// - Accepted otherwise, in which case the producer is invoked in the background
+//
+// A DiagnosticsUploadManager may be wired in via UseDiagnosticsUploadManager, so triggered messages reflect its current state.
+//
+// An AutoResponder16 is safe for concurrent use.
+type AutoResponder16 struct {
+	// Invoked whenever a producer failed to send the triggered message. Optional.
+	OnSendError   func(trigger MessageTrigger, err error)
+	numConnectors int
+	producers     map[MessageTrigger]TriggerProducer
+	supported     map[MessageTrigger]bool
+	conditions    map[MessageTrigger]TriggerCondition
+	mutex         sync.Mutex
+}
+
+// NewAutoResponder16 creates a new responder for a charge point with the given amount of connectors.
+// If numConnectors is 0, requested connectors aren't validated.
+func NewAutoResponder16(numConnectors int) *AutoResponder16 {
+	return &AutoResponder16{
+		numConnectors: numConnectors,
+		producers:     map[MessageTrigger]TriggerProducer{},
+		supported:     map[MessageTrigger]bool{},
+		conditions:    map[MessageTrigger]TriggerCondition{},
+	}
+}
+
+// Register sets the producer for a trigger, replacing any previously registered one.
+func (r *AutoResponder16) Register(trigger MessageTrigger, producer TriggerProducer) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.producers[trigger] = producer
+}
+
+// Unregister removes the producer for a trigger. Further requests for it are answered with NotImplemented,
+// or with Rejected if the trigger was declared as supported.
+func (r *AutoResponder16) Unregister(trigger MessageTrigger) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.producers, trigger)
+}
+
+// Support declares triggers as implemented by the charge point, even while no producer is registered for them,
+// e.g. DiagnosticsStatusNotification while no diagnostics upload is configured. Requests for them are answered
+// with Rejected rather than NotImplemented, until a producer is registered.
+func (r *AutoResponder16) Support(triggers ...MessageTrigger) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, trigger := range triggers {
+		r.supported[trigger] = true
+	}
+}
+
+// SetCondition sets a condition, which is checked before accepting a request for a trigger. Requests are answered
+// with Rejected while the condition doesn't hold. Passing nil removes the condition.
+func (r *AutoResponder16) SetCondition(trigger MessageTrigger, condition TriggerCondition) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if condition == nil {
+		delete(r.conditions, trigger)
+		return
+	}
+	r.conditions[trigger] = condition
+}
+
+// UseDiagnosticsUploadManager registers a DiagnosticsStatusNotification producer, reporting the current status of a DiagnosticsUploadManager.
+// Uploading is reported while an upload is ongoing, Idle otherwise.
+func (r *AutoResponder16) UseDiagnosticsUploadManager(m *firmware.DiagnosticsUploadManager) {
+	r.Register(firmware.DiagnosticsStatusNotificationFeatureName, func(connectorId *int) error {
+		return m.ReportStatus()
+	})
+}
+
+// OnTriggerMessage answers a TriggerMessageRequest and, if accepted, sends the requested message in the background.
+func (r *AutoResponder16) OnTriggerMessage(request *TriggerMessageRequest) (*TriggerMessageConfirmation, error) {
+	trigger := request.RequestedMessage
+	status, producer, connectorId := r.decide(request)
+	if status != TriggerMessageStatusAccepted {
+		return NewTriggerMessageConfirmation(status), nil
+	}
+	go func() {
+		if err := producer(connectorId); err != nil && r.OnSendError != nil {
+			r.OnSendError(trigger, err)
+		}
+	}()
+	return NewTriggerMessageConfirmation(TriggerMessageStatusAccepted), nil
+}
+
+// Returns the status for a request and, if accepted, the producer to invoke with the respective connectorId.
+func (r *AutoResponder16) decide(request *TriggerMessageRequest) (TriggerMessageStatus, TriggerProducer, *int) {
+	trigger := request.RequestedMessage
+	r.mutex.Lock()
+	producer, ok := r.producers[trigger]
+	supported := r.supported[trigger]
+	condition := r.conditions[trigger]
+	numConnectors := r.numConnectors
+	r.mutex.Unlock()
+	if !isKnownTrigger(trigger) || (!ok && !supported) {
+		return TriggerMessageStatusNotImplemented, nil, nil
+	}
+	if !ok {
+		return TriggerMessageStatusRejected, nil, nil
+	}
+	// The connectorId is ignored for messages not referring to a connector
+	var connectorId *int
+	if IsConnectorTrigger(trigger) && request.ConnectorId != nil {
+		connectorId = request.ConnectorId
+		if *connectorId <= 0 || (numConnectors > 0 && *connectorId > numConnectors) {
+			return TriggerMessageStatusRejected, nil, nil
+		}
+	}
+	if condition != nil && !condition(connectorId) {
+		return TriggerMessageStatusRejected, nil, nil
+	}
+	return TriggerMessageStatusAccepted, producer, connectorId
+}
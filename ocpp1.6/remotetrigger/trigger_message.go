@@ -1,6 +1,7 @@
 package remotetrigger
 
 import (
+	"fmt"
 	"reflect"
 
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
@@ -36,7 +37,10 @@ func isValidTriggerMessageStatus(fl validator.FieldLevel) bool {
 }
 
 func isValidMessageTrigger(fl validator.FieldLevel) bool {
-	trigger := MessageTrigger(fl.Field().String())
+	return isKnownTrigger(MessageTrigger(fl.Field().String()))
+}
+
+func isKnownTrigger(trigger MessageTrigger) bool {
 	switch trigger {
 	case core.BootNotificationFeatureName, firmware.DiagnosticsStatusNotificationFeatureName, firmware.FirmwareStatusNotificationFeatureName, core.HeartbeatFeatureName, core.MeterValuesFeatureName, core.StatusNotificationFeatureName:
 		return true
@@ -48,7 +52,51 @@ func isValidMessageTrigger(fl validator.FieldLevel) bool {
 // The field definition of the TriggerMessage request payload sent by the Central System to the Charge Point.
 type TriggerMessageRequest struct {
 	RequestedMessage MessageTrigger `json:"requestedMessage" validate:"required,messageTrigger16"`
-	ConnectorId      *int           `json:"connectorId,omitempty" validate:"omitempty,gte=0"`
+	ConnectorId      *int           `json:"connectorId,omitempty" validate:"omitempty,gt=0"`
+}
+
+// ConnectorScopeError is returned when the connectorId of a TriggerMessageRequest doesn't apply to the requested message.
+type ConnectorScopeError struct {
+	RequestedMessage MessageTrigger
+	ConnectorId      int
+}
+
+func (e *ConnectorScopeError) Error() string {
+	if !IsConnectorTrigger(e.RequestedMessage) {
+		return fmt.Sprintf("connectorId %d doesn't apply to %v, only to %v and %v", e.ConnectorId, e.RequestedMessage, core.StatusNotificationFeatureName, core.MeterValuesFeatureName)
+	}
+	return fmt.Sprintf("invalid connectorId %d for %v, must be greater than 0", e.ConnectorId, e.RequestedMessage)
+}
+
+// IsConnectorTrigger returns whether a trigger refers to a connector, i.e. whether a connectorId may be passed along.
+// This is only the case for MeterValues and StatusNotification.
+func IsConnectorTrigger(trigger MessageTrigger) bool {
+	switch trigger {
+	case core.MeterValuesFeatureName, core.StatusNotificationFeatureName:
+		return true
+	default:
+		return false
+	}
+}
+
+// ValidateConnectorScope checks whether the connectorId of the request applies to the requested message.
+// A connectorId may only be set for MeterValues and StatusNotification, and must be greater than 0.
+// Omitting it requests the message for all connectors.
+func (r *TriggerMessageRequest) ValidateConnectorScope() error {
+	if r.ConnectorId == nil {
+		return nil
+	}
+	if !IsConnectorTrigger(r.RequestedMessage) || *r.ConnectorId <= 0 {
+		return &ConnectorScopeError{RequestedMessage: r.RequestedMessage, ConnectorId: *r.ConnectorId}
+	}
+	return nil
+}
+
+func isValidTriggerMessageRequest(sl validator.StructLevel) {
+	request := sl.Current().Interface().(TriggerMessageRequest)
+	if request.ConnectorId != nil && !IsConnectorTrigger(request.RequestedMessage) {
+		sl.ReportError(request.ConnectorId, "ConnectorId", "connectorId", "connectorTrigger", "")
+	}
 }
 
 // This field definition of the TriggerMessage confirmation payload, sent by the Charge Point to the Central System in response to a TriggerMessageRequest.
@@ -111,6 +159,7 @@ func NewTriggerMessageConfirmation(status TriggerMessageStatus) *TriggerMessageC
 }
 
 func init() {
-	_ = types.Validate.RegisterValidation("triggerMessageStatus16", isValidTriggerMessageStatus)
-	_ = types.Validate.RegisterValidation("messageTrigger16", isValidMessageTrigger)
+	_ = types.RegisterValidation("triggerMessageStatus16", isValidTriggerMessageStatus)
+	_ = types.RegisterValidation("messageTrigger16", isValidMessageTrigger)
+	types.RegisterStructValidation(isValidTriggerMessageRequest, TriggerMessageRequest{})
 }
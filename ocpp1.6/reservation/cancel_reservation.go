@@ -75,5 +75,5 @@ func NewCancelReservationConfirmation(status CancelReservationStatus) *CancelRes
 }
 
 func init() {
-	_ = types.Validate.RegisterValidation("cancelReservationStatus16", isValidCancelReservationStatus)
+	_ = types.RegisterValidation("cancelReservationStatus16", isValidCancelReservationStatus)
 }
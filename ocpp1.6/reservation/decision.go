@@ -0,0 +1,99 @@
+package reservation
+
+import (
+	"fmt"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+)
+
+// ConnectorSnapshot contains the state of a connector, at the time a ReserveNowRequest is processed.
+// For connectorId 0, the snapshot refers to the charge point as a whole.
+type ConnectorSnapshot struct {
+	Status        core.ChargePointStatus // The last known status of the connector.
+	ReservationId *int                   // The ID of the reservation currently active on the connector, if any.
+}
+
+// ReservationConfig contains the charge point configuration relevant to reservations.
+type ReservationConfig struct {
+	// Whether the Reservation profile is listed in the SupportedFeatureProfiles configuration key.
+	ReservationSupported bool
+	// The value of the ReserveConnectorZeroSupported configuration key.
+	ReserveConnectorZeroSupported bool
+}
+
+// Decide returns the status a charge point should reply with, when receiving the passed ReserveNowRequest.
+//
+// The decision follows the OCPP 1.6 specification:
+//
+// - Rejected, if the charge point is configured not to accept reservations, or if connector 0 is requested but ReserveConnectorZeroSupported is not set
+//
+// - Faulted, if the connector is in the Faulted state
+//
+// - Unavailable, if the connector is in the Unavailable state
+//
+// - Occupied, if the connector is in use or reserved by a different reservation
+//
+// - Accepted, otherwise. A reservation with the same reservationId as the active one is replaced.
+func Decide(req *ReserveNowRequest, connectorState ConnectorSnapshot, config ReservationConfig) ReservationStatus {
+	if !config.ReservationSupported {
+		return ReservationStatusRejected
+	}
+	if req.ConnectorId == 0 && !config.ReserveConnectorZeroSupported {
+		return ReservationStatusRejected
+	}
+	switch connectorState.Status {
+	case core.ChargePointStatusFaulted:
+		return ReservationStatusFaulted
+	case core.ChargePointStatusUnavailable:
+		return ReservationStatusUnavailable
+	case core.ChargePointStatusPreparing,
+		core.ChargePointStatusCharging,
+		core.ChargePointStatusSuspendedEV,
+		core.ChargePointStatusSuspendedEVSE,
+		core.ChargePointStatusFinishing:
+		return ReservationStatusOccupied
+	case core.ChargePointStatusReserved:
+		if !isSameReservation(req, connectorState) {
+			return ReservationStatusOccupied
+		}
+	}
+	if connectorState.ReservationId != nil && !isSameReservation(req, connectorState) {
+		return ReservationStatusOccupied
+	}
+	return ReservationStatusAccepted
+}
+
+func isSameReservation(req *ReserveNowRequest, connectorState ConnectorSnapshot) bool {
+	return connectorState.ReservationId != nil && *connectorState.ReservationId == req.ReservationId
+}
+
+// ReservationWarning describes a ReserveNowConfirmation, which is inconsistent with the connector state known to the central system.
+//
+// Since status notifications may be delayed, a warning doesn't necessarily imply a misbehaving charge point.
+type ReservationWarning struct {
+	ConnectorId    int
+	KnownStatus    core.ChargePointStatus
+	ReportedStatus ReservationStatus
+}
+
+func (w *ReservationWarning) String() string {
+	return fmt.Sprintf("charge point replied %v to reservation on connector %v, but connector is known to be %v", w.ReportedStatus, w.ConnectorId, w.KnownStatus)
+}
+
+// CheckReserveNowConfirmation allows a central system to sanity-check a ReserveNowConfirmation, received for the passed request.
+// The knownState contains the last connector state known to the central system, typically built from StatusNotification messages.
+//
+// If the reported status is impossible for the known connector state (e.g. Accepted for a Faulted connector), a warning is returned.
+// Otherwise, the function returns nil.
+func CheckReserveNowConfirmation(req *ReserveNowRequest, knownState ConnectorSnapshot, confirmation *ReserveNowConfirmation) *ReservationWarning {
+	if confirmation == nil || confirmation.Status != ReservationStatusAccepted {
+		// Refusing a reservation is always possible, e.g. due to local configuration
+		return nil
+	}
+	// Only the connector state is known to the central system, not the charge point configuration
+	expected := Decide(req, knownState, ReservationConfig{ReservationSupported: true, ReserveConnectorZeroSupported: true})
+	if expected == ReservationStatusAccepted {
+		return nil
+	}
+	return &ReservationWarning{ConnectorId: req.ConnectorId, KnownStatus: knownState.Status, ReportedStatus: confirmation.Status}
+}
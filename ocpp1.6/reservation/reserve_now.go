@@ -93,5 +93,5 @@ func NewReserveNowConfirmation(status ReservationStatus) *ReserveNowConfirmation
 }
 
 func init() {
-	_ = types.Validate.RegisterValidation("reservationStatus", isValidReservationStatus)
+	_ = types.RegisterValidation("reservationStatus", isValidReservationStatus)
 }
@@ -81,5 +81,5 @@ func NewClearChargingProfileConfirmation(status ClearChargingProfileStatus) *Cle
 }
 
 func init() {
-	_ = types.Validate.RegisterValidation("clearChargingProfileStatus16", isValidClearChargingProfileStatus)
+	_ = types.RegisterValidation("clearChargingProfileStatus16", isValidClearChargingProfileStatus)
 }
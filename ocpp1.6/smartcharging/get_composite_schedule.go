@@ -83,5 +83,5 @@ func NewGetCompositeScheduleConfirmation(status GetCompositeScheduleStatus) *Get
 }
 
 func init() {
-	_ = types.Validate.RegisterValidation("compositeScheduleStatus", isValidGetCompositeScheduleStatus)
+	_ = types.RegisterValidation("compositeScheduleStatus", isValidGetCompositeScheduleStatus)
 }
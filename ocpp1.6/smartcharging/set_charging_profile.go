@@ -79,5 +79,5 @@ func NewSetChargingProfileConfirmation(status ChargingProfileStatus) *SetChargin
 }
 
 func init() {
-	_ = types.Validate.RegisterValidation("chargingProfileStatus16", isValidChargingProfileStatus)
+	_ = types.RegisterValidation("chargingProfileStatus16", isValidChargingProfileStatus)
 }
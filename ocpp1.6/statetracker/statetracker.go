@@ -0,0 +1,281 @@
+// Package statetracker keeps track of the connector states of charge points connected to a central system,
+// as reported via StatusNotification requests.
+//
+// The tracker is fed by the central system application, typically from within the OnStatusNotification handler
+// and the charge point connection handlers:
+//
+//	tracker := statetracker.NewStateTracker()
+//	centralSystem.SetNewChargePointHandler(func(chargePoint ocpp16.ChargePointConnection) {
+//		tracker.OnChargePointConnected(chargePoint.ID())
+//	})
+//	centralSystem.SetChargePointDisconnectedHandler(func(chargePoint ocpp16.ChargePointConnection) {
+//		tracker.OnChargePointDisconnected(chargePoint.ID())
+//	})
+//	// Inside OnStatusNotification
+//	tracker.OnStatusNotification(chargePointId, request)
+//
+// If StatusNotification requests are answered by the default handler of the central system,
+// the tracker may be registered as its observer instead:
+//
+//	centralSystem.UseDefaultHandlers(ocpp16.DefaultStatusNotification)
+//	centralSystem.SetDefaultHandlerObserver(tracker)
+//
+// Status changes are published as ConnectorStatusChanged events, if an event bus is attached via SetEventBus.
+package statetracker
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/events"
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+)
+
+// ConnectorStatusSnapshot contains the last known status of a connector.
+type ConnectorStatusSnapshot struct {
+	ConnectorId     int
+	Status          core.ChargePointStatus
+	ErrorCode       core.ChargePointErrorCode
+	Info            string
+	VendorId        string
+	VendorErrorCode string
+	// The time at which the status last changed. The timestamp reported by the charge point is preferred,
+	// the time at which the notification was received is used otherwise.
+	LastChanged time.Time
+	// Set if the status of the main controller (connectorId = 0) overrides the status reported for the connector.
+	Overridden bool
+	// Set if the charge point disconnected after the status was reported. The status may be outdated.
+	StaleSince *time.Time
+}
+
+// IsStale returns true if the charge point disconnected after the status was reported.
+func (s ConnectorStatusSnapshot) IsStale() bool {
+	return s.StaleSince != nil
+}
+
+type connectorState struct {
+	snapshot ConnectorStatusSnapshot
+	// The time of the most recently applied notification, used for discarding out-of-order notifications.
+	updated time.Time
+}
+
+type chargePointState struct {
+	connectors map[int]*connectorState
+	connected  bool
+}
+
+// StateTracker tracks the connector states of charge points, as reported via StatusNotification requests.
+//
+// Notifications are ordered by their timestamp: a notification older than the last applied one for the same connector
+// is discarded. This way, notifications delivered out-of-order (e.g. queued while offline) cannot overwrite
+// a more recent status.
+//
+// A StateTracker is safe for concurrent use.
+type StateTracker struct {
+	chargePoints map[string]*chargePointState
+	now          func() time.Time
+	bus          *events.Bus
+	mutex        sync.RWMutex
+}
+
+// NewStateTracker creates a new, empty state tracker.
+func NewStateTracker() *StateTracker {
+	return &StateTracker{
+		chargePoints: map[string]*chargePointState{},
+		now:          time.Now,
+	}
+}
+
+// SetTimeSource replaces the function used for retrieving the current time. Passing nil restores time.Now.
+func (t *StateTracker) SetTimeSource(now func() time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if now == nil {
+		now = time.Now
+	}
+	t.now = now
+}
+
+// SetEventBus sets a bus, on which a ConnectorStatusChanged event is published whenever the reported status
+// of a connector changes. Passing nil disables publishing.
+func (t *StateTracker) SetEventBus(bus *events.Bus) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.bus = bus
+}
+
+func (t *StateTracker) getOrCreate(chargePointID string) *chargePointState {
+	state, ok := t.chargePoints[chargePointID]
+	if !ok {
+		state = &chargePointState{connectors: map[int]*connectorState{}}
+		t.chargePoints[chargePointID] = state
+	}
+	return state
+}
+
+// OnStatusNotification applies a status notification received from a charge point.
+// Returns false if the notification was discarded, because a more recent status is already known for the connector.
+func (t *StateTracker) OnStatusNotification(chargePointID string, request *core.StatusNotificationRequest) bool {
+	if request == nil {
+		return false
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	received := t.now()
+	timestamp := received
+	if request.Timestamp != nil && !request.Timestamp.IsZero() {
+		timestamp = request.Timestamp.Time
+	}
+	state := t.getOrCreate(chargePointID)
+	connector, ok := state.connectors[request.ConnectorId]
+	if !ok {
+		connector = &connectorState{}
+		state.connectors[request.ConnectorId] = connector
+	} else if timestamp.Before(connector.updated) {
+		return false
+	}
+	lastChanged := connector.snapshot.LastChanged
+	if !ok || connector.snapshot.Status != request.Status {
+		lastChanged = timestamp
+		// Publishing never blocks, so events are published in the order notifications are applied
+		t.bus.Publish(events.ConnectorStatusChangedEvent{StationID: chargePointID, ConnectorID: request.ConnectorId, Status: string(request.Status)})
+	}
+	connector.updated = timestamp
+	connector.snapshot = ConnectorStatusSnapshot{
+		ConnectorId:     request.ConnectorId,
+		Status:          request.Status,
+		ErrorCode:       request.ErrorCode,
+		Info:            request.Info,
+		VendorId:        request.VendorId,
+		VendorErrorCode: request.VendorErrorCode,
+		LastChanged:     lastChanged,
+	}
+	return true
+}
+
+// ObserveRequest applies the requests answered by the default handlers of a central system.
+// All requests other than StatusNotification are ignored. Refer to ocpp16.CentralSystem.SetDefaultHandlerObserver.
+func (t *StateTracker) ObserveRequest(chargePointID string, request ocpp.Request) {
+	if notification, ok := request.(*core.StatusNotificationRequest); ok {
+		t.OnStatusNotification(chargePointID, notification)
+	}
+}
+
+// OnChargePointConnected marks a charge point as connected.
+// Previously known states remain stale, until they are refreshed by a new status notification.
+func (t *StateTracker) OnChargePointConnected(chargePointID string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.getOrCreate(chargePointID).connected = true
+}
+
+// OnChargePointDisconnected marks all known connector states of a charge point as stale.
+func (t *StateTracker) OnChargePointDisconnected(chargePointID string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	state, ok := t.chargePoints[chargePointID]
+	if !ok {
+		return
+	}
+	state.connected = false
+	now := t.now()
+	for _, connector := range state.connectors {
+		if connector.snapshot.StaleSince == nil {
+			staleSince := now
+			connector.snapshot.StaleSince = &staleSince
+		}
+	}
+}
+
+// IsConnected returns true if the charge point is currently connected.
+func (t *StateTracker) IsConnected(chargePointID string) bool {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	state, ok := t.chargePoints[chargePointID]
+	return ok && state.connected
+}
+
+// RemoveChargePoint discards all state known for a charge point.
+func (t *StateTracker) RemoveChargePoint(chargePointID string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.chargePoints, chargePointID)
+}
+
+// ConnectorStatus returns the last known status of a connector.
+//
+// The status of the main controller (connectorId = 0) applies to the whole charge point:
+// if the main controller is Faulted, every connector is reported as Faulted.
+// If the main controller became Unavailable after the connector last reported its status,
+// the connector is reported as Unavailable. In both cases, the returned snapshot has the Overridden flag set.
+//
+// An error is returned if no status was ever reported for the connector.
+func (t *StateTracker) ConnectorStatus(chargePointID string, connectorID int) (ConnectorStatusSnapshot, error) {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	state, ok := t.chargePoints[chargePointID]
+	if !ok {
+		return ConnectorStatusSnapshot{}, fmt.Errorf("unknown charge point %v", chargePointID)
+	}
+	connector, ok := state.connectors[connectorID]
+	if !ok {
+		return ConnectorStatusSnapshot{}, fmt.Errorf("no status known for connector %v of charge point %v", connectorID, chargePointID)
+	}
+	return state.effectiveStatus(connector), nil
+}
+
+// AvailableConnectors returns the IDs of all connectors of a charge point, which are currently Available,
+// in ascending order. Connectors with stale or overridden states are never considered available.
+func (t *StateTracker) AvailableConnectors(chargePointID string) []int {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	state, ok := t.chargePoints[chargePointID]
+	if !ok {
+		return nil
+	}
+	var available []int
+	for connectorID, connector := range state.connectors {
+		if connectorID == 0 {
+			continue
+		}
+		snapshot := state.effectiveStatus(connector)
+		if snapshot.Status == core.ChargePointStatusAvailable && !snapshot.IsStale() {
+			available = append(available, connectorID)
+		}
+	}
+	sort.Ints(available)
+	return available
+}
+
+func (s *chargePointState) effectiveStatus(connector *connectorState) ConnectorStatusSnapshot {
+	snapshot := connector.snapshot
+	main, ok := s.connectors[0]
+	if snapshot.ConnectorId == 0 || !ok {
+		return snapshot
+	}
+	switch main.snapshot.Status {
+	case core.ChargePointStatusFaulted:
+	case core.ChargePointStatusUnavailable:
+		if main.updated.Before(connector.updated) {
+			return snapshot
+		}
+	default:
+		return snapshot
+	}
+	snapshot.Status = main.snapshot.Status
+	snapshot.ErrorCode = main.snapshot.ErrorCode
+	snapshot.Info = main.snapshot.Info
+	snapshot.VendorId = main.snapshot.VendorId
+	snapshot.VendorErrorCode = main.snapshot.VendorErrorCode
+	if main.snapshot.LastChanged.After(snapshot.LastChanged) {
+		snapshot.LastChanged = main.snapshot.LastChanged
+	}
+	if main.snapshot.StaleSince != nil && snapshot.StaleSince == nil {
+		snapshot.StaleSince = main.snapshot.StaleSince
+	}
+	snapshot.Overridden = true
+	return snapshot
+}
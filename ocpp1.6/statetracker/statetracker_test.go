@@ -0,0 +1,177 @@
+package statetracker_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/events"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/statetracker"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+)
+
+const chargePointID = "cp1"
+
+var baseTime = time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestTracker() (*statetracker.StateTracker, *time.Time) {
+	now := baseTime.Add(time.Hour)
+	tracker := statetracker.NewStateTracker()
+	tracker.SetTimeSource(func() time.Time { return now })
+	return tracker, &now
+}
+
+func notification(connectorID int, status core.ChargePointStatus, timestamp *time.Time) *core.StatusNotificationRequest {
+	request := core.NewStatusNotificationRequest(connectorID, core.NoError, status)
+	if timestamp != nil {
+		request.Timestamp = types.NewDateTime(*timestamp)
+	}
+	return request
+}
+
+func at(offset time.Duration) *time.Time {
+	t := baseTime.Add(offset)
+	return &t
+}
+
+func TestConnectorStatusUnknown(t *testing.T) {
+	tracker, _ := newTestTracker()
+	_, err := tracker.ConnectorStatus(chargePointID, 1)
+	assert.Error(t, err)
+	tracker.OnStatusNotification(chargePointID, notification(1, core.ChargePointStatusAvailable, nil))
+	_, err = tracker.ConnectorStatus(chargePointID, 2)
+	assert.Error(t, err)
+	assert.Nil(t, tracker.AvailableConnectors("other"))
+}
+
+func TestConnectorStatusOutOfOrder(t *testing.T) {
+	tracker, now := newTestTracker()
+	request := notification(1, core.ChargePointStatusCharging, at(2*time.Minute))
+	request.ErrorCode = core.HighTemperature
+	request.VendorId = "vendor"
+	request.VendorErrorCode = "E42"
+	require.True(t, tracker.OnStatusNotification(chargePointID, request))
+	// Notifications queued while offline arrive late and are discarded
+	assert.False(t, tracker.OnStatusNotification(chargePointID, notification(1, core.ChargePointStatusPreparing, at(time.Minute))))
+	assert.False(t, tracker.OnStatusNotification(chargePointID, notification(1, core.ChargePointStatusAvailable, at(0))))
+	snapshot, err := tracker.ConnectorStatus(chargePointID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, snapshot.ConnectorId)
+	assert.Equal(t, core.ChargePointStatusCharging, snapshot.Status)
+	assert.Equal(t, core.HighTemperature, snapshot.ErrorCode)
+	assert.Equal(t, "vendor", snapshot.VendorId)
+	assert.Equal(t, "E42", snapshot.VendorErrorCode)
+	// The reported timestamp is preferred over the receive time
+	assert.Equal(t, *at(2 * time.Minute), snapshot.LastChanged)
+	assert.False(t, snapshot.IsStale())
+	// Same status doesn't change the last change timestamp
+	require.True(t, tracker.OnStatusNotification(chargePointID, notification(1, core.ChargePointStatusCharging, at(3*time.Minute))))
+	snapshot, err = tracker.ConnectorStatus(chargePointID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, core.NoError, snapshot.ErrorCode)
+	assert.Equal(t, *at(2 * time.Minute), snapshot.LastChanged)
+	// Without a timestamp, the receive time is used
+	require.True(t, tracker.OnStatusNotification(chargePointID, notification(1, core.ChargePointStatusFinishing, nil)))
+	snapshot, err = tracker.ConnectorStatus(chargePointID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, core.ChargePointStatusFinishing, snapshot.Status)
+	assert.Equal(t, *now, snapshot.LastChanged)
+}
+
+func TestAvailableConnectors(t *testing.T) {
+	tracker, _ := newTestTracker()
+	tracker.OnStatusNotification(chargePointID, notification(0, core.ChargePointStatusAvailable, at(0)))
+	tracker.OnStatusNotification(chargePointID, notification(3, core.ChargePointStatusAvailable, at(0)))
+	tracker.OnStatusNotification(chargePointID, notification(1, core.ChargePointStatusAvailable, at(0)))
+	tracker.OnStatusNotification(chargePointID, notification(2, core.ChargePointStatusCharging, at(0)))
+	assert.Equal(t, []int{1, 3}, tracker.AvailableConnectors(chargePointID))
+}
+
+func TestMainControllerFaultedOverride(t *testing.T) {
+	tracker, _ := newTestTracker()
+	tracker.OnStatusNotification(chargePointID, notification(1, core.ChargePointStatusAvailable, at(0)))
+	faulted := notification(0, core.ChargePointStatusFaulted, at(time.Minute))
+	faulted.ErrorCode = core.InternalError
+	tracker.OnStatusNotification(chargePointID, faulted)
+	// A more recent connector status doesn't lift the fault of the main controller
+	tracker.OnStatusNotification(chargePointID, notification(2, core.ChargePointStatusAvailable, at(2*time.Minute)))
+	assert.Empty(t, tracker.AvailableConnectors(chargePointID))
+	for _, connectorID := range []int{1, 2} {
+		snapshot, err := tracker.ConnectorStatus(chargePointID, connectorID)
+		require.NoError(t, err)
+		assert.Equal(t, core.ChargePointStatusFaulted, snapshot.Status)
+		assert.Equal(t, core.InternalError, snapshot.ErrorCode)
+		assert.True(t, snapshot.Overridden)
+	}
+	// Fault cleared
+	tracker.OnStatusNotification(chargePointID, notification(0, core.ChargePointStatusAvailable, at(3*time.Minute)))
+	assert.Equal(t, []int{1, 2}, tracker.AvailableConnectors(chargePointID))
+	snapshot, err := tracker.ConnectorStatus(chargePointID, 1)
+	require.NoError(t, err)
+	assert.False(t, snapshot.Overridden)
+	assert.Equal(t, *at(0), snapshot.LastChanged)
+}
+
+func TestMainControllerUnavailableOverride(t *testing.T) {
+	tracker, _ := newTestTracker()
+	tracker.OnStatusNotification(chargePointID, notification(1, core.ChargePointStatusAvailable, at(0)))
+	tracker.OnStatusNotification(chargePointID, notification(2, core.ChargePointStatusAvailable, at(0)))
+	tracker.OnStatusNotification(chargePointID, notification(0, core.ChargePointStatusUnavailable, at(time.Minute)))
+	assert.Empty(t, tracker.AvailableConnectors(chargePointID))
+	snapshot, err := tracker.ConnectorStatus(chargePointID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, core.ChargePointStatusUnavailable, snapshot.Status)
+	assert.Equal(t, *at(time.Minute), snapshot.LastChanged)
+	assert.True(t, snapshot.Overridden)
+	// A connector reporting after the main controller takes precedence
+	tracker.OnStatusNotification(chargePointID, notification(2, core.ChargePointStatusAvailable, at(2*time.Minute)))
+	assert.Equal(t, []int{2}, tracker.AvailableConnectors(chargePointID))
+}
+
+func TestStaleAfterDisconnect(t *testing.T) {
+	tracker, now := newTestTracker()
+	tracker.OnChargePointConnected(chargePointID)
+	assert.True(t, tracker.IsConnected(chargePointID))
+	tracker.OnStatusNotification(chargePointID, notification(1, core.ChargePointStatusAvailable, at(0)))
+	tracker.OnStatusNotification(chargePointID, notification(2, core.ChargePointStatusAvailable, at(0)))
+	disconnectedAt := *now
+	tracker.OnChargePointDisconnected(chargePointID)
+	assert.False(t, tracker.IsConnected(chargePointID))
+	assert.Empty(t, tracker.AvailableConnectors(chargePointID))
+	snapshot, err := tracker.ConnectorStatus(chargePointID, 1)
+	require.NoError(t, err)
+	require.True(t, snapshot.IsStale())
+	assert.Equal(t, disconnectedAt, *snapshot.StaleSince)
+	assert.Equal(t, core.ChargePointStatusAvailable, snapshot.Status)
+	// After reconnecting, states are refreshed one by one
+	*now = now.Add(time.Minute)
+	tracker.OnChargePointConnected(chargePointID)
+	tracker.OnStatusNotification(chargePointID, notification(2, core.ChargePointStatusAvailable, nil))
+	assert.Equal(t, []int{2}, tracker.AvailableConnectors(chargePointID))
+	snapshot, err = tracker.ConnectorStatus(chargePointID, 1)
+	require.NoError(t, err)
+	assert.True(t, snapshot.IsStale())
+	// Removed charge points are forgotten
+	tracker.RemoveChargePoint(chargePointID)
+	_, err = tracker.ConnectorStatus(chargePointID, 2)
+	assert.Error(t, err)
+}
+
+func TestStatusChangeEvents(t *testing.T) {
+	tracker, _ := newTestTracker()
+	bus := events.NewBus(10)
+	eventC, unsubscribe := bus.Subscribe(events.ConnectorStatusChanged)
+	defer unsubscribe()
+	tracker.SetEventBus(bus)
+	require.True(t, tracker.OnStatusNotification(chargePointID, notification(1, core.ChargePointStatusAvailable, at(0))))
+	require.True(t, tracker.OnStatusNotification(chargePointID, notification(1, core.ChargePointStatusAvailable, at(time.Minute))))
+	require.True(t, tracker.OnStatusNotification(chargePointID, notification(1, core.ChargePointStatusCharging, at(2*time.Minute))))
+	require.False(t, tracker.OnStatusNotification(chargePointID, notification(1, core.ChargePointStatusFaulted, at(0))))
+	// Only actual changes are published
+	require.Len(t, eventC, 2)
+	assert.Equal(t, events.ConnectorStatusChangedEvent{StationID: chargePointID, ConnectorID: 1, Status: string(core.ChargePointStatusAvailable)}, <-eventC)
+	assert.Equal(t, events.ConnectorStatusChangedEvent{StationID: chargePointID, ConnectorID: 1, Status: string(core.ChargePointStatusCharging)}, <-eventC)
+}
@@ -2,7 +2,6 @@
 package types
 
 import (
-	"github.com/lorenzodonini/ocpp-go/ocppj"
 	"gopkg.in/go-playground/validator.v9"
 )
 
@@ -313,20 +312,17 @@ type MeterValue struct {
 	SampledValue []SampledValue `json:"sampledValue" validate:"required,min=1,dive"`
 }
 
-// Initialize validator
-var Validate = ocppj.Validate
-
 func init() {
-	_ = Validate.RegisterValidation("authorizationStatus16", isValidAuthorizationStatus)
-	_ = Validate.RegisterValidation("chargingProfilePurpose16", isValidChargingProfilePurpose)
-	_ = Validate.RegisterValidation("chargingProfileKind16", isValidChargingProfileKind)
-	_ = Validate.RegisterValidation("recurrencyKind16", isValidRecurrencyKind)
-	_ = Validate.RegisterValidation("chargingRateUnit16", isValidChargingRateUnit)
-	_ = Validate.RegisterValidation("remoteStartStopStatus16", isValidRemoteStartStopStatus)
-	_ = Validate.RegisterValidation("readingContext16", isValidReadingContext)
-	_ = Validate.RegisterValidation("valueFormat", isValidValueFormat)
-	_ = Validate.RegisterValidation("measurand16", isValidMeasurand)
-	_ = Validate.RegisterValidation("phase16", isValidPhase)
-	_ = Validate.RegisterValidation("location16", isValidLocation)
-	_ = Validate.RegisterValidation("unitOfMeasure", isValidUnitOfMeasure)
+	_ = RegisterValidation("authorizationStatus16", isValidAuthorizationStatus)
+	_ = RegisterValidation("chargingProfilePurpose16", isValidChargingProfilePurpose)
+	_ = RegisterValidation("chargingProfileKind16", isValidChargingProfileKind)
+	_ = RegisterValidation("recurrencyKind16", isValidRecurrencyKind)
+	_ = RegisterValidation("chargingRateUnit16", isValidChargingRateUnit)
+	_ = RegisterValidation("remoteStartStopStatus16", isValidRemoteStartStopStatus)
+	_ = RegisterValidation("readingContext16", isValidReadingContext)
+	_ = RegisterValidation("valueFormat", isValidValueFormat)
+	_ = RegisterValidation("measurand16", isValidMeasurand)
+	_ = RegisterValidation("phase16", isValidPhase)
+	_ = RegisterValidation("location16", isValidLocation)
+	_ = RegisterValidation("unitOfMeasure", isValidUnitOfMeasure)
 }
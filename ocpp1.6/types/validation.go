@@ -0,0 +1,89 @@
+package types
+
+import (
+	"sync"
+
+	"gopkg.in/go-playground/validator.v9"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+)
+
+// Validator used for validating all OCPP 1.6 messages.
+// The instance is owned by this package and isn't shared with other OCPP versions or with the package-level ocppj.Validate.
+//
+// Any additional custom validations should be added via RegisterValidation and RegisterStructValidation,
+// so that they are applied to validators configured via ConfigureValidator as well.
+var Validate = ocppj.NewValidator()
+
+// Custom validations registered for OCPP 1.6, in order of registration.
+type tagValidation struct {
+	tag                      string
+	fn                       validator.Func
+	callValidationEvenIfNull []bool
+}
+
+type structValidation struct {
+	fn    validator.StructLevelFunc
+	types []interface{}
+}
+
+var tagValidations []tagValidation
+var structValidations []structValidation
+var validationsMutex sync.Mutex
+
+func init() {
+	ocppj.SetDialectValidator(ocpp.V16, Validate)
+}
+
+// Validator returns the validator instance used for all OCPP 1.6 messages.
+func Validator() *validator.Validate {
+	return Validate
+}
+
+// RegisterValidation registers a custom validation for the given tag on the OCPP 1.6 validator.
+func RegisterValidation(tag string, fn validator.Func, callValidationEvenIfNull ...bool) error {
+	if err := Validate.RegisterValidation(tag, fn, callValidationEvenIfNull...); err != nil {
+		return err
+	}
+	validationsMutex.Lock()
+	defer validationsMutex.Unlock()
+	tagValidations = append(tagValidations, tagValidation{tag: tag, fn: fn, callValidationEvenIfNull: callValidationEvenIfNull})
+	return nil
+}
+
+// RegisterStructValidation registers a custom struct-level validation for the given types on the OCPP 1.6 validator.
+func RegisterStructValidation(fn validator.StructLevelFunc, types ...interface{}) {
+	Validate.RegisterStructValidation(fn, types...)
+	validationsMutex.Lock()
+	defer validationsMutex.Unlock()
+	structValidations = append(structValidations, structValidation{fn: fn, types: types})
+}
+
+// ConfigureValidator registers all OCPP 1.6 validations on a user-supplied validator instance,
+// e.g. one with custom tag name functions. The instance may then be passed to an endpoint via ocppj.Endpoint.SetValidator:
+//
+//	v := validator.New()
+//	v.RegisterTagNameFunc(jsonTagName)
+//	if err := types.ConfigureValidator(v); err != nil {
+//		...
+//	}
+//	endpoint.SetValidator(v)
+//
+// Validations registered afterwards are not applied to the instance.
+func ConfigureValidator(v *validator.Validate) error {
+	if err := ocppj.ConfigureValidator(v); err != nil {
+		return err
+	}
+	validationsMutex.Lock()
+	defer validationsMutex.Unlock()
+	for _, t := range tagValidations {
+		if err := v.RegisterValidation(t.tag, t.fn, t.callValidationEvenIfNull...); err != nil {
+			return err
+		}
+	}
+	for _, s := range structValidations {
+		v.RegisterStructValidation(s.fn, s.types...)
+	}
+	return nil
+}
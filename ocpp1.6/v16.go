@@ -2,10 +2,15 @@
 package ocpp16
 
 import (
+	"context"
 	"crypto/tls"
 	"net"
+	"time"
 
+	"github.com/lorenzodonini/ocpp-go/clocksync"
+	"github.com/lorenzodonini/ocpp-go/events"
 	"github.com/lorenzodonini/ocpp-go/internal/callbackqueue"
+	"github.com/lorenzodonini/ocpp-go/logging"
 	"github.com/lorenzodonini/ocpp-go/ocpp"
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/firmware"
@@ -79,6 +84,12 @@ type ChargePoint interface {
 	SetRemoteTriggerHandler(listener remotetrigger.ChargePointHandler)
 	// Registers a handler for incoming smart charging profile messages
 	SetSmartChargingHandler(listener smartcharging.ChargePointHandler)
+	// Enables a lenient decoding mode for inbound messages, tolerating common deviations from the OCPP 1.6 JSON schema
+	// sent by non-conformant central systems (numbers as strings, booleans as 0/1, null values and empty optional enums).
+	// Every tolerated deviation is reported to the optional hook. Strict decoding is used by default.
+	//
+	// Refer to ocppj.Endpoint.SetLenientDecoding for more information.
+	EnableLenientDecoding(hook func(coercion ocppj.Coercion))
 	// Sends a request to the central system.
 	// The central system will respond with a confirmation, or with an error if the request was invalid or could not be processed.
 	// In case of network issues (i.e. the remote host couldn't be reached), the function also returns an error.
@@ -104,11 +115,58 @@ type ChargePoint interface {
 	// Returns true if the charge point is currently connected to the central system, false otherwise.
 	// While automatically reconnecting to the central system, the method returns false.
 	IsConnected() bool
+	// Enables an application-level watchdog, detecting connections on which requests are sent, but no messages are received anymore.
+	// If no message was received from the central system for at least maxSilence, despite requests being sent,
+	// the connection is aborted and the automatic reconnection mechanism is started.
+	// The watchdog never triggers while the charge point is idle.
+	//
+	// Passing a maxSilence <= 0 disables the watchdog.
+	EnableResponseWatchdog(maxSilence time.Duration)
+	// Registers a handler, which is invoked every time the response watchdog forces a reconnection.
+	SetResponseWatchdogHandler(handler func(silence time.Duration))
+	// Applies a new value of the WebSocketPingInterval configuration key to the live connection, without reconnecting.
+	// Passing 0 disables client pings, while negative values are rejected with an error.
+	//
+	// The function is invoked automatically whenever a ChangeConfigurationRequest for the WebSocketPingInterval key is
+	// accepted by the core handler. Requests with an invalid or negative value are rejected without invoking the handler.
+	//
+	// An error is also returned, if the websocket client doesn't implement ws.PingPeriodSetter.
+	ApplyWebSocketPingInterval(seconds int) error
+	// Sets the clock synchronization mode, defining how the current time reported by the central system
+	// in BootNotification and Heartbeat confirmations is used. Clock synchronization is disabled by default.
+	//
+	// In clocksync.ModeTrackOffset, the learned offset is applied to Now, as well as to timestamps filled automatically
+	// by the charge point (e.g. the optional timestamp of a StatusNotification).
+	// Refer to clocksync.Clock for details on how the offset is smoothed.
+	SetClockSyncMode(mode clocksync.Mode)
+	// Registers a handler, invoked with the authoritative time reported by the central system, while in clocksync.ModeCallback.
+	// The handler is expected to adjust the local clock.
+	SetClockSyncHandler(handler func(authoritativeTime time.Time))
+	// Returns the current time. In clocksync.ModeTrackOffset, the offset learned from the central system is applied.
+	Now() time.Time
+	// Sets the logger used by the charge point, overriding the package-level loggers of the ocppj and ws packages.
+	// Log entries are enriched with the charge point ID and, where available, the action and unique ID of the processed message.
+	// Passing nil restores the package-level loggers.
+	//
+	// The logger must be set before calling Start.
+	SetLogger(logger logging.Logger)
+	// Sets a bus, on which the connection state of the charge point (ConnectionUp, ConnectionDown), as well as timed out requests
+	// and queue overflows (RequestTimedOut, QueueOverflow) are published, provided the websocket client supports it.
+	// Passing nil disables publishing.
+	//
+	// The bus must be set before calling Start.
+	SetEventBus(bus *events.Bus)
 	// Errors returns a channel for error messages. If it doesn't exist it es created.
 	// The channel is closed by the charge point when stopped.
 	Errors() <-chan error
 }
 
+// Profiles returns all profiles supported by default by charge points and central systems, in the order they are registered
+// with the ocppj endpoints created by the constructors of this package.
+func Profiles() []*ocpp.Profile {
+	return []*ocpp.Profile{core.Profile, localauth.Profile, firmware.Profile, reservation.Profile, remotetrigger.Profile, smartcharging.Profile}
+}
+
 // Creates a new OCPP 1.6 charge point client.
 // The id parameter is required to uniquely identify the charge point.
 //
@@ -142,19 +200,23 @@ func NewChargePoint(id string, endpoint *ocppj.Client, client ws.WsClient) Charg
 
 	if endpoint == nil {
 		dispatcher := ocppj.NewDefaultClientDispatcher(ocppj.NewFIFOClientQueue(0))
-		endpoint = ocppj.NewClient(id, client, dispatcher, nil, core.Profile, localauth.Profile, firmware.Profile, reservation.Profile, remotetrigger.Profile, smartcharging.Profile)
+		endpoint = ocppj.NewClient(id, client, dispatcher, nil, Profiles()...)
 	}
 	endpoint.SetDialect(ocpp.V16)
 
 	cp := chargePoint{
 		client:              endpoint,
+		network:             client,
 		confirmationHandler: make(chan ocpp.Response, 1),
 		errorHandler:        make(chan error, 1),
 		callbacks:           callbackqueue.New(),
+		clock:               clocksync.NewClock(),
+		sendTimes:           map[ocpp.Request]time.Time{},
 	}
 
 	// Callback invoked by dispatcher, whenever a queued request is canceled, due to timeout.
 	endpoint.SetOnRequestCanceled(cp.onRequestTimeout)
+	endpoint.SetOnRequestSent(cp.onRequestSent)
 
 	cp.client.SetResponseHandler(func(confirmation ocpp.Response, requestId string) {
 		cp.confirmationHandler <- confirmation
@@ -163,6 +225,14 @@ func NewChargePoint(id string, endpoint *ocppj.Client, client ws.WsClient) Charg
 		cp.errorHandler <- err
 	})
 	cp.client.SetRequestHandler(cp.handleIncomingRequest)
+	// Schema violations detected in warn mode are reported on the error channel
+	cp.client.SetSchemaViolationHandler(func(err *ocppj.SchemaValidationError) {
+		cp.error(err)
+	})
+	// Panics of handlers and callbacks are reported on the error channel
+	cp.client.SetPanicHandler(func(err *ocppj.HandlerPanicError) {
+		cp.error(err)
+	})
 	return &cp
 }
 
@@ -213,6 +283,7 @@ type CentralSystem interface {
 	// Queries the current version of the local authorization list from a charge point.
 	GetLocalListVersion(clientId string, callback func(*localauth.GetLocalListVersionConfirmation, error), props ...func(request *localauth.GetLocalListVersionRequest)) error
 	// Sends or updates a local authorization list on a charge point. Versioning rules must be followed.
+	// If the charge point reported its SendLocalListMaxLength in a GetConfiguration confirmation, longer lists are rejected with a localauth.MaxLengthError.
 	SendLocalList(clientId string, callback func(*localauth.SendLocalListConfirmation, error), version int, updateType localauth.UpdateType, props ...func(request *localauth.SendLocalListRequest)) error
 	// Requests diagnostics data from a charge point. The data will be uploaded out-of-band to the provided URL location.
 	GetDiagnostics(clientId string, callback func(*firmware.GetDiagnosticsConfirmation, error), location string, props ...func(request *firmware.GetDiagnosticsRequest)) error
@@ -223,6 +294,7 @@ type CentralSystem interface {
 	// Cancels a previously reserved charge point or connector, given the reservation ID.
 	CancelReservation(clientId string, callback func(*reservation.CancelReservationConfirmation, error), reservationId int, props ...func(request *reservation.CancelReservationRequest)) error
 	// Instructs a charge point to send a specific message to the central system. This is used for forcefully triggering status updates, when the last known state is either too old or not clear to the central system.
+	// A connectorId may only be set for MeterValues and StatusNotification, otherwise a *remotetrigger.ConnectorScopeError is returned.
 	TriggerMessage(clientId string, callback func(*remotetrigger.TriggerMessageConfirmation, error), requestedMessage remotetrigger.MessageTrigger, props ...func(request *remotetrigger.TriggerMessageRequest)) error
 	// Sends a smart charging profile to a charge point. Refer to the smart charging documentation for more information.
 	SetChargingProfile(clientId string, callback func(*smartcharging.SetChargingProfileConfirmation, error), connectorId int, chargingProfile *types.ChargingProfile, props ...func(request *smartcharging.SetChargingProfileRequest)) error
@@ -245,15 +317,95 @@ type CentralSystem interface {
 	SetSmartChargingHandler(handler smartcharging.CentralSystemHandler)
 	// Registers a handler for new incoming Charging station connections.
 	SetNewChargingStationValidationHandler(handler ws.CheckClientHandler)
+	// Enables default handlers, answering trivial requests on behalf of the application. Values may be combined:
+	//
+	//	centralSystem.UseDefaultHandlers(ocpp16.DefaultHeartbeat | ocpp16.DefaultStatusNotification)
+	//
+	// The following requests are covered:
+	//  - Heartbeat and StatusNotification, if no core handler was set
+	//  - DiagnosticsStatusNotification and FirmwareStatusNotification, if no firmware management handler was set
+	//
+	// A handler set for the profile of a request always takes precedence. Responses of default handlers
+	// are sent via the ocppj endpoint as usual, hence they are recorded by the audit handler.
+	UseDefaultHandlers(handlers DefaultHandler)
+	// Registers an observer, receiving every request answered by a default handler, e.g. a statetracker.StateTracker
+	// for keeping track of the connector states while StatusNotification requests are answered by default.
+	SetDefaultHandlerObserver(observer RequestObserver)
+	// Enables the detection of repeated StartTransaction and StopTransaction requests, which charge points resend
+	// after missing the confirmation. A repeated request is answered with the confirmation previously returned
+	// by the core handler, e.g. with the same transactionId and idTagInfo, instead of invoking the handler again.
+	//
+	// Requests are identical if they have the same key, by default derived from the charge point, connector, idTag,
+	// meterStart and timestamp (see DefaultStartTransactionKey). Confirmations are retained for the configured window,
+	// while errors returned by the handler are never retained. Passing nil disables the detection.
+	SetTransactionDeduplication(config *TransactionDeduplication)
 	// Registers a handler for new incoming charge point connections.
 	SetNewChargePointHandler(handler ChargePointConnectionHandler)
 	// Registers a handler for charge point disconnections.
 	SetChargePointDisconnectedHandler(handler ChargePointConnectionHandler)
+	// Enables a lenient decoding mode for inbound messages, tolerating common deviations from the OCPP 1.6 JSON schema
+	// sent by non-conformant charge points (numbers as strings, booleans as 0/1, null values and empty optional enums).
+	// Every tolerated deviation is reported to the optional hook. Strict decoding is used by default.
+	//
+	// Refer to ocppj.Endpoint.SetLenientDecoding for more information.
+	EnableLenientDecoding(hook func(coercion ocppj.Coercion))
+	// Holds requests received from charge points, until HandlersReady is invoked. Useful if handlers are registered
+	// asynchronously after starting the central system, since requests received before would be answered with an error.
+	// Requests held for longer than maxHold, or exceeding maxQueued held requests, are handled right away.
+	//
+	// Refer to ocppj.Server.HoldRequestsUntilHandlersReady for more information. Must be invoked before calling Start.
+	HoldRequestsUntilHandlersReady(maxHold time.Duration, maxQueued int)
+	// Signals that all handlers were registered. Held requests are passed to the handlers in the order they were received.
+	HandlersReady()
+	// Processes the messages received from each charge point via a bounded inbox, so that slow handlers don't stall
+	// reading from its connection. Inboxes are served by at most maxWorkers goroutines, or one per charge point if zero.
+	//
+	// Refer to ocppj.Server.SetClientInbox for more information. Must be invoked before calling Start.
+	SetClientInbox(inboxSize int, maxWorkers int)
+	// Enables a liveness monitor, which closes the connections of charge points that stopped sending messages without
+	// disconnecting, so that their reconnection logic kicks in. Charge points exceeding their heartbeat interval plus
+	// a grace time are probed via TriggerMessage(Heartbeat) first, if the RemoteTrigger profile is supported.
+	// Heartbeat intervals are taken from BootNotification, GetConfiguration and ChangeConfiguration messages.
+	//
+	// Refer to ocppj.Server.EnableLivenessMonitor for more information. Must be invoked before calling Start.
+	EnableLivenessMonitor(config ocppj.LivenessConfig)
+	// Registers a handler, invoked whenever a charge point is probed, recovers after a probe, or is reaped by the liveness monitor.
+	SetLivenessHandler(handler func(chargePointID string, liveness ocppj.Liveness))
+	// Returns the liveness state of a connected charge point. Returns false if the charge point isn't connected,
+	// or the liveness monitor isn't enabled.
+	Liveness(chargePointID string) (ocppj.Liveness, bool)
+	// Registers a handler, receiving an audit entry for every message sent by any charge point.
+	// Entries are created even for unparseable frames, invalid payloads and unsupported actions.
+	// For incoming requests, the entry contains the response or error returned to the charge point, as well as the handler latency.
+	//
+	// Entries are delivered asynchronously and never block message processing.
+	// If the handler cannot keep up, excess entries are dropped and counted. Refer to DroppedAuditEntries.
+	SetAuditHandler(handler func(entry ocppj.AuditEntry))
+	// Returns the amount of audit entries, which were dropped because the audit handler couldn't keep up.
+	DroppedAuditEntries() uint64
 	// Sends an asynchronous request to the charge point.
 	// The charge point will respond with a confirmation message, or with an error if the request was invalid or could not be processed.
 	// This result is propagated via a callback, called asynchronously.
 	// In case of network issues (i.e. the remote host couldn't be reached), the function returns an error directly. In this case, the callback is never called.
 	SendRequestAsync(clientId string, request ocpp.Request, callback func(ocpp.Response, error)) error
+	// Works like SendRequestAsync, but additionally returns the unique message ID assigned to the request.
+	// The message ID may be used to cancel the request, by invoking CancelRequest.
+	//
+	// Every request sent by the typed functions of the central system (e.g. Reset) may also be sent via this function,
+	// by creating the request with the respective constructor (e.g. core.NewResetRequest).
+	SendRequestAsyncWithID(clientId string, request ocpp.Request, callback func(ocpp.Response, error)) (string, error)
+	// Works like SendRequestAsyncWithID, but additionally links the span of the request to the trace contained in ctx,
+	// if a tracer was set on the ocppj endpoint (refer to ocppj.Server.SetTracer).
+	//
+	// The context is only used for tracing: canceling it doesn't cancel the request. Refer to CancelRequest instead.
+	SendRequestAsyncWithContext(ctx context.Context, clientId string, request ocpp.Request, callback func(ocpp.Response, error)) (string, error)
+	// Cancels an outstanding request to a charge point, identified by its unique message ID.
+	// The request is removed from the charge point's queue, or from the pending requests if it was already sent.
+	// The callback of the request is invoked with a *ocppj.RequestCanceledError.
+	//
+	// If a response to the request was already received, the response wins: the callback is invoked with the response
+	// and an error is returned.
+	CancelRequest(clientId string, requestId string) error
 	// Starts running the central system on the specified port and URL.
 	// The central system runs as a daemon and handles incoming charge point connections and messages.
 
@@ -261,6 +413,32 @@ type CentralSystem interface {
 	Start(listenPort int, listenPath string)
 	// Stops the central system, clearing all pending requests.
 	Stop()
+	// Sets the logger used by the central system, overriding the package-level loggers of the ocppj and ws packages.
+	// Log entries are enriched with the charge point ID and, where available, the action and unique ID of the processed message.
+	// Passing nil restores the package-level loggers.
+	//
+	// The logger must be set before calling Start.
+	SetLogger(logger logging.Logger)
+	// Sets a collector, receiving measurements about the requests exchanged with charge points.
+	// If the collector also implements ws.MetricsCollector, it receives measurements about the connections
+	// of the websocket server as well, provided the server supports it. Passing nil disables metrics collection.
+	//
+	// The collector must be set before calling Start.
+	SetMetricsCollector(collector ocppj.MetricsCollector)
+	// Sets a bus, on which the connections of the websocket server (ConnectionUp, ConnectionDown), as well as
+	// timed out requests and queue overflows (RequestTimedOut, QueueOverflow) are published, provided the server supports it.
+	// Passing nil disables publishing.
+	//
+	// The bus must be set before calling Start.
+	SetEventBus(bus *events.Bus)
+	// Returns a snapshot of the request flow towards each connected charge point, sorted by ID.
+	Stats() []ocppj.ClientStats
+	// Returns all connected charge points, sorted by ID, along with their connection details and capabilities.
+	//
+	// Capabilities are read from the SupportedFeatureProfiles key, whenever a GetConfiguration confirmation containing it
+	// is received. They are marked as unknown if the key was never received, and as stale if it was received
+	// during a previous connection of the charge point.
+	Inventory() []ocppj.EndpointInfo
 	// Errors returns a channel for error messages. If it doesn't exist it es created.
 	Errors() <-chan error
 }
@@ -283,9 +461,10 @@ func NewCentralSystem(endpoint *ocppj.Server, server ws.WsServer) CentralSystem
 	}
 	server.AddSupportedSubprotocol(types.V16Subprotocol)
 	if endpoint == nil {
-		endpoint = ocppj.NewServer(server, nil, nil, core.Profile, localauth.Profile, firmware.Profile, reservation.Profile, remotetrigger.Profile, smartcharging.Profile)
+		endpoint = ocppj.NewServer(server, nil, nil, Profiles()...)
 	}
 	cs := newCentralSystem(endpoint)
+	cs.network = server
 	cs.server.SetRequestHandler(func(client ws.Channel, request ocpp.Request, requestId string, action string) {
 		cs.handleIncomingRequest(client, request, requestId, action)
 	})
@@ -296,7 +475,78 @@ func NewCentralSystem(endpoint *ocppj.Server, server ws.WsServer) CentralSystem
 		cs.handleIncomingError(client, err, details)
 	})
 	cs.server.SetCanceledRequestHandler(func(clientID string, requestID string, request ocpp.Request, err *ocpp.Error) {
-		cs.handleCanceledRequest(clientID, request, err)
+		cs.handleCanceledRequest(clientID, requestID, request, err)
+	})
+	// Schema violations detected in warn mode are reported on the error channel
+	cs.server.SetSchemaViolationHandler(func(err *ocppj.SchemaValidationError) {
+		cs.error(err)
+	})
+	// Panics of handlers and callbacks are reported on the error channel
+	cs.server.SetPanicHandler(func(err *ocppj.HandlerPanicError) {
+		cs.error(err)
 	})
 	return &cs
 }
+
+// Send sends a request to the central system and returns the confirmation as the concrete type TConf, e.g.:
+//
+//	confirmation, err := ocpp16.Send[*core.HeartbeatConfirmation](ctx, chargePoint, core.NewHeartbeatRequest())
+//
+// The function blocks until a confirmation is received, the request fails or ctx is done.
+// If ctx is done first, ctx.Err() is returned, but the request is not withdrawn and may still be sent to the central system.
+// If the received confirmation is not a TConf, a *ocpp.ResponseTypeError is returned.
+func Send[TConf ocpp.Response](ctx context.Context, cp ChargePoint, request ocpp.Request) (TConf, error) {
+	type result struct {
+		confirmation ocpp.Response
+		err          error
+	}
+	resultC := make(chan result, 1)
+	go func() {
+		confirmation, err := cp.SendRequest(request)
+		resultC <- result{confirmation: confirmation, err: err}
+	}()
+	var zero TConf
+	select {
+	case res := <-resultC:
+		if res.err != nil {
+			return zero, res.err
+		}
+		return ocpp.CastResponse[TConf](res.confirmation)
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
+
+// SendTo sends a request to a charge point and returns the confirmation as the concrete type TConf, e.g.:
+//
+//	confirmation, err := ocpp16.SendTo[*core.ResetConfirmation](ctx, centralSystem, "cp0001", core.NewResetRequest(core.ResetTypeSoft))
+//
+// The function blocks until a confirmation is received, the request fails or ctx is done.
+// If ctx is done first, the request is canceled (refer to CentralSystem.CancelRequest) and ctx.Err() is returned.
+// If the received confirmation is not a TConf, a *ocpp.ResponseTypeError is returned.
+//
+// The span of the request is linked to the trace contained in ctx, refer to CentralSystem.SendRequestAsyncWithContext.
+func SendTo[TConf ocpp.Response](ctx context.Context, cs CentralSystem, clientId string, request ocpp.Request) (TConf, error) {
+	type result struct {
+		confirmation ocpp.Response
+		err          error
+	}
+	resultC := make(chan result, 1)
+	var zero TConf
+	requestId, err := cs.SendRequestAsyncWithContext(ctx, clientId, request, func(confirmation ocpp.Response, err error) {
+		resultC <- result{confirmation: confirmation, err: err}
+	})
+	if err != nil {
+		return zero, err
+	}
+	select {
+	case res := <-resultC:
+		if res.err != nil {
+			return zero, res.err
+		}
+		return ocpp.CastResponse[TConf](res.confirmation)
+	case <-ctx.Done():
+		_ = cs.CancelRequest(clientId, requestId)
+		return zero, ctx.Err()
+	}
+}
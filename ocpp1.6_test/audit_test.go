@@ -0,0 +1,137 @@
+package ocpp16_test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/firmware"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+)
+
+func (suite *OcppV16TestSuite) TestCentralSystemAuditHandler() {
+	t := suite.T()
+	wsId := "test_id"
+	channel := NewMockWebSocket(wsId)
+	entryC := make(chan ocppj.AuditEntry, 10)
+	coreListener := &MockCentralSystemCoreListener{}
+	coreListener.On("OnHeartbeat", mock.AnythingOfType("string"), mock.Anything).Return(core.NewHeartbeatConfirmation(types.NewDateTime(time.Now())), nil)
+	suite.centralSystem.SetCoreHandler(coreListener)
+	suite.centralSystem.SetAuditHandler(func(entry ocppj.AuditEntry) {
+		entryC <- entry
+	})
+	suite.mockWsServer.On("Start", mock.AnythingOfType("int"), mock.AnythingOfType("string")).Return(nil)
+	suite.mockWsServer.On("Write", mock.AnythingOfType("string"), mock.Anything).Return(nil)
+	suite.centralSystem.Start(8887, "somePath")
+	suite.mockWsServer.NewClientHandler(channel)
+	messages := []string{
+		`[2,"1","Heartbeat",{}]`,
+		`[2,"2","Heartbeat",{`,
+		`[2,"3","StatusNotification",{"connectorId":-1}]`,
+		fmt.Sprintf(`[2,"4","%v",{"status":"Idle"}]`, firmware.DiagnosticsStatusNotificationFeatureName),
+		`[2,"5","UnknownAction",{}]`,
+	}
+	for _, message := range messages {
+		_ = suite.mockWsServer.MessageHandler(channel, []byte(message))
+	}
+	entries := map[string]ocppj.AuditEntry{}
+	for range messages {
+		select {
+		case entry := <-entryC:
+			entries[string(entry.RawMessage)] = entry
+		case <-time.After(time.Second):
+			t.Fatal("missing audit entry")
+		}
+	}
+	require.Len(t, entries, len(messages))
+	for _, entry := range entries {
+		assert.Equal(t, wsId, entry.ClientID)
+		assert.False(t, entry.Timestamp.IsZero())
+	}
+	// Valid request
+	entry := entries[messages[0]]
+	assert.Equal(t, ocppj.CALL, entry.MessageType)
+	assert.Equal(t, core.HeartbeatFeatureName, entry.Action)
+	assert.Equal(t, "1", entry.UniqueID)
+	assert.Nil(t, entry.ParseError)
+	assert.IsType(t, &core.HeartbeatConfirmation{}, entry.Response)
+	assert.Nil(t, entry.ResponseError)
+	assert.Greater(t, entry.HandlerLatency, time.Duration(0))
+	// Malformed frame
+	entry = entries[messages[1]]
+	require.NotNil(t, entry.ParseError)
+	assert.Equal(t, ocppj.FormatViolationV16, entry.ParseError.Code)
+	assert.Empty(t, entry.UniqueID)
+	assert.Nil(t, entry.Response)
+	assert.Nil(t, entry.ResponseError)
+	// Invalid payload
+	entry = entries[messages[2]]
+	assert.Equal(t, core.StatusNotificationFeatureName, entry.Action)
+	assert.Equal(t, "3", entry.UniqueID)
+	require.NotNil(t, entry.ParseError)
+	require.NotNil(t, entry.ResponseError)
+	assert.Equal(t, entry.ParseError.Code, entry.ResponseError.Code)
+	// Action without handler
+	entry = entries[messages[3]]
+	assert.Equal(t, firmware.DiagnosticsStatusNotificationFeatureName, entry.Action)
+	assert.Nil(t, entry.ParseError)
+	assert.Nil(t, entry.Response)
+	require.NotNil(t, entry.ResponseError)
+	assert.Equal(t, ocppj.NotSupported, entry.ResponseError.Code)
+	// Unknown action
+	entry = entries[messages[4]]
+	assert.Equal(t, "UnknownAction", entry.Action)
+	require.NotNil(t, entry.ParseError)
+	require.NotNil(t, entry.ResponseError)
+	assert.Equal(t, ocppj.NotSupported, entry.ResponseError.Code)
+	assert.Equal(t, uint64(0), suite.centralSystem.DroppedAuditEntries())
+}
+
+func (suite *OcppV16TestSuite) TestCentralSystemAuditHandlerDisconnect() {
+	t := suite.T()
+	wsId := "test_id"
+	channel := NewMockWebSocket(wsId)
+	entryC := make(chan ocppj.AuditEntry, 1)
+	handlerC := make(chan struct{})
+	writtenC := make(chan struct{})
+	coreListener := &MockCentralSystemCoreListener{}
+	coreListener.On("OnHeartbeat", mock.AnythingOfType("string"), mock.Anything).Return(core.NewHeartbeatConfirmation(types.NewDateTime(time.Now())), nil).Run(func(args mock.Arguments) {
+		<-handlerC
+	})
+	suite.centralSystem.SetCoreHandler(coreListener)
+	suite.centralSystem.SetAuditHandler(func(entry ocppj.AuditEntry) {
+		entryC <- entry
+	})
+	suite.mockWsServer.On("Start", mock.AnythingOfType("int"), mock.AnythingOfType("string")).Return(nil)
+	suite.mockWsServer.On("Write", mock.AnythingOfType("string"), mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		close(writtenC)
+	})
+	suite.centralSystem.Start(8887, "somePath")
+	suite.mockWsServer.NewClientHandler(channel)
+	go func() {
+		_ = suite.mockWsServer.MessageHandler(channel, []byte(`[2,"1","Heartbeat",{}]`))
+	}()
+	// Request is pending while the handler is running, the entry is delivered on disconnection
+	select {
+	case <-entryC:
+		t.Fatal("unexpected audit entry")
+	case <-time.After(100 * time.Millisecond):
+	}
+	suite.mockWsServer.DisconnectedClientHandler(channel)
+	select {
+	case entry := <-entryC:
+		assert.Equal(t, "1", entry.UniqueID)
+		assert.Nil(t, entry.Response)
+		assert.Nil(t, entry.ResponseError)
+	case <-time.After(time.Second):
+		t.Fatal("missing audit entry")
+	}
+	close(handlerC)
+	// Wait for the late response, so it doesn't outlive the test
+	<-writtenC
+}
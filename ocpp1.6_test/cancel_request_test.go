@@ -0,0 +1,132 @@
+package ocpp16_test
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type asyncResult struct {
+	response ocpp.Response
+	err      error
+}
+
+func (suite *OcppV16TestSuite) setupCancelRequestTest(wsId string) chan []byte {
+	written := make(chan []byte, 2)
+	var counter int32
+	ocppj.SetMessageIdGenerator(func() string {
+		return strconv.Itoa(int(atomic.AddInt32(&counter, 1)))
+	})
+	suite.mockWsServer.On("Start", mock.AnythingOfType("int"), mock.AnythingOfType("string")).Return(nil)
+	suite.mockWsServer.On("Write", mock.AnythingOfType("string"), mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		written <- args.Get(1).([]byte)
+	})
+	suite.centralSystem.Start(8887, "somePath")
+	suite.mockWsServer.NewClientHandler(NewMockWebSocket(wsId))
+	return written
+}
+
+func (suite *OcppV16TestSuite) TestCancelPendingRequest() {
+	t := suite.T()
+	wsId := "test_id"
+	written := suite.setupCancelRequestTest(wsId)
+	resultC := make(chan asyncResult, 2)
+	requestId, err := suite.centralSystem.SendRequestAsyncWithID(wsId, core.NewResetRequest(core.ResetTypeSoft), func(response ocpp.Response, err error) {
+		resultC <- asyncResult{response, err}
+	})
+	require.NoError(t, err)
+	<-written
+	err = suite.centralSystem.CancelRequest(wsId, requestId)
+	require.NoError(t, err)
+	result := <-resultC
+	assert.Nil(t, result.response)
+	var cancelErr *ocppj.RequestCanceledError
+	require.True(t, errors.As(result.err, &cancelErr))
+	assert.Equal(t, wsId, cancelErr.ClientID)
+	assert.Equal(t, requestId, cancelErr.RequestID)
+	// A late response is discarded
+	channel := NewMockWebSocket(wsId)
+	_ = suite.mockWsServer.MessageHandler(channel, []byte(fmt.Sprintf(`[3,"%v",{"status":"Accepted"}]`, requestId)))
+	select {
+	case <-resultC:
+		t.Fatal("unexpected callback invocation")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func (suite *OcppV16TestSuite) TestCancelQueuedRequest() {
+	t := suite.T()
+	wsId := "test_id"
+	written := suite.setupCancelRequestTest(wsId)
+	channel := NewMockWebSocket(wsId)
+	firstC := make(chan asyncResult, 1)
+	secondC := make(chan asyncResult, 1)
+	thirdC := make(chan asyncResult, 1)
+	firstId, err := suite.centralSystem.SendRequestAsyncWithID(wsId, core.NewResetRequest(core.ResetTypeSoft), func(response ocpp.Response, err error) {
+		firstC <- asyncResult{response, err}
+	})
+	require.NoError(t, err)
+	secondId, err := suite.centralSystem.SendRequestAsyncWithID(wsId, core.NewClearCacheRequest(), func(response ocpp.Response, err error) {
+		secondC <- asyncResult{response, err}
+	})
+	require.NoError(t, err)
+	thirdId, err := suite.centralSystem.SendRequestAsyncWithID(wsId, core.NewResetRequest(core.ResetTypeHard), func(response ocpp.Response, err error) {
+		thirdC <- asyncResult{response, err}
+	})
+	require.NoError(t, err)
+	<-written
+	// Cancel the second request, which wasn't sent yet
+	err = suite.centralSystem.CancelRequest(wsId, secondId)
+	require.NoError(t, err)
+	result := <-secondC
+	var cancelErr *ocppj.RequestCanceledError
+	assert.True(t, errors.As(result.err, &cancelErr))
+	// Responses are still matched to the correct callbacks
+	err = suite.mockWsServer.MessageHandler(channel, []byte(fmt.Sprintf(`[3,"%v",{"status":"Accepted"}]`, firstId)))
+	require.NoError(t, err)
+	result = <-firstC
+	require.NoError(t, result.err)
+	assert.IsType(t, &core.ResetConfirmation{}, result.response)
+	request := <-written
+	assert.Contains(t, string(request), fmt.Sprintf(`[2,"%v","Reset",{"type":"Hard"}]`, thirdId))
+	err = suite.mockWsServer.MessageHandler(channel, []byte(fmt.Sprintf(`[3,"%v",{"status":"Rejected"}]`, thirdId)))
+	require.NoError(t, err)
+	result = <-thirdC
+	require.NoError(t, result.err)
+	require.IsType(t, &core.ResetConfirmation{}, result.response)
+	assert.Equal(t, core.ResetStatusRejected, result.response.(*core.ResetConfirmation).Status)
+}
+
+func (suite *OcppV16TestSuite) TestCancelAnsweredRequest() {
+	t := suite.T()
+	wsId := "test_id"
+	written := suite.setupCancelRequestTest(wsId)
+	channel := NewMockWebSocket(wsId)
+	resultC := make(chan asyncResult, 2)
+	requestId, err := suite.centralSystem.SendRequestAsyncWithID(wsId, core.NewResetRequest(core.ResetTypeSoft), func(response ocpp.Response, err error) {
+		resultC <- asyncResult{response, err}
+	})
+	require.NoError(t, err)
+	<-written
+	err = suite.mockWsServer.MessageHandler(channel, []byte(fmt.Sprintf(`[3,"%v",{"status":"Accepted"}]`, requestId)))
+	require.NoError(t, err)
+	result := <-resultC
+	require.NoError(t, result.err)
+	// The response wins over the cancellation
+	err = suite.centralSystem.CancelRequest(wsId, requestId)
+	assert.Error(t, err)
+	select {
+	case <-resultC:
+		t.Fatal("unexpected callback invocation")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,155 @@
+package ocpp16_test
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/clock/clocktest"
+	"github.com/lorenzodonini/ocpp-go/clocksync"
+	ocpp16 "github.com/lorenzodonini/ocpp-go/ocpp1.6"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/lorenzodonini/ocpp-go/ws/wstest"
+)
+
+func (suite *OcppV16TestSuite) setupClockSyncTest(coreListener *MockCentralSystemCoreListener, serverOffset time.Duration) {
+	wsId := "test_id"
+	wsUrl := "someUrl"
+	channel := NewMockWebSocket(wsId)
+	coreListener.On("OnHeartbeat", mock.AnythingOfType("string"), mock.Anything).Return(core.NewHeartbeatConfirmation(types.NewDateTime(time.Now().Add(serverOffset))), nil)
+	setupDefaultCentralSystemHandlers(suite, coreListener, expectedCentralSystemOptions{clientId: wsId, forwardWrittenMessage: true})
+	setupDefaultChargePointHandlers(suite, nil, expectedChargePointOptions{serverUrl: wsUrl, clientId: wsId, createChannelOnStart: true, channel: channel, forwardWrittenMessage: true})
+	suite.centralSystem.Start(8887, "somePath")
+	err := suite.chargePoint.Start(wsUrl)
+	require.NoError(suite.T(), err)
+}
+
+func (suite *OcppV16TestSuite) TestClockSyncTrackOffset() {
+	t := suite.T()
+	serverOffset := time.Hour
+	coreListener := &MockCentralSystemCoreListener{}
+	timestampC := make(chan *types.DateTime, 2)
+	coreListener.On("OnStatusNotification", mock.AnythingOfType("string"), mock.Anything).Return(core.NewStatusNotificationConfirmation(), nil).Run(func(args mock.Arguments) {
+		request := args.Get(1).(*core.StatusNotificationRequest)
+		timestampC <- request.Timestamp
+	})
+	suite.setupClockSyncTest(coreListener, serverOffset)
+	suite.chargePoint.SetClockSyncMode(clocksync.ModeTrackOffset)
+	// Before learning the offset, the local time is used
+	assert.WithinDuration(t, time.Now(), suite.chargePoint.Now(), time.Second)
+	_, err := suite.chargePoint.Heartbeat()
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(serverOffset), suite.chargePoint.Now(), 2*time.Second)
+	// Missing timestamps are filled with the corrected time
+	_, err = suite.chargePoint.StatusNotification(1, core.NoError, core.ChargePointStatusAvailable)
+	require.NoError(t, err)
+	timestamp := <-timestampC
+	require.NotNil(t, timestamp)
+	assert.WithinDuration(t, time.Now().Add(serverOffset), timestamp.Time, 2*time.Second)
+	// Explicit timestamps are left untouched
+	explicit := types.NewDateTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	_, err = suite.chargePoint.StatusNotification(1, core.NoError, core.ChargePointStatusAvailable, func(request *core.StatusNotificationRequest) {
+		request.Timestamp = explicit
+	})
+	require.NoError(t, err)
+	timestamp = <-timestampC
+	require.NotNil(t, timestamp)
+	assert.True(t, explicit.Equal(timestamp.Time))
+}
+
+func (suite *OcppV16TestSuite) TestClockSyncOff() {
+	t := suite.T()
+	coreListener := &MockCentralSystemCoreListener{}
+	timestampC := make(chan *types.DateTime, 1)
+	coreListener.On("OnStatusNotification", mock.AnythingOfType("string"), mock.Anything).Return(core.NewStatusNotificationConfirmation(), nil).Run(func(args mock.Arguments) {
+		request := args.Get(1).(*core.StatusNotificationRequest)
+		timestampC <- request.Timestamp
+	})
+	suite.setupClockSyncTest(coreListener, time.Hour)
+	_, err := suite.chargePoint.Heartbeat()
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), suite.chargePoint.Now(), time.Second)
+	_, err = suite.chargePoint.StatusNotification(1, core.NoError, core.ChargePointStatusAvailable)
+	require.NoError(t, err)
+	assert.Nil(t, <-timestampC)
+}
+
+func (suite *OcppV16TestSuite) TestClockSyncCallback() {
+	t := suite.T()
+	serverOffset := -30 * time.Minute
+	coreListener := &MockCentralSystemCoreListener{}
+	authoritativeC := make(chan time.Time, 1)
+	suite.setupClockSyncTest(coreListener, serverOffset)
+	suite.chargePoint.SetClockSyncMode(clocksync.ModeCallback)
+	suite.chargePoint.SetClockSyncHandler(func(authoritativeTime time.Time) {
+		authoritativeC <- authoritativeTime
+	})
+	_, err := suite.chargePoint.Heartbeat()
+	require.NoError(t, err)
+	select {
+	case authoritative := <-authoritativeC:
+		assert.WithinDuration(t, time.Now().Add(serverOffset), authoritative, 2*time.Second)
+	case <-time.After(time.Second):
+		t.Fatal("clock sync handler not invoked")
+	}
+	// The local clock is never corrected in callback mode
+	assert.WithinDuration(t, time.Now(), suite.chargePoint.Now(), time.Second)
+}
+
+// Holds back status notifications until released, while heartbeats are answered right away.
+type blockingClockSyncListener struct {
+	MockCentralSystemCoreListener
+	serverOffset time.Duration
+	enteredC     chan struct{}
+	releaseC     chan struct{}
+}
+
+func (l *blockingClockSyncListener) OnStatusNotification(chargePointId string, request *core.StatusNotificationRequest) (*core.StatusNotificationConfirmation, error) {
+	l.enteredC <- struct{}{}
+	<-l.releaseC
+	return core.NewStatusNotificationConfirmation(), nil
+}
+
+func (l *blockingClockSyncListener) OnHeartbeat(chargePointId string, request *core.HeartbeatRequest) (*core.HeartbeatConfirmation, error) {
+	return core.NewHeartbeatConfirmation(types.NewDateTime(time.Now().Add(l.serverOffset))), nil
+}
+
+func (suite *OcppV16TestSuite) TestClockSyncExcludesQueueTime() {
+	t := suite.T()
+	serverOffset := time.Hour
+	server := wstest.NewServer()
+	centralSystem := ocpp16.NewCentralSystem(ocppj.NewServer(server, nil, nil, core.Profile), server)
+	listener := &blockingClockSyncListener{serverOffset: serverOffset, enteredC: make(chan struct{}, 1), releaseC: make(chan struct{})}
+	centralSystem.SetCoreHandler(listener)
+	fakeClock := clocktest.NewFakeClock(time.Now())
+	dispatcher := ocppj.NewDefaultClientDispatcher(ocppj.NewFIFOClientQueue(0))
+	dispatcher.SetTimeout(10 * time.Hour)
+	wsClient := wstest.NewClient(server)
+	endpoint := ocppj.NewClient("cp1", wsClient, dispatcher, nil, ocpp16.Profiles()...)
+	endpoint.SetClock(fakeClock)
+	chargePoint := ocpp16.NewChargePoint("cp1", endpoint, wsClient)
+	chargePoint.SetClockSyncMode(clocksync.ModeTrackOffset)
+	require.NoError(t, wstest.ConnectInMemory(server, centralSystem, chargePoint))
+	defer centralSystem.Stop()
+	defer chargePoint.Stop()
+	go func() {
+		_, _ = chargePoint.StatusNotification(1, core.NoError, core.ChargePointStatusAvailable)
+	}()
+	<-listener.enteredC
+	// The heartbeat waits in the queue for two hours, until the pending status notification is answered
+	heartbeatC := make(chan error, 1)
+	go func() {
+		_, err := chargePoint.Heartbeat()
+		heartbeatC <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	fakeClock.Advance(2 * time.Hour)
+	close(listener.releaseC)
+	require.NoError(t, <-heartbeatC)
+	// The time spent in the queue is not part of the round trip
+	assert.WithinDuration(t, time.Now().Add(serverOffset), chargePoint.Now(), 2*time.Second)
+}
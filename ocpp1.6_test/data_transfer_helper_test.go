@@ -0,0 +1,112 @@
+package ocpp16_test
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/datatransfer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type NestedData struct {
+	Name  string   `json:"name"`
+	Items []string `json:"items"`
+}
+
+type CustomNestedData struct {
+	Id     int        `json:"id"`
+	Nested NestedData `json:"nested"`
+}
+
+func (suite *OcppV16TestSuite) TestDataTransferHelperFromCentralSystem() {
+	t := suite.T()
+	wsId := "test_id"
+	messageId := defaultMessageId
+	wsUrl := "someUrl"
+	vendorId := "vendor1"
+	dtMessageId := "nested"
+	payload := CustomNestedData{Id: 1, Nested: NestedData{Name: "request", Items: []string{"a", "b"}}}
+	respData := CustomNestedData{Id: 2, Nested: NestedData{Name: "response", Items: []string{"c"}}}
+	status := core.DataTransferStatusAccepted
+	requestJson := fmt.Sprintf(`[2,"%v","%v",{"vendorId":"%v","messageId":"%v","data":{"id":1,"nested":{"name":"request","items":["a","b"]}}}]`, messageId, core.DataTransferFeatureName, vendorId, dtMessageId)
+	responseJson := fmt.Sprintf(`[3,"%v",{"status":"%v","data":{"id":2,"nested":{"name":"response","items":["c"]}}}]`, messageId, status)
+	dataTransferConfirmation := core.NewDataTransferConfirmation(status)
+	dataTransferConfirmation.Data = respData
+	channel := NewMockWebSocket(wsId)
+
+	coreListener := &MockChargePointCoreListener{}
+	coreListener.On("OnDataTransfer", mock.Anything).Return(dataTransferConfirmation, nil)
+	setupDefaultCentralSystemHandlers(suite, nil, expectedCentralSystemOptions{clientId: wsId, rawWrittenMessage: []byte(requestJson), forwardWrittenMessage: true})
+	setupDefaultChargePointHandlers(suite, coreListener, expectedChargePointOptions{serverUrl: wsUrl, clientId: wsId, createChannelOnStart: true, channel: channel, rawWrittenMessage: []byte(responseJson), forwardWrittenMessage: true})
+	// Run Test
+	suite.centralSystem.Start(8887, "somePath")
+	err := suite.chargePoint.Start(wsUrl)
+	require.Nil(t, err)
+	resultStatus, resp, err := datatransfer.Send[CustomNestedData, CustomNestedData](suite.centralSystem, wsId, vendorId, dtMessageId, payload)
+	require.Nil(t, err)
+	assert.Equal(t, status, resultStatus)
+	require.NotNil(t, resp)
+	assert.Equal(t, respData, *resp)
+}
+
+func (suite *OcppV16TestSuite) TestDataTransferHelperFromChargePoint() {
+	t := suite.T()
+	wsId := "test_id"
+	messageId := defaultMessageId
+	wsUrl := "someUrl"
+	vendorId := "vendor1"
+	dtMessageId := "nested"
+	payload := CustomNestedData{Id: 1, Nested: NestedData{Name: "request", Items: []string{"a", "b"}}}
+	status := core.DataTransferStatusAccepted
+	requestJson := fmt.Sprintf(`[2,"%v","%v",{"vendorId":"%v","messageId":"%v","data":{"id":1,"nested":{"name":"request","items":["a","b"]}}}]`, messageId, core.DataTransferFeatureName, vendorId, dtMessageId)
+	responseJson := fmt.Sprintf(`[3,"%v",{"status":"%v"}]`, messageId, status)
+	dataTransferConfirmation := core.NewDataTransferConfirmation(status)
+	channel := NewMockWebSocket(wsId)
+
+	coreListener := &MockCentralSystemCoreListener{}
+	coreListener.On("OnDataTransfer", mock.AnythingOfType("string"), mock.Anything).Return(dataTransferConfirmation, nil)
+	setupDefaultCentralSystemHandlers(suite, coreListener, expectedCentralSystemOptions{clientId: wsId, rawWrittenMessage: []byte(responseJson), forwardWrittenMessage: true})
+	setupDefaultChargePointHandlers(suite, nil, expectedChargePointOptions{serverUrl: wsUrl, clientId: wsId, createChannelOnStart: true, channel: channel, rawWrittenMessage: []byte(requestJson), forwardWrittenMessage: true})
+	// Run Test
+	suite.centralSystem.Start(8887, "somePath")
+	err := suite.chargePoint.Start(wsUrl)
+	require.Nil(t, err)
+	// Response contains no data
+	resultStatus, resp, err := datatransfer.SendFromChargePoint[CustomNestedData, CustomNestedData](suite.chargePoint, vendorId, dtMessageId, payload)
+	require.Nil(t, err)
+	assert.Equal(t, status, resultStatus)
+	assert.Nil(t, resp)
+}
+
+func (suite *OcppV16TestSuite) TestDataTransferHelperRejected() {
+	t := suite.T()
+	wsId := "test_id"
+	messageId := defaultMessageId
+	wsUrl := "someUrl"
+	vendorId := "vendor1"
+	dtMessageId := "nested"
+	payload := CustomNestedData{Id: 1}
+	status := core.DataTransferStatusUnknownVendorId
+	responseJson := fmt.Sprintf(`[3,"%v",{"status":"%v"}]`, messageId, status)
+	dataTransferConfirmation := core.NewDataTransferConfirmation(status)
+	channel := NewMockWebSocket(wsId)
+
+	coreListener := &MockChargePointCoreListener{}
+	coreListener.On("OnDataTransfer", mock.Anything).Return(dataTransferConfirmation, nil)
+	setupDefaultCentralSystemHandlers(suite, nil, expectedCentralSystemOptions{clientId: wsId, forwardWrittenMessage: true})
+	setupDefaultChargePointHandlers(suite, coreListener, expectedChargePointOptions{serverUrl: wsUrl, clientId: wsId, createChannelOnStart: true, channel: channel, rawWrittenMessage: []byte(responseJson), forwardWrittenMessage: true})
+	// Run Test
+	suite.centralSystem.Start(8887, "somePath")
+	err := suite.chargePoint.Start(wsUrl)
+	require.Nil(t, err)
+	resultStatus, resp, err := datatransfer.Send[CustomNestedData, CustomNestedData](suite.centralSystem, wsId, vendorId, dtMessageId, payload)
+	require.Error(t, err)
+	var statusErr *datatransfer.StatusError
+	require.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, status, statusErr.Status)
+	assert.Equal(t, status, resultStatus)
+	assert.Nil(t, resp)
+}
@@ -0,0 +1,92 @@
+package ocpp16_test
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	ocpp16 "github.com/lorenzodonini/ocpp-go/ocpp1.6"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/firmware"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/statetracker"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/lorenzodonini/ocpp-go/ws/wstest"
+)
+
+func (suite *OcppV16TestSuite) TestDefaultHandlers() {
+	t := suite.T()
+	// Without default handlers, requests of profiles without a handler are not supported
+	centralSystem, chargePoint, server := newInMemoryPair("cp1")
+	require.NoError(t, wstest.ConnectInMemory(server, centralSystem, chargePoint))
+	_, err := chargePoint.Heartbeat()
+	var ocppErr *ocpp.Error
+	require.ErrorAs(t, err, &ocppErr)
+	assert.Equal(t, ocppj.NotSupported, ocppErr.Code)
+	chargePoint.Stop()
+	centralSystem.Stop()
+	// Heartbeat and StatusNotification are answered by default
+	centralSystem, chargePoint, server = newInMemoryPair("cp1")
+	centralSystem.UseDefaultHandlers(ocpp16.DefaultHeartbeat | ocpp16.DefaultStatusNotification)
+	tracker := statetracker.NewStateTracker()
+	centralSystem.SetDefaultHandlerObserver(tracker)
+	auditC := make(chan ocppj.AuditEntry, 10)
+	centralSystem.SetAuditHandler(func(entry ocppj.AuditEntry) {
+		auditC <- entry
+	})
+	require.NoError(t, wstest.ConnectInMemory(server, centralSystem, chargePoint))
+	defer centralSystem.Stop()
+	defer chargePoint.Stop()
+	before := time.Now()
+	confirmation, err := chargePoint.Heartbeat()
+	require.NoError(t, err)
+	require.NotNil(t, confirmation.CurrentTime)
+	assert.WithinDuration(t, before, confirmation.CurrentTime.Time, time.Second)
+	entry := <-auditC
+	assert.Equal(t, core.HeartbeatFeatureName, entry.Action)
+	assert.IsType(t, &core.HeartbeatConfirmation{}, entry.Response)
+	_, err = chargePoint.StatusNotification(1, core.NoError, core.ChargePointStatusCharging)
+	require.NoError(t, err)
+	status, err := tracker.ConnectorStatus("cp1", 1)
+	require.NoError(t, err)
+	assert.Equal(t, core.ChargePointStatusCharging, status.Status)
+	// Actions without an enabled default handler are still not supported
+	_, err = chargePoint.FirmwareStatusNotification(firmware.FirmwareStatusInstalled)
+	require.ErrorAs(t, err, &ocppErr)
+	assert.Equal(t, ocppj.NotSupported, ocppErr.Code)
+	_, err = chargePoint.Authorize("tag1")
+	require.ErrorAs(t, err, &ocppErr)
+	assert.Equal(t, ocppj.NotSupported, ocppErr.Code)
+}
+
+func (suite *OcppV16TestSuite) TestDefaultHandlersPrecedence() {
+	t := suite.T()
+	centralSystem, chargePoint, server := newInMemoryPair("cp1")
+	centralSystem.UseDefaultHandlers(ocpp16.DefaultHeartbeat)
+	observer := &MockRequestObserver{}
+	centralSystem.SetDefaultHandlerObserver(observer)
+	handlerTime := types.NewDateTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	coreListener := &MockCentralSystemCoreListener{}
+	coreListener.On("OnHeartbeat", "cp1", mock.Anything).Return(core.NewHeartbeatConfirmation(handlerTime), nil)
+	centralSystem.SetCoreHandler(coreListener)
+	require.NoError(t, wstest.ConnectInMemory(server, centralSystem, chargePoint))
+	defer centralSystem.Stop()
+	defer chargePoint.Stop()
+	// The explicit handler answers the request
+	confirmation, err := chargePoint.Heartbeat()
+	require.NoError(t, err)
+	assert.True(t, handlerTime.Equal(confirmation.CurrentTime.Time))
+	coreListener.AssertExpectations(t)
+	observer.AssertNotCalled(t, "ObserveRequest", mock.Anything, mock.Anything)
+}
+
+type MockRequestObserver struct {
+	mock.Mock
+}
+
+func (o *MockRequestObserver) ObserveRequest(chargePointID string, request ocpp.Request) {
+	o.Called(chargePointID, request)
+}
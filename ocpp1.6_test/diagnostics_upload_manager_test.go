@@ -0,0 +1,216 @@
+package ocpp16_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/clock/clocktest"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/firmware"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/remotetrigger"
+)
+
+// Blocks every upload until a result is passed on its channel, or the upload is canceled.
+type blockingDiagnosticsUploader struct {
+	startedC chan string
+	resultC  chan error
+}
+
+func (u *blockingDiagnosticsUploader) Upload(ctx context.Context, location *url.URL, filename string, content []byte) error {
+	u.startedC <- location.Path
+	select {
+	case err := <-u.resultC:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type diagnosticsStatusRecorder struct {
+	statusC chan firmware.DiagnosticsStatus
+	changes []firmware.DiagnosticsStatus
+	mutex   sync.Mutex
+}
+
+func (r *diagnosticsStatusRecorder) onStatusChange(status firmware.DiagnosticsStatus) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.changes = append(r.changes, status)
+}
+
+func (r *diagnosticsStatusRecorder) next(t require.TestingT) firmware.DiagnosticsStatus {
+	select {
+	case status := <-r.statusC:
+		return status
+	case <-time.After(2 * time.Second):
+		require.Fail(t, "diagnostics status not received")
+		return ""
+	}
+}
+
+func newTestDiagnosticsUploadManager() (*firmware.DiagnosticsUploadManager, *blockingDiagnosticsUploader, *diagnosticsStatusRecorder) {
+	recorder := &diagnosticsStatusRecorder{statusC: make(chan firmware.DiagnosticsStatus, 10)}
+	manager := firmware.NewDiagnosticsUploadManager(func(startTime *time.Time, stopTime *time.Time) (string, []byte, error) {
+		return "diagnostics.txt", []byte("diagnostics"), nil
+	}, func(request *firmware.DiagnosticsStatusNotificationRequest) error {
+		recorder.statusC <- request.Status
+		return nil
+	})
+	uploader := &blockingDiagnosticsUploader{startedC: make(chan string, 10), resultC: make(chan error)}
+	manager.Uploaders["ftp"] = uploader
+	manager.OnStatusChange = recorder.onStatusChange
+	return manager, uploader, recorder
+}
+
+func (suite *OcppV16TestSuite) TestDiagnosticsUploadTriggeredStatus() {
+	t := suite.T()
+	manager, uploader, recorder := newTestDiagnosticsUploadManager()
+	responder := remotetrigger.NewAutoResponder16(2)
+	responder.UseDiagnosticsUploadManager(manager)
+	trigger := func() {
+		confirmation, err := responder.OnTriggerMessage(remotetrigger.NewTriggerMessageRequest(firmware.DiagnosticsStatusNotificationFeatureName))
+		require.NoError(t, err)
+		require.Equal(t, remotetrigger.TriggerMessageStatusAccepted, confirmation.Status)
+	}
+	// No upload was ever performed
+	assert.Equal(t, firmware.DiagnosticsStatusIdle, manager.CurrentDiagnosticsStatus())
+	trigger()
+	assert.Equal(t, firmware.DiagnosticsStatusIdle, recorder.next(t))
+	// Uploading
+	request := firmware.NewGetDiagnosticsRequest("ftp://example.com/first")
+	request.Retries = newInt(1)
+	request.RetryInterval = newInt(0)
+	confirmation, err := manager.OnGetDiagnostics(request)
+	require.NoError(t, err)
+	assert.Equal(t, "diagnostics.txt", confirmation.FileName)
+	assert.Equal(t, firmware.DiagnosticsStatusUploading, recorder.next(t))
+	assert.Equal(t, "/first", <-uploader.startedC)
+	trigger()
+	assert.Equal(t, firmware.DiagnosticsStatusUploading, recorder.next(t))
+	assert.Equal(t, firmware.DiagnosticsStatusUploading, manager.CurrentDiagnosticsStatus())
+	// A retry doesn't report Uploading again
+	uploader.resultC <- errors.New("connection refused")
+	assert.Equal(t, "/first", <-uploader.startedC)
+	uploader.resultC <- nil
+	assert.Equal(t, firmware.DiagnosticsStatusUploaded, recorder.next(t))
+	assert.Equal(t, firmware.DiagnosticsStatusUploaded, manager.CurrentDiagnosticsStatus())
+	// Once the upload is over, Idle is reported on demand
+	trigger()
+	assert.Equal(t, firmware.DiagnosticsStatusIdle, recorder.next(t))
+	trigger()
+	assert.Equal(t, firmware.DiagnosticsStatusIdle, recorder.next(t))
+	assert.Equal(t, firmware.DiagnosticsStatusIdle, manager.CurrentDiagnosticsStatus())
+	// A superseded upload doesn't report Uploading twice
+	_, err = manager.OnGetDiagnostics(firmware.NewGetDiagnosticsRequest("ftp://example.com/second"))
+	require.NoError(t, err)
+	assert.Equal(t, firmware.DiagnosticsStatusUploading, recorder.next(t))
+	assert.Equal(t, "/second", <-uploader.startedC)
+	_, err = manager.OnGetDiagnostics(firmware.NewGetDiagnosticsRequest("ftp://example.com/third"))
+	require.NoError(t, err)
+	assert.Equal(t, "/third", <-uploader.startedC)
+	uploader.resultC <- errors.New("connection refused")
+	assert.Equal(t, firmware.DiagnosticsStatusUploadFailed, recorder.next(t))
+	assert.Empty(t, recorder.statusC)
+	// The application is notified of changes only
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	assert.Equal(t, []firmware.DiagnosticsStatus{
+		firmware.DiagnosticsStatusUploading,
+		firmware.DiagnosticsStatusUploaded,
+		firmware.DiagnosticsStatusIdle,
+		firmware.DiagnosticsStatusUploading,
+		firmware.DiagnosticsStatusUploadFailed,
+	}, recorder.changes)
+}
+
+func (suite *OcppV16TestSuite) TestDiagnosticsUploadRetryInterval() {
+	t := suite.T()
+	fakeClock := clocktest.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	manager, uploader, recorder := newTestDiagnosticsUploadManager()
+	manager.SetClock(fakeClock)
+	request := firmware.NewGetDiagnosticsRequest("ftp://example.com/diagnostics")
+	request.Retries = newInt(1)
+	request.RetryInterval = newInt(60)
+	_, err := manager.OnGetDiagnostics(request)
+	require.NoError(t, err)
+	assert.Equal(t, firmware.DiagnosticsStatusUploading, recorder.next(t))
+	<-uploader.startedC
+	uploader.resultC <- errors.New("connection refused")
+	// The retry is only attempted once the interval elapsed
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(59 * time.Second)
+	select {
+	case <-uploader.startedC:
+		require.Fail(t, "upload retried before the retry interval elapsed")
+	case <-time.After(50 * time.Millisecond):
+	}
+	fakeClock.Advance(time.Second)
+	<-uploader.startedC
+	uploader.resultC <- nil
+	assert.Equal(t, firmware.DiagnosticsStatusUploaded, recorder.next(t))
+}
+
+func (suite *OcppV16TestSuite) TestDiagnosticsUploadRejected() {
+	t := suite.T()
+	manager, _, recorder := newTestDiagnosticsUploadManager()
+	// Unsupported scheme
+	confirmation, err := manager.OnGetDiagnostics(firmware.NewGetDiagnosticsRequest("sftp://example.com/diagnostics"))
+	require.NoError(t, err)
+	assert.Empty(t, confirmation.FileName)
+	// No diagnostics available
+	manager.Collect = func(startTime *time.Time, stopTime *time.Time) (string, []byte, error) {
+		return "", nil, nil
+	}
+	confirmation, err = manager.OnGetDiagnostics(firmware.NewGetDiagnosticsRequest("ftp://example.com/diagnostics"))
+	require.NoError(t, err)
+	assert.Empty(t, confirmation.FileName)
+	assert.Empty(t, recorder.statusC)
+	assert.Equal(t, firmware.DiagnosticsStatusIdle, manager.CurrentDiagnosticsStatus())
+}
+
+func (suite *OcppV16TestSuite) TestAutoResponder16() {
+	t := suite.T()
+	responder := remotetrigger.NewAutoResponder16(2)
+	producedC := make(chan *int, 1)
+	responder.Register(remotetrigger.MessageTrigger("StatusNotification"), func(connectorId *int) error {
+		producedC <- connectorId
+		return nil
+	})
+	request := remotetrigger.NewTriggerMessageRequest("StatusNotification")
+	request.ConnectorId = newInt(2)
+	confirmation, err := responder.OnTriggerMessage(request)
+	require.NoError(t, err)
+	assert.Equal(t, remotetrigger.TriggerMessageStatusAccepted, confirmation.Status)
+	assert.Equal(t, newInt(2), <-producedC)
+	// Unknown connector
+	request.ConnectorId = newInt(3)
+	confirmation, err = responder.OnTriggerMessage(request)
+	require.NoError(t, err)
+	assert.Equal(t, remotetrigger.TriggerMessageStatusRejected, confirmation.Status)
+	// Not registered
+	responder.Unregister("StatusNotification")
+	confirmation, err = responder.OnTriggerMessage(request)
+	require.NoError(t, err)
+	assert.Equal(t, remotetrigger.TriggerMessageStatusNotImplemented, confirmation.Status)
+	// Failures are reported
+	errC := make(chan error, 1)
+	responder.OnSendError = func(trigger remotetrigger.MessageTrigger, err error) {
+		assert.Equal(t, remotetrigger.MessageTrigger("Heartbeat"), trigger)
+		errC <- err
+	}
+	responder.Register("Heartbeat", func(connectorId *int) error {
+		assert.Nil(t, connectorId)
+		return errors.New("not connected")
+	})
+	heartbeat := remotetrigger.NewTriggerMessageRequest("Heartbeat")
+	heartbeat.ConnectorId = newInt(1)
+	confirmation, err = responder.OnTriggerMessage(heartbeat)
+	require.NoError(t, err)
+	assert.Equal(t, remotetrigger.TriggerMessageStatusAccepted, confirmation.Status)
+	assert.EqualError(t, <-errC, "not connected")
+}
@@ -0,0 +1,88 @@
+package ocpp16_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/clock/clocktest"
+	"github.com/lorenzodonini/ocpp-go/events"
+	ocpp16 "github.com/lorenzodonini/ocpp-go/ocpp1.6"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/lorenzodonini/ocpp-go/ws/wstest"
+)
+
+func receiveEvents(t require.TestingT, eventC <-chan events.Event, n int) []events.Event {
+	received := make([]events.Event, 0, n)
+	for len(received) < n {
+		select {
+		case event := <-eventC:
+			received = append(received, event)
+		case <-time.After(time.Second):
+			require.Fail(t, "event wasn't published", "received %v of %v events: %v", len(received), n, received)
+		}
+	}
+	return received
+}
+
+func (suite *OcppV16TestSuite) TestEventBus() {
+	t := suite.T()
+	server := wstest.NewServer()
+	fakeClock := clocktest.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	endpoint := ocppj.NewServer(server, nil, nil, core.Profile)
+	endpoint.SetClock(fakeClock)
+	centralSystem := ocpp16.NewCentralSystem(endpoint, server)
+	chargePoint := ocpp16.NewChargePoint("cp1", nil, wstest.NewClient(server))
+	centralSystemBus := events.NewBus(10)
+	centralSystem.SetEventBus(centralSystemBus)
+	chargePointBus := events.NewBus(10)
+	chargePoint.SetEventBus(chargePointBus)
+	eventC, unsubscribe := centralSystemBus.Subscribe(events.ConnectionUp, events.ConnectionDown, events.RequestTimedOut)
+	defer unsubscribe()
+	chargePointEventC, unsubscribeChargePoint := chargePointBus.Subscribe()
+	defer unsubscribeChargePoint()
+	// Connect
+	require.NoError(t, wstest.ConnectInMemory(server, centralSystem, chargePoint))
+	defer centralSystem.Stop()
+	defer chargePoint.Stop()
+	// Request: the charge point never receives it, so the request times out
+	conn, ok := server.Connection("cp1")
+	require.True(t, ok)
+	conn.SetConditions(wstest.Conditions{Drop: func(data []byte) bool { return true }})
+	resultC := make(chan error, 1)
+	waiters := fakeClock.Waiters()
+	err := centralSystem.Reset("cp1", func(confirmation *core.ResetConfirmation, err error) {
+		resultC <- err
+	}, core.ResetTypeSoft)
+	require.NoError(t, err)
+	fakeClock.BlockUntil(waiters + 1)
+	fakeClock.Advance(30 * time.Second)
+	var timeoutErr *ocppj.TimeoutError
+	select {
+	case err = <-resultC:
+		require.True(t, errors.As(err, &timeoutErr))
+	case <-time.After(time.Second):
+		require.Fail(t, "request didn't time out")
+	}
+	// Connection lost: the charge point reconnects
+	conn.SetConditions(wstest.Conditions{})
+	reason := errors.New("connection lost")
+	require.NoError(t, server.Disconnect("cp1", reason))
+	assert.Equal(t, []events.Event{
+		events.ConnectionUpEvent{Peer: "cp1"},
+		events.RequestTimedOutEvent{Peer: "cp1", RequestID: timeoutErr.UniqueID, Action: core.ResetFeatureName},
+		events.ConnectionDownEvent{Peer: "cp1"},
+		events.ConnectionUpEvent{Peer: "cp1"},
+	}, receiveEvents(t, eventC, 4))
+	assert.Equal(t, []events.Event{
+		events.ConnectionUpEvent{},
+		events.ConnectionDownEvent{Err: reason},
+		events.ConnectionUpEvent{},
+	}, receiveEvents(t, chargePointEventC, 3))
+	assert.Len(t, eventC, 0)
+	assert.Len(t, chargePointEventC, 0)
+	assert.Equal(t, uint64(0), centralSystemBus.Dropped(eventC))
+}
@@ -65,3 +65,84 @@ func (suite *OcppV16TestSuite) TestFirmwareStatusNotificationInvalidEndpoint() {
 	requestJson := fmt.Sprintf(`[2,"%v","%v",{"status":"%v"}]`, messageId, firmware.FirmwareStatusNotificationFeatureName, status)
 	testUnsupportedRequestFromCentralSystem(suite, firmwareStatusRequest, requestJson, messageId)
 }
+
+func (suite *OcppV16TestSuite) TestFirmwareStatusTransitionMatrix() {
+	t := suite.T()
+	statuses := []firmware.FirmwareStatus{
+		firmware.FirmwareStatusIdle,
+		firmware.FirmwareStatusDownloading,
+		firmware.FirmwareStatusDownloaded,
+		firmware.FirmwareStatusDownloadFailed,
+		firmware.FirmwareStatusInstalling,
+		firmware.FirmwareStatusInstalled,
+		firmware.FirmwareStatusInstallationFailed,
+	}
+	legal := map[firmware.FirmwareStatus]map[firmware.FirmwareStatus]bool{
+		firmware.FirmwareStatusIdle:               {firmware.FirmwareStatusIdle: true, firmware.FirmwareStatusDownloading: true},
+		firmware.FirmwareStatusDownloading:        {firmware.FirmwareStatusDownloading: true, firmware.FirmwareStatusDownloaded: true, firmware.FirmwareStatusDownloadFailed: true},
+		firmware.FirmwareStatusDownloaded:         {firmware.FirmwareStatusDownloaded: true, firmware.FirmwareStatusInstalling: true},
+		firmware.FirmwareStatusDownloadFailed:     {firmware.FirmwareStatusIdle: true, firmware.FirmwareStatusDownloading: true},
+		firmware.FirmwareStatusInstalling:         {firmware.FirmwareStatusInstalling: true, firmware.FirmwareStatusInstalled: true, firmware.FirmwareStatusInstallationFailed: true},
+		firmware.FirmwareStatusInstalled:          {firmware.FirmwareStatusIdle: true},
+		firmware.FirmwareStatusInstallationFailed: {firmware.FirmwareStatusIdle: true},
+	}
+	for _, from := range statuses {
+		for _, to := range statuses {
+			expected := legal[from][to]
+			assert.Equal(t, expected, firmware.IsLegalFirmwareTransition(from, to), "%v -> %v", from, to)
+			// Validator reaches the from state through a legal path, then reports the to state
+			validator := firmware.NewStatusSequenceValidator()
+			validator.OnUpdateFirmware("cp1")
+			for _, status := range firmwarePathTo(from) {
+				require.Nil(t, validator.Validate("cp1", firmware.NewFirmwareStatusNotificationRequest(status)))
+			}
+			warning := validator.Validate("cp1", firmware.NewFirmwareStatusNotificationRequest(to))
+			if expected {
+				assert.Nil(t, warning, "%v -> %v", from, to)
+			} else if assert.NotNil(t, warning, "%v -> %v", from, to) {
+				assert.Equal(t, "cp1", warning.ChargePointId)
+				assert.Equal(t, from, warning.From)
+				assert.Equal(t, to, warning.To)
+			}
+			// The reported status is recorded regardless
+			assert.Equal(t, to, validator.Status("cp1"))
+		}
+	}
+}
+
+func firmwarePathTo(status firmware.FirmwareStatus) []firmware.FirmwareStatus {
+	switch status {
+	case firmware.FirmwareStatusDownloading:
+		return []firmware.FirmwareStatus{firmware.FirmwareStatusDownloading}
+	case firmware.FirmwareStatusDownloaded:
+		return []firmware.FirmwareStatus{firmware.FirmwareStatusDownloading, firmware.FirmwareStatusDownloaded}
+	case firmware.FirmwareStatusDownloadFailed:
+		return []firmware.FirmwareStatus{firmware.FirmwareStatusDownloading, firmware.FirmwareStatusDownloadFailed}
+	case firmware.FirmwareStatusInstalling:
+		return []firmware.FirmwareStatus{firmware.FirmwareStatusDownloading, firmware.FirmwareStatusDownloaded, firmware.FirmwareStatusInstalling}
+	case firmware.FirmwareStatusInstalled:
+		return []firmware.FirmwareStatus{firmware.FirmwareStatusDownloading, firmware.FirmwareStatusDownloaded, firmware.FirmwareStatusInstalling, firmware.FirmwareStatusInstalled}
+	case firmware.FirmwareStatusInstallationFailed:
+		return []firmware.FirmwareStatus{firmware.FirmwareStatusDownloading, firmware.FirmwareStatusDownloaded, firmware.FirmwareStatusInstalling, firmware.FirmwareStatusInstallationFailed}
+	default:
+		return nil
+	}
+}
+
+func (suite *OcppV16TestSuite) TestFirmwareStatusSequenceValidatorReset() {
+	t := suite.T()
+	validator := firmware.NewStatusSequenceValidator()
+	// Unsolicited statuses are checked against Idle
+	warning := validator.Validate("cp1", firmware.NewFirmwareStatusNotificationRequest(firmware.FirmwareStatusInstalled))
+	require.NotNil(t, warning)
+	assert.Equal(t, firmware.FirmwareStatusIdle, warning.From)
+	// Repeated terminal state
+	assert.NotNil(t, validator.Validate("cp1", firmware.NewFirmwareStatusNotificationRequest(firmware.FirmwareStatusInstalled)))
+	// Charge points are tracked separately
+	assert.Nil(t, validator.Validate("cp2", firmware.NewFirmwareStatusNotificationRequest(firmware.FirmwareStatusDownloading)))
+	// A new update resets the sequence
+	validator.OnUpdateFirmware("cp1")
+	assert.Equal(t, firmware.FirmwareStatusIdle, validator.Status("cp1"))
+	assert.Nil(t, validator.Validate("cp1", firmware.NewFirmwareStatusNotificationRequest(firmware.FirmwareStatusDownloading)))
+	assert.Equal(t, firmware.FirmwareStatusDownloading, validator.Status("cp2"))
+}
@@ -0,0 +1,124 @@
+package ocpp16_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	ocpp16 "github.com/lorenzodonini/ocpp-go/ocpp1.6"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/lorenzodonini/ocpp-go/ws/wstest"
+)
+
+// Connects a charge point to a central system over the in-memory transport.
+func newInMemoryPair(chargePointID string) (ocpp16.CentralSystem, ocpp16.ChargePoint, *wstest.Server) {
+	server := wstest.NewServer()
+	centralSystem := ocpp16.NewCentralSystem(nil, server)
+	chargePoint := ocpp16.NewChargePoint(chargePointID, nil, wstest.NewClient(server))
+	return centralSystem, chargePoint, server
+}
+
+func receivePanicError(errC <-chan error) *ocppj.HandlerPanicError {
+	select {
+	case err := <-errC:
+		var panicErr *ocppj.HandlerPanicError
+		if errors.As(err, &panicErr) {
+			return panicErr
+		}
+	case <-time.After(time.Second):
+	}
+	return nil
+}
+
+func (suite *OcppV16TestSuite) TestRequestHandlerPanic() {
+	t := suite.T()
+	centralSystem, chargePoint, server := newInMemoryPair("cp1")
+	coreListener := &MockCentralSystemCoreListener{}
+	coreListener.On("OnHeartbeat", "cp1", mock.Anything).Return(nil, nil).Run(func(args mock.Arguments) {
+		panic("handler failure")
+	}).Once()
+	coreListener.On("OnHeartbeat", "cp1", mock.Anything).Return(core.NewHeartbeatConfirmation(types.NewDateTime(time.Now())), nil)
+	centralSystem.SetCoreHandler(coreListener)
+	errC := centralSystem.Errors()
+	require.NoError(t, wstest.ConnectInMemory(server, centralSystem, chargePoint))
+	defer centralSystem.Stop()
+	defer chargePoint.Stop()
+	// The charge point receives an InternalError instead of waiting for a response
+	_, err := chargePoint.Heartbeat()
+	require.Error(t, err)
+	var ocppErr *ocpp.Error
+	require.ErrorAs(t, err, &ocppErr)
+	assert.Equal(t, ocppj.InternalError, ocppErr.Code)
+	panicErr := receivePanicError(errC)
+	require.NotNil(t, panicErr)
+	assert.Equal(t, "cp1", panicErr.ClientID)
+	assert.Equal(t, core.HeartbeatFeatureName, panicErr.Action)
+	assert.Equal(t, "handler failure", panicErr.Value)
+	assert.Contains(t, string(panicErr.Stack), "TestRequestHandlerPanic")
+	// The connection survives
+	confirmation, err := chargePoint.Heartbeat()
+	require.NoError(t, err)
+	assert.NotNil(t, confirmation)
+}
+
+func (suite *OcppV16TestSuite) TestResultCallbackPanic() {
+	t := suite.T()
+	centralSystem, chargePoint, server := newInMemoryPair("cp1")
+	coreListener := &MockChargePointCoreListener{}
+	coreListener.On("OnChangeAvailability", mock.Anything).Return(core.NewChangeAvailabilityConfirmation(core.AvailabilityStatusAccepted), nil)
+	chargePoint.SetCoreHandler(coreListener)
+	errC := centralSystem.Errors()
+	require.NoError(t, wstest.ConnectInMemory(server, centralSystem, chargePoint))
+	defer centralSystem.Stop()
+	defer chargePoint.Stop()
+	err := centralSystem.ChangeAvailability("cp1", func(confirmation *core.ChangeAvailabilityConfirmation, err error) {
+		panic(errors.New("callback failure"))
+	}, 1, core.AvailabilityTypeInoperative)
+	require.NoError(t, err)
+	panicErr := receivePanicError(errC)
+	require.NotNil(t, panicErr)
+	assert.Equal(t, core.ChangeAvailabilityFeatureName, panicErr.Action)
+	assert.EqualError(t, errors.Unwrap(panicErr), "callback failure")
+	// The connection survives
+	resultC := make(chan *core.ChangeAvailabilityConfirmation, 1)
+	err = centralSystem.ChangeAvailability("cp1", func(confirmation *core.ChangeAvailabilityConfirmation, err error) {
+		resultC <- confirmation
+	}, 1, core.AvailabilityTypeOperative)
+	require.NoError(t, err)
+	select {
+	case confirmation := <-resultC:
+		require.NotNil(t, confirmation)
+		assert.Equal(t, core.AvailabilityStatusAccepted, confirmation.Status)
+	case <-time.After(time.Second):
+		t.Fatal("no confirmation received")
+	}
+}
+
+func (suite *OcppV16TestSuite) TestConnectionHandlerPanic() {
+	t := suite.T()
+	centralSystem, chargePoint, server := newInMemoryPair("cp1")
+	centralSystem.SetNewChargePointHandler(func(chargePoint ocpp16.ChargePointConnection) {
+		panic("connection handler failure")
+	})
+	coreListener := &MockCentralSystemCoreListener{}
+	coreListener.On("OnHeartbeat", "cp1", mock.Anything).Return(core.NewHeartbeatConfirmation(types.NewDateTime(time.Now())), nil)
+	centralSystem.SetCoreHandler(coreListener)
+	errC := centralSystem.Errors()
+	require.NoError(t, wstest.ConnectInMemory(server, centralSystem, chargePoint))
+	defer centralSystem.Stop()
+	defer chargePoint.Stop()
+	panicErr := receivePanicError(errC)
+	require.NotNil(t, panicErr)
+	assert.Equal(t, "cp1", panicErr.ClientID)
+	assert.Empty(t, panicErr.Action)
+	// The connection survives
+	_, err := chargePoint.Heartbeat()
+	require.NoError(t, err)
+	assert.True(t, chargePoint.IsConnected())
+}
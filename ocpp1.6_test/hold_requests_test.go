@@ -0,0 +1,58 @@
+package ocpp16_test
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/lorenzodonini/ocpp-go/ws/wstest"
+)
+
+func (suite *OcppV16TestSuite) TestHoldRequestsUntilHandlersReady() {
+	t := suite.T()
+	centralSystem, chargePoint, server := newInMemoryPair("cp1")
+	centralSystem.HoldRequestsUntilHandlersReady(time.Minute, 10)
+	require.NoError(t, wstest.ConnectInMemory(server, centralSystem, chargePoint))
+	defer centralSystem.Stop()
+	defer chargePoint.Stop()
+	resultC := make(chan asyncResult, 1)
+	err := chargePoint.SendRequestAsync(core.NewHeartbeatRequest(), func(response ocpp.Response, err error) {
+		resultC <- asyncResult{response, err}
+	})
+	require.NoError(t, err)
+	select {
+	case <-resultC:
+		require.Fail(t, "request was answered before handlers were ready")
+	case <-time.After(50 * time.Millisecond):
+	}
+	// The handler is registered late
+	coreListener := &MockCentralSystemCoreListener{}
+	coreListener.On("OnHeartbeat", "cp1", mock.Anything).Return(core.NewHeartbeatConfirmation(types.NewDateTime(time.Now())), nil)
+	centralSystem.SetCoreHandler(coreListener)
+	centralSystem.HandlersReady()
+	result := <-resultC
+	require.NoError(t, result.err)
+	assert.IsType(t, &core.HeartbeatConfirmation{}, result.response)
+}
+
+func (suite *OcppV16TestSuite) TestHoldRequestsExpired() {
+	t := suite.T()
+	centralSystem, chargePoint, server := newInMemoryPair("cp1")
+	centralSystem.HoldRequestsUntilHandlersReady(50*time.Millisecond, 10)
+	require.NoError(t, wstest.ConnectInMemory(server, centralSystem, chargePoint))
+	defer centralSystem.Stop()
+	defer chargePoint.Stop()
+	// Without handlers, the request is answered with the usual error once the hold expired
+	start := time.Now()
+	_, err := chargePoint.Heartbeat()
+	var ocppErr *ocpp.Error
+	require.ErrorAs(t, err, &ocppErr)
+	assert.Equal(t, ocppj.NotSupported, ocppErr.Code)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+}
@@ -0,0 +1,103 @@
+package ocpp16_test
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	ocpp16 "github.com/lorenzodonini/ocpp-go/ocpp1.6"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/lorenzodonini/ocpp-go/ws/wstest"
+)
+
+func inventoryEntry(inventory []ocppj.EndpointInfo, id string) (ocppj.EndpointInfo, bool) {
+	for _, info := range inventory {
+		if info.ID == id {
+			return info, true
+		}
+	}
+	return ocppj.EndpointInfo{}, false
+}
+
+func (suite *OcppV16TestSuite) TestInventory() {
+	t := suite.T()
+	server := wstest.NewServer()
+	centralSystem := ocpp16.NewCentralSystem(nil, server)
+	// cp1 reports its feature profiles, cp2 doesn't know the key
+	profiles := "Core, SmartCharging,RemoteTrigger"
+	interval := "60"
+	listener1 := &MockChargePointCoreListener{}
+	listener1.On("OnGetConfiguration", mock.Anything).Return(core.NewGetConfigurationConfirmation([]core.ConfigurationKey{
+		{Key: "HeartbeatInterval", Value: &interval},
+		{Key: "SupportedFeatureProfiles", Readonly: true, Value: &profiles},
+	}), nil)
+	chargePoint1 := ocpp16.NewChargePoint("cp1", nil, wstest.NewClient(server))
+	chargePoint1.SetCoreHandler(listener1)
+	listener2 := &MockChargePointCoreListener{}
+	confirmation2 := core.NewGetConfigurationConfirmation(nil)
+	confirmation2.UnknownKey = []string{"SupportedFeatureProfiles"}
+	listener2.On("OnGetConfiguration", mock.Anything).Return(confirmation2, nil)
+	chargePoint2 := ocpp16.NewChargePoint("cp2", nil, wstest.NewClient(server))
+	chargePoint2.SetCoreHandler(listener2)
+	require.NoError(t, wstest.ConnectInMemory(server, centralSystem, chargePoint1, chargePoint2))
+	defer centralSystem.Stop()
+	defer chargePoint1.Stop()
+	defer chargePoint2.Stop()
+	// Nothing reported yet
+	inventory := centralSystem.Inventory()
+	require.Len(t, inventory, 2)
+	assert.Equal(t, "cp1", inventory[0].ID)
+	assert.Equal(t, "cp2", inventory[1].ID)
+	for _, info := range inventory {
+		assert.Equal(t, ocpp.V16, info.ProtocolVersion)
+		assert.Equal(t, "ocpp1.6", info.Subprotocol)
+		assert.False(t, info.ConnectedAt.IsZero())
+		assert.Equal(t, ocppj.CapabilityUnknown, info.Capabilities.Status)
+		assert.Empty(t, info.Capabilities.Profiles)
+	}
+	// Query the configuration of both charge points
+	for _, id := range []string{"cp1", "cp2"} {
+		resultC := make(chan error, 1)
+		err := centralSystem.GetConfiguration(id, func(confirmation *core.GetConfigurationConfirmation, err error) {
+			resultC <- err
+		}, []string{"SupportedFeatureProfiles"})
+		require.NoError(t, err)
+		require.NoError(t, <-resultC)
+	}
+	inventory = centralSystem.Inventory()
+	info1, ok := inventoryEntry(inventory, "cp1")
+	require.True(t, ok)
+	assert.Equal(t, ocppj.CapabilityCurrent, info1.Capabilities.Status)
+	assert.Equal(t, "SupportedFeatureProfiles", info1.Capabilities.Source)
+	assert.Equal(t, []string{"Core", "SmartCharging", "RemoteTrigger"}, info1.Capabilities.Profiles)
+	require.NotNil(t, info1.Capabilities.ReportedAt)
+	assert.False(t, info1.LastActivity.Before(info1.ConnectedAt))
+	info2, ok := inventoryEntry(inventory, "cp2")
+	require.True(t, ok)
+	assert.Equal(t, ocppj.CapabilityUnknown, info2.Capabilities.Status)
+	assert.Nil(t, info2.Capabilities.ReportedAt)
+	// The inventory may be served as JSON
+	data, err := json.Marshal(info1)
+	require.NoError(t, err)
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, "1.6", decoded["protocolVersion"])
+	assert.IsType(t, "", decoded["uptime"])
+	assert.Equal(t, "Current", decoded["capabilities"].(map[string]interface{})["status"])
+	// After reconnecting, the reported profiles are stale
+	connectedAt := info1.ConnectedAt
+	require.NoError(t, server.Disconnect("cp1", errors.New("connection lost")))
+	require.Eventually(t, func() bool {
+		info, ok := inventoryEntry(centralSystem.Inventory(), "cp1")
+		return ok && info.ConnectedAt.After(connectedAt)
+	}, 5*time.Second, 10*time.Millisecond)
+	info1, _ = inventoryEntry(centralSystem.Inventory(), "cp1")
+	assert.Equal(t, ocppj.CapabilityStale, info1.Capabilities.Status)
+	assert.Equal(t, []string{"Core", "SmartCharging", "RemoteTrigger"}, info1.Capabilities.Profiles)
+}
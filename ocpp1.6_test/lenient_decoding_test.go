@@ -0,0 +1,176 @@
+package ocpp16_test
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// Payloads captured from non-conformant charge point firmware.
+const (
+	lenientStatusNotificationFixture = `[2,"%v","StatusNotification",{"connectorId":"1","errorCode":"NoError","info":null,"status":"Available","timestamp":"2023-03-01T10:00:00Z","vendorErrorCode":""}]`
+	lenientMeterValuesFixture        = `[2,"%v","MeterValues",{"connectorId":"2","transactionId":"17","meterValue":[{"timestamp":"2023-03-01T10:00:00Z","sampledValue":[{"value":"1234","context":"Sample.Periodic","measurand":"Energy.Active.Import.Register","phase":"","unit":"Wh"}]}]}]`
+	lenientGetConfigurationFixture   = `[3,"%v",{"configurationKey":[{"key":"HeartbeatInterval","readonly":0,"value":"300"},{"key":"NumberOfConnectors","readonly":1,"value":"2"}],"unknownKey":null}]`
+)
+
+type coercionRecorder struct {
+	mutex     sync.Mutex
+	coercions []ocppj.Coercion
+}
+
+func (r *coercionRecorder) record(c ocppj.Coercion) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.coercions = append(r.coercions, c)
+}
+
+func (r *coercionRecorder) count(kind ocppj.CoercionKind) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	n := 0
+	for _, c := range r.coercions {
+		if c.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+func (r *coercionRecorder) fields() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var result []string
+	for _, c := range r.coercions {
+		result = append(result, c.Field)
+	}
+	return result
+}
+
+func (suite *OcppV16TestSuite) setupLenientCentralSystem(coreListener core.CentralSystemHandler, written chan []byte) {
+	suite.centralSystem.SetCoreHandler(coreListener)
+	suite.mockWsServer.On("Start", mock.AnythingOfType("int"), mock.AnythingOfType("string")).Return(nil)
+	suite.mockWsServer.On("Write", mock.AnythingOfType("string"), mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		written <- args.Get(1).([]byte)
+	})
+	suite.centralSystem.Start(8887, "somePath")
+}
+
+func (suite *OcppV16TestSuite) TestLenientDecodingStatusNotification() {
+	t := suite.T()
+	wsId := "test_id"
+	messageId := defaultMessageId
+	channel := NewMockWebSocket(wsId)
+	written := make(chan []byte, 1)
+	recorder := &coercionRecorder{}
+	coreListener := &MockCentralSystemCoreListener{}
+	coreListener.On("OnStatusNotification", mock.AnythingOfType("string"), mock.Anything).Return(core.NewStatusNotificationConfirmation(), nil).Run(func(args mock.Arguments) {
+		request, ok := args.Get(1).(*core.StatusNotificationRequest)
+		require.True(t, ok)
+		assert.Equal(t, 1, request.ConnectorId)
+		assert.Equal(t, core.NoError, request.ErrorCode)
+		assert.Equal(t, core.ChargePointStatusAvailable, request.Status)
+		assert.Empty(t, request.Info)
+		assert.Empty(t, request.VendorErrorCode)
+	})
+	suite.centralSystem.EnableLenientDecoding(recorder.record)
+	suite.setupLenientCentralSystem(coreListener, written)
+	suite.mockWsServer.NewClientHandler(channel)
+	err := suite.mockWsServer.MessageHandler(channel, []byte(fmt.Sprintf(lenientStatusNotificationFixture, messageId)))
+	require.NoError(t, err)
+	response := <-written
+	assert.Equal(t, fmt.Sprintf(`[3,"%v",{}]`, messageId), string(response))
+	assert.Equal(t, 1, recorder.count(ocppj.CoercionNumberFromString))
+	assert.Equal(t, 1, recorder.count(ocppj.CoercionNullAsAbsent))
+	assert.Equal(t, 1, recorder.count(ocppj.CoercionEmptyStringAsAbsent))
+	assert.ElementsMatch(t, []string{"connectorId", "info", "vendorErrorCode"}, recorder.fields())
+}
+
+func (suite *OcppV16TestSuite) TestLenientDecodingMeterValues() {
+	t := suite.T()
+	wsId := "test_id"
+	messageId := defaultMessageId
+	channel := NewMockWebSocket(wsId)
+	written := make(chan []byte, 1)
+	recorder := &coercionRecorder{}
+	coreListener := &MockCentralSystemCoreListener{}
+	coreListener.On("OnMeterValues", mock.AnythingOfType("string"), mock.Anything).Return(core.NewMeterValuesConfirmation(), nil).Run(func(args mock.Arguments) {
+		request, ok := args.Get(1).(*core.MeterValuesRequest)
+		require.True(t, ok)
+		assert.Equal(t, 2, request.ConnectorId)
+		require.NotNil(t, request.TransactionId)
+		assert.Equal(t, 17, *request.TransactionId)
+		require.Len(t, request.MeterValue, 1)
+		require.Len(t, request.MeterValue[0].SampledValue, 1)
+		sampledValue := request.MeterValue[0].SampledValue[0]
+		assert.Equal(t, "1234", sampledValue.Value)
+		assert.Equal(t, types.Phase(""), sampledValue.Phase)
+		assert.Equal(t, types.UnitOfMeasureWh, sampledValue.Unit)
+	})
+	suite.centralSystem.EnableLenientDecoding(recorder.record)
+	suite.setupLenientCentralSystem(coreListener, written)
+	suite.mockWsServer.NewClientHandler(channel)
+	err := suite.mockWsServer.MessageHandler(channel, []byte(fmt.Sprintf(lenientMeterValuesFixture, messageId)))
+	require.NoError(t, err)
+	response := <-written
+	assert.Equal(t, fmt.Sprintf(`[3,"%v",{}]`, messageId), string(response))
+	assert.Equal(t, 2, recorder.count(ocppj.CoercionNumberFromString))
+	assert.Equal(t, 1, recorder.count(ocppj.CoercionEmptyStringAsAbsent))
+	assert.ElementsMatch(t, []string{"connectorId", "transactionId", "meterValue[0].sampledValue[0].phase"}, recorder.fields())
+}
+
+func (suite *OcppV16TestSuite) TestLenientDecodingGetConfigurationConfirmation() {
+	t := suite.T()
+	wsId := "test_id"
+	messageId := defaultMessageId
+	channel := NewMockWebSocket(wsId)
+	written := make(chan []byte, 1)
+	recorder := &coercionRecorder{}
+	suite.centralSystem.EnableLenientDecoding(recorder.record)
+	suite.setupLenientCentralSystem(nil, written)
+	suite.mockWsServer.NewClientHandler(channel)
+	resultC := make(chan *core.GetConfigurationConfirmation, 1)
+	err := suite.centralSystem.GetConfiguration(wsId, func(confirmation *core.GetConfigurationConfirmation, err error) {
+		require.NoError(t, err)
+		resultC <- confirmation
+	}, nil)
+	require.NoError(t, err)
+	request := <-written
+	assert.True(t, strings.HasPrefix(string(request), fmt.Sprintf(`[2,"%v","GetConfiguration"`, messageId)))
+	err = suite.mockWsServer.MessageHandler(channel, []byte(fmt.Sprintf(lenientGetConfigurationFixture, messageId)))
+	require.NoError(t, err)
+	confirmation := <-resultC
+	require.NotNil(t, confirmation)
+	require.Len(t, confirmation.ConfigurationKey, 2)
+	assert.False(t, confirmation.ConfigurationKey[0].Readonly)
+	assert.True(t, confirmation.ConfigurationKey[1].Readonly)
+	assert.Nil(t, confirmation.UnknownKey)
+	assert.Equal(t, 2, recorder.count(ocppj.CoercionBoolFromNumber))
+	assert.Equal(t, 1, recorder.count(ocppj.CoercionNullAsAbsent))
+}
+
+func (suite *OcppV16TestSuite) TestStrictDecodingRejectsNonConformantPayload() {
+	t := suite.T()
+	wsId := "test_id"
+	messageId := defaultMessageId
+	channel := NewMockWebSocket(wsId)
+	written := make(chan []byte, 1)
+	coreListener := &MockCentralSystemCoreListener{}
+	suite.setupLenientCentralSystem(coreListener, written)
+	suite.mockWsServer.NewClientHandler(channel)
+	_ = suite.mockWsServer.MessageHandler(channel, []byte(fmt.Sprintf(lenientStatusNotificationFixture, messageId)))
+	select {
+	case response := <-written:
+		assert.True(t, strings.HasPrefix(string(response), fmt.Sprintf(`[4,"%v","%v"`, messageId, ocppj.FormatViolationV16)))
+	case <-time.After(time.Second):
+		t.Fatal("no error response sent")
+	}
+	coreListener.AssertNotCalled(t, "OnStatusNotification", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,152 @@
+package ocpp16_test
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/clock/clocktest"
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	ocpp16 "github.com/lorenzodonini/ocpp-go/ocpp1.6"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/remotetrigger"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/lorenzodonini/ocpp-go/ws/wstest"
+)
+
+func newLivenessPair(profiles ...*ocpp.Profile) (ocpp16.CentralSystem, ocpp16.ChargePoint, *wstest.Server, *clocktest.FakeClock) {
+	server := wstest.NewServer()
+	fakeClock := clocktest.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	endpoint := ocppj.NewServer(server, nil, nil, profiles...)
+	endpoint.SetClock(fakeClock)
+	centralSystem := ocpp16.NewCentralSystem(endpoint, server)
+	chargePoint := ocpp16.NewChargePoint("cp1", nil, wstest.NewClient(server))
+	return centralSystem, chargePoint, server, fakeClock
+}
+
+func receiveLiveness(t require.TestingT, livenessC <-chan ocppj.Liveness) ocppj.Liveness {
+	select {
+	case liveness := <-livenessC:
+		return liveness
+	case <-time.After(time.Second):
+		require.Fail(t, "liveness handler wasn't invoked")
+	}
+	return ocppj.Liveness{}
+}
+
+func (suite *OcppV16TestSuite) TestLivenessMonitor() {
+	t := suite.T()
+	centralSystem, chargePoint, server, fakeClock := newLivenessPair(core.Profile, remotetrigger.Profile)
+	coreListener := &MockCentralSystemCoreListener{}
+	coreListener.On("OnBootNotification", "cp1", mock.Anything).Return(core.NewBootNotificationConfirmation(types.NewDateTime(time.Now()), 60, core.RegistrationStatusAccepted), nil)
+	coreListener.On("OnHeartbeat", "cp1", mock.Anything).Return(core.NewHeartbeatConfirmation(types.NewDateTime(time.Now())), nil)
+	centralSystem.SetCoreHandler(coreListener)
+	disconnectedC := make(chan string, 1)
+	centralSystem.SetChargePointDisconnectedHandler(func(chargePoint ocpp16.ChargePointConnection) {
+		disconnectedC <- chargePoint.ID()
+	})
+	livenessC := make(chan ocppj.Liveness, 10)
+	centralSystem.SetLivenessHandler(func(chargePointID string, liveness ocppj.Liveness) {
+		livenessC <- liveness
+	})
+	centralSystem.EnableLivenessMonitor(ocppj.LivenessConfig{DefaultHeartbeatInterval: 5 * time.Minute, ProbeTimeout: 30 * time.Second, CheckInterval: 10 * time.Second})
+	chargePointCoreListener := &MockChargePointCoreListener{}
+	chargePointCoreListener.On("OnChangeConfiguration", mock.Anything).Return(core.NewChangeConfigurationConfirmation(core.ConfigurationStatusAccepted), nil)
+	chargePoint.SetCoreHandler(chargePointCoreListener)
+	triggerListener := &MockChargePointRemoteTriggerListener{}
+	triggerListener.On("OnTriggerMessage", mock.Anything).Return(remotetrigger.NewTriggerMessageConfirmation(remotetrigger.TriggerMessageStatusAccepted), nil)
+	chargePoint.SetRemoteTriggerHandler(triggerListener)
+	require.NoError(t, wstest.ConnectInMemory(server, centralSystem, chargePoint))
+	defer centralSystem.Stop()
+	defer chargePoint.Stop()
+	// The heartbeat interval is taken from the boot confirmation, then from accepted configuration changes
+	_, err := chargePoint.BootNotification("model1", "vendor1")
+	require.NoError(t, err)
+	liveness, ok := centralSystem.Liveness("cp1")
+	require.True(t, ok)
+	assert.Equal(t, time.Minute, liveness.HeartbeatInterval)
+	resultC := make(chan error, 1)
+	err = centralSystem.ChangeConfiguration("cp1", func(confirmation *core.ChangeConfigurationConfirmation, err error) {
+		resultC <- err
+	}, "HeartbeatInterval", "90")
+	require.NoError(t, err)
+	require.NoError(t, <-resultC)
+	liveness, _ = centralSystem.Liveness("cp1")
+	assert.Equal(t, 90*time.Second, liveness.HeartbeatInterval)
+	assert.Equal(t, 3*time.Minute, liveness.Budget)
+	// Healthy: heartbeats within the budget
+	for i := 0; i < 3; i++ {
+		fakeClock.Advance(80 * time.Second)
+		_, err = chargePoint.Heartbeat()
+		require.NoError(t, err)
+	}
+	liveness, _ = centralSystem.Liveness("cp1")
+	assert.Equal(t, ocppj.LivenessHealthy, liveness.State)
+	assert.Len(t, livenessC, 0)
+	// Probed and recovered: the charge point answers the trigger
+	fakeClock.Advance(190 * time.Second)
+	liveness = receiveLiveness(t, livenessC)
+	assert.Equal(t, ocppj.LivenessProbing, liveness.State)
+	require.NotNil(t, liveness.ProbedAt)
+	probedAt := *liveness.ProbedAt
+	require.Eventually(t, func() bool {
+		liveness, _ := centralSystem.Liveness("cp1")
+		return liveness.LastMessage.Equal(probedAt)
+	}, time.Second, 5*time.Millisecond)
+	triggerListener.AssertCalled(t, "OnTriggerMessage", mock.MatchedBy(func(request *remotetrigger.TriggerMessageRequest) bool {
+		return request.RequestedMessage == remotetrigger.MessageTrigger(core.HeartbeatFeatureName)
+	}))
+	fakeClock.Advance(10 * time.Second)
+	liveness = receiveLiveness(t, livenessC)
+	assert.Equal(t, ocppj.LivenessHealthy, liveness.State)
+	// Reaped: the connection died silently, so the probe never reaches the charge point
+	conn, ok := server.Connection("cp1")
+	require.True(t, ok)
+	conn.SetConditions(wstest.Conditions{Drop: func(data []byte) bool { return true }})
+	fakeClock.Advance(190 * time.Second)
+	liveness = receiveLiveness(t, livenessC)
+	assert.Equal(t, ocppj.LivenessProbing, liveness.State)
+	fakeClock.Advance(30 * time.Second)
+	liveness = receiveLiveness(t, livenessC)
+	assert.Equal(t, ocppj.LivenessReaped, liveness.State)
+	select {
+	case id := <-disconnectedC:
+		assert.Equal(t, "cp1", id)
+	case <-time.After(time.Second):
+		require.Fail(t, "reaped charge point wasn't disconnected")
+	}
+	_, ok = centralSystem.Liveness("cp1")
+	assert.False(t, ok)
+}
+
+func (suite *OcppV16TestSuite) TestLivenessMonitorWithoutProbe() {
+	t := suite.T()
+	// Without the remote trigger profile, silent charge points are reaped right away
+	centralSystem, chargePoint, server, fakeClock := newLivenessPair(core.Profile)
+	disconnectedC := make(chan string, 1)
+	centralSystem.SetChargePointDisconnectedHandler(func(chargePoint ocpp16.ChargePointConnection) {
+		disconnectedC <- chargePoint.ID()
+	})
+	livenessC := make(chan ocppj.Liveness, 10)
+	centralSystem.SetLivenessHandler(func(chargePointID string, liveness ocppj.Liveness) {
+		livenessC <- liveness
+	})
+	centralSystem.EnableLivenessMonitor(ocppj.LivenessConfig{DefaultHeartbeatInterval: time.Minute, Grace: 15 * time.Second, ProbeTimeout: 30 * time.Second, CheckInterval: 10 * time.Second})
+	require.NoError(t, wstest.ConnectInMemory(server, centralSystem, chargePoint))
+	defer centralSystem.Stop()
+	defer chargePoint.Stop()
+	fakeClock.Advance(80 * time.Second)
+	liveness := receiveLiveness(t, livenessC)
+	assert.Equal(t, ocppj.LivenessReaped, liveness.State)
+	assert.Equal(t, 75*time.Second, liveness.Budget)
+	assert.Nil(t, liveness.ProbedAt)
+	select {
+	case id := <-disconnectedC:
+		assert.Equal(t, "cp1", id)
+	case <-time.After(time.Second):
+		require.Fail(t, "reaped charge point wasn't disconnected")
+	}
+}
@@ -1,7 +1,9 @@
 package ocpp16_test
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -47,6 +49,14 @@ func (websocket MockWebSocket) TLSConnectionState() *tls.ConnectionState {
 	return nil
 }
 
+func (websocket MockWebSocket) Subprotocol() string {
+	return types.V16Subprotocol
+}
+
+func (websocket MockWebSocket) Context() context.Context {
+	return context.Background()
+}
+
 func NewMockWebSocket(id string) MockWebSocket {
 	return MockWebSocket{id: id}
 }
@@ -581,6 +591,7 @@ func testUnsupportedRequestFromChargePoint(suite *OcppV16TestSuite, request ocpp
 	})
 	require.Error(t, err)
 	assert.Equal(t, expectedError, err.Error())
+	assert.True(t, errors.Is(err, ocppj.ErrUnsupportedFeature))
 	// 2. Test receiving an unsupported request on the other endpoint and receiving an error
 	// Mark mocked request as pending, otherwise response will be ignored
 	suite.ocppjChargePoint.RequestState.AddPendingRequest(messageId, request)
@@ -623,6 +634,7 @@ func testUnsupportedRequestFromCentralSystem(suite *OcppV16TestSuite, request oc
 	})
 	require.Error(t, err)
 	assert.Equal(t, expectedError, err.Error())
+	assert.True(t, errors.Is(err, ocppj.ErrUnsupportedFeature))
 	// 2. Test receiving an unsupported request on the other endpoint and receiving an error
 	// Mark mocked request as pending, otherwise response will be ignored
 	suite.ocppjCentralSystem.RequestState.AddPendingRequest(wsId, messageId, request)
@@ -719,6 +731,31 @@ func (suite *OcppV16TestSuite) TestIsConnected() {
 	assert.False(t, suite.chargePoint.IsConnected())
 }
 
+func (suite *OcppV16TestSuite) TestChargePointStoppedWhileWaiting() {
+	t := suite.T()
+	wsUrl := "someUrl"
+	writtenC := make(chan struct{}, 1)
+	suite.mockWsClient.On("Start", mock.AnythingOfType("string")).Return(nil)
+	suite.mockWsClient.On("Write", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		writtenC <- struct{}{}
+	})
+	suite.mockWsClient.On("IsConnected").Return(false)
+	suite.mockWsClient.On("Stop").Return()
+	err := suite.chargePoint.Start(wsUrl)
+	require.NoError(t, err)
+	// The central system never replies
+	errC := make(chan error, 1)
+	go func() {
+		_, err := suite.chargePoint.Heartbeat()
+		errC <- err
+	}()
+	<-writtenC
+	suite.chargePoint.Stop()
+	err = <-errC
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ocppj.ErrClientStopped))
+}
+
 // TODO: implement generic protocol tests
 func TestOcpp16Protocol(t *testing.T) {
 	suite.Run(t, new(OcppV16TestSuite))
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/reservation"
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
 	"github.com/stretchr/testify/assert"
@@ -102,3 +103,72 @@ func (suite *OcppV16TestSuite) TestReserveNowInvalidEndpoint() {
 		messageId, reservation.ReserveNowFeatureName, connectorId, expiryDate.FormatTimestamp(), idTag, parentIdTag, reservationId)
 	testUnsupportedRequestFromChargePoint(suite, reserveNowRequest, requestJson, messageId)
 }
+
+func (suite *OcppV16TestSuite) TestReserveNowDecide() {
+	t := suite.T()
+	reservationId := 42
+	otherReservationId := 7
+	enabled := reservation.ReservationConfig{ReservationSupported: true, ReserveConnectorZeroSupported: true}
+	noConnectorZero := reservation.ReservationConfig{ReservationSupported: true}
+	disabled := reservation.ReservationConfig{ReserveConnectorZeroSupported: true}
+	var testTable = []struct {
+		connectorId    int
+		snapshot       reservation.ConnectorSnapshot
+		config         reservation.ReservationConfig
+		expectedStatus reservation.ReservationStatus
+	}{
+		{1, reservation.ConnectorSnapshot{Status: core.ChargePointStatusAvailable}, enabled, reservation.ReservationStatusAccepted},
+		{1, reservation.ConnectorSnapshot{Status: core.ChargePointStatusAvailable}, disabled, reservation.ReservationStatusRejected},
+		{1, reservation.ConnectorSnapshot{Status: core.ChargePointStatusFaulted}, disabled, reservation.ReservationStatusRejected},
+		{1, reservation.ConnectorSnapshot{Status: core.ChargePointStatusFaulted}, enabled, reservation.ReservationStatusFaulted},
+		{1, reservation.ConnectorSnapshot{Status: core.ChargePointStatusUnavailable}, enabled, reservation.ReservationStatusUnavailable},
+		{1, reservation.ConnectorSnapshot{Status: core.ChargePointStatusPreparing}, enabled, reservation.ReservationStatusOccupied},
+		{1, reservation.ConnectorSnapshot{Status: core.ChargePointStatusCharging}, enabled, reservation.ReservationStatusOccupied},
+		{1, reservation.ConnectorSnapshot{Status: core.ChargePointStatusSuspendedEV}, enabled, reservation.ReservationStatusOccupied},
+		{1, reservation.ConnectorSnapshot{Status: core.ChargePointStatusSuspendedEVSE}, enabled, reservation.ReservationStatusOccupied},
+		{1, reservation.ConnectorSnapshot{Status: core.ChargePointStatusFinishing}, enabled, reservation.ReservationStatusOccupied},
+		{1, reservation.ConnectorSnapshot{Status: core.ChargePointStatusReserved, ReservationId: &otherReservationId}, enabled, reservation.ReservationStatusOccupied},
+		{1, reservation.ConnectorSnapshot{Status: core.ChargePointStatusReserved}, enabled, reservation.ReservationStatusOccupied},
+		{1, reservation.ConnectorSnapshot{Status: core.ChargePointStatusReserved, ReservationId: &reservationId}, enabled, reservation.ReservationStatusAccepted},
+		{0, reservation.ConnectorSnapshot{Status: core.ChargePointStatusAvailable}, enabled, reservation.ReservationStatusAccepted},
+		{0, reservation.ConnectorSnapshot{Status: core.ChargePointStatusAvailable}, noConnectorZero, reservation.ReservationStatusRejected},
+		{0, reservation.ConnectorSnapshot{Status: core.ChargePointStatusFaulted}, noConnectorZero, reservation.ReservationStatusRejected},
+		{0, reservation.ConnectorSnapshot{Status: core.ChargePointStatusUnavailable}, enabled, reservation.ReservationStatusUnavailable},
+		{0, reservation.ConnectorSnapshot{Status: core.ChargePointStatusAvailable, ReservationId: &otherReservationId}, enabled, reservation.ReservationStatusOccupied},
+	}
+	for i, tc := range testTable {
+		req := reservation.NewReserveNowRequest(tc.connectorId, types.NewDateTime(time.Now()), "tag1", reservationId)
+		status := reservation.Decide(req, tc.snapshot, tc.config)
+		assert.Equal(t, tc.expectedStatus, status, "test case %v", i)
+	}
+}
+
+func (suite *OcppV16TestSuite) TestCheckReserveNowConfirmation() {
+	t := suite.T()
+	reservationId := 42
+	var testTable = []struct {
+		knownStatus    core.ChargePointStatus
+		reportedStatus reservation.ReservationStatus
+		expectWarning  bool
+	}{
+		{core.ChargePointStatusAvailable, reservation.ReservationStatusAccepted, false},
+		{core.ChargePointStatusFaulted, reservation.ReservationStatusAccepted, true},
+		{core.ChargePointStatusUnavailable, reservation.ReservationStatusAccepted, true},
+		{core.ChargePointStatusCharging, reservation.ReservationStatusAccepted, true},
+		{core.ChargePointStatusFaulted, reservation.ReservationStatusFaulted, false},
+		{core.ChargePointStatusAvailable, reservation.ReservationStatusRejected, false},
+		{core.ChargePointStatusAvailable, reservation.ReservationStatusOccupied, false},
+	}
+	for i, tc := range testTable {
+		req := reservation.NewReserveNowRequest(1, types.NewDateTime(time.Now()), "tag1", reservationId)
+		warning := reservation.CheckReserveNowConfirmation(req, reservation.ConnectorSnapshot{Status: tc.knownStatus}, reservation.NewReserveNowConfirmation(tc.reportedStatus))
+		if tc.expectWarning {
+			require.NotNil(t, warning, "test case %v", i)
+			assert.Equal(t, tc.knownStatus, warning.KnownStatus)
+			assert.Equal(t, tc.reportedStatus, warning.ReportedStatus)
+			assert.Equal(t, 1, warning.ConnectorId)
+		} else {
+			assert.Nil(t, warning, "test case %v", i)
+		}
+	}
+}
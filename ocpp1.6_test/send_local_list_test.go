@@ -1,14 +1,19 @@
 package ocpp16_test
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
+	ocpp16 "github.com/lorenzodonini/ocpp-go/ocpp1.6"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/localauth"
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/lorenzodonini/ocpp-go/ws/wstest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/go-playground/validator.v9"
 )
 
 // Test
@@ -19,6 +24,7 @@ func (suite *OcppV16TestSuite) TestSendLocalListRequestValidation() {
 		ParentIdTag: "000000",
 		Status:      types.AuthorizationStatusAccepted,
 	}}
+	deletedAuthEntry := localauth.AuthorizationData{IdTag: "abcde"}
 	invalidAuthEntry := localauth.AuthorizationData{IdTag: "12345", IdTagInfo: &types.IdTagInfo{
 		ExpiryDate:  types.NewDateTime(time.Now().Add(time.Hour * 8)),
 		ParentIdTag: "000000",
@@ -28,17 +34,135 @@ func (suite *OcppV16TestSuite) TestSendLocalListRequestValidation() {
 		{localauth.SendLocalListRequest{UpdateType: localauth.UpdateTypeDifferential, ListVersion: 1, LocalAuthorizationList: []localauth.AuthorizationData{localAuthEntry}}, true},
 		{localauth.SendLocalListRequest{UpdateType: localauth.UpdateTypeDifferential, ListVersion: 1, LocalAuthorizationList: []localauth.AuthorizationData{}}, true},
 		{localauth.SendLocalListRequest{UpdateType: localauth.UpdateTypeDifferential, ListVersion: 1}, true},
-		{localauth.SendLocalListRequest{UpdateType: localauth.UpdateTypeDifferential, ListVersion: 0}, true},
-		{localauth.SendLocalListRequest{UpdateType: localauth.UpdateTypeDifferential}, true},
+		{localauth.SendLocalListRequest{UpdateType: localauth.UpdateTypeDifferential, ListVersion: 0}, false},
+		{localauth.SendLocalListRequest{UpdateType: localauth.UpdateTypeDifferential}, false},
 		{localauth.SendLocalListRequest{UpdateType: localauth.UpdateTypeDifferential, ListVersion: -1}, false},
 		{localauth.SendLocalListRequest{UpdateType: localauth.UpdateTypeDifferential, ListVersion: 1, LocalAuthorizationList: []localauth.AuthorizationData{invalidAuthEntry}}, false},
 		{localauth.SendLocalListRequest{UpdateType: "invalidUpdateType", ListVersion: 1}, false},
 		{localauth.SendLocalListRequest{ListVersion: 1}, false},
 		{localauth.SendLocalListRequest{}, false},
+		// Full updates require idTagInfo on every entry, Differential entries without it are deletions
+		{localauth.SendLocalListRequest{UpdateType: localauth.UpdateTypeFull, ListVersion: 1, LocalAuthorizationList: []localauth.AuthorizationData{localAuthEntry}}, true},
+		{localauth.SendLocalListRequest{UpdateType: localauth.UpdateTypeFull, ListVersion: 1}, true},
+		{localauth.SendLocalListRequest{UpdateType: localauth.UpdateTypeFull, ListVersion: 1, LocalAuthorizationList: []localauth.AuthorizationData{localAuthEntry, deletedAuthEntry}}, false},
+		{localauth.SendLocalListRequest{UpdateType: localauth.UpdateTypeDifferential, ListVersion: 1, LocalAuthorizationList: []localauth.AuthorizationData{localAuthEntry, deletedAuthEntry}}, true},
+		// IdTags must be unique, regardless of case
+		{localauth.SendLocalListRequest{UpdateType: localauth.UpdateTypeDifferential, ListVersion: 1, LocalAuthorizationList: []localauth.AuthorizationData{localAuthEntry, localAuthEntry}}, false},
+		{localauth.SendLocalListRequest{UpdateType: localauth.UpdateTypeDifferential, ListVersion: 1, LocalAuthorizationList: []localauth.AuthorizationData{deletedAuthEntry, {IdTag: "ABCDE"}}}, false},
 	}
 	ExecuteGenericTestTable(t, requestTable)
 }
 
+func (suite *OcppV16TestSuite) TestSendLocalListRequestValidationErrors() {
+	t := suite.T()
+	request := localauth.NewSendLocalListRequest(1, localauth.UpdateTypeFull)
+	request.LocalAuthorizationList = []localauth.AuthorizationData{{IdTag: "tag1"}, {IdTag: "TAG1"}}
+	err := types.Validate.Struct(request)
+	require.Error(t, err)
+	validationErrors, ok := err.(validator.ValidationErrors)
+	require.True(t, ok)
+	var namespaces []string
+	for _, fieldError := range validationErrors {
+		namespaces = append(namespaces, fieldError.Namespace())
+	}
+	assert.ElementsMatch(t, []string{
+		"SendLocalListRequest.LocalAuthorizationList[0].IdTagInfo",
+		"SendLocalListRequest.LocalAuthorizationList[1].IdTag",
+		"SendLocalListRequest.LocalAuthorizationList[1].IdTagInfo",
+	}, namespaces)
+}
+
+func (suite *OcppV16TestSuite) TestSplitSendLocalList() {
+	t := suite.T()
+	idTagInfo := &types.IdTagInfo{Status: types.AuthorizationStatusAccepted}
+	var list []localauth.AuthorizationData
+	for i := 0; i < 7; i++ {
+		list = append(list, localauth.AuthorizationData{IdTag: fmt.Sprintf("tag%d", i), IdTagInfo: idTagInfo})
+	}
+	request := localauth.NewSendLocalListRequest(5, localauth.UpdateTypeFull)
+	request.LocalAuthorizationList = list
+	for _, tc := range []struct {
+		maxLength int
+		expected  []int
+	}{
+		{3, []int{3, 3, 1}},
+		{7, []int{7}},
+		{10, []int{7}},
+		{0, []int{7}},
+	} {
+		batches := localauth.SplitSendLocalList(request, tc.maxLength)
+		require.Len(t, batches, len(tc.expected))
+		var entries []localauth.AuthorizationData
+		for i, batch := range batches {
+			assert.Len(t, batch.LocalAuthorizationList, tc.expected[i])
+			assert.Equal(t, 5+i, batch.ListVersion)
+			if i == 0 {
+				assert.Equal(t, localauth.UpdateTypeFull, batch.UpdateType)
+			} else {
+				assert.Equal(t, localauth.UpdateTypeDifferential, batch.UpdateType)
+			}
+			assert.NoError(t, types.Validate.Struct(batch))
+			entries = append(entries, batch.LocalAuthorizationList...)
+		}
+		assert.Equal(t, list, entries)
+	}
+}
+
+func (suite *OcppV16TestSuite) TestSendLocalListMaxLength() {
+	t := suite.T()
+	server := wstest.NewServer()
+	centralSystem := ocpp16.NewCentralSystem(nil, server)
+	maxLength := "2"
+	coreListener := &MockChargePointCoreListener{}
+	coreListener.On("OnGetConfiguration", mock.Anything).Return(core.NewGetConfigurationConfirmation([]core.ConfigurationKey{
+		{Key: "SendLocalListMaxLength", Readonly: true, Value: &maxLength},
+	}), nil)
+	localAuthListListener := &MockChargePointLocalAuthListListener{}
+	localAuthListListener.On("OnSendLocalList", mock.Anything).Return(localauth.NewSendLocalListConfirmation(localauth.UpdateStatusAccepted), nil)
+	chargePoint := ocpp16.NewChargePoint("cp1", nil, wstest.NewClient(server))
+	chargePoint.SetCoreHandler(coreListener)
+	chargePoint.SetLocalAuthListHandler(localAuthListListener)
+	require.NoError(t, wstest.ConnectInMemory(server, centralSystem, chargePoint))
+	defer centralSystem.Stop()
+	defer chargePoint.Stop()
+	idTagInfo := &types.IdTagInfo{Status: types.AuthorizationStatusAccepted}
+	list := []localauth.AuthorizationData{{IdTag: "tag1", IdTagInfo: idTagInfo}, {IdTag: "tag2", IdTagInfo: idTagInfo}, {IdTag: "tag3", IdTagInfo: idTagInfo}}
+	sendLocalList := func(request *localauth.SendLocalListRequest) error {
+		resultC := make(chan error, 1)
+		err := centralSystem.SendLocalList("cp1", func(confirmation *localauth.SendLocalListConfirmation, err error) {
+			resultC <- err
+		}, request.ListVersion, request.UpdateType, func(r *localauth.SendLocalListRequest) {
+			r.LocalAuthorizationList = request.LocalAuthorizationList
+		})
+		if err != nil {
+			return err
+		}
+		return <-resultC
+	}
+	request := localauth.NewSendLocalListRequest(1, localauth.UpdateTypeFull)
+	request.LocalAuthorizationList = list
+	// The limit is unknown, hence the list is sent
+	require.NoError(t, sendLocalList(request))
+	// Once reported, longer lists are rejected before sending
+	resultC := make(chan error, 1)
+	err := centralSystem.GetConfiguration("cp1", func(confirmation *core.GetConfigurationConfirmation, err error) {
+		resultC <- err
+	}, []string{"SendLocalListMaxLength"})
+	require.NoError(t, err)
+	require.NoError(t, <-resultC)
+	err = sendLocalList(request)
+	var maxLengthErr *localauth.MaxLengthError
+	require.True(t, errors.As(err, &maxLengthErr))
+	assert.Equal(t, 3, maxLengthErr.Length)
+	assert.Equal(t, 2, maxLengthErr.MaxLength)
+	localAuthListListener.AssertNumberOfCalls(t, "OnSendLocalList", 1)
+	// Split batches are accepted
+	for _, batch := range localauth.SplitSendLocalList(request, 2) {
+		require.NoError(t, sendLocalList(batch))
+	}
+	localAuthListListener.AssertNumberOfCalls(t, "OnSendLocalList", 3)
+}
+
 func (suite *OcppV16TestSuite) TestSendLocalListConfirmationValidation() {
 	t := suite.T()
 	confirmationTable := []GenericTestEntry{
@@ -0,0 +1,176 @@
+package ocpp16_test
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	ocpp16 "github.com/lorenzodonini/ocpp-go/ocpp1.6"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/firmware"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/localauth"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/remotetrigger"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/reservation"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/smartcharging"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/lorenzodonini/ocpp-go/soap"
+)
+
+const soapBootNotification = `<?xml version="1.0" encoding="UTF-8"?>
+<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope" xmlns:a="http://www.w3.org/2005/08/addressing">
+	<s:Header>
+		<chargeBoxIdentity xmlns="urn://Ocpp/Cs/2015/10/">%s</chargeBoxIdentity>
+		<a:MessageID>urn:uuid:3c6e3bd4-1b52-4d0b-9f0c-4c0e8a4b7f01</a:MessageID>
+		<a:Action>/BootNotification</a:Action>
+		<a:From><a:Address>%s</a:Address></a:From>
+	</s:Header>
+	<s:Body>
+		<bootNotificationRequest xmlns="urn://Ocpp/Cs/2015/10/">
+			<chargePointVendor>vendor</chargePointVendor>
+			<chargePointModel>model</chargePointModel>
+		</bootNotificationRequest>
+	</s:Body>
+</s:Envelope>`
+
+const soapRemoteStartTransactionResponse = `<?xml version="1.0" encoding="UTF-8"?>
+<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope" xmlns:a="http://www.w3.org/2005/08/addressing">
+	<s:Header>
+		<a:RelatesTo>%s</a:RelatesTo>
+		<a:Action>/RemoteStartTransactionResponse</a:Action>
+	</s:Header>
+	<s:Body>
+		<remoteStartTransactionResponse xmlns="urn://Ocpp/Cp/2015/10/">
+			<status>Accepted</status>
+		</remoteStartTransactionResponse>
+	</s:Body>
+</s:Envelope>`
+
+type soapBootNotificationResponse struct {
+	RelatesTo string `xml:"Header>RelatesTo"`
+	Action    string `xml:"Header>Action"`
+	Response  struct {
+		Status      string `xml:"status"`
+		CurrentTime string `xml:"currentTime"`
+		Interval    int    `xml:"interval"`
+	} `xml:"Body>bootNotificationResponse"`
+}
+
+type soapRemoteStartTransactionRequest struct {
+	ChargeBoxIdentity string `xml:"Header>chargeBoxIdentity"`
+	MessageID         string `xml:"Header>MessageID"`
+	Action            string `xml:"Header>Action"`
+	Request           struct {
+		ConnectorID int    `xml:"connectorId"`
+		IdTag       string `xml:"idTag"`
+	} `xml:"Body>remoteStartTransactionRequest"`
+}
+
+func (suite *OcppV16TestSuite) TestSoapTransport() {
+	t := suite.T()
+	chargePointID := "cp1"
+	// Charge point simulator, receiving requests from the central system
+	requestC := make(chan soapRemoteStartTransactionRequest, 1)
+	simulator := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request soapRemoteStartTransactionRequest
+		data, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.NoError(t, xml.Unmarshal(data, &request))
+		assert.True(t, strings.HasPrefix(r.Header.Get("Content-Type"), "application/soap+xml"))
+		requestC <- request
+		w.Header().Set("Content-Type", "application/soap+xml; charset=utf-8")
+		_, _ = fmt.Fprintf(w, soapRemoteStartTransactionResponse, request.MessageID)
+	}))
+	defer simulator.Close()
+	// Central system
+	server := soap.NewServer(core.Profile, localauth.Profile, firmware.Profile, reservation.Profile, remotetrigger.Profile, smartcharging.Profile)
+	centralSystem := ocpp16.NewCentralSystem(nil, server)
+	coreListener := &MockCentralSystemCoreListener{}
+	currentTime := types.NewDateTime(time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC))
+	coreListener.On("OnBootNotification", chargePointID, mock.Anything).Return(core.NewBootNotificationConfirmation(currentTime, 60, core.RegistrationStatusAccepted), nil).Run(func(args mock.Arguments) {
+		request := args.Get(1).(*core.BootNotificationRequest)
+		assert.Equal(t, "vendor", request.ChargePointVendor)
+		assert.Equal(t, "model", request.ChargePointModel)
+	})
+	centralSystem.SetCoreHandler(coreListener)
+	connectedC := make(chan string, 1)
+	centralSystem.SetNewChargePointHandler(func(chargePoint ocpp16.ChargePointConnection) {
+		connectedC <- chargePoint.ID()
+	})
+	go centralSystem.Start(0, "/ocpp")
+	defer centralSystem.Stop()
+	require.Eventually(t, func() bool { return server.Addr() != nil }, time.Second, 10*time.Millisecond)
+	url := fmt.Sprintf("http://localhost:%v/ocpp", server.Addr().Port)
+	// BootNotification
+	body := fmt.Sprintf(soapBootNotification, chargePointID, simulator.URL)
+	httpResponse, err := http.Post(url, "application/soap+xml; charset=utf-8", strings.NewReader(body))
+	require.NoError(t, err)
+	data, err := io.ReadAll(httpResponse.Body)
+	_ = httpResponse.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, httpResponse.StatusCode, string(data))
+	var bootResponse soapBootNotificationResponse
+	require.NoError(t, xml.Unmarshal(data, &bootResponse))
+	assert.Equal(t, "urn:uuid:3c6e3bd4-1b52-4d0b-9f0c-4c0e8a4b7f01", bootResponse.RelatesTo)
+	assert.Equal(t, "/BootNotificationResponse", bootResponse.Action)
+	assert.Equal(t, string(core.RegistrationStatusAccepted), bootResponse.Response.Status)
+	assert.Equal(t, 60, bootResponse.Response.Interval)
+	assert.Equal(t, currentTime.FormatTimestamp(), bootResponse.Response.CurrentTime)
+	assert.Equal(t, chargePointID, <-connectedC)
+	coreListener.AssertExpectations(t)
+	// RemoteStartTransaction is posted to the advertised endpoint
+	resultC := make(chan *core.RemoteStartTransactionConfirmation, 1)
+	err = centralSystem.RemoteStartTransaction(chargePointID, func(confirmation *core.RemoteStartTransactionConfirmation, err error) {
+		assert.NoError(t, err)
+		resultC <- confirmation
+	}, "tag1", func(request *core.RemoteStartTransactionRequest) {
+		connectorID := 1
+		request.ConnectorId = &connectorID
+	})
+	require.NoError(t, err)
+	request := <-requestC
+	assert.Equal(t, chargePointID, request.ChargeBoxIdentity)
+	assert.Equal(t, "/RemoteStartTransaction", request.Action)
+	assert.Equal(t, "tag1", request.Request.IdTag)
+	assert.Equal(t, 1, request.Request.ConnectorID)
+	confirmation := <-resultC
+	require.NotNil(t, confirmation)
+	assert.Equal(t, types.RemoteStartStopStatusAccepted, confirmation.Status)
+}
+
+func (suite *OcppV16TestSuite) TestSoapTransportFault() {
+	t := suite.T()
+	server := soap.NewServer(core.Profile)
+	centralSystem := ocpp16.NewCentralSystem(nil, server)
+	go centralSystem.Start(0, "/ocpp")
+	defer centralSystem.Stop()
+	require.Eventually(t, func() bool { return server.Addr() != nil }, time.Second, 10*time.Millisecond)
+	url := fmt.Sprintf("http://localhost:%v/ocpp", server.Addr().Port)
+	// No core handler was set, hence the request is not supported
+	body := fmt.Sprintf(soapBootNotification, "cp1", "http://localhost:1/unused")
+	httpResponse, err := http.Post(url, "application/soap+xml; charset=utf-8", strings.NewReader(body))
+	require.NoError(t, err)
+	data, err := io.ReadAll(httpResponse.Body)
+	_ = httpResponse.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusInternalServerError, httpResponse.StatusCode)
+	var fault struct {
+		Code   string `xml:"Body>Fault>Code>Subcode>Value"`
+		Reason string `xml:"Body>Fault>Reason>Text"`
+	}
+	require.NoError(t, xml.Unmarshal(data, &fault))
+	assert.Equal(t, "o:NotSupported", fault.Code)
+	// Missing identity
+	body = strings.Replace(body, "<chargeBoxIdentity xmlns=\"urn://Ocpp/Cs/2015/10/\">cp1</chargeBoxIdentity>", "", 1)
+	httpResponse, err = http.Post(url, "application/soap+xml; charset=utf-8", strings.NewReader(body))
+	require.NoError(t, err)
+	_ = httpResponse.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, httpResponse.StatusCode)
+}
@@ -0,0 +1,196 @@
+package ocpp16_test
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/clock/clocktest"
+	ocpp16 "github.com/lorenzodonini/ocpp-go/ocpp1.6"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/lorenzodonini/ocpp-go/ws/wstest"
+)
+
+// Issues consecutive transaction IDs, like a central system backed by a database sequence.
+type transactionIDProvider struct {
+	MockCentralSystemCoreListener
+	mutex  sync.Mutex
+	nextID int
+	starts int
+	stops  int
+	errs   []error // Returned by the next invocations, before issuing IDs.
+}
+
+func (p *transactionIDProvider) OnStartTransaction(chargePointId string, request *core.StartTransactionRequest) (*core.StartTransactionConfirmation, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.starts++
+	if len(p.errs) > 0 {
+		err := p.errs[0]
+		p.errs = p.errs[1:]
+		return nil, err
+	}
+	p.nextID++
+	return core.NewStartTransactionConfirmation(types.NewIdTagInfo(types.AuthorizationStatusAccepted), p.nextID), nil
+}
+
+func (p *transactionIDProvider) OnStopTransaction(chargePointId string, request *core.StopTransactionRequest) (*core.StopTransactionConfirmation, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.stops++
+	return core.NewStopTransactionConfirmation(), nil
+}
+
+func (p *transactionIDProvider) invocations() (starts int, stops int) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.starts, p.stops
+}
+
+func newDeduplicationPair(config *ocpp16.TransactionDeduplication) (ocpp16.CentralSystem, ocpp16.ChargePoint, *wstest.Server, *clocktest.FakeClock, *transactionIDProvider) {
+	server := wstest.NewServer()
+	fakeClock := clocktest.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	endpoint := ocppj.NewServer(server, nil, nil, core.Profile)
+	endpoint.SetClock(fakeClock)
+	centralSystem := ocpp16.NewCentralSystem(endpoint, server)
+	centralSystem.SetTransactionDeduplication(config)
+	provider := &transactionIDProvider{}
+	centralSystem.SetCoreHandler(provider)
+	chargePoint := ocpp16.NewChargePoint("cp1", nil, wstest.NewClient(server))
+	return centralSystem, chargePoint, server, fakeClock, provider
+}
+
+func (suite *OcppV16TestSuite) TestStartTransactionDeduplication() {
+	t := suite.T()
+	centralSystem, chargePoint, server, fakeClock, provider := newDeduplicationPair(&ocpp16.TransactionDeduplication{Window: time.Minute})
+	require.NoError(t, wstest.ConnectInMemory(server, centralSystem, chargePoint))
+	defer centralSystem.Stop()
+	defer chargePoint.Stop()
+	timestamp := types.NewDateTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	confirmation, err := chargePoint.StartTransaction(1, "tag1", 100, timestamp)
+	require.NoError(t, err)
+	assert.Equal(t, 1, confirmation.TransactionId)
+	// An exact duplicate returns the previously issued transaction, without invoking the handler
+	fakeClock.Advance(30 * time.Second)
+	confirmation, err = chargePoint.StartTransaction(1, "tag1", 100, timestamp)
+	require.NoError(t, err)
+	assert.Equal(t, 1, confirmation.TransactionId)
+	require.NotNil(t, confirmation.IdTagInfo)
+	assert.Equal(t, types.AuthorizationStatusAccepted, confirmation.IdTagInfo.Status)
+	starts, _ := provider.invocations()
+	assert.Equal(t, 1, starts)
+	// A request differing in any of the key fields is a new transaction
+	confirmation, err = chargePoint.StartTransaction(2, "tag1", 100, timestamp)
+	require.NoError(t, err)
+	assert.Equal(t, 2, confirmation.TransactionId)
+	confirmation, err = chargePoint.StartTransaction(1, "tag1", 101, timestamp)
+	require.NoError(t, err)
+	assert.Equal(t, 3, confirmation.TransactionId)
+	// A near-duplicate outside the window is handled again, and a new transaction ID is issued
+	fakeClock.Advance(31 * time.Second)
+	confirmation, err = chargePoint.StartTransaction(1, "tag1", 100, timestamp)
+	require.NoError(t, err)
+	assert.Equal(t, 4, confirmation.TransactionId)
+	starts, _ = provider.invocations()
+	assert.Equal(t, 4, starts)
+}
+
+func (suite *OcppV16TestSuite) TestStartTransactionDeduplicationErrors() {
+	t := suite.T()
+	centralSystem, chargePoint, server, _, provider := newDeduplicationPair(&ocpp16.TransactionDeduplication{})
+	provider.errs = []error{errors.New("database unavailable")}
+	require.NoError(t, wstest.ConnectInMemory(server, centralSystem, chargePoint))
+	defer centralSystem.Stop()
+	defer chargePoint.Stop()
+	timestamp := types.NewDateTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	_, err := chargePoint.StartTransaction(1, "tag1", 100, timestamp)
+	require.Error(t, err)
+	// Errors are not retained, hence the retry is handled again
+	confirmation, err := chargePoint.StartTransaction(1, "tag1", 100, timestamp)
+	require.NoError(t, err)
+	assert.Equal(t, 1, confirmation.TransactionId)
+	confirmation, err = chargePoint.StartTransaction(1, "tag1", 100, timestamp)
+	require.NoError(t, err)
+	assert.Equal(t, 1, confirmation.TransactionId)
+	starts, _ := provider.invocations()
+	assert.Equal(t, 2, starts)
+}
+
+func (suite *OcppV16TestSuite) TestStopTransactionDeduplication() {
+	t := suite.T()
+	centralSystem, chargePoint, server, _, provider := newDeduplicationPair(&ocpp16.TransactionDeduplication{})
+	require.NoError(t, wstest.ConnectInMemory(server, centralSystem, chargePoint))
+	defer centralSystem.Stop()
+	defer chargePoint.Stop()
+	timestamp := types.NewDateTime(time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC))
+	for i := 0; i < 2; i++ {
+		_, err := chargePoint.StopTransaction(200, timestamp, 1)
+		require.NoError(t, err)
+	}
+	_, stops := provider.invocations()
+	assert.Equal(t, 1, stops)
+	_, err := chargePoint.StopTransaction(200, timestamp, 2)
+	require.NoError(t, err)
+	_, stops = provider.invocations()
+	assert.Equal(t, 2, stops)
+}
+
+func (suite *OcppV16TestSuite) TestTransactionDeduplicationBounded() {
+	t := suite.T()
+	centralSystem, chargePoint, server, _, _ := newDeduplicationPair(&ocpp16.TransactionDeduplication{MaxEntries: 1})
+	require.NoError(t, wstest.ConnectInMemory(server, centralSystem, chargePoint))
+	defer centralSystem.Stop()
+	defer chargePoint.Stop()
+	timestamp := types.NewDateTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	confirmation, err := chargePoint.StartTransaction(1, "tag1", 100, timestamp)
+	require.NoError(t, err)
+	assert.Equal(t, 1, confirmation.TransactionId)
+	confirmation, err = chargePoint.StartTransaction(2, "tag2", 100, timestamp)
+	require.NoError(t, err)
+	assert.Equal(t, 2, confirmation.TransactionId)
+	// The first confirmation was discarded, once the maximum amount of entries was reached
+	confirmation, err = chargePoint.StartTransaction(1, "tag1", 100, timestamp)
+	require.NoError(t, err)
+	assert.Equal(t, 3, confirmation.TransactionId)
+}
+
+func (suite *OcppV16TestSuite) TestTransactionDeduplicationDisabled() {
+	t := suite.T()
+	// A custom key function may exclude requests from the detection
+	centralSystem, chargePoint, server, _, provider := newDeduplicationPair(&ocpp16.TransactionDeduplication{
+		StartTransactionKey: func(chargePointID string, request *core.StartTransactionRequest) string {
+			if request.ReservationId != nil {
+				return ""
+			}
+			return ocpp16.DefaultStartTransactionKey(chargePointID, request)
+		},
+	})
+	require.NoError(t, wstest.ConnectInMemory(server, centralSystem, chargePoint))
+	defer centralSystem.Stop()
+	defer chargePoint.Stop()
+	timestamp := types.NewDateTime(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	withReservation := func(request *core.StartTransactionRequest) {
+		reservationID := 5
+		request.ReservationId = &reservationID
+	}
+	for i := 1; i <= 2; i++ {
+		confirmation, err := chargePoint.StartTransaction(1, "tag1", 100, timestamp, withReservation)
+		require.NoError(t, err)
+		assert.Equal(t, i, confirmation.TransactionId)
+	}
+	starts, _ := provider.invocations()
+	assert.Equal(t, 2, starts)
+	// Without deduplication, every request is handled
+	centralSystem.SetTransactionDeduplication(nil)
+	confirmation, err := chargePoint.StartTransaction(1, "tag1", 100, timestamp)
+	require.NoError(t, err)
+	assert.Equal(t, 3, confirmation.TransactionId)
+	confirmation, err = chargePoint.StartTransaction(1, "tag1", 100, timestamp)
+	require.NoError(t, err)
+	assert.Equal(t, 4, confirmation.TransactionId)
+}
@@ -4,7 +4,11 @@ import (
 	"fmt"
 
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/firmware"
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/remotetrigger"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/lorenzodonini/ocpp-go/ws/wstest"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -17,13 +21,154 @@ func (suite *OcppV16TestSuite) TestTriggerMessageRequestValidation() {
 		{remotetrigger.TriggerMessageRequest{RequestedMessage: core.StatusNotificationFeatureName, ConnectorId: newInt(1)}, true},
 		{remotetrigger.TriggerMessageRequest{RequestedMessage: core.StatusNotificationFeatureName}, true},
 		{remotetrigger.TriggerMessageRequest{}, false},
-		{remotetrigger.TriggerMessageRequest{RequestedMessage: core.StatusNotificationFeatureName, ConnectorId: newInt(0)}, true},
+		{remotetrigger.TriggerMessageRequest{RequestedMessage: core.StatusNotificationFeatureName, ConnectorId: newInt(0)}, false},
 		{remotetrigger.TriggerMessageRequest{RequestedMessage: core.StatusNotificationFeatureName, ConnectorId: newInt(-1)}, false},
 		{remotetrigger.TriggerMessageRequest{RequestedMessage: core.StartTransactionFeatureName}, false},
 	}
 	ExecuteGenericTestTable(t, requestTable)
 }
 
+var allMessageTriggers = []remotetrigger.MessageTrigger{
+	core.BootNotificationFeatureName,
+	firmware.DiagnosticsStatusNotificationFeatureName,
+	firmware.FirmwareStatusNotificationFeatureName,
+	core.HeartbeatFeatureName,
+	core.MeterValuesFeatureName,
+	core.StatusNotificationFeatureName,
+}
+
+func (suite *OcppV16TestSuite) TestTriggerMessageConnectorScope() {
+	t := suite.T()
+	for _, trigger := range allMessageTriggers {
+		connectorTrigger := remotetrigger.IsConnectorTrigger(trigger)
+		var testTable = []struct {
+			connectorId *int
+			valid       bool
+		}{
+			{nil, true},
+			{newInt(1), connectorTrigger},
+			{newInt(0), false},
+			{newInt(-1), false},
+		}
+		for _, tc := range testTable {
+			request := remotetrigger.TriggerMessageRequest{RequestedMessage: trigger, ConnectorId: tc.connectorId}
+			assert.Equal(t, tc.valid, types.Validate.Struct(request) == nil, "%v with connectorId %v", trigger, tc.connectorId)
+			err := request.ValidateConnectorScope()
+			assert.Equal(t, tc.valid, err == nil, "%v with connectorId %v", trigger, tc.connectorId)
+			if err != nil {
+				var scopeErr *remotetrigger.ConnectorScopeError
+				require.ErrorAs(t, err, &scopeErr)
+				assert.Equal(t, trigger, scopeErr.RequestedMessage)
+				assert.Equal(t, *tc.connectorId, scopeErr.ConnectorId)
+			}
+		}
+	}
+}
+
+func (suite *OcppV16TestSuite) TestTriggerMessageConnectorScopeBeforeSend() {
+	t := suite.T()
+	centralSystem, chargePoint, server := newInMemoryPair("cp1")
+	triggerListener := &MockChargePointRemoteTriggerListener{}
+	triggerListener.On("OnTriggerMessage", mock.Anything).Return(remotetrigger.NewTriggerMessageConfirmation(remotetrigger.TriggerMessageStatusAccepted), nil)
+	chargePoint.SetRemoteTriggerHandler(triggerListener)
+	require.NoError(t, wstest.ConnectInMemory(server, centralSystem, chargePoint))
+	defer centralSystem.Stop()
+	defer chargePoint.Stop()
+	// The request is rejected before being sent, even without message validation
+	ocppj.SetMessageValidation(false)
+	defer ocppj.SetMessageValidation(true)
+	err := centralSystem.TriggerMessage("cp1", func(*remotetrigger.TriggerMessageConfirmation, error) {
+		assert.Fail(t, "callback invoked for invalid request")
+	}, core.HeartbeatFeatureName, func(request *remotetrigger.TriggerMessageRequest) {
+		request.ConnectorId = newInt(1)
+	})
+	var scopeErr *remotetrigger.ConnectorScopeError
+	require.ErrorAs(t, err, &scopeErr)
+	assert.EqualError(t, err, "connectorId 1 doesn't apply to Heartbeat, only to StatusNotification and MeterValues")
+	triggerListener.AssertNotCalled(t, "OnTriggerMessage", mock.Anything)
+	// Connector triggers are sent
+	resultC := make(chan remotetrigger.TriggerMessageStatus, 1)
+	err = centralSystem.TriggerMessage("cp1", func(confirmation *remotetrigger.TriggerMessageConfirmation, err error) {
+		require.NoError(t, err)
+		resultC <- confirmation.Status
+	}, core.MeterValuesFeatureName, func(request *remotetrigger.TriggerMessageRequest) {
+		request.ConnectorId = newInt(1)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, remotetrigger.TriggerMessageStatusAccepted, <-resultC)
+}
+
+func (suite *OcppV16TestSuite) TestAutoResponder16Decision() {
+	t := suite.T()
+	responder := remotetrigger.NewAutoResponder16(2)
+	producedC := make(chan remotetrigger.MessageTrigger, 20)
+	produce := func(trigger remotetrigger.MessageTrigger) remotetrigger.TriggerProducer {
+		return func(connectorId *int) error {
+			producedC <- trigger
+			return nil
+		}
+	}
+	// Diagnostics are supported, but no upload is configured yet
+	responder.Support(firmware.DiagnosticsStatusNotificationFeatureName)
+	for _, trigger := range []remotetrigger.MessageTrigger{core.BootNotificationFeatureName, core.HeartbeatFeatureName, core.MeterValuesFeatureName, core.StatusNotificationFeatureName} {
+		responder.Register(trigger, produce(trigger))
+	}
+	// Meter values are unavailable while the meter is faulted
+	meterFaulted := true
+	responder.SetCondition(core.MeterValuesFeatureName, func(connectorId *int) bool {
+		return !meterFaulted
+	})
+	var testTable = []struct {
+		trigger     remotetrigger.MessageTrigger
+		connectorId *int
+		expected    remotetrigger.TriggerMessageStatus
+	}{
+		{core.BootNotificationFeatureName, nil, remotetrigger.TriggerMessageStatusAccepted},
+		{core.BootNotificationFeatureName, newInt(1), remotetrigger.TriggerMessageStatusAccepted},
+		{firmware.DiagnosticsStatusNotificationFeatureName, nil, remotetrigger.TriggerMessageStatusRejected},
+		{firmware.DiagnosticsStatusNotificationFeatureName, newInt(1), remotetrigger.TriggerMessageStatusRejected},
+		{firmware.FirmwareStatusNotificationFeatureName, nil, remotetrigger.TriggerMessageStatusNotImplemented},
+		{firmware.FirmwareStatusNotificationFeatureName, newInt(1), remotetrigger.TriggerMessageStatusNotImplemented},
+		{core.HeartbeatFeatureName, nil, remotetrigger.TriggerMessageStatusAccepted},
+		{core.HeartbeatFeatureName, newInt(3), remotetrigger.TriggerMessageStatusAccepted},
+		{core.MeterValuesFeatureName, nil, remotetrigger.TriggerMessageStatusRejected},
+		{core.MeterValuesFeatureName, newInt(1), remotetrigger.TriggerMessageStatusRejected},
+		{core.StatusNotificationFeatureName, nil, remotetrigger.TriggerMessageStatusAccepted},
+		{core.StatusNotificationFeatureName, newInt(2), remotetrigger.TriggerMessageStatusAccepted},
+		{core.StatusNotificationFeatureName, newInt(3), remotetrigger.TriggerMessageStatusRejected},
+		{core.StatusNotificationFeatureName, newInt(0), remotetrigger.TriggerMessageStatusRejected},
+		{core.StartTransactionFeatureName, nil, remotetrigger.TriggerMessageStatusNotImplemented},
+	}
+	accepted := 0
+	for _, tc := range testTable {
+		request := remotetrigger.NewTriggerMessageRequest(tc.trigger)
+		request.ConnectorId = tc.connectorId
+		confirmation, err := responder.OnTriggerMessage(request)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, confirmation.Status, "%v with connectorId %v", tc.trigger, tc.connectorId)
+		if confirmation.Status == remotetrigger.TriggerMessageStatusAccepted {
+			accepted++
+		}
+	}
+	for i := 0; i < accepted; i++ {
+		<-producedC
+	}
+	// Once the meter recovered and diagnostics are configured, the requests are accepted
+	meterFaulted = false
+	responder.Register(firmware.DiagnosticsStatusNotificationFeatureName, produce(firmware.DiagnosticsStatusNotificationFeatureName))
+	for _, trigger := range []remotetrigger.MessageTrigger{core.MeterValuesFeatureName, firmware.DiagnosticsStatusNotificationFeatureName} {
+		confirmation, err := responder.OnTriggerMessage(remotetrigger.NewTriggerMessageRequest(trigger))
+		require.NoError(t, err)
+		assert.Equal(t, remotetrigger.TriggerMessageStatusAccepted, confirmation.Status, trigger)
+		assert.Equal(t, trigger, <-producedC)
+	}
+	// Without producer, supported triggers are rejected again
+	responder.Unregister(firmware.DiagnosticsStatusNotificationFeatureName)
+	confirmation, err := responder.OnTriggerMessage(remotetrigger.NewTriggerMessageRequest(firmware.DiagnosticsStatusNotificationFeatureName))
+	require.NoError(t, err)
+	assert.Equal(t, remotetrigger.TriggerMessageStatusRejected, confirmation.Status)
+}
+
 func (suite *OcppV16TestSuite) TestTriggerMessageConfirmationValidation() {
 	t := suite.T()
 	confirmationTable := []GenericTestEntry{
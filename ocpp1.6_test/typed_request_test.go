@@ -0,0 +1,119 @@
+package ocpp16_test
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	ocpp16 "github.com/lorenzodonini/ocpp-go/ocpp1.6"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+)
+
+func (suite *OcppV16TestSuite) TestTypedSendFromChargePoint() {
+	t := suite.T()
+	wsId := "test_id"
+	wsUrl := "someUrl"
+	currentTime := types.NewDateTime(time.Now())
+	idTag := "12345"
+	channel := NewMockWebSocket(wsId)
+
+	coreListener := &MockCentralSystemCoreListener{}
+	coreListener.On("OnHeartbeat", mock.AnythingOfType("string"), mock.Anything).Return(core.NewHeartbeatConfirmation(currentTime), nil)
+	coreListener.On("OnAuthorize", mock.AnythingOfType("string"), mock.Anything).Return(core.NewAuthorizationConfirmation(types.NewIdTagInfo(types.AuthorizationStatusAccepted)), nil)
+	setupDefaultCentralSystemHandlers(suite, coreListener, expectedCentralSystemOptions{clientId: wsId, forwardWrittenMessage: true})
+	setupDefaultChargePointHandlers(suite, nil, expectedChargePointOptions{serverUrl: wsUrl, clientId: wsId, createChannelOnStart: true, channel: channel, forwardWrittenMessage: true})
+	// Run Test
+	suite.centralSystem.Start(8887, "somePath")
+	err := suite.chargePoint.Start(wsUrl)
+	require.Nil(t, err)
+	ctx := context.Background()
+	heartbeat, err := ocpp16.Send[*core.HeartbeatConfirmation](ctx, suite.chargePoint, core.NewHeartbeatRequest())
+	require.NoError(t, err)
+	require.NotNil(t, heartbeat)
+	assertDateTimeEquality(t, *currentTime, *heartbeat.CurrentTime)
+	authorize, err := ocpp16.Send[*core.AuthorizeConfirmation](ctx, suite.chargePoint, core.NewAuthorizationRequest(idTag))
+	require.NoError(t, err)
+	require.NotNil(t, authorize)
+	assert.Equal(t, types.AuthorizationStatusAccepted, authorize.IdTagInfo.Status)
+	// Wrong type parameter
+	authorize, err = ocpp16.Send[*core.AuthorizeConfirmation](ctx, suite.chargePoint, core.NewHeartbeatRequest())
+	require.Error(t, err)
+	assert.Nil(t, authorize)
+	typeErr, ok := err.(*ocpp.ResponseTypeError)
+	require.True(t, ok)
+	assert.Equal(t, reflect.TypeOf(&core.AuthorizeConfirmation{}), typeErr.Expected)
+	assert.Equal(t, reflect.TypeOf(&core.HeartbeatConfirmation{}), typeErr.Actual)
+}
+
+func (suite *OcppV16TestSuite) TestTypedSendToChargePoint() {
+	t := suite.T()
+	wsId := "test_id"
+	wsUrl := "someUrl"
+	channel := NewMockWebSocket(wsId)
+
+	coreListener := &MockChargePointCoreListener{}
+	coreListener.On("OnReset", mock.Anything).Return(core.NewResetConfirmation(core.ResetStatusAccepted), nil)
+	coreListener.On("OnChangeAvailability", mock.Anything).Return(core.NewChangeAvailabilityConfirmation(core.AvailabilityStatusScheduled), nil)
+	setupDefaultCentralSystemHandlers(suite, nil, expectedCentralSystemOptions{clientId: wsId, forwardWrittenMessage: true})
+	setupDefaultChargePointHandlers(suite, coreListener, expectedChargePointOptions{serverUrl: wsUrl, clientId: wsId, createChannelOnStart: true, channel: channel, forwardWrittenMessage: true})
+	// Run Test
+	suite.centralSystem.Start(8887, "somePath")
+	err := suite.chargePoint.Start(wsUrl)
+	require.Nil(t, err)
+	ctx := context.Background()
+	reset, err := ocpp16.SendTo[*core.ResetConfirmation](ctx, suite.centralSystem, wsId, core.NewResetRequest(core.ResetTypeSoft))
+	require.NoError(t, err)
+	require.NotNil(t, reset)
+	assert.Equal(t, core.ResetStatusAccepted, reset.Status)
+	// Typed callback
+	resultC := make(chan *core.ChangeAvailabilityConfirmation, 1)
+	err = suite.centralSystem.SendRequestAsync(wsId, core.NewChangeAvailabilityRequest(1, core.AvailabilityTypeInoperative), ocpp.TypedCallback(func(confirmation *core.ChangeAvailabilityConfirmation, err error) {
+		assert.NoError(t, err)
+		resultC <- confirmation
+	}))
+	require.NoError(t, err)
+	confirmation := <-resultC
+	require.NotNil(t, confirmation)
+	assert.Equal(t, core.AvailabilityStatusScheduled, confirmation.Status)
+	// Wrong type parameter
+	changeAvailability, err := ocpp16.SendTo[*core.ChangeAvailabilityConfirmation](ctx, suite.centralSystem, wsId, core.NewResetRequest(core.ResetTypeSoft))
+	require.Error(t, err)
+	assert.Nil(t, changeAvailability)
+	assert.IsType(t, &ocpp.ResponseTypeError{}, err)
+	errC := make(chan error, 1)
+	err = suite.centralSystem.SendRequestAsync(wsId, core.NewResetRequest(core.ResetTypeHard), ocpp.TypedCallback(func(confirmation *core.ChangeAvailabilityConfirmation, err error) {
+		assert.Nil(t, confirmation)
+		errC <- err
+	}))
+	require.NoError(t, err)
+	assert.IsType(t, &ocpp.ResponseTypeError{}, <-errC)
+}
+
+func (suite *OcppV16TestSuite) TestTypedSendToContextCanceled() {
+	t := suite.T()
+	wsId := "test_id"
+	wsUrl := "someUrl"
+	channel := NewMockWebSocket(wsId)
+	// The charge point never replies
+	setupDefaultCentralSystemHandlers(suite, nil, expectedCentralSystemOptions{clientId: wsId, forwardWrittenMessage: false})
+	setupDefaultChargePointHandlers(suite, nil, expectedChargePointOptions{serverUrl: wsUrl, clientId: wsId, createChannelOnStart: true, channel: channel, forwardWrittenMessage: true})
+	// Run Test
+	suite.centralSystem.Start(8887, "somePath")
+	err := suite.chargePoint.Start(wsUrl)
+	require.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	reset, err := ocpp16.SendTo[*core.ResetConfirmation](ctx, suite.centralSystem, wsId, core.NewResetRequest(core.ResetTypeSoft))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Nil(t, reset)
+	// The request was withdrawn
+	assert.Eventually(t, func() bool {
+		return !suite.ocppjCentralSystem.RequestState.HasPendingRequest(wsId)
+	}, time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,125 @@
+package ocpp16_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	ocpp16 "github.com/lorenzodonini/ocpp-go/ocpp1.6"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/lorenzodonini/ocpp-go/ws"
+)
+
+// pingRecordingServer is a raw websocket server, recording the pings received from a charge point.
+type pingRecordingServer struct {
+	server   *httptest.Server
+	connC    chan *websocket.Conn
+	pingC    chan time.Time
+	messageC chan string
+}
+
+func newPingRecordingServer() *pingRecordingServer {
+	s := &pingRecordingServer{
+		connC:    make(chan *websocket.Conn, 1),
+		pingC:    make(chan time.Time, 10),
+		messageC: make(chan string, 1),
+	}
+	upgrader := websocket.Upgrader{Subprotocols: []string{types.V16Subprotocol}}
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conn.SetPingHandler(func(appData string) error {
+			s.pingC <- time.Now()
+			return conn.WriteControl(websocket.PongMessage, []byte(appData), time.Now().Add(time.Second))
+		})
+		s.connC <- conn
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			s.messageC <- string(data)
+		}
+	}))
+	return s
+}
+
+func (s *pingRecordingServer) url() string {
+	return strings.Replace(s.server.URL, "http", "ws", 1) + "/test_id"
+}
+
+func (s *pingRecordingServer) countPings(duration time.Duration) int {
+	count := 0
+	timeout := time.After(duration)
+	for {
+		select {
+		case <-s.pingC:
+			count++
+		case <-timeout:
+			return count
+		}
+	}
+}
+
+func (suite *OcppV16TestSuite) TestApplyWebSocketPingInterval() {
+	t := suite.T()
+	server := newPingRecordingServer()
+	defer server.server.Close()
+	wsClient := ws.NewClient()
+	timeoutConfig := ws.NewClientTimeoutConfig()
+	timeoutConfig.PingPeriod = 10 * time.Second
+	wsClient.SetTimeoutConfig(timeoutConfig)
+	chargePoint := ocpp16.NewChargePoint("test_id", nil, wsClient)
+	coreListener := &MockChargePointCoreListener{}
+	coreListener.On("OnChangeConfiguration", mock.Anything).Return(core.NewChangeConfigurationConfirmation(core.ConfigurationStatusAccepted), nil)
+	chargePoint.SetCoreHandler(coreListener)
+	err := chargePoint.Start(server.url())
+	require.NoError(t, err)
+	defer chargePoint.Stop()
+	conn := <-server.connC
+	changeInterval := func(messageId string, value string) string {
+		request := fmt.Sprintf(`[2,"%v","%v",{"key":"%v","value":"%v"}]`, messageId, core.ChangeConfigurationFeatureName, core.WebSocketPingIntervalKey, value)
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(request)))
+		select {
+		case response := <-server.messageC:
+			return response
+		case <-time.After(time.Second):
+			t.Fatal("no response received")
+		}
+		return ""
+	}
+	// No pings are sent with the initial configuration
+	assert.Equal(t, 0, server.countPings(500*time.Millisecond))
+	// Enable pings every second
+	response := changeInterval("1", "1")
+	assert.Equal(t, `[3,"1",{"status":"Accepted"}]`, response)
+	assert.GreaterOrEqual(t, server.countPings(2500*time.Millisecond), 2)
+	// Disable pings
+	response = changeInterval("2", "0")
+	assert.Equal(t, `[3,"2",{"status":"Accepted"}]`, response)
+	server.countPings(100 * time.Millisecond)
+	assert.Equal(t, 0, server.countPings(1500*time.Millisecond))
+	// Negative values are rejected without invoking the handler
+	response = changeInterval("3", "-5")
+	assert.Equal(t, `[3,"3",{"status":"Rejected"}]`, response)
+	coreListener.AssertNumberOfCalls(t, "OnChangeConfiguration", 2)
+	assert.True(t, chargePoint.IsConnected())
+	assert.Error(t, chargePoint.ApplyWebSocketPingInterval(-1))
+}
+
+func (suite *OcppV16TestSuite) TestApplyWebSocketPingIntervalUnsupported() {
+	t := suite.T()
+	// The mock websocket client doesn't support changing the ping period
+	err := suite.chargePoint.ApplyWebSocketPingInterval(10)
+	assert.ErrorContains(t, err, "not supported")
+}
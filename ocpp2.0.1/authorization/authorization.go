@@ -1,7 +1,11 @@
 // The authorization functional block contains OCPP 2.0 authorization-related features. It contains different ways of authorizing a user, online and/or offline .
 package authorization
 
-import "github.com/lorenzodonini/ocpp-go/ocpp"
+import (
+	"context"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+)
 
 // Needs to be implemented by a CSMS for handling messages part of the OCPP 2.0 Authorization profile.
 type CSMSHandler interface {
@@ -9,6 +13,29 @@ type CSMSHandler interface {
 	OnAuthorize(chargingStationID string, request *AuthorizeRequest) (confirmation *AuthorizeResponse, err error)
 }
 
+// Context-aware variant of CSMSHandler, for handling messages part of the OCPP 2.0 Authorization profile.
+type CSMSHandlerWithContext interface {
+	// OnAuthorize is called on the CSMS whenever an AuthorizeRequest is received from a charging station.
+	OnAuthorize(ctx context.Context, chargingStationID string, request *AuthorizeRequest) (confirmation *AuthorizeResponse, err error)
+}
+
+// AdaptCSMSHandler converts a CSMSHandler into a CSMSHandlerWithContext, which ignores the context.
+// Returns nil if handler is nil.
+func AdaptCSMSHandler(handler CSMSHandler) CSMSHandlerWithContext {
+	if handler == nil {
+		return nil
+	}
+	return csmsHandlerAdapter{handler: handler}
+}
+
+type csmsHandlerAdapter struct {
+	handler CSMSHandler
+}
+
+func (a csmsHandlerAdapter) OnAuthorize(ctx context.Context, chargingStationID string, request *AuthorizeRequest) (*AuthorizeResponse, error) {
+	return a.handler.OnAuthorize(chargingStationID, request)
+}
+
 // Needs to be implemented by Charging stations for handling messages part of the OCPP 2.0 Authorization profile.
 type ChargingStationHandler interface {
 	// OnClearCache is called on a charging station whenever a ClearCacheRequest is received from the CSMS.
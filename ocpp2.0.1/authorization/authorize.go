@@ -5,6 +5,7 @@ import (
 
 	"gopkg.in/go-playground/validator.v9"
 
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/idtoken"
 	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
 )
 
@@ -40,6 +41,7 @@ type AuthorizeRequest struct {
 	Certificate         string                      `json:"certificate,omitempty" validate:"max=5500"`
 	IdToken             types.IdToken               `json:"idToken" validate:"required"`
 	CertificateHashData []types.OCSPRequestDataType `json:"iso15118CertificateHashData,omitempty" validate:"max=4,dive"`
+	CustomData          *types.CustomData           `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // This field definition of the Authorize response payload, sent by the Charging Station to the CSMS in response to an AuthorizeRequest.
@@ -47,6 +49,7 @@ type AuthorizeRequest struct {
 type AuthorizeResponse struct {
 	CertificateStatus AuthorizeCertificateStatus `json:"certificateStatus,omitempty" validate:"omitempty,authorizeCertificateStatus"`
 	IdTokenInfo       types.IdTokenInfo          `json:"idTokenInfo" validate:"required"`
+	CustomData        *types.CustomData          `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // Before the owner of an electric vehicle can start or stop charging, the Charging Station has to authorize the operation.
@@ -89,6 +92,14 @@ func NewAuthorizationResponse(idTokenInfo types.IdTokenInfo) *AuthorizeResponse
 	return &AuthorizeResponse{IdTokenInfo: idTokenInfo}
 }
 
+func validateAuthorizeRequest(sl validator.StructLevel) {
+	request := sl.Current().Interface().(AuthorizeRequest)
+	if err := idtoken.Validate(request.IdToken); err != nil {
+		sl.ReportError(request.IdToken, "IdToken", "idToken", "idTokenFormat", "")
+	}
+}
+
 func init() {
-	_ = types.Validate.RegisterValidation("authorizeCertificateStatus", isValidAuthorizeCertificateStatus)
+	_ = types.RegisterValidation("authorizeCertificateStatus", isValidAuthorizeCertificateStatus)
+	types.RegisterStructValidation(validateAuthorizeRequest, AuthorizeRequest{})
 }
@@ -0,0 +1,226 @@
+package authorization
+
+import (
+	"crypto/x509"
+	"errors"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/clock"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/iso15118/certhelper"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// ErrAuthorizeTimeout is returned within a Decision, if the CSMS didn't respond to an AuthorizeRequest within the timeout of the Authorizer.
+var ErrAuthorizeTimeout = errors.New("authorize request timed out")
+
+// AuthorizeSender sends AuthorizeRequests to the CSMS. It is implemented by ocpp2.ChargingStation.
+type AuthorizeSender interface {
+	Authorize(idToken string, tokenType types.IdTokenType, props ...func(request *AuthorizeRequest)) (*AuthorizeResponse, error)
+}
+
+// TokenLookup returns the locally known authorization of an idToken. It is implemented by localauth.ListManager201.
+type TokenLookup interface {
+	Lookup(idToken string, tokenType types.IdTokenType) (types.IdTokenInfo, bool)
+}
+
+// AuthorizationCache is an authorization cache, which is looked up and updated by an Authorizer.
+type AuthorizationCache interface {
+	Cache
+	TokenLookup
+	// Store records the IdTokenInfo returned by the CSMS for an idToken, replacing any previous entry.
+	Store(idToken types.IdToken, info types.IdTokenInfo) error
+}
+
+// DecisionSource indicates how an Authorizer reached a decision.
+type DecisionSource string
+
+const (
+	DecisionSourceCSMS          DecisionSource = "CSMS"          // The CSMS responded to the AuthorizeRequest.
+	DecisionSourceLocalList     DecisionSource = "LocalList"     // The CSMS was unreachable, the idToken was found in the local authorization list.
+	DecisionSourceCache         DecisionSource = "Cache"         // The CSMS was unreachable, the idToken was found in the authorization cache.
+	DecisionSourceOfflinePolicy DecisionSource = "OfflinePolicy" // The CSMS was unreachable and the idToken is unknown: OfflineTxForUnknownIdEnabled applies.
+	DecisionSourceCertificate   DecisionSource = "Certificate"   // The certificate was rejected locally, the CSMS wasn't asked.
+	DecisionSourceTransaction   DecisionSource = "Transaction"   // The idToken started the transaction it stops (see AuthorizeStop), the CSMS wasn't asked.
+)
+
+// Decision is the normalized result of an authorization, regardless of whether it was obtained online or offline.
+type Decision struct {
+	// True if charging may start (or a transaction may be stopped) for the idToken.
+	Accepted bool
+	// The authorization of the idToken. For group idTokens evaluated offline, the status reflects the status of the group.
+	IdTokenInfo types.IdTokenInfo
+	// The status of the certificate, if a certificate was passed and either validated locally or by the CSMS.
+	CertificateStatus AuthorizeCertificateStatus
+	Source            DecisionSource
+	// True if the CSMS couldn't be reached, i.e. the decision was made locally.
+	Offline bool
+	// The error which caused an offline decision or a local certificate rejection, if any.
+	Err error
+}
+
+// Authorizer authorizes idTokens on a charging station, asking the CSMS first and falling back to local
+// authorization if the CSMS is unreachable:
+//
+//	authorizer := authorization.NewAuthorizer(chargingStation, listManager, cache)
+//	authorizer.LocalAuthorizeOffline = true
+//	decision := authorizer.Authorize(authorization.NewAuthorizationRequest("0102030405", types.IdTokenTypeISO14443))
+//
+// Responses of the CSMS are recorded in the authorization cache, if any. While offline, the local authorization list
+// takes precedence over the cache, so that e.g. a token blocked via the local list cannot be authorized by a stale cache entry.
+// Any error returned while sending the request (e.g. a timeout or a missing connection) is treated as the CSMS being unreachable.
+//
+// The configuration fields must be set before the Authorizer is used. Authorize may then be called concurrently.
+type Authorizer struct {
+	// The maximum time to wait for the CSMS to respond. Zero means waiting as long as the charging station does (see its request timeout).
+	Timeout time.Duration
+	// If true, the local authorization list and the cache are used while offline (LocalAuthorizeOffline of the AuthCtrlr).
+	LocalAuthorizeOffline bool
+	// If true, idTokens not known locally are accepted while offline (OfflineTxForUnknownIdEnabled of the AuthCtrlr).
+	OfflineTxForUnknownIdEnabled bool
+	// If set, certificates passed with an AuthorizeRequest are validated locally against these MO roots, before asking the CSMS.
+	ContractRoots *x509.CertPool
+	sender        AuthorizeSender
+	localList     TokenLookup
+	cache         AuthorizationCache
+	clock         clock.Clock
+}
+
+// NewAuthorizer creates an authorizer. The local list and the cache are optional and may be nil, e.g. if disabled.
+// If sender is nil, all idTokens are authorized offline.
+func NewAuthorizer(sender AuthorizeSender, localList TokenLookup, cache AuthorizationCache) *Authorizer {
+	return &Authorizer{sender: sender, localList: localList, cache: cache, clock: clock.New()}
+}
+
+// SetTimeSource replaces the function used for retrieving the current time, while timers are backed by the real clock.
+//
+// Deprecated: Use SetClock instead.
+func (a *Authorizer) SetTimeSource(now func() time.Time) {
+	a.SetClock(clock.WithNow(now))
+}
+
+// SetClock sets the clock used for retrieving the current time and for the Timeout.
+// Passing nil restores the real clock.
+func (a *Authorizer) SetClock(c clock.Clock) {
+	a.clock = clock.OrDefault(c)
+}
+
+// Authorize authorizes the idToken contained in the request. The certificate and certificate hash data of the request
+// are forwarded to the CSMS as well.
+func (a *Authorizer) Authorize(request *AuthorizeRequest) Decision {
+	if request.Certificate != "" && a.ContractRoots != nil {
+		// Contract certificates are signing end-entity certificates, just like a V2G leaf certificate
+		if err := certhelper.ValidateChainAt(request.Certificate, a.ContractRoots, types.V2GCertificateChain, a.clock.Now()); err != nil {
+			return Decision{
+				IdTokenInfo:       *types.NewIdTokenInfo(types.AuthorizationStatusInvalid),
+				CertificateStatus: certificateStatusOf(err),
+				Source:            DecisionSourceCertificate,
+				Err:               err,
+			}
+		}
+	}
+	err := errors.New("no connection to the CSMS")
+	if a.sender != nil {
+		var response *AuthorizeResponse
+		response, err = a.send(request)
+		if err == nil {
+			if a.cache != nil {
+				// A failing cache must not prevent the authorization
+				_ = a.cache.Store(request.IdToken, response.IdTokenInfo)
+			}
+			return Decision{
+				Accepted:          response.IdTokenInfo.Status == types.AuthorizationStatusAccepted && (response.CertificateStatus == "" || response.CertificateStatus == CertificateStatusAccepted),
+				IdTokenInfo:       response.IdTokenInfo,
+				CertificateStatus: response.CertificateStatus,
+				Source:            DecisionSourceCSMS,
+			}
+		}
+	}
+	return a.authorizeOffline(request.IdToken, err)
+}
+
+func (a *Authorizer) authorizeOffline(idToken types.IdToken, cause error) Decision {
+	decision := Decision{Offline: true, Err: cause}
+	if a.LocalAuthorizeOffline {
+		if info, source, ok := a.lookup(idToken.IdToken, idToken.Type); ok {
+			decision.IdTokenInfo = info
+			decision.Source = source
+			// An accepted token may not be used, if its group isn't accepted
+			if group := info.GroupIdToken; info.Status == types.AuthorizationStatusAccepted && group != nil {
+				if groupInfo, _, ok := a.lookup(group.IdToken, group.Type); ok && groupInfo.Status != types.AuthorizationStatusAccepted {
+					decision.IdTokenInfo.Status = groupInfo.Status
+				}
+			}
+			decision.Accepted = decision.IdTokenInfo.Status == types.AuthorizationStatusAccepted
+			return decision
+		}
+	}
+	decision.Source = DecisionSourceOfflinePolicy
+	if a.OfflineTxForUnknownIdEnabled {
+		decision.Accepted = true
+		decision.IdTokenInfo = *types.NewIdTokenInfo(types.AuthorizationStatusAccepted)
+	} else {
+		decision.IdTokenInfo = *types.NewIdTokenInfo(types.AuthorizationStatusUnknown)
+	}
+	return decision
+}
+
+// Looks up an idToken in the local list first, then in the cache. Expired cache entries are ignored.
+func (a *Authorizer) lookup(idToken string, tokenType types.IdTokenType) (types.IdTokenInfo, DecisionSource, bool) {
+	if a.localList != nil {
+		if info, ok := a.localList.Lookup(idToken, tokenType); ok {
+			return info, DecisionSourceLocalList, true
+		}
+	}
+	if a.cache != nil {
+		if info, ok := a.cache.Lookup(idToken, tokenType); ok {
+			if info.CacheExpiryDateTime == nil || a.clock.Now().Before(info.CacheExpiryDateTime.Time) {
+				return info, DecisionSourceCache, true
+			}
+		}
+	}
+	return types.IdTokenInfo{}, "", false
+}
+
+// Sends the request to the CSMS, waiting at most for the configured timeout.
+func (a *Authorizer) send(request *AuthorizeRequest) (*AuthorizeResponse, error) {
+	type result struct {
+		response *AuthorizeResponse
+		err      error
+	}
+	resultC := make(chan result, 1)
+	go func() {
+		response, err := a.sender.Authorize(request.IdToken.IdToken, request.IdToken.Type, func(r *AuthorizeRequest) {
+			r.IdToken = request.IdToken
+			r.Certificate = request.Certificate
+			r.CertificateHashData = request.CertificateHashData
+			r.CustomData = request.CustomData
+		})
+		if err == nil && response == nil {
+			err = errors.New("no response received")
+		}
+		resultC <- result{response: response, err: err}
+	}()
+	if a.Timeout <= 0 {
+		r := <-resultC
+		return r.response, r.err
+	}
+	timer := a.clock.NewTimer(a.Timeout)
+	defer timer.Stop()
+	select {
+	case r := <-resultC:
+		return r.response, r.err
+	case <-timer.C():
+		return nil, ErrAuthorizeTimeout
+	}
+}
+
+func certificateStatusOf(err error) AuthorizeCertificateStatus {
+	switch {
+	case errors.Is(err, certhelper.ErrCertificateExpired):
+		return CertificateStatusCertificateExpired
+	case errors.Is(err, certhelper.ErrNoCertificate):
+		return CertificateStatusNoCertificateAvailable
+	default:
+		return CertificateStatusCertChainError
+	}
+}
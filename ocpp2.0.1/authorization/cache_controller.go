@@ -0,0 +1,89 @@
+package authorization
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// The maximum length of the additional info within a StatusInfo.
+const maxAdditionalInfoLength = 512
+
+// Cache is the authorization cache of a charging station, containing the IdTokenInfo of recently authorized idTokens.
+type Cache interface {
+	// Clear removes all entries from the cache.
+	Clear() error
+}
+
+// CacheAuditEvent is passed to the audit callback of a CacheController for every processed ClearCacheRequest.
+// Like a SecurityEventNotification, it carries a timestamp and optional technical info, so it may be forwarded
+// to the security log of the charging station.
+type CacheAuditEvent struct {
+	Timestamp time.Time
+	Status    ClearCacheStatus // The status returned to the CSMS.
+	TechInfo  string           // The reason, if the request was rejected.
+}
+
+// CacheController implements the ChargingStationHandler interface, clearing the authorization cache on request of the CSMS.
+// It may therefore be registered directly:
+//
+//	chargingStation.SetAuthorizationHandler(controller)
+//
+// As mandated by the specification, only the authorization cache is cleared: the local authorization list
+// (e.g. a localauth.ListManager201) is never modified by a ClearCacheRequest.
+// Requests are rejected if no cache is set (e.g. if AuthCacheEnabled is false), or if clearing the cache failed.
+//
+// A CacheController is safe for concurrent use.
+type CacheController struct {
+	// OnAudit is invoked after every ClearCacheRequest, without holding any lock. Optional.
+	OnAudit func(event CacheAuditEvent)
+	cache   Cache
+	now     func() time.Time
+	mutex   sync.Mutex
+}
+
+// NewCacheController creates a controller clearing the passed cache. Pass nil if the charging station has no authorization cache.
+func NewCacheController(cache Cache) *CacheController {
+	return &CacheController{cache: cache, now: time.Now}
+}
+
+// SetCache replaces the cache cleared by the controller. Passing nil rejects further requests, e.g. after AuthCacheEnabled was disabled.
+func (c *CacheController) SetCache(cache Cache) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.cache = cache
+}
+
+// SetTimeSource replaces the function used for retrieving the current time. Intended for testing.
+func (c *CacheController) SetTimeSource(now func() time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = now
+}
+
+func (c *CacheController) OnClearCache(request *ClearCacheRequest) (*ClearCacheResponse, error) {
+	c.mutex.Lock()
+	response := NewClearCacheResponse(ClearCacheStatusAccepted)
+	if c.cache == nil {
+		response.Status = ClearCacheStatusRejected
+		response.StatusInfo = types.NewStatusInfo("NotEnabled", "no authorization cache available")
+	} else if err := c.cache.Clear(); err != nil {
+		info := err.Error()
+		if len(info) > maxAdditionalInfoLength {
+			info = info[:maxAdditionalInfoLength]
+		}
+		response.Status = ClearCacheStatusRejected
+		response.StatusInfo = types.NewStatusInfo("InternalError", info)
+	}
+	event := CacheAuditEvent{Timestamp: c.now(), Status: response.Status}
+	if response.StatusInfo != nil {
+		event.TechInfo = response.StatusInfo.AdditionalInfo
+	}
+	onAudit := c.OnAudit
+	c.mutex.Unlock()
+	if onAudit != nil {
+		onAudit(event)
+	}
+	return response, nil
+}
@@ -0,0 +1,41 @@
+package authorization
+
+import (
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/idtoken"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// SameGroup201 returns true if the candidate idToken belongs to the same group as the idToken which started a transaction,
+// i.e. if both IdTokenInfo carry a GroupIdToken, and the two group idTokens have the same type and normalized value.
+//
+// Tokens without a group never match, even if the group is only missing on one side.
+// Membership is only derived from the candidate info: presenting the group idToken itself doesn't match,
+// unless its info references the group as well.
+func SameGroup201(start *types.IdTokenInfo, candidate types.IdToken, candidateInfo *types.IdTokenInfo) bool {
+	if start == nil || candidateInfo == nil || start.GroupIdToken == nil || candidateInfo.GroupIdToken == nil {
+		return false
+	}
+	startGroup := types.IdToken{IdToken: start.GroupIdToken.IdToken, Type: start.GroupIdToken.Type}
+	candidateGroup := types.IdToken{IdToken: candidateInfo.GroupIdToken.IdToken, Type: candidateInfo.GroupIdToken.Type}
+	return startGroup.IdToken != "" && idtoken.Equal(startGroup, candidateGroup)
+}
+
+// AuthorizeStop decides whether the candidate idToken may stop a transaction, which was started by the start idToken.
+// The start info is the authorization obtained when starting the transaction, or nil if unknown.
+//
+// The idToken which started the transaction is always accepted. Any other idToken is authorized as done by Authorize,
+// and is accepted only if its authorization is Accepted and it belongs to the same group as the start idToken (see SameGroup201).
+// Hence, while offline, a group member is accepted if it is found in the local authorization list or in the cache.
+func (a *Authorizer) AuthorizeStop(start types.IdToken, startInfo *types.IdTokenInfo, candidate types.IdToken) Decision {
+	if idtoken.Equal(start, candidate) {
+		info := types.NewIdTokenInfo(types.AuthorizationStatusAccepted)
+		if startInfo != nil {
+			info = startInfo
+		}
+		return Decision{Accepted: true, IdTokenInfo: *info, Source: DecisionSourceTransaction}
+	}
+	decision := a.Authorize(&AuthorizeRequest{IdToken: candidate})
+	decision.Accepted = decision.IdTokenInfo.Status == types.AuthorizationStatusAccepted &&
+		SameGroup201(startInfo, candidate, &decision.IdTokenInfo)
+	return decision
+}
@@ -0,0 +1,64 @@
+package authorization
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+type cacheKey struct {
+	idToken   string
+	tokenType types.IdTokenType
+}
+
+// MemoryCache is a non-persistent AuthorizationCache, keeping all entries in memory.
+// Entries are returned until their CacheExpiryDateTime, if set.
+//
+// A MemoryCache is safe for concurrent use.
+type MemoryCache struct {
+	entries map[cacheKey]types.IdTokenInfo
+	now     func() time.Time
+	mutex   sync.Mutex
+}
+
+// NewMemoryCache creates a new, empty cache.
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: map[cacheKey]types.IdTokenInfo{}, now: time.Now}
+}
+
+// SetTimeSource replaces the function used for retrieving the current time. Intended for testing.
+func (c *MemoryCache) SetTimeSource(now func() time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = now
+}
+
+func (c *MemoryCache) Lookup(idToken string, tokenType types.IdTokenType) (types.IdTokenInfo, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	key := cacheKey{idToken: idToken, tokenType: tokenType}
+	info, ok := c.entries[key]
+	if !ok {
+		return types.IdTokenInfo{}, false
+	}
+	if info.CacheExpiryDateTime != nil && !c.now().Before(info.CacheExpiryDateTime.Time) {
+		delete(c.entries, key)
+		return types.IdTokenInfo{}, false
+	}
+	return info, true
+}
+
+func (c *MemoryCache) Store(idToken types.IdToken, info types.IdTokenInfo) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries[cacheKey{idToken: idToken.IdToken, tokenType: idToken.Type}] = info
+	return nil
+}
+
+func (c *MemoryCache) Clear() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries = map[cacheKey]types.IdTokenInfo{}
+	return nil
+}
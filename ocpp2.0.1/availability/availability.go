@@ -2,7 +2,11 @@
 // A CSMS can also instruct a charging station to change its availability.
 package availability
 
-import "github.com/lorenzodonini/ocpp-go/ocpp"
+import (
+	"context"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+)
 
 // Needs to be implemented by a CSMS for handling messages part of the OCPP 2.0 Availability profile.
 type CSMSHandler interface {
@@ -12,6 +16,35 @@ type CSMSHandler interface {
 	OnStatusNotification(chargingStationID string, request *StatusNotificationRequest) (response *StatusNotificationResponse, err error)
 }
 
+// Context-aware variant of CSMSHandler, for handling messages part of the OCPP 2.0 Availability profile.
+type CSMSHandlerWithContext interface {
+	// OnHeartbeat is called on the CSMS whenever a HeartbeatResponse is received from a charging station.
+	OnHeartbeat(ctx context.Context, chargingStationID string, request *HeartbeatRequest) (response *HeartbeatResponse, err error)
+	// OnStatusNotification is called on the CSMS whenever a StatusNotificationRequest is received from a charging station.
+	OnStatusNotification(ctx context.Context, chargingStationID string, request *StatusNotificationRequest) (response *StatusNotificationResponse, err error)
+}
+
+// AdaptCSMSHandler converts a CSMSHandler into a CSMSHandlerWithContext, which ignores the context.
+// Returns nil if handler is nil.
+func AdaptCSMSHandler(handler CSMSHandler) CSMSHandlerWithContext {
+	if handler == nil {
+		return nil
+	}
+	return csmsHandlerAdapter{handler: handler}
+}
+
+type csmsHandlerAdapter struct {
+	handler CSMSHandler
+}
+
+func (a csmsHandlerAdapter) OnHeartbeat(ctx context.Context, chargingStationID string, request *HeartbeatRequest) (*HeartbeatResponse, error) {
+	return a.handler.OnHeartbeat(chargingStationID, request)
+}
+
+func (a csmsHandlerAdapter) OnStatusNotification(ctx context.Context, chargingStationID string, request *StatusNotificationRequest) (*StatusNotificationResponse, error) {
+	return a.handler.OnStatusNotification(chargingStationID, request)
+}
+
 // Needs to be implemented by Charging stations for handling messages part of the OCPP 2.0 Availability profile.
 type ChargingStationHandler interface {
 	// OnChangeAvailability is called on a charging station whenever a ChangeAvailabilityRequest is received from the CSMS.
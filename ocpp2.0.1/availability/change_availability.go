@@ -53,6 +53,7 @@ func isValidChangeAvailabilityStatus(fl validator.FieldLevel) bool {
 type ChangeAvailabilityRequest struct {
 	OperationalStatus OperationalStatus `json:"operationalStatus" validate:"required,operationalStatus"`
 	Evse              *types.EVSE       `json:"evse,omitempty" validate:"omitempty"`
+	CustomData        *types.CustomData `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // This field definition of the ChangeAvailability response payload, sent by the Charging Station to the CSMS in response to a ChangeAvailabilityRequest.
@@ -60,6 +61,7 @@ type ChangeAvailabilityRequest struct {
 type ChangeAvailabilityResponse struct {
 	Status     ChangeAvailabilityStatus `json:"status" validate:"required,changeAvailabilityStatus"`
 	StatusInfo *types.StatusInfo        `json:"statusInfo,omitempty" validate:"omitempty"`
+	CustomData *types.CustomData        `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // CSMS can request a Charging Station to change its availability.
@@ -100,6 +102,6 @@ func NewChangeAvailabilityResponse(status ChangeAvailabilityStatus) *ChangeAvail
 }
 
 func init() {
-	_ = types.Validate.RegisterValidation("operationalStatus", isValidOperationalStatus)
-	_ = types.Validate.RegisterValidation("changeAvailabilityStatus", isValidChangeAvailabilityStatus)
+	_ = types.RegisterValidation("operationalStatus", isValidOperationalStatus)
+	_ = types.RegisterValidation("changeAvailabilityStatus", isValidChangeAvailabilityStatus)
 }
@@ -0,0 +1,420 @@
+package availability
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/clock"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// The maximum period after which a running StationManager checks whether a heartbeat is due.
+const maxHeartbeatTickPeriod = time.Second
+
+// Identifies a connector. The zero connector ID refers to a whole EVSE, while the zero EVSE ID refers to the whole station.
+type connectorKey struct {
+	evseID      int
+	connectorID int
+}
+
+var stationScope = connectorKey{}
+
+type connectorState struct {
+	status   ConnectorStatus // The status, as set by the charging station.
+	reported ConnectorStatus // The status last reported to the CSMS. Empty if none was reported yet.
+}
+
+type statusNotification struct {
+	key    connectorKey
+	status ConnectorStatus
+}
+
+// StationManager automates the availability functional block on the charging station side.
+//
+// It sends heartbeats at the interval received in the BootNotificationResponse, or configured via the
+// HeartbeatInterval variable of the OCPPCommCtrlr component. Whenever another message is sent to the CSMS
+// (see OnMessageSent), including status notifications sent by the manager, the next heartbeat is postponed by one interval.
+// Heartbeats are sent while the manager is running (see Start). Alternatively, Tick may be invoked manually.
+//
+// Connector statuses are set via SetConnectorStatus. A StatusNotificationRequest is sent only when the status
+// reported to the CSMS changes. Connectors of an inoperative EVSE or station are reported as Unavailable,
+// unless they are Faulted.
+//
+// The manager implements the ChargingStationHandler interface, so it may be registered directly via
+// SetAvailabilityHandler. Changing an EVSE or the station to Inoperative while a transaction is ongoing
+// on it is Scheduled, and applied once all affected EVSEs are free (see SetEVSEBusy).
+//
+// A StationManager is safe for concurrent use.
+type StationManager struct {
+	// Sends a HeartbeatRequest to the CSMS.
+	SendHeartbeat func(request *HeartbeatRequest) error
+	// Sends a StatusNotificationRequest to the CSMS. The function shouldn't block, since it may be invoked
+	// from within OnChangeAvailability, e.g. by using ChargingStation.SendRequestAsync.
+	SendStatusNotification func(request *StatusNotificationRequest) error
+	// Invoked whenever a request couldn't be sent. Optional.
+	OnSendError       func(featureName string, err error)
+	heartbeatInterval time.Duration
+	lastMessage       time.Time
+	connectors        map[connectorKey]*connectorState
+	operational       map[connectorKey]OperationalStatus
+	scheduled         map[connectorKey]OperationalStatus
+	busy              map[int]bool
+	clock             clock.Clock
+	stopC             chan struct{}
+	mutex             sync.Mutex
+}
+
+// NewStationManager creates a new manager. Heartbeats are disabled until an interval is set.
+func NewStationManager(sendHeartbeat func(request *HeartbeatRequest) error, sendStatusNotification func(request *StatusNotificationRequest) error) *StationManager {
+	return &StationManager{
+		SendHeartbeat:          sendHeartbeat,
+		SendStatusNotification: sendStatusNotification,
+		connectors:             map[connectorKey]*connectorState{},
+		operational:            map[connectorKey]OperationalStatus{},
+		scheduled:              map[connectorKey]OperationalStatus{},
+		busy:                   map[int]bool{},
+		clock:                  clock.New(),
+	}
+}
+
+// SetTimeSource replaces the function used for retrieving the current time, while timers are backed by the real clock.
+//
+// Deprecated: Use SetClock instead.
+func (m *StationManager) SetTimeSource(now func() time.Time) {
+	m.SetClock(clock.WithNow(now))
+}
+
+// SetClock sets the clock used for retrieving the current time and for scheduling heartbeats.
+// Passing nil restores the real clock. The clock must be set before starting the manager.
+func (m *StationManager) SetClock(c clock.Clock) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.clock = clock.OrDefault(c)
+}
+
+// Start begins sending heartbeats in the background. Calling Start on a running manager has no effect.
+func (m *StationManager) Start() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.stopC != nil {
+		return
+	}
+	stopC := make(chan struct{})
+	m.stopC = stopC
+	ticker := m.clock.NewTicker(maxHeartbeatTickPeriod)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C():
+				m.Tick()
+			case <-stopC:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops sending heartbeats in the background.
+func (m *StationManager) Stop() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.stopC != nil {
+		close(m.stopC)
+		m.stopC = nil
+	}
+}
+
+// Tick sends a heartbeat, if one is due.
+func (m *StationManager) Tick() {
+	m.mutex.Lock()
+	now := m.clock.Now()
+	if m.heartbeatInterval <= 0 || now.Before(m.lastMessage.Add(m.heartbeatInterval)) {
+		m.mutex.Unlock()
+		return
+	}
+	m.lastMessage = now
+	m.mutex.Unlock()
+	if err := m.SendHeartbeat(NewHeartbeatRequest()); err != nil && m.OnSendError != nil {
+		m.OnSendError(HeartbeatFeatureName, err)
+	}
+}
+
+// SetHeartbeatInterval sets the interval between heartbeats. A non-positive interval disables heartbeats.
+// The next heartbeat is due one interval after the last message sent to the CSMS.
+func (m *StationManager) SetHeartbeatInterval(interval time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.heartbeatInterval = interval
+	if m.lastMessage.IsZero() {
+		m.lastMessage = m.clock.Now()
+	}
+}
+
+// HeartbeatInterval returns the current interval between heartbeats.
+func (m *StationManager) HeartbeatInterval() time.Duration {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.heartbeatInterval
+}
+
+// NextHeartbeat returns the time at which the next heartbeat is due. Returns false, if heartbeats are disabled.
+func (m *StationManager) NextHeartbeat() (time.Time, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.heartbeatInterval <= 0 {
+		return time.Time{}, false
+	}
+	return m.lastMessage.Add(m.heartbeatInterval), true
+}
+
+// OnMessageSent postpones the next heartbeat by one interval. Should be invoked whenever a message is sent to the CSMS.
+func (m *StationManager) OnMessageSent() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.lastMessage = m.clock.Now()
+}
+
+// OnBootNotificationResponse applies the heartbeat interval of an accepted BootNotificationResponse.
+// For any other registration status, the interval refers to the BootNotification retry interval and is ignored.
+func (m *StationManager) OnBootNotificationResponse(response *provisioning.BootNotificationResponse) {
+	if response.Status != provisioning.RegistrationStatusAccepted || response.Interval <= 0 {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.heartbeatInterval = time.Duration(response.Interval) * time.Second
+	m.lastMessage = m.clock.Now()
+}
+
+// OnVariableSet applies changes to the HeartbeatInterval variable of the OCPPCommCtrlr component,
+// and ignores all other variables. It matches the provisioning.DeviceModelHandler OnVariableSet callback.
+func (m *StationManager) OnVariableSet(component types.Component, variable types.Variable, attributeType types.Attribute, value string) {
+	if !strings.EqualFold(component.Name, "OCPPCommCtrlr") || !strings.EqualFold(variable.Name, "HeartbeatInterval") {
+		return
+	}
+	if attributeType != "" && attributeType != types.AttributeActual {
+		return
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil {
+		return
+	}
+	m.SetHeartbeatInterval(time.Duration(seconds) * time.Second)
+}
+
+// SetConnectorStatus sets the status of a connector, as detected by the charging station.
+// A StatusNotificationRequest is sent, if the status reported to the CSMS changes.
+func (m *StationManager) SetConnectorStatus(evseID int, connectorID int, status ConnectorStatus) {
+	key := connectorKey{evseID: evseID, connectorID: connectorID}
+	m.mutex.Lock()
+	state, ok := m.connectors[key]
+	if !ok {
+		state = &connectorState{}
+		m.connectors[key] = state
+	}
+	state.status = status
+	notifications := m.collectNotifications(func(k connectorKey) bool { return k == key }, false)
+	m.mutex.Unlock()
+	m.sendNotifications(notifications)
+}
+
+// ConnectorStatus returns the status of a connector, as reported to the CSMS. Returns false for unknown connectors.
+func (m *StationManager) ConnectorStatus(evseID int, connectorID int) (ConnectorStatus, bool) {
+	key := connectorKey{evseID: evseID, connectorID: connectorID}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	state, ok := m.connectors[key]
+	if !ok {
+		return "", false
+	}
+	return m.effectiveStatus(key, state), true
+}
+
+// ReportStatus sends a StatusNotificationRequest with the current status of every matching connector,
+// even if the status was reported already. A zero connector ID matches all connectors of the EVSE,
+// while a zero EVSE ID matches all connectors of the station. Returns false, if no known connector matches.
+//
+// This is useful for answering a TriggerMessageRequest for StatusNotification.
+func (m *StationManager) ReportStatus(evseID int, connectorID int) bool {
+	m.mutex.Lock()
+	notifications := m.collectNotifications(func(key connectorKey) bool {
+		return (evseID == 0 || key.evseID == evseID) && (connectorID == 0 || key.connectorID == connectorID)
+	}, true)
+	m.mutex.Unlock()
+	m.sendNotifications(notifications)
+	return len(notifications) > 0
+}
+
+// OperationalStatus returns the operational status of a connector. A connector is inoperative, if either
+// the connector itself, its EVSE or the station is inoperative. Zero IDs refer to the EVSE and the station respectively.
+func (m *StationManager) OperationalStatus(evseID int, connectorID int) OperationalStatus {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.isInoperative(connectorKey{evseID: evseID, connectorID: connectorID}) {
+		return OperationalStatusInoperative
+	}
+	return OperationalStatusOperative
+}
+
+// SetEVSEBusy marks whether a transaction is ongoing on an EVSE. Typically invoked by the transaction handling
+// of the charging station. Scheduled availability changes are applied, once all affected EVSEs are free.
+func (m *StationManager) SetEVSEBusy(evseID int, busy bool) {
+	m.mutex.Lock()
+	if busy {
+		m.busy[evseID] = true
+		m.mutex.Unlock()
+		return
+	}
+	delete(m.busy, evseID)
+	var notifications []statusNotification
+	for scope, status := range m.scheduled {
+		if !m.isScopeBusy(scope) {
+			notifications = append(notifications, m.applyOperationalStatus(scope, status)...)
+		}
+	}
+	m.mutex.Unlock()
+	m.sendNotifications(notifications)
+}
+
+func (m *StationManager) OnChangeAvailability(request *ChangeAvailabilityRequest) (*ChangeAvailabilityResponse, error) {
+	scope := stationScope
+	if request.Evse != nil {
+		scope.evseID = request.Evse.ID
+		if request.Evse.ConnectorID != nil {
+			scope.connectorID = *request.Evse.ConnectorID
+		}
+	}
+	m.mutex.Lock()
+	if reasonCode := m.validateScope(scope); reasonCode != "" {
+		m.mutex.Unlock()
+		response := NewChangeAvailabilityResponse(ChangeAvailabilityStatusRejected)
+		response.StatusInfo = types.NewStatusInfo(reasonCode, "")
+		return response, nil
+	}
+	if request.OperationalStatus == OperationalStatusInoperative && m.isScopeBusy(scope) {
+		m.scheduled[scope] = request.OperationalStatus
+		m.mutex.Unlock()
+		return NewChangeAvailabilityResponse(ChangeAvailabilityStatusScheduled), nil
+	}
+	notifications := m.applyOperationalStatus(scope, request.OperationalStatus)
+	m.mutex.Unlock()
+	m.sendNotifications(notifications)
+	return NewChangeAvailabilityResponse(ChangeAvailabilityStatusAccepted), nil
+}
+
+// ValidateConnector returns the reason code for rejecting a request referring to an unknown EVSE or connector,
+// or an empty string if the connector is known. A zero connector ID refers to the whole EVSE.
+func (m *StationManager) ValidateConnector(evseID int, connectorID int) string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.validateScope(connectorKey{evseID: evseID, connectorID: connectorID})
+}
+
+// Returns the reason code for rejecting an availability change of an unknown EVSE or connector, or an empty string.
+func (m *StationManager) validateScope(scope connectorKey) string {
+	if scope == stationScope {
+		return ""
+	}
+	knownEVSE := false
+	for key := range m.connectors {
+		if key.evseID != scope.evseID {
+			continue
+		}
+		if scope.connectorID == 0 || key.connectorID == scope.connectorID {
+			return ""
+		}
+		knownEVSE = true
+	}
+	if knownEVSE {
+		return "UnknownConnectorId"
+	}
+	return "UnknownEvse"
+}
+
+// Sets the operational status of a scope, discarding any scheduled change, and returns the resulting notifications.
+func (m *StationManager) applyOperationalStatus(scope connectorKey, status OperationalStatus) []statusNotification {
+	delete(m.scheduled, scope)
+	if status == OperationalStatusInoperative {
+		m.operational[scope] = status
+	} else {
+		delete(m.operational, scope)
+	}
+	return m.collectNotifications(func(key connectorKey) bool {
+		return scope.evseID == 0 || (key.evseID == scope.evseID && (scope.connectorID == 0 || key.connectorID == scope.connectorID))
+	}, false)
+}
+
+func (m *StationManager) isScopeBusy(scope connectorKey) bool {
+	if scope.evseID == 0 {
+		return len(m.busy) > 0
+	}
+	return m.busy[scope.evseID]
+}
+
+func (m *StationManager) isInoperative(key connectorKey) bool {
+	for _, scope := range []connectorKey{stationScope, {evseID: key.evseID}, key} {
+		if m.operational[scope] == OperationalStatusInoperative {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *StationManager) effectiveStatus(key connectorKey, state *connectorState) ConnectorStatus {
+	if state.status != ConnectorStatusFaulted && m.isInoperative(key) {
+		return ConnectorStatusUnavailable
+	}
+	return state.status
+}
+
+// Returns a notification for every matching connector, whose reported status changed, and marks it as reported.
+// If force is set, a notification is returned for every matching connector.
+func (m *StationManager) collectNotifications(match func(key connectorKey) bool, force bool) []statusNotification {
+	var notifications []statusNotification
+	for key, state := range m.connectors {
+		if !match(key) {
+			continue
+		}
+		status := m.effectiveStatus(key, state)
+		if status == state.reported && !force {
+			continue
+		}
+		state.reported = status
+		notifications = append(notifications, statusNotification{key: key, status: status})
+	}
+	sort.Slice(notifications, func(i, j int) bool {
+		if notifications[i].key.evseID != notifications[j].key.evseID {
+			return notifications[i].key.evseID < notifications[j].key.evseID
+		}
+		return notifications[i].key.connectorID < notifications[j].key.connectorID
+	})
+	return notifications
+}
+
+func (m *StationManager) sendNotifications(notifications []statusNotification) {
+	for _, n := range notifications {
+		m.mutex.Lock()
+		timestamp := types.NewDateTime(m.clock.Now())
+		m.mutex.Unlock()
+		err := m.SendStatusNotification(NewStatusNotificationRequest(timestamp, n.status, n.key.evseID, n.key.connectorID))
+		m.mutex.Lock()
+		if err == nil {
+			m.lastMessage = m.clock.Now()
+			m.mutex.Unlock()
+			continue
+		}
+		// Allow the status to be reported again
+		if state, ok := m.connectors[n.key]; ok && state.reported == n.status {
+			state.reported = ""
+		}
+		m.mutex.Unlock()
+		if m.OnSendError != nil {
+			m.OnSendError(StatusNotificationFeatureName, err)
+		}
+	}
+}
@@ -3,8 +3,13 @@ package ocpp2
 import (
 	"fmt"
 	"reflect"
+	"sync"
+	"time"
 
+	"github.com/lorenzodonini/ocpp-go/clocksync"
+	"github.com/lorenzodonini/ocpp-go/events"
 	"github.com/lorenzodonini/ocpp-go/internal/callbackqueue"
+	"github.com/lorenzodonini/ocpp-go/logging"
 	"github.com/lorenzodonini/ocpp-go/ocpp"
 	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/authorization"
 	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/availability"
@@ -24,10 +29,12 @@ import (
 	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
 	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
 	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/lorenzodonini/ocpp-go/ws"
 )
 
 type chargingStation struct {
 	client               *ocppj.Client
+	network              ws.WsClient
 	securityHandler      security.ChargingStationHandler
 	provisioningHandler  provisioning.ChargingStationHandler
 	authorizationHandler authorization.ChargingStationHandler
@@ -47,10 +54,18 @@ type chargingStation struct {
 	responseHandler      chan ocpp.Response
 	errorHandler         chan error
 	callbacks            callbackqueue.CallbackQueue
+	clock                *clocksync.Clock
+	sendTimes            map[ocpp.Request]time.Time // The send times of BootNotification and Heartbeat requests, awaiting a response.
+	sendTimesMutex       sync.Mutex
+	persistBasicAuth     func(password string) error
 	stopC                chan struct{}
 	errC                 chan error // external error channel
 }
 
+// The delay between answering a SetVariablesRequest rotating the BasicAuthPassword and reconnecting,
+// so the response is sent before the connection is dropped.
+const basicAuthReconnectDelay = time.Second
+
 func (cs *chargingStation) error(err error) {
 	if cs.errC != nil {
 		cs.errC <- err
@@ -65,6 +80,24 @@ func (cs *chargingStation) Errors() <-chan error {
 	return cs.errC
 }
 
+// Sets the logger of the ocppj endpoint and of the websocket client, if the latter supports it.
+func (cs *chargingStation) SetLogger(logger logging.Logger) {
+	cs.client.SetLogger(logger)
+	if network, ok := cs.network.(interface{ SetLogger(logger logging.Logger) }); ok {
+		if logger != nil {
+			logger = logger.With(logging.ChargePointID(cs.client.Id))
+		}
+		network.SetLogger(logger)
+	}
+}
+
+func (cs *chargingStation) SetEventBus(bus *events.Bus) {
+	cs.client.SetEventBus(bus)
+	if network, ok := cs.network.(interface{ SetEventBus(bus *events.Bus) }); ok {
+		network.SetEventBus(bus)
+	}
+}
+
 // Callback invoked whenever a queued request is canceled, due to timeout.
 // By default, the callback returns a GenericError to the caller, who sent the original request.
 func (cs *chargingStation) onRequestTimeout(_ string, _ ocpp.Request, err *ocpp.Error) {
@@ -292,6 +325,19 @@ func (cs *chargingStation) NotifyMonitoringReport(requestID int, seqNo int, gene
 	}
 }
 
+func (cs *chargingStation) NotifyPriorityCharging(transactionID string, activated bool, props ...func(request *remotecontrol.NotifyPriorityChargingRequest)) (*remotecontrol.NotifyPriorityChargingResponse, error) {
+	request := remotecontrol.NewNotifyPriorityChargingRequest(transactionID, activated)
+	for _, fn := range props {
+		fn(request)
+	}
+	response, err := cs.SendRequest(request)
+	if err != nil {
+		return nil, err
+	} else {
+		return response.(*remotecontrol.NotifyPriorityChargingResponse), err
+	}
+}
+
 func (cs *chargingStation) NotifyReport(requestID int, generatedAt *types.DateTime, seqNo int, props ...func(request *provisioning.NotifyReportRequest)) (*provisioning.NotifyReportResponse, error) {
 	request := provisioning.NewNotifyReportRequest(requestID, generatedAt, seqNo)
 	for _, fn := range props {
@@ -349,6 +395,7 @@ func (cs *chargingStation) SecurityEventNotification(typ string, timestamp *type
 	for _, fn := range props {
 		fn(request)
 	}
+	cs.fillTimestamp(&request.Timestamp)
 	response, err := cs.SendRequest(request)
 	if err != nil {
 		return nil, err
@@ -375,6 +422,7 @@ func (cs *chargingStation) StatusNotification(timestamp *types.DateTime, status
 	for _, fn := range props {
 		fn(request)
 	}
+	cs.fillTimestamp(&request.Timestamp)
 	response, err := cs.SendRequest(request)
 	if err != nil {
 		return nil, err
@@ -388,6 +436,7 @@ func (cs *chargingStation) TransactionEvent(t transactions.TransactionEvent, tim
 	for _, fn := range props {
 		fn(request)
 	}
+	cs.fillTimestamp(&request.Timestamp)
 	response, err := cs.SendRequest(request)
 	if err != nil {
 		return nil, err
@@ -463,7 +512,7 @@ func (cs *chargingStation) SetDataHandler(handler data.ChargingStationHandler) {
 func (cs *chargingStation) SendRequest(request ocpp.Request) (ocpp.Response, error) {
 	featureName := request.GetFeatureName()
 	if _, found := cs.client.GetProfileForFeature(featureName); !found {
-		return nil, fmt.Errorf("feature %v is unsupported on charging station (missing profile), cannot send request", featureName)
+		return nil, fmt.Errorf("%w %v on charging station (missing profile), cannot send request", ocppj.ErrUnsupportedFeature, featureName)
 	}
 
 	// Wraps an asynchronous response
@@ -476,9 +525,9 @@ func (cs *chargingStation) SendRequest(request ocpp.Request) (ocpp.Response, err
 	send := func() error {
 		return cs.client.SendRequest(request)
 	}
-	err := cs.callbacks.TryQueue("main", send, func(confirmation ocpp.Response, err error) {
+	err := cs.callbacks.TryQueue("main", send, cs.observeCurrentTime(request, func(confirmation ocpp.Response, err error) {
 		asyncResponseC <- asyncResponse{r: confirmation, e: err}
-	})
+	}))
 	if err != nil {
 		return nil, err
 	}
@@ -492,7 +541,7 @@ func (cs *chargingStation) SendRequest(request ocpp.Request) (ocpp.Response, err
 func (cs *chargingStation) SendRequestAsync(request ocpp.Request, callback func(response ocpp.Response, err error)) error {
 	featureName := request.GetFeatureName()
 	if _, found := cs.client.GetProfileForFeature(featureName); !found {
-		return fmt.Errorf("feature %v is unsupported on charging station (missing profile), cannot send request", featureName)
+		return fmt.Errorf("%w %v on charging station (missing profile), cannot send request", ocppj.ErrUnsupportedFeature, featureName)
 	}
 	switch featureName {
 	case authorization.AuthorizeFeatureName,
@@ -512,6 +561,7 @@ func (cs *chargingStation) SendRequestAsync(request ocpp.Request, callback func(
 		smartcharging.NotifyEVChargingScheduleFeatureName,
 		diagnostics.NotifyEventFeatureName,
 		diagnostics.NotifyMonitoringReportFeatureName,
+		remotecontrol.NotifyPriorityChargingFeatureName,
 		provisioning.NotifyReportFeatureName,
 		firmware.PublishFirmwareStatusNotificationFeatureName,
 		smartcharging.ReportChargingProfilesFeatureName,
@@ -522,30 +572,104 @@ func (cs *chargingStation) SendRequestAsync(request ocpp.Request, callback func(
 		transactions.TransactionEventFeatureName:
 		break
 	default:
-		return fmt.Errorf("unsupported action %v on charging station, cannot send request", featureName)
+		return fmt.Errorf("%w %v on charging station, cannot send request", ocppj.ErrUnsupportedFeature, featureName)
 	}
 	// Response will be retrieved asynchronously via asyncHandler
 	send := func() error {
 		return cs.client.SendRequest(request)
 	}
-	err := cs.callbacks.TryQueue("main", send, callback)
+	err := cs.callbacks.TryQueue("main", send, cs.observeCurrentTime(request, callback))
+	if err != nil {
+		cs.forgetSendTime(request)
+	}
 	return err
 }
 
+// Wraps the callback for BootNotification and Heartbeat requests,
+// feeding the current time contained in the response to the clock synchronization mechanism.
+// Callbacks for all other requests are returned as is.
+func (cs *chargingStation) observeCurrentTime(request ocpp.Request, callback func(response ocpp.Response, err error)) func(response ocpp.Response, err error) {
+	switch request.GetFeatureName() {
+	case provisioning.BootNotificationFeatureName, availability.HeartbeatFeatureName:
+	default:
+		return callback
+	}
+	// The enqueue time is replaced by the actual send time, once the dispatcher sends the request
+	cs.sendTimesMutex.Lock()
+	cs.sendTimes[request] = cs.client.Clock().Now()
+	cs.sendTimesMutex.Unlock()
+	return func(response ocpp.Response, err error) {
+		var currentTime *types.DateTime
+		switch r := response.(type) {
+		case *provisioning.BootNotificationResponse:
+			currentTime = r.CurrentTime
+		case *availability.HeartbeatResponse:
+			currentTime = &r.CurrentTime
+		}
+		sent := cs.forgetSendTime(request)
+		if err == nil && currentTime != nil {
+			cs.clock.Observe(currentTime.Time, cs.client.Clock().Now().Sub(sent))
+		}
+		callback(response, err)
+	}
+}
+
+// Records the time a BootNotification or Heartbeat request was sent at, as reported by the dispatcher.
+func (cs *chargingStation) onRequestSent(requestID string, request ocpp.Request, sentAt time.Time) {
+	switch request.GetFeatureName() {
+	case provisioning.BootNotificationFeatureName, availability.HeartbeatFeatureName:
+	default:
+		return
+	}
+	cs.sendTimesMutex.Lock()
+	defer cs.sendTimesMutex.Unlock()
+	if _, ok := cs.sendTimes[request]; ok {
+		cs.sendTimes[request] = sentAt
+	}
+}
+
+// Removes the recorded send time of a request and returns it.
+func (cs *chargingStation) forgetSendTime(request ocpp.Request) time.Time {
+	cs.sendTimesMutex.Lock()
+	defer cs.sendTimesMutex.Unlock()
+	sent := cs.sendTimes[request]
+	delete(cs.sendTimes, request)
+	return sent
+}
+
+// Fills a missing timestamp with the current time, if the learned clock offset is being tracked.
+func (cs *chargingStation) fillTimestamp(timestamp **types.DateTime) {
+	if *timestamp == nil && cs.clock.Mode() == clocksync.ModeTrackOffset {
+		*timestamp = types.NewDateTime(cs.clock.Now())
+	}
+}
+
+func (cs *chargingStation) SetClockSyncMode(mode clocksync.Mode) {
+	cs.clock.SetMode(mode)
+}
+
+func (cs *chargingStation) SetClockSyncHandler(handler func(authoritativeTime time.Time)) {
+	cs.clock.SetHook(handler)
+}
+
+func (cs *chargingStation) Now() time.Time {
+	return cs.clock.Now()
+}
+
 func (cs *chargingStation) asyncCallbackHandler() {
 	for {
 		select {
 		case confirmation := <-cs.responseHandler:
 			// Get and invoke callback
 			if callback, ok := cs.callbacks.Dequeue("main"); ok {
-				callback(confirmation, nil)
+				cs.invokeCallback(confirmation.GetFeatureName(), callback, confirmation, nil)
 			} else {
 				cs.error(fmt.Errorf("no callback available for incoming response %v", confirmation.GetFeatureName()))
 			}
 		case protoError := <-cs.errorHandler:
 			// Get and invoke callback
 			if callback, ok := cs.callbacks.Dequeue("main"); ok {
-				callback(nil, protoError)
+				cs.invokeCallback("", callback, nil, protoError)
 			} else {
 				cs.error(fmt.Errorf("no callback available for incoming error %w", protoError))
 			}
@@ -555,6 +679,16 @@ func (cs *chargingStation) asyncCallbackHandler() {
 	}
 }
 
+// Invokes a result callback. Panics are recovered and reported, without affecting the connection.
+func (cs *chargingStation) invokeCallback(action string, callback func(response ocpp.Response, err error), response ocpp.Response, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			cs.client.HandlePanic("", ocppj.NewHandlerPanicError(cs.client.Id, action, r))
+		}
+	}()
+	callback(response, err)
+}
+
 func (cs *chargingStation) sendResponse(response ocpp.Response, err error, requestId string) {
 	if err != nil {
 		// Send error response
@@ -619,6 +753,71 @@ func (cs *chargingStation) IsConnected() bool {
 	return cs.client.IsConnected()
 }
 
+func (cs *chargingStation) EnableResponseWatchdog(maxSilence time.Duration) {
+	cs.client.EnableResponseWatchdog(maxSilence)
+}
+
+func (cs *chargingStation) SetResponseWatchdogHandler(handler func(silence time.Duration)) {
+	cs.client.SetOnResponseWatchdogTriggered(handler)
+}
+
+func (cs *chargingStation) EnableBasicAuthPasswordRotation(persist func(password string) error) {
+	cs.persistBasicAuth = persist
+}
+
+// Passes SetVariablesRequests on to the provisioning handler. While the basic auth password rotation is enabled,
+// invalid BasicAuthPassword values are rejected without invoking the handler, and accepted ones are persisted and applied.
+func (cs *chargingStation) handleSetVariables(request *provisioning.SetVariablesRequest) (*provisioning.SetVariablesResponse, error) {
+	if cs.persistBasicAuth == nil {
+		return cs.provisioningHandler.OnSetVariables(request)
+	}
+	var rejected []provisioning.SetVariableResult
+	var password string
+	forwarded := *request
+	forwarded.SetVariableData = nil
+	for _, data := range request.SetVariableData {
+		if provisioning.IsBasicAuthPassword(data.Component, data.Variable, data.AttributeType) {
+			if err := provisioning.ValidateBasicAuthPassword(data.AttributeValue); err != nil {
+				rejected = append(rejected, provisioning.SetVariableResult{
+					AttributeType:   data.AttributeType,
+					AttributeStatus: provisioning.SetVariableStatusRejected,
+					Component:       data.Component,
+					Variable:        data.Variable,
+					StatusInfo:      types.NewStatusInfo("InvalidValue", ""),
+				})
+				continue
+			}
+			password = data.AttributeValue
+		}
+		forwarded.SetVariableData = append(forwarded.SetVariableData, data)
+	}
+	response := provisioning.NewSetVariablesResponse(nil)
+	if len(forwarded.SetVariableData) > 0 {
+		var err error
+		response, err = cs.provisioningHandler.OnSetVariables(&forwarded)
+		if err != nil || response == nil {
+			return response, err
+		}
+	}
+	for i, result := range response.SetVariableResult {
+		if result.AttributeStatus != provisioning.SetVariableStatusAccepted || !provisioning.IsBasicAuthPassword(result.Component, result.Variable, result.AttributeType) {
+			continue
+		}
+		if err := cs.persistBasicAuth(password); err != nil {
+			cs.error(fmt.Errorf("couldn't persist basic auth password: %w", err))
+			response.SetVariableResult[i].AttributeStatus = provisioning.SetVariableStatusRejected
+			response.SetVariableResult[i].StatusInfo = types.NewStatusInfo("InternalError", "")
+			continue
+		}
+		cs.client.SetBasicAuth(cs.client.Id, password)
+		cs.client.Clock().AfterFunc(basicAuthReconnectDelay, func() {
+			cs.client.Reconnect(fmt.Errorf("basic auth password changed"))
+		})
+	}
+	response.SetVariableResult = append(response.SetVariableResult, rejected...)
+	return response, nil
+}
+
 func (cs *chargingStation) notImplementedError(requestId string, action string) {
 	err := cs.client.SendError(requestId, ocppj.NotImplemented, fmt.Sprintf("no handler for action %v implemented", action), nil)
 	if err != nil {
@@ -787,7 +986,7 @@ func (cs *chargingStation) handleIncomingRequest(request ocpp.Request, requestId
 	case diagnostics.SetVariableMonitoringFeatureName:
 		response, err = cs.diagnosticsHandler.OnSetVariableMonitoring(request.(*diagnostics.SetVariableMonitoringRequest))
 	case provisioning.SetVariablesFeatureName:
-		response, err = cs.provisioningHandler.OnSetVariables(request.(*provisioning.SetVariablesRequest))
+		response, err = cs.handleSetVariables(request.(*provisioning.SetVariablesRequest))
 	case remotecontrol.TriggerMessageFeatureName:
 		response, err = cs.remoteControlHandler.OnTriggerMessage(request.(*remotecontrol.TriggerMessageRequest))
 	case remotecontrol.UnlockConnectorFeatureName:
@@ -796,6 +995,8 @@ func (cs *chargingStation) handleIncomingRequest(request ocpp.Request, requestId
 		response, err = cs.firmwareHandler.OnUnpublishFirmware(request.(*firmware.UnpublishFirmwareRequest))
 	case firmware.UpdateFirmwareFeatureName:
 		response, err = cs.firmwareHandler.OnUpdateFirmware(request.(*firmware.UpdateFirmwareRequest))
+	case remotecontrol.UsePriorityChargingFeatureName:
+		response, err = cs.remoteControlHandler.OnUsePriorityCharging(request.(*remotecontrol.UsePriorityChargingRequest))
 	default:
 		cs.notSupportedError(requestId, action)
 		return
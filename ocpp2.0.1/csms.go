@@ -1,10 +1,16 @@
 package ocpp2
 
 import (
+	"context"
 	"fmt"
 	"reflect"
+	"sort"
+	"sync"
+	"time"
 
+	"github.com/lorenzodonini/ocpp-go/events"
 	"github.com/lorenzodonini/ocpp-go/internal/callbackqueue"
+	"github.com/lorenzodonini/ocpp-go/logging"
 	"github.com/lorenzodonini/ocpp-go/ocpp"
 	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/authorization"
 	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/availability"
@@ -29,24 +35,40 @@ import (
 
 type csms struct {
 	server               *ocppj.Server
-	securityHandler      security.CSMSHandler
-	provisioningHandler  provisioning.CSMSHandler
-	authorizationHandler authorization.CSMSHandler
+	network              ws.WsServer
+	securityHandler      security.CSMSHandlerWithContext
+	provisioningHandler  provisioning.CSMSHandlerWithContext
+	authorizationHandler authorization.CSMSHandlerWithContext
 	localAuthListHandler localauth.CSMSHandler
-	transactionsHandler  transactions.CSMSHandler
-	remoteControlHandler remotecontrol.CSMSHandler
-	availabilityHandler  availability.CSMSHandler
-	reservationHandler   reservation.CSMSHandler
+	transactionsHandler  transactions.CSMSHandlerWithContext
+	remoteControlHandler remotecontrol.CSMSHandlerWithContext
+	availabilityHandler  availability.CSMSHandlerWithContext
+	reservationHandler   reservation.CSMSHandlerWithContext
 	tariffCostHandler    tariffcost.CSMSHandler
-	meterHandler         meter.CSMSHandler
-	smartChargingHandler smartcharging.CSMSHandler
-	firmwareHandler      firmware.CSMSHandler
-	iso15118Handler      iso15118.CSMSHandler
-	diagnosticsHandler   diagnostics.CSMSHandler
-	displayHandler       display.CSMSHandler
-	dataHandler          data.CSMSHandler
+	meterHandler         meter.CSMSHandlerWithContext
+	smartChargingHandler smartcharging.CSMSHandlerWithContext
+	firmwareHandler      firmware.CSMSHandlerWithContext
+	iso15118Handler      iso15118.CSMSHandlerWithContext
+	diagnosticsHandler   diagnostics.CSMSHandlerWithContext
+	displayHandler       display.CSMSHandlerWithContext
+	dataHandler          data.CSMSHandlerWithContext
+	newStationHandler    ChargingStationConnectionHandler
+	disconnectedHandler  ChargingStationConnectionHandler
+	connectedStations    map[string]bool
+	connectedC           chan struct{}
+	connectionsMutex     sync.Mutex
 	callbackQueue        callbackqueue.CallbackQueue
 	errC                 chan error
+	messageLimits        map[string]provisioning.MessageLimits
+	limitsMutex          sync.RWMutex
+	costCalculator       tariffcost.Calculator
+	costCurrency         string
+	costTracker          *transactions.EventTracker
+	displayAssembler     *display.MessagesAssembler
+	validateProfiles     bool
+	capabilityProvider   CapabilityProvider
+	defaultHandlers      DefaultHandler
+	defaultObserver      RequestObserver
 }
 
 func newCSMS(server *ocppj.Server) csms {
@@ -55,8 +77,11 @@ func newCSMS(server *ocppj.Server) csms {
 	}
 	server.SetDialect(ocpp.V2)
 	return csms{
-		server:        server,
-		callbackQueue: callbackqueue.New(),
+		server:            server,
+		callbackQueue:     callbackqueue.New(),
+		messageLimits:     map[string]provisioning.MessageLimits{},
+		connectedStations: map[string]bool{},
+		connectedC:        make(chan struct{}),
 	}
 }
 
@@ -73,6 +98,59 @@ func (cs *csms) Errors() <-chan error {
 	return cs.errC
 }
 
+// Sets the logger of the ocppj endpoint and of the websocket server, if the latter supports it.
+func (cs *csms) SetLogger(logger logging.Logger) {
+	cs.server.SetLogger(logger)
+	if network, ok := cs.network.(interface{ SetLogger(logger logging.Logger) }); ok {
+		network.SetLogger(logger)
+	}
+}
+
+// Sets the collector of the ocppj endpoint and, if both support it, of the websocket server.
+func (cs *csms) SetMetricsCollector(collector ocppj.MetricsCollector) {
+	cs.server.SetMetricsCollector(collector)
+	network, ok := cs.network.(interface {
+		SetMetricsCollector(collector ws.MetricsCollector)
+	})
+	if !ok {
+		return
+	}
+	if wsCollector, ok := collector.(ws.MetricsCollector); ok {
+		network.SetMetricsCollector(wsCollector)
+	} else {
+		network.SetMetricsCollector(nil)
+	}
+}
+
+func (cs *csms) SetEventBus(bus *events.Bus) {
+	cs.server.SetEventBus(bus)
+	if network, ok := cs.network.(interface{ SetEventBus(bus *events.Bus) }); ok {
+		network.SetEventBus(bus)
+	}
+}
+
+func (cs *csms) Stats() []ocppj.ClientStats {
+	return cs.server.Stats()
+}
+
+func (cs *csms) SetCapabilityProvider(provider CapabilityProvider) {
+	cs.capabilityProvider = provider
+}
+
+func (cs *csms) Inventory() []ocppj.EndpointInfo {
+	inventory := cs.server.Inventory()
+	if cs.capabilityProvider == nil {
+		return inventory
+	}
+	for i := range inventory {
+		info := &inventory[i]
+		if controllers, reportedAt, ok := cs.capabilityProvider.ReportedControllers(info.ID); ok {
+			info.Capabilities = ocppj.NewCapabilities("DeviceModel", controllers, reportedAt, info.ConnectedAt)
+		}
+	}
+	return inventory
+}
+
 func (cs *csms) CancelReservation(clientId string, callback func(*reservation.CancelReservationResponse, error), reservationId int, props ...func(request *reservation.CancelReservationRequest)) error {
 	request := reservation.NewCancelReservationRequest(reservationId)
 	for _, fn := range props {
@@ -363,6 +441,18 @@ func (cs *csms) GetReport(clientId string, callback func(*provisioning.GetReport
 	for _, fn := range props {
 		fn(request)
 	}
+	if limits, ok := cs.getMessageLimits(clientId); ok {
+		requests := provisioning.SplitGetReport(request, limits)
+		if len(requests) > 1 {
+			return cs.sendSplitRequests(clientId, toRequests(requests), func(responses []ocpp.Response, err error) {
+				if err != nil {
+					callback(nil, err)
+					return
+				}
+				callback(aggregateGetReportResponses(responses), nil)
+			})
+		}
+	}
 	genericCallback := func(response ocpp.Response, protoError error) {
 		if response != nil {
 			callback(response.(*provisioning.GetReportResponse), protoError)
@@ -393,6 +483,22 @@ func (cs *csms) GetVariables(clientId string, callback func(*provisioning.GetVar
 	for _, fn := range props {
 		fn(request)
 	}
+	if limits, ok := cs.getMessageLimits(clientId); ok {
+		requests := provisioning.SplitGetVariables(request, limits)
+		if len(requests) > 1 {
+			return cs.sendSplitRequests(clientId, toRequests(requests), func(responses []ocpp.Response, err error) {
+				if err != nil {
+					callback(nil, err)
+					return
+				}
+				response := &provisioning.GetVariablesResponse{}
+				for _, r := range responses {
+					response.GetVariableResult = append(response.GetVariableResult, r.(*provisioning.GetVariablesResponse).GetVariableResult...)
+				}
+				callback(response, nil)
+			})
+		}
+	}
 	genericCallback := func(response ocpp.Response, protoError error) {
 		if response != nil {
 			callback(response.(*provisioning.GetVariablesResponse), protoError)
@@ -498,6 +604,24 @@ func (cs *csms) SendLocalList(clientId string, callback func(*localauth.SendLoca
 	for _, fn := range props {
 		fn(request)
 	}
+	if limits, ok := cs.getMessageLimits(clientId); ok {
+		requests, err := provisioning.SplitSendLocalList(request, limits)
+		if err != nil {
+			return err
+		}
+		if len(requests) > 1 {
+			return cs.sendSplitRequests(clientId, toRequests(requests), func(responses []ocpp.Response, err error) {
+				if err != nil {
+					callback(nil, err)
+					return
+				}
+				// The last response is either the first failure, or the result of the last part
+				callback(responses[len(responses)-1].(*localauth.SendLocalListResponse), nil)
+			}, func(response ocpp.Response) bool {
+				return response.(*localauth.SendLocalListResponse).Status == localauth.SendLocalListStatusAccepted
+			})
+		}
+	}
 	genericCallback := func(response ocpp.Response, protoError error) {
 		if response != nil {
 			callback(response.(*localauth.SendLocalListResponse), protoError)
@@ -513,6 +637,11 @@ func (cs *csms) SetChargingProfile(clientId string, callback func(*smartcharging
 	for _, fn := range props {
 		fn(request)
 	}
+	if cs.validateProfiles && request.ChargingProfile != nil {
+		if violations := smartcharging.ValidateProfile201(*request.ChargingProfile, request.EvseID); len(violations) > 0 {
+			return &smartcharging.ProfileValidationError{Violations: violations}
+		}
+	}
 	genericCallback := func(response ocpp.Response, protoError error) {
 		if response != nil {
 			callback(response.(*smartcharging.SetChargingProfileResponse), protoError)
@@ -603,6 +732,23 @@ func (cs *csms) SetVariables(clientId string, callback func(*provisioning.SetVar
 	for _, fn := range props {
 		fn(request)
 	}
+	callback = cs.observeSetVariables(clientId, request.SetVariableData, callback)
+	if limits, ok := cs.getMessageLimits(clientId); ok {
+		requests := provisioning.SplitSetVariables(request, limits)
+		if len(requests) > 1 {
+			return cs.sendSplitRequests(clientId, toRequests(requests), func(responses []ocpp.Response, err error) {
+				if err != nil {
+					callback(nil, err)
+					return
+				}
+				response := &provisioning.SetVariablesResponse{}
+				for _, r := range responses {
+					response.SetVariableResult = append(response.SetVariableResult, r.(*provisioning.SetVariablesResponse).SetVariableResult...)
+				}
+				callback(response, nil)
+			})
+		}
+	}
 	genericCallback := func(response ocpp.Response, protoError error) {
 		if response != nil {
 			callback(response.(*provisioning.SetVariablesResponse), protoError)
@@ -613,6 +759,13 @@ func (cs *csms) SetVariables(clientId string, callback func(*provisioning.SetVar
 	return cs.SendRequestAsync(clientId, request, genericCallback)
 }
 
+func (cs *csms) RotateBasicAuthPassword(clientId string, callback func(*provisioning.SetVariablesResponse, error), newPassword string, props ...func(request *provisioning.SetVariablesRequest)) error {
+	if err := provisioning.ValidateBasicAuthPassword(newPassword); err != nil {
+		return err
+	}
+	return cs.SetVariables(clientId, callback, []provisioning.SetVariableData{provisioning.NewBasicAuthPasswordData(newPassword)}, props...)
+}
+
 func (cs *csms) TriggerMessage(clientId string, callback func(*remotecontrol.TriggerMessageResponse, error), requestedMessage remotecontrol.MessageTrigger, props ...func(request *remotecontrol.TriggerMessageRequest)) error {
 	request := remotecontrol.NewTriggerMessageRequest(requestedMessage)
 	for _, fn := range props {
@@ -673,15 +826,42 @@ func (cs *csms) UpdateFirmware(clientId string, callback func(*firmware.UpdateFi
 	return cs.SendRequestAsync(clientId, request, genericCallback)
 }
 
+func (cs *csms) UsePriorityCharging(clientId string, callback func(*remotecontrol.UsePriorityChargingResponse, error), transactionID string, activate bool, props ...func(request *remotecontrol.UsePriorityChargingRequest)) error {
+	request := remotecontrol.NewUsePriorityChargingRequest(transactionID, activate)
+	for _, fn := range props {
+		fn(request)
+	}
+	genericCallback := func(response ocpp.Response, protoError error) {
+		if response != nil {
+			callback(response.(*remotecontrol.UsePriorityChargingResponse), protoError)
+		} else {
+			callback(nil, protoError)
+		}
+	}
+	return cs.SendRequestAsync(clientId, request, genericCallback)
+}
+
 func (cs *csms) SetSecurityHandler(handler security.CSMSHandler) {
+	cs.securityHandler = security.AdaptCSMSHandler(handler)
+}
+
+func (cs *csms) SetSecurityHandlerWithContext(handler security.CSMSHandlerWithContext) {
 	cs.securityHandler = handler
 }
 
 func (cs *csms) SetProvisioningHandler(handler provisioning.CSMSHandler) {
+	cs.provisioningHandler = provisioning.AdaptCSMSHandler(handler)
+}
+
+func (cs *csms) SetProvisioningHandlerWithContext(handler provisioning.CSMSHandlerWithContext) {
 	cs.provisioningHandler = handler
 }
 
 func (cs *csms) SetAuthorizationHandler(handler authorization.CSMSHandler) {
+	cs.authorizationHandler = authorization.AdaptCSMSHandler(handler)
+}
+
+func (cs *csms) SetAuthorizationHandlerWithContext(handler authorization.CSMSHandlerWithContext) {
 	cs.authorizationHandler = handler
 }
 
@@ -690,18 +870,34 @@ func (cs *csms) SetLocalAuthListHandler(handler localauth.CSMSHandler) {
 }
 
 func (cs *csms) SetTransactionsHandler(handler transactions.CSMSHandler) {
+	cs.transactionsHandler = transactions.AdaptCSMSHandler(handler)
+}
+
+func (cs *csms) SetTransactionsHandlerWithContext(handler transactions.CSMSHandlerWithContext) {
 	cs.transactionsHandler = handler
 }
 
 func (cs *csms) SetRemoteControlHandler(handler remotecontrol.CSMSHandler) {
+	cs.remoteControlHandler = remotecontrol.AdaptCSMSHandler(handler)
+}
+
+func (cs *csms) SetRemoteControlHandlerWithContext(handler remotecontrol.CSMSHandlerWithContext) {
 	cs.remoteControlHandler = handler
 }
 
 func (cs *csms) SetAvailabilityHandler(handler availability.CSMSHandler) {
+	cs.availabilityHandler = availability.AdaptCSMSHandler(handler)
+}
+
+func (cs *csms) SetAvailabilityHandlerWithContext(handler availability.CSMSHandlerWithContext) {
 	cs.availabilityHandler = handler
 }
 
 func (cs *csms) SetReservationHandler(handler reservation.CSMSHandler) {
+	cs.reservationHandler = reservation.AdaptCSMSHandler(handler)
+}
+
+func (cs *csms) SetReservationHandlerWithContext(handler reservation.CSMSHandlerWithContext) {
 	cs.reservationHandler = handler
 }
 
@@ -710,30 +906,73 @@ func (cs *csms) SetTariffCostHandler(handler tariffcost.CSMSHandler) {
 }
 
 func (cs *csms) SetMeterHandler(handler meter.CSMSHandler) {
+	cs.meterHandler = meter.AdaptCSMSHandler(handler)
+}
+
+func (cs *csms) SetMeterHandlerWithContext(handler meter.CSMSHandlerWithContext) {
 	cs.meterHandler = handler
 }
 
 func (cs *csms) SetSmartChargingHandler(handler smartcharging.CSMSHandler) {
+	cs.smartChargingHandler = smartcharging.AdaptCSMSHandler(handler)
+}
+
+func (cs *csms) SetSmartChargingHandlerWithContext(handler smartcharging.CSMSHandlerWithContext) {
 	cs.smartChargingHandler = handler
 }
 
 func (cs *csms) SetFirmwareHandler(handler firmware.CSMSHandler) {
+	cs.firmwareHandler = firmware.AdaptCSMSHandler(handler)
+}
+
+func (cs *csms) SetFirmwareHandlerWithContext(handler firmware.CSMSHandlerWithContext) {
 	cs.firmwareHandler = handler
 }
 
 func (cs *csms) SetISO15118Handler(handler iso15118.CSMSHandler) {
+	cs.iso15118Handler = iso15118.AdaptCSMSHandler(handler)
+}
+
+func (cs *csms) SetISO15118HandlerWithContext(handler iso15118.CSMSHandlerWithContext) {
 	cs.iso15118Handler = handler
 }
 
 func (cs *csms) SetDiagnosticsHandler(handler diagnostics.CSMSHandler) {
+	cs.diagnosticsHandler = diagnostics.AdaptCSMSHandler(handler)
+}
+
+func (cs *csms) SetDiagnosticsHandlerWithContext(handler diagnostics.CSMSHandlerWithContext) {
 	cs.diagnosticsHandler = handler
 }
 
 func (cs *csms) SetDisplayHandler(handler display.CSMSHandler) {
+	cs.setDisplayHandler(display.AdaptCSMSHandler(handler), handler)
+}
+
+func (cs *csms) SetDisplayHandlerWithContext(handler display.CSMSHandlerWithContext) {
+	cs.setDisplayHandler(handler, handler)
+}
+
+// Sets the display handler. The original handler is checked for the completion handler interface.
+func (cs *csms) setDisplayHandler(handler display.CSMSHandlerWithContext, original interface{}) {
 	cs.displayHandler = handler
+	cs.displayAssembler = nil
+	if completionHandler, ok := original.(display.DisplayMessagesCompletionHandler); ok {
+		cs.displayAssembler = display.NewMessagesAssembler(0, func(chargingStationID string, requestID int, messages []display.MessageInfo, err error) {
+			if err != nil {
+				// Partial messages are delivered anyway
+				go cs.error(err)
+			}
+			completionHandler.OnDisplayMessagesComplete(chargingStationID, requestID, messages)
+		})
+	}
 }
 
 func (cs *csms) SetDataHandler(handler data.CSMSHandler) {
+	cs.dataHandler = data.AdaptCSMSHandler(handler)
+}
+
+func (cs *csms) SetDataHandlerWithContext(handler data.CSMSHandlerWithContext) {
 	cs.dataHandler = handler
 }
 
@@ -742,21 +981,177 @@ func (cs *csms) SetNewChargingStationValidationHandler(handler ws.CheckClientHan
 }
 
 func (cs *csms) SetNewChargingStationHandler(handler ChargingStationConnectionHandler) {
-	cs.server.SetNewClientHandler(func(chargingStation ws.Channel) {
-		handler(chargingStation)
-	})
+	cs.newStationHandler = handler
 }
 
 func (cs *csms) SetChargingStationDisconnectedHandler(handler ChargingStationConnectionHandler) {
-	cs.server.SetDisconnectedClientHandler(func(chargingStation ws.Channel) {
-		handler(chargingStation)
-	})
+	cs.disconnectedHandler = handler
+}
+
+func (cs *csms) HoldRequestsUntilHandlersReady(maxHold time.Duration, maxQueued int) {
+	cs.server.HoldRequestsUntilHandlersReady(maxHold, maxQueued)
+}
+
+func (cs *csms) HandlersReady() {
+	cs.server.HandlersReady()
+}
+
+func (cs *csms) SetClientInbox(inboxSize int, maxWorkers int) {
+	cs.server.SetClientInbox(inboxSize, maxWorkers)
+}
+
+func (cs *csms) SetAuditHandler(handler func(entry ocppj.AuditEntry)) {
+	cs.server.SetAuditHandler(handler)
+}
+
+func (cs *csms) DroppedAuditEntries() uint64 {
+	return cs.server.DroppedAuditEntries()
+}
+
+// Returns the IDs of all connected charging stations, sorted by ID.
+func (cs *csms) getConnectedStations() []string {
+	cs.connectionsMutex.Lock()
+	defer cs.connectionsMutex.Unlock()
+	ids := make([]string, 0, len(cs.connectedStations))
+	for id := range cs.connectedStations {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Returns whether a charging station is connected, and a channel which is closed on the next connection of any charging station.
+func (cs *csms) getConnectionState(chargingStationID string) (bool, <-chan struct{}) {
+	cs.connectionsMutex.Lock()
+	defer cs.connectionsMutex.Unlock()
+	return cs.connectedStations[chargingStationID], cs.connectedC
+}
+
+func (cs *csms) SetMessageLimits(clientId string, limits provisioning.MessageLimits) {
+	cs.limitsMutex.Lock()
+	defer cs.limitsMutex.Unlock()
+	if limits.IsZero() {
+		delete(cs.messageLimits, clientId)
+		return
+	}
+	cs.messageLimits[clientId] = limits
+}
+
+func (cs *csms) getMessageLimits(clientId string) (provisioning.MessageLimits, bool) {
+	cs.limitsMutex.RLock()
+	defer cs.limitsMutex.RUnlock()
+	limits, ok := cs.messageLimits[clientId]
+	return limits, ok
+}
+
+func (cs *csms) SetChargingProfileValidation(enabled bool) {
+	cs.validateProfiles = enabled
+}
+
+func (cs *csms) SetCostCalculator(calculator tariffcost.Calculator, currency string) {
+	cs.costCalculator = calculator
+	cs.costCurrency = currency
+	if calculator != nil && cs.costTracker == nil {
+		cs.costTracker = transactions.NewEventTracker(nil, nil)
+	}
+}
+
+// Consults the cost calculator, if the handler didn't set a total cost. Calculation errors are reported, but don't fail the response.
+// The response returned by the handler is copied, as it may be shared across invocations.
+func (cs *csms) fillTotalCost(chargingStationID string, request *transactions.TransactionEventRequest, response *transactions.TransactionEventResponse) *transactions.TransactionEventResponse {
+	if cs.costCalculator == nil {
+		return response
+	}
+	// Anomalies are irrelevant for the cost calculation, the state is updated anyway
+	_ = cs.costTracker.Add(chargingStationID, request)
+	if response.TotalCost != nil {
+		return response
+	}
+	state, _ := cs.costTracker.TransactionState(chargingStationID, request.TransactionInfo.TransactionID)
+	totalCost, err := cs.costCalculator.Cost(request, tariffcost.TransactionSnapshot{TransactionState: state, Currency: cs.costCurrency})
+	if err != nil {
+		// Reported asynchronously, as the error channel must not delay the response
+		go cs.error(fmt.Errorf("couldn't calculate cost of transaction %v for client %s: %w", request.TransactionInfo.TransactionID, chargingStationID, err))
+		return response
+	}
+	filled := *response
+	filled.TotalCost = totalCost
+	return &filled
+}
+
+func toRequests[T ocpp.Request](requests []T) []ocpp.Request {
+	result := make([]ocpp.Request, len(requests))
+	for i, r := range requests {
+		result[i] = r
+	}
+	return result
+}
+
+// Sends the parts of a split request one after the other. The callback is invoked once, with all responses received so far.
+// Sending stops at the first error, or at the first response for which the optional proceed function returns false.
+func (cs *csms) sendSplitRequests(clientId string, requests []ocpp.Request, callback func(responses []ocpp.Response, err error), proceed ...func(response ocpp.Response) bool) error {
+	responses := make([]ocpp.Response, 0, len(requests))
+	var send func(i int) error
+	send = func(i int) error {
+		return cs.SendRequestAsync(clientId, requests[i], func(response ocpp.Response, err error) {
+			if err != nil {
+				callback(responses, err)
+				return
+			}
+			responses = append(responses, response)
+			for _, fn := range proceed {
+				if !fn(response) {
+					callback(responses, nil)
+					return
+				}
+			}
+			if i+1 == len(requests) {
+				callback(responses, nil)
+				return
+			}
+			// Sent from a separate goroutine, as callbacks may be invoked by the dispatcher itself
+			go func() {
+				if err := send(i + 1); err != nil {
+					callback(responses, err)
+				}
+			}()
+		})
+	}
+	return send(0)
+}
+
+// The aggregated status is Accepted if any part was accepted, EmptyResultSet if all parts yielded an empty result,
+// or the status of the first failed part otherwise.
+func aggregateGetReportResponses(responses []ocpp.Response) *provisioning.GetReportResponse {
+	var failed *provisioning.GetReportResponse
+	empty := true
+	for _, r := range responses {
+		response := r.(*provisioning.GetReportResponse)
+		switch response.Status {
+		case types.GenericDeviceModelStatusAccepted:
+			return provisioning.NewGetReportResponse(types.GenericDeviceModelStatusAccepted)
+		case types.GenericDeviceModelStatusEmptyResultSet:
+		default:
+			empty = false
+			if failed == nil {
+				failed = response
+			}
+		}
+	}
+	if empty {
+		return provisioning.NewGetReportResponse(types.GenericDeviceModelStatusEmptyResultSet)
+	}
+	return failed
 }
 
 func (cs *csms) SendRequestAsync(clientId string, request ocpp.Request, callback func(response ocpp.Response, err error)) error {
+	return cs.SendRequestAsyncWithContext(context.Background(), clientId, request, callback)
+}
+
+func (cs *csms) SendRequestAsyncWithContext(ctx context.Context, clientId string, request ocpp.Request, callback func(response ocpp.Response, err error)) error {
 	featureName := request.GetFeatureName()
 	if _, found := cs.server.GetProfileForFeature(featureName); !found {
-		return fmt.Errorf("feature %v is unsupported on CSMS (missing profile), cannot send request", featureName)
+		return fmt.Errorf("%w %v on CSMS (missing profile), cannot send request", ocppj.ErrUnsupportedFeature, featureName)
 	}
 	switch featureName {
 	case reservation.CancelReservationFeatureName,
@@ -798,14 +1193,16 @@ func (cs *csms) SendRequestAsync(clientId string, request ocpp.Request, callback
 		remotecontrol.TriggerMessageFeatureName,
 		remotecontrol.UnlockConnectorFeatureName,
 		firmware.UnpublishFirmwareFeatureName,
-		firmware.UpdateFirmwareFeatureName:
+		firmware.UpdateFirmwareFeatureName,
+		remotecontrol.UsePriorityChargingFeatureName:
 		break
 	default:
-		return fmt.Errorf("unsupported action %v on CSMS, cannot send request", featureName)
+		return fmt.Errorf("%w %v on CSMS, cannot send request", ocppj.ErrUnsupportedFeature, featureName)
 	}
 
 	send := func() error {
-		return cs.server.SendRequest(clientId, request)
+		_, err := cs.server.SendRequestWithContext(ctx, clientId, request)
+		return err
 	}
 	return cs.callbackQueue.TryQueue(clientId, send, callback)
 }
@@ -845,6 +1242,7 @@ func (cs *csms) sendResponse(chargingStationID string, response ocpp.Response, e
 		return
 	}
 
+	cs.observeHeartbeatInterval(chargingStationID, response)
 	// send confirmation response
 	err = cs.server.SendResponse(chargingStationID, requestId, response)
 	if err != nil {
@@ -872,12 +1270,18 @@ func (cs *csms) notSupportedError(chargingStationID string, requestId string, ac
 	}
 }
 
-func (cs *csms) handleIncomingRequest(chargingStation ChargingStationConnection, request ocpp.Request, requestId string, action string) {
+func (cs *csms) handleIncomingRequest(ctx context.Context, chargingStation ChargingStationConnection, request ocpp.Request, requestId string, action string) {
 	profile, found := cs.server.GetProfileForFeature(action)
 	// Check whether action is supported and a listener for it exists
 	if !found {
 		cs.notImplementedError(chargingStation.ID(), requestId, action)
 		return
+	} else if response, ok := cs.defaultResponse(action); ok {
+		if cs.defaultObserver != nil {
+			cs.defaultObserver.ObserveRequest(chargingStation.ID(), request)
+		}
+		cs.sendResponse(chargingStation.ID(), response, nil, requestId)
+		return
 	} else {
 		supported := true
 		switch profile.Name {
@@ -955,57 +1359,75 @@ func (cs *csms) handleIncomingRequest(chargingStation ChargingStationConnection,
 	var err error
 	// Execute in separate goroutine, so the caller goroutine is available
 	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				cs.server.HandlePanic(chargingStation.ID(), requestId, ocppj.NewHandlerPanicError(chargingStation.ID(), action, r))
+			}
+		}()
 		switch action {
 		case provisioning.BootNotificationFeatureName:
-			response, err = cs.provisioningHandler.OnBootNotification(chargingStation.ID(), request.(*provisioning.BootNotificationRequest))
+			response, err = cs.provisioningHandler.OnBootNotification(ctx, chargingStation.ID(), request.(*provisioning.BootNotificationRequest))
 		case authorization.AuthorizeFeatureName:
-			response, err = cs.authorizationHandler.OnAuthorize(chargingStation.ID(), request.(*authorization.AuthorizeRequest))
+			response, err = cs.authorizationHandler.OnAuthorize(ctx, chargingStation.ID(), request.(*authorization.AuthorizeRequest))
 		case smartcharging.ClearedChargingLimitFeatureName:
-			response, err = cs.smartChargingHandler.OnClearedChargingLimit(chargingStation.ID(), request.(*smartcharging.ClearedChargingLimitRequest))
+			response, err = cs.smartChargingHandler.OnClearedChargingLimit(ctx, chargingStation.ID(), request.(*smartcharging.ClearedChargingLimitRequest))
 		case data.DataTransferFeatureName:
-			response, err = cs.dataHandler.OnDataTransfer(chargingStation.ID(), request.(*data.DataTransferRequest))
+			response, err = cs.dataHandler.OnDataTransfer(ctx, chargingStation.ID(), request.(*data.DataTransferRequest))
 		case firmware.FirmwareStatusNotificationFeatureName:
-			response, err = cs.firmwareHandler.OnFirmwareStatusNotification(chargingStation.ID(), request.(*firmware.FirmwareStatusNotificationRequest))
+			response, err = cs.firmwareHandler.OnFirmwareStatusNotification(ctx, chargingStation.ID(), request.(*firmware.FirmwareStatusNotificationRequest))
 		case iso15118.Get15118EVCertificateFeatureName:
-			response, err = cs.iso15118Handler.OnGet15118EVCertificate(chargingStation.ID(), request.(*iso15118.Get15118EVCertificateRequest))
+			response, err = cs.iso15118Handler.OnGet15118EVCertificate(ctx, chargingStation.ID(), request.(*iso15118.Get15118EVCertificateRequest))
 		case iso15118.GetCertificateStatusFeatureName:
-			response, err = cs.iso15118Handler.OnGetCertificateStatus(chargingStation.ID(), request.(*iso15118.GetCertificateStatusRequest))
+			response, err = cs.iso15118Handler.OnGetCertificateStatus(ctx, chargingStation.ID(), request.(*iso15118.GetCertificateStatusRequest))
 		case availability.HeartbeatFeatureName:
-			response, err = cs.availabilityHandler.OnHeartbeat(chargingStation.ID(), request.(*availability.HeartbeatRequest))
+			response, err = cs.availabilityHandler.OnHeartbeat(ctx, chargingStation.ID(), request.(*availability.HeartbeatRequest))
 		case diagnostics.LogStatusNotificationFeatureName:
-			response, err = cs.diagnosticsHandler.OnLogStatusNotification(chargingStation.ID(), request.(*diagnostics.LogStatusNotificationRequest))
+			response, err = cs.diagnosticsHandler.OnLogStatusNotification(ctx, chargingStation.ID(), request.(*diagnostics.LogStatusNotificationRequest))
 		case meter.MeterValuesFeatureName:
-			response, err = cs.meterHandler.OnMeterValues(chargingStation.ID(), request.(*meter.MeterValuesRequest))
+			response, err = cs.meterHandler.OnMeterValues(ctx, chargingStation.ID(), request.(*meter.MeterValuesRequest))
 		case smartcharging.NotifyChargingLimitFeatureName:
-			response, err = cs.smartChargingHandler.OnNotifyChargingLimit(chargingStation.ID(), request.(*smartcharging.NotifyChargingLimitRequest))
+			response, err = cs.smartChargingHandler.OnNotifyChargingLimit(ctx, chargingStation.ID(), request.(*smartcharging.NotifyChargingLimitRequest))
 		case diagnostics.NotifyCustomerInformationFeatureName:
-			response, err = cs.diagnosticsHandler.OnNotifyCustomerInformation(chargingStation.ID(), request.(*diagnostics.NotifyCustomerInformationRequest))
+			response, err = cs.diagnosticsHandler.OnNotifyCustomerInformation(ctx, chargingStation.ID(), request.(*diagnostics.NotifyCustomerInformationRequest))
 		case display.NotifyDisplayMessagesFeatureName:
-			response, err = cs.displayHandler.OnNotifyDisplayMessages(chargingStation.ID(), request.(*display.NotifyDisplayMessagesRequest))
+			notification := request.(*display.NotifyDisplayMessagesRequest)
+			response, err = cs.displayHandler.OnNotifyDisplayMessages(ctx, chargingStation.ID(), notification)
+			if cs.displayAssembler != nil && err == nil {
+				// Errors are passed to the completion handler
+				_ = cs.displayAssembler.Add(chargingStation.ID(), notification)
+			}
 		case smartcharging.NotifyEVChargingNeedsFeatureName:
-			response, err = cs.smartChargingHandler.OnNotifyEVChargingNeeds(chargingStation.ID(), request.(*smartcharging.NotifyEVChargingNeedsRequest))
+			response, err = cs.smartChargingHandler.OnNotifyEVChargingNeeds(ctx, chargingStation.ID(), request.(*smartcharging.NotifyEVChargingNeedsRequest))
 		case smartcharging.NotifyEVChargingScheduleFeatureName:
-			response, err = cs.smartChargingHandler.OnNotifyEVChargingSchedule(chargingStation.ID(), request.(*smartcharging.NotifyEVChargingScheduleRequest))
+			response, err = cs.smartChargingHandler.OnNotifyEVChargingSchedule(ctx, chargingStation.ID(), request.(*smartcharging.NotifyEVChargingScheduleRequest))
 		case diagnostics.NotifyEventFeatureName:
-			response, err = cs.diagnosticsHandler.OnNotifyEvent(chargingStation.ID(), request.(*diagnostics.NotifyEventRequest))
+			response, err = cs.diagnosticsHandler.OnNotifyEvent(ctx, chargingStation.ID(), request.(*diagnostics.NotifyEventRequest))
 		case diagnostics.NotifyMonitoringReportFeatureName:
-			response, err = cs.diagnosticsHandler.OnNotifyMonitoringReport(chargingStation.ID(), request.(*diagnostics.NotifyMonitoringReportRequest))
+			response, err = cs.diagnosticsHandler.OnNotifyMonitoringReport(ctx, chargingStation.ID(), request.(*diagnostics.NotifyMonitoringReportRequest))
+		case remotecontrol.NotifyPriorityChargingFeatureName:
+			response, err = cs.remoteControlHandler.OnNotifyPriorityCharging(ctx, chargingStation.ID(), request.(*remotecontrol.NotifyPriorityChargingRequest))
 		case provisioning.NotifyReportFeatureName:
-			response, err = cs.provisioningHandler.OnNotifyReport(chargingStation.ID(), request.(*provisioning.NotifyReportRequest))
+			response, err = cs.provisioningHandler.OnNotifyReport(ctx, chargingStation.ID(), request.(*provisioning.NotifyReportRequest))
 		case firmware.PublishFirmwareStatusNotificationFeatureName:
-			response, err = cs.firmwareHandler.OnPublishFirmwareStatusNotification(chargingStation.ID(), request.(*firmware.PublishFirmwareStatusNotificationRequest))
+			response, err = cs.firmwareHandler.OnPublishFirmwareStatusNotification(ctx, chargingStation.ID(), request.(*firmware.PublishFirmwareStatusNotificationRequest))
 		case smartcharging.ReportChargingProfilesFeatureName:
-			response, err = cs.smartChargingHandler.OnReportChargingProfiles(chargingStation.ID(), request.(*smartcharging.ReportChargingProfilesRequest))
+			response, err = cs.smartChargingHandler.OnReportChargingProfiles(ctx, chargingStation.ID(), request.(*smartcharging.ReportChargingProfilesRequest))
 		case reservation.ReservationStatusUpdateFeatureName:
-			response, err = cs.reservationHandler.OnReservationStatusUpdate(chargingStation.ID(), request.(*reservation.ReservationStatusUpdateRequest))
+			response, err = cs.reservationHandler.OnReservationStatusUpdate(ctx, chargingStation.ID(), request.(*reservation.ReservationStatusUpdateRequest))
 		case security.SecurityEventNotificationFeatureName:
-			response, err = cs.securityHandler.OnSecurityEventNotification(chargingStation.ID(), request.(*security.SecurityEventNotificationRequest))
+			response, err = cs.securityHandler.OnSecurityEventNotification(ctx, chargingStation.ID(), request.(*security.SecurityEventNotificationRequest))
 		case security.SignCertificateFeatureName:
-			response, err = cs.securityHandler.OnSignCertificate(chargingStation.ID(), request.(*security.SignCertificateRequest))
+			response, err = cs.securityHandler.OnSignCertificate(ctx, chargingStation.ID(), request.(*security.SignCertificateRequest))
 		case availability.StatusNotificationFeatureName:
-			response, err = cs.availabilityHandler.OnStatusNotification(chargingStation.ID(), request.(*availability.StatusNotificationRequest))
+			response, err = cs.availabilityHandler.OnStatusNotification(ctx, chargingStation.ID(), request.(*availability.StatusNotificationRequest))
 		case transactions.TransactionEventFeatureName:
-			response, err = cs.transactionsHandler.OnTransactionEvent(chargingStation.ID(), request.(*transactions.TransactionEventRequest))
+			transactionEvent := request.(*transactions.TransactionEventRequest)
+			var transactionResponse *transactions.TransactionEventResponse
+			transactionResponse, err = cs.transactionsHandler.OnTransactionEvent(ctx, chargingStation.ID(), transactionEvent)
+			if transactionResponse != nil && err == nil {
+				transactionResponse = cs.fillTotalCost(chargingStation.ID(), transactionEvent, transactionResponse)
+			}
+			response = transactionResponse
 		default:
 			cs.notSupportedError(chargingStation.ID(), requestId, action)
 			return
@@ -1015,9 +1437,10 @@ func (cs *csms) handleIncomingRequest(chargingStation ChargingStationConnection,
 }
 
 func (cs *csms) handleIncomingResponse(chargingStation ChargingStationConnection, response ocpp.Response, requestId string) {
+	cs.observeHeartbeatInterval(chargingStation.ID(), response)
 	if callback, ok := cs.callbackQueue.Dequeue(chargingStation.ID()); ok {
 		// Execute in separate goroutine, so the caller goroutine is available
-		go callback(response, nil)
+		go cs.invokeCallback(chargingStation.ID(), response.GetFeatureName(), callback, response, nil)
 	} else {
 		err := fmt.Errorf("no handler available for call of type %v from client %s for request %s", response.GetFeatureName(), chargingStation.ID(), requestId)
 		cs.error(err)
@@ -1027,16 +1450,52 @@ func (cs *csms) handleIncomingResponse(chargingStation ChargingStationConnection
 func (cs *csms) handleIncomingError(chargingStation ChargingStationConnection, err *ocpp.Error, details interface{}) {
 	if callback, ok := cs.callbackQueue.Dequeue(chargingStation.ID()); ok {
 		// Execute in separate goroutine, so the caller goroutine is available
-		go callback(nil, err)
+		go cs.invokeCallback(chargingStation.ID(), "", callback, nil, err)
 	} else {
 		cs.error(fmt.Errorf("no handler available for call error %w from client %s", err, chargingStation.ID()))
 	}
 }
 
+// Fails all pending requests to a charging station, since no response will be received anymore.
+func (cs *csms) handleConnect(chargingStation ChargingStationConnection) {
+	cs.connectionsMutex.Lock()
+	cs.connectedStations[chargingStation.ID()] = true
+	// Wake up everyone waiting for a connection
+	close(cs.connectedC)
+	cs.connectedC = make(chan struct{})
+	cs.connectionsMutex.Unlock()
+	if cs.newStationHandler != nil {
+		cs.newStationHandler(chargingStation)
+	}
+}
+
+func (cs *csms) handleDisconnect(chargingStation ChargingStationConnection) {
+	cs.connectionsMutex.Lock()
+	delete(cs.connectedStations, chargingStation.ID())
+	cs.connectionsMutex.Unlock()
+	for callback, ok := cs.callbackQueue.Dequeue(chargingStation.ID()); ok; callback, ok = cs.callbackQueue.Dequeue(chargingStation.ID()) {
+		// Execute in separate goroutine, so the caller goroutine is available
+		go cs.invokeCallback(chargingStation.ID(), "", callback, nil, ocpp.NewError(ocppj.GenericError, disconnectedErrorDescription, "").WithCause(ws.ErrNotConnected))
+	}
+	if cs.disconnectedHandler != nil {
+		cs.disconnectedHandler(chargingStation)
+	}
+}
+
+// Invokes a result callback. Panics are recovered and reported, without affecting the connection.
+func (cs *csms) invokeCallback(chargingStationID string, action string, callback func(response ocpp.Response, err error), response ocpp.Response, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			cs.server.HandlePanic(chargingStationID, "", ocppj.NewHandlerPanicError(chargingStationID, action, r))
+		}
+	}()
+	callback(response, err)
+}
+
 func (cs *csms) handleCanceledRequest(chargePointID string, request ocpp.Request, err *ocpp.Error) {
 	if callback, ok := cs.callbackQueue.Dequeue(chargePointID); ok {
 		// Execute in separate goroutine, so the caller goroutine is available
-		go callback(nil, err)
+		go cs.invokeCallback(chargePointID, request.GetFeatureName(), callback, nil, err)
 	} else {
 		err := fmt.Errorf("no handler available for canceled request %s for client %s: %w",
 			request.GetFeatureName(), chargePointID, err)
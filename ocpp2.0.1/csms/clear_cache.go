@@ -0,0 +1,26 @@
+package csms
+
+import (
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/authorization"
+)
+
+// CacheClearer sends ClearCache requests to charging stations. It is implemented by ocpp2.CSMS.
+type CacheClearer interface {
+	ClearCache(clientId string, callback func(*authorization.ClearCacheResponse, error), props ...func(*authorization.ClearCacheRequest)) error
+}
+
+// ClearCache sends a ClearCacheRequest to a charging station. If the charging station accepted the request,
+// its authorization cache is marked as empty (see OnAuthCacheCleared), before the callback is invoked.
+// The callback is optional.
+//
+// The local authorization list of the charging station is not affected by a ClearCacheRequest.
+func (r *StationRegistry) ClearCache(sender CacheClearer, chargingStationID string, callback func(*authorization.ClearCacheResponse, error)) error {
+	return sender.ClearCache(chargingStationID, func(response *authorization.ClearCacheResponse, err error) {
+		if err == nil && response != nil && response.Status == authorization.ClearCacheStatusAccepted {
+			r.OnAuthCacheCleared(chargingStationID)
+		}
+		if callback != nil {
+			callback(response, err)
+		}
+	})
+}
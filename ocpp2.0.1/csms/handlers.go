@@ -0,0 +1,127 @@
+package csms
+
+import (
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/availability"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/diagnostics"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/remotecontrol"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
+)
+
+// WrapProvisioningHandler returns a handler, which feeds BootNotification and NotifyReport requests to the registry.
+// All requests are forwarded to the passed handler. Requests are only applied if the handler didn't return an error.
+func (r *StationRegistry) WrapProvisioningHandler(handler provisioning.CSMSHandler) provisioning.CSMSHandler {
+	return &provisioningObserver{CSMSHandler: handler, registry: r}
+}
+
+// WrapAvailabilityHandler returns a handler, which feeds StatusNotification requests to the registry.
+// All requests are forwarded to the passed handler. Requests are only applied if the handler didn't return an error.
+func (r *StationRegistry) WrapAvailabilityHandler(handler availability.CSMSHandler) availability.CSMSHandler {
+	return &availabilityObserver{CSMSHandler: handler, registry: r}
+}
+
+// WrapTransactionsHandler returns a handler, which feeds TransactionEvent requests to the registry.
+// All requests are forwarded to the passed handler. Requests are only applied if the handler didn't return an error.
+func (r *StationRegistry) WrapTransactionsHandler(handler transactions.CSMSHandler) transactions.CSMSHandler {
+	return &transactionsObserver{CSMSHandler: handler, registry: r}
+}
+
+// WrapDiagnosticsHandler returns a handler, which feeds NotifyEvent requests to the registry.
+// All requests are forwarded to the passed handler. Requests are only applied if the handler didn't return an error.
+func (r *StationRegistry) WrapDiagnosticsHandler(handler diagnostics.CSMSHandler) diagnostics.CSMSHandler {
+	return &diagnosticsObserver{CSMSHandler: handler, registry: r}
+}
+
+// WrapRemoteControlHandler returns a handler, which feeds NotifyPriorityCharging requests to the registry.
+// All requests are forwarded to the passed handler. Requests are only applied if the handler didn't return an error.
+func (r *StationRegistry) WrapRemoteControlHandler(handler remotecontrol.CSMSHandler) remotecontrol.CSMSHandler {
+	return &remoteControlObserver{CSMSHandler: handler, registry: r}
+}
+
+// ObserveRequest applies the requests answered by the default handlers of a CSMS.
+// NotifyReport, StatusNotification and NotifyEvent requests are applied, all other requests are ignored.
+// Refer to ocpp2.CSMS.SetDefaultHandlerObserver.
+func (r *StationRegistry) ObserveRequest(chargingStationID string, request ocpp.Request) {
+	switch req := request.(type) {
+	case *provisioning.NotifyReportRequest:
+		r.OnNotifyReport(chargingStationID, req)
+	case *availability.StatusNotificationRequest:
+		r.OnStatusNotification(chargingStationID, req)
+	case *diagnostics.NotifyEventRequest:
+		r.OnNotifyEvent(chargingStationID, req)
+	}
+}
+
+type provisioningObserver struct {
+	provisioning.CSMSHandler
+	registry *StationRegistry
+}
+
+func (o *provisioningObserver) OnBootNotification(chargingStationID string, request *provisioning.BootNotificationRequest) (*provisioning.BootNotificationResponse, error) {
+	response, err := o.CSMSHandler.OnBootNotification(chargingStationID, request)
+	if err == nil {
+		o.registry.OnBootNotification(chargingStationID, request, response)
+	}
+	return response, err
+}
+
+func (o *provisioningObserver) OnNotifyReport(chargingStationID string, request *provisioning.NotifyReportRequest) (*provisioning.NotifyReportResponse, error) {
+	response, err := o.CSMSHandler.OnNotifyReport(chargingStationID, request)
+	if err == nil {
+		o.registry.OnNotifyReport(chargingStationID, request)
+	}
+	return response, err
+}
+
+type availabilityObserver struct {
+	availability.CSMSHandler
+	registry *StationRegistry
+}
+
+func (o *availabilityObserver) OnStatusNotification(chargingStationID string, request *availability.StatusNotificationRequest) (*availability.StatusNotificationResponse, error) {
+	response, err := o.CSMSHandler.OnStatusNotification(chargingStationID, request)
+	if err == nil {
+		o.registry.OnStatusNotification(chargingStationID, request)
+	}
+	return response, err
+}
+
+type transactionsObserver struct {
+	transactions.CSMSHandler
+	registry *StationRegistry
+}
+
+func (o *transactionsObserver) OnTransactionEvent(chargingStationID string, request *transactions.TransactionEventRequest) (*transactions.TransactionEventResponse, error) {
+	response, err := o.CSMSHandler.OnTransactionEvent(chargingStationID, request)
+	if err == nil {
+		o.registry.OnTransactionEvent(chargingStationID, request)
+	}
+	return response, err
+}
+
+type diagnosticsObserver struct {
+	diagnostics.CSMSHandler
+	registry *StationRegistry
+}
+
+func (o *diagnosticsObserver) OnNotifyEvent(chargingStationID string, request *diagnostics.NotifyEventRequest) (*diagnostics.NotifyEventResponse, error) {
+	response, err := o.CSMSHandler.OnNotifyEvent(chargingStationID, request)
+	if err == nil {
+		o.registry.OnNotifyEvent(chargingStationID, request)
+	}
+	return response, err
+}
+
+type remoteControlObserver struct {
+	remotecontrol.CSMSHandler
+	registry *StationRegistry
+}
+
+func (o *remoteControlObserver) OnNotifyPriorityCharging(chargingStationID string, request *remotecontrol.NotifyPriorityChargingRequest) (*remotecontrol.NotifyPriorityChargingResponse, error) {
+	response, err := o.CSMSHandler.OnNotifyPriorityCharging(chargingStationID, request)
+	if err == nil {
+		o.registry.OnNotifyPriorityCharging(chargingStationID, request)
+	}
+	return response, err
+}
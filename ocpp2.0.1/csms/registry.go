@@ -0,0 +1,870 @@
+// Package csms contains CSMS-side utilities, which combine the messages of several OCPP 2.0.1 profiles.
+//
+// A StationRegistry keeps a per-station model, derived from the messages received from the charging stations.
+// The registry is fed by wrapping the handlers passed to the CSMS, while the application handlers keep working as before:
+//
+//	registry := csms.NewStationRegistry()
+//	server.SetProvisioningHandler(registry.WrapProvisioningHandler(provisioningHandler))
+//	server.SetAvailabilityHandler(registry.WrapAvailabilityHandler(availabilityHandler))
+//	server.SetTransactionsHandler(registry.WrapTransactionsHandler(transactionsHandler))
+//	server.SetDiagnosticsHandler(registry.WrapDiagnosticsHandler(diagnosticsHandler))
+//	server.SetRemoteControlHandler(registry.WrapRemoteControlHandler(remoteControlHandler))
+//	server.SetNewChargingStationHandler(func(chargingStation ocpp2.ChargingStationConnection) {
+//		registry.OnStationConnected(chargingStation.ID())
+//	})
+//	server.SetChargingStationDisconnectedHandler(func(chargingStation ocpp2.ChargingStationConnection) {
+//		registry.OnStationDisconnected(chargingStation.ID())
+//	})
+//
+// Requests answered by the default handlers of the CSMS are applied, if the registry is set as their observer:
+//
+//	server.UseDefaultHandlers(ocpp2.DefaultStatusNotification | ocpp2.DefaultNotifyReport)
+//	server.SetDefaultHandlerObserver(registry)
+//
+// The liveness state of each charging station is included, if the registry is set as liveness handler:
+//
+//	server.EnableLivenessMonitor(ocppj.LivenessConfig{DefaultHeartbeatInterval: 5 * time.Minute, ProbeTimeout: 30 * time.Second})
+//	server.SetLivenessHandler(registry.OnLivenessChanged)
+//
+// Accepted boots, started and ended transactions and connector status changes are published as events,
+// if an event bus is attached via SetEventBus.
+package csms
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/clock"
+	"github.com/lorenzodonini/ocpp-go/events"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/availability"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/diagnostics"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/remotecontrol"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+)
+
+// ChangeType describes which part of the model of a charging station changed.
+type ChangeType string
+
+const (
+	ChangeConnected       ChangeType = "Connected"       // The charging station connected, or sent a message after being evicted.
+	ChangeDisconnected    ChangeType = "Disconnected"    // The charging station disconnected. Its model is retained until it is evicted.
+	ChangeEvicted         ChangeType = "Evicted"         // The model of the charging station was discarded.
+	ChangeBoot            ChangeType = "Boot"            // A BootNotification was received.
+	ChangeDeviceModel     ChangeType = "DeviceModel"     // A NotifyReport updated the device model.
+	ChangeConnectorStatus ChangeType = "ConnectorStatus" // A StatusNotification updated the status of a connector.
+	ChangeTransaction     ChangeType = "Transaction"     // A transaction was started, updated or ended, or priority charging was started or stopped.
+	ChangeAlert           ChangeType = "Alert"           // A NotifyEvent raised or cleared an alert.
+	ChangeAuthCache       ChangeType = "AuthCache"       // The authorization cache was cleared, or an idToken was used since.
+	ChangeLiveness        ChangeType = "Liveness"        // The liveness monitor of the CSMS probed, recovered or reaped the charging station.
+)
+
+// StationChange is passed to the listeners of a StationRegistry, every time the model of a charging station changed.
+type StationChange struct {
+	ChargingStationID string
+	Type              ChangeType
+}
+
+// StationListener is invoked by a StationRegistry for every change. Use StationRegistry.Station for retrieving the new state.
+type StationListener func(change StationChange)
+
+// StationInfo contains the information reported by a charging station via BootNotification.
+type StationInfo struct {
+	VendorName      string                          `json:"vendorName"`
+	Model           string                          `json:"model"`
+	SerialNumber    string                          `json:"serialNumber,omitempty"`
+	FirmwareVersion string                          `json:"firmwareVersion,omitempty"`
+	Modem           *provisioning.ModemType         `json:"modem,omitempty"`
+	BootReason      provisioning.BootReason         `json:"bootReason"`
+	BootedAt        time.Time                       `json:"bootedAt"`
+	Registration    provisioning.RegistrationStatus `json:"registrationStatus,omitempty"` // The status returned by the CSMS. Empty if the wrapped handler wasn't used.
+}
+
+// ConnectorStatus contains the last known status of a connector, as reported via StatusNotification.
+type ConnectorStatus struct {
+	EvseID      int                          `json:"evseId"`
+	ConnectorID int                          `json:"connectorId"`
+	Status      availability.ConnectorStatus `json:"connectorStatus"`
+	Timestamp   time.Time                    `json:"timestamp"`
+}
+
+// ActiveTransaction contains the last known state of a transaction, which didn't end yet.
+type ActiveTransaction struct {
+	TransactionID    string                     `json:"transactionId"`
+	EvseID           int                        `json:"evseId"`
+	ConnectorID      *int                       `json:"connectorId,omitempty"`
+	ChargingState    transactions.ChargingState `json:"chargingState,omitempty"`
+	IDToken          *types.IdToken             `json:"idToken,omitempty"`
+	RemoteStartID    *int                       `json:"remoteStartId,omitempty"`
+	PriorityCharging bool                       `json:"priorityCharging,omitempty"` // True while priority charging is active, as reported via NotifyPriorityCharging.
+	StartedAt        time.Time                  `json:"startedAt"`                  // The timestamp of the first event received for the transaction.
+	UpdatedAt        time.Time                  `json:"updatedAt"`
+	LastSeqNo        int                        `json:"seqNo"`
+}
+
+// Alert contains an event reported via NotifyEvent, which wasn't cleared yet.
+// Alerts are identified by their component, variable and monitor: a later event for the same monitor replaces the alert.
+type Alert struct {
+	EventID               int                           `json:"eventId"`
+	Timestamp             time.Time                     `json:"timestamp"`
+	Trigger               diagnostics.EventTrigger      `json:"trigger"`
+	ActualValue           string                        `json:"actualValue"`
+	TechCode              string                        `json:"techCode,omitempty"`
+	TechInfo              string                        `json:"techInfo,omitempty"`
+	TransactionID         string                        `json:"transactionId,omitempty"`
+	VariableMonitoringID  *int                          `json:"variableMonitoringId,omitempty"`
+	EventNotificationType diagnostics.EventNotification `json:"eventNotificationType"`
+	Component             types.Component               `json:"component"`
+	Variable              types.Variable                `json:"variable"`
+}
+
+// AuthCacheState is the known state of the authorization cache of a charging station.
+type AuthCacheState struct {
+	Empty     bool      `json:"empty"`     // True if no idToken was used since the cache was cleared.
+	ClearedAt time.Time `json:"clearedAt"` // The time at which the charging station accepted the last ClearCacheRequest.
+}
+
+// StationSnapshot is a copy of the model of a charging station. Lists are sorted by EVSE and connector, start time
+// and timestamp respectively, while the device model retains the order in which the variables were first reported.
+type StationSnapshot struct {
+	ChargingStationID string                             `json:"chargingStationId"`
+	Connected         bool                               `json:"connected"`
+	LastSeen          time.Time                          `json:"lastSeen"`
+	DisconnectedAt    *time.Time                         `json:"disconnectedAt,omitempty"`
+	Info              *StationInfo                       `json:"info,omitempty"` // Nil until a BootNotification was received.
+	DeviceModel       []provisioning.DeviceModelVariable `json:"deviceModel,omitempty"`
+	Connectors        []ConnectorStatus                  `json:"connectors,omitempty"`
+	Transactions      []ActiveTransaction                `json:"transactions,omitempty"`
+	Alerts            []Alert                            `json:"alerts,omitempty"`
+	AuthCache         *AuthCacheState                    `json:"authCache,omitempty"` // Nil until the cache was cleared via the registry.
+	Liveness          *ocppj.Liveness                    `json:"liveness,omitempty"`  // Nil until the liveness monitor reported a change.
+}
+
+// Value returns the Actual value of a variable of the device model. Names are case-insensitive.
+// Only components at charging station level are considered, i.e. components without EVSE.
+func (s *StationSnapshot) Value(componentName string, variableName string) (string, bool) {
+	for i := range s.DeviceModel {
+		v := &s.DeviceModel[i]
+		if v.Component.EVSE != nil || !strings.EqualFold(v.Component.Name, componentName) || !strings.EqualFold(v.Variable.Name, variableName) {
+			continue
+		}
+		if attribute, ok := v.Attribute(types.AttributeActual); ok {
+			return attribute.Value, true
+		}
+	}
+	return "", false
+}
+
+// StationFilter selects charging stations within StationRegistry.Query.
+type StationFilter func(snapshot *StationSnapshot) bool
+
+// FirmwareBefore matches charging stations which reported a firmware version lower than the passed version.
+// Versions are compared segment by segment, numerically where possible (e.g. 1.9.2 < 1.10).
+// Charging stations which didn't report a firmware version yet are not matched.
+func FirmwareBefore(version string) StationFilter {
+	return func(snapshot *StationSnapshot) bool {
+		if snapshot.Info == nil || snapshot.Info.FirmwareVersion == "" {
+			return false
+		}
+		return CompareVersions(snapshot.Info.FirmwareVersion, version) < 0
+	}
+}
+
+// VariableEquals matches charging stations, for which the Actual value of a device model variable equals the passed value.
+func VariableEquals(componentName string, variableName string, value string) StationFilter {
+	return func(snapshot *StationSnapshot) bool {
+		actual, ok := snapshot.Value(componentName, variableName)
+		return ok && actual == value
+	}
+}
+
+// CompareVersions compares two version strings, returning -1, 0 or 1.
+// Segments are separated by dots or dashes; numeric segments are compared numerically, others lexically.
+// If one version is a prefix of the other, the shorter version is considered lower.
+func CompareVersions(a string, b string) int {
+	split := func(version string) []string {
+		return strings.FieldsFunc(version, func(r rune) bool { return r == '.' || r == '-' })
+	}
+	segmentsA, segmentsB := split(a), split(b)
+	for i := 0; i < len(segmentsA) && i < len(segmentsB); i++ {
+		numA, errA := strconv.Atoi(segmentsA[i])
+		numB, errB := strconv.Atoi(segmentsB[i])
+		switch {
+		case errA == nil && errB == nil && numA != numB:
+			if numA < numB {
+				return -1
+			}
+			return 1
+		case (errA != nil || errB != nil) && segmentsA[i] != segmentsB[i]:
+			if segmentsA[i] < segmentsB[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case len(segmentsA) < len(segmentsB):
+		return -1
+	case len(segmentsA) > len(segmentsB):
+		return 1
+	}
+	return 0
+}
+
+// Component and variable names and instances are case-insensitive.
+type alertKey struct {
+	component    string
+	instance     string
+	evseID       int
+	connectorID  int
+	variable     string
+	variableInst string
+	monitorID    int
+}
+
+func newAlertKey(event *diagnostics.EventData) alertKey {
+	key := alertKey{
+		component:    strings.ToLower(event.Component.Name),
+		instance:     strings.ToLower(event.Component.Instance),
+		evseID:       -1,
+		connectorID:  -1,
+		variable:     strings.ToLower(event.Variable.Name),
+		variableInst: strings.ToLower(event.Variable.Instance),
+		monitorID:    -1,
+	}
+	if event.Component.EVSE != nil {
+		key.evseID = event.Component.EVSE.ID
+		if event.Component.EVSE.ConnectorID != nil {
+			key.connectorID = *event.Component.EVSE.ConnectorID
+		}
+	}
+	if event.VariableMonitoringID != nil {
+		key.monitorID = *event.VariableMonitoringID
+	}
+	return key
+}
+
+type connectorKey struct {
+	evseID      int
+	connectorID int
+}
+
+type stationState struct {
+	info           *StationInfo
+	deviceModel    *provisioning.DeviceModel
+	reportedAt     *time.Time // The time of the last NotifyReport, which changed the device model.
+	connectors     map[connectorKey]ConnectorStatus
+	transactions   map[string]*ActiveTransaction
+	alerts         map[alertKey]Alert
+	authCache      *AuthCacheState
+	liveness       *ocppj.Liveness
+	connected      bool
+	lastSeen       time.Time
+	disconnectedAt *time.Time
+	evictionTimer  clock.Timer
+	disconnects    int
+}
+
+// StationRegistry keeps a model of every charging station known to a CSMS, derived from the messages it sent:
+//
+//   - BootNotification: vendor, model, serial number and firmware version
+//   - NotifyReport: the device model, i.e. all reported variables and their attributes
+//   - StatusNotification: the status of each connector
+//   - TransactionEvent: the ongoing transactions
+//   - NotifyPriorityCharging: whether priority charging is active for an ongoing transaction
+//   - NotifyEvent: the active alerts, until they are cleared
+//   - ClearCache: whether the authorization cache is empty (see ClearCache)
+//
+// The registry may either be fed via the On* methods, or by wrapping the CSMS handlers (see WrapProvisioningHandler).
+// Models of disconnected charging stations are retained until the eviction timeout expired (see SetEvictionTimeout).
+// Statuses reported via StatusNotification are ordered by their timestamp, so that a notification delivered late
+// cannot overwrite a more recent status.
+//
+// A StationRegistry is safe for concurrent use. Listeners are invoked synchronously, without holding any lock.
+type StationRegistry struct {
+	stations        map[string]*stationState
+	listeners       map[int]StationListener
+	nextListenerID  int
+	evictionTimeout time.Duration
+	clock           clock.Clock
+	bus             *events.Bus
+	mutex           sync.RWMutex
+}
+
+// NewStationRegistry creates a new, empty registry. Disconnected charging stations are never evicted by default.
+func NewStationRegistry() *StationRegistry {
+	return &StationRegistry{
+		stations:  map[string]*stationState{},
+		listeners: map[int]StationListener{},
+		clock:     clock.New(),
+	}
+}
+
+// SetTimeSource replaces the function used for retrieving the current time, while timers are backed by the real clock.
+//
+// Deprecated: Use SetClock instead.
+func (r *StationRegistry) SetTimeSource(now func() time.Time) {
+	r.SetClock(clock.WithNow(now))
+}
+
+// SetClock sets the clock used for retrieving the current time and for evicting disconnected charging stations.
+// Passing nil restores the real clock.
+func (r *StationRegistry) SetClock(c clock.Clock) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.clock = clock.OrDefault(c)
+}
+
+// SetEvictionTimeout sets the time after which the model of a disconnected charging station is discarded.
+// A value of zero or less disables eviction. The timeout only applies to future disconnections.
+func (r *StationRegistry) SetEvictionTimeout(timeout time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.evictionTimeout = timeout
+}
+
+// SetEventBus sets a bus, on which BootAccepted, TransactionStarted, TransactionEnded and ConnectorStatusChanged
+// events are published, as the respective messages are applied. Passing nil disables publishing.
+func (r *StationRegistry) SetEventBus(bus *events.Bus) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.bus = bus
+}
+
+// Subscribe registers a listener, which is invoked for every change of any charging station.
+// The returned function removes the listener again.
+func (r *StationRegistry) Subscribe(listener StationListener) (unsubscribe func()) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	id := r.nextListenerID
+	r.nextListenerID++
+	r.listeners[id] = listener
+	return func() {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		delete(r.listeners, id)
+	}
+}
+
+// OnStationConnected marks a charging station as connected, canceling a pending eviction.
+func (r *StationRegistry) OnStationConnected(chargingStationID string) {
+	r.update(chargingStationID, func(state *stationState) []ChangeType {
+		return nil
+	})
+}
+
+// OnStationDisconnected marks a charging station as disconnected. If an eviction timeout is set,
+// the model of the charging station is discarded once the timeout expired, unless it reconnected in the meantime.
+func (r *StationRegistry) OnStationDisconnected(chargingStationID string) {
+	r.mutex.Lock()
+	state, ok := r.stations[chargingStationID]
+	if !ok || !state.connected {
+		r.mutex.Unlock()
+		return
+	}
+	state.connected = false
+	disconnectedAt := r.clock.Now()
+	state.disconnectedAt = &disconnectedAt
+	if r.evictionTimeout > 0 {
+		state.disconnects++
+		disconnects := state.disconnects
+		state.evictionTimer = r.clock.AfterFunc(r.evictionTimeout, func() {
+			r.evict(chargingStationID, disconnects)
+		})
+	}
+	listeners := r.copyListeners()
+	r.mutex.Unlock()
+	notify(listeners, StationChange{ChargingStationID: chargingStationID, Type: ChangeDisconnected})
+}
+
+// RemoveStation discards the model of a charging station right away.
+func (r *StationRegistry) RemoveStation(chargingStationID string) {
+	r.mutex.Lock()
+	state, ok := r.stations[chargingStationID]
+	if !ok {
+		r.mutex.Unlock()
+		return
+	}
+	if state.evictionTimer != nil {
+		state.evictionTimer.Stop()
+	}
+	delete(r.stations, chargingStationID)
+	listeners := r.copyListeners()
+	r.mutex.Unlock()
+	notify(listeners, StationChange{ChargingStationID: chargingStationID, Type: ChangeEvicted})
+}
+
+// OnBootNotification applies the station information contained in a BootNotificationRequest.
+// The response is optional and only used for recording the registration status.
+func (r *StationRegistry) OnBootNotification(chargingStationID string, request *provisioning.BootNotificationRequest, response *provisioning.BootNotificationResponse) {
+	if request == nil {
+		return
+	}
+	r.update(chargingStationID, func(state *stationState) []ChangeType {
+		station := request.ChargingStation
+		info := &StationInfo{
+			VendorName:      station.VendorName,
+			Model:           station.Model,
+			SerialNumber:    station.SerialNumber,
+			FirmwareVersion: station.FirmwareVersion,
+			BootReason:      request.Reason,
+			BootedAt:        state.lastSeen,
+		}
+		if station.Modem != nil {
+			modem := *station.Modem
+			info.Modem = &modem
+		}
+		if response != nil {
+			info.Registration = response.Status
+		}
+		state.info = info
+		return []ChangeType{ChangeBoot}
+	})
+	if response != nil && response.Status == provisioning.RegistrationStatusAccepted {
+		r.publish(events.BootAcceptedEvent{StationID: chargingStationID, Interval: time.Duration(response.Interval) * time.Second})
+	}
+}
+
+// OnNotifyReport applies the variables contained in a NotifyReportRequest to the device model of a charging station.
+// Each part of a report is applied right away. Variables which were reported before are replaced.
+// Invalid variables are skipped.
+func (r *StationRegistry) OnNotifyReport(chargingStationID string, request *provisioning.NotifyReportRequest) {
+	if request == nil {
+		return
+	}
+	r.update(chargingStationID, func(state *stationState) []ChangeType {
+		changed := false
+		for _, data := range request.ReportData {
+			variable := provisioning.DeviceModelVariable{
+				Component:       data.Component,
+				Variable:        data.Variable,
+				Attributes:      data.VariableAttribute,
+				Characteristics: data.VariableCharacteristics,
+			}
+			if err := state.deviceModel.AddVariable(variable); err == nil {
+				changed = true
+			}
+		}
+		if !changed {
+			return nil
+		}
+		reportedAt := state.lastSeen
+		state.reportedAt = &reportedAt
+		return []ChangeType{ChangeDeviceModel}
+	})
+}
+
+// OnStatusNotification applies the connector status contained in a StatusNotificationRequest.
+// The notification is discarded if a more recent status is already known for the connector.
+func (r *StationRegistry) OnStatusNotification(chargingStationID string, request *availability.StatusNotificationRequest) {
+	if request == nil {
+		return
+	}
+	var event events.Event
+	r.update(chargingStationID, func(state *stationState) []ChangeType {
+		timestamp := timestampOf(request.Timestamp, state.lastSeen)
+		key := connectorKey{evseID: request.EvseID, connectorID: request.ConnectorID}
+		previous, ok := state.connectors[key]
+		if ok && timestamp.Before(previous.Timestamp) {
+			return nil
+		}
+		if !ok || previous.Status != request.ConnectorStatus {
+			event = events.ConnectorStatusChangedEvent{StationID: chargingStationID, EvseID: request.EvseID, ConnectorID: request.ConnectorID, Status: string(request.ConnectorStatus)}
+		}
+		state.connectors[key] = ConnectorStatus{
+			EvseID:      request.EvseID,
+			ConnectorID: request.ConnectorID,
+			Status:      request.ConnectorStatus,
+			Timestamp:   timestamp,
+		}
+		return []ChangeType{ChangeConnectorStatus}
+	})
+	r.publish(event)
+}
+
+// OnTransactionEvent applies a TransactionEventRequest to the active transactions of a charging station.
+// Transactions are removed once the Ended event was received. Events older than the last applied one are discarded.
+func (r *StationRegistry) OnTransactionEvent(chargingStationID string, request *transactions.TransactionEventRequest) {
+	if request == nil {
+		return
+	}
+	var event events.Event
+	r.update(chargingStationID, func(state *stationState) []ChangeType {
+		transactionID := request.TransactionInfo.TransactionID
+		timestamp := timestampOf(request.Timestamp, state.lastSeen)
+		if request.EventType == transactions.TransactionEventEnded {
+			tx, ok := state.transactions[transactionID]
+			if !ok {
+				return nil
+			}
+			delete(state.transactions, transactionID)
+			event = events.TransactionEndedEvent{StationID: chargingStationID, TransactionID: transactionID, EvseID: tx.EvseID}
+			return []ChangeType{ChangeTransaction}
+		}
+		tx, ok := state.transactions[transactionID]
+		if !ok {
+			tx = &ActiveTransaction{TransactionID: transactionID, StartedAt: timestamp}
+			state.transactions[transactionID] = tx
+		} else if request.SequenceNo < tx.LastSeqNo {
+			return nil
+		}
+		tx.UpdatedAt = timestamp
+		tx.LastSeqNo = request.SequenceNo
+		if request.Evse != nil {
+			tx.EvseID = request.Evse.ID
+			if request.Evse.ConnectorID != nil {
+				connectorID := *request.Evse.ConnectorID
+				tx.ConnectorID = &connectorID
+			}
+		}
+		info := request.TransactionInfo
+		if info.ChargingState != "" {
+			tx.ChargingState = info.ChargingState
+		}
+		if info.RemoteStartID != nil {
+			remoteStartID := *info.RemoteStartID
+			tx.RemoteStartID = &remoteStartID
+		}
+		changes := []ChangeType{ChangeTransaction}
+		if request.IDToken != nil {
+			idToken := *request.IDToken
+			tx.IDToken = &idToken
+			// The charging station may have cached the authorization of the idToken
+			if state.authCache != nil && state.authCache.Empty {
+				state.authCache.Empty = false
+				changes = append(changes, ChangeAuthCache)
+			}
+		}
+		if !ok {
+			event = events.TransactionStartedEvent{StationID: chargingStationID, TransactionID: transactionID, EvseID: tx.EvseID}
+		}
+		return changes
+	})
+	r.publish(event)
+}
+
+// OnNotifyPriorityCharging records whether priority charging is active for an ongoing transaction, as reported
+// by the charging station once priority charging was started or stopped. Notifications for unknown transactions are discarded.
+//
+// Accepting a UsePriorityChargingRequest doesn't change the state of a transaction: the charging station
+// sends a NotifyPriorityChargingRequest once priority charging actually started.
+func (r *StationRegistry) OnNotifyPriorityCharging(chargingStationID string, request *remotecontrol.NotifyPriorityChargingRequest) {
+	if request == nil {
+		return
+	}
+	r.update(chargingStationID, func(state *stationState) []ChangeType {
+		tx, ok := state.transactions[request.TransactionID]
+		if !ok || tx.PriorityCharging == request.Activated {
+			return nil
+		}
+		tx.PriorityCharging = request.Activated
+		tx.UpdatedAt = state.lastSeen
+		return []ChangeType{ChangeTransaction}
+	})
+}
+
+// OnNotifyEvent applies the events contained in a NotifyEventRequest to the active alerts of a charging station.
+// Events with the cleared flag set remove the respective alert.
+func (r *StationRegistry) OnNotifyEvent(chargingStationID string, request *diagnostics.NotifyEventRequest) {
+	if request == nil {
+		return
+	}
+	r.update(chargingStationID, func(state *stationState) []ChangeType {
+		changed := false
+		for i := range request.EventData {
+			event := &request.EventData[i]
+			key := newAlertKey(event)
+			if event.Cleared {
+				if _, ok := state.alerts[key]; ok {
+					delete(state.alerts, key)
+					changed = true
+				}
+				continue
+			}
+			alert := Alert{
+				EventID:               event.EventID,
+				Timestamp:             timestampOf(event.Timestamp, state.lastSeen),
+				Trigger:               event.Trigger,
+				ActualValue:           event.ActualValue,
+				TechCode:              event.TechCode,
+				TechInfo:              event.TechInfo,
+				TransactionID:         event.TransactionID,
+				EventNotificationType: event.EventNotificationType,
+				Component:             event.Component,
+				Variable:              event.Variable,
+			}
+			if event.VariableMonitoringID != nil {
+				monitoringID := *event.VariableMonitoringID
+				alert.VariableMonitoringID = &monitoringID
+			}
+			state.alerts[key] = alert
+			changed = true
+		}
+		if !changed {
+			return nil
+		}
+		return []ChangeType{ChangeAlert}
+	})
+}
+
+// OnAuthCacheCleared marks the authorization cache of a charging station as empty, after it accepted a ClearCacheRequest.
+func (r *StationRegistry) OnAuthCacheCleared(chargingStationID string) {
+	r.update(chargingStationID, func(state *stationState) []ChangeType {
+		state.authCache = &AuthCacheState{Empty: true, ClearedAt: state.lastSeen}
+		return []ChangeType{ChangeAuthCache}
+	})
+}
+
+// OnLivenessChanged records the liveness state of a charging station, as reported by the liveness monitor of the CSMS.
+// The signature matches the liveness handler, so the registry may be passed directly:
+//
+//	server.SetLivenessHandler(registry.OnLivenessChanged)
+//
+// Unlike messages, liveness changes don't mark a charging station as connected: a reaped charging station
+// is marked as disconnected once its connection was closed.
+func (r *StationRegistry) OnLivenessChanged(chargingStationID string, liveness ocppj.Liveness) {
+	r.mutex.Lock()
+	state, ok := r.stations[chargingStationID]
+	if !ok {
+		r.mutex.Unlock()
+		return
+	}
+	state.liveness = &liveness
+	listeners := r.copyListeners()
+	r.mutex.Unlock()
+	notify(listeners, StationChange{ChargingStationID: chargingStationID, Type: ChangeLiveness})
+}
+
+// Station returns a snapshot of the model of a charging station.
+func (r *StationRegistry) Station(chargingStationID string) (StationSnapshot, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	state, ok := r.stations[chargingStationID]
+	if !ok {
+		return StationSnapshot{}, false
+	}
+	return state.snapshot(chargingStationID), true
+}
+
+// ReportedControllers returns the names of the controller components (e.g. "SmartChargingCtrlr") found in the device model
+// of a charging station, sorted by name, along with the time of the last report which changed the device model.
+// Controllers reporting false for their Enabled or Available variable are omitted.
+//
+// Returns false if the charging station didn't report its device model yet.
+func (r *StationRegistry) ReportedControllers(chargingStationID string) ([]string, time.Time, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	state, ok := r.stations[chargingStationID]
+	if !ok || state.reportedAt == nil {
+		return nil, time.Time{}, false
+	}
+	variables := state.deviceModel.Variables(func(variable *provisioning.DeviceModelVariable) bool {
+		return variable.Component.EVSE == nil && strings.HasSuffix(strings.ToLower(variable.Component.Name), "ctrlr")
+	})
+	controllers := map[string]bool{}
+	for i := range variables {
+		variable := &variables[i]
+		name := variable.Component.Name
+		if _, known := controllers[name]; !known {
+			controllers[name] = true
+		}
+		switch strings.ToLower(variable.Variable.Name) {
+		case "enabled", "available":
+			if attribute, ok := variable.Attribute(types.AttributeActual); ok && strings.EqualFold(attribute.Value, "false") {
+				controllers[name] = false
+			}
+		}
+	}
+	names := make([]string, 0, len(controllers))
+	for name, enabled := range controllers {
+		if enabled {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, *state.reportedAt, true
+}
+
+// Stations returns the IDs of all known charging stations, in ascending order.
+func (r *StationRegistry) Stations() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	ids := make([]string, 0, len(r.stations))
+	for id := range r.stations {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Query returns the snapshots of all charging stations matched by the filter, ordered by charging station ID.
+// A nil filter matches all charging stations.
+func (r *StationRegistry) Query(filter StationFilter) []StationSnapshot {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	var result []StationSnapshot
+	for id, state := range r.stations {
+		snapshot := state.snapshot(id)
+		if filter == nil || filter(&snapshot) {
+			result = append(result, snapshot)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ChargingStationID < result[j].ChargingStationID
+	})
+	return result
+}
+
+// ExportJSON returns the full model of a charging station, encoded as JSON.
+func (r *StationRegistry) ExportJSON(chargingStationID string) ([]byte, error) {
+	snapshot, ok := r.Station(chargingStationID)
+	if !ok {
+		return nil, fmt.Errorf("unknown charging station %v", chargingStationID)
+	}
+	return json.Marshal(snapshot)
+}
+
+// Applies a message received from a charging station. Receiving a message implies that the charging station is connected.
+func (r *StationRegistry) update(chargingStationID string, apply func(state *stationState) []ChangeType) {
+	r.mutex.Lock()
+	state, ok := r.stations[chargingStationID]
+	if !ok {
+		state = &stationState{
+			deviceModel:  provisioning.NewDeviceModel(),
+			connectors:   map[connectorKey]ConnectorStatus{},
+			transactions: map[string]*ActiveTransaction{},
+			alerts:       map[alertKey]Alert{},
+		}
+		r.stations[chargingStationID] = state
+	}
+	var changes []ChangeType
+	if !state.connected {
+		state.connected = true
+		state.disconnectedAt = nil
+		if state.evictionTimer != nil {
+			state.evictionTimer.Stop()
+			state.evictionTimer = nil
+		}
+		changes = append(changes, ChangeConnected)
+	}
+	state.lastSeen = r.clock.Now()
+	changes = append(changes, apply(state)...)
+	listeners := r.copyListeners()
+	r.mutex.Unlock()
+	for _, change := range changes {
+		notify(listeners, StationChange{ChargingStationID: chargingStationID, Type: change})
+	}
+}
+
+// Publishes an event on the attached bus, if any. Must be invoked without holding the lock.
+func (r *StationRegistry) publish(event events.Event) {
+	r.mutex.RLock()
+	bus := r.bus
+	r.mutex.RUnlock()
+	bus.Publish(event)
+}
+
+// Evicts a charging station, unless it reconnected or disconnected again in the meantime.
+func (r *StationRegistry) evict(chargingStationID string, disconnects int) {
+	r.mutex.Lock()
+	state, ok := r.stations[chargingStationID]
+	if !ok || state.connected || state.disconnects != disconnects {
+		r.mutex.Unlock()
+		return
+	}
+	delete(r.stations, chargingStationID)
+	listeners := r.copyListeners()
+	r.mutex.Unlock()
+	notify(listeners, StationChange{ChargingStationID: chargingStationID, Type: ChangeEvicted})
+}
+
+// Must be invoked while holding the lock. Listeners are returned in subscription order.
+func (r *StationRegistry) copyListeners() []StationListener {
+	ids := make([]int, 0, len(r.listeners))
+	for id := range r.listeners {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	listeners := make([]StationListener, len(ids))
+	for i, id := range ids {
+		listeners[i] = r.listeners[id]
+	}
+	return listeners
+}
+
+func notify(listeners []StationListener, change StationChange) {
+	for _, listener := range listeners {
+		listener(change)
+	}
+}
+
+func (s *stationState) snapshot(chargingStationID string) StationSnapshot {
+	snapshot := StationSnapshot{
+		ChargingStationID: chargingStationID,
+		Connected:         s.connected,
+		LastSeen:          s.lastSeen,
+		DeviceModel:       s.deviceModel.Variables(nil),
+	}
+	if s.disconnectedAt != nil {
+		disconnectedAt := *s.disconnectedAt
+		snapshot.DisconnectedAt = &disconnectedAt
+	}
+	if s.info != nil {
+		info := *s.info
+		snapshot.Info = &info
+	}
+	if s.authCache != nil {
+		authCache := *s.authCache
+		snapshot.AuthCache = &authCache
+	}
+	if s.liveness != nil {
+		liveness := *s.liveness
+		snapshot.Liveness = &liveness
+	}
+	for _, connector := range s.connectors {
+		snapshot.Connectors = append(snapshot.Connectors, connector)
+	}
+	sort.Slice(snapshot.Connectors, func(i, j int) bool {
+		a, b := snapshot.Connectors[i], snapshot.Connectors[j]
+		if a.EvseID != b.EvseID {
+			return a.EvseID < b.EvseID
+		}
+		return a.ConnectorID < b.ConnectorID
+	})
+	for _, tx := range s.transactions {
+		snapshot.Transactions = append(snapshot.Transactions, *tx)
+	}
+	sort.Slice(snapshot.Transactions, func(i, j int) bool {
+		a, b := snapshot.Transactions[i], snapshot.Transactions[j]
+		if !a.StartedAt.Equal(b.StartedAt) {
+			return a.StartedAt.Before(b.StartedAt)
+		}
+		return a.TransactionID < b.TransactionID
+	})
+	for _, alert := range s.alerts {
+		snapshot.Alerts = append(snapshot.Alerts, alert)
+	}
+	sort.Slice(snapshot.Alerts, func(i, j int) bool {
+		a, b := snapshot.Alerts[i], snapshot.Alerts[j]
+		if !a.Timestamp.Equal(b.Timestamp) {
+			return a.Timestamp.Before(b.Timestamp)
+		}
+		return a.EventID < b.EventID
+	})
+	return snapshot
+}
+
+// Returns the timestamp reported by the charging station if set, the time of reception otherwise.
+func timestampOf(timestamp *types.DateTime, received time.Time) time.Time {
+	if timestamp != nil && !timestamp.IsZero() {
+		return timestamp.Time
+	}
+	return received
+}
@@ -0,0 +1,272 @@
+package ocpp2
+
+import (
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/authorization"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/availability"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/data"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/diagnostics"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/display"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/firmware"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/iso15118"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/localauth"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/remotecontrol"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/reservation"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/security"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/smartcharging"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/tariffcost"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+func (cs *csms) CancelReservationAsync(clientId string, reservationId int, props ...func(request *reservation.CancelReservationRequest)) (*Future[*reservation.CancelReservationResponse], error) {
+	return sendAsync(clientId, reservation.CancelReservationFeatureName, func(callback func(*reservation.CancelReservationResponse, error)) error {
+		return cs.CancelReservation(clientId, callback, reservationId, props...)
+	})
+}
+
+func (cs *csms) CertificateSignedAsync(clientId string, certificateChain string, props ...func(*security.CertificateSignedRequest)) (*Future[*security.CertificateSignedResponse], error) {
+	return sendAsync(clientId, security.CertificateSignedFeatureName, func(callback func(*security.CertificateSignedResponse, error)) error {
+		return cs.CertificateSigned(clientId, callback, certificateChain, props...)
+	})
+}
+
+func (cs *csms) ChangeAvailabilityAsync(clientId string, operationalStatus availability.OperationalStatus, props ...func(request *availability.ChangeAvailabilityRequest)) (*Future[*availability.ChangeAvailabilityResponse], error) {
+	return sendAsync(clientId, availability.ChangeAvailabilityFeatureName, func(callback func(*availability.ChangeAvailabilityResponse, error)) error {
+		return cs.ChangeAvailability(clientId, callback, operationalStatus, props...)
+	})
+}
+
+func (cs *csms) ClearCacheAsync(clientId string, props ...func(*authorization.ClearCacheRequest)) (*Future[*authorization.ClearCacheResponse], error) {
+	return sendAsync(clientId, authorization.ClearCacheFeatureName, func(callback func(*authorization.ClearCacheResponse, error)) error {
+		return cs.ClearCache(clientId, callback, props...)
+	})
+}
+
+func (cs *csms) ClearChargingProfileAsync(clientId string, props ...func(request *smartcharging.ClearChargingProfileRequest)) (*Future[*smartcharging.ClearChargingProfileResponse], error) {
+	return sendAsync(clientId, smartcharging.ClearChargingProfileFeatureName, func(callback func(*smartcharging.ClearChargingProfileResponse, error)) error {
+		return cs.ClearChargingProfile(clientId, callback, props...)
+	})
+}
+
+func (cs *csms) ClearDisplayAsync(clientId string, id int, props ...func(*display.ClearDisplayRequest)) (*Future[*display.ClearDisplayResponse], error) {
+	return sendAsync(clientId, display.ClearDisplayMessageFeatureName, func(callback func(*display.ClearDisplayResponse, error)) error {
+		return cs.ClearDisplay(clientId, callback, id, props...)
+	})
+}
+
+func (cs *csms) ClearVariableMonitoringAsync(clientId string, id []int, props ...func(*diagnostics.ClearVariableMonitoringRequest)) (*Future[*diagnostics.ClearVariableMonitoringResponse], error) {
+	return sendAsync(clientId, diagnostics.ClearVariableMonitoringFeatureName, func(callback func(*diagnostics.ClearVariableMonitoringResponse, error)) error {
+		return cs.ClearVariableMonitoring(clientId, callback, id, props...)
+	})
+}
+
+func (cs *csms) CostUpdatedAsync(clientId string, totalCost float64, transactionId string, props ...func(*tariffcost.CostUpdatedRequest)) (*Future[*tariffcost.CostUpdatedResponse], error) {
+	return sendAsync(clientId, tariffcost.CostUpdatedFeatureName, func(callback func(*tariffcost.CostUpdatedResponse, error)) error {
+		return cs.CostUpdated(clientId, callback, totalCost, transactionId, props...)
+	})
+}
+
+func (cs *csms) CustomerInformationAsync(clientId string, requestId int, report bool, clear bool, props ...func(*diagnostics.CustomerInformationRequest)) (*Future[*diagnostics.CustomerInformationResponse], error) {
+	return sendAsync(clientId, diagnostics.CustomerInformationFeatureName, func(callback func(*diagnostics.CustomerInformationResponse, error)) error {
+		return cs.CustomerInformation(clientId, callback, requestId, report, clear, props...)
+	})
+}
+
+func (cs *csms) DataTransferAsync(clientId string, vendorId string, props ...func(request *data.DataTransferRequest)) (*Future[*data.DataTransferResponse], error) {
+	return sendAsync(clientId, data.DataTransferFeatureName, func(callback func(*data.DataTransferResponse, error)) error {
+		return cs.DataTransfer(clientId, callback, vendorId, props...)
+	})
+}
+
+func (cs *csms) DeleteCertificateAsync(clientId string, data types.CertificateHashData, props ...func(*iso15118.DeleteCertificateRequest)) (*Future[*iso15118.DeleteCertificateResponse], error) {
+	return sendAsync(clientId, iso15118.DeleteCertificateFeatureName, func(callback func(*iso15118.DeleteCertificateResponse, error)) error {
+		return cs.DeleteCertificate(clientId, callback, data, props...)
+	})
+}
+
+func (cs *csms) GetBaseReportAsync(clientId string, requestId int, reportBase provisioning.ReportBaseType, props ...func(*provisioning.GetBaseReportRequest)) (*Future[*provisioning.GetBaseReportResponse], error) {
+	return sendAsync(clientId, provisioning.GetBaseReportFeatureName, func(callback func(*provisioning.GetBaseReportResponse, error)) error {
+		return cs.GetBaseReport(clientId, callback, requestId, reportBase, props...)
+	})
+}
+
+func (cs *csms) GetChargingProfilesAsync(clientId string, chargingProfile smartcharging.ChargingProfileCriterion, props ...func(*smartcharging.GetChargingProfilesRequest)) (*Future[*smartcharging.GetChargingProfilesResponse], error) {
+	return sendAsync(clientId, smartcharging.GetChargingProfilesFeatureName, func(callback func(*smartcharging.GetChargingProfilesResponse, error)) error {
+		return cs.GetChargingProfiles(clientId, callback, chargingProfile, props...)
+	})
+}
+
+func (cs *csms) GetCompositeScheduleAsync(clientId string, duration int, evseId int, props ...func(*smartcharging.GetCompositeScheduleRequest)) (*Future[*smartcharging.GetCompositeScheduleResponse], error) {
+	return sendAsync(clientId, smartcharging.GetCompositeScheduleFeatureName, func(callback func(*smartcharging.GetCompositeScheduleResponse, error)) error {
+		return cs.GetCompositeSchedule(clientId, callback, duration, evseId, props...)
+	})
+}
+
+func (cs *csms) GetDisplayMessagesAsync(clientId string, requestId int, props ...func(*display.GetDisplayMessagesRequest)) (*Future[*display.GetDisplayMessagesResponse], error) {
+	return sendAsync(clientId, display.GetDisplayMessagesFeatureName, func(callback func(*display.GetDisplayMessagesResponse, error)) error {
+		return cs.GetDisplayMessages(clientId, callback, requestId, props...)
+	})
+}
+
+func (cs *csms) GetInstalledCertificateIdsAsync(clientId string, props ...func(*iso15118.GetInstalledCertificateIdsRequest)) (*Future[*iso15118.GetInstalledCertificateIdsResponse], error) {
+	return sendAsync(clientId, iso15118.GetInstalledCertificateIdsFeatureName, func(callback func(*iso15118.GetInstalledCertificateIdsResponse, error)) error {
+		return cs.GetInstalledCertificateIds(clientId, callback, props...)
+	})
+}
+
+func (cs *csms) GetLocalListVersionAsync(clientId string, props ...func(*localauth.GetLocalListVersionRequest)) (*Future[*localauth.GetLocalListVersionResponse], error) {
+	return sendAsync(clientId, localauth.GetLocalListVersionFeatureName, func(callback func(*localauth.GetLocalListVersionResponse, error)) error {
+		return cs.GetLocalListVersion(clientId, callback, props...)
+	})
+}
+
+func (cs *csms) GetLogAsync(clientId string, logType diagnostics.LogType, requestID int, logParameters diagnostics.LogParameters, props ...func(*diagnostics.GetLogRequest)) (*Future[*diagnostics.GetLogResponse], error) {
+	return sendAsync(clientId, diagnostics.GetLogFeatureName, func(callback func(*diagnostics.GetLogResponse, error)) error {
+		return cs.GetLog(clientId, callback, logType, requestID, logParameters, props...)
+	})
+}
+
+func (cs *csms) GetMonitoringReportAsync(clientId string, props ...func(*diagnostics.GetMonitoringReportRequest)) (*Future[*diagnostics.GetMonitoringReportResponse], error) {
+	return sendAsync(clientId, diagnostics.GetMonitoringReportFeatureName, func(callback func(*diagnostics.GetMonitoringReportResponse, error)) error {
+		return cs.GetMonitoringReport(clientId, callback, props...)
+	})
+}
+
+func (cs *csms) GetReportAsync(clientId string, props ...func(*provisioning.GetReportRequest)) (*Future[*provisioning.GetReportResponse], error) {
+	return sendAsync(clientId, provisioning.GetReportFeatureName, func(callback func(*provisioning.GetReportResponse, error)) error {
+		return cs.GetReport(clientId, callback, props...)
+	})
+}
+
+func (cs *csms) GetTransactionStatusAsync(clientId string, props ...func(*transactions.GetTransactionStatusRequest)) (*Future[*transactions.GetTransactionStatusResponse], error) {
+	return sendAsync(clientId, transactions.GetTransactionStatusFeatureName, func(callback func(*transactions.GetTransactionStatusResponse, error)) error {
+		return cs.GetTransactionStatus(clientId, callback, props...)
+	})
+}
+
+func (cs *csms) GetVariablesAsync(clientId string, variableData []provisioning.GetVariableData, props ...func(*provisioning.GetVariablesRequest)) (*Future[*provisioning.GetVariablesResponse], error) {
+	return sendAsync(clientId, provisioning.GetVariablesFeatureName, func(callback func(*provisioning.GetVariablesResponse, error)) error {
+		return cs.GetVariables(clientId, callback, variableData, props...)
+	})
+}
+
+func (cs *csms) InstallCertificateAsync(clientId string, certificateType types.CertificateUse, certificate string, props ...func(*iso15118.InstallCertificateRequest)) (*Future[*iso15118.InstallCertificateResponse], error) {
+	return sendAsync(clientId, iso15118.InstallCertificateFeatureName, func(callback func(*iso15118.InstallCertificateResponse, error)) error {
+		return cs.InstallCertificate(clientId, callback, certificateType, certificate, props...)
+	})
+}
+
+func (cs *csms) PublishFirmwareAsync(clientId string, location string, checksum string, requestID int, props ...func(request *firmware.PublishFirmwareRequest)) (*Future[*firmware.PublishFirmwareResponse], error) {
+	return sendAsync(clientId, firmware.PublishFirmwareFeatureName, func(callback func(*firmware.PublishFirmwareResponse, error)) error {
+		return cs.PublishFirmware(clientId, callback, location, checksum, requestID, props...)
+	})
+}
+
+func (cs *csms) RequestStartTransactionAsync(clientId string, remoteStartID int, IdToken types.IdToken, props ...func(request *remotecontrol.RequestStartTransactionRequest)) (*Future[*remotecontrol.RequestStartTransactionResponse], error) {
+	return sendAsync(clientId, remotecontrol.RequestStartTransactionFeatureName, func(callback func(*remotecontrol.RequestStartTransactionResponse, error)) error {
+		return cs.RequestStartTransaction(clientId, callback, remoteStartID, IdToken, props...)
+	})
+}
+
+func (cs *csms) RequestStopTransactionAsync(clientId string, transactionID string, props ...func(request *remotecontrol.RequestStopTransactionRequest)) (*Future[*remotecontrol.RequestStopTransactionResponse], error) {
+	return sendAsync(clientId, remotecontrol.RequestStopTransactionFeatureName, func(callback func(*remotecontrol.RequestStopTransactionResponse, error)) error {
+		return cs.RequestStopTransaction(clientId, callback, transactionID, props...)
+	})
+}
+
+func (cs *csms) ReserveNowAsync(clientId string, id int, expiryDateTime *types.DateTime, idToken types.IdToken, props ...func(request *reservation.ReserveNowRequest)) (*Future[*reservation.ReserveNowResponse], error) {
+	return sendAsync(clientId, reservation.ReserveNowFeatureName, func(callback func(*reservation.ReserveNowResponse, error)) error {
+		return cs.ReserveNow(clientId, callback, id, expiryDateTime, idToken, props...)
+	})
+}
+
+func (cs *csms) ResetAsync(clientId string, t provisioning.ResetType, props ...func(request *provisioning.ResetRequest)) (*Future[*provisioning.ResetResponse], error) {
+	return sendAsync(clientId, provisioning.ResetFeatureName, func(callback func(*provisioning.ResetResponse, error)) error {
+		return cs.Reset(clientId, callback, t, props...)
+	})
+}
+
+func (cs *csms) SendLocalListAsync(clientId string, version int, updateType localauth.UpdateType, props ...func(request *localauth.SendLocalListRequest)) (*Future[*localauth.SendLocalListResponse], error) {
+	return sendAsync(clientId, localauth.SendLocalListFeatureName, func(callback func(*localauth.SendLocalListResponse, error)) error {
+		return cs.SendLocalList(clientId, callback, version, updateType, props...)
+	})
+}
+
+func (cs *csms) SetChargingProfileAsync(clientId string, evseID int, chargingProfile *types.ChargingProfile, props ...func(request *smartcharging.SetChargingProfileRequest)) (*Future[*smartcharging.SetChargingProfileResponse], error) {
+	return sendAsync(clientId, smartcharging.SetChargingProfileFeatureName, func(callback func(*smartcharging.SetChargingProfileResponse, error)) error {
+		return cs.SetChargingProfile(clientId, callback, evseID, chargingProfile, props...)
+	})
+}
+
+func (cs *csms) SetDisplayMessageAsync(clientId string, message display.MessageInfo, props ...func(request *display.SetDisplayMessageRequest)) (*Future[*display.SetDisplayMessageResponse], error) {
+	return sendAsync(clientId, display.SetDisplayMessageFeatureName, func(callback func(*display.SetDisplayMessageResponse, error)) error {
+		return cs.SetDisplayMessage(clientId, callback, message, props...)
+	})
+}
+
+func (cs *csms) SetMonitoringBaseAsync(clientId string, monitoringBase diagnostics.MonitoringBase, props ...func(request *diagnostics.SetMonitoringBaseRequest)) (*Future[*diagnostics.SetMonitoringBaseResponse], error) {
+	return sendAsync(clientId, diagnostics.SetMonitoringBaseFeatureName, func(callback func(*diagnostics.SetMonitoringBaseResponse, error)) error {
+		return cs.SetMonitoringBase(clientId, callback, monitoringBase, props...)
+	})
+}
+
+func (cs *csms) SetMonitoringLevelAsync(clientId string, severity int, props ...func(request *diagnostics.SetMonitoringLevelRequest)) (*Future[*diagnostics.SetMonitoringLevelResponse], error) {
+	return sendAsync(clientId, diagnostics.SetMonitoringLevelFeatureName, func(callback func(*diagnostics.SetMonitoringLevelResponse, error)) error {
+		return cs.SetMonitoringLevel(clientId, callback, severity, props...)
+	})
+}
+
+func (cs *csms) SetNetworkProfileAsync(clientId string, configurationSlot int, connectionData provisioning.NetworkConnectionProfile, props ...func(request *provisioning.SetNetworkProfileRequest)) (*Future[*provisioning.SetNetworkProfileResponse], error) {
+	return sendAsync(clientId, provisioning.SetNetworkProfileFeatureName, func(callback func(*provisioning.SetNetworkProfileResponse, error)) error {
+		return cs.SetNetworkProfile(clientId, callback, configurationSlot, connectionData, props...)
+	})
+}
+
+func (cs *csms) SetVariableMonitoringAsync(clientId string, data []diagnostics.SetMonitoringData, props ...func(request *diagnostics.SetVariableMonitoringRequest)) (*Future[*diagnostics.SetVariableMonitoringResponse], error) {
+	return sendAsync(clientId, diagnostics.SetVariableMonitoringFeatureName, func(callback func(*diagnostics.SetVariableMonitoringResponse, error)) error {
+		return cs.SetVariableMonitoring(clientId, callback, data, props...)
+	})
+}
+
+func (cs *csms) SetVariablesAsync(clientId string, data []provisioning.SetVariableData, props ...func(request *provisioning.SetVariablesRequest)) (*Future[*provisioning.SetVariablesResponse], error) {
+	return sendAsync(clientId, provisioning.SetVariablesFeatureName, func(callback func(*provisioning.SetVariablesResponse, error)) error {
+		return cs.SetVariables(clientId, callback, data, props...)
+	})
+}
+
+func (cs *csms) RotateBasicAuthPasswordAsync(clientId string, newPassword string, props ...func(request *provisioning.SetVariablesRequest)) (*Future[*provisioning.SetVariablesResponse], error) {
+	return sendAsync(clientId, provisioning.SetVariablesFeatureName, func(callback func(*provisioning.SetVariablesResponse, error)) error {
+		return cs.RotateBasicAuthPassword(clientId, callback, newPassword, props...)
+	})
+}
+
+func (cs *csms) TriggerMessageAsync(clientId string, requestedMessage remotecontrol.MessageTrigger, props ...func(request *remotecontrol.TriggerMessageRequest)) (*Future[*remotecontrol.TriggerMessageResponse], error) {
+	return sendAsync(clientId, remotecontrol.TriggerMessageFeatureName, func(callback func(*remotecontrol.TriggerMessageResponse, error)) error {
+		return cs.TriggerMessage(clientId, callback, requestedMessage, props...)
+	})
+}
+
+func (cs *csms) UnlockConnectorAsync(clientId string, evseID int, connectorID int, props ...func(request *remotecontrol.UnlockConnectorRequest)) (*Future[*remotecontrol.UnlockConnectorResponse], error) {
+	return sendAsync(clientId, remotecontrol.UnlockConnectorFeatureName, func(callback func(*remotecontrol.UnlockConnectorResponse, error)) error {
+		return cs.UnlockConnector(clientId, callback, evseID, connectorID, props...)
+	})
+}
+
+func (cs *csms) UnpublishFirmwareAsync(clientId string, checksum string, props ...func(request *firmware.UnpublishFirmwareRequest)) (*Future[*firmware.UnpublishFirmwareResponse], error) {
+	return sendAsync(clientId, firmware.UnpublishFirmwareFeatureName, func(callback func(*firmware.UnpublishFirmwareResponse, error)) error {
+		return cs.UnpublishFirmware(clientId, callback, checksum, props...)
+	})
+}
+
+func (cs *csms) UpdateFirmwareAsync(clientId string, requestID int, f firmware.Firmware, props ...func(request *firmware.UpdateFirmwareRequest)) (*Future[*firmware.UpdateFirmwareResponse], error) {
+	return sendAsync(clientId, firmware.UpdateFirmwareFeatureName, func(callback func(*firmware.UpdateFirmwareResponse, error)) error {
+		return cs.UpdateFirmware(clientId, callback, requestID, f, props...)
+	})
+}
+
+func (cs *csms) UsePriorityChargingAsync(clientId string, transactionID string, activate bool, props ...func(request *remotecontrol.UsePriorityChargingRequest)) (*Future[*remotecontrol.UsePriorityChargingResponse], error) {
+	return sendAsync(clientId, remotecontrol.UsePriorityChargingFeatureName, func(callback func(*remotecontrol.UsePriorityChargingResponse, error)) error {
+		return cs.UsePriorityCharging(clientId, callback, transactionID, activate, props...)
+	})
+}
@@ -1,7 +1,11 @@
 // The data transfer functional block enables parties to add custom commands and extensions to OCPP 2.0.
 package data
 
-import "github.com/lorenzodonini/ocpp-go/ocpp"
+import (
+	"context"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+)
 
 // Needs to be implemented by a CSMS for handling messages part of the OCPP 2.0 Data transfer profile.
 type CSMSHandler interface {
@@ -9,6 +13,29 @@ type CSMSHandler interface {
 	OnDataTransfer(chargingStationID string, request *DataTransferRequest) (confirmation *DataTransferResponse, err error)
 }
 
+// Context-aware variant of CSMSHandler, for handling messages part of the OCPP 2.0 Data transfer profile.
+type CSMSHandlerWithContext interface {
+	// OnDataTransfer is called on the CSMS whenever a DataTransferRequest is received from a charging station.
+	OnDataTransfer(ctx context.Context, chargingStationID string, request *DataTransferRequest) (confirmation *DataTransferResponse, err error)
+}
+
+// AdaptCSMSHandler converts a CSMSHandler into a CSMSHandlerWithContext, which ignores the context.
+// Returns nil if handler is nil.
+func AdaptCSMSHandler(handler CSMSHandler) CSMSHandlerWithContext {
+	if handler == nil {
+		return nil
+	}
+	return csmsHandlerAdapter{handler: handler}
+}
+
+type csmsHandlerAdapter struct {
+	handler CSMSHandler
+}
+
+func (a csmsHandlerAdapter) OnDataTransfer(ctx context.Context, chargingStationID string, request *DataTransferRequest) (*DataTransferResponse, error) {
+	return a.handler.OnDataTransfer(chargingStationID, request)
+}
+
 // Needs to be implemented by Charging stations for handling messages part of the OCPP 2.0 Data transfer profile.
 type ChargingStationHandler interface {
 	// OnDataTransfer is called on a charging station whenever a DataTransferRequest is received from the CSMS.
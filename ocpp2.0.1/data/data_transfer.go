@@ -34,9 +34,10 @@ func isValidDataTransferStatus(fl validator.FieldLevel) bool {
 
 // The field definition of the DataTransfer request payload sent by an endpoint to ther other endpoint.
 type DataTransferRequest struct {
-	MessageID string      `json:"messageId,omitempty" validate:"max=50"`
-	Data      interface{} `json:"data,omitempty"`
-	VendorID  string      `json:"vendorId" validate:"required,max=255"`
+	MessageID  string            `json:"messageId,omitempty" validate:"max=50"`
+	Data       interface{}       `json:"data,omitempty"`
+	VendorID   string            `json:"vendorId" validate:"required,max=255"`
+	CustomData *types.CustomData `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // This field definition of the DataTransfer response payload, sent by an endpoint in response to a DataTransferRequest, coming from the other endpoint.
@@ -45,6 +46,7 @@ type DataTransferResponse struct {
 	Status     DataTransferStatus `json:"status" validate:"required,dataTransferStatus201"`
 	Data       interface{}        `json:"data,omitempty"`
 	StatusInfo *types.StatusInfo  `json:"statusInfo,omitempty" validate:"omitempty"`
+	CustomData *types.CustomData  `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // If a CS needs to send information to the CSMS for a function not supported by OCPP, it SHALL use a DataTransfer message.
@@ -82,5 +84,5 @@ func NewDataTransferResponse(status DataTransferStatus) *DataTransferResponse {
 }
 
 func init() {
-	_ = types.Validate.RegisterValidation("dataTransferStatus201", isValidDataTransferStatus)
+	_ = types.RegisterValidation("dataTransferStatus201", isValidDataTransferStatus)
 }
@@ -0,0 +1,92 @@
+package ocpp2
+
+import (
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/availability"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/diagnostics"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/firmware"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/security"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// DefaultHandler identifies a request, which the CSMS may answer on its own. Values may be combined.
+// Refer to CSMS.UseDefaultHandlers.
+type DefaultHandler int
+
+const (
+	DefaultHeartbeat                  DefaultHandler = 1 << iota // Heartbeat, answered with the current time.
+	DefaultStatusNotification                                    // StatusNotification, answered with an empty response.
+	DefaultNotifyReport                                          // NotifyReport, answered with an empty response.
+	DefaultNotifyEvent                                           // NotifyEvent, answered with an empty response.
+	DefaultNotifyMonitoringReport                                // NotifyMonitoringReport, answered with an empty response.
+	DefaultLogStatusNotification                                 // LogStatusNotification, answered with an empty response.
+	DefaultSecurityEventNotification                             // SecurityEventNotification, answered with an empty response.
+	DefaultFirmwareStatusNotification                            // FirmwareStatusNotification, answered with an empty response.
+)
+
+// RequestObserver receives the requests answered by default handlers. It is implemented by csms.StationRegistry.
+type RequestObserver interface {
+	ObserveRequest(chargingStationID string, request ocpp.Request)
+}
+
+var defaultHandlerActions = map[string]DefaultHandler{
+	availability.HeartbeatFeatureName:              DefaultHeartbeat,
+	availability.StatusNotificationFeatureName:     DefaultStatusNotification,
+	provisioning.NotifyReportFeatureName:           DefaultNotifyReport,
+	diagnostics.NotifyEventFeatureName:             DefaultNotifyEvent,
+	diagnostics.NotifyMonitoringReportFeatureName:  DefaultNotifyMonitoringReport,
+	diagnostics.LogStatusNotificationFeatureName:   DefaultLogStatusNotification,
+	security.SecurityEventNotificationFeatureName:  DefaultSecurityEventNotification,
+	firmware.FirmwareStatusNotificationFeatureName: DefaultFirmwareStatusNotification,
+}
+
+func (cs *csms) UseDefaultHandlers(handlers DefaultHandler) {
+	cs.defaultHandlers = handlers
+}
+
+func (cs *csms) SetDefaultHandlerObserver(observer RequestObserver) {
+	cs.defaultObserver = observer
+}
+
+// Returns the response of a default handler, if one is enabled for the action and no handler was set for its profile.
+func (cs *csms) defaultResponse(action string) (ocpp.Response, bool) {
+	if cs.defaultHandlers&defaultHandlerActions[action] == 0 {
+		return nil, false
+	}
+	switch action {
+	case availability.HeartbeatFeatureName:
+		if cs.availabilityHandler == nil {
+			return availability.NewHeartbeatResponse(*types.NewDateTime(cs.server.Clock().Now())), true
+		}
+	case availability.StatusNotificationFeatureName:
+		if cs.availabilityHandler == nil {
+			return availability.NewStatusNotificationResponse(), true
+		}
+	case provisioning.NotifyReportFeatureName:
+		if cs.provisioningHandler == nil {
+			return provisioning.NewNotifyReportResponse(), true
+		}
+	case diagnostics.NotifyEventFeatureName:
+		if cs.diagnosticsHandler == nil {
+			return diagnostics.NewNotifyEventResponse(), true
+		}
+	case diagnostics.NotifyMonitoringReportFeatureName:
+		if cs.diagnosticsHandler == nil {
+			return diagnostics.NewNotifyMonitoringReportResponse(), true
+		}
+	case diagnostics.LogStatusNotificationFeatureName:
+		if cs.diagnosticsHandler == nil {
+			return diagnostics.NewLogStatusNotificationResponse(), true
+		}
+	case security.SecurityEventNotificationFeatureName:
+		if cs.securityHandler == nil {
+			return security.NewSecurityEventNotificationResponse(), true
+		}
+	case firmware.FirmwareStatusNotificationFeatureName:
+		if cs.firmwareHandler == nil {
+			return firmware.NewFirmwareStatusNotificationResponse(), true
+		}
+	}
+	return nil, false
+}
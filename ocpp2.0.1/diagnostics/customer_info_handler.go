@@ -0,0 +1,157 @@
+package diagnostics
+
+import (
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// The maximum length of the data within a single NotifyCustomerInformationRequest.
+const maxCustomerInformationDataLength = 512
+
+// The data reported if no provider holds any data about the customer.
+const noCustomerDataFound = "No data found for the customer"
+
+// CustomerInfoCriteria identifies the customer targeted by a CustomerInformationRequest.
+// At least one of the fields is set.
+type CustomerInfoCriteria struct {
+	CustomerIdentifier  string
+	IdToken             *types.IdToken
+	CustomerCertificate *types.CertificateHashData
+}
+
+// CustomerDataProvider gives access to customer data held by a part of the charging station,
+// e.g. the local authorization list, the authorization cache or the transaction history.
+type CustomerDataProvider interface {
+	// Report returns human-readable lines describing the data held about the customer. Returns nothing if no data is held.
+	Report(criteria CustomerInfoCriteria) []string
+	// Clear removes all data held about the customer.
+	Clear(criteria CustomerInfoCriteria) error
+}
+
+// CustomerInfoHandler implements the OnCustomerInformation handler method of the ChargingStationHandler interface,
+// on top of the registered CustomerDataProvider implementations.
+//
+// The handler is meant to be embedded into a charging station handler, which implements the remaining methods:
+//
+//	type DiagnosticsHandler struct {
+//		*diagnostics.CustomerInfoHandler
+//	}
+//
+// If requested, data is collected from all providers before it is cleared. Requests without any customer identifier
+// are answered with Invalid, requests which couldn't be processed with Rejected.
+// The collected data is sent asynchronously via the SendNotification function, after the response was returned,
+// split into NotifyCustomerInformationRequest messages of at most 512 characters each.
+// Typically, SendNotification invokes the NotifyCustomerInformation function of the charging station.
+//
+// A CustomerInfoHandler is safe for concurrent use.
+type CustomerInfoHandler struct {
+	// Invoked for each part of a report. If an error is returned, the remaining parts are not sent.
+	SendNotification func(request *NotifyCustomerInformationRequest) error
+	// Invoked if a part of a report couldn't be sent. Optional.
+	OnNotificationError func(requestID int, err error)
+	providers           []CustomerDataProvider
+	now                 func() time.Time
+	mutex               sync.Mutex
+}
+
+// NewCustomerInfoHandler creates a new handler without any providers.
+func NewCustomerInfoHandler(sendNotification func(request *NotifyCustomerInformationRequest) error) *CustomerInfoHandler {
+	return &CustomerInfoHandler{SendNotification: sendNotification, now: time.Now}
+}
+
+// AddProvider registers a provider. Providers are queried in the order in which they were added.
+func (h *CustomerInfoHandler) AddProvider(provider CustomerDataProvider) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.providers = append(h.providers, provider)
+}
+
+// SetTimeSource replaces the function used for retrieving the current time. Intended for testing.
+func (h *CustomerInfoHandler) SetTimeSource(now func() time.Time) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.now = now
+}
+
+func (h *CustomerInfoHandler) OnCustomerInformation(request *CustomerInformationRequest) (*CustomerInformationResponse, error) {
+	if request.CustomerIdentifier == "" && request.IdToken == nil && request.CustomerCertificate == nil {
+		response := NewCustomerInformationResponse(CustomerInformationStatusInvalid)
+		response.StatusInfo = types.NewStatusInfo("MissingParam", "no customer identifier, idToken or certificate")
+		return response, nil
+	}
+	if !request.Report && !request.Clear {
+		response := NewCustomerInformationResponse(CustomerInformationStatusInvalid)
+		response.StatusInfo = types.NewStatusInfo("MissingParam", "neither report nor clear requested")
+		return response, nil
+	}
+	if request.Report && h.SendNotification == nil {
+		return NewCustomerInformationResponse(CustomerInformationStatusRejected), nil
+	}
+	criteria := CustomerInfoCriteria{
+		CustomerIdentifier:  request.CustomerIdentifier,
+		IdToken:             request.IdToken,
+		CustomerCertificate: request.CustomerCertificate,
+	}
+	h.mutex.Lock()
+	providers := append([]CustomerDataProvider{}, h.providers...)
+	generatedAt := types.NewDateTime(h.now())
+	h.mutex.Unlock()
+	var lines []string
+	if request.Report {
+		for _, provider := range providers {
+			lines = append(lines, provider.Report(criteria)...)
+		}
+	}
+	if request.Clear {
+		for _, provider := range providers {
+			if err := provider.Clear(criteria); err != nil {
+				info := err.Error()
+				if len(info) > maxCustomerInformationDataLength {
+					info = info[:maxCustomerInformationDataLength]
+				}
+				response := NewCustomerInformationResponse(CustomerInformationStatusRejected)
+				response.StatusInfo = types.NewStatusInfo("InternalError", info)
+				return response, nil
+			}
+		}
+	}
+	if request.Report {
+		notifications := splitCustomerInformation(request.RequestID, strings.Join(lines, "\n"), *generatedAt)
+		go func() {
+			for _, notification := range notifications {
+				if err := h.SendNotification(notification); err != nil {
+					if h.OnNotificationError != nil {
+						h.OnNotificationError(request.RequestID, err)
+					}
+					return
+				}
+			}
+		}()
+	}
+	return NewCustomerInformationResponse(CustomerInformationStatusAccepted), nil
+}
+
+// Splits the data into notifications of at most 512 characters, never splitting a character.
+func splitCustomerInformation(requestID int, data string, generatedAt types.DateTime) []*NotifyCustomerInformationRequest {
+	if data == "" {
+		data = noCustomerDataFound
+	}
+	var notifications []*NotifyCustomerInformationRequest
+	for len(data) > 0 {
+		end, count := 0, 0
+		for end < len(data) && count < maxCustomerInformationDataLength {
+			_, size := utf8.DecodeRuneInString(data[end:])
+			end += size
+			count++
+		}
+		notification := NewNotifyCustomerInformationRequest(data[:end], len(notifications), generatedAt, requestID)
+		data = data[end:]
+		notification.Tbc = len(data) > 0
+		notifications = append(notifications, notification)
+	}
+	return notifications
+}
@@ -1,7 +1,11 @@
 // The diagnostics functional block contains OCPP 2.0 features than enable remote diagnostics of problems with a charging station.
 package diagnostics
 
-import "github.com/lorenzodonini/ocpp-go/ocpp"
+import (
+	"context"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+)
 
 // Needs to be implemented by a CSMS for handling messages part of the OCPP 2.0 Diagnostics profile.
 type CSMSHandler interface {
@@ -15,6 +19,47 @@ type CSMSHandler interface {
 	OnNotifyMonitoringReport(chargingStationID string, request *NotifyMonitoringReportRequest) (response *NotifyMonitoringReportResponse, err error)
 }
 
+// Context-aware variant of CSMSHandler, for handling messages part of the OCPP 2.0 Diagnostics profile.
+type CSMSHandlerWithContext interface {
+	// OnLogStatusNotification is called on the CSMS whenever a LogStatusNotificationRequest is received from a Charging Station.
+	OnLogStatusNotification(ctx context.Context, chargingStationID string, request *LogStatusNotificationRequest) (response *LogStatusNotificationResponse, err error)
+	// OnNotifyCustomerInformation is called on the CSMS whenever a NotifyCustomerInformationRequest is received from a Charging Station.
+	OnNotifyCustomerInformation(ctx context.Context, chargingStationID string, request *NotifyCustomerInformationRequest) (response *NotifyCustomerInformationResponse, err error)
+	// OnNotifyEvent is called on the CSMS whenever a NotifyEventRequest is received from a Charging Station.
+	OnNotifyEvent(ctx context.Context, chargingStationID string, request *NotifyEventRequest) (response *NotifyEventResponse, err error)
+	// OnNotifyMonitoringReport is called on the CSMS whenever a NotifyMonitoringReportRequest is received from a Charging Station.
+	OnNotifyMonitoringReport(ctx context.Context, chargingStationID string, request *NotifyMonitoringReportRequest) (response *NotifyMonitoringReportResponse, err error)
+}
+
+// AdaptCSMSHandler converts a CSMSHandler into a CSMSHandlerWithContext, which ignores the context.
+// Returns nil if handler is nil.
+func AdaptCSMSHandler(handler CSMSHandler) CSMSHandlerWithContext {
+	if handler == nil {
+		return nil
+	}
+	return csmsHandlerAdapter{handler: handler}
+}
+
+type csmsHandlerAdapter struct {
+	handler CSMSHandler
+}
+
+func (a csmsHandlerAdapter) OnLogStatusNotification(ctx context.Context, chargingStationID string, request *LogStatusNotificationRequest) (*LogStatusNotificationResponse, error) {
+	return a.handler.OnLogStatusNotification(chargingStationID, request)
+}
+
+func (a csmsHandlerAdapter) OnNotifyCustomerInformation(ctx context.Context, chargingStationID string, request *NotifyCustomerInformationRequest) (*NotifyCustomerInformationResponse, error) {
+	return a.handler.OnNotifyCustomerInformation(chargingStationID, request)
+}
+
+func (a csmsHandlerAdapter) OnNotifyEvent(ctx context.Context, chargingStationID string, request *NotifyEventRequest) (*NotifyEventResponse, error) {
+	return a.handler.OnNotifyEvent(chargingStationID, request)
+}
+
+func (a csmsHandlerAdapter) OnNotifyMonitoringReport(ctx context.Context, chargingStationID string, request *NotifyMonitoringReportRequest) (*NotifyMonitoringReportResponse, error) {
+	return a.handler.OnNotifyMonitoringReport(chargingStationID, request)
+}
+
 // Needs to be implemented by Charging stations for handling messages part of the OCPP 2.0 Diagnostics profile.
 type ChargingStationHandler interface {
 	// OnClearVariableMonitoring is called on a charging station whenever a ClearVariableMonitoringRequest is received from the CSMS.
@@ -0,0 +1,290 @@
+package diagnostics
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// The default amount of events retained by an EventBuffer while the charging station is offline.
+const DefaultMaxBufferedEvents = 100
+
+// ErrEventsBuffered is returned by EventBuffer.Add, if the events couldn't be sent right away
+// and were buffered instead. The events will be delivered once the charging station is back online.
+var ErrEventsBuffered = errors.New("events buffered for later delivery")
+
+// Fixed-size FIFO, overwriting the oldest events once full.
+type eventRing struct {
+	events []EventData
+	start  int
+	count  int
+}
+
+func (r *eventRing) push(event EventData) (dropped bool) {
+	if r.count == len(r.events) {
+		r.events[r.start] = event
+		r.start = (r.start + 1) % len(r.events)
+		return true
+	}
+	r.events[(r.start+r.count)%len(r.events)] = event
+	r.count++
+	return false
+}
+
+func (r *eventRing) peek(n int) []EventData {
+	if n <= 0 || n > r.count {
+		n = r.count
+	}
+	events := make([]EventData, n)
+	for i := range events {
+		events[i] = r.events[(r.start+i)%len(r.events)]
+	}
+	return events
+}
+
+// Removes all events up to the passed eventId. Events dropped in the meantime are skipped.
+func (r *eventRing) popUntil(eventID int) {
+	for r.count > 0 && r.events[r.start].EventID <= eventID {
+		r.events[r.start] = EventData{}
+		r.start = (r.start + 1) % len(r.events)
+		r.count--
+	}
+}
+
+func (r *eventRing) resize(capacity int) {
+	events := r.peek(0)
+	if len(events) > capacity {
+		events = events[len(events)-capacity:]
+	}
+	r.events = make([]EventData, capacity)
+	r.start = 0
+	r.count = copy(r.events, events)
+}
+
+// EventBuffer implements the reporting rules for NotifyEvent messages on the charging station side.
+//
+// All events should be passed to Add, together with their severity. Events with a severity above the monitoring level
+// are suppressed. Every accepted event is assigned a monotonically increasing eventId. Events are then batched into
+// NotifyEventRequest messages of at most ItemsPerMessage events each: a sequence of messages starts with seqNo 0,
+// and all messages except the last one have the tbc flag set.
+//
+// While the charging station is offline, only the newest events are retained, up to the buffer capacity.
+// Once back online, retained events are delivered in order, before any newer event.
+//
+// The buffer doesn't detect connectivity by itself, apart from failed sends. The application should invoke
+// OnDisconnected and OnReconnected whenever the connection to the CSMS is lost or restored.
+//
+// An EventBuffer is safe for concurrent use.
+type EventBuffer struct {
+	// Sends a request to the CSMS. Typically invokes NotifyEvent on the charging station.
+	// Errors of type *ocpp.Error are treated as a response by the CSMS, any other error as a connectivity issue.
+	Send func(request *NotifyEventRequest) error
+	// Invoked whenever retained events were overwritten by newer ones, with the amount of overwritten events. Optional.
+	OnEventsDropped func(dropped int)
+	// The maximum amount of events per NotifyEventRequest. Zero means no limit.
+	ItemsPerMessage int
+	ring            eventRing
+	level           int
+	nextEventID     int
+	dropped         int
+	online          bool
+	flushing        bool
+	now             func() time.Time
+	mutex           sync.Mutex
+}
+
+// NewEventBuffer creates a new buffer, retaining at most capacity events while offline.
+// If capacity is zero, DefaultMaxBufferedEvents is used. The buffer initially considers the charging station online.
+func NewEventBuffer(capacity int, send func(request *NotifyEventRequest) error) *EventBuffer {
+	if capacity <= 0 {
+		capacity = DefaultMaxBufferedEvents
+	}
+	return &EventBuffer{
+		Send:   send,
+		ring:   eventRing{events: make([]EventData, capacity)},
+		level:  DefaultMonitoringLevel,
+		online: true,
+		now:    time.Now,
+	}
+}
+
+// SetTimeSource replaces the function used for retrieving the current time. Intended for testing.
+func (b *EventBuffer) SetTimeSource(now func() time.Time) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.now = now
+}
+
+// SetLevel sets the monitoring level. Events with a severity above the level are suppressed.
+// The level is typically changed by the CSMS via SetMonitoringLevel, e.g.:
+//
+//	monitoringStore.OnLevelChanged = eventBuffer.SetLevel
+func (b *EventBuffer) SetLevel(severity int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.level = severity
+}
+
+// SetCapacity changes the maximum amount of retained events. If more events are currently retained, the oldest ones are dropped.
+func (b *EventBuffer) SetCapacity(capacity int) {
+	if capacity <= 0 {
+		capacity = DefaultMaxBufferedEvents
+	}
+	b.mutex.Lock()
+	dropped := b.ring.count - capacity
+	b.ring.resize(capacity)
+	if dropped > 0 {
+		b.dropped += dropped
+	}
+	b.mutex.Unlock()
+	b.eventsDropped(dropped)
+}
+
+// Add reports events of the given severity. The eventId of each event is overwritten, and a missing timestamp is set to the current time.
+//
+// If the events couldn't be delivered because the charging station is offline, ErrEventsBuffered is returned.
+// If the CSMS responded with an error, that error is returned.
+func (b *EventBuffer) Add(severity int, events ...EventData) error {
+	b.mutex.Lock()
+	if severity > b.level {
+		b.mutex.Unlock()
+		return nil
+	}
+	dropped := 0
+	for _, event := range events {
+		if b.push(event) {
+			dropped++
+		}
+	}
+	b.dropped += dropped
+	deliver := b.online && !b.flushing
+	if deliver {
+		b.flushing = true
+	}
+	online := b.online
+	b.mutex.Unlock()
+	b.eventsDropped(dropped)
+	if !online {
+		return ErrEventsBuffered
+	}
+	if !deliver {
+		// An ongoing flush will deliver the events
+		return nil
+	}
+	return b.flush()
+}
+
+// AddTriggered reports the events of all monitors triggered during an evaluation, using the severity of each monitor.
+func (b *EventBuffer) AddTriggered(triggered []TriggeredMonitor) error {
+	now := b.currentTime()
+	var firstErr error
+	for _, t := range triggered {
+		if err := b.Add(t.Monitor.Severity, t.EventData(0, now)); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// OnDisconnected marks the charging station as offline. All following events are retained.
+func (b *EventBuffer) OnDisconnected() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.online = false
+}
+
+// OnReconnected marks the charging station as online and starts delivering retained events asynchronously.
+func (b *EventBuffer) OnReconnected() {
+	b.mutex.Lock()
+	b.online = true
+	if b.flushing {
+		b.mutex.Unlock()
+		return
+	}
+	b.flushing = true
+	b.mutex.Unlock()
+	go func() {
+		_ = b.flush()
+	}()
+}
+
+// BufferLength returns the amount of currently retained events.
+func (b *EventBuffer) BufferLength() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.ring.count
+}
+
+// DroppedEvents returns the total amount of events, which were overwritten before they could be delivered.
+func (b *EventBuffer) DroppedEvents() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.dropped
+}
+
+// Delivers retained events in batches, until the buffer is empty or the charging station goes offline.
+// Returns ErrEventsBuffered if the charging station went offline, or the first error response from the CSMS.
+func (b *EventBuffer) flush() error {
+	var result error
+	seqNo := 0
+	for {
+		b.mutex.Lock()
+		if !b.online || b.ring.count == 0 {
+			b.flushing = false
+			b.mutex.Unlock()
+			return result
+		}
+		events := b.ring.peek(b.ItemsPerMessage)
+		request := NewNotifyEventRequest(types.NewDateTime(b.now()), seqNo, events)
+		request.Tbc = b.ring.count > len(events)
+		b.mutex.Unlock()
+		err := b.Send(request)
+		if err != nil && !isResponseError(err) {
+			b.mutex.Lock()
+			b.online = false
+			b.flushing = false
+			b.mutex.Unlock()
+			return ErrEventsBuffered
+		}
+		if err != nil && result == nil {
+			result = err
+		}
+		b.mutex.Lock()
+		b.ring.popUntil(events[len(events)-1].EventID)
+		b.mutex.Unlock()
+		seqNo++
+		if !request.Tbc {
+			seqNo = 0
+		}
+	}
+}
+
+// Must be invoked while holding the lock. Returns true if an older event was overwritten.
+func (b *EventBuffer) push(event EventData) bool {
+	event.EventID = b.nextEventID
+	b.nextEventID++
+	if event.Timestamp == nil {
+		event.Timestamp = types.NewDateTime(b.now())
+	}
+	return b.ring.push(event)
+}
+
+func (b *EventBuffer) currentTime() time.Time {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.now()
+}
+
+func (b *EventBuffer) eventsDropped(dropped int) {
+	if dropped > 0 && b.OnEventsDropped != nil {
+		b.OnEventsDropped(dropped)
+	}
+}
+
+func isResponseError(err error) bool {
+	_, ok := err.(*ocpp.Error)
+	return ok
+}
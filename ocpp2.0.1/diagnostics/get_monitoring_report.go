@@ -35,12 +35,14 @@ type GetMonitoringReportRequest struct {
 	RequestID          *int                      `json:"requestId,omitempty" validate:"omitempty,gte=0"`                                  // The Id of the request.
 	MonitoringCriteria []MonitoringCriteriaType  `json:"monitoringCriteria,omitempty" validate:"omitempty,max=3,dive,monitoringCriteria"` // This field contains criteria for components for which a monitoring report is requested.
 	ComponentVariable  []types.ComponentVariable `json:"componentVariable,omitempty" validate:"omitempty,dive"`                           // This field specifies the components and variables for which a monitoring report is requested.
+	CustomData         *types.CustomData         `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // This field definition of the GetMonitoringReport response payload, sent by the Charging Station to the CSMS in response to a GetMonitoringReportRequest.
 // In case the request was invalid, or couldn't be processed, an error will be sent instead.
 type GetMonitoringReportResponse struct {
-	Status types.GenericDeviceModelStatus `json:"status" validate:"required,genericDeviceModelStatus"` // This field indicates whether the Charging Station was able to accept the request.
+	Status     types.GenericDeviceModelStatus `json:"status" validate:"required,genericDeviceModelStatus"` // This field indicates whether the Charging Station was able to accept the request.
+	CustomData *types.CustomData              `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // A CSMS can request the Charging Station to send a report about configured monitoring settings per component and variable.
@@ -81,5 +83,5 @@ func NewGetMonitoringReportResponse(status types.GenericDeviceModelStatus) *GetM
 }
 
 func init() {
-	_ = types.Validate.RegisterValidation("monitoringCriteria", isValidMonitoringCriteriaType)
+	_ = types.RegisterValidation("monitoringCriteria", isValidMonitoringCriteriaType)
 }
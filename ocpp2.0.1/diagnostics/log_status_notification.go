@@ -22,12 +22,13 @@ const (
 	UploadLogStatusUploaded         UploadLogStatus = "Uploaded"              // File has been uploaded successfully.
 	UploadLogStatusUploadFailure    UploadLogStatus = "UploadFailure"         // Failed to upload the requested file.
 	UploadLogStatusUploading        UploadLogStatus = "Uploading"             // File is being uploaded.
+	UploadLogStatusAcceptedCanceled UploadLogStatus = "AcceptedCanceled"      // On-going log upload is canceled and new request to upload log has been accepted.
 )
 
 func isValidUploadLogStatus(fl validator.FieldLevel) bool {
 	status := UploadLogStatus(fl.Field().String())
 	switch status {
-	case UploadLogStatusBadMessage, UploadLogStatusIdle, UploadLogStatusNotSupportedOp, UploadLogStatusPermissionDenied, UploadLogStatusUploaded, UploadLogStatusUploadFailure, UploadLogStatusUploading:
+	case UploadLogStatusBadMessage, UploadLogStatusIdle, UploadLogStatusNotSupportedOp, UploadLogStatusPermissionDenied, UploadLogStatusUploaded, UploadLogStatusUploadFailure, UploadLogStatusUploading, UploadLogStatusAcceptedCanceled:
 		return true
 	default:
 		return false
@@ -36,13 +37,15 @@ func isValidUploadLogStatus(fl validator.FieldLevel) bool {
 
 // The field definition of the LogStatusNotification request payload sent by a Charging Station to the CSMS.
 type LogStatusNotificationRequest struct {
-	Status    UploadLogStatus `json:"status" validate:"required,uploadLogStatus"`
-	RequestID int             `json:"requestId" validate:"gte=0"`
+	Status     UploadLogStatus   `json:"status" validate:"required,uploadLogStatus"`
+	RequestID  int               `json:"requestId" validate:"gte=0"`
+	CustomData *types.CustomData `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // This field definition of the LogStatusNotification response payload, sent by the CSMS to the Charging Station in response to a LogStatusNotificationRequest.
 // In case the request was invalid, or couldn't be processed, an error will be sent instead.
 type LogStatusNotificationResponse struct {
+	CustomData *types.CustomData `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // A Charging Station shall send LogStatusNotification requests to update the CSMS with the current status of a log-upload procedure.
@@ -82,5 +85,5 @@ func NewLogStatusNotificationResponse() *LogStatusNotificationResponse {
 }
 
 func init() {
-	_ = types.Validate.RegisterValidation("uploadLogStatus", isValidUploadLogStatus)
+	_ = types.RegisterValidation("uploadLogStatus", isValidUploadLogStatus)
 }
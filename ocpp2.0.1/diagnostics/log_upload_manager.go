@@ -0,0 +1,206 @@
+package diagnostics
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/clock"
+	"github.com/lorenzodonini/ocpp-go/internal/retry"
+)
+
+var (
+	// ErrUploadPermissionDenied should be wrapped by a LogUploader, if the remote location refused the credentials.
+	ErrUploadPermissionDenied = errors.New("permission denied")
+	// ErrUploadBadMessage should be wrapped by a LogUploader, if the remote location reported a protocol error.
+	ErrUploadBadMessage = errors.New("bad message")
+	// ErrUploadNotSupported should be wrapped by a LogUploader, if the remote location doesn't support the upload operation.
+	ErrUploadNotSupported = errors.New("operation not supported")
+)
+
+// LogCollector collects the requested log, limited to the entries between the oldest and latest timestamp.
+// Either bound may be nil, meaning that the log is not limited in that direction.
+//
+// An empty filename indicates that no logging information is available.
+type LogCollector func(logType LogType, oldest *time.Time, latest *time.Time) (filename string, content []byte, err error)
+
+// LogUploader uploads a log file to a remote location. The upload must be aborted once ctx is canceled.
+//
+// Errors wrapping ErrUploadPermissionDenied, ErrUploadBadMessage or ErrUploadNotSupported are reported to the CSMS
+// with the respective status, and the upload is not retried. All other errors are retried.
+type LogUploader interface {
+	Upload(ctx context.Context, location *url.URL, filename string, content []byte) error
+}
+
+type logUpload struct {
+	requestID int
+	status    UploadLogStatus
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// LogUploadManager implements the log upload flow on the charging station side.
+//
+// OnGetLog matches the respective ChargingStationHandler method, so a diagnostics handler may delegate to it directly.
+// The requested log is collected synchronously, then uploaded in the background to the remote location,
+// using the uploader registered for its URL scheme. Progress is reported via LogStatusNotification messages:
+// Uploading, followed by Uploaded or by the respective failure status.
+//
+// Only one upload is performed at a time: a new GetLogRequest cancels the ongoing upload,
+// in which case the request is answered with AcceptedCanceled and no further status is reported for the canceled upload.
+//
+// A LogUploadManager is safe for concurrent use.
+type LogUploadManager struct {
+	// Collects the log contents. If the collector fails, the request is rejected.
+	Collect LogCollector
+	// Sends a LogStatusNotificationRequest to the CSMS. Typically invokes LogStatusNotification on the charging station.
+	SendStatus func(request *LogStatusNotificationRequest) error
+	// Invoked if a status notification couldn't be sent. Optional.
+	OnSendError func(requestID int, err error)
+	// The uploaders per URL scheme. By default, HTTP, HTTPS and FTP are supported.
+	Uploaders map[string]LogUploader
+	current   *logUpload
+	clock     clock.Clock
+	mutex     sync.Mutex
+}
+
+// NewLogUploadManager creates a new manager with the default uploaders.
+func NewLogUploadManager(collect LogCollector, sendStatus func(request *LogStatusNotificationRequest) error) *LogUploadManager {
+	httpUploader := &HTTPUploader{}
+	return &LogUploadManager{
+		Collect:    collect,
+		SendStatus: sendStatus,
+		Uploaders: map[string]LogUploader{
+			"http":  httpUploader,
+			"https": httpUploader,
+			"ftp":   &FTPUploader{},
+		},
+		clock: clock.New(),
+	}
+}
+
+// SetClock sets the clock used for waiting between upload attempts. Passing nil restores the real clock.
+// Uploads, which are already ongoing, are not affected.
+func (m *LogUploadManager) SetClock(c clock.Clock) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.clock = clock.OrDefault(c)
+}
+
+// CurrentStatus returns the requestId and status of the ongoing or last upload.
+// If no upload was requested yet, Idle is returned.
+// This is useful for answering a TriggerMessageRequest for LogStatusNotification.
+func (m *LogUploadManager) CurrentStatus() (requestID int, status UploadLogStatus) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.current == nil {
+		return 0, UploadLogStatusIdle
+	}
+	return m.current.requestID, m.current.status
+}
+
+func (m *LogUploadManager) OnGetLog(request *GetLogRequest) (*GetLogResponse, error) {
+	location, err := url.Parse(request.Log.RemoteLocation)
+	if err != nil {
+		return NewGetLogResponse(LogStatusRejected), nil
+	}
+	uploader, ok := m.Uploaders[location.Scheme]
+	if !ok || m.Collect == nil {
+		return NewGetLogResponse(LogStatusRejected), nil
+	}
+	var oldest, latest *time.Time
+	if request.Log.OldestTimestamp != nil {
+		oldest = &request.Log.OldestTimestamp.Time
+	}
+	if request.Log.LatestTimestamp != nil {
+		latest = &request.Log.LatestTimestamp.Time
+	}
+	filename, content, err := m.Collect(request.LogType, oldest, latest)
+	if err != nil {
+		return NewGetLogResponse(LogStatusRejected), nil
+	}
+	status := LogStatusAccepted
+	m.mutex.Lock()
+	previous := m.current
+	if previous != nil {
+		select {
+		case <-previous.done:
+		default:
+			previous.cancel()
+			status = LogStatusAcceptedCanceled
+		}
+	}
+	clk := clock.OrDefault(m.clock)
+	ctx, cancel := context.WithCancel(context.Background())
+	upload := &logUpload{requestID: request.RequestID, status: UploadLogStatusIdle, cancel: cancel, done: make(chan struct{})}
+	m.current = upload
+	m.mutex.Unlock()
+	response := NewGetLogResponse(status)
+	response.Filename = filename
+	go func() {
+		if previous != nil {
+			<-previous.done
+		}
+		m.upload(ctx, clk, upload, uploader, location, request, filename, content)
+	}()
+	return response, nil
+}
+
+func (m *LogUploadManager) upload(ctx context.Context, clk clock.Clock, upload *logUpload, uploader LogUploader, location *url.URL, request *GetLogRequest, filename string, content []byte) {
+	defer close(upload.done)
+	defer upload.cancel()
+	if !m.setStatus(ctx, upload, UploadLogStatusUploading) {
+		return
+	}
+	status := UploadLogStatusUploadFailure
+	completed := retry.FromRequest(request.Retries, request.RetryInterval, clk).Run(ctx, func(n int) bool {
+		err := uploader.Upload(ctx, location, filename, content)
+		if err == nil {
+			status = UploadLogStatusUploaded
+			return true
+		}
+		if failure, ok := uploadFailureStatus(err); ok {
+			status = failure
+			return true
+		}
+		return false
+	})
+	if !completed {
+		return
+	}
+	m.setStatus(ctx, upload, status)
+}
+
+// Updates the status of an upload and notifies the CSMS, unless the upload was canceled.
+func (m *LogUploadManager) setStatus(ctx context.Context, upload *logUpload, status UploadLogStatus) bool {
+	m.mutex.Lock()
+	if ctx.Err() != nil {
+		m.mutex.Unlock()
+		return false
+	}
+	upload.status = status
+	m.mutex.Unlock()
+	if m.SendStatus == nil {
+		return true
+	}
+	if err := m.SendStatus(NewLogStatusNotificationRequest(status, upload.requestID)); err != nil && m.OnSendError != nil {
+		m.OnSendError(upload.requestID, err)
+	}
+	return true
+}
+
+// Maps upload errors, which must not be retried, to the respective status.
+func uploadFailureStatus(err error) (UploadLogStatus, bool) {
+	switch {
+	case errors.Is(err, ErrUploadPermissionDenied):
+		return UploadLogStatusPermissionDenied, true
+	case errors.Is(err, ErrUploadBadMessage):
+		return UploadLogStatusBadMessage, true
+	case errors.Is(err, ErrUploadNotSupported):
+		return UploadLogStatusNotSupportedOp, true
+	default:
+		return "", false
+	}
+}
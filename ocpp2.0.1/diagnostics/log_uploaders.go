@@ -0,0 +1,113 @@
+package diagnostics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/internal/ftp"
+)
+
+// HTTPUploader uploads log files via an HTTP POST request, as a multipart form containing a single "file" field.
+// Credentials contained in the URL are sent via basic authentication.
+type HTTPUploader struct {
+	// The client used for the upload. If nil, http.DefaultClient is used.
+	Client *http.Client
+}
+
+func (u *HTTPUploader) Upload(ctx context.Context, location *url.URL, filename string, content []byte) error {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		return err
+	}
+	if _, err = part.Write(content); err != nil {
+		return err
+	}
+	if err = writer.Close(); err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, location.String(), body)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUploadBadMessage, err)
+	}
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	if location.User != nil {
+		password, _ := location.User.Password()
+		request.SetBasicAuth(location.User.Username(), password)
+	}
+	client := u.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, response.Body)
+	switch {
+	case response.StatusCode >= 200 && response.StatusCode < 300:
+		return nil
+	case response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden:
+		return fmt.Errorf("%w: %v", ErrUploadPermissionDenied, response.Status)
+	case response.StatusCode == http.StatusBadRequest:
+		return fmt.Errorf("%w: %v", ErrUploadBadMessage, response.Status)
+	case response.StatusCode == http.StatusMethodNotAllowed || response.StatusCode == http.StatusNotImplemented:
+		return fmt.Errorf("%w: %v", ErrUploadNotSupported, response.Status)
+	default:
+		return fmt.Errorf("upload failed: %v", response.Status)
+	}
+}
+
+// FTPUploader uploads log files via FTP in passive mode. If the URL contains no credentials, an anonymous login is performed.
+// If the URL path is empty or ends with a slash, the file is stored in that directory under its own filename.
+type FTPUploader struct {
+	// The timeout for establishing connections. Zero means no timeout.
+	Timeout time.Duration
+}
+
+func (u *FTPUploader) Upload(ctx context.Context, location *url.URL, filename string, content []byte) error {
+	conn, err := ftp.Dial(ctx, location, u.Timeout)
+	if err != nil {
+		return ftpError(err)
+	}
+	defer conn.Close()
+	target := location.Path
+	if target == "" || strings.HasSuffix(target, "/") {
+		target = path.Join(target, filename)
+	}
+	return ftpError(conn.Store(target, content))
+}
+
+// Maps FTP reply codes to the upload errors defined by this package.
+func ftpError(err error) error {
+	var replyErr *ftp.Error
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ftp.ErrInvalidResponse):
+		return fmt.Errorf("%w: %v", ErrUploadBadMessage, err)
+	case !errors.As(err, &replyErr):
+		return err
+	}
+	switch replyErr.Code {
+	case 530, 532:
+		return fmt.Errorf("%w: %v", ErrUploadPermissionDenied, replyErr)
+	case 500, 501, 503, 504:
+		return fmt.Errorf("%w: %v", ErrUploadBadMessage, replyErr)
+	case 502:
+		return fmt.Errorf("%w: %v", ErrUploadNotSupported, replyErr)
+	default:
+		return fmt.Errorf("upload failed: %v", replyErr)
+	}
+}
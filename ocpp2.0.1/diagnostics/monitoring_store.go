@@ -0,0 +1,489 @@
+package diagnostics
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// The default monitoring level of a MonitoringStore, reporting events of all severities.
+const DefaultMonitoringLevel = 9
+
+// Monitor is a variable monitor configured on a charging station.
+type Monitor struct {
+	VariableMonitoring
+	Component types.Component
+	Variable  types.Variable
+	// Origin of the monitor: EventCustomMonitor for monitors set by the CSMS,
+	// EventPreconfiguredMonitor or EventHardWiredMonitor for monitors defined by the manufacturer.
+	Origin EventNotification
+}
+
+// MonitorStorage persists the variable monitors configured on a charging station.
+// Implementations don't need to be safe for concurrent use, as they are only accessed by a single MonitoringStore.
+//
+// To retain monitors across reboots, provide a storage backed by persistent memory.
+type MonitorStorage interface {
+	// Put stores a monitor, replacing any existing monitor with the same ID.
+	Put(monitor Monitor) error
+	// Remove deletes the monitor with the given ID. Returns false if no such monitor exists.
+	Remove(id int) (bool, error)
+	// All returns all stored monitors.
+	All() ([]Monitor, error)
+}
+
+type memoryMonitorStorage struct {
+	monitors map[int]Monitor
+}
+
+// NewMemoryMonitorStorage creates a non-persistent MonitorStorage, keeping all monitors in memory.
+func NewMemoryMonitorStorage() MonitorStorage {
+	return &memoryMonitorStorage{monitors: map[int]Monitor{}}
+}
+
+func (s *memoryMonitorStorage) Put(monitor Monitor) error {
+	s.monitors[monitor.ID] = monitor
+	return nil
+}
+
+func (s *memoryMonitorStorage) Remove(id int) (bool, error) {
+	_, ok := s.monitors[id]
+	delete(s.monitors, id)
+	return ok, nil
+}
+
+func (s *memoryMonitorStorage) All() ([]Monitor, error) {
+	monitors := make([]Monitor, 0, len(s.monitors))
+	for _, monitor := range s.monitors {
+		monitors = append(monitors, monitor)
+	}
+	return monitors, nil
+}
+
+// TriggeredMonitor is returned by MonitoringStore.Evaluate for every monitor that triggered an event.
+type TriggeredMonitor struct {
+	Monitor     Monitor
+	Trigger     EventTrigger
+	ActualValue float64
+	// Set when the value returned within a threshold, after previously exceeding it.
+	Cleared bool
+}
+
+// EventData creates the EventData element, which reports the triggered monitor within a NotifyEventRequest.
+func (t TriggeredMonitor) EventData(eventID int, timestamp time.Time) EventData {
+	monitorID := t.Monitor.ID
+	return EventData{
+		EventID:               eventID,
+		Timestamp:             types.NewDateTime(timestamp),
+		Trigger:               t.Trigger,
+		ActualValue:           strconv.FormatFloat(t.ActualValue, 'f', -1, 64),
+		Cleared:               t.Cleared,
+		VariableMonitoringID:  &monitorID,
+		EventNotificationType: t.Monitor.Origin,
+		Component:             t.Monitor.Component,
+		Variable:              t.Monitor.Variable,
+	}
+}
+
+// Runtime evaluation state of a single monitor.
+type monitorState struct {
+	reference *float64
+	exceeded  bool
+	nextDue   time.Time
+}
+
+// MonitoringStore keeps the variable monitors of a charging station and evaluates them against sampled values.
+//
+// The OnSetVariableMonitoring, OnClearVariableMonitoring, OnSetMonitoringBase and OnSetMonitoringLevel methods
+// match the respective ChargingStationHandler methods, so a diagnostics handler may delegate to them directly.
+//
+// Monitors set by the CSMS are custom monitors. Preconfigured monitors are activated via SetMonitoringBase,
+// according to the presets registered with SetPreset. Hard-wired monitors, added via Install, can neither be replaced nor cleared.
+//
+// Evaluation state (delta references, exceeded thresholds, periodic schedules) is kept in memory only.
+//
+// A MonitoringStore is safe for concurrent use.
+type MonitoringStore struct {
+	// Reports whether a component exists on the charging station. If nil, all components are considered known.
+	IsKnownComponent func(component types.Component) bool
+	// Reports whether a variable exists on a component. If nil, all variables are considered known.
+	IsKnownVariable func(component types.Component, variable types.Variable) bool
+	// Reports whether a transaction is ongoing on the component. If nil, monitors with the transaction flag never trigger.
+	IsTransactionOngoing func(component types.Component) bool
+	// Invoked whenever the monitoring level changed, allowing the application to persist it. Optional.
+	OnLevelChanged func(severity int)
+	storage        MonitorStorage
+	monitors       map[int]Monitor
+	states         map[int]*monitorState
+	presets        map[MonitoringBase][]Monitor
+	level          int
+	nextID         int
+	now            func() time.Time
+	mutex          sync.Mutex
+}
+
+// NewMonitoringStore creates a new store backed by the passed storage, loading all previously stored monitors.
+// If storage is nil, monitors are kept in memory.
+func NewMonitoringStore(storage MonitorStorage) (*MonitoringStore, error) {
+	if storage == nil {
+		storage = NewMemoryMonitorStorage()
+	}
+	monitors, err := storage.All()
+	if err != nil {
+		return nil, err
+	}
+	s := &MonitoringStore{
+		storage:  storage,
+		monitors: map[int]Monitor{},
+		states:   map[int]*monitorState{},
+		presets:  map[MonitoringBase][]Monitor{},
+		level:    DefaultMonitoringLevel,
+		nextID:   1,
+		now:      time.Now,
+	}
+	for _, monitor := range monitors {
+		s.monitors[monitor.ID] = monitor
+		if monitor.ID >= s.nextID {
+			s.nextID = monitor.ID + 1
+		}
+	}
+	return s, nil
+}
+
+// SetTimeSource replaces the function used for retrieving the current time. Intended for testing.
+func (s *MonitoringStore) SetTimeSource(now func() time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.now = now
+}
+
+// SetPreset registers the preconfigured monitors, which are activated when the CSMS sets the given monitoring base.
+// IDs of the passed monitors are ignored, as they are assigned upon activation.
+func (s *MonitoringStore) SetPreset(base MonitoringBase, monitors []Monitor) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.presets[base] = monitors
+}
+
+// SetLevel restores a previously persisted monitoring level, without invoking OnLevelChanged.
+func (s *MonitoringStore) SetLevel(severity int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.level = severity
+}
+
+// Level returns the current monitoring level. Only events with a severity lower than or equal to the level are reported.
+func (s *MonitoringStore) Level() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.level
+}
+
+// Install adds a monitor defined by the charging station itself, e.g. a hard-wired monitor, and returns the assigned ID.
+func (s *MonitoringStore) Install(monitor Monitor) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	monitor.ID = s.nextID
+	if err := s.put(monitor); err != nil {
+		return 0, err
+	}
+	s.nextID++
+	return monitor.ID, nil
+}
+
+// Monitors returns all configured monitors, ordered by ID.
+func (s *MonitoringStore) Monitors() []Monitor {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	monitors := make([]Monitor, 0, len(s.monitors))
+	for _, monitor := range s.monitors {
+		monitors = append(monitors, monitor)
+	}
+	sort.Slice(monitors, func(i, j int) bool {
+		return monitors[i].ID < monitors[j].ID
+	})
+	return monitors
+}
+
+// OnSetVariableMonitoring applies every monitoring setting in order, and returns a result for each of them.
+// A setting is reported as Duplicate, if a monitor with the same type and severity already exists for the component/variable.
+func (s *MonitoringStore) OnSetVariableMonitoring(request *SetVariableMonitoringRequest) (*SetVariableMonitoringResponse, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	results := make([]SetMonitoringResult, 0, len(request.MonitoringData))
+	for _, data := range request.MonitoringData {
+		result := SetMonitoringResult{
+			Type:      data.Type,
+			Severity:  data.Severity,
+			Component: data.Component,
+			Variable:  data.Variable,
+		}
+		status, id, err := s.setMonitor(data)
+		if err != nil {
+			return nil, err
+		}
+		result.Status = status
+		if status == SetMonitoringStatusAccepted {
+			result.ID = &id
+		}
+		results = append(results, result)
+	}
+	return NewSetVariableMonitoringResponse(results), nil
+}
+
+// OnClearVariableMonitoring removes the requested monitors. Hard-wired monitors are rejected.
+func (s *MonitoringStore) OnClearVariableMonitoring(request *ClearVariableMonitoringRequest) (*ClearVariableMonitoringResponse, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	results := make([]ClearMonitoringResult, 0, len(request.ID))
+	for _, id := range request.ID {
+		result := ClearMonitoringResult{ID: id}
+		monitor, ok := s.monitors[id]
+		switch {
+		case !ok:
+			result.Status = ClearMonitoringStatusNotFound
+		case monitor.Origin == EventHardWiredMonitor:
+			result.Status = ClearMonitoringStatusRejected
+		default:
+			if err := s.remove(id); err != nil {
+				return nil, err
+			}
+			result.Status = ClearMonitoringStatusAccepted
+		}
+		results = append(results, result)
+	}
+	return NewClearVariableMonitoringResponse(results), nil
+}
+
+// OnSetMonitoringBase activates the preconfigured monitors of the requested base, replacing all other preconfigured monitors.
+// Custom monitors are kept, except for HardWiredOnly, which removes all custom and preconfigured monitors.
+// If no preset was registered for All or FactoryDefault, NotSupported is returned.
+func (s *MonitoringStore) OnSetMonitoringBase(request *SetMonitoringBaseRequest) (*SetMonitoringBaseResponse, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	preset, ok := s.presets[request.MonitoringBase]
+	if !ok && request.MonitoringBase != MonitoringBaseHardWiredOnly {
+		return NewSetMonitoringBaseResponse(types.GenericDeviceModelStatusNotSupported), nil
+	}
+	for id, monitor := range s.monitors {
+		remove := monitor.Origin == EventPreconfiguredMonitor ||
+			(monitor.Origin == EventCustomMonitor && request.MonitoringBase == MonitoringBaseHardWiredOnly)
+		if !remove {
+			continue
+		}
+		if err := s.remove(id); err != nil {
+			return nil, err
+		}
+	}
+	for _, monitor := range preset {
+		monitor.ID = s.nextID
+		monitor.Origin = EventPreconfiguredMonitor
+		if err := s.put(monitor); err != nil {
+			return nil, err
+		}
+		s.nextID++
+	}
+	return NewSetMonitoringBaseResponse(types.GenericDeviceModelStatusAccepted), nil
+}
+
+// OnSetMonitoringLevel restricts the events returned by Evaluate to monitors with a severity lower than or equal to the requested one.
+func (s *MonitoringStore) OnSetMonitoringLevel(request *SetMonitoringLevelRequest) (*SetMonitoringLevelResponse, error) {
+	if request.Severity < 0 || request.Severity > 9 {
+		return NewSetMonitoringLevelResponse(types.GenericDeviceModelStatusRejected), nil
+	}
+	s.mutex.Lock()
+	s.level = request.Severity
+	s.mutex.Unlock()
+	if s.OnLevelChanged != nil {
+		s.OnLevelChanged(request.Severity)
+	}
+	return NewSetMonitoringLevelResponse(types.GenericDeviceModelStatusAccepted), nil
+}
+
+// Evaluate checks all monitors of a component/variable against a sampled value, and returns the monitors that triggered an event:
+//
+// - UpperThreshold/LowerThreshold monitors trigger once when the value exceeds the threshold, and once more (cleared) when it returns
+//
+// - Delta monitors trigger when the value changed by more than the monitor value since the monitor was set or last triggered
+//
+// - Periodic/PeriodicClockAligned monitors trigger once per elapsed interval
+//
+// Since periodic monitors are only evaluated on invocation, Evaluate should be called at least once per second for such variables.
+// Monitors with a severity above the monitoring level update their state, but are not returned.
+func (s *MonitoringStore) Evaluate(component types.Component, variable types.Variable, value float64, now time.Time) []TriggeredMonitor {
+	s.mutex.Lock()
+	var matching []Monitor
+	for _, monitor := range s.monitors {
+		if matchesComponentVariable(monitor, component, variable) {
+			matching = append(matching, monitor)
+		}
+	}
+	level := s.level
+	s.mutex.Unlock()
+	sort.Slice(matching, func(i, j int) bool {
+		return matching[i].ID < matching[j].ID
+	})
+	var triggered []TriggeredMonitor
+	for _, monitor := range matching {
+		if monitor.Transaction && (s.IsTransactionOngoing == nil || !s.IsTransactionOngoing(component)) {
+			continue
+		}
+		s.mutex.Lock()
+		state := s.states[monitor.ID]
+		if state == nil {
+			state = s.newState(monitor, now)
+		}
+		t, ok := evaluateMonitor(monitor, state, value, now)
+		s.mutex.Unlock()
+		if ok && monitor.Severity <= level {
+			triggered = append(triggered, t)
+		}
+	}
+	return triggered
+}
+
+func evaluateMonitor(monitor Monitor, state *monitorState, value float64, now time.Time) (TriggeredMonitor, bool) {
+	t := TriggeredMonitor{Monitor: monitor, ActualValue: value}
+	switch monitor.Type {
+	case MonitorUpperThreshold, MonitorLowerThreshold:
+		exceeded := value > monitor.Value
+		if monitor.Type == MonitorLowerThreshold {
+			exceeded = value < monitor.Value
+		}
+		if exceeded == state.exceeded {
+			return t, false
+		}
+		state.exceeded = exceeded
+		t.Trigger = EventTriggerAlerting
+		t.Cleared = !exceeded
+		return t, true
+	case MonitorDelta:
+		if state.reference == nil {
+			state.reference = &value
+			return t, false
+		}
+		if math.Abs(value-*state.reference) <= monitor.Value {
+			return t, false
+		}
+		state.reference = &value
+		t.Trigger = EventTriggerDelta
+		return t, true
+	case MonitorPeriodic, MonitorPeriodicClockAligned:
+		if now.Before(state.nextDue) {
+			return t, false
+		}
+		interval := monitorInterval(monitor)
+		for !state.nextDue.After(now) {
+			state.nextDue = state.nextDue.Add(interval)
+		}
+		t.Trigger = EventTriggerPeriodic
+		return t, true
+	}
+	return t, false
+}
+
+// Must be invoked while holding the lock.
+func (s *MonitoringStore) setMonitor(data SetMonitoringData) (SetMonitoringStatus, int, error) {
+	if s.IsKnownComponent != nil && !s.IsKnownComponent(data.Component) {
+		return SetMonitoringStatusUnknownComponent, 0, nil
+	}
+	if s.IsKnownVariable != nil && !s.IsKnownVariable(data.Component, data.Variable) {
+		return SetMonitoringStatusUnknownVariable, 0, nil
+	}
+	if (data.Type == MonitorPeriodic || data.Type == MonitorPeriodicClockAligned) && data.Value < 1 {
+		return SetMonitoringStatusRejected, 0, nil
+	}
+	monitor := Monitor{
+		VariableMonitoring: NewVariableMonitoring(s.nextID, data.Transaction, data.Value, data.Type, data.Severity),
+		Component:          data.Component,
+		Variable:           data.Variable,
+		Origin:             EventCustomMonitor,
+	}
+	if data.ID != nil {
+		existing, ok := s.monitors[*data.ID]
+		if !ok || existing.Origin == EventHardWiredMonitor || !matchesComponentVariable(existing, data.Component, data.Variable) {
+			return SetMonitoringStatusRejected, 0, nil
+		}
+		monitor.ID = existing.ID
+	}
+	for _, existing := range s.monitors {
+		if existing.ID != monitor.ID && existing.Type == monitor.Type && existing.Severity == monitor.Severity &&
+			matchesComponentVariable(existing, data.Component, data.Variable) {
+			return SetMonitoringStatusDuplicate, 0, nil
+		}
+	}
+	if err := s.put(monitor); err != nil {
+		return "", 0, err
+	}
+	if data.ID == nil {
+		s.nextID++
+	}
+	return SetMonitoringStatusAccepted, monitor.ID, nil
+}
+
+// Must be invoked while holding the lock.
+func (s *MonitoringStore) put(monitor Monitor) error {
+	if err := s.storage.Put(monitor); err != nil {
+		return err
+	}
+	s.monitors[monitor.ID] = monitor
+	s.newState(monitor, s.now())
+	return nil
+}
+
+// Must be invoked while holding the lock.
+func (s *MonitoringStore) remove(id int) error {
+	if _, err := s.storage.Remove(id); err != nil {
+		return err
+	}
+	delete(s.monitors, id)
+	delete(s.states, id)
+	return nil
+}
+
+// Must be invoked while holding the lock.
+func (s *MonitoringStore) newState(monitor Monitor, setAt time.Time) *monitorState {
+	state := &monitorState{}
+	switch monitor.Type {
+	case MonitorPeriodic:
+		state.nextDue = setAt.Add(monitorInterval(monitor))
+	case MonitorPeriodicClockAligned:
+		interval := monitorInterval(monitor)
+		midnight := time.Date(setAt.Year(), setAt.Month(), setAt.Day(), 0, 0, 0, 0, setAt.Location())
+		elapsed := setAt.Sub(midnight)
+		state.nextDue = midnight.Add((elapsed + interval - 1) / interval * interval)
+	}
+	s.states[monitor.ID] = state
+	return state
+}
+
+func monitorInterval(monitor Monitor) time.Duration {
+	interval := time.Duration(monitor.Value * float64(time.Second))
+	if interval < time.Second {
+		interval = time.Second
+	}
+	return interval
+}
+
+func matchesComponentVariable(monitor Monitor, component types.Component, variable types.Variable) bool {
+	if !strings.EqualFold(monitor.Component.Name, component.Name) || !strings.EqualFold(monitor.Component.Instance, component.Instance) ||
+		!strings.EqualFold(monitor.Variable.Name, variable.Name) || !strings.EqualFold(monitor.Variable.Instance, variable.Instance) {
+		return false
+	}
+	a, b := monitor.Component.EVSE, component.EVSE
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if a.ID != b.ID {
+		return false
+	}
+	if a.ConnectorID == nil || b.ConnectorID == nil {
+		return a.ConnectorID == nil && b.ConnectorID == nil
+	}
+	return *a.ConnectorID == *b.ConnectorID
+}
@@ -0,0 +1,406 @@
+package diagnostics
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/lorenzodonini/ocpp-go/clock"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+const (
+	// The default time after which an incomplete report is delivered, if no further part was received.
+	DefaultReportInactivityTimeout = 60 * time.Second
+	// The default maximum amount of MonitoringData elements buffered per charging station, across all pending monitoring reports.
+	DefaultMaxMonitoringDataPerStation = 10000
+	// The default maximum amount of customer information bytes buffered per charging station, across all pending requests.
+	DefaultMaxCustomerInformationPerStation = 1024 * 1024
+	// The default maximum amount of concurrently pending reports per charging station.
+	DefaultMaxPendingReportsPerStation = 8
+	// The maximum length of the data field of a NotifyCustomerInformationRequest, in characters.
+	MaxCustomerInformationDataLength = 512
+)
+
+// ReportSequenceError is returned when a report part is received out of sequence, i.e. one or more parts were skipped.
+type ReportSequenceError struct {
+	ChargingStationID string
+	RequestID         int
+	ExpectedSeqNo     int
+	ReceivedSeqNo     int
+}
+
+func (e *ReportSequenceError) Error() string {
+	return fmt.Sprintf("report %v from %v: expected seqNo %v, received %v", e.RequestID, e.ChargingStationID, e.ExpectedSeqNo, e.ReceivedSeqNo)
+}
+
+// PartialReportError is passed to the completion handler when a report is incomplete,
+// because no further part was received before the inactivity timeout.
+type PartialReportError struct {
+	ChargingStationID string
+	RequestID         int
+	ReceivedParts     int
+}
+
+func (e *PartialReportError) Error() string {
+	return fmt.Sprintf("report %v from %v: incomplete after %v parts", e.RequestID, e.ChargingStationID, e.ReceivedParts)
+}
+
+// ReportLimitError is returned when a report would exceed the buffering limits of a charging station.
+type ReportLimitError struct {
+	ChargingStationID string
+	RequestID         int
+	Reason            string
+}
+
+func (e *ReportLimitError) Error() string {
+	return fmt.Sprintf("report %v from %v: %v", e.RequestID, e.ChargingStationID, e.Reason)
+}
+
+// SplitMonitoringReport partitions the monitoring settings into NotifyMonitoringReportRequest parts, containing at most itemsPerMessage elements each.
+// Parts are numbered starting from seqNo 0, and all parts except the last one have the tbc flag set.
+// If itemsPerMessage is zero, a single part is returned.
+//
+// If there are no monitors, nil is returned: in that case the charging station is expected to respond
+// to the GetMonitoringReportRequest with status EmptyResultSet, without sending any report.
+func SplitMonitoringReport(requestID int, generatedAt *types.DateTime, monitors []MonitoringData, itemsPerMessage int) []*NotifyMonitoringReportRequest {
+	if len(monitors) == 0 {
+		return nil
+	}
+	if itemsPerMessage <= 0 {
+		itemsPerMessage = len(monitors)
+	}
+	var requests []*NotifyMonitoringReportRequest
+	for start := 0; start < len(monitors); start += itemsPerMessage {
+		end := start + itemsPerMessage
+		if end > len(monitors) {
+			end = len(monitors)
+		}
+		request := NewNotifyMonitoringReportRequest(requestID, len(requests), generatedAt, monitors[start:end])
+		request.Tbc = end < len(monitors)
+		requests = append(requests, request)
+	}
+	return requests
+}
+
+// SplitCustomerInformation partitions the customer information into NotifyCustomerInformationRequest parts,
+// each containing at most MaxCustomerInformationDataLength characters. Multi-byte characters are never split.
+// Parts are numbered starting from seqNo 0, and all parts except the last one have the tbc flag set.
+//
+// If data is empty, nil is returned.
+func SplitCustomerInformation(requestID int, generatedAt types.DateTime, data string) []*NotifyCustomerInformationRequest {
+	var requests []*NotifyCustomerInformationRequest
+	for len(data) > 0 {
+		end := len(data)
+		if utf8.RuneCountInString(data) > MaxCustomerInformationDataLength {
+			end = 0
+			for i := 0; i < MaxCustomerInformationDataLength; i++ {
+				_, size := utf8.DecodeRuneInString(data[end:])
+				end += size
+			}
+		}
+		request := NewNotifyCustomerInformationRequest(data[:end], len(requests), generatedAt, requestID)
+		data = data[end:]
+		request.Tbc = len(data) > 0
+		requests = append(requests, request)
+	}
+	return requests
+}
+
+// MonitoringReportCompletionHandler is invoked by a MonitoringReportAssembler once a monitoring report was fully received, or was aborted.
+// If err is not nil, monitors contains the parts received so far.
+type MonitoringReportCompletionHandler func(chargingStationID string, requestID int, monitors []MonitoringData, err error)
+
+// MonitoringReportAssembler reassembles monitoring reports sent by charging stations as a sequence of NotifyMonitoringReportRequest messages,
+// in response to a GetMonitoringReportRequest.
+//
+// Each received NotifyMonitoringReportRequest should be passed to Add. Parts are grouped by charging station and requestId.
+// Once the last part (tbc = false) is received, the completion handler is invoked with all MonitoringData in order.
+//
+// A report is aborted, and the completion handler invoked with an error, if:
+//
+// - a part is missing (ReportSequenceError)
+//
+// - no further part is received within the inactivity timeout (PartialReportError)
+//
+// - the buffering limits of the charging station are exceeded (ReportLimitError)
+//
+// A MonitoringReportAssembler is safe for concurrent use.
+type MonitoringReportAssembler struct {
+	assembler *sequenceAssembler[MonitoringData]
+}
+
+// NewMonitoringReportAssembler creates a new assembler, invoking the passed handler for every completed or aborted report.
+// If timeout is zero, DefaultReportInactivityTimeout is used.
+func NewMonitoringReportAssembler(timeout time.Duration, onComplete MonitoringReportCompletionHandler) *MonitoringReportAssembler {
+	assembler := newSequenceAssembler(timeout, DefaultMaxMonitoringDataPerStation, "too much buffered monitoring data",
+		func(monitors []MonitoringData) int { return len(monitors) },
+		func(chargingStationID string, requestID int, monitors []MonitoringData, err error) {
+			if onComplete != nil {
+				onComplete(chargingStationID, requestID, monitors, err)
+			}
+		})
+	return &MonitoringReportAssembler{assembler: assembler}
+}
+
+// SetLimits configures the maximum amount of MonitoringData elements buffered per charging station,
+// and the maximum amount of concurrently pending reports per charging station.
+func (a *MonitoringReportAssembler) SetLimits(maxMonitoringData int, maxPendingReports int) {
+	a.assembler.setLimits(maxMonitoringData, maxPendingReports)
+}
+
+// SetClock sets the clock used for the inactivity timeout of pending reports. Passing nil restores the real clock.
+//
+// Timers already running are not affected.
+func (a *MonitoringReportAssembler) SetClock(c clock.Clock) {
+	a.assembler.setClock(c)
+}
+
+// Add feeds a report part received from a charging station to the assembler.
+//
+// Parts with a seqNo lower than expected are treated as retransmissions and ignored.
+// If the part couldn't be added, the report is aborted and the error is returned as well as passed to the completion handler.
+func (a *MonitoringReportAssembler) Add(chargingStationID string, request *NotifyMonitoringReportRequest) error {
+	if request == nil {
+		return nil
+	}
+	return a.assembler.add(chargingStationID, request.RequestID, request.SeqNo, request.Tbc, request.Monitor)
+}
+
+// Discard drops all pending reports of a charging station, without invoking the completion handler.
+// This may be invoked when a charging station disconnects.
+func (a *MonitoringReportAssembler) Discard(chargingStationID string) {
+	a.assembler.discard(chargingStationID)
+}
+
+// PendingReports returns the amount of incomplete reports currently buffered for a charging station.
+func (a *MonitoringReportAssembler) PendingReports(chargingStationID string) int {
+	return a.assembler.pendingSequences(chargingStationID)
+}
+
+// CustomerInformationCompletionHandler is invoked by a CustomerInformationAssembler once all customer information was received,
+// or the assembly was aborted. The data of all parts is concatenated in order.
+// If err is not nil, data contains the parts received so far.
+type CustomerInformationCompletionHandler func(chargingStationID string, requestID int, data string, err error)
+
+// CustomerInformationAssembler reassembles customer information sent by charging stations as a sequence of
+// NotifyCustomerInformationRequest messages, in response to a CustomerInformationRequest.
+//
+// Each received NotifyCustomerInformationRequest should be passed to Add. Parts are grouped by charging station and requestId.
+// Once the last part (tbc = false) is received, the completion handler is invoked with the concatenated data.
+//
+// Assembly is aborted under the same conditions as for a MonitoringReportAssembler.
+//
+// A CustomerInformationAssembler is safe for concurrent use.
+type CustomerInformationAssembler struct {
+	assembler *sequenceAssembler[string]
+}
+
+// NewCustomerInformationAssembler creates a new assembler, invoking the passed handler for every completed or aborted request.
+// If timeout is zero, DefaultReportInactivityTimeout is used.
+func NewCustomerInformationAssembler(timeout time.Duration, onComplete CustomerInformationCompletionHandler) *CustomerInformationAssembler {
+	assembler := newSequenceAssembler(timeout, DefaultMaxCustomerInformationPerStation, "too much buffered customer information",
+		func(data []string) int {
+			size := 0
+			for _, d := range data {
+				size += len(d)
+			}
+			return size
+		},
+		func(chargingStationID string, requestID int, data []string, err error) {
+			if onComplete != nil {
+				onComplete(chargingStationID, requestID, strings.Join(data, ""), err)
+			}
+		})
+	return &CustomerInformationAssembler{assembler: assembler}
+}
+
+// SetLimits configures the maximum amount of customer information bytes buffered per charging station,
+// and the maximum amount of concurrently pending requests per charging station.
+func (a *CustomerInformationAssembler) SetLimits(maxDataLength int, maxPendingRequests int) {
+	a.assembler.setLimits(maxDataLength, maxPendingRequests)
+}
+
+// SetClock sets the clock used for the inactivity timeout of pending requests. Passing nil restores the real clock.
+//
+// Timers already running are not affected.
+func (a *CustomerInformationAssembler) SetClock(c clock.Clock) {
+	a.assembler.setClock(c)
+}
+
+// Add feeds a customer information part received from a charging station to the assembler.
+//
+// Parts with a seqNo lower than expected are treated as retransmissions and ignored.
+// If the part couldn't be added, the assembly is aborted and the error is returned as well as passed to the completion handler.
+func (a *CustomerInformationAssembler) Add(chargingStationID string, request *NotifyCustomerInformationRequest) error {
+	if request == nil {
+		return nil
+	}
+	return a.assembler.add(chargingStationID, request.RequestID, request.SeqNo, request.Tbc, []string{request.Data})
+}
+
+// Discard drops all pending requests of a charging station, without invoking the completion handler.
+// This may be invoked when a charging station disconnects.
+func (a *CustomerInformationAssembler) Discard(chargingStationID string) {
+	a.assembler.discard(chargingStationID)
+}
+
+// PendingRequests returns the amount of incomplete requests currently buffered for a charging station.
+func (a *CustomerInformationAssembler) PendingRequests(chargingStationID string) int {
+	return a.assembler.pendingSequences(chargingStationID)
+}
+
+type sequenceKey struct {
+	chargingStationID string
+	requestID         int
+}
+
+type pendingSequence[T any] struct {
+	nextSeqNo int
+	items     []T
+	size      int
+	timer     clock.Timer
+}
+
+// Reassembles sequences of parts, identified by seqNo and tbc, grouped by charging station and requestId.
+type sequenceAssembler[T any] struct {
+	onComplete     func(chargingStationID string, requestID int, items []T, err error)
+	sizeOf         func(items []T) int
+	limitReason    string
+	timeout        time.Duration
+	maxSize        int
+	maxPending     int
+	sequences      map[sequenceKey]*pendingSequence[T]
+	stationSize    map[string]int
+	stationPending map[string]int
+	clock          clock.Clock
+	mutex          sync.Mutex
+}
+
+func newSequenceAssembler[T any](timeout time.Duration, maxSize int, limitReason string, sizeOf func(items []T) int, onComplete func(chargingStationID string, requestID int, items []T, err error)) *sequenceAssembler[T] {
+	if timeout <= 0 {
+		timeout = DefaultReportInactivityTimeout
+	}
+	return &sequenceAssembler[T]{
+		onComplete:     onComplete,
+		sizeOf:         sizeOf,
+		limitReason:    limitReason,
+		timeout:        timeout,
+		maxSize:        maxSize,
+		maxPending:     DefaultMaxPendingReportsPerStation,
+		sequences:      map[sequenceKey]*pendingSequence[T]{},
+		stationSize:    map[string]int{},
+		stationPending: map[string]int{},
+		clock:          clock.New(),
+	}
+}
+
+func (a *sequenceAssembler[T]) setLimits(maxSize int, maxPending int) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.maxSize = maxSize
+	a.maxPending = maxPending
+}
+
+func (a *sequenceAssembler[T]) setClock(c clock.Clock) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.clock = clock.OrDefault(c)
+}
+
+func (a *sequenceAssembler[T]) add(chargingStationID string, requestID int, seqNo int, tbc bool, items []T) error {
+	key := sequenceKey{chargingStationID: chargingStationID, requestID: requestID}
+	a.mutex.Lock()
+	sequence, ok := a.sequences[key]
+	if !ok {
+		if a.stationPending[chargingStationID] >= a.maxPending {
+			a.mutex.Unlock()
+			err := &ReportLimitError{ChargingStationID: chargingStationID, RequestID: requestID, Reason: "too many pending reports"}
+			a.complete(key, nil, err)
+			return err
+		}
+		sequence = &pendingSequence[T]{}
+		sequence.timer = a.clock.AfterFunc(a.timeout, func() {
+			a.onTimeout(key, sequence)
+		})
+		a.sequences[key] = sequence
+		a.stationPending[chargingStationID]++
+	}
+	if seqNo < sequence.nextSeqNo {
+		a.mutex.Unlock()
+		return nil
+	}
+	size := a.sizeOf(items)
+	var err error
+	if seqNo > sequence.nextSeqNo {
+		err = &ReportSequenceError{ChargingStationID: chargingStationID, RequestID: requestID, ExpectedSeqNo: sequence.nextSeqNo, ReceivedSeqNo: seqNo}
+	} else if a.stationSize[chargingStationID]+size > a.maxSize {
+		err = &ReportLimitError{ChargingStationID: chargingStationID, RequestID: requestID, Reason: a.limitReason}
+	}
+	if err != nil {
+		a.remove(key, sequence)
+		a.mutex.Unlock()
+		a.complete(key, sequence.items, err)
+		return err
+	}
+	sequence.nextSeqNo++
+	sequence.items = append(sequence.items, items...)
+	sequence.size += size
+	a.stationSize[chargingStationID] += size
+	if tbc {
+		sequence.timer.Reset(a.timeout)
+		a.mutex.Unlock()
+		return nil
+	}
+	a.remove(key, sequence)
+	a.mutex.Unlock()
+	a.complete(key, sequence.items, nil)
+	return nil
+}
+
+func (a *sequenceAssembler[T]) discard(chargingStationID string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for key, sequence := range a.sequences {
+		if key.chargingStationID == chargingStationID {
+			a.remove(key, sequence)
+		}
+	}
+}
+
+func (a *sequenceAssembler[T]) pendingSequences(chargingStationID string) int {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.stationPending[chargingStationID]
+}
+
+func (a *sequenceAssembler[T]) onTimeout(key sequenceKey, sequence *pendingSequence[T]) {
+	a.mutex.Lock()
+	if a.sequences[key] != sequence {
+		// Already completed
+		a.mutex.Unlock()
+		return
+	}
+	a.remove(key, sequence)
+	a.mutex.Unlock()
+	err := &PartialReportError{ChargingStationID: key.chargingStationID, RequestID: key.requestID, ReceivedParts: sequence.nextSeqNo}
+	a.complete(key, sequence.items, err)
+}
+
+// Must be invoked while holding the lock.
+func (a *sequenceAssembler[T]) remove(key sequenceKey, sequence *pendingSequence[T]) {
+	sequence.timer.Stop()
+	delete(a.sequences, key)
+	a.stationSize[key.chargingStationID] -= sequence.size
+	a.stationPending[key.chargingStationID]--
+	if a.stationPending[key.chargingStationID] <= 0 {
+		delete(a.stationPending, key.chargingStationID)
+		delete(a.stationSize, key.chargingStationID)
+	}
+}
+
+func (a *sequenceAssembler[T]) complete(key sequenceKey, items []T, err error) {
+	a.onComplete(key.chargingStationID, key.requestID, items, err)
+}
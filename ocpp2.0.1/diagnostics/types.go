@@ -27,5 +27,5 @@ func isValidMonitorType(fl validator.FieldLevel) bool {
 }
 
 func init() {
-	_ = types.Validate.RegisterValidation("monitorType", isValidMonitorType)
+	_ = types.RegisterValidation("monitorType", isValidMonitorType)
 }
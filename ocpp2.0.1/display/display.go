@@ -1,7 +1,11 @@
 // The display functional block contains OCPP 2.0 features for managing message that get displayed on a charging station.
 package display
 
-import "github.com/lorenzodonini/ocpp-go/ocpp"
+import (
+	"context"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+)
 
 // Needs to be implemented by a CSMS for handling messages part of the OCPP 2.0 Display profile.
 type CSMSHandler interface {
@@ -9,6 +13,29 @@ type CSMSHandler interface {
 	OnNotifyDisplayMessages(chargingStationID string, request *NotifyDisplayMessagesRequest) (response *NotifyDisplayMessagesResponse, err error)
 }
 
+// Context-aware variant of CSMSHandler, for handling messages part of the OCPP 2.0 Display profile.
+type CSMSHandlerWithContext interface {
+	// OnNotifyDisplayMessages is called on the CSMS whenever a NotifyDisplayMessagesRequest is received from a Charging Station.
+	OnNotifyDisplayMessages(ctx context.Context, chargingStationID string, request *NotifyDisplayMessagesRequest) (response *NotifyDisplayMessagesResponse, err error)
+}
+
+// AdaptCSMSHandler converts a CSMSHandler into a CSMSHandlerWithContext, which ignores the context.
+// Returns nil if handler is nil.
+func AdaptCSMSHandler(handler CSMSHandler) CSMSHandlerWithContext {
+	if handler == nil {
+		return nil
+	}
+	return csmsHandlerAdapter{handler: handler}
+}
+
+type csmsHandlerAdapter struct {
+	handler CSMSHandler
+}
+
+func (a csmsHandlerAdapter) OnNotifyDisplayMessages(ctx context.Context, chargingStationID string, request *NotifyDisplayMessagesRequest) (*NotifyDisplayMessagesResponse, error) {
+	return a.handler.OnNotifyDisplayMessages(chargingStationID, request)
+}
+
 // Needs to be implemented by Charging stations for handling messages part of the OCPP 2.0 Display profile.
 type ChargingStationHandler interface {
 	// OnClearDisplay is called on a charging station whenever a ClearDisplayRequest is received from the CSMS.
@@ -0,0 +1,305 @@
+package display
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/clock"
+)
+
+// The default period of the expiry loop of a MessageStore.
+const DefaultMessageExpiryInterval = 10 * time.Second
+
+// MessageStorage persists the display messages configured on a charging station.
+// Implementations don't need to be safe for concurrent use, as they are only accessed by a single MessageStore.
+//
+// To retain messages across reboots, provide a storage backed by persistent memory.
+type MessageStorage interface {
+	// Put stores a message, replacing any existing message with the same ID.
+	Put(message MessageInfo) error
+	// Remove deletes the message with the given ID. Returns false if no such message exists.
+	Remove(id int) (bool, error)
+	// All returns all stored messages.
+	All() ([]MessageInfo, error)
+}
+
+type memoryMessageStorage struct {
+	messages map[int]MessageInfo
+}
+
+// NewMemoryMessageStorage creates a non-persistent MessageStorage, keeping all messages in memory.
+func NewMemoryMessageStorage() MessageStorage {
+	return &memoryMessageStorage{messages: map[int]MessageInfo{}}
+}
+
+func (s *memoryMessageStorage) Put(message MessageInfo) error {
+	s.messages[message.ID] = message
+	return nil
+}
+
+func (s *memoryMessageStorage) Remove(id int) (bool, error) {
+	_, ok := s.messages[id]
+	delete(s.messages, id)
+	return ok, nil
+}
+
+func (s *memoryMessageStorage) All() ([]MessageInfo, error) {
+	messages := make([]MessageInfo, 0, len(s.messages))
+	for _, message := range s.messages {
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// MessageStore implements the ChargingStationHandler interface on top of a MessageStorage.
+// It may therefore be registered directly:
+//
+//	chargingStation.SetDisplayHandler(store)
+//
+// Messages with the same ID replace each other. Messages bound to a transaction are only accepted
+// if the IsTransactionActive callback reports the transaction as active, and should be removed via OnTransactionEnded.
+// Messages are removed once their endDateTime passed, either by the expiry loop (see Start) or by invoking RemoveExpired.
+//
+// Whenever the set of stored messages changes, the OnMessagesChanged callback is invoked,
+// allowing the application to update the physical display.
+//
+// A MessageStore is safe for concurrent use.
+type MessageStore struct {
+	// Sends a NotifyDisplayMessagesRequest to the CSMS. Typically invokes NotifyDisplayMessages on the charging station.
+	SendMessages func(request *NotifyDisplayMessagesRequest) error
+	// Invoked if the messages requested via GetDisplayMessages couldn't be sent. Optional.
+	OnSendError func(requestID int, err error)
+	// Reports whether a transaction is currently active. If nil, all transaction-bound messages are rejected.
+	IsTransactionActive func(transactionID string) bool
+	// Invoked with all stored messages, whenever a message was added or removed. Optional.
+	OnMessagesChanged func(messages []MessageInfo)
+	// The maximum amount of messages per NotifyDisplayMessagesRequest. Zero means no limit.
+	ItemsPerMessage int
+	storage         MessageStorage
+	clock           clock.Clock
+	stopC           chan struct{}
+	mutex           sync.Mutex
+}
+
+// NewMessageStore creates a new store backed by the passed storage. If storage is nil, messages are kept in memory.
+func NewMessageStore(storage MessageStorage, sendMessages func(request *NotifyDisplayMessagesRequest) error) *MessageStore {
+	if storage == nil {
+		storage = NewMemoryMessageStorage()
+	}
+	return &MessageStore{SendMessages: sendMessages, storage: storage, clock: clock.New()}
+}
+
+// SetTimeSource replaces the function used for retrieving the current time, while timers are backed by the real clock.
+//
+// Deprecated: Use SetClock instead.
+func (s *MessageStore) SetTimeSource(now func() time.Time) {
+	s.SetClock(clock.WithNow(now))
+}
+
+// SetClock sets the clock used for retrieving the current time and for running the expiry loop.
+// Passing nil restores the real clock. The clock must be set before starting the store.
+func (s *MessageStore) SetClock(c clock.Clock) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.clock = clock.OrDefault(c)
+}
+
+// Start runs the expiry loop in the background, removing expired messages every interval.
+// If interval is zero, DefaultMessageExpiryInterval is used. Calling Start on a running store has no effect.
+func (s *MessageStore) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultMessageExpiryInterval
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.stopC != nil {
+		return
+	}
+	stopC := make(chan struct{})
+	s.stopC = stopC
+	ticker := s.clock.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C():
+				_, _ = s.RemoveExpired()
+			case <-stopC:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the expiry loop.
+func (s *MessageStore) Stop() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.stopC != nil {
+		close(s.stopC)
+		s.stopC = nil
+	}
+}
+
+// Messages returns all stored messages, ordered by ID.
+func (s *MessageStore) Messages() ([]MessageInfo, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.sortedMessages()
+}
+
+// RemoveExpired removes all messages, whose endDateTime passed, and returns them.
+func (s *MessageStore) RemoveExpired() ([]MessageInfo, error) {
+	now := s.currentTime()
+	return s.removeWhere(func(message MessageInfo) bool {
+		return message.EndDateTime != nil && !message.EndDateTime.After(now)
+	})
+}
+
+// OnTransactionEnded removes all messages bound to the transaction, and returns them.
+func (s *MessageStore) OnTransactionEnded(transactionID string) ([]MessageInfo, error) {
+	return s.removeWhere(func(message MessageInfo) bool {
+		return message.TransactionID == transactionID
+	})
+}
+
+func (s *MessageStore) OnSetDisplayMessage(request *SetDisplayMessageRequest) (*SetDisplayMessageResponse, error) {
+	message := request.Message
+	if message.TransactionID != "" && (s.IsTransactionActive == nil || !s.IsTransactionActive(message.TransactionID)) {
+		return NewSetDisplayMessageResponse(DisplayMessageStatusUnknownTransaction), nil
+	}
+	s.mutex.Lock()
+	err := s.storage.Put(message)
+	if err != nil {
+		s.mutex.Unlock()
+		return nil, err
+	}
+	messages, _ := s.sortedMessages()
+	s.mutex.Unlock()
+	s.messagesChanged(messages)
+	return NewSetDisplayMessageResponse(DisplayMessageStatusAccepted), nil
+}
+
+// OnGetDisplayMessages returns all messages matching every criterion of the request: one of the requested IDs,
+// the requested priority and the requested state. Omitted criteria match all messages.
+// If at least one message matches, the messages are sent asynchronously via SendMessages.
+func (s *MessageStore) OnGetDisplayMessages(request *GetDisplayMessagesRequest) (*GetDisplayMessagesResponse, error) {
+	// Expired messages must not be reported
+	if _, err := s.RemoveExpired(); err != nil {
+		return nil, err
+	}
+	s.mutex.Lock()
+	messages, err := s.sortedMessages()
+	s.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	var matching []MessageInfo
+	for _, message := range messages {
+		if matchesMessageFilter(request, message) {
+			matching = append(matching, message)
+		}
+	}
+	if len(matching) == 0 || s.SendMessages == nil {
+		return NewGetDisplayMessagesResponse(MessageStatusUnknown), nil
+	}
+	parts := SplitDisplayMessages(request.RequestID, matching, s.ItemsPerMessage)
+	go func() {
+		for _, part := range parts {
+			if err := s.SendMessages(part); err != nil {
+				if s.OnSendError != nil {
+					s.OnSendError(request.RequestID, err)
+				}
+				return
+			}
+		}
+	}()
+	return NewGetDisplayMessagesResponse(MessageStatusAccepted), nil
+}
+
+func (s *MessageStore) OnClearDisplay(request *ClearDisplayRequest) (*ClearDisplayResponse, error) {
+	s.mutex.Lock()
+	removed, err := s.storage.Remove(request.ID)
+	if err != nil {
+		s.mutex.Unlock()
+		return nil, err
+	}
+	if !removed {
+		s.mutex.Unlock()
+		return NewClearDisplayResponse(ClearMessageStatusUnknown), nil
+	}
+	messages, _ := s.sortedMessages()
+	s.mutex.Unlock()
+	s.messagesChanged(messages)
+	return NewClearDisplayResponse(ClearMessageStatusAccepted), nil
+}
+
+func (s *MessageStore) currentTime() time.Time {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.clock.Now()
+}
+
+func (s *MessageStore) removeWhere(predicate func(message MessageInfo) bool) ([]MessageInfo, error) {
+	s.mutex.Lock()
+	messages, err := s.sortedMessages()
+	if err != nil {
+		s.mutex.Unlock()
+		return nil, err
+	}
+	var removed []MessageInfo
+	for _, message := range messages {
+		if !predicate(message) {
+			continue
+		}
+		if _, err = s.storage.Remove(message.ID); err != nil {
+			break
+		}
+		removed = append(removed, message)
+	}
+	if len(removed) > 0 {
+		messages, _ = s.sortedMessages()
+	}
+	s.mutex.Unlock()
+	if len(removed) > 0 {
+		s.messagesChanged(messages)
+	}
+	return removed, err
+}
+
+// Must be invoked while holding the lock.
+func (s *MessageStore) sortedMessages() ([]MessageInfo, error) {
+	messages, err := s.storage.All()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(messages, func(i, j int) bool {
+		return messages[i].ID < messages[j].ID
+	})
+	return messages, nil
+}
+
+func (s *MessageStore) messagesChanged(messages []MessageInfo) {
+	if s.OnMessagesChanged != nil {
+		s.OnMessagesChanged(messages)
+	}
+}
+
+func matchesMessageFilter(request *GetDisplayMessagesRequest, message MessageInfo) bool {
+	if request.Priority != "" && request.Priority != message.Priority {
+		return false
+	}
+	if request.State != "" && request.State != message.State {
+		return false
+	}
+	if len(request.ID) == 0 {
+		return true
+	}
+	for _, id := range request.ID {
+		if id == message.ID {
+			return true
+		}
+	}
+	return false
+}
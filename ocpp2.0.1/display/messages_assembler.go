@@ -0,0 +1,248 @@
+package display
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/clock"
+)
+
+const (
+	// The default time after which incomplete display messages are delivered, if no further part was received.
+	DefaultDisplayMessagesInactivityTimeout = 60 * time.Second
+	// The default maximum amount of MessageInfo elements buffered per charging station, across all pending requests.
+	DefaultMaxDisplayMessagesPerStation = 1000
+	// The default maximum amount of concurrently pending requests per charging station.
+	DefaultMaxPendingDisplayMessagesPerStation = 8
+)
+
+// DisplayMessagesCompletionHandler may optionally be implemented by a CSMSHandler.
+// If so, the CSMS reassembles all NotifyDisplayMessagesRequest parts sent for the same requestId
+// and invokes OnDisplayMessagesComplete once the last part was received.
+// OnNotifyDisplayMessages is still invoked for every single part.
+type DisplayMessagesCompletionHandler interface {
+	// OnDisplayMessagesComplete is called on the CSMS once all display messages requested via a GetDisplayMessagesRequest were received.
+	OnDisplayMessagesComplete(chargingStationID string, requestID int, messages []MessageInfo)
+}
+
+// SplitDisplayMessages partitions the messages into NotifyDisplayMessagesRequest parts, containing at most itemsPerMessage messages each.
+// All parts except the last one have the tbc flag set. If itemsPerMessage is zero, a single part is returned.
+//
+// If there are no messages, nil is returned: in that case the charging station is expected to respond
+// to the GetDisplayMessagesRequest with status Unknown, without sending any notification.
+func SplitDisplayMessages(requestID int, messages []MessageInfo, itemsPerMessage int) []*NotifyDisplayMessagesRequest {
+	if len(messages) == 0 {
+		return nil
+	}
+	if itemsPerMessage <= 0 {
+		itemsPerMessage = len(messages)
+	}
+	var requests []*NotifyDisplayMessagesRequest
+	for start := 0; start < len(messages); start += itemsPerMessage {
+		end := start + itemsPerMessage
+		if end > len(messages) {
+			end = len(messages)
+		}
+		request := NewNotifyDisplayMessagesRequest(requestID)
+		request.MessageInfo = messages[start:end]
+		request.Tbc = end < len(messages)
+		requests = append(requests, request)
+	}
+	return requests
+}
+
+// PartialDisplayMessagesError is passed to the completion handler when display messages are incomplete,
+// because no further part was received before the inactivity timeout.
+type PartialDisplayMessagesError struct {
+	ChargingStationID string
+	RequestID         int
+	ReceivedParts     int
+}
+
+func (e *PartialDisplayMessagesError) Error() string {
+	return fmt.Sprintf("display messages %v from %v: incomplete after %v parts", e.RequestID, e.ChargingStationID, e.ReceivedParts)
+}
+
+// DisplayMessagesLimitError is returned when display messages would exceed the buffering limits of a charging station.
+type DisplayMessagesLimitError struct {
+	ChargingStationID string
+	RequestID         int
+	Reason            string
+}
+
+func (e *DisplayMessagesLimitError) Error() string {
+	return fmt.Sprintf("display messages %v from %v: %v", e.RequestID, e.ChargingStationID, e.Reason)
+}
+
+// MessagesCompletionHandler is invoked by a MessagesAssembler once all display messages for a request were received,
+// or the assembly was aborted. If err is not nil, messages contains the parts received so far.
+type MessagesCompletionHandler func(chargingStationID string, requestID int, messages []MessageInfo, err error)
+
+type messagesKey struct {
+	chargingStationID string
+	requestID         int
+}
+
+type pendingMessages struct {
+	parts    int
+	messages []MessageInfo
+	timer    clock.Timer
+}
+
+// MessagesAssembler reassembles display messages sent by charging stations as a sequence of NotifyDisplayMessagesRequest messages,
+// in response to a GetDisplayMessagesRequest.
+//
+// Each received NotifyDisplayMessagesRequest should be passed to Add. Parts are grouped by charging station and requestId.
+// Once the last part (tbc = false) is received, the completion handler is invoked with all messages in order.
+//
+// Since parts carry no sequence number, missing parts cannot be detected. Assembly is aborted,
+// and the completion handler invoked with an error, if:
+//
+// - no further part is received within the inactivity timeout (PartialDisplayMessagesError)
+//
+// - the buffering limits of the charging station are exceeded (DisplayMessagesLimitError)
+//
+// A MessagesAssembler is safe for concurrent use.
+type MessagesAssembler struct {
+	onComplete         MessagesCompletionHandler
+	timeout            time.Duration
+	maxMessages        int
+	maxPendingRequests int
+	pending            map[messagesKey]*pendingMessages
+	stationMessages    map[string]int
+	stationRequests    map[string]int
+	clock              clock.Clock
+	mutex              sync.Mutex
+}
+
+// NewMessagesAssembler creates a new assembler, invoking the passed handler for every completed or aborted request.
+// If timeout is zero, DefaultDisplayMessagesInactivityTimeout is used.
+func NewMessagesAssembler(timeout time.Duration, onComplete MessagesCompletionHandler) *MessagesAssembler {
+	if timeout <= 0 {
+		timeout = DefaultDisplayMessagesInactivityTimeout
+	}
+	return &MessagesAssembler{
+		onComplete:         onComplete,
+		timeout:            timeout,
+		maxMessages:        DefaultMaxDisplayMessagesPerStation,
+		maxPendingRequests: DefaultMaxPendingDisplayMessagesPerStation,
+		pending:            map[messagesKey]*pendingMessages{},
+		stationMessages:    map[string]int{},
+		stationRequests:    map[string]int{},
+		clock:              clock.New(),
+	}
+}
+
+// SetLimits configures the maximum amount of MessageInfo elements buffered per charging station,
+// and the maximum amount of concurrently pending requests per charging station.
+func (a *MessagesAssembler) SetLimits(maxMessages int, maxPendingRequests int) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.maxMessages = maxMessages
+	a.maxPendingRequests = maxPendingRequests
+}
+
+// SetClock sets the clock used for the inactivity timeout of pending requests. Passing nil restores the real clock.
+//
+// Timers already running are not affected.
+func (a *MessagesAssembler) SetClock(c clock.Clock) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.clock = clock.OrDefault(c)
+}
+
+// Add feeds a NotifyDisplayMessagesRequest received from a charging station to the assembler.
+//
+// If the part couldn't be added, the assembly is aborted and the error is returned as well as passed to the completion handler.
+func (a *MessagesAssembler) Add(chargingStationID string, request *NotifyDisplayMessagesRequest) error {
+	if request == nil {
+		return nil
+	}
+	key := messagesKey{chargingStationID: chargingStationID, requestID: request.RequestID}
+	a.mutex.Lock()
+	pending, ok := a.pending[key]
+	if !ok {
+		if a.stationRequests[chargingStationID] >= a.maxPendingRequests {
+			a.mutex.Unlock()
+			err := &DisplayMessagesLimitError{ChargingStationID: chargingStationID, RequestID: request.RequestID, Reason: "too many pending requests"}
+			a.complete(key, nil, err)
+			return err
+		}
+		pending = &pendingMessages{}
+		pending.timer = a.clock.AfterFunc(a.timeout, func() {
+			a.onTimeout(key, pending)
+		})
+		a.pending[key] = pending
+		a.stationRequests[chargingStationID]++
+	}
+	if a.stationMessages[chargingStationID]+len(request.MessageInfo) > a.maxMessages {
+		a.remove(key, pending)
+		a.mutex.Unlock()
+		err := &DisplayMessagesLimitError{ChargingStationID: chargingStationID, RequestID: request.RequestID, Reason: "too many buffered display messages"}
+		a.complete(key, pending.messages, err)
+		return err
+	}
+	pending.parts++
+	pending.messages = append(pending.messages, request.MessageInfo...)
+	a.stationMessages[chargingStationID] += len(request.MessageInfo)
+	if request.Tbc {
+		pending.timer.Reset(a.timeout)
+		a.mutex.Unlock()
+		return nil
+	}
+	a.remove(key, pending)
+	a.mutex.Unlock()
+	a.complete(key, pending.messages, nil)
+	return nil
+}
+
+// Discard drops all pending requests of a charging station, without invoking the completion handler.
+// This may be invoked when a charging station disconnects.
+func (a *MessagesAssembler) Discard(chargingStationID string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for key, pending := range a.pending {
+		if key.chargingStationID == chargingStationID {
+			a.remove(key, pending)
+		}
+	}
+}
+
+// PendingRequests returns the amount of incomplete requests currently buffered for a charging station.
+func (a *MessagesAssembler) PendingRequests(chargingStationID string) int {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.stationRequests[chargingStationID]
+}
+
+func (a *MessagesAssembler) onTimeout(key messagesKey, pending *pendingMessages) {
+	a.mutex.Lock()
+	if a.pending[key] != pending {
+		// Already completed
+		a.mutex.Unlock()
+		return
+	}
+	a.remove(key, pending)
+	a.mutex.Unlock()
+	err := &PartialDisplayMessagesError{ChargingStationID: key.chargingStationID, RequestID: key.requestID, ReceivedParts: pending.parts}
+	a.complete(key, pending.messages, err)
+}
+
+// Must be invoked while holding the lock.
+func (a *MessagesAssembler) remove(key messagesKey, pending *pendingMessages) {
+	pending.timer.Stop()
+	delete(a.pending, key)
+	a.stationMessages[key.chargingStationID] -= len(pending.messages)
+	a.stationRequests[key.chargingStationID]--
+	if a.stationRequests[key.chargingStationID] <= 0 {
+		delete(a.stationRequests, key.chargingStationID)
+		delete(a.stationMessages, key.chargingStationID)
+	}
+}
+
+func (a *MessagesAssembler) complete(key messagesKey, messages []MessageInfo, err error) {
+	if a.onComplete != nil {
+		a.onComplete(key.chargingStationID, key.requestID, messages, err)
+	}
+}
@@ -1,6 +1,7 @@
 package display
 
 import (
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
 	"reflect"
 )
 
@@ -10,14 +11,16 @@ const NotifyDisplayMessagesFeatureName = "NotifyDisplayMessages"
 
 // The field definition of the NotifyDisplayMessages request payload sent by the CSMS to the Charging Station.
 type NotifyDisplayMessagesRequest struct {
-	RequestID   int           `json:"requestId" validate:"gte=0"`                      // The id of the GetDisplayMessagesRequest that requested this message.
-	Tbc         bool          `json:"tbc,omitempty" validate:"omitempty"`              // "to be continued" indicator. Indicates whether another part of the report follows in an upcoming NotifyDisplayMessagesRequest message. Default value when omitted is false.
-	MessageInfo []MessageInfo `json:"messageInfo,omitempty" validate:"omitempty,dive"` // The requested display message as configured in the Charging Station.
+	RequestID   int               `json:"requestId" validate:"gte=0"`                      // The id of the GetDisplayMessagesRequest that requested this message.
+	Tbc         bool              `json:"tbc,omitempty" validate:"omitempty"`              // "to be continued" indicator. Indicates whether another part of the report follows in an upcoming NotifyDisplayMessagesRequest message. Default value when omitted is false.
+	MessageInfo []MessageInfo     `json:"messageInfo,omitempty" validate:"omitempty,dive"` // The requested display message as configured in the Charging Station.
+	CustomData  *types.CustomData `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // This field definition of the NotifyDisplayMessages response payload, sent by the Charging Station to the CSMS in response to a NotifyDisplayMessagesRequest.
 // In case the request was invalid, or couldn't be processed, an error will be sent instead.
 type NotifyDisplayMessagesResponse struct {
+	CustomData *types.CustomData `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // A CSO MAY request all the installed DisplayMessages configured via OCPP in a Charging Station. For this the CSO asks the CSMS to retrieve all messages (see GetDisplayMessagesFeature).
@@ -68,10 +68,12 @@ type MessageInfo struct {
 	TransactionID string               `json:"transactionId,omitempty" validate:"omitempty,max=36"` // During which transaction shall this message be shown. Message SHALL be removed by the Charging Station after transaction has ended.
 	Message       types.MessageContent `json:"message" validate:"required"`                         // Contains message details for the message to be displayed on a Charging Station.
 	Display       *types.Component     `json:"display,omitempty" validate:"omitempty"`              // When a Charging Station has multiple Displays, this field can be used to define to which Display this message belongs.
+	CustomData    *types.CustomData    `json:"customData,omitempty" validate:"omitempty"`
 }
 
 func init() {
-	_ = types.Validate.RegisterValidation("messagePriority", isValidMessagePriority)
-	_ = types.Validate.RegisterValidation("messageState", isValidMessageState)
-	_ = types.Validate.RegisterValidation("messageStatus", isValidMessageStatus)
+	_ = types.RegisterValidation("messagePriority", isValidMessagePriority)
+	_ = types.RegisterValidation("messageState", isValidMessageState)
+	_ = types.RegisterValidation("messageStatus", isValidMessageStatus)
+	types.RegisterStructValidation(validateMessageInfo, MessageInfo{})
 }
@@ -0,0 +1,136 @@
+package display
+
+import (
+	"net/url"
+	"strings"
+
+	"gopkg.in/go-playground/validator.v9"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// IsValidLanguageTag checks whether tag is syntactically valid according to the langtag grammar of RFC 5646 (BCP 47).
+// Private use tags (e.g. "x-custom") are accepted as well, while irregular grandfathered tags are not.
+//
+// Only the syntax is verified: subtags are not checked against the IANA language subtag registry.
+func IsValidLanguageTag(tag string) bool {
+	if tag == "" {
+		return false
+	}
+	subtags := strings.Split(strings.ToLower(tag), "-")
+	i := 0
+	if subtags[0] == "x" {
+		return isValidPrivateUse(subtags)
+	}
+	// language
+	language := subtags[0]
+	if !isAlpha(language) || len(language) < 2 || len(language) > 8 {
+		return false
+	}
+	i++
+	// extlang: up to 3 subtags, only after a 2-3 letter language
+	if len(language) <= 3 {
+		for n := 0; n < 3 && i < len(subtags) && len(subtags[i]) == 3 && isAlpha(subtags[i]); n++ {
+			i++
+		}
+	}
+	// script
+	if i < len(subtags) && len(subtags[i]) == 4 && isAlpha(subtags[i]) {
+		i++
+	}
+	// region
+	if i < len(subtags) && ((len(subtags[i]) == 2 && isAlpha(subtags[i])) || (len(subtags[i]) == 3 && isDigit(subtags[i]))) {
+		i++
+	}
+	// variants
+	for i < len(subtags) && isVariant(subtags[i]) {
+		i++
+	}
+	// extensions
+	for i < len(subtags) && len(subtags[i]) == 1 && subtags[i] != "x" && isAlphanum(subtags[i]) {
+		i++
+		start := i
+		for i < len(subtags) && len(subtags[i]) >= 2 && len(subtags[i]) <= 8 && isAlphanum(subtags[i]) {
+			i++
+		}
+		if i == start {
+			return false
+		}
+	}
+	// private use
+	if i < len(subtags) && subtags[i] == "x" {
+		return isValidPrivateUse(subtags[i:])
+	}
+	return i == len(subtags)
+}
+
+func isValidPrivateUse(subtags []string) bool {
+	if len(subtags) < 2 {
+		return false
+	}
+	for _, subtag := range subtags[1:] {
+		if len(subtag) < 1 || len(subtag) > 8 || !isAlphanum(subtag) {
+			return false
+		}
+	}
+	return true
+}
+
+func isVariant(subtag string) bool {
+	if !isAlphanum(subtag) {
+		return false
+	}
+	return (len(subtag) >= 5 && len(subtag) <= 8) || (len(subtag) == 4 && isDigit(subtag[:1]))
+}
+
+func isAlpha(s string) bool {
+	for _, c := range s {
+		if c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isDigit(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+func isAlphanum(s string) bool {
+	for _, c := range s {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// Validates the message content according to its format, as well as the display time window.
+func validateMessageInfo(sl validator.StructLevel) {
+	info := sl.Current().Interface().(MessageInfo)
+	content := info.Message
+	if content.Language != "" && !IsValidLanguageTag(content.Language) {
+		sl.ReportError(content.Language, "Language", "language", "languageTag", "")
+	}
+	switch content.Format {
+	case types.MessageFormatASCII:
+		for i := 0; i < len(content.Content); i++ {
+			if content.Content[i] > 127 {
+				sl.ReportError(content.Content, "Content", "content", "ascii", "")
+				break
+			}
+		}
+	case types.MessageFormatURI:
+		if uri, err := url.Parse(content.Content); err != nil || uri.Scheme == "" {
+			sl.ReportError(content.Content, "Content", "content", "uri", "")
+		}
+	}
+	if info.StartDateTime != nil && info.EndDateTime != nil && !info.EndDateTime.After(info.StartDateTime.Time) {
+		sl.ReportError(info.EndDateTime, "EndDateTime", "endDateTime", "gtfield", "StartDateTime")
+	}
+}
@@ -1,7 +1,11 @@
 // The firmware functional block contains OCPP 2.0 features that enable firmware updates on a charging station.
 package firmware
 
-import "github.com/lorenzodonini/ocpp-go/ocpp"
+import (
+	"context"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+)
 
 // Needs to be implemented by a CSMS for handling messages part of the OCPP 2.0 Firmware profile.
 type CSMSHandler interface {
@@ -11,6 +15,35 @@ type CSMSHandler interface {
 	OnPublishFirmwareStatusNotification(chargingStationID string, request *PublishFirmwareStatusNotificationRequest) (response *PublishFirmwareStatusNotificationResponse, err error)
 }
 
+// Context-aware variant of CSMSHandler, for handling messages part of the OCPP 2.0 Firmware profile.
+type CSMSHandlerWithContext interface {
+	// OnFirmwareStatusNotification is called on the CSMS whenever a FirmwareStatusNotificationRequest is received from a charging station.
+	OnFirmwareStatusNotification(ctx context.Context, chargingStationID string, request *FirmwareStatusNotificationRequest) (response *FirmwareStatusNotificationResponse, err error)
+	// OnPublishFirmwareStatusNotification is called on the CSMS whenever a PublishFirmwareStatusNotificationRequest is received from a local controller.
+	OnPublishFirmwareStatusNotification(ctx context.Context, chargingStationID string, request *PublishFirmwareStatusNotificationRequest) (response *PublishFirmwareStatusNotificationResponse, err error)
+}
+
+// AdaptCSMSHandler converts a CSMSHandler into a CSMSHandlerWithContext, which ignores the context.
+// Returns nil if handler is nil.
+func AdaptCSMSHandler(handler CSMSHandler) CSMSHandlerWithContext {
+	if handler == nil {
+		return nil
+	}
+	return csmsHandlerAdapter{handler: handler}
+}
+
+type csmsHandlerAdapter struct {
+	handler CSMSHandler
+}
+
+func (a csmsHandlerAdapter) OnFirmwareStatusNotification(ctx context.Context, chargingStationID string, request *FirmwareStatusNotificationRequest) (*FirmwareStatusNotificationResponse, error) {
+	return a.handler.OnFirmwareStatusNotification(chargingStationID, request)
+}
+
+func (a csmsHandlerAdapter) OnPublishFirmwareStatusNotification(ctx context.Context, chargingStationID string, request *PublishFirmwareStatusNotificationRequest) (*PublishFirmwareStatusNotificationResponse, error) {
+	return a.handler.OnPublishFirmwareStatusNotification(chargingStationID, request)
+}
+
 // Needs to be implemented by Charging stations for handling messages part of the OCPP 2.0 Firmware profile.
 type ChargingStationHandler interface {
 	// OnPublishFirmware is called on a charging station whenever a PublishFirmwareRequest is received from the CSMS.
@@ -0,0 +1,253 @@
+package firmware
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/clock"
+	"github.com/lorenzodonini/ocpp-go/internal/retry"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+type publishedFirmware struct {
+	filename string
+	content  []byte
+	modified time.Time
+}
+
+type firmwarePublication struct {
+	requestID int
+	checksum  string
+	status    PublishFirmwareStatus
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// PublishManager implements the firmware publishing flow of a local controller.
+//
+// OnPublishFirmware and OnUnpublishFirmware match the respective ChargingStationHandler methods,
+// so a firmware handler may delegate to them directly.
+// The firmware is downloaded in the background, using the downloader registered for its URL scheme, and its MD5 checksum is verified.
+// Progress is reported via PublishFirmwareStatusNotification messages: Downloading (preceded by DownloadScheduled before each retry),
+// followed by Downloaded or DownloadFailed, then ChecksumVerified or InvalidChecksum, and finally Published.
+//
+// Published firmware files are served by the manager itself, which is an http.Handler: the handler must be
+// reachable by the downstream charging stations under each of the BaseURLs. Files are served at <checksum>/<filename>,
+// relative to the base URL, and the resulting URIs are reported to the CSMS with the Published status.
+//
+// Only one download is performed at a time: a new PublishFirmwareRequest cancels the ongoing download,
+// in which case no further status is reported for the canceled download. Multiple firmware files may be published at once.
+//
+// A PublishManager is safe for concurrent use.
+type PublishManager struct {
+	// Sends a PublishFirmwareStatusNotificationRequest to the CSMS. Typically invokes PublishFirmwareStatusNotification on the local controller.
+	SendStatus func(request *PublishFirmwareStatusNotificationRequest) error
+	// Invoked if a status notification couldn't be sent. Optional.
+	OnSendError func(requestID int, err error)
+	// The downloaders per URL scheme. By default, HTTP, HTTPS and FTP are supported.
+	Downloaders map[string]FirmwareDownloader
+	// The base URLs under which the manager is served to downstream charging stations, e.g. "http://192.168.1.10:8080/firmware/".
+	// If empty, all requests are rejected.
+	BaseURLs  []string
+	current   *firmwarePublication
+	published map[string]*publishedFirmware
+	clock     clock.Clock
+	mutex     sync.Mutex
+}
+
+// NewPublishManager creates a new manager with the default downloaders.
+func NewPublishManager(baseURLs []string, sendStatus func(request *PublishFirmwareStatusNotificationRequest) error) *PublishManager {
+	httpDownloader := &HTTPDownloader{}
+	return &PublishManager{
+		SendStatus: sendStatus,
+		BaseURLs:   baseURLs,
+		Downloaders: map[string]FirmwareDownloader{
+			"http":  httpDownloader,
+			"https": httpDownloader,
+			"ftp":   &FTPDownloader{},
+		},
+		published: map[string]*publishedFirmware{},
+		clock:     clock.New(),
+	}
+}
+
+// SetClock sets the clock used for waiting between download attempts and for timestamping published files.
+// Passing nil restores the real clock. Downloads, which are already ongoing, are not affected.
+func (m *PublishManager) SetClock(c clock.Clock) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.clock = clock.OrDefault(c)
+}
+
+// CurrentStatus returns the requestId and status of the ongoing or last publish request.
+// If no firmware was published yet, Idle is returned.
+// This is useful for answering a TriggerMessageRequest for PublishFirmwareStatusNotification.
+func (m *PublishManager) CurrentStatus() (requestID int, status PublishFirmwareStatus) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.current == nil {
+		return 0, PublishFirmwareStatusIdle
+	}
+	return m.current.requestID, m.current.status
+}
+
+func (m *PublishManager) OnPublishFirmware(request *PublishFirmwareRequest) (*PublishFirmwareResponse, error) {
+	location, err := url.Parse(request.Location)
+	if err != nil {
+		return NewPublishFirmwareResponse(types.GenericStatusRejected), nil
+	}
+	downloader, ok := m.Downloaders[location.Scheme]
+	if !ok || len(m.BaseURLs) == 0 {
+		return NewPublishFirmwareResponse(types.GenericStatusRejected), nil
+	}
+	if _, err = hex.DecodeString(request.Checksum); err != nil || len(request.Checksum) != 2*md5.Size {
+		return NewPublishFirmwareResponse(types.GenericStatusRejected), nil
+	}
+	m.mutex.Lock()
+	previous := m.current
+	if previous != nil {
+		previous.cancel()
+	}
+	clk := clock.OrDefault(m.clock)
+	ctx, cancel := context.WithCancel(context.Background())
+	publication := &firmwarePublication{requestID: request.RequestID, checksum: strings.ToLower(request.Checksum), status: PublishFirmwareStatusIdle, cancel: cancel, done: make(chan struct{})}
+	m.current = publication
+	m.mutex.Unlock()
+	go func() {
+		if previous != nil {
+			<-previous.done
+		}
+		m.publish(ctx, clk, publication, downloader, location, request)
+	}()
+	return NewPublishFirmwareResponse(types.GenericStatusAccepted), nil
+}
+
+func (m *PublishManager) OnUnpublishFirmware(request *UnpublishFirmwareRequest) (*UnpublishFirmwareResponse, error) {
+	checksum := strings.ToLower(request.Checksum)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.current != nil && m.current.checksum == checksum {
+		select {
+		case <-m.current.done:
+		default:
+			return NewUnpublishFirmwareResponse(UnpublishFirmwareStatusDownloadOngoing), nil
+		}
+	}
+	if _, ok := m.published[checksum]; !ok {
+		return NewUnpublishFirmwareResponse(UnpublishFirmwareStatusNoFirmware), nil
+	}
+	delete(m.published, checksum)
+	if m.current != nil && m.current.checksum == checksum {
+		m.current.status = PublishFirmwareStatusIdle
+	}
+	return NewUnpublishFirmwareResponse(UnpublishFirmwareStatusUnpublished), nil
+}
+
+// ServeHTTP serves the published firmware files at <checksum>/<filename>.
+func (m *PublishManager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	dir, filename := path.Split(r.URL.Path)
+	checksum := strings.ToLower(path.Base(dir))
+	m.mutex.Lock()
+	published, ok := m.published[checksum]
+	m.mutex.Unlock()
+	if !ok || published.filename != filename {
+		http.NotFound(w, r)
+		return
+	}
+	http.ServeContent(w, r, filename, published.modified, bytes.NewReader(published.content))
+}
+
+func (m *PublishManager) publish(ctx context.Context, clk clock.Clock, publication *firmwarePublication, downloader FirmwareDownloader, location *url.URL, request *PublishFirmwareRequest) {
+	defer close(publication.done)
+	defer publication.cancel()
+	content, ok := m.download(ctx, clk, publication, downloader, location, request)
+	if ctx.Err() != nil {
+		return
+	}
+	if !ok {
+		m.setStatus(ctx, publication, PublishFirmwareStatusDownloadFailed, nil)
+		return
+	}
+	if !m.setStatus(ctx, publication, PublishFirmwareStatusDownloaded, nil) {
+		return
+	}
+	sum := md5.Sum(content)
+	if hex.EncodeToString(sum[:]) != publication.checksum {
+		m.setStatus(ctx, publication, PublishFirmwareStatusInvalidChecksum, nil)
+		return
+	}
+	if !m.setStatus(ctx, publication, PublishFirmwareStatusChecksumVerified, nil) {
+		return
+	}
+	filename := path.Base(location.Path)
+	if filename == "." || filename == "/" {
+		filename = "firmware"
+	}
+	locations := make([]string, 0, len(m.BaseURLs))
+	for _, baseURL := range m.BaseURLs {
+		locations = append(locations, strings.TrimSuffix(baseURL, "/")+"/"+publication.checksum+"/"+url.PathEscape(filename))
+	}
+	m.mutex.Lock()
+	if ctx.Err() != nil {
+		m.mutex.Unlock()
+		return
+	}
+	m.published[publication.checksum] = &publishedFirmware{filename: filename, content: content, modified: clk.Now()}
+	m.mutex.Unlock()
+	m.setStatus(ctx, publication, PublishFirmwareStatusPublished, locations)
+}
+
+// Downloads the firmware file, retrying as specified by the request. Returns false if all attempts failed or the download was canceled.
+func (m *PublishManager) download(ctx context.Context, clk clock.Clock, publication *firmwarePublication, downloader FirmwareDownloader, location *url.URL, request *PublishFirmwareRequest) ([]byte, bool) {
+	policy := retry.FromRequest(request.Retries, request.RetryInterval, clk)
+	var content []byte
+	var downloaded bool
+	completed := policy.Run(ctx, func(n int) bool {
+		if !m.setStatus(ctx, publication, PublishFirmwareStatusDownloading, nil) {
+			return true
+		}
+		var err error
+		content, err = downloader.Download(ctx, location)
+		downloaded = err == nil
+		if !downloaded && n < policy.Attempts-1 {
+			// The next attempt is scheduled after the retry interval
+			return !m.setStatus(ctx, publication, PublishFirmwareStatusDownloadScheduled, nil)
+		}
+		return downloaded
+	})
+	return content, completed && downloaded
+}
+
+// Updates the status of a publish request and notifies the CSMS, unless the request was canceled.
+func (m *PublishManager) setStatus(ctx context.Context, publication *firmwarePublication, status PublishFirmwareStatus, locations []string) bool {
+	m.mutex.Lock()
+	if ctx.Err() != nil {
+		m.mutex.Unlock()
+		return false
+	}
+	publication.status = status
+	m.mutex.Unlock()
+	if m.SendStatus == nil {
+		return true
+	}
+	request := NewPublishFirmwareStatusNotificationRequest(status)
+	requestID := publication.requestID
+	request.RequestID = &requestID
+	request.Location = locations
+	if err := m.SendStatus(request); err != nil && m.OnSendError != nil {
+		m.OnSendError(publication.requestID, err)
+	}
+	return true
+}
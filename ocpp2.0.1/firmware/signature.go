@@ -0,0 +1,100 @@
+package firmware
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Errors returned by VerifySignature.
+var (
+	ErrMissingSignature          = errors.New("firmware signature or signing certificate missing")
+	ErrInvalidSigningCertificate = errors.New("invalid firmware signing certificate")
+	ErrInvalidSignature          = errors.New("invalid firmware signature")
+)
+
+// VerifySigningCertificate parses the PEM encoded signing certificate of a firmware, and verifies it against the manufacturer roots.
+// Additional certificates following the first one are used as intermediates.
+// On success, the parsed signing certificate is returned. Otherwise, the returned error wraps ErrInvalidSigningCertificate.
+func VerifySigningCertificate(signingCertificate string, manufacturerRoots *x509.CertPool) (*x509.Certificate, error) {
+	var chain []*x509.Certificate
+	rest := []byte(signingCertificate)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSigningCertificate, err)
+		}
+		chain = append(chain, certificate)
+	}
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("%w: no PEM encoded certificate found", ErrInvalidSigningCertificate)
+	}
+	intermediates := x509.NewCertPool()
+	for _, certificate := range chain[1:] {
+		intermediates.AddCert(certificate)
+	}
+	options := x509.VerifyOptions{
+		Roots:         manufacturerRoots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	if _, err := chain[0].Verify(options); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSigningCertificate, err)
+	}
+	return chain[0], nil
+}
+
+// VerifySignature verifies a downloaded firmware image, as required by the secure firmware update procedure.
+//
+// The signing certificate of the firmware must chain up to one of the manufacturer roots,
+// and the signature must be a valid RSA or ECDSA signature of the SHA-256 digest of the image, created with the certificate's key.
+//
+// The returned error wraps ErrMissingSignature, ErrInvalidSigningCertificate or ErrInvalidSignature,
+// unless the image couldn't be read.
+func VerifySignature(firmware Firmware, downloaded io.Reader, manufacturerRoots *x509.CertPool) error {
+	if firmware.Signature == "" || firmware.SigningCertificate == "" {
+		return ErrMissingSignature
+	}
+	certificate, err := VerifySigningCertificate(firmware.SigningCertificate, manufacturerRoots)
+	if err != nil {
+		return err
+	}
+	signature, err := base64.StdEncoding.DecodeString(firmware.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+	hash := sha256.New()
+	if _, err = io.Copy(hash, downloaded); err != nil {
+		return err
+	}
+	digest := hash.Sum(nil)
+	switch publicKey := certificate.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(publicKey, digest, signature) {
+			return ErrInvalidSignature
+		}
+	case *rsa.PublicKey:
+		if rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest, signature) != nil &&
+			rsa.VerifyPSS(publicKey, crypto.SHA256, digest, signature, nil) != nil {
+			return ErrInvalidSignature
+		}
+	default:
+		return fmt.Errorf("%w: unsupported public key type %T", ErrInvalidSigningCertificate, publicKey)
+	}
+	return nil
+}
@@ -33,13 +33,15 @@ func isValidUnpublishFirmwareStatus(fl validator.FieldLevel) bool {
 
 // The field definition of the UnpublishFirmware request payload sent by the CSMS to the Charging Station.
 type UnpublishFirmwareRequest struct {
-	Checksum string `json:"checksum" validate:"required,max=32"` // The MD5 checksum over the entire firmware file as a hexadecimal string of length 32.
+	Checksum   string            `json:"checksum" validate:"required,max=32"` // The MD5 checksum over the entire firmware file as a hexadecimal string of length 32.
+	CustomData *types.CustomData `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // This field definition of the UnpublishFirmware response payload, sent by the Charging Station to the CSMS in response to a UnpublishFirmwareRequest.
 // In case the request was invalid, or couldn't be processed, an error will be sent instead.
 type UnpublishFirmwareResponse struct {
-	Status UnpublishFirmwareStatus `json:"status" validate:"required,unpublishFirmwareStatus"`
+	Status     UnpublishFirmwareStatus `json:"status" validate:"required,unpublishFirmwareStatus"`
+	CustomData *types.CustomData       `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // Allows to stop a Local Controller from publishing a firmware update to connected Charging Stations.
@@ -78,5 +80,5 @@ func NewUnpublishFirmwareResponse(status UnpublishFirmwareStatus) *UnpublishFirm
 }
 
 func init() {
-	_ = types.Validate.RegisterValidation("unpublishFirmwareStatus", isValidUnpublishFirmwareStatus)
+	_ = types.RegisterValidation("unpublishFirmwareStatus", isValidUnpublishFirmwareStatus)
 }
@@ -0,0 +1,301 @@
+package firmware
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/clock"
+	"github.com/lorenzodonini/ocpp-go/internal/ftp"
+	"github.com/lorenzodonini/ocpp-go/internal/retry"
+)
+
+// FirmwareDownloader downloads a firmware image from a remote location. The download must be aborted once ctx is canceled.
+// Failed downloads are retried, as specified by the UpdateFirmwareRequest.
+type FirmwareDownloader interface {
+	Download(ctx context.Context, location *url.URL) ([]byte, error)
+}
+
+// FirmwareInstaller installs a downloaded and verified firmware image. If the installation requires a reboot,
+// reboot must be true: the reboot itself is left to the caller, once the InstallRebooting status was sent.
+type FirmwareInstaller func(ctx context.Context, image []byte) (reboot bool, err error)
+
+// HTTPDownloader downloads firmware images via an HTTP GET request.
+// Credentials contained in the URL are sent via basic authentication.
+type HTTPDownloader struct {
+	// The client used for the download. If nil, http.DefaultClient is used.
+	Client *http.Client
+}
+
+func (d *HTTPDownloader) Download(ctx context.Context, location *url.URL) ([]byte, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, location.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if location.User != nil {
+		password, _ := location.User.Password()
+		request.SetBasicAuth(location.User.Username(), password)
+	}
+	client := d.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		_, _ = io.Copy(io.Discard, response.Body)
+		return nil, fmt.Errorf("download failed: %v", response.Status)
+	}
+	return io.ReadAll(response.Body)
+}
+
+// FTPDownloader downloads firmware images via FTP in passive mode. If the URL contains no credentials, an anonymous login is performed.
+type FTPDownloader struct {
+	// The timeout for establishing connections. Zero means no timeout.
+	Timeout time.Duration
+}
+
+func (d *FTPDownloader) Download(ctx context.Context, location *url.URL) ([]byte, error) {
+	conn, err := ftp.Dial(ctx, location, d.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.Retrieve(location.Path)
+}
+
+type firmwareUpdate struct {
+	requestID  int
+	status     FirmwareStatus
+	installing bool
+	cancel     context.CancelFunc
+	done       chan struct{}
+}
+
+// UpdateManager201 implements the firmware update flow on the charging station side.
+//
+// OnUpdateFirmware matches the respective ChargingStationHandler method, so a firmware handler may delegate to it directly.
+// The firmware is downloaded in the background once its retrieveDateTime is reached, using the downloader registered for its URL scheme.
+// If manufacturer roots are configured, the downloaded image is verified via VerifySignature, before being installed
+// once its installDateTime is reached. Progress is reported via FirmwareStatusNotification messages:
+//
+//   - DownloadScheduled (only if the download is delayed), Downloading, followed by Downloaded or DownloadFailed
+//   - SignatureVerified or InvalidSignature (only if manufacturer roots are configured)
+//   - InstallScheduled (only if the installation is delayed), Installing, followed by Installed, InstallRebooting or InstallationFailed
+//
+// Only one update is performed at a time: a new UpdateFirmwareRequest cancels the ongoing update, in which case the request
+// is answered with AcceptedCanceled and no further status is reported for the canceled update.
+// Once the installation started, new requests are rejected instead.
+//
+// An UpdateManager201 is safe for concurrent use.
+type UpdateManager201 struct {
+	// Installs the verified firmware image. If nil, all requests are rejected.
+	Install FirmwareInstaller
+	// Sends a FirmwareStatusNotificationRequest to the CSMS. Typically invokes FirmwareStatusNotification on the charging station.
+	SendStatus func(request *FirmwareStatusNotificationRequest) error
+	// Invoked if a status notification couldn't be sent. Optional.
+	OnSendError func(requestID int, err error)
+	// The downloaders per URL scheme. By default, HTTP, HTTPS and FTP are supported.
+	Downloaders map[string]FirmwareDownloader
+	// The trusted manufacturer root certificates. If nil, firmware signatures aren't verified.
+	ManufacturerRoots *x509.CertPool
+	current           *firmwareUpdate
+	clock             clock.Clock
+	mutex             sync.Mutex
+}
+
+// NewUpdateManager201 creates a new manager with the default downloaders.
+func NewUpdateManager201(install FirmwareInstaller, manufacturerRoots *x509.CertPool, sendStatus func(request *FirmwareStatusNotificationRequest) error) *UpdateManager201 {
+	httpDownloader := &HTTPDownloader{}
+	return &UpdateManager201{
+		Install:           install,
+		SendStatus:        sendStatus,
+		ManufacturerRoots: manufacturerRoots,
+		clock:             clock.New(),
+		Downloaders: map[string]FirmwareDownloader{
+			"http":  httpDownloader,
+			"https": httpDownloader,
+			"ftp":   &FTPDownloader{},
+		},
+	}
+}
+
+// SetClock sets the clock used for scheduling downloads and installations, and for waiting between download attempts.
+// Passing nil restores the real clock. Updates, which are already ongoing, are not affected.
+func (m *UpdateManager201) SetClock(c clock.Clock) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.clock = clock.OrDefault(c)
+}
+
+// CurrentStatus returns the requestId and status of the ongoing or last update.
+// If no update was requested yet, or the last update completed, Idle is returned.
+// This is useful for answering a TriggerMessageRequest for FirmwareStatusNotification.
+func (m *UpdateManager201) CurrentStatus() (requestID int, status FirmwareStatus) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.current == nil {
+		return 0, FirmwareStatusIdle
+	}
+	select {
+	case <-m.current.done:
+		return m.current.requestID, FirmwareStatusIdle
+	default:
+		return m.current.requestID, m.current.status
+	}
+}
+
+func (m *UpdateManager201) OnUpdateFirmware(request *UpdateFirmwareRequest) (*UpdateFirmwareResponse, error) {
+	location, err := url.Parse(request.Firmware.Location)
+	if err != nil {
+		return NewUpdateFirmwareResponse(UpdateFirmwareStatusRejected), nil
+	}
+	downloader, ok := m.Downloaders[location.Scheme]
+	if !ok || m.Install == nil {
+		return NewUpdateFirmwareResponse(UpdateFirmwareStatusRejected), nil
+	}
+	if m.ManufacturerRoots != nil {
+		if _, err = VerifySigningCertificate(request.Firmware.SigningCertificate, m.ManufacturerRoots); err != nil {
+			return NewUpdateFirmwareResponse(UpdateFirmwareStatusInvalidCertificate), nil
+		}
+	}
+	status := UpdateFirmwareStatusAccepted
+	m.mutex.Lock()
+	previous := m.current
+	if previous != nil {
+		select {
+		case <-previous.done:
+		default:
+			if previous.installing {
+				m.mutex.Unlock()
+				return NewUpdateFirmwareResponse(UpdateFirmwareStatusRejected), nil
+			}
+			previous.cancel()
+			status = UpdateFirmwareStatusAcceptedCanceled
+		}
+	}
+	clk := clock.OrDefault(m.clock)
+	ctx, cancel := context.WithCancel(context.Background())
+	update := &firmwareUpdate{requestID: request.RequestID, status: FirmwareStatusIdle, cancel: cancel, done: make(chan struct{})}
+	m.current = update
+	m.mutex.Unlock()
+	go func() {
+		if previous != nil {
+			<-previous.done
+		}
+		m.update(ctx, clk, update, downloader, location, request)
+	}()
+	return NewUpdateFirmwareResponse(status), nil
+}
+
+func (m *UpdateManager201) update(ctx context.Context, clk clock.Clock, update *firmwareUpdate, downloader FirmwareDownloader, location *url.URL, request *UpdateFirmwareRequest) {
+	defer close(update.done)
+	defer update.cancel()
+	if request.Firmware.RetrieveDateTime != nil && request.Firmware.RetrieveDateTime.Time.After(clk.Now()) {
+		if !m.setStatus(ctx, update, FirmwareStatusDownloadScheduled) || !waitUntil(ctx, clk, request.Firmware.RetrieveDateTime.Time) {
+			return
+		}
+	}
+	if !m.setStatus(ctx, update, FirmwareStatusDownloading) {
+		return
+	}
+	image, ok := m.download(ctx, clk, downloader, location, request)
+	if ctx.Err() != nil {
+		return
+	}
+	if !ok {
+		m.setStatus(ctx, update, FirmwareStatusDownloadFailed)
+		return
+	}
+	if !m.setStatus(ctx, update, FirmwareStatusDownloaded) {
+		return
+	}
+	if m.ManufacturerRoots != nil {
+		if err := VerifySignature(request.Firmware, bytes.NewReader(image), m.ManufacturerRoots); err != nil {
+			m.setStatus(ctx, update, FirmwareStatusInvalidSignature)
+			return
+		}
+		if !m.setStatus(ctx, update, FirmwareStatusSignatureVerified) {
+			return
+		}
+	}
+	if request.Firmware.InstallDateTime != nil && request.Firmware.InstallDateTime.Time.After(clk.Now()) {
+		if !m.setStatus(ctx, update, FirmwareStatusInstallScheduled) || !waitUntil(ctx, clk, request.Firmware.InstallDateTime.Time) {
+			return
+		}
+	}
+	m.mutex.Lock()
+	if ctx.Err() != nil {
+		m.mutex.Unlock()
+		return
+	}
+	update.installing = true
+	m.mutex.Unlock()
+	if !m.setStatus(ctx, update, FirmwareStatusInstalling) {
+		return
+	}
+	reboot, err := m.Install(ctx, image)
+	switch {
+	case err != nil:
+		m.setStatus(ctx, update, FirmwareStatusInstallationFailed)
+	case reboot:
+		m.setStatus(ctx, update, FirmwareStatusInstallRebooting)
+	default:
+		m.setStatus(ctx, update, FirmwareStatusInstalled)
+	}
+}
+
+// Downloads the firmware image, retrying as specified by the request. Returns false if all attempts failed or the update was canceled.
+func (m *UpdateManager201) download(ctx context.Context, clk clock.Clock, downloader FirmwareDownloader, location *url.URL, request *UpdateFirmwareRequest) ([]byte, bool) {
+	var image []byte
+	var downloaded bool
+	completed := retry.FromRequest(request.Retries, request.RetryInterval, clk).Run(ctx, func(n int) bool {
+		var err error
+		image, err = downloader.Download(ctx, location)
+		downloaded = err == nil
+		return downloaded
+	})
+	return image, completed && downloaded
+}
+
+// Updates the status of an update and notifies the CSMS, unless the update was canceled.
+func (m *UpdateManager201) setStatus(ctx context.Context, update *firmwareUpdate, status FirmwareStatus) bool {
+	m.mutex.Lock()
+	if ctx.Err() != nil {
+		m.mutex.Unlock()
+		return false
+	}
+	update.status = status
+	m.mutex.Unlock()
+	if m.SendStatus == nil {
+		return true
+	}
+	request := NewFirmwareStatusNotificationRequest(status)
+	requestID := update.requestID
+	request.RequestID = &requestID
+	if err := m.SendStatus(request); err != nil && m.OnSendError != nil {
+		m.OnSendError(update.requestID, err)
+	}
+	return true
+}
+
+// Blocks until the passed time is reached. Returns false if ctx was canceled in the meantime.
+func waitUntil(ctx context.Context, clk clock.Clock, t time.Time) bool {
+	timer := clk.NewTimer(t.Sub(clk.Now()))
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
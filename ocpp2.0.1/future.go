@@ -0,0 +1,128 @@
+package ocpp2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/lorenzodonini/ocpp-go/ws"
+)
+
+// The description of the error passed to pending callbacks, when a charging station disconnected.
+const disconnectedErrorDescription = "client disconnected, no response received from client"
+
+// Errors wrapped by a RequestError, describing why a future completed without a response.
+// If the charging station replied with a CALLERROR, the RequestError wraps the respective *ocpp.Error instead.
+var (
+	ErrRequestTimeout  = errors.New("no response received before the timeout expired")
+	ErrRequestCanceled = errors.New("request canceled")
+	ErrDisconnected    = errors.New("charging station disconnected")
+)
+
+// RequestError is returned by Future.Get, if no response to a request was received.
+// Use errors.Is for checking the cause (e.g. ErrRequestTimeout), or errors.As for retrieving the CALLERROR (*ocpp.Error).
+type RequestError struct {
+	ChargingStationID string
+	FeatureName       string
+	Err               error
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("%v request to %v failed: %v", e.FeatureName, e.ChargingStationID, e.Err)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// Future is the result of a request sent to a charging station via one of the ...Async functions of the CSMS.
+// It completes once a response was received, or once the request failed.
+//
+// Futures share the request queue with the callback-based functions, so both may be mixed freely.
+// A Future is safe for concurrent use.
+type Future[T ocpp.Response] struct {
+	chargingStationID string
+	featureName       string
+	response          T
+	err               error
+	done              chan struct{}
+	once              sync.Once
+}
+
+func newFuture[T ocpp.Response](chargingStationID string, featureName string) *Future[T] {
+	return &Future[T]{chargingStationID: chargingStationID, featureName: featureName, done: make(chan struct{})}
+}
+
+// Done returns a channel, which is closed once the future completed.
+func (f *Future[T]) Done() <-chan struct{} {
+	return f.done
+}
+
+// Get waits until the future completed and returns the response. If no response was received, a *RequestError is returned.
+//
+// If the context is done first, the context error is returned. The request remains pending in this case,
+// so Get may be invoked again later. To abandon the request, invoke Cancel.
+func (f *Future[T]) Get(ctx context.Context) (T, error) {
+	select {
+	case <-f.done:
+		return f.response, f.err
+	case <-ctx.Done():
+		var empty T
+		return empty, ctx.Err()
+	}
+}
+
+// Cancel completes the future with ErrRequestCanceled. Returns false if the future already completed.
+//
+// The request itself is not withdrawn: a later response is discarded, while subsequent requests to the same charging station
+// are sent only after the response was received, or after the request timed out.
+func (f *Future[T]) Cancel() bool {
+	var empty T
+	return f.complete(empty, &RequestError{ChargingStationID: f.chargingStationID, FeatureName: f.featureName, Err: ErrRequestCanceled})
+}
+
+// Completes the future with the result passed to a callback.
+func (f *Future[T]) callback(response T, err error) {
+	if err != nil {
+		err = &RequestError{ChargingStationID: f.chargingStationID, FeatureName: f.featureName, Err: requestFailureCause(err)}
+	}
+	f.complete(response, err)
+}
+
+func (f *Future[T]) complete(response T, err error) bool {
+	completed := false
+	f.once.Do(func() {
+		f.response = response
+		f.err = err
+		close(f.done)
+		completed = true
+	})
+	return completed
+}
+
+// Maps the errors passed to callbacks to the causes wrapped by a RequestError.
+func requestFailureCause(err error) error {
+	var ocppErr *ocpp.Error
+	var timeoutErr *ocppj.TimeoutError
+	switch {
+	case errors.As(err, &timeoutErr):
+		return ErrRequestTimeout
+	case errors.As(err, &ocppErr) && ocppErr.Code == ocppj.GenericError && ocppErr.Description == ocppj.RequestTimeoutDescription:
+		return ErrRequestTimeout
+	case errors.Is(err, ws.ErrNotConnected), errors.As(err, &ocppErr) && ocppErr.Code == ocppj.GenericError && ocppErr.Description == disconnectedErrorDescription:
+		return ErrDisconnected
+	}
+	return err
+}
+
+// Sends a request via a callback-based function of the CSMS, completing the returned future from within the callback.
+func sendAsync[T ocpp.Response](chargingStationID string, featureName string, send func(callback func(T, error)) error) (*Future[T], error) {
+	future := newFuture[T](chargingStationID, featureName)
+	if err := send(future.callback); err != nil {
+		return nil, err
+	}
+	return future, nil
+}
@@ -0,0 +1,119 @@
+// Package idtoken contains validation and normalization helpers for OCPP 2.0.1 idTokens.
+//
+// The OCPP schemas only limit the length of an idToken. Depending on its type, an idToken is however expected
+// to follow a specific format, e.g. a hex-encoded UID for RFID cards, or a contract ID for eMAIDs.
+// Since the same token may be presented in different notations (e.g. lowercase hex, or an eMAID with or without dashes),
+// tokens should be compared in their normalized form, e.g. via Equal.
+package idtoken
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// Errors returned by Validate.
+var (
+	ErrUnknownType           = errors.New("unknown idToken type")
+	ErrEmptyIdToken          = errors.New("idToken is required")
+	ErrUnexpectedIdToken     = errors.New("idToken must be empty for type NoAuthorization")
+	ErrInvalidFormat         = errors.New("invalid idToken format")
+	ErrInvalidAdditionalInfo = errors.New("invalid additionalInfo")
+)
+
+var (
+	// Two hex digits per byte. ISO14443 UIDs are 4, 7 or 10 bytes long, ISO15693 UIDs are 8 bytes long.
+	iso14443Pattern = regexp.MustCompile(`^([0-9A-Fa-f]{8}|[0-9A-Fa-f]{14}|[0-9A-Fa-f]{20})$`)
+	iso15693Pattern = regexp.MustCompile(`^[0-9A-Fa-f]{16}$`)
+	// Six bytes, either without separators, or separated by colons or dashes.
+	macAddressPattern = regexp.MustCompile(`^([0-9A-Fa-f]{12}|([0-9A-Fa-f]{2}:){5}[0-9A-Fa-f]{2}|([0-9A-Fa-f]{2}-){5}[0-9A-Fa-f]{2})$`)
+	// Country code, provider ID, eMA instance and optional check digit, as defined by ISO 15118-2 and DIN SPEC 91286.
+	emaidPattern = regexp.MustCompile(`^([A-Za-z]{2}[0-9A-Za-z]{12,13}|[A-Za-z]{2}-[0-9A-Za-z]{3}-[0-9A-Za-z]{9}(-[0-9A-Za-z])?)$`)
+)
+
+// Validate checks whether an idToken is well-formed for its type:
+//
+// - ISO14443 and ISO15693 tokens must be hex-encoded UIDs of 4, 7 or 10 bytes, and 8 bytes respectively
+//
+// - MacAddress tokens must contain six hex-encoded bytes, optionally separated by colons or dashes
+//
+// - eMAID tokens must be contract IDs, with or without dashes
+//
+// - NoAuthorization tokens must be empty, while tokens of all other types are required
+//
+// Additional info entries must contain a non-blank additionalIdToken and type, and may not be repeated.
+// The returned error wraps one of the errors defined by this package.
+func Validate(token types.IdToken) error {
+	var pattern *regexp.Regexp
+	switch token.Type {
+	case types.IdTokenTypeNoAuthorization:
+		if token.IdToken != "" {
+			return ErrUnexpectedIdToken
+		}
+	case types.IdTokenTypeISO14443:
+		pattern = iso14443Pattern
+	case types.IdTokenTypeISO15693:
+		pattern = iso15693Pattern
+	case types.IdTokenTypeMacAddress:
+		pattern = macAddressPattern
+	case types.IdTokenTypeEMAID:
+		pattern = emaidPattern
+	case types.IdTokenTypeCentral, types.IdTokenTypeKeyCode, types.IdTokenTypeLocal:
+	default:
+		return fmt.Errorf("%w: %v", ErrUnknownType, token.Type)
+	}
+	if token.Type != types.IdTokenTypeNoAuthorization && token.IdToken == "" {
+		return ErrEmptyIdToken
+	}
+	if pattern != nil && !pattern.MatchString(token.IdToken) {
+		return fmt.Errorf("%w: %v is not a valid %v token", ErrInvalidFormat, token.IdToken, token.Type)
+	}
+	seen := map[types.AdditionalInfo]bool{}
+	for i, info := range token.AdditionalInfo {
+		if strings.TrimSpace(info.AdditionalIdToken) == "" || strings.TrimSpace(info.Type) == "" {
+			return fmt.Errorf("%w: entry %d is blank", ErrInvalidAdditionalInfo, i)
+		}
+		key := types.AdditionalInfo{AdditionalIdToken: info.AdditionalIdToken, Type: info.Type}
+		if seen[key] {
+			return fmt.Errorf("%w: entry %d is a duplicate", ErrInvalidAdditionalInfo, i)
+		}
+		seen[key] = true
+	}
+	return nil
+}
+
+// Normalize returns a copy of an idToken in its canonical notation. Hex-encoded UIDs are uppercased,
+// MAC addresses are uppercased and separated by colons, and eMAIDs are uppercased without dashes.
+// Tokens of other types, as well as malformed tokens, are returned unchanged.
+func Normalize(token types.IdToken) types.IdToken {
+	normalized := token
+	switch token.Type {
+	case types.IdTokenTypeISO14443, types.IdTokenTypeISO15693:
+		if iso14443Pattern.MatchString(token.IdToken) || iso15693Pattern.MatchString(token.IdToken) {
+			normalized.IdToken = strings.ToUpper(token.IdToken)
+		}
+	case types.IdTokenTypeMacAddress:
+		if macAddressPattern.MatchString(token.IdToken) {
+			hex := strings.ToUpper(strings.NewReplacer(":", "", "-", "").Replace(token.IdToken))
+			octets := make([]string, 0, 6)
+			for i := 0; i < len(hex); i += 2 {
+				octets = append(octets, hex[i:i+2])
+			}
+			normalized.IdToken = strings.Join(octets, ":")
+		}
+	case types.IdTokenTypeEMAID:
+		if emaidPattern.MatchString(token.IdToken) {
+			normalized.IdToken = strings.ToUpper(strings.ReplaceAll(token.IdToken, "-", ""))
+		}
+	}
+	return normalized
+}
+
+// Equal returns true if two idTokens have the same type and the same normalized value.
+// Additional info and custom data are ignored. This is useful for looking up tokens, e.g. in the local authorization list.
+func Equal(a types.IdToken, b types.IdToken) bool {
+	return a.Type == b.Type && Normalize(a).IdToken == Normalize(b).IdToken
+}
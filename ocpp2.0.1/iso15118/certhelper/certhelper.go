@@ -0,0 +1,151 @@
+// Package certhelper contains helpers for handling the certificates exchanged in ISO 15118 (Plug & Charge) and
+// security related OCPP 2.0.1 messages, such as InstallCertificate, CertificateSigned and GetCertificateStatus.
+//
+// All helpers are pure: they perform no network access, and the validation time can be passed explicitly.
+package certhelper
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+var (
+	// ErrNoCertificate is returned when a PEM chain doesn't contain any certificate.
+	ErrNoCertificate = errors.New("no certificate found")
+	// ErrCertificateExpired is returned when a certificate of a chain is expired, or not valid yet.
+	ErrCertificateExpired = errors.New("certificate is not within its validity period")
+	// ErrUnknownRoot is returned when a chain doesn't lead to one of the trusted roots.
+	ErrUnknownRoot = errors.New("certificate chain doesn't lead to a trusted root")
+	// ErrInvalidUsage is returned when a certificate is not suitable for the requested use.
+	ErrInvalidUsage = errors.New("certificate is not suitable for the requested use")
+)
+
+// ParseChain parses all PEM encoded certificates contained in pemChain, in order.
+// Blocks of other types are ignored.
+func ParseChain(pemChain string) ([]*x509.Certificate, error) {
+	var certificates []*x509.Certificate
+	rest := []byte(pemChain)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, certificate)
+	}
+	if len(certificates) == 0 {
+		return nil, ErrNoCertificate
+	}
+	return certificates, nil
+}
+
+// ValidateChain validates a PEM encoded certificate chain at the current time. See ValidateChainAt.
+func ValidateChain(pemChain string, roots *x509.CertPool, usage types.CertificateUse) error {
+	return ValidateChainAt(pemChain, roots, usage, time.Now())
+}
+
+// ValidateChainAt validates a PEM encoded certificate chain for the given use, at the given time.
+// The chain starts with the leaf certificate, optionally followed by intermediate certificates.
+//
+// For root certificates (V2GRootCertificate, MORootCertificate, CSMSRootCertificate, ManufacturerRootCertificate),
+// the chain must consist of a single self-signed CA certificate; roots is not needed and may be nil.
+// For all other uses, the chain must lead to one of the passed roots, which should be the roots matching the use
+// (e.g. the V2G roots for a V2GCertificateChain, or the MO roots for a contract certificate).
+// Sub-CA certificates (CSOSubCA1, CSOSubCA2) must be CA certificates, while a V2GCertificateChain
+// must start with an end-entity certificate allowing digital signatures.
+//
+// Errors wrap ErrNoCertificate, ErrCertificateExpired, ErrUnknownRoot or ErrInvalidUsage, where applicable.
+func ValidateChainAt(pemChain string, roots *x509.CertPool, usage types.CertificateUse, now time.Time) error {
+	certificates, err := ParseChain(pemChain)
+	if err != nil {
+		return err
+	}
+	for _, certificate := range certificates {
+		if now.Before(certificate.NotBefore) || now.After(certificate.NotAfter) {
+			return fmt.Errorf("%w: %v (valid from %v until %v)", ErrCertificateExpired, certificate.Subject, certificate.NotBefore, certificate.NotAfter)
+		}
+	}
+	leaf := certificates[0]
+	switch usage {
+	case types.V2GRootCertificate, types.MORootCertificate, types.CSMSRootCertificate, types.ManufacturerRootCertificate:
+		if len(certificates) != 1 || !leaf.IsCA {
+			return fmt.Errorf("%w: %v requires a single CA certificate", ErrInvalidUsage, usage)
+		}
+		if err = leaf.CheckSignatureFrom(leaf); err != nil {
+			return fmt.Errorf("%w: %v is not self-signed", ErrInvalidUsage, leaf.Subject)
+		}
+		return nil
+	case types.CSOSubCA1, types.CSOSubCA2:
+		if !leaf.IsCA {
+			return fmt.Errorf("%w: %v is not a CA certificate", ErrInvalidUsage, leaf.Subject)
+		}
+	case types.V2GCertificateChain:
+		if leaf.IsCA || leaf.KeyUsage&x509.KeyUsageDigitalSignature == 0 {
+			return fmt.Errorf("%w: %v is not a signing end-entity certificate", ErrInvalidUsage, leaf.Subject)
+		}
+	default:
+		return fmt.Errorf("%w: unknown use %v", ErrInvalidUsage, usage)
+	}
+	intermediates := x509.NewCertPool()
+	for _, certificate := range certificates[1:] {
+		intermediates.AddCert(certificate)
+	}
+	if roots == nil {
+		roots = x509.NewCertPool()
+	}
+	_, err = leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	var unknownAuthority x509.UnknownAuthorityError
+	if errors.As(err, &unknownAuthority) {
+		return fmt.Errorf("%w: %v", ErrUnknownRoot, err)
+	}
+	return err
+}
+
+// BuildCertificateHashData computes the hash data identifying a certificate. See types.NewCertificateHashDataFromCert.
+func BuildCertificateHashData(cert *x509.Certificate, issuer *x509.Certificate, alg types.HashAlgorithmType) (types.CertificateHashData, error) {
+	if cert == nil {
+		return types.CertificateHashData{}, ErrNoCertificate
+	}
+	hashData, err := types.NewCertificateHashDataFromCert(cert, issuer, alg)
+	if err != nil {
+		return types.CertificateHashData{}, err
+	}
+	return *hashData, nil
+}
+
+// BuildOCSPRequestData computes the data needed for checking the revocation status of a certificate
+// via a GetCertificateStatusRequest. The responderURL is taken from the authority information access extension
+// of the certificate, if present.
+func BuildOCSPRequestData(cert *x509.Certificate, issuer *x509.Certificate, alg types.HashAlgorithmType) (types.OCSPRequestDataType, error) {
+	hashData, err := BuildCertificateHashData(cert, issuer, alg)
+	if err != nil {
+		return types.OCSPRequestDataType{}, err
+	}
+	data := types.OCSPRequestDataType{
+		HashAlgorithm:  hashData.HashAlgorithm,
+		IssuerNameHash: hashData.IssuerNameHash,
+		IssuerKeyHash:  hashData.IssuerKeyHash,
+		SerialNumber:   hashData.SerialNumber,
+	}
+	if len(cert.OCSPServer) > 0 {
+		data.ResponderURL = cert.OCSPServer[0]
+	}
+	return data, nil
+}
@@ -0,0 +1,163 @@
+package certhelper_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/iso15118/certhelper"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+var fixtureTime = time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+type fixtureCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func (f fixtureCert) pem() string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: f.cert.Raw}))
+}
+
+func newFixtureCert(t *testing.T, name string, serial int64, isCA bool, notAfter time.Time, parent *fixtureCert) fixtureCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: name, Organization: []string{"ocpp-go"}},
+		NotBefore:             fixtureTime.Add(-24 * time.Hour),
+		NotAfter:              notAfter,
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		OCSPServer:            []string{"http://ocsp.example.com"},
+	}
+	if isCA {
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign
+	} else {
+		template.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyAgreement
+	}
+	parentCert, parentKey := template, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return fixtureCert{cert: cert, key: key}
+}
+
+type fixtureChain struct {
+	root  fixtureCert
+	subCA fixtureCert
+	leaf  fixtureCert
+}
+
+func newFixtureChain(t *testing.T, leafNotAfter time.Time) fixtureChain {
+	root := newFixtureCert(t, "V2G Root", 1, true, fixtureTime.Add(365*24*time.Hour), nil)
+	subCA := newFixtureCert(t, "CPO Sub-CA", 2, true, fixtureTime.Add(180*24*time.Hour), &root)
+	leaf := newFixtureCert(t, "EVSE Leaf", 4095, false, leafNotAfter, &subCA)
+	return fixtureChain{root: root, subCA: subCA, leaf: leaf}
+}
+
+func certPool(certs ...fixtureCert) *x509.CertPool {
+	pool := x509.NewCertPool()
+	for _, c := range certs {
+		pool.AddCert(c.cert)
+	}
+	return pool
+}
+
+func TestValidateChain(t *testing.T) {
+	chain := newFixtureChain(t, fixtureTime.Add(30*24*time.Hour))
+	pemChain := chain.leaf.pem() + chain.subCA.pem()
+	roots := certPool(chain.root)
+	assert.NoError(t, certhelper.ValidateChainAt(pemChain, roots, types.V2GCertificateChain, fixtureTime))
+	// Sub-CA
+	assert.NoError(t, certhelper.ValidateChainAt(chain.subCA.pem(), roots, types.CSOSubCA1, fixtureTime))
+	assert.ErrorIs(t, certhelper.ValidateChainAt(pemChain, roots, types.CSOSubCA1, fixtureTime), certhelper.ErrInvalidUsage)
+	// Missing intermediate
+	assert.ErrorIs(t, certhelper.ValidateChainAt(chain.leaf.pem(), roots, types.V2GCertificateChain, fixtureTime), certhelper.ErrUnknownRoot)
+	// Wrong root
+	other := newFixtureChain(t, fixtureTime.Add(30*24*time.Hour))
+	assert.ErrorIs(t, certhelper.ValidateChainAt(pemChain, certPool(other.root), types.V2GCertificateChain, fixtureTime), certhelper.ErrUnknownRoot)
+	assert.ErrorIs(t, certhelper.ValidateChainAt(pemChain, nil, types.V2GCertificateChain, fixtureTime), certhelper.ErrUnknownRoot)
+	// A CA can't be used as V2G leaf
+	assert.ErrorIs(t, certhelper.ValidateChainAt(chain.subCA.pem(), roots, types.V2GCertificateChain, fixtureTime), certhelper.ErrInvalidUsage)
+	// Validity window
+	assert.ErrorIs(t, certhelper.ValidateChainAt(pemChain, roots, types.V2GCertificateChain, fixtureTime.Add(31*24*time.Hour)), certhelper.ErrCertificateExpired)
+	assert.ErrorIs(t, certhelper.ValidateChainAt(pemChain, roots, types.V2GCertificateChain, fixtureTime.Add(-48*time.Hour)), certhelper.ErrCertificateExpired)
+	// Invalid input
+	assert.ErrorIs(t, certhelper.ValidateChainAt("not a certificate", roots, types.V2GCertificateChain, fixtureTime), certhelper.ErrNoCertificate)
+}
+
+func TestValidateExpiredLeaf(t *testing.T) {
+	chain := newFixtureChain(t, fixtureTime.Add(-time.Hour))
+	err := certhelper.ValidateChainAt(chain.leaf.pem()+chain.subCA.pem(), certPool(chain.root), types.V2GCertificateChain, fixtureTime)
+	assert.ErrorIs(t, err, certhelper.ErrCertificateExpired)
+}
+
+func TestValidateRootCertificate(t *testing.T) {
+	chain := newFixtureChain(t, fixtureTime.Add(30*24*time.Hour))
+	assert.NoError(t, certhelper.ValidateChainAt(chain.root.pem(), nil, types.V2GRootCertificate, fixtureTime))
+	assert.NoError(t, certhelper.ValidateChainAt(chain.root.pem(), nil, types.MORootCertificate, fixtureTime))
+	// Not self-signed
+	assert.ErrorIs(t, certhelper.ValidateChainAt(chain.subCA.pem(), nil, types.V2GRootCertificate, fixtureTime), certhelper.ErrInvalidUsage)
+	// Not a CA
+	assert.ErrorIs(t, certhelper.ValidateChainAt(chain.leaf.pem(), nil, types.CSMSRootCertificate, fixtureTime), certhelper.ErrInvalidUsage)
+	// More than one certificate
+	assert.ErrorIs(t, certhelper.ValidateChainAt(chain.root.pem()+chain.root.pem(), nil, types.V2GRootCertificate, fixtureTime), certhelper.ErrInvalidUsage)
+}
+
+func TestBuildCertificateHashData(t *testing.T) {
+	chain := newFixtureChain(t, fixtureTime.Add(30*24*time.Hour))
+	hashData, err := certhelper.BuildCertificateHashData(chain.leaf.cert, chain.subCA.cert, types.SHA256)
+	require.NoError(t, err)
+	nameHash := sha256.Sum256(chain.subCA.cert.RawSubject)
+	keyHash := sha256.Sum256(elliptic.Marshal(elliptic.P256(), chain.subCA.key.X, chain.subCA.key.Y))
+	assert.Equal(t, types.SHA256, hashData.HashAlgorithm)
+	assert.Equal(t, hex.EncodeToString(nameHash[:]), hashData.IssuerNameHash)
+	assert.Equal(t, hex.EncodeToString(keyHash[:]), hashData.IssuerKeyHash)
+	assert.Equal(t, "fff", hashData.SerialNumber)
+	assert.NoError(t, types.Validate.Struct(hashData))
+	// Other algorithms
+	for _, alg := range []types.HashAlgorithmType{types.SHA384, types.SHA512} {
+		hashData, err = certhelper.BuildCertificateHashData(chain.leaf.cert, chain.subCA.cert, alg)
+		require.NoError(t, err)
+		assert.NoError(t, types.Validate.Struct(hashData))
+	}
+	assert.Len(t, hashData.IssuerNameHash, 128)
+	_, err = certhelper.BuildCertificateHashData(chain.leaf.cert, chain.subCA.cert, "MD5")
+	assert.Error(t, err)
+	// Self-signed certificates don't need an issuer
+	hashData, err = certhelper.BuildCertificateHashData(chain.root.cert, nil, types.SHA256)
+	require.NoError(t, err)
+	assert.Equal(t, "1", hashData.SerialNumber)
+	_, err = certhelper.BuildCertificateHashData(chain.leaf.cert, nil, types.SHA256)
+	assert.Error(t, err)
+}
+
+func TestBuildOCSPRequestData(t *testing.T) {
+	chain := newFixtureChain(t, fixtureTime.Add(30*24*time.Hour))
+	data, err := certhelper.BuildOCSPRequestData(chain.leaf.cert, chain.subCA.cert, types.SHA256)
+	require.NoError(t, err)
+	hashData, err := certhelper.BuildCertificateHashData(chain.leaf.cert, chain.subCA.cert, types.SHA256)
+	require.NoError(t, err)
+	assert.Equal(t, hashData.IssuerNameHash, data.IssuerNameHash)
+	assert.Equal(t, hashData.IssuerKeyHash, data.IssuerKeyHash)
+	assert.Equal(t, hashData.SerialNumber, data.SerialNumber)
+	assert.Equal(t, "http://ocsp.example.com", data.ResponderURL)
+	assert.NoError(t, types.Validate.Struct(data))
+}
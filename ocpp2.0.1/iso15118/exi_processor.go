@@ -0,0 +1,94 @@
+package iso15118
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// MaxEXIPayloadLength is the maximum length of the base64 encoded exiRequest and exiResponse fields.
+const MaxEXIPayloadLength = 5600
+
+// ErrInvalidEXIPayload is returned when an EXI payload is not valid base64, or exceeds MaxEXIPayloadLength once encoded.
+var ErrInvalidEXIPayload = errors.New("invalid EXI payload")
+
+// EXIProcessor processes the raw, EXI encoded ISO 15118 CertificateInstallationReq or CertificateUpdateReq of an EV,
+// typically by forwarding it to an external EXI codec and to the contract certificate pool,
+// and returns the EXI encoded response for the EV.
+//
+// If the certificate couldn't be provided, the processor should return a Failed status,
+// along with the EXI encoded response containing the ISO 15118 failure code, if available.
+type EXIProcessor interface {
+	ProcessCertificateInstallation(ctx context.Context, action CertificateAction, exiRequest []byte, schemaVersion string) (exiResponse []byte, status types.Certificate15118EVStatus, err error)
+}
+
+// EXIBridge connects an EXIProcessor to the Get15118EVCertificate flow on the CSMS, taking care of the base64 encoding
+// of the payloads and of the status mapping.
+//
+// OnGet15118EVCertificate matches the respective CSMSHandler method, so an ISO 15118 handler may delegate to it directly.
+// If the processor fails without providing an EXI response, the request is answered with an error,
+// since the exiResponse field is mandatory; otherwise a Failed status is sent along with the EXI response.
+type EXIBridge struct {
+	// The processor handling the decoded EXI requests.
+	Processor EXIProcessor
+	// The timeout for processing a single request. Zero means no timeout.
+	Timeout time.Duration
+}
+
+// NewEXIBridge creates a new bridge for the given processor.
+func NewEXIBridge(processor EXIProcessor, timeout time.Duration) *EXIBridge {
+	return &EXIBridge{Processor: processor, Timeout: timeout}
+}
+
+func (b *EXIBridge) OnGet15118EVCertificate(chargingStationID string, request *Get15118EVCertificateRequest) (*Get15118EVCertificateResponse, error) {
+	exiRequest, err := DecodeEXIPayload(request.ExiRequest)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.Background()
+	if b.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.Timeout)
+		defer cancel()
+	}
+	rawResponse, status, err := b.Processor.ProcessCertificateInstallation(ctx, request.Action, exiRequest, request.SchemaVersion)
+	if len(rawResponse) == 0 {
+		if err == nil {
+			err = errors.New("empty EXI response")
+		}
+		return nil, fmt.Errorf("couldn't process EXI request of %v: %w", chargingStationID, err)
+	}
+	exiResponse, encodeErr := EncodeEXIPayload(rawResponse)
+	if encodeErr != nil {
+		return nil, encodeErr
+	}
+	if err != nil || status != types.Certificate15188EVStatusAccepted {
+		status = types.Certificate15118EVStatusFailed
+	}
+	return NewGet15118EVCertificateResponse(status, exiResponse), nil
+}
+
+// DecodeEXIPayload decodes a base64 encoded exiRequest or exiResponse field.
+func DecodeEXIPayload(payload string) ([]byte, error) {
+	if len(payload) > MaxEXIPayloadLength {
+		return nil, fmt.Errorf("%w: length %d exceeds %d", ErrInvalidEXIPayload, len(payload), MaxEXIPayloadLength)
+	}
+	raw, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEXIPayload, err)
+	}
+	return raw, nil
+}
+
+// EncodeEXIPayload encodes a raw EXI message for the exiRequest or exiResponse field.
+func EncodeEXIPayload(raw []byte) (string, error) {
+	payload := base64.StdEncoding.EncodeToString(raw)
+	if len(payload) > MaxEXIPayloadLength {
+		return "", fmt.Errorf("%w: length %d exceeds %d", ErrInvalidEXIPayload, len(payload), MaxEXIPayloadLength)
+	}
+	return payload, nil
+}
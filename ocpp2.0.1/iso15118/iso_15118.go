@@ -5,7 +5,11 @@
 // - support for certificate-based authentication and authorization at the charging station, i.e. plug and charge
 package iso15118
 
-import "github.com/lorenzodonini/ocpp-go/ocpp"
+import (
+	"context"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+)
 
 // Needs to be implemented by a CSMS for handling messages part of the OCPP 2.0 ISO 15118 profile.
 type CSMSHandler interface {
@@ -15,6 +19,35 @@ type CSMSHandler interface {
 	OnGetCertificateStatus(chargingStationID string, request *GetCertificateStatusRequest) (response *GetCertificateStatusResponse, err error)
 }
 
+// Context-aware variant of CSMSHandler, for handling messages part of the OCPP 2.0 ISO 15118 profile.
+type CSMSHandlerWithContext interface {
+	// OnGet15118EVCertificate is called on the CSMS whenever a Get15118EVCertificateRequest is received from a charging station.
+	OnGet15118EVCertificate(ctx context.Context, chargingStationID string, request *Get15118EVCertificateRequest) (response *Get15118EVCertificateResponse, err error)
+	// OnGetCertificateStatus is called on the CSMS whenever a GetCertificateStatusRequest is received from a charging station.
+	OnGetCertificateStatus(ctx context.Context, chargingStationID string, request *GetCertificateStatusRequest) (response *GetCertificateStatusResponse, err error)
+}
+
+// AdaptCSMSHandler converts a CSMSHandler into a CSMSHandlerWithContext, which ignores the context.
+// Returns nil if handler is nil.
+func AdaptCSMSHandler(handler CSMSHandler) CSMSHandlerWithContext {
+	if handler == nil {
+		return nil
+	}
+	return csmsHandlerAdapter{handler: handler}
+}
+
+type csmsHandlerAdapter struct {
+	handler CSMSHandler
+}
+
+func (a csmsHandlerAdapter) OnGet15118EVCertificate(ctx context.Context, chargingStationID string, request *Get15118EVCertificateRequest) (*Get15118EVCertificateResponse, error) {
+	return a.handler.OnGet15118EVCertificate(chargingStationID, request)
+}
+
+func (a csmsHandlerAdapter) OnGetCertificateStatus(ctx context.Context, chargingStationID string, request *GetCertificateStatusRequest) (*GetCertificateStatusResponse, error) {
+	return a.handler.OnGetCertificateStatus(chargingStationID, request)
+}
+
 // Needs to be implemented by Charging stations for handling messages part of the OCPP 2.0 ISO 15118 profile.
 type ChargingStationHandler interface {
 	// OnDeleteCertificate is called on a charging station whenever a DeleteCertificateRequest is received from the CSMS.
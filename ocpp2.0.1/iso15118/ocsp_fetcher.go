@@ -0,0 +1,333 @@
+package iso15118
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+var (
+	// ErrInvalidOCSPResponse is returned when an OCSP responder replied with a malformed or unsuccessful response,
+	// or with a response not matching the request.
+	ErrInvalidOCSPResponse = errors.New("invalid OCSP response")
+	// ErrStaleOCSPResponse is returned when an OCSP response is not yet valid, or was already superseded.
+	ErrStaleOCSPResponse = errors.New("OCSP response is outside of its validity window")
+)
+
+// DefaultOCSPClockSkew is the clock difference tolerated by default when validating the thisUpdate and nextUpdate
+// fields of an OCSP response.
+const DefaultOCSPClockSkew = 5 * time.Minute
+
+// The maximum size of an OCSP response read from a responder.
+const maxOCSPResponseSize = 64 * 1024
+
+var (
+	oidOCSPBasicResponse = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+	oidOCSPNonce         = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 2}
+	oidHashAlgorithms    = map[types.HashAlgorithmType]asn1.ObjectIdentifier{
+		types.SHA256: {2, 16, 840, 1, 101, 3, 4, 2, 1},
+		types.SHA384: {2, 16, 840, 1, 101, 3, 4, 2, 2},
+		types.SHA512: {2, 16, 840, 1, 101, 3, 4, 2, 3},
+	}
+)
+
+// ASN.1 structures of RFC 6960.
+
+type ocspCertID struct {
+	HashAlgorithm  pkix.AlgorithmIdentifier
+	IssuerNameHash []byte
+	IssuerKeyHash  []byte
+	SerialNumber   *big.Int
+}
+
+type ocspSingleRequest struct {
+	CertID ocspCertID
+}
+
+type ocspTBSRequest struct {
+	Version           int `asn1:"explicit,tag:0,default:0,optional"`
+	RequestList       []ocspSingleRequest
+	RequestExtensions []pkix.Extension `asn1:"explicit,tag:2,optional"`
+}
+
+type ocspRequest struct {
+	TBSRequest ocspTBSRequest
+}
+
+type ocspResponseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type ocspResponse struct {
+	Status        asn1.Enumerated
+	ResponseBytes ocspResponseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspRevokedInfo struct {
+	RevocationTime time.Time       `asn1:"generalized"`
+	Reason         asn1.Enumerated `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspSingleResponse struct {
+	CertID           ocspCertID
+	Good             asn1.Flag        `asn1:"tag:0,optional"`
+	Revoked          ocspRevokedInfo  `asn1:"tag:1,optional"`
+	Unknown          asn1.Flag        `asn1:"tag:2,optional"`
+	ThisUpdate       time.Time        `asn1:"generalized"`
+	NextUpdate       time.Time        `asn1:"generalized,explicit,tag:0,optional"`
+	SingleExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type ocspResponseData struct {
+	Version            int `asn1:"explicit,tag:0,default:0,optional"`
+	ResponderID        asn1.RawValue
+	ProducedAt         time.Time `asn1:"generalized"`
+	Responses          []ocspSingleResponse
+	ResponseExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type ocspBasicResponse struct {
+	TBSResponseData    ocspResponseData
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspCacheEntry struct {
+	result     string
+	nextUpdate time.Time
+}
+
+// OCSPFetcher retrieves the OCSP status of certificates on behalf of charging stations, as required by the
+// GetCertificateStatus flow on the CSMS.
+//
+// OnGetCertificateStatus matches the respective CSMSHandler method, so an ISO 15118 handler may delegate to it directly.
+//
+// Responses are validated against the request (certificate ID and nonce, if echoed by the responder) and against
+// their thisUpdate/nextUpdate window, then cached until their nextUpdate time. Responses without a nextUpdate time are not cached.
+// The signature of a response is not verified, since the response is forwarded as-is to the charging station,
+// which validates it against its own trust anchors.
+//
+// An OCSPFetcher is safe for concurrent use.
+type OCSPFetcher struct {
+	// The client used for contacting OCSP responders. If nil, http.DefaultClient is used.
+	Client *http.Client
+	// The timeout for a single OCSP request. Zero means no timeout, other than the one of the client.
+	Timeout time.Duration
+	// The clock difference tolerated when validating the thisUpdate and nextUpdate fields of a response.
+	ClockSkew time.Duration
+	// Invoked whenever the status of a certificate couldn't be retrieved for a charging station. Optional.
+	OnFetchError func(chargingStationID string, err error)
+	cache        map[string]ocspCacheEntry
+	now          func() time.Time
+	mutex        sync.Mutex
+}
+
+// NewOCSPFetcher creates a new fetcher using the default HTTP client.
+func NewOCSPFetcher(timeout time.Duration) *OCSPFetcher {
+	return &OCSPFetcher{
+		Timeout:   timeout,
+		ClockSkew: DefaultOCSPClockSkew,
+		cache:     map[string]ocspCacheEntry{},
+		now:       time.Now,
+	}
+}
+
+// SetTimeSource overrides the clock used for validating and caching responses. Mainly useful for testing.
+func (f *OCSPFetcher) SetTimeSource(now func() time.Time) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.now = now
+}
+
+// Fetch retrieves the OCSP status of the certificate identified by data, from the responder at data.ResponderURL.
+// On success, the DER encoded OCSP response is returned base64 encoded, as expected in a GetCertificateStatusResponse.
+//
+// The returned status reflects whether the status could be retrieved, not the validity of the certificate:
+// a revoked certificate still yields an Accepted status. All failures yield a Rejected status, which corresponds
+// to the Failed status of the OCPP 2.0.1 specification.
+func (f *OCSPFetcher) Fetch(ctx context.Context, data types.OCSPRequestDataType) (ocspResult string, status types.GenericStatus, err error) {
+	key := ocspCacheKey(data)
+	f.mutex.Lock()
+	now := f.now()
+	if entry, ok := f.cache[key]; ok {
+		if now.Before(entry.nextUpdate) {
+			f.mutex.Unlock()
+			return entry.result, types.GenericStatusAccepted, nil
+		}
+		delete(f.cache, key)
+	}
+	f.mutex.Unlock()
+	result, nextUpdate, err := f.fetch(ctx, data, now)
+	if err != nil {
+		return "", types.GenericStatusRejected, err
+	}
+	if !nextUpdate.IsZero() {
+		f.mutex.Lock()
+		f.cache[key] = ocspCacheEntry{result: result, nextUpdate: nextUpdate}
+		f.mutex.Unlock()
+	}
+	return result, types.GenericStatusAccepted, nil
+}
+
+func (f *OCSPFetcher) OnGetCertificateStatus(chargingStationID string, request *GetCertificateStatusRequest) (*GetCertificateStatusResponse, error) {
+	result, status, err := f.Fetch(context.Background(), request.OcspRequestData)
+	if err != nil && f.OnFetchError != nil {
+		f.OnFetchError(chargingStationID, err)
+	}
+	response := NewGetCertificateStatusResponse(status)
+	response.OcspResult = result
+	return response, nil
+}
+
+func (f *OCSPFetcher) fetch(ctx context.Context, data types.OCSPRequestDataType, now time.Time) (string, time.Time, error) {
+	if data.ResponderURL == "" {
+		return "", time.Time{}, errors.New("no OCSP responder URL")
+	}
+	certID, err := newOCSPCertID(data)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	nonce := make([]byte, 16)
+	if _, err = rand.Read(nonce); err != nil {
+		return "", time.Time{}, err
+	}
+	nonceValue, err := asn1.Marshal(nonce)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	rawRequest, err := asn1.Marshal(ocspRequest{TBSRequest: ocspTBSRequest{
+		RequestList:       []ocspSingleRequest{{CertID: certID}},
+		RequestExtensions: []pkix.Extension{{Id: oidOCSPNonce, Value: nonceValue}},
+	}})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	rawResponse, err := f.post(ctx, data.ResponderURL, rawRequest)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	nextUpdate, err := f.validateResponse(rawResponse, certID, nonceValue, now)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	result := base64.StdEncoding.EncodeToString(rawResponse)
+	if len(result) > 5500 {
+		return "", time.Time{}, fmt.Errorf("%w: response exceeds 5500 characters", ErrInvalidOCSPResponse)
+	}
+	return result, nextUpdate, nil
+}
+
+func (f *OCSPFetcher) post(ctx context.Context, responderURL string, rawRequest []byte) ([]byte, error) {
+	if f.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.Timeout)
+		defer cancel()
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, responderURL, bytes.NewReader(rawRequest))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/ocsp-request")
+	request.Header.Set("Accept", "application/ocsp-response")
+	client := f.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("OCSP responder returned %v", response.Status)
+	}
+	return io.ReadAll(io.LimitReader(response.Body, maxOCSPResponseSize))
+}
+
+// Parses and validates a DER encoded OCSP response, returning the nextUpdate time of the matching single response.
+func (f *OCSPFetcher) validateResponse(rawResponse []byte, certID ocspCertID, nonceValue []byte, now time.Time) (time.Time, error) {
+	var response ocspResponse
+	if rest, err := asn1.Unmarshal(rawResponse, &response); err != nil || len(rest) > 0 {
+		return time.Time{}, fmt.Errorf("%w: malformed response", ErrInvalidOCSPResponse)
+	}
+	if response.Status != 0 {
+		return time.Time{}, fmt.Errorf("%w: response status %d", ErrInvalidOCSPResponse, response.Status)
+	}
+	if !response.ResponseBytes.ResponseType.Equal(oidOCSPBasicResponse) {
+		return time.Time{}, fmt.Errorf("%w: unsupported response type %v", ErrInvalidOCSPResponse, response.ResponseBytes.ResponseType)
+	}
+	var basicResponse ocspBasicResponse
+	if rest, err := asn1.Unmarshal(response.ResponseBytes.Response, &basicResponse); err != nil || len(rest) > 0 {
+		return time.Time{}, fmt.Errorf("%w: malformed basic response", ErrInvalidOCSPResponse)
+	}
+	for _, extension := range basicResponse.TBSResponseData.ResponseExtensions {
+		if extension.Id.Equal(oidOCSPNonce) && !bytes.Equal(extension.Value, nonceValue) {
+			return time.Time{}, fmt.Errorf("%w: nonce mismatch", ErrInvalidOCSPResponse)
+		}
+	}
+	for _, single := range basicResponse.TBSResponseData.Responses {
+		if !certID.matches(single.CertID) {
+			continue
+		}
+		if single.ThisUpdate.After(now.Add(f.ClockSkew)) {
+			return time.Time{}, fmt.Errorf("%w: thisUpdate %v is in the future", ErrStaleOCSPResponse, single.ThisUpdate)
+		}
+		if !single.NextUpdate.IsZero() && single.NextUpdate.Before(now.Add(-f.ClockSkew)) {
+			return time.Time{}, fmt.Errorf("%w: nextUpdate %v has passed", ErrStaleOCSPResponse, single.NextUpdate)
+		}
+		return single.NextUpdate, nil
+	}
+	return time.Time{}, fmt.Errorf("%w: no status for the requested certificate", ErrInvalidOCSPResponse)
+}
+
+func newOCSPCertID(data types.OCSPRequestDataType) (ocspCertID, error) {
+	oid, ok := oidHashAlgorithms[data.HashAlgorithm]
+	if !ok {
+		return ocspCertID{}, fmt.Errorf("unsupported hash algorithm %v", data.HashAlgorithm)
+	}
+	nameHash, err := hex.DecodeString(data.IssuerNameHash)
+	if err != nil {
+		return ocspCertID{}, fmt.Errorf("invalid issuerNameHash: %w", err)
+	}
+	keyHash, err := hex.DecodeString(data.IssuerKeyHash)
+	if err != nil {
+		return ocspCertID{}, fmt.Errorf("invalid issuerKeyHash: %w", err)
+	}
+	serialNumber, ok := new(big.Int).SetString(data.SerialNumber, 16)
+	if !ok {
+		return ocspCertID{}, fmt.Errorf("invalid serialNumber %v", data.SerialNumber)
+	}
+	return ocspCertID{
+		HashAlgorithm:  pkix.AlgorithmIdentifier{Algorithm: oid, Parameters: asn1.NullRawValue},
+		IssuerNameHash: nameHash,
+		IssuerKeyHash:  keyHash,
+		SerialNumber:   serialNumber,
+	}, nil
+}
+
+func (id ocspCertID) matches(other ocspCertID) bool {
+	return id.HashAlgorithm.Algorithm.Equal(other.HashAlgorithm.Algorithm) &&
+		bytes.Equal(id.IssuerNameHash, other.IssuerNameHash) &&
+		bytes.Equal(id.IssuerKeyHash, other.IssuerKeyHash) &&
+		other.SerialNumber != nil && id.SerialNumber.Cmp(other.SerialNumber) == 0
+}
+
+func ocspCacheKey(data types.OCSPRequestDataType) string {
+	return strings.ToLower(strings.Join([]string{string(data.HashAlgorithm), data.IssuerNameHash, data.IssuerKeyHash, strings.TrimLeft(data.SerialNumber, "0")}, ":"))
+}
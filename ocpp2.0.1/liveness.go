@@ -0,0 +1,82 @@
+package ocpp2
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/remotecontrol"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+)
+
+const (
+	heartbeatCtrlrComponent = "HeartbeatCtrlr"
+	heartbeatIntervalName   = "Interval"
+)
+
+func (cs *csms) EnableLivenessMonitor(config ocppj.LivenessConfig) {
+	if _, ok := cs.server.GetProfile(remotecontrol.ProfileName); ok {
+		cs.server.SetLivenessProbe(cs.probeLiveness)
+	}
+	cs.server.EnableLivenessMonitor(config)
+}
+
+func (cs *csms) SetLivenessHandler(handler func(chargingStationID string, liveness ocppj.Liveness)) {
+	cs.server.SetLivenessHandler(handler)
+}
+
+func (cs *csms) Liveness(chargingStationID string) (ocppj.Liveness, bool) {
+	return cs.server.Liveness(chargingStationID)
+}
+
+// Triggers a heartbeat. Both the TriggerMessage response and the heartbeat itself prove that the charging station is alive.
+func (cs *csms) probeLiveness(chargingStationID string) error {
+	return cs.TriggerMessage(chargingStationID, func(*remotecontrol.TriggerMessageResponse, error) {}, remotecontrol.MessageTriggerHeartbeat)
+}
+
+func isHeartbeatInterval(component types.Component, variable types.Variable, attribute types.Attribute) bool {
+	return component.Name == heartbeatCtrlrComponent && component.EVSE == nil && variable.Name == heartbeatIntervalName &&
+		(attribute == "" || attribute == types.AttributeActual)
+}
+
+// Updates the heartbeat interval known to the liveness monitor, from a BootNotification or GetVariables response.
+func (cs *csms) observeHeartbeatInterval(chargingStationID string, response ocpp.Response) {
+	switch r := response.(type) {
+	case *provisioning.BootNotificationResponse:
+		cs.server.SetHeartbeatInterval(chargingStationID, time.Duration(r.Interval)*time.Second)
+	case *provisioning.GetVariablesResponse:
+		for _, result := range r.GetVariableResult {
+			if result.AttributeStatus == provisioning.GetVariableStatusAccepted && isHeartbeatInterval(result.Component, result.Variable, result.AttributeType) {
+				cs.setHeartbeatInterval(chargingStationID, result.AttributeValue)
+			}
+		}
+	}
+}
+
+// Wraps the callback of a SetVariables request, updating the heartbeat interval known to the liveness monitor
+// once a new HeartbeatCtrlr interval was accepted.
+func (cs *csms) observeSetVariables(chargingStationID string, data []provisioning.SetVariableData, callback func(*provisioning.SetVariablesResponse, error)) func(*provisioning.SetVariablesResponse, error) {
+	return func(response *provisioning.SetVariablesResponse, err error) {
+		if response != nil {
+			for _, result := range response.SetVariableResult {
+				if result.AttributeStatus != provisioning.SetVariableStatusAccepted || !isHeartbeatInterval(result.Component, result.Variable, result.AttributeType) {
+					continue
+				}
+				for _, d := range data {
+					if isHeartbeatInterval(d.Component, d.Variable, d.AttributeType) {
+						cs.setHeartbeatInterval(chargingStationID, d.AttributeValue)
+					}
+				}
+			}
+		}
+		callback(response, err)
+	}
+}
+
+func (cs *csms) setHeartbeatInterval(chargingStationID string, value string) {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		cs.server.SetHeartbeatInterval(chargingStationID, time.Duration(seconds)*time.Second)
+	}
+}
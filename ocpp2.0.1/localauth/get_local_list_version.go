@@ -1,6 +1,7 @@
 package localauth
 
 import (
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
 	"reflect"
 )
 
@@ -10,12 +11,14 @@ const GetLocalListVersionFeatureName = "GetLocalListVersion"
 
 // The field definition of the GetLocalListVersion request payload sent by the CSMS to the Charging Station.
 type GetLocalListVersionRequest struct {
+	CustomData *types.CustomData `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // This field definition of the GetLocalListVersion response payload, sent by the Charging Station to the CSMS in response to a GetLocalListVersionRequest.
 // In case the request was invalid, or couldn't be processed, an error will be sent instead.
 type GetLocalListVersionResponse struct {
-	VersionNumber int `json:"versionNumber" validate:"gte=0"`
+	VersionNumber int               `json:"versionNumber" validate:"gte=0"`
+	CustomData    *types.CustomData `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // The CSMS can request a Charging Station for the version number of the Local Authorization List by sending a GetLocalListVersionRequest.
@@ -0,0 +1,179 @@
+package localauth
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/idtoken"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// ListStorage persists the local authorization list of a charging station.
+// Implementations don't need to be safe for concurrent use, as they are only accessed by a single ListManager201.
+//
+// To retain the list across reboots, provide a storage backed by persistent memory.
+type ListStorage interface {
+	// Save replaces the stored list with the passed version and entries. The operation must be atomic:
+	// if an error is returned, the previously stored list must be retained.
+	Save(version int, entries []AuthorizationData) error
+	// Load returns the stored list. An empty storage returns version 0 and no entries.
+	Load() (version int, entries []AuthorizationData, err error)
+}
+
+type memoryListStorage struct {
+	version int
+	entries []AuthorizationData
+}
+
+// NewMemoryListStorage creates a non-persistent ListStorage, keeping the list in memory.
+func NewMemoryListStorage() ListStorage {
+	return &memoryListStorage{}
+}
+
+func (s *memoryListStorage) Save(version int, entries []AuthorizationData) error {
+	s.version = version
+	s.entries = entries
+	return nil
+}
+
+func (s *memoryListStorage) Load() (int, []AuthorizationData, error) {
+	return s.version, s.entries, nil
+}
+
+type listKey struct {
+	idToken   string
+	tokenType types.IdTokenType
+}
+
+// ListManager201 implements the ChargingStationHandler interface on top of a ListStorage.
+// It may therefore be registered directly:
+//
+//	chargingStation.SetLocalAuthListHandler(manager)
+//
+// Full updates replace the list, while Differential updates add or replace entries containing an idTokenInfo,
+// and remove entries without one. Differential updates must increase the version of the list,
+// otherwise they are answered with VersionMismatch. Updates containing the same idToken more than once,
+// Full updates containing entries without idTokenInfo, updates exceeding ItemsPerMessage and updates
+// that couldn't be stored are answered with Failed, leaving the list and its version untouched.
+//
+// Entries are looked up via Lookup, e.g. for authorizing idTokens while offline.
+//
+// A ListManager201 is safe for concurrent use.
+type ListManager201 struct {
+	// The maximum amount of entries per SendLocalListRequest (ItemsPerMessage of the LocalAuthListCtrlr). Zero means no limit.
+	ItemsPerMessage int
+	storage         ListStorage
+	version         int
+	entries         map[listKey]AuthorizationData
+	mutex           sync.Mutex
+}
+
+// NewListManager201 creates a new manager, restoring the list contained in storage.
+func NewListManager201(storage ListStorage) (*ListManager201, error) {
+	version, entries, err := storage.Load()
+	if err != nil {
+		return nil, err
+	}
+	m := &ListManager201{storage: storage, version: version, entries: map[listKey]AuthorizationData{}}
+	for _, entry := range entries {
+		m.entries[keyOf(entry.IdToken)] = entry
+	}
+	return m, nil
+}
+
+// Version returns the current version of the list. Zero indicates an empty list.
+func (m *ListManager201) Version() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.version
+}
+
+// Lookup returns the idTokenInfo stored for an idToken of the given type.
+// Tokens are compared in their normalized form, so e.g. lowercase hex UIDs match uppercase entries.
+func (m *ListManager201) Lookup(idToken string, tokenType types.IdTokenType) (types.IdTokenInfo, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	entry, ok := m.entries[keyOf(types.IdToken{IdToken: idToken, Type: tokenType})]
+	if !ok || entry.IdTokenInfo == nil {
+		return types.IdTokenInfo{}, false
+	}
+	return *entry.IdTokenInfo, true
+}
+
+func (m *ListManager201) OnGetLocalListVersion(request *GetLocalListVersionRequest) (*GetLocalListVersionResponse, error) {
+	return NewGetLocalListVersionResponse(m.Version()), nil
+}
+
+func (m *ListManager201) OnSendLocalList(request *SendLocalListRequest) (*SendLocalListResponse, error) {
+	if m.ItemsPerMessage > 0 && len(request.LocalAuthorizationList) > m.ItemsPerMessage {
+		return newSendLocalListFailure("TooManyElements"), nil
+	}
+	seen := map[listKey]bool{}
+	for _, entry := range request.LocalAuthorizationList {
+		key := keyOf(entry.IdToken)
+		if seen[key] {
+			return newSendLocalListFailure("DuplicateIdToken"), nil
+		}
+		seen[key] = true
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	entries := map[listKey]AuthorizationData{}
+	switch request.UpdateType {
+	case UpdateTypeFull:
+		for _, entry := range request.LocalAuthorizationList {
+			if entry.IdTokenInfo == nil {
+				return newSendLocalListFailure("MissingIdTokenInfo"), nil
+			}
+			entries[keyOf(entry.IdToken)] = entry
+		}
+	case UpdateTypeDifferential:
+		if request.VersionNumber <= m.version {
+			return NewSendLocalListResponse(SendLocalListStatusVersionMismatch), nil
+		}
+		for key, entry := range m.entries {
+			entries[key] = entry
+		}
+		for _, entry := range request.LocalAuthorizationList {
+			if entry.IdTokenInfo == nil {
+				delete(entries, keyOf(entry.IdToken))
+			} else {
+				entries[keyOf(entry.IdToken)] = entry
+			}
+		}
+	default:
+		return newSendLocalListFailure("UnknownUpdateType"), nil
+	}
+	if err := m.storage.Save(request.VersionNumber, sortedEntries(entries)); err != nil {
+		return newSendLocalListFailure("StorageError"), nil
+	}
+	m.version = request.VersionNumber
+	m.entries = entries
+	return NewSendLocalListResponse(SendLocalListStatusAccepted), nil
+}
+
+func newSendLocalListFailure(reason string) *SendLocalListResponse {
+	response := NewSendLocalListResponse(SendLocalListStatusFailed)
+	response.StatusInfo = types.NewStatusInfo(reason, "")
+	return response
+}
+
+func keyOf(idToken types.IdToken) listKey {
+	normalized := idtoken.Normalize(idToken)
+	return listKey{idToken: normalized.IdToken, tokenType: normalized.Type}
+}
+
+// Returns the entries ordered by idToken and type, for deterministic storage.
+func sortedEntries(entries map[listKey]AuthorizationData) []AuthorizationData {
+	result := make([]AuthorizationData, 0, len(entries))
+	for _, entry := range entries {
+		result = append(result, entry)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].IdToken.IdToken != result[j].IdToken.IdToken {
+			return result[i].IdToken.IdToken < result[j].IdToken.IdToken
+		}
+		return result[i].IdToken.Type < result[j].IdToken.Type
+	})
+	return result
+}
@@ -52,6 +52,7 @@ func isValidSendLocalListStatus(fl validator.FieldLevel) bool {
 type AuthorizationData struct {
 	IdTokenInfo *types.IdTokenInfo `json:"idTokenInfo,omitempty" validate:"omitempty"`
 	IdToken     types.IdToken      `json:"idToken"`
+	CustomData  *types.CustomData  `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // The field definition of the SendLocalList request payload sent by the CSMS to the Charging Station.
@@ -59,6 +60,7 @@ type SendLocalListRequest struct {
 	VersionNumber          int                 `json:"versionNumber" validate:"gte=0"`
 	UpdateType             UpdateType          `json:"updateType" validate:"required,updateType201"`
 	LocalAuthorizationList []AuthorizationData `json:"localAuthorizationList,omitempty" validate:"omitempty,dive"`
+	CustomData             *types.CustomData   `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // This field definition of the SendLocalList response payload, sent by the Charging Station to the CSMS in response to a SendLocalListRequest.
@@ -66,6 +68,7 @@ type SendLocalListRequest struct {
 type SendLocalListResponse struct {
 	Status     SendLocalListStatus `json:"status" validate:"required,sendLocalListStatus"`
 	StatusInfo *types.StatusInfo   `json:"statusInfo,omitempty" validate:"omitempty"`
+	CustomData *types.CustomData   `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // Enables the CSMS to send a Local Authorization List which a Charging Station can use for the
@@ -110,6 +113,6 @@ func NewSendLocalListResponse(status SendLocalListStatus) *SendLocalListResponse
 }
 
 func init() {
-	_ = types.Validate.RegisterValidation("updateType201", isValidUpdateType)
-	_ = types.Validate.RegisterValidation("sendLocalListStatus", isValidSendLocalListStatus)
+	_ = types.RegisterValidation("updateType201", isValidUpdateType)
+	_ = types.RegisterValidation("sendLocalListStatus", isValidSendLocalListStatus)
 }
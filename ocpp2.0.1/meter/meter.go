@@ -1,7 +1,11 @@
 // The Meter values functional block contains OCPP 2.0 features for sending meter values to the CSMS.
 package meter
 
-import "github.com/lorenzodonini/ocpp-go/ocpp"
+import (
+	"context"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+)
 
 // Needs to be implemented by a CSMS for handling messages part of the OCPP 2.0 Meter values profile.
 type CSMSHandler interface {
@@ -9,6 +13,29 @@ type CSMSHandler interface {
 	OnMeterValues(chargingStationID string, request *MeterValuesRequest) (response *MeterValuesResponse, err error)
 }
 
+// Context-aware variant of CSMSHandler, for handling messages part of the OCPP 2.0 Meter values profile.
+type CSMSHandlerWithContext interface {
+	// OnMeterValues is called on the CSMS whenever a MeterValuesRequest is received from a charging station.
+	OnMeterValues(ctx context.Context, chargingStationID string, request *MeterValuesRequest) (response *MeterValuesResponse, err error)
+}
+
+// AdaptCSMSHandler converts a CSMSHandler into a CSMSHandlerWithContext, which ignores the context.
+// Returns nil if handler is nil.
+func AdaptCSMSHandler(handler CSMSHandler) CSMSHandlerWithContext {
+	if handler == nil {
+		return nil
+	}
+	return csmsHandlerAdapter{handler: handler}
+}
+
+type csmsHandlerAdapter struct {
+	handler CSMSHandler
+}
+
+func (a csmsHandlerAdapter) OnMeterValues(ctx context.Context, chargingStationID string, request *MeterValuesRequest) (*MeterValuesResponse, error) {
+	return a.handler.OnMeterValues(chargingStationID, request)
+}
+
 // Needs to be implemented by Charging stations for handling messages part of the OCPP 2.0 Meter values profile.
 type ChargingStationHandler interface {
 }
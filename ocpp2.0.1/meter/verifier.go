@@ -0,0 +1,293 @@
+package meter
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+var (
+	// ErrUnsupportedEncoding is returned by a Verifier, if the signed meter data isn't in a format it understands.
+	ErrUnsupportedEncoding = errors.New("unsupported signed meter data encoding")
+	// ErrMalformedSignedData is returned by a Verifier, if the signed meter data or the public key can't be parsed.
+	ErrMalformedSignedData = errors.New("malformed signed meter data")
+	// ErrInvalidSignature is returned by a Verifier, if the signature doesn't match the signed meter data.
+	ErrInvalidSignature = errors.New("invalid meter value signature")
+)
+
+// SignedReading is a single meter reading, as contained in signed meter data.
+type SignedReading struct {
+	Time        time.Time // The time of the reading.
+	TimeStatus  string    // The synchronization status of the meter clock (e.g. S for synchronized), if available.
+	Type        string    // The type of the reading within the transaction (e.g. B for begin, E for end).
+	Value       float64   // The reading value.
+	Identifier  string    // The identifier of the reading, typically an OBIS code.
+	Unit        string    // The unit of the reading value.
+	CurrentType string    // The type of current (e.g. AC or DC), if available.
+	ErrorFlags  string    // Error flags reported by the meter, if any.
+	Status      string    // The status of the meter at the time of the reading.
+}
+
+// SignedReadings contains the data of a verified signed meter value.
+type SignedReadings struct {
+	MeterSerial    string          // The serial number of the meter.
+	Pagination     string          // The pagination counter of the signed data.
+	Identification string          // The identification data of the user, if included.
+	Readings       []SignedReading // The readings, in order.
+}
+
+// Verifier verifies signed meter values, returning the signed readings on success.
+//
+// Errors wrap ErrUnsupportedEncoding, ErrMalformedSignedData or ErrInvalidSignature, where applicable.
+type Verifier interface {
+	Verify(value types.SignedMeterValue) (*SignedReadings, error)
+}
+
+// The default OCMF signature algorithm.
+const ocmfDefaultAlgorithm = "ECDSA-secp256r1-SHA256"
+
+type ocmfReading struct {
+	Time        string  `json:"TM"`
+	Type        string  `json:"TX"`
+	Value       float64 `json:"RV"`
+	Identifier  string  `json:"RI"`
+	Unit        string  `json:"RU"`
+	CurrentType string  `json:"RT"`
+	ErrorFlags  string  `json:"EF"`
+	Status      string  `json:"ST"`
+}
+
+type ocmfPayload struct {
+	FormatVersion  string        `json:"FV"`
+	MeterSerial    string        `json:"MS"`
+	Pagination     string        `json:"PG"`
+	Identification string        `json:"ID"`
+	Readings       []ocmfReading `json:"RD"`
+}
+
+type ocmfSignature struct {
+	Algorithm string `json:"SA"`
+	Encoding  string `json:"SE"`
+	MimeType  string `json:"SM"`
+	Data      string `json:"SD"`
+}
+
+// OCMFVerifier verifies signed meter values in the Open Charge Metering Format (OCMF), as used for German
+// calibration law (Eichrecht) compliance. The signed meter data must contain the OCMF container, i.e.
+// "OCMF|{payload}|{signature}".
+//
+// ECDSA signatures on the secp256r1 and secp384r1 curves are supported.
+// If PublicKey is set, signatures are verified against it, ignoring the public key sent along with the meter value.
+// Otherwise the sent public key is used: since it is provided by the charging station itself,
+// this only proves the integrity of the data, while its authenticity must be ensured by comparing the key
+// with the one registered for the meter.
+type OCMFVerifier struct {
+	// The trusted public key of the meter. Optional.
+	PublicKey *ecdsa.PublicKey
+}
+
+func (v *OCMFVerifier) Verify(value types.SignedMeterValue) (*SignedReadings, error) {
+	rawData, err := base64.StdEncoding.DecodeString(value.SignedMeterData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedSignedData, err)
+	}
+	data := string(rawData)
+	if !strings.HasPrefix(data, "OCMF|") {
+		return nil, fmt.Errorf("%w: not an OCMF container", ErrUnsupportedEncoding)
+	}
+	separator := strings.LastIndex(data, "|")
+	if separator <= len("OCMF") {
+		return nil, fmt.Errorf("%w: missing OCMF signature section", ErrMalformedSignedData)
+	}
+	payloadSection := data[len("OCMF|"):separator]
+	var payload ocmfPayload
+	if err = json.Unmarshal([]byte(payloadSection), &payload); err != nil {
+		return nil, fmt.Errorf("%w: OCMF payload: %v", ErrMalformedSignedData, err)
+	}
+	var signature ocmfSignature
+	if err = json.Unmarshal([]byte(data[separator+1:]), &signature); err != nil {
+		return nil, fmt.Errorf("%w: OCMF signature: %v", ErrMalformedSignedData, err)
+	}
+	publicKey := v.PublicKey
+	if publicKey == nil {
+		if publicKey, err = parseMeterPublicKey(value.PublicKey); err != nil {
+			return nil, err
+		}
+	}
+	if err = verifyOCMFSignature(publicKey, signature, []byte(payloadSection)); err != nil {
+		return nil, err
+	}
+	readings, err := parseOCMFReadings(payload.Readings)
+	if err != nil {
+		return nil, err
+	}
+	return &SignedReadings{
+		MeterSerial:    payload.MeterSerial,
+		Pagination:     payload.Pagination,
+		Identification: payload.Identification,
+		Readings:       readings,
+	}, nil
+}
+
+func verifyOCMFSignature(publicKey *ecdsa.PublicKey, signature ocmfSignature, payload []byte) error {
+	algorithm := signature.Algorithm
+	if algorithm == "" {
+		algorithm = ocmfDefaultAlgorithm
+	}
+	var curve elliptic.Curve
+	var hash crypto.Hash
+	switch algorithm {
+	case "ECDSA-secp256r1-SHA256":
+		curve, hash = elliptic.P256(), crypto.SHA256
+	case "ECDSA-secp384r1-SHA256":
+		curve, hash = elliptic.P384(), crypto.SHA256
+	case "ECDSA-secp384r1-SHA384":
+		curve, hash = elliptic.P384(), crypto.SHA384
+	default:
+		return fmt.Errorf("%w: signature algorithm %v", ErrUnsupportedEncoding, algorithm)
+	}
+	if publicKey.Curve != curve {
+		return fmt.Errorf("%w: public key doesn't match signature algorithm %v", ErrInvalidSignature, algorithm)
+	}
+	if signature.MimeType != "" && signature.MimeType != "application/x-der" {
+		return fmt.Errorf("%w: signature mime type %v", ErrUnsupportedEncoding, signature.MimeType)
+	}
+	var rawSignature []byte
+	var err error
+	switch signature.Encoding {
+	case "", "hex":
+		rawSignature, err = hex.DecodeString(signature.Data)
+	case "base64":
+		rawSignature, err = base64.StdEncoding.DecodeString(signature.Data)
+	default:
+		return fmt.Errorf("%w: signature encoding %v", ErrUnsupportedEncoding, signature.Encoding)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: signature data: %v", ErrMalformedSignedData, err)
+	}
+	h := hash.New()
+	h.Write(payload)
+	if !ecdsa.VerifyASN1(publicKey, h.Sum(nil), rawSignature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// Parses a base64 encoded public key. The decoded key is either a DER encoded SubjectPublicKeyInfo,
+// or its hex representation, as commonly used by OCMF meters.
+func parseMeterPublicKey(encoded string) (*ecdsa.PublicKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: public key: %v", ErrMalformedSignedData, err)
+	}
+	key, err := x509.ParsePKIXPublicKey(raw)
+	if err != nil {
+		der, hexErr := hex.DecodeString(strings.TrimSpace(string(raw)))
+		if hexErr != nil {
+			return nil, fmt.Errorf("%w: public key: %v", ErrMalformedSignedData, err)
+		}
+		if key, err = x509.ParsePKIXPublicKey(der); err != nil {
+			return nil, fmt.Errorf("%w: public key: %v", ErrMalformedSignedData, err)
+		}
+	}
+	ecdsaKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: public key is not an ECDSA key", ErrUnsupportedEncoding)
+	}
+	return ecdsaKey, nil
+}
+
+// Parses OCMF readings. Identifier, unit and current type may be omitted in subsequent readings, in which case they are inherited.
+func parseOCMFReadings(rawReadings []ocmfReading) ([]SignedReading, error) {
+	readings := make([]SignedReading, 0, len(rawReadings))
+	var previous SignedReading
+	for _, raw := range rawReadings {
+		reading := SignedReading{
+			Type:        raw.Type,
+			Value:       raw.Value,
+			Identifier:  raw.Identifier,
+			Unit:        raw.Unit,
+			CurrentType: raw.CurrentType,
+			ErrorFlags:  raw.ErrorFlags,
+			Status:      raw.Status,
+		}
+		if raw.Time != "" {
+			// Timestamps are formatted as "2018-07-24T13:22:04,000+0200 S", the suffix being the time status
+			timestamp, status, _ := strings.Cut(raw.Time, " ")
+			t, err := time.Parse("2006-01-02T15:04:05,000-0700", timestamp)
+			if err != nil {
+				return nil, fmt.Errorf("%w: reading time %v", ErrMalformedSignedData, raw.Time)
+			}
+			reading.Time = t
+			reading.TimeStatus = status
+		} else {
+			reading.Time, reading.TimeStatus = previous.Time, previous.TimeStatus
+		}
+		if reading.Identifier == "" {
+			reading.Identifier = previous.Identifier
+		}
+		if reading.Unit == "" {
+			reading.Unit = previous.Unit
+		}
+		if reading.CurrentType == "" {
+			reading.CurrentType = previous.CurrentType
+		}
+		readings = append(readings, reading)
+		previous = reading
+	}
+	return readings, nil
+}
+
+// VerificationResult contains the outcome of verifying a single signed sampled value of a transaction.
+type VerificationResult struct {
+	TransactionID string             // The ID of the transaction.
+	SeqNo         int                // The sequence number of the TransactionEventRequest containing the value.
+	Timestamp     time.Time          // The timestamp of the meter value containing the sampled value.
+	SampledValue  types.SampledValue // The verified sampled value.
+	Readings      *SignedReadings    // The signed readings. Nil if the verification failed.
+	Err           error              // The verification error. Nil if the signature is valid.
+}
+
+// Valid returns true if the signature of the sampled value was successfully verified.
+func (r VerificationResult) Valid() bool {
+	return r.Err == nil
+}
+
+// VerifyTransactionEvents verifies all signed sampled values contained in the events of a transaction,
+// e.g. for auditing a transaction once it ended. Sampled values without a signedMeterValue are skipped.
+// Results are returned in the order of the events, as passed.
+func VerifyTransactionEvents(events []transactions.TransactionEventRequest, verifier Verifier) []VerificationResult {
+	var results []VerificationResult
+	for _, event := range events {
+		for _, meterValue := range event.MeterValue {
+			for _, sampledValue := range meterValue.SampledValue {
+				if sampledValue.SignedMeterValue == nil {
+					continue
+				}
+				readings, err := verifier.Verify(*sampledValue.SignedMeterValue)
+				results = append(results, VerificationResult{
+					TransactionID: event.TransactionInfo.TransactionID,
+					SeqNo:         event.SequenceNo,
+					Timestamp:     meterValue.Timestamp.Time,
+					SampledValue:  sampledValue,
+					Readings:      readings,
+					Err:           err,
+				})
+			}
+		}
+	}
+	return results
+}
@@ -0,0 +1,58 @@
+package provisioning
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// The component and variable holding the password, which a charging station uses for HTTP Basic Authentication (use case A05).
+const (
+	BasicAuthPasswordComponent = "SecurityCtrlr"
+	BasicAuthPasswordVariable  = "BasicAuthPassword"
+)
+
+// The length limits of a BasicAuthPassword.
+const (
+	MinBasicAuthPasswordLength = 16
+	MaxBasicAuthPasswordLength = 40
+)
+
+// ErrInvalidBasicAuthPassword is returned when a password doesn't satisfy the BasicAuthPassword constraints.
+var ErrInvalidBasicAuthPassword = errors.New("invalid basic auth password")
+
+// ValidateBasicAuthPassword checks that a password consists of 16 to 40 printable ASCII characters.
+// Colons are not allowed, since they separate username and password in the Authorization header.
+func ValidateBasicAuthPassword(password string) error {
+	if len(password) < MinBasicAuthPasswordLength || len(password) > MaxBasicAuthPasswordLength {
+		return fmt.Errorf("%w: length must be between %d and %d characters", ErrInvalidBasicAuthPassword, MinBasicAuthPasswordLength, MaxBasicAuthPasswordLength)
+	}
+	for _, c := range password {
+		if c < 0x20 || c > 0x7e {
+			return fmt.Errorf("%w: only printable ASCII characters are allowed", ErrInvalidBasicAuthPassword)
+		}
+		if c == ':' {
+			return fmt.Errorf("%w: colons are not allowed", ErrInvalidBasicAuthPassword)
+		}
+	}
+	return nil
+}
+
+// IsBasicAuthPassword returns true if the attribute is the Actual value of the SecurityCtrlr.BasicAuthPassword variable.
+func IsBasicAuthPassword(component types.Component, variable types.Variable, attributeType types.Attribute) bool {
+	return strings.EqualFold(component.Name, BasicAuthPasswordComponent) &&
+		strings.EqualFold(variable.Name, BasicAuthPasswordVariable) &&
+		(attributeType == "" || attributeType == types.AttributeActual)
+}
+
+// NewBasicAuthPasswordData creates the SetVariableData for rotating the BasicAuthPassword of a charging station.
+func NewBasicAuthPasswordData(password string) SetVariableData {
+	return SetVariableData{
+		AttributeType:  types.AttributeActual,
+		AttributeValue: password,
+		Component:      types.Component{Name: BasicAuthPasswordComponent},
+		Variable:       types.Variable{Name: BasicAuthPasswordVariable},
+	}
+}
@@ -0,0 +1,232 @@
+package provisioning
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/clock"
+	"github.com/lorenzodonini/ocpp-go/events"
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+)
+
+// ErrNotAcceptedYet is returned by the BootManager request filter, for requests that may not be sent,
+// because the charging station wasn't accepted by the CSMS yet.
+var ErrNotAcceptedYet = errors.New("charging station not accepted by the CSMS yet")
+
+// The interval after which a BootNotification is retried, if the CSMS didn't provide one, or the request failed.
+const DefaultBootRetryInterval = time.Minute
+
+// The maximum period after which a running BootManager checks whether a BootNotification retry is due.
+const maxBootTickPeriod = time.Second
+
+// BootManager performs the BootNotification of a charging station, as described by use cases B01-B03.
+//
+// Until the CSMS accepts the charging station, outgoing requests are gated by Filter, which is meant to be
+// registered with the ocppj client of the charging station:
+//
+//	endpoint := ocppj.NewClient(id, wsClient, nil, nil, profiles...)
+//	endpoint.SetRequestFilter(bootManager.Filter)
+//	chargingStation := ocpp2.NewChargingStation(id, endpoint, wsClient)
+//
+// While the registration status is Pending, only BootNotification requests and requests solicited by the CSMS
+// (see Solicit) may be sent. While Rejected, no requests may be sent until the retry interval elapsed.
+// Responses to requests from the CSMS, e.g. GetVariables and SetVariables, are never gated.
+// The gate is lifted as soon as an Accepted response is received.
+//
+// If the charging station isn't accepted, the BootNotification is retried after the interval returned by the CSMS.
+// Retries are sent while the manager is running (see Start). Alternatively, Tick may be invoked manually.
+//
+// A BootManager is safe for concurrent use.
+type BootManager struct {
+	// Sends a BootNotificationRequest to the CSMS and returns the response, e.g. by using ChargingStation.SendRequest.
+	Send func(request *BootNotificationRequest) (*BootNotificationResponse, error)
+	// Invoked whenever the registration status changes. Optional.
+	OnStatusChanged func(status RegistrationStatus, interval time.Duration)
+	// Invoked whenever a BootNotification couldn't be sent. Optional.
+	OnSendError     func(err error)
+	chargingStation ChargingStationType
+	reason          BootReason
+	status          RegistrationStatus
+	nextBoot        time.Time
+	solicited       map[string]int
+	clock           clock.Clock
+	stopC           chan struct{}
+	bus             *events.Bus
+	mutex           sync.Mutex
+}
+
+// NewBootManager creates a new manager for the given charging station. The BootNotification is sent on Boot.
+func NewBootManager(chargingStation ChargingStationType, send func(request *BootNotificationRequest) (*BootNotificationResponse, error)) *BootManager {
+	return &BootManager{
+		Send:            send,
+		chargingStation: chargingStation,
+		solicited:       map[string]int{},
+		clock:           clock.New(),
+	}
+}
+
+// SetTimeSource replaces the function used for retrieving the current time, while timers are backed by the real clock.
+//
+// Deprecated: Use SetClock instead.
+func (m *BootManager) SetTimeSource(now func() time.Time) {
+	m.SetClock(clock.WithNow(now))
+}
+
+// SetClock sets the clock used for retrieving the current time and for scheduling BootNotification retries.
+// Passing nil restores the real clock. The clock must be set before starting the manager.
+func (m *BootManager) SetClock(c clock.Clock) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.clock = clock.OrDefault(c)
+}
+
+// SetEventBus sets a bus, on which a BootAccepted event is published once the CSMS accepts the charging station.
+// Passing nil disables publishing.
+func (m *BootManager) SetEventBus(bus *events.Bus) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.bus = bus
+}
+
+// Status returns the current registration status. The status is empty, until a response was received.
+func (m *BootManager) Status() RegistrationStatus {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.status
+}
+
+// NextBoot returns the time at which the next BootNotification retry is due. Returns false, if no retry is scheduled.
+func (m *BootManager) NextBoot() (time.Time, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.nextBoot, !m.nextBoot.IsZero()
+}
+
+// Boot sends a BootNotificationRequest with the given reason and returns the resulting registration status.
+// The reason is reused for retries. If the request fails, it is retried after DefaultBootRetryInterval.
+//
+// While Rejected, the request is gated until the retry interval elapsed.
+func (m *BootManager) Boot(reason BootReason) (RegistrationStatus, error) {
+	m.mutex.Lock()
+	m.reason = reason
+	request := &BootNotificationRequest{Reason: reason, ChargingStation: m.chargingStation}
+	m.mutex.Unlock()
+	response, err := m.Send(request)
+	m.mutex.Lock()
+	if err != nil {
+		if !errors.Is(err, ErrNotAcceptedYet) {
+			m.nextBoot = m.clock.Now().Add(DefaultBootRetryInterval)
+		}
+		status := m.status
+		m.mutex.Unlock()
+		if m.OnSendError != nil {
+			m.OnSendError(err)
+		}
+		return status, err
+	}
+	interval := time.Duration(response.Interval) * time.Second
+	if response.Status == RegistrationStatusAccepted {
+		m.nextBoot = time.Time{}
+		m.solicited = map[string]int{}
+	} else {
+		if interval <= 0 {
+			interval = DefaultBootRetryInterval
+		}
+		m.nextBoot = m.clock.Now().Add(interval)
+	}
+	changed := m.status != response.Status
+	m.status = response.Status
+	bus := m.bus
+	m.mutex.Unlock()
+	if changed && response.Status == RegistrationStatusAccepted {
+		bus.Publish(events.BootAcceptedEvent{Interval: interval})
+	}
+	if changed && m.OnStatusChanged != nil {
+		m.OnStatusChanged(response.Status, interval)
+	}
+	return response.Status, nil
+}
+
+// Start begins retrying the BootNotification in the background. Calling Start on a running manager has no effect.
+func (m *BootManager) Start() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.stopC != nil {
+		return
+	}
+	stopC := make(chan struct{})
+	m.stopC = stopC
+	ticker := m.clock.NewTicker(maxBootTickPeriod)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C():
+				m.Tick()
+			case <-stopC:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops retrying the BootNotification in the background.
+func (m *BootManager) Stop() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.stopC != nil {
+		close(m.stopC)
+		m.stopC = nil
+	}
+}
+
+// Tick retries the BootNotification, if a retry is due.
+func (m *BootManager) Tick() {
+	m.mutex.Lock()
+	due := !m.nextBoot.IsZero() && !m.clock.Now().Before(m.nextBoot)
+	reason := m.reason
+	m.mutex.Unlock()
+	if due {
+		_, _ = m.Boot(reason)
+	}
+}
+
+// Solicit allows sending a single request for the given feature, while the charging station isn't accepted yet.
+// Typically invoked when accepting a TriggerMessageRequest from the CSMS.
+func (m *BootManager) Solicit(featureName string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.status != RegistrationStatusAccepted {
+		m.solicited[featureName]++
+	}
+}
+
+// Filter returns an error wrapping ErrNotAcceptedYet, if the request may not be sent in the current registration status.
+// Matches the ocppj.Client request filter.
+func (m *BootManager) Filter(request ocpp.Request) error {
+	featureName := request.GetFeatureName()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	switch m.status {
+	case RegistrationStatusAccepted:
+		return nil
+	case RegistrationStatusRejected:
+		if featureName == BootNotificationFeatureName && !m.clock.Now().Before(m.nextBoot) {
+			return nil
+		}
+		return fmt.Errorf("%w: %v not permitted before retry at %v", ErrNotAcceptedYet, featureName, m.nextBoot.Format(time.RFC3339))
+	}
+	if featureName == BootNotificationFeatureName {
+		return nil
+	}
+	if m.solicited[featureName] > 0 {
+		m.solicited[featureName]--
+		return nil
+	}
+	if m.status == "" {
+		return fmt.Errorf("%w: %v not permitted before the BootNotification", ErrNotAcceptedYet, featureName)
+	}
+	return fmt.Errorf("%w: %v not permitted while registration status is %v", ErrNotAcceptedYet, featureName, m.status)
+}
@@ -0,0 +1,348 @@
+package provisioning
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/relvacode/iso8601"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// DeviceModelVariable is a single variable of a component, as stored in a DeviceModel.
+type DeviceModelVariable struct {
+	Component       types.Component          `json:"component" validate:"required"`
+	Variable        types.Variable           `json:"variable" validate:"required"`
+	Attributes      []VariableAttribute      `json:"variableAttribute" validate:"required,min=1,max=4,dive"` // The attributes of the variable. At most one attribute per type is allowed.
+	Characteristics *VariableCharacteristics `json:"variableCharacteristics,omitempty" validate:"omitempty"` // Characteristics used for validating new values.
+	RebootRequired  bool                     `json:"rebootRequired,omitempty"`                               // If true, a new value only becomes effective after a reboot.
+}
+
+// Attribute returns the attribute of the given type. An empty type refers to the Actual attribute.
+func (v *DeviceModelVariable) Attribute(attributeType types.Attribute) (*VariableAttribute, bool) {
+	if attributeType == "" {
+		attributeType = types.AttributeActual
+	}
+	for i := range v.Attributes {
+		if v.Attributes[i].Type == attributeType {
+			return &v.Attributes[i], true
+		}
+	}
+	return nil, false
+}
+
+// ReportData converts the variable to the format used within NotifyReportRequest messages.
+// Values of WriteOnly attributes are omitted.
+func (v *DeviceModelVariable) ReportData() ReportData {
+	attributes := make([]VariableAttribute, len(v.Attributes))
+	for i, attribute := range v.Attributes {
+		if attribute.Mutability == MutabilityWriteOnly {
+			attribute.Value = ""
+		}
+		attributes[i] = attribute
+	}
+	data := ReportData{Component: v.Component, Variable: v.Variable, VariableAttribute: attributes}
+	if v.Characteristics != nil {
+		characteristics := *v.Characteristics
+		data.VariableCharacteristics = &characteristics
+	}
+	return data
+}
+
+// Component and variable names and instances are case-insensitive.
+type componentKey struct {
+	name        string
+	instance    string
+	evseID      int
+	connectorID int
+}
+
+type variableKey struct {
+	component componentKey
+	name      string
+	instance  string
+}
+
+func newComponentKey(component types.Component) componentKey {
+	key := componentKey{name: strings.ToLower(component.Name), instance: strings.ToLower(component.Instance), evseID: -1, connectorID: -1}
+	if component.EVSE != nil {
+		key.evseID = component.EVSE.ID
+		if component.EVSE.ConnectorID != nil {
+			key.connectorID = *component.EVSE.ConnectorID
+		}
+	}
+	return key
+}
+
+func newVariableKey(component types.Component, variable types.Variable) variableKey {
+	return variableKey{component: newComponentKey(component), name: strings.ToLower(variable.Name), instance: strings.ToLower(variable.Instance)}
+}
+
+// DeviceModel stores the components and variables of a charging station, as defined by the OCPP 2.0.1 device model.
+//
+// Variables are identified by their component (name, instance and EVSE) and by their own name and instance.
+// Each variable holds up to four attributes (Actual, Target, MinSet, MaxSet) and optional characteristics,
+// which are used for validating values set by the CSMS.
+//
+// A DeviceModelHandler may be used for answering GetBaseReport, GetReport, GetVariables and SetVariables requests
+// directly from the model.
+//
+// A DeviceModel is safe for concurrent use.
+type DeviceModel struct {
+	variables  map[variableKey]*DeviceModelVariable
+	components map[componentKey]int
+	order      []variableKey
+	mutex      sync.RWMutex
+}
+
+// NewDeviceModel creates an empty device model.
+func NewDeviceModel() *DeviceModel {
+	return &DeviceModel{
+		variables:  map[variableKey]*DeviceModelVariable{},
+		components: map[componentKey]int{},
+	}
+}
+
+// LoadDeviceModel creates a device model from a JSON seed, containing an array of DeviceModelVariable objects.
+func LoadDeviceModel(r io.Reader) (*DeviceModel, error) {
+	var variables []DeviceModelVariable
+	if err := json.NewDecoder(r).Decode(&variables); err != nil {
+		return nil, fmt.Errorf("couldn't decode device model: %w", err)
+	}
+	model := NewDeviceModel()
+	for _, variable := range variables {
+		if err := model.AddVariable(variable); err != nil {
+			return nil, err
+		}
+	}
+	return model, nil
+}
+
+// LoadDeviceModelFile creates a device model from a JSON seed file. See LoadDeviceModel for the expected format.
+func LoadDeviceModelFile(path string) (*DeviceModel, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadDeviceModel(f)
+}
+
+// AddVariable adds a variable to the model, replacing any existing variable with the same key.
+// Attributes without a type default to Actual, attributes without mutability default to ReadWrite.
+func (m *DeviceModel) AddVariable(variable DeviceModelVariable) error {
+	attributes := make([]VariableAttribute, len(variable.Attributes))
+	seen := map[types.Attribute]bool{}
+	for i, attribute := range variable.Attributes {
+		if attribute.Type == "" {
+			attribute.Type = types.AttributeActual
+		}
+		if attribute.Mutability == "" {
+			attribute.Mutability = MutabilityReadWrite
+		}
+		if seen[attribute.Type] {
+			return fmt.Errorf("duplicate attribute %v for variable %v of component %v", attribute.Type, variable.Variable.Name, variable.Component.Name)
+		}
+		seen[attribute.Type] = true
+		attributes[i] = attribute
+	}
+	variable.Attributes = attributes
+	if variable.Characteristics != nil {
+		characteristics := *variable.Characteristics
+		variable.Characteristics = &characteristics
+	}
+	if err := types.Validate.Struct(variable); err != nil {
+		return fmt.Errorf("invalid variable %v of component %v: %w", variable.Variable.Name, variable.Component.Name, err)
+	}
+	key := newVariableKey(variable.Component, variable.Variable)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, ok := m.variables[key]; !ok {
+		m.order = append(m.order, key)
+		m.components[key.component]++
+	}
+	m.variables[key] = &variable
+	return nil
+}
+
+// Variable returns a copy of the variable identified by the component and variable.
+func (m *DeviceModel) Variable(component types.Component, variable types.Variable) (DeviceModelVariable, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	v, ok := m.variables[newVariableKey(component, variable)]
+	if !ok {
+		return DeviceModelVariable{}, false
+	}
+	return copyVariable(v), true
+}
+
+// Variables returns a copy of all variables, in the order they were added, for which the filter returns true.
+// A nil filter matches all variables.
+func (m *DeviceModel) Variables(filter func(variable *DeviceModelVariable) bool) []DeviceModelVariable {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	var result []DeviceModelVariable
+	for _, key := range m.order {
+		v := m.variables[key]
+		if filter == nil || filter(v) {
+			result = append(result, copyVariable(v))
+		}
+	}
+	return result
+}
+
+func copyVariable(v *DeviceModelVariable) DeviceModelVariable {
+	result := *v
+	result.Attributes = append([]VariableAttribute(nil), v.Attributes...)
+	if v.Characteristics != nil {
+		characteristics := *v.Characteristics
+		result.Characteristics = &characteristics
+	}
+	return result
+}
+
+// GetAttributeValue returns the value of a variable attribute, as requested via a GetVariablesRequest.
+// An empty attribute type refers to the Actual attribute.
+func (m *DeviceModel) GetAttributeValue(component types.Component, variable types.Variable, attributeType types.Attribute) (string, GetVariableStatus) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	v, status := m.lookup(component, variable)
+	if v == nil {
+		return "", GetVariableStatus(status)
+	}
+	attribute, ok := v.Attribute(attributeType)
+	if !ok {
+		return "", GetVariableStatusNotSupported
+	}
+	if attribute.Mutability == MutabilityWriteOnly {
+		return "", GetVariableStatusRejected
+	}
+	return attribute.Value, GetVariableStatusAccepted
+}
+
+// SetAttributeValue sets the value of a variable attribute, as requested via a SetVariablesRequest.
+// An empty attribute type refers to the Actual attribute.
+//
+// The value is validated against the variable characteristics (data type, limits and values list)
+// and, for the Actual attribute, against the MinSet and MaxSet attributes.
+// Rejected values are left unchanged and the returned status info describes the reason.
+// If the variable requires a reboot, the value is stored and RebootRequired is returned.
+func (m *DeviceModel) SetAttributeValue(component types.Component, variable types.Variable, attributeType types.Attribute, value string) (SetVariableStatus, *types.StatusInfo) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	v, status := m.lookup(component, variable)
+	if v == nil {
+		return SetVariableStatus(status), nil
+	}
+	attribute, ok := v.Attribute(attributeType)
+	if !ok {
+		return SetVariableStatusNotSupported, nil
+	}
+	if attribute.Mutability == MutabilityReadOnly || attribute.Constant {
+		return SetVariableStatusRejected, types.NewStatusInfo("ReadOnly", "")
+	}
+	if err := validateAttributeValue(v, attribute.Type, value); err != nil {
+		return SetVariableStatusRejected, types.NewStatusInfo("InvalidValue", err.Error())
+	}
+	attribute.Value = value
+	if v.RebootRequired {
+		return SetVariableStatusRebootRequired, nil
+	}
+	return SetVariableStatusAccepted, nil
+}
+
+// Returns the variable, or the status to report if either the component or the variable is unknown.
+func (m *DeviceModel) lookup(component types.Component, variable types.Variable) (*DeviceModelVariable, string) {
+	v, ok := m.variables[newVariableKey(component, variable)]
+	if ok {
+		return v, ""
+	}
+	if m.components[newComponentKey(component)] == 0 {
+		return nil, string(GetVariableStatusUnknownComponent)
+	}
+	return nil, string(GetVariableStatusUnknownVariable)
+}
+
+func validateAttributeValue(v *DeviceModelVariable, attributeType types.Attribute, value string) error {
+	characteristics := v.Characteristics
+	if characteristics == nil {
+		return nil
+	}
+	var number *float64
+	switch characteristics.DataType {
+	case TypeInteger:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%v is not an integer", value)
+		}
+		f := float64(n)
+		number = &f
+	case TypeDecimal:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%v is not a decimal", value)
+		}
+		number = &f
+	case TypeBoolean:
+		if value != "true" && value != "false" {
+			return fmt.Errorf("%v is not a boolean", value)
+		}
+	case TypeDateTime:
+		if _, err := iso8601.ParseString(value); err != nil {
+			return fmt.Errorf("%v is not a dateTime", value)
+		}
+	case TypeOptionList:
+		if !inValuesList(characteristics.ValuesList, value) {
+			return fmt.Errorf("%v is not one of %v", value, characteristics.ValuesList)
+		}
+	case TypeMemberList, TypeSequenceList:
+		if value != "" {
+			for _, member := range strings.Split(value, ",") {
+				if !inValuesList(characteristics.ValuesList, member) {
+					return fmt.Errorf("%v is not one of %v", member, characteristics.ValuesList)
+				}
+			}
+		}
+	}
+	if number != nil {
+		if characteristics.MinLimit != nil && *number < *characteristics.MinLimit {
+			return fmt.Errorf("%v is lower than the minimum limit %v", value, *characteristics.MinLimit)
+		}
+		if characteristics.MaxLimit != nil && *number > *characteristics.MaxLimit {
+			return fmt.Errorf("%v is greater than the maximum limit %v", value, *characteristics.MaxLimit)
+		}
+		if attributeType == types.AttributeActual {
+			if minSet, ok := v.Attribute(types.AttributeMinSet); ok {
+				if bound, err := strconv.ParseFloat(minSet.Value, 64); err == nil && *number < bound {
+					return fmt.Errorf("%v is lower than MinSet %v", value, minSet.Value)
+				}
+			}
+			if maxSet, ok := v.Attribute(types.AttributeMaxSet); ok {
+				if bound, err := strconv.ParseFloat(maxSet.Value, 64); err == nil && *number > bound {
+					return fmt.Errorf("%v is greater than MaxSet %v", value, maxSet.Value)
+				}
+			}
+		}
+	} else if characteristics.MaxLimit != nil && float64(len(value)) > *characteristics.MaxLimit {
+		// For non-numeric types, the max limit defines the maximum length
+		return fmt.Errorf("value exceeds the maximum length %v", *characteristics.MaxLimit)
+	}
+	return nil
+}
+
+func inValuesList(valuesList string, value string) bool {
+	if valuesList == "" {
+		return true
+	}
+	for _, allowed := range strings.Split(valuesList, ",") {
+		if strings.TrimSpace(allowed) == value {
+			return true
+		}
+	}
+	return false
+}
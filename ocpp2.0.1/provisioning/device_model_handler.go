@@ -0,0 +1,208 @@
+package provisioning
+
+import (
+	"strings"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// The default amount of ReportData elements sent within a single NotifyReportRequest.
+const DefaultReportPageSize = 20
+
+// DeviceModelHandler implements the GetBaseReport, GetReport, GetVariables and SetVariables handler methods
+// of the ChargingStationHandler interface directly from a DeviceModel.
+//
+// The handler is meant to be embedded into a charging station handler, which implements the remaining methods:
+//
+//	type ProvisioningHandler struct {
+//		*provisioning.DeviceModelHandler
+//	}
+//
+//	func (h *ProvisioningHandler) OnReset(request *provisioning.ResetRequest) (*provisioning.ResetResponse, error) { ... }
+//
+// Reports are sent asynchronously via the SendReport function, after the response was returned.
+// Typically, SendReport invokes the NotifyReport function of the charging station.
+type DeviceModelHandler struct {
+	Model *DeviceModel
+	// Invoked for each report part. If an error is returned, the remaining parts are not sent.
+	SendReport func(request *NotifyReportRequest) error
+	// Invoked if a report part couldn't be sent. Optional.
+	OnReportError func(requestID int, err error)
+	// The maximum amount of ReportData elements per NotifyReportRequest.
+	ReportPageSize int
+	// Invoked for every value accepted via a SetVariablesRequest, after it was stored in the model. Optional.
+	// Allows applying new settings at runtime, e.g. the heartbeat interval.
+	OnVariableSet func(component types.Component, variable types.Variable, attributeType types.Attribute, value string)
+}
+
+// NewDeviceModelHandler creates a new handler for the given model, using the default report page size.
+func NewDeviceModelHandler(model *DeviceModel, sendReport func(request *NotifyReportRequest) error) *DeviceModelHandler {
+	return &DeviceModelHandler{Model: model, SendReport: sendReport, ReportPageSize: DefaultReportPageSize}
+}
+
+func (h *DeviceModelHandler) OnGetBaseReport(request *GetBaseReportRequest) (*GetBaseReportResponse, error) {
+	var filter func(variable *DeviceModelVariable) bool
+	switch request.ReportBase {
+	case ReportTypeFullInventory:
+	case ReportTypeConfigurationInventory:
+		filter = isConfigurable
+	default:
+		return NewGetBaseReportResponse(types.GenericDeviceModelStatusNotSupported), nil
+	}
+	status := h.report(request.RequestID, h.Model.Variables(filter))
+	return NewGetBaseReportResponse(status), nil
+}
+
+func (h *DeviceModelHandler) OnGetReport(request *GetReportRequest) (*GetReportResponse, error) {
+	requestID := 0
+	if request.RequestID != nil {
+		requestID = *request.RequestID
+	}
+	variables := h.Model.Variables(func(variable *DeviceModelVariable) bool {
+		if len(request.ComponentVariable) == 0 {
+			return true
+		}
+		for _, cv := range request.ComponentVariable {
+			if matchComponent(cv.Component, variable.Component) && matchVariable(cv.Variable, variable.Variable) {
+				return true
+			}
+		}
+		return false
+	})
+	if len(request.ComponentCriteria) > 0 {
+		variables = h.filterByCriteria(variables, request.ComponentCriteria)
+	}
+	status := h.report(requestID, variables)
+	return NewGetReportResponse(status), nil
+}
+
+func (h *DeviceModelHandler) OnGetVariables(request *GetVariablesRequest) (*GetVariablesResponse, error) {
+	results := make([]GetVariableResult, len(request.GetVariableData))
+	for i, data := range request.GetVariableData {
+		value, status := h.Model.GetAttributeValue(data.Component, data.Variable, data.AttributeType)
+		results[i] = GetVariableResult{
+			AttributeStatus: status,
+			AttributeType:   data.AttributeType,
+			AttributeValue:  value,
+			Component:       data.Component,
+			Variable:        data.Variable,
+		}
+	}
+	return NewGetVariablesResponse(results), nil
+}
+
+func (h *DeviceModelHandler) OnSetVariables(request *SetVariablesRequest) (*SetVariablesResponse, error) {
+	results := make([]SetVariableResult, len(request.SetVariableData))
+	for i, data := range request.SetVariableData {
+		status, statusInfo := h.Model.SetAttributeValue(data.Component, data.Variable, data.AttributeType, data.AttributeValue)
+		results[i] = SetVariableResult{
+			AttributeType:   data.AttributeType,
+			AttributeStatus: status,
+			Component:       data.Component,
+			Variable:        data.Variable,
+			StatusInfo:      statusInfo,
+		}
+		if status == SetVariableStatusAccepted && h.OnVariableSet != nil {
+			h.OnVariableSet(data.Component, data.Variable, data.AttributeType, data.AttributeValue)
+		}
+	}
+	return NewSetVariablesResponse(results), nil
+}
+
+// Starts sending the report asynchronously and returns the status to reply with.
+func (h *DeviceModelHandler) report(requestID int, variables []DeviceModelVariable) types.GenericDeviceModelStatus {
+	if len(variables) == 0 {
+		return types.GenericDeviceModelStatusEmptyResultSet
+	}
+	if h.SendReport == nil {
+		return types.GenericDeviceModelStatusRejected
+	}
+	pageSize := h.ReportPageSize
+	if pageSize <= 0 {
+		pageSize = DefaultReportPageSize
+	}
+	var pages []*NotifyReportRequest
+	for start := 0; start < len(variables); start += pageSize {
+		end := start + pageSize
+		if end > len(variables) {
+			end = len(variables)
+		}
+		page := NewNotifyReportRequest(requestID, types.Now(), len(pages))
+		for _, variable := range variables[start:end] {
+			page.ReportData = append(page.ReportData, variable.ReportData())
+		}
+		page.Tbc = end < len(variables)
+		pages = append(pages, page)
+	}
+	go func() {
+		for _, page := range pages {
+			if err := h.SendReport(page); err != nil {
+				if h.OnReportError != nil {
+					h.OnReportError(requestID, err)
+				}
+				return
+			}
+		}
+	}()
+	return types.GenericDeviceModelStatusAccepted
+}
+
+// A component matches a component criterion, if it contains a variable named after the criterion, with an Actual value of true.
+func (h *DeviceModelHandler) filterByCriteria(variables []DeviceModelVariable, criteria []ComponentCriterion) []DeviceModelVariable {
+	matching := map[componentKey]bool{}
+	for _, variable := range h.Model.Variables(nil) {
+		for _, criterion := range criteria {
+			if !strings.EqualFold(variable.Variable.Name, string(criterion)) {
+				continue
+			}
+			if attribute, ok := variable.Attribute(types.AttributeActual); ok && attribute.Value == "true" {
+				matching[newComponentKey(variable.Component)] = true
+			}
+		}
+	}
+	var result []DeviceModelVariable
+	for _, variable := range variables {
+		if matching[newComponentKey(variable.Component)] {
+			result = append(result, variable)
+		}
+	}
+	return result
+}
+
+// Variables are configurable, if at least one of their attributes may be written.
+func isConfigurable(variable *DeviceModelVariable) bool {
+	for _, attribute := range variable.Attributes {
+		if attribute.Mutability != MutabilityReadOnly && !attribute.Constant {
+			return true
+		}
+	}
+	return false
+}
+
+// Omitted instances and EVSEs in a requested component match all instances and EVSEs.
+func matchComponent(requested types.Component, component types.Component) bool {
+	if !strings.EqualFold(requested.Name, component.Name) {
+		return false
+	}
+	if requested.Instance != "" && !strings.EqualFold(requested.Instance, component.Instance) {
+		return false
+	}
+	if requested.EVSE == nil {
+		return true
+	}
+	if component.EVSE == nil || requested.EVSE.ID != component.EVSE.ID {
+		return false
+	}
+	if requested.EVSE.ConnectorID == nil {
+		return true
+	}
+	return component.EVSE.ConnectorID != nil && *requested.EVSE.ConnectorID == *component.EVSE.ConnectorID
+}
+
+// An omitted variable instance matches all instances.
+func matchVariable(requested types.Variable, variable types.Variable) bool {
+	if !strings.EqualFold(requested.Name, variable.Name) {
+		return false
+	}
+	return requested.Instance == "" || strings.EqualFold(requested.Instance, variable.Instance)
+}
@@ -33,28 +33,32 @@ func isValidGetVariableStatus(fl validator.FieldLevel) bool {
 }
 
 type GetVariableData struct {
-	AttributeType types.Attribute `json:"attributeType,omitempty" validate:"omitempty,attribute"`
-	Component     types.Component `json:"component" validate:"required"`
-	Variable      types.Variable  `json:"variable" validate:"required"`
+	AttributeType types.Attribute   `json:"attributeType,omitempty" validate:"omitempty,attribute"`
+	Component     types.Component   `json:"component" validate:"required"`
+	Variable      types.Variable    `json:"variable" validate:"required"`
+	CustomData    *types.CustomData `json:"customData,omitempty" validate:"omitempty"`
 }
 
 type GetVariableResult struct {
 	AttributeStatus GetVariableStatus `json:"attributeStatus" validate:"required,getVariableStatus"`
 	AttributeType   types.Attribute   `json:"attributeType,omitempty" validate:"omitempty,attribute"`
-	AttributeValue  string            `json:"attributeValue,omitempty" validate:"omitempty,max=1000"`
+	AttributeValue  string            `json:"attributeValue,omitempty" validate:"omitempty,max=2500"`
 	Component       types.Component   `json:"component" validate:"required"`
 	Variable        types.Variable    `json:"variable" validate:"required"`
+	CustomData      *types.CustomData `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // The field definition of the GetVariables request payload sent by the CSMS to the Charging Station.
 type GetVariablesRequest struct {
 	GetVariableData []GetVariableData `json:"getVariableData" validate:"required,min=1,dive"`
+	CustomData      *types.CustomData `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // This field definition of the GetVariables response payload, sent by the Charging Station to the CSMS in response to a GetVariablesRequest.
 // In case the request was invalid, or couldn't be processed, an error will be sent instead.
 type GetVariablesResponse struct {
 	GetVariableResult []GetVariableResult `json:"getVariableResult" validate:"required,min=1,dive"`
+	CustomData        *types.CustomData   `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // The CSO may trigger the CSMS to request to request for a number of variables in a Charging Station.
@@ -86,14 +90,14 @@ func (c GetVariablesResponse) GetFeatureName() string {
 
 // Creates a new GetVariablesRequest, containing all required fields.  There are no optional fields for this message.
 func NewGetVariablesRequest(variableData []GetVariableData) *GetVariablesRequest {
-	return &GetVariablesRequest{variableData}
+	return &GetVariablesRequest{GetVariableData: variableData}
 }
 
 // Creates a new GetVariablesResponse, containing all required fields. There are no optional fields for this message.
 func NewGetVariablesResponse(result []GetVariableResult) *GetVariablesResponse {
-	return &GetVariablesResponse{result}
+	return &GetVariablesResponse{GetVariableResult: result}
 }
 
 func init() {
-	_ = types.Validate.RegisterValidation("getVariableStatus", isValidGetVariableStatus)
+	_ = types.RegisterValidation("getVariableStatus", isValidGetVariableStatus)
 }
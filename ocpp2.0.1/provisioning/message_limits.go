@@ -0,0 +1,223 @@
+package provisioning
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/localauth"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// ErrVersionNumberTooLow is returned when splitting a SendLocalListRequest, whose version number is lower than the amount of resulting requests.
+var ErrVersionNumberTooLow = errors.New("version number too low for splitting the local authorization list")
+
+// MessageLimits contains the maximum amount of items and the maximum serialized size (in bytes) of a single message,
+// as reported by a charging station via the ItemsPerMessage* and BytesPerMessage* device model variables.
+//
+// A zero value means that no limit applies.
+type MessageLimits struct {
+	ItemsPerMessageGetVariables  int
+	BytesPerMessageGetVariables  int
+	ItemsPerMessageSetVariables  int
+	BytesPerMessageSetVariables  int
+	ItemsPerMessageGetReport     int
+	BytesPerMessageGetReport     int
+	ItemsPerMessageSendLocalList int
+	BytesPerMessageSendLocalList int
+}
+
+// IsZero returns true if no limits are set.
+func (l MessageLimits) IsZero() bool {
+	return l == MessageLimits{}
+}
+
+// MessageLimitsFromReport extracts message limits from the Actual attribute of the respective variables,
+// as contained in a report (see ReportAssembler). Variables missing from the report result in no limit.
+func MessageLimitsFromReport(reportData []ReportData) MessageLimits {
+	var limits MessageLimits
+	fields := map[string]*int{
+		"devicedatactrlr.itemspermessagegetvariables": &limits.ItemsPerMessageGetVariables,
+		"devicedatactrlr.bytespermessagegetvariables": &limits.BytesPerMessageGetVariables,
+		"devicedatactrlr.itemspermessagesetvariables": &limits.ItemsPerMessageSetVariables,
+		"devicedatactrlr.bytespermessagesetvariables": &limits.BytesPerMessageSetVariables,
+		"devicedatactrlr.itemspermessagegetreport":    &limits.ItemsPerMessageGetReport,
+		"devicedatactrlr.bytespermessagegetreport":    &limits.BytesPerMessageGetReport,
+		"localauthlistctrlr.itemspermessage":          &limits.ItemsPerMessageSendLocalList,
+		"localauthlistctrlr.bytespermessage":          &limits.BytesPerMessageSendLocalList,
+	}
+	for _, data := range reportData {
+		field, ok := fields[strings.ToLower(data.Component.Name+"."+data.Variable.Name)]
+		if !ok {
+			continue
+		}
+		for _, attribute := range data.VariableAttribute {
+			if attribute.Type != "" && attribute.Type != types.AttributeActual {
+				continue
+			}
+			if value, err := strconv.Atoi(attribute.Value); err == nil && value > 0 {
+				*field = value
+			}
+		}
+	}
+	return limits
+}
+
+// Partitions items into chunks, such that each chunk contains at most maxItems items
+// and the serialized message containing the chunk is at most maxBytes long.
+// The overhead is the serialized size of the message, excluding the items. Items exceeding the byte budget on their own are placed in a separate chunk.
+func partition[T any](items []T, maxItems int, maxBytes int, overhead int) [][]T {
+	var chunks [][]T
+	var current []T
+	size := overhead
+	for _, item := range items {
+		itemSize := 0
+		if maxBytes > 0 {
+			raw, _ := json.Marshal(item)
+			itemSize = len(raw)
+		}
+		// Items are separated by a comma
+		separator := 0
+		if len(current) > 0 {
+			separator = 1
+		}
+		fitsItems := maxItems <= 0 || len(current) < maxItems
+		fitsBytes := maxBytes <= 0 || size+separator+itemSize <= maxBytes
+		if len(current) > 0 && (!fitsItems || !fitsBytes) {
+			chunks = append(chunks, current)
+			current = nil
+			size = overhead
+			separator = 0
+		}
+		current = append(current, item)
+		size += separator + itemSize
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, current)
+	}
+	return chunks
+}
+
+// Returns the serialized size of the message containing a single item, excluding the item itself.
+func messageOverhead[T any](message interface{}, item T) int {
+	rawMessage, _ := json.Marshal(message)
+	rawItem, _ := json.Marshal(item)
+	return len(rawMessage) - len(rawItem)
+}
+
+// SplitGetVariables partitions a request into multiple requests, respecting the GetVariables message limits.
+// The original request is returned as is, if no split is needed.
+func SplitGetVariables(request *GetVariablesRequest, limits MessageLimits) []*GetVariablesRequest {
+	items := request.GetVariableData
+	if len(items) == 0 {
+		return []*GetVariablesRequest{request}
+	}
+	single := *request
+	single.GetVariableData = items[:1]
+	chunks := partition(items, limits.ItemsPerMessageGetVariables, limits.BytesPerMessageGetVariables, messageOverhead(single, items[0]))
+	if len(chunks) == 1 {
+		return []*GetVariablesRequest{request}
+	}
+	requests := make([]*GetVariablesRequest, len(chunks))
+	for i, chunk := range chunks {
+		r := *request
+		r.GetVariableData = chunk
+		requests[i] = &r
+	}
+	return requests
+}
+
+// SplitSetVariables partitions a request into multiple requests, respecting the SetVariables message limits.
+// The original request is returned as is, if no split is needed.
+func SplitSetVariables(request *SetVariablesRequest, limits MessageLimits) []*SetVariablesRequest {
+	items := request.SetVariableData
+	if len(items) == 0 {
+		return []*SetVariablesRequest{request}
+	}
+	single := *request
+	single.SetVariableData = items[:1]
+	chunks := partition(items, limits.ItemsPerMessageSetVariables, limits.BytesPerMessageSetVariables, messageOverhead(single, items[0]))
+	if len(chunks) == 1 {
+		return []*SetVariablesRequest{request}
+	}
+	requests := make([]*SetVariablesRequest, len(chunks))
+	for i, chunk := range chunks {
+		r := *request
+		r.SetVariableData = chunk
+		requests[i] = &r
+	}
+	return requests
+}
+
+// SplitGetReport partitions the componentVariable criteria of a request into multiple requests,
+// respecting the GetReport message limits. The original request is returned as is, if no split is needed.
+//
+// Since every request triggers a separate report, each additional request is assigned a consecutive requestId,
+// starting from the requestId of the original request.
+func SplitGetReport(request *GetReportRequest, limits MessageLimits) []*GetReportRequest {
+	items := request.ComponentVariable
+	if len(items) == 0 {
+		return []*GetReportRequest{request}
+	}
+	single := *request
+	single.ComponentVariable = items[:1]
+	chunks := partition(items, limits.ItemsPerMessageGetReport, limits.BytesPerMessageGetReport, messageOverhead(single, items[0]))
+	if len(chunks) == 1 {
+		return []*GetReportRequest{request}
+	}
+	requestID := 0
+	if request.RequestID != nil {
+		requestID = *request.RequestID
+	}
+	requests := make([]*GetReportRequest, len(chunks))
+	for i, chunk := range chunks {
+		r := *request
+		r.ComponentVariable = chunk
+		id := requestID + i
+		r.RequestID = &id
+		requests[i] = &r
+	}
+	return requests
+}
+
+// SplitSendLocalList partitions the authorization list of a request into multiple requests,
+// respecting the SendLocalList message limits. The original request is returned as is, if no split is needed.
+//
+// If the original request is a Full update, only the first request is a Full update,
+// while the following ones are Differential updates. The requests must therefore be sent in order.
+//
+// Since a charging station answers VersionMismatch to Differential updates not increasing its list version,
+// the requests carry consecutive version numbers, ending with the version number of the original request.
+// A charging station therefore only reports the requested version once all requests were applied.
+// An error wrapping ErrVersionNumberTooLow is returned, if the requested version isn't high enough for numbering all requests.
+func SplitSendLocalList(request *localauth.SendLocalListRequest, limits MessageLimits) ([]*localauth.SendLocalListRequest, error) {
+	items := request.LocalAuthorizationList
+	if len(items) == 0 {
+		return []*localauth.SendLocalListRequest{request}, nil
+	}
+	single := *request
+	single.LocalAuthorizationList = items[:1]
+	// Differential updates are slightly longer than full updates
+	single.UpdateType = localauth.UpdateTypeDifferential
+	chunks := partition(items, limits.ItemsPerMessageSendLocalList, limits.BytesPerMessageSendLocalList, messageOverhead(single, items[0]))
+	if len(chunks) == 1 {
+		return []*localauth.SendLocalListRequest{request}, nil
+	}
+	firstVersion := request.VersionNumber - len(chunks) + 1
+	if firstVersion < 1 {
+		return nil, fmt.Errorf("%w: version %d for %d requests", ErrVersionNumberTooLow, request.VersionNumber, len(chunks))
+	}
+	requests := make([]*localauth.SendLocalListRequest, len(chunks))
+	for i, chunk := range chunks {
+		r := *request
+		r.LocalAuthorizationList = chunk
+		r.VersionNumber = firstVersion + i
+		if i > 0 {
+			r.UpdateType = localauth.UpdateTypeDifferential
+		}
+		requests[i] = &r
+	}
+	return requests, nil
+}
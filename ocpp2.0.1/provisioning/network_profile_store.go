@@ -0,0 +1,189 @@
+package provisioning
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// NetworkProfileStorage persists the network connection profiles of a charging station, keyed by configuration slot.
+// Implementations don't need to be safe for concurrent use, as they are only accessed by a single NetworkProfileStore.
+//
+// To retain the profiles across reboots, provide a storage backed by persistent memory.
+type NetworkProfileStorage interface {
+	// Save replaces the stored profiles. The operation must be atomic:
+	// if an error is returned, the previously stored profiles must be retained.
+	Save(profiles map[int]NetworkConnectionProfile) error
+	// Load returns the stored profiles.
+	Load() (map[int]NetworkConnectionProfile, error)
+}
+
+type memoryNetworkProfileStorage struct {
+	profiles map[int]NetworkConnectionProfile
+}
+
+// NewMemoryNetworkProfileStorage creates a non-persistent NetworkProfileStorage, keeping the profiles in memory.
+func NewMemoryNetworkProfileStorage() NetworkProfileStorage {
+	return &memoryNetworkProfileStorage{}
+}
+
+func (s *memoryNetworkProfileStorage) Save(profiles map[int]NetworkConnectionProfile) error {
+	s.profiles = profiles
+	return nil
+}
+
+func (s *memoryNetworkProfileStorage) Load() (map[int]NetworkConnectionProfile, error) {
+	return s.profiles, nil
+}
+
+// NetworkProfileStore keeps the network connection profiles of a charging station, as provisioned by the CSMS.
+//
+// OnSetNetworkProfile matches the respective ChargingStationHandler method, so a provisioning handler may delegate to it directly.
+// A request is rejected if its configuration slot isn't supported, if it would downgrade the active security profile,
+// or if its OCPP version or transport isn't supported by this library. If the storage fails, Failed is returned.
+//
+// The stored profiles are used in the order defined by the NetworkConfigurationPriority variable (see SetPriority).
+// To let the charging station reconnect with the configured profiles, pass CandidateURLs to the websocket client,
+// if it implements ws.FailoverClient (as the default ws.Client does):
+//
+//	if failover, ok := wsClient.(ws.FailoverClient); ok {
+//		failover.SetFailoverURLs(func() []string { return store.CandidateURLs(chargingStationID) })
+//	}
+//
+// A NetworkProfileStore is safe for concurrent use.
+type NetworkProfileStore struct {
+	// Invoked after a profile was stored. Optional.
+	OnProfileChanged func(configurationSlot int, profile NetworkConnectionProfile)
+	storage          NetworkProfileStorage
+	slots            []int
+	priority         []int
+	securityProfile  int
+	profiles         map[int]NetworkConnectionProfile
+	mutex            sync.Mutex
+}
+
+// NewNetworkProfileStore creates a store with the passed configuration slots, corresponding to the valuesList
+// of the NetworkConfigurationPriority variable. Initially, the slots are prioritized in the passed order.
+// Previously stored profiles are loaded from the storage.
+func NewNetworkProfileStore(storage NetworkProfileStorage, slots []int) (*NetworkProfileStore, error) {
+	if storage == nil {
+		storage = NewMemoryNetworkProfileStorage()
+	}
+	profiles, err := storage.Load()
+	if err != nil {
+		return nil, err
+	}
+	if profiles == nil {
+		profiles = map[int]NetworkConnectionProfile{}
+	}
+	return &NetworkProfileStore{
+		storage:  storage,
+		slots:    append([]int{}, slots...),
+		priority: append([]int{}, slots...),
+		profiles: profiles,
+	}, nil
+}
+
+// SetPriority sets the order in which the configuration slots are used, as defined by the NetworkConfigurationPriority variable.
+// Slots which are not part of the list aren't used. An error is returned if the list contains an unsupported slot.
+func (s *NetworkProfileStore) SetPriority(priority []int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, slot := range priority {
+		if !s.supportsSlot(slot) {
+			return fmt.Errorf("unsupported configuration slot %v", slot)
+		}
+	}
+	s.priority = append([]int{}, priority...)
+	return nil
+}
+
+// SetActiveSecurityProfile sets the security profile currently in use by the charging station.
+// Profiles with a lower security profile are rejected.
+func (s *NetworkProfileStore) SetActiveSecurityProfile(securityProfile int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.securityProfile = securityProfile
+}
+
+// Profile returns the profile stored in a configuration slot.
+func (s *NetworkProfileStore) Profile(configurationSlot int) (NetworkConnectionProfile, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	profile, ok := s.profiles[configurationSlot]
+	return profile, ok
+}
+
+// Candidates returns the stored profiles in priority order. Empty slots are skipped.
+func (s *NetworkProfileStore) Candidates() []NetworkConnectionProfile {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	candidates := make([]NetworkConnectionProfile, 0, len(s.priority))
+	for _, slot := range s.priority {
+		if profile, ok := s.profiles[slot]; ok {
+			candidates = append(candidates, profile)
+		}
+	}
+	return candidates
+}
+
+// CandidateURLs returns the websocket URLs of the stored profiles in priority order,
+// with the identity of the charging station appended to the CSMS URL of each profile.
+func (s *NetworkProfileStore) CandidateURLs(chargingStationID string) []string {
+	candidates := s.Candidates()
+	urls := make([]string, 0, len(candidates))
+	for _, profile := range candidates {
+		urls = append(urls, strings.TrimSuffix(profile.CSMSUrl, "/")+"/"+chargingStationID)
+	}
+	return urls
+}
+
+func (s *NetworkProfileStore) OnSetNetworkProfile(request *SetNetworkProfileRequest) (*SetNetworkProfileResponse, error) {
+	profile := request.ConnectionData
+	s.mutex.Lock()
+	switch {
+	case !s.supportsSlot(request.ConfigurationSlot):
+		s.mutex.Unlock()
+		return rejectedNetworkProfile("InvalidConfSlot"), nil
+	case profile.SecurityProfile < s.securityProfile:
+		s.mutex.Unlock()
+		return rejectedNetworkProfile("NoSecurityDowngrade"), nil
+	case profile.OCPPVersion != OCPPVersion20 || profile.OCPPTransport != OCPPTransportJSON:
+		s.mutex.Unlock()
+		return rejectedNetworkProfile("UnsupportedParam"), nil
+	}
+	profiles := make(map[int]NetworkConnectionProfile, len(s.profiles)+1)
+	for slot, p := range s.profiles {
+		profiles[slot] = p
+	}
+	profiles[request.ConfigurationSlot] = profile
+	if err := s.storage.Save(profiles); err != nil {
+		s.mutex.Unlock()
+		response := NewSetNetworkProfileResponse(SetNetworkProfileStatusFailed)
+		response.StatusInfo = types.NewStatusInfo("InternalError", "")
+		return response, nil
+	}
+	s.profiles = profiles
+	s.mutex.Unlock()
+	if s.OnProfileChanged != nil {
+		s.OnProfileChanged(request.ConfigurationSlot, profile)
+	}
+	return NewSetNetworkProfileResponse(SetNetworkProfileStatusAccepted), nil
+}
+
+func (s *NetworkProfileStore) supportsSlot(slot int) bool {
+	for _, supported := range s.slots {
+		if supported == slot {
+			return true
+		}
+	}
+	return false
+}
+
+func rejectedNetworkProfile(reasonCode string) *SetNetworkProfileResponse {
+	response := NewSetNetworkProfileResponse(SetNetworkProfileStatusRejected)
+	response.StatusInfo = types.NewStatusInfo(reasonCode, "")
+	return response
+}
@@ -2,7 +2,11 @@
 // Additionally, it contains features for retrieving information about the configuration of Charging Stations, make changes to the configuration, resetting it etc.
 package provisioning
 
-import "github.com/lorenzodonini/ocpp-go/ocpp"
+import (
+	"context"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+)
 
 // Needs to be implemented by a CSMS for handling messages part of the OCPP 2.0 Provisioning profile.
 type CSMSHandler interface {
@@ -12,6 +16,35 @@ type CSMSHandler interface {
 	OnNotifyReport(chargingStationID string, request *NotifyReportRequest) (response *NotifyReportResponse, err error)
 }
 
+// Context-aware variant of CSMSHandler, for handling messages part of the OCPP 2.0 Provisioning profile.
+type CSMSHandlerWithContext interface {
+	// OnBootNotification is called on the CSMS whenever a BootNotificationRequest is received from a charging station.
+	OnBootNotification(ctx context.Context, chargingStationID string, request *BootNotificationRequest) (response *BootNotificationResponse, err error)
+	// OnNotifyReport is called on the CSMS whenever a NotifyReportRequest is received from a charging station.
+	OnNotifyReport(ctx context.Context, chargingStationID string, request *NotifyReportRequest) (response *NotifyReportResponse, err error)
+}
+
+// AdaptCSMSHandler converts a CSMSHandler into a CSMSHandlerWithContext, which ignores the context.
+// Returns nil if handler is nil.
+func AdaptCSMSHandler(handler CSMSHandler) CSMSHandlerWithContext {
+	if handler == nil {
+		return nil
+	}
+	return csmsHandlerAdapter{handler: handler}
+}
+
+type csmsHandlerAdapter struct {
+	handler CSMSHandler
+}
+
+func (a csmsHandlerAdapter) OnBootNotification(ctx context.Context, chargingStationID string, request *BootNotificationRequest) (*BootNotificationResponse, error) {
+	return a.handler.OnBootNotification(chargingStationID, request)
+}
+
+func (a csmsHandlerAdapter) OnNotifyReport(ctx context.Context, chargingStationID string, request *NotifyReportRequest) (*NotifyReportResponse, error) {
+	return a.handler.OnNotifyReport(chargingStationID, request)
+}
+
 // Needs to be implemented by Charging stations for handling messages part of the OCPP 2.0 Provisioning profile.
 type ChargingStationHandler interface {
 	// OnGetBaseReport is called on a charging station whenever a GetBaseReportRequest is received from the CSMS.
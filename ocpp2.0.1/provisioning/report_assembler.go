@@ -0,0 +1,236 @@
+package provisioning
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/clock"
+)
+
+const (
+	// The default time after which an incomplete report is discarded, if no further part was received.
+	DefaultReportInactivityTimeout = 60 * time.Second
+	// The default maximum amount of ReportData elements buffered per charging station, across all pending reports.
+	DefaultMaxReportDataPerStation = 10000
+	// The default maximum amount of concurrently pending reports per charging station.
+	DefaultMaxPendingReportsPerStation = 8
+)
+
+// ReportSequenceError is returned when a report part is received out of sequence, i.e. one or more parts were skipped.
+type ReportSequenceError struct {
+	ChargingStationID string
+	RequestID         int
+	ExpectedSeqNo     int
+	ReceivedSeqNo     int
+}
+
+func (e *ReportSequenceError) Error() string {
+	return fmt.Sprintf("report %v from %v: expected seqNo %v, received %v", e.RequestID, e.ChargingStationID, e.ExpectedSeqNo, e.ReceivedSeqNo)
+}
+
+// PartialReportError is passed to the completion handler when a report is incomplete,
+// because no further part was received before the inactivity timeout.
+type PartialReportError struct {
+	ChargingStationID string
+	RequestID         int
+	ReceivedParts     int
+}
+
+func (e *PartialReportError) Error() string {
+	return fmt.Sprintf("report %v from %v: incomplete after %v parts", e.RequestID, e.ChargingStationID, e.ReceivedParts)
+}
+
+// ReportLimitError is returned when a report would exceed the buffering limits of a charging station.
+type ReportLimitError struct {
+	ChargingStationID string
+	RequestID         int
+	Reason            string
+}
+
+func (e *ReportLimitError) Error() string {
+	return fmt.Sprintf("report %v from %v: %v", e.RequestID, e.ChargingStationID, e.Reason)
+}
+
+// ReportCompletionHandler is invoked by a ReportAssembler once a report was fully received, or was aborted.
+// If err is not nil, reportData contains the parts received so far.
+type ReportCompletionHandler func(chargingStationID string, requestID int, reportData []ReportData, err error)
+
+type reportKey struct {
+	chargingStationID string
+	requestID         int
+}
+
+type pendingReport struct {
+	nextSeqNo  int
+	reportData []ReportData
+	timer      clock.Timer
+}
+
+// ReportAssembler reassembles reports sent by charging stations as a sequence of NotifyReportRequest messages,
+// e.g. in response to a GetBaseReportRequest or a GetReportRequest.
+//
+// Each received NotifyReportRequest should be passed to Add. Parts are grouped by charging station and requestId.
+// Once the last part (tbc = false) is received, the completion handler is invoked with all ReportData in order.
+//
+// A report is aborted, and the completion handler invoked with an error, if:
+//
+// - a part is missing (ReportSequenceError)
+//
+// - no further part is received within the inactivity timeout (PartialReportError)
+//
+// - the buffering limits of the charging station are exceeded (ReportLimitError)
+//
+// A ReportAssembler is safe for concurrent use.
+type ReportAssembler struct {
+	onComplete        ReportCompletionHandler
+	timeout           time.Duration
+	maxReportData     int
+	maxPendingReports int
+	reports           map[reportKey]*pendingReport
+	stationReportData map[string]int
+	stationReports    map[string]int
+	clock             clock.Clock
+	mutex             sync.Mutex
+}
+
+// NewReportAssembler creates a new assembler, invoking the passed handler for every completed or aborted report.
+// If timeout is zero, DefaultReportInactivityTimeout is used.
+func NewReportAssembler(timeout time.Duration, onComplete ReportCompletionHandler) *ReportAssembler {
+	if timeout <= 0 {
+		timeout = DefaultReportInactivityTimeout
+	}
+	return &ReportAssembler{
+		onComplete:        onComplete,
+		timeout:           timeout,
+		maxReportData:     DefaultMaxReportDataPerStation,
+		maxPendingReports: DefaultMaxPendingReportsPerStation,
+		reports:           map[reportKey]*pendingReport{},
+		stationReportData: map[string]int{},
+		stationReports:    map[string]int{},
+		clock:             clock.New(),
+	}
+}
+
+// SetLimits configures the maximum amount of ReportData elements buffered per charging station,
+// and the maximum amount of concurrently pending reports per charging station.
+func (a *ReportAssembler) SetLimits(maxReportData int, maxPendingReports int) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.maxReportData = maxReportData
+	a.maxPendingReports = maxPendingReports
+}
+
+// SetClock sets the clock used for the inactivity timeout of pending reports. Passing nil restores the real clock.
+//
+// Timers already running are not affected.
+func (a *ReportAssembler) SetClock(c clock.Clock) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.clock = clock.OrDefault(c)
+}
+
+// Add feeds a report part received from a charging station to the assembler.
+//
+// Parts with a seqNo lower than expected are treated as retransmissions and ignored.
+// If the part couldn't be added, the report is aborted and the error is returned as well as passed to the completion handler.
+func (a *ReportAssembler) Add(chargingStationID string, request *NotifyReportRequest) error {
+	if request == nil {
+		return nil
+	}
+	key := reportKey{chargingStationID: chargingStationID, requestID: request.RequestID}
+	a.mutex.Lock()
+	report, ok := a.reports[key]
+	if !ok {
+		if a.stationReports[chargingStationID] >= a.maxPendingReports {
+			a.mutex.Unlock()
+			err := &ReportLimitError{ChargingStationID: chargingStationID, RequestID: request.RequestID, Reason: "too many pending reports"}
+			a.complete(key, nil, err)
+			return err
+		}
+		report = &pendingReport{}
+		report.timer = a.clock.AfterFunc(a.timeout, func() {
+			a.onTimeout(key, report)
+		})
+		a.reports[key] = report
+		a.stationReports[chargingStationID]++
+	}
+	if request.SeqNo < report.nextSeqNo {
+		a.mutex.Unlock()
+		return nil
+	}
+	var err error
+	if request.SeqNo > report.nextSeqNo {
+		err = &ReportSequenceError{ChargingStationID: chargingStationID, RequestID: request.RequestID, ExpectedSeqNo: report.nextSeqNo, ReceivedSeqNo: request.SeqNo}
+	} else if a.stationReportData[chargingStationID]+len(request.ReportData) > a.maxReportData {
+		err = &ReportLimitError{ChargingStationID: chargingStationID, RequestID: request.RequestID, Reason: "too much buffered report data"}
+	}
+	if err != nil {
+		a.remove(key, report)
+		a.mutex.Unlock()
+		a.complete(key, report.reportData, err)
+		return err
+	}
+	report.nextSeqNo++
+	report.reportData = append(report.reportData, request.ReportData...)
+	a.stationReportData[chargingStationID] += len(request.ReportData)
+	if request.Tbc {
+		report.timer.Reset(a.timeout)
+		a.mutex.Unlock()
+		return nil
+	}
+	a.remove(key, report)
+	a.mutex.Unlock()
+	a.complete(key, report.reportData, nil)
+	return nil
+}
+
+// Discard drops all pending reports of a charging station, without invoking the completion handler.
+// This may be invoked when a charging station disconnects.
+func (a *ReportAssembler) Discard(chargingStationID string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for key, report := range a.reports {
+		if key.chargingStationID == chargingStationID {
+			a.remove(key, report)
+		}
+	}
+}
+
+// PendingReports returns the amount of incomplete reports currently buffered for a charging station.
+func (a *ReportAssembler) PendingReports(chargingStationID string) int {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.stationReports[chargingStationID]
+}
+
+func (a *ReportAssembler) onTimeout(key reportKey, report *pendingReport) {
+	a.mutex.Lock()
+	if a.reports[key] != report {
+		// Already completed
+		a.mutex.Unlock()
+		return
+	}
+	a.remove(key, report)
+	a.mutex.Unlock()
+	err := &PartialReportError{ChargingStationID: key.chargingStationID, RequestID: key.requestID, ReceivedParts: report.nextSeqNo}
+	a.complete(key, report.reportData, err)
+}
+
+// Must be invoked while holding the lock.
+func (a *ReportAssembler) remove(key reportKey, report *pendingReport) {
+	report.timer.Stop()
+	delete(a.reports, key)
+	a.stationReportData[key.chargingStationID] -= len(report.reportData)
+	a.stationReports[key.chargingStationID]--
+	if a.stationReports[key.chargingStationID] <= 0 {
+		delete(a.stationReports, key.chargingStationID)
+		delete(a.stationReportData, key.chargingStationID)
+	}
+}
+
+func (a *ReportAssembler) complete(key reportKey, reportData []ReportData, err error) {
+	if a.onComplete != nil {
+		a.onComplete(key.chargingStationID, key.requestID, reportData, err)
+	}
+}
@@ -52,15 +52,17 @@ func isValidResetStatus(fl validator.FieldLevel) bool {
 
 // The field definition of the Reset request payload sent by the CSMS to the Charging Station.
 type ResetRequest struct {
-	Type   ResetType `json:"type" validate:"resetType201"`
-	EvseID *int      `json:"evseId,omitempty" validate:"omitempty,gte=0"`
+	Type       ResetType         `json:"type" validate:"required,resetType201"`
+	EvseID     *int              `json:"evseId,omitempty" validate:"omitempty,gte=0"`
+	CustomData *types.CustomData `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // This field definition of the Reset response payload, sent by the Charging Station to the CSMS in response to a ResetRequest.
 // In case the request was invalid, or couldn't be processed, an error will be sent instead.
 type ResetResponse struct {
 	Status     ResetStatus       `json:"status" validate:"required,resetStatus201"`
-	StatusInfo *types.StatusInfo `json:"statusInfo" validate:"omitempty"`
+	StatusInfo *types.StatusInfo `json:"statusInfo,omitempty" validate:"omitempty"`
+	CustomData *types.CustomData `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // The CSO may trigger the CSMS to request a Charging Station to reset itself or an EVSE.
@@ -103,6 +105,6 @@ func NewResetResponse(status ResetStatus) *ResetResponse {
 }
 
 func init() {
-	_ = types.Validate.RegisterValidation("resetType201", isValidResetType)
-	_ = types.Validate.RegisterValidation("resetStatus201", isValidResetStatus)
+	_ = types.RegisterValidation("resetType201", isValidResetType)
+	_ = types.RegisterValidation("resetStatus201", isValidResetStatus)
 }
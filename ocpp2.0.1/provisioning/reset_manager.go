@@ -0,0 +1,197 @@
+package provisioning
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// The default maximum time spent delivering pending messages before a reset.
+const DefaultResetFlushTimeout = 10 * time.Second
+
+// ResetManager implements the reset rules of use cases B11 and B12 on the charging station side.
+//
+// OnReset matches the respective ChargingStationHandler method, so a provisioning handler may delegate to it directly.
+// The manager keeps track of ongoing transactions per EVSE, via OnTransactionEvent. A reset request targets either
+// the whole charging station, or a single EVSE if evseId is set:
+//
+//   - Immediate resets are accepted. Ongoing transactions in the targeted scope are stopped via StopTransactions first.
+//   - OnIdle resets are accepted if no transaction is ongoing in the targeted scope. Otherwise, the request is answered
+//     with Scheduled, and the reset is performed once the last transaction in the scope ended.
+//   - Requests for unknown EVSEs are rejected.
+//
+// Before invoking the reset callback, pending messages are delivered via Flush, e.g. a queued TransactionEvent(Ended)
+// or SecurityEventNotification. Resets are always performed asynchronously, after the response was returned.
+//
+// A ResetManager is safe for concurrent use.
+type ResetManager struct {
+	// Performs the reset of the charging station (nil evseID) or of a single EVSE. Required.
+	Reset func(evseID *int, resetType ResetType)
+	// Stops the ongoing transactions in the passed scope before an immediate reset, e.g. by sending a TransactionEvent(Ended)
+	// with triggerReason ResetCommand and stoppedReason ImmediateReset. A nil evseID stands for the whole charging station. Optional.
+	StopTransactions func(evseID *int)
+	// Delivers pending messages before a reset, e.g. via OfflineQueue.Flush. Optional.
+	Flush func(ctx context.Context) error
+	// The maximum time spent in Flush. The reset is performed regardless, once the timeout expired.
+	FlushTimeout time.Duration
+	// Invoked if Flush returned an error. Optional.
+	OnFlushError func(err error)
+	evses        map[int]bool
+	active       map[string]int
+	scheduled    map[int]bool
+	mutex        sync.Mutex
+}
+
+// Scope key for the whole charging station.
+const stationScope = 0
+
+// NewResetManager creates a new manager for a charging station with the passed EVSEs.
+func NewResetManager(evseIDs []int, reset func(evseID *int, resetType ResetType)) *ResetManager {
+	evses := map[int]bool{}
+	for _, id := range evseIDs {
+		evses[id] = true
+	}
+	return &ResetManager{
+		Reset:        reset,
+		FlushTimeout: DefaultResetFlushTimeout,
+		evses:        evses,
+		active:       map[string]int{},
+		scheduled:    map[int]bool{},
+	}
+}
+
+// SetTopology replaces the EVSEs passed to NewResetManager with the EVSEs of a shared station topology.
+func (m *ResetManager) SetTopology(topology *types.StationTopology) {
+	evses := map[int]bool{}
+	for _, id := range topology.EVSEIDs() {
+		evses[id] = true
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.evses = evses
+}
+
+// IsScheduled returns true if an OnIdle reset is pending for the charging station (nil evseID) or for an EVSE.
+func (m *ResetManager) IsScheduled(evseID *int) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.scheduled[scopeOf(evseID)]
+}
+
+func (m *ResetManager) OnReset(request *ResetRequest) (*ResetResponse, error) {
+	scope := scopeOf(request.EvseID)
+	m.mutex.Lock()
+	known := scope == stationScope || m.evses[scope]
+	m.mutex.Unlock()
+	if !known {
+		response := NewResetResponse(ResetStatusRejected)
+		response.StatusInfo = types.NewStatusInfo("UnknownEvse", "")
+		return response, nil
+	}
+	if m.Reset == nil {
+		return NewResetResponse(ResetStatusRejected), nil
+	}
+	m.mutex.Lock()
+	busy := !m.isIdle(scope)
+	if request.Type == ResetTypeOnIdle && busy {
+		m.scheduled[scope] = true
+		m.mutex.Unlock()
+		return NewResetResponse(ResetStatusScheduled), nil
+	}
+	m.unschedule(scope)
+	m.mutex.Unlock()
+	go m.perform(targetOf(scope), request.Type, request.Type == ResetTypeImmediate && busy)
+	return NewResetResponse(ResetStatusAccepted), nil
+}
+
+// OnTransactionEvent keeps track of the ongoing transactions per EVSE. Should be invoked with every TransactionEventRequest
+// sent by the charging station, after it was sent or queued, so that a pending Ended event is covered by Flush.
+// Once the last transaction in the scope of a scheduled reset ended, the reset is performed.
+func (m *ResetManager) OnTransactionEvent(request *transactions.TransactionEventRequest) {
+	if request == nil {
+		return
+	}
+	transactionID := request.TransactionInfo.TransactionID
+	m.mutex.Lock()
+	if request.EventType != transactions.TransactionEventEnded {
+		evseID, ok := m.active[transactionID]
+		if request.Evse != nil {
+			evseID = request.Evse.ID
+		} else if !ok {
+			evseID = stationScope
+		}
+		m.active[transactionID] = evseID
+		m.mutex.Unlock()
+		return
+	}
+	evseID, ok := m.active[transactionID]
+	if !ok {
+		m.mutex.Unlock()
+		return
+	}
+	delete(m.active, transactionID)
+	var due []int
+	if m.scheduled[stationScope] && m.isIdle(stationScope) {
+		due = append(due, stationScope)
+		m.unschedule(stationScope)
+	} else if evseID != stationScope && m.scheduled[evseID] && m.isIdle(evseID) {
+		due = append(due, evseID)
+		m.unschedule(evseID)
+	}
+	m.mutex.Unlock()
+	for _, scope := range due {
+		go m.perform(targetOf(scope), ResetTypeOnIdle, false)
+	}
+}
+
+func (m *ResetManager) perform(evseID *int, resetType ResetType, stopTransactions bool) {
+	if stopTransactions && m.StopTransactions != nil {
+		m.StopTransactions(evseID)
+	}
+	if m.Flush != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), m.FlushTimeout)
+		err := m.Flush(ctx)
+		cancel()
+		if err != nil && m.OnFlushError != nil {
+			m.OnFlushError(err)
+		}
+	}
+	m.Reset(evseID, resetType)
+}
+
+// Must be invoked while holding the lock.
+func (m *ResetManager) isIdle(scope int) bool {
+	for _, evseID := range m.active {
+		if scope == stationScope || evseID == scope {
+			return false
+		}
+	}
+	return true
+}
+
+// Must be invoked while holding the lock. A station reset covers all EVSEs.
+func (m *ResetManager) unschedule(scope int) {
+	if scope == stationScope {
+		m.scheduled = map[int]bool{}
+		return
+	}
+	delete(m.scheduled, scope)
+}
+
+// Returns nil for the whole charging station, as evseId 0 is equivalent to an omitted evseId.
+func targetOf(scope int) *int {
+	if scope == stationScope {
+		return nil
+	}
+	return &scope
+}
+
+func scopeOf(evseID *int) int {
+	if evseID == nil {
+		return stationScope
+	}
+	return *evseID
+}
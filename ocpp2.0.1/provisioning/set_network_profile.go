@@ -1,6 +1,7 @@
 package provisioning
 
 import (
+	"net/url"
 	"reflect"
 
 	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
@@ -129,12 +130,13 @@ func isValidSetNetworkProfileStatus(fl validator.FieldLevel) bool {
 
 // VPN Configuration settings.
 type VPN struct {
-	Server   string  `json:"server" validate:"required,max=512"`          // VPN Server Address.
-	User     string  `json:"user" validate:"required,max=20"`             // VPN User.
-	Group    string  `json:"group,omitempty" validate:"omitempty,max=20"` // VPN group.
-	Password string  `json:"password" validate:"required,max=20"`         // VPN Password.
-	Key      string  `json:"key" validate:"required,max=255"`             // VPN shared secret.
-	Type     VPNType `json:"type" validate:"required,vpnType"`            // Type of VPN.
+	Server     string            `json:"server" validate:"required,max=512"`          // VPN Server Address.
+	User       string            `json:"user" validate:"required,max=20"`             // VPN User.
+	Group      string            `json:"group,omitempty" validate:"omitempty,max=20"` // VPN group.
+	Password   string            `json:"password" validate:"required,max=20"`         // VPN Password.
+	Key        string            `json:"key" validate:"required,max=255"`             // VPN shared secret.
+	Type       VPNType           `json:"type" validate:"required,vpnType"`            // Type of VPN.
+	CustomData *types.CustomData `json:"customData,omitempty" validate:"omitempty"`
 }
 
 type APN struct {
@@ -145,31 +147,62 @@ type APN struct {
 	PreferredNetwork        string            `json:"preferredNetwork,omitempty" validate:"omitempty,max=6"`   // Preferred network, written as MCC and MNC concatenated.
 	UseOnlyPreferredNetwork bool              `json:"useOnlyPreferredNetwork,omitempty"`                       // Use only the preferred Network, do not dial in when not available.
 	APNAuthentication       APNAuthentication `json:"apnAuthentication" validate:"required,apnAuthentication"` // Authentication method.
+	CustomData              *types.CustomData `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // NetworkConnectionProfile defines the functional and technical parameters of a communication link.
 type NetworkConnectionProfile struct {
-	OCPPVersion     OCPPVersion   `json:"ocppVersion" validate:"required,ocppVersion"`     // The OCPP version used for this communication function.
-	OCPPTransport   OCPPTransport `json:"ocppTransport" validate:"required,ocppTransport"` // Defines the transport protocol (only OCPP-J is supported by this library).
-	CSMSUrl         string        `json:"ocppCsmsUrl" validate:"required,max=512"`         // URL of the CSMS(s) that this Charging Station communicates with.
-	MessageTimeout  int           `json:"messageTimeout" validate:"gte=-1"`                // Duration in seconds before a message send by the Charging Station via this network connection times out.
-	SecurityProfile int           `json:"securityProfile"`                                 // The security profile used when connecting to the CSMS with this NetworkConnectionProfile.
-	OCPPInterface   OCPPInterface `json:"ocppInterface" validate:"required,ocppInterface"` // Applicable Network Interface.
-	VPN             *VPN          `json:"vpn,omitempty" validate:"omitempty"`              // Settings to be used to set up the VPN connection.
-	APN             *APN          `json:"apn,omitempty" validate:"omitempty"`              // Collection of configuration data needed to make a data-connection over a cellular network.
+	OCPPVersion     OCPPVersion       `json:"ocppVersion" validate:"required,ocppVersion"`     // The OCPP version used for this communication function.
+	OCPPTransport   OCPPTransport     `json:"ocppTransport" validate:"required,ocppTransport"` // Defines the transport protocol (only OCPP-J is supported by this library).
+	CSMSUrl         string            `json:"ocppCsmsUrl" validate:"required,max=512"`         // URL of the CSMS(s) that this Charging Station communicates with.
+	MessageTimeout  int               `json:"messageTimeout" validate:"gte=-1"`                // Duration in seconds before a message send by the Charging Station via this network connection times out.
+	SecurityProfile int               `json:"securityProfile" validate:"gte=0,lte=3"`          // The security profile used when connecting to the CSMS with this NetworkConnectionProfile.
+	OCPPInterface   OCPPInterface     `json:"ocppInterface" validate:"required,ocppInterface"` // Applicable Network Interface.
+	VPN             *VPN              `json:"vpn,omitempty" validate:"omitempty"`              // Settings to be used to set up the VPN connection.
+	APN             *APN              `json:"apn,omitempty" validate:"omitempty"`              // Collection of configuration data needed to make a data-connection over a cellular network.
+	CustomData      *types.CustomData `json:"customData,omitempty" validate:"omitempty"`
+}
+
+// Security profiles 2 and 3 require a TLS connection, whereas lower profiles use an unsecured connection.
+func validateNetworkConnectionProfile(sl validator.StructLevel) {
+	profile := sl.Current().Interface().(NetworkConnectionProfile)
+	csmsURL, err := url.Parse(profile.CSMSUrl)
+	if err != nil || csmsURL.Host == "" || (csmsURL.Scheme != "ws" && csmsURL.Scheme != "wss") {
+		sl.ReportError(profile.CSMSUrl, "CSMSUrl", "ocppCsmsUrl", "websocketUrl", "")
+		return
+	}
+	if secure := profile.SecurityProfile >= 2; secure != (csmsURL.Scheme == "wss") {
+		sl.ReportError(profile.CSMSUrl, "CSMSUrl", "ocppCsmsUrl", "securityProfileScheme", "")
+	}
+}
+
+// CHAP and PAP authentication require credentials.
+func validateAPN(sl validator.StructLevel) {
+	apn := sl.Current().Interface().(APN)
+	if apn.APNAuthentication != APNAuthenticationCHAP && apn.APNAuthentication != APNAuthenticationPAP {
+		return
+	}
+	if apn.APNUsername == "" {
+		sl.ReportError(apn.APNUsername, "APNUsername", "apnUserName", "required_with_apnAuthentication", "")
+	}
+	if apn.APNPassword == "" {
+		sl.ReportError(apn.APNPassword, "APNPassword", "apnPassword", "required_with_apnAuthentication", "")
+	}
 }
 
 // The field definition of the SetNetworkProfile request payload sent by the CSMS to the Charging Station.
 type SetNetworkProfileRequest struct {
 	ConfigurationSlot int                      `json:"configurationSlot" validate:"gte=0"` // Slot in which the configuration should be stored.
 	ConnectionData    NetworkConnectionProfile `json:"connectionData" validate:"required"` // Connection details.
+	CustomData        *types.CustomData        `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // Field definition of the SetNetworkProfile response payload, sent by the Charging Station to the CSMS in response to a SetNetworkProfileRequest.
 // In case the request was invalid, or couldn't be processed, an error will be sent instead.
 type SetNetworkProfileResponse struct {
 	Status     SetNetworkProfileStatus `json:"status" validate:"required,setNetworkProfileStatus"`
-	StatusInfo *types.StatusInfo       `json:"statusInfo" validate:"omitempty"`
+	StatusInfo *types.StatusInfo       `json:"statusInfo,omitempty" validate:"omitempty"`
+	CustomData *types.CustomData       `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // The CSMS may update the connection details on the Charging Station.
@@ -212,10 +245,12 @@ func NewSetNetworkProfileResponse(status SetNetworkProfileStatus) *SetNetworkPro
 }
 
 func init() {
-	_ = types.Validate.RegisterValidation("ocppVersion", isValidOCPPVersion)
-	_ = types.Validate.RegisterValidation("ocppTransport", isValidOCPPTransport)
-	_ = types.Validate.RegisterValidation("ocppInterface", isValidOCPPInterface)
-	_ = types.Validate.RegisterValidation("vpnType", isValidVPNType)
-	_ = types.Validate.RegisterValidation("apnAuthentication", isValidAPNAuthentication)
-	_ = types.Validate.RegisterValidation("setNetworkProfileStatus", isValidSetNetworkProfileStatus)
+	_ = types.RegisterValidation("ocppVersion", isValidOCPPVersion)
+	_ = types.RegisterValidation("ocppTransport", isValidOCPPTransport)
+	_ = types.RegisterValidation("ocppInterface", isValidOCPPInterface)
+	_ = types.RegisterValidation("vpnType", isValidVPNType)
+	_ = types.RegisterValidation("apnAuthentication", isValidAPNAuthentication)
+	_ = types.RegisterValidation("setNetworkProfileStatus", isValidSetNetworkProfileStatus)
+	types.RegisterStructValidation(validateNetworkConnectionProfile, NetworkConnectionProfile{})
+	types.RegisterStructValidation(validateAPN, APN{})
 }
@@ -0,0 +1,203 @@
+package remotecontrol
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/availability"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/diagnostics"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/firmware"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/meter"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/security"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// TriggerProducer sends the message requested by a TriggerMessageRequest to the CSMS.
+// The evse is only passed for triggers referring to an EVSE (MeterValues, StatusNotification and TransactionEvent),
+// and is nil if the CSMS didn't request a specific EVSE.
+type TriggerProducer func(evse *types.EVSE) error
+
+// The feature names of the messages sent for each trigger.
+var triggerFeatureNames = map[MessageTrigger]string{
+	MessageTriggerBootNotification:                  provisioning.BootNotificationFeatureName,
+	MessageTriggerLogStatusNotification:             diagnostics.LogStatusNotificationFeatureName,
+	MessageTriggerFirmwareStatusNotification:        firmware.FirmwareStatusNotificationFeatureName,
+	MessageTriggerHeartbeat:                         availability.HeartbeatFeatureName,
+	MessageTriggerMeterValues:                       meter.MeterValuesFeatureName,
+	MessageTriggerSignChargingStationCertificate:    security.SignCertificateFeatureName,
+	MessageTriggerSignV2GCertificate:                security.SignCertificateFeatureName,
+	MessageTriggerStatusNotification:                availability.StatusNotificationFeatureName,
+	MessageTriggerTransactionEvent:                  transactions.TransactionEventFeatureName,
+	MessageTriggerSignCombinedCertificate:           security.SignCertificateFeatureName,
+	MessageTriggerPublishFirmwareStatusNotification: firmware.PublishFirmwareStatusNotificationFeatureName,
+}
+
+func isEVSETrigger(trigger MessageTrigger) bool {
+	switch trigger {
+	case MessageTriggerMeterValues, MessageTriggerStatusNotification, MessageTriggerTransactionEvent:
+		return true
+	default:
+		return false
+	}
+}
+
+// AutoResponder201 answers TriggerMessageRequest messages on the charging station side, as described by use case F06.
+//
+// The application registers a TriggerProducer per supported trigger (see Register). OnTriggerMessage matches the
+// respective ChargingStationHandler method, so a remote control handler may delegate to it directly.
+// A request is answered with:
+//
+// - NotImplemented, if no producer is registered for the requested message
+//
+// - Rejected, if the requested EVSE or connector doesn't exist, or if a BootNotification was requested
+// after the charging station was already accepted
+//
+// - Accepted otherwise, in which case the producer is invoked in the background
+//
+// The existing charging station managers may be wired in via UseBootManager, UseStationManager and UseLogUploadManager,
+// so triggered messages reflect their current state. The layout of the station may alternatively be set via SetTopology.
+//
+// An AutoResponder201 is safe for concurrent use.
+type AutoResponder201 struct {
+	// Invoked whenever a producer failed to send the triggered message. Optional.
+	OnSendError func(trigger MessageTrigger, err error)
+	validate    func(evseID int, connectorID int) string
+	bootManager *provisioning.BootManager
+	producers   map[MessageTrigger]TriggerProducer
+	mutex       sync.Mutex
+}
+
+// NewAutoResponder201 creates a new responder for a charging station with the given amount of connectors per EVSE id.
+// If connectorsPerEVSE is nil, requested EVSEs aren't validated, unless a StationManager is used.
+func NewAutoResponder201(connectorsPerEVSE map[int]int) *AutoResponder201 {
+	r := &AutoResponder201{producers: map[MessageTrigger]TriggerProducer{}}
+	if connectorsPerEVSE != nil {
+		r.validate = func(evseID int, connectorID int) string {
+			connectors, ok := connectorsPerEVSE[evseID]
+			if !ok {
+				return "UnknownEvse"
+			}
+			if connectorID < 0 || connectorID > connectors {
+				return "UnknownConnectorId"
+			}
+			return ""
+		}
+	}
+	return r
+}
+
+// Register sets the producer for a trigger, replacing any previously registered one.
+func (r *AutoResponder201) Register(trigger MessageTrigger, producer TriggerProducer) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.producers[trigger] = producer
+}
+
+// Unregister removes the producer for a trigger. Further requests for it are answered with NotImplemented.
+func (r *AutoResponder201) Unregister(trigger MessageTrigger) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.producers, trigger)
+}
+
+// SetTopology validates requested EVSEs and connectors against a shared station topology,
+// replacing the connectors passed to NewAutoResponder201.
+func (r *AutoResponder201) SetTopology(topology *types.StationTopology) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.validate = topology.ValidateConnector
+}
+
+// UseBootManager registers the BootNotification producer of a BootManager. Triggered BootNotification requests are
+// rejected once the charging station was accepted, while all other accepted triggers are solicited
+// via the manager, so they may be sent before the charging station was accepted.
+func (r *AutoResponder201) UseBootManager(m *provisioning.BootManager) {
+	r.mutex.Lock()
+	r.bootManager = m
+	r.mutex.Unlock()
+	r.Register(MessageTriggerBootNotification, func(evse *types.EVSE) error {
+		_, err := m.Boot(provisioning.BootReasonTriggered)
+		return err
+	})
+}
+
+// UseStationManager registers the Heartbeat and StatusNotification producers of a StationManager.
+// StatusNotification reports the current status of the requested connector, of all connectors of the requested EVSE,
+// or of all connectors of the station. Requested EVSEs are validated against the connectors known to the manager.
+func (r *AutoResponder201) UseStationManager(m *availability.StationManager) {
+	r.mutex.Lock()
+	r.validate = m.ValidateConnector
+	r.mutex.Unlock()
+	r.Register(MessageTriggerHeartbeat, func(evse *types.EVSE) error {
+		if err := m.SendHeartbeat(availability.NewHeartbeatRequest()); err != nil {
+			return err
+		}
+		m.OnMessageSent()
+		return nil
+	})
+	r.Register(MessageTriggerStatusNotification, func(evse *types.EVSE) error {
+		evseID, connectorID := 0, 0
+		if evse != nil {
+			evseID = evse.ID
+			if evse.ConnectorID != nil {
+				connectorID = *evse.ConnectorID
+			}
+		}
+		if !m.ReportStatus(evseID, connectorID) {
+			return fmt.Errorf("no connector status known for evse %v, connector %v", evseID, connectorID)
+		}
+		return nil
+	})
+}
+
+// UseLogUploadManager registers a LogStatusNotification producer, reporting the current status of a LogUploadManager.
+func (r *AutoResponder201) UseLogUploadManager(m *diagnostics.LogUploadManager) {
+	r.Register(MessageTriggerLogStatusNotification, func(evse *types.EVSE) error {
+		requestID, status := m.CurrentStatus()
+		return m.SendStatus(diagnostics.NewLogStatusNotificationRequest(status, requestID))
+	})
+}
+
+// OnTriggerMessage answers a TriggerMessageRequest and, if accepted, sends the requested message in the background.
+func (r *AutoResponder201) OnTriggerMessage(request *TriggerMessageRequest) (*TriggerMessageResponse, error) {
+	trigger := request.RequestedMessage
+	r.mutex.Lock()
+	producer, ok := r.producers[trigger]
+	validate := r.validate
+	bootManager := r.bootManager
+	r.mutex.Unlock()
+	if !ok {
+		return NewTriggerMessageResponse(TriggerMessageStatusNotImplemented), nil
+	}
+	var evse *types.EVSE
+	if isEVSETrigger(trigger) && request.Evse != nil {
+		evse = request.Evse
+		connectorID := 0
+		if evse.ConnectorID != nil {
+			connectorID = *evse.ConnectorID
+		}
+		if validate != nil {
+			if reasonCode := validate(evse.ID, connectorID); reasonCode != "" {
+				response := NewTriggerMessageResponse(TriggerMessageStatusRejected)
+				response.StatusInfo = types.NewStatusInfo(reasonCode, "")
+				return response, nil
+			}
+		}
+	}
+	if bootManager != nil {
+		if trigger == MessageTriggerBootNotification && bootManager.Status() == provisioning.RegistrationStatusAccepted {
+			response := NewTriggerMessageResponse(TriggerMessageStatusRejected)
+			response.StatusInfo = types.NewStatusInfo("AlreadyAccepted", "")
+			return response, nil
+		}
+		bootManager.Solicit(triggerFeatureNames[trigger])
+	}
+	go func() {
+		if err := producer(evse); err != nil && r.OnSendError != nil {
+			r.OnSendError(trigger, err)
+		}
+	}()
+	return NewTriggerMessageResponse(TriggerMessageStatusAccepted), nil
+}
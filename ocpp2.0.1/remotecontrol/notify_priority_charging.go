@@ -0,0 +1,62 @@
+package remotecontrol
+
+import (
+	"reflect"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// -------------------- Notify Priority Charging (CS -> CSMS) --------------------
+
+const NotifyPriorityChargingFeatureName = "NotifyPriorityCharging"
+
+// The field definition of the NotifyPriorityCharging request payload sent by the Charging Station to the CSMS.
+type NotifyPriorityChargingRequest struct {
+	TransactionID string            `json:"transactionId" validate:"required,max=36"` // The transaction for which priority charging was started or stopped.
+	Activated     bool              `json:"activated"`                                // True if priority charging was activated, false if it was stopped.
+	CustomData    *types.CustomData `json:"customData,omitempty" validate:"omitempty"`
+}
+
+// This field definition of the NotifyPriorityCharging response payload, sent by the CSMS to the Charging Station in response to a NotifyPriorityChargingRequest.
+// In case the request was invalid, or couldn't be processed, an error will be sent instead.
+type NotifyPriorityChargingResponse struct {
+	CustomData *types.CustomData `json:"customData,omitempty" validate:"omitempty"`
+}
+
+// The Charging Station informs the CSMS via a NotifyPriorityChargingRequest, whenever priority charging was started
+// or stopped for a transaction, regardless of whether it was requested by the EV driver or by the CSMS
+// (see UsePriorityChargingFeature). The CSMS responds with a NotifyPriorityChargingResponse.
+//
+// The message was introduced with OCPP 2.1, and is not part of OCPP 2.0.1 or any of its errata.
+// The message name and payload follow the OCPP 2.1 schemas.
+type NotifyPriorityChargingFeature struct{}
+
+func (f NotifyPriorityChargingFeature) GetFeatureName() string {
+	return NotifyPriorityChargingFeatureName
+}
+
+func (f NotifyPriorityChargingFeature) GetRequestType() reflect.Type {
+	return reflect.TypeOf(NotifyPriorityChargingRequest{})
+}
+
+func (f NotifyPriorityChargingFeature) GetResponseType() reflect.Type {
+	return reflect.TypeOf(NotifyPriorityChargingResponse{})
+}
+
+func (r NotifyPriorityChargingRequest) GetFeatureName() string {
+	return NotifyPriorityChargingFeatureName
+}
+
+func (c NotifyPriorityChargingResponse) GetFeatureName() string {
+	return NotifyPriorityChargingFeatureName
+}
+
+// Creates a new NotifyPriorityChargingRequest, containing all required fields. There are no optional fields for this message.
+func NewNotifyPriorityChargingRequest(transactionID string, activated bool) *NotifyPriorityChargingRequest {
+	return &NotifyPriorityChargingRequest{TransactionID: transactionID, Activated: activated}
+}
+
+// Creates a new NotifyPriorityChargingResponse, which doesn't contain any required or optional fields.
+func NewNotifyPriorityChargingResponse() *NotifyPriorityChargingResponse {
+	return &NotifyPriorityChargingResponse{}
+}
@@ -1,10 +1,42 @@
 // The Remote control functional block contains OCPP 2.0 features for remote-control management from the CSMS.
+//
+// The profile additionally contains the priority charging messages (UsePriorityCharging and NotifyPriorityCharging),
+// which were introduced with OCPP 2.1 and are commonly supported as an extension to OCPP 2.0.1.
 package remotecontrol
 
-import "github.com/lorenzodonini/ocpp-go/ocpp"
+import (
+	"context"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+)
 
 // Needs to be implemented by a CSMS for handling messages part of the OCPP 2.0 Remote control profile.
 type CSMSHandler interface {
+	// OnNotifyPriorityCharging is called on the CSMS whenever a NotifyPriorityChargingRequest is received from a Charging Station.
+	OnNotifyPriorityCharging(chargingStationID string, request *NotifyPriorityChargingRequest) (response *NotifyPriorityChargingResponse, err error)
+}
+
+// Context-aware variant of CSMSHandler, for handling messages part of the OCPP 2.0 Remote control profile.
+type CSMSHandlerWithContext interface {
+	// OnNotifyPriorityCharging is called on the CSMS whenever a NotifyPriorityChargingRequest is received from a Charging Station.
+	OnNotifyPriorityCharging(ctx context.Context, chargingStationID string, request *NotifyPriorityChargingRequest) (response *NotifyPriorityChargingResponse, err error)
+}
+
+// AdaptCSMSHandler converts a CSMSHandler into a CSMSHandlerWithContext, which ignores the context.
+// Returns nil if handler is nil.
+func AdaptCSMSHandler(handler CSMSHandler) CSMSHandlerWithContext {
+	if handler == nil {
+		return nil
+	}
+	return csmsHandlerAdapter{handler: handler}
+}
+
+type csmsHandlerAdapter struct {
+	handler CSMSHandler
+}
+
+func (a csmsHandlerAdapter) OnNotifyPriorityCharging(ctx context.Context, chargingStationID string, request *NotifyPriorityChargingRequest) (*NotifyPriorityChargingResponse, error) {
+	return a.handler.OnNotifyPriorityCharging(chargingStationID, request)
 }
 
 // Needs to be implemented by Charging stations for handling messages part of the OCPP 2.0 Remote control profile.
@@ -17,14 +49,18 @@ type ChargingStationHandler interface {
 	OnTriggerMessage(request *TriggerMessageRequest) (response *TriggerMessageResponse, err error)
 	// OnUnlockConnector is called on a charging station whenever a UnlockConnectorRequest is received from the CSMS.
 	OnUnlockConnector(request *UnlockConnectorRequest) (response *UnlockConnectorResponse, err error)
+	// OnUsePriorityCharging is called on a charging station whenever a UsePriorityChargingRequest is received from the CSMS.
+	OnUsePriorityCharging(request *UsePriorityChargingRequest) (response *UsePriorityChargingResponse, err error)
 }
 
 const ProfileName = "remoteControl"
 
 var Profile = ocpp.NewProfile(
 	ProfileName,
+	NotifyPriorityChargingFeature{},
 	RequestStartTransactionFeature{},
 	RequestStopTransactionFeature{},
 	TriggerMessageFeature{},
 	UnlockConnectorFeature{},
+	UsePriorityChargingFeature{},
 )
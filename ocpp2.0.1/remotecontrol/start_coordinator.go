@@ -0,0 +1,249 @@
+package remotecontrol
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/clock"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+const (
+	// The default time a StartCoordinator waits for a remotely started transaction, before failing the start.
+	DefaultRemoteStartTimeout = 60 * time.Second
+	// The default time a StartCoordinator remembers completed starts, in order to detect duplicate echoes.
+	DefaultRemoteStartRetention = 10 * time.Minute
+)
+
+// Errors passed to the callback of a remote start.
+var (
+	ErrStartRejected         = errors.New("charging station rejected the remote start")
+	ErrStartTimeout          = errors.New("charging station didn't respond to the remote start in time")
+	ErrTransactionNotStarted = errors.New("charging station accepted the remote start, but didn't start a transaction in time")
+)
+
+// DuplicateRemoteStartError is reported when a remoteStartId is echoed for a transaction,
+// after it was already matched to a different transaction.
+type DuplicateRemoteStartError struct {
+	ChargingStationID     string
+	RemoteStartID         int
+	TransactionID         string
+	ExistingTransactionID string
+}
+
+func (e *DuplicateRemoteStartError) Error() string {
+	return fmt.Sprintf("remoteStartId %v from %v: echoed by transaction %v, but already matched to transaction %v",
+		e.RemoteStartID, e.ChargingStationID, e.TransactionID, e.ExistingTransactionID)
+}
+
+// StartResult is the outcome of a remote start. Either TransactionID or Err is set.
+type StartResult struct {
+	ChargingStationID string
+	RemoteStartID     int
+	TransactionID     string
+	Err               error
+}
+
+type pendingStart struct {
+	chargingStationID string
+	callback          func(result StartResult)
+	accepted          bool
+	timer             clock.Timer
+}
+
+type completedStart struct {
+	chargingStationID string
+	transactionID     string
+}
+
+// StartCoordinator correlates RequestStartTransaction requests with the transactions they started, on the CSMS side.
+//
+// Start allocates a unique remoteStartId and sends the request. Transactions are matched by the remoteStartId echoed
+// by the charging station in a TransactionEventRequest: OnTransactionState may be registered as a listener of a
+// transactions.EventTracker, or OnTransactionEvent may be invoked with every received event instead.
+// The callback of each start is invoked exactly once, with either the transactionId or an error:
+//
+// - ErrStartRejected, if the charging station rejected the request
+//
+// - ErrStartTimeout, if the charging station didn't respond within the timeout
+//
+// - ErrTransactionNotStarted, if the charging station accepted the request, but no transaction was started within the timeout
+//
+// - any error returned while sending the request
+//
+// If a remoteStartId is echoed by a different transaction after the start was completed, a DuplicateRemoteStartError
+// is passed to OnDuplicate.
+//
+// A StartCoordinator is safe for concurrent use.
+type StartCoordinator struct {
+	// Sends a RequestStartTransactionRequest to a charging station, e.g. CSMS.RequestStartTransaction.
+	SendRequestStartTransaction func(clientId string, callback func(*RequestStartTransactionResponse, error), remoteStartID int, IdToken types.IdToken, props ...func(request *RequestStartTransactionRequest)) error
+	// Invoked whenever a duplicate echo was detected. Optional.
+	OnDuplicate func(err *DuplicateRemoteStartError)
+	timeout     time.Duration
+	retention   time.Duration
+	nextID      int
+	pending     map[int]*pendingStart
+	completed   map[int]completedStart
+	clock       clock.Clock
+	mutex       sync.Mutex
+}
+
+// NewStartCoordinator creates a new coordinator. If timeout is zero, DefaultRemoteStartTimeout is used.
+func NewStartCoordinator(timeout time.Duration, sendRequestStartTransaction func(clientId string, callback func(*RequestStartTransactionResponse, error), remoteStartID int, IdToken types.IdToken, props ...func(request *RequestStartTransactionRequest)) error) *StartCoordinator {
+	if timeout <= 0 {
+		timeout = DefaultRemoteStartTimeout
+	}
+	return &StartCoordinator{
+		SendRequestStartTransaction: sendRequestStartTransaction,
+		timeout:                     timeout,
+		retention:                   DefaultRemoteStartRetention,
+		nextID:                      1,
+		pending:                     map[int]*pendingStart{},
+		completed:                   map[int]completedStart{},
+		clock:                       clock.New(),
+	}
+}
+
+// SetRetention configures how long completed starts are remembered, in order to detect duplicate echoes.
+func (c *StartCoordinator) SetRetention(retention time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.retention = retention
+}
+
+// SetClock sets the clock used for the start timeout and for forgetting completed starts.
+// Passing nil restores the real clock.
+//
+// Timers already running are not affected.
+func (c *StartCoordinator) SetClock(clk clock.Clock) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.clock = clock.OrDefault(clk)
+}
+
+// SetNextRemoteStartID sets the next remoteStartId to allocate, e.g. to continue a sequence persisted across restarts.
+func (c *StartCoordinator) SetNextRemoteStartID(remoteStartID int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.nextID = remoteStartID
+}
+
+// Start sends a RequestStartTransactionRequest with a newly allocated remoteStartId, which is returned.
+// The callback is invoked once the transaction was started, or the start failed.
+// If the request couldn't be sent, the error is returned and the callback is not invoked.
+func (c *StartCoordinator) Start(chargingStationID string, idToken types.IdToken, callback func(result StartResult), props ...func(request *RequestStartTransactionRequest)) (int, error) {
+	c.mutex.Lock()
+	remoteStartID := c.nextID
+	c.nextID++
+	start := &pendingStart{chargingStationID: chargingStationID, callback: callback}
+	c.pending[remoteStartID] = start
+	start.timer = c.clock.AfterFunc(c.timeout, func() {
+		c.onTimeout(remoteStartID, start)
+	})
+	c.mutex.Unlock()
+	err := c.SendRequestStartTransaction(chargingStationID, func(response *RequestStartTransactionResponse, err error) {
+		if err != nil {
+			c.complete(remoteStartID, start, StartResult{Err: err})
+		} else if response.Status != RequestStartStopStatusAccepted {
+			c.complete(remoteStartID, start, StartResult{Err: ErrStartRejected})
+		} else if response.TransactionID != "" {
+			// The transaction was already started before the request was received
+			c.complete(remoteStartID, start, StartResult{TransactionID: response.TransactionID})
+		} else {
+			c.mutex.Lock()
+			start.accepted = true
+			c.mutex.Unlock()
+		}
+	}, remoteStartID, idToken, props...)
+	if err != nil {
+		c.mutex.Lock()
+		start.timer.Stop()
+		delete(c.pending, remoteStartID)
+		c.mutex.Unlock()
+		return remoteStartID, err
+	}
+	return remoteStartID, nil
+}
+
+// PendingStarts returns the amount of starts, which weren't completed yet.
+func (c *StartCoordinator) PendingStarts() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.pending)
+}
+
+// OnTransactionState matches a transaction state to a pending start. It matches the transactions.TransactionListener signature.
+func (c *StartCoordinator) OnTransactionState(state transactions.TransactionState) {
+	if state.RemoteStartID != nil {
+		c.match(state.ChargingStationID, *state.RemoteStartID, state.TransactionID)
+	}
+}
+
+// OnTransactionEvent matches a received TransactionEventRequest to a pending start.
+func (c *StartCoordinator) OnTransactionEvent(chargingStationID string, request *transactions.TransactionEventRequest) {
+	if request != nil && request.TransactionInfo.RemoteStartID != nil {
+		c.match(chargingStationID, *request.TransactionInfo.RemoteStartID, request.TransactionInfo.TransactionID)
+	}
+}
+
+func (c *StartCoordinator) match(chargingStationID string, remoteStartID int, transactionID string) {
+	c.mutex.Lock()
+	if start, ok := c.pending[remoteStartID]; ok && start.chargingStationID == chargingStationID {
+		c.mutex.Unlock()
+		c.complete(remoteStartID, start, StartResult{TransactionID: transactionID})
+		return
+	}
+	completed, ok := c.completed[remoteStartID]
+	c.mutex.Unlock()
+	// Further events of the same transaction echo the id again, which is expected
+	if !ok || completed.chargingStationID != chargingStationID || completed.transactionID == transactionID {
+		return
+	}
+	if c.OnDuplicate != nil {
+		c.OnDuplicate(&DuplicateRemoteStartError{
+			ChargingStationID:     chargingStationID,
+			RemoteStartID:         remoteStartID,
+			TransactionID:         transactionID,
+			ExistingTransactionID: completed.transactionID,
+		})
+	}
+}
+
+func (c *StartCoordinator) onTimeout(remoteStartID int, start *pendingStart) {
+	c.mutex.Lock()
+	err := ErrStartTimeout
+	if start.accepted {
+		err = ErrTransactionNotStarted
+	}
+	c.mutex.Unlock()
+	c.complete(remoteStartID, start, StartResult{Err: err})
+}
+
+// Completes a start, unless it was completed already.
+func (c *StartCoordinator) complete(remoteStartID int, start *pendingStart, result StartResult) {
+	c.mutex.Lock()
+	if c.pending[remoteStartID] != start {
+		c.mutex.Unlock()
+		return
+	}
+	start.timer.Stop()
+	delete(c.pending, remoteStartID)
+	if result.Err == nil {
+		c.completed[remoteStartID] = completedStart{chargingStationID: start.chargingStationID, transactionID: result.TransactionID}
+		c.clock.AfterFunc(c.retention, func() {
+			c.mutex.Lock()
+			defer c.mutex.Unlock()
+			delete(c.completed, remoteStartID)
+		})
+	}
+	c.mutex.Unlock()
+	result.ChargingStationID = start.chargingStationID
+	result.RemoteStartID = remoteStartID
+	if start.callback != nil {
+		start.callback(result)
+	}
+}
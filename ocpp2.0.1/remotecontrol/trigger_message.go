@@ -60,8 +60,9 @@ func isValidTriggerMessageStatus(fl validator.FieldLevel) bool {
 
 // The field definition of the TriggerMessage request payload sent by the CSMS to the Charging Station.
 type TriggerMessageRequest struct {
-	RequestedMessage MessageTrigger `json:"requestedMessage" validate:"required,messageTrigger201"`
-	Evse             *types.EVSE    `json:"evse,omitempty" validate:"omitempty"`
+	RequestedMessage MessageTrigger    `json:"requestedMessage" validate:"required,messageTrigger201"`
+	Evse             *types.EVSE       `json:"evse,omitempty" validate:"omitempty"`
+	CustomData       *types.CustomData `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // This field definition of the TriggerMessage response payload, sent by the Charging Station to the CSMS in response to a TriggerMessageRequest.
@@ -69,6 +70,7 @@ type TriggerMessageRequest struct {
 type TriggerMessageResponse struct {
 	Status     TriggerMessageStatus `json:"status" validate:"required,triggerMessageStatus201"`
 	StatusInfo *types.StatusInfo    `json:"statusInfo,omitempty"`
+	CustomData *types.CustomData    `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // The CSMS may request a Charging Station to send a Charging Station-initiated message.
@@ -107,6 +109,6 @@ func NewTriggerMessageResponse(status TriggerMessageStatus) *TriggerMessageRespo
 }
 
 func init() {
-	_ = types.Validate.RegisterValidation("messageTrigger201", isValidMessageTrigger)
-	_ = types.Validate.RegisterValidation("triggerMessageStatus201", isValidTriggerMessageStatus)
+	_ = types.RegisterValidation("messageTrigger201", isValidMessageTrigger)
+	_ = types.RegisterValidation("triggerMessageStatus201", isValidTriggerMessageStatus)
 }
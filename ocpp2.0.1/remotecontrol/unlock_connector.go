@@ -37,8 +37,9 @@ func isValidUnlockStatus(fl validator.FieldLevel) bool {
 
 // The field definition of the UnlockConnector request payload sent by the CSMS to the Charging Station.
 type UnlockConnectorRequest struct {
-	EvseID      int `json:"evseId" validate:"gte=0"`
-	ConnectorID int `json:"connectorId" validate:"gte=0"`
+	EvseID      int               `json:"evseId" validate:"gte=0"`
+	ConnectorID int               `json:"connectorId" validate:"gte=0"`
+	CustomData  *types.CustomData `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // This field definition of the UnlockConnector response payload, sent by the Charging Station to the CSMS in response to a UnlockConnectorRequest.
@@ -46,6 +47,7 @@ type UnlockConnectorRequest struct {
 type UnlockConnectorResponse struct {
 	Status     UnlockStatus      `json:"status" validate:"required,unlockStatus201"`
 	StatusInfo *types.StatusInfo `json:"statusInfo,omitempty"`
+	CustomData *types.CustomData `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // It sometimes happens that a connector of a Charging Station socket does not unlock correctly.
@@ -86,5 +88,5 @@ func NewUnlockConnectorResponse(status UnlockStatus) *UnlockConnectorResponse {
 }
 
 func init() {
-	_ = types.Validate.RegisterValidation("unlockStatus201", isValidUnlockStatus)
+	_ = types.RegisterValidation("unlockStatus201", isValidUnlockStatus)
 }
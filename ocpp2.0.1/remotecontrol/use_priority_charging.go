@@ -0,0 +1,92 @@
+package remotecontrol
+
+import (
+	"reflect"
+
+	"gopkg.in/go-playground/validator.v9"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// -------------------- Use Priority Charging (CSMS -> CS) --------------------
+
+const UsePriorityChargingFeatureName = "UsePriorityCharging"
+
+// Status in UsePriorityChargingResponse.
+type PriorityChargingStatus string
+
+const (
+	PriorityChargingStatusAccepted  PriorityChargingStatus = "Accepted"  // The request was accepted.
+	PriorityChargingStatusRejected  PriorityChargingStatus = "Rejected"  // The request was rejected, e.g. because the transaction is unknown.
+	PriorityChargingStatusNoProfile PriorityChargingStatus = "NoProfile" // No priority charging profile is installed for the transaction.
+)
+
+func isValidPriorityChargingStatus(fl validator.FieldLevel) bool {
+	status := PriorityChargingStatus(fl.Field().String())
+	switch status {
+	case PriorityChargingStatusAccepted, PriorityChargingStatusRejected, PriorityChargingStatusNoProfile:
+		return true
+	default:
+		return false
+	}
+}
+
+// The field definition of the UsePriorityCharging request payload sent by the CSMS to the Charging Station.
+type UsePriorityChargingRequest struct {
+	TransactionID string            `json:"transactionId" validate:"required,max=36"` // The transaction for which priority charging is requested.
+	Activate      bool              `json:"activate"`                                 // True to request priority charging, false to request stopping priority charging.
+	CustomData    *types.CustomData `json:"customData,omitempty" validate:"omitempty"`
+}
+
+// This field definition of the UsePriorityCharging response payload, sent by the Charging Station to the CSMS in response to a UsePriorityChargingRequest.
+// In case the request was invalid, or couldn't be processed, an error will be sent instead.
+type UsePriorityChargingResponse struct {
+	Status     PriorityChargingStatus `json:"status" validate:"required,priorityChargingStatus"`
+	StatusInfo *types.StatusInfo      `json:"statusInfo,omitempty" validate:"omitempty"`
+	CustomData *types.CustomData      `json:"customData,omitempty" validate:"omitempty"`
+}
+
+// Priority charging allows a transaction to bypass the charging limits set via smart charging, by switching to a
+// dedicated priority charging profile installed on the Charging Station. It is requested either locally by the EV driver,
+// or remotely by the CSMS, which sends a UsePriorityChargingRequest for an ongoing transaction.
+// The Charging Station responds with a UsePriorityChargingResponse and, once priority charging was actually
+// started or stopped, informs the CSMS via NotifyPriorityCharging.
+//
+// The message was introduced with OCPP 2.1, and is not part of OCPP 2.0.1 or any of its errata.
+// The message name and payload follow the OCPP 2.1 schemas, so that both ends interoperate
+// with charging stations implementing priority charging as an extension to OCPP 2.0.1.
+type UsePriorityChargingFeature struct{}
+
+func (f UsePriorityChargingFeature) GetFeatureName() string {
+	return UsePriorityChargingFeatureName
+}
+
+func (f UsePriorityChargingFeature) GetRequestType() reflect.Type {
+	return reflect.TypeOf(UsePriorityChargingRequest{})
+}
+
+func (f UsePriorityChargingFeature) GetResponseType() reflect.Type {
+	return reflect.TypeOf(UsePriorityChargingResponse{})
+}
+
+func (r UsePriorityChargingRequest) GetFeatureName() string {
+	return UsePriorityChargingFeatureName
+}
+
+func (c UsePriorityChargingResponse) GetFeatureName() string {
+	return UsePriorityChargingFeatureName
+}
+
+// Creates a new UsePriorityChargingRequest, containing all required fields. There are no optional fields for this message.
+func NewUsePriorityChargingRequest(transactionID string, activate bool) *UsePriorityChargingRequest {
+	return &UsePriorityChargingRequest{TransactionID: transactionID, Activate: activate}
+}
+
+// Creates a new UsePriorityChargingResponse, containing all required fields. Optional fields may be set afterwards.
+func NewUsePriorityChargingResponse(status PriorityChargingStatus) *UsePriorityChargingResponse {
+	return &UsePriorityChargingResponse{Status: status}
+}
+
+func init() {
+	_ = types.RegisterValidation("priorityChargingStatus", isValidPriorityChargingStatus)
+}
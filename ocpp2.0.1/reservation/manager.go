@@ -0,0 +1,467 @@
+package reservation
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/clock"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/availability"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/idtoken"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// Reservation is a reservation accepted by a charging station.
+type Reservation struct {
+	ID             int
+	EvseID         int // The EVSE the reservation was assigned to. If the CSMS didn't request a specific EVSE, one is chosen by the Manager201.
+	ConnectorType  ConnectorType
+	ExpiryDateTime time.Time
+	IdToken        types.IdToken
+	GroupIdToken   *types.IdToken
+}
+
+// Storage persists the active reservations of a charging station.
+// Implementations don't need to be safe for concurrent use, as they are only accessed by a single Manager201.
+//
+// To retain reservations across reboots, provide a storage backed by persistent memory.
+type Storage interface {
+	// Save replaces the stored reservations. The operation must be atomic:
+	// if an error is returned, the previously stored reservations must be retained.
+	Save(reservations []Reservation) error
+	// Load returns the stored reservations.
+	Load() ([]Reservation, error)
+}
+
+type memoryStorage struct {
+	reservations []Reservation
+}
+
+// NewMemoryStorage creates a non-persistent Storage, keeping all reservations in memory.
+func NewMemoryStorage() Storage {
+	return &memoryStorage{}
+}
+
+func (s *memoryStorage) Save(reservations []Reservation) error {
+	s.reservations = reservations
+	return nil
+}
+
+func (s *memoryStorage) Load() ([]Reservation, error) {
+	return s.reservations, nil
+}
+
+type activeReservation struct {
+	Reservation
+	timer clock.Timer
+}
+
+// Manager201 implements the ChargingStationHandler interface on top of a Storage, as described by use cases H01-H04.
+// It may therefore be registered directly:
+//
+//	chargingStation.SetReservationHandler(manager)
+//
+// The topology of the charging station is supplied as the connector types of each EVSE, with connector ids starting at 1.
+// A reservation for a specific EVSE is rejected if the EVSE doesn't exist, or doesn't have a connector of the requested type.
+// Otherwise, the reservation is assigned to the first available EVSE with a matching connector. If no EVSE is available,
+// the request is answered with Occupied, Faulted or Unavailable, depending on the status of the matching EVSEs.
+// Connector statuses are retrieved via ConnectorStatus, which matches the StationManager method of the availability package.
+//
+// Once a reservation expires, it is removed and a ReservationStatusUpdateRequest with status Expired is sent.
+// A reservation is used once a transaction is started on its EVSE, for its idToken (see OnTransactionEvent and
+// OnTransactionState), after which it is removed and a ReservationStatusUpdateRequest with status Removed is sent.
+// While an EVSE is reserved, Authorize only accepts the reserving idToken, or the group idToken of the reservation.
+//
+// A Manager201 is safe for concurrent use.
+type Manager201 struct {
+	// Sends a ReservationStatusUpdateRequest to the CSMS. The function shouldn't block, since it may be invoked
+	// from within OnTransactionEvent, e.g. by using ChargingStation.SendRequestAsync.
+	SendStatusUpdate func(request *ReservationStatusUpdateRequest) error
+	// Retrieves the status of a connector. Connectors with unknown status are considered Available. Optional.
+	ConnectorStatus func(evseID int, connectorID int) (availability.ConnectorStatus, bool)
+	// Invoked whenever a reservation was added or removed, e.g. for updating the status of the reserved EVSE. Optional.
+	OnReservationChanged func(reservation Reservation, active bool)
+	// Invoked whenever a status update couldn't be sent, or the storage couldn't be updated. Optional.
+	OnError      func(err error)
+	topology     map[int][]ConnectorType
+	storage      Storage
+	reservations map[int]*activeReservation
+	clock        clock.Clock
+	mutex        sync.Mutex
+}
+
+// NewManager201 creates a new manager for a charging station with the given topology, restoring the reservations
+// contained in storage. If storage is nil, reservations are kept in memory.
+// Restored reservations, which expired in the meantime, are expired right away.
+func NewManager201(storage Storage, topology map[int][]ConnectorType, sendStatusUpdate func(request *ReservationStatusUpdateRequest) error) (*Manager201, error) {
+	if storage == nil {
+		storage = NewMemoryStorage()
+	}
+	reservations, err := storage.Load()
+	if err != nil {
+		return nil, err
+	}
+	m := &Manager201{
+		SendStatusUpdate: sendStatusUpdate,
+		topology:         topology,
+		storage:          storage,
+		reservations:     map[int]*activeReservation{},
+		clock:            clock.New(),
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, reservation := range reservations {
+		m.add(reservation)
+	}
+	return m, nil
+}
+
+// SetTopology replaces the topology passed to NewManager201 with the connector types of a shared station topology.
+// Active reservations are retained.
+func (m *Manager201) SetTopology(topology *types.StationTopology) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.topology = topology.ConnectorTypes()
+}
+
+// SetTimeSource replaces the function used for retrieving the current time, while timers are backed by the real clock.
+//
+// Deprecated: Use SetClock instead.
+func (m *Manager201) SetTimeSource(now func() time.Time) {
+	m.SetClock(clock.WithNow(now))
+}
+
+// SetClock sets the clock used for retrieving the current time and for expiring reservations.
+// Passing nil restores the real clock.
+//
+// The expiry of active reservations is rescheduled according to the new clock.
+func (m *Manager201) SetClock(c clock.Clock) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.clock = clock.OrDefault(c)
+	for _, r := range m.reservations {
+		r.timer.Stop()
+		m.schedule(r)
+	}
+}
+
+// Reservations returns all active reservations, ordered by id.
+func (m *Manager201) Reservations() []Reservation {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.list()
+}
+
+// ReservationForEVSE returns the active reservation assigned to an EVSE, if any.
+func (m *Manager201) ReservationForEVSE(evseID int) (Reservation, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if r := m.forEVSE(evseID); r != nil {
+		return r.Reservation, true
+	}
+	return Reservation{}, false
+}
+
+// Authorize returns whether an idToken may be used on an EVSE. If the EVSE is reserved, only the reserving idToken
+// is accepted, or any idToken belonging to the group idToken of the reservation. The group of an idToken is
+// typically retrieved from its idTokenInfo, as received in an AuthorizeResponse or stored in the local authorization list.
+func (m *Manager201) Authorize(evseID int, idToken types.IdToken, groupIdToken *types.IdToken) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	r := m.forEVSE(evseID)
+	if r == nil || idtoken.Equal(r.IdToken, idToken) {
+		return true
+	}
+	return r.GroupIdToken != nil && groupIdToken != nil && idtoken.Equal(*r.GroupIdToken, *groupIdToken)
+}
+
+func (m *Manager201) OnReserveNow(request *ReserveNowRequest) (*ReserveNowResponse, error) {
+	m.mutex.Lock()
+	if request.ExpiryDateTime == nil || !request.ExpiryDateTime.After(m.clock.Now()) {
+		m.mutex.Unlock()
+		return newReserveNowFailure(ReserveNowStatusRejected, "InvalidExpiryDate"), nil
+	}
+	var candidates []int
+	if request.EvseID != nil {
+		connectors, ok := m.topology[*request.EvseID]
+		if !ok {
+			m.mutex.Unlock()
+			return newReserveNowFailure(ReserveNowStatusRejected, "UnknownEvse"), nil
+		}
+		if !hasConnectorType(connectors, request.ConnectorType) {
+			m.mutex.Unlock()
+			return newReserveNowFailure(ReserveNowStatusRejected, "UnknownConnectorType"), nil
+		}
+		candidates = []int{*request.EvseID}
+	} else {
+		for evseID, connectors := range m.topology {
+			if hasConnectorType(connectors, request.ConnectorType) {
+				candidates = append(candidates, evseID)
+			}
+		}
+		if len(candidates) == 0 {
+			m.mutex.Unlock()
+			return newReserveNowFailure(ReserveNowStatusRejected, "UnknownConnectorType"), nil
+		}
+		sort.Ints(candidates)
+	}
+	// The best status among all candidates, in order of preference
+	status := ReserveNowStatusUnavailable
+	evseID := 0
+	for _, candidate := range candidates {
+		candidateStatus := m.evseStatus(candidate, request.ConnectorType, request.ID)
+		if statusPriority(candidateStatus) < statusPriority(status) {
+			status = candidateStatus
+			evseID = candidate
+		}
+		if status == ReserveNowStatusAccepted {
+			break
+		}
+	}
+	if status != ReserveNowStatusAccepted {
+		m.mutex.Unlock()
+		return NewReserveNowResponse(status), nil
+	}
+	reservation := Reservation{
+		ID:             request.ID,
+		EvseID:         evseID,
+		ConnectorType:  request.ConnectorType,
+		ExpiryDateTime: request.ExpiryDateTime.Time,
+		IdToken:        request.IdToken,
+		GroupIdToken:   request.GroupIdToken,
+	}
+	// A reservation with the same id is replaced
+	replaced := m.reservations[request.ID]
+	if replaced != nil {
+		replaced.timer.Stop()
+		delete(m.reservations, request.ID)
+	}
+	m.add(reservation)
+	if err := m.storage.Save(m.list()); err != nil {
+		m.reservations[request.ID].timer.Stop()
+		delete(m.reservations, request.ID)
+		if replaced != nil {
+			m.add(replaced.Reservation)
+		}
+		m.mutex.Unlock()
+		m.reportError(err)
+		return newReserveNowFailure(ReserveNowStatusRejected, "InternalError"), nil
+	}
+	m.mutex.Unlock()
+	if replaced != nil {
+		m.notifyChanged(replaced.Reservation, false)
+	}
+	m.notifyChanged(reservation, true)
+	return NewReserveNowResponse(ReserveNowStatusAccepted), nil
+}
+
+func (m *Manager201) OnCancelReservation(request *CancelReservationRequest) (*CancelReservationResponse, error) {
+	m.mutex.Lock()
+	r, ok := m.reservations[request.ReservationID]
+	if !ok {
+		m.mutex.Unlock()
+		return NewCancelReservationResponse(CancelReservationStatusRejected), nil
+	}
+	err := m.remove(r)
+	m.mutex.Unlock()
+	if err != nil {
+		m.reportError(err)
+	}
+	m.notifyChanged(r.Reservation, false)
+	return NewCancelReservationResponse(CancelReservationStatusAccepted), nil
+}
+
+// OnTransactionEvent marks the reservation of an EVSE as used, once a transaction was started on it for the reserving
+// idToken. Should be invoked with every TransactionEventRequest sent by the charging station.
+// If the event refers to a reservation explicitly via reservationId, the idToken isn't checked.
+func (m *Manager201) OnTransactionEvent(request *transactions.TransactionEventRequest) {
+	if request == nil || request.Evse == nil {
+		return
+	}
+	if request.ReservationID != nil {
+		m.use(request.Evse.ID, nil, request.ReservationID)
+	} else if request.IDToken != nil {
+		m.use(request.Evse.ID, request.IDToken, nil)
+	}
+}
+
+// OnTransactionState marks the reservation of an EVSE as used, once a transaction was started on it for the reserving
+// idToken. It matches the transactions.TransactionListener signature.
+func (m *Manager201) OnTransactionState(state transactions.TransactionState) {
+	if !state.Started || state.Ended || state.Evse == nil || state.IDToken == nil {
+		return
+	}
+	m.use(state.Evse.ID, state.IDToken, nil)
+}
+
+func (m *Manager201) use(evseID int, idToken *types.IdToken, reservationID *int) {
+	m.mutex.Lock()
+	r := m.forEVSE(evseID)
+	if r == nil || (reservationID != nil && *reservationID != r.ID) || (idToken != nil && !idtoken.Equal(*idToken, r.IdToken)) {
+		m.mutex.Unlock()
+		return
+	}
+	err := m.remove(r)
+	m.mutex.Unlock()
+	if err != nil {
+		m.reportError(err)
+	}
+	m.notifyChanged(r.Reservation, false)
+	m.sendStatusUpdate(r.ID, ReservationUpdateStatusRemoved)
+}
+
+func (m *Manager201) onExpired(r *activeReservation) {
+	m.mutex.Lock()
+	if m.reservations[r.ID] != r {
+		// Already removed or replaced
+		m.mutex.Unlock()
+		return
+	}
+	err := m.remove(r)
+	m.mutex.Unlock()
+	if err != nil {
+		m.reportError(err)
+	}
+	m.notifyChanged(r.Reservation, false)
+	m.sendStatusUpdate(r.ID, ReservationUpdateStatusExpired)
+}
+
+// Must be invoked while holding the lock.
+func (m *Manager201) add(reservation Reservation) {
+	r := &activeReservation{Reservation: reservation}
+	m.schedule(r)
+	m.reservations[reservation.ID] = r
+}
+
+// Starts the expiry timer of a reservation. Must be invoked while holding the lock.
+func (m *Manager201) schedule(r *activeReservation) {
+	r.timer = m.clock.AfterFunc(r.ExpiryDateTime.Sub(m.clock.Now()), func() {
+		m.onExpired(r)
+	})
+}
+
+// Removes a reservation and returns the error of updating the storage, if any. Must be invoked while holding the lock.
+func (m *Manager201) remove(r *activeReservation) error {
+	r.timer.Stop()
+	delete(m.reservations, r.ID)
+	return m.storage.Save(m.list())
+}
+
+// Must be invoked while holding the lock.
+func (m *Manager201) list() []Reservation {
+	reservations := make([]Reservation, 0, len(m.reservations))
+	for _, r := range m.reservations {
+		reservations = append(reservations, r.Reservation)
+	}
+	sort.Slice(reservations, func(i, j int) bool {
+		return reservations[i].ID < reservations[j].ID
+	})
+	return reservations
+}
+
+// Must be invoked while holding the lock.
+func (m *Manager201) forEVSE(evseID int) *activeReservation {
+	for _, r := range m.reservations {
+		if r.EvseID == evseID {
+			return r
+		}
+	}
+	return nil
+}
+
+// Returns the status of an EVSE for a new reservation, ignoring the reservation with the given id, which is being replaced.
+// Must be invoked while holding the lock.
+func (m *Manager201) evseStatus(evseID int, connectorType ConnectorType, reservationID int) ReserveNowStatus {
+	if r := m.forEVSE(evseID); r != nil && r.ID != reservationID {
+		return ReserveNowStatusOccupied
+	}
+	faulted := false
+	available := false
+	for i, t := range m.topology[evseID] {
+		status := availability.ConnectorStatusAvailable
+		if m.ConnectorStatus != nil {
+			if s, ok := m.ConnectorStatus(evseID, i+1); ok {
+				status = s
+			}
+		}
+		switch status {
+		case availability.ConnectorStatusOccupied:
+			// A transaction is ongoing on the EVSE
+			return ReserveNowStatusOccupied
+		case availability.ConnectorStatusFaulted:
+			faulted = true
+		case availability.ConnectorStatusAvailable, availability.ConnectorStatusReserved:
+			if isCompatible(t, connectorType) {
+				available = true
+			}
+		}
+	}
+	if available {
+		return ReserveNowStatusAccepted
+	}
+	if faulted {
+		return ReserveNowStatusFaulted
+	}
+	return ReserveNowStatusUnavailable
+}
+
+func (m *Manager201) sendStatusUpdate(reservationID int, status ReservationUpdateStatus) {
+	if m.SendStatusUpdate == nil {
+		return
+	}
+	if err := m.SendStatusUpdate(NewReservationStatusUpdateRequest(reservationID, status)); err != nil {
+		m.reportError(err)
+	}
+}
+
+func (m *Manager201) notifyChanged(reservation Reservation, active bool) {
+	if m.OnReservationChanged != nil {
+		m.OnReservationChanged(reservation, active)
+	}
+}
+
+func (m *Manager201) reportError(err error) {
+	if m.OnError != nil {
+		m.OnError(err)
+	}
+}
+
+// Ranks the statuses of candidate EVSEs: the lowest value is preferred.
+func statusPriority(status ReserveNowStatus) int {
+	switch status {
+	case ReserveNowStatusAccepted:
+		return 0
+	case ReserveNowStatusOccupied:
+		return 1
+	case ReserveNowStatusFaulted:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// Connector types Unknown and Undetermined, as well as an empty type, match any connector.
+func isCompatible(connectorType ConnectorType, requested ConnectorType) bool {
+	switch requested {
+	case "", ConnectorTypeUnknown, ConnectorTypeUndetermined:
+		return true
+	default:
+		return connectorType == requested
+	}
+}
+
+func hasConnectorType(connectors []ConnectorType, requested ConnectorType) bool {
+	for _, t := range connectors {
+		if isCompatible(t, requested) {
+			return true
+		}
+	}
+	return false
+}
+
+func newReserveNowFailure(status ReserveNowStatus, reasonCode string) *ReserveNowResponse {
+	response := NewReserveNowResponse(status)
+	response.StatusInfo = types.NewStatusInfo(reasonCode, "")
+	return response
+}
@@ -2,6 +2,8 @@
 package reservation
 
 import (
+	"context"
+
 	"github.com/lorenzodonini/ocpp-go/ocpp"
 )
 
@@ -11,6 +13,29 @@ type CSMSHandler interface {
 	OnReservationStatusUpdate(chargingStationID string, request *ReservationStatusUpdateRequest) (resp *ReservationStatusUpdateResponse, err error)
 }
 
+// Context-aware variant of CSMSHandler, for handling messages part of the OCPP 2.0 Reservation profile.
+type CSMSHandlerWithContext interface {
+	// OnReservationStatusUpdate is called on the CSMS whenever a ReservationStatusUpdateRequest is received from a charging station.
+	OnReservationStatusUpdate(ctx context.Context, chargingStationID string, request *ReservationStatusUpdateRequest) (resp *ReservationStatusUpdateResponse, err error)
+}
+
+// AdaptCSMSHandler converts a CSMSHandler into a CSMSHandlerWithContext, which ignores the context.
+// Returns nil if handler is nil.
+func AdaptCSMSHandler(handler CSMSHandler) CSMSHandlerWithContext {
+	if handler == nil {
+		return nil
+	}
+	return csmsHandlerAdapter{handler: handler}
+}
+
+type csmsHandlerAdapter struct {
+	handler CSMSHandler
+}
+
+func (a csmsHandlerAdapter) OnReservationStatusUpdate(ctx context.Context, chargingStationID string, request *ReservationStatusUpdateRequest) (*ReservationStatusUpdateResponse, error) {
+	return a.handler.OnReservationStatusUpdate(chargingStationID, request)
+}
+
 // Needs to be implemented by Charging stations for handling messages part of the OCPP 2.0 Reservation profile.
 type ChargingStationHandler interface {
 	// OnCancelReservation is called on a charging station whenever a CancelReservationRequest is received from the CSMS.
@@ -32,58 +32,43 @@ func isValidReserveNowStatus(fl validator.FieldLevel) bool {
 	}
 }
 
-// Allowed ConnectorType, as supported by most charging station vendors.
-// The OCPP protocol directly supports the most widely known connector types. For not mentioned types,
-// refer to the Other1PhMax16A, Other1PhOver16A and Other3Ph fallbacks.
-type ConnectorType string
+// Allowed ConnectorType, as supported by most charging station vendors. Refer to types.ConnectorType.
+type ConnectorType = types.ConnectorType
 
 const (
-	ConnectorTypeCCS1              ConnectorType = "cCCS1"           // Combined Charging System 1 (captive cabled) a.k.a. Combo 1
-	ConnectorTypeCCS2              ConnectorType = "cCCS2"           // Combined Charging System 2 (captive cabled) a.k.a. Combo 2
-	ConnectorTypeG105              ConnectorType = "cG105"           // JARI G105-1993 (captive cabled) a.k.a. CHAdeMO
-	ConnectorTypeTesla             ConnectorType = "cTesla"          // Tesla Connector
-	ConnectorTypeCType1            ConnectorType = "cType1"          // IEC62196-2 Type 1 connector (captive cabled) a.k.a. J1772
-	ConnectorTypeCType2            ConnectorType = "cType2"          // IEC62196-2 Type 2 connector (captive cabled) a.k.a. Mennekes connector
-	ConnectorType3091P16A          ConnectorType = "s309-1P-16A"     // 16A 1 phase IEC60309 socket
-	ConnectorType3091P32A          ConnectorType = "s309-1P-32A"     // 32A 1 phase IEC60309 socket
-	ConnectorType3093P16A          ConnectorType = "s309-3P-16A"     // 16A 3 phase IEC60309 socket
-	ConnectorType3093P32A          ConnectorType = "s309-3P-32A"     // 32A 3 phase IEC60309 socket
-	ConnectorTypeBS1361            ConnectorType = "sBS1361"         // UK domestic socket a.k.a. 13Amp
-	ConnectorTypeCEE77             ConnectorType = "sCEE-7-7"        // CEE 7/7 16A socket. May represent 7/4 & 7/5 a.k.a Schuko
-	ConnectorTypeSType2            ConnectorType = "sType2"          // EC62196-2 Type 2 socket a.k.a. Mennekes connector
-	ConnectorTypeSType3            ConnectorType = "sType3"          // IEC62196-2 Type 2 socket a.k.a. Scame
-	ConnectorTypeOther1PhMax16A    ConnectorType = "Other1PhMax16A"  // Other single phase (domestic) sockets not mentioned above, rated at no more than 16A. CEE7/17, AS3112, NEMA 5-15, NEMA 5-20, JISC8303, TIS166, SI 32, CPCS-CCC, SEV1011, etc.
-	ConnectorTypeOther1PhOver16A   ConnectorType = "Other1PhOver16A" // Other single phase sockets not mentioned above (over 16A)
-	ConnectorTypeOther3Ph          ConnectorType = "Other3Ph"        // Other 3 phase sockets not mentioned above. NEMA14-30, NEMA14-50.
-	ConnectorTypePan               ConnectorType = "Pan"             // Pantograph connector
-	ConnectorTypeWirelessInductive ConnectorType = "wInductive"      // Wireless inductively coupled connection
-	ConnectorTypeWirelessResonant  ConnectorType = "wResonant"       // Wireless resonant coupled connection
-	ConnectorTypeUndetermined      ConnectorType = "Undetermined"    // Yet to be determined (e.g. before plugged in)
-	ConnectorTypeUnknown           ConnectorType = "Unknown"         // Unknown; not determinable
+	ConnectorTypeCCS1              = types.ConnectorTypeCCS1
+	ConnectorTypeCCS2              = types.ConnectorTypeCCS2
+	ConnectorTypeG105              = types.ConnectorTypeG105
+	ConnectorTypeTesla             = types.ConnectorTypeTesla
+	ConnectorTypeCType1            = types.ConnectorTypeCType1
+	ConnectorTypeCType2            = types.ConnectorTypeCType2
+	ConnectorType3091P16A          = types.ConnectorType3091P16A
+	ConnectorType3091P32A          = types.ConnectorType3091P32A
+	ConnectorType3093P16A          = types.ConnectorType3093P16A
+	ConnectorType3093P32A          = types.ConnectorType3093P32A
+	ConnectorTypeBS1361            = types.ConnectorTypeBS1361
+	ConnectorTypeCEE77             = types.ConnectorTypeCEE77
+	ConnectorTypeSType2            = types.ConnectorTypeSType2
+	ConnectorTypeSType3            = types.ConnectorTypeSType3
+	ConnectorTypeOther1PhMax16A    = types.ConnectorTypeOther1PhMax16A
+	ConnectorTypeOther1PhOver16A   = types.ConnectorTypeOther1PhOver16A
+	ConnectorTypeOther3Ph          = types.ConnectorTypeOther3Ph
+	ConnectorTypePan               = types.ConnectorTypePan
+	ConnectorTypeWirelessInductive = types.ConnectorTypeWirelessInductive
+	ConnectorTypeWirelessResonant  = types.ConnectorTypeWirelessResonant
+	ConnectorTypeUndetermined      = types.ConnectorTypeUndetermined
+	ConnectorTypeUnknown           = types.ConnectorTypeUnknown
 )
 
-func isValidConnectorType(fl validator.FieldLevel) bool {
-	status := ConnectorType(fl.Field().String())
-	switch status {
-	case ConnectorTypeCCS1, ConnectorTypeCCS2, ConnectorTypeG105, ConnectorTypeTesla, ConnectorTypeCType1,
-		ConnectorTypeCType2, ConnectorType3091P16A, ConnectorType3091P32A, ConnectorType3093P16A, ConnectorType3093P32A,
-		ConnectorTypeBS1361, ConnectorTypeCEE77, ConnectorTypeSType2, ConnectorTypeSType3, ConnectorTypeOther1PhMax16A,
-		ConnectorTypeOther1PhOver16A, ConnectorTypeOther3Ph, ConnectorTypePan, ConnectorTypeWirelessInductive,
-		ConnectorTypeWirelessResonant, ConnectorTypeUndetermined, ConnectorTypeUnknown:
-		return true
-	default:
-		return false
-	}
-}
-
 // The field definition of the ReserveNow request payload sent by the CSMS to the Charging Station.
 type ReserveNowRequest struct {
-	ID             int             `json:"id" validate:"gte=0"` // ID of reservation
-	ExpiryDateTime *types.DateTime `json:"expiryDateTime" validate:"required"`
-	ConnectorType  ConnectorType   `json:"connectorType,omitempty" validate:"omitempty,connectorType"`
-	EvseID         *int            `json:"evseId,omitempty" validate:"omitempty,gte=0"`
-	IdToken        types.IdToken   `json:"idToken" validate:"required,dive"`
-	GroupIdToken   *types.IdToken  `json:"groupIdToken,omitempty" validate:"omitempty,dive"`
+	ID             int               `json:"id" validate:"gte=0"` // ID of reservation
+	ExpiryDateTime *types.DateTime   `json:"expiryDateTime" validate:"required"`
+	ConnectorType  ConnectorType     `json:"connectorType,omitempty" validate:"omitempty,connectorType"`
+	EvseID         *int              `json:"evseId,omitempty" validate:"omitempty,gte=0"`
+	IdToken        types.IdToken     `json:"idToken" validate:"required,dive"`
+	GroupIdToken   *types.IdToken    `json:"groupIdToken,omitempty" validate:"omitempty,dive"`
+	CustomData     *types.CustomData `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // This field definition of the ReserveNow response payload, sent by the Charging Station to the CSMS in response to a ReserveNowRequest.
@@ -91,6 +76,7 @@ type ReserveNowRequest struct {
 type ReserveNowResponse struct {
 	Status     ReserveNowStatus  `json:"status" validate:"required,reserveNowStatus"`
 	StatusInfo *types.StatusInfo `json:"statusInfo,omitempty" validate:"omitempty"`
+	CustomData *types.CustomData `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // To ensure an EV drive can charge their EV at a charging station, the EV driver may make a reservation until
@@ -135,6 +121,5 @@ func NewReserveNowResponse(status ReserveNowStatus) *ReserveNowResponse {
 }
 
 func init() {
-	_ = types.Validate.RegisterValidation("reserveNowStatus", isValidReserveNowStatus)
-	_ = types.Validate.RegisterValidation("connectorType", isValidConnectorType)
+	_ = types.RegisterValidation("reserveNowStatus", isValidReserveNowStatus)
 }
@@ -0,0 +1,250 @@
+package ocpp2
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+)
+
+// ErrStationOffline is wrapped by the RequestError of a rollout, if a charging station wasn't connected (within the offline TTL).
+var ErrStationOffline = errors.New("charging station offline")
+
+// Selector determines the charging stations targeted by a fleet-wide operation, such as SetVariablesRollout.
+type Selector interface {
+	// Select returns the IDs of the targeted charging stations. The IDs of all currently connected charging stations are passed.
+	Select(connected []string) []string
+}
+
+// SelectorFunc is an adapter allowing the use of ordinary functions as a Selector.
+type SelectorFunc func(connected []string) []string
+
+func (f SelectorFunc) Select(connected []string) []string {
+	return f(connected)
+}
+
+// AllStations selects all connected charging stations.
+func AllStations() Selector {
+	return SelectorFunc(func(connected []string) []string {
+		return connected
+	})
+}
+
+// Stations selects the charging stations with the passed IDs, regardless of whether they are connected.
+// Combined with RolloutOptions.OfflineTTL, offline charging stations are processed once they connect.
+func Stations(chargingStationIDs ...string) Selector {
+	return SelectorFunc(func(connected []string) []string {
+		return chargingStationIDs
+	})
+}
+
+// RolloutOutcome summarizes the result of a rollout for a single charging station.
+type RolloutOutcome string
+
+const (
+	RolloutOutcomeAccepted       RolloutOutcome = "Accepted"       // All variables were accepted.
+	RolloutOutcomeRebootRequired RolloutOutcome = "RebootRequired" // All variables were accepted, but at least one requires a reboot to become effective.
+	RolloutOutcomeRejected       RolloutOutcome = "Rejected"       // At least one variable wasn't accepted by the charging station.
+	RolloutOutcomeFailed         RolloutOutcome = "Failed"         // A request failed, even after retrying.
+	RolloutOutcomeOffline        RolloutOutcome = "Offline"        // The charging station wasn't connected (within the offline TTL).
+)
+
+// RolloutOptions configures a rollout to multiple charging stations.
+// Retry intervals and the offline TTL are measured by the clock of the underlying ocppj.Server (see ocppj.Server.SetClock).
+type RolloutOptions struct {
+	// The limits used for splitting requests to charging stations, for which no limits were set via SetMessageLimits.
+	DefaultLimits provisioning.MessageLimits
+	// The maximum amount of charging stations processed concurrently. If zero, all charging stations are processed concurrently.
+	Concurrency int
+	// The maximum amount of retries for a request, which timed out or couldn't be delivered.
+	MaxRetries int
+	// The delay before retrying a request.
+	RetryInterval time.Duration
+	// If greater than zero, offline charging stations are queued: the rollout waits up to the TTL for them to connect.
+	// Otherwise, offline charging stations are reported as RolloutOutcomeOffline right away.
+	OfflineTTL time.Duration
+}
+
+// StationResult contains the outcome of a rollout for a single charging station.
+type StationResult struct {
+	ChargingStationID string
+	Outcome           RolloutOutcome
+	// The merged results of all requests sent to the charging station. If a request failed, only the results received so far are contained.
+	Results []provisioning.SetVariableResult
+	// True if at least one variable requires a reboot of the charging station to become effective.
+	RebootRequired bool
+	// The amount of requests sent to the charging station, including retries.
+	Attempts int
+	// The error that caused RolloutOutcomeFailed or RolloutOutcomeOffline.
+	Err error
+}
+
+// RolloutSummary groups the results of a rollout by outcome.
+type RolloutSummary struct {
+	Stations map[RolloutOutcome][]StationResult
+}
+
+// StationIDs returns the sorted IDs of all charging stations with the passed outcome.
+func (s RolloutSummary) StationIDs(outcome RolloutOutcome) []string {
+	var ids []string
+	for _, result := range s.Stations[outcome] {
+		ids = append(ids, result.ChargingStationID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// CollectRollout waits until a rollout completed and returns its summary.
+func CollectRollout(results <-chan StationResult) RolloutSummary {
+	summary := RolloutSummary{Stations: map[RolloutOutcome][]StationResult{}}
+	for result := range results {
+		summary.Stations[result.Outcome] = append(summary.Stations[result.Outcome], result)
+	}
+	return summary
+}
+
+func (cs *csms) SetVariablesRollout(targets Selector, variables []provisioning.SetVariableData, opts RolloutOptions) (<-chan StationResult, error) {
+	if targets == nil {
+		return nil, errors.New("no selector passed for rollout")
+	}
+	if len(variables) == 0 {
+		return nil, errors.New("no variables passed for rollout")
+	}
+	request := provisioning.NewSetVariablesRequest(variables)
+	var chargingStationIDs []string
+	selected := map[string]bool{}
+	for _, id := range targets.Select(cs.getConnectedStations()) {
+		if !selected[id] {
+			selected[id] = true
+			chargingStationIDs = append(chargingStationIDs, id)
+		}
+	}
+	results := make(chan StationResult, len(chargingStationIDs))
+	offlineDeadline := cs.server.Clock().Now().Add(opts.OfflineTTL)
+	var semaphore chan struct{}
+	if opts.Concurrency > 0 {
+		semaphore = make(chan struct{}, opts.Concurrency)
+	}
+	var wg sync.WaitGroup
+	wg.Add(len(chargingStationIDs))
+	for _, id := range chargingStationIDs {
+		go func(chargingStationID string) {
+			defer wg.Done()
+			if semaphore != nil {
+				semaphore <- struct{}{}
+				defer func() { <-semaphore }()
+			}
+			results <- cs.rolloutSetVariables(chargingStationID, request, opts, offlineDeadline)
+		}(id)
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results, nil
+}
+
+// Splits, sends and merges the SetVariables requests for a single charging station.
+func (cs *csms) rolloutSetVariables(chargingStationID string, request *provisioning.SetVariablesRequest, opts RolloutOptions, offlineDeadline time.Time) StationResult {
+	result := StationResult{ChargingStationID: chargingStationID}
+	limits, ok := cs.getMessageLimits(chargingStationID)
+	if !ok {
+		limits = opts.DefaultLimits
+	}
+	for _, part := range provisioning.SplitSetVariables(request, limits) {
+		response, err := cs.sendRolloutRequest(chargingStationID, part, opts, offlineDeadline, &result.Attempts)
+		if err != nil {
+			result.Err = err
+			if errors.Is(err, ErrStationOffline) && len(result.Results) == 0 {
+				result.Outcome = RolloutOutcomeOffline
+			} else {
+				result.Outcome = RolloutOutcomeFailed
+			}
+			return result
+		}
+		result.Results = append(result.Results, response.SetVariableResult...)
+	}
+	rejected := false
+	for _, r := range result.Results {
+		switch r.AttributeStatus {
+		case provisioning.SetVariableStatusAccepted:
+		case provisioning.SetVariableStatusRebootRequired:
+			result.RebootRequired = true
+		default:
+			rejected = true
+		}
+	}
+	switch {
+	case rejected:
+		result.Outcome = RolloutOutcomeRejected
+	case result.RebootRequired:
+		result.Outcome = RolloutOutcomeRebootRequired
+	default:
+		result.Outcome = RolloutOutcomeAccepted
+	}
+	return result
+}
+
+// Sends a single SetVariables request, retrying if it timed out or couldn't be delivered.
+// Waits for offline charging stations to connect, until the deadline expires.
+func (cs *csms) sendRolloutRequest(chargingStationID string, request *provisioning.SetVariablesRequest, opts RolloutOptions, offlineDeadline time.Time, attempts *int) (*provisioning.SetVariablesResponse, error) {
+	for retry := 0; ; retry++ {
+		if retry > 0 && opts.RetryInterval > 0 {
+			<-cs.server.Clock().After(opts.RetryInterval)
+		}
+		if err := cs.waitForConnection(chargingStationID, offlineDeadline); err != nil {
+			return nil, &RequestError{ChargingStationID: chargingStationID, FeatureName: request.GetFeatureName(), Err: err}
+		}
+		*attempts++
+		future, err := sendAsync(chargingStationID, request.GetFeatureName(), func(callback func(*provisioning.SetVariablesResponse, error)) error {
+			return cs.SendRequestAsync(chargingStationID, request, func(response ocpp.Response, err error) {
+				if response != nil {
+					callback(response.(*provisioning.SetVariablesResponse), err)
+				} else {
+					callback(nil, err)
+				}
+			})
+		})
+		var transient bool
+		if err != nil {
+			// The charging station may have disconnected in the meantime
+			connected, _ := cs.getConnectionState(chargingStationID)
+			transient = !connected
+		} else {
+			var response *provisioning.SetVariablesResponse
+			response, err = future.Get(context.Background())
+			if err == nil {
+				return response, nil
+			}
+			transient = errors.Is(err, ErrRequestTimeout) || errors.Is(err, ErrDisconnected)
+		}
+		if !transient || retry >= opts.MaxRetries {
+			return nil, err
+		}
+	}
+}
+
+// Blocks until the charging station is connected. Returns ErrStationOffline if the deadline expired first.
+func (cs *csms) waitForConnection(chargingStationID string, deadline time.Time) error {
+	clk := cs.server.Clock()
+	for {
+		connected, connectedC := cs.getConnectionState(chargingStationID)
+		if connected {
+			return nil
+		}
+		remaining := deadline.Sub(clk.Now())
+		if remaining <= 0 {
+			return ErrStationOffline
+		}
+		timer := clk.NewTimer(remaining)
+		select {
+		case <-connectedC:
+			timer.Stop()
+		case <-timer.C():
+		}
+	}
+}
@@ -34,12 +34,14 @@ func isValidCertificateSignedStatus(fl validator.FieldLevel) bool {
 type CertificateSignedRequest struct {
 	CertificateChain  string                      `json:"certificateChain" validate:"required,max=10000"`
 	TypeOfCertificate types.CertificateSigningUse `json:"certificateType,omitempty" validate:"omitempty,certificateSigningUse"`
+	CustomData        *types.CustomData           `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // The field definition of the CertificateSignedResponse payload sent by the Charging Station to the CSMS in response to a CertificateSignedRequest.
 type CertificateSignedResponse struct {
 	Status     CertificateSignedStatus `json:"status" validate:"required,certificateSignedStatus"`
 	StatusInfo *types.StatusInfo       `json:"statusInfo,omitempty" validate:"omitempty"`
+	CustomData *types.CustomData       `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // During the a certificate update procedure, the CSMS sends a new certificate, signed by a CA,
@@ -79,5 +81,5 @@ func NewCertificateSignedResponse(status CertificateSignedStatus) *CertificateSi
 }
 
 func init() {
-	_ = types.Validate.RegisterValidation("certificateSignedStatus", isValidCertificateSignedStatus)
+	_ = types.RegisterValidation("certificateSignedStatus", isValidCertificateSignedStatus)
 }
@@ -0,0 +1,88 @@
+package security
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// FileCertificateStorage is a CertificateStorage keeping each certificate as PEM file within a directory.
+// Files are named <certificateType>_<id>.pem, other files within the directory are ignored.
+type FileCertificateStorage struct {
+	dir string
+}
+
+// NewFileCertificateStorage creates a storage within the passed directory. The directory is created on the first write, if needed.
+func NewFileCertificateStorage(dir string) *FileCertificateStorage {
+	return &FileCertificateStorage{dir: dir}
+}
+
+func (f *FileCertificateStorage) Load() ([]StoredCertificate, error) {
+	entries, err := os.ReadDir(f.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var certificates []StoredCertificate
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || filepath.Ext(name) != ".pem" {
+			continue
+		}
+		certificateType, id, ok := strings.Cut(strings.TrimSuffix(name, ".pem"), "_")
+		if !ok || id == "" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(f.dir, name))
+		if err != nil {
+			return nil, err
+		}
+		certificates = append(certificates, StoredCertificate{ID: id, CertificateType: types.CertificateUse(certificateType), PEM: string(data)})
+	}
+	return certificates, nil
+}
+
+func (f *FileCertificateStorage) Save(certificate StoredCertificate) error {
+	if certificate.ID == "" || strings.ContainsAny(certificate.ID, `_/\.`) {
+		return fmt.Errorf("invalid certificate id %q", certificate.ID)
+	}
+	if err := os.MkdirAll(f.dir, 0o700); err != nil {
+		return err
+	}
+	// Remove any previous file with the same ID, in case the type changed
+	if err := f.Delete(certificate.ID); err != nil {
+		return err
+	}
+	// Write to a temporary file first, so that no partially written certificate is ever loaded
+	tmp, err := os.CreateTemp(f.dir, "tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.WriteString(certificate.PEM); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(f.dir, fmt.Sprintf("%v_%v.pem", certificate.CertificateType, certificate.ID)))
+}
+
+func (f *FileCertificateStorage) Delete(id string) error {
+	matches, err := filepath.Glob(filepath.Join(f.dir, "*_"+id+".pem"))
+	if err != nil {
+		return err
+	}
+	for _, match := range matches {
+		if err = os.Remove(match); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
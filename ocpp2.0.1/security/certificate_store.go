@@ -0,0 +1,393 @@
+package security
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/iso15118"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/iso15118/certhelper"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// The maximum length of the additional info within a StatusInfo.
+const maxStatusInfoLength = 512
+
+// ErrCertificateLimitReached is returned when installing a certificate would exceed the maximum amount of certificates of its type.
+var ErrCertificateLimitReached = errors.New("maximum amount of installed certificates reached")
+
+// StorageError is returned when the CertificateStorage failed to persist a change.
+type StorageError struct {
+	Err error
+}
+
+func (e *StorageError) Error() string {
+	return fmt.Sprintf("certificate storage: %v", e.Err)
+}
+
+func (e *StorageError) Unwrap() error {
+	return e.Err
+}
+
+// StoredCertificate is a certificate persisted by a CertificateStorage.
+type StoredCertificate struct {
+	ID              string               // The hex encoded SHA256 fingerprint of the (leaf) certificate.
+	CertificateType types.CertificateUse // The type the certificate was installed as.
+	PEM             string               // The PEM encoded certificate. For a V2GCertificateChain, the leaf is followed by the sub-CA certificates.
+}
+
+// CertificateStorage persists the certificates managed by a CertificateStore.
+type CertificateStorage interface {
+	// Load returns all stored certificates.
+	Load() ([]StoredCertificate, error)
+	// Save stores a certificate, replacing any certificate with the same ID.
+	Save(certificate StoredCertificate) error
+	// Delete removes the certificate with the given ID. Deleting a certificate that doesn't exist is not an error.
+	Delete(id string) error
+}
+
+type installedCertificate struct {
+	id              string
+	certificateType types.CertificateUse
+	chain           []*x509.Certificate
+}
+
+// CertificateStore keeps track of the certificates installed on a charging station, keyed by certificate type.
+// It implements the iso15118.ChargingStationHandler, answering GetInstalledCertificateIds, InstallCertificate and
+// DeleteCertificate requests consistently:
+//
+//	store, err := security.NewCertificateStore(security.NewFileCertificateStorage("/etc/ocpp/certs"))
+//	chargingStation.SetISO15118Handler(store)
+//
+// Root certificates are installed via InstallCertificate, while the V2G certificate chain received via
+// CertificateSigned is stored using StoreV2GCertificateChain.
+//
+// CertificateStore is safe for concurrent use.
+type CertificateStore struct {
+	storage       CertificateStorage
+	certificates  []installedCertificate
+	maxEntries    map[types.CertificateUse]int
+	hashAlgorithm types.HashAlgorithmType
+	now           func() time.Time
+	mutex         sync.Mutex
+}
+
+// NewCertificateStore creates a store, loading all certificates from the passed storage.
+// If storage is nil, certificates are only kept in memory.
+func NewCertificateStore(storage CertificateStorage) (*CertificateStore, error) {
+	s := &CertificateStore{
+		storage:       storage,
+		maxEntries:    map[types.CertificateUse]int{},
+		hashAlgorithm: types.SHA256,
+		now:           time.Now,
+	}
+	if storage == nil {
+		return s, nil
+	}
+	stored, err := storage.Load()
+	if err != nil {
+		return nil, err
+	}
+	for _, certificate := range stored {
+		chain, err := parseCertificateChain(certificate.PEM)
+		if err != nil {
+			return nil, fmt.Errorf("stored certificate %v: %w", certificate.ID, err)
+		}
+		s.certificates = append(s.certificates, installedCertificate{id: certificate.ID, certificateType: certificate.CertificateType, chain: chain})
+	}
+	return s, nil
+}
+
+// SetTimeSource replaces the function used for retrieving the current time. Intended for testing.
+func (s *CertificateStore) SetTimeSource(now func() time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.now = now
+}
+
+// SetMaxEntries sets the maximum amount of certificates of a type, e.g. according to the maxLimit of the
+// CertificateEntries variable. Installing further certificates is rejected. Zero means no limit.
+func (s *CertificateStore) SetMaxEntries(certificateType types.CertificateUse, max int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.maxEntries[certificateType] = max
+}
+
+// SetHashAlgorithm sets the algorithm used for computing the hash data reported by GetInstalledCertificateIds. Defaults to SHA256.
+func (s *CertificateStore) SetHashAlgorithm(alg types.HashAlgorithmType) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.hashAlgorithm = alg
+}
+
+// Count returns the amount of installed certificates, i.e. the actual value of the CertificateEntries variable.
+func (s *CertificateStore) Count() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.certificates)
+}
+
+// CertPool returns a pool containing all installed certificates of the passed type,
+// e.g. the CSMSRootCertificate certificates for verifying the CSMS.
+func (s *CertificateStore) CertPool(certificateType types.CertificateUse) *x509.CertPool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.certPool(certificateType)
+}
+
+func (s *CertificateStore) certPool(certificateType types.CertificateUse) *x509.CertPool {
+	pool := x509.NewCertPool()
+	for _, installed := range s.certificates {
+		if installed.certificateType == certificateType {
+			pool.AddCert(installed.chain[0])
+		}
+	}
+	return pool
+}
+
+// Install installs a PEM encoded root certificate. Only V2GRootCertificate, MORootCertificate, CSMSRootCertificate
+// and ManufacturerRootCertificate are supported. The certificate must be a currently valid, self-signed CA certificate.
+// Installing an already installed certificate has no effect.
+//
+// Returns a StorageError if the certificate couldn't be persisted. Any other error means the certificate was rejected,
+// e.g. wrapping ErrCertificateLimitReached or one of the certhelper errors.
+func (s *CertificateStore) Install(certificateType types.CertificateUse, certificatePEM string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	switch certificateType {
+	case types.V2GRootCertificate, types.MORootCertificate, types.CSMSRootCertificate, types.ManufacturerRootCertificate:
+	default:
+		return fmt.Errorf("%w: cannot install %v", certhelper.ErrInvalidUsage, certificateType)
+	}
+	if err := certhelper.ValidateChainAt(certificatePEM, nil, certificateType, s.now()); err != nil {
+		return err
+	}
+	chain, err := parseCertificateChain(certificatePEM)
+	if err != nil {
+		return err
+	}
+	id := fingerprint(chain[0])
+	count := 0
+	for _, installed := range s.certificates {
+		if installed.certificateType != certificateType {
+			continue
+		}
+		if installed.id == id {
+			return nil
+		}
+		count++
+	}
+	if max := s.maxEntries[certificateType]; max > 0 && count >= max {
+		return fmt.Errorf("%w: %v %v certificates installed", ErrCertificateLimitReached, count, certificateType)
+	}
+	return s.save(installedCertificate{id: id, certificateType: certificateType, chain: chain})
+}
+
+// StoreV2GCertificateChain stores the PEM encoded V2G certificate chain, e.g. after receiving it via a CertificateSignedRequest,
+// replacing the previous chain. The chain must lead to one of the installed V2GRootCertificate certificates.
+func (s *CertificateStore) StoreV2GCertificateChain(chainPEM string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := certhelper.ValidateChainAt(chainPEM, s.certPool(types.V2GRootCertificate), types.V2GCertificateChain, s.now()); err != nil {
+		return err
+	}
+	chain, err := parseCertificateChain(chainPEM)
+	if err != nil {
+		return err
+	}
+	var previous []string
+	for _, installed := range s.certificates {
+		if installed.certificateType == types.V2GCertificateChain {
+			previous = append(previous, installed.id)
+		}
+	}
+	id := fingerprint(chain[0])
+	if err = s.save(installedCertificate{id: id, certificateType: types.V2GCertificateChain, chain: chain}); err != nil {
+		return err
+	}
+	for _, previousID := range previous {
+		if previousID == id {
+			continue
+		}
+		if err = s.remove(previousID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes the certificate identified by the hash data. Only installed certificates are matched,
+// i.e. roots and V2G certificate chain leafs, but not the sub-CA certificates of a chain.
+// Returns false if no matching certificate is installed, or a StorageError if the deletion couldn't be persisted.
+func (s *CertificateStore) Delete(hashData types.CertificateHashData) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, installed := range s.certificates {
+		entryHashData, err := s.hashData(installed.chain, 0, hashData.HashAlgorithm)
+		if err != nil || !entryHashData.Equal(hashData) {
+			continue
+		}
+		return true, s.remove(installed.id)
+	}
+	return false, nil
+}
+
+// List returns the hash data of all installed certificates of the passed types, or of all types if none are passed.
+// The sub-CA certificates of a V2G certificate chain are returned as child certificates of the leaf.
+func (s *CertificateStore) List(certificateTypes ...types.CertificateUse) ([]types.CertificateHashDataChain, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	filter := map[types.CertificateUse]bool{}
+	for _, certificateType := range certificateTypes {
+		filter[certificateType] = true
+	}
+	var result []types.CertificateHashDataChain
+	for _, installed := range s.certificates {
+		if len(filter) > 0 && !filter[installed.certificateType] {
+			continue
+		}
+		hashData, err := s.hashData(installed.chain, 0, s.hashAlgorithm)
+		if err != nil {
+			return nil, err
+		}
+		entry := types.CertificateHashDataChain{CertificateType: installed.certificateType, CertificateHashData: *hashData}
+		for i := 1; i < len(installed.chain); i++ {
+			childHashData, err := s.hashData(installed.chain, i, s.hashAlgorithm)
+			if err != nil {
+				return nil, err
+			}
+			entry.ChildCertificateHashData = append(entry.ChildCertificateHashData, *childHashData)
+		}
+		result = append(result, entry)
+	}
+	return result, nil
+}
+
+func (s *CertificateStore) OnGetInstalledCertificateIds(request *iso15118.GetInstalledCertificateIdsRequest) (*iso15118.GetInstalledCertificateIdsResponse, error) {
+	chains, err := s.List(request.CertificateTypes...)
+	if err != nil {
+		return nil, err
+	}
+	if len(chains) == 0 {
+		return iso15118.NewGetInstalledCertificateIdsResponse(iso15118.GetInstalledCertificateStatusNotFound), nil
+	}
+	response := iso15118.NewGetInstalledCertificateIdsResponse(iso15118.GetInstalledCertificateStatusAccepted)
+	response.CertificateHashDataChain = chains
+	return response, nil
+}
+
+func (s *CertificateStore) OnInstallCertificate(request *iso15118.InstallCertificateRequest) (*iso15118.InstallCertificateResponse, error) {
+	err := s.Install(request.CertificateType, request.Certificate)
+	var storageErr *StorageError
+	switch {
+	case err == nil:
+		return iso15118.NewInstallCertificateResponse(iso15118.CertificateStatusAccepted), nil
+	case errors.As(err, &storageErr):
+		response := iso15118.NewInstallCertificateResponse(iso15118.CertificateStatusFailed)
+		response.StatusInfo = types.NewStatusInfo("InternalError", statusInfoText(err))
+		return response, nil
+	case errors.Is(err, ErrCertificateLimitReached):
+		response := iso15118.NewInstallCertificateResponse(iso15118.CertificateStatusRejected)
+		response.StatusInfo = types.NewStatusInfo("OutOfStorage", statusInfoText(err))
+		return response, nil
+	default:
+		response := iso15118.NewInstallCertificateResponse(iso15118.CertificateStatusRejected)
+		response.StatusInfo = types.NewStatusInfo("InvalidCertificate", statusInfoText(err))
+		return response, nil
+	}
+}
+
+func (s *CertificateStore) OnDeleteCertificate(request *iso15118.DeleteCertificateRequest) (*iso15118.DeleteCertificateResponse, error) {
+	found, err := s.Delete(request.CertificateHashData)
+	switch {
+	case err != nil:
+		response := iso15118.NewDeleteCertificateResponse(iso15118.DeleteCertificateStatusFailed)
+		response.StatusInfo = types.NewStatusInfo("InternalError", statusInfoText(err))
+		return response, nil
+	case !found:
+		return iso15118.NewDeleteCertificateResponse(iso15118.DeleteCertificateStatusNotFound), nil
+	default:
+		return iso15118.NewDeleteCertificateResponse(iso15118.DeleteCertificateStatusAccepted), nil
+	}
+}
+
+// Persists a certificate, then adds it to the installed certificates.
+func (s *CertificateStore) save(installed installedCertificate) error {
+	if s.storage != nil {
+		var chainPEM bytes.Buffer
+		for _, certificate := range installed.chain {
+			_ = pem.Encode(&chainPEM, &pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw})
+		}
+		if err := s.storage.Save(StoredCertificate{ID: installed.id, CertificateType: installed.certificateType, PEM: chainPEM.String()}); err != nil {
+			return &StorageError{Err: err}
+		}
+	}
+	for i, existing := range s.certificates {
+		if existing.id == installed.id {
+			s.certificates[i] = installed
+			return nil
+		}
+	}
+	s.certificates = append(s.certificates, installed)
+	return nil
+}
+
+// Deletes a certificate from the storage, then removes it from the installed certificates.
+func (s *CertificateStore) remove(id string) error {
+	if s.storage != nil {
+		if err := s.storage.Delete(id); err != nil {
+			return &StorageError{Err: err}
+		}
+	}
+	for i, installed := range s.certificates {
+		if installed.id == id {
+			s.certificates = append(s.certificates[:i], s.certificates[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// Computes the hash data of the i-th certificate of a chain. The issuer is the next certificate of the chain,
+// the certificate itself if self-signed, or else any installed certificate that signed it.
+func (s *CertificateStore) hashData(chain []*x509.Certificate, i int, alg types.HashAlgorithmType) (*types.CertificateHashData, error) {
+	certificate := chain[i]
+	if i+1 < len(chain) {
+		return types.NewCertificateHashDataFromCert(certificate, chain[i+1], alg)
+	}
+	if certificate.CheckSignatureFrom(certificate) == nil {
+		return types.NewCertificateHashDataFromCert(certificate, nil, alg)
+	}
+	// Sorted for a deterministic lookup
+	candidates := make([]installedCertificate, len(s.certificates))
+	copy(candidates, s.certificates)
+	sort.Slice(candidates, func(a, b int) bool { return candidates[a].id < candidates[b].id })
+	for _, candidate := range candidates {
+		for _, issuer := range candidate.chain {
+			if certificate.CheckSignatureFrom(issuer) == nil {
+				return types.NewCertificateHashDataFromCert(certificate, issuer, alg)
+			}
+		}
+	}
+	return nil, fmt.Errorf("no issuer installed for certificate %v", certificate.Subject)
+}
+
+func fingerprint(certificate *x509.Certificate) string {
+	sum := sha256.Sum256(certificate.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+func statusInfoText(err error) string {
+	text := err.Error()
+	if len(text) > maxStatusInfoLength {
+		text = text[:maxStatusInfoLength]
+	}
+	return text
+}
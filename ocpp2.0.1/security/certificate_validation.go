@@ -0,0 +1,170 @@
+package security
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+var (
+	// ErrMalformedCertificateChain is returned when a chain isn't a sequence of PEM encoded certificates.
+	ErrMalformedCertificateChain = errors.New("malformed certificate chain")
+	// ErrCertificateExpired is returned when a certificate of a chain is expired, or not valid yet.
+	ErrCertificateExpired = errors.New("certificate is not within its validity period")
+	// ErrUntrustedRoot is returned when a chain doesn't lead to one of the configured roots.
+	ErrUntrustedRoot = errors.New("certificate chain doesn't lead to a trusted root")
+	// ErrInvalidKeyUsage is returned when the leaf certificate is not suitable for the requested certificate type.
+	ErrInvalidKeyUsage = errors.New("certificate is not suitable for the requested certificate type")
+)
+
+// Parses a PEM encoded certificate chain, starting with the leaf certificate.
+func parseCertificateChain(chainPEM string) ([]*x509.Certificate, error) {
+	var certificates []*x509.Certificate
+	rest := []byte(chainPEM)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			return nil, fmt.Errorf("%w: unexpected PEM block %v", ErrMalformedCertificateChain, block.Type)
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMalformedCertificateChain, err)
+		}
+		certificates = append(certificates, certificate)
+	}
+	if len(certificates) == 0 {
+		return nil, fmt.Errorf("%w: no certificate found", ErrMalformedCertificateChain)
+	}
+	return certificates, nil
+}
+
+// Returns an error wrapping ErrCertificateKeyMismatch, if the certificate wasn't issued for the given public key.
+func matchPublicKey(certificate *x509.Certificate, publicKey crypto.PublicKey) error {
+	key, ok := publicKey.(interface{ Equal(x crypto.PublicKey) bool })
+	if !ok {
+		return fmt.Errorf("unsupported public key type %T", publicKey)
+	}
+	if !key.Equal(certificate.PublicKey) {
+		return fmt.Errorf("%w: %v", ErrCertificateKeyMismatch, certificate.Subject)
+	}
+	return nil
+}
+
+// ValidateSignedCertificate validates a PEM encoded certificate chain, as received via a CertificateSignedRequest.
+// The chain starts with the leaf certificate, optionally followed by intermediate certificates, and must lead to
+// one of the passed roots. All certificates must be currently valid.
+//
+// The leaf must be an end-entity certificate allowing digital signatures. If it restricts its extended key usage,
+// it must allow TLS client authentication for a ChargingStationCertificate, or TLS server authentication for a
+// V2GCertificate. An empty certificate type refers to ChargingStationCertificate.
+//
+// If pendingKey is set, the leaf must have been issued for it, i.e. for the key of the pending CSR.
+//
+// Errors wrap ErrMalformedCertificateChain, ErrCertificateExpired, ErrUntrustedRoot, ErrInvalidKeyUsage or
+// ErrCertificateKeyMismatch, where applicable.
+func ValidateSignedCertificate(chainPEM string, certType types.CertificateSigningUse, roots *x509.CertPool, pendingKey crypto.PublicKey) error {
+	certificates, err := parseCertificateChain(chainPEM)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, certificate := range certificates {
+		if now.Before(certificate.NotBefore) || now.After(certificate.NotAfter) {
+			return fmt.Errorf("%w: %v (valid from %v until %v)", ErrCertificateExpired, certificate.Subject, certificate.NotBefore, certificate.NotAfter)
+		}
+	}
+	leaf := certificates[0]
+	var extKeyUsage x509.ExtKeyUsage
+	switch certType {
+	case "", types.ChargingStationCert:
+		extKeyUsage = x509.ExtKeyUsageClientAuth
+	case types.V2GCertificate:
+		extKeyUsage = x509.ExtKeyUsageServerAuth
+	default:
+		return fmt.Errorf("%w: unknown certificate type %v", ErrInvalidKeyUsage, certType)
+	}
+	if leaf.IsCA || leaf.KeyUsage&x509.KeyUsageDigitalSignature == 0 {
+		return fmt.Errorf("%w: %v is not a signing end-entity certificate", ErrInvalidKeyUsage, leaf.Subject)
+	}
+	if pendingKey != nil {
+		if err = matchPublicKey(leaf, pendingKey); err != nil {
+			return err
+		}
+	}
+	intermediates := x509.NewCertPool()
+	for _, certificate := range certificates[1:] {
+		intermediates.AddCert(certificate)
+	}
+	if roots == nil {
+		roots = x509.NewCertPool()
+	}
+	_, err = leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{extKeyUsage},
+	})
+	var unknownAuthority x509.UnknownAuthorityError
+	var invalid x509.CertificateInvalidError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &unknownAuthority):
+		return fmt.Errorf("%w: %v", ErrUntrustedRoot, err)
+	case errors.As(err, &invalid) && invalid.Reason == x509.IncompatibleUsage:
+		return fmt.Errorf("%w: %v", ErrInvalidKeyUsage, err)
+	default:
+		return err
+	}
+}
+
+// CertificateSignedHandler implements the ChargingStationHandler interface, validating certificate chains received
+// via CertificateSignedRequest messages before installing them. It may be registered directly via SetSecurityHandler.
+//
+// Chains are validated with ValidateSignedCertificate, against the pending key of the respective certificate type.
+// Valid chains are passed to Install. The request is rejected, if either the validation or the installation fails.
+type CertificateSignedHandler struct {
+	// The trusted roots, which signed chains must lead to.
+	Roots *x509.CertPool
+	// Returns the public key of the pending CSR for a certificate type, or nil if no CSR is pending.
+	PendingKey func(certType types.CertificateSigningUse) crypto.PublicKey
+	// Installs a validated certificate chain.
+	Install func(chainPEM string, certType types.CertificateSigningUse) error
+	// Invoked whenever a chain is rejected, e.g. for sending an InvalidChargingStationCertificate security event. Optional.
+	OnRejected func(certType types.CertificateSigningUse, err error)
+}
+
+// NewCertificateSignedHandler creates a new handler, validating chains against the given roots.
+func NewCertificateSignedHandler(roots *x509.CertPool, pendingKey func(certType types.CertificateSigningUse) crypto.PublicKey, install func(chainPEM string, certType types.CertificateSigningUse) error) *CertificateSignedHandler {
+	return &CertificateSignedHandler{Roots: roots, PendingKey: pendingKey, Install: install}
+}
+
+func (h *CertificateSignedHandler) OnCertificateSigned(request *CertificateSignedRequest) (*CertificateSignedResponse, error) {
+	certType := request.TypeOfCertificate
+	if certType == "" {
+		certType = types.ChargingStationCert
+	}
+	var err error
+	pendingKey := h.PendingKey(certType)
+	if pendingKey == nil {
+		err = fmt.Errorf("no pending CSR for %v", certType)
+	} else if err = ValidateSignedCertificate(request.CertificateChain, certType, h.Roots, pendingKey); err == nil {
+		err = h.Install(request.CertificateChain, certType)
+	}
+	if err != nil {
+		if h.OnRejected != nil {
+			h.OnRejected(certType, err)
+		}
+		return NewCertificateSignedResponse(CertificateSignedStatusRejected), nil
+	}
+	return NewCertificateSignedResponse(CertificateSignedStatusAccepted), nil
+}
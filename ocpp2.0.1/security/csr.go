@@ -0,0 +1,132 @@
+package security
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// The maximum length of a PEM encoded CSR within a SignCertificateRequest.
+const MaxCSRLength = 5500
+
+// The default RSA key size used by GenerateCSR.
+const DefaultRSAKeyBits = 2048
+
+var (
+	// ErrCSRTooLarge is returned by GenerateCSR, if the PEM encoded CSR exceeds MaxCSRLength.
+	ErrCSRTooLarge = errors.New("CSR exceeds the maximum length")
+	// ErrCertificateKeyMismatch is returned when a signed certificate wasn't issued for the pending key.
+	ErrCertificateKeyMismatch = errors.New("certificate doesn't match the pending key")
+)
+
+// KeyType is the type of key pair generated by GenerateCSR.
+type KeyType string
+
+const (
+	KeyTypeECDSAP256 KeyType = "ECDSA-P256"
+	KeyTypeRSA       KeyType = "RSA"
+)
+
+// The domain component attribute, which ISO 15118-2 requires for SECC certificates.
+var oidDomainComponent = asn1.ObjectIdentifier{0, 9, 2342, 19200300, 100, 1, 25}
+
+// CSROptions contains the subject and key settings for generating a certificate signing request.
+type CSROptions struct {
+	// The serial number of the charging station, used as common name. Required.
+	CommonName string
+	// The name of the CPO, used as organization. Required.
+	Organization string
+	// The ISO 3166 country code of the CPO. Optional.
+	Country string
+	// The type of certificate to request. Defaults to ChargingStationCertificate.
+	CertificateType types.CertificateSigningUse
+	// The eMAID to use as common name instead of the serial number. Only allowed for V2G certificates.
+	EMAID string
+	// The type of key pair to generate. Defaults to ECDSA on the P-256 curve.
+	KeyType KeyType
+	// The size of generated RSA keys. Defaults to DefaultRSAKeyBits.
+	RSABits int
+	// An existing key to use, instead of generating a new one, e.g. a key stored in an HSM. Optional.
+	Signer crypto.Signer
+}
+
+// GenerateCSR generates a key pair and a PEM encoded PKCS#10 certificate signing request for it, as required for a
+// SignCertificateRequest (use case A02). If opts.Signer is set, no key is generated and the signer is used instead.
+//
+// The key is returned as a crypto.Signer and must be kept until the signed certificate was received,
+// see CertificateSignedMatchesCSR.
+func GenerateCSR(opts CSROptions) (csrPEM string, key crypto.Signer, err error) {
+	if opts.CommonName == "" || opts.Organization == "" {
+		return "", nil, errors.New("common name and organization are required")
+	}
+	subject := pkix.Name{CommonName: opts.CommonName, Organization: []string{opts.Organization}}
+	if opts.Country != "" {
+		subject.Country = []string{opts.Country}
+	}
+	switch opts.CertificateType {
+	case "", types.ChargingStationCert:
+		if opts.EMAID != "" {
+			return "", nil, errors.New("eMAID is only allowed for V2G certificates")
+		}
+	case types.V2GCertificate:
+		if opts.EMAID != "" {
+			subject.CommonName = opts.EMAID
+		}
+		subject.ExtraNames = []pkix.AttributeTypeAndValue{{Type: oidDomainComponent, Value: "CPO"}}
+	default:
+		return "", nil, fmt.Errorf("unsupported certificate type %v", opts.CertificateType)
+	}
+	key = opts.Signer
+	if key == nil {
+		if key, err = generateKey(opts.KeyType, opts.RSABits); err != nil {
+			return "", nil, err
+		}
+	}
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: subject}, key)
+	if err != nil {
+		return "", nil, fmt.Errorf("couldn't create CSR: %w", err)
+	}
+	csrPEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}))
+	if len(csrPEM) > MaxCSRLength {
+		return "", nil, fmt.Errorf("%w: %d characters", ErrCSRTooLarge, len(csrPEM))
+	}
+	return csrPEM, key, nil
+}
+
+func generateKey(keyType KeyType, rsaBits int) (crypto.Signer, error) {
+	switch keyType {
+	case "", KeyTypeECDSAP256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KeyTypeRSA:
+		if rsaBits == 0 {
+			rsaBits = DefaultRSAKeyBits
+		}
+		return rsa.GenerateKey(rand.Reader, rsaBits)
+	default:
+		return nil, fmt.Errorf("unsupported key type %v", keyType)
+	}
+}
+
+// CertificateSignedMatchesCSR verifies that the leaf certificate of a PEM chain, as received via a
+// CertificateSignedRequest, was issued for the public key of the given signer.
+// A charging station should only install the certificate, if no error is returned.
+//
+// Returns an error wrapping ErrCertificateKeyMismatch, if the certificate was issued for a different key,
+// or ErrMalformedCertificateChain, if the chain couldn't be parsed.
+func CertificateSignedMatchesCSR(certChainPEM string, key crypto.Signer) error {
+	certificates, err := parseCertificateChain(certChainPEM)
+	if err != nil {
+		return err
+	}
+	return matchPublicKey(certificates[0], key.Public())
+}
@@ -1,7 +1,11 @@
 // The security functional block contains OCPP 2.0 features aimed at providing E2E security between a CSMS and a Charging station.
 package security
 
-import "github.com/lorenzodonini/ocpp-go/ocpp"
+import (
+	"context"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+)
 
 // Needs to be implemented by a CSMS for handling messages part of the OCPP 2.0 Security profile.
 type CSMSHandler interface {
@@ -11,6 +15,35 @@ type CSMSHandler interface {
 	OnSignCertificate(chargingStationID string, request *SignCertificateRequest) (response *SignCertificateResponse, err error)
 }
 
+// Context-aware variant of CSMSHandler, for handling messages part of the OCPP 2.0 Security profile.
+type CSMSHandlerWithContext interface {
+	// OnSecurityEventNotification is called on the CSMS whenever a SecurityEventNotificationRequest is received from a charging station.
+	OnSecurityEventNotification(ctx context.Context, chargingStationID string, request *SecurityEventNotificationRequest) (response *SecurityEventNotificationResponse, err error)
+	// OnSignCertificate is called on the CSMS whenever a SignCertificateRequest is received from a charging station.
+	OnSignCertificate(ctx context.Context, chargingStationID string, request *SignCertificateRequest) (response *SignCertificateResponse, err error)
+}
+
+// AdaptCSMSHandler converts a CSMSHandler into a CSMSHandlerWithContext, which ignores the context.
+// Returns nil if handler is nil.
+func AdaptCSMSHandler(handler CSMSHandler) CSMSHandlerWithContext {
+	if handler == nil {
+		return nil
+	}
+	return csmsHandlerAdapter{handler: handler}
+}
+
+type csmsHandlerAdapter struct {
+	handler CSMSHandler
+}
+
+func (a csmsHandlerAdapter) OnSecurityEventNotification(ctx context.Context, chargingStationID string, request *SecurityEventNotificationRequest) (*SecurityEventNotificationResponse, error) {
+	return a.handler.OnSecurityEventNotification(chargingStationID, request)
+}
+
+func (a csmsHandlerAdapter) OnSignCertificate(ctx context.Context, chargingStationID string, request *SignCertificateRequest) (*SignCertificateResponse, error) {
+	return a.handler.OnSignCertificate(chargingStationID, request)
+}
+
 // Needs to be implemented by Charging stations for handling messages part of the OCPP 2.0 Security profile.
 type ChargingStationHandler interface {
 	// OnCertificateSigned is called on a charging station whenever a CertificateSignedRequest is received from the CSMS.
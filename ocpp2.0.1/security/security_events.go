@@ -0,0 +1,123 @@
+package security
+
+import (
+	"strings"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// SecurityEvent is a security event type, as listed in the Security Events appendix of the OCPP 2.0.1 specification.
+// It is sent as the type of a SecurityEventNotificationRequest.
+type SecurityEvent string
+
+const (
+	SecurityEventFirmwareUpdated                     SecurityEvent = "FirmwareUpdated"                     // The Charging Station firmware is updated.
+	SecurityEventFailedToAuthenticateAtCsms          SecurityEvent = "FailedToAuthenticateAtCsms"          // The authentication credentials provided by the Charging Station were rejected by the CSMS.
+	SecurityEventCsmsFailedToAuthenticate            SecurityEvent = "CsmsFailedToAuthenticate"            // The authentication credentials provided by the CSMS were rejected by the Charging Station.
+	SecurityEventSettingSystemTime                   SecurityEvent = "SettingSystemTime"                   // The system time on the Charging Station was changed by more than 180 seconds.
+	SecurityEventStartupOfTheDevice                  SecurityEvent = "StartupOfTheDevice"                  // The Charging Station has booted.
+	SecurityEventResetOrReboot                       SecurityEvent = "ResetOrReboot"                       // The Charging Station was rebooted or reset.
+	SecurityEventSecurityLogWasCleared               SecurityEvent = "SecurityLogWasCleared"               // The security log was cleared.
+	SecurityEventReconfigurationOfSecurityParameters SecurityEvent = "ReconfigurationOfSecurityParameters" // Security parameters, such as keys or the security profile used, were changed.
+	SecurityEventMemoryExhaustion                    SecurityEvent = "MemoryExhaustion"                    // The Flash or RAM memory of the Charging Station is getting full.
+	SecurityEventInvalidMessages                     SecurityEvent = "InvalidMessages"                     // The Charging Station has received messages that are not valid OCPP messages.
+	SecurityEventAttemptedReplayAttacks              SecurityEvent = "AttemptedReplayAttacks"              // The Charging Station has received a replayed message.
+	SecurityEventTamperDetectionActivated            SecurityEvent = "TamperDetectionActivated"            // The physical tamper detection sensor was triggered.
+	SecurityEventInvalidFirmwareSignature            SecurityEvent = "InvalidFirmwareSignature"            // The firmware signature is not valid.
+	SecurityEventInvalidFirmwareSigningCertificate   SecurityEvent = "InvalidFirmwareSigningCertificate"   // The certificate used to verify the firmware signature is not valid.
+	SecurityEventInvalidCsmsCertificate              SecurityEvent = "InvalidCsmsCertificate"              // The certificate that the CSMS uses was not valid or could not be verified.
+	SecurityEventInvalidChargingStationCertificate   SecurityEvent = "InvalidChargingStationCertificate"   // The certificate sent to the Charging Station using the CertificateSigned message is not a valid certificate.
+	SecurityEventInvalidTLSVersion                   SecurityEvent = "InvalidTLSVersion"                   // The TLS version used by the CSMS is lower than 1.2 and is not allowed by the security specification.
+	SecurityEventInvalidTLSCipherSuite               SecurityEvent = "InvalidTLSCipherSuite"               // The CSMS did only allow connections using TLS cipher suites that are not allowed by the security specification.
+	SecurityEventMaintenanceLoginAccepted            SecurityEvent = "MaintenanceLoginAccepted"            // A successful login to the local maintenance interface.
+	SecurityEventMaintenanceLoginFailed              SecurityEvent = "MaintenanceLoginFailed"              // A failed login attempt to the local maintenance interface.
+	SecurityEventUnknown                             SecurityEvent = "Unknown"                             // Fallback for event types that are not part of the security events list.
+)
+
+// Severity is the criticality classification of a security event.
+// Critical events are pushed to the CSMS, while all other events are only stored in the security log.
+type Severity string
+
+const (
+	SeverityCritical      Severity = "Critical"
+	SeverityInformational Severity = "Informational"
+)
+
+var securityEventSeverities = map[SecurityEvent]Severity{
+	SecurityEventFirmwareUpdated:                     SeverityCritical,
+	SecurityEventFailedToAuthenticateAtCsms:          SeverityInformational,
+	SecurityEventCsmsFailedToAuthenticate:            SeverityInformational,
+	SecurityEventSettingSystemTime:                   SeverityCritical,
+	SecurityEventStartupOfTheDevice:                  SeverityCritical,
+	SecurityEventResetOrReboot:                       SeverityCritical,
+	SecurityEventSecurityLogWasCleared:               SeverityCritical,
+	SecurityEventReconfigurationOfSecurityParameters: SeverityInformational,
+	SecurityEventMemoryExhaustion:                    SeverityCritical,
+	SecurityEventInvalidMessages:                     SeverityInformational,
+	SecurityEventAttemptedReplayAttacks:              SeverityInformational,
+	SecurityEventTamperDetectionActivated:            SeverityCritical,
+	SecurityEventInvalidFirmwareSignature:            SeverityInformational,
+	SecurityEventInvalidFirmwareSigningCertificate:   SeverityInformational,
+	SecurityEventInvalidCsmsCertificate:              SeverityInformational,
+	SecurityEventInvalidChargingStationCertificate:   SeverityInformational,
+	SecurityEventInvalidTLSVersion:                   SeverityInformational,
+	SecurityEventInvalidTLSCipherSuite:               SeverityInformational,
+	SecurityEventMaintenanceLoginAccepted:            SeverityCritical,
+	SecurityEventMaintenanceLoginFailed:              SeverityCritical,
+}
+
+// SeverityOf returns the severity of a security event type. Returns false, if the type is not part of the security events list.
+func SeverityOf(eventType string) (Severity, bool) {
+	severity, ok := securityEventSeverities[SecurityEvent(eventType)]
+	return severity, ok
+}
+
+// ParseSecurityEvent maps the type of a received SecurityEventNotificationRequest to a security event.
+// Types are matched case-insensitively. Types that are not part of the security events list are mapped to SecurityEventUnknown.
+func ParseSecurityEvent(eventType string) SecurityEvent {
+	if _, ok := securityEventSeverities[SecurityEvent(eventType)]; ok {
+		return SecurityEvent(eventType)
+	}
+	for event := range securityEventSeverities {
+		if strings.EqualFold(string(event), eventType) {
+			return event
+		}
+	}
+	return SecurityEventUnknown
+}
+
+// NewSecurityEvent creates a new SecurityEventNotificationRequest for the given event type, which occurred now.
+func NewSecurityEvent(eventType SecurityEvent) *SecurityEventNotificationRequest {
+	return NewSecurityEventNotificationRequest(string(eventType), types.Now())
+}
+
+// SecurityEventFilter forwards security events to the CSMS on the charging station side, based on their severity.
+//
+// By default, only critical events are forwarded, as required by the specification.
+// Non-critical events and events that are not part of the security events list are dropped,
+// unless ShouldForward is set.
+type SecurityEventFilter struct {
+	// Sends a SecurityEventNotificationRequest to the CSMS, e.g. by using ChargingStation.SendRequestAsync.
+	Send func(request *SecurityEventNotificationRequest) error
+	// Decides whether an event is forwarded. Unknown events are passed with an empty severity. Optional.
+	ShouldForward func(eventType string, severity Severity) bool
+}
+
+// NewSecurityEventFilter creates a new filter, forwarding critical events only.
+func NewSecurityEventFilter(send func(request *SecurityEventNotificationRequest) error) *SecurityEventFilter {
+	return &SecurityEventFilter{Send: send}
+}
+
+// Notify sends the security event to the CSMS, if it should be forwarded.
+// Returns whether the event was forwarded, along with any error returned by Send.
+func (f *SecurityEventFilter) Notify(request *SecurityEventNotificationRequest) (bool, error) {
+	severity, _ := SeverityOf(request.Type)
+	forward := severity == SeverityCritical
+	if f.ShouldForward != nil {
+		forward = f.ShouldForward(request.Type, severity)
+	}
+	if !forward {
+		return false, nil
+	}
+	return true, f.Send(request)
+}
@@ -0,0 +1,328 @@
+package smartcharging
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// ErrInvalidTopology is returned by ComputeComposite201, if the passed topology is incomplete.
+var ErrInvalidTopology = errors.New("invalid station topology")
+
+// StationTopology describes the electrical setup of a charging station, as required for calculating composite schedules.
+type StationTopology struct {
+	// The nominal voltage between phase and neutral, used for converting between W and A. Required.
+	Voltage float64
+	// The amount of phases the EVSEs are connected with. Defaults to 3.
+	Phases int
+	// The amount of EVSEs, among which station-wide limits are distributed equally. Defaults to 1.
+	EVSECount int
+	// The hardware current limit per phase of a single EVSE, applying whenever no profile is more restrictive. Required.
+	MaxCurrent float64
+}
+
+// A charging rate limit in W, with the amount of phases it was defined for (0 if unspecified).
+type rateLimit struct {
+	watts  float64
+	phases int
+}
+
+// ComputeComposite201 calculates the composite schedule of an EVSE over the given time window, as reported in a
+// GetCompositeScheduleResponse. Passing evseID 0 calculates the schedule of the entire charging station.
+//
+// The profiles are stacked according to the K-series rules: within each purpose, the valid profile with the highest
+// stackLevel, whose schedule is active at a given time, prevails. A TxProfile overrides any TxDefaultProfile.
+// The composite limit is the minimum of the prevailing ChargingStationMaxProfile, ChargingStationExternalConstraints
+// and TxProfile/TxDefaultProfile limits, and of the hardware limit defined by the topology.
+//
+// ChargingStationMaxProfile and ChargingStationExternalConstraints profiles are station-wide and distributed equally
+// among all EVSEs of the topology. TxProfile and TxDefaultProfile profiles are expected to apply to the requested EVSE;
+// when calculating the schedule of the entire station, they are assumed to apply to every EVSE.
+//
+// Only the first schedule of each profile is considered. Recurring profiles are repeated daily or weekly from their
+// startSchedule, while Relative profiles are assumed to start at the beginning of the time window.
+// Limits are converted to the requested unit, using the voltage of the topology and the amount of phases of the
+// respective periods, and rounded down to one decimal.
+func ComputeComposite201(profiles []types.ChargingProfile, evseID int, from time.Time, duration time.Duration, unit types.ChargingRateUnitType, topology StationTopology) (*CompositeSchedule, error) {
+	if unit != types.ChargingRateUnitWatts && unit != types.ChargingRateUnitAmperes {
+		return nil, fmt.Errorf("invalid charging rate unit %v", unit)
+	}
+	if evseID < 0 {
+		return nil, fmt.Errorf("invalid evseId %v", evseID)
+	}
+	seconds := int(duration / time.Second)
+	if seconds <= 0 {
+		return nil, fmt.Errorf("invalid duration %v", duration)
+	}
+	if topology.Phases == 0 {
+		topology.Phases = 3
+	}
+	if topology.EVSECount == 0 {
+		topology.EVSECount = 1
+	}
+	if topology.Voltage <= 0 || topology.MaxCurrent <= 0 || topology.Phases < 0 || topology.Phases > 3 || topology.EVSECount < 0 {
+		return nil, fmt.Errorf("%w: %+v", ErrInvalidTopology, topology)
+	}
+	from = from.Truncate(time.Second)
+	calculator := compositeCalculator{profiles: profiles, evseID: evseID, from: from, topology: topology}
+	schedule := types.NewChargingSchedule(0, unit)
+	schedule.StartSchedule = types.NewDateTime(from)
+	schedule.Duration = &seconds
+	var previous *types.ChargingSchedulePeriod
+	for _, offset := range calculator.breakpoints(seconds) {
+		limit := calculator.limitAt(from.Add(time.Duration(offset) * time.Second))
+		period := types.NewChargingSchedulePeriod(offset, topology.convert(limit, unit))
+		if limit.phases > 0 {
+			phases := limit.phases
+			period.NumberPhases = &phases
+		}
+		if previous != nil && previous.Limit == period.Limit && equalPhases(previous.NumberPhases, period.NumberPhases) {
+			continue
+		}
+		schedule.ChargingSchedulePeriod = append(schedule.ChargingSchedulePeriod, period)
+		previous = &schedule.ChargingSchedulePeriod[len(schedule.ChargingSchedulePeriod)-1]
+	}
+	return &CompositeSchedule{StartDateTime: types.NewDateTime(from), ChargingSchedule: schedule}, nil
+}
+
+type compositeCalculator struct {
+	profiles []types.ChargingProfile
+	evseID   int
+	from     time.Time
+	topology StationTopology
+}
+
+// Returns the sorted offsets within the window, at which the composite limit may change.
+func (c *compositeCalculator) breakpoints(seconds int) []int {
+	end := c.from.Add(time.Duration(seconds) * time.Second)
+	offsets := map[int]bool{0: true}
+	add := func(t time.Time) {
+		if !t.Before(c.from) && t.Before(end) {
+			offsets[int(t.Sub(c.from)/time.Second)] = true
+		}
+	}
+	for _, profile := range c.profiles {
+		if profile.ValidFrom != nil {
+			add(profile.ValidFrom.Time)
+		}
+		if profile.ValidTo != nil {
+			add(profile.ValidTo.Time)
+		}
+		if len(profile.ChargingSchedule) == 0 {
+			continue
+		}
+		schedule := profile.ChargingSchedule[0]
+		for _, start := range c.occurrences(profile, end) {
+			for _, period := range schedule.ChargingSchedulePeriod {
+				add(start.Add(time.Duration(period.StartPeriod) * time.Second))
+			}
+			if schedule.Duration != nil {
+				add(start.Add(time.Duration(*schedule.Duration) * time.Second))
+			}
+		}
+	}
+	result := make([]int, 0, len(offsets))
+	for offset := range offsets {
+		result = append(result, offset)
+	}
+	sort.Ints(result)
+	return result
+}
+
+// Returns the start times of all occurrences of the schedule of a profile, which may overlap the window.
+func (c *compositeCalculator) occurrences(profile types.ChargingProfile, end time.Time) []time.Time {
+	schedule := profile.ChargingSchedule[0]
+	switch profile.ChargingProfileKind {
+	case types.ChargingProfileKindRelative:
+		return []time.Time{c.from}
+	case types.ChargingProfileKindRecurring:
+		recurrence := recurrenceOf(profile)
+		if schedule.StartSchedule == nil || recurrence == 0 {
+			return nil
+		}
+		start := schedule.StartSchedule.Time
+		var result []time.Time
+		k := int64(math.Floor(float64(c.from.Sub(start))/float64(recurrence))) - 1
+		for occurrence := start.Add(time.Duration(k) * recurrence); occurrence.Before(end); occurrence = occurrence.Add(recurrence) {
+			if !occurrence.Before(start) {
+				result = append(result, occurrence)
+			}
+		}
+		return result
+	default:
+		if schedule.StartSchedule == nil {
+			return nil
+		}
+		return []time.Time{schedule.StartSchedule.Time}
+	}
+}
+
+// Returns the composite limit at the given time, considering all purposes and the hardware limit.
+func (c *compositeCalculator) limitAt(t time.Time) rateLimit {
+	stationScale := 1.0
+	evseScale := 1.0
+	if c.evseID > 0 {
+		stationScale = 1 / float64(c.topology.EVSECount)
+	} else {
+		evseScale = float64(c.topology.EVSECount)
+	}
+	var candidates []rateLimit
+	addCandidate := func(limit rateLimit, ok bool, scale float64) {
+		if ok {
+			limit.watts *= scale
+			candidates = append(candidates, limit)
+		}
+	}
+	limit, ok := c.prevailingLimit(types.ChargingProfilePurposeChargingStationMaxProfile, t)
+	addCandidate(limit, ok, stationScale)
+	limit, ok = c.prevailingLimit(types.ChargingProfilePurposeChargingStationExternalConstraints, t)
+	addCandidate(limit, ok, stationScale)
+	limit, ok = c.prevailingLimit(types.ChargingProfilePurposeTxProfile, t)
+	if !ok {
+		limit, ok = c.prevailingLimit(types.ChargingProfilePurposeTxDefaultProfile, t)
+	}
+	addCandidate(limit, ok, evseScale)
+	// The amount of phases is restricted by all limits, which also determines the hardware limit
+	result := rateLimit{}
+	for _, candidate := range candidates {
+		if candidate.phases > 0 && (result.phases == 0 || candidate.phases < result.phases) {
+			result.phases = candidate.phases
+		}
+	}
+	result.watts = c.topology.MaxCurrent * c.topology.Voltage * float64(c.topology.phasesOf(result.phases)) * evseScale
+	for _, candidate := range candidates {
+		result.watts = math.Min(result.watts, candidate.watts)
+	}
+	return result
+}
+
+// Returns the limit of the valid and active profile with the highest stack level for a purpose.
+func (c *compositeCalculator) prevailingLimit(purpose types.ChargingProfilePurposeType, t time.Time) (rateLimit, bool) {
+	var result rateLimit
+	found := false
+	stackLevel := 0
+	for _, profile := range c.profiles {
+		if profile.ChargingProfilePurpose != purpose || (found && profile.StackLevel <= stackLevel) {
+			continue
+		}
+		if profile.ValidFrom != nil && t.Before(profile.ValidFrom.Time) || profile.ValidTo != nil && !t.Before(profile.ValidTo.Time) {
+			continue
+		}
+		if limit, ok := c.profileLimit(profile, t); ok {
+			result, found, stackLevel = limit, true, profile.StackLevel
+		}
+	}
+	return result, found
+}
+
+// Returns the limit of the schedule of a profile at the given time, if the schedule is active.
+func (c *compositeCalculator) profileLimit(profile types.ChargingProfile, t time.Time) (rateLimit, bool) {
+	if len(profile.ChargingSchedule) == 0 {
+		return rateLimit{}, false
+	}
+	schedule := profile.ChargingSchedule[0]
+	var start time.Time
+	switch profile.ChargingProfileKind {
+	case types.ChargingProfileKindRelative:
+		start = c.from
+	case types.ChargingProfileKindRecurring:
+		recurrence := recurrenceOf(profile)
+		if schedule.StartSchedule == nil || recurrence == 0 || t.Before(schedule.StartSchedule.Time) {
+			return rateLimit{}, false
+		}
+		elapsed := t.Sub(schedule.StartSchedule.Time)
+		start = schedule.StartSchedule.Add(elapsed - elapsed%recurrence)
+	default:
+		if schedule.StartSchedule == nil {
+			return rateLimit{}, false
+		}
+		start = schedule.StartSchedule.Time
+	}
+	offset := int(t.Sub(start) / time.Second)
+	if offset < 0 || schedule.Duration != nil && offset >= *schedule.Duration {
+		return rateLimit{}, false
+	}
+	var active *types.ChargingSchedulePeriod
+	for i, period := range schedule.ChargingSchedulePeriod {
+		if period.StartPeriod <= offset && (active == nil || period.StartPeriod > active.StartPeriod) {
+			active = &schedule.ChargingSchedulePeriod[i]
+		}
+	}
+	if active == nil {
+		return rateLimit{}, false
+	}
+	limit := rateLimit{watts: active.Limit}
+	if active.NumberPhases != nil {
+		limit.phases = *active.NumberPhases
+	}
+	if schedule.ChargingRateUnit == types.ChargingRateUnitAmperes {
+		limit.watts = active.Limit * c.topology.Voltage * float64(c.topology.phasesOf(limit.phases))
+	}
+	return limit, true
+}
+
+func recurrenceOf(profile types.ChargingProfile) time.Duration {
+	switch profile.RecurrencyKind {
+	case types.RecurrencyKindDaily:
+		return secondsPerDay * time.Second
+	case types.RecurrencyKindWeekly:
+		return secondsPerWeek * time.Second
+	default:
+		return 0
+	}
+}
+
+// Returns the given amount of phases, or the phases of the topology if unspecified.
+func (t StationTopology) phasesOf(phases int) int {
+	if phases > 0 {
+		return phases
+	}
+	return t.Phases
+}
+
+// Converts a limit to the given unit, rounding down to one decimal.
+func (t StationTopology) convert(limit rateLimit, unit types.ChargingRateUnitType) float64 {
+	value := limit.watts
+	if unit == types.ChargingRateUnitAmperes {
+		value = limit.watts / (t.Voltage * float64(t.phasesOf(limit.phases)))
+	}
+	return math.Floor(value*10+1e-6) / 10
+}
+
+func equalPhases(a *int, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// TopologyForEVSE derives the electrical setup required by ComputeComposite201 from a shared station topology.
+// Passing evseID 0 derives the setup of the entire charging station, using the highest limit and amount of phases among its EVSEs.
+//
+// Returns an error wrapping ErrInvalidTopology, if the EVSE doesn't exist or has no current limit.
+func TopologyForEVSE(station *types.StationTopology, evseID int) (StationTopology, error) {
+	evseIDs := []int{evseID}
+	if evseID == 0 {
+		evseIDs = station.EVSEIDs()
+	}
+	topology := StationTopology{Voltage: station.NominalVoltage, EVSECount: len(station.EVSEs)}
+	if topology.Voltage == 0 {
+		topology.Voltage = types.DefaultNominalVoltage
+	}
+	for _, id := range evseIDs {
+		current, ok := station.MaxCurrentForEVSE(id, types.ChargingRateUnitAmperes)
+		if !ok {
+			return StationTopology{}, fmt.Errorf("%w: no current limit for evse %v", ErrInvalidTopology, id)
+		}
+		phases, _ := station.PhasesForEVSE(id)
+		topology.MaxCurrent = math.Max(topology.MaxCurrent, current)
+		if phases > topology.Phases {
+			topology.Phases = phases
+		}
+	}
+	return topology, nil
+}
@@ -0,0 +1,282 @@
+package smartcharging
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/clock"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// The default time a ChargingLimitNotifier waits for further changes, before notifying the CSMS.
+const DefaultLimitNotificationDelay = 5 * time.Second
+
+// ErrUnknownChargingLimit is reported by an ExternalLimitTracker, when a charging station clears a limit it never notified.
+var ErrUnknownChargingLimit = errors.New("cleared charging limit was never notified")
+
+// ExternalLimit is a charging limit imposed on a charging station or EVSE by an external system (use case K12).
+type ExternalLimit struct {
+	EvseID            int // 0 refers to the whole charging station.
+	Source            types.ChargingLimitSourceType
+	IsGridCritical    bool
+	ChargingSchedules []types.ChargingSchedule // The schedules reported with the limit, if any.
+	UpdatedAt         time.Time                // The time the limit was last notified.
+}
+
+type externalLimitKey struct {
+	evseID int
+	source types.ChargingLimitSourceType
+}
+
+// ExternalLimitTracker keeps track of the external charging limits currently active on each charging station, on the CSMS side.
+//
+// OnNotifyChargingLimit and OnClearedChargingLimit match the respective CSMSHandler callbacks, and should be invoked by
+// the CSMS handler. A limit is identified by its EVSE and source: notifying the same limit again replaces it.
+// A ClearedChargingLimitRequest without evseId clears all limits of the source on the charging station.
+// Clearing a limit that was never notified is reported as ErrUnknownChargingLimit via OnInconsistency.
+//
+// An ExternalLimitTracker is safe for concurrent use.
+type ExternalLimitTracker struct {
+	// Invoked whenever a limit was notified or cleared. Optional.
+	OnLimitChanged func(chargingStationID string, limit ExternalLimit, cleared bool)
+	// Invoked whenever a charging station reports an inconsistent limit state. Optional.
+	OnInconsistency func(chargingStationID string, err error)
+	limits          map[string]map[externalLimitKey]ExternalLimit
+	now             func() time.Time
+	mutex           sync.Mutex
+}
+
+// NewExternalLimitTracker creates a new tracker, without any active limits.
+func NewExternalLimitTracker() *ExternalLimitTracker {
+	return &ExternalLimitTracker{limits: map[string]map[externalLimitKey]ExternalLimit{}, now: time.Now}
+}
+
+// SetTimeSource replaces the function used for retrieving the current time. Intended for testing.
+func (t *ExternalLimitTracker) SetTimeSource(now func() time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.now = now
+}
+
+// OnNotifyChargingLimit stores the notified limit, replacing any previous limit of the same source on the EVSE.
+func (t *ExternalLimitTracker) OnNotifyChargingLimit(chargingStationID string, request *NotifyChargingLimitRequest) (*NotifyChargingLimitResponse, error) {
+	limit := ExternalLimit{Source: request.ChargingLimit.ChargingLimitSource, ChargingSchedules: request.ChargingSchedule}
+	if request.EvseID != nil {
+		limit.EvseID = *request.EvseID
+	}
+	if request.ChargingLimit.IsGridCritical != nil {
+		limit.IsGridCritical = *request.ChargingLimit.IsGridCritical
+	}
+	t.mutex.Lock()
+	limit.UpdatedAt = t.now()
+	stationLimits, ok := t.limits[chargingStationID]
+	if !ok {
+		stationLimits = map[externalLimitKey]ExternalLimit{}
+		t.limits[chargingStationID] = stationLimits
+	}
+	stationLimits[externalLimitKey{evseID: limit.EvseID, source: limit.Source}] = limit
+	t.mutex.Unlock()
+	if t.OnLimitChanged != nil {
+		t.OnLimitChanged(chargingStationID, limit, false)
+	}
+	return NewNotifyChargingLimitResponse(), nil
+}
+
+// OnClearedChargingLimit removes the cleared limits. The request is always acknowledged, even if it is inconsistent.
+func (t *ExternalLimitTracker) OnClearedChargingLimit(chargingStationID string, request *ClearedChargingLimitRequest) (*ClearedChargingLimitResponse, error) {
+	var cleared []ExternalLimit
+	t.mutex.Lock()
+	for key, limit := range t.limits[chargingStationID] {
+		if key.source == request.ChargingLimitSource && (request.EvseID == nil || key.evseID == *request.EvseID) {
+			cleared = append(cleared, limit)
+			delete(t.limits[chargingStationID], key)
+		}
+	}
+	if len(t.limits[chargingStationID]) == 0 {
+		delete(t.limits, chargingStationID)
+	}
+	t.mutex.Unlock()
+	if len(cleared) == 0 && t.OnInconsistency != nil {
+		evse := "all EVSEs"
+		if request.EvseID != nil {
+			evse = fmt.Sprintf("EVSE %v", *request.EvseID)
+		}
+		t.OnInconsistency(chargingStationID, fmt.Errorf("%w: source %v on %v", ErrUnknownChargingLimit, request.ChargingLimitSource, evse))
+	}
+	if t.OnLimitChanged != nil {
+		sortExternalLimits(cleared)
+		for _, limit := range cleared {
+			t.OnLimitChanged(chargingStationID, limit, true)
+		}
+	}
+	return NewClearedChargingLimitResponse(), nil
+}
+
+// ActiveLimits returns all limits currently active on a charging station, sorted by EVSE and source.
+func (t *ExternalLimitTracker) ActiveLimits(chargingStationID string) []ExternalLimit {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	var limits []ExternalLimit
+	for _, limit := range t.limits[chargingStationID] {
+		limits = append(limits, limit)
+	}
+	sortExternalLimits(limits)
+	return limits
+}
+
+// RemoveChargingStation forgets all limits of a charging station, e.g. after it rebooted.
+func (t *ExternalLimitTracker) RemoveChargingStation(chargingStationID string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.limits, chargingStationID)
+}
+
+func sortExternalLimits(limits []ExternalLimit) {
+	sort.Slice(limits, func(i, j int) bool {
+		if limits[i].EvseID != limits[j].EvseID {
+			return limits[i].EvseID < limits[j].EvseID
+		}
+		return limits[i].Source < limits[j].Source
+	})
+}
+
+type pendingLimit struct {
+	request *NotifyChargingLimitRequest
+	timer   clock.Timer
+}
+
+// ChargingLimitNotifier reports external charging limits to the CSMS on the charging station side.
+//
+// To avoid flooding the CSMS while an external system adjusts a limit repeatedly, a NotifyChargingLimitRequest
+// is only sent once the limit of an EVSE and source didn't change for the configured delay.
+// Limits equal to the last notified one are not sent again.
+// A ClearedChargingLimitRequest is only sent for limits that were previously notified:
+// clearing a limit that is still pending just discards it.
+//
+// A ChargingLimitNotifier is safe for concurrent use.
+type ChargingLimitNotifier struct {
+	// Sends a NotifyChargingLimitRequest to the CSMS.
+	SendNotify func(request *NotifyChargingLimitRequest) error
+	// Sends a ClearedChargingLimitRequest to the CSMS.
+	SendCleared func(request *ClearedChargingLimitRequest) error
+	// Invoked whenever a request couldn't be sent. Optional.
+	OnSendError func(featureName string, err error)
+	delay       time.Duration
+	pending     map[externalLimitKey]*pendingLimit
+	sent        map[externalLimitKey]*NotifyChargingLimitRequest
+	clock       clock.Clock
+	mutex       sync.Mutex
+}
+
+// NewChargingLimitNotifier creates a new notifier. If delay is zero, DefaultLimitNotificationDelay is used.
+func NewChargingLimitNotifier(delay time.Duration, sendNotify func(request *NotifyChargingLimitRequest) error, sendCleared func(request *ClearedChargingLimitRequest) error) *ChargingLimitNotifier {
+	if delay <= 0 {
+		delay = DefaultLimitNotificationDelay
+	}
+	return &ChargingLimitNotifier{
+		SendNotify:  sendNotify,
+		SendCleared: sendCleared,
+		delay:       delay,
+		pending:     map[externalLimitKey]*pendingLimit{},
+		sent:        map[externalLimitKey]*NotifyChargingLimitRequest{},
+		clock:       clock.New(),
+	}
+}
+
+// SetClock sets the clock used for delaying notifications. Passing nil restores the real clock.
+//
+// Pending notifications are not affected.
+func (n *ChargingLimitNotifier) SetClock(c clock.Clock) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.clock = clock.OrDefault(c)
+}
+
+// SetLimit schedules a notification for the limit imposed on an EVSE (0 for the whole charging station).
+// Any pending notification for the same EVSE and source is replaced.
+func (n *ChargingLimitNotifier) SetLimit(evseID int, limit ChargingLimit, schedules ...types.ChargingSchedule) {
+	request := NewNotifyChargingLimitRequest(limit)
+	if evseID > 0 {
+		request.EvseID = &evseID
+	}
+	request.ChargingSchedule = schedules
+	key := externalLimitKey{evseID: evseID, source: limit.ChargingLimitSource}
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if pending, ok := n.pending[key]; ok {
+		pending.timer.Stop()
+	}
+	pending := &pendingLimit{request: request}
+	pending.timer = n.clock.AfterFunc(n.delay, func() {
+		n.flush(key, pending)
+	})
+	n.pending[key] = pending
+}
+
+// ClearLimit discards a pending notification for the EVSE and source, and notifies the CSMS if the limit was previously notified.
+func (n *ChargingLimitNotifier) ClearLimit(evseID int, source types.ChargingLimitSourceType) {
+	key := externalLimitKey{evseID: evseID, source: source}
+	n.mutex.Lock()
+	if pending, ok := n.pending[key]; ok {
+		pending.timer.Stop()
+		delete(n.pending, key)
+	}
+	_, notified := n.sent[key]
+	delete(n.sent, key)
+	n.mutex.Unlock()
+	if !notified {
+		return
+	}
+	request := NewClearedChargingLimitRequest(source)
+	if evseID > 0 {
+		request.EvseID = &evseID
+	}
+	if err := n.SendCleared(request); err != nil && n.OnSendError != nil {
+		n.OnSendError(ClearedChargingLimitFeatureName, err)
+	}
+}
+
+// Flush sends all pending notifications immediately.
+func (n *ChargingLimitNotifier) Flush() {
+	n.mutex.Lock()
+	pending := make(map[externalLimitKey]*pendingLimit, len(n.pending))
+	for key, limit := range n.pending {
+		pending[key] = limit
+	}
+	n.mutex.Unlock()
+	for key, limit := range pending {
+		n.flush(key, limit)
+	}
+}
+
+// Sends a pending notification, unless it was superseded or equals the last notified limit.
+func (n *ChargingLimitNotifier) flush(key externalLimitKey, pending *pendingLimit) {
+	n.mutex.Lock()
+	if n.pending[key] != pending {
+		n.mutex.Unlock()
+		return
+	}
+	pending.timer.Stop()
+	delete(n.pending, key)
+	if reflect.DeepEqual(n.sent[key], pending.request) {
+		n.mutex.Unlock()
+		return
+	}
+	n.sent[key] = pending.request
+	n.mutex.Unlock()
+	if err := n.SendNotify(pending.request); err != nil {
+		// The CSMS doesn't know about the limit, so it mustn't be cleared later on
+		n.mutex.Lock()
+		if n.sent[key] == pending.request {
+			delete(n.sent, key)
+		}
+		n.mutex.Unlock()
+		if n.OnSendError != nil {
+			n.OnSendError(NotifyChargingLimitFeatureName, err)
+		}
+	}
+}
@@ -37,6 +37,7 @@ type ChargingProfileCriterion struct {
 	StackLevel             *int                             `json:"stackLevel,omitempty" validate:"omitempty,gte=0"`
 	ChargingProfileID      []int                            `json:"chargingProfileId,omitempty" validate:"omitempty"` // This field SHALL NOT contain more ids than set in ChargingProfileEntries.maxLimit
 	ChargingLimitSource    []types.ChargingLimitSourceType  `json:"chargingLimitSource,omitempty" validate:"omitempty,max=4,dive,chargingLimitSource"`
+	CustomData             *types.CustomData                `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // The field definition of the GetChargingProfiles request payload sent by the CSMS to the Charging Station.
@@ -44,6 +45,7 @@ type GetChargingProfilesRequest struct {
 	RequestID       int                      `json:"requestId"`
 	EvseID          *int                     `json:"evseId,omitempty" validate:"omitempty,gte=0"`
 	ChargingProfile ChargingProfileCriterion `json:"chargingProfile" validate:"required"`
+	CustomData      *types.CustomData        `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // This field definition of the GetChargingProfiles response payload, sent by the Charging Station to the CSMS in response to a GetChargingProfilesRequest.
@@ -51,6 +53,7 @@ type GetChargingProfilesRequest struct {
 type GetChargingProfilesResponse struct {
 	Status     GetChargingProfileStatus `json:"status" validate:"required,getChargingProfileStatus"`
 	StatusInfo *types.StatusInfo        `json:"statusInfo,omitempty" validate:"omitempty"`
+	CustomData *types.CustomData        `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // The CSMS MAY ask a Charging Station to report all, or a subset of all the install Charging Profiles from the different possible sources, by sending a GetChargingProfilesRequest.
@@ -89,5 +92,5 @@ func NewGetChargingProfilesResponse(status GetChargingProfileStatus) *GetChargin
 }
 
 func init() {
-	_ = types.Validate.RegisterValidation("getChargingProfileStatus", isValidGetChargingProfileStatus)
+	_ = types.RegisterValidation("getChargingProfileStatus", isValidGetChargingProfileStatus)
 }
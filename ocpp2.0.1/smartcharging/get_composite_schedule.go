@@ -32,6 +32,7 @@ func isValidGetCompositeScheduleStatus(fl validator.FieldLevel) bool {
 type CompositeSchedule struct {
 	StartDateTime    *types.DateTime         `json:"startDateTime,omitempty" validate:"omitempty"`
 	ChargingSchedule *types.ChargingSchedule `json:"chargingSchedule,omitempty" validate:"omitempty"`
+	CustomData       *types.CustomData       `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // The field definition of the GetCompositeSchedule request payload sent by the CSMS to the Charging System.
@@ -39,14 +40,16 @@ type GetCompositeScheduleRequest struct {
 	Duration         int                        `json:"duration" validate:"gte=0"`
 	ChargingRateUnit types.ChargingRateUnitType `json:"chargingRateUnit,omitempty" validate:"omitempty,chargingRateUnit201"`
 	EvseID           int                        `json:"evseId" validate:"gte=0"`
+	CustomData       *types.CustomData          `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // This field definition of the GetCompositeSchedule response payload, sent by the Charging System to the CSMS in response to a GetCompositeScheduleRequest.
 // In case the request was invalid, or couldn't be processed, an error will be sent instead.
 type GetCompositeScheduleResponse struct {
-	Status   GetCompositeScheduleStatus `json:"status" validate:"required,getCompositeScheduleStatus"`
-	EvseID   int                        `json:"evseId" validate:"gte=0"`
-	Schedule *CompositeSchedule         `json:"schedule,omitempty" validate:"omitempty"`
+	Status     GetCompositeScheduleStatus `json:"status" validate:"required,getCompositeScheduleStatus"`
+	EvseID     int                        `json:"evseId" validate:"gte=0"`
+	Schedule   *CompositeSchedule         `json:"schedule,omitempty" validate:"omitempty"`
+	CustomData *types.CustomData          `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // The CSMS MAY request the Charging System to report the Composite Charging Schedule by sending a GetCompositeScheduleRequest.
@@ -87,5 +90,5 @@ func NewGetCompositeScheduleResponse(status GetCompositeScheduleStatus, evseId i
 }
 
 func init() {
-	_ = types.Validate.RegisterValidation("getCompositeScheduleStatus", isValidGetCompositeScheduleStatus)
+	_ = types.RegisterValidation("getCompositeScheduleStatus", isValidGetCompositeScheduleStatus)
 }
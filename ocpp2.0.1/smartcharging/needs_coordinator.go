@@ -0,0 +1,189 @@
+package smartcharging
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/clock"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// The default time a NeedsCoordinator waits for the EV to confirm a charging schedule.
+const DefaultNegotiationTimeout = 60 * time.Second
+
+// Errors reported by a NeedsCoordinator, in addition to errors returned by the planner.
+var (
+	ErrNoTransaction           = errors.New("no ongoing transaction on EVSE")
+	ErrChargingProfileRejected = errors.New("charging profile rejected by charging station")
+	ErrNegotiationTimeout      = errors.New("EV didn't confirm charging schedule in time")
+	ErrNegotiationSuperseded   = errors.New("superseded by new charging needs")
+)
+
+// NegotiationResult describes the outcome of a schedule negotiation, started by a NotifyEVChargingNeedsRequest.
+type NegotiationResult struct {
+	ChargingStationID string
+	EvseID            int
+	// The profile sent to the charging station. Nil, if no profile could be planned.
+	ChargingProfile *types.ChargingProfile
+	// The schedule confirmed by the EV via NotifyEVChargingSchedule. Nil, if the negotiation failed.
+	EVChargingSchedule *types.ChargingSchedule
+	// Nil, if the EV confirmed a schedule.
+	Err error
+}
+
+type negotiationKey struct {
+	chargingStationID string
+	evseID            int
+}
+
+type negotiation struct {
+	profile *types.ChargingProfile
+	timer   clock.Timer
+}
+
+// NeedsCoordinator automates the ISO 15118 schedule negotiation (use cases K15-K17) on the CSMS side.
+//
+// OnNotifyEVChargingNeeds and OnNotifyEVChargingSchedule match the respective CSMSHandler callbacks,
+// and should be invoked by the CSMS handler. On receiving charging needs, a profile is computed via the planner.
+// If planning fails, the charging needs are Rejected. Otherwise they are Accepted and the profile is sent to the
+// charging station, after which the EV is expected to confirm its charging schedule within the timeout.
+// Negotiations are correlated by charging station and evseId: new charging needs for an EVSE supersede
+// any ongoing negotiation on it.
+//
+// The outcome of every negotiation is reported via OnResult.
+//
+// A NeedsCoordinator is safe for concurrent use.
+type NeedsCoordinator struct {
+	// Computes the profiles. Required.
+	Planner NeedsPlanner
+	// Returns the power available to an EVSE (in W). Required.
+	AvailablePower func(chargingStationID string, evseID int) float64
+	// Returns the id of the ongoing transaction on an EVSE, or false if there is none. Required.
+	TransactionID func(chargingStationID string, evseID int) (string, bool)
+	// Sends a SetChargingProfileRequest to a charging station, e.g. CSMS.SetChargingProfile. Required.
+	SendChargingProfile func(clientId string, callback func(*SetChargingProfileResponse, error), evseID int, chargingProfile *types.ChargingProfile, props ...func(request *SetChargingProfileRequest)) error
+	// Invoked whenever a negotiation completed or failed. Optional.
+	OnResult func(result NegotiationResult)
+	timeout  time.Duration
+	pending  map[negotiationKey]*negotiation
+	clock    clock.Clock
+	mutex    sync.Mutex
+}
+
+// NewNeedsCoordinator creates a new coordinator. If timeout is zero, DefaultNegotiationTimeout is used.
+// The remaining callbacks must be set before use.
+func NewNeedsCoordinator(planner NeedsPlanner, timeout time.Duration) *NeedsCoordinator {
+	if timeout <= 0 {
+		timeout = DefaultNegotiationTimeout
+	}
+	return &NeedsCoordinator{
+		Planner: planner,
+		timeout: timeout,
+		pending: map[negotiationKey]*negotiation{},
+		clock:   clock.New(),
+	}
+}
+
+// SetTimeSource replaces the function used for retrieving the current time, while timers are backed by the real clock.
+//
+// Deprecated: Use SetClock instead.
+func (c *NeedsCoordinator) SetTimeSource(now func() time.Time) {
+	c.SetClock(clock.WithNow(now))
+}
+
+// SetClock sets the clock used for retrieving the current time and for the negotiation timeouts.
+// Passing nil restores the real clock.
+func (c *NeedsCoordinator) SetClock(clk clock.Clock) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.clock = clock.OrDefault(clk)
+}
+
+// OnNotifyEVChargingNeeds plans a profile for the charging needs and sends it to the charging station asynchronously.
+func (c *NeedsCoordinator) OnNotifyEVChargingNeeds(chargingStationID string, request *NotifyEVChargingNeedsRequest) (*NotifyEVChargingNeedsResponse, error) {
+	key := negotiationKey{chargingStationID: chargingStationID, evseID: request.EvseID}
+	c.finish(key, nil, NegotiationResult{Err: ErrNegotiationSuperseded})
+	transactionID, ok := c.TransactionID(chargingStationID, request.EvseID)
+	if !ok {
+		return c.reject(key, ErrNoTransaction, "NoTransaction"), nil
+	}
+	c.mutex.Lock()
+	now := c.clock.Now()
+	c.mutex.Unlock()
+	profile, err := c.Planner.Plan(PlanningRequest{
+		ChargingStationID: chargingStationID,
+		Request:           request,
+		TransactionID:     transactionID,
+		AvailablePower:    c.AvailablePower(chargingStationID, request.EvseID),
+		Now:               now,
+	})
+	if err != nil {
+		return c.reject(key, err, "NotFeasible"), nil
+	}
+	if violations := ValidateProfile201(*profile, request.EvseID); len(violations) > 0 {
+		return c.reject(key, &ProfileValidationError{Violations: violations}, "InternalError"), nil
+	}
+	pending := &negotiation{profile: profile}
+	c.mutex.Lock()
+	c.pending[key] = pending
+	pending.timer = c.clock.AfterFunc(c.timeout, func() {
+		c.finish(key, pending, NegotiationResult{Err: ErrNegotiationTimeout})
+	})
+	c.mutex.Unlock()
+	// The profile is sent after responding, as the charging station expects the response first
+	go func() {
+		err := c.SendChargingProfile(chargingStationID, func(response *SetChargingProfileResponse, err error) {
+			if err == nil && response.Status != ChargingProfileStatusAccepted {
+				err = ErrChargingProfileRejected
+			}
+			if err != nil {
+				c.finish(key, pending, NegotiationResult{Err: err})
+			}
+		}, request.EvseID, profile)
+		if err != nil {
+			c.finish(key, pending, NegotiationResult{Err: fmt.Errorf("couldn't send charging profile: %w", err)})
+		}
+	}()
+	return NewNotifyEVChargingNeedsResponse(EVChargingNeedsStatusAccepted), nil
+}
+
+// OnNotifyEVChargingSchedule completes the ongoing negotiation on the EVSE, if any. The schedule is always accepted.
+func (c *NeedsCoordinator) OnNotifyEVChargingSchedule(chargingStationID string, request *NotifyEVChargingScheduleRequest) (*NotifyEVChargingScheduleResponse, error) {
+	schedule := request.ChargingSchedule
+	c.finish(negotiationKey{chargingStationID: chargingStationID, evseID: request.EvseID}, nil, NegotiationResult{EVChargingSchedule: &schedule})
+	return NewNotifyEVChargingScheduleResponse(types.GenericStatusAccepted), nil
+}
+
+func (c *NeedsCoordinator) reject(key negotiationKey, err error, reasonCode string) *NotifyEVChargingNeedsResponse {
+	c.report(key, nil, NegotiationResult{Err: err})
+	response := NewNotifyEVChargingNeedsResponse(EVChargingNeedsStatusRejected)
+	response.StatusInfo = types.NewStatusInfo(reasonCode, "")
+	return response
+}
+
+// Removes the pending negotiation for the key and reports its result. If expected is set, the negotiation is only
+// finished if it is still the pending one. Does nothing if there is no matching negotiation.
+func (c *NeedsCoordinator) finish(key negotiationKey, expected *negotiation, result NegotiationResult) {
+	c.mutex.Lock()
+	pending, ok := c.pending[key]
+	if !ok || (expected != nil && pending != expected) {
+		c.mutex.Unlock()
+		return
+	}
+	delete(c.pending, key)
+	pending.timer.Stop()
+	c.mutex.Unlock()
+	c.report(key, pending.profile, result)
+}
+
+func (c *NeedsCoordinator) report(key negotiationKey, profile *types.ChargingProfile, result NegotiationResult) {
+	if c.OnResult == nil {
+		return
+	}
+	result.ChargingStationID = key.chargingStationID
+	result.EvseID = key.evseID
+	result.ChargingProfile = profile
+	c.OnResult(result)
+}
@@ -0,0 +1,163 @@
+package smartcharging
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// The default nominal voltage between phase and neutral, used by the DefaultNeedsPlanner for AC charging.
+const DefaultNominalVoltage = 230.0
+
+// Errors returned by the DefaultNeedsPlanner.
+var (
+	ErrInvalidChargingNeeds    = errors.New("charging parameters don't match the requested energy transfer mode")
+	ErrInfeasibleChargingNeeds = errors.New("charging needs can't be met before departure")
+)
+
+// PlanningRequest contains all inputs for computing a charging profile, after an EV reported its charging needs.
+type PlanningRequest struct {
+	ChargingStationID string
+	Request           *NotifyEVChargingNeedsRequest
+	TransactionID     string    // The id of the ongoing transaction on the EVSE.
+	AvailablePower    float64   // The power available to the EVSE (in W).
+	Now               time.Time // The time at which the schedule starts.
+}
+
+// NeedsPlanner computes a charging profile, which satisfies the charging needs of an EV (use cases K15-K17).
+type NeedsPlanner interface {
+	// Plan returns a TxProfile for the EVSE of the request, or an error if the charging needs can't be met.
+	Plan(request PlanningRequest) (*types.ChargingProfile, error)
+}
+
+// DefaultNeedsPlanner is a reference NeedsPlanner, charging at the highest rate supported by both the EV and the
+// available power, until the requested energy was delivered. Charging is then paused until the departure time.
+//
+// If the EV doesn't report a departure time, the schedule ends once the requested energy was delivered.
+// If the requested energy is unknown, the EV is charged at the highest rate until departure.
+// AC schedules are expressed in A, using the phases of the requested energy transfer mode, while DC schedules are expressed in W.
+type DefaultNeedsPlanner struct {
+	// The nominal voltage between phase and neutral, for AC charging. Defaults to DefaultNominalVoltage.
+	Voltage float64
+	// The id of generated profiles is ProfileIDBase + evseId, so that re-planning replaces the previous profile of an EVSE.
+	ProfileIDBase int
+	// The stack level of generated profiles.
+	StackLevel int
+}
+
+// Plan computes a TxProfile for the charging needs, or returns ErrInvalidChargingNeeds or ErrInfeasibleChargingNeeds.
+func (p *DefaultNeedsPlanner) Plan(request PlanningRequest) (*types.ChargingProfile, error) {
+	needs := request.Request.ChargingNeeds
+	var schedule *types.ChargingSchedule
+	var rate, power float64
+	var energy *int
+	if needs.RequestedEnergyTransfer == EnergyTransferModeDC {
+		params := needs.DCChargingParameters
+		if params == nil {
+			return nil, fmt.Errorf("%w: missing DC charging parameters", ErrInvalidChargingNeeds)
+		}
+		power = request.AvailablePower
+		if params.EVMaxPower != nil {
+			power = math.Min(power, float64(*params.EVMaxPower))
+		}
+		if params.EVMaxCurrent > 0 && params.EVMaxVoltage > 0 {
+			power = math.Min(power, float64(params.EVMaxCurrent*params.EVMaxVoltage))
+		}
+		power = math.Floor(power)
+		rate = power
+		schedule = types.NewChargingSchedule(request.Request.EvseID, types.ChargingRateUnitWatts)
+		energy = dcEnergyAmount(params)
+	} else {
+		params := needs.ACChargingParameters
+		phases := acPhases(needs.RequestedEnergyTransfer)
+		if params == nil || phases == 0 {
+			return nil, fmt.Errorf("%w: missing AC charging parameters", ErrInvalidChargingNeeds)
+		}
+		voltage := p.Voltage
+		if voltage <= 0 {
+			voltage = DefaultNominalVoltage
+		}
+		rate = math.Min(float64(params.EVMaxCurrent), math.Floor(request.AvailablePower/(voltage*float64(phases))*10)/10)
+		if rate < float64(params.EVMinCurrent) {
+			return nil, fmt.Errorf("%w: available current %v below EV minimum %v", ErrInfeasibleChargingNeeds, rate, params.EVMinCurrent)
+		}
+		power = rate * voltage * float64(phases)
+		schedule = types.NewChargingSchedule(request.Request.EvseID, types.ChargingRateUnitAmperes)
+		energy = &params.EnergyAmount
+	}
+	if power <= 0 {
+		return nil, fmt.Errorf("%w: no power available", ErrInfeasibleChargingNeeds)
+	}
+	// Time needed for delivering the requested energy, and time available until departure
+	chargingTime := -1
+	if energy != nil {
+		chargingTime = int(math.Ceil(float64(*energy) * 3600 / power))
+	}
+	departureTime := -1
+	if needs.DepartureTime != nil {
+		departureTime = int(needs.DepartureTime.Sub(request.Now) / time.Second)
+		if departureTime <= 0 {
+			return nil, fmt.Errorf("%w: departure time %v already passed", ErrInfeasibleChargingNeeds, needs.DepartureTime.FormatTimestamp())
+		}
+		if chargingTime > departureTime {
+			return nil, fmt.Errorf("%w: delivering %v Wh requires %v s, departure in %v s", ErrInfeasibleChargingNeeds, *energy, chargingTime, departureTime)
+		}
+	}
+	schedule.StartSchedule = types.NewDateTime(request.Now)
+	schedule.ChargingSchedulePeriod = []types.ChargingSchedulePeriod{types.NewChargingSchedulePeriod(0, rate)}
+	if needs.RequestedEnergyTransfer != EnergyTransferModeDC {
+		phases := acPhases(needs.RequestedEnergyTransfer)
+		schedule.ChargingSchedulePeriod[0].NumberPhases = &phases
+	}
+	switch {
+	case departureTime > 0:
+		schedule.Duration = &departureTime
+		maxTuples := request.Request.MaxScheduleTuples
+		if chargingTime > 0 && chargingTime < departureTime && (maxTuples == nil || *maxTuples > 1) {
+			pause := schedule.ChargingSchedulePeriod[0]
+			pause.StartPeriod = chargingTime
+			pause.Limit = 0
+			schedule.ChargingSchedulePeriod = append(schedule.ChargingSchedulePeriod, pause)
+		}
+	case chargingTime > 0:
+		schedule.Duration = &chargingTime
+	}
+	profile := types.NewChargingProfile(p.ProfileIDBase+request.Request.EvseID, p.StackLevel, types.ChargingProfilePurposeTxProfile, types.ChargingProfileKindAbsolute, []types.ChargingSchedule{*schedule})
+	profile.TransactionID = request.TransactionID
+	return profile, nil
+}
+
+func acPhases(mode EnergyTransferMode) int {
+	switch mode {
+	case EnergyTransferModeAC1Phase:
+		return 1
+	case EnergyTransferModeAC2Phase:
+		return 2
+	case EnergyTransferModeAC3Phase:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// Returns the energy requested by a DC EV, either directly or derived from its state of charge. Returns nil if unknown.
+func dcEnergyAmount(params *DCChargingParameters) *int {
+	if params.EnergyAmount != nil {
+		return params.EnergyAmount
+	}
+	if params.StateOfCharge == nil || params.EVEnergyCapacity == nil {
+		return nil
+	}
+	target := 100
+	if params.FullSoC != nil {
+		target = *params.FullSoC
+	}
+	energy := 0
+	if target > *params.StateOfCharge {
+		energy = (target - *params.StateOfCharge) * *params.EVEnergyCapacity / 100
+	}
+	return &energy
+}
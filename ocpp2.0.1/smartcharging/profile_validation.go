@@ -0,0 +1,217 @@
+package smartcharging
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// The maximum amount of entries within a SalesTariff, and of consumption costs and costs within each entry.
+const (
+	MaxSalesTariffEntries       = 1024
+	MaxConsumptionCostsPerEntry = 3
+	MaxCostsPerConsumptionCost  = 3
+)
+
+const (
+	maxChargingSchedulesPerProfile = 3
+	secondsPerDay                  = 24 * 60 * 60
+	secondsPerWeek                 = 7 * secondsPerDay
+)
+
+// Violations reported by ValidateProfile201. Each returned error wraps exactly one of these.
+var (
+	ErrExternalConstraintsProfile   = errors.New("ChargingStationExternalConstraints profiles may not be set by the CSMS")
+	ErrTxProfileTransactionID       = errors.New("TxProfile requires a transactionId")
+	ErrUnexpectedTransactionID      = errors.New("transactionId is only allowed for TxProfile")
+	ErrInvalidEvseID                = errors.New("evseId not allowed for charging profile purpose")
+	ErrStackLevelConflict           = errors.New("stackLevel already in use for charging profile purpose")
+	ErrInvalidValidityPeriod        = errors.New("validFrom must be before validTo")
+	ErrInvalidRecurrency            = errors.New("recurrencyKind must be set for, and only for, Recurring profiles")
+	ErrInvalidStartSchedule         = errors.New("startSchedule must be set for Absolute and Recurring profiles, and omitted for Relative profiles")
+	ErrRelativeMaxProfile           = errors.New("ChargingStationMaxProfile may not be Relative")
+	ErrInvalidChargingScheduleCount = errors.New("invalid amount of charging schedules")
+	ErrDuplicateChargingScheduleID  = errors.New("duplicate charging schedule id")
+	ErrInvalidSchedulePeriods       = errors.New("charging schedule periods must start at 0 and be in ascending order")
+	ErrInvalidScheduleDuration      = errors.New("charging schedule periods exceed the schedule duration")
+	ErrNegativeChargingRate         = errors.New("charging rates may not be negative")
+	ErrInvalidPhases                = errors.New("invalid phase configuration")
+	ErrInvalidSalesTariff           = errors.New("invalid amount of sales tariff entries")
+)
+
+// ProfileValidationError contains all violations found while validating a charging profile.
+// Use errors.Is to check for a specific violation.
+type ProfileValidationError struct {
+	Violations []error
+}
+
+func (e *ProfileValidationError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		messages[i] = violation.Error()
+	}
+	return fmt.Sprintf("invalid charging profile: %v", strings.Join(messages, "; "))
+}
+
+// Is returns true if any of the violations matches the target.
+func (e *ProfileValidationError) Is(target error) bool {
+	for _, violation := range e.Violations {
+		if errors.Is(violation, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateProfile201 checks a charging profile, which is about to be set on the given EVSE via a SetChargingProfileRequest,
+// against the cross-field rules of use case K01. All violations are returned, or nil if the profile is valid.
+// The field constraints enforced when sending the message are not checked again.
+//
+// Profiles with purpose ChargingStationExternalConstraints are always rejected, since only external systems may set them.
+//
+// Optionally, the profiles currently installed on the charging station may be passed. A profile may not use the same
+// purpose and stackLevel as an installed profile with a different id on the same EVSE, if their validity periods overlap.
+// TxProfiles only conflict with profiles of the same transaction.
+func ValidateProfile201(profile types.ChargingProfile, evseID int, installed ...SetChargingProfileRequest) []error {
+	var violations []error
+	switch profile.ChargingProfilePurpose {
+	case types.ChargingProfilePurposeChargingStationExternalConstraints:
+		violations = append(violations, ErrExternalConstraintsProfile)
+	case types.ChargingProfilePurposeChargingStationMaxProfile:
+		if evseID != 0 {
+			violations = append(violations, fmt.Errorf("%w: %v requires evseId 0, got %v", ErrInvalidEvseID, profile.ChargingProfilePurpose, evseID))
+		}
+		if profile.ChargingProfileKind == types.ChargingProfileKindRelative {
+			violations = append(violations, ErrRelativeMaxProfile)
+		}
+	case types.ChargingProfilePurposeTxProfile:
+		if profile.TransactionID == "" {
+			violations = append(violations, ErrTxProfileTransactionID)
+		}
+		if evseID <= 0 {
+			violations = append(violations, fmt.Errorf("%w: %v requires evseId > 0, got %v", ErrInvalidEvseID, profile.ChargingProfilePurpose, evseID))
+		}
+	}
+	if profile.TransactionID != "" && profile.ChargingProfilePurpose != types.ChargingProfilePurposeTxProfile {
+		violations = append(violations, ErrUnexpectedTransactionID)
+	}
+	if profile.ValidFrom != nil && profile.ValidTo != nil && !profile.ValidFrom.Before(profile.ValidTo.Time) {
+		violations = append(violations, ErrInvalidValidityPeriod)
+	}
+	if (profile.ChargingProfileKind == types.ChargingProfileKindRecurring) != (profile.RecurrencyKind != "") {
+		violations = append(violations, ErrInvalidRecurrency)
+	}
+	for _, other := range installed {
+		if conflictsWith(profile, evseID, other) {
+			violations = append(violations, fmt.Errorf("%w: stackLevel %v used by profile %v", ErrStackLevelConflict, profile.StackLevel, other.ChargingProfile.ID))
+		}
+	}
+	if len(profile.ChargingSchedule) < 1 || len(profile.ChargingSchedule) > maxChargingSchedulesPerProfile {
+		violations = append(violations, fmt.Errorf("%w: %v", ErrInvalidChargingScheduleCount, len(profile.ChargingSchedule)))
+	}
+	scheduleIDs := map[int]bool{}
+	for _, schedule := range profile.ChargingSchedule {
+		if scheduleIDs[schedule.ID] {
+			violations = append(violations, fmt.Errorf("%w: %v", ErrDuplicateChargingScheduleID, schedule.ID))
+		}
+		scheduleIDs[schedule.ID] = true
+		violations = append(violations, validateSchedule(profile, schedule)...)
+	}
+	return violations
+}
+
+func validateSchedule(profile types.ChargingProfile, schedule types.ChargingSchedule) []error {
+	var violations []error
+	wrap := func(err error, format string, args ...interface{}) {
+		violations = append(violations, fmt.Errorf("%w: schedule %v: %v", err, schedule.ID, fmt.Sprintf(format, args...)))
+	}
+	switch profile.ChargingProfileKind {
+	case types.ChargingProfileKindAbsolute, types.ChargingProfileKindRecurring:
+		if schedule.StartSchedule == nil {
+			wrap(ErrInvalidStartSchedule, "missing startSchedule for %v profile", profile.ChargingProfileKind)
+		}
+	case types.ChargingProfileKindRelative:
+		if schedule.StartSchedule != nil {
+			wrap(ErrInvalidStartSchedule, "unexpected startSchedule for Relative profile")
+		}
+	}
+	if schedule.MinChargingRate != nil && *schedule.MinChargingRate < 0 {
+		wrap(ErrNegativeChargingRate, "minChargingRate %v", *schedule.MinChargingRate)
+	}
+	// The recurrence limits the duration of a schedule
+	maxDuration := 0
+	switch profile.RecurrencyKind {
+	case types.RecurrencyKindDaily:
+		maxDuration = secondsPerDay
+	case types.RecurrencyKindWeekly:
+		maxDuration = secondsPerWeek
+	}
+	if schedule.Duration != nil && maxDuration > 0 && *schedule.Duration > maxDuration {
+		wrap(ErrInvalidScheduleDuration, "duration %v exceeds %v recurrence", *schedule.Duration, profile.RecurrencyKind)
+	}
+	if schedule.Duration != nil && (maxDuration == 0 || *schedule.Duration < maxDuration) {
+		maxDuration = *schedule.Duration
+	}
+	for i, period := range schedule.ChargingSchedulePeriod {
+		if i == 0 && period.StartPeriod != 0 {
+			wrap(ErrInvalidSchedulePeriods, "first period starts at %v", period.StartPeriod)
+		} else if i > 0 && period.StartPeriod <= schedule.ChargingSchedulePeriod[i-1].StartPeriod {
+			wrap(ErrInvalidSchedulePeriods, "period %v starts at %v", i, period.StartPeriod)
+		}
+		if maxDuration > 0 && period.StartPeriod >= maxDuration {
+			wrap(ErrInvalidScheduleDuration, "period %v starts at %v, after duration %v", i, period.StartPeriod, maxDuration)
+		}
+		if period.Limit < 0 {
+			wrap(ErrNegativeChargingRate, "period %v has limit %v", i, period.Limit)
+		}
+		if period.NumberPhases != nil && (*period.NumberPhases < 1 || *period.NumberPhases > 3) {
+			wrap(ErrInvalidPhases, "period %v uses %v phases", i, *period.NumberPhases)
+		}
+		if period.PhaseToUse != nil {
+			if period.NumberPhases == nil || *period.NumberPhases != 1 {
+				wrap(ErrInvalidPhases, "period %v sets phaseToUse without numberPhases 1", i)
+			} else if *period.PhaseToUse < 1 || *period.PhaseToUse > 3 {
+				wrap(ErrInvalidPhases, "period %v uses phase %v", i, *period.PhaseToUse)
+			}
+		}
+	}
+	if tariff := schedule.SalesTariff; tariff != nil {
+		if len(tariff.SalesTariffEntry) < 1 || len(tariff.SalesTariffEntry) > MaxSalesTariffEntries {
+			wrap(ErrInvalidSalesTariff, "%v entries", len(tariff.SalesTariffEntry))
+		}
+		for i, entry := range tariff.SalesTariffEntry {
+			if len(entry.ConsumptionCost) > MaxConsumptionCostsPerEntry {
+				wrap(ErrInvalidSalesTariff, "entry %v has %v consumption costs", i, len(entry.ConsumptionCost))
+			}
+			for _, consumptionCost := range entry.ConsumptionCost {
+				if len(consumptionCost.Cost) < 1 || len(consumptionCost.Cost) > MaxCostsPerConsumptionCost {
+					wrap(ErrInvalidSalesTariff, "entry %v has a consumption cost with %v costs", i, len(consumptionCost.Cost))
+				}
+			}
+		}
+	}
+	return violations
+}
+
+// Two profiles conflict, if they share EVSE, purpose and stack level, and their validity periods overlap.
+func conflictsWith(profile types.ChargingProfile, evseID int, other SetChargingProfileRequest) bool {
+	installed := other.ChargingProfile
+	if installed == nil || installed.ID == profile.ID || other.EvseID != evseID {
+		return false
+	}
+	if installed.ChargingProfilePurpose != profile.ChargingProfilePurpose || installed.StackLevel != profile.StackLevel {
+		return false
+	}
+	if profile.ChargingProfilePurpose == types.ChargingProfilePurposeTxProfile && installed.TransactionID != profile.TransactionID {
+		return false
+	}
+	if profile.ValidTo != nil && installed.ValidFrom != nil && !installed.ValidFrom.Before(profile.ValidTo.Time) {
+		return false
+	}
+	if installed.ValidTo != nil && profile.ValidFrom != nil && !profile.ValidFrom.Before(installed.ValidTo.Time) {
+		return false
+	}
+	return true
+}
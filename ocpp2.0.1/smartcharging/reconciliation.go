@@ -0,0 +1,126 @@
+package smartcharging
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// ProfileDifference describes a charging profile, which is installed on a charging station with different content than expected.
+type ProfileDifference struct {
+	Expected SetChargingProfileRequest
+	Reported ReportedProfile
+	Fields   []string // The JSON names of the differing fields, e.g. "stackLevel" or "chargingSchedule[0].duration".
+}
+
+// ReconciliationResult lists the differences between the charging profiles a CSMS expects to be installed on a charging station,
+// and the ones actually reported by it.
+type ReconciliationResult struct {
+	// Expected profiles not reported by the charging station.
+	Missing []SetChargingProfileRequest
+	// Reported profiles set by the CSMS, which weren't expected.
+	Unexpected []ReportedProfile
+	// Profiles reported with different content than expected.
+	Differences []ProfileDifference
+}
+
+// InSync returns true if the reported profiles match the expected ones.
+func (r ReconciliationResult) InSync() bool {
+	return len(r.Missing) == 0 && len(r.Unexpected) == 0 && len(r.Differences) == 0
+}
+
+// RepairRequests returns the requests for re-installing all missing and differing profiles, in order.
+// Unexpected profiles may be removed via ClearChargingProfile.
+func (r ReconciliationResult) RepairRequests() []*SetChargingProfileRequest {
+	var requests []*SetChargingProfileRequest
+	for i := range r.Missing {
+		request := r.Missing[i]
+		requests = append(requests, &request)
+	}
+	for i := range r.Differences {
+		request := r.Differences[i].Expected
+		requests = append(requests, &request)
+	}
+	return requests
+}
+
+// Reconcile compares the charging profiles a CSMS expects to be installed on a charging station, i.e. the last accepted
+// SetChargingProfileRequest for each profile, with the profiles reported via ReportChargingProfiles.
+//
+// Profiles are matched by id. Reported profiles from a charging limit source other than CSO weren't set by the CSMS,
+// and are ignored. Since the charging station may fill in or renumber some values, schedule ids are never compared,
+// while validFrom and startSchedule are only compared if they were set in the expected profile.
+func Reconcile(expected []SetChargingProfileRequest, reported []ReportedProfile) ReconciliationResult {
+	result := ReconciliationResult{}
+	reportedByID := map[int]ReportedProfile{}
+	for _, profile := range reported {
+		if profile.ChargingLimitSource != types.ChargingLimitSourceCSO {
+			continue
+		}
+		reportedByID[profile.ChargingProfile.ID] = profile
+	}
+	expectedIDs := map[int]bool{}
+	for _, request := range expected {
+		if request.ChargingProfile == nil {
+			continue
+		}
+		expectedIDs[request.ChargingProfile.ID] = true
+		actual, ok := reportedByID[request.ChargingProfile.ID]
+		if !ok {
+			result.Missing = append(result.Missing, request)
+			continue
+		}
+		if fields := profileDifferences(request, actual); len(fields) > 0 {
+			result.Differences = append(result.Differences, ProfileDifference{Expected: request, Reported: actual, Fields: fields})
+		}
+	}
+	for _, profile := range reported {
+		if profile.ChargingLimitSource == types.ChargingLimitSourceCSO && !expectedIDs[profile.ChargingProfile.ID] {
+			result.Unexpected = append(result.Unexpected, profile)
+		}
+	}
+	return result
+}
+
+func profileDifferences(request SetChargingProfileRequest, reported ReportedProfile) []string {
+	var fields []string
+	expected := request.ChargingProfile
+	actual := reported.ChargingProfile
+	check := func(field string, equal bool) {
+		if !equal {
+			fields = append(fields, field)
+		}
+	}
+	check("evseId", request.EvseID == reported.EvseID)
+	check("stackLevel", expected.StackLevel == actual.StackLevel)
+	check("chargingProfilePurpose", expected.ChargingProfilePurpose == actual.ChargingProfilePurpose)
+	check("chargingProfileKind", expected.ChargingProfileKind == actual.ChargingProfileKind)
+	check("recurrencyKind", expected.RecurrencyKind == actual.RecurrencyKind)
+	check("validFrom", expected.ValidFrom == nil || equalDateTime(expected.ValidFrom, actual.ValidFrom))
+	check("validTo", equalDateTime(expected.ValidTo, actual.ValidTo))
+	check("transactionId", expected.TransactionID == actual.TransactionID)
+	for i := 0; i < len(expected.ChargingSchedule) || i < len(actual.ChargingSchedule); i++ {
+		prefix := fmt.Sprintf("chargingSchedule[%d]", i)
+		if i >= len(expected.ChargingSchedule) || i >= len(actual.ChargingSchedule) {
+			fields = append(fields, prefix)
+			continue
+		}
+		expectedSchedule := expected.ChargingSchedule[i]
+		actualSchedule := actual.ChargingSchedule[i]
+		check(prefix+".startSchedule", expectedSchedule.StartSchedule == nil || equalDateTime(expectedSchedule.StartSchedule, actualSchedule.StartSchedule))
+		check(prefix+".duration", reflect.DeepEqual(expectedSchedule.Duration, actualSchedule.Duration))
+		check(prefix+".chargingRateUnit", expectedSchedule.ChargingRateUnit == actualSchedule.ChargingRateUnit)
+		check(prefix+".minChargingRate", reflect.DeepEqual(expectedSchedule.MinChargingRate, actualSchedule.MinChargingRate))
+		check(prefix+".chargingSchedulePeriod", reflect.DeepEqual(expectedSchedule.ChargingSchedulePeriod, actualSchedule.ChargingSchedulePeriod))
+		check(prefix+".salesTariff", reflect.DeepEqual(expectedSchedule.SalesTariff, actualSchedule.SalesTariff))
+	}
+	return fields
+}
+
+func equalDateTime(a *types.DateTime, b *types.DateTime) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(b.Time)
+}
@@ -0,0 +1,223 @@
+package smartcharging
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/clock"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+const (
+	// The default time after which an incomplete report is delivered, if no further part was received.
+	DefaultReportInactivityTimeout = 60 * time.Second
+	// The default maximum amount of charging profiles buffered per charging station, across all pending reports.
+	DefaultMaxReportedProfilesPerStation = 1000
+	// The default maximum amount of concurrently pending reports per charging station.
+	DefaultMaxPendingReportsPerStation = 8
+)
+
+// PartialReportError is passed to the completion handler when a report is incomplete,
+// because no further part was received before the inactivity timeout.
+type PartialReportError struct {
+	ChargingStationID string
+	RequestID         int
+	ReceivedParts     int
+}
+
+func (e *PartialReportError) Error() string {
+	return fmt.Sprintf("report %v from %v: incomplete after %v parts", e.RequestID, e.ChargingStationID, e.ReceivedParts)
+}
+
+// ReportLimitError is returned when a report would exceed the buffering limits of a charging station.
+type ReportLimitError struct {
+	ChargingStationID string
+	RequestID         int
+	Reason            string
+}
+
+func (e *ReportLimitError) Error() string {
+	return fmt.Sprintf("report %v from %v: %v", e.RequestID, e.ChargingStationID, e.Reason)
+}
+
+// ReportedProfile is a charging profile installed on a charging station, as reported via ReportChargingProfiles.
+type ReportedProfile struct {
+	EvseID              int
+	ChargingLimitSource types.ChargingLimitSourceType
+	ChargingProfile     types.ChargingProfile
+}
+
+// ChargingProfilesReportCompletionHandler is invoked by a ChargingProfilesReportAssembler once a report was fully received, or was aborted.
+// If err is not nil, profiles contains the parts received so far.
+type ChargingProfilesReportCompletionHandler func(chargingStationID string, requestID int, profiles []ReportedProfile, err error)
+
+type reportKey struct {
+	chargingStationID string
+	requestID         int
+}
+
+type pendingReport struct {
+	parts    int
+	profiles []ReportedProfile
+	timer    clock.Timer
+}
+
+// ChargingProfilesReportAssembler reassembles reports sent by charging stations as a sequence of ReportChargingProfilesRequest
+// messages, in response to a GetChargingProfilesRequest.
+//
+// Each received ReportChargingProfilesRequest should be passed to Add. Parts are grouped by charging station and requestId.
+// Once the last part (tbc = false) is received, the completion handler is invoked with all reported profiles in order.
+// Since parts aren't numbered, retransmissions can't be detected.
+//
+// A report is aborted, and the completion handler invoked with an error, if:
+//
+// - no further part is received within the inactivity timeout (PartialReportError)
+//
+// - the buffering limits of the charging station are exceeded (ReportLimitError)
+//
+// A ChargingProfilesReportAssembler is safe for concurrent use.
+type ChargingProfilesReportAssembler struct {
+	onComplete        ChargingProfilesReportCompletionHandler
+	timeout           time.Duration
+	maxProfiles       int
+	maxPendingReports int
+	reports           map[reportKey]*pendingReport
+	stationProfiles   map[string]int
+	stationReports    map[string]int
+	clock             clock.Clock
+	mutex             sync.Mutex
+}
+
+// NewChargingProfilesReportAssembler creates a new assembler, invoking the passed handler for every completed or aborted report.
+// If timeout is zero, DefaultReportInactivityTimeout is used.
+func NewChargingProfilesReportAssembler(timeout time.Duration, onComplete ChargingProfilesReportCompletionHandler) *ChargingProfilesReportAssembler {
+	if timeout <= 0 {
+		timeout = DefaultReportInactivityTimeout
+	}
+	return &ChargingProfilesReportAssembler{
+		onComplete:        onComplete,
+		timeout:           timeout,
+		maxProfiles:       DefaultMaxReportedProfilesPerStation,
+		maxPendingReports: DefaultMaxPendingReportsPerStation,
+		reports:           map[reportKey]*pendingReport{},
+		stationProfiles:   map[string]int{},
+		stationReports:    map[string]int{},
+		clock:             clock.New(),
+	}
+}
+
+// SetLimits configures the maximum amount of charging profiles buffered per charging station,
+// and the maximum amount of concurrently pending reports per charging station.
+func (a *ChargingProfilesReportAssembler) SetLimits(maxProfiles int, maxPendingReports int) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.maxProfiles = maxProfiles
+	a.maxPendingReports = maxPendingReports
+}
+
+// SetClock sets the clock used for the inactivity timeout of pending reports. Passing nil restores the real clock.
+//
+// Timers already running are not affected.
+func (a *ChargingProfilesReportAssembler) SetClock(c clock.Clock) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.clock = clock.OrDefault(c)
+}
+
+// Add feeds a report part received from a charging station to the assembler.
+//
+// If the part couldn't be added, the report is aborted and the error is returned as well as passed to the completion handler.
+func (a *ChargingProfilesReportAssembler) Add(chargingStationID string, request *ReportChargingProfilesRequest) error {
+	if request == nil {
+		return nil
+	}
+	key := reportKey{chargingStationID: chargingStationID, requestID: request.RequestID}
+	a.mutex.Lock()
+	report, ok := a.reports[key]
+	if !ok {
+		if a.stationReports[chargingStationID] >= a.maxPendingReports {
+			a.mutex.Unlock()
+			err := &ReportLimitError{ChargingStationID: chargingStationID, RequestID: request.RequestID, Reason: "too many pending reports"}
+			a.complete(key, nil, err)
+			return err
+		}
+		report = &pendingReport{}
+		report.timer = a.clock.AfterFunc(a.timeout, func() {
+			a.onTimeout(key, report)
+		})
+		a.reports[key] = report
+		a.stationReports[chargingStationID]++
+	}
+	if a.stationProfiles[chargingStationID]+len(request.ChargingProfile) > a.maxProfiles {
+		err := &ReportLimitError{ChargingStationID: chargingStationID, RequestID: request.RequestID, Reason: "too many buffered charging profiles"}
+		a.remove(key, report)
+		a.mutex.Unlock()
+		a.complete(key, report.profiles, err)
+		return err
+	}
+	report.parts++
+	for _, profile := range request.ChargingProfile {
+		report.profiles = append(report.profiles, ReportedProfile{EvseID: request.EvseID, ChargingLimitSource: request.ChargingLimitSource, ChargingProfile: profile})
+	}
+	a.stationProfiles[chargingStationID] += len(request.ChargingProfile)
+	if request.Tbc {
+		report.timer.Reset(a.timeout)
+		a.mutex.Unlock()
+		return nil
+	}
+	a.remove(key, report)
+	a.mutex.Unlock()
+	a.complete(key, report.profiles, nil)
+	return nil
+}
+
+// Discard drops all pending reports of a charging station, without invoking the completion handler.
+// This may be invoked when a charging station disconnects.
+func (a *ChargingProfilesReportAssembler) Discard(chargingStationID string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for key, report := range a.reports {
+		if key.chargingStationID == chargingStationID {
+			a.remove(key, report)
+		}
+	}
+}
+
+// PendingReports returns the amount of incomplete reports currently buffered for a charging station.
+func (a *ChargingProfilesReportAssembler) PendingReports(chargingStationID string) int {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.stationReports[chargingStationID]
+}
+
+func (a *ChargingProfilesReportAssembler) onTimeout(key reportKey, report *pendingReport) {
+	a.mutex.Lock()
+	if a.reports[key] != report {
+		// Already completed
+		a.mutex.Unlock()
+		return
+	}
+	a.remove(key, report)
+	a.mutex.Unlock()
+	err := &PartialReportError{ChargingStationID: key.chargingStationID, RequestID: key.requestID, ReceivedParts: report.parts}
+	a.complete(key, report.profiles, err)
+}
+
+// Must be invoked while holding the lock.
+func (a *ChargingProfilesReportAssembler) remove(key reportKey, report *pendingReport) {
+	report.timer.Stop()
+	delete(a.reports, key)
+	a.stationProfiles[key.chargingStationID] -= len(report.profiles)
+	a.stationReports[key.chargingStationID]--
+	if a.stationReports[key.chargingStationID] <= 0 {
+		delete(a.stationReports, key.chargingStationID)
+		delete(a.stationProfiles, key.chargingStationID)
+	}
+}
+
+func (a *ChargingProfilesReportAssembler) complete(key reportKey, profiles []ReportedProfile, err error) {
+	if a.onComplete != nil {
+		a.onComplete(key.chargingStationID, key.requestID, profiles, err)
+	}
+}
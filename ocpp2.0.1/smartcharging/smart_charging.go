@@ -2,6 +2,8 @@
 package smartcharging
 
 import (
+	"context"
+
 	"github.com/lorenzodonini/ocpp-go/ocpp"
 )
 
@@ -19,6 +21,53 @@ type CSMSHandler interface {
 	OnReportChargingProfiles(chargingStationID string, request *ReportChargingProfilesRequest) (reponse *ReportChargingProfilesResponse, err error)
 }
 
+// Context-aware variant of CSMSHandler, for handling messages part of the OCPP 2.0 Smart charging profile.
+type CSMSHandlerWithContext interface {
+	// OnClearedChargingLimit is called on the CSMS whenever a ClearedChargingLimitRequest is received from a charging station.
+	OnClearedChargingLimit(ctx context.Context, chargingStationID string, request *ClearedChargingLimitRequest) (response *ClearedChargingLimitResponse, err error)
+	// OnNotifyChargingLimit is called on the CSMS whenever a NotifyChargingLimitRequest is received from a charging station.
+	OnNotifyChargingLimit(ctx context.Context, chargingStationID string, request *NotifyChargingLimitRequest) (response *NotifyChargingLimitResponse, err error)
+	// OnNotifyEVChargingNeeds is called on the CSMS whenever a NotifyEVChargingNeedsRequest is received from a charging station.
+	OnNotifyEVChargingNeeds(ctx context.Context, chargingStationID string, request *NotifyEVChargingNeedsRequest) (response *NotifyEVChargingNeedsResponse, err error)
+	// OnNotifyEVChargingSchedule is called on the CSMS whenever a NotifyEVChargingScheduleRequest is received from a charging station.
+	OnNotifyEVChargingSchedule(ctx context.Context, chargingStationID string, request *NotifyEVChargingScheduleRequest) (response *NotifyEVChargingScheduleResponse, err error)
+	// OnReportChargingProfiles is called on the CSMS whenever a ReportChargingProfilesRequest is received from a charging station.
+	OnReportChargingProfiles(ctx context.Context, chargingStationID string, request *ReportChargingProfilesRequest) (reponse *ReportChargingProfilesResponse, err error)
+}
+
+// AdaptCSMSHandler converts a CSMSHandler into a CSMSHandlerWithContext, which ignores the context.
+// Returns nil if handler is nil.
+func AdaptCSMSHandler(handler CSMSHandler) CSMSHandlerWithContext {
+	if handler == nil {
+		return nil
+	}
+	return csmsHandlerAdapter{handler: handler}
+}
+
+type csmsHandlerAdapter struct {
+	handler CSMSHandler
+}
+
+func (a csmsHandlerAdapter) OnClearedChargingLimit(ctx context.Context, chargingStationID string, request *ClearedChargingLimitRequest) (*ClearedChargingLimitResponse, error) {
+	return a.handler.OnClearedChargingLimit(chargingStationID, request)
+}
+
+func (a csmsHandlerAdapter) OnNotifyChargingLimit(ctx context.Context, chargingStationID string, request *NotifyChargingLimitRequest) (*NotifyChargingLimitResponse, error) {
+	return a.handler.OnNotifyChargingLimit(chargingStationID, request)
+}
+
+func (a csmsHandlerAdapter) OnNotifyEVChargingNeeds(ctx context.Context, chargingStationID string, request *NotifyEVChargingNeedsRequest) (*NotifyEVChargingNeedsResponse, error) {
+	return a.handler.OnNotifyEVChargingNeeds(chargingStationID, request)
+}
+
+func (a csmsHandlerAdapter) OnNotifyEVChargingSchedule(ctx context.Context, chargingStationID string, request *NotifyEVChargingScheduleRequest) (*NotifyEVChargingScheduleResponse, error) {
+	return a.handler.OnNotifyEVChargingSchedule(chargingStationID, request)
+}
+
+func (a csmsHandlerAdapter) OnReportChargingProfiles(ctx context.Context, chargingStationID string, request *ReportChargingProfilesRequest) (*ReportChargingProfilesResponse, error) {
+	return a.handler.OnReportChargingProfiles(chargingStationID, request)
+}
+
 // Needs to be implemented by Charging stations for handling messages part of the OCPP 2.0 Smart charging profile.
 type ChargingStationHandler interface {
 	// OnClearChargingProfile is called on a charging station whenever a ClearChargingProfileRequest is received from the CSMS.
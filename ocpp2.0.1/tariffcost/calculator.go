@@ -0,0 +1,23 @@
+package tariffcost
+
+import "github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
+
+// TransactionSnapshot contains the state of a transaction, as accumulated from all TransactionEvent messages
+// received so far (including the current one), as well as the currency configured on the CSMS.
+type TransactionSnapshot struct {
+	transactions.TransactionState
+	// The currency in which the cost is expected, as ISO 4217 code.
+	Currency string
+}
+
+// Calculator computes the cost of a transaction on the CSMS side. Refer to CSMS.SetCostCalculator.
+//
+// Cost is invoked for every TransactionEvent message, for which the CSMS handler didn't set a totalCost in the response.
+// The returned cost is sent as totalCost. A nil cost omits the field: according to the specification,
+// the final cost SHALL only be sent once the transaction ended, hence most calculators return nil
+// unless event.EventType is Ended.
+//
+// When invoked by a CostUpdater, no event triggered the calculation and event is nil.
+type Calculator interface {
+	Cost(event *transactions.TransactionEventRequest, history TransactionSnapshot) (*float64, error)
+}
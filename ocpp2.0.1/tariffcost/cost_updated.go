@@ -1,6 +1,7 @@
 package tariffcost
 
 import (
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
 	"reflect"
 )
 
@@ -10,13 +11,15 @@ const CostUpdatedFeatureName = "CostUpdated"
 
 // The field definition of the CostUpdated request payload sent by the CSMS to the Charging Station.
 type CostUpdatedRequest struct {
-	TotalCost     float64 `json:"totalCost" validate:"required"`
-	TransactionID string  `json:"transactionId" validate:"required,max=36"`
+	TotalCost     float64           `json:"totalCost" validate:"required"`
+	TransactionID string            `json:"transactionId" validate:"required,max=36"`
+	CustomData    *types.CustomData `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // This field definition of the CostUpdated response payload, sent by the Charging Station to the CSMS in response to a CostUpdatedRequest.
 // In case the request was invalid, or couldn't be processed, an error will be sent instead.
 type CostUpdatedResponse struct {
+	CustomData *types.CustomData `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // The driver wants to know how much the running total cost is, updated at a relevant interval, while a transaction is ongoing.
@@ -0,0 +1,192 @@
+package tariffcost
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/clock"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
+)
+
+// The maximum period after which a running CostUpdater checks for due updates.
+const maxCostUpdateTickPeriod = time.Second
+
+type costUpdateKey struct {
+	chargingStationID string
+	transactionID     string
+}
+
+// CostUpdater periodically sends CostUpdatedRequest messages for all ongoing transactions on the CSMS side,
+// allowing charging stations to display the running cost to the driver.
+//
+// Ongoing transactions are discovered through a transactions.EventTracker, which the updater registers with.
+// Updates for a transaction start one interval after its first event and stop automatically once the transaction ended,
+// or after OnChargingStationDisconnected was invoked for the owning charging station.
+//
+// The running cost is computed by a Calculator, with a nil event. If the calculator returns a nil cost, no update is sent.
+// Errors, both from the calculator and from sending, are passed to the error handler and only affect the respective transaction.
+//
+// Updates are sent while the updater is running (see Start). Alternatively, Tick may be invoked manually.
+type CostUpdater struct {
+	// Sends a CostUpdatedRequest to a charging station. The function shouldn't block, e.g. by using CSMS.SendRequestAsync.
+	Send func(chargingStationID string, request *CostUpdatedRequest) error
+	// Invoked whenever an update couldn't be computed or sent. Optional.
+	OnError func(chargingStationID string, transactionID string, err error)
+	// The currency passed to the calculator.
+	Currency     string
+	tracker      *transactions.EventTracker
+	calculator   Calculator
+	interval     time.Duration
+	clock        clock.Clock
+	transactions map[costUpdateKey]time.Time
+	stopC        chan struct{}
+	mutex        sync.Mutex
+}
+
+// NewCostUpdater creates a new updater, sending an update for every ongoing transaction of the tracker, every interval.
+// The updater is registered as listener with the tracker.
+func NewCostUpdater(tracker *transactions.EventTracker, calculator Calculator, interval time.Duration, send func(chargingStationID string, request *CostUpdatedRequest) error) *CostUpdater {
+	u := &CostUpdater{
+		Send:         send,
+		tracker:      tracker,
+		calculator:   calculator,
+		interval:     interval,
+		clock:        clock.New(),
+		transactions: map[costUpdateKey]time.Time{},
+	}
+	tracker.AddListener(u.onTransactionState)
+	return u
+}
+
+// SetTimeSource replaces the function used for retrieving the current time, while timers are backed by the real clock.
+//
+// Deprecated: Use SetClock instead.
+func (u *CostUpdater) SetTimeSource(now func() time.Time) {
+	u.SetClock(clock.WithNow(now))
+}
+
+// SetClock sets the clock used for retrieving the current time and for scheduling updates.
+// Passing nil restores the real clock. The clock must be set before starting the updater.
+func (u *CostUpdater) SetClock(c clock.Clock) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.clock = clock.OrDefault(c)
+}
+
+// Start begins sending updates in the background. Calling Start on a running updater has no effect.
+func (u *CostUpdater) Start() {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if u.stopC != nil {
+		return
+	}
+	period := u.interval
+	if period > maxCostUpdateTickPeriod {
+		period = maxCostUpdateTickPeriod
+	}
+	stopC := make(chan struct{})
+	u.stopC = stopC
+	ticker := u.clock.NewTicker(period)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C():
+				u.Tick()
+			case <-stopC:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops sending updates in the background. Ongoing transactions are still tracked.
+func (u *CostUpdater) Stop() {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if u.stopC != nil {
+		close(u.stopC)
+		u.stopC = nil
+	}
+}
+
+// Tick sends an update for every transaction, for which an update is due.
+func (u *CostUpdater) Tick() {
+	u.mutex.Lock()
+	now := u.clock.Now()
+	var due []costUpdateKey
+	for key, next := range u.transactions {
+		if !now.Before(next) {
+			due = append(due, key)
+			u.transactions[key] = now.Add(u.interval)
+		}
+	}
+	u.mutex.Unlock()
+	for _, key := range due {
+		if err := u.update(key); err != nil && u.OnError != nil {
+			u.OnError(key.chargingStationID, key.transactionID, err)
+		}
+	}
+}
+
+// ForceUpdate immediately sends an update for an ongoing transaction. The next periodic update is due one interval later.
+func (u *CostUpdater) ForceUpdate(chargingStationID string, transactionID string) error {
+	key := costUpdateKey{chargingStationID: chargingStationID, transactionID: transactionID}
+	u.mutex.Lock()
+	if _, ok := u.transactions[key]; !ok {
+		u.mutex.Unlock()
+		return fmt.Errorf("no ongoing transaction %v for charging station %v", transactionID, chargingStationID)
+	}
+	u.transactions[key] = u.clock.Now().Add(u.interval)
+	u.mutex.Unlock()
+	return u.update(key)
+}
+
+// OnChargingStationDisconnected stops all updates for a charging station.
+// Typically invoked from the CSMS' charging station disconnected handler.
+func (u *CostUpdater) OnChargingStationDisconnected(chargingStationID string) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	for key := range u.transactions {
+		if key.chargingStationID == chargingStationID {
+			delete(u.transactions, key)
+		}
+	}
+}
+
+// ActiveTransactions returns the amount of transactions, for which updates are currently scheduled.
+func (u *CostUpdater) ActiveTransactions() int {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	return len(u.transactions)
+}
+
+func (u *CostUpdater) onTransactionState(state transactions.TransactionState) {
+	key := costUpdateKey{chargingStationID: state.ChargingStationID, transactionID: state.TransactionID}
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if state.Ended || state.Completed {
+		delete(u.transactions, key)
+		return
+	}
+	if _, ok := u.transactions[key]; !ok {
+		u.transactions[key] = u.clock.Now().Add(u.interval)
+	}
+}
+
+func (u *CostUpdater) update(key costUpdateKey) error {
+	state, ok := u.tracker.TransactionState(key.chargingStationID, key.transactionID)
+	if !ok {
+		// The transaction was removed from the tracker, without ending
+		u.mutex.Lock()
+		delete(u.transactions, key)
+		u.mutex.Unlock()
+		return fmt.Errorf("unknown transaction %v for charging station %v", key.transactionID, key.chargingStationID)
+	}
+	totalCost, err := u.calculator.Cost(nil, TransactionSnapshot{TransactionState: state, Currency: u.Currency})
+	if err != nil || totalCost == nil {
+		return err
+	}
+	return u.Send(key.chargingStationID, NewCostUpdatedRequest(*totalCost, key.transactionID))
+}
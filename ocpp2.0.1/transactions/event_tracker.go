@@ -0,0 +1,404 @@
+package transactions
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/clock"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+const (
+	// The default time a finished transaction is retained, in order to detect late or duplicated events.
+	DefaultTransactionRetention = 10 * time.Minute
+	// The default time to wait for missing events, after the Ended event of a transaction was received.
+	DefaultSequenceGapTimeout = 60 * time.Second
+)
+
+// AnomalyType describes an irregularity in the sequence of TransactionEvent messages of a transaction.
+type AnomalyType string
+
+const (
+	AnomalyDuplicateSeqNo  AnomalyType = "DuplicateSeqNo"  // An event with the same seqNo was already received. The event is ignored.
+	AnomalySequenceGap     AnomalyType = "SequenceGap"     // Events are still missing, after the gap timeout expired.
+	AnomalyMissingStarted  AnomalyType = "MissingStarted"  // The transaction ended, but the Started event was never received.
+	AnomalyOutOfSequence   AnomalyType = "OutOfSequence"   // An event lies outside of the range delimited by the Started and Ended events, or the transaction was started/ended twice.
+	AnomalyAfterCompletion AnomalyType = "AfterCompletion" // An event was received after the transaction was completed. The event is ignored.
+)
+
+// TransactionAnomaly is returned by EventTracker.Add and passed to the anomaly handler,
+// whenever an irregularity in the event sequence of a transaction is detected.
+type TransactionAnomaly struct {
+	ChargingStationID string
+	TransactionID     string
+	Type              AnomalyType
+	SeqNo             int   // The seqNo of the offending event. Not set for SequenceGap and MissingStarted anomalies.
+	MissingSeqNos     []int // Only set for SequenceGap anomalies.
+}
+
+func (a *TransactionAnomaly) Error() string {
+	switch a.Type {
+	case AnomalySequenceGap:
+		return fmt.Sprintf("transaction %v from %v: missing seqNo %v", a.TransactionID, a.ChargingStationID, a.MissingSeqNos)
+	case AnomalyMissingStarted:
+		return fmt.Sprintf("transaction %v from %v: missing Started event", a.TransactionID, a.ChargingStationID)
+	default:
+		return fmt.Sprintf("transaction %v from %v: %v event with seqNo %v", a.TransactionID, a.ChargingStationID, a.Type, a.SeqNo)
+	}
+}
+
+// TransactionState is a snapshot of the state of a transaction, as accumulated from all received events.
+// Events are applied in seqNo order, regardless of the order they were received in.
+type TransactionState struct {
+	ChargingStationID string
+	TransactionID     string
+	Started           bool
+	Ended             bool
+	StartedAt         *types.DateTime // The timestamp of the Started event, if received.
+	EndedAt           *types.DateTime // The timestamp of the Ended event, if received.
+	ChargingState     ChargingState   // The most recent charging state reported.
+	StoppedReason     Reason
+	IDToken           *types.IdToken     // The most recent idToken reported.
+	RemoteStartID     *int               // The remoteStartId reported, if the transaction was started remotely.
+	Evse              *types.EVSE        // The most recent EVSE reported.
+	MeterValues       []types.MeterValue // All meter values, in seqNo order.
+	Offline           bool               // True if at least one event was sent while the charging station was offline.
+	ReceivedEvents    int
+	LastSeqNo         int
+	MissingSeqNos     []int // SeqNos missing between the Started and the most recent event.
+	Completed         bool  // True once the transaction ended and no more events are expected.
+}
+
+// TransactionCompletionHandler is invoked by an EventTracker once a transaction ended.
+// If events went missing, the state contains the missing seqNos and the anomalies were reported beforehand.
+type TransactionCompletionHandler func(state TransactionState)
+
+// TransactionAnomalyHandler is invoked by an EventTracker for every detected anomaly.
+type TransactionAnomalyHandler func(anomaly *TransactionAnomaly)
+
+// TransactionListener is invoked by an EventTracker every time the state of a transaction changed,
+// i.e. after an event was applied or the transaction was completed.
+type TransactionListener func(state TransactionState)
+
+type transactionKey struct {
+	chargingStationID string
+	transactionID     string
+}
+
+type trackedTransaction struct {
+	events       map[int]*TransactionEventRequest
+	startedSeqNo int
+	endedSeqNo   int
+	completed    bool
+	gapTimer     clock.Timer
+	evictTimer   clock.Timer
+}
+
+// EventTracker keeps track of the TransactionEvent sequence of each transaction on the CSMS side,
+// in order to detect duplicated or missing events.
+//
+// Each received TransactionEventRequest should be passed to Add. Transactions are identified by charging station
+// and transactionId. Since a charging station delivers queued events after being offline,
+// events may arrive in any order (e.g. Ended before Started): missing events are therefore only reported
+// if they weren't received within the gap timeout, after the Ended event.
+//
+// Completed transactions are retained for the retention period, to detect late or duplicated events, and then evicted.
+// Transactions that never end must be removed explicitly via Remove.
+//
+// An EventTracker is safe for concurrent use.
+type EventTracker struct {
+	onComplete   TransactionCompletionHandler
+	onAnomaly    TransactionAnomalyHandler
+	retention    time.Duration
+	gapTimeout   time.Duration
+	transactions map[transactionKey]*trackedTransaction
+	listeners    []TransactionListener
+	clock        clock.Clock
+	mutex        sync.Mutex
+}
+
+// NewEventTracker creates a new tracker, invoking the passed handlers for every completed transaction and for every anomaly.
+// Both handlers are optional.
+func NewEventTracker(onComplete TransactionCompletionHandler, onAnomaly TransactionAnomalyHandler) *EventTracker {
+	return &EventTracker{
+		onComplete:   onComplete,
+		onAnomaly:    onAnomaly,
+		retention:    DefaultTransactionRetention,
+		gapTimeout:   DefaultSequenceGapTimeout,
+		transactions: map[transactionKey]*trackedTransaction{},
+		clock:        clock.New(),
+	}
+}
+
+// SetTimeouts configures how long completed transactions are retained,
+// and how long to wait for missing events after the Ended event was received.
+func (t *EventTracker) SetTimeouts(retention time.Duration, gapTimeout time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.retention = retention
+	t.gapTimeout = gapTimeout
+}
+
+// SetClock sets the clock used for the gap timeout and for evicting completed transactions.
+// Passing nil restores the real clock.
+//
+// Timers already running are not affected.
+func (t *EventTracker) SetClock(c clock.Clock) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.clock = clock.OrDefault(c)
+}
+
+// AddListener registers a listener, which is notified of every transaction state change.
+func (t *EventTracker) AddListener(listener TransactionListener) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.listeners = append(t.listeners, listener)
+}
+
+// Add feeds a TransactionEventRequest received from a charging station to the tracker.
+//
+// If the event is irregular, the anomaly is returned as well as passed to the anomaly handler.
+// Duplicated events and events received after completion are not applied to the transaction state.
+func (t *EventTracker) Add(chargingStationID string, request *TransactionEventRequest) error {
+	if request == nil {
+		return nil
+	}
+	key := transactionKey{chargingStationID: chargingStationID, transactionID: request.TransactionInfo.TransactionID}
+	seqNo := request.SequenceNo
+	t.mutex.Lock()
+	tx, ok := t.transactions[key]
+	if !ok {
+		tx = &trackedTransaction{events: map[int]*TransactionEventRequest{}, startedSeqNo: -1, endedSeqNo: -1}
+		t.transactions[key] = tx
+	}
+	var anomaly *TransactionAnomaly
+	switch {
+	case tx.events[seqNo] != nil:
+		anomaly = t.newAnomaly(key, AnomalyDuplicateSeqNo, seqNo)
+	case tx.completed:
+		anomaly = t.newAnomaly(key, AnomalyAfterCompletion, seqNo)
+	case request.EventType == TransactionEventStarted && tx.startedSeqNo >= 0,
+		request.EventType == TransactionEventEnded && tx.endedSeqNo >= 0:
+		anomaly = t.newAnomaly(key, AnomalyOutOfSequence, seqNo)
+	}
+	if anomaly != nil {
+		t.mutex.Unlock()
+		t.reportAnomaly(anomaly)
+		return anomaly
+	}
+	tx.events[seqNo] = request
+	switch request.EventType {
+	case TransactionEventStarted:
+		tx.startedSeqNo = seqNo
+	case TransactionEventEnded:
+		tx.endedSeqNo = seqNo
+	}
+	// Events are still applied if out of sequence, as the charging station may number events inconsistently
+	if tx.isOutOfRange(seqNo) || (request.EventType != TransactionEventUpdated && tx.hasEventsOutOfRange()) {
+		anomaly = t.newAnomaly(key, AnomalyOutOfSequence, seqNo)
+	}
+	completed := false
+	if tx.endedSeqNo >= 0 {
+		if tx.startedSeqNo >= 0 && len(tx.missingSeqNos()) == 0 {
+			t.complete(key, tx)
+			completed = true
+		} else if tx.gapTimer == nil {
+			tx.gapTimer = t.clock.AfterFunc(t.gapTimeout, func() {
+				t.onGapTimeout(key, tx)
+			})
+		}
+	}
+	state := tx.state(key)
+	listeners := t.listeners
+	t.mutex.Unlock()
+	if anomaly != nil {
+		t.reportAnomaly(anomaly)
+	}
+	t.notifyListeners(listeners, state)
+	if completed && t.onComplete != nil {
+		t.onComplete(state)
+	}
+	if anomaly != nil {
+		return anomaly
+	}
+	return nil
+}
+
+// TransactionState returns a snapshot of the current state of a transaction.
+// The second return value is false if the transaction is unknown or was already evicted.
+func (t *EventTracker) TransactionState(chargingStationID string, transactionID string) (TransactionState, bool) {
+	key := transactionKey{chargingStationID: chargingStationID, transactionID: transactionID}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	tx, ok := t.transactions[key]
+	if !ok {
+		return TransactionState{}, false
+	}
+	return tx.state(key), true
+}
+
+// Remove drops a transaction, without invoking any handler.
+func (t *EventTracker) Remove(chargingStationID string, transactionID string) {
+	key := transactionKey{chargingStationID: chargingStationID, transactionID: transactionID}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if tx, ok := t.transactions[key]; ok {
+		tx.stopTimers()
+		delete(t.transactions, key)
+	}
+}
+
+func (t *EventTracker) onGapTimeout(key transactionKey, tx *trackedTransaction) {
+	t.mutex.Lock()
+	if t.transactions[key] != tx || tx.completed {
+		t.mutex.Unlock()
+		return
+	}
+	var anomalies []*TransactionAnomaly
+	if tx.startedSeqNo < 0 {
+		anomalies = append(anomalies, &TransactionAnomaly{ChargingStationID: key.chargingStationID, TransactionID: key.transactionID, Type: AnomalyMissingStarted})
+	}
+	if missing := tx.missingSeqNos(); len(missing) > 0 {
+		anomalies = append(anomalies, &TransactionAnomaly{ChargingStationID: key.chargingStationID, TransactionID: key.transactionID, Type: AnomalySequenceGap, MissingSeqNos: missing})
+	}
+	t.complete(key, tx)
+	state := tx.state(key)
+	listeners := t.listeners
+	t.mutex.Unlock()
+	for _, anomaly := range anomalies {
+		t.reportAnomaly(anomaly)
+	}
+	t.notifyListeners(listeners, state)
+	if t.onComplete != nil {
+		t.onComplete(state)
+	}
+}
+
+// Must be invoked while holding the lock.
+func (t *EventTracker) complete(key transactionKey, tx *trackedTransaction) {
+	tx.completed = true
+	tx.stopTimers()
+	tx.evictTimer = t.clock.AfterFunc(t.retention, func() {
+		t.mutex.Lock()
+		defer t.mutex.Unlock()
+		if t.transactions[key] == tx {
+			delete(t.transactions, key)
+		}
+	})
+}
+
+func (t *EventTracker) newAnomaly(key transactionKey, anomalyType AnomalyType, seqNo int) *TransactionAnomaly {
+	return &TransactionAnomaly{ChargingStationID: key.chargingStationID, TransactionID: key.transactionID, Type: anomalyType, SeqNo: seqNo}
+}
+
+func (t *EventTracker) notifyListeners(listeners []TransactionListener, state TransactionState) {
+	for _, listener := range listeners {
+		listener(state)
+	}
+}
+
+func (t *EventTracker) reportAnomaly(anomaly *TransactionAnomaly) {
+	if t.onAnomaly != nil {
+		t.onAnomaly(anomaly)
+	}
+}
+
+func (tx *trackedTransaction) stopTimers() {
+	if tx.gapTimer != nil {
+		tx.gapTimer.Stop()
+		tx.gapTimer = nil
+	}
+	if tx.evictTimer != nil {
+		tx.evictTimer.Stop()
+		tx.evictTimer = nil
+	}
+}
+
+func (tx *trackedTransaction) isOutOfRange(seqNo int) bool {
+	return (tx.startedSeqNo >= 0 && seqNo < tx.startedSeqNo) || (tx.endedSeqNo >= 0 && seqNo > tx.endedSeqNo)
+}
+
+func (tx *trackedTransaction) hasEventsOutOfRange() bool {
+	for seqNo := range tx.events {
+		if tx.isOutOfRange(seqNo) {
+			return true
+		}
+	}
+	return false
+}
+
+func (tx *trackedTransaction) sortedSeqNos() []int {
+	seqNos := make([]int, 0, len(tx.events))
+	for seqNo := range tx.events {
+		seqNos = append(seqNos, seqNo)
+	}
+	sort.Ints(seqNos)
+	return seqNos
+}
+
+// Returns the seqNos missing between the Started event (or 0, if not received yet) and the Ended event
+// (or the highest received seqNo, if not received yet).
+func (tx *trackedTransaction) missingSeqNos() []int {
+	first := 0
+	if tx.startedSeqNo >= 0 {
+		first = tx.startedSeqNo
+	}
+	last := tx.endedSeqNo
+	if last < 0 {
+		for seqNo := range tx.events {
+			if seqNo > last {
+				last = seqNo
+			}
+		}
+	}
+	var missing []int
+	for seqNo := first; seqNo <= last; seqNo++ {
+		if tx.events[seqNo] == nil {
+			missing = append(missing, seqNo)
+		}
+	}
+	return missing
+}
+
+func (tx *trackedTransaction) state(key transactionKey) TransactionState {
+	state := TransactionState{
+		ChargingStationID: key.chargingStationID,
+		TransactionID:     key.transactionID,
+		Started:           tx.startedSeqNo >= 0,
+		Ended:             tx.endedSeqNo >= 0,
+		ReceivedEvents:    len(tx.events),
+		MissingSeqNos:     tx.missingSeqNos(),
+		Completed:         tx.completed,
+	}
+	for _, seqNo := range tx.sortedSeqNos() {
+		event := tx.events[seqNo]
+		state.LastSeqNo = seqNo
+		switch event.EventType {
+		case TransactionEventStarted:
+			state.StartedAt = event.Timestamp
+		case TransactionEventEnded:
+			state.EndedAt = event.Timestamp
+		}
+		if event.TransactionInfo.ChargingState != "" {
+			state.ChargingState = event.TransactionInfo.ChargingState
+		}
+		if event.TransactionInfo.StoppedReason != "" {
+			state.StoppedReason = event.TransactionInfo.StoppedReason
+		}
+		if event.IDToken != nil {
+			state.IDToken = event.IDToken
+		}
+		if event.TransactionInfo.RemoteStartID != nil {
+			state.RemoteStartID = event.TransactionInfo.RemoteStartID
+		}
+		if event.Evse != nil {
+			state.Evse = event.Evse
+		}
+		state.Offline = state.Offline || event.Offline
+		state.MeterValues = append(state.MeterValues, event.MeterValue...)
+	}
+	return state
+}
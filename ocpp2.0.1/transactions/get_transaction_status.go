@@ -1,6 +1,7 @@
 package transactions
 
 import (
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
 	"reflect"
 )
 
@@ -10,14 +11,16 @@ const GetTransactionStatusFeatureName = "GetTransactionStatus"
 
 // The field definition of the GetTransactionStatus request payload sent by the CSMS to the Charging Station.
 type GetTransactionStatusRequest struct {
-	TransactionID string `json:"transactionId,omitempty" validate:"omitempty,max=36"`
+	TransactionID string            `json:"transactionId,omitempty" validate:"omitempty,max=36"`
+	CustomData    *types.CustomData `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // This field definition of the GetTransactionStatus response payload, sent by the Charging Station to the CSMS in response to a GetTransactionStatusRequest.
 // In case the request was invalid, or couldn't be processed, an error will be sent instead.
 type GetTransactionStatusResponse struct {
-	OngoingIndicator *bool `json:"ongoingIndicator,omitempty" validate:"omitempty"`
-	MessagesInQueue  bool  `json:"messagesInQueue"`
+	OngoingIndicator *bool             `json:"ongoingIndicator,omitempty" validate:"omitempty"`
+	MessagesInQueue  bool              `json:"messagesInQueue"`
+	CustomData       *types.CustomData `json:"customData,omitempty" validate:"omitempty"`
 }
 
 // In some scenarios a CSMS needs to know whether there are still messages for a transaction that need to be delivered.
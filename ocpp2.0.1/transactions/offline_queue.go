@@ -0,0 +1,312 @@
+package transactions
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+)
+
+// The default amount of attempts for delivering a queued event, which is rejected by the CSMS with an error response.
+// Corresponds to the default value of the OCPPCommCtrlr.MessageAttemptsTransactionEvent variable.
+const DefaultTransactionMessageAttempts = 3
+
+// ErrTransactionEventQueued is returned by OfflineQueue.SendTransactionEvent, if the event couldn't be sent right away
+// and was queued instead. The event will be delivered once the charging station is back online.
+var ErrTransactionEventQueued = errors.New("transaction event queued for later delivery")
+
+// EventQueueStore persists the TransactionEventRequest messages queued by an OfflineQueue.
+// Implementations must preserve insertion order. A store is only accessed by one OfflineQueue at a time.
+//
+// To retain queued events across reboots, provide a store backed by persistent storage.
+type EventQueueStore interface {
+	// Push appends a request to the end of the queue.
+	Push(request *TransactionEventRequest) error
+	// Peek returns the oldest request in the queue, or nil if the queue is empty.
+	Peek() (*TransactionEventRequest, error)
+	// Pop removes the oldest request from the queue.
+	Pop() error
+	// Entries returns all queued requests, oldest first.
+	Entries() ([]*TransactionEventRequest, error)
+}
+
+type memoryEventQueueStore struct {
+	queue []*TransactionEventRequest
+}
+
+// NewMemoryEventQueueStore creates a non-persistent EventQueueStore, keeping all queued events in memory.
+func NewMemoryEventQueueStore() EventQueueStore {
+	return &memoryEventQueueStore{}
+}
+
+func (s *memoryEventQueueStore) Push(request *TransactionEventRequest) error {
+	s.queue = append(s.queue, request)
+	return nil
+}
+
+func (s *memoryEventQueueStore) Peek() (*TransactionEventRequest, error) {
+	if len(s.queue) == 0 {
+		return nil, nil
+	}
+	return s.queue[0], nil
+}
+
+func (s *memoryEventQueueStore) Pop() error {
+	if len(s.queue) > 0 {
+		s.queue[0] = nil
+		s.queue = s.queue[1:]
+	}
+	return nil
+}
+
+func (s *memoryEventQueueStore) Entries() ([]*TransactionEventRequest, error) {
+	entries := make([]*TransactionEventRequest, len(s.queue))
+	copy(entries, s.queue)
+	return entries, nil
+}
+
+// OfflineQueue implements the queuing rules for TransactionEvent messages on the charging station side.
+//
+// All TransactionEventRequest messages should be sent via SendTransactionEvent. While the charging station is offline,
+// events are queued, with the offline field set. Once back online, queued events are delivered in FIFO order,
+// one at a time, before any newer event. Events created while a flush is in progress are queued as well,
+// in order to preserve the seqNo ordering.
+//
+// The queue doesn't detect connectivity by itself, apart from failed sends. The application should invoke
+// OnDisconnected and OnReconnected whenever the connection to the CSMS is lost or restored.
+//
+// The queue also keeps track of ongoing transactions, based on the Started and Ended events passing through it,
+// and implements the ChargingStationHandler interface accordingly. It may therefore be registered directly:
+//
+//	chargingStation.SetTransactionsHandler(queue)
+//
+// An OfflineQueue is safe for concurrent use.
+type OfflineQueue struct {
+	// Sends a request to the CSMS. Typically invokes SendRequest on the charging station.
+	// Errors of type *ocpp.Error are treated as a response by the CSMS, any other error as a connectivity issue.
+	Send func(request *TransactionEventRequest) (*TransactionEventResponse, error)
+	// Invoked for every response to a queued event, once delivered. Optional.
+	OnQueuedEventResponse func(request *TransactionEventRequest, response *TransactionEventResponse)
+	// Invoked if a queued event was discarded, after being rejected by the CSMS MaxAttempts times. Optional.
+	OnQueuedEventDiscarded func(request *TransactionEventRequest, err error)
+	// The maximum amount of delivery attempts for a queued event, rejected by the CSMS with an error response.
+	MaxAttempts int
+	store       EventQueueStore
+	online      bool
+	flushing    bool
+	active      map[string]bool
+	drained     []chan struct{}
+	mutex       sync.Mutex
+}
+
+// NewOfflineQueue creates a new queue, backed by the passed store. If store is nil, events are kept in memory.
+// The queue initially considers the charging station online.
+func NewOfflineQueue(store EventQueueStore, send func(request *TransactionEventRequest) (*TransactionEventResponse, error)) *OfflineQueue {
+	if store == nil {
+		store = NewMemoryEventQueueStore()
+	}
+	return &OfflineQueue{
+		Send:        send,
+		MaxAttempts: DefaultTransactionMessageAttempts,
+		store:       store,
+		online:      true,
+		active:      map[string]bool{},
+	}
+}
+
+// SendTransactionEvent sends a TransactionEventRequest to the CSMS, or queues it if the charging station is offline,
+// or older events are still queued. In the latter case, ErrTransactionEventQueued is returned.
+//
+// If sending fails because of a connectivity issue, the charging station is considered offline
+// and the event is queued.
+func (q *OfflineQueue) SendTransactionEvent(request *TransactionEventRequest) (*TransactionEventResponse, error) {
+	q.mutex.Lock()
+	q.track(request)
+	if !q.online || q.flushing {
+		err := q.enqueue(request)
+		q.mutex.Unlock()
+		return nil, err
+	}
+	entries, err := q.store.Entries()
+	if err != nil {
+		q.mutex.Unlock()
+		return nil, err
+	}
+	if len(entries) > 0 {
+		// Older events are still waiting for a flush
+		err = q.enqueue(request)
+		q.mutex.Unlock()
+		return nil, err
+	}
+	q.mutex.Unlock()
+	response, err := q.Send(request)
+	if err != nil && !isResponseError(err) {
+		q.mutex.Lock()
+		q.online = false
+		err = q.enqueue(request)
+		q.mutex.Unlock()
+		return nil, err
+	}
+	return response, err
+}
+
+// OnDisconnected marks the charging station as offline. All following events are queued.
+func (q *OfflineQueue) OnDisconnected() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.online = false
+}
+
+// OnReconnected marks the charging station as online and starts delivering queued events asynchronously.
+func (q *OfflineQueue) OnReconnected() {
+	q.mutex.Lock()
+	q.online = true
+	if q.flushing {
+		q.mutex.Unlock()
+		return
+	}
+	q.flushing = true
+	q.mutex.Unlock()
+	go q.flush()
+}
+
+// Flush blocks until all queued events were delivered, or ctx is canceled.
+// If the charging station is online, a pending delivery is started right away. While offline, Flush waits for OnReconnected.
+//
+// This is useful for delivering pending events, e.g. a TransactionEvent(Ended), before the charging station resets.
+func (q *OfflineQueue) Flush(ctx context.Context) error {
+	q.mutex.Lock()
+	entries, err := q.store.Entries()
+	if err != nil || len(entries) == 0 {
+		q.mutex.Unlock()
+		return err
+	}
+	drained := make(chan struct{})
+	q.drained = append(q.drained, drained)
+	startFlush := q.online && !q.flushing
+	if startFlush {
+		q.flushing = true
+	}
+	q.mutex.Unlock()
+	if startFlush {
+		go q.flush()
+	}
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// QueueLength returns the amount of currently queued events.
+func (q *OfflineQueue) QueueLength() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	entries, _ := q.store.Entries()
+	return len(entries)
+}
+
+// IsTransactionOngoing returns true if a Started event, but no Ended event, was sent for the transaction.
+func (q *OfflineQueue) IsTransactionOngoing(transactionID string) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.active[transactionID]
+}
+
+// OnGetTransactionStatus reports whether events for the requested transaction (or for any transaction,
+// if no transactionId was passed) are still queued, and whether the requested transaction is ongoing.
+func (q *OfflineQueue) OnGetTransactionStatus(request *GetTransactionStatusRequest) (*GetTransactionStatusResponse, error) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	entries, err := q.store.Entries()
+	if err != nil {
+		return nil, err
+	}
+	messagesInQueue := false
+	for _, entry := range entries {
+		if request.TransactionID == "" || entry.TransactionInfo.TransactionID == request.TransactionID {
+			messagesInQueue = true
+			break
+		}
+	}
+	response := NewGetTransactionStatusResponse(messagesInQueue)
+	if request.TransactionID != "" {
+		ongoing := q.active[request.TransactionID]
+		response.OngoingIndicator = &ongoing
+	}
+	return response, nil
+}
+
+// Delivers queued events one at a time, until the queue is empty or the charging station goes offline.
+func (q *OfflineQueue) flush() {
+	attempts := 0
+	for {
+		q.mutex.Lock()
+		request, err := q.store.Peek()
+		if !q.online || request == nil || err != nil {
+			q.flushing = false
+			if request == nil && err == nil {
+				for _, drained := range q.drained {
+					close(drained)
+				}
+				q.drained = nil
+			}
+			q.mutex.Unlock()
+			return
+		}
+		q.mutex.Unlock()
+		response, err := q.Send(request)
+		if err != nil && !isResponseError(err) {
+			q.mutex.Lock()
+			q.online = false
+			q.flushing = false
+			q.mutex.Unlock()
+			return
+		}
+		if err != nil {
+			attempts++
+			if attempts < q.MaxAttempts {
+				continue
+			}
+		}
+		q.mutex.Lock()
+		_ = q.store.Pop()
+		q.mutex.Unlock()
+		attempts = 0
+		if err != nil {
+			if q.OnQueuedEventDiscarded != nil {
+				q.OnQueuedEventDiscarded(request, err)
+			}
+		} else if q.OnQueuedEventResponse != nil {
+			q.OnQueuedEventResponse(request, response)
+		}
+	}
+}
+
+// Must be invoked while holding the lock.
+func (q *OfflineQueue) enqueue(request *TransactionEventRequest) error {
+	if !q.online {
+		request.Offline = true
+	}
+	if err := q.store.Push(request); err != nil {
+		return err
+	}
+	return ErrTransactionEventQueued
+}
+
+// Must be invoked while holding the lock.
+func (q *OfflineQueue) track(request *TransactionEventRequest) {
+	switch request.EventType {
+	case TransactionEventStarted, TransactionEventUpdated:
+		q.active[request.TransactionInfo.TransactionID] = true
+	case TransactionEventEnded:
+		delete(q.active, request.TransactionInfo.TransactionID)
+	}
+}
+
+func isResponseError(err error) bool {
+	_, ok := err.(*ocpp.Error)
+	return ok
+}
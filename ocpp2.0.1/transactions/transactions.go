@@ -1,7 +1,11 @@
 // The transactions functional block contains OCPP 2.0 features related to OCPP transactions.
 package transactions
 
-import "github.com/lorenzodonini/ocpp-go/ocpp"
+import (
+	"context"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+)
 
 // Needs to be implemented by a CSMS for handling messages part of the OCPP 2.0 Transactions profile.
 type CSMSHandler interface {
@@ -9,6 +13,29 @@ type CSMSHandler interface {
 	OnTransactionEvent(chargingStationID string, request *TransactionEventRequest) (response *TransactionEventResponse, err error)
 }
 
+// Context-aware variant of CSMSHandler, for handling messages part of the OCPP 2.0 Transactions profile.
+type CSMSHandlerWithContext interface {
+	// OnTransactionEvent is called on the CSMS whenever a TransactionEventRequest is received from a charging station.
+	OnTransactionEvent(ctx context.Context, chargingStationID string, request *TransactionEventRequest) (response *TransactionEventResponse, err error)
+}
+
+// AdaptCSMSHandler converts a CSMSHandler into a CSMSHandlerWithContext, which ignores the context.
+// Returns nil if handler is nil.
+func AdaptCSMSHandler(handler CSMSHandler) CSMSHandlerWithContext {
+	if handler == nil {
+		return nil
+	}
+	return csmsHandlerAdapter{handler: handler}
+}
+
+type csmsHandlerAdapter struct {
+	handler CSMSHandler
+}
+
+func (a csmsHandlerAdapter) OnTransactionEvent(ctx context.Context, chargingStationID string, request *TransactionEventRequest) (*TransactionEventResponse, error) {
+	return a.handler.OnTransactionEvent(chargingStationID, request)
+}
+
 // Needs to be implemented by Charging stations for handling messages part of the OCPP 2.0 Transactions profile.
 type ChargingStationHandler interface {
 	// OnGetTransactionStatusResponse is called on a charging station whenever a OnGetTransactionStatusRequest is received from the CSMS.
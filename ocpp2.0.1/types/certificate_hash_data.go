@@ -0,0 +1,171 @@
+package types
+
+import (
+	"crypto"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/go-playground/validator.v9"
+)
+
+// The maximum length of a hex encoded certificate serial number (20 bytes, as per RFC 5280).
+const maxSerialNumberLength = 40
+
+// HexLength returns the length of a hex encoded hash computed with the algorithm, or 0 if the algorithm is unknown.
+func (alg HashAlgorithmType) HexLength() int {
+	switch alg {
+	case SHA256:
+		return 64
+	case SHA384:
+		return 96
+	case SHA512:
+		return 128
+	default:
+		return 0
+	}
+}
+
+func (alg HashAlgorithmType) hash() (crypto.Hash, error) {
+	switch alg {
+	case SHA256:
+		return crypto.SHA256, nil
+	case SHA384:
+		return crypto.SHA384, nil
+	case SHA512:
+		return crypto.SHA512, nil
+	default:
+		return 0, fmt.Errorf("unsupported hash algorithm %v", alg)
+	}
+}
+
+// NewCertificateHashDataFromCert computes the hash data identifying a certificate, as used in GetInstalledCertificateIds,
+// DeleteCertificate and similar messages.
+//
+// The issuerNameHash is computed over the DER encoded issuer distinguished name, and the issuerKeyHash over the public key
+// of the issuer (excluding algorithm and parameters), as for OCSP (RFC 6960). Both are hex encoded.
+// The serialNumber is hex encoded, without leading zeroes.
+//
+// If issuer is nil, the certificate must be self-signed.
+func NewCertificateHashDataFromCert(cert *x509.Certificate, issuer *x509.Certificate, alg HashAlgorithmType) (*CertificateHashData, error) {
+	if cert == nil {
+		return nil, errors.New("no certificate passed")
+	}
+	if issuer == nil {
+		if err := cert.CheckSignatureFrom(cert); err != nil {
+			return nil, fmt.Errorf("issuer required for certificate %v: %w", cert.Subject, err)
+		}
+		issuer = cert
+	}
+	hash, err := alg.hash()
+	if err != nil {
+		return nil, err
+	}
+	var publicKeyInfo struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err = asn1.Unmarshal(issuer.RawSubjectPublicKeyInfo, &publicKeyInfo); err != nil {
+		return nil, err
+	}
+	serialNumber := cert.SerialNumber.Text(16)
+	if len(serialNumber) > maxSerialNumberLength {
+		return nil, fmt.Errorf("serial number of %v exceeds 20 bytes", cert.Subject)
+	}
+	return &CertificateHashData{
+		HashAlgorithm:  alg,
+		IssuerNameHash: hexDigest(hash, cert.RawIssuer),
+		IssuerKeyHash:  hexDigest(hash, publicKeyInfo.PublicKey.Bytes),
+		SerialNumber:   serialNumber,
+	}, nil
+}
+
+// Equal returns true if both hash data identify the same certificate.
+// Hashes are compared case-insensitively, serial numbers additionally ignoring leading zeroes.
+//
+// Hash data computed with different algorithms are never equal, even if they identify the same certificate.
+func (h CertificateHashData) Equal(other CertificateHashData) bool {
+	return h.HashAlgorithm == other.HashAlgorithm &&
+		strings.EqualFold(h.IssuerNameHash, other.IssuerNameHash) &&
+		strings.EqualFold(h.IssuerKeyHash, other.IssuerKeyHash) &&
+		strings.EqualFold(normalizeSerialNumber(h.SerialNumber), normalizeSerialNumber(other.SerialNumber))
+}
+
+// IndexCertificateHashData returns the index of the first element of list equal to hashData, or -1 if not found.
+func IndexCertificateHashData(list []CertificateHashData, hashData CertificateHashData) int {
+	for i, h := range list {
+		if h.Equal(hashData) {
+			return i
+		}
+	}
+	return -1
+}
+
+// FindCertificateHashDataChain returns the chain whose certificate, or one of whose child certificates, matches hashData.
+func FindCertificateHashDataChain(chains []CertificateHashDataChain, hashData CertificateHashData) (*CertificateHashDataChain, bool) {
+	for i, chain := range chains {
+		if chain.CertificateHashData.Equal(hashData) || IndexCertificateHashData(chain.ChildCertificateHashData, hashData) >= 0 {
+			return &chains[i], true
+		}
+	}
+	return nil, false
+}
+
+func normalizeSerialNumber(serialNumber string) string {
+	normalized := strings.TrimLeft(serialNumber, "0")
+	if normalized == "" && serialNumber != "" {
+		return "0"
+	}
+	return normalized
+}
+
+func hexDigest(hash crypto.Hash, data []byte) string {
+	h := hash.New()
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func isHex(value string) bool {
+	for _, c := range value {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// Validates the hashes against the length mandated by the algorithm. Missing fields and unknown algorithms are reported by the field validation.
+func validateHashData(sl validator.StructLevel, alg HashAlgorithmType, issuerNameHash string, issuerKeyHash string, serialNumber string) {
+	if length := alg.HexLength(); length > 0 {
+		for _, field := range []struct{ name, value string }{{"IssuerNameHash", issuerNameHash}, {"IssuerKeyHash", issuerKeyHash}} {
+			if field.value == "" {
+				continue
+			}
+			if !isHex(field.value) {
+				sl.ReportError(field.value, field.name, field.name, "hexadecimal", "")
+			} else if len(field.value) != length {
+				sl.ReportError(field.value, field.name, field.name, "len", strconv.Itoa(length))
+			}
+		}
+	}
+	if serialNumber != "" && !isHex(serialNumber) {
+		sl.ReportError(serialNumber, "SerialNumber", "SerialNumber", "hexadecimal", "")
+	}
+}
+
+func isValidCertificateHashData(sl validator.StructLevel) {
+	hashData := sl.Current().Interface().(CertificateHashData)
+	validateHashData(sl, hashData.HashAlgorithm, hashData.IssuerNameHash, hashData.IssuerKeyHash, hashData.SerialNumber)
+}
+
+func isValidOCSPRequestData(sl validator.StructLevel) {
+	data := sl.Current().Interface().(OCSPRequestDataType)
+	validateHashData(sl, data.HashAlgorithm, data.IssuerNameHash, data.IssuerKeyHash, data.SerialNumber)
+}
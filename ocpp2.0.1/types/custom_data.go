@@ -0,0 +1,50 @@
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// CustomData allows extending any OCPP 2.0.1 message or datatype with vendor-specific properties.
+//
+// Besides the mandatory vendorId, a customData object may contain arbitrary properties.
+// These are stored in AdditionalProperties and are preserved across unmarshaling and marshaling.
+type CustomData struct {
+	VendorID             string                 `json:"vendorId" validate:"required,max=255"`
+	AdditionalProperties map[string]interface{} `json:"-"`
+}
+
+// NewCustomData creates a new CustomData struct for the given vendor. Additional properties may be set afterwards.
+func NewCustomData(vendorID string) *CustomData {
+	return &CustomData{VendorID: vendorID}
+}
+
+func (c CustomData) MarshalJSON() ([]byte, error) {
+	obj := make(map[string]interface{}, len(c.AdditionalProperties)+1)
+	for k, v := range c.AdditionalProperties {
+		obj[k] = v
+	}
+	obj["vendorId"] = c.VendorID
+	return json.Marshal(obj)
+}
+
+func (c *CustomData) UnmarshalJSON(input []byte) error {
+	var obj map[string]interface{}
+	if err := json.Unmarshal(input, &obj); err != nil {
+		return err
+	}
+	c.VendorID = ""
+	c.AdditionalProperties = nil
+	if vendorID, ok := obj["vendorId"]; ok {
+		s, ok := vendorID.(string)
+		if !ok {
+			return fmt.Errorf("invalid vendorId %v, expected string", vendorID)
+		}
+		c.VendorID = s
+		delete(obj, "vendorId")
+	}
+	if len(obj) > 0 {
+		c.AdditionalProperties = obj
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// MeterUnit is a unit accepted by the MeterValueBuilder.
+// The kW and kWh convenience units are converted to W and Wh with a multiplier of 3.
+type MeterUnit string
+
+const (
+	Wh      MeterUnit = "Wh"
+	KWh     MeterUnit = "kWh"
+	W       MeterUnit = "W"
+	KW      MeterUnit = "kW"
+	A       MeterUnit = "A"
+	V       MeterUnit = "V"
+	Percent MeterUnit = "Percent"
+	Celsius MeterUnit = "Celsius"
+)
+
+// Returns the unit of measure for a MeterUnit, converting convenience units to their base unit and multiplier.
+func (u MeterUnit) unitOfMeasure() *UnitOfMeasure {
+	switch u {
+	case KWh:
+		return &UnitOfMeasure{Unit: string(Wh), Multiplier: intPointer(3)}
+	case KW:
+		return &UnitOfMeasure{Unit: string(W), Multiplier: intPointer(3)}
+	default:
+		return &UnitOfMeasure{Unit: string(u)}
+	}
+}
+
+func intPointer(i int) *int {
+	return &i
+}
+
+// MeterValueBuilder builds a MeterValue, as contained in TransactionEventRequest and MeterValuesRequest messages.
+//
+//	meterValue, err := types.NewMeterValue(time.Now()).
+//		Energy(15.23, types.KWh).
+//		PowerPhase(7.4, types.KW, types.PhaseL1).
+//		SOC(80).
+//		TransactionEnd().
+//		Build()
+//
+// Location, Multiplier and Signed apply to the most recently added sampled value,
+// while the context applies to all sampled values. Errors are reported by Build.
+type MeterValueBuilder struct {
+	timestamp     time.Time
+	context       ReadingContext
+	sampledValues []SampledValue
+	err           error
+}
+
+// NewMeterValue creates a builder for a meter value sampled at the given time.
+func NewMeterValue(timestamp time.Time) *MeterValueBuilder {
+	return &MeterValueBuilder{timestamp: timestamp}
+}
+
+// Measure adds a sampled value for an arbitrary measurand. The phase may be empty, for overall values.
+func (b *MeterValueBuilder) Measure(measurand Measurand, value float64, unit MeterUnit, phase Phase) *MeterValueBuilder {
+	b.sampledValues = append(b.sampledValues, SampledValue{
+		Value:         value,
+		Measurand:     measurand,
+		Phase:         phase,
+		UnitOfMeasure: unit.unitOfMeasure(),
+	})
+	return b
+}
+
+// Energy adds the imported active energy register value.
+func (b *MeterValueBuilder) Energy(value float64, unit MeterUnit) *MeterValueBuilder {
+	return b.Measure(MeasurandEnergyActiveImportRegister, value, unit, "")
+}
+
+// Power adds the overall imported active power.
+func (b *MeterValueBuilder) Power(value float64, unit MeterUnit) *MeterValueBuilder {
+	return b.Measure(MeasurandPowerActiveImport, value, unit, "")
+}
+
+// PowerPhase adds the imported active power of a single phase.
+func (b *MeterValueBuilder) PowerPhase(value float64, unit MeterUnit, phase Phase) *MeterValueBuilder {
+	return b.Measure(MeasurandPowerActiveImport, value, unit, phase)
+}
+
+// Current adds the imported current in Ampere. The phase may be empty, for overall values.
+func (b *MeterValueBuilder) Current(value float64, phase Phase) *MeterValueBuilder {
+	return b.Measure(MeasurandCurrentImport, value, A, phase)
+}
+
+// Voltage adds the voltage in Volt. The phase may be empty, for overall values.
+func (b *MeterValueBuilder) Voltage(value float64, phase Phase) *MeterValueBuilder {
+	return b.Measure(MeasurandVoltage, value, V, phase)
+}
+
+// SOC adds the state of charge of the EV, in percent.
+func (b *MeterValueBuilder) SOC(percent float64) *MeterValueBuilder {
+	b.Measure(MeasueandSoC, percent, Percent, "")
+	return b.Location(LocationEV)
+}
+
+// Location sets where the most recently added value was sampled.
+func (b *MeterValueBuilder) Location(location Location) *MeterValueBuilder {
+	if value := b.last("location"); value != nil {
+		value.Location = location
+	}
+	return b
+}
+
+// Multiplier overrides the multiplier of the most recently added value.
+func (b *MeterValueBuilder) Multiplier(multiplier int) *MeterValueBuilder {
+	if value := b.last("multiplier"); value != nil {
+		value.UnitOfMeasure.Multiplier = intPointer(multiplier)
+	}
+	return b
+}
+
+// Signed attaches signed meter data to the most recently added value.
+func (b *MeterValueBuilder) Signed(signedMeterValue SignedMeterValue) *MeterValueBuilder {
+	if value := b.last("signed meter value"); value != nil {
+		value.SignedMeterValue = &signedMeterValue
+	}
+	return b
+}
+
+// Context sets the reading context of all sampled values.
+func (b *MeterValueBuilder) Context(context ReadingContext) *MeterValueBuilder {
+	b.context = context
+	return b
+}
+
+// TransactionBegin marks all sampled values as taken at the start of a transaction.
+func (b *MeterValueBuilder) TransactionBegin() *MeterValueBuilder {
+	return b.Context(ReadingContextTransactionBegin)
+}
+
+// TransactionEnd marks all sampled values as taken at the end of a transaction.
+func (b *MeterValueBuilder) TransactionEnd() *MeterValueBuilder {
+	return b.Context(ReadingContextTransactionEnd)
+}
+
+// SamplePeriodic marks all sampled values as periodic samples.
+func (b *MeterValueBuilder) SamplePeriodic() *MeterValueBuilder {
+	return b.Context(ReadingContextSamplePeriodic)
+}
+
+// Build returns the meter value, or the first error that occurred while building it.
+// The resulting meter value is validated.
+func (b *MeterValueBuilder) Build() (MeterValue, error) {
+	if b.err != nil {
+		return MeterValue{}, b.err
+	}
+	if len(b.sampledValues) == 0 {
+		return MeterValue{}, errors.New("meter value requires at least one sampled value")
+	}
+	sampledValues := make([]SampledValue, len(b.sampledValues))
+	for i, value := range b.sampledValues {
+		if value.Measurand == MeasueandSoC && value.Phase != "" {
+			return MeterValue{}, fmt.Errorf("sampled value %d: %v can't refer to phase %v", i, value.Measurand, value.Phase)
+		}
+		if value.UnitOfMeasure != nil && value.UnitOfMeasure.Multiplier != nil && *value.UnitOfMeasure.Multiplier < 0 {
+			return MeterValue{}, fmt.Errorf("sampled value %d: negative multiplier %d", i, *value.UnitOfMeasure.Multiplier)
+		}
+		value.Context = b.context
+		sampledValues[i] = value
+	}
+	meterValue := MeterValue{Timestamp: *NewDateTime(b.timestamp), SampledValue: sampledValues}
+	if err := Validate.Struct(meterValue); err != nil {
+		return MeterValue{}, err
+	}
+	return meterValue, nil
+}
+
+// Returns the most recently added value. If no value was added yet, an error is recorded.
+func (b *MeterValueBuilder) last(setting string) *SampledValue {
+	if len(b.sampledValues) == 0 {
+		if b.err == nil {
+			b.err = fmt.Errorf("%v set before adding a sampled value", setting)
+		}
+		return nil
+	}
+	return &b.sampledValues[len(b.sampledValues)-1]
+}
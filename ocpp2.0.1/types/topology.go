@@ -0,0 +1,338 @@
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"gopkg.in/go-playground/validator.v9"
+)
+
+// Allowed ConnectorType, as supported by most charging station vendors.
+// The OCPP protocol directly supports the most widely known connector types. For not mentioned types,
+// refer to the Other1PhMax16A, Other1PhOver16A and Other3Ph fallbacks.
+type ConnectorType string
+
+const (
+	ConnectorTypeCCS1              ConnectorType = "cCCS1"           // Combined Charging System 1 (captive cabled) a.k.a. Combo 1
+	ConnectorTypeCCS2              ConnectorType = "cCCS2"           // Combined Charging System 2 (captive cabled) a.k.a. Combo 2
+	ConnectorTypeG105              ConnectorType = "cG105"           // JARI G105-1993 (captive cabled) a.k.a. CHAdeMO
+	ConnectorTypeTesla             ConnectorType = "cTesla"          // Tesla Connector
+	ConnectorTypeCType1            ConnectorType = "cType1"          // IEC62196-2 Type 1 connector (captive cabled) a.k.a. J1772
+	ConnectorTypeCType2            ConnectorType = "cType2"          // IEC62196-2 Type 2 connector (captive cabled) a.k.a. Mennekes connector
+	ConnectorType3091P16A          ConnectorType = "s309-1P-16A"     // 16A 1 phase IEC60309 socket
+	ConnectorType3091P32A          ConnectorType = "s309-1P-32A"     // 32A 1 phase IEC60309 socket
+	ConnectorType3093P16A          ConnectorType = "s309-3P-16A"     // 16A 3 phase IEC60309 socket
+	ConnectorType3093P32A          ConnectorType = "s309-3P-32A"     // 32A 3 phase IEC60309 socket
+	ConnectorTypeBS1361            ConnectorType = "sBS1361"         // UK domestic socket a.k.a. 13Amp
+	ConnectorTypeCEE77             ConnectorType = "sCEE-7-7"        // CEE 7/7 16A socket. May represent 7/4 & 7/5 a.k.a Schuko
+	ConnectorTypeSType2            ConnectorType = "sType2"          // EC62196-2 Type 2 socket a.k.a. Mennekes connector
+	ConnectorTypeSType3            ConnectorType = "sType3"          // IEC62196-2 Type 2 socket a.k.a. Scame
+	ConnectorTypeOther1PhMax16A    ConnectorType = "Other1PhMax16A"  // Other single phase (domestic) sockets not mentioned above, rated at no more than 16A. CEE7/17, AS3112, NEMA 5-15, NEMA 5-20, JISC8303, TIS166, SI 32, CPCS-CCC, SEV1011, etc.
+	ConnectorTypeOther1PhOver16A   ConnectorType = "Other1PhOver16A" // Other single phase sockets not mentioned above (over 16A)
+	ConnectorTypeOther3Ph          ConnectorType = "Other3Ph"        // Other 3 phase sockets not mentioned above. NEMA14-30, NEMA14-50.
+	ConnectorTypePan               ConnectorType = "Pan"             // Pantograph connector
+	ConnectorTypeWirelessInductive ConnectorType = "wInductive"      // Wireless inductively coupled connection
+	ConnectorTypeWirelessResonant  ConnectorType = "wResonant"       // Wireless resonant coupled connection
+	ConnectorTypeUndetermined      ConnectorType = "Undetermined"    // Yet to be determined (e.g. before plugged in)
+	ConnectorTypeUnknown           ConnectorType = "Unknown"         // Unknown; not determinable
+)
+
+func isValidConnectorType(fl validator.FieldLevel) bool {
+	status := ConnectorType(fl.Field().String())
+	switch status {
+	case ConnectorTypeCCS1, ConnectorTypeCCS2, ConnectorTypeG105, ConnectorTypeTesla, ConnectorTypeCType1,
+		ConnectorTypeCType2, ConnectorType3091P16A, ConnectorType3091P32A, ConnectorType3093P16A, ConnectorType3093P32A,
+		ConnectorTypeBS1361, ConnectorTypeCEE77, ConnectorTypeSType2, ConnectorTypeSType3, ConnectorTypeOther1PhMax16A,
+		ConnectorTypeOther1PhOver16A, ConnectorTypeOther3Ph, ConnectorTypePan, ConnectorTypeWirelessInductive,
+		ConnectorTypeWirelessResonant, ConnectorTypeUndetermined, ConnectorTypeUnknown:
+		return true
+	default:
+		return false
+	}
+}
+
+// PhaseRotation describes how the phases of a connector are wired to the grid, as reported via the PhaseRotation variable.
+// R, S and T denote the phases L1, L2 and L3 of the grid connection.
+type PhaseRotation string
+
+const (
+	PhaseRotationNotApplicable PhaseRotation = "NotApplicable"
+	PhaseRotationUnknown       PhaseRotation = "Unknown"
+	PhaseRotationRST           PhaseRotation = "RST" // Standard Reference Phasing
+	PhaseRotationRTS           PhaseRotation = "RTS" // Reversed Reference Phasing
+	PhaseRotationSRT           PhaseRotation = "SRT" // Reversed 240 degree rotation
+	PhaseRotationSTR           PhaseRotation = "STR" // Standard 120 degree rotation
+	PhaseRotationTRS           PhaseRotation = "TRS" // Standard 240 degree rotation
+	PhaseRotationTSR           PhaseRotation = "TSR" // Reversed 120 degree rotation
+)
+
+func isValidPhaseRotation(fl validator.FieldLevel) bool {
+	rotation := PhaseRotation(fl.Field().String())
+	switch rotation {
+	case PhaseRotationNotApplicable, PhaseRotationUnknown, PhaseRotationRST, PhaseRotationRTS,
+		PhaseRotationSRT, PhaseRotationSTR, PhaseRotationTRS, PhaseRotationTSR:
+		return true
+	default:
+		return false
+	}
+}
+
+// The nominal voltage between phase and neutral, used by a StationTopology if none is set.
+const DefaultNominalVoltage = 230.0
+
+// ErrInvalidStationTopology is wrapped by all errors returned when validating a StationTopology.
+var ErrInvalidStationTopology = errors.New("invalid station topology")
+
+// TopologyConnector describes a connector of an EVSE.
+type TopologyConnector struct {
+	ID            int           `json:"id" validate:"gt=0"` // Connector ids start at 1 on every EVSE.
+	Type          ConnectorType `json:"type" validate:"required,connectorType"`
+	Phases        int           `json:"phases" validate:"min=1,max=3"`
+	PhaseRotation PhaseRotation `json:"phaseRotation,omitempty" validate:"omitempty,phaseRotation"`
+	MaxCurrent    float64       `json:"maxCurrent,omitempty" validate:"gte=0"` // The current limit per phase of the connector in A. Zero means unlimited.
+}
+
+// TopologyEVSE describes an EVSE and its connectors.
+type TopologyEVSE struct {
+	ID         int                 `json:"id" validate:"gt=0"`
+	Connectors []TopologyConnector `json:"connectors" validate:"required,min=1,dive"`
+	MaxCurrent float64             `json:"maxCurrent,omitempty" validate:"gte=0"` // The current limit per phase of the EVSE in A. Zero means unlimited.
+	MaxPower   float64             `json:"maxPower,omitempty" validate:"gte=0"`   // The power limit of the EVSE in W. Zero means unlimited.
+}
+
+// StationTopology describes the physical layout of a charging station: its EVSEs, their connectors and their electrical limits.
+// It is meant to be defined once, and shared by all helpers which need to know the layout of the station,
+// e.g. via the SetTopology method of the reservation, reset and remote trigger managers.
+//
+// A topology is created via NewStationTopology or LoadStationTopology, which validate it and sort EVSEs and connectors by id.
+// It must not be modified afterwards.
+type StationTopology struct {
+	EVSEs []TopologyEVSE `json:"evses" validate:"required,min=1,dive"`
+	// The nominal voltage between phase and neutral, used for converting between W and A. Defaults to DefaultNominalVoltage.
+	NominalVoltage float64 `json:"nominalVoltage,omitempty" validate:"gte=0"`
+}
+
+// NewStationTopology creates a validated topology from the passed EVSEs.
+func NewStationTopology(evses ...TopologyEVSE) (*StationTopology, error) {
+	t := &StationTopology{EVSEs: evses}
+	if err := t.init(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// LoadStationTopology parses and validates a topology from JSON, e.g.:
+//
+//	{"nominalVoltage": 230, "evses": [{"id": 1, "maxCurrent": 32, "connectors": [{"id": 1, "type": "sType2", "phases": 3}]}]}
+func LoadStationTopology(data []byte) (*StationTopology, error) {
+	var t StationTopology
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidStationTopology, err)
+	}
+	if err := t.init(); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// Validates the topology and sorts EVSEs and connectors by id.
+func (t *StationTopology) init() error {
+	if err := t.Validate(); err != nil {
+		return err
+	}
+	evses := make([]TopologyEVSE, len(t.EVSEs))
+	for i, evse := range t.EVSEs {
+		evse.Connectors = append([]TopologyConnector{}, evse.Connectors...)
+		sort.Slice(evse.Connectors, func(a, b int) bool { return evse.Connectors[a].ID < evse.Connectors[b].ID })
+		evses[i] = evse
+	}
+	sort.Slice(evses, func(a, b int) bool { return evses[a].ID < evses[b].ID })
+	t.EVSEs = evses
+	if t.NominalVoltage == 0 {
+		t.NominalVoltage = DefaultNominalVoltage
+	}
+	return nil
+}
+
+// Validate checks the topology for missing or invalid fields, as well as duplicate EVSE and connector ids.
+// Returned errors wrap ErrInvalidStationTopology.
+func (t *StationTopology) Validate() error {
+	if err := Validate.Struct(t); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidStationTopology, err)
+	}
+	evseIDs := map[int]bool{}
+	for _, evse := range t.EVSEs {
+		if evseIDs[evse.ID] {
+			return fmt.Errorf("%w: duplicate evse %v", ErrInvalidStationTopology, evse.ID)
+		}
+		evseIDs[evse.ID] = true
+		connectorIDs := map[int]bool{}
+		for _, connector := range evse.Connectors {
+			if connectorIDs[connector.ID] {
+				return fmt.Errorf("%w: duplicate connector %v on evse %v", ErrInvalidStationTopology, connector.ID, evse.ID)
+			}
+			connectorIDs[connector.ID] = true
+		}
+	}
+	return nil
+}
+
+// EVSE returns the EVSE with the given id.
+func (t *StationTopology) EVSE(evseID int) (TopologyEVSE, bool) {
+	for _, evse := range t.EVSEs {
+		if evse.ID == evseID {
+			return evse, true
+		}
+	}
+	return TopologyEVSE{}, false
+}
+
+// EVSEIDs returns the ids of all EVSEs in ascending order.
+func (t *StationTopology) EVSEIDs() []int {
+	ids := make([]int, 0, len(t.EVSEs))
+	for _, evse := range t.EVSEs {
+		ids = append(ids, evse.ID)
+	}
+	return ids
+}
+
+// ConnectorTypes returns the connector types of each EVSE, ordered by connector id.
+func (t *StationTopology) ConnectorTypes() map[int][]ConnectorType {
+	result := map[int][]ConnectorType{}
+	for _, evse := range t.EVSEs {
+		for _, connector := range evse.Connectors {
+			result[evse.ID] = append(result[evse.ID], connector.Type)
+		}
+	}
+	return result
+}
+
+// ValidateConnector returns the reason code for rejecting a request referring to an unknown EVSE or connector,
+// or an empty string if the connector is known. A zero connector ID refers to the whole EVSE.
+func (t *StationTopology) ValidateConnector(evseID int, connectorID int) string {
+	evse, ok := t.EVSE(evseID)
+	if !ok {
+		return "UnknownEvse"
+	}
+	if connectorID == 0 {
+		return ""
+	}
+	for _, connector := range evse.Connectors {
+		if connector.ID == connectorID {
+			return ""
+		}
+	}
+	return "UnknownConnectorId"
+}
+
+// NumConnectors returns the total amount of connectors of the station, as configured via NumberOfConnectors in OCPP 1.6.
+func (t *StationTopology) NumConnectors() int {
+	count := 0
+	for _, evse := range t.EVSEs {
+		count += len(evse.Connectors)
+	}
+	return count
+}
+
+// EVSEForConnector maps the flat connector numbering of OCPP 1.6 to an EVSE and the connector id on that EVSE.
+// OCPP 1.6 connector ids are assigned consecutively, starting at 1, following the order of EVSE and connector ids.
+// Connector id 0 refers to the whole station, and is mapped to EVSE 0.
+//
+// Returns false if the connector doesn't exist.
+func (t *StationTopology) EVSEForConnector(connectorID int) (evseID int, evseConnectorID int, ok bool) {
+	if connectorID == 0 {
+		return 0, 0, true
+	}
+	if connectorID < 0 {
+		return 0, 0, false
+	}
+	for _, evse := range t.EVSEs {
+		if connectorID <= len(evse.Connectors) {
+			return evse.ID, evse.Connectors[connectorID-1].ID, true
+		}
+		connectorID -= len(evse.Connectors)
+	}
+	return 0, 0, false
+}
+
+// ConnectorForEVSE is the inverse of EVSEForConnector, returning the OCPP 1.6 connector id of a connector on an EVSE.
+func (t *StationTopology) ConnectorForEVSE(evseID int, evseConnectorID int) (connectorID int, ok bool) {
+	offset := 0
+	for _, evse := range t.EVSEs {
+		for i, connector := range evse.Connectors {
+			if evse.ID == evseID && connector.ID == evseConnectorID {
+				return offset + i + 1, true
+			}
+		}
+		offset += len(evse.Connectors)
+	}
+	return 0, false
+}
+
+// PhasesForEVSE returns the highest amount of phases of the connectors of an EVSE.
+func (t *StationTopology) PhasesForEVSE(evseID int) (int, bool) {
+	evse, ok := t.EVSE(evseID)
+	if !ok {
+		return 0, false
+	}
+	phases := 0
+	for _, connector := range evse.Connectors {
+		if connector.Phases > phases {
+			phases = connector.Phases
+		}
+	}
+	return phases, true
+}
+
+// MaxCurrentForEVSE returns the hardware limit of an EVSE in the requested unit: the current per phase in A, or the power in W.
+//
+// The limit is the most restrictive of the EVSE current and power limits. If the EVSE has no current limit,
+// the highest current limit of its connectors applies. Values are converted using the nominal voltage
+// and the amount of phases of the EVSE (see PhasesForEVSE).
+//
+// Returns false if the EVSE doesn't exist, if no limit is defined, or if the unit is invalid.
+func (t *StationTopology) MaxCurrentForEVSE(evseID int, unit ChargingRateUnitType) (float64, bool) {
+	evse, ok := t.EVSE(evseID)
+	if !ok {
+		return 0, false
+	}
+	phases, _ := t.PhasesForEVSE(evseID)
+	voltage := t.NominalVoltage
+	if voltage == 0 {
+		voltage = DefaultNominalVoltage
+	}
+	current := evse.MaxCurrent
+	if current == 0 {
+		for _, connector := range evse.Connectors {
+			if connector.MaxCurrent == 0 {
+				current = 0
+				break
+			}
+			if connector.MaxCurrent > current {
+				current = connector.MaxCurrent
+			}
+		}
+	}
+	watts := current * voltage * float64(phases)
+	if evse.MaxPower > 0 && (watts == 0 || evse.MaxPower < watts) {
+		watts = evse.MaxPower
+	}
+	if watts == 0 {
+		return 0, false
+	}
+	switch unit {
+	case ChargingRateUnitWatts:
+		return watts, true
+	case ChargingRateUnitAmperes:
+		return watts / (voltage * float64(phases)), true
+	default:
+		return 0, false
+	}
+}
+
+func init() {
+	_ = RegisterValidation("connectorType", isValidConnectorType)
+	_ = RegisterValidation("phaseRotation", isValidPhaseRotation)
+}
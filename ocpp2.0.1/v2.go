@@ -2,10 +2,15 @@
 package ocpp2
 
 import (
+	"context"
 	"crypto/tls"
 	"net"
+	"time"
 
+	"github.com/lorenzodonini/ocpp-go/clocksync"
+	"github.com/lorenzodonini/ocpp-go/events"
 	"github.com/lorenzodonini/ocpp-go/internal/callbackqueue"
+	"github.com/lorenzodonini/ocpp-go/logging"
 	"github.com/lorenzodonini/ocpp-go/ocpp"
 	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/authorization"
 	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/availability"
@@ -97,6 +102,8 @@ type ChargingStation interface {
 	NotifyEvent(generatedAt *types.DateTime, seqNo int, eventData []diagnostics.EventData, props ...func(request *diagnostics.NotifyEventRequest)) (*diagnostics.NotifyEventResponse, error)
 	// Sends a monitoring report to the CSMS, according to parameters specified in the GetMonitoringReport request, previously sent by the CSMS.
 	NotifyMonitoringReport(requestID int, seqNo int, generatedAt *types.DateTime, monitorData []diagnostics.MonitoringData, props ...func(request *diagnostics.NotifyMonitoringReportRequest)) (*diagnostics.NotifyMonitoringReportResponse, error)
+	// Notifies the CSMS that priority charging was started or stopped for a transaction (OCPP 2.1 extension).
+	NotifyPriorityCharging(transactionID string, activated bool, props ...func(request *remotecontrol.NotifyPriorityChargingRequest)) (*remotecontrol.NotifyPriorityChargingResponse, error)
 	// Sends a base report to the CSMS, according to parameters specified in the GetBaseReport request, previously sent by the CSMS.
 	NotifyReport(requestID int, generatedAt *types.DateTime, seqNo int, props ...func(request *provisioning.NotifyReportRequest)) (*provisioning.NotifyReportResponse, error)
 	// Notifies the CSMS about the current progress of a PublishFirmware operation.
@@ -178,11 +185,60 @@ type ChargingStation interface {
 	// Returns true if the charging station is currently connected to the CSMS, false otherwise.
 	// While automatically reconnecting to the CSMS, the method returns false.
 	IsConnected() bool
+	// Enables an application-level watchdog, detecting connections on which requests are sent, but no messages are received anymore.
+	// If no message was received from the CSMS for at least maxSilence, despite requests being sent,
+	// the connection is aborted and the automatic reconnection mechanism is started.
+	// The watchdog never triggers while the charging station is idle.
+	//
+	// Passing a maxSilence <= 0 disables the watchdog.
+	EnableResponseWatchdog(maxSilence time.Duration)
+	// Registers a handler, which is invoked every time the response watchdog forces a reconnection.
+	SetResponseWatchdogHandler(handler func(silence time.Duration))
+	// Enables the basic auth password rotation (use case A05), driven by SetVariablesRequests for the
+	// SecurityCtrlr.BasicAuthPassword variable. Values not satisfying provisioning.ValidateBasicAuthPassword are rejected,
+	// without invoking the provisioning handler.
+	//
+	// Once the handler accepted a new password, it is passed to persist. On success, the new credentials are
+	// used by the websocket client, and the charging station reconnects to the CSMS shortly after responding.
+	// If persist returns an error, the change is reported as Rejected and the current connection is kept.
+	//
+	// Passing nil disables the rotation, so SetVariablesRequests are passed on to the provisioning handler as is.
+	EnableBasicAuthPasswordRotation(persist func(password string) error)
+	// Sets the clock synchronization mode, defining how the current time reported by the CSMS
+	// in BootNotification and Heartbeat responses is used. Clock synchronization is disabled by default.
+	//
+	// In clocksync.ModeTrackOffset, the learned offset is applied to Now. Additionally, timestamps passed as nil
+	// to the StatusNotification, TransactionEvent and SecurityEventNotification functions are filled with the corrected time.
+	// Refer to clocksync.Clock for details on how the offset is smoothed.
+	SetClockSyncMode(mode clocksync.Mode)
+	// Registers a handler, invoked with the authoritative time reported by the CSMS, while in clocksync.ModeCallback.
+	// The handler is expected to adjust the local clock.
+	SetClockSyncHandler(handler func(authoritativeTime time.Time))
+	// Returns the current time. In clocksync.ModeTrackOffset, the offset learned from the CSMS is applied.
+	Now() time.Time
+	// Sets the logger used by the charging station, overriding the package-level loggers of the ocppj and ws packages.
+	// Log entries are enriched with the charging station ID and, where available, the action and unique ID of the processed message.
+	// Passing nil restores the package-level loggers.
+	//
+	// The logger must be set before calling Start.
+	SetLogger(logger logging.Logger)
+	// Sets a bus, on which the connection state of the charging station (ConnectionUp, ConnectionDown), as well as timed out requests
+	// and queue overflows (RequestTimedOut, QueueOverflow) are published, provided the websocket client supports it.
+	// Passing nil disables publishing.
+	//
+	// The bus must be set before calling Start.
+	SetEventBus(bus *events.Bus)
 	// Errors returns a channel for error messages. If it doesn't exist it es created.
 	// The channel is closed by the charging station when stopped.
 	Errors() <-chan error
 }
 
+// Profiles returns all profiles supported by default by charging stations and the CSMS, in the order they are registered
+// with the ocppj endpoints created by the constructors of this package.
+func Profiles() []*ocpp.Profile {
+	return []*ocpp.Profile{authorization.Profile, availability.Profile, data.Profile, diagnostics.Profile, display.Profile, firmware.Profile, iso15118.Profile, localauth.Profile, meter.Profile, provisioning.Profile, remotecontrol.Profile, reservation.Profile, security.Profile, smartcharging.Profile, tariffcost.Profile, transactions.Profile}
+}
+
 // Creates a new OCPP 2.0 charging station client.
 // The id parameter is required to uniquely identify the charge point.
 //
@@ -216,19 +272,23 @@ func NewChargingStation(id string, endpoint *ocppj.Client, client ws.WsClient) C
 
 	if endpoint == nil {
 		dispatcher := ocppj.NewDefaultClientDispatcher(ocppj.NewFIFOClientQueue(0))
-		endpoint = ocppj.NewClient(id, client, dispatcher, nil, authorization.Profile, availability.Profile, data.Profile, diagnostics.Profile, display.Profile, firmware.Profile, iso15118.Profile, localauth.Profile, meter.Profile, provisioning.Profile, remotecontrol.Profile, reservation.Profile, security.Profile, smartcharging.Profile, tariffcost.Profile, transactions.Profile)
+		endpoint = ocppj.NewClient(id, client, dispatcher, nil, Profiles()...)
 	}
 	endpoint.SetDialect(ocpp.V2)
 
 	cs := chargingStation{
 		client:          endpoint,
+		network:         client,
 		responseHandler: make(chan ocpp.Response, 1),
 		errorHandler:    make(chan error, 1),
 		callbacks:       callbackqueue.New(),
+		clock:           clocksync.NewClock(),
+		sendTimes:       map[ocpp.Request]time.Time{},
 	}
 
 	// Callback invoked by dispatcher, whenever a queued request is canceled, due to timeout.
 	endpoint.SetOnRequestCanceled(cs.onRequestTimeout)
+	endpoint.SetOnRequestSent(cs.onRequestSent)
 
 	cs.client.SetResponseHandler(func(confirmation ocpp.Response, requestId string) {
 		cs.responseHandler <- confirmation
@@ -237,11 +297,27 @@ func NewChargingStation(id string, endpoint *ocppj.Client, client ws.WsClient) C
 		cs.errorHandler <- err
 	})
 	cs.client.SetRequestHandler(cs.handleIncomingRequest)
+	// Schema violations detected in warn mode are reported on the error channel
+	cs.client.SetSchemaViolationHandler(func(err *ocppj.SchemaValidationError) {
+		cs.error(err)
+	})
+	// Panics of handlers and callbacks are reported on the error channel
+	cs.client.SetPanicHandler(func(err *ocppj.HandlerPanicError) {
+		cs.error(err)
+	})
 	return &cs
 }
 
 // -------------------- v2.0 CSMS --------------------
 
+// CapabilityProvider provides the controllers, which charging stations reported in their device model.
+// It is implemented by csms.StationRegistry.
+type CapabilityProvider interface {
+	// ReportedControllers returns the controller components reported by a charging station, along with the time of the report.
+	// Returns false if the charging station didn't report its device model yet.
+	ReportedControllers(chargingStationID string) (controllers []string, reportedAt time.Time, ok bool)
+}
+
 // A Charging Station Management System (CSMS) manages Charging Stations and has the information for authorizing Management Users for using its Charging Stations.
 // You can instantiate a default CSMS struct by calling the NewCSMS function.
 //
@@ -325,6 +401,7 @@ type CSMS interface {
 	// Instructs the Charging Station to reset itself.
 	Reset(clientId string, callback func(*provisioning.ResetResponse, error), t provisioning.ResetType, props ...func(request *provisioning.ResetRequest)) error
 	// Sends a local authorization list to a charging station, which can be used for the authorization of idTokens.
+	// If the list is split due to message limits, the parts carry consecutive version numbers ending with version (see provisioning.SplitSendLocalList).
 	SendLocalList(clientId string, callback func(*localauth.SendLocalListResponse, error), version int, updateType localauth.UpdateType, props ...func(request *localauth.SendLocalListRequest)) error
 	// Sends a charging profile to a charging station, to influence the power/current drawn by EVs.
 	SetChargingProfile(clientId string, callback func(*smartcharging.SetChargingProfileResponse, error), evseID int, chargingProfile *types.ChargingProfile, props ...func(request *smartcharging.SetChargingProfileRequest)) error
@@ -344,54 +421,223 @@ type CSMS interface {
 	TriggerMessage(clientId string, callback func(*remotecontrol.TriggerMessageResponse, error), requestedMessage remotecontrol.MessageTrigger, props ...func(request *remotecontrol.TriggerMessageRequest)) error
 	// Instructs the Charging Station to unlock a connector, to help out an EV-driver.
 	UnlockConnector(clientId string, callback func(*remotecontrol.UnlockConnectorResponse, error), evseID int, connectorID int, props ...func(request *remotecontrol.UnlockConnectorRequest)) error
+	// Requests a Charging Station to start or stop priority charging for an ongoing transaction (OCPP 2.1 extension).
+	UsePriorityCharging(clientId string, callback func(*remotecontrol.UsePriorityChargingResponse, error), transactionID string, activate bool, props ...func(request *remotecontrol.UsePriorityChargingRequest)) error
 	// Instructs a Local Controller to stops serving a firmware update to connected Charging Stations.
 	UnpublishFirmware(clientId string, callback func(*firmware.UnpublishFirmwareResponse, error), checksum string, props ...func(request *firmware.UnpublishFirmwareRequest)) error
 	// Instructs a Charging Station to download and install a firmware update.
 	UpdateFirmware(clientId string, callback func(*firmware.UpdateFirmwareResponse, error), requestID int, firmware firmware.Firmware, props ...func(request *firmware.UpdateFirmwareRequest)) error
 
+	// The ...Async functions work like their callback-based counterparts, but return a Future instead of invoking a callback.
+	// The future completes with the response, or with a *RequestError if the request timed out, was canceled,
+	// the charging station disconnected, or replied with a CALLERROR. Requests are queued together with the callback-based ones:
+	//
+	//	future, err := csms.ResetAsync("cs0001", provisioning.ResetTypeImmediate)
+	//	if err != nil {
+	//		// the request couldn't be sent
+	//	}
+	//	response, err := future.Get(ctx)
+	CancelReservationAsync(clientId string, reservationId int, props ...func(request *reservation.CancelReservationRequest)) (*Future[*reservation.CancelReservationResponse], error)
+	CertificateSignedAsync(clientId string, certificateChain string, props ...func(*security.CertificateSignedRequest)) (*Future[*security.CertificateSignedResponse], error)
+	ChangeAvailabilityAsync(clientId string, operationalStatus availability.OperationalStatus, props ...func(request *availability.ChangeAvailabilityRequest)) (*Future[*availability.ChangeAvailabilityResponse], error)
+	ClearCacheAsync(clientId string, props ...func(*authorization.ClearCacheRequest)) (*Future[*authorization.ClearCacheResponse], error)
+	ClearChargingProfileAsync(clientId string, props ...func(request *smartcharging.ClearChargingProfileRequest)) (*Future[*smartcharging.ClearChargingProfileResponse], error)
+	ClearDisplayAsync(clientId string, id int, props ...func(*display.ClearDisplayRequest)) (*Future[*display.ClearDisplayResponse], error)
+	ClearVariableMonitoringAsync(clientId string, id []int, props ...func(*diagnostics.ClearVariableMonitoringRequest)) (*Future[*diagnostics.ClearVariableMonitoringResponse], error)
+	CostUpdatedAsync(clientId string, totalCost float64, transactionId string, props ...func(*tariffcost.CostUpdatedRequest)) (*Future[*tariffcost.CostUpdatedResponse], error)
+	CustomerInformationAsync(clientId string, requestId int, report bool, clear bool, props ...func(*diagnostics.CustomerInformationRequest)) (*Future[*diagnostics.CustomerInformationResponse], error)
+	DataTransferAsync(clientId string, vendorId string, props ...func(request *data.DataTransferRequest)) (*Future[*data.DataTransferResponse], error)
+	DeleteCertificateAsync(clientId string, data types.CertificateHashData, props ...func(*iso15118.DeleteCertificateRequest)) (*Future[*iso15118.DeleteCertificateResponse], error)
+	GetBaseReportAsync(clientId string, requestId int, reportBase provisioning.ReportBaseType, props ...func(*provisioning.GetBaseReportRequest)) (*Future[*provisioning.GetBaseReportResponse], error)
+	GetChargingProfilesAsync(clientId string, chargingProfile smartcharging.ChargingProfileCriterion, props ...func(*smartcharging.GetChargingProfilesRequest)) (*Future[*smartcharging.GetChargingProfilesResponse], error)
+	GetCompositeScheduleAsync(clientId string, duration int, evseId int, props ...func(*smartcharging.GetCompositeScheduleRequest)) (*Future[*smartcharging.GetCompositeScheduleResponse], error)
+	GetDisplayMessagesAsync(clientId string, requestId int, props ...func(*display.GetDisplayMessagesRequest)) (*Future[*display.GetDisplayMessagesResponse], error)
+	GetInstalledCertificateIdsAsync(clientId string, props ...func(*iso15118.GetInstalledCertificateIdsRequest)) (*Future[*iso15118.GetInstalledCertificateIdsResponse], error)
+	GetLocalListVersionAsync(clientId string, props ...func(*localauth.GetLocalListVersionRequest)) (*Future[*localauth.GetLocalListVersionResponse], error)
+	GetLogAsync(clientId string, logType diagnostics.LogType, requestID int, logParameters diagnostics.LogParameters, props ...func(*diagnostics.GetLogRequest)) (*Future[*diagnostics.GetLogResponse], error)
+	GetMonitoringReportAsync(clientId string, props ...func(*diagnostics.GetMonitoringReportRequest)) (*Future[*diagnostics.GetMonitoringReportResponse], error)
+	GetReportAsync(clientId string, props ...func(*provisioning.GetReportRequest)) (*Future[*provisioning.GetReportResponse], error)
+	GetTransactionStatusAsync(clientId string, props ...func(*transactions.GetTransactionStatusRequest)) (*Future[*transactions.GetTransactionStatusResponse], error)
+	GetVariablesAsync(clientId string, variableData []provisioning.GetVariableData, props ...func(*provisioning.GetVariablesRequest)) (*Future[*provisioning.GetVariablesResponse], error)
+	InstallCertificateAsync(clientId string, certificateType types.CertificateUse, certificate string, props ...func(*iso15118.InstallCertificateRequest)) (*Future[*iso15118.InstallCertificateResponse], error)
+	PublishFirmwareAsync(clientId string, location string, checksum string, requestID int, props ...func(request *firmware.PublishFirmwareRequest)) (*Future[*firmware.PublishFirmwareResponse], error)
+	RequestStartTransactionAsync(clientId string, remoteStartID int, IdToken types.IdToken, props ...func(request *remotecontrol.RequestStartTransactionRequest)) (*Future[*remotecontrol.RequestStartTransactionResponse], error)
+	RequestStopTransactionAsync(clientId string, transactionID string, props ...func(request *remotecontrol.RequestStopTransactionRequest)) (*Future[*remotecontrol.RequestStopTransactionResponse], error)
+	ReserveNowAsync(clientId string, id int, expiryDateTime *types.DateTime, idToken types.IdToken, props ...func(request *reservation.ReserveNowRequest)) (*Future[*reservation.ReserveNowResponse], error)
+	ResetAsync(clientId string, t provisioning.ResetType, props ...func(request *provisioning.ResetRequest)) (*Future[*provisioning.ResetResponse], error)
+	SendLocalListAsync(clientId string, version int, updateType localauth.UpdateType, props ...func(request *localauth.SendLocalListRequest)) (*Future[*localauth.SendLocalListResponse], error)
+	SetChargingProfileAsync(clientId string, evseID int, chargingProfile *types.ChargingProfile, props ...func(request *smartcharging.SetChargingProfileRequest)) (*Future[*smartcharging.SetChargingProfileResponse], error)
+	SetDisplayMessageAsync(clientId string, message display.MessageInfo, props ...func(request *display.SetDisplayMessageRequest)) (*Future[*display.SetDisplayMessageResponse], error)
+	SetMonitoringBaseAsync(clientId string, monitoringBase diagnostics.MonitoringBase, props ...func(request *diagnostics.SetMonitoringBaseRequest)) (*Future[*diagnostics.SetMonitoringBaseResponse], error)
+	SetMonitoringLevelAsync(clientId string, severity int, props ...func(request *diagnostics.SetMonitoringLevelRequest)) (*Future[*diagnostics.SetMonitoringLevelResponse], error)
+	SetNetworkProfileAsync(clientId string, configurationSlot int, connectionData provisioning.NetworkConnectionProfile, props ...func(request *provisioning.SetNetworkProfileRequest)) (*Future[*provisioning.SetNetworkProfileResponse], error)
+	SetVariableMonitoringAsync(clientId string, data []diagnostics.SetMonitoringData, props ...func(request *diagnostics.SetVariableMonitoringRequest)) (*Future[*diagnostics.SetVariableMonitoringResponse], error)
+	SetVariablesAsync(clientId string, data []provisioning.SetVariableData, props ...func(request *provisioning.SetVariablesRequest)) (*Future[*provisioning.SetVariablesResponse], error)
+	RotateBasicAuthPasswordAsync(clientId string, newPassword string, props ...func(request *provisioning.SetVariablesRequest)) (*Future[*provisioning.SetVariablesResponse], error)
+	TriggerMessageAsync(clientId string, requestedMessage remotecontrol.MessageTrigger, props ...func(request *remotecontrol.TriggerMessageRequest)) (*Future[*remotecontrol.TriggerMessageResponse], error)
+	UnlockConnectorAsync(clientId string, evseID int, connectorID int, props ...func(request *remotecontrol.UnlockConnectorRequest)) (*Future[*remotecontrol.UnlockConnectorResponse], error)
+	UnpublishFirmwareAsync(clientId string, checksum string, props ...func(request *firmware.UnpublishFirmwareRequest)) (*Future[*firmware.UnpublishFirmwareResponse], error)
+	UpdateFirmwareAsync(clientId string, requestID int, f firmware.Firmware, props ...func(request *firmware.UpdateFirmwareRequest)) (*Future[*firmware.UpdateFirmwareResponse], error)
+	UsePriorityChargingAsync(clientId string, transactionID string, activate bool, props ...func(request *remotecontrol.UsePriorityChargingRequest)) (*Future[*remotecontrol.UsePriorityChargingResponse], error)
+
 	// Registers a handler for incoming security profile messages.
 	SetSecurityHandler(handler security.CSMSHandler)
+	// Registers a context-aware handler for incoming security profile messages, replacing the handler set via SetSecurityHandler.
+	SetSecurityHandlerWithContext(handler security.CSMSHandlerWithContext)
 	// Registers a handler for incoming provisioning profile messages.
 	SetProvisioningHandler(handler provisioning.CSMSHandler)
+	// Registers a context-aware handler for incoming provisioning profile messages, replacing the handler set via SetProvisioningHandler.
+	SetProvisioningHandlerWithContext(handler provisioning.CSMSHandlerWithContext)
 	// Registers a handler for incoming authorization profile messages.
 	SetAuthorizationHandler(handler authorization.CSMSHandler)
+	// Registers a context-aware handler for incoming authorization profile messages, replacing the handler set via SetAuthorizationHandler.
+	SetAuthorizationHandlerWithContext(handler authorization.CSMSHandlerWithContext)
 	// Registers a handler for incoming local authorization list profile messages.
 	SetLocalAuthListHandler(handler localauth.CSMSHandler)
 	// Registers a handler for incoming transactions profile messages
 	SetTransactionsHandler(handler transactions.CSMSHandler)
+	// Registers a context-aware handler for incoming transactions profile messages, replacing the handler set via SetTransactionsHandler.
+	SetTransactionsHandlerWithContext(handler transactions.CSMSHandlerWithContext)
 	// Registers a handler for incoming remote control profile messages
 	SetRemoteControlHandler(handler remotecontrol.CSMSHandler)
+	// Registers a context-aware handler for incoming remote control profile messages, replacing the handler set via SetRemoteControlHandler.
+	SetRemoteControlHandlerWithContext(handler remotecontrol.CSMSHandlerWithContext)
 	// Registers a handler for incoming availability profile messages
 	SetAvailabilityHandler(handler availability.CSMSHandler)
+	// Registers a context-aware handler for incoming availability profile messages, replacing the handler set via SetAvailabilityHandler.
+	SetAvailabilityHandlerWithContext(handler availability.CSMSHandlerWithContext)
 	// Registers a handler for incoming reservation profile messages
 	SetReservationHandler(handler reservation.CSMSHandler)
+	// Registers a context-aware handler for incoming reservation profile messages, replacing the handler set via SetReservationHandler.
+	SetReservationHandlerWithContext(handler reservation.CSMSHandlerWithContext)
 	// Registers a handler for incoming tariff and cost profile messages
 	SetTariffCostHandler(handler tariffcost.CSMSHandler)
 	// Registers a handler for incoming meter profile messages
 	SetMeterHandler(handler meter.CSMSHandler)
+	// Registers a context-aware handler for incoming meter profile messages, replacing the handler set via SetMeterHandler.
+	SetMeterHandlerWithContext(handler meter.CSMSHandlerWithContext)
 	// Registers a handler for incoming smart charging messages
 	SetSmartChargingHandler(handler smartcharging.CSMSHandler)
+	// Registers a context-aware handler for incoming smart charging messages, replacing the handler set via SetSmartChargingHandler.
+	SetSmartChargingHandlerWithContext(handler smartcharging.CSMSHandlerWithContext)
 	// Registers a handler for incoming firmware management messages
 	SetFirmwareHandler(handler firmware.CSMSHandler)
+	// Registers a context-aware handler for incoming firmware management messages, replacing the handler set via SetFirmwareHandler.
+	SetFirmwareHandlerWithContext(handler firmware.CSMSHandlerWithContext)
 	// Registers a handler for incoming ISO15118 management messages
 	SetISO15118Handler(handler iso15118.CSMSHandler)
+	// Registers a context-aware handler for incoming ISO15118 management messages, replacing the handler set via SetISO15118Handler.
+	SetISO15118HandlerWithContext(handler iso15118.CSMSHandlerWithContext)
 	// Registers a handler for incoming diagnostics messages
 	SetDiagnosticsHandler(handler diagnostics.CSMSHandler)
-	// Registers a handler for incoming display messages
+	// Registers a context-aware handler for incoming diagnostics messages, replacing the handler set via SetDiagnosticsHandler.
+	SetDiagnosticsHandlerWithContext(handler diagnostics.CSMSHandlerWithContext)
+	// Registers a handler for incoming display messages.
+	// If the handler also implements display.DisplayMessagesCompletionHandler, multi-part notifications are reassembled automatically.
 	SetDisplayHandler(handler display.CSMSHandler)
+	// Registers a context-aware handler for incoming display messages, replacing the handler set via SetDisplayHandler.
+	// If the handler also implements display.DisplayMessagesCompletionHandler, multi-part notifications are reassembled automatically.
+	SetDisplayHandlerWithContext(handler display.CSMSHandlerWithContext)
 	// Registers a handler for incoming data transfer messages
 	SetDataHandler(handler data.CSMSHandler)
+	// Registers a context-aware handler for incoming data transfer messages, replacing the handler set via SetDataHandler.
+	SetDataHandlerWithContext(handler data.CSMSHandlerWithContext)
 	// Registers a handler for new incoming Charging station connections.
 	SetNewChargingStationValidationHandler(handler ws.CheckClientHandler)
+	// Enables default handlers, answering trivial requests on behalf of the application. Values may be combined:
+	//
+	//	csms.UseDefaultHandlers(ocpp2.DefaultHeartbeat | ocpp2.DefaultStatusNotification | ocpp2.DefaultNotifyReport)
+	//
+	// The following requests are covered:
+	//  - Heartbeat and StatusNotification, if no availability handler was set
+	//  - NotifyReport, if no provisioning handler was set
+	//  - NotifyEvent, NotifyMonitoringReport and LogStatusNotification, if no diagnostics handler was set
+	//  - SecurityEventNotification, if no security handler was set
+	//  - FirmwareStatusNotification, if no firmware handler was set
+	//
+	// A handler set for the profile of a request always takes precedence. Responses of default handlers
+	// are sent via the ocppj endpoint as usual, hence they are recorded by the audit handler.
+	UseDefaultHandlers(handlers DefaultHandler)
+	// Registers an observer, receiving every request answered by a default handler, e.g. a csms.StationRegistry
+	// for keeping the model of the charging stations up to date.
+	SetDefaultHandlerObserver(observer RequestObserver)
 	// Registers a handler for new incoming Charging station connections.
 	SetNewChargingStationHandler(handler ChargingStationConnectionHandler)
 	// Registers a handler for Charging station disconnections.
 	SetChargingStationDisconnectedHandler(handler ChargingStationConnectionHandler)
+	// Holds requests received from charging stations, until HandlersReady is invoked. Useful if handlers are registered
+	// asynchronously after starting the CSMS, since requests received before would be answered with an error.
+	// Requests held for longer than maxHold, or exceeding maxQueued held requests, are handled right away.
+	//
+	// Refer to ocppj.Server.HoldRequestsUntilHandlersReady for more information. Must be invoked before calling Start.
+	HoldRequestsUntilHandlersReady(maxHold time.Duration, maxQueued int)
+	// Signals that all handlers were registered. Held requests are passed to the handlers in the order they were received.
+	HandlersReady()
+	// Processes the messages received from each charging station via a bounded inbox, so that slow handlers don't stall
+	// reading from its connection. Inboxes are served by at most maxWorkers goroutines, or one per charging station if zero.
+	//
+	// Refer to ocppj.Server.SetClientInbox for more information. Must be invoked before calling Start.
+	SetClientInbox(inboxSize int, maxWorkers int)
+	// Enables a liveness monitor, which closes the connections of charging stations that stopped sending messages without
+	// disconnecting, so that their reconnection logic kicks in. Charging stations exceeding their heartbeat interval plus
+	// a grace time are probed via TriggerMessage(Heartbeat) first, if the RemoteControl profile is supported.
+	// Heartbeat intervals are taken from BootNotification messages, and from the HeartbeatCtrlr Interval variable
+	// reported via GetVariables or set via SetVariables.
+	//
+	// Refer to ocppj.Server.EnableLivenessMonitor for more information. Must be invoked before calling Start.
+	EnableLivenessMonitor(config ocppj.LivenessConfig)
+	// Registers a handler, invoked whenever a charging station is probed, recovers after a probe, or is reaped by the liveness monitor.
+	SetLivenessHandler(handler func(chargingStationID string, liveness ocppj.Liveness))
+	// Returns the liveness state of a connected charging station. Returns false if the charging station isn't connected,
+	// or the liveness monitor isn't enabled.
+	Liveness(chargingStationID string) (ocppj.Liveness, bool)
+	// Registers a handler, receiving an audit entry for every message sent by any charging station.
+	// Entries are created even for unparseable frames, invalid payloads and unsupported actions.
+	// For incoming requests, the entry contains the response or error returned to the charging station, as well as the handler latency.
+	//
+	// Entries are delivered asynchronously and never block message processing.
+	// If the handler cannot keep up, excess entries are dropped and counted. Refer to DroppedAuditEntries.
+	SetAuditHandler(handler func(entry ocppj.AuditEntry))
+	// Returns the amount of audit entries, which were dropped because the audit handler couldn't keep up.
+	DroppedAuditEntries() uint64
+	// Sets the message limits reported by a charging station (see provisioning.MessageLimitsFromReport).
+	// When limits are set, GetVariables, SetVariables, GetReport and SendLocalList requests exceeding them are automatically
+	// split into multiple requests, which are sent one after the other. The partial responses are aggregated into a single response.
+	// Passing zero limits disables the automatic split.
+	SetMessageLimits(clientId string, limits provisioning.MessageLimits)
+	// Attaches a cost calculator, which is consulted whenever the TransactionEvent handler returns a response without a totalCost.
+	// The calculated cost is filled into the response. The currency is passed to the calculator along with the transaction history.
+	//
+	// Calculation errors don't fail the response: the cost is omitted and the error is reported via the Errors channel.
+	// Passing a nil calculator disables the automatic cost calculation.
+	SetCostCalculator(calculator tariffcost.Calculator, currency string)
+	// Enables validating charging profiles via smartcharging.ValidateProfile201, before sending a SetChargingProfileRequest.
+	// If the profile is invalid, SetChargingProfile returns a *smartcharging.ProfileValidationError containing all violations,
+	// and the request is not sent. Validation is disabled by default.
+	SetChargingProfileValidation(enabled bool)
+	// Rotates the basic auth password of a charging station (use case A05), by setting the SecurityCtrlr.BasicAuthPassword variable.
+	// The password is validated via provisioning.ValidateBasicAuthPassword before sending the request.
+	//
+	// If the charging station accepts the new password, it reconnects using the new credentials shortly after.
+	// The callback should therefore update the credentials checked by the basic auth handler of the CSMS.
+	RotateBasicAuthPassword(clientId string, callback func(*provisioning.SetVariablesResponse, error), newPassword string, props ...func(request *provisioning.SetVariablesRequest)) error
+	// Sets the passed variables on all charging stations chosen by the selector, e.g. AllStations().
+	// Per charging station, the request is split according to its message limits, the partial results are merged,
+	// and requests which timed out or couldn't be delivered are retried (see RolloutOptions).
+	//
+	// One StationResult per targeted charging station is delivered on the returned channel, which is closed once the rollout completed.
+	// Use CollectRollout for grouping the results by outcome.
+	SetVariablesRollout(targets Selector, variables []provisioning.SetVariableData, opts RolloutOptions) (<-chan StationResult, error)
 	// Sends an asynchronous request to a Charging Station, identified by the clientId.
 	// The charging station will respond with a confirmation message, or with an error if the request was invalid or could not be processed.
 	// This result is propagated via a callback, called asynchronously.
 	// In case of network issues (i.e. the remote host couldn't be reached), the function returns an error directly. In this case, the callback is never invoked.
 	SendRequestAsync(clientId string, request ocpp.Request, callback func(ocpp.Response, error)) error
+	// Works like SendRequestAsync, but additionally links the span of the request to the trace contained in ctx,
+	// if a tracer was set on the ocppj endpoint (refer to ocppj.Server.SetTracer).
+	//
+	// The context is only used for tracing: canceling it doesn't cancel the request.
+	SendRequestAsyncWithContext(ctx context.Context, clientId string, request ocpp.Request, callback func(ocpp.Response, error)) error
 	// Starts running the CSMS on the specified port and URL.
 	// The central system runs as a daemon and handles incoming charge point connections and messages.
 
@@ -399,6 +645,34 @@ type CSMS interface {
 	Start(listenPort int, listenPath string)
 	// Stops the CSMS, clearing all pending requests.
 	Stop()
+	// Sets the logger used by the CSMS, overriding the package-level loggers of the ocppj and ws packages.
+	// Log entries are enriched with the charging station ID and, where available, the action and unique ID of the processed message.
+	// Passing nil restores the package-level loggers.
+	//
+	// The logger must be set before calling Start.
+	SetLogger(logger logging.Logger)
+	// Sets a collector, receiving measurements about the requests exchanged with charging stations.
+	// If the collector also implements ws.MetricsCollector, it receives measurements about the connections
+	// of the websocket server as well, provided the server supports it. Passing nil disables metrics collection.
+	//
+	// The collector must be set before calling Start.
+	SetMetricsCollector(collector ocppj.MetricsCollector)
+	// Sets a bus, on which the connections of the websocket server (ConnectionUp, ConnectionDown), as well as
+	// timed out requests and queue overflows (RequestTimedOut, QueueOverflow) are published, provided the server supports it.
+	// Passing nil disables publishing.
+	//
+	// The bus must be set before calling Start.
+	SetEventBus(bus *events.Bus)
+	// Returns a snapshot of the request flow towards each connected charging station, sorted by ID.
+	Stats() []ocppj.ClientStats
+	// Sets the provider of the controllers reported by charging stations, which are listed as capabilities in the Inventory.
+	// Passing nil removes the provider.
+	SetCapabilityProvider(provider CapabilityProvider)
+	// Returns all connected charging stations, sorted by ID, along with their connection details and capabilities.
+	//
+	// Capabilities are only known if a capability provider is set and the charging station reported its device model.
+	// They are marked as stale if the device model was reported during a previous connection of the charging station.
+	Inventory() []ocppj.EndpointInfo
 	// Errors returns a channel for error messages. If it doesn't exist it es created.
 	Errors() <-chan error
 }
@@ -422,11 +696,12 @@ func NewCSMS(endpoint *ocppj.Server, server ws.WsServer) CSMS {
 	server.AddSupportedSubprotocol(types.V201Subprotocol)
 	if endpoint == nil {
 		dispatcher := ocppj.NewDefaultServerDispatcher(ocppj.NewFIFOQueueMap(0))
-		endpoint = ocppj.NewServer(server, dispatcher, nil, authorization.Profile, availability.Profile, data.Profile, diagnostics.Profile, display.Profile, firmware.Profile, iso15118.Profile, localauth.Profile, meter.Profile, provisioning.Profile, remotecontrol.Profile, reservation.Profile, security.Profile, smartcharging.Profile, tariffcost.Profile, transactions.Profile)
+		endpoint = ocppj.NewServer(server, dispatcher, nil, Profiles()...)
 	}
 	cs := newCSMS(endpoint)
-	cs.server.SetRequestHandler(func(client ws.Channel, request ocpp.Request, requestId string, action string) {
-		cs.handleIncomingRequest(client, request, requestId, action)
+	cs.network = server
+	cs.server.SetRequestHandlerWithContext(func(ctx context.Context, client ws.Channel, request ocpp.Request, requestId string, action string) {
+		cs.handleIncomingRequest(ctx, client, request, requestId, action)
 	})
 	cs.server.SetResponseHandler(func(client ws.Channel, response ocpp.Response, requestId string) {
 		cs.handleIncomingResponse(client, response, requestId)
@@ -437,5 +712,81 @@ func NewCSMS(endpoint *ocppj.Server, server ws.WsServer) CSMS {
 	cs.server.SetCanceledRequestHandler(func(clientID string, requestID string, request ocpp.Request, err *ocpp.Error) {
 		cs.handleCanceledRequest(clientID, request, err)
 	})
+	cs.server.SetNewClientHandler(func(client ws.Channel) {
+		cs.handleConnect(client)
+	})
+	cs.server.SetDisconnectedClientHandler(func(client ws.Channel) {
+		cs.handleDisconnect(client)
+	})
+	// Schema violations detected in warn mode are reported on the error channel
+	cs.server.SetSchemaViolationHandler(func(err *ocppj.SchemaValidationError) {
+		cs.error(err)
+	})
+	// Panics of handlers and callbacks are reported on the error channel
+	cs.server.SetPanicHandler(func(err *ocppj.HandlerPanicError) {
+		cs.error(err)
+	})
 	return &cs
 }
+
+// Send sends a request to the CSMS and returns the response as the concrete type TResp, e.g.:
+//
+//	response, err := ocpp2.Send[*availability.HeartbeatResponse](ctx, chargingStation, availability.NewHeartbeatRequest())
+//
+// The function blocks until a response is received, the request fails or ctx is done.
+// If ctx is done first, ctx.Err() is returned, but the request is not withdrawn and may still be sent to the CSMS.
+// If the received response is not a TResp, a *ocpp.ResponseTypeError is returned.
+func Send[TResp ocpp.Response](ctx context.Context, cs ChargingStation, request ocpp.Request) (TResp, error) {
+	type result struct {
+		response ocpp.Response
+		err      error
+	}
+	resultC := make(chan result, 1)
+	go func() {
+		response, err := cs.SendRequest(request)
+		resultC <- result{response: response, err: err}
+	}()
+	var zero TResp
+	select {
+	case res := <-resultC:
+		if res.err != nil {
+			return zero, res.err
+		}
+		return ocpp.CastResponse[TResp](res.response)
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
+
+// SendTo sends a request to a charging station and returns the response as the concrete type TResp, e.g.:
+//
+//	response, err := ocpp2.SendTo[*provisioning.ResetResponse](ctx, csms, "cs0001", provisioning.NewResetRequest(provisioning.ResetTypeImmediate))
+//
+// The function blocks until a response is received, the request fails or ctx is done.
+// If ctx is done first, ctx.Err() is returned, but the request is still processed by the charging station.
+// If the received response is not a TResp, a *ocpp.ResponseTypeError is returned.
+//
+// The span of the request is linked to the trace contained in ctx, refer to CSMS.SendRequestAsyncWithContext.
+func SendTo[TResp ocpp.Response](ctx context.Context, csms CSMS, clientId string, request ocpp.Request) (TResp, error) {
+	type result struct {
+		response ocpp.Response
+		err      error
+	}
+	resultC := make(chan result, 1)
+	var zero TResp
+	err := csms.SendRequestAsyncWithContext(ctx, clientId, request, func(response ocpp.Response, err error) {
+		resultC <- result{response: response, err: err}
+	})
+	if err != nil {
+		return zero, err
+	}
+	select {
+	case res := <-resultC:
+		if res.err != nil {
+			return zero, res.err
+		}
+		return ocpp.CastResponse[TResp](res.response)
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+}
@@ -0,0 +1,69 @@
+package ocpp2_test
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/availability"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+)
+
+func (suite *OcppV2TestSuite) TestCSMSAuditHandler() {
+	t := suite.T()
+	wsId := "test_id"
+	channel := NewMockWebSocket(wsId)
+	entryC := make(chan ocppj.AuditEntry, 10)
+	handler := &MockCSMSAvailabilityHandler{}
+	handler.On("OnHeartbeat", mock.AnythingOfType("string"), mock.Anything).Return(availability.NewHeartbeatResponse(*types.NewDateTime(time.Now())), nil)
+	suite.csms.SetAvailabilityHandler(handler)
+	suite.csms.SetAuditHandler(func(entry ocppj.AuditEntry) {
+		entryC <- entry
+	})
+	suite.mockWsServer.On("Start", mock.AnythingOfType("int"), mock.AnythingOfType("string")).Return(nil)
+	suite.mockWsServer.On("Write", mock.AnythingOfType("string"), mock.Anything).Return(nil)
+	suite.csms.Start(8887, "somePath")
+	suite.mockWsServer.NewClientHandler(channel)
+	messages := []string{
+		`[2,"1","Heartbeat",{}]`,
+		`not a json frame`,
+		`[2,"2","UnknownAction",{}]`,
+		`[2,"3","Authorize",{}]`,
+	}
+	for _, message := range messages {
+		_ = suite.mockWsServer.MessageHandler(channel, []byte(message))
+	}
+	entries := map[string]ocppj.AuditEntry{}
+	for range messages {
+		select {
+		case entry := <-entryC:
+			entries[string(entry.RawMessage)] = entry
+		case <-time.After(time.Second):
+			t.Fatal("missing audit entry")
+		}
+	}
+	require.Len(t, entries, len(messages))
+	// Valid request
+	entry := entries[messages[0]]
+	assert.Equal(t, availability.HeartbeatFeatureName, entry.Action)
+	assert.Nil(t, entry.ParseError)
+	assert.IsType(t, &availability.HeartbeatResponse{}, entry.Response)
+	// Malformed frame
+	entry = entries[messages[1]]
+	require.NotNil(t, entry.ParseError)
+	assert.Equal(t, ocppj.FormatViolationV2, entry.ParseError.Code)
+	assert.Equal(t, ocppj.MessageType(0), entry.MessageType)
+	// Unknown action
+	entry = entries[messages[2]]
+	require.NotNil(t, entry.ResponseError)
+	assert.Equal(t, ocppj.NotSupported, entry.ResponseError.Code)
+	// Action without handler
+	entry = entries[messages[3]]
+	assert.Equal(t, "Authorize", entry.Action)
+	require.NotNil(t, entry.ResponseError)
+	assert.Nil(t, entry.Response)
+	assert.Equal(t, uint64(0), suite.csms.DroppedAuditEntries())
+}
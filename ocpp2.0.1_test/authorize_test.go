@@ -15,16 +15,16 @@ import (
 func (suite *OcppV2TestSuite) TestAuthorizeRequestValidation() {
 	t := suite.T()
 	var requestTable = []GenericTestEntry{
-		{authorization.AuthorizeRequest{Certificate: "deadc0de", IdToken: types.IdToken{IdToken: "1234", Type: types.IdTokenTypeKeyCode, AdditionalInfo: []types.AdditionalInfo{{AdditionalIdToken: "0000", Type: "someType"}}}, CertificateHashData: []types.OCSPRequestDataType{{SerialNumber: "serial0", HashAlgorithm: types.SHA256, IssuerNameHash: "hash0", IssuerKeyHash: "hash1", ResponderURL: "www.someurl.com"}}}, true},
+		{authorization.AuthorizeRequest{Certificate: "deadc0de", IdToken: types.IdToken{IdToken: "1234", Type: types.IdTokenTypeKeyCode, AdditionalInfo: []types.AdditionalInfo{{AdditionalIdToken: "0000", Type: "someType"}}}, CertificateHashData: []types.OCSPRequestDataType{{SerialNumber: "5e1a10", HashAlgorithm: types.SHA256, IssuerNameHash: "6ac73742db534bebccc9af1453c5637ee5bb5d7c9628ec2f26cf9777c89e96d8", IssuerKeyHash: "af316ecb91a8ee7ae99210702b2d4758f30cdde3bf61e3d8e787d74681f90a6e", ResponderURL: "www.someurl.com"}}}, true},
 		{authorization.AuthorizeRequest{Certificate: "deadc0de", IdToken: types.IdToken{IdToken: "1234", Type: types.IdTokenTypeKeyCode, AdditionalInfo: []types.AdditionalInfo{{AdditionalIdToken: "0000", Type: "someType"}}}}, true},
-		{authorization.AuthorizeRequest{IdToken: types.IdToken{IdToken: "1234", Type: types.IdTokenTypeKeyCode, AdditionalInfo: []types.AdditionalInfo{{AdditionalIdToken: "0000", Type: "someType"}}}, CertificateHashData: []types.OCSPRequestDataType{{SerialNumber: "serial0", HashAlgorithm: types.SHA256, IssuerNameHash: "hash0", IssuerKeyHash: "hash1", ResponderURL: "www.someurl.com"}}}, true},
+		{authorization.AuthorizeRequest{IdToken: types.IdToken{IdToken: "1234", Type: types.IdTokenTypeKeyCode, AdditionalInfo: []types.AdditionalInfo{{AdditionalIdToken: "0000", Type: "someType"}}}, CertificateHashData: []types.OCSPRequestDataType{{SerialNumber: "5e1a10", HashAlgorithm: types.SHA256, IssuerNameHash: "6ac73742db534bebccc9af1453c5637ee5bb5d7c9628ec2f26cf9777c89e96d8", IssuerKeyHash: "af316ecb91a8ee7ae99210702b2d4758f30cdde3bf61e3d8e787d74681f90a6e", ResponderURL: "www.someurl.com"}}}, true},
 		{authorization.AuthorizeRequest{IdToken: types.IdToken{IdToken: "1234", Type: types.IdTokenTypeKeyCode, AdditionalInfo: []types.AdditionalInfo{{AdditionalIdToken: "0000", Type: "someType"}}}, CertificateHashData: []types.OCSPRequestDataType{}}, true},
 		{authorization.AuthorizeRequest{IdToken: types.IdToken{IdToken: "1234", Type: types.IdTokenTypeKeyCode, AdditionalInfo: []types.AdditionalInfo{{AdditionalIdToken: "0000", Type: "someType"}}}}, true},
 		{authorization.AuthorizeRequest{}, false},
 		{authorization.AuthorizeRequest{Certificate: newLongString(5501), IdToken: types.IdToken{IdToken: "1234", Type: types.IdTokenTypeKeyCode, AdditionalInfo: []types.AdditionalInfo{{AdditionalIdToken: "0000", Type: "someType"}}}}, false},
 		{authorization.AuthorizeRequest{Certificate: "deadc0de", IdToken: types.IdToken{Type: types.IdTokenTypeKeyCode, AdditionalInfo: []types.AdditionalInfo{{AdditionalIdToken: "0000", Type: "someType"}}}}, false},
-		{authorization.AuthorizeRequest{Certificate: "deadc0de", IdToken: types.IdToken{Type: types.IdTokenTypeKeyCode, AdditionalInfo: []types.AdditionalInfo{{AdditionalIdToken: "0000", Type: "someType"}}}, CertificateHashData: []types.OCSPRequestDataType{{HashAlgorithm: types.SHA256, IssuerNameHash: "hash0", IssuerKeyHash: "hash1"}}}, false},
-		{authorization.AuthorizeRequest{Certificate: "deadc0de", IdToken: types.IdToken{Type: types.IdTokenTypeKeyCode, AdditionalInfo: []types.AdditionalInfo{{AdditionalIdToken: "0000", Type: "someType"}}}, CertificateHashData: []types.OCSPRequestDataType{{SerialNumber: "s0", HashAlgorithm: types.SHA256, IssuerNameHash: "h0", IssuerKeyHash: "h0.1"}, {SerialNumber: "s1", HashAlgorithm: types.SHA256, IssuerNameHash: "h1", IssuerKeyHash: "h1.1"}, {SerialNumber: "s2", HashAlgorithm: types.SHA256, IssuerNameHash: "h2", IssuerKeyHash: "h2.1"}, {SerialNumber: "s3", HashAlgorithm: types.SHA256, IssuerNameHash: "h3", IssuerKeyHash: "h3.1"}, {SerialNumber: "s4", HashAlgorithm: types.SHA256, IssuerNameHash: "h4", IssuerKeyHash: "h4.1"}}}, false},
+		{authorization.AuthorizeRequest{Certificate: "deadc0de", IdToken: types.IdToken{Type: types.IdTokenTypeKeyCode, AdditionalInfo: []types.AdditionalInfo{{AdditionalIdToken: "0000", Type: "someType"}}}, CertificateHashData: []types.OCSPRequestDataType{{HashAlgorithm: types.SHA256, IssuerNameHash: "6ac73742db534bebccc9af1453c5637ee5bb5d7c9628ec2f26cf9777c89e96d8", IssuerKeyHash: "af316ecb91a8ee7ae99210702b2d4758f30cdde3bf61e3d8e787d74681f90a6e"}}}, false},
+		{authorization.AuthorizeRequest{Certificate: "deadc0de", IdToken: types.IdToken{Type: types.IdTokenTypeKeyCode, AdditionalInfo: []types.AdditionalInfo{{AdditionalIdToken: "0000", Type: "someType"}}}, CertificateHashData: []types.OCSPRequestDataType{{SerialNumber: "a0", HashAlgorithm: types.SHA256, IssuerNameHash: "db87cee52504ca5732bad49ddb1d5b8551c011c1891996d58aacae47f66585ed", IssuerKeyHash: "4ce0677b082c68be54f71e33c85a3b028a7da30f35072e5d5119777632a3c0bf"}, {SerialNumber: "a1", HashAlgorithm: types.SHA256, IssuerNameHash: "33112ee14ee469c3eb52fe90322ec81dd404a0093d565a6d71ce77cbc8124e3b", IssuerKeyHash: "6860089a391bb062e9dd4f341b769d0b24ffcf87df492e9ad112774187c337a6"}, {SerialNumber: "a2", HashAlgorithm: types.SHA256, IssuerNameHash: "f998fe06afa0cfbe73e0449dc2b1698309e1b5714960f027b2858312b152c275", IssuerKeyHash: "149117b9c228f36b89644cc54fbf06ce519a60fcc49a0bd20ddf96d58d4511de"}, {SerialNumber: "a3", HashAlgorithm: types.SHA256, IssuerNameHash: "97fb5f8538b89f6c1accfd19836b65a73b61fbc2e0cbf84bb858a0fffa3f1592", IssuerKeyHash: "b6a4aaa340550d0e0cc2f7ec9c7cd0a19e6ad0e352654826b79be04e41546cb7"}, {SerialNumber: "a4", HashAlgorithm: types.SHA256, IssuerNameHash: "e9590c04cea54beb769a96148583176605389b3a3809162f2fd6392b43fb8382", IssuerKeyHash: "8a654a6a783f22a03fc536fee50e54e21386427364b844fbb796c05f0a41557a"}}}, false},
 	}
 	ExecuteGenericTestTable(t, requestTable)
 }
@@ -50,7 +50,7 @@ func (suite *OcppV2TestSuite) TestAuthorizeE2EMocked() {
 	certificate := "deadc0de"
 	additionalInfo := types.AdditionalInfo{AdditionalIdToken: "at1", Type: "some"}
 	idToken := types.IdToken{IdToken: "tok1", Type: types.IdTokenTypeKeyCode, AdditionalInfo: []types.AdditionalInfo{additionalInfo}}
-	certHashData := types.OCSPRequestDataType{HashAlgorithm: types.SHA256, IssuerNameHash: "h0", IssuerKeyHash: "h0.1", SerialNumber: "s0", ResponderURL: "http://www.test.org"}
+	certHashData := types.OCSPRequestDataType{HashAlgorithm: types.SHA256, IssuerNameHash: "db87cee52504ca5732bad49ddb1d5b8551c011c1891996d58aacae47f66585ed", IssuerKeyHash: "4ce0677b082c68be54f71e33c85a3b028a7da30f35072e5d5119777632a3c0bf", SerialNumber: "a0", ResponderURL: "http://www.test.org"}
 	status := types.AuthorizationStatusAccepted
 	certificateStatus := authorization.CertificateStatusAccepted
 	requestJson := fmt.Sprintf(`[2,"%v","%v",{"certificate":"%v","idToken":{"idToken":"%v","type":"%v","additionalInfo":[{"additionalIdToken":"%v","type":"%v"}]},"iso15118CertificateHashData":[{"hashAlgorithm":"%v","issuerNameHash":"%v","issuerKeyHash":"%v","serialNumber":"%v","responderURL":"%v"}]}]`,
@@ -101,7 +101,7 @@ func (suite *OcppV2TestSuite) TestAuthorizeInvalidEndpoint() {
 	certificate := "deadc0de"
 	additionalInfo := types.AdditionalInfo{AdditionalIdToken: "at1", Type: "some"}
 	idToken := types.IdToken{IdToken: "tok1", Type: types.IdTokenTypeKeyCode, AdditionalInfo: []types.AdditionalInfo{additionalInfo}}
-	certHashData := types.OCSPRequestDataType{HashAlgorithm: types.SHA256, IssuerNameHash: "h0", IssuerKeyHash: "h0.1", SerialNumber: "s0", ResponderURL: "http://www.test.org"}
+	certHashData := types.OCSPRequestDataType{HashAlgorithm: types.SHA256, IssuerNameHash: "db87cee52504ca5732bad49ddb1d5b8551c011c1891996d58aacae47f66585ed", IssuerKeyHash: "4ce0677b082c68be54f71e33c85a3b028a7da30f35072e5d5119777632a3c0bf", SerialNumber: "a0", ResponderURL: "http://www.test.org"}
 	authorizeRequest := authorization.NewAuthorizationRequest(idToken.IdToken, idToken.Type)
 	requestJson := fmt.Sprintf(`[2,"%v","%v",{"certificate":"%v","idToken":{"idToken":"%v","type":"%v","additionalInfo":[{"additionalIdToken":"%v","type":"%v"}]},"iso15118CertificateHashData":[{"hashAlgorithm":"%v","issuerNameHash":"%v","issuerKeyHash":"%v","serialNumber":"%v","responderURL":"%v"}]}]`,
 		messageId, authorization.AuthorizeFeatureName, certificate, idToken.IdToken, idToken.Type, additionalInfo.AdditionalIdToken, additionalInfo.Type, certHashData.HashAlgorithm, certHashData.IssuerNameHash, certHashData.IssuerKeyHash, certHashData.SerialNumber, certHashData.ResponderURL)
@@ -0,0 +1,246 @@
+package ocpp2_test
+
+import (
+	"crypto/x509"
+	"errors"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/authorization"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/localauth"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// Answers AuthorizeRequests with the configured response, or simulates an unreachable CSMS.
+type testAuthorizeSender struct {
+	response *authorization.AuthorizeResponse
+	err      error
+	delay    time.Duration
+	requests []*authorization.AuthorizeRequest
+}
+
+func (s *testAuthorizeSender) Authorize(idToken string, tokenType types.IdTokenType, props ...func(request *authorization.AuthorizeRequest)) (*authorization.AuthorizeResponse, error) {
+	request := authorization.NewAuthorizationRequest(idToken, tokenType)
+	for _, prop := range props {
+		prop(request)
+	}
+	s.requests = append(s.requests, request)
+	time.Sleep(s.delay)
+	return s.response, s.err
+}
+
+func newTestLocalList(t require.TestingT, entries ...localauth.AuthorizationData) *localauth.ListManager201 {
+	manager, err := localauth.NewListManager201(localauth.NewMemoryListStorage())
+	require.NoError(t, err)
+	request := localauth.NewSendLocalListRequest(1, localauth.UpdateTypeFull)
+	request.LocalAuthorizationList = entries
+	response, err := manager.OnSendLocalList(request)
+	require.NoError(t, err)
+	require.Equal(t, localauth.SendLocalListStatusAccepted, response.Status)
+	return manager
+}
+
+func (suite *OcppV2TestSuite) TestAuthorizerOnline() {
+	t := suite.T()
+	cache := authorization.NewMemoryCache()
+	sender := &testAuthorizeSender{response: authorization.NewAuthorizationResponse(*types.NewIdTokenInfo(types.AuthorizationStatusAccepted))}
+	authorizer := authorization.NewAuthorizer(sender, nil, cache)
+	request := authorization.NewAuthorizationRequest("0102030405", types.IdTokenTypeISO14443)
+	decision := authorizer.Authorize(request)
+	assert.True(t, decision.Accepted)
+	assert.False(t, decision.Offline)
+	assert.Equal(t, authorization.DecisionSourceCSMS, decision.Source)
+	assert.NoError(t, decision.Err)
+	// The response was cached
+	info, ok := cache.Lookup("0102030405", types.IdTokenTypeISO14443)
+	require.True(t, ok)
+	assert.Equal(t, types.AuthorizationStatusAccepted, info.Status)
+	// A rejected certificate prevents the authorization
+	fixture := newSignedCertificateFixture(t)
+	contractCertificate := fixture.chain(t, stationCertificateTemplate())
+	sender.response.CertificateStatus = authorization.CertificateStatusCertificateRevoked
+	request = authorization.NewAuthorizationRequest("DE8ACC12E46L89", types.IdTokenTypeEMAID)
+	request.Certificate = contractCertificate
+	decision = authorizer.Authorize(request)
+	assert.False(t, decision.Accepted)
+	assert.Equal(t, authorization.CertificateStatusCertificateRevoked, decision.CertificateStatus)
+	require.Len(t, sender.requests, 2)
+	assert.Equal(t, contractCertificate, sender.requests[1].Certificate)
+	assert.Equal(t, types.IdTokenTypeEMAID, sender.requests[1].IdToken.Type)
+	// Certificates are validated locally first, if configured
+	sender.response.CertificateStatus = authorization.CertificateStatusAccepted
+	authorizer.ContractRoots = fixture.roots
+	decision = authorizer.Authorize(request)
+	assert.True(t, decision.Accepted)
+	assert.Len(t, sender.requests, 3)
+	authorizer.ContractRoots = x509.NewCertPool()
+	decision = authorizer.Authorize(request)
+	assert.False(t, decision.Accepted)
+	assert.Equal(t, authorization.DecisionSourceCertificate, decision.Source)
+	assert.Equal(t, authorization.CertificateStatusCertChainError, decision.CertificateStatus)
+	assert.Equal(t, types.AuthorizationStatusInvalid, decision.IdTokenInfo.Status)
+	assert.Error(t, decision.Err)
+	authorizer.ContractRoots = fixture.roots
+	authorizer.SetTimeSource(func() time.Time { return time.Now().Add(2 * time.Hour) })
+	decision = authorizer.Authorize(request)
+	assert.Equal(t, authorization.CertificateStatusCertificateExpired, decision.CertificateStatus)
+	assert.Len(t, sender.requests, 3)
+}
+
+func (suite *OcppV2TestSuite) TestAuthorizerOffline() {
+	t := suite.T()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	group := &types.GroupIdToken{IdToken: "fleet", Type: types.IdTokenTypeCentral}
+	memberInfo := types.NewIdTokenInfo(types.AuthorizationStatusAccepted)
+	memberInfo.GroupIdToken = group
+	localList := newTestLocalList(t,
+		localauth.AuthorizationData{IdToken: types.IdToken{IdToken: "listed", Type: types.IdTokenTypeISO14443}, IdTokenInfo: types.NewIdTokenInfo(types.AuthorizationStatusAccepted)},
+		localauth.AuthorizationData{IdToken: types.IdToken{IdToken: "blocked", Type: types.IdTokenTypeISO14443}, IdTokenInfo: types.NewIdTokenInfo(types.AuthorizationStatusBlocked)},
+		localauth.AuthorizationData{IdToken: types.IdToken{IdToken: "member", Type: types.IdTokenTypeISO14443}, IdTokenInfo: memberInfo},
+		localauth.AuthorizationData{IdToken: types.IdToken{IdToken: "fleet", Type: types.IdTokenTypeCentral}, IdTokenInfo: types.NewIdTokenInfo(types.AuthorizationStatusBlocked)},
+	)
+	cache := authorization.NewMemoryCache()
+	cache.SetTimeSource(func() time.Time { return now })
+	require.NoError(t, cache.Store(types.IdToken{IdToken: "blocked", Type: types.IdTokenTypeISO14443}, *types.NewIdTokenInfo(types.AuthorizationStatusAccepted)))
+	require.NoError(t, cache.Store(types.IdToken{IdToken: "cached", Type: types.IdTokenTypeISO14443}, *types.NewIdTokenInfo(types.AuthorizationStatusAccepted)))
+	expiredInfo := types.NewIdTokenInfo(types.AuthorizationStatusAccepted)
+	expiredInfo.CacheExpiryDateTime = types.NewDateTime(now.Add(time.Hour))
+	require.NoError(t, cache.Store(types.IdToken{IdToken: "expiring", Type: types.IdTokenTypeISO14443}, *expiredInfo))
+	sender := &testAuthorizeSender{err: errors.New("not connected")}
+	authorizer := authorization.NewAuthorizer(sender, localList, cache)
+	authorizer.SetTimeSource(func() time.Time { return now.Add(2 * time.Hour) })
+	authorizer.LocalAuthorizeOffline = true
+	decide := func(idToken string) authorization.Decision {
+		return authorizer.Authorize(authorization.NewAuthorizationRequest(idToken, types.IdTokenTypeISO14443))
+	}
+	// Local list
+	decision := decide("listed")
+	assert.True(t, decision.Accepted)
+	assert.True(t, decision.Offline)
+	assert.Equal(t, authorization.DecisionSourceLocalList, decision.Source)
+	assert.EqualError(t, decision.Err, "not connected")
+	// The blocked entry of the local list overrides the cached accept
+	decision = decide("blocked")
+	assert.False(t, decision.Accepted)
+	assert.Equal(t, authorization.DecisionSourceLocalList, decision.Source)
+	assert.Equal(t, types.AuthorizationStatusBlocked, decision.IdTokenInfo.Status)
+	// Cache
+	decision = decide("cached")
+	assert.True(t, decision.Accepted)
+	assert.Equal(t, authorization.DecisionSourceCache, decision.Source)
+	// A blocked group overrides the accepted member
+	decision = decide("member")
+	assert.False(t, decision.Accepted)
+	assert.Equal(t, types.AuthorizationStatusBlocked, decision.IdTokenInfo.Status)
+	assert.Equal(t, group, decision.IdTokenInfo.GroupIdToken)
+	// Unknown and expired idTokens
+	for _, idToken := range []string{"unknown", "expiring"} {
+		decision = decide(idToken)
+		assert.False(t, decision.Accepted)
+		assert.Equal(t, authorization.DecisionSourceOfflinePolicy, decision.Source)
+		assert.Equal(t, types.AuthorizationStatusUnknown, decision.IdTokenInfo.Status)
+	}
+	authorizer.OfflineTxForUnknownIdEnabled = true
+	decision = decide("unknown")
+	assert.True(t, decision.Accepted)
+	assert.Equal(t, authorization.DecisionSourceOfflinePolicy, decision.Source)
+	// Without LocalAuthorizeOffline, all idTokens are unknown
+	authorizer.LocalAuthorizeOffline = false
+	authorizer.OfflineTxForUnknownIdEnabled = false
+	decision = decide("listed")
+	assert.False(t, decision.Accepted)
+	assert.Equal(t, authorization.DecisionSourceOfflinePolicy, decision.Source)
+	// An unanswered request is treated as offline
+	sender.err = nil
+	sender.response = authorization.NewAuthorizationResponse(*types.NewIdTokenInfo(types.AuthorizationStatusAccepted))
+	sender.delay = 100 * time.Millisecond
+	authorizer.Timeout = 10 * time.Millisecond
+	authorizer.LocalAuthorizeOffline = true
+	decision = decide("blocked")
+	assert.True(t, decision.Offline)
+	assert.ErrorIs(t, decision.Err, authorization.ErrAuthorizeTimeout)
+	assert.False(t, decision.Accepted)
+}
+
+func (suite *OcppV2TestSuite) TestSameGroup201() {
+	t := suite.T()
+	withGroup := func(group *types.GroupIdToken) *types.IdTokenInfo {
+		info := types.NewIdTokenInfo(types.AuthorizationStatusAccepted)
+		info.GroupIdToken = group
+		return info
+	}
+	candidate := types.IdToken{IdToken: "0A0B0C0D", Type: types.IdTokenTypeISO14443}
+	var testTable = []struct {
+		name          string
+		start         *types.IdTokenInfo
+		candidateInfo *types.IdTokenInfo
+		expected      bool
+	}{
+		{"same group", withGroup(&types.GroupIdToken{IdToken: "fleet", Type: types.IdTokenTypeCentral}), withGroup(&types.GroupIdToken{IdToken: "fleet", Type: types.IdTokenTypeCentral}), true},
+		{"hex UID case difference", withGroup(&types.GroupIdToken{IdToken: "04a2b3c4", Type: types.IdTokenTypeISO14443}), withGroup(&types.GroupIdToken{IdToken: "04A2B3C4", Type: types.IdTokenTypeISO14443}), true},
+		{"eMAID notation difference", withGroup(&types.GroupIdToken{IdToken: "DE-8AC-C12E46L89", Type: types.IdTokenTypeEMAID}), withGroup(&types.GroupIdToken{IdToken: "de8acc12e46l89", Type: types.IdTokenTypeEMAID}), true},
+		{"case difference of central tokens", withGroup(&types.GroupIdToken{IdToken: "fleet", Type: types.IdTokenTypeCentral}), withGroup(&types.GroupIdToken{IdToken: "FLEET", Type: types.IdTokenTypeCentral}), false},
+		{"type mismatch", withGroup(&types.GroupIdToken{IdToken: "04A2B3C4", Type: types.IdTokenTypeISO14443}), withGroup(&types.GroupIdToken{IdToken: "04A2B3C4", Type: types.IdTokenTypeCentral}), false},
+		{"different group", withGroup(&types.GroupIdToken{IdToken: "fleet", Type: types.IdTokenTypeCentral}), withGroup(&types.GroupIdToken{IdToken: "other", Type: types.IdTokenTypeCentral}), false},
+		{"group only on start", withGroup(&types.GroupIdToken{IdToken: "fleet", Type: types.IdTokenTypeCentral}), withGroup(nil), false},
+		{"group only on candidate", withGroup(nil), withGroup(&types.GroupIdToken{IdToken: "fleet", Type: types.IdTokenTypeCentral}), false},
+		{"no groups", withGroup(nil), withGroup(nil), false},
+		{"missing start info", nil, withGroup(&types.GroupIdToken{IdToken: "fleet", Type: types.IdTokenTypeCentral}), false},
+		{"missing candidate info", withGroup(&types.GroupIdToken{IdToken: "fleet", Type: types.IdTokenTypeCentral}), nil, false},
+	}
+	for _, tc := range testTable {
+		assert.Equal(t, tc.expected, authorization.SameGroup201(tc.start, candidate, tc.candidateInfo), tc.name)
+	}
+}
+
+func (suite *OcppV2TestSuite) TestAuthorizerStopByGroupMember() {
+	t := suite.T()
+	group := &types.GroupIdToken{IdToken: "fleet", Type: types.IdTokenTypeCentral}
+	memberInfo := types.NewIdTokenInfo(types.AuthorizationStatusAccepted)
+	memberInfo.GroupIdToken = group
+	otherInfo := types.NewIdTokenInfo(types.AuthorizationStatusAccepted)
+	otherInfo.GroupIdToken = &types.GroupIdToken{IdToken: "other", Type: types.IdTokenTypeCentral}
+	cache := authorization.NewMemoryCache()
+	require.NoError(t, cache.Store(types.IdToken{IdToken: "0A0B0C0D", Type: types.IdTokenTypeISO14443}, *memberInfo))
+	require.NoError(t, cache.Store(types.IdToken{IdToken: "0E0F0A0B", Type: types.IdTokenTypeISO14443}, *otherInfo))
+	sender := &testAuthorizeSender{err: errors.New("not connected")}
+	authorizer := authorization.NewAuthorizer(sender, nil, cache)
+	authorizer.LocalAuthorizeOffline = true
+	authorizer.OfflineTxForUnknownIdEnabled = true
+	start := types.IdToken{IdToken: "0102030A", Type: types.IdTokenTypeISO14443}
+	startInfo := types.NewIdTokenInfo(types.AuthorizationStatusAccepted)
+	startInfo.GroupIdToken = &types.GroupIdToken{IdToken: "fleet", Type: types.IdTokenTypeCentral}
+	stop := func(idToken string) authorization.Decision {
+		return authorizer.AuthorizeStop(start, startInfo, types.IdToken{IdToken: idToken, Type: types.IdTokenTypeISO14443})
+	}
+	// The idToken which started the transaction, in a different notation
+	decision := stop("0102030a")
+	assert.True(t, decision.Accepted)
+	assert.Equal(t, authorization.DecisionSourceTransaction, decision.Source)
+	decision = authorizer.AuthorizeStop(start, startInfo, types.IdToken{IdToken: "0102030A", Type: types.IdTokenTypeISO15693})
+	assert.NotEqual(t, authorization.DecisionSourceTransaction, decision.Source)
+	// A cached group member is accepted offline
+	decision = stop("0A0B0C0D")
+	assert.True(t, decision.Accepted)
+	assert.True(t, decision.Offline)
+	assert.Equal(t, authorization.DecisionSourceCache, decision.Source)
+	// Members of other groups and unknown idTokens are rejected, regardless of the offline policy
+	for _, idToken := range []string{"0E0F0A0B", "0C0C0C0C"} {
+		decision = stop(idToken)
+		assert.False(t, decision.Accepted, idToken)
+	}
+	// Without a group, only the idToken which started the transaction may stop it
+	decision = authorizer.AuthorizeStop(start, nil, types.IdToken{IdToken: "0A0B0C0D", Type: types.IdTokenTypeISO14443})
+	assert.False(t, decision.Accepted)
+	// Online, the group returned by the CSMS is compared
+	sender.err = nil
+	sender.response = authorization.NewAuthorizationResponse(*otherInfo)
+	decision = stop("0A0B0C0D")
+	assert.False(t, decision.Accepted)
+	assert.Equal(t, authorization.DecisionSourceCSMS, decision.Source)
+	sender.response = authorization.NewAuthorizationResponse(*memberInfo)
+	decision = stop("0E0F0A0B")
+	assert.True(t, decision.Accepted)
+}
@@ -0,0 +1,135 @@
+package ocpp2_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/availability"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/remotecontrol"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+func (suite *OcppV2TestSuite) TestAutoResponderRegisteredTrigger() {
+	t := suite.T()
+	responder := remotecontrol.NewAutoResponder201(map[int]int{1: 1})
+	producedC := make(chan *types.EVSE, 1)
+	responder.Register(remotecontrol.MessageTriggerMeterValues, func(evse *types.EVSE) error {
+		producedC <- evse
+		return nil
+	})
+	request := remotecontrol.NewTriggerMessageRequest(remotecontrol.MessageTriggerMeterValues)
+	request.Evse = &types.EVSE{ID: 1}
+	response, err := responder.OnTriggerMessage(request)
+	require.NoError(t, err)
+	assert.Equal(t, remotecontrol.TriggerMessageStatusAccepted, response.Status)
+	select {
+	case evse := <-producedC:
+		require.NotNil(t, evse)
+		assert.Equal(t, 1, evse.ID)
+	case <-time.After(time.Second):
+		require.Fail(t, "triggered message wasn't produced")
+	}
+	// Unknown EVSE or connector
+	request.Evse = &types.EVSE{ID: 2}
+	response, err = responder.OnTriggerMessage(request)
+	require.NoError(t, err)
+	assert.Equal(t, remotecontrol.TriggerMessageStatusRejected, response.Status)
+	require.NotNil(t, response.StatusInfo)
+	assert.Equal(t, "UnknownEvse", response.StatusInfo.ReasonCode)
+	request.Evse = &types.EVSE{ID: 1, ConnectorID: newInt(2)}
+	response, err = responder.OnTriggerMessage(request)
+	require.NoError(t, err)
+	assert.Equal(t, remotecontrol.TriggerMessageStatusRejected, response.Status)
+	assert.Empty(t, producedC)
+	// Failures are reported
+	errC := make(chan error, 1)
+	responder.OnSendError = func(trigger remotecontrol.MessageTrigger, err error) {
+		assert.Equal(t, remotecontrol.MessageTriggerHeartbeat, trigger)
+		errC <- err
+	}
+	responder.Register(remotecontrol.MessageTriggerHeartbeat, func(evse *types.EVSE) error {
+		assert.Nil(t, evse)
+		return errors.New("not connected")
+	})
+	response, err = responder.OnTriggerMessage(remotecontrol.NewTriggerMessageRequest(remotecontrol.MessageTriggerHeartbeat))
+	require.NoError(t, err)
+	assert.Equal(t, remotecontrol.TriggerMessageStatusAccepted, response.Status)
+	assert.EqualError(t, <-errC, "not connected")
+}
+
+func (suite *OcppV2TestSuite) TestAutoResponderUnregisteredTrigger() {
+	t := suite.T()
+	responder := remotecontrol.NewAutoResponder201(nil)
+	response, err := responder.OnTriggerMessage(remotecontrol.NewTriggerMessageRequest(remotecontrol.MessageTriggerFirmwareStatusNotification))
+	require.NoError(t, err)
+	assert.Equal(t, remotecontrol.TriggerMessageStatusNotImplemented, response.Status)
+	responder.Register(remotecontrol.MessageTriggerFirmwareStatusNotification, func(evse *types.EVSE) error { return nil })
+	responder.Unregister(remotecontrol.MessageTriggerFirmwareStatusNotification)
+	response, err = responder.OnTriggerMessage(remotecontrol.NewTriggerMessageRequest(remotecontrol.MessageTriggerFirmwareStatusNotification))
+	require.NoError(t, err)
+	assert.Equal(t, remotecontrol.TriggerMessageStatusNotImplemented, response.Status)
+}
+
+func (suite *OcppV2TestSuite) TestAutoResponderStatusNotification() {
+	t := suite.T()
+	sentC := make(chan *availability.StatusNotificationRequest, 4)
+	stationManager := availability.NewStationManager(nil, func(request *availability.StatusNotificationRequest) error {
+		sentC <- request
+		return nil
+	})
+	stationManager.SetConnectorStatus(1, 1, availability.ConnectorStatusAvailable)
+	stationManager.SetConnectorStatus(2, 1, availability.ConnectorStatusOccupied)
+	stationManager.SetConnectorStatus(2, 2, availability.ConnectorStatusFaulted)
+	for i := 0; i < 3; i++ {
+		<-sentC
+	}
+	bootManager := provisioning.NewBootManager(provisioning.ChargingStationType{Model: "model1", VendorName: "vendor1"}, func(request *provisioning.BootNotificationRequest) (*provisioning.BootNotificationResponse, error) {
+		assert.Equal(t, provisioning.BootReasonTriggered, request.Reason)
+		return provisioning.NewBootNotificationResponse(types.NewDateTime(time.Now()), 60, provisioning.RegistrationStatusAccepted), nil
+	})
+	responder := remotecontrol.NewAutoResponder201(nil)
+	responder.UseStationManager(stationManager)
+	responder.UseBootManager(bootManager)
+	// Only the current status of the requested connector is reported, although it didn't change
+	request := remotecontrol.NewTriggerMessageRequest(remotecontrol.MessageTriggerStatusNotification)
+	request.Evse = &types.EVSE{ID: 2, ConnectorID: newInt(2)}
+	response, err := responder.OnTriggerMessage(request)
+	require.NoError(t, err)
+	assert.Equal(t, remotecontrol.TriggerMessageStatusAccepted, response.Status)
+	sent := <-sentC
+	assert.Equal(t, 2, sent.EvseID)
+	assert.Equal(t, 2, sent.ConnectorID)
+	assert.Equal(t, availability.ConnectorStatusFaulted, sent.ConnectorStatus)
+	// All connectors of an EVSE
+	request.Evse = &types.EVSE{ID: 2}
+	_, err = responder.OnTriggerMessage(request)
+	require.NoError(t, err)
+	assert.Equal(t, 1, (<-sentC).ConnectorID)
+	assert.Equal(t, 2, (<-sentC).ConnectorID)
+	// EVSEs are validated against the connectors known to the manager
+	request.Evse = &types.EVSE{ID: 3}
+	response, err = responder.OnTriggerMessage(request)
+	require.NoError(t, err)
+	assert.Equal(t, remotecontrol.TriggerMessageStatusRejected, response.Status)
+	assert.Equal(t, "UnknownEvse", response.StatusInfo.ReasonCode)
+	request.Evse = &types.EVSE{ID: 1, ConnectorID: newInt(2)}
+	response, err = responder.OnTriggerMessage(request)
+	require.NoError(t, err)
+	assert.Equal(t, remotecontrol.TriggerMessageStatusRejected, response.Status)
+	assert.Equal(t, "UnknownConnectorId", response.StatusInfo.ReasonCode)
+	// A BootNotification may be triggered until the station was accepted
+	response, err = responder.OnTriggerMessage(remotecontrol.NewTriggerMessageRequest(remotecontrol.MessageTriggerBootNotification))
+	require.NoError(t, err)
+	assert.Equal(t, remotecontrol.TriggerMessageStatusAccepted, response.Status)
+	assert.Eventually(t, func() bool {
+		return bootManager.Status() == provisioning.RegistrationStatusAccepted
+	}, time.Second, 10*time.Millisecond)
+	response, err = responder.OnTriggerMessage(remotecontrol.NewTriggerMessageRequest(remotecontrol.MessageTriggerBootNotification))
+	require.NoError(t, err)
+	assert.Equal(t, remotecontrol.TriggerMessageStatusRejected, response.Status)
+	assert.Empty(t, sentC)
+}
@@ -0,0 +1,183 @@
+package ocpp2_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	ocpp2 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+	"github.com/lorenzodonini/ocpp-go/ws"
+)
+
+const basicAuthRotationPort = 8895
+
+// basicAuthRecorder checks the credentials of connecting charging stations against a single password,
+// recording every attempt.
+type basicAuthRecorder struct {
+	mutex     sync.Mutex
+	password  string
+	passwords []string
+}
+
+func (r *basicAuthRecorder) check(username string, password string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.passwords = append(r.passwords, password)
+	return password == r.password
+}
+
+func (r *basicAuthRecorder) setPassword(password string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.password = password
+}
+
+func (r *basicAuthRecorder) lastPassword() string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.passwords[len(r.passwords)-1]
+}
+
+func (suite *OcppV2TestSuite) TestValidateBasicAuthPassword() {
+	t := suite.T()
+	var testTable = []struct {
+		password string
+		valid    bool
+	}{
+		{"0123456789abcdef", true},
+		{"0123456789abcdef0123456789abcdef01234567", true},
+		{"!#$%&'()*+,-./;<=>?@[]^_`{|}~ Ab1", true},
+		{"0123456789abcde", false},
+		{"0123456789abcdef0123456789abcdef012345678", false},
+		{"0123456789:abcdef", false},
+		{"0123456789\tabcdef", false},
+		{"0123456789äbcdefg", false},
+		{"", false},
+	}
+	for _, entry := range testTable {
+		err := provisioning.ValidateBasicAuthPassword(entry.password)
+		if entry.valid {
+			assert.NoError(t, err, entry.password)
+		} else {
+			assert.ErrorIs(t, err, provisioning.ErrInvalidBasicAuthPassword, entry.password)
+		}
+	}
+	assert.True(t, provisioning.IsBasicAuthPassword(types.Component{Name: "SecurityCtrlr"}, types.Variable{Name: "BasicAuthPassword"}, ""))
+	assert.True(t, provisioning.IsBasicAuthPassword(types.Component{Name: "securityctrlr"}, types.Variable{Name: "basicauthpassword"}, types.AttributeActual))
+	assert.False(t, provisioning.IsBasicAuthPassword(types.Component{Name: "SecurityCtrlr"}, types.Variable{Name: "BasicAuthPassword"}, types.AttributeTarget))
+	assert.False(t, provisioning.IsBasicAuthPassword(types.Component{Name: "SecurityCtrlr"}, types.Variable{Name: "Identity"}, ""))
+}
+
+func (suite *OcppV2TestSuite) TestRotateBasicAuthPassword() {
+	t := suite.T()
+	stationID := "station1"
+	oldPassword := "0123456789abcdef"
+	newPassword := "fedcba9876543210-new"
+	// CSMS, checking basic auth credentials
+	auth := &basicAuthRecorder{password: oldPassword}
+	wsServer := ws.NewServer()
+	wsServer.SetBasicAuthHandler(auth.check)
+	csms := ocpp2.NewCSMS(nil, wsServer)
+	connectedC := make(chan string, 2)
+	csms.SetNewChargingStationHandler(func(chargingStation ocpp2.ChargingStationConnection) {
+		connectedC <- chargingStation.ID()
+	})
+	go csms.Start(basicAuthRotationPort, "/{ws}")
+	defer csms.Stop()
+	// Charging station, reconnecting quickly
+	wsClient := ws.NewClient()
+	timeoutConfig := ws.NewClientTimeoutConfig()
+	timeoutConfig.RetryBackOffWaitMinimum = 50 * time.Millisecond
+	timeoutConfig.RetryBackOffRandomRange = 0
+	wsClient.SetTimeoutConfig(timeoutConfig)
+	wsClient.SetBasicAuth(stationID, oldPassword)
+	station := ocpp2.NewChargingStation(stationID, nil, wsClient)
+	handler := &MockChargingStationProvisioningHandler{}
+	handler.On("OnSetVariables", mock.Anything).Return(provisioning.NewSetVariablesResponse([]provisioning.SetVariableResult{
+		{AttributeStatus: provisioning.SetVariableStatusAccepted, Component: types.Component{Name: "SecurityCtrlr"}, Variable: types.Variable{Name: "BasicAuthPassword"}},
+	}), nil)
+	station.SetProvisioningHandler(handler)
+	persistedC := make(chan string, 1)
+	station.EnableBasicAuthPasswordRotation(func(password string) error {
+		persistedC <- password
+		return nil
+	})
+	url := fmt.Sprintf("ws://localhost:%v", basicAuthRotationPort)
+	require.Eventually(t, func() bool {
+		return station.Start(url) == nil
+	}, 5*time.Second, 50*time.Millisecond)
+	defer station.Stop()
+	require.Equal(t, stationID, <-connectedC)
+	assert.Equal(t, oldPassword, auth.lastPassword())
+	// Invalid passwords are rejected by the CSMS before sending
+	err := csms.RotateBasicAuthPassword(stationID, func(response *provisioning.SetVariablesResponse, err error) {}, "too short")
+	assert.ErrorIs(t, err, provisioning.ErrInvalidBasicAuthPassword)
+	// Invalid passwords are rejected by the charging station without invoking the handler
+	responseC := make(chan *provisioning.SetVariablesResponse, 1)
+	err = csms.SetVariables(stationID, func(response *provisioning.SetVariablesResponse, err error) {
+		require.NoError(t, err)
+		responseC <- response
+	}, []provisioning.SetVariableData{provisioning.NewBasicAuthPasswordData("invalid:password:value")})
+	require.NoError(t, err)
+	response := <-responseC
+	require.Len(t, response.SetVariableResult, 1)
+	assert.Equal(t, provisioning.SetVariableStatusRejected, response.SetVariableResult[0].AttributeStatus)
+	handler.AssertNotCalled(t, "OnSetVariables", mock.Anything)
+	// Valid passwords are accepted, persisted and used for reconnecting
+	err = csms.RotateBasicAuthPassword(stationID, func(response *provisioning.SetVariablesResponse, err error) {
+		require.NoError(t, err)
+		if response.SetVariableResult[0].AttributeStatus == provisioning.SetVariableStatusAccepted {
+			auth.setPassword(newPassword)
+		}
+		responseC <- response
+	}, newPassword)
+	require.NoError(t, err)
+	response = <-responseC
+	require.Len(t, response.SetVariableResult, 1)
+	assert.Equal(t, provisioning.SetVariableStatusAccepted, response.SetVariableResult[0].AttributeStatus)
+	assert.Equal(t, newPassword, <-persistedC)
+	select {
+	case id := <-connectedC:
+		assert.Equal(t, stationID, id)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "charging station didn't reconnect")
+	}
+	assert.Equal(t, newPassword, auth.lastPassword())
+	assert.Eventually(t, station.IsConnected, time.Second, 10*time.Millisecond)
+}
+
+func (suite *OcppV2TestSuite) TestRotateBasicAuthPasswordPersistFailure() {
+	t := suite.T()
+	wsId := "test_id"
+	channel := NewMockWebSocket(wsId)
+	handler := &MockChargingStationProvisioningHandler{}
+	handler.On("OnSetVariables", mock.Anything).Return(provisioning.NewSetVariablesResponse([]provisioning.SetVariableResult{
+		{AttributeStatus: provisioning.SetVariableStatusAccepted, Component: types.Component{Name: "SecurityCtrlr"}, Variable: types.Variable{Name: "BasicAuthPassword"}},
+	}), nil)
+	setupDefaultCSMSHandlers(suite, expectedCSMSOptions{clientId: wsId, forwardWrittenMessage: true})
+	setupDefaultChargingStationHandlers(suite, expectedChargingStationOptions{serverUrl: "someUrl", clientId: wsId, createChannelOnStart: true, channel: channel, forwardWrittenMessage: true}, handler)
+	suite.chargingStation.EnableBasicAuthPasswordRotation(func(password string) error {
+		return errors.New("storage unavailable")
+	})
+	suite.csms.Start(8887, "somePath")
+	err := suite.chargingStation.Start("someUrl")
+	require.NoError(t, err)
+	resultC := make(chan *provisioning.SetVariablesResponse, 1)
+	err = suite.csms.RotateBasicAuthPassword(wsId, func(response *provisioning.SetVariablesResponse, err error) {
+		require.NoError(t, err)
+		resultC <- response
+	}, "0123456789abcdef")
+	require.NoError(t, err)
+	response := <-resultC
+	require.Len(t, response.SetVariableResult, 1)
+	assert.Equal(t, provisioning.SetVariableStatusRejected, response.SetVariableResult[0].AttributeStatus)
+	require.NotNil(t, response.SetVariableResult[0].StatusInfo)
+	assert.Equal(t, "InternalError", response.SetVariableResult[0].StatusInfo.ReasonCode)
+}
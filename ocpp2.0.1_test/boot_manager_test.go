@@ -0,0 +1,200 @@
+package ocpp2_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/events"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/availability"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/remotecontrol"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+type bootStatusChange struct {
+	status   provisioning.RegistrationStatus
+	interval time.Duration
+}
+
+var testBootChargingStation = provisioning.ChargingStationType{Model: "model1", VendorName: "vendor1"}
+
+func (suite *OcppV2TestSuite) TestBootManagerPendingGate() {
+	t := suite.T()
+	wsId := "test_id"
+	wsUrl := "someUrl"
+	channel := NewMockWebSocket(wsId)
+	clock := &fakeCostClock{now: time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)}
+	currentTime := types.NewDateTime(clock.Now())
+	var bootManager *provisioning.BootManager
+	// CSMS keeps the charging station pending, until the second boot notification
+	csmsProvisioningHandler := &MockCSMSProvisioningHandler{}
+	csmsProvisioningHandler.On("OnBootNotification", mock.AnythingOfType("string"), mock.Anything).Return(provisioning.NewBootNotificationResponse(currentTime, 30, provisioning.RegistrationStatusPending), nil).Once()
+	csmsProvisioningHandler.On("OnBootNotification", mock.AnythingOfType("string"), mock.Anything).Return(provisioning.NewBootNotificationResponse(currentTime, 300, provisioning.RegistrationStatusAccepted), nil).Once()
+	csmsAvailabilityHandler := &MockCSMSAvailabilityHandler{}
+	csmsAvailabilityHandler.On("OnHeartbeat", mock.AnythingOfType("string"), mock.Anything).Return(availability.NewHeartbeatResponse(*currentTime), nil)
+	csmsAvailabilityHandler.On("OnStatusNotification", mock.AnythingOfType("string"), mock.Anything).Return(availability.NewStatusNotificationResponse(), nil)
+	// The charging station answers CSMS requests while pending
+	provisioningHandler := &MockChargingStationProvisioningHandler{}
+	provisioningHandler.On("OnSetVariables", mock.Anything).Return(provisioning.NewSetVariablesResponse([]provisioning.SetVariableResult{
+		{AttributeStatus: provisioning.SetVariableStatusAccepted, Component: types.Component{Name: "OCPPCommCtrlr"}, Variable: types.Variable{Name: "HeartbeatInterval"}},
+	}), nil)
+	remoteControlHandler := &MockChargingStationRemoteControlHandler{}
+	remoteControlHandler.On("OnTriggerMessage", mock.Anything).Return(remotecontrol.NewTriggerMessageResponse(remotecontrol.TriggerMessageStatusAccepted), nil).Run(func(args mock.Arguments) {
+		request := args.Get(0).(*remotecontrol.TriggerMessageRequest)
+		bootManager.Solicit(string(request.RequestedMessage))
+	})
+	setupDefaultCSMSHandlers(suite, expectedCSMSOptions{clientId: wsId, forwardWrittenMessage: true}, csmsProvisioningHandler, csmsAvailabilityHandler)
+	setupDefaultChargingStationHandlers(suite, expectedChargingStationOptions{serverUrl: wsUrl, clientId: wsId, createChannelOnStart: true, channel: channel, forwardWrittenMessage: true}, provisioningHandler, remoteControlHandler)
+	bootManager = provisioning.NewBootManager(testBootChargingStation, func(request *provisioning.BootNotificationRequest) (*provisioning.BootNotificationResponse, error) {
+		return suite.chargingStation.BootNotification(request.Reason, request.ChargingStation.Model, request.ChargingStation.VendorName)
+	})
+	bootManager.SetTimeSource(clock.Now)
+	var statusChanges []bootStatusChange
+	bootManager.OnStatusChanged = func(status provisioning.RegistrationStatus, interval time.Duration) {
+		statusChanges = append(statusChanges, bootStatusChange{status: status, interval: interval})
+	}
+	suite.ocppjClient.SetRequestFilter(bootManager.Filter)
+	// Run Test
+	suite.csms.Start(8887, "somePath")
+	err := suite.chargingStation.Start(wsUrl)
+	require.Nil(t, err)
+	// Nothing but the boot notification may be sent initially
+	_, err = suite.chargingStation.Heartbeat()
+	assert.ErrorIs(t, err, provisioning.ErrNotAcceptedYet)
+	status, err := bootManager.Boot(provisioning.BootReasonPowerUp)
+	require.NoError(t, err)
+	assert.Equal(t, provisioning.RegistrationStatusPending, status)
+	// CSMS requests are answered while pending
+	resultC := make(chan error, 1)
+	err = suite.csms.SetVariables(wsId, func(response *provisioning.SetVariablesResponse, err error) {
+		resultC <- err
+	}, []provisioning.SetVariableData{{Component: types.Component{Name: "OCPPCommCtrlr"}, Variable: types.Variable{Name: "HeartbeatInterval"}, AttributeValue: "60"}})
+	require.NoError(t, err)
+	require.NoError(t, <-resultC)
+	provisioningHandler.AssertCalled(t, "OnSetVariables", mock.Anything)
+	// Unsolicited requests are rejected
+	_, err = suite.chargingStation.Heartbeat()
+	assert.ErrorIs(t, err, provisioning.ErrNotAcceptedYet)
+	_, err = suite.chargingStation.StatusNotification(currentTime, availability.ConnectorStatusAvailable, 1, 1)
+	assert.ErrorIs(t, err, provisioning.ErrNotAcceptedYet)
+	// Triggered requests are sent once
+	triggerC := make(chan error, 1)
+	err = suite.csms.TriggerMessage(wsId, func(response *remotecontrol.TriggerMessageResponse, err error) {
+		triggerC <- err
+	}, remotecontrol.MessageTriggerStatusNotification)
+	require.NoError(t, err)
+	require.NoError(t, <-triggerC)
+	_, err = suite.chargingStation.StatusNotification(currentTime, availability.ConnectorStatusAvailable, 1, 1)
+	assert.NoError(t, err)
+	_, err = suite.chargingStation.StatusNotification(currentTime, availability.ConnectorStatusAvailable, 1, 1)
+	assert.ErrorIs(t, err, provisioning.ErrNotAcceptedYet)
+	csmsAvailabilityHandler.AssertNumberOfCalls(t, "OnHeartbeat", 0)
+	csmsAvailabilityHandler.AssertNumberOfCalls(t, "OnStatusNotification", 1)
+	// The boot notification is retried after the returned interval
+	clock.Advance(29 * time.Second)
+	bootManager.Tick()
+	csmsProvisioningHandler.AssertNumberOfCalls(t, "OnBootNotification", 1)
+	clock.Advance(time.Second)
+	bootManager.Tick()
+	csmsProvisioningHandler.AssertNumberOfCalls(t, "OnBootNotification", 2)
+	assert.Equal(t, provisioning.RegistrationStatusAccepted, bootManager.Status())
+	_, ok := bootManager.NextBoot()
+	assert.False(t, ok)
+	// The gate is lifted once accepted
+	_, err = suite.chargingStation.Heartbeat()
+	assert.NoError(t, err)
+	csmsAvailabilityHandler.AssertNumberOfCalls(t, "OnHeartbeat", 1)
+	assert.Equal(t, []bootStatusChange{
+		{status: provisioning.RegistrationStatusPending, interval: 30 * time.Second},
+		{status: provisioning.RegistrationStatusAccepted, interval: 300 * time.Second},
+	}, statusChanges)
+}
+
+func (suite *OcppV2TestSuite) TestBootManagerRejectedBackoff() {
+	t := suite.T()
+	clock := &fakeCostClock{now: time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)}
+	var sent []*provisioning.BootNotificationRequest
+	responses := []*provisioning.BootNotificationResponse{
+		provisioning.NewBootNotificationResponse(types.NewDateTime(clock.Now()), 60, provisioning.RegistrationStatusRejected),
+		nil,
+		provisioning.NewBootNotificationResponse(types.NewDateTime(clock.Now()), 0, provisioning.RegistrationStatusAccepted),
+	}
+	var bootManager *provisioning.BootManager
+	bootManager = provisioning.NewBootManager(testBootChargingStation, func(request *provisioning.BootNotificationRequest) (*provisioning.BootNotificationResponse, error) {
+		// Mimics the ocppj client, which applies the filter before sending
+		if err := bootManager.Filter(request); err != nil {
+			return nil, err
+		}
+		sent = append(sent, request)
+		response := responses[0]
+		responses = responses[1:]
+		if response == nil {
+			return nil, errors.New("connection lost")
+		}
+		return response, nil
+	})
+	bootManager.SetTimeSource(clock.Now)
+	var sendErrors []error
+	bootManager.OnSendError = func(err error) {
+		sendErrors = append(sendErrors, err)
+	}
+	status, err := bootManager.Boot(provisioning.BootReasonWatchdog)
+	require.NoError(t, err)
+	assert.Equal(t, provisioning.RegistrationStatusRejected, status)
+	next, ok := bootManager.NextBoot()
+	require.True(t, ok)
+	assert.Equal(t, clock.Now().Add(time.Minute), next)
+	// No requests at all are permitted before the retry interval elapsed, not even solicited ones
+	bootManager.Solicit(availability.StatusNotificationFeatureName)
+	assert.ErrorIs(t, bootManager.Filter(availability.NewStatusNotificationRequest(types.NewDateTime(clock.Now()), availability.ConnectorStatusAvailable, 1, 1)), provisioning.ErrNotAcceptedYet)
+	_, err = bootManager.Boot(provisioning.BootReasonWatchdog)
+	assert.ErrorIs(t, err, provisioning.ErrNotAcceptedYet)
+	clock.Advance(59 * time.Second)
+	bootManager.Tick()
+	assert.Len(t, sent, 1)
+	// A failed retry is rescheduled with the default interval
+	clock.Advance(time.Second)
+	bootManager.Tick()
+	require.Len(t, sent, 2)
+	assert.Equal(t, provisioning.BootReasonWatchdog, sent[1].Reason)
+	assert.Equal(t, testBootChargingStation, sent[1].ChargingStation)
+	assert.Len(t, sendErrors, 2)
+	assert.Equal(t, provisioning.RegistrationStatusRejected, bootManager.Status())
+	next, ok = bootManager.NextBoot()
+	require.True(t, ok)
+	assert.Equal(t, clock.Now().Add(provisioning.DefaultBootRetryInterval), next)
+	clock.Advance(provisioning.DefaultBootRetryInterval)
+	bootManager.Tick()
+	assert.Len(t, sent, 3)
+	assert.Equal(t, provisioning.RegistrationStatusAccepted, bootManager.Status())
+	assert.NoError(t, bootManager.Filter(availability.NewHeartbeatRequest()))
+}
+
+func (suite *OcppV2TestSuite) TestBootManagerAcceptedEvent() {
+	t := suite.T()
+	responses := []*provisioning.BootNotificationResponse{
+		provisioning.NewBootNotificationResponse(types.Now(), 30, provisioning.RegistrationStatusPending),
+		provisioning.NewBootNotificationResponse(types.Now(), 300, provisioning.RegistrationStatusAccepted),
+		provisioning.NewBootNotificationResponse(types.Now(), 300, provisioning.RegistrationStatusAccepted),
+	}
+	bootManager := provisioning.NewBootManager(testBootChargingStation, func(request *provisioning.BootNotificationRequest) (*provisioning.BootNotificationResponse, error) {
+		response := responses[0]
+		responses = responses[1:]
+		return response, nil
+	})
+	bus := events.NewBus(10)
+	eventC, unsubscribe := bus.Subscribe(events.BootAccepted)
+	defer unsubscribe()
+	bootManager.SetEventBus(bus)
+	for i := 0; i < 3; i++ {
+		_, err := bootManager.Boot(provisioning.BootReasonPowerUp)
+		require.NoError(t, err)
+	}
+	// Published once, when the registration status changes to Accepted
+	require.Len(t, eventC, 1)
+	assert.Equal(t, events.BootAcceptedEvent{Interval: 5 * time.Minute}, <-eventC)
+}
@@ -0,0 +1,167 @@
+package ocpp2_test
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/authorization"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/csms"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/localauth"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+type testAuthCache struct {
+	entries map[string]types.IdTokenInfo
+	err     error
+}
+
+func (c *testAuthCache) Clear() error {
+	if c.err != nil {
+		return c.err
+	}
+	c.entries = map[string]types.IdTokenInfo{}
+	return nil
+}
+
+// Always answers ClearCache requests with the configured response.
+type testCacheClearer struct {
+	response *authorization.ClearCacheResponse
+	err      error
+}
+
+func (c *testCacheClearer) ClearCache(clientId string, callback func(*authorization.ClearCacheResponse, error), props ...func(*authorization.ClearCacheRequest)) error {
+	callback(c.response, c.err)
+	return nil
+}
+
+func (suite *OcppV2TestSuite) TestCacheController() {
+	t := suite.T()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	cache := &testAuthCache{entries: map[string]types.IdTokenInfo{"token1": *types.NewIdTokenInfo(types.AuthorizationStatusAccepted)}}
+	listManager, err := localauth.NewListManager201(localauth.NewMemoryListStorage())
+	require.NoError(t, err)
+	listUpdate := localauth.NewSendLocalListRequest(3, localauth.UpdateTypeFull)
+	listUpdate.LocalAuthorizationList = []localauth.AuthorizationData{{IdToken: types.IdToken{IdToken: "token2", Type: types.IdTokenTypeISO14443}, IdTokenInfo: types.NewIdTokenInfo(types.AuthorizationStatusAccepted)}}
+	_, err = listManager.OnSendLocalList(listUpdate)
+	require.NoError(t, err)
+	controller := authorization.NewCacheController(cache)
+	controller.SetTimeSource(func() time.Time { return now })
+	var events []authorization.CacheAuditEvent
+	controller.OnAudit = func(event authorization.CacheAuditEvent) {
+		events = append(events, event)
+	}
+	// Only the cache is cleared, the local authorization list is retained
+	response, err := controller.OnClearCache(authorization.NewClearCacheRequest())
+	require.NoError(t, err)
+	assert.Equal(t, authorization.ClearCacheStatusAccepted, response.Status)
+	assert.Nil(t, response.StatusInfo)
+	assert.Empty(t, cache.entries)
+	assert.Equal(t, 3, listManager.Version())
+	_, ok := listManager.Lookup("token2", types.IdTokenTypeISO14443)
+	assert.True(t, ok)
+	// Clearing fails
+	cache.err = errors.New("flash write error")
+	response, err = controller.OnClearCache(authorization.NewClearCacheRequest())
+	require.NoError(t, err)
+	assert.Equal(t, authorization.ClearCacheStatusRejected, response.Status)
+	require.NotNil(t, response.StatusInfo)
+	assert.Equal(t, "InternalError", response.StatusInfo.ReasonCode)
+	assert.NoError(t, types.Validate.Struct(response))
+	// No cache available
+	controller.SetCache(nil)
+	response, err = controller.OnClearCache(authorization.NewClearCacheRequest())
+	require.NoError(t, err)
+	assert.Equal(t, authorization.ClearCacheStatusRejected, response.Status)
+	require.NotNil(t, response.StatusInfo)
+	assert.Equal(t, "NotEnabled", response.StatusInfo.ReasonCode)
+	assert.Equal(t, []authorization.CacheAuditEvent{
+		{Timestamp: now, Status: authorization.ClearCacheStatusAccepted},
+		{Timestamp: now, Status: authorization.ClearCacheStatusRejected, TechInfo: "flash write error"},
+		{Timestamp: now, Status: authorization.ClearCacheStatusRejected, TechInfo: "no authorization cache available"},
+	}, events)
+	response, err = authorization.NewCacheController(nil).OnClearCache(authorization.NewClearCacheRequest())
+	require.NoError(t, err)
+	assert.Equal(t, authorization.ClearCacheStatusRejected, response.Status)
+}
+
+func (suite *OcppV2TestSuite) TestCacheControllerE2EMocked() {
+	t := suite.T()
+	wsId := "test_id"
+	messageId := defaultMessageId
+	wsUrl := "someUrl"
+	requestJson := fmt.Sprintf(`[2,"%v","%v",{}]`, messageId, authorization.ClearCacheFeatureName)
+	responseJson := fmt.Sprintf(`[3,"%v",{"status":"%v"}]`, messageId, authorization.ClearCacheStatusAccepted)
+	channel := NewMockWebSocket(wsId)
+	cache := &testAuthCache{entries: map[string]types.IdTokenInfo{"token1": *types.NewIdTokenInfo(types.AuthorizationStatusAccepted)}}
+	setupDefaultCSMSHandlers(suite, expectedCSMSOptions{clientId: wsId, rawWrittenMessage: []byte(requestJson), forwardWrittenMessage: true})
+	setupDefaultChargingStationHandlers(suite, expectedChargingStationOptions{serverUrl: wsUrl, clientId: wsId, createChannelOnStart: true, channel: channel, rawWrittenMessage: []byte(responseJson), forwardWrittenMessage: true})
+	suite.chargingStation.SetAuthorizationHandler(authorization.NewCacheController(cache))
+	registry := csms.NewStationRegistry()
+	// Run Test
+	suite.csms.Start(8887, "somePath")
+	err := suite.chargingStation.Start(wsUrl)
+	require.Nil(t, err)
+	resultChannel := make(chan bool, 1)
+	err = registry.ClearCache(suite.csms, wsId, func(response *authorization.ClearCacheResponse, err error) {
+		require.Nil(t, err)
+		require.NotNil(t, response)
+		assert.Equal(t, authorization.ClearCacheStatusAccepted, response.Status)
+		resultChannel <- true
+	})
+	require.Nil(t, err)
+	result := <-resultChannel
+	assert.True(t, result)
+	assert.Empty(t, cache.entries)
+	snapshot, ok := registry.Station(wsId)
+	require.True(t, ok)
+	require.NotNil(t, snapshot.AuthCache)
+	assert.True(t, snapshot.AuthCache.Empty)
+}
+
+func (suite *OcppV2TestSuite) TestStationRegistryAuthCache() {
+	t := suite.T()
+	registry := csms.NewStationRegistry()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	registry.SetTimeSource(func() time.Time { return now })
+	var changes []csms.ChangeType
+	registry.Subscribe(func(change csms.StationChange) {
+		changes = append(changes, change.Type)
+	})
+	// Rejected requests and errors don't change the model
+	var responses []*authorization.ClearCacheResponse
+	callback := func(response *authorization.ClearCacheResponse, err error) {
+		responses = append(responses, response)
+	}
+	require.NoError(t, registry.ClearCache(&testCacheClearer{response: authorization.NewClearCacheResponse(authorization.ClearCacheStatusRejected)}, "cs1", callback))
+	require.NoError(t, registry.ClearCache(&testCacheClearer{err: errors.New("timeout")}, "cs1", callback))
+	assert.Len(t, responses, 2)
+	_, ok := registry.Station("cs1")
+	assert.False(t, ok)
+	require.NoError(t, registry.ClearCache(&testCacheClearer{response: authorization.NewClearCacheResponse(authorization.ClearCacheStatusAccepted)}, "cs1", nil))
+	snapshot, ok := registry.Station("cs1")
+	require.True(t, ok)
+	assert.Equal(t, &csms.AuthCacheState{Empty: true, ClearedAt: now}, snapshot.AuthCache)
+	// Using an idToken may populate the cache again
+	transactionsHandler := &MockCSMSTransactionsHandler{}
+	transactionsHandler.On("OnTransactionEvent", mock.Anything, mock.Anything).Return(transactions.NewTransactionEventResponse(), nil)
+	observer := registry.WrapTransactionsHandler(transactionsHandler)
+	event := transactions.NewTransactionEventRequest(transactions.TransactionEventStarted, types.NewDateTime(now), transactions.TriggerReasonAuthorized, 0, transactions.Transaction{TransactionID: "tx1"})
+	_, err := observer.OnTransactionEvent("cs1", event)
+	require.NoError(t, err)
+	snapshot, _ = registry.Station("cs1")
+	assert.True(t, snapshot.AuthCache.Empty)
+	event = transactions.NewTransactionEventRequest(transactions.TransactionEventUpdated, types.NewDateTime(now), transactions.TriggerReasonAuthorized, 1, transactions.Transaction{TransactionID: "tx1"})
+	event.IDToken = &types.IdToken{IdToken: "token1", Type: types.IdTokenTypeISO14443}
+	_, err = observer.OnTransactionEvent("cs1", event)
+	require.NoError(t, err)
+	snapshot, _ = registry.Station("cs1")
+	assert.False(t, snapshot.AuthCache.Empty)
+	assert.Equal(t, now, snapshot.AuthCache.ClearedAt)
+	assert.Equal(t, []csms.ChangeType{csms.ChangeConnected, csms.ChangeAuthCache, csms.ChangeTransaction, csms.ChangeTransaction, csms.ChangeAuthCache}, changes)
+}
@@ -0,0 +1,119 @@
+package ocpp2_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"strings"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+func (suite *OcppV2TestSuite) TestCertificateHashDataValidation() {
+	sha256Hash := strings.Repeat("ab", 32)
+	sha384Hash := strings.Repeat("cd", 48)
+	sha512Hash := strings.Repeat("ef", 64)
+	var testTable = []GenericTestEntry{
+		{types.CertificateHashData{HashAlgorithm: types.SHA256, IssuerNameHash: sha256Hash, IssuerKeyHash: sha256Hash, SerialNumber: "1a2b"}, true},
+		{types.CertificateHashData{HashAlgorithm: types.SHA384, IssuerNameHash: sha384Hash, IssuerKeyHash: sha384Hash, SerialNumber: "1a2b"}, true},
+		{types.CertificateHashData{HashAlgorithm: types.SHA512, IssuerNameHash: sha512Hash, IssuerKeyHash: sha512Hash, SerialNumber: "1a2b"}, true},
+		{types.CertificateHashData{HashAlgorithm: types.SHA256, IssuerNameHash: strings.ToUpper(sha256Hash), IssuerKeyHash: sha256Hash, SerialNumber: "1A2B"}, true},
+		{types.CertificateHashData{HashAlgorithm: types.SHA256, IssuerNameHash: sha256Hash, IssuerKeyHash: sha256Hash, SerialNumber: strings.Repeat("f", 40)}, true},
+		// Hash length not matching the algorithm
+		{types.CertificateHashData{HashAlgorithm: types.SHA256, IssuerNameHash: sha384Hash, IssuerKeyHash: sha256Hash, SerialNumber: "1a2b"}, false},
+		{types.CertificateHashData{HashAlgorithm: types.SHA256, IssuerNameHash: sha256Hash, IssuerKeyHash: sha512Hash, SerialNumber: "1a2b"}, false},
+		{types.CertificateHashData{HashAlgorithm: types.SHA384, IssuerNameHash: sha256Hash, IssuerKeyHash: sha384Hash, SerialNumber: "1a2b"}, false},
+		{types.CertificateHashData{HashAlgorithm: types.SHA384, IssuerNameHash: sha384Hash, IssuerKeyHash: sha512Hash, SerialNumber: "1a2b"}, false},
+		{types.CertificateHashData{HashAlgorithm: types.SHA512, IssuerNameHash: sha384Hash, IssuerKeyHash: sha512Hash, SerialNumber: "1a2b"}, false},
+		{types.CertificateHashData{HashAlgorithm: types.SHA512, IssuerNameHash: sha512Hash, IssuerKeyHash: sha256Hash, SerialNumber: "1a2b"}, false},
+		{types.CertificateHashData{HashAlgorithm: types.SHA256, IssuerNameHash: sha256Hash[1:], IssuerKeyHash: sha256Hash, SerialNumber: "1a2b"}, false},
+		// Non-hex characters
+		{types.CertificateHashData{HashAlgorithm: types.SHA256, IssuerNameHash: "zz" + sha256Hash[2:], IssuerKeyHash: sha256Hash, SerialNumber: "1a2b"}, false},
+		{types.CertificateHashData{HashAlgorithm: types.SHA256, IssuerNameHash: sha256Hash, IssuerKeyHash: "0x" + sha256Hash[2:], SerialNumber: "1a2b"}, false},
+		{types.CertificateHashData{HashAlgorithm: types.SHA256, IssuerNameHash: sha256Hash, IssuerKeyHash: sha256Hash, SerialNumber: "serial"}, false},
+		{types.CertificateHashData{HashAlgorithm: types.SHA256, IssuerNameHash: sha256Hash, IssuerKeyHash: sha256Hash, SerialNumber: "1a 2b"}, false},
+		// Serial number too long
+		{types.CertificateHashData{HashAlgorithm: types.SHA256, IssuerNameHash: sha256Hash, IssuerKeyHash: sha256Hash, SerialNumber: strings.Repeat("f", 41)}, false},
+		// Missing fields and unknown algorithm
+		{types.CertificateHashData{HashAlgorithm: types.SHA256, IssuerKeyHash: sha256Hash, SerialNumber: "1a2b"}, false},
+		{types.CertificateHashData{HashAlgorithm: types.SHA256, IssuerNameHash: sha256Hash, SerialNumber: "1a2b"}, false},
+		{types.CertificateHashData{HashAlgorithm: types.SHA256, IssuerNameHash: sha256Hash, IssuerKeyHash: sha256Hash}, false},
+		{types.CertificateHashData{HashAlgorithm: "MD5", IssuerNameHash: sha256Hash, IssuerKeyHash: sha256Hash, SerialNumber: "1a2b"}, false},
+		// OCSP request data
+		{types.OCSPRequestDataType{HashAlgorithm: types.SHA384, IssuerNameHash: sha384Hash, IssuerKeyHash: sha384Hash, SerialNumber: "1a2b", ResponderURL: "http://ocsp.example.com"}, true},
+		{types.OCSPRequestDataType{HashAlgorithm: types.SHA384, IssuerNameHash: sha256Hash, IssuerKeyHash: sha384Hash, SerialNumber: "1a2b"}, false},
+		{types.OCSPRequestDataType{HashAlgorithm: types.SHA512, IssuerNameHash: sha512Hash, IssuerKeyHash: "g" + sha512Hash[1:], SerialNumber: "1a2b"}, false},
+		{types.OCSPRequestDataType{HashAlgorithm: types.SHA256, IssuerNameHash: sha256Hash, IssuerKeyHash: sha256Hash, SerialNumber: "xyz"}, false},
+	}
+	ExecuteGenericTestTable(suite.T(), testTable)
+}
+
+func (suite *OcppV2TestSuite) TestNewCertificateHashDataFromCert() {
+	t := suite.T()
+	ca := newTestCA(t)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	leaf := issueTestCertificate(t, ca, &x509.Certificate{SerialNumber: big.NewInt(0x0abc), Subject: pkix.Name{CommonName: "CS-0001"}}, &key.PublicKey)
+	for _, alg := range []types.HashAlgorithmType{types.SHA256, types.SHA384, types.SHA512} {
+		hashData, err := types.NewCertificateHashDataFromCert(leaf, ca.certificate, alg)
+		require.NoError(t, err)
+		assert.Equal(t, alg, hashData.HashAlgorithm)
+		assert.Len(t, hashData.IssuerNameHash, alg.HexLength())
+		assert.Len(t, hashData.IssuerKeyHash, alg.HexLength())
+		assert.Equal(t, "abc", hashData.SerialNumber)
+		assert.NoError(t, types.Validate.Struct(hashData))
+		// The issuer hashes of a self-signed certificate match the ones of the certificates it issued
+		caHashData, err := types.NewCertificateHashDataFromCert(ca.certificate, nil, alg)
+		require.NoError(t, err)
+		assert.Equal(t, hashData.IssuerNameHash, caHashData.IssuerNameHash)
+		assert.Equal(t, hashData.IssuerKeyHash, caHashData.IssuerKeyHash)
+	}
+	_, err = types.NewCertificateHashDataFromCert(leaf, ca.certificate, "MD5")
+	assert.Error(t, err)
+	_, err = types.NewCertificateHashDataFromCert(leaf, nil, types.SHA256)
+	assert.Error(t, err)
+	_, err = types.NewCertificateHashDataFromCert(nil, ca.certificate, types.SHA256)
+	assert.Error(t, err)
+}
+
+func (suite *OcppV2TestSuite) TestCertificateHashDataLookup() {
+	t := suite.T()
+	ca := newTestCA(t)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	leaf := issueTestCertificate(t, ca, &x509.Certificate{SerialNumber: big.NewInt(0x0abc), Subject: pkix.Name{CommonName: "CS-0001"}}, &key.PublicKey)
+	caHashData, err := types.NewCertificateHashDataFromCert(ca.certificate, nil, types.SHA256)
+	require.NoError(t, err)
+	leafHashData, err := types.NewCertificateHashDataFromCert(leaf, ca.certificate, types.SHA256)
+	require.NoError(t, err)
+	// Hashes are compared case-insensitively, serial numbers ignore leading zeroes
+	other := *leafHashData
+	other.IssuerNameHash = strings.ToUpper(other.IssuerNameHash)
+	other.SerialNumber = "0ABC"
+	assert.True(t, leafHashData.Equal(other))
+	assert.False(t, leafHashData.Equal(*caHashData))
+	sha384HashData, err := types.NewCertificateHashDataFromCert(leaf, ca.certificate, types.SHA384)
+	require.NoError(t, err)
+	assert.False(t, leafHashData.Equal(*sha384HashData))
+	list := []types.CertificateHashData{*caHashData, *sha384HashData, *leafHashData}
+	assert.Equal(t, 2, types.IndexCertificateHashData(list, other))
+	assert.Equal(t, -1, types.IndexCertificateHashData(list[:2], other))
+	chains := []types.CertificateHashDataChain{
+		{CertificateType: types.CSMSRootCertificate, CertificateHashData: *sha384HashData},
+		{CertificateType: types.V2GCertificateChain, CertificateHashData: *caHashData, ChildCertificateHashData: []types.CertificateHashData{*leafHashData}},
+	}
+	chain, ok := types.FindCertificateHashDataChain(chains, other)
+	require.True(t, ok)
+	assert.Equal(t, types.V2GCertificateChain, chain.CertificateType)
+	chain, ok = types.FindCertificateHashDataChain(chains, *sha384HashData)
+	require.True(t, ok)
+	assert.Equal(t, types.CSMSRootCertificate, chain.CertificateType)
+	_, ok = types.FindCertificateHashDataChain(chains[:1], *caHashData)
+	assert.False(t, ok)
+}
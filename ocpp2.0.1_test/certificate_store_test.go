@@ -0,0 +1,197 @@
+package ocpp2_test
+
+import (
+	"crypto/x509"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/iso15118"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/security"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// An in-memory storage with optionally failing writes, for simulating storage errors.
+type failingCertificateStorage struct {
+	failSave     bool
+	certificates []security.StoredCertificate
+}
+
+func (f *failingCertificateStorage) Load() ([]security.StoredCertificate, error) {
+	return f.certificates, nil
+}
+
+func (f *failingCertificateStorage) Save(certificate security.StoredCertificate) error {
+	if f.failSave {
+		return errors.New("disk full")
+	}
+	f.certificates = append(f.certificates, certificate)
+	return nil
+}
+
+func (f *failingCertificateStorage) Delete(id string) error {
+	return errors.New("read-only filesystem")
+}
+
+func (suite *OcppV2TestSuite) TestCertificateStoreInstall() {
+	t := suite.T()
+	store, err := security.NewCertificateStore(security.NewFileCertificateStorage(t.TempDir()))
+	require.NoError(t, err)
+	store.SetMaxEntries(types.CSMSRootCertificate, 2)
+	csmsRoot := newTestCA(t)
+	response, err := store.OnInstallCertificate(iso15118.NewInstallCertificateRequest(types.CSMSRootCertificate, encodeTestChain(csmsRoot.certificate)))
+	require.NoError(t, err)
+	assert.Equal(t, iso15118.CertificateStatusAccepted, response.Status)
+	// Installing the same certificate again has no effect
+	response, err = store.OnInstallCertificate(iso15118.NewInstallCertificateRequest(types.CSMSRootCertificate, encodeTestChain(csmsRoot.certificate)))
+	require.NoError(t, err)
+	assert.Equal(t, iso15118.CertificateStatusAccepted, response.Status)
+	assert.Equal(t, 1, store.Count())
+	response, err = store.OnInstallCertificate(iso15118.NewInstallCertificateRequest(types.CSMSRootCertificate, encodeTestChain(newTestCA(t).certificate)))
+	require.NoError(t, err)
+	assert.Equal(t, iso15118.CertificateStatusAccepted, response.Status)
+	// The maximum amount of entries applies per type
+	response, err = store.OnInstallCertificate(iso15118.NewInstallCertificateRequest(types.CSMSRootCertificate, encodeTestChain(newTestCA(t).certificate)))
+	require.NoError(t, err)
+	assert.Equal(t, iso15118.CertificateStatusRejected, response.Status)
+	require.NotNil(t, response.StatusInfo)
+	assert.Equal(t, "OutOfStorage", response.StatusInfo.ReasonCode)
+	response, err = store.OnInstallCertificate(iso15118.NewInstallCertificateRequest(types.V2GRootCertificate, encodeTestChain(newTestCA(t).certificate)))
+	require.NoError(t, err)
+	assert.Equal(t, iso15118.CertificateStatusAccepted, response.Status)
+	assert.Equal(t, 3, store.Count())
+	// Invalid certificates
+	fixture := newSignedCertificateFixture(t)
+	leaf := issueTestCertificate(t, fixture.intermediate, stationCertificateTemplate(), &fixture.key.PublicKey)
+	expired := newTestCA(t)
+	store.SetTimeSource(func() time.Time { return expired.certificate.NotAfter.Add(time.Minute) })
+	for _, request := range []*iso15118.InstallCertificateRequest{
+		iso15118.NewInstallCertificateRequest(types.MORootCertificate, "not a certificate"),
+		iso15118.NewInstallCertificateRequest(types.MORootCertificate, encodeTestChain(leaf)),
+		iso15118.NewInstallCertificateRequest(types.MORootCertificate, encodeTestChain(fixture.intermediate.certificate)),
+		iso15118.NewInstallCertificateRequest(types.MORootCertificate, encodeTestChain(fixture.root.certificate, fixture.intermediate.certificate)),
+		iso15118.NewInstallCertificateRequest(types.MORootCertificate, encodeTestChain(expired.certificate)),
+		iso15118.NewInstallCertificateRequest(types.CSOSubCA1, encodeTestChain(fixture.intermediate.certificate)),
+	} {
+		response, err = store.OnInstallCertificate(request)
+		require.NoError(t, err)
+		assert.Equal(t, iso15118.CertificateStatusRejected, response.Status)
+		require.NotNil(t, response.StatusInfo)
+		assert.Equal(t, "InvalidCertificate", response.StatusInfo.ReasonCode)
+		assert.NoError(t, types.Validate.Struct(response))
+	}
+	assert.Equal(t, 3, store.Count())
+	// Storage errors
+	store, err = security.NewCertificateStore(&failingCertificateStorage{failSave: true})
+	require.NoError(t, err)
+	response, err = store.OnInstallCertificate(iso15118.NewInstallCertificateRequest(types.CSMSRootCertificate, encodeTestChain(csmsRoot.certificate)))
+	require.NoError(t, err)
+	assert.Equal(t, iso15118.CertificateStatusFailed, response.Status)
+	require.NotNil(t, response.StatusInfo)
+	assert.Equal(t, "InternalError", response.StatusInfo.ReasonCode)
+	assert.Equal(t, 0, store.Count())
+}
+
+func (suite *OcppV2TestSuite) TestCertificateStoreListAndDelete() {
+	t := suite.T()
+	dir := t.TempDir()
+	store, err := security.NewCertificateStore(security.NewFileCertificateStorage(dir))
+	require.NoError(t, err)
+	fixture := newSignedCertificateFixture(t)
+	csmsRoot := newTestCA(t)
+	require.NoError(t, store.Install(types.V2GRootCertificate, encodeTestChain(fixture.root.certificate)))
+	require.NoError(t, store.Install(types.CSMSRootCertificate, encodeTestChain(csmsRoot.certificate)))
+	// The V2G certificate chain must lead to an installed V2G root
+	template := stationCertificateTemplate()
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	leaf := issueTestCertificate(t, fixture.intermediate, template, &fixture.key.PublicKey)
+	assert.Error(t, store.StoreV2GCertificateChain(encodeTestChain(issueTestCertificate(t, csmsRoot, stationCertificateTemplate(), &fixture.key.PublicKey))))
+	require.NoError(t, store.StoreV2GCertificateChain(encodeTestChain(leaf, fixture.intermediate.certificate)))
+	rootHashData, err := types.NewCertificateHashDataFromCert(fixture.root.certificate, nil, types.SHA256)
+	require.NoError(t, err)
+	csmsRootHashData, err := types.NewCertificateHashDataFromCert(csmsRoot.certificate, nil, types.SHA256)
+	require.NoError(t, err)
+	leafHashData, err := types.NewCertificateHashDataFromCert(leaf, fixture.intermediate.certificate, types.SHA256)
+	require.NoError(t, err)
+	intermediateHashData, err := types.NewCertificateHashDataFromCert(fixture.intermediate.certificate, fixture.root.certificate, types.SHA256)
+	require.NoError(t, err)
+	// Listing all types
+	response, err := store.OnGetInstalledCertificateIds(iso15118.NewGetInstalledCertificateIdsRequest())
+	require.NoError(t, err)
+	assert.Equal(t, iso15118.GetInstalledCertificateStatusAccepted, response.Status)
+	require.Len(t, response.CertificateHashDataChain, 3)
+	assert.NoError(t, types.Validate.Struct(response))
+	chain, ok := types.FindCertificateHashDataChain(response.CertificateHashDataChain, *leafHashData)
+	require.True(t, ok)
+	assert.Equal(t, types.V2GCertificateChain, chain.CertificateType)
+	assert.True(t, chain.CertificateHashData.Equal(*leafHashData))
+	require.Len(t, chain.ChildCertificateHashData, 1)
+	assert.True(t, chain.ChildCertificateHashData[0].Equal(*intermediateHashData))
+	chain, ok = types.FindCertificateHashDataChain(response.CertificateHashDataChain, *rootHashData)
+	require.True(t, ok)
+	assert.Equal(t, types.V2GRootCertificate, chain.CertificateType)
+	assert.Empty(t, chain.ChildCertificateHashData)
+	// Filtering by multiple types
+	request := iso15118.NewGetInstalledCertificateIdsRequest()
+	request.CertificateTypes = []types.CertificateUse{types.CSMSRootCertificate, types.V2GCertificateChain}
+	response, err = store.OnGetInstalledCertificateIds(request)
+	require.NoError(t, err)
+	require.Len(t, response.CertificateHashDataChain, 2)
+	_, ok = types.FindCertificateHashDataChain(response.CertificateHashDataChain, *rootHashData)
+	assert.False(t, ok)
+	request.CertificateTypes = []types.CertificateUse{types.MORootCertificate}
+	response, err = store.OnGetInstalledCertificateIds(request)
+	require.NoError(t, err)
+	assert.Equal(t, iso15118.GetInstalledCertificateStatusNotFound, response.Status)
+	assert.Empty(t, response.CertificateHashDataChain)
+	// Hash data of other algorithms
+	store.SetHashAlgorithm(types.SHA512)
+	request.CertificateTypes = []types.CertificateUse{types.CSMSRootCertificate}
+	response, err = store.OnGetInstalledCertificateIds(request)
+	require.NoError(t, err)
+	require.Len(t, response.CertificateHashDataChain, 1)
+	assert.Equal(t, types.SHA512, response.CertificateHashDataChain[0].CertificateHashData.HashAlgorithm)
+	assert.NoError(t, types.Validate.Struct(response))
+	// Certificates are reloaded from the storage
+	reloaded, err := security.NewCertificateStore(security.NewFileCertificateStorage(dir))
+	require.NoError(t, err)
+	assert.Equal(t, 3, reloaded.Count())
+	chains, err := reloaded.List(types.V2GCertificateChain)
+	require.NoError(t, err)
+	require.Len(t, chains, 1)
+	assert.True(t, chains[0].CertificateHashData.Equal(*leafHashData))
+	// Deleting by hash data, matching case-insensitively
+	deleteHashData := *csmsRootHashData
+	deleteHashData.SerialNumber = "0" + deleteHashData.SerialNumber
+	deleteResponse, err := reloaded.OnDeleteCertificate(iso15118.NewDeleteCertificateRequest(deleteHashData))
+	require.NoError(t, err)
+	assert.Equal(t, iso15118.DeleteCertificateStatusAccepted, deleteResponse.Status)
+	deleteResponse, err = reloaded.OnDeleteCertificate(iso15118.NewDeleteCertificateRequest(deleteHashData))
+	require.NoError(t, err)
+	assert.Equal(t, iso15118.DeleteCertificateStatusNotFound, deleteResponse.Status)
+	// Sub-CA certificates of the chain can't be deleted on their own
+	deleteResponse, err = reloaded.OnDeleteCertificate(iso15118.NewDeleteCertificateRequest(*intermediateHashData))
+	require.NoError(t, err)
+	assert.Equal(t, iso15118.DeleteCertificateStatusNotFound, deleteResponse.Status)
+	deleteResponse, err = reloaded.OnDeleteCertificate(iso15118.NewDeleteCertificateRequest(*leafHashData))
+	require.NoError(t, err)
+	assert.Equal(t, iso15118.DeleteCertificateStatusAccepted, deleteResponse.Status)
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.Equal(t, encodeTestChain(fixture.root.certificate), string(data))
+	// Storage errors
+	failing, err := security.NewCertificateStore(&failingCertificateStorage{})
+	require.NoError(t, err)
+	require.NoError(t, failing.Install(types.V2GRootCertificate, encodeTestChain(fixture.root.certificate)))
+	deleteResponse, err = failing.OnDeleteCertificate(iso15118.NewDeleteCertificateRequest(*rootHashData))
+	require.NoError(t, err)
+	assert.Equal(t, iso15118.DeleteCertificateStatusFailed, deleteResponse.Status)
+	assert.Equal(t, 1, failing.Count())
+}
@@ -0,0 +1,201 @@
+package ocpp2_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/security"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+type signedCertificateFixture struct {
+	root         testCA
+	intermediate testCA
+	roots        *x509.CertPool
+	key          *ecdsa.PrivateKey
+}
+
+func newSignedCertificateFixture(t require.TestingT) signedCertificateFixture {
+	root := newTestCA(t)
+	intermediateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	intermediate := issueTestCertificate(t, root, &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Test Sub-CA"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, &intermediateKey.PublicKey)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	roots.AddCert(root.certificate)
+	return signedCertificateFixture{root: root, intermediate: testCA{certificate: intermediate, key: intermediateKey}, roots: roots, key: key}
+}
+
+// Issues a certificate for the public key, filling serial number and validity period of the template if missing.
+func issueTestCertificate(t require.TestingT, issuer testCA, template *x509.Certificate, publicKey crypto.PublicKey) *x509.Certificate {
+	if template.SerialNumber == nil {
+		template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	}
+	if template.NotBefore.IsZero() {
+		template.NotBefore = time.Now().Add(-time.Hour)
+		template.NotAfter = time.Now().Add(time.Hour)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer.certificate, publicKey, issuer.key)
+	require.NoError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return certificate
+}
+
+func encodeTestChain(certificates ...*x509.Certificate) string {
+	var chain []byte
+	for _, certificate := range certificates {
+		chain = append(chain, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw})...)
+	}
+	return string(chain)
+}
+
+// Returns the PEM chain of a leaf certificate issued by the intermediate CA for the given template.
+func (f signedCertificateFixture) chain(t require.TestingT, template *x509.Certificate) string {
+	leaf := issueTestCertificate(t, f.intermediate, template, &f.key.PublicKey)
+	return encodeTestChain(leaf, f.intermediate.certificate)
+}
+
+func stationCertificateTemplate() *x509.Certificate {
+	return &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "CS-0001", Organization: []string{"Some CPO"}},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+}
+
+func (suite *OcppV2TestSuite) TestValidateSignedCertificate() {
+	t := suite.T()
+	fixture := newSignedCertificateFixture(t)
+	chain := fixture.chain(t, stationCertificateTemplate())
+	assert.NoError(t, security.ValidateSignedCertificate(chain, types.ChargingStationCert, fixture.roots, &fixture.key.PublicKey))
+	assert.NoError(t, security.ValidateSignedCertificate(chain, "", fixture.roots, nil))
+	// V2G certificates are used for TLS server authentication
+	template := stationCertificateTemplate()
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	assert.NoError(t, security.ValidateSignedCertificate(fixture.chain(t, template), types.V2GCertificate, fixture.roots, &fixture.key.PublicKey))
+	// Certificates without extended key usage are suitable for both types
+	template = stationCertificateTemplate()
+	template.ExtKeyUsage = nil
+	chain = fixture.chain(t, template)
+	assert.NoError(t, security.ValidateSignedCertificate(chain, types.ChargingStationCert, fixture.roots, &fixture.key.PublicKey))
+	assert.NoError(t, security.ValidateSignedCertificate(chain, types.V2GCertificate, fixture.roots, &fixture.key.PublicKey))
+}
+
+func (suite *OcppV2TestSuite) TestValidateSignedCertificateFailures() {
+	t := suite.T()
+	fixture := newSignedCertificateFixture(t)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherRoots := x509.NewCertPool()
+	otherRoots.AddCert(newTestCA(t).certificate)
+	expired := stationCertificateTemplate()
+	expired.NotBefore = time.Now().Add(-48 * time.Hour)
+	expired.NotAfter = time.Now().Add(-24 * time.Hour)
+	notYetValid := stationCertificateTemplate()
+	notYetValid.NotBefore = time.Now().Add(time.Hour)
+	notYetValid.NotAfter = time.Now().Add(2 * time.Hour)
+	serverOnly := stationCertificateTemplate()
+	serverOnly.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	encipherOnly := stationCertificateTemplate()
+	encipherOnly.KeyUsage = x509.KeyUsageKeyEncipherment
+	ca := stationCertificateTemplate()
+	ca.IsCA = true
+	ca.BasicConstraintsValid = true
+	validChain := fixture.chain(t, stationCertificateTemplate())
+	var testTable = []struct {
+		name       string
+		chain      string
+		certType   types.CertificateSigningUse
+		roots      *x509.CertPool
+		pendingKey crypto.PublicKey
+		expected   error
+	}{
+		{"empty chain", "", types.ChargingStationCert, fixture.roots, &fixture.key.PublicKey, security.ErrMalformedCertificateChain},
+		{"not PEM", "MIIBkTCB+wIJAK", types.ChargingStationCert, fixture.roots, &fixture.key.PublicKey, security.ErrMalformedCertificateChain},
+		{"invalid DER", string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte{0xde, 0xad}})), types.ChargingStationCert, fixture.roots, &fixture.key.PublicKey, security.ErrMalformedCertificateChain},
+		{"private key block", string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte{0xde, 0xad}})), types.ChargingStationCert, fixture.roots, &fixture.key.PublicKey, security.ErrMalformedCertificateChain},
+		{"expired leaf", fixture.chain(t, expired), types.ChargingStationCert, fixture.roots, &fixture.key.PublicKey, security.ErrCertificateExpired},
+		{"leaf not yet valid", fixture.chain(t, notYetValid), types.ChargingStationCert, fixture.roots, &fixture.key.PublicKey, security.ErrCertificateExpired},
+		{"wrong root", validChain, types.ChargingStationCert, otherRoots, &fixture.key.PublicKey, security.ErrUntrustedRoot},
+		{"no roots", validChain, types.ChargingStationCert, nil, &fixture.key.PublicKey, security.ErrUntrustedRoot},
+		{"missing intermediate", encodeTestChain(issueTestCertificate(t, fixture.intermediate, stationCertificateTemplate(), &fixture.key.PublicKey)), types.ChargingStationCert, fixture.roots, &fixture.key.PublicKey, security.ErrUntrustedRoot},
+		{"key mismatch", validChain, types.ChargingStationCert, fixture.roots, &otherKey.PublicKey, security.ErrCertificateKeyMismatch},
+		{"server certificate as station certificate", fixture.chain(t, serverOnly), types.ChargingStationCert, fixture.roots, &fixture.key.PublicKey, security.ErrInvalidKeyUsage},
+		{"station certificate as V2G certificate", validChain, types.V2GCertificate, fixture.roots, &fixture.key.PublicKey, security.ErrInvalidKeyUsage},
+		{"no digital signature", fixture.chain(t, encipherOnly), types.ChargingStationCert, fixture.roots, &fixture.key.PublicKey, security.ErrInvalidKeyUsage},
+		{"CA leaf", fixture.chain(t, ca), types.ChargingStationCert, fixture.roots, &fixture.key.PublicKey, security.ErrInvalidKeyUsage},
+		{"unknown certificate type", validChain, "OtherCertificate", fixture.roots, &fixture.key.PublicKey, security.ErrInvalidKeyUsage},
+	}
+	for _, entry := range testTable {
+		err := security.ValidateSignedCertificate(entry.chain, entry.certType, entry.roots, entry.pendingKey)
+		assert.ErrorIs(t, err, entry.expected, entry.name)
+	}
+}
+
+func (suite *OcppV2TestSuite) TestCertificateSignedHandler() {
+	t := suite.T()
+	fixture := newSignedCertificateFixture(t)
+	installed := map[types.CertificateSigningUse]string{}
+	var rejected []error
+	handler := security.NewCertificateSignedHandler(fixture.roots, func(certType types.CertificateSigningUse) crypto.PublicKey {
+		if certType != types.ChargingStationCert {
+			return nil
+		}
+		return &fixture.key.PublicKey
+	}, func(chainPEM string, certType types.CertificateSigningUse) error {
+		installed[certType] = chainPEM
+		return nil
+	})
+	handler.OnRejected = func(certType types.CertificateSigningUse, err error) {
+		rejected = append(rejected, err)
+	}
+	chain := fixture.chain(t, stationCertificateTemplate())
+	response, err := handler.OnCertificateSigned(security.NewCertificateSignedRequest(chain))
+	require.NoError(t, err)
+	assert.Equal(t, security.CertificateSignedStatusAccepted, response.Status)
+	assert.Equal(t, chain, installed[types.ChargingStationCert])
+	assert.Empty(t, rejected)
+	// Invalid chains are rejected without installing them
+	expired := stationCertificateTemplate()
+	expired.NotBefore = time.Now().Add(-48 * time.Hour)
+	expired.NotAfter = time.Now().Add(-24 * time.Hour)
+	installed = map[types.CertificateSigningUse]string{}
+	response, err = handler.OnCertificateSigned(security.NewCertificateSignedRequest(fixture.chain(t, expired)))
+	require.NoError(t, err)
+	assert.Equal(t, security.CertificateSignedStatusRejected, response.Status)
+	assert.Empty(t, installed)
+	require.Len(t, rejected, 1)
+	assert.ErrorIs(t, rejected[0], security.ErrCertificateExpired)
+	// Certificates without a pending CSR are rejected
+	request := security.NewCertificateSignedRequest(chain)
+	request.TypeOfCertificate = types.V2GCertificate
+	response, err = handler.OnCertificateSigned(request)
+	require.NoError(t, err)
+	assert.Equal(t, security.CertificateSignedStatusRejected, response.Status)
+	// Installation failures are rejected
+	handler.Install = func(chainPEM string, certType types.CertificateSigningUse) error {
+		return errors.New("storage full")
+	}
+	response, err = handler.OnCertificateSigned(security.NewCertificateSignedRequest(chain))
+	require.NoError(t, err)
+	assert.Equal(t, security.CertificateSignedStatusRejected, response.Status)
+	assert.Len(t, rejected, 3)
+}
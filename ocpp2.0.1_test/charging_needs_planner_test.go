@@ -0,0 +1,236 @@
+package ocpp2_test
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/smartcharging"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// Returns a request for an AC three-phase EV on EVSE 1, requesting the given energy before the optional departure time.
+func newTestChargingNeedsRequest(energy int, departure *time.Time) *smartcharging.NotifyEVChargingNeedsRequest {
+	needs := smartcharging.ChargingNeeds{
+		RequestedEnergyTransfer: smartcharging.EnergyTransferModeAC3Phase,
+		ACChargingParameters:    &smartcharging.ACChargingParameters{EnergyAmount: energy, EVMinCurrent: 6, EVMaxCurrent: 32, EVMaxVoltage: 400},
+	}
+	if departure != nil {
+		needs.DepartureTime = types.NewDateTime(*departure)
+	}
+	return smartcharging.NewNotifyEVChargingNeedsRequest(1, needs)
+}
+
+func (suite *OcppV2TestSuite) TestDefaultNeedsPlanner() {
+	t := suite.T()
+	now := time.Date(2021, 3, 1, 18, 0, 0, 0, time.UTC)
+	departure := now.Add(4 * time.Hour)
+	planner := &smartcharging.DefaultNeedsPlanner{ProfileIDBase: 100, StackLevel: 2}
+	// 16A on three phases deliver 11040 Wh within one hour, after which charging is paused
+	profile, err := planner.Plan(smartcharging.PlanningRequest{ChargingStationID: "station1", Request: newTestChargingNeedsRequest(11040, &departure), TransactionID: "tx-1", AvailablePower: 11040, Now: now})
+	require.NoError(t, err)
+	require.NotNil(t, profile)
+	assert.Empty(t, smartcharging.ValidateProfile201(*profile, 1))
+	assert.Equal(t, 101, profile.ID)
+	assert.Equal(t, 2, profile.StackLevel)
+	assert.Equal(t, types.ChargingProfilePurposeTxProfile, profile.ChargingProfilePurpose)
+	assert.Equal(t, "tx-1", profile.TransactionID)
+	require.Len(t, profile.ChargingSchedule, 1)
+	schedule := profile.ChargingSchedule[0]
+	assert.Equal(t, types.ChargingRateUnitAmperes, schedule.ChargingRateUnit)
+	assert.Equal(t, now, schedule.StartSchedule.Time)
+	require.NotNil(t, schedule.Duration)
+	assert.Equal(t, 4*3600, *schedule.Duration)
+	require.Len(t, schedule.ChargingSchedulePeriod, 2)
+	assert.Equal(t, 0, schedule.ChargingSchedulePeriod[0].StartPeriod)
+	assert.Equal(t, 16.0, schedule.ChargingSchedulePeriod[0].Limit)
+	assert.Equal(t, newInt(3), schedule.ChargingSchedulePeriod[0].NumberPhases)
+	assert.Equal(t, 3600, schedule.ChargingSchedulePeriod[1].StartPeriod)
+	assert.Equal(t, 0.0, schedule.ChargingSchedulePeriod[1].Limit)
+	// A single tuple omits the pause
+	request := newTestChargingNeedsRequest(11040, &departure)
+	request.MaxScheduleTuples = newInt(1)
+	profile, err = planner.Plan(smartcharging.PlanningRequest{Request: request, TransactionID: "tx-1", AvailablePower: 11040, Now: now})
+	require.NoError(t, err)
+	assert.Len(t, profile.ChargingSchedule[0].ChargingSchedulePeriod, 1)
+	// The EV maximum applies, if more power is available
+	profile, err = planner.Plan(smartcharging.PlanningRequest{Request: newTestChargingNeedsRequest(11040, &departure), TransactionID: "tx-1", AvailablePower: 50000, Now: now})
+	require.NoError(t, err)
+	assert.Equal(t, 32.0, profile.ChargingSchedule[0].ChargingSchedulePeriod[0].Limit)
+	assert.Equal(t, 1800, profile.ChargingSchedule[0].ChargingSchedulePeriod[1].StartPeriod)
+}
+
+func (suite *OcppV2TestSuite) TestDefaultNeedsPlannerInfeasible() {
+	t := suite.T()
+	now := time.Date(2021, 3, 1, 18, 0, 0, 0, time.UTC)
+	departure := now.Add(4 * time.Hour)
+	planner := &smartcharging.DefaultNeedsPlanner{}
+	// 60 kWh can't be delivered with 11 kW within four hours
+	_, err := planner.Plan(smartcharging.PlanningRequest{Request: newTestChargingNeedsRequest(60000, &departure), TransactionID: "tx-1", AvailablePower: 11040, Now: now})
+	assert.ErrorIs(t, err, smartcharging.ErrInfeasibleChargingNeeds)
+	// The available current is below the EV minimum
+	_, err = planner.Plan(smartcharging.PlanningRequest{Request: newTestChargingNeedsRequest(1000, &departure), TransactionID: "tx-1", AvailablePower: 2000, Now: now})
+	assert.ErrorIs(t, err, smartcharging.ErrInfeasibleChargingNeeds)
+	// The departure time already passed
+	past := now.Add(-time.Minute)
+	_, err = planner.Plan(smartcharging.PlanningRequest{Request: newTestChargingNeedsRequest(1000, &past), TransactionID: "tx-1", AvailablePower: 11040, Now: now})
+	assert.ErrorIs(t, err, smartcharging.ErrInfeasibleChargingNeeds)
+	// Parameters don't match the energy transfer mode
+	request := newTestChargingNeedsRequest(1000, &departure)
+	request.ChargingNeeds.RequestedEnergyTransfer = smartcharging.EnergyTransferModeDC
+	_, err = planner.Plan(smartcharging.PlanningRequest{Request: request, TransactionID: "tx-1", AvailablePower: 11040, Now: now})
+	assert.ErrorIs(t, err, smartcharging.ErrInvalidChargingNeeds)
+}
+
+func (suite *OcppV2TestSuite) TestDefaultNeedsPlannerWithoutDepartureTime() {
+	t := suite.T()
+	now := time.Date(2021, 3, 1, 18, 0, 0, 0, time.UTC)
+	planner := &smartcharging.DefaultNeedsPlanner{}
+	// The schedule ends once the energy was delivered
+	profile, err := planner.Plan(smartcharging.PlanningRequest{Request: newTestChargingNeedsRequest(60000, nil), TransactionID: "tx-1", AvailablePower: 11040, Now: now})
+	require.NoError(t, err)
+	assert.Empty(t, smartcharging.ValidateProfile201(*profile, 1))
+	schedule := profile.ChargingSchedule[0]
+	require.NotNil(t, schedule.Duration)
+	assert.Equal(t, 19566, *schedule.Duration)
+	require.Len(t, schedule.ChargingSchedulePeriod, 1)
+	assert.Equal(t, 16.0, schedule.ChargingSchedulePeriod[0].Limit)
+	// DC energy is derived from the state of charge
+	request := smartcharging.NewNotifyEVChargingNeedsRequest(1, smartcharging.ChargingNeeds{
+		RequestedEnergyTransfer: smartcharging.EnergyTransferModeDC,
+		DCChargingParameters:    &smartcharging.DCChargingParameters{EVMaxCurrent: 125, EVMaxVoltage: 400, EVMaxPower: newInt(50000), StateOfCharge: newInt(20), EVEnergyCapacity: newInt(75000), FullSoC: newInt(80)},
+	})
+	profile, err = planner.Plan(smartcharging.PlanningRequest{Request: request, TransactionID: "tx-1", AvailablePower: 150000, Now: now})
+	require.NoError(t, err)
+	assert.Empty(t, smartcharging.ValidateProfile201(*profile, 1))
+	schedule = profile.ChargingSchedule[0]
+	assert.Equal(t, types.ChargingRateUnitWatts, schedule.ChargingRateUnit)
+	require.NotNil(t, schedule.Duration)
+	assert.Equal(t, 3240, *schedule.Duration)
+	assert.Equal(t, 50000.0, schedule.ChargingSchedulePeriod[0].Limit)
+	assert.Nil(t, schedule.ChargingSchedulePeriod[0].NumberPhases)
+}
+
+type sentChargingProfile struct {
+	chargingStationID string
+	evseID            int
+	profile           *types.ChargingProfile
+	callback          func(*smartcharging.SetChargingProfileResponse, error)
+}
+
+// Returns a coordinator with an ongoing transaction on every EVSE, recording sent profiles and results.
+func newTestNeedsCoordinator(timeout time.Duration, now time.Time) (*smartcharging.NeedsCoordinator, chan sentChargingProfile, chan smartcharging.NegotiationResult) {
+	coordinator := smartcharging.NewNeedsCoordinator(&smartcharging.DefaultNeedsPlanner{}, timeout)
+	coordinator.SetTimeSource(func() time.Time { return now })
+	sentC := make(chan sentChargingProfile, 1)
+	resultC := make(chan smartcharging.NegotiationResult, 1)
+	coordinator.AvailablePower = func(chargingStationID string, evseID int) float64 { return 11040 }
+	coordinator.TransactionID = func(chargingStationID string, evseID int) (string, bool) { return "tx-1", true }
+	coordinator.SendChargingProfile = func(clientId string, callback func(*smartcharging.SetChargingProfileResponse, error), evseID int, chargingProfile *types.ChargingProfile, props ...func(request *smartcharging.SetChargingProfileRequest)) error {
+		sentC <- sentChargingProfile{chargingStationID: clientId, evseID: evseID, profile: chargingProfile, callback: callback}
+		return nil
+	}
+	coordinator.OnResult = func(result smartcharging.NegotiationResult) {
+		resultC <- result
+	}
+	return coordinator, sentC, resultC
+}
+
+func (suite *OcppV2TestSuite) TestNeedsCoordinator() {
+	t := suite.T()
+	now := time.Date(2021, 3, 1, 18, 0, 0, 0, time.UTC)
+	departure := now.Add(4 * time.Hour)
+	coordinator, sentC, resultC := newTestNeedsCoordinator(time.Minute, now)
+	// Achievable needs are accepted, and the planned profile is sent
+	response, err := coordinator.OnNotifyEVChargingNeeds("station1", newTestChargingNeedsRequest(11040, &departure))
+	require.NoError(t, err)
+	assert.Equal(t, smartcharging.EVChargingNeedsStatusAccepted, response.Status)
+	sent := <-sentC
+	assert.Equal(t, "station1", sent.chargingStationID)
+	assert.Equal(t, 1, sent.evseID)
+	assert.Equal(t, "tx-1", sent.profile.TransactionID)
+	sent.callback(smartcharging.NewSetChargingProfileResponse(smartcharging.ChargingProfileStatusAccepted), nil)
+	// The EV confirms its schedule
+	evSchedule := sent.profile.ChargingSchedule[0]
+	scheduleResponse, err := coordinator.OnNotifyEVChargingSchedule("station1", smartcharging.NewNotifyEVChargingScheduleRequest(types.NewDateTime(now), 1, evSchedule))
+	require.NoError(t, err)
+	assert.Equal(t, types.GenericStatusAccepted, scheduleResponse.Status)
+	result := <-resultC
+	assert.NoError(t, result.Err)
+	assert.Equal(t, "station1", result.ChargingStationID)
+	assert.Equal(t, 1, result.EvseID)
+	assert.Equal(t, sent.profile, result.ChargingProfile)
+	require.NotNil(t, result.EVChargingSchedule)
+	assert.Equal(t, evSchedule.ChargingSchedulePeriod, result.EVChargingSchedule.ChargingSchedulePeriod)
+	// Unsolicited schedules are accepted without reporting a result
+	_, err = coordinator.OnNotifyEVChargingSchedule("station1", smartcharging.NewNotifyEVChargingScheduleRequest(types.NewDateTime(now), 1, evSchedule))
+	require.NoError(t, err)
+	assert.Empty(t, resultC)
+}
+
+func (suite *OcppV2TestSuite) TestNeedsCoordinatorRejected() {
+	t := suite.T()
+	now := time.Date(2021, 3, 1, 18, 0, 0, 0, time.UTC)
+	departure := now.Add(4 * time.Hour)
+	coordinator, sentC, resultC := newTestNeedsCoordinator(time.Minute, now)
+	// Infeasible energy requests are rejected without sending a profile
+	response, err := coordinator.OnNotifyEVChargingNeeds("station1", newTestChargingNeedsRequest(60000, &departure))
+	require.NoError(t, err)
+	assert.Equal(t, smartcharging.EVChargingNeedsStatusRejected, response.Status)
+	require.NotNil(t, response.StatusInfo)
+	assert.Equal(t, "NotFeasible", response.StatusInfo.ReasonCode)
+	result := <-resultC
+	assert.ErrorIs(t, result.Err, smartcharging.ErrInfeasibleChargingNeeds)
+	assert.Nil(t, result.ChargingProfile)
+	assert.Empty(t, sentC)
+	// Needs without an ongoing transaction are rejected
+	coordinator.TransactionID = func(chargingStationID string, evseID int) (string, bool) { return "", false }
+	response, err = coordinator.OnNotifyEVChargingNeeds("station1", newTestChargingNeedsRequest(1000, &departure))
+	require.NoError(t, err)
+	assert.Equal(t, smartcharging.EVChargingNeedsStatusRejected, response.Status)
+	assert.ErrorIs(t, (<-resultC).Err, smartcharging.ErrNoTransaction)
+}
+
+func (suite *OcppV2TestSuite) TestNeedsCoordinatorWithoutDepartureTime() {
+	t := suite.T()
+	now := time.Date(2021, 3, 1, 18, 0, 0, 0, time.UTC)
+	coordinator, sentC, resultC := newTestNeedsCoordinator(time.Minute, now)
+	response, err := coordinator.OnNotifyEVChargingNeeds("station1", newTestChargingNeedsRequest(11040, nil))
+	require.NoError(t, err)
+	assert.Equal(t, smartcharging.EVChargingNeedsStatusAccepted, response.Status)
+	sent := <-sentC
+	require.NotNil(t, sent.profile.ChargingSchedule[0].Duration)
+	assert.Equal(t, 3600, *sent.profile.ChargingSchedule[0].Duration)
+	// The charging station rejects the profile
+	sent.callback(smartcharging.NewSetChargingProfileResponse(smartcharging.ChargingProfileStatusRejected), nil)
+	result := <-resultC
+	assert.ErrorIs(t, result.Err, smartcharging.ErrChargingProfileRejected)
+	assert.Equal(t, sent.profile, result.ChargingProfile)
+}
+
+func (suite *OcppV2TestSuite) TestNeedsCoordinatorTimeout() {
+	t := suite.T()
+	now := time.Date(2021, 3, 1, 18, 0, 0, 0, time.UTC)
+	departure := now.Add(4 * time.Hour)
+	coordinator, sentC, resultC := newTestNeedsCoordinator(50*time.Millisecond, now)
+	_, err := coordinator.OnNotifyEVChargingNeeds("station1", newTestChargingNeedsRequest(11040, &departure))
+	require.NoError(t, err)
+	sent := <-sentC
+	sent.callback(smartcharging.NewSetChargingProfileResponse(smartcharging.ChargingProfileStatusAccepted), nil)
+	select {
+	case result := <-resultC:
+		assert.ErrorIs(t, result.Err, smartcharging.ErrNegotiationTimeout)
+		assert.Nil(t, result.EVChargingSchedule)
+	case <-time.After(time.Second):
+		require.Fail(t, "negotiation didn't time out")
+	}
+	// New needs supersede an ongoing negotiation
+	_, err = coordinator.OnNotifyEVChargingNeeds("station1", newTestChargingNeedsRequest(11040, &departure))
+	require.NoError(t, err)
+	<-sentC
+	_, err = coordinator.OnNotifyEVChargingNeeds("station1", newTestChargingNeedsRequest(5000, &departure))
+	require.NoError(t, err)
+	assert.ErrorIs(t, (<-resultC).Err, smartcharging.ErrNegotiationSuperseded)
+	<-sentC
+}
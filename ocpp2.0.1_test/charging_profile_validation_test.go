@@ -0,0 +1,281 @@
+package ocpp2_test
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/smartcharging"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// Returns a valid absolute TxDefaultProfile with a single schedule, which may be modified by test cases.
+func newTestProfile201() types.ChargingProfile {
+	schedule := types.NewChargingSchedule(1, types.ChargingRateUnitAmperes, types.NewChargingSchedulePeriod(0, 16), types.NewChargingSchedulePeriod(3600, 8))
+	schedule.StartSchedule = types.NewDateTime(time.Now())
+	return *types.NewChargingProfile(1, 0, types.ChargingProfilePurposeTxDefaultProfile, types.ChargingProfileKindAbsolute, []types.ChargingSchedule{*schedule})
+}
+
+func (suite *OcppV2TestSuite) TestValidateProfile201() {
+	t := suite.T()
+	now := time.Now()
+	var testTable = []struct {
+		name     string
+		evseID   int
+		modify   func(profile *types.ChargingProfile)
+		expected []error
+	}{
+		{"valid TxDefaultProfile", 0, func(profile *types.ChargingProfile) {}, nil},
+		{"valid TxProfile", 1, func(profile *types.ChargingProfile) {
+			profile.ChargingProfilePurpose = types.ChargingProfilePurposeTxProfile
+			profile.TransactionID = "tx-1"
+		}, nil},
+		{"valid recurring ChargingStationMaxProfile", 0, func(profile *types.ChargingProfile) {
+			profile.ChargingProfilePurpose = types.ChargingProfilePurposeChargingStationMaxProfile
+			profile.ChargingProfileKind = types.ChargingProfileKindRecurring
+			profile.RecurrencyKind = types.RecurrencyKindDaily
+			profile.ChargingSchedule[0].Duration = newInt(86400)
+		}, nil},
+		{"valid relative profile with phases", 2, func(profile *types.ChargingProfile) {
+			profile.ChargingProfileKind = types.ChargingProfileKindRelative
+			profile.ChargingSchedule[0].StartSchedule = nil
+			profile.ChargingSchedule[0].ChargingSchedulePeriod[0].NumberPhases = newInt(1)
+			profile.ChargingSchedule[0].ChargingSchedulePeriod[0].PhaseToUse = newInt(2)
+			profile.ChargingSchedule[0].ChargingSchedulePeriod[1].NumberPhases = newInt(3)
+		}, nil},
+		{"external constraints set by CSMS", 0, func(profile *types.ChargingProfile) {
+			profile.ChargingProfilePurpose = types.ChargingProfilePurposeChargingStationExternalConstraints
+		}, []error{smartcharging.ErrExternalConstraintsProfile}},
+		{"TxProfile without transaction", 1, func(profile *types.ChargingProfile) {
+			profile.ChargingProfilePurpose = types.ChargingProfilePurposeTxProfile
+		}, []error{smartcharging.ErrTxProfileTransactionID}},
+		{"TxProfile for station", 0, func(profile *types.ChargingProfile) {
+			profile.ChargingProfilePurpose = types.ChargingProfilePurposeTxProfile
+			profile.TransactionID = "tx-1"
+		}, []error{smartcharging.ErrInvalidEvseID}},
+		{"TxProfile without transaction for station", 0, func(profile *types.ChargingProfile) {
+			profile.ChargingProfilePurpose = types.ChargingProfilePurposeTxProfile
+		}, []error{smartcharging.ErrTxProfileTransactionID, smartcharging.ErrInvalidEvseID}},
+		{"transaction for TxDefaultProfile", 1, func(profile *types.ChargingProfile) {
+			profile.TransactionID = "tx-1"
+		}, []error{smartcharging.ErrUnexpectedTransactionID}},
+		{"ChargingStationMaxProfile for EVSE", 1, func(profile *types.ChargingProfile) {
+			profile.ChargingProfilePurpose = types.ChargingProfilePurposeChargingStationMaxProfile
+		}, []error{smartcharging.ErrInvalidEvseID}},
+		{"relative ChargingStationMaxProfile", 0, func(profile *types.ChargingProfile) {
+			profile.ChargingProfilePurpose = types.ChargingProfilePurposeChargingStationMaxProfile
+			profile.ChargingProfileKind = types.ChargingProfileKindRelative
+			profile.ChargingSchedule[0].StartSchedule = nil
+		}, []error{smartcharging.ErrRelativeMaxProfile}},
+		{"validTo before validFrom", 0, func(profile *types.ChargingProfile) {
+			profile.ValidFrom = types.NewDateTime(now)
+			profile.ValidTo = types.NewDateTime(now.Add(-time.Hour))
+		}, []error{smartcharging.ErrInvalidValidityPeriod}},
+		{"recurring without recurrencyKind", 0, func(profile *types.ChargingProfile) {
+			profile.ChargingProfileKind = types.ChargingProfileKindRecurring
+		}, []error{smartcharging.ErrInvalidRecurrency}},
+		{"recurrencyKind for absolute profile", 0, func(profile *types.ChargingProfile) {
+			profile.RecurrencyKind = types.RecurrencyKindWeekly
+		}, []error{smartcharging.ErrInvalidRecurrency}},
+		{"absolute without startSchedule", 0, func(profile *types.ChargingProfile) {
+			profile.ChargingSchedule[0].StartSchedule = nil
+		}, []error{smartcharging.ErrInvalidStartSchedule}},
+		{"relative with startSchedule", 0, func(profile *types.ChargingProfile) {
+			profile.ChargingProfileKind = types.ChargingProfileKindRelative
+		}, []error{smartcharging.ErrInvalidStartSchedule}},
+		{"no schedules", 0, func(profile *types.ChargingProfile) {
+			profile.ChargingSchedule = nil
+		}, []error{smartcharging.ErrInvalidChargingScheduleCount}},
+		{"too many schedules", 0, func(profile *types.ChargingProfile) {
+			schedule := profile.ChargingSchedule[0]
+			for i := 2; i <= 4; i++ {
+				schedule.ID = i
+				profile.ChargingSchedule = append(profile.ChargingSchedule, schedule)
+			}
+		}, []error{smartcharging.ErrInvalidChargingScheduleCount}},
+		{"duplicate schedule ids", 0, func(profile *types.ChargingProfile) {
+			profile.ChargingSchedule = append(profile.ChargingSchedule, profile.ChargingSchedule[0])
+		}, []error{smartcharging.ErrDuplicateChargingScheduleID}},
+		{"first period not at 0", 0, func(profile *types.ChargingProfile) {
+			profile.ChargingSchedule[0].ChargingSchedulePeriod[0].StartPeriod = 60
+		}, []error{smartcharging.ErrInvalidSchedulePeriods}},
+		{"periods out of order", 0, func(profile *types.ChargingProfile) {
+			profile.ChargingSchedule[0].ChargingSchedulePeriod = append(profile.ChargingSchedule[0].ChargingSchedulePeriod, types.NewChargingSchedulePeriod(1800, 10))
+		}, []error{smartcharging.ErrInvalidSchedulePeriods}},
+		{"period after duration", 0, func(profile *types.ChargingProfile) {
+			profile.ChargingSchedule[0].Duration = newInt(3600)
+		}, []error{smartcharging.ErrInvalidScheduleDuration}},
+		{"duration exceeding recurrence", 0, func(profile *types.ChargingProfile) {
+			profile.ChargingProfileKind = types.ChargingProfileKindRecurring
+			profile.RecurrencyKind = types.RecurrencyKindDaily
+			profile.ChargingSchedule[0].Duration = newInt(86401)
+		}, []error{smartcharging.ErrInvalidScheduleDuration}},
+		{"period after recurrence", 0, func(profile *types.ChargingProfile) {
+			profile.ChargingProfileKind = types.ChargingProfileKindRecurring
+			profile.RecurrencyKind = types.RecurrencyKindDaily
+			profile.ChargingSchedule[0].ChargingSchedulePeriod[1].StartPeriod = 86400
+		}, []error{smartcharging.ErrInvalidScheduleDuration}},
+		{"negative minChargingRate", 0, func(profile *types.ChargingProfile) {
+			minChargingRate := -0.1
+			profile.ChargingSchedule[0].MinChargingRate = &minChargingRate
+		}, []error{smartcharging.ErrNegativeChargingRate}},
+		{"negative limit", 0, func(profile *types.ChargingProfile) {
+			profile.ChargingSchedule[0].ChargingSchedulePeriod[1].Limit = -1
+		}, []error{smartcharging.ErrNegativeChargingRate}},
+		{"invalid numberPhases", 0, func(profile *types.ChargingProfile) {
+			profile.ChargingSchedule[0].ChargingSchedulePeriod[0].NumberPhases = newInt(4)
+		}, []error{smartcharging.ErrInvalidPhases}},
+		{"phaseToUse without single phase", 0, func(profile *types.ChargingProfile) {
+			profile.ChargingSchedule[0].ChargingSchedulePeriod[0].NumberPhases = newInt(3)
+			profile.ChargingSchedule[0].ChargingSchedulePeriod[0].PhaseToUse = newInt(1)
+		}, []error{smartcharging.ErrInvalidPhases}},
+		{"invalid phaseToUse", 0, func(profile *types.ChargingProfile) {
+			profile.ChargingSchedule[0].ChargingSchedulePeriod[0].NumberPhases = newInt(1)
+			profile.ChargingSchedule[0].ChargingSchedulePeriod[0].PhaseToUse = newInt(4)
+		}, []error{smartcharging.ErrInvalidPhases}},
+	}
+	for _, entry := range testTable {
+		profile := newTestProfile201()
+		entry.modify(&profile)
+		violations := smartcharging.ValidateProfile201(profile, entry.evseID)
+		require.Len(t, violations, len(entry.expected), entry.name)
+		for i, expected := range entry.expected {
+			assert.ErrorIs(t, violations[i], expected, entry.name)
+		}
+	}
+}
+
+func (suite *OcppV2TestSuite) TestValidateProfile201SalesTariff() {
+	t := suite.T()
+	cost := types.CostType{CostKind: types.CostKindRelativePricePercentage, Amount: 10}
+	newEntries := func(count int) []types.SalesTariffEntry {
+		entries := make([]types.SalesTariffEntry, count)
+		for i := range entries {
+			entries[i] = types.SalesTariffEntry{RelativeTimeInterval: types.RelativeTimeInterval{Start: i * 60}}
+		}
+		return entries
+	}
+	var testTable = []struct {
+		name    string
+		tariff  *types.SalesTariff
+		isValid bool
+	}{
+		{"single entry", types.NewSalesTariff(1, newEntries(1)), true},
+		{"maximum entries", types.NewSalesTariff(1, newEntries(smartcharging.MaxSalesTariffEntries)), true},
+		{"no entries", types.NewSalesTariff(1, nil), false},
+		{"too many entries", types.NewSalesTariff(1, newEntries(smartcharging.MaxSalesTariffEntries+1)), false},
+		{"maximum consumption costs", types.NewSalesTariff(1, []types.SalesTariffEntry{{ConsumptionCost: []types.ConsumptionCost{
+			types.NewConsumptionCost(0, []types.CostType{cost, cost, cost}), types.NewConsumptionCost(10, []types.CostType{cost}), types.NewConsumptionCost(20, []types.CostType{cost}),
+		}}}), true},
+		{"too many consumption costs", types.NewSalesTariff(1, []types.SalesTariffEntry{{ConsumptionCost: []types.ConsumptionCost{
+			types.NewConsumptionCost(0, []types.CostType{cost}), types.NewConsumptionCost(10, []types.CostType{cost}), types.NewConsumptionCost(20, []types.CostType{cost}), types.NewConsumptionCost(30, []types.CostType{cost}),
+		}}}), false},
+		{"too many costs", types.NewSalesTariff(1, []types.SalesTariffEntry{{ConsumptionCost: []types.ConsumptionCost{
+			types.NewConsumptionCost(0, []types.CostType{cost, cost, cost, cost}),
+		}}}), false},
+		{"no costs", types.NewSalesTariff(1, []types.SalesTariffEntry{{ConsumptionCost: []types.ConsumptionCost{
+			types.NewConsumptionCost(0, nil),
+		}}}), false},
+	}
+	for _, entry := range testTable {
+		profile := newTestProfile201()
+		profile.ChargingSchedule[0].SalesTariff = entry.tariff
+		violations := smartcharging.ValidateProfile201(profile, 0)
+		if entry.isValid {
+			assert.Empty(t, violations, entry.name)
+		} else {
+			require.Len(t, violations, 1, entry.name)
+			assert.ErrorIs(t, violations[0], smartcharging.ErrInvalidSalesTariff, entry.name)
+		}
+	}
+}
+
+func (suite *OcppV2TestSuite) TestValidateProfile201StackLevels() {
+	t := suite.T()
+	now := time.Now()
+	newInstalled := func(evseID int, modify func(profile *types.ChargingProfile)) smartcharging.SetChargingProfileRequest {
+		profile := newTestProfile201()
+		profile.ID = 2
+		modify(&profile)
+		return *smartcharging.NewSetChargingProfileRequest(evseID, &profile)
+	}
+	var testTable = []struct {
+		name      string
+		installed smartcharging.SetChargingProfileRequest
+		conflict  bool
+	}{
+		{"same stack level", newInstalled(1, func(profile *types.ChargingProfile) {}), true},
+		{"replaced profile", newInstalled(1, func(profile *types.ChargingProfile) { profile.ID = 1 }), false},
+		{"other EVSE", newInstalled(2, func(profile *types.ChargingProfile) {}), false},
+		{"other stack level", newInstalled(1, func(profile *types.ChargingProfile) { profile.StackLevel = 1 }), false},
+		{"other purpose", newInstalled(1, func(profile *types.ChargingProfile) {
+			profile.ChargingProfilePurpose = types.ChargingProfilePurposeTxProfile
+			profile.TransactionID = "tx-1"
+		}), false},
+		{"expired profile", newInstalled(1, func(profile *types.ChargingProfile) {
+			profile.ValidTo = types.NewDateTime(now.Add(-time.Hour))
+		}), false},
+		{"future profile", newInstalled(1, func(profile *types.ChargingProfile) {
+			profile.ValidFrom = types.NewDateTime(now.Add(2 * time.Hour))
+		}), false},
+		{"overlapping validity", newInstalled(1, func(profile *types.ChargingProfile) {
+			profile.ValidFrom = types.NewDateTime(now.Add(-time.Hour))
+			profile.ValidTo = types.NewDateTime(now.Add(30 * time.Minute))
+		}), true},
+	}
+	for _, entry := range testTable {
+		profile := newTestProfile201()
+		profile.ValidFrom = types.NewDateTime(now)
+		profile.ValidTo = types.NewDateTime(now.Add(time.Hour))
+		violations := smartcharging.ValidateProfile201(profile, 1, entry.installed)
+		if entry.conflict {
+			require.Len(t, violations, 1, entry.name)
+			assert.ErrorIs(t, violations[0], smartcharging.ErrStackLevelConflict, entry.name)
+		} else {
+			assert.Empty(t, violations, entry.name)
+		}
+	}
+	// TxProfiles only conflict within the same transaction
+	profile := newTestProfile201()
+	profile.ChargingProfilePurpose = types.ChargingProfilePurposeTxProfile
+	profile.TransactionID = "tx-1"
+	otherTransaction := newInstalled(1, func(profile *types.ChargingProfile) {
+		profile.ChargingProfilePurpose = types.ChargingProfilePurposeTxProfile
+		profile.TransactionID = "tx-2"
+	})
+	sameTransaction := newInstalled(1, func(profile *types.ChargingProfile) {
+		profile.ChargingProfilePurpose = types.ChargingProfilePurposeTxProfile
+		profile.TransactionID = "tx-1"
+	})
+	assert.Empty(t, smartcharging.ValidateProfile201(profile, 1, otherTransaction))
+	violations := smartcharging.ValidateProfile201(profile, 1, otherTransaction, sameTransaction)
+	require.Len(t, violations, 1)
+	assert.ErrorIs(t, violations[0], smartcharging.ErrStackLevelConflict)
+}
+
+func (suite *OcppV2TestSuite) TestSetChargingProfileValidation() {
+	t := suite.T()
+	wsId := "test_id"
+	setupDefaultCSMSHandlers(suite, expectedCSMSOptions{clientId: wsId, forwardWrittenMessage: false})
+	suite.csms.SetChargingProfileValidation(true)
+	suite.csms.Start(8887, "somePath")
+	profile := newTestProfile201()
+	profile.ChargingProfilePurpose = types.ChargingProfilePurposeChargingStationExternalConstraints
+	profile.TransactionID = "tx-1"
+	profile.ChargingSchedule[0].ChargingSchedulePeriod[0].StartPeriod = 10
+	err := suite.csms.SetChargingProfile(wsId, func(response *smartcharging.SetChargingProfileResponse, err error) {
+		assert.Fail(t, "unexpected callback")
+	}, 0, &profile)
+	require.Error(t, err)
+	// All violations are returned
+	var validationErr *smartcharging.ProfileValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.Len(t, validationErr.Violations, 3)
+	assert.ErrorIs(t, err, smartcharging.ErrExternalConstraintsProfile)
+	assert.ErrorIs(t, err, smartcharging.ErrUnexpectedTransactionID)
+	assert.ErrorIs(t, err, smartcharging.ErrInvalidSchedulePeriods)
+	assert.NotErrorIs(t, err, smartcharging.ErrTxProfileTransactionID)
+	suite.mockWsServer.AssertNotCalled(t, "Write", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,150 @@
+package ocpp2_test
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/smartcharging"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+type completedProfilesReport struct {
+	chargingStationID string
+	requestID         int
+	profiles          []smartcharging.ReportedProfile
+	err               error
+}
+
+func newTestProfilesReportAssembler(timeout time.Duration) (*smartcharging.ChargingProfilesReportAssembler, chan completedProfilesReport) {
+	completedC := make(chan completedProfilesReport, 2)
+	assembler := smartcharging.NewChargingProfilesReportAssembler(timeout, func(chargingStationID string, requestID int, profiles []smartcharging.ReportedProfile, err error) {
+		completedC <- completedProfilesReport{chargingStationID, requestID, profiles, err}
+	})
+	return assembler, completedC
+}
+
+// Returns a ReportChargingProfilesRequest part, reporting profiles with the given ids.
+func newTestProfilesReport(requestID int, evseID int, tbc bool, ids ...int) *smartcharging.ReportChargingProfilesRequest {
+	var profiles []types.ChargingProfile
+	for _, id := range ids {
+		profile := newTestProfile201()
+		profile.ID = id
+		profiles = append(profiles, profile)
+	}
+	request := smartcharging.NewReportChargingProfilesRequest(requestID, types.ChargingLimitSourceCSO, evseID, profiles)
+	request.Tbc = tbc
+	return request
+}
+
+func (suite *OcppV2TestSuite) TestChargingProfilesReportAssembler() {
+	t := suite.T()
+	assembler, completedC := newTestProfilesReportAssembler(time.Minute)
+	// Interleaved parts of two reports from the same station
+	require.NoError(t, assembler.Add("station1", newTestProfilesReport(1, 0, true, 1, 2)))
+	require.NoError(t, assembler.Add("station1", newTestProfilesReport(2, 0, true, 10)))
+	require.NoError(t, assembler.Add("station1", newTestProfilesReport(1, 1, true, 3)))
+	assert.Equal(t, 2, assembler.PendingReports("station1"))
+	assert.Empty(t, completedC)
+	require.NoError(t, assembler.Add("station1", newTestProfilesReport(1, 2, false, 4)))
+	completed := <-completedC
+	require.NoError(t, completed.err)
+	assert.Equal(t, "station1", completed.chargingStationID)
+	assert.Equal(t, 1, completed.requestID)
+	require.Len(t, completed.profiles, 4)
+	for i, expected := range []struct{ id, evseID int }{{1, 0}, {2, 0}, {3, 1}, {4, 2}} {
+		assert.Equal(t, expected.id, completed.profiles[i].ChargingProfile.ID)
+		assert.Equal(t, expected.evseID, completed.profiles[i].EvseID)
+		assert.Equal(t, types.ChargingLimitSourceCSO, completed.profiles[i].ChargingLimitSource)
+	}
+	assert.Equal(t, 1, assembler.PendingReports("station1"))
+	assembler.Discard("station1")
+	assert.Equal(t, 0, assembler.PendingReports("station1"))
+	// Single-part reports complete immediately
+	require.NoError(t, assembler.Add("station2", newTestProfilesReport(1, 0, false, 5)))
+	completed = <-completedC
+	require.NoError(t, completed.err)
+	assert.Len(t, completed.profiles, 1)
+}
+
+func (suite *OcppV2TestSuite) TestChargingProfilesReportAssemblerErrors() {
+	t := suite.T()
+	assembler, completedC := newTestProfilesReportAssembler(50 * time.Millisecond)
+	// Incomplete reports are delivered after the timeout
+	require.NoError(t, assembler.Add("station1", newTestProfilesReport(1, 0, true, 1)))
+	select {
+	case completed := <-completedC:
+		var partialErr *smartcharging.PartialReportError
+		require.ErrorAs(t, completed.err, &partialErr)
+		assert.Equal(t, 1, partialErr.ReceivedParts)
+		assert.Len(t, completed.profiles, 1)
+	case <-time.After(time.Second):
+		require.Fail(t, "report didn't time out")
+	}
+	// Buffering limits
+	assembler.SetLimits(2, 1)
+	require.NoError(t, assembler.Add("station1", newTestProfilesReport(2, 0, true, 1)))
+	var limitErr *smartcharging.ReportLimitError
+	err := assembler.Add("station1", newTestProfilesReport(3, 0, true, 2))
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, 3, (<-completedC).requestID)
+	err = assembler.Add("station1", newTestProfilesReport(2, 0, true, 2, 3))
+	require.ErrorAs(t, err, &limitErr)
+	completed := <-completedC
+	assert.Equal(t, 2, completed.requestID)
+	assert.Len(t, completed.profiles, 1)
+	assert.Equal(t, 0, assembler.PendingReports("station1"))
+}
+
+// Returns the SetChargingProfileRequest expected to have installed a profile with the given id.
+func newTestExpectedProfile(id int, evseID int) smartcharging.SetChargingProfileRequest {
+	profile := newTestProfile201()
+	profile.ID = id
+	return *smartcharging.NewSetChargingProfileRequest(evseID, &profile)
+}
+
+func (suite *OcppV2TestSuite) TestReconcileChargingProfiles() {
+	t := suite.T()
+	inSync := newTestExpectedProfile(1, 0)
+	missing := newTestExpectedProfile(2, 1)
+	differing := newTestExpectedProfile(3, 1)
+	expected := []smartcharging.SetChargingProfileRequest{inSync, missing, differing}
+	// The station fills in validFrom and renumbers schedules, which isn't a difference
+	filledIn := *inSync.ChargingProfile
+	filledIn.ValidFrom = types.NewDateTime(time.Now())
+	filledIn.ChargingSchedule = []types.ChargingSchedule{filledIn.ChargingSchedule[0]}
+	filledIn.ChargingSchedule[0].ID = 42
+	changed := *differing.ChargingProfile
+	changed.StackLevel = 3
+	changed.ChargingSchedule = []types.ChargingSchedule{changed.ChargingSchedule[0]}
+	changed.ChargingSchedule[0].ChargingSchedulePeriod = []types.ChargingSchedulePeriod{types.NewChargingSchedulePeriod(0, 10)}
+	unexpectedProfile := newTestProfile201()
+	unexpectedProfile.ID = 4
+	externalProfile := newTestProfile201()
+	externalProfile.ID = 5
+	reported := []smartcharging.ReportedProfile{
+		{EvseID: 0, ChargingLimitSource: types.ChargingLimitSourceCSO, ChargingProfile: filledIn},
+		{EvseID: 2, ChargingLimitSource: types.ChargingLimitSourceCSO, ChargingProfile: changed},
+		{EvseID: 1, ChargingLimitSource: types.ChargingLimitSourceCSO, ChargingProfile: unexpectedProfile},
+		{EvseID: 1, ChargingLimitSource: types.ChargingLimitSourceEMS, ChargingProfile: externalProfile},
+	}
+	result := smartcharging.Reconcile(expected, reported)
+	assert.False(t, result.InSync())
+	require.Len(t, result.Missing, 1)
+	assert.Equal(t, 2, result.Missing[0].ChargingProfile.ID)
+	require.Len(t, result.Unexpected, 1)
+	assert.Equal(t, 4, result.Unexpected[0].ChargingProfile.ID)
+	require.Len(t, result.Differences, 1)
+	assert.Equal(t, 3, result.Differences[0].Expected.ChargingProfile.ID)
+	assert.Equal(t, []string{"evseId", "stackLevel", "chargingSchedule[0].chargingSchedulePeriod"}, result.Differences[0].Fields)
+	// Missing and differing profiles are re-pushed with their original request
+	repair := result.RepairRequests()
+	require.Len(t, repair, 2)
+	assert.Equal(t, missing, *repair[0])
+	assert.Equal(t, differing, *repair[1])
+	// Matching profiles
+	result = smartcharging.Reconcile([]smartcharging.SetChargingProfileRequest{inSync}, reported[:1])
+	assert.True(t, result.InSync())
+	assert.Empty(t, result.RepairRequests())
+}
@@ -0,0 +1,43 @@
+package ocpp2_test
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/clocksync"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/availability"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+func (suite *OcppV2TestSuite) TestClockSyncTrackOffset() {
+	t := suite.T()
+	wsId := "test_id"
+	wsUrl := "someUrl"
+	serverOffset := -2 * time.Hour
+	channel := NewMockWebSocket(wsId)
+	timestampC := make(chan *types.DateTime, 1)
+	handler := &MockCSMSAvailabilityHandler{}
+	handler.On("OnHeartbeat", mock.AnythingOfType("string"), mock.Anything).Return(availability.NewHeartbeatResponse(*types.NewDateTime(time.Now().Add(serverOffset))), nil)
+	handler.On("OnStatusNotification", mock.AnythingOfType("string"), mock.Anything).Return(availability.NewStatusNotificationResponse(), nil).Run(func(args mock.Arguments) {
+		request := args.Get(1).(*availability.StatusNotificationRequest)
+		timestampC <- request.Timestamp
+	})
+	setupDefaultCSMSHandlers(suite, expectedCSMSOptions{clientId: wsId, forwardWrittenMessage: true}, handler)
+	setupDefaultChargingStationHandlers(suite, expectedChargingStationOptions{serverUrl: wsUrl, clientId: wsId, createChannelOnStart: true, channel: channel, forwardWrittenMessage: true})
+	suite.csms.Start(8887, "somePath")
+	err := suite.chargingStation.Start(wsUrl)
+	require.NoError(t, err)
+	suite.chargingStation.SetClockSyncMode(clocksync.ModeTrackOffset)
+	_, err = suite.chargingStation.Heartbeat()
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(serverOffset), suite.chargingStation.Now(), 2*time.Second)
+	// A nil timestamp is filled with the corrected time
+	_, err = suite.chargingStation.StatusNotification(nil, availability.ConnectorStatusAvailable, 1, 1)
+	require.NoError(t, err)
+	timestamp := <-timestampC
+	require.NotNil(t, timestamp)
+	assert.WithinDuration(t, time.Now().Add(serverOffset), timestamp.Time, 2*time.Second)
+}
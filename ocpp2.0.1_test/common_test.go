@@ -209,15 +209,17 @@ func (suite *OcppV2TestSuite) TestChargingProfileValidation() {
 func (suite *OcppV2TestSuite) TestSignedMeterValue() {
 	t := suite.T()
 	var testTable = []GenericTestEntry{
-		{types.SignedMeterValue{SignedMeterData: "0xdeadbeef", SigningMethod: "ECDSAP256SHA256", EncodingMethod: "DLMS Message", PublicKey: "0xd34dc0de"}, true},
-		{types.SignedMeterValue{SignedMeterData: "0xdeadbeef", SigningMethod: "ECDSAP256SHA256", EncodingMethod: "DLMS Message"}, false},
-		{types.SignedMeterValue{SignedMeterData: "0xdeadbeef", SigningMethod: "ECDSAP256SHA256", PublicKey: "0xd34dc0de"}, false},
-		{types.SignedMeterValue{SignedMeterData: "0xdeadbeef", EncodingMethod: "DLMS Message", PublicKey: "0xd34dc0de"}, false},
-		{types.SignedMeterValue{SigningMethod: "ECDSAP256SHA256", EncodingMethod: "DLMS Message", PublicKey: "0xd34dc0de"}, false},
-		{types.SignedMeterValue{SignedMeterData: ">2500................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................", SigningMethod: "ECDSAP256SHA256", EncodingMethod: "DLMS Message", PublicKey: "0xd34dc0de"}, false},
-		{types.SignedMeterValue{SignedMeterData: "0xdeadbeef", SigningMethod: ">50................................................", EncodingMethod: "DLMS Message", PublicKey: "0xd34dc0de"}, false},
-		{types.SignedMeterValue{SignedMeterData: "0xdeadbeef", SigningMethod: "ECDSAP256SHA256", EncodingMethod: ">50................................................", PublicKey: "0xd34dc0de"}, false},
-		{types.SignedMeterValue{SignedMeterData: "0xdeadbeef", SigningMethod: "ECDSAP256SHA256", EncodingMethod: "DLMS Message", PublicKey: ">2500................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................"}, false},
+		{types.SignedMeterValue{SignedMeterData: "3q2+7w==", SigningMethod: "ECDSAP256SHA256", EncodingMethod: "DLMS Message", PublicKey: "001NwN4="}, true},
+		{types.SignedMeterValue{SignedMeterData: "3q2+7w==", SigningMethod: "ECDSAP256SHA256", EncodingMethod: "DLMS Message"}, false},
+		{types.SignedMeterValue{SignedMeterData: "3q2+7w==", SigningMethod: "ECDSAP256SHA256", PublicKey: "001NwN4="}, false},
+		{types.SignedMeterValue{SignedMeterData: "3q2+7w==", EncodingMethod: "DLMS Message", PublicKey: "001NwN4="}, false},
+		{types.SignedMeterValue{SigningMethod: "ECDSAP256SHA256", EncodingMethod: "DLMS Message", PublicKey: "001NwN4="}, false},
+		{types.SignedMeterValue{SignedMeterData: "0xdeadbeef", SigningMethod: "ECDSAP256SHA256", EncodingMethod: "DLMS Message", PublicKey: "001NwN4="}, false},
+		{types.SignedMeterValue{SignedMeterData: "3q2+7w==", SigningMethod: "ECDSAP256SHA256", EncodingMethod: "DLMS Message", PublicKey: "0xd34dc0de"}, false},
+		{types.SignedMeterValue{SignedMeterData: ">2500................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................", SigningMethod: "ECDSAP256SHA256", EncodingMethod: "DLMS Message", PublicKey: "001NwN4="}, false},
+		{types.SignedMeterValue{SignedMeterData: "3q2+7w==", SigningMethod: ">50................................................", EncodingMethod: "DLMS Message", PublicKey: "001NwN4="}, false},
+		{types.SignedMeterValue{SignedMeterData: "3q2+7w==", SigningMethod: "ECDSAP256SHA256", EncodingMethod: ">50................................................", PublicKey: "001NwN4="}, false},
+		{types.SignedMeterValue{SignedMeterData: "3q2+7w==", SigningMethod: "ECDSAP256SHA256", EncodingMethod: "DLMS Message", PublicKey: ">2500................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................................"}, false},
 	}
 	ExecuteGenericTestTable(t, testTable)
 }
@@ -225,10 +227,10 @@ func (suite *OcppV2TestSuite) TestSignedMeterValue() {
 func (suite *OcppV2TestSuite) TestSampledValueValidation() {
 	t := suite.T()
 	signedMeterValue := types.SignedMeterValue{
-		SignedMeterData: "0xdeadbeef",
+		SignedMeterData: "3q2+7w==",
 		SigningMethod:   "ECDSAP256SHA256",
 		EncodingMethod:  "DLMS Message",
-		PublicKey:       "0xd34dc0de",
+		PublicKey:       "001NwN4=",
 	}
 	var testTable = []GenericTestEntry{
 		{types.SampledValue{Value: 3.14, Context: types.ReadingContextTransactionEnd, Measurand: types.MeasurandPowerActiveExport, Phase: types.PhaseL2, Location: types.LocationBody, SignedMeterValue: &signedMeterValue, UnitOfMeasure: &types.UnitOfMeasure{Unit: "kW", Multiplier: newInt(0)}}, true},
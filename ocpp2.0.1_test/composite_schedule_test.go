@@ -0,0 +1,227 @@
+package ocpp2_test
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/smartcharging"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+type expectedCompositePeriod struct {
+	startPeriod  int
+	limit        float64
+	numberPhases *int
+}
+
+// Returns an absolute profile with a single schedule, starting at the given time.
+func newCompositeTestProfile(id int, stackLevel int, purpose types.ChargingProfilePurposeType, start time.Time, duration *int, unit types.ChargingRateUnitType, periods ...types.ChargingSchedulePeriod) types.ChargingProfile {
+	schedule := types.NewChargingSchedule(id, unit, periods...)
+	schedule.StartSchedule = types.NewDateTime(start)
+	schedule.Duration = duration
+	return *types.NewChargingProfile(id, stackLevel, purpose, types.ChargingProfileKindAbsolute, []types.ChargingSchedule{*schedule})
+}
+
+func assertCompositePeriods(suite *OcppV2TestSuite, composite *smartcharging.CompositeSchedule, expected []expectedCompositePeriod) {
+	t := suite.T()
+	require.NotNil(t, composite)
+	require.NotNil(t, composite.ChargingSchedule)
+	require.Len(t, composite.ChargingSchedule.ChargingSchedulePeriod, len(expected))
+	for i, period := range composite.ChargingSchedule.ChargingSchedulePeriod {
+		assert.Equal(t, expected[i].startPeriod, period.StartPeriod, "period %v", i)
+		assert.Equal(t, expected[i].limit, period.Limit, "period %v", i)
+		assert.Equal(t, expected[i].numberPhases, period.NumberPhases, "period %v", i)
+	}
+}
+
+func (suite *OcppV2TestSuite) TestComputeComposite201Stacking() {
+	t := suite.T()
+	day := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	topology := smartcharging.StationTopology{Voltage: 230, Phases: 3, MaxCurrent: 32}
+	// Daily default of 16A, overridden by a higher stack level in the morning
+	dailyDefault := newCompositeTestProfile(1, 0, types.ChargingProfilePurposeTxDefaultProfile, day, nil, types.ChargingRateUnitAmperes, types.NewChargingSchedulePeriod(0, 16))
+	dailyDefault.ChargingProfileKind = types.ChargingProfileKindRecurring
+	dailyDefault.RecurrencyKind = types.RecurrencyKindDaily
+	morningDefault := newCompositeTestProfile(2, 1, types.ChargingProfilePurposeTxDefaultProfile, day.Add(32*time.Hour), newInt(4*3600), types.ChargingRateUnitAmperes, types.NewChargingSchedulePeriod(0, 10))
+	// The transaction profile overrides any default profile, regardless of its stack level
+	txProfile := newCompositeTestProfile(3, 0, types.ChargingProfilePurposeTxProfile, day.Add(34*time.Hour), newInt(3600), types.ChargingRateUnitAmperes, types.NewChargingSchedulePeriod(0, 6))
+	txProfile.TransactionID = "tx-1"
+	// The station maximum of 11kW is equivalent to 15.94A on three phases
+	maxProfile := newCompositeTestProfile(4, 0, types.ChargingProfilePurposeChargingStationMaxProfile, day, nil, types.ChargingRateUnitWatts, types.NewChargingSchedulePeriod(0, 11000))
+	profiles := []types.ChargingProfile{dailyDefault, morningDefault, txProfile, maxProfile}
+	from := day.Add(31 * time.Hour)
+	composite, err := smartcharging.ComputeComposite201(profiles, 1, from, 6*time.Hour, types.ChargingRateUnitAmperes, topology)
+	require.NoError(t, err)
+	assert.Equal(t, from, composite.StartDateTime.Time)
+	assert.Equal(t, types.ChargingRateUnitAmperes, composite.ChargingSchedule.ChargingRateUnit)
+	require.NotNil(t, composite.ChargingSchedule.Duration)
+	assert.Equal(t, 6*3600, *composite.ChargingSchedule.Duration)
+	assertCompositePeriods(suite, composite, []expectedCompositePeriod{
+		{0, 15.9, nil},
+		{3600, 10, nil},
+		{3 * 3600, 6, nil},
+		{4 * 3600, 10, nil},
+		{5 * 3600, 15.9, nil},
+	})
+	// The same schedule, reported in W
+	composite, err = smartcharging.ComputeComposite201(profiles, 1, from, 6*time.Hour, types.ChargingRateUnitWatts, topology)
+	require.NoError(t, err)
+	assertCompositePeriods(suite, composite, []expectedCompositePeriod{
+		{0, 11000, nil},
+		{3600, 6900, nil},
+		{3 * 3600, 4140, nil},
+		{4 * 3600, 6900, nil},
+		{5 * 3600, 11000, nil},
+	})
+}
+
+func (suite *OcppV2TestSuite) TestComputeComposite201StationLimits() {
+	t := suite.T()
+	from := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	topology := smartcharging.StationTopology{Voltage: 230, Phases: 3, EVSECount: 2, MaxCurrent: 32}
+	maxProfile := newCompositeTestProfile(1, 0, types.ChargingProfilePurposeChargingStationMaxProfile, from, nil, types.ChargingRateUnitAmperes, types.NewChargingSchedulePeriod(0, 32))
+	externalProfile := newCompositeTestProfile(2, 0, types.ChargingProfilePurposeChargingStationExternalConstraints, from.Add(time.Hour), newInt(3600), types.ChargingRateUnitAmperes, types.NewChargingSchedulePeriod(0, 20))
+	singlePhase := types.NewChargingSchedulePeriod(0, 20)
+	singlePhase.NumberPhases = newInt(1)
+	txProfile := newCompositeTestProfile(3, 0, types.ChargingProfilePurposeTxProfile, from.Add(2*time.Hour), nil, types.ChargingRateUnitAmperes, singlePhase)
+	txProfile.TransactionID = "tx-1"
+	profiles := []types.ChargingProfile{maxProfile, externalProfile, txProfile}
+	// Station-wide limits are shared by both EVSEs
+	composite, err := smartcharging.ComputeComposite201(profiles, 1, from, 3*time.Hour, types.ChargingRateUnitAmperes, topology)
+	require.NoError(t, err)
+	assertCompositePeriods(suite, composite, []expectedCompositePeriod{
+		{0, 16, nil},
+		{3600, 10, nil},
+		{7200, 20, newInt(1)},
+	})
+	// The station schedule applies the transaction limit to every EVSE
+	composite, err = smartcharging.ComputeComposite201(profiles, 0, from, 3*time.Hour, types.ChargingRateUnitAmperes, topology)
+	require.NoError(t, err)
+	assertCompositePeriods(suite, composite, []expectedCompositePeriod{
+		{0, 32, nil},
+		{3600, 20, nil},
+		{7200, 40, newInt(1)},
+	})
+}
+
+func (suite *OcppV2TestSuite) TestComputeComposite201RecurringAndRelative() {
+	t := suite.T()
+	from := time.Date(2021, 3, 8, 12, 0, 0, 0, time.UTC)
+	topology := smartcharging.StationTopology{Voltage: 230, MaxCurrent: 32}
+	// Weekly default, started a week earlier and expiring after one hour
+	weeklyDefault := newCompositeTestProfile(1, 0, types.ChargingProfilePurposeTxDefaultProfile, from.Add(-7*24*time.Hour), nil, types.ChargingRateUnitAmperes, types.NewChargingSchedulePeriod(0, 16))
+	weeklyDefault.ChargingProfileKind = types.ChargingProfileKindRecurring
+	weeklyDefault.RecurrencyKind = types.RecurrencyKindWeekly
+	weeklyDefault.ValidTo = types.NewDateTime(from.Add(time.Hour))
+	// Relative profiles start with the window
+	relativeProfile := newCompositeTestProfile(2, 0, types.ChargingProfilePurposeTxProfile, from, newInt(1800), types.ChargingRateUnitAmperes, types.NewChargingSchedulePeriod(0, 8))
+	relativeProfile.ChargingProfileKind = types.ChargingProfileKindRelative
+	relativeProfile.ChargingSchedule[0].StartSchedule = nil
+	relativeProfile.TransactionID = "tx-1"
+	composite, err := smartcharging.ComputeComposite201([]types.ChargingProfile{weeklyDefault, relativeProfile}, 1, from, 2*time.Hour, types.ChargingRateUnitAmperes, topology)
+	require.NoError(t, err)
+	assertCompositePeriods(suite, composite, []expectedCompositePeriod{
+		{0, 8, nil},
+		{1800, 16, nil},
+		{3600, 32, nil},
+	})
+}
+
+func (suite *OcppV2TestSuite) TestComputeComposite201InvalidInput() {
+	t := suite.T()
+	topology := smartcharging.StationTopology{Voltage: 230, MaxCurrent: 32}
+	_, err := smartcharging.ComputeComposite201(nil, 1, time.Now(), time.Hour, types.ChargingRateUnitAmperes, smartcharging.StationTopology{MaxCurrent: 32})
+	assert.ErrorIs(t, err, smartcharging.ErrInvalidTopology)
+	_, err = smartcharging.ComputeComposite201(nil, 1, time.Now(), time.Hour, types.ChargingRateUnitAmperes, smartcharging.StationTopology{Voltage: 230, Phases: 4, MaxCurrent: 32})
+	assert.ErrorIs(t, err, smartcharging.ErrInvalidTopology)
+	_, err = smartcharging.ComputeComposite201(nil, 1, time.Now(), 0, types.ChargingRateUnitAmperes, topology)
+	assert.Error(t, err)
+	_, err = smartcharging.ComputeComposite201(nil, -1, time.Now(), time.Hour, types.ChargingRateUnitAmperes, topology)
+	assert.Error(t, err)
+	_, err = smartcharging.ComputeComposite201(nil, 1, time.Now(), time.Hour, "kW", topology)
+	assert.Error(t, err)
+}
+
+// Returns the limit in A of the active profile with the highest stack level, among absolute three-phase profiles.
+func prevailingTestLimit(profiles []types.ChargingProfile, purpose types.ChargingProfilePurposeType, at time.Time, voltage float64) (float64, bool) {
+	limit, found, stackLevel := 0.0, false, 0
+	for _, profile := range profiles {
+		schedule := profile.ChargingSchedule[0]
+		offset := int(at.Sub(schedule.StartSchedule.Time) / time.Second)
+		if profile.ChargingProfilePurpose != purpose || offset < 0 || offset >= *schedule.Duration || (found && profile.StackLevel <= stackLevel) {
+			continue
+		}
+		for _, period := range schedule.ChargingSchedulePeriod {
+			if period.StartPeriod <= offset {
+				limit = period.Limit
+			}
+		}
+		if schedule.ChargingRateUnit == types.ChargingRateUnitWatts {
+			limit = limit / (voltage * 3)
+		}
+		found, stackLevel = true, profile.StackLevel
+	}
+	return limit, found
+}
+
+func (suite *OcppV2TestSuite) TestComputeComposite201NeverExceedsLimits() {
+	t := suite.T()
+	random := rand.New(rand.NewSource(42))
+	from := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	window := 6 * time.Hour
+	topology := smartcharging.StationTopology{Voltage: 230, Phases: 3, MaxCurrent: 32}
+	purposes := []types.ChargingProfilePurposeType{
+		types.ChargingProfilePurposeChargingStationMaxProfile,
+		types.ChargingProfilePurposeChargingStationExternalConstraints,
+		types.ChargingProfilePurposeTxDefaultProfile,
+		types.ChargingProfilePurposeTxProfile,
+	}
+	for i := 0; i < 100; i++ {
+		var profiles []types.ChargingProfile
+		for _, purpose := range purposes {
+			count := random.Intn(3)
+			for stackLevel := 0; stackLevel < count; stackLevel++ {
+				unit := types.ChargingRateUnitAmperes
+				scale := 1.0
+				if random.Intn(2) == 0 {
+					unit, scale = types.ChargingRateUnitWatts, 690
+				}
+				periods := []types.ChargingSchedulePeriod{types.NewChargingSchedulePeriod(0, float64(random.Intn(40))*scale)}
+				for startPeriod := 0; len(periods) < 4; {
+					startPeriod += 60 * (1 + random.Intn(120))
+					periods = append(periods, types.NewChargingSchedulePeriod(startPeriod, float64(random.Intn(40))*scale))
+				}
+				start := from.Add(time.Duration(random.Intn(5*3600)-3600) * time.Second)
+				profiles = append(profiles, newCompositeTestProfile(len(profiles)+1, stackLevel, purpose, start, newInt(3600*(1+random.Intn(4))), unit, periods...))
+			}
+		}
+		composite, err := smartcharging.ComputeComposite201(profiles, 1, from, window, types.ChargingRateUnitAmperes, topology)
+		require.NoError(t, err)
+		periods := composite.ChargingSchedule.ChargingSchedulePeriod
+		require.NotEmpty(t, periods)
+		require.Equal(t, 0, periods[0].StartPeriod)
+		for offset := 0; offset < int(window/time.Second); offset += 300 {
+			var actual float64
+			for _, period := range periods {
+				if period.StartPeriod <= offset {
+					actual = period.Limit
+				}
+			}
+			at := from.Add(time.Duration(offset) * time.Second)
+			assert.LessOrEqual(t, actual, topology.MaxCurrent)
+			assert.GreaterOrEqual(t, actual, 0.0)
+			contributing := purposes[:3]
+			if _, ok := prevailingTestLimit(profiles, types.ChargingProfilePurposeTxProfile, at, topology.Voltage); ok {
+				contributing = []types.ChargingProfilePurposeType{purposes[0], purposes[1], purposes[3]}
+			}
+			for _, purpose := range contributing {
+				if limit, ok := prevailingTestLimit(profiles, purpose, at, topology.Voltage); ok {
+					assert.LessOrEqual(t, actual, limit+1e-9, "iteration %v, offset %v, purpose %v", i, offset, purpose)
+				}
+			}
+		}
+	}
+}
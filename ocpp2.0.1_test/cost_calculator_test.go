@@ -0,0 +1,165 @@
+package ocpp2_test
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/tariffcost"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+type energyReading struct {
+	timestamp time.Time
+	wh        float64
+}
+
+// Extracts the energy register readings from the accumulated meter values.
+func energyReadings(history tariffcost.TransactionSnapshot) []energyReading {
+	var readings []energyReading
+	for _, meterValue := range history.MeterValues {
+		for _, sampledValue := range meterValue.SampledValue {
+			if sampledValue.Measurand == "" || sampledValue.Measurand == types.MeasurandEnergyActiveImportRegister {
+				readings = append(readings, energyReading{timestamp: meterValue.Timestamp.Time, wh: sampledValue.Value})
+			}
+		}
+	}
+	return readings
+}
+
+// Charges a fixed price per kWh, once the transaction ended.
+type flatRateCalculator struct {
+	pricePerKWh float64
+}
+
+func (c *flatRateCalculator) Cost(event *transactions.TransactionEventRequest, history tariffcost.TransactionSnapshot) (*float64, error) {
+	if event.EventType != transactions.TransactionEventEnded {
+		return nil, nil
+	}
+	readings := energyReadings(history)
+	if len(readings) < 2 {
+		return nil, errors.New("not enough meter values")
+	}
+	cost := (readings[len(readings)-1].wh - readings[0].wh) / 1000 * c.pricePerKWh
+	return &cost, nil
+}
+
+// Charges a peak price for energy consumed in intervals starting within peak hours, and an off-peak price otherwise.
+// Running costs are reported with every event.
+type timeOfUseCalculator struct {
+	peakStart    int
+	peakEnd      int
+	peakPrice    float64
+	offPeakPrice float64
+	currencies   []string
+}
+
+func (c *timeOfUseCalculator) Cost(event *transactions.TransactionEventRequest, history tariffcost.TransactionSnapshot) (*float64, error) {
+	c.currencies = append(c.currencies, history.Currency)
+	readings := energyReadings(history)
+	if len(readings) == 0 {
+		return nil, errors.New("no meter values")
+	}
+	cost := 0.0
+	for i := 1; i < len(readings); i++ {
+		price := c.offPeakPrice
+		if hour := readings[i-1].timestamp.UTC().Hour(); hour >= c.peakStart && hour < c.peakEnd {
+			price = c.peakPrice
+		}
+		cost += (readings[i].wh - readings[i-1].wh) / 1000 * price
+	}
+	cost = math.Round(cost*100) / 100
+	return &cost, nil
+}
+
+func (suite *OcppV2TestSuite) setupCostCalculatorTest(handlerResponse *transactions.TransactionEventResponse) {
+	t := suite.T()
+	wsId := "test_id"
+	wsUrl := "someUrl"
+	channel := NewMockWebSocket(wsId)
+	handler := &MockCSMSTransactionsHandler{}
+	handler.On("OnTransactionEvent", mock.AnythingOfType("string"), mock.Anything).Return(handlerResponse, nil)
+	setupDefaultCSMSHandlers(suite, expectedCSMSOptions{clientId: wsId, forwardWrittenMessage: true}, handler)
+	setupDefaultChargingStationHandlers(suite, expectedChargingStationOptions{serverUrl: wsUrl, clientId: wsId, createChannelOnStart: true, channel: channel, forwardWrittenMessage: true})
+	suite.csms.Start(8887, "somePath")
+	err := suite.chargingStation.Start(wsUrl)
+	require.Nil(t, err)
+}
+
+func (suite *OcppV2TestSuite) sendMeteredTransactionEvent(eventType transactions.TransactionEvent, seqNo int, timestamp time.Time, wh float64) *transactions.TransactionEventResponse {
+	meterValue := types.MeterValue{Timestamp: *types.NewDateTime(timestamp), SampledValue: []types.SampledValue{{Value: wh, Measurand: types.MeasurandEnergyActiveImportRegister}}}
+	response, err := suite.chargingStation.TransactionEvent(eventType, types.NewDateTime(timestamp), transactions.TriggerReasonMeterValuePeriodic, seqNo, transactions.Transaction{TransactionID: "tx1"}, func(request *transactions.TransactionEventRequest) {
+		request.MeterValue = []types.MeterValue{meterValue}
+	})
+	require.NoError(suite.T(), err)
+	require.NotNil(suite.T(), response)
+	return response
+}
+
+func (suite *OcppV2TestSuite) TestCostCalculatorFlatRate() {
+	t := suite.T()
+	suite.setupCostCalculatorTest(transactions.NewTransactionEventResponse())
+	suite.csms.SetCostCalculator(&flatRateCalculator{pricePerKWh: 0.3}, "EUR")
+	start := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	response := suite.sendMeteredTransactionEvent(transactions.TransactionEventStarted, 0, start, 1000)
+	assert.Nil(t, response.TotalCost)
+	response = suite.sendMeteredTransactionEvent(transactions.TransactionEventUpdated, 1, start.Add(time.Hour), 6000)
+	assert.Nil(t, response.TotalCost)
+	response = suite.sendMeteredTransactionEvent(transactions.TransactionEventEnded, 2, start.Add(2*time.Hour), 13000)
+	require.NotNil(t, response.TotalCost)
+	assert.InDelta(t, 3.6, *response.TotalCost, 0.0001)
+}
+
+func (suite *OcppV2TestSuite) TestCostCalculatorTimeOfUse() {
+	t := suite.T()
+	suite.setupCostCalculatorTest(transactions.NewTransactionEventResponse())
+	calculator := &timeOfUseCalculator{peakStart: 8, peakEnd: 20, peakPrice: 0.4, offPeakPrice: 0.2}
+	suite.csms.SetCostCalculator(calculator, "CHF")
+	start := time.Date(2023, 5, 1, 19, 0, 0, 0, time.UTC)
+	response := suite.sendMeteredTransactionEvent(transactions.TransactionEventStarted, 0, start, 0)
+	require.NotNil(t, response.TotalCost)
+	assert.Equal(t, 0.0, *response.TotalCost)
+	// 10 kWh during peak hours
+	response = suite.sendMeteredTransactionEvent(transactions.TransactionEventUpdated, 1, start.Add(time.Hour), 10000)
+	require.NotNil(t, response.TotalCost)
+	assert.Equal(t, 4.0, *response.TotalCost)
+	// 5 kWh during off-peak hours
+	response = suite.sendMeteredTransactionEvent(transactions.TransactionEventEnded, 2, start.Add(2*time.Hour), 15000)
+	require.NotNil(t, response.TotalCost)
+	assert.Equal(t, 5.0, *response.TotalCost)
+	assert.Equal(t, []string{"CHF", "CHF", "CHF"}, calculator.currencies)
+}
+
+func (suite *OcppV2TestSuite) TestCostCalculatorErrorOmitsCost() {
+	t := suite.T()
+	suite.setupCostCalculatorTest(transactions.NewTransactionEventResponse())
+	suite.csms.SetCostCalculator(&flatRateCalculator{pricePerKWh: 0.3}, "EUR")
+	errC := suite.csms.Errors()
+	// A single meter value isn't enough for the calculation
+	response := suite.sendMeteredTransactionEvent(transactions.TransactionEventEnded, 0, time.Now(), 1000)
+	assert.Nil(t, response.TotalCost)
+	select {
+	case err := <-errC:
+		assert.ErrorContains(t, err, "not enough meter values")
+	case <-time.After(time.Second):
+		t.Fatal("calculation error not reported")
+	}
+}
+
+func (suite *OcppV2TestSuite) TestCostCalculatorHandlerCostPrevails() {
+	t := suite.T()
+	handlerResponse := transactions.NewTransactionEventResponse()
+	handlerResponse.TotalCost = newFloat(1.23)
+	suite.setupCostCalculatorTest(handlerResponse)
+	suite.csms.SetCostCalculator(&flatRateCalculator{pricePerKWh: 0.3}, "EUR")
+	start := time.Now()
+	suite.sendMeteredTransactionEvent(transactions.TransactionEventStarted, 0, start, 0)
+	response := suite.sendMeteredTransactionEvent(transactions.TransactionEventEnded, 1, start.Add(time.Hour), 10000)
+	require.NotNil(t, response.TotalCost)
+	assert.Equal(t, 1.23, *response.TotalCost)
+}
@@ -0,0 +1,191 @@
+package ocpp2_test
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/tariffcost"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+type fakeCostClock struct {
+	now   time.Time
+	mutex sync.Mutex
+}
+
+func (c *fakeCostClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *fakeCostClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+type sentCostUpdate struct {
+	chargingStationID string
+	request           *tariffcost.CostUpdatedRequest
+}
+
+// Charges a fixed price per Wh, based on the last energy reading.
+type runningCostCalculator struct{}
+
+func (c runningCostCalculator) Cost(event *transactions.TransactionEventRequest, history tariffcost.TransactionSnapshot) (*float64, error) {
+	if len(history.MeterValues) == 0 {
+		return nil, nil
+	}
+	last := history.MeterValues[len(history.MeterValues)-1]
+	cost := last.SampledValue[0].Value * 0.001
+	return &cost, nil
+}
+
+type costUpdaterTest struct {
+	clock   *fakeCostClock
+	tracker *transactions.EventTracker
+	updater *tariffcost.CostUpdater
+	sent    []sentCostUpdate
+	errors  []error
+	failing map[string]bool
+}
+
+func newCostUpdaterTest(interval time.Duration) *costUpdaterTest {
+	test := &costUpdaterTest{
+		clock:   &fakeCostClock{now: time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)},
+		tracker: transactions.NewEventTracker(nil, nil),
+		failing: map[string]bool{},
+	}
+	test.updater = tariffcost.NewCostUpdater(test.tracker, runningCostCalculator{}, interval, func(chargingStationID string, request *tariffcost.CostUpdatedRequest) error {
+		if test.failing[chargingStationID] {
+			return errors.New("connection lost")
+		}
+		test.sent = append(test.sent, sentCostUpdate{chargingStationID: chargingStationID, request: request})
+		return nil
+	})
+	test.updater.OnError = func(chargingStationID string, transactionID string, err error) {
+		test.errors = append(test.errors, err)
+	}
+	test.updater.SetTimeSource(test.clock.Now)
+	return test
+}
+
+func (test *costUpdaterTest) event(chargingStationID string, eventType transactions.TransactionEvent, seqNo int, wh float64) {
+	request := transactions.NewTransactionEventRequest(eventType, types.NewDateTime(test.clock.Now()), transactions.TriggerReasonMeterValuePeriodic, seqNo, transactions.Transaction{TransactionID: "tx-" + chargingStationID})
+	request.MeterValue = []types.MeterValue{{Timestamp: *types.NewDateTime(test.clock.Now()), SampledValue: []types.SampledValue{{Value: wh}}}}
+	_ = test.tracker.Add(chargingStationID, request)
+}
+
+func (test *costUpdaterTest) sentTo(chargingStationID string) []float64 {
+	var costs []float64
+	for _, update := range test.sent {
+		if update.chargingStationID == chargingStationID {
+			costs = append(costs, update.request.TotalCost)
+		}
+	}
+	return costs
+}
+
+func (suite *OcppV2TestSuite) TestCostUpdaterCadence() {
+	t := suite.T()
+	test := newCostUpdaterTest(time.Minute)
+	test.event("cs1", transactions.TransactionEventStarted, 0, 1000)
+	assert.Equal(t, 1, test.updater.ActiveTransactions())
+	// Not due yet
+	test.clock.Advance(30 * time.Second)
+	test.updater.Tick()
+	assert.Empty(t, test.sent)
+	test.clock.Advance(30 * time.Second)
+	test.updater.Tick()
+	require.Len(t, test.sent, 1)
+	assert.Equal(t, "tx-cs1", test.sent[0].request.TransactionID)
+	assert.Equal(t, 1.0, test.sent[0].request.TotalCost)
+	// Further ticks within the interval don't send
+	test.clock.Advance(59 * time.Second)
+	test.updater.Tick()
+	assert.Len(t, test.sent, 1)
+	test.event("cs1", transactions.TransactionEventUpdated, 1, 5000)
+	test.clock.Advance(time.Second)
+	test.updater.Tick()
+	assert.Equal(t, []float64{1.0, 5.0}, test.sentTo("cs1"))
+	// Forced updates reschedule the next periodic update
+	test.clock.Advance(30 * time.Second)
+	require.NoError(t, test.updater.ForceUpdate("cs1", "tx-cs1"))
+	test.clock.Advance(45 * time.Second)
+	test.updater.Tick()
+	assert.Len(t, test.sent, 3)
+	test.clock.Advance(15 * time.Second)
+	test.updater.Tick()
+	assert.Len(t, test.sent, 4)
+	assert.Error(t, test.updater.ForceUpdate("cs1", "unknown"))
+}
+
+func (suite *OcppV2TestSuite) TestCostUpdaterStopsOnEnd() {
+	t := suite.T()
+	test := newCostUpdaterTest(time.Minute)
+	test.event("cs1", transactions.TransactionEventStarted, 0, 1000)
+	test.event("cs2", transactions.TransactionEventStarted, 0, 2000)
+	assert.Equal(t, 2, test.updater.ActiveTransactions())
+	test.event("cs1", transactions.TransactionEventEnded, 1, 3000)
+	assert.Equal(t, 1, test.updater.ActiveTransactions())
+	test.updater.OnChargingStationDisconnected("cs2")
+	assert.Equal(t, 0, test.updater.ActiveTransactions())
+	test.clock.Advance(5 * time.Minute)
+	test.updater.Tick()
+	assert.Empty(t, test.sent)
+	assert.Error(t, test.updater.ForceUpdate("cs1", "tx-cs1"))
+}
+
+func (suite *OcppV2TestSuite) TestCostUpdaterSendFailures() {
+	t := suite.T()
+	test := newCostUpdaterTest(time.Minute)
+	test.event("cs1", transactions.TransactionEventStarted, 0, 1000)
+	test.event("cs2", transactions.TransactionEventStarted, 0, 2000)
+	test.failing["cs2"] = true
+	for i := 0; i < 3; i++ {
+		test.clock.Advance(time.Minute)
+		test.updater.Tick()
+	}
+	// Failures of one station don't affect other stations
+	assert.Equal(t, []float64{1.0, 1.0, 1.0}, test.sentTo("cs1"))
+	assert.Len(t, test.errors, 3)
+	// The failing station recovers
+	test.failing["cs2"] = false
+	test.clock.Advance(time.Minute)
+	test.updater.Tick()
+	assert.Equal(t, []float64{2.0}, test.sentTo("cs2"))
+	assert.Equal(t, 2, test.updater.ActiveTransactions())
+}
+
+func (suite *OcppV2TestSuite) TestCostUpdaterStartStop() {
+	t := suite.T()
+	tracker := transactions.NewEventTracker(nil, nil)
+	sentC := make(chan *tariffcost.CostUpdatedRequest, 10)
+	updater := tariffcost.NewCostUpdater(tracker, runningCostCalculator{}, 20*time.Millisecond, func(chargingStationID string, request *tariffcost.CostUpdatedRequest) error {
+		sentC <- request
+		return nil
+	})
+	request := transactions.NewTransactionEventRequest(transactions.TransactionEventStarted, types.Now(), transactions.TriggerReasonAuthorized, 0, transactions.Transaction{TransactionID: "tx1"})
+	request.MeterValue = []types.MeterValue{{Timestamp: *types.Now(), SampledValue: []types.SampledValue{{Value: 1000}}}}
+	require.NoError(t, tracker.Add("cs1", request))
+	updater.Start()
+	select {
+	case update := <-sentC:
+		assert.Equal(t, "tx1", update.TransactionID)
+	case <-time.After(time.Second):
+		t.Fatal("no update sent")
+	}
+	updater.Stop()
+	time.Sleep(50 * time.Millisecond)
+	for len(sentC) > 0 {
+		<-sentC
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, sentC, 0)
+}
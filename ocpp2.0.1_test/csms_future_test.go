@@ -0,0 +1,144 @@
+package ocpp2_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	ocpp2 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/lorenzodonini/ocpp-go/ws"
+)
+
+func (suite *OcppV2TestSuite) TestCSMSAsyncSequentialFlow() {
+	t := suite.T()
+	wsId := "test_id"
+	wsUrl := "someUrl"
+	channel := NewMockWebSocket(wsId)
+	profile := provisioning.NetworkConnectionProfile{OCPPVersion: provisioning.OCPPVersion20, OCPPTransport: provisioning.OCPPTransportJSON, CSMSUrl: "wss://backup:443/ocpp", MessageTimeout: 30, SecurityProfile: 2, OCPPInterface: provisioning.OCPPInterfaceWired0}
+	var steps []string
+	handler := &MockChargingStationProvisioningHandler{}
+	handler.On("OnSetNetworkProfile", mock.Anything).Return(provisioning.NewSetNetworkProfileResponse(provisioning.SetNetworkProfileStatusAccepted), nil).Run(func(args mock.Arguments) {
+		request := args.Get(0).(*provisioning.SetNetworkProfileRequest)
+		assert.Equal(t, "wss://backup:443/ocpp", request.ConnectionData.CSMSUrl)
+		steps = append(steps, "SetNetworkProfile")
+	})
+	handler.On("OnReset", mock.Anything).Return(provisioning.NewResetResponse(provisioning.ResetStatusAccepted), nil).Run(func(args mock.Arguments) {
+		steps = append(steps, "Reset")
+	})
+	handler.On("OnGetBaseReport", mock.Anything).Return(provisioning.NewGetBaseReportResponse(types.GenericDeviceModelStatusAccepted), nil).Run(func(args mock.Arguments) {
+		request := args.Get(0).(*provisioning.GetBaseReportRequest)
+		assert.Equal(t, 7, request.RequestID)
+		steps = append(steps, "GetBaseReport")
+	})
+	handler.On("OnGetVariables", mock.Anything).Return((*provisioning.GetVariablesResponse)(nil), ocpp.NewError(ocppj.NotSupported, "no variables", "")).Run(func(args mock.Arguments) {
+		steps = append(steps, "GetVariables")
+	})
+	setupDefaultCSMSHandlers(suite, expectedCSMSOptions{clientId: wsId, forwardWrittenMessage: true})
+	setupDefaultChargingStationHandlers(suite, expectedChargingStationOptions{serverUrl: wsUrl, clientId: wsId, createChannelOnStart: true, channel: channel, forwardWrittenMessage: true}, handler)
+	suite.csms.Start(8887, "somePath")
+	require.NoError(t, suite.chargingStation.Start(wsUrl))
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	// Each step depends on the outcome of the previous one
+	networkProfileFuture, err := suite.csms.SetNetworkProfileAsync(wsId, 1, profile)
+	require.NoError(t, err)
+	networkProfileResponse, err := networkProfileFuture.Get(ctx)
+	require.NoError(t, err)
+	require.Equal(t, provisioning.SetNetworkProfileStatusAccepted, networkProfileResponse.Status)
+	resetFuture, err := suite.csms.ResetAsync(wsId, provisioning.ResetTypeOnIdle)
+	require.NoError(t, err)
+	resetResponse, err := resetFuture.Get(ctx)
+	require.NoError(t, err)
+	require.Equal(t, provisioning.ResetStatusAccepted, resetResponse.Status)
+	reportFuture, err := suite.csms.GetBaseReportAsync(wsId, 7, provisioning.ReportTypeFullInventory)
+	require.NoError(t, err)
+	reportResponse, err := reportFuture.Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, types.GenericDeviceModelStatusAccepted, reportResponse.Status)
+	// A completed future may be read again
+	select {
+	case <-reportFuture.Done():
+	default:
+		assert.Fail(t, "future not completed")
+	}
+	reportResponse, err = reportFuture.Get(context.Background())
+	require.NoError(t, err)
+	assert.NotNil(t, reportResponse)
+	// A CALLERROR completes the future with a typed error
+	variablesFuture, err := suite.csms.GetVariablesAsync(wsId, []provisioning.GetVariableData{{Component: types.Component{Name: "OCPPCommCtrlr"}, Variable: types.Variable{Name: "HeartbeatInterval"}}})
+	require.NoError(t, err)
+	variablesResponse, err := variablesFuture.Get(ctx)
+	require.Error(t, err)
+	assert.Nil(t, variablesResponse)
+	var requestErr *ocpp2.RequestError
+	require.True(t, errors.As(err, &requestErr))
+	assert.Equal(t, wsId, requestErr.ChargingStationID)
+	assert.Equal(t, provisioning.GetVariablesFeatureName, requestErr.FeatureName)
+	var callErr *ocpp.Error
+	require.True(t, errors.As(err, &callErr))
+	assert.Equal(t, ocppj.NotSupported, callErr.Code)
+	assert.Equal(t, []string{"SetNetworkProfile", "Reset", "GetBaseReport", "GetVariables"}, steps)
+}
+
+func (suite *OcppV2TestSuite) TestCSMSAsyncDisconnection() {
+	t := suite.T()
+	wsId := "test_id"
+	channel := NewMockWebSocket(wsId)
+	setupDefaultCSMSHandlers(suite, expectedCSMSOptions{clientId: wsId})
+	disconnectedC := make(chan string, 1)
+	suite.csms.SetChargingStationDisconnectedHandler(func(chargingStation ocpp2.ChargingStationConnection) {
+		disconnectedC <- chargingStation.ID()
+	})
+	suite.csms.Start(8887, "somePath")
+	suite.mockWsServer.NewClientHandler(channel)
+	// The station never replies
+	resetFuture, err := suite.csms.ResetAsync(wsId, provisioning.ResetTypeImmediate)
+	require.NoError(t, err)
+	callbackC := make(chan error, 1)
+	err = suite.csms.GetBaseReport(wsId, func(response *provisioning.GetBaseReportResponse, err error) {
+		callbackC <- err
+	}, 1, provisioning.ReportTypeFullInventory)
+	require.NoError(t, err)
+	// Waiting may be abandoned, without completing the future
+	shortCtx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = resetFuture.Get(shortCtx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	// A disconnection fails the pending future and callback promptly
+	suite.mockWsServer.DisconnectedClientHandler(channel)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	response, err := resetFuture.Get(ctx)
+	assert.Nil(t, response)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ocpp2.ErrDisconnected))
+	var requestErr *ocpp2.RequestError
+	require.True(t, errors.As(err, &requestErr))
+	assert.Equal(t, provisioning.ResetFeatureName, requestErr.FeatureName)
+	select {
+	case err = <-callbackC:
+		var callErr *ocpp.Error
+		require.True(t, errors.As(err, &callErr))
+		assert.Equal(t, ocppj.GenericError, callErr.Code)
+		assert.True(t, errors.Is(err, ws.ErrNotConnected))
+	case <-time.After(time.Second):
+		require.Fail(t, "pending callback not invoked")
+	}
+	assert.Equal(t, wsId, <-disconnectedC)
+	assert.False(t, resetFuture.Cancel())
+	// Canceling a pending future
+	suite.mockWsServer.NewClientHandler(channel)
+	resetFuture, err = suite.csms.ResetAsync(wsId, provisioning.ResetTypeImmediate)
+	require.NoError(t, err)
+	assert.True(t, resetFuture.Cancel())
+	_, err = resetFuture.Get(ctx)
+	assert.True(t, errors.Is(err, ocpp2.ErrRequestCanceled))
+}
@@ -0,0 +1,160 @@
+package ocpp2_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/security"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+type testCA struct {
+	certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+}
+
+func newTestCA(t require.TestingT) testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return testCA{certificate: certificate, key: key}
+}
+
+// Signs a PEM encoded CSR, returning the PEM encoded chain of the issued certificate and the CA certificate.
+func (ca testCA) sign(t require.TestingT, csrPEM string) string {
+	block, _ := pem.Decode([]byte(csrPEM))
+	require.NotNil(t, block)
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	require.NoError(t, err)
+	require.NoError(t, csr.CheckSignature())
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      csr.Subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.certificate, csr.PublicKey, ca.key)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})) +
+		string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.certificate.Raw}))
+}
+
+func parseTestCSR(t require.TestingT, csrPEM string) *x509.CertificateRequest {
+	block, _ := pem.Decode([]byte(csrPEM))
+	require.NotNil(t, block)
+	assert.Equal(t, "CERTIFICATE REQUEST", block.Type)
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	require.NoError(t, err)
+	return csr
+}
+
+// A signer that doesn't expose its private key, as is the case for HSM-backed keys.
+type opaqueSigner struct {
+	key *ecdsa.PrivateKey
+}
+
+func (s *opaqueSigner) Public() crypto.PublicKey {
+	return s.key.Public()
+}
+
+func (s *opaqueSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return s.key.Sign(rand, digest, opts)
+}
+
+func (suite *OcppV2TestSuite) TestGenerateCSR() {
+	t := suite.T()
+	ca := newTestCA(t)
+	csrPEM, key, err := security.GenerateCSR(security.CSROptions{CommonName: "CS-0001", Organization: "Some CPO", Country: "DE"})
+	require.NoError(t, err)
+	_, ok := key.(*ecdsa.PrivateKey)
+	assert.True(t, ok)
+	csr := parseTestCSR(t, csrPEM)
+	assert.Equal(t, "CS-0001", csr.Subject.CommonName)
+	assert.Equal(t, []string{"Some CPO"}, csr.Subject.Organization)
+	assert.Equal(t, []string{"DE"}, csr.Subject.Country)
+	assert.Equal(t, x509.ECDSA, csr.PublicKeyAlgorithm)
+	assert.NoError(t, types.Validate.Struct(security.NewSignCertificateRequest(csrPEM)))
+	// The signed certificate matches the pending key only
+	chain := ca.sign(t, csrPEM)
+	assert.NoError(t, security.CertificateSignedMatchesCSR(chain, key))
+	_, otherKey, err := security.GenerateCSR(security.CSROptions{CommonName: "CS-0001", Organization: "Some CPO"})
+	require.NoError(t, err)
+	assert.ErrorIs(t, security.CertificateSignedMatchesCSR(chain, otherKey), security.ErrCertificateKeyMismatch)
+	assert.Error(t, security.CertificateSignedMatchesCSR("not a certificate", key))
+}
+
+func (suite *OcppV2TestSuite) TestGenerateCSRKeyTypes() {
+	t := suite.T()
+	ca := newTestCA(t)
+	// RSA
+	csrPEM, key, err := security.GenerateCSR(security.CSROptions{CommonName: "CS-0001", Organization: "Some CPO", KeyType: security.KeyTypeRSA})
+	require.NoError(t, err)
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	require.True(t, ok)
+	assert.Equal(t, security.DefaultRSAKeyBits, rsaKey.N.BitLen())
+	assert.Equal(t, x509.RSA, parseTestCSR(t, csrPEM).PublicKeyAlgorithm)
+	assert.NoError(t, security.CertificateSignedMatchesCSR(ca.sign(t, csrPEM), key))
+	// Externally managed key
+	ecdsaKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signer := &opaqueSigner{key: ecdsaKey}
+	csrPEM, key, err = security.GenerateCSR(security.CSROptions{CommonName: "CS-0001", Organization: "Some CPO", Signer: signer})
+	require.NoError(t, err)
+	assert.Equal(t, signer, key)
+	assert.NoError(t, security.CertificateSignedMatchesCSR(ca.sign(t, csrPEM), signer))
+	// Unsupported key type
+	_, _, err = security.GenerateCSR(security.CSROptions{CommonName: "CS-0001", Organization: "Some CPO", KeyType: "DSA"})
+	assert.Error(t, err)
+}
+
+func (suite *OcppV2TestSuite) TestGenerateCSRSubject() {
+	t := suite.T()
+	// V2G certificates with an eMAID
+	csrPEM, _, err := security.GenerateCSR(security.CSROptions{CommonName: "CS-0001", Organization: "Some CPO", CertificateType: types.V2GCertificate, EMAID: "DE8AACA2B3C4D5E6"})
+	require.NoError(t, err)
+	csr := parseTestCSR(t, csrPEM)
+	assert.Equal(t, "DE8AACA2B3C4D5E6", csr.Subject.CommonName)
+	// Domain component, as required by ISO 15118-2
+	assert.Contains(t, csr.Subject.String(), "0.9.2342.19200300.100.1.25=CPO")
+	csrPEM, _, err = security.GenerateCSR(security.CSROptions{CommonName: "CS-0001", Organization: "Some CPO", CertificateType: types.V2GCertificate})
+	require.NoError(t, err)
+	assert.Equal(t, "CS-0001", parseTestCSR(t, csrPEM).Subject.CommonName)
+	// Invalid options
+	_, _, err = security.GenerateCSR(security.CSROptions{CommonName: "CS-0001", Organization: "Some CPO", EMAID: "DE8AACA2B3C4D5E6"})
+	assert.Error(t, err)
+	_, _, err = security.GenerateCSR(security.CSROptions{Organization: "Some CPO"})
+	assert.Error(t, err)
+	_, _, err = security.GenerateCSR(security.CSROptions{CommonName: "CS-0001"})
+	assert.Error(t, err)
+	_, _, err = security.GenerateCSR(security.CSROptions{CommonName: "CS-0001", Organization: "Some CPO", CertificateType: "OtherCertificate"})
+	assert.Error(t, err)
+	// The CSR must fit into a SignCertificateRequest
+	_, _, err = security.GenerateCSR(security.CSROptions{CommonName: "CS-0001", Organization: strings.Repeat("a", 5000)})
+	assert.ErrorIs(t, err, security.ErrCSRTooLarge)
+}
@@ -0,0 +1,74 @@
+package ocpp2_test
+
+import (
+	"encoding/json"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/availability"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+)
+
+func (suite *OcppV2TestSuite) TestCustomDataValidation() {
+	var testTable = []GenericTestEntry{
+		{availability.HeartbeatRequest{}, true},
+		{availability.HeartbeatRequest{CustomData: types.NewCustomData("vendor")}, true},
+		{availability.HeartbeatRequest{CustomData: &types.CustomData{AdditionalProperties: map[string]interface{}{"key": "value"}}}, false},
+		{availability.HeartbeatRequest{CustomData: types.NewCustomData(">255............................................................................................................................................................................................................................................................")}, false},
+		{provisioning.BootNotificationRequest{Reason: provisioning.BootReasonPowerUp, ChargingStation: provisioning.ChargingStationType{Model: "model", VendorName: "vendor", CustomData: types.NewCustomData("vendor")}}, true},
+		{provisioning.BootNotificationRequest{Reason: provisioning.BootReasonPowerUp, ChargingStation: provisioning.ChargingStationType{Model: "model", VendorName: "vendor", CustomData: &types.CustomData{}}}, false},
+	}
+	ExecuteGenericTestTable(suite.T(), testTable)
+}
+
+func (suite *OcppV2TestSuite) TestCustomDataRoundTrip() {
+	t := suite.T()
+	payload := `{"chargingStation":{"customData":{"vendorId":"acme","firmwareChannel":"beta","limits":{"max":32}},"model":"m1","vendorName":"acme"},"customData":{"vendorId":"acme","tags":["a","b"]},"reason":"PowerUp"}`
+	var request provisioning.BootNotificationRequest
+	err := json.Unmarshal([]byte(payload), &request)
+	require.NoError(t, err)
+	require.NotNil(t, request.CustomData)
+	assert.Equal(t, "acme", request.CustomData.VendorID)
+	assert.Equal(t, []interface{}{"a", "b"}, request.CustomData.AdditionalProperties["tags"])
+	require.NotNil(t, request.ChargingStation.CustomData)
+	assert.Equal(t, "beta", request.ChargingStation.CustomData.AdditionalProperties["firmwareChannel"])
+	_, ok := request.CustomData.AdditionalProperties["vendorId"]
+	assert.False(t, ok)
+	// Unknown vendor keys survive re-encoding
+	encoded, err := json.Marshal(request)
+	require.NoError(t, err)
+	assert.JSONEq(t, payload, string(encoded))
+	// Messages without custom data are unaffected
+	encoded, err = json.Marshal(availability.NewHeartbeatRequest())
+	require.NoError(t, err)
+	assert.Equal(t, "{}", string(encoded))
+	// Invalid vendorId type
+	err = json.Unmarshal([]byte(`{"vendorId":42}`), &types.CustomData{})
+	assert.Error(t, err)
+}
+
+func (suite *OcppV2TestSuite) TestCustomDataParsing() {
+	t := suite.T()
+	var coercions []ocppj.Coercion
+	suite.ocppjServer.SetLenientDecoding(true, func(coercion ocppj.Coercion) {
+		coercions = append(coercions, coercion)
+	})
+	raw := `[2,"1234","StatusNotification",{"timestamp":"2023-01-01T12:00:00Z","connectorStatus":"Available","evseId":"1","connectorId":1,"customData":{"vendorId":"acme","count":"5","enabled":1,"note":null}}]`
+	var arr []interface{}
+	require.NoError(t, json.Unmarshal([]byte(raw), &arr))
+	message, err := suite.ocppjServer.ParseMessage(arr, nil)
+	require.NoError(t, err)
+	call, ok := message.(*ocppj.Call)
+	require.True(t, ok)
+	request, ok := call.Payload.(*availability.StatusNotificationRequest)
+	require.True(t, ok)
+	assert.Equal(t, 1, request.EvseID)
+	require.NotNil(t, request.CustomData)
+	// Custom data contents are passed through as-is
+	assert.Equal(t, map[string]interface{}{"count": "5", "enabled": float64(1), "note": nil}, request.CustomData.AdditionalProperties)
+	require.Len(t, coercions, 1)
+	assert.Equal(t, "evseId", coercions[0].Field)
+}
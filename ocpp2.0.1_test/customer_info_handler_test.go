@@ -0,0 +1,156 @@
+package ocpp2_test
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/diagnostics"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// Holds data keyed by idToken.
+type testCustomerDataProvider struct {
+	data     map[string][]string
+	clearErr error
+	cleared  []diagnostics.CustomerInfoCriteria
+}
+
+func (p *testCustomerDataProvider) Report(criteria diagnostics.CustomerInfoCriteria) []string {
+	if criteria.IdToken == nil {
+		return nil
+	}
+	return p.data[criteria.IdToken.IdToken]
+}
+
+func (p *testCustomerDataProvider) Clear(criteria diagnostics.CustomerInfoCriteria) error {
+	if p.clearErr != nil {
+		return p.clearErr
+	}
+	p.cleared = append(p.cleared, criteria)
+	if criteria.IdToken != nil {
+		delete(p.data, criteria.IdToken.IdToken)
+	}
+	return nil
+}
+
+// Returns all notifications of a report, up to the last one.
+func collectCustomerInformation(t require.TestingT, notifications <-chan *diagnostics.NotifyCustomerInformationRequest) []*diagnostics.NotifyCustomerInformationRequest {
+	var result []*diagnostics.NotifyCustomerInformationRequest
+	for {
+		select {
+		case notification := <-notifications:
+			result = append(result, notification)
+			if !notification.Tbc {
+				return result
+			}
+		case <-time.After(time.Second):
+			require.Fail(t, "timeout waiting for NotifyCustomerInformation")
+			return result
+		}
+	}
+}
+
+func (suite *OcppV2TestSuite) TestCustomerInfoHandler() {
+	t := suite.T()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	notifications := make(chan *diagnostics.NotifyCustomerInformationRequest, 10)
+	handler := diagnostics.NewCustomerInfoHandler(func(request *diagnostics.NotifyCustomerInformationRequest) error {
+		notifications <- request
+		return nil
+	})
+	handler.SetTimeSource(func() time.Time { return now })
+	localList := &testCustomerDataProvider{data: map[string][]string{"token1": {"Local list: token1 Accepted"}}}
+	transactions := &testCustomerDataProvider{data: map[string][]string{"token1": {strings.Repeat("a", 400), strings.Repeat("ü", 400), strings.Repeat("b", 400)}}}
+	handler.AddProvider(localList)
+	handler.AddProvider(transactions)
+	idToken := &types.IdToken{IdToken: "token1", Type: types.IdTokenTypeISO14443}
+	// Report only
+	request := diagnostics.NewCustomerInformationRequest(1, true, false)
+	request.IdToken = idToken
+	response, err := handler.OnCustomerInformation(request)
+	require.NoError(t, err)
+	assert.Equal(t, diagnostics.CustomerInformationStatusAccepted, response.Status)
+	report := collectCustomerInformation(t, notifications)
+	require.Len(t, report, 3)
+	var data strings.Builder
+	for i, notification := range report {
+		assert.Equal(t, i, notification.SeqNo)
+		assert.Equal(t, 1, notification.RequestID)
+		assert.Equal(t, i < 2, notification.Tbc)
+		assert.Equal(t, now, notification.GeneratedAt.Time)
+		assert.NoError(t, types.Validate.Struct(notification))
+		data.WriteString(notification.Data)
+	}
+	assert.Equal(t, "Local list: token1 Accepted\n"+strings.Repeat("a", 400)+"\n"+strings.Repeat("ü", 400)+"\n"+strings.Repeat("b", 400), data.String())
+	assert.Empty(t, localList.cleared)
+	assert.Len(t, transactions.data, 1)
+	// Report and clear: the report contains the data before clearing
+	request = diagnostics.NewCustomerInformationRequest(2, true, true)
+	request.IdToken = idToken
+	response, err = handler.OnCustomerInformation(request)
+	require.NoError(t, err)
+	assert.Equal(t, diagnostics.CustomerInformationStatusAccepted, response.Status)
+	report = collectCustomerInformation(t, notifications)
+	assert.Len(t, report, 3)
+	assert.Empty(t, localList.data)
+	assert.Empty(t, transactions.data)
+	// Reporting without any data found
+	request.RequestID = 3
+	response, err = handler.OnCustomerInformation(request)
+	require.NoError(t, err)
+	assert.Equal(t, diagnostics.CustomerInformationStatusAccepted, response.Status)
+	report = collectCustomerInformation(t, notifications)
+	require.Len(t, report, 1)
+	assert.Equal(t, 3, report[0].RequestID)
+	assert.NotEmpty(t, report[0].Data)
+	// Clear only, by customer identifier: nothing is reported
+	request = diagnostics.NewCustomerInformationRequest(4, false, true)
+	request.CustomerIdentifier = "customer-42"
+	response, err = handler.OnCustomerInformation(request)
+	require.NoError(t, err)
+	assert.Equal(t, diagnostics.CustomerInformationStatusAccepted, response.Status)
+	require.Len(t, localList.cleared, 3)
+	assert.Equal(t, "customer-42", localList.cleared[2].CustomerIdentifier)
+	select {
+	case notification := <-notifications:
+		assert.Fail(t, "unexpected notification", notification.Data)
+	case <-time.After(50 * time.Millisecond):
+	}
+	// Clearing fails
+	transactions.clearErr = errors.New("storage locked")
+	response, err = handler.OnCustomerInformation(request)
+	require.NoError(t, err)
+	assert.Equal(t, diagnostics.CustomerInformationStatusRejected, response.Status)
+	require.NotNil(t, response.StatusInfo)
+	assert.Equal(t, "InternalError", response.StatusInfo.ReasonCode)
+}
+
+func (suite *OcppV2TestSuite) TestCustomerInfoHandlerInvalidRequests() {
+	t := suite.T()
+	handler := diagnostics.NewCustomerInfoHandler(func(request *diagnostics.NotifyCustomerInformationRequest) error {
+		return nil
+	})
+	provider := &testCustomerDataProvider{data: map[string][]string{}}
+	handler.AddProvider(provider)
+	// No identifier
+	response, err := handler.OnCustomerInformation(diagnostics.NewCustomerInformationRequest(1, true, true))
+	require.NoError(t, err)
+	assert.Equal(t, diagnostics.CustomerInformationStatusInvalid, response.Status)
+	assert.NoError(t, types.Validate.Struct(response))
+	assert.Empty(t, provider.cleared)
+	// Neither report nor clear
+	request := diagnostics.NewCustomerInformationRequest(2, false, false)
+	request.CustomerCertificate = &types.CertificateHashData{HashAlgorithm: types.SHA256, IssuerNameHash: strings.Repeat("ab", 32), IssuerKeyHash: strings.Repeat("cd", 32), SerialNumber: "1a"}
+	response, err = handler.OnCustomerInformation(request)
+	require.NoError(t, err)
+	assert.Equal(t, diagnostics.CustomerInformationStatusInvalid, response.Status)
+	// Reports can't be sent
+	request.Report = true
+	response, err = diagnostics.NewCustomerInfoHandler(nil).OnCustomerInformation(request)
+	require.NoError(t, err)
+	assert.Equal(t, diagnostics.CustomerInformationStatusRejected, response.Status)
+}
@@ -15,7 +15,7 @@ import (
 func (suite *OcppV2TestSuite) TestCustomerInformationRequestValidation() {
 	t := suite.T()
 	var requestTable = []GenericTestEntry{
-		{diagnostics.CustomerInformationRequest{RequestID: 42, Report: true, Clear: true, CustomerIdentifier: "0001", IdToken: &types.IdToken{IdToken: "1234", Type: types.IdTokenTypeKeyCode, AdditionalInfo: nil}, CustomerCertificate: &types.CertificateHashData{HashAlgorithm: types.SHA256, IssuerNameHash: "hash00", IssuerKeyHash: "hash01", SerialNumber: "serial0"}}, true},
+		{diagnostics.CustomerInformationRequest{RequestID: 42, Report: true, Clear: true, CustomerIdentifier: "0001", IdToken: &types.IdToken{IdToken: "1234", Type: types.IdTokenTypeKeyCode, AdditionalInfo: nil}, CustomerCertificate: &types.CertificateHashData{HashAlgorithm: types.SHA256, IssuerNameHash: "abbfd6816cd185c727ada56e34741396f7174de038511f87ac34c69334999a76", IssuerKeyHash: "26ae59a9ced2093fe5a5e83312688acc1400e66fc9e858a1e1d00586545bf5e0", SerialNumber: "5e1a10"}}, true},
 		{diagnostics.CustomerInformationRequest{RequestID: 42, Report: true, Clear: true, CustomerIdentifier: "0001", IdToken: &types.IdToken{IdToken: "1234", Type: types.IdTokenTypeKeyCode, AdditionalInfo: nil}}, true},
 		{diagnostics.CustomerInformationRequest{RequestID: 42, Report: true, Clear: true, CustomerIdentifier: "0001"}, true},
 		{diagnostics.CustomerInformationRequest{RequestID: 42, Report: true, Clear: true}, true},
@@ -26,7 +26,7 @@ func (suite *OcppV2TestSuite) TestCustomerInformationRequestValidation() {
 		{diagnostics.CustomerInformationRequest{RequestID: -1, Report: true, Clear: true}, false},
 		{diagnostics.CustomerInformationRequest{RequestID: 42, Report: true, Clear: true, CustomerIdentifier: ">64.............................................................."}, false},
 		{diagnostics.CustomerInformationRequest{RequestID: 42, Report: true, Clear: true, IdToken: &types.IdToken{IdToken: "1234", Type: "invalidTokenType", AdditionalInfo: nil}}, false},
-		{diagnostics.CustomerInformationRequest{RequestID: 42, Report: true, Clear: true, CustomerCertificate: &types.CertificateHashData{HashAlgorithm: "invalidHasAlgorithm", IssuerNameHash: "hash00", IssuerKeyHash: "hash01", SerialNumber: "serial0"}}, false},
+		{diagnostics.CustomerInformationRequest{RequestID: 42, Report: true, Clear: true, CustomerCertificate: &types.CertificateHashData{HashAlgorithm: "invalidHasAlgorithm", IssuerNameHash: "abbfd6816cd185c727ada56e34741396f7174de038511f87ac34c69334999a76", IssuerKeyHash: "26ae59a9ced2093fe5a5e83312688acc1400e66fc9e858a1e1d00586545bf5e0", SerialNumber: "5e1a10"}}, false},
 	}
 	ExecuteGenericTestTable(t, requestTable)
 }
@@ -51,7 +51,7 @@ func (suite *OcppV2TestSuite) TestCustomerInformationE2EMocked() {
 	clear := true
 	customerId := "0001"
 	idToken := types.IdToken{IdToken: "1234", Type: types.IdTokenTypeKeyCode}
-	customerCertificate := types.CertificateHashData{HashAlgorithm: types.SHA256, IssuerNameHash: "hash00", IssuerKeyHash: "hash01", SerialNumber: "serial0"}
+	customerCertificate := types.CertificateHashData{HashAlgorithm: types.SHA256, IssuerNameHash: "abbfd6816cd185c727ada56e34741396f7174de038511f87ac34c69334999a76", IssuerKeyHash: "26ae59a9ced2093fe5a5e83312688acc1400e66fc9e858a1e1d00586545bf5e0", SerialNumber: "5e1a10"}
 	status := diagnostics.CustomerInformationStatusAccepted
 	requestJson := fmt.Sprintf(`[2,"%v","%v",{"requestId":%v,"report":%v,"clear":%v,"customerIdentifier":"%v","idToken":{"idToken":"%v","type":"%v"},"customerCertificate":{"hashAlgorithm":"%v","issuerNameHash":"%v","issuerKeyHash":"%v","serialNumber":"%v"}}]`,
 		messageId, diagnostics.CustomerInformationFeatureName, requestId, report, clear, customerId, idToken.IdToken, idToken.Type, customerCertificate.HashAlgorithm, customerCertificate.IssuerNameHash, customerCertificate.IssuerKeyHash, customerCertificate.SerialNumber)
@@ -0,0 +1,80 @@
+package ocpp2_test
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	ocpp2 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/availability"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/csms"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/diagnostics"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/lorenzodonini/ocpp-go/ws/wstest"
+)
+
+var _ ocpp2.RequestObserver = (*csms.StationRegistry)(nil)
+
+func (suite *OcppV2TestSuite) TestDefaultHandlers() {
+	t := suite.T()
+	server := wstest.NewServer()
+	csmsServer := ocpp2.NewCSMS(nil, server)
+	csmsServer.UseDefaultHandlers(ocpp2.DefaultHeartbeat | ocpp2.DefaultStatusNotification | ocpp2.DefaultNotifyReport)
+	registry := csms.NewStationRegistry()
+	csmsServer.SetDefaultHandlerObserver(registry)
+	station := ocpp2.NewChargingStation("station1", nil, wstest.NewClient(server))
+	require.NoError(t, wstest.ConnectInMemory(server, csmsServer, station))
+	defer csmsServer.Stop()
+	defer station.Stop()
+	// Heartbeat is answered with the current time
+	before := time.Now()
+	heartbeat, err := station.Heartbeat()
+	require.NoError(t, err)
+	assert.WithinDuration(t, before, heartbeat.CurrentTime.Time, time.Second)
+	// Status notifications and reports are applied to the registry
+	_, err = station.StatusNotification(types.Now(), availability.ConnectorStatusOccupied, 1, 1)
+	require.NoError(t, err)
+	_, err = station.NotifyReport(1, types.Now(), 0, func(request *provisioning.NotifyReportRequest) {
+		request.ReportData = []provisioning.ReportData{
+			{Component: types.Component{Name: "SmartChargingCtrlr"}, Variable: types.Variable{Name: "Enabled"}, VariableAttribute: []provisioning.VariableAttribute{{Value: "true"}}},
+		}
+	})
+	require.NoError(t, err)
+	snapshot, ok := registry.Station("station1")
+	require.True(t, ok)
+	require.Len(t, snapshot.Connectors, 1)
+	assert.Equal(t, availability.ConnectorStatusOccupied, snapshot.Connectors[0].Status)
+	value, ok := snapshot.Value("SmartChargingCtrlr", "Enabled")
+	require.True(t, ok)
+	assert.Equal(t, "true", value)
+	// Actions without an enabled default handler are still not supported
+	_, err = station.LogStatusNotification(diagnostics.UploadLogStatusUploaded, 1)
+	var ocppErr *ocpp.Error
+	require.ErrorAs(t, err, &ocppErr)
+	assert.Equal(t, ocppj.NotSupported, ocppErr.Code)
+}
+
+func (suite *OcppV2TestSuite) TestDefaultHandlersPrecedence() {
+	t := suite.T()
+	server := wstest.NewServer()
+	csmsServer := ocpp2.NewCSMS(nil, server)
+	csmsServer.UseDefaultHandlers(ocpp2.DefaultHeartbeat)
+	handlerTime := types.NewDateTime(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	handler := &MockCSMSAvailabilityHandler{}
+	handler.On("OnHeartbeat", mock.Anything, mock.Anything).Return(availability.NewHeartbeatResponse(*handlerTime), nil)
+	csmsServer.SetAvailabilityHandler(handler)
+	station := ocpp2.NewChargingStation("station1", nil, wstest.NewClient(server))
+	require.NoError(t, wstest.ConnectInMemory(server, csmsServer, station))
+	defer csmsServer.Stop()
+	defer station.Stop()
+	// The explicit handler answers the request
+	heartbeat, err := station.Heartbeat()
+	require.NoError(t, err)
+	assert.True(t, handlerTime.Equal(heartbeat.CurrentTime.Time))
+	handler.AssertExpectations(t)
+}
@@ -0,0 +1,257 @@
+package ocpp2_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+const deviceModelSeed = `[
+	{"component":{"name":"OCPPCommCtrlr"},"variable":{"name":"HeartbeatInterval"},"variableAttribute":[{"value":"60","persistent":true}],"variableCharacteristics":{"dataType":"integer","unit":"s","minLimit":1,"maxLimit":86400,"supportsMonitoring":false}},
+	{"component":{"name":"OCPPCommCtrlr"},"variable":{"name":"NetworkProfileConnectionAttempts"},"variableAttribute":[{"value":"3"}],"variableCharacteristics":{"dataType":"integer","supportsMonitoring":false},"rebootRequired":true},
+	{"component":{"name":"AuthCtrlr"},"variable":{"name":"AuthorizeRemoteStart"},"variableAttribute":[{"value":"true","mutability":"ReadOnly"}],"variableCharacteristics":{"dataType":"boolean","supportsMonitoring":false}},
+	{"component":{"name":"SecurityCtrlr"},"variable":{"name":"BasicAuthPassword"},"variableAttribute":[{"value":"secret","mutability":"WriteOnly"}],"variableCharacteristics":{"dataType":"string","maxLimit":40,"supportsMonitoring":false}},
+	{"component":{"name":"SampledDataCtrlr"},"variable":{"name":"TxUpdatedMeasurands"},"variableAttribute":[{"value":"Energy.Active.Import.Register"}],"variableCharacteristics":{"dataType":"MemberList","valuesList":"Energy.Active.Import.Register,Power.Active.Import,Current.Import","supportsMonitoring":false}},
+	{"component":{"name":"EVSE","evse":{"id":1}},"variable":{"name":"Available"},"variableAttribute":[{"value":"true","mutability":"ReadOnly"}],"variableCharacteristics":{"dataType":"boolean","supportsMonitoring":false}},
+	{"component":{"name":"EVSE","evse":{"id":1}},"variable":{"name":"Power"},"variableAttribute":[{"value":"11000"},{"type":"MaxSet","value":"22000"}],"variableCharacteristics":{"dataType":"decimal","unit":"W","supportsMonitoring":true}},
+	{"component":{"name":"EVSE","evse":{"id":2}},"variable":{"name":"Available"},"variableAttribute":[{"value":"false","mutability":"ReadOnly"}],"variableCharacteristics":{"dataType":"boolean","supportsMonitoring":false}},
+	{"component":{"name":"TxCtrlr"},"variable":{"name":"TxStartPoint"},"variableAttribute":[{"value":"PowerPathClosed"}],"variableCharacteristics":{"dataType":"OptionList","valuesList":"ParkingBayOccupancy,EVConnected,Authorized,PowerPathClosed","supportsMonitoring":false}}
+]`
+
+func newTestDeviceModelHandler(t require.TestingT) (*provisioning.DeviceModelHandler, chan *provisioning.NotifyReportRequest) {
+	model, err := provisioning.LoadDeviceModel(strings.NewReader(deviceModelSeed))
+	require.NoError(t, err)
+	reportC := make(chan *provisioning.NotifyReportRequest, 20)
+	handler := provisioning.NewDeviceModelHandler(model, func(request *provisioning.NotifyReportRequest) error {
+		reportC <- request
+		return nil
+	})
+	return handler, reportC
+}
+
+func collectReports(t require.TestingT, reportC chan *provisioning.NotifyReportRequest) []*provisioning.NotifyReportRequest {
+	var reports []*provisioning.NotifyReportRequest
+	for {
+		select {
+		case report := <-reportC:
+			reports = append(reports, report)
+			if !report.Tbc {
+				return reports
+			}
+		case <-time.After(time.Second):
+			require.Fail(t, "missing report part")
+			return nil
+		}
+	}
+}
+
+func (suite *OcppV2TestSuite) TestDeviceModelFullInventoryPagination() {
+	t := suite.T()
+	handler, reportC := newTestDeviceModelHandler(t)
+	handler.ReportPageSize = 4
+	response, err := handler.OnGetBaseReport(provisioning.NewGetBaseReportRequest(42, provisioning.ReportTypeFullInventory))
+	require.NoError(t, err)
+	assert.Equal(t, types.GenericDeviceModelStatusAccepted, response.Status)
+	reports := collectReports(t, reportC)
+	require.Len(t, reports, 3)
+	var names []string
+	for i, report := range reports {
+		assert.Equal(t, 42, report.RequestID)
+		assert.Equal(t, i, report.SeqNo)
+		assert.Equal(t, i < 2, report.Tbc)
+		require.NoError(t, types.Validate.Struct(report))
+		for _, data := range report.ReportData {
+			names = append(names, data.Component.Name+"."+data.Variable.Name)
+		}
+	}
+	assert.Len(t, reports[0].ReportData, 4)
+	assert.Len(t, reports[1].ReportData, 4)
+	assert.Len(t, reports[2].ReportData, 1)
+	assert.Equal(t, "OCPPCommCtrlr.HeartbeatInterval", names[0])
+	assert.Equal(t, "TxCtrlr.TxStartPoint", names[8])
+	// Defaults are applied and write-only values are never reported
+	data := reports[0].ReportData[0]
+	assert.Equal(t, types.AttributeActual, data.VariableAttribute[0].Type)
+	assert.Equal(t, provisioning.MutabilityReadWrite, data.VariableAttribute[0].Mutability)
+	assert.True(t, data.VariableAttribute[0].Persistent)
+	require.NotNil(t, data.VariableCharacteristics)
+	assert.Equal(t, "s", data.VariableCharacteristics.Unit)
+	data = reports[0].ReportData[3]
+	assert.Equal(t, "BasicAuthPassword", data.Variable.Name)
+	assert.Empty(t, data.VariableAttribute[0].Value)
+}
+
+func (suite *OcppV2TestSuite) TestDeviceModelBaseReportTypes() {
+	t := suite.T()
+	handler, reportC := newTestDeviceModelHandler(t)
+	response, err := handler.OnGetBaseReport(provisioning.NewGetBaseReportRequest(1, provisioning.ReportTypeConfigurationInventory))
+	require.NoError(t, err)
+	assert.Equal(t, types.GenericDeviceModelStatusAccepted, response.Status)
+	reports := collectReports(t, reportC)
+	require.Len(t, reports, 1)
+	// Read-only variables are excluded
+	assert.Len(t, reports[0].ReportData, 6)
+	response, err = handler.OnGetBaseReport(provisioning.NewGetBaseReportRequest(2, provisioning.ReportTypeSummaryInventory))
+	require.NoError(t, err)
+	assert.Equal(t, types.GenericDeviceModelStatusNotSupported, response.Status)
+	// Empty model
+	handler.Model = provisioning.NewDeviceModel()
+	response, err = handler.OnGetBaseReport(provisioning.NewGetBaseReportRequest(3, provisioning.ReportTypeFullInventory))
+	require.NoError(t, err)
+	assert.Equal(t, types.GenericDeviceModelStatusEmptyResultSet, response.Status)
+}
+
+func (suite *OcppV2TestSuite) TestDeviceModelGetReport() {
+	t := suite.T()
+	handler, reportC := newTestDeviceModelHandler(t)
+	requestID := 7
+	// Components without EVSE match all EVSEs
+	request := provisioning.NewGetReportRequest()
+	request.RequestID = &requestID
+	request.ComponentVariable = []types.ComponentVariable{{Component: types.Component{Name: "evse"}, Variable: types.Variable{Name: "available"}}}
+	response, err := handler.OnGetReport(request)
+	require.NoError(t, err)
+	assert.Equal(t, types.GenericDeviceModelStatusAccepted, response.Status)
+	reports := collectReports(t, reportC)
+	require.Len(t, reports, 1)
+	assert.Equal(t, requestID, reports[0].RequestID)
+	assert.Len(t, reports[0].ReportData, 2)
+	// Criteria filter on components
+	request = provisioning.NewGetReportRequest()
+	request.ComponentCriteria = []provisioning.ComponentCriterion{provisioning.ComponentCriterionAvailable}
+	response, err = handler.OnGetReport(request)
+	require.NoError(t, err)
+	assert.Equal(t, types.GenericDeviceModelStatusAccepted, response.Status)
+	reports = collectReports(t, reportC)
+	require.Len(t, reports, 1)
+	require.Len(t, reports[0].ReportData, 2)
+	for _, data := range reports[0].ReportData {
+		require.NotNil(t, data.Component.EVSE)
+		assert.Equal(t, 1, data.Component.EVSE.ID)
+	}
+	// No match
+	request = provisioning.NewGetReportRequest()
+	request.ComponentVariable = []types.ComponentVariable{{Component: types.Component{Name: "Unknown"}, Variable: types.Variable{Name: "x"}}}
+	response, err = handler.OnGetReport(request)
+	require.NoError(t, err)
+	assert.Equal(t, types.GenericDeviceModelStatusEmptyResultSet, response.Status)
+}
+
+func (suite *OcppV2TestSuite) TestDeviceModelGetVariables() {
+	t := suite.T()
+	handler, _ := newTestDeviceModelHandler(t)
+	evse := types.Component{Name: "EVSE", EVSE: &types.EVSE{ID: 1}}
+	request := provisioning.NewGetVariablesRequest([]provisioning.GetVariableData{
+		{Component: types.Component{Name: "ocppcommctrlr"}, Variable: types.Variable{Name: "heartbeatinterval"}},
+		{Component: evse, Variable: types.Variable{Name: "Power"}, AttributeType: types.AttributeMaxSet},
+		{Component: evse, Variable: types.Variable{Name: "Power"}, AttributeType: types.AttributeTarget},
+		{Component: types.Component{Name: "SecurityCtrlr"}, Variable: types.Variable{Name: "BasicAuthPassword"}},
+		{Component: types.Component{Name: "EVSE"}, Variable: types.Variable{Name: "Power"}},
+		{Component: evse, Variable: types.Variable{Name: "Unknown"}},
+	})
+	response, err := handler.OnGetVariables(request)
+	require.NoError(t, err)
+	require.NoError(t, types.Validate.Struct(response))
+	expected := []struct {
+		status provisioning.GetVariableStatus
+		value  string
+	}{
+		{provisioning.GetVariableStatusAccepted, "60"},
+		{provisioning.GetVariableStatusAccepted, "22000"},
+		{provisioning.GetVariableStatusNotSupported, ""},
+		{provisioning.GetVariableStatusRejected, ""},
+		{provisioning.GetVariableStatusUnknownComponent, ""},
+		{provisioning.GetVariableStatusUnknownVariable, ""},
+	}
+	require.Len(t, response.GetVariableResult, len(expected))
+	for i, result := range response.GetVariableResult {
+		assert.Equal(t, expected[i].status, result.AttributeStatus, "result %v", i)
+		assert.Equal(t, expected[i].value, result.AttributeValue, "result %v", i)
+	}
+}
+
+func (suite *OcppV2TestSuite) TestDeviceModelSetVariables() {
+	t := suite.T()
+	handler, _ := newTestDeviceModelHandler(t)
+	evse := types.Component{Name: "EVSE", EVSE: &types.EVSE{ID: 1}}
+	txCtrlr := types.Component{Name: "TxCtrlr"}
+	measurands := types.Component{Name: "SampledDataCtrlr"}
+	var testTable = []struct {
+		data           provisioning.SetVariableData
+		expectedStatus provisioning.SetVariableStatus
+		expectedValue  string
+	}{
+		{provisioning.SetVariableData{Component: txCtrlr, Variable: types.Variable{Name: "TxStartPoint"}, AttributeValue: "Unplugged"}, provisioning.SetVariableStatusRejected, "PowerPathClosed"},
+		{provisioning.SetVariableData{Component: txCtrlr, Variable: types.Variable{Name: "TxStartPoint"}, AttributeValue: "Authorized"}, provisioning.SetVariableStatusAccepted, "Authorized"},
+		{provisioning.SetVariableData{Component: measurands, Variable: types.Variable{Name: "TxUpdatedMeasurands"}, AttributeValue: "Power.Active.Import,Voltage"}, provisioning.SetVariableStatusRejected, "Energy.Active.Import.Register"},
+		{provisioning.SetVariableData{Component: measurands, Variable: types.Variable{Name: "TxUpdatedMeasurands"}, AttributeValue: "Power.Active.Import,Current.Import"}, provisioning.SetVariableStatusAccepted, "Power.Active.Import,Current.Import"},
+		{provisioning.SetVariableData{Component: types.Component{Name: "OCPPCommCtrlr"}, Variable: types.Variable{Name: "HeartbeatInterval"}, AttributeValue: "0"}, provisioning.SetVariableStatusRejected, "60"},
+		{provisioning.SetVariableData{Component: types.Component{Name: "OCPPCommCtrlr"}, Variable: types.Variable{Name: "HeartbeatInterval"}, AttributeValue: "abc"}, provisioning.SetVariableStatusRejected, "60"},
+		{provisioning.SetVariableData{Component: types.Component{Name: "OCPPCommCtrlr"}, Variable: types.Variable{Name: "HeartbeatInterval"}, AttributeValue: "300"}, provisioning.SetVariableStatusAccepted, "300"},
+		{provisioning.SetVariableData{Component: types.Component{Name: "OCPPCommCtrlr"}, Variable: types.Variable{Name: "NetworkProfileConnectionAttempts"}, AttributeValue: "5"}, provisioning.SetVariableStatusRebootRequired, "5"},
+		{provisioning.SetVariableData{Component: types.Component{Name: "AuthCtrlr"}, Variable: types.Variable{Name: "AuthorizeRemoteStart"}, AttributeValue: "false"}, provisioning.SetVariableStatusRejected, "true"},
+		{provisioning.SetVariableData{Component: evse, Variable: types.Variable{Name: "Power"}, AttributeValue: "30000"}, provisioning.SetVariableStatusRejected, "11000"},
+		{provisioning.SetVariableData{Component: evse, Variable: types.Variable{Name: "Power"}, AttributeValue: "7400.5"}, provisioning.SetVariableStatusAccepted, "7400.5"},
+		{provisioning.SetVariableData{Component: evse, Variable: types.Variable{Name: "Power"}, AttributeType: types.AttributeMinSet, AttributeValue: "1"}, provisioning.SetVariableStatusNotSupported, ""},
+		{provisioning.SetVariableData{Component: types.Component{Name: "Unknown"}, Variable: types.Variable{Name: "Power"}, AttributeValue: "1"}, provisioning.SetVariableStatusUnknownComponent, ""},
+		{provisioning.SetVariableData{Component: evse, Variable: types.Variable{Name: "Unknown"}, AttributeValue: "1"}, provisioning.SetVariableStatusUnknownVariable, ""},
+	}
+	for i, tc := range testTable {
+		response, err := handler.OnSetVariables(provisioning.NewSetVariablesRequest([]provisioning.SetVariableData{tc.data}))
+		require.NoError(t, err)
+		require.NoError(t, types.Validate.Struct(response))
+		require.Len(t, response.SetVariableResult, 1)
+		result := response.SetVariableResult[0]
+		assert.Equal(t, tc.expectedStatus, result.AttributeStatus, "test case %v", i)
+		if tc.expectedStatus == provisioning.SetVariableStatusRejected {
+			assert.NotNil(t, result.StatusInfo, "test case %v", i)
+		}
+		if tc.expectedValue != "" {
+			value, status := handler.Model.GetAttributeValue(tc.data.Component, tc.data.Variable, tc.data.AttributeType)
+			assert.Equal(t, provisioning.GetVariableStatusAccepted, status, "test case %v", i)
+			assert.Equal(t, tc.expectedValue, value, "test case %v", i)
+		}
+	}
+}
+
+func (suite *OcppV2TestSuite) TestDeviceModelReportError() {
+	t := suite.T()
+	handler, _ := newTestDeviceModelHandler(t)
+	handler.ReportPageSize = 1
+	sent := 0
+	errC := make(chan error, 1)
+	handler.SendReport = func(request *provisioning.NotifyReportRequest) error {
+		sent++
+		return errors.New("not connected")
+	}
+	handler.OnReportError = func(requestID int, err error) {
+		errC <- fmt.Errorf("report %v: %w", requestID, err)
+	}
+	response, err := handler.OnGetBaseReport(provisioning.NewGetBaseReportRequest(5, provisioning.ReportTypeFullInventory))
+	require.NoError(t, err)
+	assert.Equal(t, types.GenericDeviceModelStatusAccepted, response.Status)
+	select {
+	case err = <-errC:
+		assert.EqualError(t, err, "report 5: not connected")
+	case <-time.After(time.Second):
+		t.Fatal("report error not received")
+	}
+	assert.Equal(t, 1, sent)
+}
+
+func (suite *OcppV2TestSuite) TestDeviceModelInvalidSeed() {
+	t := suite.T()
+	_, err := provisioning.LoadDeviceModel(strings.NewReader(`{"component":{}}`))
+	assert.Error(t, err)
+	_, err = provisioning.LoadDeviceModel(strings.NewReader(`[{"component":{"name":"A"},"variable":{"name":"B"},"variableAttribute":[]}]`))
+	assert.Error(t, err)
+	_, err = provisioning.LoadDeviceModel(strings.NewReader(`[{"component":{"name":"A"},"variable":{"name":"B"},"variableAttribute":[{"value":"1"},{"type":"Actual","value":"2"}]}]`))
+	assert.Error(t, err)
+}
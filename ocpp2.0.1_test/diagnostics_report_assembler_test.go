@@ -0,0 +1,182 @@
+package ocpp2_test
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/diagnostics"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+type assembledMonitoringReport struct {
+	chargingStationID string
+	requestID         int
+	monitors          []diagnostics.MonitoringData
+	err               error
+}
+
+type assembledCustomerInformation struct {
+	chargingStationID string
+	requestID         int
+	data              string
+	err               error
+}
+
+func newTestMonitoringReportAssembler(timeout time.Duration) (*diagnostics.MonitoringReportAssembler, chan assembledMonitoringReport) {
+	resultC := make(chan assembledMonitoringReport, 10)
+	assembler := diagnostics.NewMonitoringReportAssembler(timeout, func(chargingStationID string, requestID int, monitors []diagnostics.MonitoringData, err error) {
+		resultC <- assembledMonitoringReport{chargingStationID: chargingStationID, requestID: requestID, monitors: monitors, err: err}
+	})
+	return assembler, resultC
+}
+
+func newTestCustomerInformationAssembler(timeout time.Duration) (*diagnostics.CustomerInformationAssembler, chan assembledCustomerInformation) {
+	resultC := make(chan assembledCustomerInformation, 10)
+	assembler := diagnostics.NewCustomerInformationAssembler(timeout, func(chargingStationID string, requestID int, data string, err error) {
+		resultC <- assembledCustomerInformation{chargingStationID: chargingStationID, requestID: requestID, data: data, err: err}
+	})
+	return assembler, resultC
+}
+
+func newReportedMonitors(variables ...string) []diagnostics.MonitoringData {
+	var monitors []diagnostics.MonitoringData
+	for i, variable := range variables {
+		monitors = append(monitors, diagnostics.MonitoringData{
+			Component:          types.Component{Name: "Component"},
+			Variable:           types.Variable{Name: variable},
+			VariableMonitoring: []diagnostics.VariableMonitoring{diagnostics.NewVariableMonitoring(i, false, 10, diagnostics.MonitorDelta, 5)},
+		})
+	}
+	return monitors
+}
+
+func monitoredVariableNames(monitors []diagnostics.MonitoringData) []string {
+	var names []string
+	for _, monitor := range monitors {
+		names = append(names, monitor.Variable.Name)
+	}
+	return names
+}
+
+func (suite *OcppV2TestSuite) TestSplitMonitoringReport() {
+	t := suite.T()
+	parts := diagnostics.SplitMonitoringReport(4, types.Now(), newReportedMonitors("a", "b", "c", "d", "e"), 2)
+	require.Len(t, parts, 3)
+	for i, part := range parts {
+		assert.Equal(t, 4, part.RequestID)
+		assert.Equal(t, i, part.SeqNo)
+		assert.Equal(t, i < 2, part.Tbc)
+		assert.NoError(t, types.Validate.Struct(part))
+	}
+	assert.Equal(t, []string{"e"}, monitoredVariableNames(parts[2].Monitor))
+	parts = diagnostics.SplitMonitoringReport(4, types.Now(), newReportedMonitors("a", "b"), 0)
+	require.Len(t, parts, 1)
+	assert.False(t, parts[0].Tbc)
+	assert.Nil(t, diagnostics.SplitMonitoringReport(4, types.Now(), nil, 2))
+}
+
+func (suite *OcppV2TestSuite) TestSplitCustomerInformation() {
+	t := suite.T()
+	// Multi-byte characters count as a single character
+	data := strings.Repeat("ä", 600) + strings.Repeat("b", 500)
+	parts := diagnostics.SplitCustomerInformation(2, *types.Now(), data)
+	require.Len(t, parts, 3)
+	var joined string
+	for i, part := range parts {
+		assert.Equal(t, i, part.SeqNo)
+		assert.Equal(t, i < 2, part.Tbc)
+		assert.True(t, utf8.ValidString(part.Data))
+		assert.NoError(t, types.Validate.Struct(part))
+		joined += part.Data
+	}
+	assert.Equal(t, 512, utf8.RuneCountInString(parts[0].Data))
+	assert.Equal(t, 512, utf8.RuneCountInString(parts[1].Data))
+	assert.Equal(t, 76, utf8.RuneCountInString(parts[2].Data))
+	assert.Equal(t, data, joined)
+	parts = diagnostics.SplitCustomerInformation(2, *types.Now(), "short")
+	require.Len(t, parts, 1)
+	assert.False(t, parts[0].Tbc)
+	assert.Nil(t, diagnostics.SplitCustomerInformation(2, *types.Now(), ""))
+}
+
+func (suite *OcppV2TestSuite) TestMonitoringReportAssembler() {
+	t := suite.T()
+	assembler, resultC := newTestMonitoringReportAssembler(time.Second)
+	// Single part
+	require.NoError(t, assembler.Add("cs1", diagnostics.NewNotifyMonitoringReportRequest(1, 0, types.Now(), newReportedMonitors("a"))))
+	result := <-resultC
+	assert.NoError(t, result.err)
+	assert.Equal(t, 1, result.requestID)
+	assert.Equal(t, []string{"a"}, monitoredVariableNames(result.monitors))
+	// Multiple parts, with a retransmission
+	parts := diagnostics.SplitMonitoringReport(2, types.Now(), newReportedMonitors("a", "b", "c", "d", "e"), 2)
+	require.NoError(t, assembler.Add("cs1", parts[0]))
+	require.NoError(t, assembler.Add("cs1", parts[0]))
+	require.NoError(t, assembler.Add("cs1", parts[1]))
+	assert.Equal(t, 1, assembler.PendingReports("cs1"))
+	require.NoError(t, assembler.Add("cs1", parts[2]))
+	result = <-resultC
+	assert.NoError(t, result.err)
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, monitoredVariableNames(result.monitors))
+	assert.Equal(t, 0, assembler.PendingReports("cs1"))
+	// Missing part
+	require.NoError(t, assembler.Add("cs1", parts[0]))
+	err := assembler.Add("cs1", parts[2])
+	require.IsType(t, &diagnostics.ReportSequenceError{}, err)
+	assert.Equal(t, 1, err.(*diagnostics.ReportSequenceError).ExpectedSeqNo)
+	result = <-resultC
+	assert.Equal(t, err, result.err)
+	assert.Equal(t, []string{"a", "b"}, monitoredVariableNames(result.monitors))
+	// Limits
+	assembler.SetLimits(3, 1)
+	require.NoError(t, assembler.Add("cs1", parts[0]))
+	err = assembler.Add("cs1", diagnostics.NewNotifyMonitoringReportRequest(3, 0, types.Now(), nil))
+	assert.IsType(t, &diagnostics.ReportLimitError{}, err)
+	<-resultC
+	err = assembler.Add("cs1", parts[1])
+	assert.IsType(t, &diagnostics.ReportLimitError{}, err)
+	<-resultC
+	assert.Equal(t, 0, assembler.PendingReports("cs1"))
+}
+
+func (suite *OcppV2TestSuite) TestCustomerInformationAssembler() {
+	t := suite.T()
+	assembler, resultC := newTestCustomerInformationAssembler(50 * time.Millisecond)
+	// Single part
+	require.NoError(t, assembler.Add("cs1", diagnostics.NewNotifyCustomerInformationRequest("hello", 0, *types.Now(), 1)))
+	result := <-resultC
+	assert.NoError(t, result.err)
+	assert.Equal(t, "hello", result.data)
+	// Data is concatenated in order
+	data := strings.Repeat("x", 1000) + strings.Repeat("y", 100)
+	for _, part := range diagnostics.SplitCustomerInformation(2, *types.Now(), data) {
+		require.NoError(t, assembler.Add("cs1", part))
+	}
+	result = <-resultC
+	assert.NoError(t, result.err)
+	assert.Equal(t, 2, result.requestID)
+	assert.Equal(t, data, result.data)
+	// The last part never arrives
+	parts := diagnostics.SplitCustomerInformation(3, *types.Now(), data)
+	require.NoError(t, assembler.Add("cs1", parts[0]))
+	select {
+	case result = <-resultC:
+		require.IsType(t, &diagnostics.PartialReportError{}, result.err)
+		assert.Equal(t, 1, result.err.(*diagnostics.PartialReportError).ReceivedParts)
+		assert.Equal(t, parts[0].Data, result.data)
+	case <-time.After(time.Second):
+		t.Fatal("timeout not triggered")
+	}
+	assert.Equal(t, 0, assembler.PendingRequests("cs1"))
+	// Byte limit
+	assembler.SetLimits(600, 8)
+	require.NoError(t, assembler.Add("cs1", parts[0]))
+	err := assembler.Add("cs1", parts[1])
+	assert.IsType(t, &diagnostics.ReportLimitError{}, err)
+	result = <-resultC
+	assert.Equal(t, parts[0].Data, result.data)
+}
@@ -0,0 +1,201 @@
+package ocpp2_test
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/display"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+func newStoredDisplayMessage(id int, priority display.MessagePriority, state display.MessageState, content string) display.MessageInfo {
+	return display.MessageInfo{
+		ID:       id,
+		Priority: priority,
+		State:    state,
+		Message:  types.MessageContent{Format: types.MessageFormatUTF8, Content: content},
+	}
+}
+
+func newTestMessageStore(t require.TestingT) (*display.MessageStore, chan *display.NotifyDisplayMessagesRequest) {
+	sentC := make(chan *display.NotifyDisplayMessagesRequest, 10)
+	store := display.NewMessageStore(nil, func(request *display.NotifyDisplayMessagesRequest) error {
+		sentC <- request
+		return nil
+	})
+	messages := []display.MessageInfo{
+		newStoredDisplayMessage(1, display.MessagePriorityAlwaysFront, display.MessageStateCharging, "a"),
+		newStoredDisplayMessage(2, display.MessagePriorityInFront, display.MessageStateCharging, "b"),
+		newStoredDisplayMessage(3, display.MessagePriorityInFront, display.MessageStateIdle, "c"),
+		newStoredDisplayMessage(4, display.MessagePriorityNormalCycle, "", "d"),
+	}
+	for _, message := range messages {
+		response, err := store.OnSetDisplayMessage(display.NewSetDisplayMessageRequest(message))
+		require.NoError(t, err)
+		require.Equal(t, display.DisplayMessageStatusAccepted, response.Status)
+	}
+	return store, sentC
+}
+
+// Requests display messages from the store and collects all notified messages.
+func getStoredDisplayMessages(t require.TestingT, store *display.MessageStore, sentC chan *display.NotifyDisplayMessagesRequest, props func(request *display.GetDisplayMessagesRequest)) (display.MessageStatus, []int) {
+	request := display.NewGetDisplayMessagesRequest(5)
+	if props != nil {
+		props(request)
+	}
+	response, err := store.OnGetDisplayMessages(request)
+	require.NoError(t, err)
+	if response.Status != display.MessageStatusAccepted {
+		return response.Status, nil
+	}
+	var ids []int
+	for {
+		select {
+		case notification := <-sentC:
+			require.Equal(t, 5, notification.RequestID)
+			ids = append(ids, displayMessageIDs(notification.MessageInfo)...)
+			if !notification.Tbc {
+				return response.Status, ids
+			}
+		case <-time.After(time.Second):
+			require.Fail(t, "display messages not sent")
+		}
+	}
+}
+
+func (suite *OcppV2TestSuite) TestMessageStoreFilters() {
+	t := suite.T()
+	store, sentC := newTestMessageStore(t)
+	status, ids := getStoredDisplayMessages(t, store, sentC, nil)
+	assert.Equal(t, display.MessageStatusAccepted, status)
+	assert.Equal(t, []int{1, 2, 3, 4}, ids)
+	// By ID
+	_, ids = getStoredDisplayMessages(t, store, sentC, func(request *display.GetDisplayMessagesRequest) {
+		request.ID = []int{4, 2, 99}
+	})
+	assert.Equal(t, []int{2, 4}, ids)
+	// By priority
+	_, ids = getStoredDisplayMessages(t, store, sentC, func(request *display.GetDisplayMessagesRequest) {
+		request.Priority = display.MessagePriorityInFront
+	})
+	assert.Equal(t, []int{2, 3}, ids)
+	// By state
+	_, ids = getStoredDisplayMessages(t, store, sentC, func(request *display.GetDisplayMessagesRequest) {
+		request.State = display.MessageStateCharging
+	})
+	assert.Equal(t, []int{1, 2}, ids)
+	// All criteria must match
+	_, ids = getStoredDisplayMessages(t, store, sentC, func(request *display.GetDisplayMessagesRequest) {
+		request.Priority = display.MessagePriorityInFront
+		request.State = display.MessageStateCharging
+		request.ID = []int{1, 2, 3}
+	})
+	assert.Equal(t, []int{2}, ids)
+	status, _ = getStoredDisplayMessages(t, store, sentC, func(request *display.GetDisplayMessagesRequest) {
+		request.Priority = display.MessagePriorityAlwaysFront
+		request.State = display.MessageStateIdle
+	})
+	assert.Equal(t, display.MessageStatusUnknown, status)
+	// Split into multiple notifications
+	store.ItemsPerMessage = 3
+	_, ids = getStoredDisplayMessages(t, store, sentC, nil)
+	assert.Equal(t, []int{1, 2, 3, 4}, ids)
+}
+
+func (suite *OcppV2TestSuite) TestMessageStoreReplaceAndClear() {
+	t := suite.T()
+	store, _ := newTestMessageStore(t)
+	var changes [][]display.MessageInfo
+	store.OnMessagesChanged = func(messages []display.MessageInfo) {
+		changes = append(changes, messages)
+	}
+	// Duplicate ID replaces the existing message
+	_, err := store.OnSetDisplayMessage(display.NewSetDisplayMessageRequest(newStoredDisplayMessage(2, display.MessagePriorityNormalCycle, display.MessageStateFaulted, "replaced")))
+	require.NoError(t, err)
+	messages, err := store.Messages()
+	require.NoError(t, err)
+	require.Len(t, messages, 4)
+	assert.Equal(t, "replaced", messages[1].Message.Content)
+	assert.Equal(t, display.MessagePriorityNormalCycle, messages[1].Priority)
+	require.Len(t, changes, 1)
+	// Clear
+	response, err := store.OnClearDisplay(display.NewClearDisplayRequest(2))
+	require.NoError(t, err)
+	assert.Equal(t, display.ClearMessageStatusAccepted, response.Status)
+	response, err = store.OnClearDisplay(display.NewClearDisplayRequest(2))
+	require.NoError(t, err)
+	assert.Equal(t, display.ClearMessageStatusUnknown, response.Status)
+	require.Len(t, changes, 2)
+	assert.Equal(t, []int{1, 3, 4}, displayMessageIDs(changes[1]))
+}
+
+func (suite *OcppV2TestSuite) TestMessageStoreTransactionMessages() {
+	t := suite.T()
+	store := display.NewMessageStore(nil, nil)
+	message := newStoredDisplayMessage(1, display.MessagePriorityInFront, "", "tx")
+	message.TransactionID = "tx1"
+	// No active transactions known
+	response, err := store.OnSetDisplayMessage(display.NewSetDisplayMessageRequest(message))
+	require.NoError(t, err)
+	assert.Equal(t, display.DisplayMessageStatusUnknownTransaction, response.Status)
+	store.IsTransactionActive = func(transactionID string) bool {
+		return transactionID == "tx1"
+	}
+	response, err = store.OnSetDisplayMessage(display.NewSetDisplayMessageRequest(message))
+	require.NoError(t, err)
+	assert.Equal(t, display.DisplayMessageStatusAccepted, response.Status)
+	message.TransactionID = "tx2"
+	response, err = store.OnSetDisplayMessage(display.NewSetDisplayMessageRequest(message))
+	require.NoError(t, err)
+	assert.Equal(t, display.DisplayMessageStatusUnknownTransaction, response.Status)
+	removed, err := store.OnTransactionEnded("tx1")
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, displayMessageIDs(removed))
+	messages, _ := store.Messages()
+	assert.Empty(t, messages)
+}
+
+func (suite *OcppV2TestSuite) TestMessageStoreExpiry() {
+	t := suite.T()
+	store, sentC := newTestMessageStore(t)
+	clock := &fakeCostClock{now: time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)}
+	store.SetTimeSource(clock.Now)
+	changedC := make(chan []display.MessageInfo, 10)
+	store.OnMessagesChanged = func(messages []display.MessageInfo) {
+		changedC <- messages
+	}
+	message := newStoredDisplayMessage(10, display.MessagePriorityInFront, "", "expiring")
+	message.EndDateTime = types.NewDateTime(clock.Now().Add(time.Hour))
+	_, err := store.OnSetDisplayMessage(display.NewSetDisplayMessageRequest(message))
+	require.NoError(t, err)
+	<-changedC
+	removed, err := store.RemoveExpired()
+	require.NoError(t, err)
+	assert.Empty(t, removed)
+	assert.Len(t, changedC, 0)
+	clock.Advance(time.Hour)
+	// Expired messages are never reported
+	status, _ := getStoredDisplayMessages(t, store, sentC, func(request *display.GetDisplayMessagesRequest) {
+		request.ID = []int{10}
+	})
+	assert.Equal(t, display.MessageStatusUnknown, status)
+	changed := <-changedC
+	assert.Equal(t, []int{1, 2, 3, 4}, displayMessageIDs(changed))
+	// Expiry loop
+	message.ID = 11
+	message.EndDateTime = types.NewDateTime(clock.Now().Add(time.Minute))
+	_, err = store.OnSetDisplayMessage(display.NewSetDisplayMessageRequest(message))
+	require.NoError(t, err)
+	<-changedC
+	store.Start(10 * time.Millisecond)
+	defer store.Stop()
+	clock.Advance(time.Minute)
+	select {
+	case changed = <-changedC:
+		assert.Equal(t, []int{1, 2, 3, 4}, displayMessageIDs(changed))
+	case <-time.After(time.Second):
+		t.Fatal("expired message not removed")
+	}
+}
@@ -0,0 +1,77 @@
+package ocpp2_test
+
+import (
+	"strings"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/display"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+func newValidationDisplayMessage(format types.MessageFormatType, language string, content string) display.MessageInfo {
+	return display.MessageInfo{
+		ID:       1,
+		Priority: display.MessagePriorityNormalCycle,
+		Message:  types.MessageContent{Format: format, Language: language, Content: content},
+	}
+}
+
+func (suite *OcppV2TestSuite) TestDisplayMessageLanguageTags() {
+	t := suite.T()
+	valid := []string{"en", "de", "EN-us", "zh-Hant", "zh-Hant-TW", "sr-Latn-RS", "es-419", "de-CH-1901", "sl-rozaj-biske", "zh-yue-HK", "en-a-bbb-x-a-ccc", "en-x-priv", "x-whatever", "tlh"}
+	invalid := []string{"", "e", "en-", "-en", "en--us", "123", "en-a", "en-a-x-y", "en-x", "x", "en_US", "abcdefghi", "en-US-a-toolongvalue", "é"}
+	for _, tag := range valid {
+		assert.True(t, display.IsValidLanguageTag(tag), tag)
+	}
+	for _, tag := range invalid {
+		assert.False(t, display.IsValidLanguageTag(tag), tag)
+	}
+}
+
+func (suite *OcppV2TestSuite) TestDisplayMessageContentValidation() {
+	t := suite.T()
+	now := time.Now()
+	invalidWindow := newValidationDisplayMessage(types.MessageFormatUTF8, "", "hello")
+	invalidWindow.StartDateTime = types.NewDateTime(now)
+	invalidWindow.EndDateTime = types.NewDateTime(now.Add(-time.Minute))
+	validWindow := invalidWindow
+	validWindow.EndDateTime = types.NewDateTime(now.Add(time.Minute))
+	var requestTable = []GenericTestEntry{
+		// Language
+		{display.NewSetDisplayMessageRequest(newValidationDisplayMessage(types.MessageFormatUTF8, "en-US", "hello")), true},
+		{display.NewSetDisplayMessageRequest(newValidationDisplayMessage(types.MessageFormatUTF8, "", "hello")), true},
+		{display.NewSetDisplayMessageRequest(newValidationDisplayMessage(types.MessageFormatUTF8, "en_US", "hello")), false},
+		{display.NewSetDisplayMessageRequest(newValidationDisplayMessage(types.MessageFormatUTF8, "zh-Hant-TW", "hello")), false},
+		// Format
+		{display.NewSetDisplayMessageRequest(newValidationDisplayMessage(types.MessageFormatHTML, "", "<b>hello</b>")), true},
+		{display.NewSetDisplayMessageRequest(newValidationDisplayMessage("invalidFormat", "", "hello")), false},
+		{display.NewSetDisplayMessageRequest(newValidationDisplayMessage(types.MessageFormatASCII, "", "hello")), true},
+		{display.NewSetDisplayMessageRequest(newValidationDisplayMessage(types.MessageFormatASCII, "", "grüezi")), false},
+		{display.NewSetDisplayMessageRequest(newValidationDisplayMessage(types.MessageFormatUTF8, "", "grüezi")), true},
+		// Content length
+		{display.NewSetDisplayMessageRequest(newValidationDisplayMessage(types.MessageFormatUTF8, "", strings.Repeat("a", 512))), true},
+		{display.NewSetDisplayMessageRequest(newValidationDisplayMessage(types.MessageFormatUTF8, "", strings.Repeat("a", 513))), false},
+		{display.NewSetDisplayMessageRequest(newValidationDisplayMessage(types.MessageFormatUTF8, "", "")), false},
+		// URI
+		{display.NewSetDisplayMessageRequest(newValidationDisplayMessage(types.MessageFormatURI, "", "https://example.com/message?id=1")), true},
+		{display.NewSetDisplayMessageRequest(newValidationDisplayMessage(types.MessageFormatURI, "", "example.com/message")), false},
+		{display.NewSetDisplayMessageRequest(newValidationDisplayMessage(types.MessageFormatURI, "", "https://example.com/%zz")), false},
+		{display.NewSetDisplayMessageRequest(newValidationDisplayMessage(types.MessageFormatURI, "", "https://example.com/"+strings.Repeat("a", 500))), false},
+		// Priority and state
+		{display.NewSetDisplayMessageRequest(display.MessageInfo{ID: 1, Priority: display.MessagePriorityInFront, State: display.MessageStateUnavailable, Message: types.MessageContent{Format: types.MessageFormatUTF8, Content: "hello"}}), true},
+		{display.NewSetDisplayMessageRequest(display.MessageInfo{ID: 1, Priority: display.MessagePriorityInFront, State: "invalidState", Message: types.MessageContent{Format: types.MessageFormatUTF8, Content: "hello"}}), false},
+		{display.NewSetDisplayMessageRequest(display.MessageInfo{ID: 1, Message: types.MessageContent{Format: types.MessageFormatUTF8, Content: "hello"}}), false},
+		// Display window
+		{display.NewSetDisplayMessageRequest(validWindow), true},
+		{display.NewSetDisplayMessageRequest(invalidWindow), false},
+	}
+	ExecuteGenericTestTable(t, requestTable)
+	// Messages reported by the charging station are validated as well
+	var notifyTable = []GenericTestEntry{
+		{display.NotifyDisplayMessagesRequest{RequestID: 1, MessageInfo: []display.MessageInfo{newValidationDisplayMessage(types.MessageFormatURI, "de-CH", "https://example.com")}}, true},
+		{display.NotifyDisplayMessagesRequest{RequestID: 1, MessageInfo: []display.MessageInfo{newValidationDisplayMessage(types.MessageFormatURI, "de-CH", "not a uri")}}, false},
+	}
+	ExecuteGenericTestTable(t, notifyTable)
+}
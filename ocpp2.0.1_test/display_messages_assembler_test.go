@@ -0,0 +1,170 @@
+package ocpp2_test
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/display"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+type assembledDisplayMessages struct {
+	chargingStationID string
+	requestID         int
+	messages          []display.MessageInfo
+	err               error
+}
+
+func newDisplayMessages(ids ...int) []display.MessageInfo {
+	var messages []display.MessageInfo
+	for _, id := range ids {
+		messages = append(messages, display.MessageInfo{
+			ID:       id,
+			Priority: display.MessagePriorityInFront,
+			Message:  types.MessageContent{Format: types.MessageFormatUTF8, Content: "message"},
+		})
+	}
+	return messages
+}
+
+func displayMessageIDs(messages []display.MessageInfo) []int {
+	var ids []int
+	for _, message := range messages {
+		ids = append(ids, message.ID)
+	}
+	return ids
+}
+
+func newTestMessagesAssembler(timeout time.Duration) (*display.MessagesAssembler, chan assembledDisplayMessages) {
+	resultC := make(chan assembledDisplayMessages, 10)
+	assembler := display.NewMessagesAssembler(timeout, func(chargingStationID string, requestID int, messages []display.MessageInfo, err error) {
+		resultC <- assembledDisplayMessages{chargingStationID: chargingStationID, requestID: requestID, messages: messages, err: err}
+	})
+	return assembler, resultC
+}
+
+func (suite *OcppV2TestSuite) TestSplitDisplayMessages() {
+	t := suite.T()
+	// Remainder in last part
+	parts := display.SplitDisplayMessages(7, newDisplayMessages(1, 2, 3, 4, 5), 2)
+	require.Len(t, parts, 3)
+	assert.Equal(t, []int{1, 2}, displayMessageIDs(parts[0].MessageInfo))
+	assert.Equal(t, []int{3, 4}, displayMessageIDs(parts[1].MessageInfo))
+	assert.Equal(t, []int{5}, displayMessageIDs(parts[2].MessageInfo))
+	for i, part := range parts {
+		assert.Equal(t, 7, part.RequestID)
+		assert.Equal(t, i < 2, part.Tbc)
+		assert.NoError(t, types.Validate.Struct(part))
+	}
+	// Exact multiple
+	parts = display.SplitDisplayMessages(1, newDisplayMessages(1, 2, 3, 4), 2)
+	require.Len(t, parts, 2)
+	assert.True(t, parts[0].Tbc)
+	assert.False(t, parts[1].Tbc)
+	// No limit
+	parts = display.SplitDisplayMessages(1, newDisplayMessages(1, 2, 3), 0)
+	require.Len(t, parts, 1)
+	assert.False(t, parts[0].Tbc)
+	assert.Len(t, parts[0].MessageInfo, 3)
+	// Empty result set
+	assert.Nil(t, display.SplitDisplayMessages(1, nil, 2))
+}
+
+func (suite *OcppV2TestSuite) TestMessagesAssemblerInOrder() {
+	t := suite.T()
+	assembler, resultC := newTestMessagesAssembler(time.Second)
+	for _, part := range display.SplitDisplayMessages(3, newDisplayMessages(1, 2, 3, 4, 5), 2) {
+		require.NoError(t, assembler.Add("cs1", part))
+	}
+	result := <-resultC
+	assert.NoError(t, result.err)
+	assert.Equal(t, "cs1", result.chargingStationID)
+	assert.Equal(t, 3, result.requestID)
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, displayMessageIDs(result.messages))
+	assert.Equal(t, 0, assembler.PendingRequests("cs1"))
+	// Interleaved requests
+	require.NoError(t, assembler.Add("cs1", &display.NotifyDisplayMessagesRequest{RequestID: 1, Tbc: true, MessageInfo: newDisplayMessages(1)}))
+	require.NoError(t, assembler.Add("cs1", &display.NotifyDisplayMessagesRequest{RequestID: 2, Tbc: true, MessageInfo: newDisplayMessages(10)}))
+	assert.Equal(t, 2, assembler.PendingRequests("cs1"))
+	require.NoError(t, assembler.Add("cs1", &display.NotifyDisplayMessagesRequest{RequestID: 2, MessageInfo: newDisplayMessages(11)}))
+	result = <-resultC
+	assert.Equal(t, 2, result.requestID)
+	assert.Equal(t, []int{10, 11}, displayMessageIDs(result.messages))
+	require.NoError(t, assembler.Add("cs1", &display.NotifyDisplayMessagesRequest{RequestID: 1, MessageInfo: newDisplayMessages(2)}))
+	result = <-resultC
+	assert.Equal(t, 1, result.requestID)
+	assert.Equal(t, []int{1, 2}, displayMessageIDs(result.messages))
+}
+
+func (suite *OcppV2TestSuite) TestMessagesAssemblerEmptyAndTimeout() {
+	t := suite.T()
+	assembler, resultC := newTestMessagesAssembler(50 * time.Millisecond)
+	// A single empty notification completes right away
+	require.NoError(t, assembler.Add("cs1", display.NewNotifyDisplayMessagesRequest(1)))
+	result := <-resultC
+	assert.NoError(t, result.err)
+	assert.Empty(t, result.messages)
+	// The last part never arrives
+	require.NoError(t, assembler.Add("cs1", &display.NotifyDisplayMessagesRequest{RequestID: 2, Tbc: true, MessageInfo: newDisplayMessages(1, 2)}))
+	select {
+	case result = <-resultC:
+		require.IsType(t, &display.PartialDisplayMessagesError{}, result.err)
+		assert.Equal(t, 1, result.err.(*display.PartialDisplayMessagesError).ReceivedParts)
+		assert.Equal(t, []int{1, 2}, displayMessageIDs(result.messages))
+	case <-time.After(time.Second):
+		t.Fatal("timeout not triggered")
+	}
+	// Limits
+	assembler.SetLimits(2, 1)
+	err := assembler.Add("cs1", &display.NotifyDisplayMessagesRequest{RequestID: 3, Tbc: true, MessageInfo: newDisplayMessages(1, 2, 3)})
+	assert.IsType(t, &display.DisplayMessagesLimitError{}, err)
+	<-resultC
+	assert.Equal(t, 0, assembler.PendingRequests("cs1"))
+}
+
+type displayMessagesCompletionHandler struct {
+	*MockCSMSDisplayHandler
+	resultC chan assembledDisplayMessages
+}
+
+func (h *displayMessagesCompletionHandler) OnDisplayMessagesComplete(chargingStationID string, requestID int, messages []display.MessageInfo) {
+	h.resultC <- assembledDisplayMessages{chargingStationID: chargingStationID, requestID: requestID, messages: messages}
+}
+
+func (suite *OcppV2TestSuite) TestNotifyDisplayMessagesAssembledByCSMS() {
+	t := suite.T()
+	wsId := "test_id"
+	wsUrl := "someUrl"
+	channel := NewMockWebSocket(wsId)
+	mockHandler := &MockCSMSDisplayHandler{}
+	mockHandler.On("OnNotifyDisplayMessages", mock.AnythingOfType("string"), mock.Anything).Return(display.NewNotifyDisplayMessagesResponse(), nil)
+	handler := &displayMessagesCompletionHandler{MockCSMSDisplayHandler: mockHandler, resultC: make(chan assembledDisplayMessages, 1)}
+	setupDefaultCSMSHandlers(suite, expectedCSMSOptions{clientId: wsId, forwardWrittenMessage: true})
+	setupDefaultChargingStationHandlers(suite, expectedChargingStationOptions{serverUrl: wsUrl, clientId: wsId, createChannelOnStart: true, channel: channel, forwardWrittenMessage: true})
+	suite.csms.SetDisplayHandler(handler)
+	// Run Test
+	suite.csms.Start(8887, "somePath")
+	err := suite.chargingStation.Start(wsUrl)
+	require.Nil(t, err)
+	for _, part := range display.SplitDisplayMessages(42, newDisplayMessages(1, 2, 3), 2) {
+		part := part
+		_, err = suite.chargingStation.NotifyDisplayMessages(part.RequestID, func(request *display.NotifyDisplayMessagesRequest) {
+			request.Tbc = part.Tbc
+			request.MessageInfo = part.MessageInfo
+		})
+		require.NoError(t, err)
+	}
+	select {
+	case result := <-handler.resultC:
+		assert.Equal(t, wsId, result.chargingStationID)
+		assert.Equal(t, 42, result.requestID)
+		assert.Equal(t, []int{1, 2, 3}, displayMessageIDs(result.messages))
+	case <-time.After(time.Second):
+		t.Fatal("display messages not assembled")
+	}
+	// Raw fragments are still passed to the handler
+	mockHandler.AssertNumberOfCalls(t, "OnNotifyDisplayMessages", 2)
+}
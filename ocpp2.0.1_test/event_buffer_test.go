@@ -0,0 +1,196 @@
+package ocpp2_test
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/diagnostics"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+type recordingEventSender struct {
+	requests []*diagnostics.NotifyEventRequest
+	err      error
+	mutex    sync.Mutex
+}
+
+func (s *recordingEventSender) send(request *diagnostics.NotifyEventRequest) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.requests = append(s.requests, request)
+	return nil
+}
+
+func (s *recordingEventSender) setError(err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.err = err
+}
+
+func (s *recordingEventSender) sent() []*diagnostics.NotifyEventRequest {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	requests := s.requests
+	s.requests = nil
+	return requests
+}
+
+func newBufferedEvent(value string) diagnostics.EventData {
+	return diagnostics.EventData{
+		Trigger:               diagnostics.EventTriggerAlerting,
+		ActualValue:           value,
+		EventNotificationType: diagnostics.EventHardWiredNotification,
+		Component:             types.Component{Name: "Door"},
+		Variable:              types.Variable{Name: "Open"},
+	}
+}
+
+// Returns the eventIds, seqNo and tbc flags of all sent requests.
+func sentEventBatches(requests []*diagnostics.NotifyEventRequest) (ids [][]int, seqNos []int, tbc []bool) {
+	for _, request := range requests {
+		var batch []int
+		for _, event := range request.EventData {
+			batch = append(batch, event.EventID)
+		}
+		ids = append(ids, batch)
+		seqNos = append(seqNos, request.SeqNo)
+		tbc = append(tbc, request.Tbc)
+	}
+	return
+}
+
+func (suite *OcppV2TestSuite) TestEventBufferSeverityFilter() {
+	t := suite.T()
+	sender := &recordingEventSender{}
+	buffer := diagnostics.NewEventBuffer(10, sender.send)
+	clock := &fakeCostClock{now: time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)}
+	buffer.SetTimeSource(clock.Now)
+	require.NoError(t, buffer.Add(9, newBufferedEvent("debug")))
+	buffer.SetLevel(4)
+	require.NoError(t, buffer.Add(5, newBufferedEvent("alert")))
+	require.NoError(t, buffer.Add(4, newBufferedEvent("error")))
+	require.NoError(t, buffer.Add(0, newBufferedEvent("danger")))
+	requests := sender.sent()
+	require.Len(t, requests, 3)
+	var values []string
+	for _, request := range requests {
+		require.Len(t, request.EventData, 1)
+		values = append(values, request.EventData[0].ActualValue)
+		assert.Equal(t, 0, request.SeqNo)
+		assert.False(t, request.Tbc)
+		assert.NoError(t, types.Validate.Struct(request))
+	}
+	assert.Equal(t, []string{"debug", "error", "danger"}, values)
+	// Suppressed events don't consume an eventId
+	ids, _, _ := sentEventBatches(requests)
+	assert.Equal(t, [][]int{{0}, {1}, {2}}, ids)
+	assert.Equal(t, clock.Now().Unix(), requests[0].EventData[0].Timestamp.Unix())
+}
+
+func (suite *OcppV2TestSuite) TestEventBufferBatching() {
+	t := suite.T()
+	sender := &recordingEventSender{}
+	buffer := diagnostics.NewEventBuffer(10, sender.send)
+	buffer.ItemsPerMessage = 2
+	// Exact multiple
+	require.NoError(t, buffer.Add(5, newBufferedEvent("a"), newBufferedEvent("b"), newBufferedEvent("c"), newBufferedEvent("d")))
+	ids, seqNos, tbc := sentEventBatches(sender.sent())
+	assert.Equal(t, [][]int{{0, 1}, {2, 3}}, ids)
+	assert.Equal(t, []int{0, 1}, seqNos)
+	assert.Equal(t, []bool{true, false}, tbc)
+	// Remainder, with a new sequence starting at seqNo 0
+	require.NoError(t, buffer.Add(5, newBufferedEvent("e"), newBufferedEvent("f"), newBufferedEvent("g")))
+	ids, seqNos, tbc = sentEventBatches(sender.sent())
+	assert.Equal(t, [][]int{{4, 5}, {6}}, ids)
+	assert.Equal(t, []int{0, 1}, seqNos)
+	assert.Equal(t, []bool{true, false}, tbc)
+	// Single event
+	require.NoError(t, buffer.Add(5, newBufferedEvent("h")))
+	ids, seqNos, tbc = sentEventBatches(sender.sent())
+	assert.Equal(t, [][]int{{7}}, ids)
+	assert.Equal(t, []int{0}, seqNos)
+	assert.Equal(t, []bool{false}, tbc)
+	assert.Equal(t, 0, buffer.BufferLength())
+}
+
+func (suite *OcppV2TestSuite) TestEventBufferOffline() {
+	t := suite.T()
+	sender := &recordingEventSender{}
+	buffer := diagnostics.NewEventBuffer(3, sender.send)
+	buffer.ItemsPerMessage = 2
+	var dropped []int
+	buffer.OnEventsDropped = func(count int) {
+		dropped = append(dropped, count)
+	}
+	// A failed send takes the buffer offline
+	sender.setError(errors.New("connection lost"))
+	err := buffer.Add(5, newBufferedEvent("a"))
+	assert.Equal(t, diagnostics.ErrEventsBuffered, err)
+	assert.Equal(t, 1, buffer.BufferLength())
+	sender.setError(nil)
+	// Only the newest events are retained
+	for _, value := range []string{"b", "c", "d", "e"} {
+		assert.Equal(t, diagnostics.ErrEventsBuffered, buffer.Add(5, newBufferedEvent(value)))
+	}
+	assert.Empty(t, sender.sent())
+	assert.Equal(t, 3, buffer.BufferLength())
+	assert.Equal(t, 2, buffer.DroppedEvents())
+	assert.Equal(t, []int{1, 1}, dropped)
+	buffer.OnReconnected()
+	require.Eventually(t, func() bool {
+		return buffer.BufferLength() == 0
+	}, time.Second, 5*time.Millisecond)
+	requests := sender.sent()
+	ids, seqNos, tbc := sentEventBatches(requests)
+	assert.Equal(t, [][]int{{2, 3}, {4}}, ids)
+	assert.Equal(t, []int{0, 1}, seqNos)
+	assert.Equal(t, []bool{true, false}, tbc)
+	assert.Equal(t, "c", requests[0].EventData[0].ActualValue)
+	// Shrinking the capacity drops the oldest retained events
+	buffer.OnDisconnected()
+	for _, value := range []string{"f", "g", "h"} {
+		_ = buffer.Add(5, newBufferedEvent(value))
+	}
+	buffer.SetCapacity(1)
+	assert.Equal(t, 1, buffer.BufferLength())
+	assert.Equal(t, 4, buffer.DroppedEvents())
+	buffer.OnReconnected()
+	require.Eventually(t, func() bool {
+		return buffer.BufferLength() == 0
+	}, time.Second, 5*time.Millisecond)
+	requests = sender.sent()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "h", requests[0].EventData[0].ActualValue)
+	// Newer events are sent right away
+	require.NoError(t, buffer.Add(5, newBufferedEvent("i")))
+	assert.Len(t, sender.sent(), 1)
+}
+
+func (suite *OcppV2TestSuite) TestEventBufferTriggeredMonitors() {
+	t := suite.T()
+	sender := &recordingEventSender{}
+	buffer := diagnostics.NewEventBuffer(0, sender.send)
+	buffer.SetLevel(5)
+	store, err := diagnostics.NewMonitoringStore(nil)
+	require.NoError(t, err)
+	setTestMonitors(t, store,
+		newMonitoringData(diagnostics.MonitorUpperThreshold, 10, 5),
+		newMonitoringData(diagnostics.MonitorUpperThreshold, 10, 8),
+	)
+	now := time.Now()
+	require.NoError(t, buffer.AddTriggered(store.Evaluate(monitoredComponent, monitoredVariable, 11, now)))
+	requests := sender.sent()
+	require.Len(t, requests, 1)
+	event := requests[0].EventData[0]
+	require.NotNil(t, event.VariableMonitoringID)
+	assert.Equal(t, 1, *event.VariableMonitoringID)
+	assert.Equal(t, diagnostics.EventTriggerAlerting, event.Trigger)
+	assert.NoError(t, types.Validate.Struct(requests[0]))
+}
@@ -0,0 +1,102 @@
+package ocpp2_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/iso15118"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+type stubEXIProcessor struct {
+	exiResponse   []byte
+	status        types.Certificate15118EVStatus
+	err           error
+	action        iso15118.CertificateAction
+	exiRequest    []byte
+	schemaVersion string
+	deadline      bool
+}
+
+func (p *stubEXIProcessor) ProcessCertificateInstallation(ctx context.Context, action iso15118.CertificateAction, exiRequest []byte, schemaVersion string) ([]byte, types.Certificate15118EVStatus, error) {
+	p.action = action
+	p.exiRequest = exiRequest
+	p.schemaVersion = schemaVersion
+	_, p.deadline = ctx.Deadline()
+	return p.exiResponse, p.status, p.err
+}
+
+func (suite *OcppV2TestSuite) TestEXIBridgeAccepted() {
+	t := suite.T()
+	processor := &stubEXIProcessor{exiResponse: []byte{0x80, 0x98, 0x02}, status: types.Certificate15188EVStatusAccepted}
+	bridge := iso15118.NewEXIBridge(processor, time.Second)
+	exiRequest, err := iso15118.EncodeEXIPayload([]byte{0x80, 0x9a, 0x02, 0x00})
+	require.NoError(t, err)
+	response, err := bridge.OnGet15118EVCertificate("station1", iso15118.NewGet15118EVCertificateRequest("urn:iso:15118:2:2013:MsgDef", iso15118.CertificateActionUpdate, exiRequest))
+	require.NoError(t, err)
+	assert.Equal(t, types.Certificate15188EVStatusAccepted, response.Status)
+	assert.Equal(t, "gJgC", response.ExiResponse)
+	assert.NoError(t, types.Validate.Struct(response))
+	// The processor receives the decoded request
+	assert.Equal(t, iso15118.CertificateActionUpdate, processor.action)
+	assert.Equal(t, []byte{0x80, 0x9a, 0x02, 0x00}, processor.exiRequest)
+	assert.Equal(t, "urn:iso:15118:2:2013:MsgDef", processor.schemaVersion)
+	assert.True(t, processor.deadline)
+}
+
+func (suite *OcppV2TestSuite) TestEXIBridgeFailed() {
+	t := suite.T()
+	request := iso15118.NewGet15118EVCertificateRequest("urn:iso:15118:2:2013:MsgDef", iso15118.CertificateActionInstall, "gJoCAA==")
+	// Failure reported by the processor
+	processor := &stubEXIProcessor{exiResponse: []byte{0x80, 0x98, 0x03}, status: types.Certificate15118EVStatusFailed}
+	bridge := iso15118.NewEXIBridge(processor, 0)
+	response, err := bridge.OnGet15118EVCertificate("station1", request)
+	require.NoError(t, err)
+	assert.Equal(t, types.Certificate15118EVStatusFailed, response.Status)
+	assert.Equal(t, "gJgD", response.ExiResponse)
+	assert.False(t, processor.deadline)
+	// Errors with an EXI response are mapped to Failed
+	processor.status = types.Certificate15188EVStatusAccepted
+	processor.err = errors.New("contract certificate pool unavailable")
+	response, err = bridge.OnGet15118EVCertificate("station1", request)
+	require.NoError(t, err)
+	assert.Equal(t, types.Certificate15118EVStatusFailed, response.Status)
+	assert.NoError(t, types.Validate.Struct(response))
+	// Without an EXI response, no valid response can be sent
+	processor.exiResponse = nil
+	response, err = bridge.OnGet15118EVCertificate("station1", request)
+	assert.ErrorIs(t, err, processor.err)
+	assert.Nil(t, response)
+	processor.err = nil
+	_, err = bridge.OnGet15118EVCertificate("station1", request)
+	assert.Error(t, err)
+	// EXI response too large
+	processor.exiResponse = make([]byte, 4201)
+	_, err = bridge.OnGet15118EVCertificate("station1", request)
+	assert.ErrorIs(t, err, iso15118.ErrInvalidEXIPayload)
+}
+
+func (suite *OcppV2TestSuite) TestEXIPayloadEncoding() {
+	t := suite.T()
+	raw, err := iso15118.DecodeEXIPayload("gJoCAA==")
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0x80, 0x9a, 0x02, 0x00}, raw)
+	for _, payload := range []string{"gJoCAA=", "gJo CAA==", "gJo%CAA==", newLongString(5604)} {
+		_, err = iso15118.DecodeEXIPayload(payload)
+		assert.ErrorIs(t, err, iso15118.ErrInvalidEXIPayload, payload)
+	}
+	// 4200 bytes are encoded in exactly 5600 characters
+	payload, err := iso15118.EncodeEXIPayload(make([]byte, 4200))
+	require.NoError(t, err)
+	assert.Len(t, payload, iso15118.MaxEXIPayloadLength)
+	_, err = iso15118.EncodeEXIPayload(make([]byte, 4201))
+	assert.ErrorIs(t, err, iso15118.ErrInvalidEXIPayload)
+	// Malformed payloads are rejected by the bridge
+	bridge := iso15118.NewEXIBridge(&stubEXIProcessor{}, 0)
+	_, err = bridge.OnGet15118EVCertificate("station1", iso15118.NewGet15118EVCertificateRequest("1.0", iso15118.CertificateActionInstall, "not base64!"))
+	assert.ErrorIs(t, err, iso15118.ErrInvalidEXIPayload)
+}
@@ -0,0 +1,214 @@
+package ocpp2_test
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/clock/clocktest"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/smartcharging"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+type externalLimitChange struct {
+	chargingStationID string
+	limit             smartcharging.ExternalLimit
+	cleared           bool
+}
+
+func (suite *OcppV2TestSuite) TestExternalLimitTracker() {
+	t := suite.T()
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	tracker := smartcharging.NewExternalLimitTracker()
+	tracker.SetTimeSource(func() time.Time { return now })
+	var changes []externalLimitChange
+	tracker.OnLimitChanged = func(chargingStationID string, limit smartcharging.ExternalLimit, cleared bool) {
+		changes = append(changes, externalLimitChange{chargingStationID, limit, cleared})
+	}
+	tracker.OnInconsistency = func(chargingStationID string, err error) {
+		assert.Fail(t, "unexpected inconsistency", err.Error())
+	}
+	// Set a station-wide limit and an EVSE limit with schedule
+	response, err := tracker.OnNotifyChargingLimit("station1", smartcharging.NewNotifyChargingLimitRequest(smartcharging.ChargingLimit{ChargingLimitSource: types.ChargingLimitSourceEMS}))
+	require.NoError(t, err)
+	assert.NotNil(t, response)
+	schedule := types.NewChargingSchedule(1, types.ChargingRateUnitAmperes, types.NewChargingSchedulePeriod(0, 16))
+	request := smartcharging.NewNotifyChargingLimitRequest(smartcharging.ChargingLimit{ChargingLimitSource: types.ChargingLimitSourceSO, IsGridCritical: newBool(true)})
+	request.EvseID = newInt(1)
+	request.ChargingSchedule = []types.ChargingSchedule{*schedule}
+	_, err = tracker.OnNotifyChargingLimit("station1", request)
+	require.NoError(t, err)
+	limits := tracker.ActiveLimits("station1")
+	require.Len(t, limits, 2)
+	assert.Equal(t, smartcharging.ExternalLimit{EvseID: 0, Source: types.ChargingLimitSourceEMS, UpdatedAt: now}, limits[0])
+	assert.Equal(t, 1, limits[1].EvseID)
+	assert.Equal(t, types.ChargingLimitSourceSO, limits[1].Source)
+	assert.True(t, limits[1].IsGridCritical)
+	assert.Equal(t, []types.ChargingSchedule{*schedule}, limits[1].ChargingSchedules)
+	assert.Empty(t, tracker.ActiveLimits("station2"))
+	// Update the EVSE limit
+	now = now.Add(time.Minute)
+	schedule.ChargingSchedulePeriod[0].Limit = 10
+	request.ChargingSchedule = []types.ChargingSchedule{*schedule}
+	_, err = tracker.OnNotifyChargingLimit("station1", request)
+	require.NoError(t, err)
+	limits = tracker.ActiveLimits("station1")
+	require.Len(t, limits, 2)
+	assert.Equal(t, 10.0, limits[1].ChargingSchedules[0].ChargingSchedulePeriod[0].Limit)
+	assert.Equal(t, now, limits[1].UpdatedAt)
+	// Clear the EVSE limit
+	cleared := smartcharging.NewClearedChargingLimitRequest(types.ChargingLimitSourceSO)
+	cleared.EvseID = newInt(1)
+	clearedResponse, err := tracker.OnClearedChargingLimit("station1", cleared)
+	require.NoError(t, err)
+	assert.NotNil(t, clearedResponse)
+	limits = tracker.ActiveLimits("station1")
+	require.Len(t, limits, 1)
+	assert.Equal(t, types.ChargingLimitSourceEMS, limits[0].Source)
+	// Clearing without evseId removes all limits of the source
+	_, err = tracker.OnClearedChargingLimit("station1", smartcharging.NewClearedChargingLimitRequest(types.ChargingLimitSourceEMS))
+	require.NoError(t, err)
+	assert.Empty(t, tracker.ActiveLimits("station1"))
+	require.Len(t, changes, 5)
+	assert.False(t, changes[0].cleared)
+	assert.False(t, changes[2].cleared)
+	assert.True(t, changes[3].cleared)
+	assert.Equal(t, 1, changes[3].limit.EvseID)
+	assert.True(t, changes[4].cleared)
+	assert.Equal(t, types.ChargingLimitSourceEMS, changes[4].limit.Source)
+}
+
+func (suite *OcppV2TestSuite) TestExternalLimitTrackerInconsistency() {
+	t := suite.T()
+	tracker := smartcharging.NewExternalLimitTracker()
+	var inconsistencies []error
+	tracker.OnInconsistency = func(chargingStationID string, err error) {
+		assert.Equal(t, "station1", chargingStationID)
+		inconsistencies = append(inconsistencies, err)
+	}
+	tracker.OnLimitChanged = func(chargingStationID string, limit smartcharging.ExternalLimit, cleared bool) {
+		assert.False(t, cleared)
+	}
+	// Cleared for a source never notified
+	response, err := tracker.OnClearedChargingLimit("station1", smartcharging.NewClearedChargingLimitRequest(types.ChargingLimitSourceCSO))
+	require.NoError(t, err)
+	assert.NotNil(t, response)
+	// Cleared for a notified source, but on another EVSE
+	request := smartcharging.NewNotifyChargingLimitRequest(smartcharging.ChargingLimit{ChargingLimitSource: types.ChargingLimitSourceCSO})
+	request.EvseID = newInt(1)
+	_, err = tracker.OnNotifyChargingLimit("station1", request)
+	require.NoError(t, err)
+	cleared := smartcharging.NewClearedChargingLimitRequest(types.ChargingLimitSourceCSO)
+	cleared.EvseID = newInt(2)
+	_, err = tracker.OnClearedChargingLimit("station1", cleared)
+	require.NoError(t, err)
+	require.Len(t, inconsistencies, 2)
+	for _, err := range inconsistencies {
+		assert.ErrorIs(t, err, smartcharging.ErrUnknownChargingLimit)
+	}
+	assert.Len(t, tracker.ActiveLimits("station1"), 1)
+	tracker.RemoveChargingStation("station1")
+	assert.Empty(t, tracker.ActiveLimits("station1"))
+}
+
+// Records requests sent by a ChargingLimitNotifier.
+type chargingLimitRecorder struct {
+	mutex   sync.Mutex
+	notify  []*smartcharging.NotifyChargingLimitRequest
+	cleared []*smartcharging.ClearedChargingLimitRequest
+}
+
+func (r *chargingLimitRecorder) sendNotify(request *smartcharging.NotifyChargingLimitRequest) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.notify = append(r.notify, request)
+	return nil
+}
+
+func (r *chargingLimitRecorder) sendCleared(request *smartcharging.ClearedChargingLimitRequest) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.cleared = append(r.cleared, request)
+	return nil
+}
+
+func (r *chargingLimitRecorder) counts() (int, int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.notify), len(r.cleared)
+}
+
+func (suite *OcppV2TestSuite) TestChargingLimitNotifier() {
+	t := suite.T()
+	recorder := &chargingLimitRecorder{}
+	notifier := smartcharging.NewChargingLimitNotifier(time.Hour, recorder.sendNotify, recorder.sendCleared)
+	limit := smartcharging.ChargingLimit{ChargingLimitSource: types.ChargingLimitSourceEMS}
+	// Rapid changes are merged into a single notification
+	for _, value := range []float64{16, 12, 10} {
+		notifier.SetLimit(1, limit, *types.NewChargingSchedule(1, types.ChargingRateUnitAmperes, types.NewChargingSchedulePeriod(0, value)))
+	}
+	notifier.SetLimit(0, limit)
+	notifier.Flush()
+	require.Len(t, recorder.notify, 2)
+	for _, request := range recorder.notify {
+		if request.EvseID == nil {
+			assert.Empty(t, request.ChargingSchedule)
+		} else {
+			assert.Equal(t, 1, *request.EvseID)
+			require.Len(t, request.ChargingSchedule, 1)
+			assert.Equal(t, 10.0, request.ChargingSchedule[0].ChargingSchedulePeriod[0].Limit)
+		}
+	}
+	// Unchanged limits aren't sent again
+	notifier.SetLimit(0, limit)
+	notifier.Flush()
+	assert.Len(t, recorder.notify, 2)
+	// Notified limits are cleared
+	notifier.ClearLimit(1, types.ChargingLimitSourceEMS)
+	require.Len(t, recorder.cleared, 1)
+	assert.Equal(t, newInt(1), recorder.cleared[0].EvseID)
+	assert.Equal(t, types.ChargingLimitSourceEMS, recorder.cleared[0].ChargingLimitSource)
+	// Pending limits are discarded on clear, without notifying the CSMS
+	notifier.SetLimit(2, limit)
+	notifier.ClearLimit(2, types.ChargingLimitSourceEMS)
+	notifier.Flush()
+	notify, cleared := recorder.counts()
+	assert.Equal(t, 2, notify)
+	assert.Equal(t, 1, cleared)
+}
+
+func (suite *OcppV2TestSuite) TestChargingLimitNotifierDelay() {
+	t := suite.T()
+	recorder := &chargingLimitRecorder{}
+	notifier := smartcharging.NewChargingLimitNotifier(5*time.Second, recorder.sendNotify, recorder.sendCleared)
+	fakeClock := clocktest.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	notifier.SetClock(fakeClock)
+	limit := smartcharging.ChargingLimit{ChargingLimitSource: types.ChargingLimitSourceSO}
+	notifier.SetLimit(1, limit)
+	fakeClock.Advance(4 * time.Second)
+	// Changing the limit again restarts the delay
+	notifier.SetLimit(1, limit)
+	fakeClock.Advance(4 * time.Second)
+	notify, _ := recorder.counts()
+	assert.Equal(t, 0, notify)
+	fakeClock.Advance(time.Second)
+	notify, _ = recorder.counts()
+	assert.Equal(t, 1, notify)
+	// Failed notifications aren't cleared later on
+	var sendErrors []string
+	notifier.SendNotify = func(request *smartcharging.NotifyChargingLimitRequest) error {
+		return errors.New("disconnected")
+	}
+	notifier.OnSendError = func(featureName string, err error) {
+		sendErrors = append(sendErrors, featureName)
+	}
+	notifier.SetLimit(2, limit)
+	notifier.Flush()
+	notifier.ClearLimit(2, types.ChargingLimitSourceSO)
+	assert.Equal(t, []string{smartcharging.NotifyChargingLimitFeatureName}, sendErrors)
+	_, cleared := recorder.counts()
+	assert.Equal(t, 0, cleared)
+}
@@ -0,0 +1,157 @@
+package ocpp2_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/authorization"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/availability"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/data"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/diagnostics"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/display"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/firmware"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/iso15118"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/localauth"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/meter"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/remotecontrol"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/reservation"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/security"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/smartcharging"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/tariffcost"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
+)
+
+// The string limits defined in OCPP 2.0.1 Part 2 (Appendix), keyed by Go type (package.Type) and JSON field name.
+// New messages must add their string fields to this table.
+const stringLimitsFile = "testdata/string_limits.json"
+
+type stringLimit struct {
+	MaxLength int  `json:"maxLength"`
+	Required  bool `json:"required"`
+	// The field is required by the schema, but an empty string is a valid value (e.g. idToken for NoAuthorization).
+	AllowEmpty bool `json:"allowEmpty,omitempty"`
+}
+
+// Validation tags, which are not enum validators.
+var builtinValidationTags = map[string]bool{"required": true, "omitempty": true, "dive": true, "url": true, "uri": true, "base64": true}
+
+type stringField struct {
+	typeName  string
+	jsonName  string
+	field     reflect.StructField
+	fieldType reflect.Type
+}
+
+func ocpp2Profiles() []*ocpp.Profile {
+	return []*ocpp.Profile{authorization.Profile, availability.Profile, data.Profile, diagnostics.Profile, display.Profile, firmware.Profile, iso15118.Profile, localauth.Profile, meter.Profile, provisioning.Profile, remotecontrol.Profile, reservation.Profile, security.Profile, smartcharging.Profile, tariffcost.Profile, transactions.Profile}
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+	return t
+}
+
+// Collects all string fields reachable from the request and response types of every registered feature.
+func collectStringFields() []stringField {
+	var fields []stringField
+	visited := map[reflect.Type]bool{}
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		t = derefType(t)
+		if t.Kind() != reflect.Struct || visited[t] || !strings.HasPrefix(t.PkgPath(), "github.com/lorenzodonini/ocpp-go/ocpp2.0.1") {
+			return
+		}
+		visited[t] = true
+		pkg := t.PkgPath()[strings.LastIndex(t.PkgPath(), "/")+1:]
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			jsonName := strings.Split(f.Tag.Get("json"), ",")[0]
+			if fieldType := derefType(f.Type); fieldType.Kind() == reflect.String && jsonName != "" && jsonName != "-" {
+				fields = append(fields, stringField{typeName: fmt.Sprintf("%v.%v", pkg, t.Name()), jsonName: jsonName, field: f, fieldType: fieldType})
+			}
+			walk(f.Type)
+		}
+	}
+	for _, profile := range ocpp2Profiles() {
+		for _, feature := range profile.Features {
+			walk(feature.GetRequestType())
+			walk(feature.GetResponseType())
+		}
+	}
+	return fields
+}
+
+// Returns the validation rules applying to the string values of a field. For slices, only the rules after dive are returned.
+func stringValidationRules(f stringField) []string {
+	rules := strings.Split(f.field.Tag.Get("validate"), ",")
+	if f.field.Type.Kind() == reflect.Slice {
+		for i, rule := range rules {
+			if rule == "dive" {
+				return rules[i+1:]
+			}
+		}
+		return nil
+	}
+	return rules
+}
+
+func (suite *OcppV2TestSuite) TestStringFieldLimits() {
+	t := suite.T()
+	raw, err := os.ReadFile(stringLimitsFile)
+	require.NoError(t, err)
+	var limits map[string]map[string]stringLimit
+	require.NoError(t, json.Unmarshal(raw, &limits))
+	checked := map[string]bool{}
+	for _, f := range collectStringFields() {
+		id := fmt.Sprintf("%v.%v", f.typeName, f.jsonName)
+		tag := f.field.Tag.Get("validate")
+		if f.fieldType.Name() != "string" {
+			// Enumerations must be validated against their allowed values
+			hasEnumValidator := false
+			for _, rule := range strings.Split(tag, ",") {
+				if rule != "" && !strings.Contains(rule, "=") && !builtinValidationTags[rule] {
+					hasEnumValidator = true
+				}
+			}
+			assert.True(t, hasEnumValidator, "%v of type %v has no enum validator", id, f.fieldType.Name())
+			continue
+		}
+		limit, ok := limits[f.typeName][f.jsonName]
+		if !assert.True(t, ok, "%v missing from %v", id, stringLimitsFile) {
+			continue
+		}
+		checked[id] = true
+		rules := stringValidationRules(f)
+		maxLength := -1
+		required := false
+		for _, rule := range rules {
+			if strings.HasPrefix(rule, "max=") {
+				maxLength, err = strconv.Atoi(strings.TrimPrefix(rule, "max="))
+				require.NoError(t, err)
+			} else if rule == "required" {
+				required = true
+			}
+		}
+		assert.Equal(t, limit.MaxLength, maxLength, "%v has wrong max length (tag: %v)", id, tag)
+		assert.Equal(t, limit.Required && !limit.AllowEmpty, required, "%v has wrong required rule (tag: %v)", id, tag)
+		assert.Equal(t, limit.Required, !strings.Contains(f.field.Tag.Get("json"), ",omitempty"), "%v has wrong json omitempty (tag: %v)", id, f.field.Tag.Get("json"))
+	}
+	// Stale entries in the table
+	for typeName, fields := range limits {
+		for jsonName := range fields {
+			id := fmt.Sprintf("%v.%v", typeName, jsonName)
+			assert.True(t, checked[id], "%v in %v doesn't match any registered message field", id, stringLimitsFile)
+		}
+	}
+}
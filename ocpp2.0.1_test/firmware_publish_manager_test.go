@@ -0,0 +1,150 @@
+package ocpp2_test
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/firmware"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+func newTestPublishManager(baseURLs []string) (*firmware.PublishManager, chan *firmware.PublishFirmwareStatusNotificationRequest) {
+	statusC := make(chan *firmware.PublishFirmwareStatusNotificationRequest, 10)
+	manager := firmware.NewPublishManager(baseURLs, func(request *firmware.PublishFirmwareStatusNotificationRequest) error {
+		statusC <- request
+		return nil
+	})
+	return manager, statusC
+}
+
+// Collects status notifications until a final status is received.
+func publishFirmwareStatuses(t require.TestingT, statusC chan *firmware.PublishFirmwareStatusNotificationRequest, requestID int) (statuses []firmware.PublishFirmwareStatus, locations []string) {
+	for {
+		select {
+		case request := <-statusC:
+			require.NotNil(t, request.RequestID)
+			require.Equal(t, requestID, *request.RequestID)
+			statuses = append(statuses, request.Status)
+			switch request.Status {
+			case firmware.PublishFirmwareStatusPublished, firmware.PublishFirmwareStatusDownloadFailed, firmware.PublishFirmwareStatusInvalidChecksum:
+				return statuses, request.Location
+			}
+		case <-time.After(2 * time.Second):
+			require.Fail(t, "publish firmware status not received")
+			return statuses, nil
+		}
+	}
+}
+
+func md5Checksum(content []byte) string {
+	sum := md5.Sum(content)
+	return hex.EncodeToString(sum[:])
+}
+
+func (suite *OcppV2TestSuite) TestPublishManagerPublish() {
+	t := suite.T()
+	image := []byte("firmware image")
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(image)
+	}))
+	defer origin.Close()
+	manager, statusC := newTestPublishManager(nil)
+	local := httptest.NewServer(http.StripPrefix("/firmware", manager))
+	defer local.Close()
+	manager.BaseURLs = []string{local.URL + "/firmware/"}
+	checksum := md5Checksum(image)
+	response, err := manager.OnPublishFirmware(firmware.NewPublishFirmwareRequest(origin.URL+"/images/fw.bin", checksum, 1))
+	require.NoError(t, err)
+	assert.Equal(t, types.GenericStatusAccepted, response.Status)
+	statuses, locations := publishFirmwareStatuses(t, statusC, 1)
+	assert.Equal(t, []firmware.PublishFirmwareStatus{
+		firmware.PublishFirmwareStatusDownloading,
+		firmware.PublishFirmwareStatusDownloaded,
+		firmware.PublishFirmwareStatusChecksumVerified,
+		firmware.PublishFirmwareStatusPublished,
+	}, statuses)
+	require.Equal(t, []string{local.URL + "/firmware/" + checksum + "/fw.bin"}, locations)
+	requestID, status := manager.CurrentStatus()
+	assert.Equal(t, 1, requestID)
+	assert.Equal(t, firmware.PublishFirmwareStatusPublished, status)
+	// The published firmware is served to downstream stations
+	downloaded, err := http.Get(locations[0])
+	require.NoError(t, err)
+	content, err := io.ReadAll(downloaded.Body)
+	_ = downloaded.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, downloaded.StatusCode)
+	assert.Equal(t, image, content)
+	// Unpublish
+	unpublishResponse, err := manager.OnUnpublishFirmware(firmware.NewUnpublishFirmwareRequest(md5Checksum([]byte("other"))))
+	require.NoError(t, err)
+	assert.Equal(t, firmware.UnpublishFirmwareStatusNoFirmware, unpublishResponse.Status)
+	unpublishResponse, err = manager.OnUnpublishFirmware(firmware.NewUnpublishFirmwareRequest(checksum))
+	require.NoError(t, err)
+	assert.Equal(t, firmware.UnpublishFirmwareStatusUnpublished, unpublishResponse.Status)
+	downloaded, err = http.Get(locations[0])
+	require.NoError(t, err)
+	_ = downloaded.Body.Close()
+	assert.Equal(t, http.StatusNotFound, downloaded.StatusCode)
+	_, status = manager.CurrentStatus()
+	assert.Equal(t, firmware.PublishFirmwareStatusIdle, status)
+}
+
+func (suite *OcppV2TestSuite) TestPublishManagerInvalidChecksum() {
+	t := suite.T()
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("tampered image"))
+	}))
+	defer origin.Close()
+	manager, statusC := newTestPublishManager([]string{"http://192.168.1.10/firmware"})
+	response, err := manager.OnPublishFirmware(firmware.NewPublishFirmwareRequest(origin.URL+"/fw.bin", md5Checksum([]byte("firmware image")), 2))
+	require.NoError(t, err)
+	assert.Equal(t, types.GenericStatusAccepted, response.Status)
+	statuses, locations := publishFirmwareStatuses(t, statusC, 2)
+	assert.Equal(t, []firmware.PublishFirmwareStatus{
+		firmware.PublishFirmwareStatusDownloading,
+		firmware.PublishFirmwareStatusDownloaded,
+		firmware.PublishFirmwareStatusInvalidChecksum,
+	}, statuses)
+	assert.Empty(t, locations)
+	// Malformed checksums and unsupported schemes are rejected right away
+	response, err = manager.OnPublishFirmware(firmware.NewPublishFirmwareRequest(origin.URL+"/fw.bin", "not a checksum", 3))
+	require.NoError(t, err)
+	assert.Equal(t, types.GenericStatusRejected, response.Status)
+	response, err = manager.OnPublishFirmware(firmware.NewPublishFirmwareRequest("sftp://example.com/fw.bin", md5Checksum([]byte("firmware image")), 4))
+	require.NoError(t, err)
+	assert.Equal(t, types.GenericStatusRejected, response.Status)
+}
+
+func (suite *OcppV2TestSuite) TestPublishManagerDownloadOngoing() {
+	t := suite.T()
+	image := []byte("firmware image")
+	releaseC := make(chan struct{})
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-releaseC
+		_, _ = w.Write(image)
+	}))
+	defer origin.Close()
+	manager, statusC := newTestPublishManager([]string{"http://192.168.1.10/firmware"})
+	checksum := md5Checksum(image)
+	request := firmware.NewPublishFirmwareRequest(origin.URL+"/fw.bin", checksum, 5)
+	response, err := manager.OnPublishFirmware(request)
+	require.NoError(t, err)
+	assert.Equal(t, types.GenericStatusAccepted, response.Status)
+	downloading := <-statusC
+	assert.Equal(t, firmware.PublishFirmwareStatusDownloading, downloading.Status)
+	unpublishResponse, err := manager.OnUnpublishFirmware(firmware.NewUnpublishFirmwareRequest(checksum))
+	require.NoError(t, err)
+	assert.Equal(t, firmware.UnpublishFirmwareStatusDownloadOngoing, unpublishResponse.Status)
+	close(releaseC)
+	statuses, locations := publishFirmwareStatuses(t, statusC, 5)
+	assert.Equal(t, firmware.PublishFirmwareStatusPublished, statuses[len(statuses)-1])
+	assert.Equal(t, []string{"http://192.168.1.10/firmware/" + checksum + "/fw.bin"}, locations)
+}
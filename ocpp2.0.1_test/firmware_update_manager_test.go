@@ -0,0 +1,235 @@
+package ocpp2_test
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/firmware"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+type testSigningCA struct {
+	roots              *x509.CertPool
+	signingKey         crypto.Signer
+	signingCertificate string
+}
+
+// Creates a manufacturer root and a signing certificate issued by it.
+func newTestSigningCA(t require.TestingT) *testSigningCA {
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rootTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Manufacturer Root"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	rootDER, err := x509.CreateCertificate(rand.Reader, rootTemplate, rootTemplate, rootKey.Public(), rootKey)
+	require.NoError(t, err)
+	root, err := x509.ParseCertificate(rootDER)
+	require.NoError(t, err)
+	signingKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	signingTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "Firmware Signing"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}
+	signingDER, err := x509.CreateCertificate(rand.Reader, signingTemplate, root, signingKey.Public(), rootKey)
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	return &testSigningCA{
+		roots:              roots,
+		signingKey:         signingKey,
+		signingCertificate: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: signingDER})),
+	}
+}
+
+// Returns a firmware descriptor for the image, signed by the CA's signing certificate.
+func (ca *testSigningCA) sign(t require.TestingT, location string, image []byte) firmware.Firmware {
+	digest := sha256.Sum256(image)
+	signature, err := ca.signingKey.Sign(rand.Reader, digest[:], crypto.SHA256)
+	require.NoError(t, err)
+	return firmware.Firmware{
+		Location:           location,
+		RetrieveDateTime:   types.NewDateTime(time.Now()),
+		SigningCertificate: ca.signingCertificate,
+		Signature:          base64.StdEncoding.EncodeToString(signature),
+	}
+}
+
+// Collects status notifications until a final status is received.
+func firmwareStatuses(t require.TestingT, statusC chan *firmware.FirmwareStatusNotificationRequest, requestID int) []firmware.FirmwareStatus {
+	var statuses []firmware.FirmwareStatus
+	for {
+		select {
+		case request := <-statusC:
+			require.NotNil(t, request.RequestID)
+			require.Equal(t, requestID, *request.RequestID)
+			statuses = append(statuses, request.Status)
+			switch request.Status {
+			case firmware.FirmwareStatusDownloadFailed, firmware.FirmwareStatusInvalidSignature, firmware.FirmwareStatusInstalled, firmware.FirmwareStatusInstallRebooting, firmware.FirmwareStatusInstallationFailed:
+				return statuses
+			}
+		case <-time.After(2 * time.Second):
+			require.Fail(t, "firmware status not received")
+			return statuses
+		}
+	}
+}
+
+func (suite *OcppV2TestSuite) TestFirmwareVerifySignature() {
+	t := suite.T()
+	ca := newTestSigningCA(t)
+	image := []byte("firmware image")
+	fw := ca.sign(t, "http://example.com/firmware.bin", image)
+	// Valid signature
+	assert.NoError(t, firmware.VerifySignature(fw, bytes.NewReader(image), ca.roots))
+	// Tampered image
+	err := firmware.VerifySignature(fw, bytes.NewReader([]byte("tampered image")), ca.roots)
+	assert.ErrorIs(t, err, firmware.ErrInvalidSignature)
+	// Wrong root
+	err = firmware.VerifySignature(fw, bytes.NewReader(image), newTestSigningCA(t).roots)
+	assert.ErrorIs(t, err, firmware.ErrInvalidSigningCertificate)
+	// Missing or malformed signature
+	unsigned := fw
+	unsigned.Signature = ""
+	err = firmware.VerifySignature(unsigned, bytes.NewReader(image), ca.roots)
+	assert.ErrorIs(t, err, firmware.ErrMissingSignature)
+	unsigned.Signature = "not base64!"
+	err = firmware.VerifySignature(unsigned, bytes.NewReader(image), ca.roots)
+	assert.ErrorIs(t, err, firmware.ErrInvalidSignature)
+	unsigned.Signature = fw.Signature
+	unsigned.SigningCertificate = "not a certificate"
+	err = firmware.VerifySignature(unsigned, bytes.NewReader(image), ca.roots)
+	assert.ErrorIs(t, err, firmware.ErrInvalidSigningCertificate)
+}
+
+func (suite *OcppV2TestSuite) TestFirmwareUpdateManager() {
+	t := suite.T()
+	ca := newTestSigningCA(t)
+	image := []byte("firmware image")
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The first attempt fails
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write(image)
+	}))
+	defer server.Close()
+	installedC := make(chan []byte, 1)
+	statusC := make(chan *firmware.FirmwareStatusNotificationRequest, 10)
+	manager := firmware.NewUpdateManager201(func(ctx context.Context, image []byte) (bool, error) {
+		installedC <- image
+		return true, nil
+	}, ca.roots, func(request *firmware.FirmwareStatusNotificationRequest) error {
+		statusC <- request
+		return nil
+	})
+	request := firmware.NewUpdateFirmwareRequest(1, ca.sign(t, server.URL+"/firmware.bin", image))
+	request.Retries = newInt(1)
+	request.RetryInterval = newInt(0)
+	response, err := manager.OnUpdateFirmware(request)
+	require.NoError(t, err)
+	assert.Equal(t, firmware.UpdateFirmwareStatusAccepted, response.Status)
+	assert.Equal(t, []firmware.FirmwareStatus{
+		firmware.FirmwareStatusDownloading,
+		firmware.FirmwareStatusDownloaded,
+		firmware.FirmwareStatusSignatureVerified,
+		firmware.FirmwareStatusInstalling,
+		firmware.FirmwareStatusInstallRebooting,
+	}, firmwareStatuses(t, statusC, 1))
+	assert.Equal(t, image, <-installedC)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+	// Tampered image
+	request = firmware.NewUpdateFirmwareRequest(2, ca.sign(t, server.URL+"/firmware.bin", []byte("other image")))
+	response, err = manager.OnUpdateFirmware(request)
+	require.NoError(t, err)
+	assert.Equal(t, firmware.UpdateFirmwareStatusAccepted, response.Status)
+	assert.Equal(t, []firmware.FirmwareStatus{
+		firmware.FirmwareStatusDownloading,
+		firmware.FirmwareStatusDownloaded,
+		firmware.FirmwareStatusInvalidSignature,
+	}, firmwareStatuses(t, statusC, 2))
+	// Untrusted signing certificate
+	request = firmware.NewUpdateFirmwareRequest(3, newTestSigningCA(t).sign(t, server.URL+"/firmware.bin", image))
+	response, err = manager.OnUpdateFirmware(request)
+	require.NoError(t, err)
+	assert.Equal(t, firmware.UpdateFirmwareStatusInvalidCertificate, response.Status)
+	// Unsupported scheme
+	request = firmware.NewUpdateFirmwareRequest(4, ca.sign(t, "sftp://example.com/firmware.bin", image))
+	response, err = manager.OnUpdateFirmware(request)
+	require.NoError(t, err)
+	assert.Equal(t, firmware.UpdateFirmwareStatusRejected, response.Status)
+	_, status := manager.CurrentStatus()
+	assert.Equal(t, firmware.FirmwareStatusIdle, status)
+	select {
+	case request := <-statusC:
+		assert.Fail(t, "unexpected status", request.Status)
+	default:
+	}
+}
+
+func (suite *OcppV2TestSuite) TestFirmwareUpdateSuperseded() {
+	t := suite.T()
+	ca := newTestSigningCA(t)
+	image := []byte("firmware image")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(image)
+	}))
+	defer server.Close()
+	statusC := make(chan *firmware.FirmwareStatusNotificationRequest, 10)
+	manager := firmware.NewUpdateManager201(func(ctx context.Context, image []byte) (bool, error) {
+		return false, nil
+	}, ca.roots, func(request *firmware.FirmwareStatusNotificationRequest) error {
+		statusC <- request
+		return nil
+	})
+	// The first update is scheduled in the future, and superseded while waiting
+	fw := ca.sign(t, server.URL+"/firmware.bin", image)
+	fw.RetrieveDateTime = types.NewDateTime(time.Now().Add(time.Hour))
+	response, err := manager.OnUpdateFirmware(firmware.NewUpdateFirmwareRequest(1, fw))
+	require.NoError(t, err)
+	assert.Equal(t, firmware.UpdateFirmwareStatusAccepted, response.Status)
+	scheduled := <-statusC
+	assert.Equal(t, firmware.FirmwareStatusDownloadScheduled, scheduled.Status)
+	requestID, status := manager.CurrentStatus()
+	assert.Equal(t, 1, requestID)
+	assert.Equal(t, firmware.FirmwareStatusDownloadScheduled, status)
+	response, err = manager.OnUpdateFirmware(firmware.NewUpdateFirmwareRequest(2, ca.sign(t, server.URL+"/firmware.bin", image)))
+	require.NoError(t, err)
+	assert.Equal(t, firmware.UpdateFirmwareStatusAcceptedCanceled, response.Status)
+	assert.Equal(t, []firmware.FirmwareStatus{
+		firmware.FirmwareStatusDownloading,
+		firmware.FirmwareStatusDownloaded,
+		firmware.FirmwareStatusSignatureVerified,
+		firmware.FirmwareStatusInstalling,
+		firmware.FirmwareStatusInstalled,
+	}, firmwareStatuses(t, statusC, 2))
+}
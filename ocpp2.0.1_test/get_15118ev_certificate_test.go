@@ -23,6 +23,8 @@ func (suite *OcppV2TestSuite) TestGet15118EVCertificateRequestValidation() {
 		{iso15118.Get15118EVCertificateRequest{SchemaVersion: ">50................................................", Action: iso15118.CertificateActionInstall, ExiRequest: "deadbeef"}, false},
 		{iso15118.Get15118EVCertificateRequest{SchemaVersion: "1.0", Action: "invalidCertificateAction", ExiRequest: "deadbeef"}, false},
 		{iso15118.Get15118EVCertificateRequest{SchemaVersion: "1.0", Action: iso15118.CertificateActionInstall, ExiRequest: newLongString(5601)}, false},
+		{iso15118.Get15118EVCertificateRequest{SchemaVersion: "1.0", Action: iso15118.CertificateActionInstall, ExiRequest: "deadbeef2"}, false},
+		{iso15118.Get15118EVCertificateRequest{SchemaVersion: "1.0", Action: iso15118.CertificateActionInstall, ExiRequest: "dead%eef"}, false},
 	}
 	ExecuteGenericTestTable(t, requestTable)
 }
@@ -38,6 +40,7 @@ func (suite *OcppV2TestSuite) TestGet15118EVCertificateConfirmationValidation()
 		{iso15118.Get15118EVCertificateResponse{Status: "invalidCertificateStatus", ExiResponse: "deadbeef", StatusInfo: types.NewStatusInfo("200", "ok")}, false},
 		{iso15118.Get15118EVCertificateResponse{Status: types.Certificate15188EVStatusAccepted, ExiResponse: newLongString(5601), StatusInfo: types.NewStatusInfo("200", "ok")}, false},
 		{iso15118.Get15118EVCertificateResponse{Status: types.Certificate15188EVStatusAccepted, ExiResponse: "deadbeef", StatusInfo: types.NewStatusInfo("", "")}, false},
+		{iso15118.Get15118EVCertificateResponse{Status: types.Certificate15188EVStatusAccepted, ExiResponse: "deadbeef="}, false},
 	}
 	ExecuteGenericTestTable(t, confirmationTable)
 }
@@ -51,7 +54,7 @@ func (suite *OcppV2TestSuite) TestGet15118EVCertificateE2EMocked() {
 	schemaVersion := "1.0"
 	action := iso15118.CertificateActionInstall
 	exiRequest := "deadbeef"
-	exiResponse := "deadbeef2"
+	exiResponse := "deadbee2"
 	statusInfo := types.NewStatusInfo("200", "ok")
 	requestJson := fmt.Sprintf(`[2,"%v","%v",{"iso15118SchemaVersion":"%v","action":"%v","exiRequest":"%v"}]`,
 		messageId, iso15118.Get15118EVCertificateFeatureName, schemaVersion, action, exiRequest)
@@ -15,9 +15,9 @@ import (
 func (suite *OcppV2TestSuite) TestGetCertificateStatusRequestValidation() {
 	t := suite.T()
 	var requestTable = []GenericTestEntry{
-		{iso15118.GetCertificateStatusRequest{OcspRequestData: types.OCSPRequestDataType{HashAlgorithm: types.SHA256, IssuerNameHash: "hash00", IssuerKeyHash: "hash01", SerialNumber: "serial0", ResponderURL: "http://someUrl"}}, true},
+		{iso15118.GetCertificateStatusRequest{OcspRequestData: types.OCSPRequestDataType{HashAlgorithm: types.SHA256, IssuerNameHash: "abbfd6816cd185c727ada56e34741396f7174de038511f87ac34c69334999a76", IssuerKeyHash: "26ae59a9ced2093fe5a5e83312688acc1400e66fc9e858a1e1d00586545bf5e0", SerialNumber: "5e1a10", ResponderURL: "http://someUrl"}}, true},
 		{iso15118.GetCertificateStatusRequest{}, false},
-		{iso15118.GetCertificateStatusRequest{OcspRequestData: types.OCSPRequestDataType{HashAlgorithm: "invalidHashAlgorithm", IssuerNameHash: "hash00", IssuerKeyHash: "hash01", SerialNumber: "serial0", ResponderURL: "http://someUrl"}}, false},
+		{iso15118.GetCertificateStatusRequest{OcspRequestData: types.OCSPRequestDataType{HashAlgorithm: "invalidHashAlgorithm", IssuerNameHash: "abbfd6816cd185c727ada56e34741396f7174de038511f87ac34c69334999a76", IssuerKeyHash: "26ae59a9ced2093fe5a5e83312688acc1400e66fc9e858a1e1d00586545bf5e0", SerialNumber: "5e1a10", ResponderURL: "http://someUrl"}}, false},
 	}
 	ExecuteGenericTestTable(t, requestTable)
 }
@@ -40,7 +40,7 @@ func (suite *OcppV2TestSuite) TestGetCertificateStatusE2EMocked() {
 	wsId := "test_id"
 	messageId := defaultMessageId
 	wsUrl := "someUrl"
-	ocspData := types.OCSPRequestDataType{HashAlgorithm: types.SHA256, IssuerNameHash: "hash00", IssuerKeyHash: "hash01", SerialNumber: "serial0", ResponderURL: "http://someUrl"}
+	ocspData := types.OCSPRequestDataType{HashAlgorithm: types.SHA256, IssuerNameHash: "abbfd6816cd185c727ada56e34741396f7174de038511f87ac34c69334999a76", IssuerKeyHash: "26ae59a9ced2093fe5a5e83312688acc1400e66fc9e858a1e1d00586545bf5e0", SerialNumber: "5e1a10", ResponderURL: "http://someUrl"}
 	ocspResult := "deadbeef"
 	status := types.GenericStatusAccepted
 	requestJson := fmt.Sprintf(`[2,"%v","%v",{"ocspRequestData":{"hashAlgorithm":"%v","issuerNameHash":"%v","issuerKeyHash":"%v","serialNumber":"%v","responderURL":"%v"}}]`,
@@ -76,7 +76,7 @@ func (suite *OcppV2TestSuite) TestGetCertificateStatusE2EMocked() {
 
 func (suite *OcppV2TestSuite) TestGetCertificateStatusInvalidEndpoint() {
 	messageId := defaultMessageId
-	ocspData := types.OCSPRequestDataType{HashAlgorithm: types.SHA256, IssuerNameHash: "hash00", IssuerKeyHash: "hash01", SerialNumber: "serial0", ResponderURL: "http://someUrl"}
+	ocspData := types.OCSPRequestDataType{HashAlgorithm: types.SHA256, IssuerNameHash: "abbfd6816cd185c727ada56e34741396f7174de038511f87ac34c69334999a76", IssuerKeyHash: "26ae59a9ced2093fe5a5e83312688acc1400e66fc9e858a1e1d00586545bf5e0", SerialNumber: "5e1a10", ResponderURL: "http://someUrl"}
 	getCertificateStatusRequest := iso15118.NewGetCertificateStatusRequest(ocspData)
 	requestJson := fmt.Sprintf(`[2,"%v","%v",{"ocspRequestData":{"hashAlgorithm":"%v","issuerNameHash":"%v","issuerKeyHash":"%v","serialNumber":"%v","responderURL":"%v"}}]`,
 		messageId, iso15118.GetCertificateStatusFeatureName, ocspData.HashAlgorithm, ocspData.IssuerNameHash, ocspData.IssuerKeyHash, ocspData.SerialNumber, ocspData.ResponderURL)
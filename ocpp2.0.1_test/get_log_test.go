@@ -46,6 +46,7 @@ func (suite *OcppV2TestSuite) TestGetLogConfirmationValidation() {
 		{diagnostics.GetLogResponse{Status: diagnostics.LogStatusAcceptedCanceled}, true},
 		{diagnostics.GetLogResponse{}, false},
 		{diagnostics.GetLogResponse{Status: "invalidLogStatus"}, false},
+		{diagnostics.GetLogResponse{Status: diagnostics.LogStatusAccepted, Filename: ">255............................................................................................................................................................................................................................................................"}, false},
 		{diagnostics.GetLogResponse{Status: diagnostics.LogStatusAccepted, Filename: ">256............................................................................................................................................................................................................................................................."}, false},
 	}
 	ExecuteGenericTestTable(t, confirmationTable)
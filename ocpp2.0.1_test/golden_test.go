@@ -0,0 +1,17 @@
+package ocpp2_test
+
+import (
+	"flag"
+	"path/filepath"
+	"testing"
+
+	"github.com/lorenzodonini/ocpp-go/internal/golden"
+	ocpp2 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden payloads in testdata/golden")
+
+// The wire format of the requests and responses of all features must not change unnoticed.
+func TestGoldenPayloads(t *testing.T) {
+	golden.Run(t, filepath.Join("testdata", "golden"), *updateGolden, ocpp2.Profiles()...)
+}
@@ -0,0 +1,116 @@
+package ocpp2_test
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/authorization"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/idtoken"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/localauth"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+func (suite *OcppV2TestSuite) TestIdTokenValidation() {
+	t := suite.T()
+	var testTable = []struct {
+		token       types.IdToken
+		expectedErr error
+	}{
+		{types.IdToken{IdToken: "any value", Type: types.IdTokenTypeCentral}, nil},
+		{types.IdToken{IdToken: "", Type: types.IdTokenTypeCentral}, idtoken.ErrEmptyIdToken},
+		{types.IdToken{IdToken: "DE-ABC-C12345678-X", Type: types.IdTokenTypeEMAID}, nil},
+		{types.IdToken{IdToken: "DE-ABC-C12345678", Type: types.IdTokenTypeEMAID}, nil},
+		{types.IdToken{IdToken: "deabcc12345678x", Type: types.IdTokenTypeEMAID}, nil},
+		{types.IdToken{IdToken: "DE-ABCC12345678X", Type: types.IdTokenTypeEMAID}, idtoken.ErrInvalidFormat},
+		{types.IdToken{IdToken: "D1-ABC-C12345678", Type: types.IdTokenTypeEMAID}, idtoken.ErrInvalidFormat},
+		{types.IdToken{IdToken: "DEABC", Type: types.IdTokenTypeEMAID}, idtoken.ErrInvalidFormat},
+		{types.IdToken{IdToken: "04A2B3C4", Type: types.IdTokenTypeISO14443}, nil},
+		{types.IdToken{IdToken: "04a2b3c4d5e680", Type: types.IdTokenTypeISO14443}, nil},
+		{types.IdToken{IdToken: "04A2B3C4D5E6801122FF", Type: types.IdTokenTypeISO14443}, nil},
+		{types.IdToken{IdToken: "04A2B3", Type: types.IdTokenTypeISO14443}, idtoken.ErrInvalidFormat},
+		{types.IdToken{IdToken: "04A2B3CG", Type: types.IdTokenTypeISO14443}, idtoken.ErrInvalidFormat},
+		{types.IdToken{IdToken: "E004010012345678", Type: types.IdTokenTypeISO15693}, nil},
+		{types.IdToken{IdToken: "E0040100123456", Type: types.IdTokenTypeISO15693}, idtoken.ErrInvalidFormat},
+		{types.IdToken{IdToken: "1234", Type: types.IdTokenTypeKeyCode}, nil},
+		{types.IdToken{IdToken: "", Type: types.IdTokenTypeKeyCode}, idtoken.ErrEmptyIdToken},
+		{types.IdToken{IdToken: "local1", Type: types.IdTokenTypeLocal}, nil},
+		{types.IdToken{IdToken: "00:1A:2b:3C:4d:5E", Type: types.IdTokenTypeMacAddress}, nil},
+		{types.IdToken{IdToken: "00-1A-2B-3C-4D-5E", Type: types.IdTokenTypeMacAddress}, nil},
+		{types.IdToken{IdToken: "001A2B3C4D5E", Type: types.IdTokenTypeMacAddress}, nil},
+		{types.IdToken{IdToken: "00:1A-2B:3C-4D:5E", Type: types.IdTokenTypeMacAddress}, idtoken.ErrInvalidFormat},
+		{types.IdToken{IdToken: "00:1A:2B:3C:4D", Type: types.IdTokenTypeMacAddress}, idtoken.ErrInvalidFormat},
+		{types.IdToken{IdToken: "", Type: types.IdTokenTypeNoAuthorization}, nil},
+		{types.IdToken{IdToken: "1234", Type: types.IdTokenTypeNoAuthorization}, idtoken.ErrUnexpectedIdToken},
+		{types.IdToken{IdToken: "1234", Type: "invalidTokenType"}, idtoken.ErrUnknownType},
+		{types.IdToken{IdToken: "1234", Type: types.IdTokenTypeKeyCode, AdditionalInfo: []types.AdditionalInfo{{AdditionalIdToken: "0000", Type: "someType"}, {AdditionalIdToken: "0000", Type: "otherType"}}}, nil},
+		{types.IdToken{IdToken: "1234", Type: types.IdTokenTypeKeyCode, AdditionalInfo: []types.AdditionalInfo{{AdditionalIdToken: " ", Type: "someType"}}}, idtoken.ErrInvalidAdditionalInfo},
+		{types.IdToken{IdToken: "1234", Type: types.IdTokenTypeKeyCode, AdditionalInfo: []types.AdditionalInfo{{AdditionalIdToken: "0000", Type: ""}}}, idtoken.ErrInvalidAdditionalInfo},
+		{types.IdToken{IdToken: "1234", Type: types.IdTokenTypeKeyCode, AdditionalInfo: []types.AdditionalInfo{{AdditionalIdToken: "0000", Type: "someType"}, {AdditionalIdToken: "0000", Type: "someType"}}}, idtoken.ErrInvalidAdditionalInfo},
+	}
+	for _, testCase := range testTable {
+		err := idtoken.Validate(testCase.token)
+		if testCase.expectedErr == nil {
+			assert.NoError(t, err, testCase.token.IdToken)
+		} else {
+			assert.ErrorIs(t, err, testCase.expectedErr, testCase.token.IdToken)
+		}
+	}
+}
+
+func (suite *OcppV2TestSuite) TestIdTokenNormalization() {
+	t := suite.T()
+	var testTable = []struct {
+		token    types.IdToken
+		expected string
+	}{
+		{types.IdToken{IdToken: "04a2b3c4", Type: types.IdTokenTypeISO14443}, "04A2B3C4"},
+		{types.IdToken{IdToken: "e004010012345678", Type: types.IdTokenTypeISO15693}, "E004010012345678"},
+		{types.IdToken{IdToken: "de-abc-c12345678-x", Type: types.IdTokenTypeEMAID}, "DEABCC12345678X"},
+		{types.IdToken{IdToken: "DEABCC12345678X", Type: types.IdTokenTypeEMAID}, "DEABCC12345678X"},
+		{types.IdToken{IdToken: "001a2b3c4d5e", Type: types.IdTokenTypeMacAddress}, "00:1A:2B:3C:4D:5E"},
+		{types.IdToken{IdToken: "00-1a-2b-3c-4d-5e", Type: types.IdTokenTypeMacAddress}, "00:1A:2B:3C:4D:5E"},
+		{types.IdToken{IdToken: "abc", Type: types.IdTokenTypeCentral}, "abc"},
+		{types.IdToken{IdToken: "abc", Type: types.IdTokenTypeKeyCode}, "abc"},
+		{types.IdToken{IdToken: "abc", Type: types.IdTokenTypeLocal}, "abc"},
+		{types.IdToken{IdToken: "", Type: types.IdTokenTypeNoAuthorization}, ""},
+		// Malformed tokens are left untouched
+		{types.IdToken{IdToken: "not-hex", Type: types.IdTokenTypeISO14443}, "not-hex"},
+	}
+	for _, testCase := range testTable {
+		normalized := idtoken.Normalize(testCase.token)
+		assert.Equal(t, testCase.expected, normalized.IdToken)
+		assert.Equal(t, testCase.token.Type, normalized.Type)
+	}
+	assert.True(t, idtoken.Equal(types.IdToken{IdToken: "DE-ABC-C12345678", Type: types.IdTokenTypeEMAID}, types.IdToken{IdToken: "deabcc12345678", Type: types.IdTokenTypeEMAID}))
+	assert.False(t, idtoken.Equal(types.IdToken{IdToken: "04A2B3C4", Type: types.IdTokenTypeISO14443}, types.IdToken{IdToken: "04A2B3C4", Type: types.IdTokenTypeISO15693}))
+	assert.False(t, idtoken.Equal(types.IdToken{IdToken: "abc", Type: types.IdTokenTypeCentral}, types.IdToken{IdToken: "ABC", Type: types.IdTokenTypeCentral}))
+	// Local list lookups compare normalized tokens
+	manager, err := localauth.NewListManager201(localauth.NewMemoryListStorage())
+	require.NoError(t, err)
+	request := localauth.NewSendLocalListRequest(1, localauth.UpdateTypeFull)
+	request.LocalAuthorizationList = []localauth.AuthorizationData{{IdToken: types.IdToken{IdToken: "04a2b3c4", Type: types.IdTokenTypeISO14443}, IdTokenInfo: types.NewIdTokenInfo(types.AuthorizationStatusAccepted)}}
+	response, err := manager.OnSendLocalList(request)
+	require.NoError(t, err)
+	require.Equal(t, localauth.SendLocalListStatusAccepted, response.Status)
+	_, ok := manager.Lookup("04A2B3C4", types.IdTokenTypeISO14443)
+	assert.True(t, ok)
+}
+
+func (suite *OcppV2TestSuite) TestIdTokenRequestValidation() {
+	var authorizeTable = []GenericTestEntry{
+		{authorization.AuthorizeRequest{IdToken: types.IdToken{IdToken: "04A2B3C4", Type: types.IdTokenTypeISO14443}}, true},
+		{authorization.AuthorizeRequest{IdToken: types.IdToken{IdToken: "04A2B3", Type: types.IdTokenTypeISO14443}}, false},
+		{authorization.AuthorizeRequest{IdToken: types.IdToken{IdToken: "1234", Type: types.IdTokenTypeNoAuthorization}}, false},
+	}
+	ExecuteGenericTestTable(suite.T(), authorizeTable)
+	transactionInfo := transactions.Transaction{TransactionID: "42"}
+	var transactionEventTable = []GenericTestEntry{
+		{transactions.TransactionEventRequest{EventType: transactions.TransactionEventStarted, Timestamp: types.NewDateTime(time.Now()), TriggerReason: transactions.TriggerReasonAuthorized, TransactionInfo: transactionInfo}, true},
+		{transactions.TransactionEventRequest{EventType: transactions.TransactionEventStarted, Timestamp: types.NewDateTime(time.Now()), TriggerReason: transactions.TriggerReasonAuthorized, TransactionInfo: transactionInfo, IDToken: &types.IdToken{IdToken: "DE-ABC-C12345678", Type: types.IdTokenTypeEMAID}}, true},
+		{transactions.TransactionEventRequest{EventType: transactions.TransactionEventStarted, Timestamp: types.NewDateTime(time.Now()), TriggerReason: transactions.TriggerReasonAuthorized, TransactionInfo: transactionInfo, IDToken: &types.IdToken{IdToken: "invalid", Type: types.IdTokenTypeEMAID}}, false},
+	}
+	ExecuteGenericTestTable(suite.T(), transactionEventTable)
+}
@@ -0,0 +1,98 @@
+package ocpp2_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	ocpp2 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/csms"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/lorenzodonini/ocpp-go/ws/wstest"
+)
+
+var _ ocpp2.CapabilityProvider = (*csms.StationRegistry)(nil)
+
+func newControllerReport(enabled map[string]bool) []provisioning.ReportData {
+	report := []provisioning.ReportData{
+		// Variables of components other than controllers are ignored
+		{Component: types.Component{Name: "ChargingStation"}, Variable: types.Variable{Name: "Available"}, VariableAttribute: []provisioning.VariableAttribute{{Value: "true"}}},
+	}
+	for name, value := range enabled {
+		state := "false"
+		if value {
+			state = "true"
+		}
+		report = append(report, provisioning.ReportData{Component: types.Component{Name: name}, Variable: types.Variable{Name: "Enabled"}, VariableAttribute: []provisioning.VariableAttribute{{Value: state}}})
+	}
+	return report
+}
+
+func findInventoryEntry(inventory []ocppj.EndpointInfo, id string) (ocppj.EndpointInfo, bool) {
+	for _, info := range inventory {
+		if info.ID == id {
+			return info, true
+		}
+	}
+	return ocppj.EndpointInfo{}, false
+}
+
+func (suite *OcppV2TestSuite) TestInventory() {
+	t := suite.T()
+	server := wstest.NewServer()
+	csmsServer := ocpp2.NewCSMS(nil, server)
+	registry := csms.NewStationRegistry()
+	handler := &MockCSMSProvisioningHandler{}
+	handler.On("OnNotifyReport", mock.Anything, mock.Anything).Return(provisioning.NewNotifyReportResponse(), nil)
+	csmsServer.SetProvisioningHandler(registry.WrapProvisioningHandler(handler))
+	station1 := ocpp2.NewChargingStation("station1", nil, wstest.NewClient(server))
+	station2 := ocpp2.NewChargingStation("station2", nil, wstest.NewClient(server))
+	require.NoError(t, wstest.ConnectInMemory(server, csmsServer, station1, station2))
+	defer csmsServer.Stop()
+	defer station1.Stop()
+	defer station2.Stop()
+	// Capabilities are unknown without a provider
+	_, err := station1.NotifyReport(1, types.Now(), 0, func(request *provisioning.NotifyReportRequest) {
+		request.ReportData = newControllerReport(map[string]bool{"SmartChargingCtrlr": true, "ReservationCtrlr": true})
+	})
+	require.NoError(t, err)
+	inventory := csmsServer.Inventory()
+	require.Len(t, inventory, 2)
+	for _, info := range inventory {
+		assert.Equal(t, ocpp.V2, info.ProtocolVersion)
+		assert.Equal(t, types.V201Subprotocol, info.Subprotocol)
+		assert.Equal(t, ocppj.CapabilityUnknown, info.Capabilities.Status)
+	}
+	// Each station exposes the controllers it reported
+	csmsServer.SetCapabilityProvider(registry)
+	_, err = station2.NotifyReport(1, types.Now(), 0, func(request *provisioning.NotifyReportRequest) {
+		request.ReportData = newControllerReport(map[string]bool{"SmartChargingCtrlr": false, "LocalAuthListCtrlr": true})
+	})
+	require.NoError(t, err)
+	inventory = csmsServer.Inventory()
+	info1, ok := findInventoryEntry(inventory, "station1")
+	require.True(t, ok)
+	assert.Equal(t, ocppj.CapabilityCurrent, info1.Capabilities.Status)
+	assert.Equal(t, "DeviceModel", info1.Capabilities.Source)
+	assert.Equal(t, []string{"ReservationCtrlr", "SmartChargingCtrlr"}, info1.Capabilities.Profiles)
+	info2, ok := findInventoryEntry(inventory, "station2")
+	require.True(t, ok)
+	assert.Equal(t, ocppj.CapabilityCurrent, info2.Capabilities.Status)
+	assert.Equal(t, []string{"LocalAuthListCtrlr"}, info2.Capabilities.Profiles)
+	// After reconnecting, the reported controllers are stale
+	connectedAt := info1.ConnectedAt
+	require.NoError(t, server.Disconnect("station1", errors.New("connection lost")))
+	require.Eventually(t, func() bool {
+		info, ok := findInventoryEntry(csmsServer.Inventory(), "station1")
+		return ok && info.ConnectedAt.After(connectedAt)
+	}, 5*time.Second, 10*time.Millisecond)
+	info1, _ = findInventoryEntry(csmsServer.Inventory(), "station1")
+	assert.Equal(t, ocppj.CapabilityStale, info1.Capabilities.Status)
+	assert.Equal(t, []string{"ReservationCtrlr", "SmartChargingCtrlr"}, info1.Capabilities.Profiles)
+}
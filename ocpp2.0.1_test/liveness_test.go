@@ -0,0 +1,136 @@
+package ocpp2_test
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/clock/clocktest"
+	ocpp2 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/availability"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/csms"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/remotecontrol"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/lorenzodonini/ocpp-go/ws/wstest"
+)
+
+type livenessEvent struct {
+	id       string
+	liveness ocppj.Liveness
+}
+
+func receiveLiveness(t require.TestingT, livenessC <-chan livenessEvent) ocppj.Liveness {
+	select {
+	case event := <-livenessC:
+		return event.liveness
+	case <-time.After(time.Second):
+		require.Fail(t, "liveness handler wasn't invoked")
+	}
+	return ocppj.Liveness{}
+}
+
+func (suite *OcppV2TestSuite) TestLivenessMonitor() {
+	t := suite.T()
+	server := wstest.NewServer()
+	fakeClock := clocktest.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	endpoint := ocppj.NewServer(server, nil, nil, availability.Profile, provisioning.Profile, remotecontrol.Profile)
+	endpoint.SetClock(fakeClock)
+	csmsServer := ocpp2.NewCSMS(endpoint, server)
+	registry := csms.NewStationRegistry()
+	handler := &MockCSMSProvisioningHandler{}
+	handler.On("OnBootNotification", "station1", mock.Anything).Return(provisioning.NewBootNotificationResponse(types.Now(), 60, provisioning.RegistrationStatusAccepted), nil)
+	csmsServer.SetProvisioningHandler(registry.WrapProvisioningHandler(handler))
+	availabilityHandler := &MockCSMSAvailabilityHandler{}
+	availabilityHandler.On("OnHeartbeat", "station1", mock.Anything).Return(availability.NewHeartbeatResponse(*types.Now()), nil)
+	csmsServer.SetAvailabilityHandler(registry.WrapAvailabilityHandler(availabilityHandler))
+	disconnectedC := make(chan string, 1)
+	csmsServer.SetNewChargingStationHandler(func(chargingStation ocpp2.ChargingStationConnection) {
+		registry.OnStationConnected(chargingStation.ID())
+	})
+	csmsServer.SetChargingStationDisconnectedHandler(func(chargingStation ocpp2.ChargingStationConnection) {
+		registry.OnStationDisconnected(chargingStation.ID())
+		disconnectedC <- chargingStation.ID()
+	})
+	livenessC := make(chan livenessEvent, 10)
+	csmsServer.SetLivenessHandler(func(chargingStationID string, liveness ocppj.Liveness) {
+		registry.OnLivenessChanged(chargingStationID, liveness)
+		livenessC <- livenessEvent{chargingStationID, liveness}
+	})
+	csmsServer.EnableLivenessMonitor(ocppj.LivenessConfig{DefaultHeartbeatInterval: 5 * time.Minute, ProbeTimeout: 30 * time.Second, CheckInterval: 10 * time.Second})
+	triggerHandler := &MockChargingStationRemoteControlHandler{}
+	triggerHandler.On("OnTriggerMessage", mock.Anything).Return(remotecontrol.NewTriggerMessageResponse(remotecontrol.TriggerMessageStatusAccepted), nil)
+	station := ocpp2.NewChargingStation("station1", nil, wstest.NewClient(server))
+	station.SetRemoteControlHandler(triggerHandler)
+	require.NoError(t, wstest.ConnectInMemory(server, csmsServer, station))
+	defer csmsServer.Stop()
+	defer station.Stop()
+	liveness, ok := csmsServer.Liveness("station1")
+	require.True(t, ok)
+	assert.Equal(t, 5*time.Minute, liveness.HeartbeatInterval)
+	// The heartbeat interval is taken from the boot response
+	_, err := station.BootNotification(provisioning.BootReasonPowerUp, "model1", "vendor1")
+	require.NoError(t, err)
+	liveness, ok = csmsServer.Liveness("station1")
+	require.True(t, ok)
+	assert.Equal(t, ocppj.LivenessHealthy, liveness.State)
+	assert.Equal(t, time.Minute, liveness.HeartbeatInterval)
+	assert.Equal(t, 2*time.Minute, liveness.Budget)
+	// Healthy: heartbeats within the budget
+	for i := 0; i < 3; i++ {
+		fakeClock.Advance(50 * time.Second)
+		_, err = station.Heartbeat()
+		require.NoError(t, err)
+	}
+	liveness, _ = csmsServer.Liveness("station1")
+	assert.Equal(t, ocppj.LivenessHealthy, liveness.State)
+	assert.Equal(t, fakeClock.Now(), liveness.LastMessage)
+	assert.Len(t, livenessC, 0)
+	// Probed and recovered: the station answers the triggered heartbeat
+	fakeClock.Advance(130 * time.Second)
+	liveness = receiveLiveness(t, livenessC)
+	assert.Equal(t, ocppj.LivenessProbing, liveness.State)
+	require.NotNil(t, liveness.ProbedAt)
+	probedAt := *liveness.ProbedAt
+	require.Eventually(t, func() bool {
+		liveness, _ := csmsServer.Liveness("station1")
+		return liveness.LastMessage.Equal(probedAt)
+	}, time.Second, 5*time.Millisecond)
+	triggerHandler.AssertCalled(t, "OnTriggerMessage", mock.MatchedBy(func(request *remotecontrol.TriggerMessageRequest) bool {
+		return request.RequestedMessage == remotecontrol.MessageTriggerHeartbeat
+	}))
+	fakeClock.Advance(10 * time.Second)
+	liveness = receiveLiveness(t, livenessC)
+	assert.Equal(t, ocppj.LivenessHealthy, liveness.State)
+	assert.Nil(t, liveness.ProbedAt)
+	snapshot, ok := registry.Station("station1")
+	require.True(t, ok)
+	require.NotNil(t, snapshot.Liveness)
+	assert.Equal(t, ocppj.LivenessHealthy, snapshot.Liveness.State)
+	// Reaped: the connection died silently, so the probe never reaches the station
+	conn, ok := server.Connection("station1")
+	require.True(t, ok)
+	conn.SetConditions(wstest.Conditions{Drop: func(data []byte) bool { return true }})
+	fakeClock.Advance(130 * time.Second)
+	liveness = receiveLiveness(t, livenessC)
+	assert.Equal(t, ocppj.LivenessProbing, liveness.State)
+	fakeClock.Advance(30 * time.Second)
+	liveness = receiveLiveness(t, livenessC)
+	assert.Equal(t, ocppj.LivenessReaped, liveness.State)
+	select {
+	case id := <-disconnectedC:
+		assert.Equal(t, "station1", id)
+	case <-time.After(time.Second):
+		require.Fail(t, "reaped station wasn't disconnected")
+	}
+	snapshot, ok = registry.Station("station1")
+	require.True(t, ok)
+	assert.False(t, snapshot.Connected)
+	require.NotNil(t, snapshot.Liveness)
+	assert.Equal(t, ocppj.LivenessReaped, snapshot.Liveness.State)
+	_, ok = csmsServer.Liveness("station1")
+	assert.False(t, ok)
+}
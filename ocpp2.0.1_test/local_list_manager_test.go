@@ -0,0 +1,179 @@
+package ocpp2_test
+
+import (
+	"errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/localauth"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// Fails all saves after the first successful ones.
+type failingListStorage struct {
+	localauth.ListStorage
+	remainingSaves int
+}
+
+func (s *failingListStorage) Save(version int, entries []localauth.AuthorizationData) error {
+	if s.remainingSaves <= 0 {
+		return errors.New("storage full")
+	}
+	s.remainingSaves--
+	return s.ListStorage.Save(version, entries)
+}
+
+func sendLocalListStatuses(t assert.TestingT, manager *localauth.ListManager201, requests []*localauth.SendLocalListRequest) []localauth.SendLocalListStatus {
+	var statuses []localauth.SendLocalListStatus
+	for _, request := range requests {
+		response, err := manager.OnSendLocalList(request)
+		assert.NoError(t, err)
+		assert.NoError(t, types.Validate.Struct(response))
+		statuses = append(statuses, response.Status)
+	}
+	return statuses
+}
+
+func (suite *OcppV2TestSuite) TestLocalListManagerUpdates() {
+	t := suite.T()
+	manager, err := localauth.NewListManager201(localauth.NewMemoryListStorage())
+	require.NoError(t, err)
+	response, err := manager.OnGetLocalListVersion(localauth.NewGetLocalListVersionRequest())
+	require.NoError(t, err)
+	assert.Equal(t, 0, response.VersionNumber)
+	// Full update
+	request := localauth.NewSendLocalListRequest(1, localauth.UpdateTypeFull)
+	request.LocalAuthorizationList = newAuthorizationData(3)
+	assert.Equal(t, []localauth.SendLocalListStatus{localauth.SendLocalListStatusAccepted}, sendLocalListStatuses(t, manager, []*localauth.SendLocalListRequest{request}))
+	info, ok := manager.Lookup("token1", types.IdTokenTypeISO14443)
+	require.True(t, ok)
+	assert.Equal(t, types.AuthorizationStatusAccepted, info.Status)
+	_, ok = manager.Lookup("token1", types.IdTokenTypeISO15693)
+	assert.False(t, ok)
+	// Differential update: remove token0, block token1, add token3
+	request = localauth.NewSendLocalListRequest(2, localauth.UpdateTypeDifferential)
+	request.LocalAuthorizationList = []localauth.AuthorizationData{
+		{IdToken: types.IdToken{IdToken: "token0", Type: types.IdTokenTypeISO14443}},
+		{IdToken: types.IdToken{IdToken: "token1", Type: types.IdTokenTypeISO14443}, IdTokenInfo: types.NewIdTokenInfo(types.AuthorizationStatusBlocked)},
+		{IdToken: types.IdToken{IdToken: "token3", Type: types.IdTokenTypeISO14443}, IdTokenInfo: types.NewIdTokenInfo(types.AuthorizationStatusAccepted)},
+	}
+	assert.Equal(t, []localauth.SendLocalListStatus{localauth.SendLocalListStatusAccepted}, sendLocalListStatuses(t, manager, []*localauth.SendLocalListRequest{request}))
+	_, ok = manager.Lookup("token0", types.IdTokenTypeISO14443)
+	assert.False(t, ok)
+	info, _ = manager.Lookup("token1", types.IdTokenTypeISO14443)
+	assert.Equal(t, types.AuthorizationStatusBlocked, info.Status)
+	_, ok = manager.Lookup("token3", types.IdTokenTypeISO14443)
+	assert.True(t, ok)
+	assert.Equal(t, 2, manager.Version())
+	// Differential updates must increase the version
+	request.VersionNumber = 2
+	assert.Equal(t, []localauth.SendLocalListStatus{localauth.SendLocalListStatusVersionMismatch}, sendLocalListStatuses(t, manager, []*localauth.SendLocalListRequest{request}))
+	// Full updates may reset the version
+	request = localauth.NewSendLocalListRequest(1, localauth.UpdateTypeFull)
+	assert.Equal(t, []localauth.SendLocalListStatus{localauth.SendLocalListStatusAccepted}, sendLocalListStatuses(t, manager, []*localauth.SendLocalListRequest{request}))
+	assert.Equal(t, 1, manager.Version())
+	_, ok = manager.Lookup("token1", types.IdTokenTypeISO14443)
+	assert.False(t, ok)
+}
+
+func (suite *OcppV2TestSuite) TestLocalListManagerRejectsInvalidUpdates() {
+	t := suite.T()
+	manager, err := localauth.NewListManager201(localauth.NewMemoryListStorage())
+	require.NoError(t, err)
+	manager.ItemsPerMessage = 3
+	// Duplicate idToken
+	request := localauth.NewSendLocalListRequest(1, localauth.UpdateTypeFull)
+	request.LocalAuthorizationList = append(newAuthorizationData(2), newAuthorizationData(1)...)
+	response, err := manager.OnSendLocalList(request)
+	require.NoError(t, err)
+	assert.Equal(t, localauth.SendLocalListStatusFailed, response.Status)
+	require.NotNil(t, response.StatusInfo)
+	assert.Equal(t, "DuplicateIdToken", response.StatusInfo.ReasonCode)
+	// The same idToken with different types isn't a duplicate
+	request.LocalAuthorizationList[2].IdToken.Type = types.IdTokenTypeISO15693
+	response, err = manager.OnSendLocalList(request)
+	require.NoError(t, err)
+	assert.Equal(t, localauth.SendLocalListStatusAccepted, response.Status)
+	// Full updates require idTokenInfo
+	request = localauth.NewSendLocalListRequest(2, localauth.UpdateTypeFull)
+	request.LocalAuthorizationList = []localauth.AuthorizationData{{IdToken: types.IdToken{IdToken: "token9", Type: types.IdTokenTypeISO14443}}}
+	response, err = manager.OnSendLocalList(request)
+	require.NoError(t, err)
+	assert.Equal(t, localauth.SendLocalListStatusFailed, response.Status)
+	// Too many entries
+	request = localauth.NewSendLocalListRequest(2, localauth.UpdateTypeDifferential)
+	request.LocalAuthorizationList = newAuthorizationData(4)
+	response, err = manager.OnSendLocalList(request)
+	require.NoError(t, err)
+	assert.Equal(t, localauth.SendLocalListStatusFailed, response.Status)
+	// Rejected updates leave the list untouched
+	assert.Equal(t, 1, manager.Version())
+	_, ok := manager.Lookup("token0", types.IdTokenTypeISO15693)
+	assert.True(t, ok)
+	_, ok = manager.Lookup("token9", types.IdTokenTypeISO14443)
+	assert.False(t, ok)
+}
+
+func (suite *OcppV2TestSuite) TestLocalListManagerChunkedRollout() {
+	t := suite.T()
+	storage := localauth.NewMemoryListStorage()
+	manager, err := localauth.NewListManager201(storage)
+	require.NoError(t, err)
+	manager.ItemsPerMessage = 2
+	// An old list is replaced by the chunked rollout
+	old := localauth.NewSendLocalListRequest(3, localauth.UpdateTypeFull)
+	old.LocalAuthorizationList = []localauth.AuthorizationData{{IdToken: types.IdToken{IdToken: "old", Type: types.IdTokenTypeISO14443}, IdTokenInfo: types.NewIdTokenInfo(types.AuthorizationStatusAccepted)}}
+	_, err = manager.OnSendLocalList(old)
+	require.NoError(t, err)
+	request := localauth.NewSendLocalListRequest(10, localauth.UpdateTypeFull)
+	request.LocalAuthorizationList = newAuthorizationData(5)
+	requests, err := provisioning.SplitSendLocalList(request, provisioning.MessageLimits{ItemsPerMessageSendLocalList: manager.ItemsPerMessage})
+	require.NoError(t, err)
+	require.Len(t, requests, 3)
+	assert.Equal(t, []localauth.SendLocalListStatus{localauth.SendLocalListStatusAccepted, localauth.SendLocalListStatusAccepted, localauth.SendLocalListStatusAccepted}, sendLocalListStatuses(t, manager, requests))
+	assert.Equal(t, 10, manager.Version())
+	for _, entry := range request.LocalAuthorizationList {
+		_, ok := manager.Lookup(entry.IdToken.IdToken, entry.IdToken.Type)
+		assert.True(t, ok, entry.IdToken.IdToken)
+	}
+	_, ok := manager.Lookup("old", types.IdTokenTypeISO14443)
+	assert.False(t, ok)
+	// The list is restored from storage
+	restored, err := localauth.NewListManager201(storage)
+	require.NoError(t, err)
+	assert.Equal(t, 10, restored.Version())
+	_, ok = restored.Lookup("token4", types.IdTokenTypeISO14443)
+	assert.True(t, ok)
+}
+
+func (suite *OcppV2TestSuite) TestLocalListManagerInterruptedRollout() {
+	t := suite.T()
+	storage := &failingListStorage{ListStorage: localauth.NewMemoryListStorage(), remainingSaves: 1}
+	manager, err := localauth.NewListManager201(storage)
+	require.NoError(t, err)
+	request := localauth.NewSendLocalListRequest(5, localauth.UpdateTypeFull)
+	request.LocalAuthorizationList = newAuthorizationData(5)
+	requests, err := provisioning.SplitSendLocalList(request, provisioning.MessageLimits{ItemsPerMessageSendLocalList: 2})
+	require.NoError(t, err)
+	require.Len(t, requests, 3)
+	// The second part fails, so the CSMS stops the rollout
+	statuses := sendLocalListStatuses(t, manager, requests[:2])
+	assert.Equal(t, []localauth.SendLocalListStatus{localauth.SendLocalListStatusAccepted, localauth.SendLocalListStatusFailed}, statuses)
+	// The version reflects the applied part only, revealing the incomplete rollout
+	assert.Equal(t, requests[0].VersionNumber, manager.Version())
+	assert.NotEqual(t, request.VersionNumber, manager.Version())
+	_, ok := manager.Lookup("token1", types.IdTokenTypeISO14443)
+	assert.True(t, ok)
+	_, ok = manager.Lookup("token2", types.IdTokenTypeISO14443)
+	assert.False(t, ok)
+	version, entries, err := storage.Load()
+	require.NoError(t, err)
+	assert.Equal(t, requests[0].VersionNumber, version)
+	assert.Len(t, entries, 2)
+	// Retrying the rollout from the start succeeds
+	storage.remainingSaves = 3
+	assert.Equal(t, []localauth.SendLocalListStatus{localauth.SendLocalListStatusAccepted, localauth.SendLocalListStatusAccepted, localauth.SendLocalListStatusAccepted}, sendLocalListStatuses(t, manager, requests))
+	assert.Equal(t, 5, manager.Version())
+}
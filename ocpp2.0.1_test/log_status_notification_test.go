@@ -19,6 +19,7 @@ func (suite *OcppV2TestSuite) TestLogStatusNotificationRequestValidation() {
 		{diagnostics.LogStatusNotificationRequest{Status: diagnostics.UploadLogStatusUploaded, RequestID: 42}, true},
 		{diagnostics.LogStatusNotificationRequest{Status: diagnostics.UploadLogStatusPermissionDenied, RequestID: 42}, true},
 		{diagnostics.LogStatusNotificationRequest{Status: diagnostics.UploadLogStatusNotSupportedOp, RequestID: 42}, true},
+		{diagnostics.LogStatusNotificationRequest{Status: diagnostics.UploadLogStatusAcceptedCanceled, RequestID: 42}, true},
 		{diagnostics.LogStatusNotificationRequest{Status: diagnostics.UploadLogStatusIdle, RequestID: 42}, true},
 		{diagnostics.LogStatusNotificationRequest{Status: diagnostics.UploadLogStatusBadMessage, RequestID: 42}, true},
 		{diagnostics.LogStatusNotificationRequest{Status: diagnostics.UploadLogStatusIdle}, true},
@@ -0,0 +1,300 @@
+package ocpp2_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/clock/clocktest"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/diagnostics"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+type uploadedLog struct {
+	filename string
+	content  string
+}
+
+func newTestLogUploadManager(collected *[]diagnostics.LogType) (*diagnostics.LogUploadManager, chan *diagnostics.LogStatusNotificationRequest) {
+	statusC := make(chan *diagnostics.LogStatusNotificationRequest, 10)
+	manager := diagnostics.NewLogUploadManager(func(logType diagnostics.LogType, oldest *time.Time, latest *time.Time) (string, []byte, error) {
+		if collected != nil {
+			*collected = append(*collected, logType)
+		}
+		return "log.txt", []byte("log content"), nil
+	}, func(request *diagnostics.LogStatusNotificationRequest) error {
+		statusC <- request
+		return nil
+	})
+	return manager, statusC
+}
+
+func newTestGetLogRequest(requestID int, remoteLocation string) *diagnostics.GetLogRequest {
+	return diagnostics.NewGetLogRequest(diagnostics.LogTypeDiagnostics, requestID, diagnostics.LogParameters{RemoteLocation: remoteLocation})
+}
+
+// Collects status notifications until a final status is received.
+func logUploadStatuses(t require.TestingT, statusC chan *diagnostics.LogStatusNotificationRequest, requestID int) []diagnostics.UploadLogStatus {
+	var statuses []diagnostics.UploadLogStatus
+	for {
+		select {
+		case request := <-statusC:
+			require.Equal(t, requestID, request.RequestID)
+			statuses = append(statuses, request.Status)
+			if request.Status != diagnostics.UploadLogStatusUploading {
+				return statuses
+			}
+		case <-time.After(2 * time.Second):
+			require.Fail(t, "log upload status not received")
+			return statuses
+		}
+	}
+}
+
+func newLogUploadServer(status func(request *http.Request) int, uploadedC chan uploadedLog) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code := status(r)
+		if code == http.StatusOK {
+			file, header, err := r.FormFile("file")
+			if err == nil {
+				content, _ := io.ReadAll(file)
+				uploadedC <- uploadedLog{filename: header.Filename, content: string(content)}
+			}
+		}
+		w.WriteHeader(code)
+	}))
+}
+
+func (suite *OcppV2TestSuite) TestLogUploadHTTP() {
+	t := suite.T()
+	uploadedC := make(chan uploadedLog, 10)
+	server := newLogUploadServer(func(r *http.Request) int {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "user" || password != "secret" {
+			return http.StatusUnauthorized
+		}
+		return http.StatusOK
+	}, uploadedC)
+	defer server.Close()
+	var collected []diagnostics.LogType
+	manager, statusC := newTestLogUploadManager(&collected)
+	requestID, status := manager.CurrentStatus()
+	assert.Equal(t, diagnostics.UploadLogStatusIdle, status)
+	// Success
+	location := strings.Replace(server.URL, "http://", "http://user:secret@", 1)
+	response, err := manager.OnGetLog(newTestGetLogRequest(1, location))
+	require.NoError(t, err)
+	assert.Equal(t, diagnostics.LogStatusAccepted, response.Status)
+	assert.Equal(t, "log.txt", response.Filename)
+	assert.Equal(t, []diagnostics.UploadLogStatus{diagnostics.UploadLogStatusUploading, diagnostics.UploadLogStatusUploaded}, logUploadStatuses(t, statusC, 1))
+	uploaded := <-uploadedC
+	assert.Equal(t, uploadedLog{filename: "log.txt", content: "log content"}, uploaded)
+	assert.Equal(t, []diagnostics.LogType{diagnostics.LogTypeDiagnostics}, collected)
+	requestID, status = manager.CurrentStatus()
+	assert.Equal(t, 1, requestID)
+	assert.Equal(t, diagnostics.UploadLogStatusUploaded, status)
+	// Authentication failure is not retried
+	request := newTestGetLogRequest(2, strings.Replace(server.URL, "http://", "http://user:wrong@", 1))
+	request.Retries = newInt(3)
+	response, err = manager.OnGetLog(request)
+	require.NoError(t, err)
+	assert.Equal(t, diagnostics.LogStatusAccepted, response.Status)
+	assert.Equal(t, []diagnostics.UploadLogStatus{diagnostics.UploadLogStatusUploading, diagnostics.UploadLogStatusPermissionDenied}, logUploadStatuses(t, statusC, 2))
+	// Unsupported scheme
+	response, err = manager.OnGetLog(newTestGetLogRequest(3, "sftp://example.com/logs"))
+	require.NoError(t, err)
+	assert.Equal(t, diagnostics.LogStatusRejected, response.Status)
+}
+
+func (suite *OcppV2TestSuite) TestLogUploadRetries() {
+	t := suite.T()
+	var attempts int
+	var mutex sync.Mutex
+	uploadedC := make(chan uploadedLog, 10)
+	server := newLogUploadServer(func(r *http.Request) int {
+		mutex.Lock()
+		defer mutex.Unlock()
+		attempts++
+		if attempts < 3 {
+			return http.StatusInternalServerError
+		}
+		return http.StatusOK
+	}, uploadedC)
+	defer server.Close()
+	manager, statusC := newTestLogUploadManager(nil)
+	// Not enough retries
+	request := newTestGetLogRequest(1, server.URL)
+	request.Retries = newInt(1)
+	request.RetryInterval = newInt(0)
+	_, err := manager.OnGetLog(request)
+	require.NoError(t, err)
+	assert.Equal(t, []diagnostics.UploadLogStatus{diagnostics.UploadLogStatusUploading, diagnostics.UploadLogStatusUploadFailure}, logUploadStatuses(t, statusC, 1))
+	// Third attempt succeeds
+	request = newTestGetLogRequest(2, server.URL)
+	request.Retries = newInt(2)
+	_, err = manager.OnGetLog(request)
+	require.NoError(t, err)
+	assert.Equal(t, []diagnostics.UploadLogStatus{diagnostics.UploadLogStatusUploading, diagnostics.UploadLogStatusUploaded}, logUploadStatuses(t, statusC, 2))
+	<-uploadedC
+}
+
+// Fails every upload, reporting each attempt on the channel.
+type failingLogUploader struct {
+	attemptC chan struct{}
+}
+
+func (u *failingLogUploader) Upload(ctx context.Context, location *url.URL, filename string, content []byte) error {
+	u.attemptC <- struct{}{}
+	return errors.New("connection refused")
+}
+
+func (suite *OcppV2TestSuite) TestLogUploadRetryInterval() {
+	t := suite.T()
+	fakeClock := clocktest.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	uploader := &failingLogUploader{attemptC: make(chan struct{}, 10)}
+	manager, statusC := newTestLogUploadManager(nil)
+	manager.SetClock(fakeClock)
+	manager.Uploaders["http"] = uploader
+	request := newTestGetLogRequest(1, "http://example.com/logs")
+	request.Retries = newInt(1)
+	request.RetryInterval = newInt(60)
+	_, err := manager.OnGetLog(request)
+	require.NoError(t, err)
+	<-uploader.attemptC
+	// The retry is only attempted once the interval elapsed
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(59 * time.Second)
+	select {
+	case <-uploader.attemptC:
+		require.Fail(t, "upload retried before the retry interval elapsed")
+	case <-time.After(50 * time.Millisecond):
+	}
+	fakeClock.Advance(time.Second)
+	<-uploader.attemptC
+	assert.Equal(t, []diagnostics.UploadLogStatus{diagnostics.UploadLogStatusUploading, diagnostics.UploadLogStatusUploadFailure}, logUploadStatuses(t, statusC, 1))
+}
+
+func (suite *OcppV2TestSuite) TestLogUploadSuperseded() {
+	t := suite.T()
+	releaseC := make(chan struct{})
+	uploadedC := make(chan uploadedLog, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			select {
+			case <-releaseC:
+			case <-r.Context().Done():
+			}
+			return
+		}
+		file, header, err := r.FormFile("file")
+		if err == nil {
+			content, _ := io.ReadAll(file)
+			uploadedC <- uploadedLog{filename: header.Filename, content: string(content)}
+		}
+	}))
+	defer server.Close()
+	defer close(releaseC)
+	manager, statusC := newTestLogUploadManager(nil)
+	response, err := manager.OnGetLog(newTestGetLogRequest(1, server.URL+"/slow"))
+	require.NoError(t, err)
+	assert.Equal(t, diagnostics.LogStatusAccepted, response.Status)
+	request := <-statusC
+	assert.Equal(t, diagnostics.UploadLogStatusUploading, request.Status)
+	// The new request cancels the ongoing upload
+	response, err = manager.OnGetLog(newTestGetLogRequest(2, server.URL+"/fast"))
+	require.NoError(t, err)
+	assert.Equal(t, diagnostics.LogStatusAcceptedCanceled, response.Status)
+	assert.NoError(t, types.Validate.Struct(response))
+	// No further status is reported for the canceled upload
+	assert.Equal(t, []diagnostics.UploadLogStatus{diagnostics.UploadLogStatusUploading, diagnostics.UploadLogStatusUploaded}, logUploadStatuses(t, statusC, 2))
+	<-uploadedC
+	// Completed uploads are not canceled
+	response, err = manager.OnGetLog(newTestGetLogRequest(3, server.URL+"/fast"))
+	require.NoError(t, err)
+	assert.Equal(t, diagnostics.LogStatusAccepted, response.Status)
+	logUploadStatuses(t, statusC, 3)
+}
+
+// Serves a single FTP session, accepting the passed password only.
+func serveTestFTP(t require.TestingT, listener net.Listener, password string, uploadedC chan uploadedLog) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(format string, args ...interface{}) {
+		_, _ = fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+	reply("220 ready")
+	var dataListener net.Listener
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command, argument, _ := strings.Cut(strings.TrimSpace(line), " ")
+		switch command {
+		case "USER":
+			reply("331 password required")
+		case "PASS":
+			if argument != password {
+				reply("530 login incorrect")
+				continue
+			}
+			reply("230 logged in")
+		case "TYPE":
+			reply("200 ok")
+		case "PASV":
+			dataListener, err = net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			port := dataListener.Addr().(*net.TCPAddr).Port
+			reply("227 Entering Passive Mode (127,0,0,1,%d,%d)", port>>8, port&0xff)
+		case "STOR":
+			reply("150 opening data connection")
+			data, err := dataListener.Accept()
+			require.NoError(t, err)
+			content, _ := io.ReadAll(data)
+			_ = data.Close()
+			_ = dataListener.Close()
+			uploadedC <- uploadedLog{filename: argument, content: string(content)}
+			reply("226 transfer complete")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func (suite *OcppV2TestSuite) TestLogUploadFTP() {
+	t := suite.T()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	uploadedC := make(chan uploadedLog, 1)
+	manager, statusC := newTestLogUploadManager(nil)
+	go serveTestFTP(t, listener, "secret", uploadedC)
+	_, err = manager.OnGetLog(newTestGetLogRequest(1, fmt.Sprintf("ftp://user:secret@%v/logs/", listener.Addr())))
+	require.NoError(t, err)
+	assert.Equal(t, []diagnostics.UploadLogStatus{diagnostics.UploadLogStatusUploading, diagnostics.UploadLogStatusUploaded}, logUploadStatuses(t, statusC, 1))
+	assert.Equal(t, uploadedLog{filename: "/logs/log.txt", content: "log content"}, <-uploadedC)
+	// Wrong password
+	go serveTestFTP(t, listener, "secret", uploadedC)
+	_, err = manager.OnGetLog(newTestGetLogRequest(2, fmt.Sprintf("ftp://user:wrong@%v/logs/", listener.Addr())))
+	require.NoError(t, err)
+	assert.Equal(t, []diagnostics.UploadLogStatus{diagnostics.UploadLogStatusUploading, diagnostics.UploadLogStatusPermissionDenied}, logUploadStatuses(t, statusC, 2))
+}
@@ -0,0 +1,259 @@
+package ocpp2_test
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/localauth"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+func newGetVariableData(n int) []provisioning.GetVariableData {
+	data := make([]provisioning.GetVariableData, n)
+	for i := range data {
+		data[i] = provisioning.GetVariableData{
+			Component: types.Component{Name: "component"},
+			Variable:  types.Variable{Name: fmt.Sprintf("variable%v", i)},
+		}
+	}
+	return data
+}
+
+func newAuthorizationData(n int) []localauth.AuthorizationData {
+	data := make([]localauth.AuthorizationData, n)
+	for i := range data {
+		data[i] = localauth.AuthorizationData{
+			IdToken:     types.IdToken{IdToken: fmt.Sprintf("token%v", i), Type: types.IdTokenTypeISO14443},
+			IdTokenInfo: types.NewIdTokenInfo(types.AuthorizationStatusAccepted),
+		}
+	}
+	return data
+}
+
+func (suite *OcppV2TestSuite) TestSplitGetVariablesByItems() {
+	t := suite.T()
+	request := provisioning.NewGetVariablesRequest(newGetVariableData(5))
+	// No split required
+	requests := provisioning.SplitGetVariables(request, provisioning.MessageLimits{ItemsPerMessageGetVariables: 5})
+	require.Len(t, requests, 1)
+	assert.Equal(t, request, requests[0])
+	requests = provisioning.SplitGetVariables(request, provisioning.MessageLimits{})
+	require.Len(t, requests, 1)
+	// Split into 2 + 2 + 1
+	requests = provisioning.SplitGetVariables(request, provisioning.MessageLimits{ItemsPerMessageGetVariables: 2})
+	require.Len(t, requests, 3)
+	assert.Len(t, requests[0].GetVariableData, 2)
+	assert.Len(t, requests[1].GetVariableData, 2)
+	assert.Len(t, requests[2].GetVariableData, 1)
+	var joined []provisioning.GetVariableData
+	for _, r := range requests {
+		joined = append(joined, r.GetVariableData...)
+	}
+	assert.Equal(t, request.GetVariableData, joined)
+}
+
+func (suite *OcppV2TestSuite) TestSplitSetVariablesByBytes() {
+	t := suite.T()
+	var data []provisioning.SetVariableData
+	for i := 0; i < 10; i++ {
+		data = append(data, provisioning.SetVariableData{
+			AttributeValue: fmt.Sprintf("value%v", i),
+			Component:      types.Component{Name: "component"},
+			Variable:       types.Variable{Name: fmt.Sprintf("variable%v", i)},
+		})
+	}
+	request := provisioning.NewSetVariablesRequest(data)
+	raw, _ := json.Marshal(request)
+	maxBytes := len(raw) / 3
+	requests := provisioning.SplitSetVariables(request, provisioning.MessageLimits{BytesPerMessageSetVariables: maxBytes})
+	require.True(t, len(requests) >= 3)
+	var joined []provisioning.SetVariableData
+	for _, r := range requests {
+		raw, err := json.Marshal(r)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, len(raw), maxBytes)
+		joined = append(joined, r.SetVariableData...)
+	}
+	assert.Equal(t, data, joined)
+	// Both limits apply
+	requests = provisioning.SplitSetVariables(request, provisioning.MessageLimits{ItemsPerMessageSetVariables: 1, BytesPerMessageSetVariables: maxBytes})
+	assert.Len(t, requests, 10)
+}
+
+func (suite *OcppV2TestSuite) TestSplitGetReport() {
+	t := suite.T()
+	request := provisioning.NewGetReportRequest()
+	request.RequestID = newInt(42)
+	for i := 0; i < 3; i++ {
+		request.ComponentVariable = append(request.ComponentVariable, types.ComponentVariable{
+			Component: types.Component{Name: fmt.Sprintf("component%v", i)},
+		})
+	}
+	requests := provisioning.SplitGetReport(request, provisioning.MessageLimits{ItemsPerMessageGetReport: 1})
+	require.Len(t, requests, 3)
+	for i, r := range requests {
+		require.NotNil(t, r.RequestID)
+		assert.Equal(t, 42+i, *r.RequestID)
+		assert.Equal(t, request.ComponentVariable[i:i+1], r.ComponentVariable)
+	}
+	// The original request is not modified
+	assert.Equal(t, 42, *request.RequestID)
+	assert.Len(t, request.ComponentVariable, 3)
+}
+
+func (suite *OcppV2TestSuite) TestSplitSendLocalList() {
+	t := suite.T()
+	request := localauth.NewSendLocalListRequest(3, localauth.UpdateTypeFull)
+	request.LocalAuthorizationList = newAuthorizationData(5)
+	requests, err := provisioning.SplitSendLocalList(request, provisioning.MessageLimits{ItemsPerMessageSendLocalList: 2})
+	require.NoError(t, err)
+	require.Len(t, requests, 3)
+	assert.Equal(t, localauth.UpdateTypeFull, requests[0].UpdateType)
+	assert.Equal(t, localauth.UpdateTypeDifferential, requests[1].UpdateType)
+	assert.Equal(t, localauth.UpdateTypeDifferential, requests[2].UpdateType)
+	// Versions increase up to the requested version
+	for i, r := range requests {
+		assert.Equal(t, i+1, r.VersionNumber)
+		assert.NoError(t, types.Validate.Struct(r))
+	}
+	assert.Equal(t, localauth.UpdateTypeFull, request.UpdateType)
+	assert.Equal(t, 3, request.VersionNumber)
+	// Not enough version numbers
+	request.VersionNumber = 2
+	_, err = provisioning.SplitSendLocalList(request, provisioning.MessageLimits{ItemsPerMessageSendLocalList: 2})
+	assert.ErrorIs(t, err, provisioning.ErrVersionNumberTooLow)
+	request.VersionNumber = 10
+	// Byte limits account for the longer Differential update type
+	raw, _ := json.Marshal(request)
+	maxBytes := len(raw) / 2
+	requests, err = provisioning.SplitSendLocalList(request, provisioning.MessageLimits{BytesPerMessageSendLocalList: maxBytes})
+	require.NoError(t, err)
+	require.True(t, len(requests) >= 2)
+	for _, r := range requests {
+		raw, _ := json.Marshal(r)
+		assert.LessOrEqual(t, len(raw), maxBytes)
+	}
+}
+
+func (suite *OcppV2TestSuite) TestMessageLimitsFromReport() {
+	t := suite.T()
+	reportData := func(component string, variable string, value string) provisioning.ReportData {
+		attribute := provisioning.NewVariableAttribute()
+		attribute.Type = types.AttributeActual
+		attribute.Value = value
+		return provisioning.ReportData{
+			Component:         types.Component{Name: component},
+			Variable:          types.Variable{Name: variable},
+			VariableAttribute: []provisioning.VariableAttribute{attribute},
+		}
+	}
+	limits := provisioning.MessageLimitsFromReport([]provisioning.ReportData{
+		reportData("DeviceDataCtrlr", "ItemsPerMessageGetVariables", "4"),
+		reportData("DeviceDataCtrlr", "BytesPerMessageGetVariables", "2048"),
+		reportData("DeviceDataCtrlr", "ItemsPerMessageSetVariables", "invalid"),
+		reportData("LocalAuthListCtrlr", "ItemsPerMessage", "100"),
+		reportData("OCPPCommCtrlr", "ItemsPerMessage", "7"),
+	})
+	assert.Equal(t, provisioning.MessageLimits{
+		ItemsPerMessageGetVariables:  4,
+		BytesPerMessageGetVariables:  2048,
+		ItemsPerMessageSendLocalList: 100,
+	}, limits)
+	assert.True(t, provisioning.MessageLimitsFromReport(nil).IsZero())
+}
+
+func (suite *OcppV2TestSuite) TestGetVariablesAutoSplit() {
+	t := suite.T()
+	wsId := "test_id"
+	wsUrl := "someUrl"
+	channel := NewMockWebSocket(wsId)
+	variableData := newGetVariableData(5)
+	var received [][]provisioning.GetVariableData
+	handler := &MockChargingStationProvisioningHandler{}
+	handler.On("OnGetVariables", mock.Anything).Run(func(args mock.Arguments) {
+		request := args.Get(0).(*provisioning.GetVariablesRequest)
+		received = append(received, request.GetVariableData)
+	})
+	// The mock cannot build responses dynamically, hence results are returned via a dedicated handler
+	provisioningHandler := &splitTestProvisioningHandler{MockChargingStationProvisioningHandler: handler}
+	setupDefaultCSMSHandlers(suite, expectedCSMSOptions{clientId: wsId, forwardWrittenMessage: true})
+	setupDefaultChargingStationHandlers(suite, expectedChargingStationOptions{serverUrl: wsUrl, clientId: wsId, createChannelOnStart: true, channel: channel, forwardWrittenMessage: true})
+	suite.chargingStation.SetProvisioningHandler(provisioningHandler)
+	suite.csms.SetMessageLimits(wsId, provisioning.MessageLimits{ItemsPerMessageGetVariables: 2})
+	// Run Test
+	suite.csms.Start(8887, "somePath")
+	err := suite.chargingStation.Start(wsUrl)
+	require.Nil(t, err)
+	resultChannel := make(chan *provisioning.GetVariablesResponse, 1)
+	err = suite.csms.GetVariables(wsId, func(response *provisioning.GetVariablesResponse, err error) {
+		require.Nil(t, err)
+		resultChannel <- response
+	}, variableData)
+	require.Nil(t, err)
+	response := <-resultChannel
+	require.NotNil(t, response)
+	require.Len(t, response.GetVariableResult, 5)
+	for i, result := range response.GetVariableResult {
+		assert.Equal(t, variableData[i].Variable.Name, result.Variable.Name)
+	}
+	require.Len(t, received, 3)
+	assert.Len(t, received[0], 2)
+	assert.Len(t, received[2], 1)
+}
+
+func (suite *OcppV2TestSuite) TestSendLocalListAutoSplitStopsOnFailure() {
+	t := suite.T()
+	wsId := "test_id"
+	wsUrl := "someUrl"
+	channel := NewMockWebSocket(wsId)
+	var updateTypes []localauth.UpdateType
+	handler := &MockChargingStationLocalAuthHandler{}
+	recordUpdateType := func(args mock.Arguments) {
+		updateTypes = append(updateTypes, args.Get(0).(*localauth.SendLocalListRequest).UpdateType)
+	}
+	handler.On("OnSendLocalList", mock.Anything).Return(localauth.NewSendLocalListResponse(localauth.SendLocalListStatusAccepted), nil).Run(recordUpdateType).Once()
+	handler.On("OnSendLocalList", mock.Anything).Return(localauth.NewSendLocalListResponse(localauth.SendLocalListStatusFailed), nil).Run(recordUpdateType)
+	setupDefaultCSMSHandlers(suite, expectedCSMSOptions{clientId: wsId, forwardWrittenMessage: true})
+	setupDefaultChargingStationHandlers(suite, expectedChargingStationOptions{serverUrl: wsUrl, clientId: wsId, createChannelOnStart: true, channel: channel, forwardWrittenMessage: true}, handler)
+	suite.csms.SetMessageLimits(wsId, provisioning.MessageLimits{ItemsPerMessageSendLocalList: 2})
+	// Run Test
+	suite.csms.Start(8887, "somePath")
+	err := suite.chargingStation.Start(wsUrl)
+	require.Nil(t, err)
+	resultChannel := make(chan *localauth.SendLocalListResponse, 1)
+	err = suite.csms.SendLocalList(wsId, func(response *localauth.SendLocalListResponse, err error) {
+		require.Nil(t, err)
+		resultChannel <- response
+	}, 3, localauth.UpdateTypeFull, func(request *localauth.SendLocalListRequest) {
+		request.LocalAuthorizationList = newAuthorizationData(5)
+	})
+	require.Nil(t, err)
+	response := <-resultChannel
+	require.NotNil(t, response)
+	assert.Equal(t, localauth.SendLocalListStatusFailed, response.Status)
+	// The third part is never sent
+	assert.Equal(t, []localauth.UpdateType{localauth.UpdateTypeFull, localauth.UpdateTypeDifferential}, updateTypes)
+}
+
+type splitTestProvisioningHandler struct {
+	*MockChargingStationProvisioningHandler
+}
+
+func (h *splitTestProvisioningHandler) OnGetVariables(request *provisioning.GetVariablesRequest) (*provisioning.GetVariablesResponse, error) {
+	h.MethodCalled("OnGetVariables", request)
+	results := make([]provisioning.GetVariableResult, len(request.GetVariableData))
+	for i, data := range request.GetVariableData {
+		results[i] = provisioning.GetVariableResult{
+			AttributeStatus: provisioning.GetVariableStatusAccepted,
+			AttributeValue:  "value",
+			Component:       data.Component,
+			Variable:        data.Variable,
+		}
+	}
+	return provisioning.NewGetVariablesResponse(results), nil
+}
@@ -0,0 +1,79 @@
+package ocpp2_test
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+func (suite *OcppV2TestSuite) TestMeterValueBuilder() {
+	t := suite.T()
+	timestamp := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	signed := types.SignedMeterValue{SignedMeterData: "3q2+7w==", SigningMethod: "ECDSAP256SHA256", EncodingMethod: "OCMF", PublicKey: "001NwN4="}
+	meterValue, err := types.NewMeterValue(timestamp).
+		Energy(15.23, types.KWh).
+		Signed(signed).
+		PowerPhase(7.4, types.KW, types.PhaseL1).
+		Current(32, types.PhaseL1).Location(types.LocationOutlet).
+		SOC(80).
+		TransactionEnd().
+		Build()
+	require.NoError(t, err)
+	expected := types.MeterValue{
+		Timestamp: *types.NewDateTime(timestamp),
+		SampledValue: []types.SampledValue{
+			{Value: 15.23, Context: types.ReadingContextTransactionEnd, Measurand: types.MeasurandEnergyActiveImportRegister, SignedMeterValue: &signed, UnitOfMeasure: &types.UnitOfMeasure{Unit: "Wh", Multiplier: newInt(3)}},
+			{Value: 7.4, Context: types.ReadingContextTransactionEnd, Measurand: types.MeasurandPowerActiveImport, Phase: types.PhaseL1, UnitOfMeasure: &types.UnitOfMeasure{Unit: "W", Multiplier: newInt(3)}},
+			{Value: 32, Context: types.ReadingContextTransactionEnd, Measurand: types.MeasurandCurrentImport, Phase: types.PhaseL1, Location: types.LocationOutlet, UnitOfMeasure: &types.UnitOfMeasure{Unit: "A"}},
+			{Value: 80, Context: types.ReadingContextTransactionEnd, Measurand: types.MeasueandSoC, Location: types.LocationEV, UnitOfMeasure: &types.UnitOfMeasure{Unit: "Percent"}},
+		},
+	}
+	assert.Equal(t, expected, meterValue)
+	// Base units don't use a multiplier
+	meterValue, err = types.NewMeterValue(timestamp).Energy(15230, types.Wh).Power(7400, types.W).Voltage(230, types.PhaseL1N).TransactionBegin().Build()
+	require.NoError(t, err)
+	expected = types.MeterValue{
+		Timestamp: *types.NewDateTime(timestamp),
+		SampledValue: []types.SampledValue{
+			{Value: 15230, Context: types.ReadingContextTransactionBegin, Measurand: types.MeasurandEnergyActiveImportRegister, UnitOfMeasure: &types.UnitOfMeasure{Unit: "Wh"}},
+			{Value: 7400, Context: types.ReadingContextTransactionBegin, Measurand: types.MeasurandPowerActiveImport, UnitOfMeasure: &types.UnitOfMeasure{Unit: "W"}},
+			{Value: 230, Context: types.ReadingContextTransactionBegin, Measurand: types.MeasurandVoltage, Phase: types.PhaseL1N, UnitOfMeasure: &types.UnitOfMeasure{Unit: "V"}},
+		},
+	}
+	assert.Equal(t, expected, meterValue)
+	// Arbitrary measurands and explicit multipliers
+	meterValue, err = types.NewMeterValue(timestamp).Measure(types.MeasurandTemperature, 35, types.Celsius, "").Location(types.LocationBody).SamplePeriodic().Build()
+	require.NoError(t, err)
+	assert.Equal(t, types.ReadingContextSamplePeriodic, meterValue.SampledValue[0].Context)
+	assert.Equal(t, types.LocationBody, meterValue.SampledValue[0].Location)
+	meterValue, err = types.NewMeterValue(timestamp).Energy(15.23, types.Wh).Multiplier(6).Build()
+	require.NoError(t, err)
+	assert.Equal(t, newInt(6), meterValue.SampledValue[0].UnitOfMeasure.Multiplier)
+}
+
+func (suite *OcppV2TestSuite) TestMeterValueBuilderInvalidCombinations() {
+	t := suite.T()
+	timestamp := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	// SoC with a phase
+	_, err := types.NewMeterValue(timestamp).Measure(types.MeasueandSoC, 80, types.Percent, types.PhaseL1).Build()
+	assert.Error(t, err)
+	// Negative multiplier
+	_, err = types.NewMeterValue(timestamp).Energy(15.23, types.KWh).Multiplier(-1).Build()
+	assert.Error(t, err)
+	// No sampled values
+	_, err = types.NewMeterValue(timestamp).TransactionEnd().Build()
+	assert.Error(t, err)
+	// Settings applied before any sampled value
+	_, err = types.NewMeterValue(timestamp).Location(types.LocationEV).SOC(80).Build()
+	assert.Error(t, err)
+	_, err = types.NewMeterValue(timestamp).Signed(types.SignedMeterValue{}).Energy(1, types.Wh).Build()
+	assert.Error(t, err)
+	// Invalid enum values are caught by the validation
+	_, err = types.NewMeterValue(timestamp).Measure("invalidMeasurand", 1, types.Wh, "").Build()
+	assert.Error(t, err)
+	_, err = types.NewMeterValue(timestamp).Energy(1, types.Wh).Signed(types.SignedMeterValue{SignedMeterData: "not base64"}).Build()
+	assert.Error(t, err)
+}
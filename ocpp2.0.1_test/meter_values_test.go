@@ -38,7 +38,7 @@ func (suite *OcppV2TestSuite) TestMeterValuesE2EMocked() {
 	messageId := defaultMessageId
 	wsUrl := "someUrl"
 	evseId := 1
-	signedMeterValue := types.SignedMeterValue{SignedMeterData: "0xdeadbeef", SigningMethod: "ECDSAP256SHA256", EncodingMethod: "DLMS Message", PublicKey: "0xd34dc0de"}
+	signedMeterValue := types.SignedMeterValue{SignedMeterData: "3q2+7w==", SigningMethod: "ECDSAP256SHA256", EncodingMethod: "DLMS Message", PublicKey: "001NwN4="}
 	unitOfMeasure := types.UnitOfMeasure{Unit: "kW", Multiplier: newInt(0)}
 	sampledValue := types.SampledValue{Value: 3.14, Context: types.ReadingContextTransactionEnd, Measurand: types.MeasurandPowerActiveExport, Phase: types.PhaseL2, Location: types.LocationBody, SignedMeterValue: &signedMeterValue, UnitOfMeasure: &unitOfMeasure}
 	sampledValues := []types.SampledValue{sampledValue}
@@ -87,7 +87,7 @@ func (suite *OcppV2TestSuite) TestMeterValuesInvalidEndpoint() {
 	messageId := defaultMessageId
 	connectorId := 1
 	evseId := 1
-	signedMeterValue := types.SignedMeterValue{SignedMeterData: "0xdeadbeef", SigningMethod: "ECDSAP256SHA256", EncodingMethod: "DLMS Message", PublicKey: "0xd34dc0de"}
+	signedMeterValue := types.SignedMeterValue{SignedMeterData: "3q2+7w==", SigningMethod: "ECDSAP256SHA256", EncodingMethod: "DLMS Message", PublicKey: "001NwN4="}
 	unitOfMeasure := types.UnitOfMeasure{Unit: "kW", Multiplier: newInt(0)}
 	sampledValue := types.SampledValue{Value: 3.14, Context: types.ReadingContextTransactionEnd, Measurand: types.MeasurandPowerActiveExport, Phase: types.PhaseL2, Location: types.LocationBody, SignedMeterValue: &signedMeterValue, UnitOfMeasure: &unitOfMeasure}
 	sampledValues := []types.SampledValue{sampledValue}
@@ -0,0 +1,232 @@
+package ocpp2_test
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/diagnostics"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+var (
+	monitoredComponent = types.Component{Name: "EVSE", EVSE: &types.EVSE{ID: 1}}
+	monitoredVariable  = types.Variable{Name: "Power"}
+)
+
+func newMonitoringData(monitorType diagnostics.MonitorType, value float64, severity int) diagnostics.SetMonitoringData {
+	return diagnostics.SetMonitoringData{Type: monitorType, Value: value, Severity: severity, Component: monitoredComponent, Variable: monitoredVariable}
+}
+
+func setTestMonitors(t require.TestingT, store *diagnostics.MonitoringStore, data ...diagnostics.SetMonitoringData) []diagnostics.SetMonitoringResult {
+	response, err := store.OnSetVariableMonitoring(diagnostics.NewSetVariableMonitoringRequest(data))
+	require.NoError(t, err)
+	require.Len(t, response.MonitoringResult, len(data))
+	return response.MonitoringResult
+}
+
+func triggeredMonitorIDs(triggered []diagnostics.TriggeredMonitor) []int {
+	var ids []int
+	for _, t := range triggered {
+		ids = append(ids, t.Monitor.ID)
+	}
+	return ids
+}
+
+func (suite *OcppV2TestSuite) TestMonitoringStoreSetAndClear() {
+	t := suite.T()
+	store, err := diagnostics.NewMonitoringStore(nil)
+	require.NoError(t, err)
+	store.IsKnownVariable = func(component types.Component, variable types.Variable) bool {
+		return variable.Name == monitoredVariable.Name
+	}
+	unknown := newMonitoringData(diagnostics.MonitorDelta, 1, 5)
+	unknown.Variable = types.Variable{Name: "Unknown"}
+	results := setTestMonitors(t, store,
+		newMonitoringData(diagnostics.MonitorUpperThreshold, 100, 5),
+		newMonitoringData(diagnostics.MonitorUpperThreshold, 100, 5),
+		newMonitoringData(diagnostics.MonitorUpperThreshold, 200, 3),
+		unknown,
+		newMonitoringData(diagnostics.MonitorPeriodic, 0, 5),
+	)
+	assert.Equal(t, diagnostics.SetMonitoringStatusAccepted, results[0].Status)
+	require.NotNil(t, results[0].ID)
+	assert.Equal(t, 1, *results[0].ID)
+	assert.Equal(t, diagnostics.SetMonitoringStatusDuplicate, results[1].Status)
+	assert.Nil(t, results[1].ID)
+	assert.Equal(t, diagnostics.SetMonitoringStatusAccepted, results[2].Status)
+	assert.Equal(t, 2, *results[2].ID)
+	assert.Equal(t, diagnostics.SetMonitoringStatusUnknownVariable, results[3].Status)
+	assert.Equal(t, diagnostics.SetMonitoringStatusRejected, results[4].Status)
+	response := diagnostics.NewSetVariableMonitoringResponse(results)
+	assert.NoError(t, types.Validate.Struct(response))
+	// Replacing a monitor keeps its ID, but may not create a duplicate
+	replacement := newMonitoringData(diagnostics.MonitorUpperThreshold, 150, 3)
+	replacement.ID = newInt(2)
+	results = setTestMonitors(t, store, replacement)
+	assert.Equal(t, diagnostics.SetMonitoringStatusAccepted, results[0].Status)
+	assert.Equal(t, 2, *results[0].ID)
+	replacement.Severity = 5
+	results = setTestMonitors(t, store, replacement)
+	assert.Equal(t, diagnostics.SetMonitoringStatusDuplicate, results[0].Status)
+	replacement.ID = newInt(99)
+	results = setTestMonitors(t, store, replacement)
+	assert.Equal(t, diagnostics.SetMonitoringStatusRejected, results[0].Status)
+	monitors := store.Monitors()
+	require.Len(t, monitors, 2)
+	assert.Equal(t, 150.0, monitors[1].Value)
+	assert.Equal(t, diagnostics.EventCustomMonitor, monitors[1].Origin)
+	// Hard-wired monitors can't be cleared
+	hardWiredID, err := store.Install(diagnostics.Monitor{
+		VariableMonitoring: diagnostics.NewVariableMonitoring(0, false, 10, diagnostics.MonitorLowerThreshold, 0),
+		Component:          monitoredComponent,
+		Variable:           monitoredVariable,
+		Origin:             diagnostics.EventHardWiredMonitor,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, hardWiredID)
+	clearResponse, err := store.OnClearVariableMonitoring(diagnostics.NewClearVariableMonitoringRequest([]int{1, 1, hardWiredID, 42}))
+	require.NoError(t, err)
+	var statuses []diagnostics.ClearMonitoringStatus
+	for _, result := range clearResponse.ClearMonitoringResult {
+		statuses = append(statuses, result.Status)
+	}
+	assert.Equal(t, []diagnostics.ClearMonitoringStatus{
+		diagnostics.ClearMonitoringStatusAccepted,
+		diagnostics.ClearMonitoringStatusNotFound,
+		diagnostics.ClearMonitoringStatusRejected,
+		diagnostics.ClearMonitoringStatusNotFound,
+	}, statuses)
+	assert.Len(t, store.Monitors(), 2)
+}
+
+func (suite *OcppV2TestSuite) TestMonitoringStorePersistence() {
+	t := suite.T()
+	storage := diagnostics.NewMemoryMonitorStorage()
+	store, err := diagnostics.NewMonitoringStore(storage)
+	require.NoError(t, err)
+	setTestMonitors(t, store, newMonitoringData(diagnostics.MonitorDelta, 1, 5), newMonitoringData(diagnostics.MonitorDelta, 1, 6))
+	// A new store resumes ID allocation after the stored monitors
+	store, err = diagnostics.NewMonitoringStore(storage)
+	require.NoError(t, err)
+	require.Len(t, store.Monitors(), 2)
+	results := setTestMonitors(t, store, newMonitoringData(diagnostics.MonitorDelta, 1, 7))
+	assert.Equal(t, 3, *results[0].ID)
+}
+
+func (suite *OcppV2TestSuite) TestMonitoringStoreThresholdAndDelta() {
+	t := suite.T()
+	store, err := diagnostics.NewMonitoringStore(nil)
+	require.NoError(t, err)
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	setTestMonitors(t, store,
+		newMonitoringData(diagnostics.MonitorUpperThreshold, 100, 5),
+		newMonitoringData(diagnostics.MonitorDelta, 10, 8),
+	)
+	evaluate := func(value float64) []diagnostics.TriggeredMonitor {
+		return store.Evaluate(monitoredComponent, monitoredVariable, value, now)
+	}
+	// The first sample sets the delta reference
+	assert.Empty(t, evaluate(50))
+	// Changes within the delta don't trigger, and don't move the reference
+	assert.Empty(t, evaluate(58))
+	assert.Empty(t, evaluate(42))
+	assert.Empty(t, evaluate(60))
+	triggered := evaluate(61)
+	require.Len(t, triggered, 1)
+	assert.Equal(t, diagnostics.EventTriggerDelta, triggered[0].Trigger)
+	assert.Equal(t, 61.0, triggered[0].ActualValue)
+	assert.Empty(t, evaluate(55))
+	// Threshold triggers once, and clears once
+	triggered = evaluate(101)
+	require.Len(t, triggered, 2)
+	assert.Equal(t, diagnostics.EventTriggerAlerting, triggered[0].Trigger)
+	assert.False(t, triggered[0].Cleared)
+	assert.Empty(t, evaluate(105))
+	triggered = evaluate(99)
+	assert.Equal(t, []int{1}, triggeredMonitorIDs(triggered))
+	assert.True(t, triggered[0].Cleared)
+	eventData := triggered[0].EventData(7, now)
+	assert.Equal(t, "99", eventData.ActualValue)
+	assert.Equal(t, diagnostics.EventCustomMonitor, eventData.EventNotificationType)
+	assert.NoError(t, types.Validate.Struct(eventData))
+	// Other variables are not affected
+	assert.Empty(t, store.Evaluate(monitoredComponent, types.Variable{Name: "Current"}, 1000, now))
+	// The monitoring level filters out the delta monitor
+	_, err = store.OnSetMonitoringLevel(diagnostics.NewSetMonitoringLevelRequest(5))
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, triggeredMonitorIDs(evaluate(200)))
+	assert.Empty(t, evaluate(220))
+}
+
+func (suite *OcppV2TestSuite) TestMonitoringStorePeriodic() {
+	t := suite.T()
+	clock := &fakeCostClock{now: time.Date(2023, 1, 1, 12, 7, 30, 0, time.UTC)}
+	store, err := diagnostics.NewMonitoringStore(nil)
+	require.NoError(t, err)
+	store.SetTimeSource(clock.Now)
+	setTestMonitors(t, store,
+		newMonitoringData(diagnostics.MonitorPeriodic, 600, 5),
+		newMonitoringData(diagnostics.MonitorPeriodicClockAligned, 900, 6),
+	)
+	evaluate := func() []int {
+		return triggeredMonitorIDs(store.Evaluate(monitoredComponent, monitoredVariable, 1, clock.Now()))
+	}
+	assert.Empty(t, evaluate())
+	// Clock-aligned monitor triggers at 12:15
+	clock.Advance(7*time.Minute + 29*time.Second)
+	assert.Empty(t, evaluate())
+	clock.Advance(time.Second)
+	assert.Equal(t, []int{2}, evaluate())
+	assert.Empty(t, evaluate())
+	// Periodic monitor triggers 10 minutes after being set, at 12:17:30
+	clock.Advance(2*time.Minute + 30*time.Second)
+	assert.Equal(t, []int{1}, evaluate())
+	// Missed intervals are reported once: 12:30 and 12:45 elapsed, next trigger at 13:00
+	clock.Advance(30 * time.Minute)
+	assert.Equal(t, []int{1, 2}, evaluate())
+	clock.Advance(10 * time.Minute)
+	assert.Equal(t, []int{1}, evaluate())
+	clock.Advance(2*time.Minute + 30*time.Second)
+	assert.Equal(t, []int{2}, evaluate())
+}
+
+func (suite *OcppV2TestSuite) TestMonitoringStoreBase() {
+	t := suite.T()
+	store, err := diagnostics.NewMonitoringStore(nil)
+	require.NoError(t, err)
+	response, err := store.OnSetMonitoringBase(diagnostics.NewSetMonitoringBaseRequest(diagnostics.MonitoringBaseAll))
+	require.NoError(t, err)
+	assert.Equal(t, types.GenericDeviceModelStatusNotSupported, response.Status)
+	preset := func(monitorType diagnostics.MonitorType) diagnostics.Monitor {
+		return diagnostics.Monitor{
+			VariableMonitoring: diagnostics.NewVariableMonitoring(0, false, 100, monitorType, 3),
+			Component:          monitoredComponent,
+			Variable:           monitoredVariable,
+		}
+	}
+	store.SetPreset(diagnostics.MonitoringBaseAll, []diagnostics.Monitor{preset(diagnostics.MonitorUpperThreshold), preset(diagnostics.MonitorLowerThreshold)})
+	store.SetPreset(diagnostics.MonitoringBaseFactoryDefault, []diagnostics.Monitor{preset(diagnostics.MonitorUpperThreshold)})
+	setTestMonitors(t, store, newMonitoringData(diagnostics.MonitorDelta, 5, 5))
+	origins := func() map[diagnostics.EventNotification]int {
+		result := map[diagnostics.EventNotification]int{}
+		for _, monitor := range store.Monitors() {
+			result[monitor.Origin]++
+		}
+		return result
+	}
+	response, err = store.OnSetMonitoringBase(diagnostics.NewSetMonitoringBaseRequest(diagnostics.MonitoringBaseAll))
+	require.NoError(t, err)
+	assert.Equal(t, types.GenericDeviceModelStatusAccepted, response.Status)
+	assert.Equal(t, map[diagnostics.EventNotification]int{diagnostics.EventCustomMonitor: 1, diagnostics.EventPreconfiguredMonitor: 2}, origins())
+	_, err = store.OnSetMonitoringBase(diagnostics.NewSetMonitoringBaseRequest(diagnostics.MonitoringBaseFactoryDefault))
+	require.NoError(t, err)
+	assert.Equal(t, map[diagnostics.EventNotification]int{diagnostics.EventCustomMonitor: 1, diagnostics.EventPreconfiguredMonitor: 1}, origins())
+	_, err = store.Install(diagnostics.Monitor{VariableMonitoring: diagnostics.NewVariableMonitoring(0, false, 1, diagnostics.MonitorDelta, 0), Component: monitoredComponent, Variable: monitoredVariable, Origin: diagnostics.EventHardWiredMonitor})
+	require.NoError(t, err)
+	response, err = store.OnSetMonitoringBase(diagnostics.NewSetMonitoringBaseRequest(diagnostics.MonitoringBaseHardWiredOnly))
+	require.NoError(t, err)
+	assert.Equal(t, types.GenericDeviceModelStatusAccepted, response.Status)
+	assert.Equal(t, map[diagnostics.EventNotification]int{diagnostics.EventHardWiredMonitor: 1}, origins())
+}
@@ -0,0 +1,105 @@
+package ocpp2_test
+
+import (
+	"errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+)
+
+type failingNetworkProfileStorage struct {
+	provisioning.NetworkProfileStorage
+}
+
+func (s failingNetworkProfileStorage) Save(profiles map[int]provisioning.NetworkConnectionProfile) error {
+	return errors.New("storage full")
+}
+
+func newTestNetworkProfile(csmsURL string, securityProfile int) provisioning.NetworkConnectionProfile {
+	return provisioning.NetworkConnectionProfile{OCPPVersion: provisioning.OCPPVersion20, OCPPTransport: provisioning.OCPPTransportJSON, CSMSUrl: csmsURL, MessageTimeout: 30, SecurityProfile: securityProfile, OCPPInterface: provisioning.OCPPInterfaceWired0}
+}
+
+func (suite *OcppV2TestSuite) TestNetworkProfileStoreSlots() {
+	t := suite.T()
+	storage := provisioning.NewMemoryNetworkProfileStorage()
+	store, err := provisioning.NewNetworkProfileStore(storage, []int{1, 2})
+	require.NoError(t, err)
+	var changed []int
+	store.OnProfileChanged = func(configurationSlot int, profile provisioning.NetworkConnectionProfile) {
+		changed = append(changed, configurationSlot)
+	}
+	response, err := store.OnSetNetworkProfile(provisioning.NewSetNetworkProfileRequest(1, newTestNetworkProfile("ws://csms1:8887/ocpp", 1)))
+	require.NoError(t, err)
+	assert.Equal(t, provisioning.SetNetworkProfileStatusAccepted, response.Status)
+	// Overwrite the slot
+	response, err = store.OnSetNetworkProfile(provisioning.NewSetNetworkProfileRequest(1, newTestNetworkProfile("wss://csms2:443/ocpp", 2)))
+	require.NoError(t, err)
+	assert.Equal(t, provisioning.SetNetworkProfileStatusAccepted, response.Status)
+	profile, ok := store.Profile(1)
+	require.True(t, ok)
+	assert.Equal(t, "wss://csms2:443/ocpp", profile.CSMSUrl)
+	assert.Equal(t, []int{1, 1}, changed)
+	// The profiles are persisted
+	reloaded, err := provisioning.NewNetworkProfileStore(storage, []int{1, 2})
+	require.NoError(t, err)
+	profile, ok = reloaded.Profile(1)
+	require.True(t, ok)
+	assert.Equal(t, 2, profile.SecurityProfile)
+	// Unsupported slot
+	response, err = store.OnSetNetworkProfile(provisioning.NewSetNetworkProfileRequest(3, newTestNetworkProfile("ws://csms3:8887/ocpp", 1)))
+	require.NoError(t, err)
+	assert.Equal(t, provisioning.SetNetworkProfileStatusRejected, response.Status)
+	require.NotNil(t, response.StatusInfo)
+	assert.Equal(t, "InvalidConfSlot", response.StatusInfo.ReasonCode)
+	// Security downgrade
+	store.SetActiveSecurityProfile(2)
+	response, err = store.OnSetNetworkProfile(provisioning.NewSetNetworkProfileRequest(2, newTestNetworkProfile("ws://csms3:8887/ocpp", 1)))
+	require.NoError(t, err)
+	assert.Equal(t, provisioning.SetNetworkProfileStatusRejected, response.Status)
+	assert.Equal(t, "NoSecurityDowngrade", response.StatusInfo.ReasonCode)
+	// Unsupported OCPP version
+	legacy := newTestNetworkProfile("wss://csms3:443/ocpp", 2)
+	legacy.OCPPVersion = provisioning.OCPPVersion16
+	response, err = store.OnSetNetworkProfile(provisioning.NewSetNetworkProfileRequest(2, legacy))
+	require.NoError(t, err)
+	assert.Equal(t, provisioning.SetNetworkProfileStatusRejected, response.Status)
+	assert.Equal(t, "UnsupportedParam", response.StatusInfo.ReasonCode)
+	_, ok = store.Profile(2)
+	assert.False(t, ok)
+	// Storage failure
+	failing, err := provisioning.NewNetworkProfileStore(failingNetworkProfileStorage{provisioning.NewMemoryNetworkProfileStorage()}, []int{1})
+	require.NoError(t, err)
+	response, err = failing.OnSetNetworkProfile(provisioning.NewSetNetworkProfileRequest(1, newTestNetworkProfile("ws://csms1:8887/ocpp", 1)))
+	require.NoError(t, err)
+	assert.Equal(t, provisioning.SetNetworkProfileStatusFailed, response.Status)
+	_, ok = failing.Profile(1)
+	assert.False(t, ok)
+}
+
+func (suite *OcppV2TestSuite) TestNetworkProfileStoreCandidateURLs() {
+	t := suite.T()
+	store, err := provisioning.NewNetworkProfileStore(nil, []int{0, 1, 2})
+	require.NoError(t, err)
+	assert.Empty(t, store.CandidateURLs("cs1"))
+	_, err = store.OnSetNetworkProfile(provisioning.NewSetNetworkProfileRequest(0, newTestNetworkProfile("ws://primary:8887/ocpp", 1)))
+	require.NoError(t, err)
+	_, err = store.OnSetNetworkProfile(provisioning.NewSetNetworkProfileRequest(2, newTestNetworkProfile("wss://backup:443/ocpp/", 2)))
+	require.NoError(t, err)
+	// Empty slots are skipped
+	assert.Equal(t, []string{"ws://primary:8887/ocpp/cs1", "wss://backup:443/ocpp/cs1"}, store.CandidateURLs("cs1"))
+	// A reconfigured priority is picked up right away
+	require.NoError(t, store.SetPriority([]int{2, 0}))
+	assert.Equal(t, []string{"wss://backup:443/ocpp/cs1", "ws://primary:8887/ocpp/cs1"}, store.CandidateURLs("cs1"))
+	require.NoError(t, store.SetPriority([]int{2}))
+	assert.Equal(t, []string{"wss://backup:443/ocpp/cs1"}, store.CandidateURLs("cs1"))
+	assert.Error(t, store.SetPriority([]int{5}))
+	// A reprovisioned slot is picked up right away
+	_, err = store.OnSetNetworkProfile(provisioning.NewSetNetworkProfileRequest(2, newTestNetworkProfile("wss://migrated:443/ocpp", 2)))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"wss://migrated:443/ocpp/cs1"}, store.CandidateURLs("cs1"))
+	candidates := store.Candidates()
+	require.Len(t, candidates, 1)
+	assert.Equal(t, 2, candidates[0].SecurityProfile)
+}
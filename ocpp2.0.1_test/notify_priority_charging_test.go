@@ -0,0 +1,73 @@
+package ocpp2_test
+
+import (
+	"fmt"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/remotecontrol"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// Test
+func (suite *OcppV2TestSuite) TestNotifyPriorityChargingRequestValidation() {
+	t := suite.T()
+	var requestTable = []GenericTestEntry{
+		{remotecontrol.NotifyPriorityChargingRequest{TransactionID: "1234", Activated: true}, true},
+		{remotecontrol.NotifyPriorityChargingRequest{TransactionID: "1234"}, true},
+		{remotecontrol.NotifyPriorityChargingRequest{Activated: true}, false},
+		{remotecontrol.NotifyPriorityChargingRequest{}, false},
+		{remotecontrol.NotifyPriorityChargingRequest{TransactionID: ">36..................................", Activated: true}, false},
+	}
+	ExecuteGenericTestTable(t, requestTable)
+}
+
+func (suite *OcppV2TestSuite) TestNotifyPriorityChargingResponseValidation() {
+	t := suite.T()
+	var responseTable = []GenericTestEntry{
+		{remotecontrol.NotifyPriorityChargingResponse{}, true},
+	}
+	ExecuteGenericTestTable(t, responseTable)
+}
+
+func (suite *OcppV2TestSuite) TestNotifyPriorityChargingE2EMocked() {
+	t := suite.T()
+	wsId := "test_id"
+	messageId := defaultMessageId
+	wsUrl := "someUrl"
+	transactionID := "1234"
+	activated := true
+	requestJson := fmt.Sprintf(`[2,"%v","%v",{"transactionId":"%v","activated":%v}]`,
+		messageId, remotecontrol.NotifyPriorityChargingFeatureName, transactionID, activated)
+	responseJson := fmt.Sprintf(`[3,"%v",{}]`, messageId)
+	response := remotecontrol.NewNotifyPriorityChargingResponse()
+	channel := NewMockWebSocket(wsId)
+
+	handler := &MockCSMSRemoteControlHandler{}
+	handler.On("OnNotifyPriorityCharging", mock.AnythingOfType("string"), mock.Anything).Return(response, nil).Run(func(args mock.Arguments) {
+		request, ok := args.Get(1).(*remotecontrol.NotifyPriorityChargingRequest)
+		require.True(t, ok)
+		assert.Equal(t, transactionID, request.TransactionID)
+		assert.Equal(t, activated, request.Activated)
+	})
+	setupDefaultCSMSHandlers(suite, expectedCSMSOptions{clientId: wsId, rawWrittenMessage: []byte(responseJson), forwardWrittenMessage: true}, handler)
+	setupDefaultChargingStationHandlers(suite, expectedChargingStationOptions{serverUrl: wsUrl, clientId: wsId, createChannelOnStart: true, channel: channel, rawWrittenMessage: []byte(requestJson), forwardWrittenMessage: true})
+	// Run Test
+	suite.csms.Start(8887, "somePath")
+	err := suite.chargingStation.Start(wsUrl)
+	require.Nil(t, err)
+	r, err := suite.chargingStation.NotifyPriorityCharging(transactionID, activated)
+	assert.Nil(t, err)
+	assert.NotNil(t, r)
+}
+
+func (suite *OcppV2TestSuite) TestNotifyPriorityChargingInvalidEndpoint() {
+	messageId := defaultMessageId
+	transactionID := "1234"
+	activated := true
+	request := remotecontrol.NewNotifyPriorityChargingRequest(transactionID, activated)
+	requestJson := fmt.Sprintf(`[2,"%v","%v",{"transactionId":"%v","activated":%v}]`,
+		messageId, remotecontrol.NotifyPriorityChargingFeatureName, transactionID, activated)
+	testUnsupportedRequestFromCentralSystem(suite, request, requestJson, messageId)
+}
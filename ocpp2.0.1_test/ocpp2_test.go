@@ -1,7 +1,9 @@
 package ocpp2_test
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -59,6 +61,14 @@ func (websocket MockWebSocket) TLSConnectionState() *tls.ConnectionState {
 	return nil
 }
 
+func (websocket MockWebSocket) Subprotocol() string {
+	return types.V201Subprotocol
+}
+
+func (websocket MockWebSocket) Context() context.Context {
+	return context.Background()
+}
+
 func NewMockWebSocket(id string) MockWebSocket {
 	return MockWebSocket{id: id}
 }
@@ -697,12 +707,24 @@ func (handler *MockChargingStationRemoteControlHandler) OnUnlockConnector(reques
 	return response, args.Error(1)
 }
 
+func (handler *MockChargingStationRemoteControlHandler) OnUsePriorityCharging(request *remotecontrol.UsePriorityChargingRequest) (response *remotecontrol.UsePriorityChargingResponse, err error) {
+	args := handler.MethodCalled("OnUsePriorityCharging", request)
+	response = args.Get(0).(*remotecontrol.UsePriorityChargingResponse)
+	return response, args.Error(1)
+}
+
 // ---------------------- MOCK CSMS REMOTE CONTROL HANDLER ----------------------
 
 type MockCSMSRemoteControlHandler struct {
 	mock.Mock
 }
 
+func (handler *MockCSMSRemoteControlHandler) OnNotifyPriorityCharging(chargingStationID string, request *remotecontrol.NotifyPriorityChargingRequest) (response *remotecontrol.NotifyPriorityChargingResponse, err error) {
+	args := handler.MethodCalled("OnNotifyPriorityCharging", chargingStationID, request)
+	response = args.Get(0).(*remotecontrol.NotifyPriorityChargingResponse)
+	return response, args.Error(1)
+}
+
 // ---------------------- MOCK CS SMART CHARGING HANDLER ----------------------
 
 type MockChargingStationSmartChargingHandler struct {
@@ -1021,6 +1043,7 @@ func testUnsupportedRequestFromChargingStation(suite *OcppV2TestSuite, request o
 	})
 	require.Error(t, err)
 	assert.Equal(t, expectedError, err.Error())
+	assert.True(t, errors.Is(err, ocppj.ErrUnsupportedFeature))
 	// 2. Test receiving an unsupported request on the other endpoint and receiving an error
 	// Mark mocked request as pending, otherwise response will be ignored
 	suite.ocppjClient.RequestState.AddPendingRequest(messageId, request)
@@ -1062,6 +1085,7 @@ func testUnsupportedRequestFromCentralSystem(suite *OcppV2TestSuite, request ocp
 	})
 	require.Error(t, err)
 	assert.Equal(t, expectedError, err.Error())
+	assert.True(t, errors.Is(err, ocppj.ErrUnsupportedFeature))
 	// 2. Test receiving an unsupported request on the other endpoint and receiving an error
 	// Mark mocked request as pending, otherwise response will be ignored
 	suite.ocppjServer.RequestState.AddPendingRequest(wsId, messageId, request)
@@ -0,0 +1,271 @@
+package ocpp2_test
+
+import (
+	"context"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/iso15118"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// Minimal RFC 6960 structures, used by the fake OCSP responder.
+
+type testOCSPCertID struct {
+	HashAlgorithm  pkix.AlgorithmIdentifier
+	IssuerNameHash []byte
+	IssuerKeyHash  []byte
+	SerialNumber   *big.Int
+}
+
+type testOCSPRequest struct {
+	TBSRequest struct {
+		Version     int `asn1:"explicit,tag:0,default:0,optional"`
+		RequestList []struct {
+			CertID testOCSPCertID
+		}
+		RequestExtensions []pkix.Extension `asn1:"explicit,tag:2,optional"`
+	}
+}
+
+type testOCSPRevokedInfo struct {
+	RevocationTime time.Time `asn1:"generalized"`
+}
+
+type testOCSPSingleResponse struct {
+	CertID     testOCSPCertID
+	Good       asn1.Flag           `asn1:"tag:0,optional"`
+	Revoked    testOCSPRevokedInfo `asn1:"tag:1,optional"`
+	ThisUpdate time.Time           `asn1:"generalized"`
+	NextUpdate time.Time           `asn1:"generalized,explicit,tag:0,optional"`
+}
+
+type testOCSPResponseData struct {
+	ResponderID        asn1.RawValue
+	ProducedAt         time.Time `asn1:"generalized"`
+	Responses          []testOCSPSingleResponse
+	ResponseExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type testOCSPBasicResponse struct {
+	TBSResponseData    testOCSPResponseData
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+}
+
+type testOCSPResponseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type testOCSPResponse struct {
+	Status        asn1.Enumerated
+	ResponseBytes testOCSPResponseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type fakeOCSPResponder struct {
+	revoked    bool
+	thisUpdate time.Time
+	nextUpdate time.Time
+	badNonce   bool
+	requests   int
+	responses  [][]byte
+	mutex      sync.Mutex
+}
+
+func (r *fakeOCSPResponder) ServeHTTP(w http.ResponseWriter, request *http.Request) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.requests++
+	body, _ := io.ReadAll(request.Body)
+	var ocspRequest testOCSPRequest
+	if request.Header.Get("Content-Type") != "application/ocsp-request" {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if _, err := asn1.Unmarshal(body, &ocspRequest); err != nil || len(ocspRequest.TBSRequest.RequestList) != 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	single := testOCSPSingleResponse{
+		CertID:     ocspRequest.TBSRequest.RequestList[0].CertID,
+		ThisUpdate: r.thisUpdate,
+		NextUpdate: r.nextUpdate,
+	}
+	if r.revoked {
+		single.Revoked = testOCSPRevokedInfo{RevocationTime: r.thisUpdate.Add(-time.Hour)}
+	} else {
+		single.Good = true
+	}
+	nonces := ocspRequest.TBSRequest.RequestExtensions
+	if r.badNonce {
+		nonces = []pkix.Extension{{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 2}, Value: []byte{0x04, 0x01, 0x00}}}
+	}
+	responderName, _ := asn1.Marshal(pkix.Name{CommonName: "OCSP Responder"}.ToRDNSequence())
+	basic, _ := asn1.Marshal(testOCSPBasicResponse{
+		TBSResponseData: testOCSPResponseData{
+			ResponderID:        asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 1, IsCompound: true, Bytes: responderName},
+			ProducedAt:         r.thisUpdate,
+			Responses:          []testOCSPSingleResponse{single},
+			ResponseExtensions: nonces,
+		},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}},
+		Signature:          asn1.BitString{Bytes: []byte{0x00}, BitLength: 8},
+	})
+	response, _ := asn1.Marshal(testOCSPResponse{ResponseBytes: testOCSPResponseBytes{
+		ResponseType: asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1},
+		Response:     basic,
+	}})
+	r.responses = append(r.responses, response)
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	_, _ = w.Write(response)
+}
+
+func (r *fakeOCSPResponder) setWindow(thisUpdate time.Time, nextUpdate time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.badNonce = false
+	r.thisUpdate, r.nextUpdate = thisUpdate, nextUpdate
+}
+
+func (r *fakeOCSPResponder) requestCount() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.requests
+}
+
+func (r *fakeOCSPResponder) lastResponse() []byte {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.responses[len(r.responses)-1]
+}
+
+func newTestOCSPRequestData(responderURL string) types.OCSPRequestDataType {
+	return types.OCSPRequestDataType{
+		HashAlgorithm:  types.SHA256,
+		IssuerNameHash: "4b2f8f6a1e2c3d4e5f60718293a4b5c6d7e8f90112233445566778899aabbccd",
+		IssuerKeyHash:  "0a1b2c3d4e5f60718293a4b5c6d7e8f90112233445566778899aabbccddeeff0",
+		SerialNumber:   "fff",
+		ResponderURL:   responderURL,
+	}
+}
+
+func newTestOCSPFetcher(now *time.Time) *iso15118.OCSPFetcher {
+	fetcher := iso15118.NewOCSPFetcher(time.Second)
+	fetcher.SetTimeSource(func() time.Time { return *now })
+	return fetcher
+}
+
+func (suite *OcppV2TestSuite) TestOCSPFetcherGood() {
+	t := suite.T()
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	responder := &fakeOCSPResponder{thisUpdate: now.Add(-time.Minute), nextUpdate: now.Add(time.Hour)}
+	server := httptest.NewServer(responder)
+	defer server.Close()
+	fetcher := newTestOCSPFetcher(&now)
+	result, status, err := fetcher.Fetch(context.Background(), newTestOCSPRequestData(server.URL))
+	require.NoError(t, err)
+	assert.Equal(t, types.GenericStatusAccepted, status)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(responder.lastResponse()), result)
+	// Cached until nextUpdate
+	now = now.Add(30 * time.Minute)
+	cached, status, err := fetcher.Fetch(context.Background(), newTestOCSPRequestData(server.URL))
+	require.NoError(t, err)
+	assert.Equal(t, types.GenericStatusAccepted, status)
+	assert.Equal(t, result, cached)
+	assert.Equal(t, 1, responder.requestCount())
+	// Fetched again once expired
+	now = now.Add(time.Hour)
+	responder.setWindow(now, now.Add(time.Hour))
+	_, status, err = fetcher.Fetch(context.Background(), newTestOCSPRequestData(server.URL))
+	require.NoError(t, err)
+	assert.Equal(t, types.GenericStatusAccepted, status)
+	assert.Equal(t, 2, responder.requestCount())
+}
+
+func (suite *OcppV2TestSuite) TestOCSPFetcherRevoked() {
+	t := suite.T()
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	responder := &fakeOCSPResponder{revoked: true, thisUpdate: now, nextUpdate: now.Add(time.Hour)}
+	server := httptest.NewServer(responder)
+	defer server.Close()
+	fetcher := newTestOCSPFetcher(&now)
+	// The status reflects the retrieval, not the validity of the certificate
+	response, err := fetcher.OnGetCertificateStatus("station1", iso15118.NewGetCertificateStatusRequest(newTestOCSPRequestData(server.URL)))
+	require.NoError(t, err)
+	assert.Equal(t, types.GenericStatusAccepted, response.Status)
+	raw, err := base64.StdEncoding.DecodeString(response.OcspResult)
+	require.NoError(t, err)
+	var ocspResponse testOCSPResponse
+	_, err = asn1.Unmarshal(raw, &ocspResponse)
+	require.NoError(t, err)
+	var basic testOCSPBasicResponse
+	_, err = asn1.Unmarshal(ocspResponse.ResponseBytes.Response, &basic)
+	require.NoError(t, err)
+	require.Len(t, basic.TBSResponseData.Responses, 1)
+	assert.False(t, bool(basic.TBSResponseData.Responses[0].Good))
+	assert.Equal(t, now.Add(-time.Hour), basic.TBSResponseData.Responses[0].Revoked.RevocationTime)
+	assert.NoError(t, types.Validate.Struct(response))
+}
+
+func (suite *OcppV2TestSuite) TestOCSPFetcherFailures() {
+	t := suite.T()
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	fetcher := newTestOCSPFetcher(&now)
+	var fetchErrors []error
+	fetcher.OnFetchError = func(chargingStationID string, err error) {
+		assert.Equal(t, "station1", chargingStationID)
+		fetchErrors = append(fetchErrors, err)
+	}
+	// Unreachable responder
+	server := httptest.NewServer(&fakeOCSPResponder{})
+	unreachableURL := server.URL
+	server.Close()
+	response, err := fetcher.OnGetCertificateStatus("station1", iso15118.NewGetCertificateStatusRequest(newTestOCSPRequestData(unreachableURL)))
+	require.NoError(t, err)
+	assert.Equal(t, types.GenericStatusRejected, response.Status)
+	assert.Empty(t, response.OcspResult)
+	assert.NoError(t, types.Validate.Struct(response))
+	require.Len(t, fetchErrors, 1)
+	// Missing responder URL
+	_, status, err := fetcher.Fetch(context.Background(), newTestOCSPRequestData(""))
+	assert.Error(t, err)
+	assert.Equal(t, types.GenericStatusRejected, status)
+	// Nonce mismatch
+	responder := &fakeOCSPResponder{badNonce: true, thisUpdate: now, nextUpdate: now.Add(time.Hour)}
+	server = httptest.NewServer(responder)
+	defer server.Close()
+	_, status, err = fetcher.Fetch(context.Background(), newTestOCSPRequestData(server.URL))
+	assert.ErrorIs(t, err, iso15118.ErrInvalidOCSPResponse)
+	assert.Equal(t, types.GenericStatusRejected, status)
+	// thisUpdate in the future
+	responder.setWindow(now.Add(time.Hour), now.Add(2*time.Hour))
+	_, status, err = fetcher.Fetch(context.Background(), newTestOCSPRequestData(server.URL))
+	assert.ErrorIs(t, err, iso15118.ErrStaleOCSPResponse)
+	assert.Equal(t, types.GenericStatusRejected, status)
+	// nextUpdate already passed
+	responder.setWindow(now.Add(-2*time.Hour), now.Add(-time.Hour))
+	_, status, err = fetcher.Fetch(context.Background(), newTestOCSPRequestData(server.URL))
+	assert.ErrorIs(t, err, iso15118.ErrStaleOCSPResponse)
+	assert.Equal(t, types.GenericStatusRejected, status)
+	// Failures are not cached
+	assert.Equal(t, 3, responder.requestCount())
+	// Responder error
+	errorServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer errorServer.Close()
+	_, status, err = fetcher.Fetch(context.Background(), newTestOCSPRequestData(errorServer.URL))
+	assert.Error(t, err)
+	assert.Equal(t, types.GenericStatusRejected, status)
+}
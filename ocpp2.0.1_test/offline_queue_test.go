@@ -0,0 +1,170 @@
+package ocpp2_test
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+)
+
+// Simulates the connection to the CSMS, recording all delivered events.
+type fakeTransactionEventSender struct {
+	connected bool
+	rejectN   int
+	delivered []*transactions.TransactionEventRequest
+	mutex     sync.Mutex
+}
+
+func (s *fakeTransactionEventSender) send(request *transactions.TransactionEventRequest) (*transactions.TransactionEventResponse, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.connected {
+		return nil, errors.New("connection closed")
+	}
+	if s.rejectN > 0 {
+		s.rejectN--
+		return nil, ocpp.NewError(ocppj.InternalError, "rejected", "1234")
+	}
+	s.delivered = append(s.delivered, request)
+	return transactions.NewTransactionEventResponse(), nil
+}
+
+func (s *fakeTransactionEventSender) setConnected(connected bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.connected = connected
+}
+
+func (s *fakeTransactionEventSender) deliveredSeqNos() []int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var seqNos []int
+	for _, request := range s.delivered {
+		seqNos = append(seqNos, request.SequenceNo)
+	}
+	return seqNos
+}
+
+func queuedEvent(eventType transactions.TransactionEvent, transactionID string, seqNo int) *transactions.TransactionEventRequest {
+	return transactions.NewTransactionEventRequest(eventType, types.Now(), transactions.TriggerReasonMeterValuePeriodic, seqNo, transactions.Transaction{TransactionID: transactionID})
+}
+
+func transactionStatus(t require.TestingT, queue *transactions.OfflineQueue, transactionID string) *transactions.GetTransactionStatusResponse {
+	request := transactions.NewGetTransactionStatusRequest()
+	request.TransactionID = transactionID
+	response, err := queue.OnGetTransactionStatus(request)
+	require.NoError(t, err)
+	require.NotNil(t, response)
+	return response
+}
+
+func (suite *OcppV2TestSuite) TestOfflineQueueDisconnectDuringTransaction() {
+	t := suite.T()
+	sender := &fakeTransactionEventSender{connected: true}
+	queue := transactions.NewOfflineQueue(nil, sender.send)
+	var _ transactions.ChargingStationHandler = queue
+	// Online
+	response, err := queue.SendTransactionEvent(queuedEvent(transactions.TransactionEventStarted, "tx1", 0))
+	require.NoError(t, err)
+	assert.NotNil(t, response)
+	status := transactionStatus(t, queue, "tx1")
+	assert.False(t, status.MessagesInQueue)
+	require.NotNil(t, status.OngoingIndicator)
+	assert.True(t, *status.OngoingIndicator)
+	// Connection lost: detected by the failed send
+	sender.setConnected(false)
+	_, err = queue.SendTransactionEvent(queuedEvent(transactions.TransactionEventUpdated, "tx1", 1))
+	assert.Equal(t, transactions.ErrTransactionEventQueued, err)
+	queue.OnDisconnected()
+	_, err = queue.SendTransactionEvent(queuedEvent(transactions.TransactionEventUpdated, "tx1", 2))
+	assert.Equal(t, transactions.ErrTransactionEventQueued, err)
+	_, err = queue.SendTransactionEvent(queuedEvent(transactions.TransactionEventEnded, "tx1", 3))
+	assert.Equal(t, transactions.ErrTransactionEventQueued, err)
+	assert.Equal(t, 3, queue.QueueLength())
+	// Before flush
+	status = transactionStatus(t, queue, "tx1")
+	assert.True(t, status.MessagesInQueue)
+	require.NotNil(t, status.OngoingIndicator)
+	assert.False(t, *status.OngoingIndicator)
+	assert.True(t, transactionStatus(t, queue, "").MessagesInQueue)
+	assert.Nil(t, transactionStatus(t, queue, "").OngoingIndicator)
+	assert.False(t, transactionStatus(t, queue, "tx2").MessagesInQueue)
+	// Flush after reconnecting
+	sender.setConnected(true)
+	queue.OnReconnected()
+	assert.Eventually(t, func() bool {
+		return queue.QueueLength() == 0
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []int{0, 1, 2, 3}, sender.deliveredSeqNos())
+	for i, request := range sender.delivered {
+		assert.Equal(t, i > 0, request.Offline)
+	}
+	// After flush
+	status = transactionStatus(t, queue, "tx1")
+	assert.False(t, status.MessagesInQueue)
+	assert.False(t, *status.OngoingIndicator)
+	// Events are sent directly again
+	response, err = queue.SendTransactionEvent(queuedEvent(transactions.TransactionEventStarted, "tx2", 0))
+	require.NoError(t, err)
+	assert.NotNil(t, response)
+	assert.False(t, sender.delivered[4].Offline)
+}
+
+func (suite *OcppV2TestSuite) TestOfflineQueuePreservesOrderDuringFlush() {
+	t := suite.T()
+	sender := &fakeTransactionEventSender{}
+	blockC := make(chan struct{})
+	var once sync.Once
+	queue := transactions.NewOfflineQueue(nil, func(request *transactions.TransactionEventRequest) (*transactions.TransactionEventResponse, error) {
+		// Block the first delivery, until a new event was created
+		once.Do(func() { <-blockC })
+		return sender.send(request)
+	})
+	queue.OnDisconnected()
+	for i := 0; i < 3; i++ {
+		_, err := queue.SendTransactionEvent(queuedEvent(transactions.TransactionEventUpdated, "tx1", i))
+		assert.Equal(t, transactions.ErrTransactionEventQueued, err)
+	}
+	sender.setConnected(true)
+	queue.OnReconnected()
+	// Created while online, but queued behind the older events
+	_, err := queue.SendTransactionEvent(queuedEvent(transactions.TransactionEventUpdated, "tx1", 3))
+	assert.Equal(t, transactions.ErrTransactionEventQueued, err)
+	close(blockC)
+	assert.Eventually(t, func() bool {
+		return queue.QueueLength() == 0
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []int{0, 1, 2, 3}, sender.deliveredSeqNos())
+	assert.True(t, sender.delivered[2].Offline)
+	assert.False(t, sender.delivered[3].Offline)
+}
+
+func (suite *OcppV2TestSuite) TestOfflineQueueDiscardsRejectedEvents() {
+	t := suite.T()
+	sender := &fakeTransactionEventSender{}
+	queue := transactions.NewOfflineQueue(nil, sender.send)
+	discardedC := make(chan *transactions.TransactionEventRequest, 1)
+	queue.OnQueuedEventDiscarded = func(request *transactions.TransactionEventRequest, err error) {
+		assert.IsType(t, &ocpp.Error{}, err)
+		discardedC <- request
+	}
+	queue.OnDisconnected()
+	_, _ = queue.SendTransactionEvent(queuedEvent(transactions.TransactionEventStarted, "tx1", 0))
+	_, _ = queue.SendTransactionEvent(queuedEvent(transactions.TransactionEventUpdated, "tx1", 1))
+	sender.setConnected(true)
+	sender.rejectN = transactions.DefaultTransactionMessageAttempts
+	queue.OnReconnected()
+	discarded := <-discardedC
+	assert.Equal(t, 0, discarded.SequenceNo)
+	assert.Eventually(t, func() bool {
+		return queue.QueueLength() == 0
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []int{1}, sender.deliveredSeqNos())
+}
@@ -0,0 +1,96 @@
+package ocpp2_test
+
+import (
+	"context"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	ocpp2 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/csms"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/remotecontrol"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+	"github.com/lorenzodonini/ocpp-go/ws/wstest"
+)
+
+func (suite *OcppV2TestSuite) TestPriorityChargingRegistry() {
+	t := suite.T()
+	server := wstest.NewServer()
+	csmsServer := ocpp2.NewCSMS(nil, server)
+	registry := csms.NewStationRegistry()
+	transactionsHandler := &MockCSMSTransactionsHandler{}
+	transactionsHandler.On("OnTransactionEvent", mock.Anything, mock.Anything).Return(transactions.NewTransactionEventResponse(), nil)
+	csmsServer.SetTransactionsHandler(registry.WrapTransactionsHandler(transactionsHandler))
+	remoteControlHandler := &MockCSMSRemoteControlHandler{}
+	remoteControlHandler.On("OnNotifyPriorityCharging", "station1", mock.Anything).Return(remotecontrol.NewNotifyPriorityChargingResponse(), nil)
+	csmsServer.SetRemoteControlHandler(registry.WrapRemoteControlHandler(remoteControlHandler))
+	station := ocpp2.NewChargingStation("station1", nil, wstest.NewClient(server))
+	// The charging station accepts requests for its only transaction, and notifies the CSMS once priority charging changed
+	stationHandler := &MockChargingStationRemoteControlHandler{}
+	forTransaction := func(transactionID string) interface{} {
+		return mock.MatchedBy(func(request *remotecontrol.UsePriorityChargingRequest) bool {
+			return request.TransactionID == transactionID
+		})
+	}
+	stationHandler.On("OnUsePriorityCharging", forTransaction("tx-1")).Return(remotecontrol.NewUsePriorityChargingResponse(remotecontrol.PriorityChargingStatusAccepted), nil).Run(func(args mock.Arguments) {
+		request := args.Get(0).(*remotecontrol.UsePriorityChargingRequest)
+		go func() {
+			_, err := station.NotifyPriorityCharging(request.TransactionID, request.Activate)
+			assert.NoError(t, err)
+		}()
+	})
+	stationHandler.On("OnUsePriorityCharging", forTransaction("tx-2")).Return(remotecontrol.NewUsePriorityChargingResponse(remotecontrol.PriorityChargingStatusRejected), nil)
+	station.SetRemoteControlHandler(stationHandler)
+	require.NoError(t, wstest.ConnectInMemory(server, csmsServer, station))
+	defer csmsServer.Stop()
+	defer station.Stop()
+	changeC := make(chan csms.StationChange, 10)
+	unsubscribe := registry.Subscribe(func(change csms.StationChange) {
+		changeC <- change
+	})
+	defer unsubscribe()
+	receiveChange := func() csms.StationChange {
+		select {
+		case change := <-changeC:
+			return change
+		case <-time.After(time.Second):
+			require.Fail(t, "registry didn't change")
+			return csms.StationChange{}
+		}
+	}
+	priorityCharging := func() bool {
+		snapshot, ok := registry.Station("station1")
+		require.True(t, ok)
+		require.Len(t, snapshot.Transactions, 1)
+		return snapshot.Transactions[0].PriorityCharging
+	}
+	_, err := station.TransactionEvent(transactions.TransactionEventStarted, types.Now(), transactions.TriggerReasonAuthorized, 0, transactions.Transaction{TransactionID: "tx-1"})
+	require.NoError(t, err)
+	assert.Equal(t, csms.StationChange{ChargingStationID: "station1", Type: csms.ChangeConnected}, receiveChange())
+	assert.Equal(t, csms.StationChange{ChargingStationID: "station1", Type: csms.ChangeTransaction}, receiveChange())
+	assert.False(t, priorityCharging())
+	for _, activate := range []bool{true, false} {
+		future, err := csmsServer.UsePriorityChargingAsync("station1", "tx-1", activate)
+		require.NoError(t, err)
+		response, err := future.Get(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, remotecontrol.PriorityChargingStatusAccepted, response.Status)
+		assert.Equal(t, csms.StationChange{ChargingStationID: "station1", Type: csms.ChangeTransaction}, receiveChange())
+		assert.Equal(t, activate, priorityCharging())
+	}
+	// Unknown transactions are rejected, and the state of the known transaction is retained
+	future, err := csmsServer.UsePriorityChargingAsync("station1", "tx-2", true)
+	require.NoError(t, err)
+	response, err := future.Get(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, remotecontrol.PriorityChargingStatusRejected, response.Status)
+	assert.False(t, priorityCharging())
+	// Notifications for unknown transactions are discarded
+	_, err = station.NotifyPriorityCharging("tx-2", true)
+	require.NoError(t, err)
+	assert.Len(t, changeC, 0)
+	remoteControlHandler.AssertNumberOfCalls(t, "OnNotifyPriorityCharging", 3)
+}
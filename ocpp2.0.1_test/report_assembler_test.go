@@ -0,0 +1,156 @@
+package ocpp2_test
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+type assembledReport struct {
+	chargingStationID string
+	requestID         int
+	reportData        []provisioning.ReportData
+	err               error
+}
+
+func newTestReportAssembler(timeout time.Duration) (*provisioning.ReportAssembler, chan assembledReport) {
+	resultC := make(chan assembledReport, 10)
+	assembler := provisioning.NewReportAssembler(timeout, func(chargingStationID string, requestID int, reportData []provisioning.ReportData, err error) {
+		resultC <- assembledReport{chargingStationID: chargingStationID, requestID: requestID, reportData: reportData, err: err}
+	})
+	return assembler, resultC
+}
+
+func reportPart(requestID int, seqNo int, tbc bool, variables ...string) *provisioning.NotifyReportRequest {
+	request := provisioning.NewNotifyReportRequest(requestID, types.Now(), seqNo)
+	request.Tbc = tbc
+	for _, variable := range variables {
+		request.ReportData = append(request.ReportData, provisioning.ReportData{
+			Component:         types.Component{Name: "Component"},
+			Variable:          types.Variable{Name: variable},
+			VariableAttribute: []provisioning.VariableAttribute{provisioning.NewVariableAttribute()},
+		})
+	}
+	return request
+}
+
+func variableNames(reportData []provisioning.ReportData) []string {
+	var names []string
+	for _, data := range reportData {
+		names = append(names, data.Variable.Name)
+	}
+	return names
+}
+
+func (suite *OcppV2TestSuite) TestReportAssemblerInOrder() {
+	t := suite.T()
+	assembler, resultC := newTestReportAssembler(time.Second)
+	require.NoError(t, assembler.Add("cs1", reportPart(1, 0, true, "a", "b")))
+	require.NoError(t, assembler.Add("cs1", reportPart(1, 1, true, "c")))
+	assert.Equal(t, 1, assembler.PendingReports("cs1"))
+	// Retransmitted parts are ignored
+	require.NoError(t, assembler.Add("cs1", reportPart(1, 1, true, "c")))
+	assert.Len(t, resultC, 0)
+	require.NoError(t, assembler.Add("cs1", reportPart(1, 2, false, "d")))
+	result := <-resultC
+	assert.NoError(t, result.err)
+	assert.Equal(t, "cs1", result.chargingStationID)
+	assert.Equal(t, 1, result.requestID)
+	assert.Equal(t, []string{"a", "b", "c", "d"}, variableNames(result.reportData))
+	assert.Equal(t, 0, assembler.PendingReports("cs1"))
+	// Single-part report
+	require.NoError(t, assembler.Add("cs1", reportPart(2, 0, false, "e")))
+	result = <-resultC
+	assert.NoError(t, result.err)
+	assert.Equal(t, []string{"e"}, variableNames(result.reportData))
+}
+
+func (suite *OcppV2TestSuite) TestReportAssemblerSequenceGap() {
+	t := suite.T()
+	assembler, resultC := newTestReportAssembler(time.Second)
+	require.NoError(t, assembler.Add("cs1", reportPart(1, 0, true, "a")))
+	err := assembler.Add("cs1", reportPart(1, 2, true, "c"))
+	require.Error(t, err)
+	sequenceErr, ok := err.(*provisioning.ReportSequenceError)
+	require.True(t, ok)
+	assert.Equal(t, 1, sequenceErr.ExpectedSeqNo)
+	assert.Equal(t, 2, sequenceErr.ReceivedSeqNo)
+	result := <-resultC
+	assert.Equal(t, err, result.err)
+	assert.Equal(t, []string{"a"}, variableNames(result.reportData))
+	assert.Equal(t, 0, assembler.PendingReports("cs1"))
+	// A report must start with seqNo 0
+	err = assembler.Add("cs1", reportPart(2, 1, false, "x"))
+	assert.IsType(t, &provisioning.ReportSequenceError{}, err)
+	<-resultC
+}
+
+func (suite *OcppV2TestSuite) TestReportAssemblerInterleaved() {
+	t := suite.T()
+	assembler, resultC := newTestReportAssembler(time.Second)
+	// Same requestId used by two stations
+	require.NoError(t, assembler.Add("cs1", reportPart(1, 0, true, "cs1-a")))
+	require.NoError(t, assembler.Add("cs2", reportPart(1, 0, true, "cs2-a")))
+	require.NoError(t, assembler.Add("cs2", reportPart(1, 1, true, "cs2-b")))
+	require.NoError(t, assembler.Add("cs1", reportPart(1, 1, false, "cs1-b")))
+	result := <-resultC
+	assert.NoError(t, result.err)
+	assert.Equal(t, "cs1", result.chargingStationID)
+	assert.Equal(t, []string{"cs1-a", "cs1-b"}, variableNames(result.reportData))
+	require.NoError(t, assembler.Add("cs2", reportPart(1, 2, false, "cs2-c")))
+	result = <-resultC
+	assert.NoError(t, result.err)
+	assert.Equal(t, "cs2", result.chargingStationID)
+	assert.Equal(t, []string{"cs2-a", "cs2-b", "cs2-c"}, variableNames(result.reportData))
+}
+
+func (suite *OcppV2TestSuite) TestReportAssemblerTimeout() {
+	t := suite.T()
+	assembler, resultC := newTestReportAssembler(50 * time.Millisecond)
+	require.NoError(t, assembler.Add("cs1", reportPart(3, 0, true, "a")))
+	require.NoError(t, assembler.Add("cs1", reportPart(3, 1, true, "b")))
+	select {
+	case result := <-resultC:
+		require.Error(t, result.err)
+		partialErr, ok := result.err.(*provisioning.PartialReportError)
+		require.True(t, ok)
+		assert.Equal(t, 2, partialErr.ReceivedParts)
+		assert.Equal(t, 3, result.requestID)
+		assert.Equal(t, []string{"a", "b"}, variableNames(result.reportData))
+	case <-time.After(time.Second):
+		t.Fatal("timeout not triggered")
+	}
+	assert.Equal(t, 0, assembler.PendingReports("cs1"))
+	// Discarded reports never complete
+	require.NoError(t, assembler.Add("cs1", reportPart(4, 0, true, "a")))
+	assembler.Discard("cs1")
+	select {
+	case <-resultC:
+		t.Fatal("unexpected completion of discarded report")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func (suite *OcppV2TestSuite) TestReportAssemblerLimits() {
+	t := suite.T()
+	assembler, resultC := newTestReportAssembler(time.Second)
+	assembler.SetLimits(3, 1)
+	require.NoError(t, assembler.Add("cs1", reportPart(1, 0, true, "a", "b")))
+	// Too many pending reports
+	err := assembler.Add("cs1", reportPart(2, 0, true, "x"))
+	assert.IsType(t, &provisioning.ReportLimitError{}, err)
+	<-resultC
+	// Other stations are not affected
+	require.NoError(t, assembler.Add("cs2", reportPart(2, 0, false, "x")))
+	assert.NoError(t, (<-resultC).err)
+	// Too much data
+	err = assembler.Add("cs1", reportPart(1, 1, true, "c", "d"))
+	assert.IsType(t, &provisioning.ReportLimitError{}, err)
+	result := <-resultC
+	assert.Equal(t, []string{"a", "b"}, variableNames(result.reportData))
+	assert.Equal(t, 0, assembler.PendingReports("cs1"))
+}
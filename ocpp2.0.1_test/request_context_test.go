@@ -0,0 +1,68 @@
+package ocpp2_test
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ocpp2 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/availability"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/lorenzodonini/ocpp-go/ws/wstest"
+)
+
+type tenantContextKey struct{}
+
+// availabilityContextRecorder forwards the context of every received heartbeat.
+type availabilityContextRecorder struct {
+	ctxC chan context.Context
+}
+
+func (h *availabilityContextRecorder) OnHeartbeat(ctx context.Context, chargingStationID string, request *availability.HeartbeatRequest) (*availability.HeartbeatResponse, error) {
+	h.ctxC <- ctx
+	return availability.NewHeartbeatResponse(*types.Now()), nil
+}
+
+func (h *availabilityContextRecorder) OnStatusNotification(ctx context.Context, chargingStationID string, request *availability.StatusNotificationRequest) (*availability.StatusNotificationResponse, error) {
+	return availability.NewStatusNotificationResponse(), nil
+}
+
+func (suite *OcppV2TestSuite) TestRequestContextPropagation() {
+	t := suite.T()
+	stationID := "station1"
+	server := wstest.NewServer()
+	server.SetConnectionInterceptor(func(ctx context.Context, id string, r *http.Request) context.Context {
+		return context.WithValue(ctx, tenantContextKey{}, r.Header.Get("X-Tenant"))
+	})
+	csms := ocpp2.NewCSMS(nil, server)
+	handler := &availabilityContextRecorder{ctxC: make(chan context.Context, 1)}
+	csms.SetAvailabilityHandlerWithContext(handler)
+	client := wstest.NewClient(server)
+	client.SetHeaderValue("X-Tenant", "tenant1")
+	station := ocpp2.NewChargingStation(stationID, nil, client)
+	require.NoError(t, wstest.ConnectInMemory(server, csms, station))
+	defer csms.Stop()
+	_, err := station.Heartbeat()
+	require.NoError(t, err)
+	ctx := <-handler.ctxC
+	// Values set by the interceptor and request info are available to the handler
+	assert.Equal(t, "tenant1", ctx.Value(tenantContextKey{}))
+	info, ok := ocppj.RequestInfoFromContext(ctx)
+	require.True(t, ok)
+	assert.Equal(t, stationID, info.ClientID)
+	assert.Equal(t, availability.HeartbeatFeatureName, info.Action)
+	assert.NotEmpty(t, info.UniqueID)
+	require.NoError(t, ctx.Err())
+	// The context is canceled once the charging station disconnects
+	require.NoError(t, server.Disconnect(stationID, nil))
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("request context not canceled after disconnect")
+	}
+	station.Stop()
+}
@@ -0,0 +1,156 @@
+package ocpp2_test
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/clock/clocktest"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/availability"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/reservation"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// Returns a manager for a station with a CCS2 EVSE and a Type 2 EVSE, and a channel receiving all sent status updates.
+func newTestReservationManager(t require.TestingT) (*reservation.Manager201, chan *reservation.ReservationStatusUpdateRequest) {
+	updateC := make(chan *reservation.ReservationStatusUpdateRequest, 2)
+	topology := map[int][]reservation.ConnectorType{
+		1: {reservation.ConnectorTypeCCS2},
+		2: {reservation.ConnectorTypeSType2},
+	}
+	manager, err := reservation.NewManager201(nil, topology, func(request *reservation.ReservationStatusUpdateRequest) error {
+		updateC <- request
+		return nil
+	})
+	require.NoError(t, err)
+	return manager, updateC
+}
+
+func newTestReserveNowRequest(id int, expiry time.Duration, idToken string) *reservation.ReserveNowRequest {
+	return reservation.NewReserveNowRequest(id, types.NewDateTime(time.Now().Add(expiry)), types.IdToken{IdToken: idToken, Type: types.IdTokenTypeISO14443})
+}
+
+func (suite *OcppV2TestSuite) TestReservationManagerConnectorType() {
+	t := suite.T()
+	manager, _ := newTestReservationManager(t)
+	statuses := map[int]availability.ConnectorStatus{}
+	manager.ConnectorStatus = func(evseID int, connectorID int) (availability.ConnectorStatus, bool) {
+		status, ok := statuses[evseID]
+		return status, ok
+	}
+	// The requested EVSE doesn't have a matching connector
+	request := newTestReserveNowRequest(1, time.Hour, "1234")
+	request.EvseID = newInt(1)
+	request.ConnectorType = reservation.ConnectorTypeSType2
+	response, err := manager.OnReserveNow(request)
+	require.NoError(t, err)
+	assert.Equal(t, reservation.ReserveNowStatusRejected, response.Status)
+	require.NotNil(t, response.StatusInfo)
+	assert.Equal(t, "UnknownConnectorType", response.StatusInfo.ReasonCode)
+	// No EVSE has a matching connector
+	request.EvseID = nil
+	request.ConnectorType = reservation.ConnectorTypeG105
+	response, err = manager.OnReserveNow(request)
+	require.NoError(t, err)
+	assert.Equal(t, reservation.ReserveNowStatusRejected, response.Status)
+	// The matching EVSE is chosen
+	request.ConnectorType = reservation.ConnectorTypeSType2
+	response, err = manager.OnReserveNow(request)
+	require.NoError(t, err)
+	assert.Equal(t, reservation.ReserveNowStatusAccepted, response.Status)
+	reserved, ok := manager.ReservationForEVSE(2)
+	require.True(t, ok)
+	assert.Equal(t, 1, reserved.ID)
+	// Only reserved EVSEs remain for the connector type
+	response, err = manager.OnReserveNow(newTestReserveNowRequest(2, time.Hour, "5678"))
+	require.NoError(t, err)
+	assert.Equal(t, reservation.ReserveNowStatusAccepted, response.Status)
+	_, ok = manager.ReservationForEVSE(1)
+	assert.True(t, ok)
+	response, err = manager.OnReserveNow(newTestReserveNowRequest(3, time.Hour, "5678"))
+	require.NoError(t, err)
+	assert.Equal(t, reservation.ReserveNowStatusOccupied, response.Status)
+	// Canceled reservations free the EVSE, while faulted EVSEs can't be reserved
+	cancelResponse, err := manager.OnCancelReservation(reservation.NewCancelReservationRequest(2))
+	require.NoError(t, err)
+	assert.Equal(t, reservation.CancelReservationStatusAccepted, cancelResponse.Status)
+	statuses[1] = availability.ConnectorStatusFaulted
+	request = newTestReserveNowRequest(3, time.Hour, "5678")
+	request.ConnectorType = reservation.ConnectorTypeCCS2
+	response, err = manager.OnReserveNow(request)
+	require.NoError(t, err)
+	assert.Equal(t, reservation.ReserveNowStatusFaulted, response.Status)
+	cancelResponse, err = manager.OnCancelReservation(reservation.NewCancelReservationRequest(2))
+	require.NoError(t, err)
+	assert.Equal(t, reservation.CancelReservationStatusRejected, cancelResponse.Status)
+	assert.Len(t, manager.Reservations(), 1)
+}
+
+func (suite *OcppV2TestSuite) TestReservationManagerExpiry() {
+	t := suite.T()
+	manager, updateC := newTestReservationManager(t)
+	changedC := make(chan bool, 2)
+	manager.OnReservationChanged = func(r reservation.Reservation, active bool) {
+		changedC <- active
+	}
+	fakeClock := clocktest.NewFakeClock(time.Now())
+	manager.SetClock(fakeClock)
+	request := reservation.NewReserveNowRequest(7, types.NewDateTime(fakeClock.Now().Add(time.Hour)), types.IdToken{IdToken: "1234", Type: types.IdTokenTypeISO14443})
+	request.EvseID = newInt(1)
+	response, err := manager.OnReserveNow(request)
+	require.NoError(t, err)
+	assert.Equal(t, reservation.ReserveNowStatusAccepted, response.Status)
+	assert.True(t, <-changedC)
+	fakeClock.Advance(time.Hour - time.Second)
+	assert.Len(t, manager.Reservations(), 1)
+	assert.Empty(t, updateC)
+	fakeClock.Advance(time.Second)
+	select {
+	case update := <-updateC:
+		assert.Equal(t, 7, update.ReservationID)
+		assert.Equal(t, reservation.ReservationUpdateStatusExpired, update.Status)
+	default:
+		require.Fail(t, "reservation didn't expire")
+	}
+	assert.False(t, <-changedC)
+	assert.Empty(t, manager.Reservations())
+	// Expired reservations are rejected right away
+	response, err = manager.OnReserveNow(newTestReserveNowRequest(8, -time.Minute, "1234"))
+	require.NoError(t, err)
+	assert.Equal(t, reservation.ReserveNowStatusRejected, response.Status)
+}
+
+func (suite *OcppV2TestSuite) TestReservationManagerGroupIdToken() {
+	t := suite.T()
+	manager, updateC := newTestReservationManager(t)
+	group := types.IdToken{IdToken: "fleet", Type: types.IdTokenTypeCentral}
+	request := newTestReserveNowRequest(1, time.Hour, "1234")
+	request.EvseID = newInt(2)
+	request.GroupIdToken = &group
+	response, err := manager.OnReserveNow(request)
+	require.NoError(t, err)
+	require.Equal(t, reservation.ReserveNowStatusAccepted, response.Status)
+	driver := types.IdToken{IdToken: "5678", Type: types.IdTokenTypeISO14443}
+	other := types.IdToken{IdToken: "other", Type: types.IdTokenTypeCentral}
+	assert.True(t, manager.Authorize(2, request.IdToken, nil))
+	assert.True(t, manager.Authorize(2, driver, &group))
+	assert.False(t, manager.Authorize(2, driver, nil))
+	assert.False(t, manager.Authorize(2, driver, &other))
+	// Other EVSEs aren't restricted
+	assert.True(t, manager.Authorize(1, driver, nil))
+	// A transaction of another idToken doesn't use the reservation
+	event := transactions.NewTransactionEventRequest(transactions.TransactionEventStarted, types.NewDateTime(time.Now()), transactions.TriggerReasonAuthorized, 0, transactions.Transaction{TransactionID: "tx-1"})
+	event.Evse = &types.EVSE{ID: 2}
+	event.IDToken = &driver
+	manager.OnTransactionEvent(event)
+	assert.Len(t, manager.Reservations(), 1)
+	event.IDToken = &request.IdToken
+	manager.OnTransactionEvent(event)
+	assert.Empty(t, manager.Reservations())
+	update := <-updateC
+	assert.Equal(t, 1, update.ReservationID)
+	assert.Equal(t, reservation.ReservationUpdateStatusRemoved, update.Status)
+	assert.True(t, manager.Authorize(2, driver, nil))
+}
@@ -0,0 +1,168 @@
+package ocpp2_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+type performedReset struct {
+	evseID    *int
+	resetType provisioning.ResetType
+}
+
+func newTestResetManager() (*provisioning.ResetManager, chan performedReset) {
+	resetC := make(chan performedReset, 2)
+	manager := provisioning.NewResetManager([]int{1, 2}, func(evseID *int, resetType provisioning.ResetType) {
+		resetC <- performedReset{evseID: evseID, resetType: resetType}
+	})
+	return manager, resetC
+}
+
+func newTestResetTransactionEvent(eventType transactions.TransactionEvent, transactionID string, evseID int) *transactions.TransactionEventRequest {
+	request := transactions.NewTransactionEventRequest(eventType, types.NewDateTime(time.Now()), transactions.TriggerReasonAuthorized, 0, transactions.Transaction{TransactionID: transactionID})
+	if evseID > 0 {
+		request.Evse = &types.EVSE{ID: evseID}
+	}
+	return request
+}
+
+func expectReset(t require.TestingT, resetC chan performedReset) performedReset {
+	select {
+	case reset := <-resetC:
+		return reset
+	case <-time.After(time.Second):
+		require.Fail(t, "reset not performed")
+		return performedReset{}
+	}
+}
+
+func expectNoReset(t require.TestingT, resetC chan performedReset) {
+	select {
+	case reset := <-resetC:
+		require.Fail(t, "unexpected reset", "%v", reset)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func (suite *OcppV2TestSuite) TestResetManagerImmediate() {
+	t := suite.T()
+	manager, resetC := newTestResetManager()
+	stoppedC := make(chan *int, 1)
+	manager.StopTransactions = func(evseID *int) {
+		stoppedC <- evseID
+	}
+	// No transaction ongoing
+	response, err := manager.OnReset(provisioning.NewResetRequest(provisioning.ResetTypeImmediate))
+	require.NoError(t, err)
+	assert.Equal(t, provisioning.ResetStatusAccepted, response.Status)
+	reset := expectReset(t, resetC)
+	assert.Nil(t, reset.evseID)
+	assert.Equal(t, provisioning.ResetTypeImmediate, reset.resetType)
+	assert.Empty(t, stoppedC)
+	// Ongoing transactions are stopped first
+	manager.OnTransactionEvent(newTestResetTransactionEvent(transactions.TransactionEventStarted, "tx-1", 1))
+	response, err = manager.OnReset(provisioning.NewResetRequest(provisioning.ResetTypeImmediate))
+	require.NoError(t, err)
+	assert.Equal(t, provisioning.ResetStatusAccepted, response.Status)
+	expectReset(t, resetC)
+	assert.Nil(t, <-stoppedC)
+	// Unknown EVSE
+	request := provisioning.NewResetRequest(provisioning.ResetTypeImmediate)
+	request.EvseID = newInt(3)
+	response, err = manager.OnReset(request)
+	require.NoError(t, err)
+	assert.Equal(t, provisioning.ResetStatusRejected, response.Status)
+	require.NotNil(t, response.StatusInfo)
+	assert.Equal(t, "UnknownEvse", response.StatusInfo.ReasonCode)
+	expectNoReset(t, resetC)
+}
+
+func (suite *OcppV2TestSuite) TestResetManagerScheduled() {
+	t := suite.T()
+	manager, resetC := newTestResetManager()
+	// The Ended event is queued while offline, and must be delivered before the reset
+	var sent []transactions.TransactionEvent
+	queue := transactions.NewOfflineQueue(nil, func(request *transactions.TransactionEventRequest) (*transactions.TransactionEventResponse, error) {
+		sent = append(sent, request.EventType)
+		return transactions.NewTransactionEventResponse(), nil
+	})
+	manager.Flush = queue.Flush
+	send := func(request *transactions.TransactionEventRequest) {
+		_, _ = queue.SendTransactionEvent(request)
+		manager.OnTransactionEvent(request)
+	}
+	send(newTestResetTransactionEvent(transactions.TransactionEventStarted, "tx-1", 1))
+	send(newTestResetTransactionEvent(transactions.TransactionEventStarted, "tx-2", 2))
+	response, err := manager.OnReset(provisioning.NewResetRequest(provisioning.ResetTypeOnIdle))
+	require.NoError(t, err)
+	assert.Equal(t, provisioning.ResetStatusScheduled, response.Status)
+	assert.True(t, manager.IsScheduled(nil))
+	send(newTestResetTransactionEvent(transactions.TransactionEventEnded, "tx-1", 0))
+	expectNoReset(t, resetC)
+	queue.OnDisconnected()
+	send(newTestResetTransactionEvent(transactions.TransactionEventEnded, "tx-2", 0))
+	expectNoReset(t, resetC)
+	assert.Equal(t, 1, queue.QueueLength())
+	queue.OnReconnected()
+	reset := expectReset(t, resetC)
+	assert.Nil(t, reset.evseID)
+	assert.Equal(t, provisioning.ResetTypeOnIdle, reset.resetType)
+	assert.Equal(t, 0, queue.QueueLength())
+	assert.Equal(t, []transactions.TransactionEvent{transactions.TransactionEventStarted, transactions.TransactionEventStarted, transactions.TransactionEventEnded, transactions.TransactionEventEnded}, sent)
+	assert.False(t, manager.IsScheduled(nil))
+	// A failing flush doesn't prevent the reset
+	flushErr := errors.New("flush failed")
+	manager.Flush = func(ctx context.Context) error {
+		return flushErr
+	}
+	errC := make(chan error, 1)
+	manager.OnFlushError = func(err error) {
+		errC <- err
+	}
+	response, err = manager.OnReset(provisioning.NewResetRequest(provisioning.ResetTypeOnIdle))
+	require.NoError(t, err)
+	assert.Equal(t, provisioning.ResetStatusAccepted, response.Status)
+	expectReset(t, resetC)
+	assert.Equal(t, flushErr, <-errC)
+}
+
+func (suite *OcppV2TestSuite) TestResetManagerEVSEScope() {
+	t := suite.T()
+	manager, resetC := newTestResetManager()
+	manager.OnTransactionEvent(newTestResetTransactionEvent(transactions.TransactionEventStarted, "tx-1", 1))
+	manager.OnTransactionEvent(newTestResetTransactionEvent(transactions.TransactionEventStarted, "tx-2", 2))
+	// The targeted EVSE is busy
+	request := provisioning.NewResetRequest(provisioning.ResetTypeOnIdle)
+	request.EvseID = newInt(1)
+	response, err := manager.OnReset(request)
+	require.NoError(t, err)
+	assert.Equal(t, provisioning.ResetStatusScheduled, response.Status)
+	assert.True(t, manager.IsScheduled(newInt(1)))
+	assert.False(t, manager.IsScheduled(newInt(2)))
+	// Ending the transaction on the other EVSE doesn't trigger the reset
+	manager.OnTransactionEvent(newTestResetTransactionEvent(transactions.TransactionEventEnded, "tx-2", 0))
+	expectNoReset(t, resetC)
+	manager.OnTransactionEvent(newTestResetTransactionEvent(transactions.TransactionEventStarted, "tx-3", 2))
+	manager.OnTransactionEvent(newTestResetTransactionEvent(transactions.TransactionEventEnded, "tx-1", 0))
+	reset := expectReset(t, resetC)
+	require.NotNil(t, reset.evseID)
+	assert.Equal(t, 1, *reset.evseID)
+	assert.False(t, manager.IsScheduled(newInt(1)))
+	// EVSE 1 is idle now, while EVSE 2 keeps charging
+	response, err = manager.OnReset(request)
+	require.NoError(t, err)
+	assert.Equal(t, provisioning.ResetStatusAccepted, response.Status)
+	reset = expectReset(t, resetC)
+	assert.Equal(t, 1, *reset.evseID)
+	response, err = manager.OnReset(provisioning.NewResetRequest(provisioning.ResetTypeOnIdle))
+	require.NoError(t, err)
+	assert.Equal(t, provisioning.ResetStatusScheduled, response.Status)
+}
@@ -0,0 +1,116 @@
+package ocpp2_test
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/security"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+var securityEventTable = []struct {
+	event    security.SecurityEvent
+	name     string
+	severity security.Severity
+}{
+	{security.SecurityEventFirmwareUpdated, "FirmwareUpdated", security.SeverityCritical},
+	{security.SecurityEventFailedToAuthenticateAtCsms, "FailedToAuthenticateAtCsms", security.SeverityInformational},
+	{security.SecurityEventCsmsFailedToAuthenticate, "CsmsFailedToAuthenticate", security.SeverityInformational},
+	{security.SecurityEventSettingSystemTime, "SettingSystemTime", security.SeverityCritical},
+	{security.SecurityEventStartupOfTheDevice, "StartupOfTheDevice", security.SeverityCritical},
+	{security.SecurityEventResetOrReboot, "ResetOrReboot", security.SeverityCritical},
+	{security.SecurityEventSecurityLogWasCleared, "SecurityLogWasCleared", security.SeverityCritical},
+	{security.SecurityEventReconfigurationOfSecurityParameters, "ReconfigurationOfSecurityParameters", security.SeverityInformational},
+	{security.SecurityEventMemoryExhaustion, "MemoryExhaustion", security.SeverityCritical},
+	{security.SecurityEventInvalidMessages, "InvalidMessages", security.SeverityInformational},
+	{security.SecurityEventAttemptedReplayAttacks, "AttemptedReplayAttacks", security.SeverityInformational},
+	{security.SecurityEventTamperDetectionActivated, "TamperDetectionActivated", security.SeverityCritical},
+	{security.SecurityEventInvalidFirmwareSignature, "InvalidFirmwareSignature", security.SeverityInformational},
+	{security.SecurityEventInvalidFirmwareSigningCertificate, "InvalidFirmwareSigningCertificate", security.SeverityInformational},
+	{security.SecurityEventInvalidCsmsCertificate, "InvalidCsmsCertificate", security.SeverityInformational},
+	{security.SecurityEventInvalidChargingStationCertificate, "InvalidChargingStationCertificate", security.SeverityInformational},
+	{security.SecurityEventInvalidTLSVersion, "InvalidTLSVersion", security.SeverityInformational},
+	{security.SecurityEventInvalidTLSCipherSuite, "InvalidTLSCipherSuite", security.SeverityInformational},
+	{security.SecurityEventMaintenanceLoginAccepted, "MaintenanceLoginAccepted", security.SeverityCritical},
+	{security.SecurityEventMaintenanceLoginFailed, "MaintenanceLoginFailed", security.SeverityCritical},
+}
+
+func (suite *OcppV2TestSuite) TestSecurityEventSeverities() {
+	t := suite.T()
+	for _, entry := range securityEventTable {
+		assert.Equal(t, entry.name, string(entry.event))
+		severity, ok := security.SeverityOf(entry.name)
+		assert.True(t, ok, entry.name)
+		assert.Equal(t, entry.severity, severity, entry.name)
+		// Every event type fits into a request
+		request := security.NewSecurityEvent(entry.event)
+		assert.NoError(t, types.Validate.Struct(request), entry.name)
+	}
+	_, ok := security.SeverityOf("CustomVendorEvent")
+	assert.False(t, ok)
+	_, ok = security.SeverityOf(string(security.SecurityEventUnknown))
+	assert.False(t, ok)
+}
+
+func (suite *OcppV2TestSuite) TestParseSecurityEvent() {
+	t := suite.T()
+	for _, entry := range securityEventTable {
+		assert.Equal(t, entry.event, security.ParseSecurityEvent(entry.name))
+		assert.Equal(t, entry.event, security.ParseSecurityEvent(strings.ToLower(entry.name)))
+	}
+	assert.Equal(t, security.SecurityEventUnknown, security.ParseSecurityEvent("CustomVendorEvent"))
+	assert.Equal(t, security.SecurityEventUnknown, security.ParseSecurityEvent(""))
+}
+
+func (suite *OcppV2TestSuite) TestNewSecurityEvent() {
+	t := suite.T()
+	request := security.NewSecurityEvent(security.SecurityEventTamperDetectionActivated)
+	assert.Equal(t, "TamperDetectionActivated", request.Type)
+	require.NotNil(t, request.Timestamp)
+	assert.False(t, request.Timestamp.IsZero())
+}
+
+func (suite *OcppV2TestSuite) TestSecurityEventFilter() {
+	t := suite.T()
+	var sent []string
+	filter := security.NewSecurityEventFilter(func(request *security.SecurityEventNotificationRequest) error {
+		sent = append(sent, request.Type)
+		return nil
+	})
+	// Only critical events are forwarded by default
+	for _, entry := range securityEventTable {
+		forwarded, err := filter.Notify(security.NewSecurityEvent(entry.event))
+		require.NoError(t, err)
+		assert.Equal(t, entry.severity == security.SeverityCritical, forwarded, entry.name)
+	}
+	forwarded, err := filter.Notify(security.NewSecurityEventNotificationRequest("CustomVendorEvent", types.Now()))
+	require.NoError(t, err)
+	assert.False(t, forwarded)
+	assert.Len(t, sent, 9)
+	// Custom forwarding rules
+	sent = nil
+	filter.ShouldForward = func(eventType string, severity security.Severity) bool {
+		return severity != "" || eventType == "CustomVendorEvent"
+	}
+	for _, entry := range securityEventTable {
+		forwarded, err = filter.Notify(security.NewSecurityEvent(entry.event))
+		require.NoError(t, err)
+		assert.True(t, forwarded)
+	}
+	forwarded, err = filter.Notify(security.NewSecurityEventNotificationRequest("CustomVendorEvent", types.Now()))
+	require.NoError(t, err)
+	assert.True(t, forwarded)
+	forwarded, _ = filter.Notify(security.NewSecurityEventNotificationRequest("OtherVendorEvent", types.Now()))
+	assert.False(t, forwarded)
+	assert.Len(t, sent, len(securityEventTable)+1)
+	// Send errors are returned
+	filter.Send = func(request *security.SecurityEventNotificationRequest) error {
+		return errors.New("not connected")
+	}
+	forwarded, err = filter.Notify(security.NewSecurityEvent(security.SecurityEventFirmwareUpdated))
+	assert.True(t, forwarded)
+	assert.Error(t, err)
+}
@@ -0,0 +1,144 @@
+package ocpp2_test
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	ocpp2 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// Simulates the SetVariables behavior of multiple charging stations, behind the mocked websocket server.
+type mockRolloutStations struct {
+	suite    *OcppV2TestSuite
+	mutex    sync.Mutex
+	statuses map[string]map[string]provisioning.SetVariableStatus // Station -> variable -> status. Defaults to Accepted.
+	drop     map[string]int                                       // Station -> amount of requests to leave unanswered.
+	requests map[string]int
+}
+
+func (m *mockRolloutStations) write(args mock.Arguments) {
+	t := m.suite.T()
+	clientID := args.String(0)
+	var message []json.RawMessage
+	require.NoError(t, json.Unmarshal(args.Get(1).([]byte), &message))
+	var messageID string
+	require.NoError(t, json.Unmarshal(message[1], &messageID))
+	var request provisioning.SetVariablesRequest
+	require.NoError(t, json.Unmarshal(message[3], &request))
+	m.mutex.Lock()
+	m.requests[clientID]++
+	if m.drop[clientID] > 0 {
+		m.drop[clientID]--
+		m.mutex.Unlock()
+		return
+	}
+	response := provisioning.SetVariablesResponse{}
+	for _, data := range request.SetVariableData {
+		status, ok := m.statuses[clientID][data.Variable.Name]
+		if !ok {
+			status = provisioning.SetVariableStatusAccepted
+		}
+		response.SetVariableResult = append(response.SetVariableResult, provisioning.SetVariableResult{AttributeStatus: status, Component: data.Component, Variable: data.Variable})
+	}
+	m.mutex.Unlock()
+	raw, err := json.Marshal([]interface{}{3, messageID, response})
+	require.NoError(t, err)
+	go func() {
+		_ = m.suite.mockWsServer.MessageHandler(NewMockWebSocket(clientID), raw)
+	}()
+}
+
+func (suite *OcppV2TestSuite) TestSetVariablesRollout() {
+	t := suite.T()
+	stations := &mockRolloutStations{
+		suite: suite,
+		statuses: map[string]map[string]provisioning.SetVariableStatus{
+			"cs2": {"HeartbeatInterval": provisioning.SetVariableStatusRebootRequired},
+			"cs3": {"MessageTimeout": provisioning.SetVariableStatusRejected, "HeartbeatInterval": provisioning.SetVariableStatusRebootRequired},
+		},
+		drop:     map[string]int{"cs4": 1},
+		requests: map[string]int{},
+	}
+	suite.serverDispatcher.SetTimeout(200 * time.Millisecond)
+	suite.mockWsServer.On("Start", mock.AnythingOfType("int"), mock.AnythingOfType("string")).Return(nil)
+	suite.mockWsServer.On("Write", mock.AnythingOfType("string"), mock.Anything).Return(nil).Run(stations.write)
+	suite.csms.Start(8887, "somePath")
+	for _, id := range []string{"cs1", "cs2", "cs3", "cs4"} {
+		suite.mockWsServer.NewClientHandler(NewMockWebSocket(id))
+	}
+	suite.csms.SetMessageLimits("cs1", provisioning.MessageLimits{ItemsPerMessageSetVariables: 1})
+	variables := []provisioning.SetVariableData{
+		{AttributeValue: "300", Component: types.Component{Name: "OCPPCommCtrlr"}, Variable: types.Variable{Name: "HeartbeatInterval"}},
+		{AttributeValue: "30", Component: types.Component{Name: "OCPPCommCtrlr"}, Variable: types.Variable{Name: "MessageTimeout"}},
+		{AttributeValue: "true", Component: types.Component{Name: "AuthCtrlr"}, Variable: types.Variable{Name: "LocalPreAuthorize"}},
+	}
+	// Invalid rollouts
+	_, err := suite.csms.SetVariablesRollout(nil, variables, ocpp2.RolloutOptions{})
+	assert.Error(t, err)
+	_, err = suite.csms.SetVariablesRollout(ocpp2.AllStations(), nil, ocpp2.RolloutOptions{})
+	assert.Error(t, err)
+	// cs5 connects during the rollout, cs6 never does
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		suite.mockWsServer.NewClientHandler(NewMockWebSocket("cs5"))
+	}()
+	opts := ocpp2.RolloutOptions{Concurrency: 3, MaxRetries: 1, RetryInterval: 10 * time.Millisecond, OfflineTTL: 500 * time.Millisecond}
+	results, err := suite.csms.SetVariablesRollout(ocpp2.Stations("cs1", "cs2", "cs3", "cs4", "cs5", "cs6", "cs1"), variables, opts)
+	require.NoError(t, err)
+	summary := ocpp2.CollectRollout(results)
+	assert.Equal(t, []string{"cs1", "cs4", "cs5"}, summary.StationIDs(ocpp2.RolloutOutcomeAccepted))
+	assert.Equal(t, []string{"cs2"}, summary.StationIDs(ocpp2.RolloutOutcomeRebootRequired))
+	assert.Equal(t, []string{"cs3"}, summary.StationIDs(ocpp2.RolloutOutcomeRejected))
+	assert.Equal(t, []string{"cs6"}, summary.StationIDs(ocpp2.RolloutOutcomeOffline))
+	assert.Empty(t, summary.StationIDs(ocpp2.RolloutOutcomeFailed))
+	resultByStation := map[string]ocpp2.StationResult{}
+	for _, r := range summary.Stations {
+		for _, result := range r {
+			resultByStation[result.ChargingStationID] = result
+		}
+	}
+	// Split into one request per variable, results are merged in order
+	cs1 := resultByStation["cs1"]
+	assert.Equal(t, 3, cs1.Attempts)
+	require.Len(t, cs1.Results, 3)
+	for i, result := range cs1.Results {
+		assert.Equal(t, variables[i].Variable.Name, result.Variable.Name)
+	}
+	assert.True(t, resultByStation["cs2"].RebootRequired)
+	cs3 := resultByStation["cs3"]
+	assert.True(t, cs3.RebootRequired)
+	require.Len(t, cs3.Results, 3)
+	assert.Equal(t, provisioning.SetVariableStatusRejected, cs3.Results[1].AttributeStatus)
+	// The unanswered request was retried
+	cs4 := resultByStation["cs4"]
+	assert.Equal(t, 2, cs4.Attempts)
+	assert.NoError(t, cs4.Err)
+	cs6 := resultByStation["cs6"]
+	assert.Equal(t, 0, cs6.Attempts)
+	assert.ErrorIs(t, cs6.Err, ocpp2.ErrStationOffline)
+	// Only connected stations are selected, offline stations aren't waited for by default
+	stations.mutex.Lock()
+	stations.drop["cs2"] = 2
+	stations.mutex.Unlock()
+	suite.mockWsServer.DisconnectedClientHandler(NewMockWebSocket("cs3"))
+	results, err = suite.csms.SetVariablesRollout(ocpp2.AllStations(), variables[2:], ocpp2.RolloutOptions{MaxRetries: 1})
+	require.NoError(t, err)
+	summary = ocpp2.CollectRollout(results)
+	assert.Equal(t, []string{"cs1", "cs4", "cs5"}, summary.StationIDs(ocpp2.RolloutOutcomeAccepted))
+	require.Len(t, summary.Stations[ocpp2.RolloutOutcomeFailed], 1)
+	failed := summary.Stations[ocpp2.RolloutOutcomeFailed][0]
+	assert.Equal(t, "cs2", failed.ChargingStationID)
+	assert.Equal(t, 2, failed.Attempts)
+	assert.ErrorIs(t, failed.Err, ocpp2.ErrRequestTimeout)
+	results, err = suite.csms.SetVariablesRollout(ocpp2.Stations("cs3"), variables, ocpp2.RolloutOptions{})
+	require.NoError(t, err)
+	summary = ocpp2.CollectRollout(results)
+	assert.Equal(t, []string{"cs3"}, summary.StationIDs(ocpp2.RolloutOutcomeOffline))
+}
@@ -0,0 +1,165 @@
+package ocpp2_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/meter"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+const ocmfFixturePayload = `{"FV":"1.0","GI":"ABL SBC-301","GS":"808829900001","GV":"1.4p3","PG":"T12345","MV":"Phoenix Contact","MM":"EEM-350-D-MCB","MS":"BQ27400330016","MF":"1.0","IS":true,"IL":"VERIFIED","IF":["RFID_PLAIN","OCPP_RS_TLS"],"IT":"ISO14443","ID":"1F2D3A4F5506C7","RD":[{"TM":"2018-07-24T13:22:04,000+0200 S","TX":"B","RV":2935.6,"RI":"1-b:1.8.0","RU":"kWh","RT":"AC","EF":"","ST":"G"},{"TM":"2018-07-24T14:38:43,000+0200 S","TX":"E","RV":2947.2,"EF":"","ST":"G"}]}`
+
+type ocmfFixture struct {
+	key       *ecdsa.PrivateKey
+	publicKey string
+}
+
+func newOCMFFixture(t require.TestingT) ocmfFixture {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	// OCMF meters typically publish their key as hex encoded DER
+	return ocmfFixture{key: key, publicKey: base64.StdEncoding.EncodeToString([]byte(strings.ToUpper(hex.EncodeToString(der))))}
+}
+
+// Signs the payload, returning the signed meter value containing the OCMF container.
+func (f ocmfFixture) signedMeterValue(t require.TestingT, payload string) types.SignedMeterValue {
+	digest := sha256.Sum256([]byte(payload))
+	signature, err := ecdsa.SignASN1(rand.Reader, f.key, digest[:])
+	require.NoError(t, err)
+	container := fmt.Sprintf(`OCMF|%s|{"SA":"ECDSA-secp256r1-SHA256","SD":"%s"}`, payload, strings.ToUpper(hex.EncodeToString(signature)))
+	return types.SignedMeterValue{
+		SignedMeterData: base64.StdEncoding.EncodeToString([]byte(container)),
+		SigningMethod:   "ECDSA-secp256r1-SHA256",
+		EncodingMethod:  "OCMF",
+		PublicKey:       f.publicKey,
+	}
+}
+
+func (suite *OcppV2TestSuite) TestOCMFVerifierValidSignature() {
+	t := suite.T()
+	fixture := newOCMFFixture(t)
+	value := fixture.signedMeterValue(t, ocmfFixturePayload)
+	require.NoError(t, types.Validate.Struct(value))
+	verifier := &meter.OCMFVerifier{}
+	readings, err := verifier.Verify(value)
+	require.NoError(t, err)
+	assert.Equal(t, "BQ27400330016", readings.MeterSerial)
+	assert.Equal(t, "T12345", readings.Pagination)
+	assert.Equal(t, "1F2D3A4F5506C7", readings.Identification)
+	require.Len(t, readings.Readings, 2)
+	begin := readings.Readings[0]
+	assert.Equal(t, "B", begin.Type)
+	assert.Equal(t, 2935.6, begin.Value)
+	assert.Equal(t, "1-b:1.8.0", begin.Identifier)
+	assert.Equal(t, "kWh", begin.Unit)
+	assert.Equal(t, "S", begin.TimeStatus)
+	assert.True(t, time.Date(2018, 7, 24, 11, 22, 4, 0, time.UTC).Equal(begin.Time))
+	// Identifier, unit and current type are inherited
+	end := readings.Readings[1]
+	assert.Equal(t, "E", end.Type)
+	assert.Equal(t, 2947.2, end.Value)
+	assert.Equal(t, "1-b:1.8.0", end.Identifier)
+	assert.Equal(t, "kWh", end.Unit)
+	assert.Equal(t, "AC", end.CurrentType)
+	// A trusted key overrides the sent one
+	verifier.PublicKey = &fixture.key.PublicKey
+	value.PublicKey = newOCMFFixture(t).publicKey
+	_, err = verifier.Verify(value)
+	assert.NoError(t, err)
+}
+
+func (suite *OcppV2TestSuite) TestOCMFVerifierTamperedPayload() {
+	t := suite.T()
+	fixture := newOCMFFixture(t)
+	value := fixture.signedMeterValue(t, ocmfFixturePayload)
+	container, err := base64.StdEncoding.DecodeString(value.SignedMeterData)
+	require.NoError(t, err)
+	tampered := strings.Replace(string(container), `"RV":2947.2`, `"RV":2940.2`, 1)
+	value.SignedMeterData = base64.StdEncoding.EncodeToString([]byte(tampered))
+	verifier := &meter.OCMFVerifier{}
+	readings, err := verifier.Verify(value)
+	assert.ErrorIs(t, err, meter.ErrInvalidSignature)
+	assert.Nil(t, readings)
+	// Signed by a different meter
+	value = fixture.signedMeterValue(t, ocmfFixturePayload)
+	verifier.PublicKey = &newOCMFFixture(t).key.PublicKey
+	_, err = verifier.Verify(value)
+	assert.ErrorIs(t, err, meter.ErrInvalidSignature)
+}
+
+func (suite *OcppV2TestSuite) TestOCMFVerifierMalformedData() {
+	t := suite.T()
+	fixture := newOCMFFixture(t)
+	verifier := &meter.OCMFVerifier{}
+	encode := func(data string) types.SignedMeterValue {
+		return types.SignedMeterValue{SignedMeterData: base64.StdEncoding.EncodeToString([]byte(data)), SigningMethod: "ECDSA-secp256r1-SHA256", EncodingMethod: "OCMF", PublicKey: fixture.publicKey}
+	}
+	_, err := verifier.Verify(encode("DLMS|deadbeef"))
+	assert.ErrorIs(t, err, meter.ErrUnsupportedEncoding)
+	_, err = verifier.Verify(encode("OCMF|" + ocmfFixturePayload))
+	assert.ErrorIs(t, err, meter.ErrMalformedSignedData)
+	_, err = verifier.Verify(encode(`OCMF|{"RD":[}|{"SD":"00"}`))
+	assert.ErrorIs(t, err, meter.ErrMalformedSignedData)
+	_, err = verifier.Verify(encode(`OCMF|{}|{"SA":"ECDSA-brainpool256r1-SHA256","SD":"00"}`))
+	assert.ErrorIs(t, err, meter.ErrUnsupportedEncoding)
+	value := fixture.signedMeterValue(t, ocmfFixturePayload)
+	value.PublicKey = "3q2+7w=="
+	_, err = verifier.Verify(value)
+	assert.ErrorIs(t, err, meter.ErrMalformedSignedData)
+}
+
+func (suite *OcppV2TestSuite) TestVerifyTransactionEvents() {
+	t := suite.T()
+	fixture := newOCMFFixture(t)
+	valid := fixture.signedMeterValue(t, ocmfFixturePayload)
+	tampered := fixture.signedMeterValue(t, ocmfFixturePayload)
+	tampered.SignedMeterData = valid.SignedMeterData[:len(valid.SignedMeterData)-8] + "AAAAAA=="
+	timestamp := types.NewDateTime(time.Date(2018, 7, 24, 12, 38, 43, 0, time.UTC))
+	newEvent := func(eventType transactions.TransactionEvent, seqNo int, signed *types.SignedMeterValue) transactions.TransactionEventRequest {
+		event := transactions.TransactionEventRequest{
+			EventType:       eventType,
+			Timestamp:       timestamp,
+			SequenceNo:      seqNo,
+			TransactionInfo: transactions.Transaction{TransactionID: "tx1"},
+		}
+		sampledValues := []types.SampledValue{{Value: 1.5, Measurand: types.MeasurandPowerActiveImport}}
+		if signed != nil {
+			sampledValues = append(sampledValues, types.SampledValue{Value: 2947.2, Context: types.ReadingContextTransactionEnd, SignedMeterValue: signed})
+		}
+		event.MeterValue = []types.MeterValue{{Timestamp: *timestamp, SampledValue: sampledValues}}
+		return event
+	}
+	events := []transactions.TransactionEventRequest{
+		newEvent(transactions.TransactionEventStarted, 0, &valid),
+		newEvent(transactions.TransactionEventUpdated, 1, nil),
+		newEvent(transactions.TransactionEventEnded, 2, &tampered),
+	}
+	results := meter.VerifyTransactionEvents(events, &meter.OCMFVerifier{})
+	require.Len(t, results, 2)
+	assert.True(t, results[0].Valid())
+	assert.Equal(t, "tx1", results[0].TransactionID)
+	assert.Equal(t, 0, results[0].SeqNo)
+	assert.True(t, timestamp.Time.Equal(results[0].Timestamp))
+	require.NotNil(t, results[0].Readings)
+	assert.Len(t, results[0].Readings.Readings, 2)
+	assert.False(t, results[1].Valid())
+	assert.Equal(t, 2, results[1].SeqNo)
+	assert.Equal(t, 2947.2, results[1].SampledValue.Value)
+	assert.Error(t, results[1].Err)
+	assert.Empty(t, meter.VerifyTransactionEvents(nil, &meter.OCMFVerifier{}))
+}
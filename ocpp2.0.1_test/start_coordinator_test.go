@@ -0,0 +1,164 @@
+package ocpp2_test
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/remotecontrol"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+type sentRemoteStart struct {
+	chargingStationID string
+	remoteStartID     int
+	evseID            *int
+	callback          func(*remotecontrol.RequestStartTransactionResponse, error)
+}
+
+// Returns a coordinator recording all sent requests, and a channel receiving all start results.
+func newTestStartCoordinator(timeout time.Duration) (*remotecontrol.StartCoordinator, chan sentRemoteStart, chan remotecontrol.StartResult) {
+	sentC := make(chan sentRemoteStart, 2)
+	resultC := make(chan remotecontrol.StartResult, 2)
+	coordinator := remotecontrol.NewStartCoordinator(timeout, func(clientId string, callback func(*remotecontrol.RequestStartTransactionResponse, error), remoteStartID int, IdToken types.IdToken, props ...func(request *remotecontrol.RequestStartTransactionRequest)) error {
+		request := remotecontrol.NewRequestStartTransactionRequest(remoteStartID, IdToken)
+		for _, fn := range props {
+			fn(request)
+		}
+		sentC <- sentRemoteStart{chargingStationID: clientId, remoteStartID: remoteStartID, evseID: request.EvseID, callback: callback}
+		return nil
+	})
+	return coordinator, sentC, resultC
+}
+
+// Returns a TransactionEventRequest echoing the remoteStartId.
+func newTestRemoteStartEvent(eventType transactions.TransactionEvent, seqNo int, transactionID string, remoteStartID int, evseID int) *transactions.TransactionEventRequest {
+	request := transactions.NewTransactionEventRequest(eventType, types.NewDateTime(time.Now()), transactions.TriggerReasonRemoteStart, seqNo, transactions.Transaction{TransactionID: transactionID, RemoteStartID: newInt(remoteStartID)})
+	request.Evse = &types.EVSE{ID: evseID}
+	return request
+}
+
+func (suite *OcppV2TestSuite) TestStartCoordinatorConcurrentStarts() {
+	t := suite.T()
+	coordinator, sentC, resultC := newTestStartCoordinator(time.Minute)
+	tracker := transactions.NewEventTracker(nil, nil)
+	tracker.AddListener(coordinator.OnTransactionState)
+	var duplicates []*remotecontrol.DuplicateRemoteStartError
+	var mutex sync.Mutex
+	coordinator.OnDuplicate = func(err *remotecontrol.DuplicateRemoteStartError) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		duplicates = append(duplicates, err)
+	}
+	idToken := types.IdToken{IdToken: "1234", Type: types.IdTokenTypeISO14443}
+	onResult := func(result remotecontrol.StartResult) {
+		resultC <- result
+	}
+	// Start on two EVSEs concurrently
+	var wg sync.WaitGroup
+	for _, evseID := range []int{1, 2} {
+		wg.Add(1)
+		go func(evseID int) {
+			defer wg.Done()
+			_, err := coordinator.Start("station1", idToken, onResult, func(request *remotecontrol.RequestStartTransactionRequest) {
+				request.EvseID = newInt(evseID)
+			})
+			assert.NoError(t, err)
+		}(evseID)
+	}
+	wg.Wait()
+	sent := map[int]sentRemoteStart{}
+	for i := 0; i < 2; i++ {
+		start := <-sentC
+		require.NotNil(t, start.evseID)
+		sent[*start.evseID] = start
+	}
+	require.Len(t, sent, 2)
+	assert.NotEqual(t, sent[1].remoteStartID, sent[2].remoteStartID)
+	assert.Equal(t, 2, coordinator.PendingStarts())
+	for _, start := range sent {
+		start.callback(remotecontrol.NewRequestStartTransactionResponse(remotecontrol.RequestStartStopStatusAccepted), nil)
+	}
+	assert.Empty(t, resultC)
+	// The transactions start in reverse order
+	require.NoError(t, tracker.Add("station1", newTestRemoteStartEvent(transactions.TransactionEventStarted, 0, "tx-2", sent[2].remoteStartID, 2)))
+	require.NoError(t, tracker.Add("station1", newTestRemoteStartEvent(transactions.TransactionEventStarted, 0, "tx-1", sent[1].remoteStartID, 1)))
+	results := map[int]remotecontrol.StartResult{}
+	for i := 0; i < 2; i++ {
+		result := <-resultC
+		require.NoError(t, result.Err)
+		assert.Equal(t, "station1", result.ChargingStationID)
+		results[result.RemoteStartID] = result
+	}
+	assert.Equal(t, "tx-1", results[sent[1].remoteStartID].TransactionID)
+	assert.Equal(t, "tx-2", results[sent[2].remoteStartID].TransactionID)
+	assert.Equal(t, 0, coordinator.PendingStarts())
+	// Further events of the same transaction aren't duplicates
+	require.NoError(t, tracker.Add("station1", newTestRemoteStartEvent(transactions.TransactionEventUpdated, 1, "tx-1", sent[1].remoteStartID, 1)))
+	// Another transaction echoing the same remoteStartId is
+	coordinator.OnTransactionEvent("station1", newTestRemoteStartEvent(transactions.TransactionEventStarted, 0, "tx-3", sent[1].remoteStartID, 1))
+	mutex.Lock()
+	defer mutex.Unlock()
+	require.Len(t, duplicates, 1)
+	assert.Equal(t, "tx-3", duplicates[0].TransactionID)
+	assert.Equal(t, "tx-1", duplicates[0].ExistingTransactionID)
+	assert.Equal(t, sent[1].remoteStartID, duplicates[0].RemoteStartID)
+	assert.Empty(t, resultC)
+}
+
+func (suite *OcppV2TestSuite) TestStartCoordinatorFailures() {
+	t := suite.T()
+	coordinator, sentC, resultC := newTestStartCoordinator(50 * time.Millisecond)
+	coordinator.SetNextRemoteStartID(100)
+	idToken := types.IdToken{IdToken: "1234", Type: types.IdTokenTypeISO14443}
+	onResult := func(result remotecontrol.StartResult) {
+		resultC <- result
+	}
+	// Accepted, but never started
+	remoteStartID, err := coordinator.Start("station1", idToken, onResult)
+	require.NoError(t, err)
+	assert.Equal(t, 100, remoteStartID)
+	(<-sentC).callback(remotecontrol.NewRequestStartTransactionResponse(remotecontrol.RequestStartStopStatusAccepted), nil)
+	select {
+	case result := <-resultC:
+		assert.ErrorIs(t, result.Err, remotecontrol.ErrTransactionNotStarted)
+		assert.Equal(t, 100, result.RemoteStartID)
+		assert.Empty(t, result.TransactionID)
+	case <-time.After(time.Second):
+		require.Fail(t, "remote start didn't time out")
+	}
+	// Late echoes are ignored
+	coordinator.OnTransactionEvent("station1", newTestRemoteStartEvent(transactions.TransactionEventStarted, 0, "tx-1", 100, 1))
+	assert.Empty(t, resultC)
+	// No response at all
+	_, err = coordinator.Start("station1", idToken, onResult)
+	require.NoError(t, err)
+	<-sentC
+	assert.ErrorIs(t, (<-resultC).Err, remotecontrol.ErrStartTimeout)
+	// Rejected
+	_, err = coordinator.Start("station1", idToken, onResult)
+	require.NoError(t, err)
+	(<-sentC).callback(remotecontrol.NewRequestStartTransactionResponse(remotecontrol.RequestStartStopStatusRejected), nil)
+	assert.ErrorIs(t, (<-resultC).Err, remotecontrol.ErrStartRejected)
+	// Already started before the request was received
+	_, err = coordinator.Start("station1", idToken, onResult)
+	require.NoError(t, err)
+	response := remotecontrol.NewRequestStartTransactionResponse(remotecontrol.RequestStartStopStatusAccepted)
+	response.TransactionID = "tx-2"
+	(<-sentC).callback(response, nil)
+	result := <-resultC
+	require.NoError(t, result.Err)
+	assert.Equal(t, "tx-2", result.TransactionID)
+	// Sending fails
+	coordinator.SendRequestStartTransaction = func(clientId string, callback func(*remotecontrol.RequestStartTransactionResponse, error), remoteStartID int, IdToken types.IdToken, props ...func(request *remotecontrol.RequestStartTransactionRequest)) error {
+		return errors.New("not connected")
+	}
+	_, err = coordinator.Start("station1", idToken, onResult)
+	assert.Error(t, err)
+	assert.Equal(t, 0, coordinator.PendingStarts())
+	assert.Empty(t, resultC)
+}
@@ -0,0 +1,266 @@
+package ocpp2_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/availability"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+type sentStatusNotification struct {
+	evseID      int
+	connectorID int
+	status      availability.ConnectorStatus
+}
+
+type stationManagerRecorder struct {
+	heartbeats    []time.Time
+	notifications []sentStatusNotification
+	clock         *fakeCostClock
+	sendErr       error
+}
+
+func newTestStationManager() (*availability.StationManager, *stationManagerRecorder) {
+	recorder := &stationManagerRecorder{clock: &fakeCostClock{now: time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)}}
+	manager := availability.NewStationManager(func(request *availability.HeartbeatRequest) error {
+		recorder.heartbeats = append(recorder.heartbeats, recorder.clock.Now())
+		return nil
+	}, func(request *availability.StatusNotificationRequest) error {
+		if recorder.sendErr != nil {
+			return recorder.sendErr
+		}
+		recorder.notifications = append(recorder.notifications, sentStatusNotification{evseID: request.EvseID, connectorID: request.ConnectorID, status: request.ConnectorStatus})
+		return nil
+	})
+	manager.SetTimeSource(recorder.clock.Now)
+	return manager, recorder
+}
+
+// Advances the fake clock in steps of one second, ticking the manager after each step.
+func (r *stationManagerRecorder) advance(manager *availability.StationManager, d time.Duration) {
+	for elapsed := time.Duration(0); elapsed < d; elapsed += time.Second {
+		r.clock.Advance(time.Second)
+		manager.Tick()
+	}
+}
+
+func (r *stationManagerRecorder) popNotifications() []sentStatusNotification {
+	notifications := r.notifications
+	r.notifications = nil
+	return notifications
+}
+
+func (suite *OcppV2TestSuite) TestStationManagerHeartbeatCadence() {
+	t := suite.T()
+	manager, recorder := newTestStationManager()
+	// No heartbeats before the boot notification was accepted
+	recorder.advance(manager, time.Minute)
+	assert.Empty(t, recorder.heartbeats)
+	_, ok := manager.NextHeartbeat()
+	assert.False(t, ok)
+	manager.OnBootNotificationResponse(provisioning.NewBootNotificationResponse(types.NewDateTime(recorder.clock.Now()), 60, provisioning.RegistrationStatusPending))
+	recorder.advance(manager, time.Minute)
+	assert.Empty(t, recorder.heartbeats)
+	manager.OnBootNotificationResponse(provisioning.NewBootNotificationResponse(types.NewDateTime(recorder.clock.Now()), 60, provisioning.RegistrationStatusAccepted))
+	bootTime := recorder.clock.Now()
+	assert.Equal(t, time.Minute, manager.HeartbeatInterval())
+	recorder.advance(manager, 150*time.Second)
+	assert.Equal(t, []time.Time{bootTime.Add(time.Minute), bootTime.Add(2 * time.Minute)}, recorder.heartbeats)
+	next, ok := manager.NextHeartbeat()
+	require.True(t, ok)
+	assert.Equal(t, bootTime.Add(3*time.Minute), next)
+}
+
+func (suite *OcppV2TestSuite) TestStationManagerHeartbeatSuppression() {
+	t := suite.T()
+	manager, recorder := newTestStationManager()
+	manager.SetHeartbeatInterval(time.Minute)
+	recorder.advance(manager, time.Minute)
+	require.Len(t, recorder.heartbeats, 1)
+	// Other traffic postpones the next heartbeat
+	recorder.advance(manager, 50*time.Second)
+	manager.OnMessageSent()
+	recorder.advance(manager, 50*time.Second)
+	assert.Len(t, recorder.heartbeats, 1)
+	recorder.advance(manager, 10*time.Second)
+	assert.Len(t, recorder.heartbeats, 2)
+	// Status notifications count as traffic
+	recorder.advance(manager, 50*time.Second)
+	manager.SetConnectorStatus(1, 1, availability.ConnectorStatusAvailable)
+	recorder.advance(manager, 50*time.Second)
+	assert.Len(t, recorder.heartbeats, 2)
+	// Disabled heartbeats
+	manager.SetHeartbeatInterval(0)
+	recorder.advance(manager, 5*time.Minute)
+	assert.Len(t, recorder.heartbeats, 2)
+}
+
+func (suite *OcppV2TestSuite) TestStationManagerHeartbeatIntervalVariable() {
+	t := suite.T()
+	manager, recorder := newTestStationManager()
+	model := provisioning.NewDeviceModel()
+	require.NoError(t, model.AddVariable(provisioning.DeviceModelVariable{
+		Component:       types.Component{Name: "OCPPCommCtrlr"},
+		Variable:        types.Variable{Name: "HeartbeatInterval"},
+		Attributes:      []provisioning.VariableAttribute{{Value: "60"}},
+		Characteristics: &provisioning.VariableCharacteristics{DataType: provisioning.TypeInteger},
+	}))
+	handler := provisioning.NewDeviceModelHandler(model, nil)
+	handler.OnVariableSet = manager.OnVariableSet
+	manager.OnBootNotificationResponse(provisioning.NewBootNotificationResponse(types.NewDateTime(recorder.clock.Now()), 300, provisioning.RegistrationStatusAccepted))
+	// The interval set by the CSMS replaces the boot interval
+	response, err := handler.OnSetVariables(provisioning.NewSetVariablesRequest([]provisioning.SetVariableData{
+		{Component: types.Component{Name: "OCPPCommCtrlr"}, Variable: types.Variable{Name: "HeartbeatInterval"}, AttributeValue: "30"},
+	}))
+	require.NoError(t, err)
+	require.Equal(t, provisioning.SetVariableStatusAccepted, response.SetVariableResult[0].AttributeStatus)
+	assert.Equal(t, 30*time.Second, manager.HeartbeatInterval())
+	recorder.advance(manager, time.Minute)
+	assert.Len(t, recorder.heartbeats, 2)
+	// Rejected values and other variables don't affect the interval
+	_, err = handler.OnSetVariables(provisioning.NewSetVariablesRequest([]provisioning.SetVariableData{
+		{Component: types.Component{Name: "OCPPCommCtrlr"}, Variable: types.Variable{Name: "HeartbeatInterval"}, AttributeValue: "often"},
+	}))
+	require.NoError(t, err)
+	manager.OnVariableSet(types.Component{Name: "OCPPCommCtrlr"}, types.Variable{Name: "NetworkProfileConnectionAttempts"}, types.AttributeActual, "5")
+	manager.OnVariableSet(types.Component{Name: "OCPPCommCtrlr"}, types.Variable{Name: "HeartbeatInterval"}, types.AttributeMaxSet, "5")
+	assert.Equal(t, 30*time.Second, manager.HeartbeatInterval())
+}
+
+func (suite *OcppV2TestSuite) TestStationManagerStatusDeduplication() {
+	t := suite.T()
+	manager, recorder := newTestStationManager()
+	manager.SetConnectorStatus(1, 1, availability.ConnectorStatusAvailable)
+	manager.SetConnectorStatus(1, 2, availability.ConnectorStatusAvailable)
+	manager.SetConnectorStatus(1, 1, availability.ConnectorStatusAvailable)
+	manager.SetConnectorStatus(1, 1, availability.ConnectorStatusOccupied)
+	manager.SetConnectorStatus(1, 1, availability.ConnectorStatusOccupied)
+	assert.Equal(t, []sentStatusNotification{
+		{evseID: 1, connectorID: 1, status: availability.ConnectorStatusAvailable},
+		{evseID: 1, connectorID: 2, status: availability.ConnectorStatusAvailable},
+		{evseID: 1, connectorID: 1, status: availability.ConnectorStatusOccupied},
+	}, recorder.popNotifications())
+	status, ok := manager.ConnectorStatus(1, 1)
+	require.True(t, ok)
+	assert.Equal(t, availability.ConnectorStatusOccupied, status)
+	_, ok = manager.ConnectorStatus(2, 1)
+	assert.False(t, ok)
+	// Failed notifications are sent again
+	var sendErrors []string
+	manager.OnSendError = func(featureName string, err error) {
+		sendErrors = append(sendErrors, featureName)
+	}
+	recorder.sendErr = errors.New("not connected")
+	manager.SetConnectorStatus(1, 2, availability.ConnectorStatusFaulted)
+	assert.Equal(t, []string{availability.StatusNotificationFeatureName}, sendErrors)
+	recorder.sendErr = nil
+	manager.SetConnectorStatus(1, 2, availability.ConnectorStatusFaulted)
+	assert.Equal(t, []sentStatusNotification{{evseID: 1, connectorID: 2, status: availability.ConnectorStatusFaulted}}, recorder.popNotifications())
+}
+
+func (suite *OcppV2TestSuite) TestStationManagerChangeAvailability() {
+	t := suite.T()
+	manager, recorder := newTestStationManager()
+	manager.SetConnectorStatus(1, 1, availability.ConnectorStatusAvailable)
+	manager.SetConnectorStatus(2, 1, availability.ConnectorStatusAvailable)
+	manager.SetConnectorStatus(2, 2, availability.ConnectorStatusFaulted)
+	recorder.popNotifications()
+	// EVSE scope
+	request := availability.NewChangeAvailabilityRequest(availability.OperationalStatusInoperative)
+	request.Evse = &types.EVSE{ID: 1}
+	response, err := manager.OnChangeAvailability(request)
+	require.NoError(t, err)
+	assert.Equal(t, availability.ChangeAvailabilityStatusAccepted, response.Status)
+	assert.Equal(t, []sentStatusNotification{{evseID: 1, connectorID: 1, status: availability.ConnectorStatusUnavailable}}, recorder.popNotifications())
+	assert.Equal(t, availability.OperationalStatusInoperative, manager.OperationalStatus(1, 0))
+	assert.Equal(t, availability.OperationalStatusOperative, manager.OperationalStatus(2, 0))
+	// The physical status is still tracked while inoperative
+	manager.SetConnectorStatus(1, 1, availability.ConnectorStatusOccupied)
+	assert.Empty(t, recorder.popNotifications())
+	// Station scope; faulted connectors stay faulted
+	response, err = manager.OnChangeAvailability(availability.NewChangeAvailabilityRequest(availability.OperationalStatusInoperative))
+	require.NoError(t, err)
+	assert.Equal(t, availability.ChangeAvailabilityStatusAccepted, response.Status)
+	assert.Equal(t, []sentStatusNotification{{evseID: 2, connectorID: 1, status: availability.ConnectorStatusUnavailable}}, recorder.popNotifications())
+	response, err = manager.OnChangeAvailability(availability.NewChangeAvailabilityRequest(availability.OperationalStatusOperative))
+	require.NoError(t, err)
+	assert.Equal(t, availability.ChangeAvailabilityStatusAccepted, response.Status)
+	assert.Equal(t, []sentStatusNotification{{evseID: 2, connectorID: 1, status: availability.ConnectorStatusAvailable}}, recorder.popNotifications())
+	// EVSE 1 is still inoperative by itself
+	assert.Equal(t, availability.OperationalStatusInoperative, manager.OperationalStatus(1, 1))
+	request = availability.NewChangeAvailabilityRequest(availability.OperationalStatusOperative)
+	request.Evse = &types.EVSE{ID: 1}
+	_, err = manager.OnChangeAvailability(request)
+	require.NoError(t, err)
+	assert.Equal(t, []sentStatusNotification{{evseID: 1, connectorID: 1, status: availability.ConnectorStatusOccupied}}, recorder.popNotifications())
+	// Unknown EVSE and connector
+	request.Evse = &types.EVSE{ID: 3}
+	response, err = manager.OnChangeAvailability(request)
+	require.NoError(t, err)
+	assert.Equal(t, availability.ChangeAvailabilityStatusRejected, response.Status)
+	require.NotNil(t, response.StatusInfo)
+	assert.Equal(t, "UnknownEvse", response.StatusInfo.ReasonCode)
+	request.Evse = &types.EVSE{ID: 1, ConnectorID: newInt(2)}
+	response, err = manager.OnChangeAvailability(request)
+	require.NoError(t, err)
+	assert.Equal(t, availability.ChangeAvailabilityStatusRejected, response.Status)
+	assert.Equal(t, "UnknownConnectorId", response.StatusInfo.ReasonCode)
+}
+
+func (suite *OcppV2TestSuite) TestStationManagerScheduledAvailability() {
+	t := suite.T()
+	manager, recorder := newTestStationManager()
+	manager.SetConnectorStatus(1, 1, availability.ConnectorStatusOccupied)
+	manager.SetConnectorStatus(2, 1, availability.ConnectorStatusOccupied)
+	recorder.popNotifications()
+	manager.SetEVSEBusy(1, true)
+	manager.SetEVSEBusy(2, true)
+	// EVSE scope
+	request := availability.NewChangeAvailabilityRequest(availability.OperationalStatusInoperative)
+	request.Evse = &types.EVSE{ID: 1}
+	response, err := manager.OnChangeAvailability(request)
+	require.NoError(t, err)
+	assert.Equal(t, availability.ChangeAvailabilityStatusScheduled, response.Status)
+	assert.Equal(t, availability.OperationalStatusOperative, manager.OperationalStatus(1, 0))
+	// Station scope
+	response, err = manager.OnChangeAvailability(availability.NewChangeAvailabilityRequest(availability.OperationalStatusInoperative))
+	require.NoError(t, err)
+	assert.Equal(t, availability.ChangeAvailabilityStatusScheduled, response.Status)
+	assert.Empty(t, recorder.popNotifications())
+	// The EVSE change is applied once its transaction ended
+	manager.SetConnectorStatus(1, 1, availability.ConnectorStatusAvailable)
+	assert.Equal(t, []sentStatusNotification{{evseID: 1, connectorID: 1, status: availability.ConnectorStatusAvailable}}, recorder.popNotifications())
+	manager.SetEVSEBusy(1, false)
+	assert.Equal(t, []sentStatusNotification{{evseID: 1, connectorID: 1, status: availability.ConnectorStatusUnavailable}}, recorder.popNotifications())
+	assert.Equal(t, availability.OperationalStatusInoperative, manager.OperationalStatus(1, 0))
+	assert.Equal(t, availability.OperationalStatusOperative, manager.OperationalStatus(0, 0))
+	// The station change waits for all EVSEs
+	manager.SetConnectorStatus(2, 1, availability.ConnectorStatusAvailable)
+	recorder.popNotifications()
+	manager.SetEVSEBusy(2, false)
+	assert.Equal(t, availability.OperationalStatusInoperative, manager.OperationalStatus(0, 0))
+	assert.Equal(t, []sentStatusNotification{{evseID: 2, connectorID: 1, status: availability.ConnectorStatusUnavailable}}, recorder.popNotifications())
+	// Changing to operative is never scheduled, and replaces a scheduled change
+	_, err = manager.OnChangeAvailability(availability.NewChangeAvailabilityRequest(availability.OperationalStatusOperative))
+	require.NoError(t, err)
+	manager.SetEVSEBusy(2, true)
+	request = availability.NewChangeAvailabilityRequest(availability.OperationalStatusOperative)
+	request.Evse = &types.EVSE{ID: 2}
+	response, err = manager.OnChangeAvailability(request)
+	require.NoError(t, err)
+	assert.Equal(t, availability.ChangeAvailabilityStatusAccepted, response.Status)
+	request.OperationalStatus = availability.OperationalStatusInoperative
+	response, err = manager.OnChangeAvailability(request)
+	require.NoError(t, err)
+	assert.Equal(t, availability.ChangeAvailabilityStatusScheduled, response.Status)
+	request.OperationalStatus = availability.OperationalStatusOperative
+	_, err = manager.OnChangeAvailability(request)
+	require.NoError(t, err)
+	manager.SetEVSEBusy(2, false)
+	assert.Equal(t, availability.OperationalStatusOperative, manager.OperationalStatus(2, 0))
+}
@@ -0,0 +1,283 @@
+package ocpp2_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/events"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/availability"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/csms"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/diagnostics"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+func newTestRegistryReportData(component string, variable string, value string) provisioning.ReportData {
+	return provisioning.ReportData{
+		Component:         types.Component{Name: component},
+		Variable:          types.Variable{Name: variable},
+		VariableAttribute: []provisioning.VariableAttribute{{Type: types.AttributeActual, Value: value, Mutability: provisioning.MutabilityReadOnly}},
+	}
+}
+
+// Feeds a boot, report and transaction sequence of a charging station through the wrapped handlers.
+func bootTestRegistryStation(t *testing.T, registry *csms.StationRegistry, chargingStationID string, firmwareVersion string, timestamp time.Time) {
+	provisioningHandler := &MockCSMSProvisioningHandler{}
+	provisioningHandler.On("OnBootNotification", mock.Anything, mock.Anything).Return(provisioning.NewBootNotificationResponse(types.NewDateTime(timestamp), 60, provisioning.RegistrationStatusAccepted), nil)
+	provisioningHandler.On("OnNotifyReport", mock.Anything, mock.Anything).Return(provisioning.NewNotifyReportResponse(), nil)
+	wrapped := registry.WrapProvisioningHandler(provisioningHandler)
+	boot := provisioning.NewBootNotificationRequest(provisioning.BootReasonPowerUp, "AC-22", "ACME")
+	boot.ChargingStation.FirmwareVersion = firmwareVersion
+	boot.ChargingStation.SerialNumber = "SN-" + chargingStationID
+	_, err := wrapped.OnBootNotification(chargingStationID, boot)
+	require.NoError(t, err)
+	report := provisioning.NewNotifyReportRequest(1, types.NewDateTime(timestamp), 0)
+	report.Tbc = true
+	report.ReportData = []provisioning.ReportData{newTestRegistryReportData("SecurityCtrlr", "SecurityProfile", "1")}
+	_, err = wrapped.OnNotifyReport(chargingStationID, report)
+	require.NoError(t, err)
+	report = provisioning.NewNotifyReportRequest(1, types.NewDateTime(timestamp), 1)
+	report.ReportData = []provisioning.ReportData{
+		newTestRegistryReportData("OCPPCommCtrlr", "HeartbeatInterval", "60"),
+		newTestRegistryReportData("SecurityCtrlr", "SecurityProfile", "2"),
+	}
+	_, err = wrapped.OnNotifyReport(chargingStationID, report)
+	require.NoError(t, err)
+	provisioningHandler.AssertNumberOfCalls(t, "OnNotifyReport", 2)
+}
+
+func (suite *OcppV2TestSuite) TestStationRegistryModel() {
+	t := suite.T()
+	registry := csms.NewStationRegistry()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	registry.SetTimeSource(func() time.Time { return now })
+	var changes []csms.StationChange
+	unsubscribe := registry.Subscribe(func(change csms.StationChange) {
+		changes = append(changes, change)
+	})
+	bootTestRegistryStation(t, registry, "cs1", "1.9.2", now)
+	bootTestRegistryStation(t, registry, "cs2", "1.10.0", now)
+	bootTestRegistryStation(t, registry, "cs3", "2.0.0-rc1", now)
+	assert.Equal(t, []csms.StationChange{
+		{ChargingStationID: "cs1", Type: csms.ChangeConnected},
+		{ChargingStationID: "cs1", Type: csms.ChangeBoot},
+		{ChargingStationID: "cs1", Type: csms.ChangeDeviceModel},
+		{ChargingStationID: "cs1", Type: csms.ChangeDeviceModel},
+	}, changes[:4])
+	// Connector status and transactions
+	availabilityHandler := &MockCSMSAvailabilityHandler{}
+	availabilityHandler.On("OnStatusNotification", mock.Anything, mock.Anything).Return(availability.NewStatusNotificationResponse(), nil)
+	transactionsHandler := &MockCSMSTransactionsHandler{}
+	transactionsHandler.On("OnTransactionEvent", mock.Anything, mock.Anything).Return(transactions.NewTransactionEventResponse(), nil)
+	availabilityObserver := registry.WrapAvailabilityHandler(availabilityHandler)
+	transactionsObserver := registry.WrapTransactionsHandler(transactionsHandler)
+	_, err := availabilityObserver.OnStatusNotification("cs1", availability.NewStatusNotificationRequest(types.NewDateTime(now.Add(-time.Minute)), availability.ConnectorStatusAvailable, 1, 1))
+	require.NoError(t, err)
+	_, err = availabilityObserver.OnStatusNotification("cs1", availability.NewStatusNotificationRequest(types.NewDateTime(now), availability.ConnectorStatusOccupied, 1, 1))
+	require.NoError(t, err)
+	// A late notification doesn't overwrite the more recent status
+	_, err = availabilityObserver.OnStatusNotification("cs1", availability.NewStatusNotificationRequest(types.NewDateTime(now.Add(-2*time.Minute)), availability.ConnectorStatusFaulted, 1, 1))
+	require.NoError(t, err)
+	_, err = availabilityObserver.OnStatusNotification("cs1", availability.NewStatusNotificationRequest(types.NewDateTime(now), availability.ConnectorStatusAvailable, 2, 1))
+	require.NoError(t, err)
+	started := transactions.NewTransactionEventRequest(transactions.TransactionEventStarted, types.NewDateTime(now), transactions.TriggerReasonAuthorized, 0, transactions.Transaction{TransactionID: "tx-1"})
+	started.Evse = &types.EVSE{ID: 1, ConnectorID: newInt(1)}
+	started.IDToken = &types.IdToken{IdToken: "ABC123", Type: types.IdTokenTypeISO14443}
+	_, err = transactionsObserver.OnTransactionEvent("cs1", started)
+	require.NoError(t, err)
+	updated := transactions.NewTransactionEventRequest(transactions.TransactionEventUpdated, types.NewDateTime(now.Add(time.Minute)), transactions.TriggerReasonChargingStateChanged, 1, transactions.Transaction{TransactionID: "tx-1", ChargingState: transactions.ChargingStateCharging})
+	_, err = transactionsObserver.OnTransactionEvent("cs1", updated)
+	require.NoError(t, err)
+	other := transactions.NewTransactionEventRequest(transactions.TransactionEventStarted, types.NewDateTime(now), transactions.TriggerReasonAuthorized, 0, transactions.Transaction{TransactionID: "tx-2"})
+	other.Evse = &types.EVSE{ID: 1}
+	_, err = transactionsObserver.OnTransactionEvent("cs2", other)
+	require.NoError(t, err)
+	ended := transactions.NewTransactionEventRequest(transactions.TransactionEventEnded, types.NewDateTime(now.Add(time.Hour)), transactions.TriggerReasonEVCommunicationLost, 1, transactions.Transaction{TransactionID: "tx-2"})
+	_, err = transactionsObserver.OnTransactionEvent("cs2", ended)
+	require.NoError(t, err)
+	// A failing application handler prevents the request from being applied
+	failingHandler := &MockCSMSTransactionsHandler{}
+	failingHandler.On("OnTransactionEvent", mock.Anything, mock.Anything).Return(transactions.NewTransactionEventResponse(), errors.New("db unavailable"))
+	_, err = registry.WrapTransactionsHandler(failingHandler).OnTransactionEvent("cs3", other)
+	require.Error(t, err)
+	// Snapshot
+	snapshot, ok := registry.Station("cs1")
+	require.True(t, ok)
+	assert.True(t, snapshot.Connected)
+	require.NotNil(t, snapshot.Info)
+	assert.Equal(t, "ACME", snapshot.Info.VendorName)
+	assert.Equal(t, "SN-cs1", snapshot.Info.SerialNumber)
+	assert.Equal(t, provisioning.RegistrationStatusAccepted, snapshot.Info.Registration)
+	require.Len(t, snapshot.DeviceModel, 2)
+	value, ok := snapshot.Value("securityctrlr", "SecurityProfile")
+	require.True(t, ok)
+	assert.Equal(t, "2", value)
+	require.Len(t, snapshot.Connectors, 2)
+	assert.Equal(t, availability.ConnectorStatusOccupied, snapshot.Connectors[0].Status)
+	assert.Equal(t, 2, snapshot.Connectors[1].EvseID)
+	require.Len(t, snapshot.Transactions, 1)
+	tx := snapshot.Transactions[0]
+	assert.Equal(t, "tx-1", tx.TransactionID)
+	assert.Equal(t, 1, tx.EvseID)
+	assert.Equal(t, transactions.ChargingStateCharging, tx.ChargingState)
+	require.NotNil(t, tx.IDToken)
+	assert.Equal(t, "ABC123", tx.IDToken.IdToken)
+	snapshot, ok = registry.Station("cs2")
+	require.True(t, ok)
+	assert.Empty(t, snapshot.Transactions)
+	snapshot, _ = registry.Station("cs3")
+	assert.Empty(t, snapshot.Transactions)
+	// Queries
+	outdated := registry.Query(csms.FirmwareBefore("1.10"))
+	require.Len(t, outdated, 1)
+	assert.Equal(t, "cs1", outdated[0].ChargingStationID)
+	outdated = registry.Query(csms.FirmwareBefore("2.0.0"))
+	require.Len(t, outdated, 2)
+	assert.Equal(t, "cs2", outdated[1].ChargingStationID)
+	assert.Len(t, registry.Query(csms.VariableEquals("SecurityCtrlr", "SecurityProfile", "2")), 3)
+	assert.Empty(t, registry.Query(csms.VariableEquals("SecurityCtrlr", "SecurityProfile", "1")))
+	assert.Equal(t, []string{"cs1", "cs2", "cs3"}, registry.Stations())
+	// Export
+	data, err := registry.ExportJSON("cs1")
+	require.NoError(t, err)
+	var exported map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &exported))
+	assert.Equal(t, "cs1", exported["chargingStationId"])
+	assert.Len(t, exported["deviceModel"], 2)
+	assert.Len(t, exported["transactions"], 1)
+	_, err = registry.ExportJSON("unknown")
+	assert.Error(t, err)
+	// No more changes are delivered after unsubscribing
+	unsubscribe()
+	count := len(changes)
+	registry.OnStationDisconnected("cs1")
+	assert.Len(t, changes, count)
+}
+
+func (suite *OcppV2TestSuite) TestStationRegistryAlerts() {
+	t := suite.T()
+	registry := csms.NewStationRegistry()
+	diagnosticsHandler := &MockCSMSDiagnosticsHandler{}
+	diagnosticsHandler.On("OnNotifyEvent", mock.Anything, mock.Anything).Return(diagnostics.NewNotifyEventResponse(), nil)
+	observer := registry.WrapDiagnosticsHandler(diagnosticsHandler)
+	now := time.Now()
+	overheating := diagnostics.EventData{
+		EventID:               1,
+		Timestamp:             types.NewDateTime(now),
+		Trigger:               diagnostics.EventTriggerAlerting,
+		ActualValue:           "85",
+		VariableMonitoringID:  newInt(3),
+		EventNotificationType: diagnostics.EventHardWiredMonitor,
+		Component:             types.Component{Name: "TemperatureSensor", EVSE: &types.EVSE{ID: 1}},
+		Variable:              types.Variable{Name: "Temperature"},
+	}
+	doorOpen := diagnostics.EventData{
+		EventID:               2,
+		Timestamp:             types.NewDateTime(now.Add(time.Second)),
+		Trigger:               diagnostics.EventTriggerDelta,
+		ActualValue:           "true",
+		EventNotificationType: diagnostics.EventHardWiredNotification,
+		Component:             types.Component{Name: "AccessBarrier"},
+		Variable:              types.Variable{Name: "Enabled"},
+	}
+	_, err := observer.OnNotifyEvent("cs1", diagnostics.NewNotifyEventRequest(types.NewDateTime(now), 0, []diagnostics.EventData{overheating, doorOpen}))
+	require.NoError(t, err)
+	snapshot, _ := registry.Station("cs1")
+	require.Len(t, snapshot.Alerts, 2)
+	assert.Equal(t, 1, snapshot.Alerts[0].EventID)
+	assert.Equal(t, "85", snapshot.Alerts[0].ActualValue)
+	// Clearing an alert
+	cleared := overheating
+	cleared.EventID = 3
+	cleared.ActualValue = "60"
+	cleared.Cleared = true
+	_, err = observer.OnNotifyEvent("cs1", diagnostics.NewNotifyEventRequest(types.NewDateTime(now), 1, []diagnostics.EventData{cleared}))
+	require.NoError(t, err)
+	snapshot, _ = registry.Station("cs1")
+	require.Len(t, snapshot.Alerts, 1)
+	assert.Equal(t, 2, snapshot.Alerts[0].EventID)
+}
+
+func (suite *OcppV2TestSuite) TestStationRegistryEviction() {
+	t := suite.T()
+	registry := csms.NewStationRegistry()
+	registry.SetEvictionTimeout(50 * time.Millisecond)
+	changeC := make(chan csms.StationChange, 10)
+	registry.Subscribe(func(change csms.StationChange) {
+		changeC <- change
+	})
+	registry.OnStationConnected("cs1")
+	registry.OnBootNotification("cs1", provisioning.NewBootNotificationRequest(provisioning.BootReasonPowerUp, "AC-22", "ACME"), nil)
+	assert.Equal(t, csms.ChangeConnected, (<-changeC).Type)
+	assert.Equal(t, csms.ChangeBoot, (<-changeC).Type)
+	// Reconnecting in time cancels the eviction
+	registry.OnStationDisconnected("cs1")
+	assert.Equal(t, csms.ChangeDisconnected, (<-changeC).Type)
+	snapshot, ok := registry.Station("cs1")
+	require.True(t, ok)
+	assert.False(t, snapshot.Connected)
+	assert.NotNil(t, snapshot.DisconnectedAt)
+	registry.OnStationConnected("cs1")
+	assert.Equal(t, csms.ChangeConnected, (<-changeC).Type)
+	time.Sleep(100 * time.Millisecond)
+	_, ok = registry.Station("cs1")
+	assert.True(t, ok)
+	// The model is discarded once the timeout expired
+	registry.OnStationDisconnected("cs1")
+	assert.Equal(t, csms.ChangeDisconnected, (<-changeC).Type)
+	select {
+	case change := <-changeC:
+		assert.Equal(t, csms.StationChange{ChargingStationID: "cs1", Type: csms.ChangeEvicted}, change)
+	case <-time.After(time.Second):
+		require.Fail(t, "station not evicted")
+	}
+	_, ok = registry.Station("cs1")
+	assert.False(t, ok)
+	assert.Empty(t, registry.Stations())
+}
+
+func (suite *OcppV2TestSuite) TestStationRegistryEvents() {
+	t := suite.T()
+	registry := csms.NewStationRegistry()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	registry.SetTimeSource(func() time.Time { return now })
+	bus := events.NewBus(10)
+	eventC, unsubscribe := bus.Subscribe(events.BootAccepted, events.TransactionStarted, events.TransactionEnded, events.ConnectorStatusChanged)
+	defer unsubscribe()
+	registry.SetEventBus(bus)
+	registry.OnBootNotification("cs1", provisioning.NewBootNotificationRequest(provisioning.BootReasonPowerUp, "AC-22", "ACME"), provisioning.NewBootNotificationResponse(types.NewDateTime(now), 60, provisioning.RegistrationStatusPending))
+	registry.OnBootNotification("cs1", provisioning.NewBootNotificationRequest(provisioning.BootReasonPowerUp, "AC-22", "ACME"), provisioning.NewBootNotificationResponse(types.NewDateTime(now), 60, provisioning.RegistrationStatusAccepted))
+	registry.OnStatusNotification("cs1", availability.NewStatusNotificationRequest(types.NewDateTime(now), availability.ConnectorStatusAvailable, 1, 1))
+	// Neither an unchanged status, nor a late notification is published
+	registry.OnStatusNotification("cs1", availability.NewStatusNotificationRequest(types.NewDateTime(now), availability.ConnectorStatusAvailable, 1, 1))
+	registry.OnStatusNotification("cs1", availability.NewStatusNotificationRequest(types.NewDateTime(now.Add(-time.Minute)), availability.ConnectorStatusFaulted, 1, 1))
+	started := transactions.NewTransactionEventRequest(transactions.TransactionEventStarted, types.NewDateTime(now), transactions.TriggerReasonAuthorized, 0, transactions.Transaction{TransactionID: "tx-1"})
+	started.Evse = &types.EVSE{ID: 1, ConnectorID: newInt(1)}
+	registry.OnTransactionEvent("cs1", started)
+	updated := transactions.NewTransactionEventRequest(transactions.TransactionEventUpdated, types.NewDateTime(now), transactions.TriggerReasonChargingStateChanged, 1, transactions.Transaction{TransactionID: "tx-1", ChargingState: transactions.ChargingStateCharging})
+	registry.OnTransactionEvent("cs1", updated)
+	ended := transactions.NewTransactionEventRequest(transactions.TransactionEventEnded, types.NewDateTime(now), transactions.TriggerReasonEVDeparted, 2, transactions.Transaction{TransactionID: "tx-1"})
+	registry.OnTransactionEvent("cs1", ended)
+	registry.OnTransactionEvent("cs1", ended)
+	require.Len(t, eventC, 4)
+	assert.Equal(t, events.BootAcceptedEvent{StationID: "cs1", Interval: time.Minute}, <-eventC)
+	assert.Equal(t, events.ConnectorStatusChangedEvent{StationID: "cs1", EvseID: 1, ConnectorID: 1, Status: string(availability.ConnectorStatusAvailable)}, <-eventC)
+	assert.Equal(t, events.TransactionStartedEvent{StationID: "cs1", TransactionID: "tx-1", EvseID: 1}, <-eventC)
+	assert.Equal(t, events.TransactionEndedEvent{StationID: "cs1", TransactionID: "tx-1", EvseID: 1}, <-eventC)
+}
+
+func (suite *OcppV2TestSuite) TestCompareVersions() {
+	t := suite.T()
+	assert.Equal(t, -1, csms.CompareVersions("1.9.2", "1.10"))
+	assert.Equal(t, 1, csms.CompareVersions("1.10", "1.9.2"))
+	assert.Equal(t, 0, csms.CompareVersions("2.0.1", "2.0.1"))
+	assert.Equal(t, -1, csms.CompareVersions("2.0", "2.0.1"))
+	assert.Equal(t, -1, csms.CompareVersions("2.0.0-beta", "2.0.0-rc1"))
+}
@@ -0,0 +1,174 @@
+package ocpp2_test
+
+import (
+	"errors"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/remotecontrol"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/reservation"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/smartcharging"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// A station with an AC EVSE offering a Type 2 socket and a domestic socket, and a power-limited EVSE with a Type 2 cable.
+const dualEVSETopology = `{
+	"nominalVoltage": 230,
+	"evses": [
+		{"id": 2, "maxPower": 11000, "connectors": [{"id": 1, "type": "cType2", "phases": 3, "phaseRotation": "RST", "maxCurrent": 32}]},
+		{"id": 1, "maxCurrent": 32, "connectors": [
+			{"id": 2, "type": "sCEE-7-7", "phases": 1, "maxCurrent": 16},
+			{"id": 1, "type": "sType2", "phases": 3, "phaseRotation": "TRS"}
+		]}
+	]
+}`
+
+func (suite *OcppV2TestSuite) TestStationTopology() {
+	t := suite.T()
+	topology, err := types.LoadStationTopology([]byte(dualEVSETopology))
+	require.NoError(t, err)
+	// EVSEs and connectors are sorted
+	assert.Equal(t, []int{1, 2}, topology.EVSEIDs())
+	assert.Equal(t, map[int][]types.ConnectorType{
+		1: {types.ConnectorTypeSType2, types.ConnectorTypeCEE77},
+		2: {types.ConnectorTypeCType2},
+	}, topology.ConnectorTypes())
+	assert.Equal(t, "", topology.ValidateConnector(1, 2))
+	assert.Equal(t, "", topology.ValidateConnector(2, 0))
+	assert.Equal(t, "UnknownConnectorId", topology.ValidateConnector(2, 2))
+	assert.Equal(t, "UnknownEvse", topology.ValidateConnector(3, 0))
+	// Limits
+	current, ok := topology.MaxCurrentForEVSE(1, types.ChargingRateUnitAmperes)
+	require.True(t, ok)
+	assert.Equal(t, 32.0, current)
+	power, ok := topology.MaxCurrentForEVSE(1, types.ChargingRateUnitWatts)
+	require.True(t, ok)
+	assert.Equal(t, 32.0*230*3, power)
+	power, ok = topology.MaxCurrentForEVSE(2, types.ChargingRateUnitWatts)
+	require.True(t, ok)
+	assert.Equal(t, 11000.0, power)
+	current, ok = topology.MaxCurrentForEVSE(2, types.ChargingRateUnitAmperes)
+	require.True(t, ok)
+	assert.InDelta(t, 11000.0/(230*3), current, 0.001)
+	_, ok = topology.MaxCurrentForEVSE(3, types.ChargingRateUnitAmperes)
+	assert.False(t, ok)
+	// Composite schedule setup
+	composite, err := smartcharging.TopologyForEVSE(topology, 2)
+	require.NoError(t, err)
+	assert.Equal(t, 230.0, composite.Voltage)
+	assert.Equal(t, 3, composite.Phases)
+	assert.Equal(t, 2, composite.EVSECount)
+	assert.InDelta(t, 11000.0/(230*3), composite.MaxCurrent, 0.001)
+	composite, err = smartcharging.TopologyForEVSE(topology, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 32.0, composite.MaxCurrent)
+	_, err = smartcharging.TopologyForEVSE(topology, 3)
+	assert.ErrorIs(t, err, smartcharging.ErrInvalidTopology)
+}
+
+func (suite *OcppV2TestSuite) TestStationTopologyConnectorNumbering16() {
+	t := suite.T()
+	topology, err := types.LoadStationTopology([]byte(dualEVSETopology))
+	require.NoError(t, err)
+	assert.Equal(t, 3, topology.NumConnectors())
+	var testTable = []struct {
+		connectorID     int
+		evseID          int
+		evseConnectorID int
+		ok              bool
+	}{
+		{0, 0, 0, true},
+		{1, 1, 1, true},
+		{2, 1, 2, true},
+		{3, 2, 1, true},
+		{4, 0, 0, false},
+		{-1, 0, 0, false},
+	}
+	for _, tc := range testTable {
+		evseID, evseConnectorID, ok := topology.EVSEForConnector(tc.connectorID)
+		assert.Equal(t, tc.ok, ok, tc.connectorID)
+		assert.Equal(t, tc.evseID, evseID, tc.connectorID)
+		assert.Equal(t, tc.evseConnectorID, evseConnectorID, tc.connectorID)
+		if tc.ok && tc.connectorID > 0 {
+			connectorID, ok := topology.ConnectorForEVSE(evseID, evseConnectorID)
+			assert.True(t, ok)
+			assert.Equal(t, tc.connectorID, connectorID)
+		}
+	}
+	_, ok := topology.ConnectorForEVSE(2, 2)
+	assert.False(t, ok)
+}
+
+func (suite *OcppV2TestSuite) TestStationTopologyValidation() {
+	t := suite.T()
+	connector := types.TopologyConnector{ID: 1, Type: types.ConnectorTypeSType2, Phases: 3}
+	_, err := types.NewStationTopology(types.TopologyEVSE{ID: 1, Connectors: []types.TopologyConnector{connector}})
+	require.NoError(t, err)
+	var testTable = []struct {
+		name  string
+		evses []types.TopologyEVSE
+	}{
+		{"no EVSEs", nil},
+		{"no connectors", []types.TopologyEVSE{{ID: 1}}},
+		{"invalid EVSE id", []types.TopologyEVSE{{ID: 0, Connectors: []types.TopologyConnector{connector}}}},
+		{"duplicate EVSE", []types.TopologyEVSE{{ID: 1, Connectors: []types.TopologyConnector{connector}}, {ID: 1, Connectors: []types.TopologyConnector{connector}}}},
+		{"duplicate connector", []types.TopologyEVSE{{ID: 1, Connectors: []types.TopologyConnector{connector, connector}}}},
+		{"invalid connector type", []types.TopologyEVSE{{ID: 1, Connectors: []types.TopologyConnector{{ID: 1, Type: "Type2", Phases: 3}}}}},
+		{"invalid phases", []types.TopologyEVSE{{ID: 1, Connectors: []types.TopologyConnector{{ID: 1, Type: types.ConnectorTypeSType2, Phases: 4}}}}},
+		{"invalid phase rotation", []types.TopologyEVSE{{ID: 1, Connectors: []types.TopologyConnector{{ID: 1, Type: types.ConnectorTypeSType2, Phases: 3, PhaseRotation: "RRR"}}}}},
+		{"negative limit", []types.TopologyEVSE{{ID: 1, MaxPower: -1, Connectors: []types.TopologyConnector{connector}}}},
+	}
+	for _, tc := range testTable {
+		_, err := types.NewStationTopology(tc.evses...)
+		assert.True(t, errors.Is(err, types.ErrInvalidStationTopology), tc.name)
+	}
+	_, err = types.LoadStationTopology([]byte(`{"evses": 1}`))
+	assert.ErrorIs(t, err, types.ErrInvalidStationTopology)
+}
+
+func (suite *OcppV2TestSuite) TestStationTopologyManagers() {
+	t := suite.T()
+	topology, err := types.LoadStationTopology([]byte(dualEVSETopology))
+	require.NoError(t, err)
+	// Reservations
+	reservations, _ := newTestReservationManager(t)
+	reservations.SetTopology(topology)
+	request := newTestReserveNowRequest(1, time.Hour, "1234")
+	request.EvseID = newInt(2)
+	request.ConnectorType = reservation.ConnectorTypeCEE77
+	response, err := reservations.OnReserveNow(request)
+	require.NoError(t, err)
+	assert.Equal(t, reservation.ReserveNowStatusRejected, response.Status)
+	request.EvseID = nil
+	response, err = reservations.OnReserveNow(request)
+	require.NoError(t, err)
+	assert.Equal(t, reservation.ReserveNowStatusAccepted, response.Status)
+	// Reset
+	resets := provisioning.NewResetManager(nil, func(evseID *int, resetType provisioning.ResetType) {})
+	resets.SetTopology(topology)
+	reset := provisioning.NewResetRequest(provisioning.ResetTypeOnIdle)
+	reset.EvseID = newInt(2)
+	resetResponse, err := resets.OnReset(reset)
+	require.NoError(t, err)
+	assert.Equal(t, provisioning.ResetStatusAccepted, resetResponse.Status)
+	reset.EvseID = newInt(3)
+	resetResponse, err = resets.OnReset(reset)
+	require.NoError(t, err)
+	assert.Equal(t, provisioning.ResetStatusRejected, resetResponse.Status)
+	// Remote trigger
+	responder := remotecontrol.NewAutoResponder201(nil)
+	responder.SetTopology(topology)
+	responder.Register(remotecontrol.MessageTriggerStatusNotification, func(evse *types.EVSE) error { return nil })
+	trigger := remotecontrol.NewTriggerMessageRequest(remotecontrol.MessageTriggerStatusNotification)
+	trigger.Evse = &types.EVSE{ID: 1, ConnectorID: newInt(2)}
+	triggerResponse, err := responder.OnTriggerMessage(trigger)
+	require.NoError(t, err)
+	assert.Equal(t, remotecontrol.TriggerMessageStatusAccepted, triggerResponse.Status)
+	trigger.Evse = &types.EVSE{ID: 2, ConnectorID: newInt(2)}
+	triggerResponse, err = responder.OnTriggerMessage(trigger)
+	require.NoError(t, err)
+	assert.Equal(t, remotecontrol.TriggerMessageStatusRejected, triggerResponse.Status)
+}
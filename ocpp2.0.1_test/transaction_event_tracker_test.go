@@ -0,0 +1,166 @@
+package ocpp2_test
+
+import (
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/clock/clocktest"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+func newTestEventTracker(retention time.Duration, gapTimeout time.Duration) (*transactions.EventTracker, chan transactions.TransactionState, chan *transactions.TransactionAnomaly) {
+	completedC := make(chan transactions.TransactionState, 10)
+	anomalyC := make(chan *transactions.TransactionAnomaly, 10)
+	tracker := transactions.NewEventTracker(func(state transactions.TransactionState) {
+		completedC <- state
+	}, func(anomaly *transactions.TransactionAnomaly) {
+		anomalyC <- anomaly
+	})
+	tracker.SetTimeouts(retention, gapTimeout)
+	return tracker, completedC, anomalyC
+}
+
+func transactionEvent(eventType transactions.TransactionEvent, seqNo int, chargingState transactions.ChargingState, energy float64) *transactions.TransactionEventRequest {
+	request := transactions.NewTransactionEventRequest(eventType, types.NewDateTime(time.Now()), transactions.TriggerReasonMeterValuePeriodic, seqNo, transactions.Transaction{
+		TransactionID: "tx1",
+		ChargingState: chargingState,
+	})
+	request.MeterValue = []types.MeterValue{{Timestamp: *types.NewDateTime(time.Now()), SampledValue: []types.SampledValue{{Value: energy}}}}
+	return request
+}
+
+func meterReadings(state transactions.TransactionState) []float64 {
+	var readings []float64
+	for _, meterValue := range state.MeterValues {
+		readings = append(readings, meterValue.SampledValue[0].Value)
+	}
+	return readings
+}
+
+func (suite *OcppV2TestSuite) TestEventTrackerInOrder() {
+	t := suite.T()
+	tracker, completedC, anomalyC := newTestEventTracker(time.Second, time.Second)
+	require.NoError(t, tracker.Add("cs1", transactionEvent(transactions.TransactionEventStarted, 0, transactions.ChargingStateEVConnected, 0)))
+	require.NoError(t, tracker.Add("cs1", transactionEvent(transactions.TransactionEventUpdated, 1, transactions.ChargingStateCharging, 10)))
+	state, ok := tracker.TransactionState("cs1", "tx1")
+	require.True(t, ok)
+	assert.True(t, state.Started)
+	assert.False(t, state.Ended)
+	assert.Equal(t, transactions.ChargingStateCharging, state.ChargingState)
+	assert.Equal(t, []float64{0, 10}, meterReadings(state))
+	ended := transactionEvent(transactions.TransactionEventEnded, 2, transactions.ChargingStateIdle, 20)
+	ended.TransactionInfo.StoppedReason = transactions.ReasonEVDisconnected
+	require.NoError(t, tracker.Add("cs1", ended))
+	state = <-completedC
+	assert.True(t, state.Completed)
+	assert.Equal(t, transactions.ReasonEVDisconnected, state.StoppedReason)
+	assert.Equal(t, []float64{0, 10, 20}, meterReadings(state))
+	assert.Empty(t, state.MissingSeqNos)
+	assert.Len(t, anomalyC, 0)
+	// Same transactionId on another station is a separate transaction
+	require.NoError(t, tracker.Add("cs2", transactionEvent(transactions.TransactionEventStarted, 0, "", 0)))
+	state, ok = tracker.TransactionState("cs2", "tx1")
+	require.True(t, ok)
+	assert.False(t, state.Completed)
+}
+
+func (suite *OcppV2TestSuite) TestEventTrackerOfflineOutOfOrder() {
+	t := suite.T()
+	tracker, completedC, anomalyC := newTestEventTracker(time.Second, time.Second)
+	// Queued events are replayed, with Ended received before Started
+	events := []*transactions.TransactionEventRequest{
+		transactionEvent(transactions.TransactionEventEnded, 3, transactions.ChargingStateIdle, 30),
+		transactionEvent(transactions.TransactionEventUpdated, 1, transactions.ChargingStateCharging, 10),
+		transactionEvent(transactions.TransactionEventStarted, 0, transactions.ChargingStateEVConnected, 0),
+		transactionEvent(transactions.TransactionEventUpdated, 2, transactions.ChargingStateSuspendedEV, 20),
+	}
+	for i, event := range events {
+		event.Offline = true
+		require.NoError(t, tracker.Add("cs1", event))
+		if i < len(events)-1 {
+			assert.Len(t, completedC, 0)
+		}
+	}
+	state := <-completedC
+	assert.True(t, state.Offline)
+	assert.Equal(t, 4, state.ReceivedEvents)
+	assert.Equal(t, transactions.ChargingStateIdle, state.ChargingState)
+	assert.Equal(t, []float64{0, 10, 20, 30}, meterReadings(state))
+	assert.Len(t, anomalyC, 0)
+}
+
+func (suite *OcppV2TestSuite) TestEventTrackerDuplicateSeqNo() {
+	t := suite.T()
+	tracker, completedC, anomalyC := newTestEventTracker(time.Second, time.Second)
+	require.NoError(t, tracker.Add("cs1", transactionEvent(transactions.TransactionEventStarted, 0, "", 0)))
+	require.NoError(t, tracker.Add("cs1", transactionEvent(transactions.TransactionEventUpdated, 1, "", 10)))
+	err := tracker.Add("cs1", transactionEvent(transactions.TransactionEventUpdated, 1, "", 10))
+	require.Error(t, err)
+	anomaly, ok := err.(*transactions.TransactionAnomaly)
+	require.True(t, ok)
+	assert.Equal(t, transactions.AnomalyDuplicateSeqNo, anomaly.Type)
+	assert.Equal(t, 1, anomaly.SeqNo)
+	assert.Equal(t, anomaly, <-anomalyC)
+	// Duplicates are not accounted for
+	state, _ := tracker.TransactionState("cs1", "tx1")
+	assert.Equal(t, []float64{0, 10}, meterReadings(state))
+	// A second Started event is out of sequence
+	err = tracker.Add("cs1", transactionEvent(transactions.TransactionEventStarted, 2, "", 0))
+	assert.Equal(t, transactions.AnomalyOutOfSequence, err.(*transactions.TransactionAnomaly).Type)
+	<-anomalyC
+	require.NoError(t, tracker.Add("cs1", transactionEvent(transactions.TransactionEventEnded, 2, "", 20)))
+	<-completedC
+	// Events after completion are ignored
+	err = tracker.Add("cs1", transactionEvent(transactions.TransactionEventUpdated, 3, "", 30))
+	assert.Equal(t, transactions.AnomalyAfterCompletion, err.(*transactions.TransactionAnomaly).Type)
+	<-anomalyC
+}
+
+func (suite *OcppV2TestSuite) TestEventTrackerSequenceGap() {
+	t := suite.T()
+	tracker, completedC, anomalyC := newTestEventTracker(time.Second, 50*time.Millisecond)
+	require.NoError(t, tracker.Add("cs1", transactionEvent(transactions.TransactionEventUpdated, 2, "", 20)))
+	require.NoError(t, tracker.Add("cs1", transactionEvent(transactions.TransactionEventEnded, 4, "", 40)))
+	state, _ := tracker.TransactionState("cs1", "tx1")
+	assert.Equal(t, []int{0, 1, 3}, state.MissingSeqNos)
+	select {
+	case state = <-completedC:
+	case <-time.After(time.Second):
+		t.Fatal("gap timeout not triggered")
+	}
+	assert.True(t, state.Completed)
+	assert.False(t, state.Started)
+	assert.Equal(t, []int{0, 1, 3}, state.MissingSeqNos)
+	require.Len(t, anomalyC, 2)
+	assert.Equal(t, transactions.AnomalyMissingStarted, (<-anomalyC).Type)
+	anomaly := <-anomalyC
+	assert.Equal(t, transactions.AnomalySequenceGap, anomaly.Type)
+	assert.Equal(t, []int{0, 1, 3}, anomaly.MissingSeqNos)
+}
+
+func (suite *OcppV2TestSuite) TestEventTrackerRetention() {
+	t := suite.T()
+	tracker, completedC, _ := newTestEventTracker(time.Minute, time.Second)
+	fakeClock := clocktest.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	tracker.SetClock(fakeClock)
+	require.NoError(t, tracker.Add("cs1", transactionEvent(transactions.TransactionEventStarted, 0, "", 0)))
+	require.NoError(t, tracker.Add("cs1", transactionEvent(transactions.TransactionEventEnded, 1, "", 10)))
+	<-completedC
+	fakeClock.Advance(59 * time.Second)
+	_, ok := tracker.TransactionState("cs1", "tx1")
+	assert.True(t, ok)
+	fakeClock.Advance(time.Second)
+	_, ok = tracker.TransactionState("cs1", "tx1")
+	assert.False(t, ok)
+	// Unfinished transactions are kept until removed
+	require.NoError(t, tracker.Add("cs1", transactionEvent(transactions.TransactionEventStarted, 0, "", 0)))
+	fakeClock.Advance(time.Hour)
+	_, ok = tracker.TransactionState("cs1", "tx1")
+	assert.True(t, ok)
+	tracker.Remove("cs1", "tx1")
+	_, ok = tracker.TransactionState("cs1", "tx1")
+	assert.False(t, ok)
+}
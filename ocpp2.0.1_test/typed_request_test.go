@@ -0,0 +1,106 @@
+package ocpp2_test
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	ocpp2 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/availability"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+func (suite *OcppV2TestSuite) TestTypedSendFromChargingStation() {
+	t := suite.T()
+	wsId := "test_id"
+	wsUrl := "someUrl"
+	currentTime := types.NewDateTime(time.Now())
+	channel := NewMockWebSocket(wsId)
+
+	handler := &MockCSMSAvailabilityHandler{}
+	handler.On("OnHeartbeat", mock.AnythingOfType("string"), mock.Anything).Return(availability.NewHeartbeatResponse(*currentTime), nil)
+	handler.On("OnStatusNotification", mock.AnythingOfType("string"), mock.Anything).Return(availability.NewStatusNotificationResponse(), nil)
+	setupDefaultCSMSHandlers(suite, expectedCSMSOptions{clientId: wsId, forwardWrittenMessage: true}, handler)
+	setupDefaultChargingStationHandlers(suite, expectedChargingStationOptions{serverUrl: wsUrl, clientId: wsId, createChannelOnStart: true, channel: channel, forwardWrittenMessage: true})
+	// Run Test
+	suite.csms.Start(8887, "somePath")
+	err := suite.chargingStation.Start(wsUrl)
+	require.Nil(t, err)
+	ctx := context.Background()
+	heartbeat, err := ocpp2.Send[*availability.HeartbeatResponse](ctx, suite.chargingStation, availability.NewHeartbeatRequest())
+	require.NoError(t, err)
+	require.NotNil(t, heartbeat)
+	assertDateTimeEquality(t, currentTime, &heartbeat.CurrentTime)
+	statusNotification, err := ocpp2.Send[*availability.StatusNotificationResponse](ctx, suite.chargingStation, availability.NewStatusNotificationRequest(types.NewDateTime(time.Now()), availability.ConnectorStatusAvailable, 1, 1))
+	require.NoError(t, err)
+	assert.NotNil(t, statusNotification)
+	// Wrong type parameter
+	statusNotification, err = ocpp2.Send[*availability.StatusNotificationResponse](ctx, suite.chargingStation, availability.NewHeartbeatRequest())
+	require.Error(t, err)
+	assert.Nil(t, statusNotification)
+	typeErr, ok := err.(*ocpp.ResponseTypeError)
+	require.True(t, ok)
+	assert.Equal(t, reflect.TypeOf(&availability.StatusNotificationResponse{}), typeErr.Expected)
+	assert.Equal(t, reflect.TypeOf(&availability.HeartbeatResponse{}), typeErr.Actual)
+}
+
+func (suite *OcppV2TestSuite) TestTypedSendToChargingStation() {
+	t := suite.T()
+	wsId := "test_id"
+	wsUrl := "someUrl"
+	channel := NewMockWebSocket(wsId)
+
+	handler := &MockChargingStationProvisioningHandler{}
+	handler.On("OnReset", mock.Anything).Return(provisioning.NewResetResponse(provisioning.ResetStatusScheduled), nil)
+	setupDefaultCSMSHandlers(suite, expectedCSMSOptions{clientId: wsId, forwardWrittenMessage: true})
+	setupDefaultChargingStationHandlers(suite, expectedChargingStationOptions{serverUrl: wsUrl, clientId: wsId, createChannelOnStart: true, channel: channel, forwardWrittenMessage: true}, handler)
+	// Run Test
+	suite.csms.Start(8887, "somePath")
+	err := suite.chargingStation.Start(wsUrl)
+	require.Nil(t, err)
+	ctx := context.Background()
+	reset, err := ocpp2.SendTo[*provisioning.ResetResponse](ctx, suite.csms, wsId, provisioning.NewResetRequest(provisioning.ResetTypeOnIdle))
+	require.NoError(t, err)
+	require.NotNil(t, reset)
+	assert.Equal(t, provisioning.ResetStatusScheduled, reset.Status)
+	// Typed callback
+	resultC := make(chan *provisioning.ResetResponse, 1)
+	err = suite.csms.SendRequestAsync(wsId, provisioning.NewResetRequest(provisioning.ResetTypeImmediate), ocpp.TypedCallback(func(response *provisioning.ResetResponse, err error) {
+		assert.NoError(t, err)
+		resultC <- response
+	}))
+	require.NoError(t, err)
+	response := <-resultC
+	require.NotNil(t, response)
+	assert.Equal(t, provisioning.ResetStatusScheduled, response.Status)
+	// Wrong type parameter
+	getVariables, err := ocpp2.SendTo[*provisioning.GetVariablesResponse](ctx, suite.csms, wsId, provisioning.NewResetRequest(provisioning.ResetTypeOnIdle))
+	require.Error(t, err)
+	assert.Nil(t, getVariables)
+	assert.IsType(t, &ocpp.ResponseTypeError{}, err)
+}
+
+func (suite *OcppV2TestSuite) TestTypedSendToContextCanceled() {
+	t := suite.T()
+	wsId := "test_id"
+	wsUrl := "someUrl"
+	channel := NewMockWebSocket(wsId)
+	// The charging station never replies
+	setupDefaultCSMSHandlers(suite, expectedCSMSOptions{clientId: wsId, forwardWrittenMessage: false})
+	setupDefaultChargingStationHandlers(suite, expectedChargingStationOptions{serverUrl: wsUrl, clientId: wsId, createChannelOnStart: true, channel: channel, forwardWrittenMessage: true})
+	// Run Test
+	suite.csms.Start(8887, "somePath")
+	err := suite.chargingStation.Start(wsUrl)
+	require.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	reset, err := ocpp2.SendTo[*provisioning.ResetResponse](ctx, suite.csms, wsId, provisioning.NewResetRequest(provisioning.ResetTypeOnIdle))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Nil(t, reset)
+}
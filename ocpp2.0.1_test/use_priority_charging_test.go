@@ -0,0 +1,92 @@
+package ocpp2_test
+
+import (
+	"fmt"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/remotecontrol"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// Test
+func (suite *OcppV2TestSuite) TestUsePriorityChargingRequestValidation() {
+	t := suite.T()
+	var requestTable = []GenericTestEntry{
+		{remotecontrol.UsePriorityChargingRequest{TransactionID: "1234", Activate: true}, true},
+		{remotecontrol.UsePriorityChargingRequest{TransactionID: "1234"}, true},
+		{remotecontrol.UsePriorityChargingRequest{Activate: true}, false},
+		{remotecontrol.UsePriorityChargingRequest{}, false},
+		{remotecontrol.UsePriorityChargingRequest{TransactionID: ">36..................................", Activate: true}, false},
+	}
+	ExecuteGenericTestTable(t, requestTable)
+}
+
+func (suite *OcppV2TestSuite) TestUsePriorityChargingResponseValidation() {
+	t := suite.T()
+	var responseTable = []GenericTestEntry{
+		{remotecontrol.UsePriorityChargingResponse{Status: remotecontrol.PriorityChargingStatusAccepted, StatusInfo: &types.StatusInfo{ReasonCode: "200"}}, true},
+		{remotecontrol.UsePriorityChargingResponse{Status: remotecontrol.PriorityChargingStatusRejected}, true},
+		{remotecontrol.UsePriorityChargingResponse{Status: remotecontrol.PriorityChargingStatusNoProfile}, true},
+		{remotecontrol.UsePriorityChargingResponse{}, false},
+		{remotecontrol.UsePriorityChargingResponse{Status: "invalidPriorityChargingStatus"}, false},
+		{remotecontrol.UsePriorityChargingResponse{Status: remotecontrol.PriorityChargingStatusAccepted, StatusInfo: &types.StatusInfo{}}, false},
+	}
+	ExecuteGenericTestTable(t, responseTable)
+}
+
+func (suite *OcppV2TestSuite) TestUsePriorityChargingE2EMocked() {
+	t := suite.T()
+	wsId := "test_id"
+	messageId := defaultMessageId
+	wsUrl := "someUrl"
+	transactionID := "1234"
+	activate := false
+	status := remotecontrol.PriorityChargingStatusAccepted
+	statusInfo := types.NewStatusInfo("200", "")
+	// The activate flag is required, so it is sent even if false
+	requestJson := fmt.Sprintf(`[2,"%v","%v",{"transactionId":"%v","activate":%v}]`,
+		messageId, remotecontrol.UsePriorityChargingFeatureName, transactionID, activate)
+	responseJson := fmt.Sprintf(`[3,"%v",{"status":"%v","statusInfo":{"reasonCode":"%v"}}]`,
+		messageId, status, statusInfo.ReasonCode)
+	usePriorityChargingResponse := remotecontrol.NewUsePriorityChargingResponse(status)
+	usePriorityChargingResponse.StatusInfo = statusInfo
+	channel := NewMockWebSocket(wsId)
+
+	handler := &MockChargingStationRemoteControlHandler{}
+	handler.On("OnUsePriorityCharging", mock.Anything).Return(usePriorityChargingResponse, nil).Run(func(args mock.Arguments) {
+		request, ok := args.Get(0).(*remotecontrol.UsePriorityChargingRequest)
+		require.True(t, ok)
+		assert.Equal(t, transactionID, request.TransactionID)
+		assert.Equal(t, activate, request.Activate)
+	})
+	setupDefaultCSMSHandlers(suite, expectedCSMSOptions{clientId: wsId, rawWrittenMessage: []byte(requestJson), forwardWrittenMessage: true})
+	setupDefaultChargingStationHandlers(suite, expectedChargingStationOptions{serverUrl: wsUrl, clientId: wsId, createChannelOnStart: true, channel: channel, rawWrittenMessage: []byte(responseJson), forwardWrittenMessage: true}, handler)
+	// Run Test
+	suite.csms.Start(8887, "somePath")
+	err := suite.chargingStation.Start(wsUrl)
+	require.Nil(t, err)
+	resultChannel := make(chan bool, 1)
+	err = suite.csms.UsePriorityCharging(wsId, func(response *remotecontrol.UsePriorityChargingResponse, err error) {
+		require.Nil(t, err)
+		require.NotNil(t, response)
+		assert.Equal(t, status, response.Status)
+		assert.Equal(t, statusInfo.ReasonCode, response.StatusInfo.ReasonCode)
+		resultChannel <- true
+	}, transactionID, activate)
+	require.Nil(t, err)
+	result := <-resultChannel
+	assert.True(t, result)
+}
+
+func (suite *OcppV2TestSuite) TestUsePriorityChargingInvalidEndpoint() {
+	messageId := defaultMessageId
+	transactionID := "1234"
+	activate := true
+	request := remotecontrol.NewUsePriorityChargingRequest(transactionID, activate)
+	requestJson := fmt.Sprintf(`[2,"%v","%v",{"transactionId":"%v","activate":%v}]`,
+		messageId, remotecontrol.UsePriorityChargingFeatureName, transactionID, activate)
+	testUnsupportedRequestFromChargingStation(suite, request, requestJson, messageId)
+}
@@ -0,0 +1,150 @@
+package ocppj
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+)
+
+// Unknown actions are counted individually up to this amount. All further ones are counted as OtherUnknownAction,
+// to keep the memory used by peers sending arbitrary actions bounded.
+const maxUnknownActions = 100
+
+// OtherUnknownAction is the key under which unknown actions are counted, once maxUnknownActions distinct ones were received.
+const OtherUnknownAction = "(other)"
+
+// Maximum edit distance between an unknown action and a supported one, for the latter to be suggested.
+const maxSuggestionDistance = 2
+
+// UnknownActionError is the cause of the error returned for a request, whose action isn't supported by any
+// registered profile. It is accessible via errors.As.
+type UnknownActionError struct {
+	Action string
+	// The supported action the peer most likely meant, e.g. BootNotification for Bootnotification.
+	// Empty if no similar action exists, or if suggestions are disabled.
+	Suggestion string
+	// The key the action was counted under
+	counted string
+}
+
+func (e *UnknownActionError) Error() string {
+	if e.Suggestion != "" {
+		return fmt.Sprintf("unsupported feature %v, did you mean %v?", e.Action, e.Suggestion)
+	}
+	return fmt.Sprintf("unsupported feature %v", e.Action)
+}
+
+// UnknownActionCollector may additionally be implemented by a MetricsCollector,
+// to be notified of every request received with an unsupported action.
+//
+// The action of the passed info is the counted key, hence it is OtherUnknownAction once too many distinct actions were received.
+type UnknownActionCollector interface {
+	UnknownAction(info SpanInfo)
+}
+
+// SetActionSuggestions enables or disables suggestions for unsupported actions.
+//
+// When enabled (the default), the description of the error returned for an unsupported action names the supported
+// action the peer most likely meant, e.g. "Unsupported feature Bootnotification, did you mean BootNotification?".
+// Disabling suggestions keeps descriptions strictly as defined, e.g. for certification runs.
+func (endpoint *Endpoint) SetActionSuggestions(enabled bool) {
+	endpoint.noActionSuggestions = !enabled
+}
+
+// UnknownActions returns how often requests with each unsupported action were received.
+func (endpoint *Endpoint) UnknownActions() map[string]uint64 {
+	endpoint.unknownActionsMutex.Lock()
+	defer endpoint.unknownActionsMutex.Unlock()
+	counts := make(map[string]uint64, len(endpoint.unknownActions))
+	for action, count := range endpoint.unknownActions {
+		counts[action] = count
+	}
+	return counts
+}
+
+// Counts an unsupported action and returns the key it was counted under.
+func (endpoint *Endpoint) countUnknownAction(action string) string {
+	endpoint.unknownActionsMutex.Lock()
+	defer endpoint.unknownActionsMutex.Unlock()
+	if endpoint.unknownActions == nil {
+		endpoint.unknownActions = map[string]uint64{}
+	}
+	if _, ok := endpoint.unknownActions[action]; !ok && len(endpoint.unknownActions) >= maxUnknownActions {
+		action = OtherUnknownAction
+	}
+	endpoint.unknownActions[action]++
+	return action
+}
+
+// Returns the error for a request with an unsupported action.
+func (endpoint *Endpoint) unknownActionError(action string, uniqueId string) *ocpp.Error {
+	cause := &UnknownActionError{Action: action, counted: endpoint.countUnknownAction(action)}
+	description := fmt.Sprintf("Unsupported feature %v", action)
+	if !endpoint.noActionSuggestions {
+		cause.Suggestion = endpoint.suggestAction(action)
+	}
+	if cause.Suggestion != "" {
+		description = fmt.Sprintf("%v, did you mean %v?", description, cause.Suggestion)
+	}
+	return ocpp.NewError(NotSupported, description, uniqueId).WithCause(cause)
+}
+
+// Returns the supported action most similar to the passed one. Actions differing only in case are preferred,
+// followed by the ones with the smallest edit distance. Returns an empty string, if no action is similar enough.
+func (endpoint *Endpoint) suggestAction(action string) string {
+	var features []string
+	for _, profile := range endpoint.Profiles {
+		for name := range profile.Features {
+			features = append(features, name)
+		}
+	}
+	sort.Strings(features)
+	lower := strings.ToLower(action)
+	suggestion := ""
+	bestDistance := maxSuggestionDistance + 1
+	for _, feature := range features {
+		if strings.EqualFold(feature, action) {
+			return feature
+		}
+		distance := editDistance(lower, strings.ToLower(feature))
+		// Short actions need a proportionally closer match
+		if distance < bestDistance && distance*2 < len(action) {
+			suggestion = feature
+			bestDistance = distance
+		}
+	}
+	return suggestion
+}
+
+// Returns the Levenshtein distance between a and b.
+func editDistance(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min3(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
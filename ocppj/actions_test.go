@@ -0,0 +1,113 @@
+package ocppj_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+)
+
+func parseUnknownAction(endpoint *ocppj.Client, action string) (*ocpp.Error, *ocppj.UnknownActionError) {
+	message, err := endpoint.ParseMessage([]interface{}{float64(ocppj.CALL), "1234", action, map[string]interface{}{}}, endpoint.RequestState)
+	if message != nil || err == nil {
+		return nil, nil
+	}
+	ocppErr, _ := err.(*ocpp.Error)
+	var unknown *ocppj.UnknownActionError
+	errors.As(err, &unknown)
+	return ocppErr, unknown
+}
+
+func TestUnknownActionSuggestions(t *testing.T) {
+	endpoint := ocppj.NewClient("cp1", &MockWebsocketClient{}, nil, nil, core.Profile)
+	endpoint.SetDialect(ocpp.V16)
+	for _, tc := range []struct {
+		action     string
+		suggestion string
+	}{
+		{"Bootnotification", core.BootNotificationFeatureName},    // Case mismatch
+		{"BOOTNOTIFICATION", core.BootNotificationFeatureName},    // Case mismatch
+		{"MeterValue", core.MeterValuesFeatureName},               // Near miss
+		{"StartTransction", core.StartTransactionFeatureName},     // Near miss
+		{"ChangeAvailabilty", core.ChangeAvailabilityFeatureName}, // Near miss
+		{"SomethingCompletelyDifferent", ""},                      // Unknown
+		{"Rest", core.ResetFeatureName},                           // Near miss
+		{"Rst", ""},                                               // Too short for a near miss
+	} {
+		ocppErr, unknown := parseUnknownAction(endpoint, tc.action)
+		require.NotNil(t, ocppErr, tc.action)
+		require.NotNil(t, unknown, tc.action)
+		assert.Equal(t, ocppj.NotSupported, ocppErr.Code, tc.action)
+		assert.Equal(t, tc.action, unknown.Action)
+		assert.Equal(t, tc.suggestion, unknown.Suggestion, tc.action)
+		if tc.suggestion != "" {
+			assert.Equal(t, fmt.Sprintf("Unsupported feature %v, did you mean %v?", tc.action, tc.suggestion), ocppErr.Description)
+		} else {
+			assert.Equal(t, fmt.Sprintf("Unsupported feature %v", tc.action), ocppErr.Description)
+		}
+	}
+	// Suggestions may be disabled
+	endpoint.SetActionSuggestions(false)
+	ocppErr, unknown := parseUnknownAction(endpoint, "Bootnotification")
+	require.NotNil(t, unknown)
+	assert.Empty(t, unknown.Suggestion)
+	assert.Equal(t, "Unsupported feature Bootnotification", ocppErr.Description)
+	// Every unknown action is counted
+	counts := endpoint.UnknownActions()
+	assert.Equal(t, uint64(2), counts["Bootnotification"])
+	assert.Equal(t, uint64(1), counts["MeterValue"])
+	assert.Len(t, counts, 8)
+}
+
+func TestUnknownActionCountLimit(t *testing.T) {
+	endpoint := ocppj.NewClient("cp1", &MockWebsocketClient{}, nil, nil, core.Profile)
+	endpoint.SetDialect(ocpp.V16)
+	for i := 0; i < 110; i++ {
+		parseUnknownAction(endpoint, fmt.Sprintf("Unknown%d", i))
+	}
+	parseUnknownAction(endpoint, "Unknown0")
+	counts := endpoint.UnknownActions()
+	assert.Len(t, counts, 101)
+	assert.Equal(t, uint64(2), counts["Unknown0"])
+	assert.Equal(t, uint64(10), counts[ocppj.OtherUnknownAction])
+}
+
+type unknownActionCollector struct {
+	recordingCollector
+	infos chan ocppj.SpanInfo
+}
+
+func (c *unknownActionCollector) UnknownAction(info ocppj.SpanInfo) {
+	c.infos <- info
+}
+
+func (suite *OcppJTestSuite) TestServerUnknownAction() {
+	t := suite.T()
+	collector := &unknownActionCollector{infos: make(chan ocppj.SpanInfo, 1)}
+	suite.centralSystem.SetMetricsCollector(collector)
+	mockChargePointId := "1234"
+	channel := NewMockWebSocket(mockChargePointId)
+	writeC := make(chan string, 1)
+	suite.mockServer.On("Start", mock.AnythingOfType("int"), mock.AnythingOfType("string")).Return()
+	suite.mockServer.On("Write", mockChargePointId, mock.Anything).Run(func(args mock.Arguments) {
+		writeC <- string(args.Get(1).([]byte))
+	}).Return(nil)
+	suite.centralSystem.Start(8887, "somePath")
+	suite.mockServer.NewClientHandler(channel)
+	// The suggestion is sent back to the client and reported locally
+	err := suite.mockServer.MessageHandler(channel, []byte(`[2,"5678","mock",{"mockValue":"someValue"}]`))
+	require.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "did you mean Mock?"))
+	assert.Equal(t, `[4,"5678","NotSupported","Unsupported feature mock, did you mean Mock?",{}]`, <-writeC)
+	info := <-collector.infos
+	assert.Equal(t, ocppj.SpanInfo{Kind: ocppj.SpanKindIncoming, ClientID: mockChargePointId, Action: "mock", UniqueID: "5678"}, info)
+	assert.Equal(t, map[string]uint64{"mock": 1}, suite.centralSystem.UnknownActions())
+}
@@ -0,0 +1,191 @@
+package ocppj
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/logging"
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+)
+
+// The maximum amount of audit entries, which may be waiting for delivery to the audit handler.
+// Entries exceeding this capacity are dropped.
+const auditQueueCapacity = 256
+
+// AuditEntry is a record of a single message received from a client.
+//
+// An entry is created for every inbound frame, regardless of whether it could be parsed or validated.
+// For incoming requests, the entry is delivered once a response (or error) was sent back to the client,
+// or once the client disconnected without receiving a response.
+type AuditEntry struct {
+	ClientID       string        // The ID of the client, which sent the message.
+	Timestamp      time.Time     // The time at which the message was received.
+	MessageType    MessageType   // The type of the message. Zero if the frame couldn't be parsed.
+	Action         string        // The action of the message, if known. For responses, this is the action of the original request.
+	UniqueID       string        // The unique message ID, if known.
+	RawMessage     []byte        // The raw message, as received over the network.
+	ParseError     *ocpp.Error   // The error that occurred while parsing or validating the message. Nil if the message was valid.
+	Response       ocpp.Response // The response that was sent to the client. Only set for incoming requests.
+	ResponseError  *ocpp.Error   // The error that was sent to the client, if any.
+	HandlerLatency time.Duration // The time elapsed between the reception of a request and the reply being sent.
+}
+
+type auditKey struct {
+	clientID string
+	uniqueID string
+}
+
+// auditor delivers audit entries to a handler asynchronously, without ever blocking the caller.
+type auditor struct {
+	handler func(entry AuditEntry)
+	logger  func() logging.Logger
+	now     func() time.Time
+	entryC  chan AuditEntry
+	pending map[auditKey]AuditEntry
+	dropped uint64
+	mutex   sync.Mutex
+}
+
+func newAuditor(handler func(entry AuditEntry), logger func() logging.Logger, now func() time.Time) *auditor {
+	a := &auditor{
+		handler: handler,
+		logger:  logger,
+		now:     now,
+		entryC:  make(chan AuditEntry, auditQueueCapacity),
+		pending: map[auditKey]AuditEntry{},
+	}
+	go a.run()
+	return a
+}
+
+func (a *auditor) run() {
+	for entry := range a.entryC {
+		a.handler(entry)
+	}
+}
+
+func (a *auditor) close() {
+	close(a.entryC)
+}
+
+// Queues an entry for delivery. If the queue is full, the entry is dropped.
+func (a *auditor) emit(entry AuditEntry) {
+	if a == nil {
+		return
+	}
+	select {
+	case a.entryC <- entry:
+	default:
+		atomic.AddUint64(&a.dropped, 1)
+		a.logger().With(logging.ChargePointID(entry.ClientID), logging.UniqueID(entry.UniqueID)).Errorf("audit queue full, dropping entry for message %v from %v", entry.UniqueID, entry.ClientID)
+	}
+}
+
+// Holds an entry for an incoming request, until a reply is sent to the client.
+func (a *auditor) await(entry AuditEntry) {
+	if a == nil {
+		return
+	}
+	key := auditKey{clientID: entry.ClientID, uniqueID: entry.UniqueID}
+	a.mutex.Lock()
+	previous, exists := a.pending[key]
+	a.pending[key] = entry
+	a.mutex.Unlock()
+	if exists {
+		// Duplicate message ID, the previous request will never be matched to a reply
+		a.emit(previous)
+	}
+}
+
+// Completes the pending entry for a request, with the reply sent to the client.
+func (a *auditor) complete(clientID string, uniqueID string, response ocpp.Response, responseErr *ocpp.Error) {
+	if a == nil {
+		return
+	}
+	key := auditKey{clientID: clientID, uniqueID: uniqueID}
+	a.mutex.Lock()
+	entry, ok := a.pending[key]
+	delete(a.pending, key)
+	a.mutex.Unlock()
+	if !ok {
+		return
+	}
+	entry.Response = response
+	entry.ResponseError = responseErr
+	entry.HandlerLatency = a.now().Sub(entry.Timestamp)
+	a.emit(entry)
+}
+
+// Delivers all pending entries for a client, which will never be matched to a reply.
+func (a *auditor) flushClient(clientID string) {
+	if a == nil {
+		return
+	}
+	var entries []AuditEntry
+	a.mutex.Lock()
+	for key, entry := range a.pending {
+		if key.clientID == clientID {
+			entries = append(entries, entry)
+			delete(a.pending, key)
+		}
+	}
+	a.mutex.Unlock()
+	for _, entry := range entries {
+		a.emit(entry)
+	}
+}
+
+func (a *auditor) droppedEntries() uint64 {
+	if a == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&a.dropped)
+}
+
+// Creates an audit entry for a raw message, filling all fields that can be inferred without parsing the payload.
+func (s *Server) newAuditEntry(clientID string, received time.Time, data []byte, arr []interface{}) AuditEntry {
+	entry := AuditEntry{ClientID: clientID, Timestamp: received, RawMessage: data}
+	if len(arr) < 2 {
+		return entry
+	}
+	if typeId, ok := arr[0].(float64); ok {
+		entry.MessageType = MessageType(typeId)
+	}
+	entry.UniqueID, _ = arr[1].(string)
+	switch entry.MessageType {
+	case CALL:
+		if len(arr) > 2 {
+			entry.Action, _ = arr[2].(string)
+		}
+	case CALL_RESULT, CALL_ERROR:
+		if request, ok := s.RequestState.GetClientState(clientID).GetPendingRequest(entry.UniqueID); ok {
+			entry.Action = request.GetFeatureName()
+		}
+	}
+	return entry
+}
+
+// Registers a handler, receiving an audit entry for every message received from any client.
+//
+// Entries are created for all inbound frames, including unparseable or invalid messages and
+// requests for unsupported actions. Entries for requests contain the reply sent to the client and the handler latency.
+//
+// Entries are delivered asynchronously on a dedicated goroutine, hence the handler never blocks message processing.
+// If the handler cannot keep up, excess entries are dropped. Refer to DroppedAuditEntries.
+//
+// Passing a nil handler disables auditing. The handler must be set before starting the server.
+func (s *Server) SetAuditHandler(handler func(entry AuditEntry)) {
+	if s.audit != nil {
+		s.audit.close()
+		s.audit = nil
+	}
+	if handler != nil {
+		s.audit = newAuditor(handler, s.getLogger, s.now)
+	}
+}
+
+// Returns the amount of audit entries, which were dropped because the audit handler couldn't keep up.
+func (s *Server) DroppedAuditEntries() uint64 {
+	return s.audit.droppedEntries()
+}
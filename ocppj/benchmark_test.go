@@ -0,0 +1,186 @@
+package ocppj_test
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/lorenzodonini/ocpp-go/ws"
+	"github.com/lorenzodonini/ocpp-go/ws/wstest"
+)
+
+func BenchmarkCallMarshal(b *testing.B) {
+	call := &ocppj.Call{MessageTypeId: ocppj.CALL, UniqueId: "1234", Action: core.BootNotificationFeatureName, Payload: core.NewBootNotificationRequest("model1", "vendor1")}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := call.MarshalJSON(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCallResultMarshal(b *testing.B) {
+	callResult := &ocppj.CallResult{MessageTypeId: ocppj.CALL_RESULT, UniqueId: "1234", Payload: core.NewBootNotificationConfirmation(types.NewDateTime(time.Now()), 60, core.RegistrationStatusAccepted)}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := callResult.MarshalJSON(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCallParse(b *testing.B) {
+	endpoint := ocppj.Endpoint{}
+	endpoint.SetDialect(ocpp.V16)
+	endpoint.AddProfile(core.Profile)
+	data := []byte(`[2,"1234","BootNotification",{"chargePointModel":"model1","chargePointVendor":"vendor1"}]`)
+	state := ocppj.NewClientState()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		arr, err := ocppj.ParseRawJsonMessage(data)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err = endpoint.ParseMessage(arr, state); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// Measures a full request/response roundtrip, initiated by the server, over the in-memory transport.
+func BenchmarkServerDispatch(b *testing.B) {
+	network := wstest.NewServer()
+	network.AddSupportedSubprotocol(types.V16Subprotocol)
+	server := ocppj.NewServer(network, nil, nil, core.Profile)
+	server.SetDialect(ocpp.V16)
+	responseC := make(chan struct{}, 1)
+	server.SetResponseHandler(func(client ws.Channel, response ocpp.Response, requestId string) {
+		responseC <- struct{}{}
+	})
+	wsClient := wstest.NewClient(network)
+	wsClient.SetRequestedSubProtocol(types.V16Subprotocol)
+	var client *ocppj.Client
+	client = ocppj.NewClient("cp1", wsClient, nil, nil, core.Profile)
+	client.SetDialect(ocpp.V16)
+	client.SetRequestHandler(func(request ocpp.Request, requestId string, action string) {
+		if err := client.SendResponse(requestId, core.NewDataTransferConfirmation(core.DataTransferStatusAccepted)); err != nil {
+			b.Error(err)
+		}
+	})
+	if err := wstest.ConnectInMemory(network, server, client); err != nil {
+		b.Fatal(err)
+	}
+	defer server.Stop()
+	defer client.Stop()
+	request := core.NewDataTransferRequest("vendor1")
+	request.Data = "someData"
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := server.SendRequest("cp1", request); err != nil {
+			b.Fatal(err)
+		}
+		<-responseC
+	}
+}
+
+// Measures the latency of requests sent by a quiet client, while another client floods the server with requests
+// taking a while to handle. The 99th percentile is reported as p99-µs, and should be close to the one measured without flood.
+func BenchmarkServerQuietClientLatency(b *testing.B) {
+	b.Run("NoFlood", func(b *testing.B) {
+		benchmarkQuietClientLatency(b, false, 16, 0)
+	})
+	b.Run("Sync", func(b *testing.B) {
+		benchmarkQuietClientLatency(b, true, 0, 0)
+	})
+	b.Run("Inbox", func(b *testing.B) {
+		benchmarkQuietClientLatency(b, true, 16, 0)
+	})
+	b.Run("InboxMaxWorkers", func(b *testing.B) {
+		benchmarkQuietClientLatency(b, true, 16, 4)
+	})
+}
+
+func benchmarkQuietClientLatency(b *testing.B, flooded bool, inboxSize int, maxWorkers int) {
+	network := wstest.NewServer()
+	network.AddSupportedSubprotocol(types.V16Subprotocol)
+	server := ocppj.NewServer(network, nil, nil, core.Profile)
+	server.SetDialect(ocpp.V16)
+	server.SetClientInbox(inboxSize, maxWorkers)
+	confirmation := core.NewDataTransferConfirmation(core.DataTransferStatusAccepted)
+	server.SetRequestHandler(func(client ws.Channel, request ocpp.Request, requestId string, action string) {
+		if client.ID() == "flood" {
+			time.Sleep(100 * time.Microsecond)
+		}
+		_ = server.SendResponse(client.ID(), requestId, confirmation)
+	})
+	wsClient := wstest.NewClient(network)
+	wsClient.SetRequestedSubProtocol(types.V16Subprotocol)
+	client := ocppj.NewClient("quiet", wsClient, nil, nil, core.Profile)
+	client.SetDialect(ocpp.V16)
+	responseC := make(chan struct{}, 1)
+	client.SetResponseHandler(func(response ocpp.Response, requestId string) {
+		responseC <- struct{}{}
+	})
+	if err := wstest.ConnectInMemory(network, server, client); err != nil {
+		b.Fatal(err)
+	}
+	defer server.Stop()
+	defer client.Stop()
+	// The flooding client writes bursts of raw requests, without waiting for the single responses
+	const burst = 50
+	flood := wstest.NewClient(network)
+	flood.SetRequestedSubProtocol(types.V16Subprotocol)
+	floodResponseC := make(chan struct{}, burst)
+	flood.SetMessageHandler(func(data []byte) error {
+		floodResponseC <- struct{}{}
+		return nil
+	})
+	if err := flood.Start(wstest.URL + "/flood"); err != nil {
+		b.Fatal(err)
+	}
+	defer flood.Stop()
+	stopC := make(chan struct{})
+	floodDoneC := make(chan struct{})
+	go func() {
+		defer close(floodDoneC)
+		for id := 0; flooded; {
+			for i := 0; i < burst; i++ {
+				id++
+				if err := flood.Write([]byte(fmt.Sprintf(`[2,"%v","DataTransfer",{"vendorId":"vendor1"}]`, id))); err != nil {
+					return
+				}
+			}
+			for i := 0; i < burst; i++ {
+				select {
+				case <-floodResponseC:
+				case <-stopC:
+					return
+				}
+			}
+		}
+	}()
+	defer func() {
+		close(stopC)
+		<-floodDoneC
+	}()
+	request := core.NewDataTransferRequest("vendor1")
+	latencies := make([]time.Duration, 0, b.N)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		start := time.Now()
+		if err := client.SendRequest(request); err != nil {
+			b.Fatal(err)
+		}
+		<-responseC
+		latencies = append(latencies, time.Since(start))
+	}
+	b.StopTimer()
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	b.ReportMetric(float64(latencies[len(latencies)*99/100].Microseconds()), "p99-µs")
+}
@@ -2,6 +2,7 @@ package ocppj_test
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
 	"sync"
@@ -34,6 +35,7 @@ func (suite *OcppJTestSuite) TestServerNotStartedError() {
 	req := newMockRequest("somevalue")
 	err := suite.centralSystem.SendRequest(mockChargePointId, req)
 	require.Error(t, err, "ocppj server is not started, couldn't send request")
+	assert.True(t, errors.Is(err, ocppj.ErrNotStarted))
 	assert.False(t, suite.serverDispatcher.IsRunning())
 }
 
@@ -175,6 +177,7 @@ func (suite *OcppJTestSuite) TestServerSendInvalidCall() {
 	suite.centralSystem.Profiles = []*ocpp.Profile{}
 	err := suite.centralSystem.SendRequest(mockChargePointId, mockRequest)
 	assert.Error(suite.T(), err, fmt.Sprintf("Couldn't create Call for unsupported action %v", mockRequest.GetFeatureName()))
+	assert.True(suite.T(), errors.Is(err, ocppj.ErrUnsupportedFeature))
 }
 
 func (suite *OcppJTestSuite) TestCentralSystemSendRequestFailed() {
@@ -453,6 +456,41 @@ func (suite *OcppJTestSuite) TestCentralSystemRequestHandler() {
 	assert.Nil(t, err)
 }
 
+func (suite *OcppJTestSuite) TestCentralSystemAuditHandlerDropsEntries() {
+	t := suite.T()
+	mockChargePointId := "1234"
+	blockC := make(chan struct{})
+	deliveredC := make(chan ocppj.AuditEntry, 1000)
+	suite.centralSystem.SetAuditHandler(func(entry ocppj.AuditEntry) {
+		<-blockC
+		deliveredC <- entry
+	})
+	suite.mockServer.On("Start", mock.AnythingOfType("int"), mock.AnythingOfType("string")).Return()
+	suite.centralSystem.Start(8887, "somePath")
+	suite.serverDispatcher.CreateClient(mockChargePointId)
+	channel := NewMockWebSocket(mockChargePointId)
+	// Responses to unknown requests are discarded, but audited nonetheless
+	total := 300
+	for i := 0; i < total; i++ {
+		err := suite.mockServer.MessageHandler(channel, []byte(fmt.Sprintf(`[3,"%v",{}]`, i)))
+		require.NoError(t, err)
+	}
+	dropped := suite.centralSystem.DroppedAuditEntries()
+	assert.Greater(t, dropped, uint64(0))
+	close(blockC)
+	delivered := 0
+	for delivered+int(dropped) < total {
+		select {
+		case entry := <-deliveredC:
+			assert.Equal(t, ocppj.CALL_RESULT, entry.MessageType)
+			delivered++
+		case <-time.After(time.Second):
+			t.Fatalf("only %v entries delivered, %v dropped", delivered, dropped)
+		}
+	}
+	assert.Equal(t, total, delivered+int(dropped))
+}
+
 func (suite *OcppJTestSuite) TestCentralSystemConfirmationHandler() {
 	t := suite.T()
 	mockChargePointId := "1234"
@@ -508,6 +546,19 @@ func (suite *OcppJTestSuite) TestCentralSystemErrorHandler() {
 	assert.Nil(t, err)
 }
 
+type nonCancelingDispatcher struct {
+	ocppj.ServerDispatcher
+}
+
+func (suite *OcppJTestSuite) TestCentralSystemCancelRequestUnsupported() {
+	t := suite.T()
+	dispatcher := &nonCancelingDispatcher{ServerDispatcher: ocppj.NewDefaultServerDispatcher(ocppj.NewFIFOQueueMap(queueCapacity))}
+	server := ocppj.NewServer(suite.mockServer, dispatcher, nil)
+	err := server.CancelRequest("1234", "5678")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not supported")
+}
+
 func addMockPendingRequest(suite *OcppJTestSuite, mockRequest ocpp.Request, mockUniqueID string, mockChargePointID string) {
 	mockCall, _ := suite.centralSystem.CreateCall(mockRequest)
 	mockCall.UniqueId = mockUniqueID
@@ -610,6 +661,7 @@ func (suite *OcppJTestSuite) TestRequestQueueFull() {
 	err := suite.centralSystem.SendRequest(mockChargePointId, req)
 	require.NotNil(t, err)
 	assert.Equal(t, "request queue is full, cannot push new element", err.Error())
+	assert.True(t, errors.Is(err, ocppj.ErrQueueFull))
 }
 
 func (suite *OcppJTestSuite) TestParallelRequests() {
@@ -2,6 +2,7 @@ package ocppj_test
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
 	"sync"
@@ -13,6 +14,7 @@ import (
 
 	"github.com/lorenzodonini/ocpp-go/ocpp"
 	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/lorenzodonini/ocpp-go/ws"
 )
 
 // ----------------- Start tests -----------------
@@ -44,6 +46,7 @@ func (suite *OcppJTestSuite) TestClientNotStartedError() {
 	err := suite.chargePoint.SendRequest(req)
 	require.NotNil(t, err)
 	assert.Equal(t, "ocppj client is not started, couldn't send request", err.Error())
+	assert.True(t, errors.Is(err, ocppj.ErrNotStarted))
 	require.True(t, suite.clientRequestQueue.IsEmpty())
 }
 
@@ -89,6 +92,24 @@ func (suite *OcppJTestSuite) TestChargePointSendInvalidRequest() {
 	require.NotNil(suite.T(), err)
 }
 
+func (suite *OcppJTestSuite) TestChargePointSendRequestFiltered() {
+	suite.mockClient.On("Write", mock.Anything).Return(nil)
+	suite.mockClient.On("Start", mock.AnythingOfType("string")).Return(nil)
+	_ = suite.chargePoint.Start("someUrl")
+	filterErr := errors.New("not permitted")
+	suite.chargePoint.SetRequestFilter(func(request ocpp.Request) error {
+		if request.(*MockRequest).MockValue == "blocked" {
+			return filterErr
+		}
+		return nil
+	})
+	err := suite.chargePoint.SendRequest(newMockRequest("blocked"))
+	assert.ErrorIs(suite.T(), err, filterErr)
+	suite.mockClient.AssertNotCalled(suite.T(), "Write", mock.Anything)
+	err = suite.chargePoint.SendRequest(newMockRequest("mockValue"))
+	assert.Nil(suite.T(), err)
+}
+
 func (suite *OcppJTestSuite) TestChargePointSendRequestNoValidation() {
 	suite.mockClient.On("Write", mock.Anything).Return(nil)
 	suite.mockClient.On("Start", mock.AnythingOfType("string")).Return(nil)
@@ -176,6 +197,7 @@ func (suite *OcppJTestSuite) TestChargePointSendInvalidCall() {
 	suite.chargePoint.Profiles = []*ocpp.Profile{}
 	err := suite.chargePoint.SendRequest(mockRequest)
 	assert.Error(suite.T(), err, fmt.Sprintf("Couldn't create Call for unsupported action %v", mockRequest.GetFeatureName()))
+	assert.True(suite.T(), errors.Is(err, ocppj.ErrUnsupportedFeature))
 }
 
 func (suite *OcppJTestSuite) TestChargePointSendRequestFailed() {
@@ -503,6 +525,7 @@ func (suite *OcppJTestSuite) TestClientRequestQueueFull() {
 	err = suite.chargePoint.SendRequest(req)
 	require.NotNil(t, err)
 	assert.Equal(t, "request queue is full, cannot push new element", err.Error())
+	assert.True(t, errors.Is(err, ocppj.ErrQueueFull))
 }
 
 func (suite *OcppJTestSuite) TestClientParallelRequests() {
@@ -824,3 +847,94 @@ func (suite *OcppJTestSuite) TestStopDisconnectedClient() {
 	assert.True(t, suite.clientDispatcher.IsPaused())
 	assert.False(t, suite.chargePoint.IsConnected())
 }
+
+// ----------------- Response watchdog tests -----------------
+
+func (suite *OcppJTestSuite) TestChargePointResponseWatchdogTriggered() {
+	t := suite.T()
+	maxSilence := 100 * time.Millisecond
+	suite.mockClient.On("Start", mock.AnythingOfType("string")).Return(nil)
+	suite.mockClient.On("IsConnected").Return(true)
+	// Server never answers
+	suite.mockClient.On("Write", mock.Anything).Return(nil)
+	reconnectC := make(chan error, 1)
+	suite.mockClient.On("ForceReconnect", mock.Anything).Return().Run(func(args mock.Arguments) {
+		reconnectC <- args.Error(0)
+	})
+	triggeredC := make(chan time.Duration, 1)
+	suite.chargePoint.SetOnResponseWatchdogTriggered(func(silence time.Duration) {
+		triggeredC <- silence
+	})
+	suite.chargePoint.EnableResponseWatchdog(maxSilence)
+	defer suite.chargePoint.EnableResponseWatchdog(0)
+	err := suite.chargePoint.Start("someUrl")
+	require.NoError(t, err)
+	err = suite.chargePoint.SendRequest(newMockRequest("somevalue"))
+	require.NoError(t, err)
+	select {
+	case reason := <-reconnectC:
+		assert.Error(t, reason)
+	case <-time.After(time.Second):
+		t.Fatal("watchdog didn't force a reconnection")
+	}
+	select {
+	case silence := <-triggeredC:
+		assert.GreaterOrEqual(t, silence, maxSilence)
+	case <-time.After(time.Second):
+		t.Fatal("watchdog handler wasn't invoked")
+	}
+}
+
+// Hides the optional ws.Reconnector interface of the wrapped websocket client.
+type nonReconnectingClient struct {
+	ws.WsClient
+}
+
+func (suite *OcppJTestSuite) TestChargePointReconnectUnsupported() {
+	t := suite.T()
+	client := ocppj.NewClient("id", &nonReconnectingClient{WsClient: suite.mockClient}, nil, nil)
+	// Websocket clients without support for forced reconnections are left untouched
+	client.Reconnect(errors.New("no response"))
+	suite.mockClient.AssertNotCalled(t, "ForceReconnect", mock.Anything)
+}
+
+func (suite *OcppJTestSuite) TestChargePointResponseWatchdogIdle() {
+	t := suite.T()
+	maxSilence := 50 * time.Millisecond
+	suite.mockClient.On("Start", mock.AnythingOfType("string")).Return(nil)
+	suite.mockClient.On("IsConnected").Return(true)
+	suite.mockClient.On("ForceReconnect", mock.Anything).Return()
+	suite.chargePoint.EnableResponseWatchdog(maxSilence)
+	defer suite.chargePoint.EnableResponseWatchdog(0)
+	err := suite.chargePoint.Start("someUrl")
+	require.NoError(t, err)
+	// No requests are sent, so the watchdog should never trigger
+	time.Sleep(4 * maxSilence)
+	suite.mockClient.AssertNotCalled(t, "ForceReconnect", mock.Anything)
+}
+
+func (suite *OcppJTestSuite) TestChargePointResponseWatchdogResponseReceived() {
+	t := suite.T()
+	maxSilence := 100 * time.Millisecond
+	suite.mockClient.On("Start", mock.AnythingOfType("string")).Return(nil)
+	suite.mockClient.On("IsConnected").Return(true)
+	suite.mockClient.On("ForceReconnect", mock.Anything).Return()
+	suite.mockClient.On("Write", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		var call []interface{}
+		err := json.Unmarshal(args.Get(0).([]byte), &call)
+		require.NoError(t, err)
+		// Server answers promptly
+		go func() {
+			err := suite.mockClient.MessageHandler([]byte(fmt.Sprintf(`[3,"%v",{"mockValue":"someValue"}]`, call[1])))
+			assert.NoError(t, err)
+		}()
+	})
+	suite.chargePoint.EnableResponseWatchdog(maxSilence)
+	defer suite.chargePoint.EnableResponseWatchdog(0)
+	err := suite.chargePoint.Start("someUrl")
+	require.NoError(t, err)
+	err = suite.chargePoint.SendRequest(newMockRequest("somevalue"))
+	require.NoError(t, err)
+	time.Sleep(3 * maxSilence)
+	suite.mockClient.AssertNotCalled(t, "ForceReconnect", mock.Anything)
+}
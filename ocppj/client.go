@@ -1,10 +1,15 @@
 package ocppj
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"gopkg.in/go-playground/validator.v9"
 
+	"github.com/lorenzodonini/ocpp-go/clock"
+	"github.com/lorenzodonini/ocpp-go/events"
+	"github.com/lorenzodonini/ocpp-go/logging"
 	"github.com/lorenzodonini/ocpp-go/ocpp"
 	"github.com/lorenzodonini/ocpp-go/ws"
 )
@@ -16,13 +21,19 @@ type Client struct {
 	client                ws.WsClient
 	Id                    string
 	requestHandler        func(request ocpp.Request, requestId string, action string)
+	requestHandlerWithCtx func(ctx context.Context, request ocpp.Request, requestId string, action string)
 	responseHandler       func(response ocpp.Response, requestId string)
 	errorHandler          func(err *ocpp.Error, details interface{})
 	onDisconnectedHandler func(err error)
 	onReconnectedHandler  func()
 	invalidMessageHook    func(err *ocpp.Error, rawMessage string, parsedFields []interface{}) *ocpp.Error
+	requestFilter         func(request ocpp.Request) error
+	onRequestCanceled     func(requestId string, request ocpp.Request, err *ocpp.Error)
+	onRequestSent         func(requestId string, request ocpp.Request, sentAt time.Time)
 	dispatcher            ClientDispatcher
 	RequestState          ClientState
+	watchdog              responseWatchdog
+	tracing               spanTracker
 }
 
 // Creates a new Client endpoint.
@@ -36,13 +47,9 @@ type Client struct {
 // The wsClient parameter cannot be nil. Refer to the ws package for information on how to create and
 // customize a websocket client.
 func NewClient(id string, wsClient ws.WsClient, dispatcher ClientDispatcher, stateHandler ClientState, profiles ...*ocpp.Profile) *Client {
-	endpoint := Endpoint{}
 	if wsClient == nil {
 		panic("wsClient parameter cannot be nil")
 	}
-	for _, profile := range profiles {
-		endpoint.AddProfile(profile)
-	}
 	if dispatcher == nil {
 		dispatcher = NewDefaultClientDispatcher(NewFIFOClientQueue(10))
 	}
@@ -51,7 +58,82 @@ func NewClient(id string, wsClient ws.WsClient, dispatcher ClientDispatcher, sta
 	}
 	dispatcher.SetNetworkClient(wsClient)
 	dispatcher.SetPendingRequestState(stateHandler)
-	return &Client{Endpoint: endpoint, client: wsClient, Id: id, dispatcher: dispatcher, RequestState: stateHandler}
+	c := &Client{client: wsClient, Id: id, dispatcher: dispatcher, RequestState: stateHandler}
+	for _, profile := range profiles {
+		c.AddProfile(profile)
+	}
+	dispatcher.SetOnRequestCanceled(c.requestCanceled)
+	if d, ok := dispatcher.(interface {
+		SetOnRequestThrottled(cb func(call *Call, delay time.Duration))
+	}); ok {
+		d.SetOnRequestThrottled(c.onRequestThrottled)
+	}
+	if d, ok := dispatcher.(interface {
+		SetOnRequestSent(cb func(call *Call, sentAt time.Time))
+	}); ok {
+		d.SetOnRequestSent(c.requestSent)
+	}
+	return c
+}
+
+// SetLogger sets the logger used by this client and its dispatcher, overriding the package-level logger.
+// Log entries are enriched with the client ID and, where available, the action and unique ID of the processed message.
+// Passing nil restores the package-level logger.
+//
+// The websocket client keeps its own logger, refer to the ws package.
+func (c *Client) SetLogger(logger logging.Logger) {
+	c.logger = logger
+	if d, ok := c.dispatcher.(interface{ SetLogger(logger logging.Logger) }); ok {
+		if logger != nil {
+			logger = logger.With(logging.ChargePointID(c.Id))
+		}
+		d.SetLogger(logger)
+	}
+}
+
+func (c *Client) getLogger() logging.Logger {
+	return c.Endpoint.getLogger().With(logging.ChargePointID(c.Id))
+}
+
+// SetClock sets the clock used for request timeouts, the response watchdog and tracing. Passing nil restores the real clock.
+// The clock is passed on to the dispatcher as well, if it supports it.
+// To control the websocket pings and reconnection delays, set the clock on the websocket client too.
+//
+// The clock must be set before starting the client.
+func (c *Client) SetClock(clk clock.Clock) {
+	c.clock = clk
+	c.tracing.setClock(clk)
+	if d, ok := c.dispatcher.(interface{ SetClock(c clock.Clock) }); ok {
+		d.SetClock(clk)
+	}
+}
+
+// SetEventBus sets a bus, on which RequestTimedOut and QueueOverflow events are published
+// for requests sent to the server. Passing nil disables publishing.
+//
+// Requires a dispatcher supporting it, such as the DefaultClientDispatcher.
+// The bus must be set before starting the client.
+func (c *Client) SetEventBus(bus *events.Bus) {
+	if d, ok := c.dispatcher.(interface{ SetEventBus(bus *events.Bus) }); ok {
+		d.SetEventBus(bus)
+	}
+}
+
+// SetMinSendInterval sets the minimum gap between sending two consecutive requests to the server.
+// Responses to incoming requests are never delayed.
+// The time spent waiting is reported to the metrics collector, if it implements ThrottleCollector.
+//
+// Requires a dispatcher supporting it, such as the DefaultClientDispatcher. Passing zero disables throttling.
+// The interval must be set before starting the client.
+func (c *Client) SetMinSendInterval(interval time.Duration) {
+	if d, ok := c.dispatcher.(interface{ SetMinSendInterval(interval time.Duration) }); ok {
+		d.SetMinSendInterval(interval)
+	}
+}
+
+func (c *Client) onRequestThrottled(call *Call, delay time.Duration) {
+	c.getLogger().With(logging.Action(call.Action), logging.UniqueID(call.UniqueId)).Debugf("request %v was held back for %v", call.UniqueId, delay)
+	c.tracing.throttled(SpanInfo{Kind: SpanKindOutgoing, ClientID: c.Id, Action: call.Action, UniqueID: call.UniqueId}, delay)
 }
 
 // Registers a handler for incoming requests.
@@ -60,6 +142,12 @@ func (c *Client) SetRequestHandler(handler func(request ocpp.Request, requestId
 }
 
 // Registers a handler for incoming responses.
+// Registers a context-aware handler for incoming requests. If set, it is invoked instead of the regular request handler.
+// The context carries the RequestInfo of the request and is canceled once the handler deadline expires.
+func (c *Client) SetRequestHandlerWithContext(handler func(ctx context.Context, request ocpp.Request, requestId string, action string)) {
+	c.requestHandlerWithCtx = handler
+}
+
 func (c *Client) SetResponseHandler(handler func(response ocpp.Response, requestId string)) {
 	c.responseHandler = handler
 }
@@ -87,6 +175,15 @@ func (c *Client) SetInvalidMessageHook(hook func(err *ocpp.Error, rawMessage str
 	c.invalidMessageHook = hook
 }
 
+// SetRequestFilter registers an optional filter for outgoing requests.
+// The filter is invoked synchronously by SendRequest, before the request is enqueued.
+// If the filter returns an error, the request is not sent and the error is returned to the caller as is.
+//
+// Responses and errors sent to the server are never filtered.
+func (c *Client) SetRequestFilter(filter func(request ocpp.Request) error) {
+	c.requestFilter = filter
+}
+
 func (c *Client) SetOnDisconnectedHandler(handler func(err error)) {
 	c.onDisconnectedHandler = handler
 }
@@ -97,7 +194,87 @@ func (c *Client) SetOnReconnectedHandler(handler func()) {
 
 // Registers the handler to be called on timeout.
 func (c *Client) SetOnRequestCanceled(handler func(requestId string, request ocpp.Request, err *ocpp.Error)) {
-	c.dispatcher.SetOnRequestCanceled(handler)
+	c.onRequestCanceled = handler
+}
+
+// SetOnRequestSent registers a handler, invoked whenever a request is about to be written to the network.
+// Unlike the time a request is enqueued at, the send time excludes any time spent waiting in the request queue,
+// e.g. for measuring the round trip of a request. The time is retrieved from the clock of the client.
+//
+// Requires a dispatcher supporting it, such as the DefaultClientDispatcher.
+func (c *Client) SetOnRequestSent(handler func(requestId string, request ocpp.Request, sentAt time.Time)) {
+	c.onRequestSent = handler
+}
+
+// SetTracer enables tracing of the requests exchanged with the server. Passing nil disables tracing.
+//
+// A span is created for every request sent to the server, from enqueuing until the response is received,
+// and for every request received from the server, from reception until the response is sent back.
+// The tracer must be set before starting the client.
+func (c *Client) SetTracer(tracer Tracer) {
+	c.tracing.setTracer(tracer)
+}
+
+// Changes the interval at which websocket pings are sent to the server. Passing 0 disables client pings.
+// The new period is applied to the current connection right away, without reconnecting.
+//
+// Requires a websocket client implementing ws.PingPeriodSetter, such as the default ws.Client, otherwise an error is returned.
+// Refer to ws.PingPeriodSetter for more information.
+func (c *Client) SetWebSocketPingPeriod(period time.Duration) error {
+	setter, ok := c.client.(ws.PingPeriodSetter)
+	if !ok {
+		return fmt.Errorf("cannot change ping period, not supported by websocket client %T", c.client)
+	}
+	setter.SetPingPeriod(period)
+	return nil
+}
+
+// Replaces the basic authentication credentials used by the websocket client.
+// The new credentials are only sent when the next connection is established.
+func (c *Client) SetBasicAuth(username string, password string) {
+	c.client.SetBasicAuth(username, password)
+}
+
+// Aborts the current connection and starts the automatic reconnection mechanism.
+// Has no effect if the client is currently not connected.
+//
+// Requires a websocket client implementing ws.Reconnector, such as the default ws.Client.
+func (c *Client) Reconnect(reason error) {
+	c.forceReconnect(reason)
+}
+
+// Aborts the current connection, if the websocket client supports it.
+func (c *Client) forceReconnect(reason error) {
+	reconnector, ok := c.client.(ws.Reconnector)
+	if !ok {
+		c.getLogger().Errorf("websocket client doesn't support forcing a reconnection: %v", reason)
+		return
+	}
+	reconnector.ForceReconnect(reason)
+}
+
+// Enables an application-level watchdog, which detects connections that appear to be alive but on which
+// no OCPP messages are received anymore (e.g. half-open TCP connections, where pings are answered by a middlebox).
+//
+// If requests were sent to the server, but no message (CALL, CALL RESULT or CALL ERROR) was received from the server
+// for at least maxSilence, the underlying websocket connection is aborted and the automatic reconnection mechanism is started.
+// The watchdog never triggers while the client is idle, i.e. if no requests were sent since the last received message.
+//
+// Passing a maxSilence <= 0 disables the watchdog.
+func (c *Client) EnableResponseWatchdog(maxSilence time.Duration) {
+	c.watchdog.stop()
+	c.watchdog.setMaxSilence(maxSilence)
+	if c.dispatcher.IsRunning() {
+		c.watchdog.start(c)
+	}
+}
+
+// Registers a handler, which is invoked every time the response watchdog forces a reconnection.
+// The silence parameter contains the time elapsed since the client started waiting for a message from the server.
+//
+// Refer to EnableResponseWatchdog for more information.
+func (c *Client) SetOnResponseWatchdogTriggered(handler func(silence time.Duration)) {
+	c.watchdog.setOnTriggered(handler)
 }
 
 // Connects to the given serverURL and starts running the I/O loop for the underlying connection.
@@ -119,6 +296,7 @@ func (c *Client) Start(serverURL string) error {
 	err := c.client.Start(fullUrl)
 	if err == nil {
 		c.dispatcher.Start()
+		c.watchdog.start(c)
 	}
 	return err
 }
@@ -132,6 +310,7 @@ func (c *Client) StartWithRetries(serverURL string) {
 	fullUrl := fmt.Sprintf("%v/%v", serverURL, c.Id)
 	c.client.StartWithRetries(fullUrl)
 	c.dispatcher.Start()
+	c.watchdog.start(c)
 }
 
 // Stops the client.
@@ -146,12 +325,15 @@ func (c *Client) Stop() {
 	} else {
 		close(cleanupC)
 	}
+	c.watchdog.stop()
 	c.client.Stop()
 	if c.dispatcher.IsRunning() {
 		c.dispatcher.Stop()
 	}
 	// Wait for websocket to be cleaned up
 	<-cleanupC
+	c.handlers.drop("")
+	c.tracing.endClient("", OutcomeCanceled, errEndpointStopped)
 }
 
 func (c *Client) IsConnected() bool {
@@ -171,24 +353,47 @@ func (c *Client) IsConnected() bool {
 // - the endpoint doesn't support the feature
 //
 // - the output queue is full
+//
+// - the request filter rejects the request
 func (c *Client) SendRequest(request ocpp.Request) error {
+	return c.SendRequestWithContext(context.Background(), request)
+}
+
+// SendRequestWithContext works like SendRequest, but additionally links the span of the request
+// to the trace contained in ctx, if a Tracer was set.
+//
+// The context is only used for tracing: canceling it doesn't cancel the request.
+func (c *Client) SendRequestWithContext(ctx context.Context, request ocpp.Request) error {
 	if !c.dispatcher.IsRunning() {
-		return fmt.Errorf("ocppj client is not started, couldn't send request")
+		return fmt.Errorf("ocppj client is %w, couldn't send request", ErrNotStarted)
+	}
+	if c.requestFilter != nil {
+		if err := c.requestFilter(request); err != nil {
+			return err
+		}
 	}
 	call, err := c.CreateCall(request)
 	if err != nil {
 		return err
 	}
+	if err = c.checkSchema("", SchemaOutbound, CALL, call.Action, call.UniqueId, call.Payload); err != nil {
+		return err
+	}
 	jsonMessage, err := call.MarshalJSON()
 	if err != nil {
 		return err
 	}
+	logger := c.getLogger().With(logging.Action(call.Action), logging.UniqueID(call.UniqueId))
+	// The span must exist before the request is dispatched, as the response may arrive at any time
+	c.tracing.start(ctx, SpanInfo{Kind: SpanKindOutgoing, ClientID: c.Id, Action: call.Action, UniqueID: call.UniqueId})
 	// Message will be processed by dispatcher. A dedicated mechanism allows to delegate the message queue handling.
 	if err = c.dispatcher.SendRequest(RequestBundle{Call: call, Data: jsonMessage}); err != nil {
-		log.Errorf("error dispatching request [%s, %s]: %v", call.UniqueId, call.Action, err)
+		logger.Errorf("error dispatching request [%s, %s]: %v", call.UniqueId, call.Action, err)
+		c.tracing.end(SpanKindOutgoing, c.Id, call.UniqueId, OutcomeFailed, err)
 		return err
 	}
-	log.Debugf("enqueued CALL [%s, %s]", call.UniqueId, call.Action)
+	c.watchdog.await(c.now())
+	logger.Debugf("enqueued CALL [%s, %s]", call.UniqueId, call.Action)
 	return nil
 }
 
@@ -203,20 +408,29 @@ func (c *Client) SendRequest(request ocpp.Request) error {
 //
 // - a network error occurred
 func (c *Client) SendResponse(requestId string, response ocpp.Response) error {
+	if c.discardLateResponse(requestId) {
+		return nil
+	}
 	callResult, err := c.CreateCallResult(response, requestId)
 	if err != nil {
 		return err
 	}
+	if err = c.checkSchema("", SchemaOutbound, CALL_RESULT, response.GetFeatureName(), requestId, response); err != nil {
+		return err
+	}
 	jsonMessage, err := callResult.MarshalJSON()
 	if err != nil {
 		return ocpp.NewError(GenericError, err.Error(), requestId)
 	}
+	logger := c.getLogger().With(logging.UniqueID(requestId))
 	if err = c.client.Write(jsonMessage); err != nil {
-		log.Errorf("error sending response [%s]: %v", callResult.GetUniqueId(), err)
+		logger.Errorf("error sending response [%s]: %v", callResult.GetUniqueId(), err)
+		c.tracing.end(SpanKindIncoming, c.Id, requestId, OutcomeFailed, err)
 		return ocpp.NewError(GenericError, err.Error(), requestId)
 	}
-	log.Debugf("sent CALL RESULT [%s]", callResult.GetUniqueId())
-	log.Debugf("sent JSON message to server: %s", string(jsonMessage))
+	c.tracing.end(SpanKindIncoming, c.Id, requestId, OutcomeResponse, nil)
+	logger.Debugf("sent CALL RESULT [%s]", callResult.GetUniqueId())
+	logger.Debugf("sent JSON message to server: %s", jsonMessage)
 	return nil
 }
 
@@ -229,6 +443,13 @@ func (c *Client) SendResponse(requestId string, response ocpp.Response) error {
 //
 // - a network error occurred
 func (c *Client) SendError(requestId string, errorCode ocpp.ErrorCode, description string, details interface{}) error {
+	if c.discardLateResponse(requestId) {
+		return nil
+	}
+	return c.sendError(requestId, errorCode, description, details)
+}
+
+func (c *Client) sendError(requestId string, errorCode ocpp.ErrorCode, description string, details interface{}) error {
 	callError, err := c.CreateCallError(requestId, errorCode, description, details)
 	if err != nil {
 		return err
@@ -237,26 +458,47 @@ func (c *Client) SendError(requestId string, errorCode ocpp.ErrorCode, descripti
 	if err != nil {
 		return ocpp.NewError(GenericError, err.Error(), requestId)
 	}
+	logger := c.getLogger().With(logging.UniqueID(requestId))
 	if err = c.client.Write(jsonMessage); err != nil {
-		log.Errorf("error sending response error [%s]: %v", callError.UniqueId, err)
+		logger.Errorf("error sending response error [%s]: %v", callError.UniqueId, err)
+		c.tracing.end(SpanKindIncoming, c.Id, requestId, OutcomeFailed, err)
 		return ocpp.NewError(GenericError, err.Error(), requestId)
 	}
-	log.Debugf("sent CALL ERROR [%s]", callError.UniqueId)
-	log.Debugf("sent JSON message to server: %s", string(jsonMessage))
+	c.tracing.end(SpanKindIncoming, c.Id, requestId, OutcomeError, ocpp.NewError(errorCode, description, requestId))
+	logger.Debugf("sent CALL ERROR [%s]", callError.UniqueId)
+	logger.Debugf("sent JSON message to server: %s", jsonMessage)
 	return nil
 }
 
 func (c *Client) ocppMessageHandler(data []byte) error {
-	parsedJson, err := ParseRawJsonMessage(data)
-	if err != nil {
-		log.Error(err)
-		return err
+	logger := c.getLogger()
+	// Pathological input is rejected before decoding
+	err := c.checkParseLimits(data)
+	var parsedJson []interface{}
+	if err == nil {
+		parsedJson, err = ParseRawJsonMessage(data)
+		if err != nil {
+			logger.Error(err)
+			return err
+		}
+	}
+	logger.Debugf("received JSON message from server: %s", data)
+	var message Message
+	if err == nil {
+		message, err = c.ParseMessage(parsedJson, c.RequestState)
+	}
+	if err == nil && message != nil {
+		if schemaErr := c.checkInboundSchema("", message, parsedJson); schemaErr != nil {
+			err = schemaErr
+		}
 	}
-	log.Debugf("received JSON message from server: %s", string(data))
-	message, err := c.ParseMessage(parsedJson, c.RequestState)
 	if err != nil {
-		ocppErr := err.(*ocpp.Error)
+		ocppErr, ok := err.(*ocpp.Error)
+		if !ok {
+			ocppErr = ocpp.NewError(GenericError, err.Error(), "")
+		}
 		messageID := ocppErr.MessageId
+		c.tracing.unknownAction(SpanInfo{Kind: SpanKindIncoming, ClientID: c.Id, UniqueID: messageID}, ocppErr)
 		// Support ad-hoc callback for invalid message handling
 		if c.invalidMessageHook != nil {
 			err2 := c.invalidMessageHook(ocppErr, string(data), parsedJson)
@@ -274,27 +516,46 @@ func (c *Client) ocppMessageHandler(data []byte) error {
 				return err2
 			}
 		}
-		log.Error(err)
+		logger.With(logging.UniqueID(ocppErr.MessageId)).Error(err)
 		return err
 	}
 	if message != nil {
+		// A message was received from the server, so the connection is alive
+		c.watchdog.reset()
 		switch message.GetMessageTypeId() {
 		case CALL:
 			call := message.(*Call)
-			log.Debugf("handling incoming CALL [%s, %s]", call.UniqueId, call.Action)
-			c.requestHandler(call.Payload, call.UniqueId, call.Action)
+			logger.With(logging.Action(call.Action), logging.UniqueID(call.UniqueId)).Debugf("handling incoming CALL [%s, %s]", call.UniqueId, call.Action)
+			ctx := NewRequestContext(context.Background(), RequestInfo{ClientID: c.Id, Action: call.Action, UniqueID: call.UniqueId})
+			info := SpanInfo{Kind: SpanKindIncoming, ClientID: c.Id, Action: call.Action, UniqueID: call.UniqueId}
+			c.tracing.start(ctx, info)
+			ctx = c.handlers.track(ctx, info, c.handlerDeadlineFor(call.Action, c.dispatcher), c.getClock(), c.onHandlerDeadlineExceeded)
+			defer func() {
+				if r := recover(); r != nil {
+					c.HandlePanic(call.UniqueId, NewHandlerPanicError(c.Id, call.Action, r))
+				}
+			}()
+			if c.requestHandlerWithCtx != nil {
+				c.requestHandlerWithCtx(ctx, call.Payload, call.UniqueId, call.Action)
+			} else {
+				c.requestHandler(call.Payload, call.UniqueId, call.Action)
+			}
 		case CALL_RESULT:
 			callResult := message.(*CallResult)
-			log.Debugf("handling incoming CALL RESULT [%s]", callResult.UniqueId)
+			logger.With(logging.UniqueID(callResult.UniqueId)).Debugf("handling incoming CALL RESULT [%s]", callResult.UniqueId)
 			c.dispatcher.CompleteRequest(callResult.GetUniqueId()) // Remove current request from queue and send next one
+			c.tracing.end(SpanKindOutgoing, c.Id, callResult.UniqueId, OutcomeResponse, nil)
 			if c.responseHandler != nil {
+				defer c.recoverPanic(c.Id, callResult.Payload.GetFeatureName())
 				c.responseHandler(callResult.Payload, callResult.UniqueId)
 			}
 		case CALL_ERROR:
 			callError := message.(*CallError)
-			log.Debugf("handling incoming CALL ERROR [%s]", callError.UniqueId)
+			logger.With(logging.UniqueID(callError.UniqueId)).Debugf("handling incoming CALL ERROR [%s]", callError.UniqueId)
 			c.dispatcher.CompleteRequest(callError.GetUniqueId()) // Remove current request from queue and send next one
+			c.tracing.end(SpanKindOutgoing, c.Id, callError.UniqueId, OutcomeError, ocpp.NewError(callError.ErrorCode, callError.ErrorDescription, callError.UniqueId))
 			if c.errorHandler != nil {
+				defer c.recoverPanic(c.Id, "")
 				c.errorHandler(ocpp.NewError(callError.ErrorCode, callError.ErrorDescription, callError.UniqueId), callError.ErrorDetails)
 			}
 		}
@@ -310,10 +571,13 @@ func (c *Client) ocppMessageHandler(data []byte) error {
 // The method will, however, only attempt to send a default error once.
 // If this operation fails, the other endpoint may still starve.
 func (c *Client) HandleFailedResponseError(requestID string, err error, featureName string) {
-	log.Debugf("handling error for failed response [%s]", requestID)
+	c.getLogger().With(logging.Action(featureName), logging.UniqueID(requestID)).Debugf("handling error for failed response [%s]", requestID)
 	var responseErr *ocpp.Error
 	// There's several possible errors: invalid profile, invalid payload or send error
 	switch err.(type) {
+	case *ValidationError:
+		// Validation error
+		responseErr = errorFromValidation(err.(*ValidationError).FieldErrors, requestID, featureName)
 	case validator.ValidationErrors:
 		// Validation error
 		validationErr := err.(validator.ValidationErrors)
@@ -321,6 +585,9 @@ func (c *Client) HandleFailedResponseError(requestID string, err error, featureN
 	case *ocpp.Error:
 		// Internal OCPP error
 		responseErr = err.(*ocpp.Error)
+	case *SchemaValidationError:
+		// Schema validation error
+		responseErr = err.(*SchemaValidationError).ocppError(c)
 	case error:
 		// Unknown error
 		responseErr = ocpp.NewError(GenericError, err.Error(), requestID)
@@ -329,17 +596,62 @@ func (c *Client) HandleFailedResponseError(requestID string, err error, featureN
 	_ = c.SendError(requestID, responseErr.Code, responseErr.Description, nil)
 }
 
+// Invoked by the dispatcher, whenever a request times out or couldn't be sent.
+func (c *Client) requestSent(call *Call, sentAt time.Time) {
+	if c.onRequestSent != nil {
+		defer c.recoverPanic(c.Id, call.Action)
+		c.onRequestSent(call.UniqueId, call.Payload, sentAt)
+	}
+}
+
+func (c *Client) requestCanceled(requestId string, request ocpp.Request, err *ocpp.Error) {
+	c.tracing.end(SpanKindOutgoing, c.Id, requestId, canceledOutcome(err), err)
+	if c.onRequestCanceled != nil {
+		defer c.recoverPanic(c.Id, request.GetFeatureName())
+		c.onRequestCanceled(requestId, request, err)
+	}
+}
+
 func (c *Client) onDisconnected(err error) {
-	log.Error("disconnected from server", err)
+	c.getLogger().Error("disconnected from server", err)
 	c.dispatcher.Pause()
+	c.handlers.drop("")
 	if c.onDisconnectedHandler != nil {
+		defer c.recoverPanic(c.Id, "")
 		c.onDisconnectedHandler(err)
 	}
 }
 
 func (c *Client) onReconnected() {
+	c.watchdog.reset()
 	if c.onReconnectedHandler != nil {
-		c.onReconnectedHandler()
+		c.invokeReconnectedHandler()
 	}
 	c.dispatcher.Resume()
 }
+
+func (c *Client) invokeReconnectedHandler() {
+	defer c.recoverPanic(c.Id, "")
+	c.onReconnectedHandler()
+}
+
+// Sends an InternalError in place of the response to a request, whose handler missed its deadline.
+func (c *Client) onHandlerDeadlineExceeded(pending *pendingHandler, elapsed time.Duration) {
+	info := pending.info
+	_ = c.sendError(info.UniqueID, InternalError, HandlerDeadlineDescription, nil)
+	c.tracing.handlerDeadlineExceeded(info, pending.deadline)
+	defer c.recoverPanic(c.Id, info.Action)
+	c.reportSlowHandler(c.getLogger(), info, elapsed)
+}
+
+// Returns true if the response to a request must be discarded, since an error was already sent in its place.
+func (c *Client) discardLateResponse(requestID string) bool {
+	late := c.handlers.complete(c.Id, requestID)
+	if late == nil {
+		return false
+	}
+	elapsed := c.getClock().Now().Sub(late.received)
+	c.getLogger().With(logging.Action(late.info.Action), logging.UniqueID(requestID)).Errorf("discarding response to request %v, handler responded after %v", requestID, elapsed)
+	c.tracing.lateResponseDiscarded(late.info, elapsed)
+	return true
+}
@@ -0,0 +1,27 @@
+package ocppj
+
+import "context"
+
+// RequestInfo identifies an incoming request, which is being handled.
+type RequestInfo struct {
+	// The ID of the charge point/charging station, which sent the request.
+	ClientID string
+	Action   string
+	UniqueID string
+}
+
+type requestInfoKey struct{}
+
+// NewRequestContext returns a copy of parent, carrying the passed request info.
+//
+// The server derives such a context for every incoming request from the base context of the connection,
+// hence it is canceled once the client disconnects.
+func NewRequestContext(parent context.Context, info RequestInfo) context.Context {
+	return context.WithValue(parent, requestInfoKey{}, info)
+}
+
+// RequestInfoFromContext returns the request info stored in ctx, if any.
+func RequestInfoFromContext(ctx context.Context) (RequestInfo, bool) {
+	info, ok := ctx.Value(requestInfoKey{}).(RequestInfo)
+	return info, ok
+}
@@ -0,0 +1,192 @@
+package ocppj
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/clock"
+	"github.com/lorenzodonini/ocpp-go/logging"
+)
+
+// NoHandlerDeadline disables handler deadlines, when passed to SetHandlerDeadline.
+const NoHandlerDeadline time.Duration = -1
+
+// HandlerDeadlineDescription is the description of the InternalError sent in place of a response,
+// when the handler of an incoming request didn't respond before its deadline.
+const HandlerDeadlineDescription = "Request handler did not respond in time"
+
+// SlowHandlerHandler is invoked once the handler of an incoming request missed its deadline.
+// The elapsed time is measured from receiving the request.
+type SlowHandlerHandler func(action string, elapsed time.Duration)
+
+// HandlerDeadlineCollector may additionally be implemented by a MetricsCollector,
+// to be notified of request handlers missing their deadline.
+type HandlerDeadlineCollector interface {
+	// HandlerDeadlineExceeded is invoked once an InternalError was sent in place of the response to a request.
+	HandlerDeadlineExceeded(info SpanInfo, deadline time.Duration)
+	// LateResponseDiscarded is invoked when the handler eventually responds.
+	// The elapsed time is measured from receiving the request.
+	LateResponseDiscarded(info SpanInfo, elapsed time.Duration)
+}
+
+// SetHandlerDeadline sets the time handlers have to respond to incoming requests.
+//
+// The handler context is canceled at the deadline, so that handlers honoring it may abort early.
+// This happens regardless of whether a response was sent already. If no response was sent by then, an InternalError is sent instead, the SlowHandlerHandler is invoked
+// and the eventual response of the handler is discarded.
+//
+// By default, the deadline equals the timeout of the dispatcher, assuming the other endpoint uses a similar timeout
+// and wouldn't wait for the response any longer. Passing zero restores the default, NoHandlerDeadline disables deadlines.
+func (endpoint *Endpoint) SetHandlerDeadline(deadline time.Duration) {
+	endpoint.handlerDeadline = deadline
+}
+
+// SetHandlerDeadlineOverrides sets the handler deadline for specific actions, overriding the deadline passed to SetHandlerDeadline.
+// Passing nil removes all overrides.
+func (endpoint *Endpoint) SetHandlerDeadlineOverrides(overrides map[string]time.Duration) {
+	endpoint.handlerDeadlineOverrides = overrides
+}
+
+// SetSlowHandlerHandler registers a handler, invoked whenever a request handler missed its deadline.
+func (endpoint *Endpoint) SetSlowHandlerHandler(handler SlowHandlerHandler) {
+	endpoint.slowHandlerHandler = handler
+}
+
+// Returns the deadline for handling a request with the given action. Zero means no deadline.
+func (endpoint *Endpoint) handlerDeadlineFor(action string, dispatcher interface{}) time.Duration {
+	deadline, ok := endpoint.handlerDeadlineOverrides[action]
+	if !ok {
+		deadline = endpoint.handlerDeadline
+	}
+	if deadline == 0 {
+		deadline = defaultMessageTimeout
+		if d, ok := dispatcher.(interface{ Timeout() time.Duration }); ok {
+			deadline = d.Timeout()
+		}
+	}
+	if deadline < 0 {
+		return 0
+	}
+	return deadline
+}
+
+// Logs a missed deadline and notifies the slow handler handler. The error was already sent by then.
+func (endpoint *Endpoint) reportSlowHandler(logger logging.Logger, info SpanInfo, elapsed time.Duration) {
+	logger.With(logging.Action(info.Action), logging.UniqueID(info.UniqueID)).Errorf("handler for request %v didn't respond within %v, sent %v instead", info.UniqueID, elapsed, InternalError)
+	if endpoint.slowHandlerHandler != nil {
+		endpoint.slowHandlerHandler(info.Action, elapsed)
+	}
+}
+
+type pendingHandler struct {
+	info     SpanInfo
+	received time.Time
+	deadline time.Duration
+	timer    clock.Timer
+	cancel   context.CancelFunc
+}
+
+// Keeps track of the handlers of incoming requests, which were not responded to yet.
+// A zero value is ready to use.
+type handlerTracker struct {
+	pending map[spanKey]*pendingHandler
+	// Handlers which missed their deadline and didn't respond yet
+	expired map[spanKey]*pendingHandler
+	mutex   sync.Mutex
+}
+
+// Starts tracking the handler of an incoming request. The returned context is canceled once the deadline expires.
+// If the request wasn't completed by then, onExpired is invoked. If deadline is zero, ctx is returned unchanged.
+func (t *handlerTracker) track(ctx context.Context, info SpanInfo, deadline time.Duration, clk clock.Clock, onExpired func(pending *pendingHandler, elapsed time.Duration)) context.Context {
+	if deadline <= 0 {
+		return ctx
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	key := spanKey{kind: SpanKindIncoming, clientID: info.ClientID, uniqueID: info.UniqueID}
+	pending := &pendingHandler{info: info, received: clk.Now(), deadline: deadline, cancel: cancel}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.pending == nil {
+		t.pending = map[spanKey]*pendingHandler{}
+	}
+	if previous, ok := t.pending[key]; ok {
+		// The other endpoint reused a message ID, before the previous request was responded to
+		previous.timer.Stop()
+		previous.cancel()
+	}
+	t.pending[key] = pending
+	pending.timer = clk.AfterFunc(deadline, func() {
+		cancel()
+		t.mutex.Lock()
+		if t.pending[key] != pending {
+			// Completed in time
+			t.mutex.Unlock()
+			return
+		}
+		delete(t.pending, key)
+		if t.expired == nil {
+			t.expired = map[spanKey]*pendingHandler{}
+		}
+		t.expired[key] = pending
+		t.mutex.Unlock()
+		onExpired(pending, clk.Now().Sub(pending.received))
+	})
+	return ctx
+}
+
+// Stops tracking the handler of a request, which is being responded to.
+// Returns the handler if it already missed its deadline, in which case the response must be discarded.
+func (t *handlerTracker) complete(clientID string, uniqueID string) *pendingHandler {
+	key := spanKey{kind: SpanKindIncoming, clientID: clientID, uniqueID: uniqueID}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if _, ok := t.pending[key]; ok {
+		// The timer keeps running, to cancel the context at the deadline
+		delete(t.pending, key)
+		return nil
+	}
+	if expired, ok := t.expired[key]; ok {
+		delete(t.expired, key)
+		return expired
+	}
+	return nil
+}
+
+// Stops tracking all handlers of a client. If clientID is empty, the handlers of all clients are dropped.
+func (t *handlerTracker) drop(clientID string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for key, pending := range t.pending {
+		if clientID == "" || key.clientID == clientID {
+			pending.timer.Stop()
+			pending.cancel()
+			delete(t.pending, key)
+		}
+	}
+	for key := range t.expired {
+		if clientID == "" || key.clientID == clientID {
+			delete(t.expired, key)
+		}
+	}
+}
+
+// Reports a missed handler deadline, if the metrics collector supports it.
+func (t *spanTracker) handlerDeadlineExceeded(info SpanInfo, deadline time.Duration) {
+	t.mutex.Lock()
+	collector, _ := t.collector.(HandlerDeadlineCollector)
+	t.mutex.Unlock()
+	if collector != nil {
+		collector.HandlerDeadlineExceeded(info, deadline)
+	}
+}
+
+// Reports a discarded late response, if the metrics collector supports it.
+func (t *spanTracker) lateResponseDiscarded(info SpanInfo, elapsed time.Duration) {
+	t.mutex.Lock()
+	collector, _ := t.collector.(HandlerDeadlineCollector)
+	t.mutex.Unlock()
+	if collector != nil {
+		collector.LateResponseDiscarded(info, elapsed)
+	}
+}
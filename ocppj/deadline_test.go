@@ -0,0 +1,135 @@
+package ocppj_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/clock/clocktest"
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/lorenzodonini/ocpp-go/ws"
+)
+
+type deadlineCollector struct {
+	recordingCollector
+	exceeded  chan time.Duration
+	discarded chan time.Duration
+}
+
+func (c *deadlineCollector) HandlerDeadlineExceeded(info ocppj.SpanInfo, deadline time.Duration) {
+	c.exceeded <- deadline
+}
+
+func (c *deadlineCollector) LateResponseDiscarded(info ocppj.SpanInfo, elapsed time.Duration) {
+	c.discarded <- elapsed
+}
+
+func (suite *OcppJTestSuite) TestServerHandlerDeadline() {
+	t := suite.T()
+	mockChargePointId := "1234"
+	deadline := 10 * time.Second
+	fakeClock := clocktest.NewFakeClock(time.Now())
+	collector := &deadlineCollector{exceeded: make(chan time.Duration, 1), discarded: make(chan time.Duration, 1)}
+	suite.centralSystem.SetClock(fakeClock)
+	suite.centralSystem.SetMetricsCollector(collector)
+	suite.centralSystem.SetHandlerDeadline(deadline)
+	slowC := make(chan time.Duration, 1)
+	suite.centralSystem.SetSlowHandlerHandler(func(action string, elapsed time.Duration) {
+		assert.Equal(t, MockFeatureName, action)
+		slowC <- elapsed
+	})
+	ctxC := make(chan context.Context, 1)
+	suite.centralSystem.SetRequestHandlerWithContext(func(ctx context.Context, client ws.Channel, request ocpp.Request, requestId string, action string) {
+		ctxC <- ctx
+	})
+	channel := NewMockWebSocket(mockChargePointId)
+	writeC := make(chan string, 1)
+	suite.mockServer.On("Start", mock.AnythingOfType("int"), mock.AnythingOfType("string")).Return()
+	suite.mockServer.On("Write", mockChargePointId, mock.Anything).Run(func(args mock.Arguments) {
+		writeC <- string(args.Get(1).([]byte))
+	}).Return(nil)
+	suite.centralSystem.Start(8887, "somePath")
+	suite.mockServer.NewClientHandler(channel)
+	receive := func(uniqueId string) context.Context {
+		err := suite.mockServer.MessageHandler(channel, []byte(fmt.Sprintf(`[2,"%v","%v",{"mockValue":"someValue"}]`, uniqueId, MockFeatureName)))
+		require.NoError(t, err)
+		return <-ctxC
+	}
+	// Handler responding just before the deadline
+	ctx := receive("1")
+	fakeClock.Advance(deadline - time.Millisecond)
+	require.NoError(t, ctx.Err())
+	require.NoError(t, suite.centralSystem.SendResponse(mockChargePointId, "1", newMockConfirmation("someValue")))
+	assert.True(t, strings.HasPrefix(<-writeC, `[3,"1",`))
+	// The context expires at the deadline regardless, but no error is sent
+	fakeClock.Advance(time.Millisecond)
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+	assert.Empty(t, writeC)
+	assert.Empty(t, slowC)
+	// Handler responding just after the deadline
+	ctx = receive("2")
+	fakeClock.Advance(deadline)
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+	assert.Equal(t, fmt.Sprintf(`[4,"2","%v","%v",{}]`, ocppj.InternalError, ocppj.HandlerDeadlineDescription), <-writeC)
+	assert.Equal(t, deadline, <-slowC)
+	assert.Equal(t, deadline, <-collector.exceeded)
+	fakeClock.Advance(time.Millisecond)
+	require.NoError(t, suite.centralSystem.SendResponse(mockChargePointId, "2", newMockConfirmation("someValue")))
+	assert.Equal(t, deadline+time.Millisecond, <-collector.discarded)
+	assert.Empty(t, writeC)
+	// Handler never responding
+	ctx = receive("3")
+	fakeClock.Advance(time.Minute)
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+	assert.Equal(t, fmt.Sprintf(`[4,"3","%v","%v",{}]`, ocppj.InternalError, ocppj.HandlerDeadlineDescription), <-writeC)
+	assert.Equal(t, deadline, <-slowC)
+	assert.Equal(t, deadline, <-collector.exceeded)
+	// Per-action overrides and disabled deadlines
+	suite.centralSystem.SetHandlerDeadlineOverrides(map[string]time.Duration{MockFeatureName: ocppj.NoHandlerDeadline})
+	ctx = receive("4")
+	fakeClock.Advance(time.Hour)
+	require.NoError(t, ctx.Err())
+	assert.Empty(t, writeC)
+	require.NoError(t, suite.centralSystem.SendResponse(mockChargePointId, "4", newMockConfirmation("someValue")))
+	assert.True(t, strings.HasPrefix(<-writeC, `[3,"4",`))
+}
+
+func (suite *OcppJTestSuite) TestClientHandlerDeadline() {
+	t := suite.T()
+	fakeClock := clocktest.NewFakeClock(time.Now())
+	suite.chargePoint.SetClock(fakeClock)
+	slowC := make(chan time.Duration, 1)
+	suite.chargePoint.SetSlowHandlerHandler(func(action string, elapsed time.Duration) {
+		slowC <- elapsed
+	})
+	ctxC := make(chan context.Context, 1)
+	suite.chargePoint.SetRequestHandlerWithContext(func(ctx context.Context, request ocpp.Request, requestId string, action string) {
+		ctxC <- ctx
+	})
+	writeC := make(chan string, 1)
+	suite.mockClient.On("Start", mock.AnythingOfType("string")).Return(nil)
+	suite.mockClient.On("Write", mock.Anything).Run(func(args mock.Arguments) {
+		writeC <- string(args.Get(0).([]byte))
+	}).Return(nil)
+	require.NoError(t, suite.chargePoint.Start("someUrl"))
+	// The default deadline is the timeout of the dispatcher
+	suite.clientDispatcher.SetTimeout(5 * time.Second)
+	require.NoError(t, suite.mockClient.MessageHandler([]byte(fmt.Sprintf(`[2,"5678","%v",{"mockValue":"someValue"}]`, MockFeatureName))))
+	ctx := <-ctxC
+	info, ok := ocppj.RequestInfoFromContext(ctx)
+	require.True(t, ok)
+	assert.Equal(t, "5678", info.UniqueID)
+	fakeClock.Advance(5 * time.Second)
+	assert.ErrorIs(t, ctx.Err(), context.Canceled)
+	assert.Equal(t, fmt.Sprintf(`[4,"5678","%v","%v",{}]`, ocppj.InternalError, ocppj.HandlerDeadlineDescription), <-writeC)
+	assert.Equal(t, 5*time.Second, <-slowC)
+	// The late response is discarded
+	require.NoError(t, suite.chargePoint.SendResponse("5678", newMockConfirmation("someValue")))
+	assert.Empty(t, writeC)
+}
@@ -2,10 +2,14 @@ package ocppj
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
+	"github.com/lorenzodonini/ocpp-go/clock"
+	"github.com/lorenzodonini/ocpp-go/events"
+	"github.com/lorenzodonini/ocpp-go/logging"
 	"github.com/lorenzodonini/ocpp-go/ocpp"
 	"github.com/lorenzodonini/ocpp-go/ws"
 )
@@ -93,9 +97,20 @@ type DefaultClientDispatcher struct {
 	network             ws.WsClient
 	mutex               sync.RWMutex
 	onRequestCancel     func(requestID string, request ocpp.Request, err *ocpp.Error)
-	timer               *time.Timer
+	timer               clock.Timer
 	paused              bool
 	timeout             time.Duration
+	logger              logging.Logger
+	clock               clock.Clock
+	minSendInterval     time.Duration
+	lastSent            time.Time
+	throttleTimer       clock.Timer
+	onRequestThrottled  func(call *Call, delay time.Duration)
+	onRequestSent       func(call *Call, sentAt time.Time)
+	flushC              chan struct{}
+	flush               flushState
+	dispatchMutex       sync.Mutex
+	bus                 *events.Bus
 }
 
 const (
@@ -111,9 +126,25 @@ func NewDefaultClientDispatcher(queue RequestQueue) *DefaultClientDispatcher {
 		readyForDispatch:    make(chan bool, 1),
 		pendingRequestState: NewClientState(),
 		timeout:             defaultMessageTimeout,
+		clock:               clock.New(),
+		flushC:              make(chan struct{}, 1),
+		flush:               flushState{changedC: make(chan struct{})},
 	}
 }
 
+// SetLogger sets the logger used by the dispatcher, overriding the package-level logger.
+// Passing nil restores the package-level logger.
+func (d *DefaultClientDispatcher) SetLogger(logger logging.Logger) {
+	d.logger = logger
+}
+
+func (d *DefaultClientDispatcher) getLogger() logging.Logger {
+	if d.logger != nil {
+		return d.logger
+	}
+	return log
+}
+
 func (d *DefaultClientDispatcher) SetOnRequestCanceled(cb func(requestID string, request ocpp.Request, err *ocpp.Error)) {
 	d.onRequestCancel = cb
 }
@@ -122,11 +153,49 @@ func (d *DefaultClientDispatcher) SetTimeout(timeout time.Duration) {
 	d.timeout = timeout
 }
 
+// Timeout returns the maximum time to wait for a response to a sent request.
+func (d *DefaultClientDispatcher) Timeout() time.Duration {
+	return d.timeout
+}
+
+// SetClock sets the clock used for request timeouts. Passing nil restores the real clock.
+// The clock must be set before starting the dispatcher.
+func (d *DefaultClientDispatcher) SetClock(c clock.Clock) {
+	d.clock = clock.OrDefault(c)
+}
+
+// SetMinSendInterval sets the minimum gap between sending two consecutive requests.
+// A request becoming ready for dispatch earlier is held back, the timeout only starts once it is sent.
+// Passing zero disables throttling.
+//
+// The interval must be set before starting the dispatcher.
+func (d *DefaultClientDispatcher) SetMinSendInterval(interval time.Duration) {
+	d.minSendInterval = interval
+}
+
+// SetOnRequestThrottled sets a callback, invoked whenever a request is sent after being held back
+// by the minimum send interval. The callback receives the time the request was held back for.
+func (d *DefaultClientDispatcher) SetOnRequestThrottled(cb func(call *Call, delay time.Duration)) {
+	d.onRequestThrottled = cb
+}
+
+// SetOnRequestSent sets a callback, invoked whenever a request is about to be written to the network.
+// The callback receives the time the request was sent at, according to the clock of the dispatcher.
+func (d *DefaultClientDispatcher) SetOnRequestSent(cb func(call *Call, sentAt time.Time)) {
+	d.onRequestSent = cb
+}
+
+// SetEventBus sets a bus, on which RequestTimedOut and QueueOverflow events are published. Passing nil disables publishing.
+func (d *DefaultClientDispatcher) SetEventBus(bus *events.Bus) {
+	d.bus = bus
+}
+
 func (d *DefaultClientDispatcher) Start() {
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
 	d.requestChannel = make(chan bool, 1)
-	d.timer = time.NewTimer(defaultTimeoutTick) // Default to 24 hours tick
+	d.timer = d.clock.NewTimer(defaultTimeoutTick) // Default to 24 hours tick
+	d.throttleTimer = d.clock.NewTimer(defaultTimeoutTick)
 	go d.messagePump()
 }
 
@@ -146,6 +215,7 @@ func (d *DefaultClientDispatcher) Stop() {
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
 	close(d.requestChannel)
+	d.flush.notify()
 	// TODO: clear pending requests?
 }
 
@@ -161,7 +231,13 @@ func (d *DefaultClientDispatcher) SendRequest(req RequestBundle) error {
 	if d.network == nil {
 		return fmt.Errorf("cannot SendRequest, no network client was set")
 	}
+	if req.EnqueuedAt.IsZero() {
+		req.EnqueuedAt = d.clock.Now()
+	}
 	if err := d.requestQueue.Push(req); err != nil {
+		if errors.Is(err, ErrQueueFull) {
+			d.bus.Publish(events.QueueOverflowEvent{RequestID: req.Call.UniqueId, Action: req.Call.Action})
+		}
 		return err
 	}
 	d.mutex.RLock()
@@ -172,6 +248,8 @@ func (d *DefaultClientDispatcher) SendRequest(req RequestBundle) error {
 
 func (d *DefaultClientDispatcher) messagePump() {
 	rdy := true // Ready to transmit at the beginning
+	throttled := false
+	var throttledSince time.Time
 
 	reqChan := func() chan bool {
 		d.mutex.RLock()
@@ -190,7 +268,7 @@ func (d *DefaultClientDispatcher) messagePump() {
 				d.mutex.Unlock()
 				return
 			}
-		case _, ok := <-d.timer.C:
+		case _, ok := <-d.timer.C():
 			// Timeout elapsed
 			if !ok {
 				continue
@@ -199,14 +277,17 @@ func (d *DefaultClientDispatcher) messagePump() {
 				// Current request timed out. Removing request and triggering cancel callback
 				el := d.requestQueue.Peek()
 				bundle, _ := el.(RequestBundle)
-				d.CompleteRequest(bundle.Call.UniqueId)
-				if d.onRequestCancel != nil {
-					d.onRequestCancel(bundle.Call.UniqueId, bundle.Call.Payload,
-						ocpp.NewError(GenericError, "Request timed out", bundle.Call.UniqueId))
-				}
+				d.cancelPendingRequest(bundle, newTimeoutError(bundle.Call))
+				d.bus.Publish(events.RequestTimedOutEvent{RequestID: bundle.Call.UniqueId, Action: bundle.Call.Action})
 			}
 			// No request is currently pending -> set timer to high number
 			d.timer.Reset(defaultTimeoutTick)
+		case <-d.throttleTimer.C():
+			// Minimum send interval elapsed
+			throttled = false
+		case <-d.flushC:
+			// Flush requested, a held back request is sent right away
+			throttled = false
 		case rdy = <-d.readyForDispatch:
 			// Ready flag set, keep going
 		}
@@ -219,45 +300,86 @@ func (d *DefaultClientDispatcher) messagePump() {
 
 		// Only dispatch request if able to send and request queue isn't empty
 		if rdy && !d.requestQueue.IsEmpty() {
-			d.dispatchNextRequest()
+			now := d.clock.Now()
+			if wait := d.minSendInterval - now.Sub(d.lastSent); wait > 0 && !d.flush.active() {
+				// Too early, hold back the request until the interval elapsed
+				if !throttled {
+					throttled = true
+					if throttledSince.IsZero() {
+						throttledSince = now
+					}
+					d.throttleTimer.Reset(wait)
+				}
+				continue
+			}
+			bundle, ok := d.dispatchNextRequest()
+			if !ok {
+				// The queue was drained in the meantime
+				continue
+			}
+			d.lastSent = now
+			if !throttledSince.IsZero() {
+				if d.onRequestThrottled != nil {
+					d.onRequestThrottled(bundle.Call, now.Sub(throttledSince))
+				}
+				throttledSince = time.Time{}
+			}
 			rdy = false
 			// Set timer
 			if !d.timer.Stop() {
-				<-d.timer.C
+				<-d.timer.C()
 			}
 			d.timer.Reset(d.timeout)
 		}
 	}
 }
 
-func (d *DefaultClientDispatcher) dispatchNextRequest() {
-	// Get first element in queue
+// Sends the first request in the queue. Returns false if the queue is empty.
+func (d *DefaultClientDispatcher) dispatchNextRequest() (RequestBundle, bool) {
+	// Get first element in queue. The request is marked as pending before DrainQueue may access the queue again.
+	d.dispatchMutex.Lock()
 	el := d.requestQueue.Peek()
+	if el == nil {
+		d.dispatchMutex.Unlock()
+		return RequestBundle{}, false
+	}
 	bundle, _ := el.(RequestBundle)
 	jsonMessage := bundle.Data
 	d.pendingRequestState.AddPendingRequest(bundle.Call.UniqueId, bundle.Call.Payload)
+	d.dispatchMutex.Unlock()
+	if d.onRequestSent != nil {
+		d.onRequestSent(bundle.Call, d.clock.Now())
+	}
 	// Attempt to send over network
 	err := d.network.Write(jsonMessage)
 	if err != nil {
 		// TODO: handle retransmission instead of skipping request altogether
-		d.CompleteRequest(bundle.Call.GetUniqueId())
-		if d.onRequestCancel != nil {
-			d.onRequestCancel(bundle.Call.UniqueId, bundle.Call.Payload,
-				ocpp.NewError(InternalError, err.Error(), bundle.Call.UniqueId))
-		}
+		d.cancelPendingRequest(bundle, ocpp.NewError(InternalError, err.Error(), bundle.Call.UniqueId))
+	}
+	logger := d.getLogger().With(logging.Action(bundle.Call.Action), logging.UniqueID(bundle.Call.UniqueId))
+	logger.Infof("dispatched request %s to server", bundle.Call.UniqueId)
+	logger.Debugf("sent JSON message to server: %s", jsonMessage)
+	return bundle, true
+}
+
+// Removes the pending request from the queue and invokes the cancel callback.
+// The error is reported to a running FlushQueue as well.
+func (d *DefaultClientDispatcher) cancelPendingRequest(bundle RequestBundle, err *ocpp.Error) {
+	d.completeRequest(bundle.Call.UniqueId, err)
+	if d.onRequestCancel != nil {
+		d.onRequestCancel(bundle.Call.UniqueId, bundle.Call.Payload, err)
 	}
-	log.Infof("dispatched request %s to server", bundle.Call.UniqueId)
-	log.Debugf("sent JSON message to server: %s", string(jsonMessage))
 }
 
 func (d *DefaultClientDispatcher) Pause() {
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
 	if !d.timer.Stop() {
-		<-d.timer.C
+		<-d.timer.C()
 	}
 	d.timer.Reset(defaultTimeoutTick)
 	d.paused = true
+	d.flush.notify()
 }
 
 func (d *DefaultClientDispatcher) Resume() {
@@ -274,19 +396,25 @@ func (d *DefaultClientDispatcher) Resume() {
 }
 
 func (d *DefaultClientDispatcher) CompleteRequest(requestId string) {
+	d.completeRequest(requestId, nil)
+}
+
+// Removes the pending request from the front of the queue. The error is set, if the request was canceled instead.
+func (d *DefaultClientDispatcher) completeRequest(requestId string, canceledErr *ocpp.Error) {
 	el := d.requestQueue.Peek()
 	if el == nil {
-		log.Errorf("attempting to pop front of queue, but queue is empty")
+		d.getLogger().With(logging.UniqueID(requestId)).Errorf("attempting to pop front of queue, but queue is empty")
 		return
 	}
 	bundle, _ := el.(RequestBundle)
 	if bundle.Call.UniqueId != requestId {
-		log.Errorf("internal state mismatch: received response for %v but expected response for %v", requestId, bundle.Call.UniqueId)
+		d.getLogger().With(logging.UniqueID(requestId)).Errorf("internal state mismatch: received response for %v but expected response for %v", requestId, bundle.Call.UniqueId)
 		return
 	}
 	d.requestQueue.Pop()
 	d.pendingRequestState.DeletePendingRequest(requestId)
-	log.Debugf("removed request %v from front of queue", bundle.Call.UniqueId)
+	d.flush.record(canceledErr)
+	d.getLogger().With(logging.Action(bundle.Call.Action), logging.UniqueID(requestId)).Debugf("removed request %v from front of queue", bundle.Call.UniqueId)
 	// Signal that next message in queue may be sent
 	d.readyForDispatch <- true
 }
@@ -357,6 +485,23 @@ type ServerDispatcher interface {
 	DeleteClient(clientID string)
 }
 
+// RequestCanceler is implemented by server dispatchers, which support canceling outstanding requests,
+// such as DefaultServerDispatcher.
+//
+// It is not part of ServerDispatcher, so that existing ServerDispatcher implementations remain valid.
+// Server.CancelRequest checks for it with a type assertion.
+type RequestCanceler interface {
+	// Cancels an outstanding request for a specific client, identified by its unique message ID.
+	//
+	// If the request is still queued, it is simply removed from the queue.
+	// If the request was already sent, it is no longer considered pending: a late response will be discarded,
+	// and the next queued request for the client may be dispatched.
+	//
+	// The OnRequestCanceled callback is NOT invoked for requests canceled via this function.
+	// If no such request is outstanding (e.g. because a response was already received), an error is returned.
+	CancelRequest(clientID string, requestID string) error
+}
+
 // DefaultServerDispatcher is a default implementation of the ServerDispatcher interface.
 //
 // The dispatcher implements the ClientState as well for simplicity.
@@ -365,6 +510,7 @@ type DefaultServerDispatcher struct {
 	queueMap            ServerQueueMap
 	requestChannel      chan string
 	readyForDispatch    chan string
+	cancelC             chan cancelRequest
 	pendingRequestState ServerState
 	timeout             time.Duration
 	timerC              chan string
@@ -373,21 +519,61 @@ type DefaultServerDispatcher struct {
 	onRequestCancel     CanceledRequestHandler
 	network             ws.WsServer
 	mutex               sync.RWMutex
+	logger              logging.Logger
+	clock               clock.Clock
+	throttleC           chan string
+	minSendInterval     time.Duration
+	minSendIntervals    map[string]time.Duration
+	onRequestThrottled  func(clientID string, call *Call, delay time.Duration)
+	bus                 *events.Bus
 }
 
 // Handler function to be invoked when a request gets canceled (either due to timeout or to other external factors).
 type CanceledRequestHandler func(clientID string, requestID string, request ocpp.Request, err *ocpp.Error)
 
+// The description of the GenericError passed to the cancel callbacks, when no response to a request was received in time.
+const RequestTimeoutDescription = "Request timed out"
+
+// Creates the error passed to the cancel callback for a request that timed out. The error wraps a *TimeoutError.
+func newTimeoutError(call *Call) *ocpp.Error {
+	return ocpp.NewError(GenericError, RequestTimeoutDescription, call.UniqueId).WithCause(&TimeoutError{Action: call.Action, UniqueID: call.UniqueId})
+}
+
+// RequestCanceledError is returned to the sender of a request, which was explicitly canceled before a response was received.
+type RequestCanceledError struct {
+	ClientID  string
+	RequestID string
+}
+
+func (e *RequestCanceledError) Error() string {
+	return fmt.Sprintf("request %v for client %v was canceled", e.RequestID, e.ClientID)
+}
+
+// Utility struct for passing a cancellation command to the message pump.
+type cancelRequest struct {
+	clientID  string
+	requestID string
+	resultC   chan error
+}
+
 // Utility struct for passing a client context around and cancel pending requests.
 type clientTimeoutContext struct {
 	ctx    context.Context
 	cancel func()
+	timer  clock.Timer
 }
 
 func (c clientTimeoutContext) isActive() bool {
 	return c.cancel != nil
 }
 
+// Utility struct for keeping track of the send interval of a client.
+type clientThrottle struct {
+	lastSent time.Time
+	since    time.Time // The time at which the queued request was first held back.
+	waiting  bool      // Whether a wake-up is scheduled already.
+}
+
 // NewDefaultServerDispatcher creates a new DefaultServerDispatcher struct.
 func NewDefaultServerDispatcher(queueMap ServerQueueMap) *DefaultServerDispatcher {
 	d := &DefaultServerDispatcher{
@@ -395,16 +581,38 @@ func NewDefaultServerDispatcher(queueMap ServerQueueMap) *DefaultServerDispatche
 		requestChannel:   nil,
 		readyForDispatch: make(chan string, 1),
 		timeout:          defaultMessageTimeout,
+		clock:            clock.New(),
 	}
 	d.pendingRequestState = NewServerState(&d.mutex)
 	return d
 }
 
+// SetLogger sets the logger used by the dispatcher, overriding the package-level logger.
+// Log entries are enriched with the ID of the client the processed request is addressed to.
+// Passing nil restores the package-level logger.
+func (d *DefaultServerDispatcher) SetLogger(logger logging.Logger) {
+	d.logger = logger
+}
+
+func (d *DefaultServerDispatcher) getLogger() logging.Logger {
+	if d.logger != nil {
+		return d.logger
+	}
+	return log
+}
+
+// Returns the logger for entries related to a specific client.
+func (d *DefaultServerDispatcher) clientLogger(clientID string) logging.Logger {
+	return d.getLogger().With(logging.ChargePointID(clientID))
+}
+
 func (d *DefaultServerDispatcher) Start() {
 	d.mutex.Lock()
 	defer d.mutex.Unlock()
 	d.requestChannel = make(chan string, 20)
+	d.cancelC = make(chan cancelRequest)
 	d.timerC = make(chan string, 10)
+	d.throttleC = make(chan string, 10)
 	d.stoppedC = make(chan struct{}, 1)
 	d.running = true
 	go d.messagePump()
@@ -427,6 +635,55 @@ func (d *DefaultServerDispatcher) SetTimeout(timeout time.Duration) {
 	d.timeout = timeout
 }
 
+// Timeout returns the maximum time to wait for a response to a sent request.
+func (d *DefaultServerDispatcher) Timeout() time.Duration {
+	return d.timeout
+}
+
+// SetClock sets the clock used for request timeouts. Passing nil restores the real clock.
+// The clock must be set before starting the dispatcher.
+func (d *DefaultServerDispatcher) SetClock(c clock.Clock) {
+	d.clock = clock.OrDefault(c)
+}
+
+// SetMinSendInterval sets the minimum gap between sending two consecutive requests to the same client.
+// A request becoming ready for dispatch earlier is held back, the timeout only starts once it is sent.
+// Passing zero disables throttling.
+func (d *DefaultServerDispatcher) SetMinSendInterval(interval time.Duration) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.minSendInterval = interval
+}
+
+// SetMinSendIntervalOverrides sets the minimum send interval for specific clients, by client ID.
+// Clients not contained in the map use the interval passed to SetMinSendInterval.
+// Passing nil removes all overrides.
+func (d *DefaultServerDispatcher) SetMinSendIntervalOverrides(overrides map[string]time.Duration) {
+	intervals := make(map[string]time.Duration, len(overrides))
+	for id, interval := range overrides {
+		intervals[id] = interval
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.minSendIntervals = intervals
+}
+
+// SetOnRequestThrottled sets a callback, invoked whenever a request is sent after being held back
+// by the minimum send interval. The callback receives the time the request was held back for.
+func (d *DefaultServerDispatcher) SetOnRequestThrottled(cb func(clientID string, call *Call, delay time.Duration)) {
+	d.onRequestThrottled = cb
+}
+
+// Returns the minimum send interval for a client.
+func (d *DefaultServerDispatcher) sendInterval(clientID string) time.Duration {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	if interval, ok := d.minSendIntervals[clientID]; ok {
+		return interval
+	}
+	return d.minSendInterval
+}
+
 func (d *DefaultServerDispatcher) CreateClient(clientID string) {
 	if d.IsRunning() {
 		_ = d.queueMap.GetOrCreate(clientID)
@@ -442,6 +699,25 @@ func (d *DefaultServerDispatcher) DeleteClient(clientID string) {
 	}
 }
 
+func (d *DefaultServerDispatcher) CancelRequest(clientID string, requestID string) error {
+	d.mutex.RLock()
+	if !d.running {
+		d.mutex.RUnlock()
+		return fmt.Errorf("cannot cancel request %v, dispatcher %w", requestID, ErrNotStarted)
+	}
+	cancelC := d.cancelC
+	stoppedC := d.stoppedC
+	d.mutex.RUnlock()
+	cmd := cancelRequest{clientID: clientID, requestID: requestID, resultC: make(chan error, 1)}
+	// Cancellation is processed by the message pump, to avoid races with the dispatch of requests
+	select {
+	case cancelC <- cmd:
+		return <-cmd.resultC
+	case <-stoppedC:
+		return fmt.Errorf("cannot cancel request %v, dispatcher %w", requestID, ErrNotStarted)
+	}
+}
+
 func (d *DefaultServerDispatcher) SetNetworkServer(server ws.WsServer) {
 	d.network = server
 }
@@ -450,6 +726,11 @@ func (d *DefaultServerDispatcher) SetOnRequestCanceled(cb CanceledRequestHandler
 	d.onRequestCancel = cb
 }
 
+// SetEventBus sets a bus, on which RequestTimedOut and QueueOverflow events are published. Passing nil disables publishing.
+func (d *DefaultServerDispatcher) SetEventBus(bus *events.Bus) {
+	d.bus = bus
+}
+
 func (d *DefaultServerDispatcher) SetPendingRequestState(state ServerState) {
 	d.pendingRequestState = state
 }
@@ -460,9 +741,12 @@ func (d *DefaultServerDispatcher) SendRequest(clientID string, req RequestBundle
 	}
 	q, ok := d.queueMap.Get(clientID)
 	if !ok {
-		return fmt.Errorf("cannot send request %s, no client %s exists", req.Call.UniqueId, clientID)
+		return fmt.Errorf("cannot send request %s to %s: %w", req.Call.UniqueId, clientID, ws.ErrNotConnected)
 	}
 	if err := q.Push(req); err != nil {
+		if errors.Is(err, ErrQueueFull) {
+			d.bus.Publish(events.QueueOverflowEvent{Peer: clientID, RequestID: req.Call.UniqueId, Action: req.Call.Action})
+		}
 		return err
 	}
 	d.mutex.RLock()
@@ -480,12 +764,17 @@ func (d *DefaultServerDispatcher) messagePump() {
 	var clientCtx clientTimeoutContext
 	var clientQueue RequestQueue
 	clientContextMap := map[string]clientTimeoutContext{} // Empty at the beginning
+	throttles := map[string]clientThrottle{}
 
 	reqChan := func() chan string {
 		d.mutex.RLock()
 		defer d.mutex.RUnlock()
 		return d.requestChannel
 	}
+	d.mutex.RLock()
+	cancelC := d.cancelC
+	throttleC := d.throttleC
+	d.mutex.RUnlock()
 
 	// Dispatcher Loop
 	for {
@@ -493,7 +782,7 @@ func (d *DefaultServerDispatcher) messagePump() {
 		case <-d.stoppedC:
 			// Server was stopped
 			d.queueMap.Init()
-			log.Info("stopped processing requests")
+			d.getLogger().Info("stopped processing requests")
 			return
 		case clientID = <-reqChan():
 			// Check whether there is a request queue for the specified client
@@ -503,6 +792,7 @@ func (d *DefaultServerDispatcher) messagePump() {
 				// Deleting and canceling the context
 				clientCtx = clientContextMap[clientID]
 				delete(clientContextMap, clientID)
+				delete(throttles, clientID)
 				if clientCtx.ctx != nil {
 					clientCtx.cancel()
 				}
@@ -523,7 +813,7 @@ func (d *DefaultServerDispatcher) messagePump() {
 				continue
 			}
 			// Canceling timeout context
-			log.Debugf("timeout for client %v, canceling message", clientID)
+			d.clientLogger(clientID).Debugf("timeout for client %v, canceling message", clientID)
 			clientCtx = clientContextMap[clientID]
 			if clientCtx.isActive() {
 				clientCtx.cancel()
@@ -534,11 +824,11 @@ func (d *DefaultServerDispatcher) messagePump() {
 				q, _ := d.queueMap.Get(clientID)
 				bundle, _ := q.Peek().(RequestBundle)
 				d.CompleteRequest(clientID, bundle.Call.UniqueId)
-				log.Infof("request %v for %v timed out", bundle.Call.UniqueId, clientID)
+				d.clientLogger(clientID).With(logging.Action(bundle.Call.Action), logging.UniqueID(bundle.Call.UniqueId)).Infof("request %v for %v timed out", bundle.Call.UniqueId, clientID)
 				if d.onRequestCancel != nil {
-					d.onRequestCancel(clientID, bundle.Call.UniqueId, bundle.Call.Payload,
-						ocpp.NewError(GenericError, "Request timed out", bundle.Call.UniqueId))
+					d.onRequestCancel(clientID, bundle.Call.UniqueId, bundle.Call.Payload, newTimeoutError(bundle.Call))
 				}
+				d.bus.Publish(events.RequestTimedOutEvent{Peer: clientID, RequestID: bundle.Call.UniqueId, Action: bundle.Call.Action})
 			}
 		case clientID = <-d.readyForDispatch:
 			// Cancel previous timeout (if any)
@@ -553,38 +843,108 @@ func (d *DefaultServerDispatcher) messagePump() {
 				// Ready to transmit
 				rdy = true
 			}
-			log.Debugf("%v ready to transmit again", clientID)
+			d.clientLogger(clientID).Debugf("%v ready to transmit again", clientID)
+		case clientID = <-throttleC:
+			// Minimum send interval elapsed
+			if throttle, ok := throttles[clientID]; ok {
+				throttle.waiting = false
+				throttles[clientID] = throttle
+			}
+			clientQueue, rdy = d.queueMap.Get(clientID)
+			rdy = rdy && !clientContextMap[clientID].isActive()
+		case cmd := <-cancelC:
+			clientID = cmd.clientID
+			wasPending, err := d.cancelRequest(cmd.clientID, cmd.requestID)
+			cmd.resultC <- err
+			rdy = false
+			if err == nil && wasPending {
+				// Stop timeout for the canceled request. The client can now transmit again.
+				clientCtx = clientContextMap[clientID]
+				if clientCtx.isActive() {
+					clientCtx.cancel()
+					clientContextMap[clientID] = clientTimeoutContext{}
+				}
+				clientQueue, rdy = d.queueMap.Get(clientID)
+			}
 		}
 
 		// Only dispatch request if able to send and request queue isn't empty
 		if rdy && clientQueue != nil && !clientQueue.IsEmpty() {
+			now := d.clock.Now()
+			throttle := throttles[clientID]
+			if wait := d.sendInterval(clientID) - now.Sub(throttle.lastSent); wait > 0 {
+				// Too early, hold back the request until the interval elapsed
+				if !throttle.waiting {
+					throttle.waiting = true
+					if throttle.since.IsZero() {
+						throttle.since = now
+					}
+					throttles[clientID] = throttle
+					go d.waitForThrottle(clientID, wait)
+				}
+				rdy = false
+				continue
+			}
+			bundle, _ := clientQueue.Peek().(RequestBundle)
+			throttles[clientID] = clientThrottle{lastSent: now}
 			// Send request & set new context
 			clientCtx = d.dispatchNextRequest(clientID)
 			clientContextMap[clientID] = clientCtx
 			if clientCtx.isActive() {
 				go d.waitForTimeout(clientID, clientCtx)
 			}
+			if !throttle.since.IsZero() && d.onRequestThrottled != nil {
+				d.onRequestThrottled(clientID, bundle.Call, now.Sub(throttle.since))
+			}
 			// Update ready state
 			rdy = false
 		}
 	}
 }
 
+// Removes a request from a client queue. Returns true if the request was already sent and pending.
+// This method is only invoked by the message pump.
+func (d *DefaultServerDispatcher) cancelRequest(clientID string, requestID string) (bool, error) {
+	q, ok := d.queueMap.Get(clientID)
+	if !ok {
+		return false, fmt.Errorf("cannot cancel request %s for %s: %w", requestID, clientID, ws.ErrNotConnected)
+	}
+	removable, ok := q.(RemovableQueue)
+	if !ok {
+		return false, fmt.Errorf("cannot cancel request %s for %s, not supported by queue %T", requestID, clientID, q)
+	}
+	el := removable.Remove(func(element interface{}) bool {
+		bundle, _ := element.(RequestBundle)
+		return bundle.Call != nil && bundle.Call.UniqueId == requestID
+	})
+	if el == nil {
+		return false, fmt.Errorf("cannot cancel request %s for %s, %w", requestID, clientID, ErrRequestNotFound)
+	}
+	_, pending := d.pendingRequestState.GetClientState(clientID).GetPendingRequest(requestID)
+	if pending {
+		d.pendingRequestState.DeletePendingRequest(clientID, requestID)
+	}
+	d.clientLogger(clientID).With(logging.UniqueID(requestID)).Infof("canceled request %s for %s", requestID, clientID)
+	return pending, nil
+}
+
 func (d *DefaultServerDispatcher) dispatchNextRequest(clientID string) (clientCtx clientTimeoutContext) {
 	// Get first element in queue
+	logger := d.clientLogger(clientID)
 	q, ok := d.queueMap.Get(clientID)
 	if !ok {
-		log.Errorf("failed to dispatch next request for %s, no request queue available", clientID)
+		logger.Errorf("failed to dispatch next request for %s, no request queue available", clientID)
 		return
 	}
 	el := q.Peek()
 	bundle, _ := el.(RequestBundle)
 	jsonMessage := bundle.Data
 	callID := bundle.Call.GetUniqueId()
+	logger = logger.With(logging.Action(bundle.Call.Action), logging.UniqueID(callID))
 	d.pendingRequestState.AddPendingRequest(clientID, callID, bundle.Call.Payload)
 	err := d.network.Write(clientID, jsonMessage)
 	if err != nil {
-		log.Errorf("error while sending message: %v", err)
+		logger.Errorf("error while sending message: %v", err)
 		// TODO: handle retransmission instead of removing pending request
 		d.CompleteRequest(clientID, callID)
 		if d.onRequestCancel != nil {
@@ -595,55 +955,78 @@ func (d *DefaultServerDispatcher) dispatchNextRequest(clientID string) (clientCt
 	}
 	// Create and return context (only if timeout is set)
 	if d.timeout > 0 {
-		ctx, cancel := context.WithTimeout(context.TODO(), d.timeout)
-		clientCtx = clientTimeoutContext{ctx: ctx, cancel: cancel}
+		ctx, cancel := context.WithCancel(context.TODO())
+		clientCtx = clientTimeoutContext{ctx: ctx, cancel: cancel, timer: d.clock.NewTimer(d.timeout)}
 	}
-	log.Infof("dispatched request %s for %s", callID, clientID)
-	log.Debugf("sent JSON message to %s: %s", clientID, string(jsonMessage))
+	logger.Infof("dispatched request %s for %s", callID, clientID)
+	logger.Debugf("sent JSON message to %s: %s", clientID, jsonMessage)
 	return
 }
 
 func (d *DefaultServerDispatcher) waitForTimeout(clientID string, clientCtx clientTimeoutContext) {
 	defer clientCtx.cancel()
-	log.Debugf("started timeout timer for %s", clientID)
+	defer clientCtx.timer.Stop()
+	d.clientLogger(clientID).Debugf("started timeout timer for %s", clientID)
 	select {
-	case <-clientCtx.ctx.Done():
-		err := clientCtx.ctx.Err()
-		if err == context.DeadlineExceeded {
-			// Timeout triggered, notifying messagePump
-			d.mutex.RLock()
-			defer d.mutex.RUnlock()
-			if d.running {
-				d.timerC <- clientID
-			}
-		} else {
-			log.Debugf("timeout canceled for %s", clientID)
+	case <-clientCtx.timer.C():
+		if clientCtx.ctx.Err() != nil {
+			// The timeout was canceled concurrently
+			d.clientLogger(clientID).Debugf("timeout canceled for %s", clientID)
+			return
 		}
+		// Timeout triggered, notifying messagePump
+		d.mutex.RLock()
+		defer d.mutex.RUnlock()
+		if d.running {
+			d.timerC <- clientID
+		}
+	case <-clientCtx.ctx.Done():
+		d.clientLogger(clientID).Debugf("timeout canceled for %s", clientID)
 	case <-d.stoppedC:
 		// Server was stopped, every pending timeout gets canceled
 	}
 }
 
+// Notifies the message pump once the send interval for a client elapsed.
+func (d *DefaultServerDispatcher) waitForThrottle(clientID string, wait time.Duration) {
+	d.mutex.RLock()
+	throttleC := d.throttleC
+	stoppedC := d.stoppedC
+	d.mutex.RUnlock()
+	timer := d.clock.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C():
+		select {
+		case throttleC <- clientID:
+		case <-stoppedC:
+		}
+	case <-stoppedC:
+		// Server was stopped, every pending wake-up gets canceled
+	}
+}
+
 func (d *DefaultServerDispatcher) CompleteRequest(clientID string, requestID string) {
+	logger := d.clientLogger(clientID).With(logging.UniqueID(requestID))
 	q, ok := d.queueMap.Get(clientID)
 	if !ok {
-		log.Errorf("attempting to complete request for client %v, but no matching queue found", clientID)
+		logger.Errorf("attempting to complete request for client %v, but no matching queue found", clientID)
 		return
 	}
 	el := q.Peek()
 	if el == nil {
-		log.Errorf("attempting to pop front of queue, but queue is empty")
+		logger.Errorf("attempting to pop front of queue, but queue is empty")
 		return
 	}
 	bundle, _ := el.(RequestBundle)
 	callID := bundle.Call.GetUniqueId()
 	if callID != requestID {
-		log.Errorf("internal state mismatch: processing response for %v but expected response for %v", requestID, callID)
+		logger.Errorf("internal state mismatch: processing response for %v but expected response for %v", requestID, callID)
 		return
 	}
 	q.Pop()
 	d.pendingRequestState.DeletePendingRequest(clientID, requestID)
-	log.Debugf("completed request %s for %s", callID, clientID)
+	logger.With(logging.Action(bundle.Call.Action)).Debugf("completed request %s for %s", callID, clientID)
 	// Signal that next message in queue may be sent
 	d.readyForDispatch <- clientID
 }
@@ -1,6 +1,7 @@
 package ocppj_test
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -10,8 +11,11 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 
+	"github.com/lorenzodonini/ocpp-go/clock/clocktest"
+	"github.com/lorenzodonini/ocpp-go/events"
 	"github.com/lorenzodonini/ocpp-go/ocpp"
 	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/lorenzodonini/ocpp-go/ws"
 )
 
 type ServerDispatcherTestSuite struct {
@@ -209,28 +213,284 @@ func (s *ServerDispatcherTestSuite) TestServerDispatcherTimeout() {
 		assert.Equal(t, req, request)
 		assert.Equal(t, ocppj.GenericError, err.Code)
 		assert.Equal(t, "Request timed out", err.Description)
+		var timeoutErr *ocppj.TimeoutError
+		require.True(t, errors.As(err, &timeoutErr))
+		assert.Equal(t, MockFeatureName, timeoutErr.Action)
+		assert.Equal(t, requestID, timeoutErr.UniqueID)
 		canceled <- true
 	})
 	// Set timeout and start
-	timeout := time.Second * 1
+	fakeClock := clocktest.NewFakeClock(time.Now())
+	s.dispatcher.(*ocppj.DefaultServerDispatcher).SetClock(fakeClock)
+	timeout := time.Second * 30
 	s.dispatcher.SetTimeout(timeout)
 	s.dispatcher.Start()
 	require.True(t, s.dispatcher.IsRunning())
 	// Simulate client connection
 	s.dispatcher.CreateClient(clientID)
 	// Send mock request
-	startTime := time.Now()
 	err = s.dispatcher.SendRequest(clientID, bundle)
 	require.NoError(t, err)
-	// Wait for timeout, canceled callback will be invoked
+	// Wait for the timeout timer to be started, then let it expire
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(timeout - time.Millisecond)
+	select {
+	case <-canceled:
+		require.Fail(t, "request canceled before timeout")
+	case <-time.After(50 * time.Millisecond):
+	}
+	fakeClock.Advance(time.Millisecond)
+	// Canceled callback will be invoked
 	_, ok := <-canceled
 	assert.True(t, ok)
-	elapsed := time.Since(startTime)
-	assert.GreaterOrEqual(t, elapsed.Seconds(), timeout.Seconds())
 	clientQ, _ := s.queueMap.Get(clientID)
 	assert.True(t, clientQ.IsEmpty())
 }
 
+func (s *ServerDispatcherTestSuite) newBundle(value string) ocppj.RequestBundle {
+	req := newMockRequest(value)
+	call, err := s.endpoint.CreateCall(req)
+	require.NoError(s.T(), err)
+	data, err := call.MarshalJSON()
+	require.NoError(s.T(), err)
+	return ocppj.RequestBundle{Call: call, Data: data}
+}
+
+func (s *ServerDispatcherTestSuite) TestServerQueueOverflowEvent() {
+	t := s.T()
+	clientID := "client1"
+	s.websocketServer.On("Write", mock.AnythingOfType("string"), mock.Anything).Return(nil)
+	bus := events.NewBus(10)
+	eventC, unsubscribe := bus.Subscribe(events.QueueOverflow)
+	defer unsubscribe()
+	s.dispatcher.(*ocppj.DefaultServerDispatcher).SetEventBus(bus)
+	s.dispatcher.Start()
+	s.dispatcher.CreateClient(clientID)
+	// No response is received, so the queue fills up
+	for i := 0; i < 10; i++ {
+		require.NoError(t, s.dispatcher.SendRequest(clientID, s.newBundle(fmt.Sprintf("value%v", i))))
+	}
+	assert.Len(t, eventC, 0)
+	bundle := s.newBundle("overflow")
+	err := s.dispatcher.SendRequest(clientID, bundle)
+	require.ErrorIs(t, err, ocppj.ErrQueueFull)
+	require.Len(t, eventC, 1)
+	assert.Equal(t, events.QueueOverflowEvent{Peer: clientID, RequestID: bundle.Call.UniqueId, Action: MockFeatureName}, <-eventC)
+}
+
+func (s *ServerDispatcherTestSuite) TestServerCancelQueuedRequest() {
+	t := s.T()
+	// Setup
+	clientID := "client1"
+	sent := make(chan string, 2)
+	s.websocketServer.On("Write", mock.AnythingOfType("string"), mock.Anything).Run(func(args mock.Arguments) {
+		data, _ := args.Get(1).([]byte)
+		sent <- string(data)
+	}).Return(nil)
+	s.dispatcher.SetOnRequestCanceled(func(cID string, rID string, request ocpp.Request, err *ocpp.Error) {
+		require.Fail(t, "unexpected OnRequestCanceled")
+	})
+	s.dispatcher.Start()
+	s.dispatcher.CreateClient(clientID)
+	bundle1 := s.newBundle("first")
+	bundle2 := s.newBundle("second")
+	require.NoError(t, s.dispatcher.SendRequest(clientID, bundle1))
+	require.NoError(t, s.dispatcher.SendRequest(clientID, bundle2))
+	<-sent
+	// Second request is still queued, since the first one is pending
+	q, _ := s.queueMap.Get(clientID)
+	require.Equal(t, 2, q.Size())
+	err := s.dispatcher.(ocppj.RequestCanceler).CancelRequest(clientID, bundle2.Call.UniqueId)
+	require.NoError(t, err)
+	assert.Equal(t, 1, q.Size())
+	assert.True(t, s.state.HasPendingRequest(clientID))
+	// Completing the first request doesn't dispatch the canceled one
+	s.dispatcher.CompleteRequest(clientID, bundle1.Call.UniqueId)
+	assert.True(t, q.IsEmpty())
+	select {
+	case data := <-sent:
+		require.Fail(t, "unexpected write", data)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func (s *ServerDispatcherTestSuite) TestServerCancelPendingRequest() {
+	t := s.T()
+	// Setup
+	clientID := "client1"
+	sent := make(chan string, 2)
+	s.websocketServer.On("Write", mock.AnythingOfType("string"), mock.Anything).Run(func(args mock.Arguments) {
+		data, _ := args.Get(1).([]byte)
+		sent <- string(data)
+	}).Return(nil)
+	s.dispatcher.SetOnRequestCanceled(func(cID string, rID string, request ocpp.Request, err *ocpp.Error) {
+		require.Fail(t, "unexpected OnRequestCanceled")
+	})
+	timeout := 500 * time.Millisecond
+	s.dispatcher.SetTimeout(timeout)
+	s.dispatcher.Start()
+	s.dispatcher.CreateClient(clientID)
+	bundle1 := s.newBundle("first")
+	bundle2 := s.newBundle("second")
+	require.NoError(t, s.dispatcher.SendRequest(clientID, bundle1))
+	require.NoError(t, s.dispatcher.SendRequest(clientID, bundle2))
+	assert.Equal(t, string(bundle1.Data), <-sent)
+	require.True(t, s.state.HasPendingRequest(clientID))
+	// Cancel the pending request, the next one is dispatched right away
+	err := s.dispatcher.(ocppj.RequestCanceler).CancelRequest(clientID, bundle1.Call.UniqueId)
+	require.NoError(t, err)
+	assert.Equal(t, string(bundle2.Data), <-sent)
+	_, pending := s.state.GetClientState(clientID).GetPendingRequest(bundle1.Call.UniqueId)
+	assert.False(t, pending)
+	_, pending = s.state.GetClientState(clientID).GetPendingRequest(bundle2.Call.UniqueId)
+	assert.True(t, pending)
+	s.dispatcher.CompleteRequest(clientID, bundle2.Call.UniqueId)
+	// Assert that no timeout is invoked for the canceled request
+	time.Sleep(timeout + 200*time.Millisecond)
+}
+
+func (s *ServerDispatcherTestSuite) TestServerCancelCompletedRequest() {
+	t := s.T()
+	// Setup
+	clientID := "client1"
+	sent := make(chan bool, 1)
+	s.websocketServer.On("Write", mock.AnythingOfType("string"), mock.Anything).Run(func(args mock.Arguments) {
+		sent <- true
+	}).Return(nil)
+	s.dispatcher.Start()
+	s.dispatcher.CreateClient(clientID)
+	bundle := s.newBundle("somevalue")
+	require.NoError(t, s.dispatcher.SendRequest(clientID, bundle))
+	<-sent
+	// The response wins over the cancellation
+	s.dispatcher.CompleteRequest(clientID, bundle.Call.UniqueId)
+	err := s.dispatcher.(ocppj.RequestCanceler).CancelRequest(clientID, bundle.Call.UniqueId)
+	assert.True(t, errors.Is(err, ocppj.ErrRequestNotFound))
+	// Unknown client
+	err = s.dispatcher.(ocppj.RequestCanceler).CancelRequest("unknownClient", bundle.Call.UniqueId)
+	assert.True(t, errors.Is(err, ws.ErrNotConnected))
+}
+
+var _ ocppj.RequestCanceler = (*ocppj.DefaultServerDispatcher)(nil)
+var _ ocppj.RemovableQueue = (*ocppj.FIFOClientQueue)(nil)
+
+type nonRemovableQueue struct {
+	ocppj.RequestQueue
+}
+
+func (s *ServerDispatcherTestSuite) TestServerCancelRequestUnsupportedQueue() {
+	t := s.T()
+	// Setup
+	clientID := "client1"
+	sent := make(chan bool, 1)
+	s.websocketServer.On("Write", mock.AnythingOfType("string"), mock.Anything).Run(func(args mock.Arguments) {
+		sent <- true
+	}).Return(nil)
+	s.queueMap.Add(clientID, &nonRemovableQueue{RequestQueue: ocppj.NewFIFOClientQueue(10)})
+	s.dispatcher.Start()
+	s.dispatcher.CreateClient(clientID)
+	bundle := s.newBundle("somevalue")
+	require.NoError(t, s.dispatcher.SendRequest(clientID, bundle))
+	<-sent
+	// Requests can't be removed from the queue, hence they can't be canceled
+	err := s.dispatcher.(ocppj.RequestCanceler).CancelRequest(clientID, bundle.Call.UniqueId)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not supported")
+	assert.True(t, s.state.HasPendingRequest(clientID))
+}
+
+func (s *ServerDispatcherTestSuite) TestServerMinSendInterval() {
+	t := s.T()
+	// Setup
+	clientID := "client1"
+	fakeClock := clocktest.NewFakeClock(time.Now())
+	written := make(chan time.Time, 2)
+	s.websocketServer.On("Write", clientID, mock.Anything).Run(func(args mock.Arguments) {
+		written <- fakeClock.Now()
+	}).Return(nil)
+	canceled := make(chan string, 1)
+	s.dispatcher.SetOnRequestCanceled(func(cID string, rID string, request ocpp.Request, err *ocpp.Error) {
+		canceled <- rID
+	})
+	throttled := make(chan time.Duration, 1)
+	dispatcher := s.dispatcher.(*ocppj.DefaultServerDispatcher)
+	dispatcher.SetOnRequestThrottled(func(cID string, call *ocppj.Call, delay time.Duration) {
+		assert.Equal(t, clientID, cID)
+		throttled <- delay
+	})
+	interval := 10 * time.Second
+	timeout := 30 * time.Second
+	dispatcher.SetClock(fakeClock)
+	dispatcher.SetMinSendInterval(interval)
+	s.dispatcher.SetTimeout(timeout)
+	s.dispatcher.Start()
+	defer s.dispatcher.Stop()
+	s.dispatcher.CreateClient(clientID)
+	// The first request is sent right away
+	bundle1 := s.newBundle("first")
+	bundle2 := s.newBundle("second")
+	require.NoError(t, s.dispatcher.SendRequest(clientID, bundle1))
+	require.NoError(t, s.dispatcher.SendRequest(clientID, bundle2))
+	sent1 := <-written
+	// The response arrives immediately, the second request is held back
+	s.dispatcher.CompleteRequest(clientID, bundle1.Call.UniqueId)
+	select {
+	case <-written:
+		require.Fail(t, "request sent before the minimum send interval elapsed")
+	case <-time.After(50 * time.Millisecond):
+	}
+	// Only the wake-up timer is left, since the timeout of the first request was stopped
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(interval)
+	sent2 := <-written
+	assert.Equal(t, interval, sent2.Sub(sent1))
+	assert.Equal(t, interval, <-throttled)
+	// The timeout only starts once the request was sent
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(timeout - time.Millisecond)
+	select {
+	case <-canceled:
+		require.Fail(t, "request canceled before timeout")
+	case <-time.After(50 * time.Millisecond):
+	}
+	fakeClock.Advance(time.Millisecond)
+	assert.Equal(t, bundle2.Call.UniqueId, <-canceled)
+}
+
+func (s *ServerDispatcherTestSuite) TestServerMinSendIntervalOverrides() {
+	t := s.T()
+	// Setup
+	fakeClock := clocktest.NewFakeClock(time.Now())
+	written := make(chan string, 4)
+	s.websocketServer.On("Write", mock.AnythingOfType("string"), mock.Anything).Run(func(args mock.Arguments) {
+		written <- args.String(0)
+	}).Return(nil)
+	dispatcher := s.dispatcher.(*ocppj.DefaultServerDispatcher)
+	dispatcher.SetClock(fakeClock)
+	dispatcher.SetMinSendInterval(time.Minute)
+	dispatcher.SetMinSendIntervalOverrides(map[string]time.Duration{"fastClient": 0})
+	s.dispatcher.Start()
+	defer s.dispatcher.Stop()
+	// Requests to a client without throttling are sent back-to-back
+	for _, clientID := range []string{"slowClient", "fastClient"} {
+		s.dispatcher.CreateClient(clientID)
+		for i := 0; i < 2; i++ {
+			bundle := s.newBundle(fmt.Sprintf("value%d", i))
+			require.NoError(t, s.dispatcher.SendRequest(clientID, bundle))
+			if i == 0 {
+				assert.Equal(t, clientID, <-written)
+				s.dispatcher.CompleteRequest(clientID, bundle.Call.UniqueId)
+			}
+		}
+	}
+	assert.Equal(t, "fastClient", <-written)
+	select {
+	case clientID := <-written:
+		require.Fail(t, "unexpected request", "request sent to %v", clientID)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
 type ClientDispatcherTestSuite struct {
 	suite.Suite
 	state           ocppj.ClientState
@@ -345,14 +605,20 @@ func (c *ClientDispatcherTestSuite) TestClientDispatcherTimeout() {
 	data, err := call.MarshalJSON()
 	require.NoError(t, err)
 	bundle := ocppj.RequestBundle{Call: call, Data: data}
-	// Set low timeout to trigger OnRequestCanceled callback
-	c.dispatcher.SetTimeout(1 * time.Second)
+	// Set timeout on a fake clock, to trigger OnRequestCanceled callback
+	fakeClock := clocktest.NewFakeClock(time.Now())
+	c.dispatcher.(*ocppj.DefaultClientDispatcher).SetClock(fakeClock)
+	c.dispatcher.SetTimeout(30 * time.Second)
 	c.dispatcher.SetOnRequestCanceled(func(rID string, request ocpp.Request, err *ocpp.Error) {
 		assert.Equal(t, requestID, rID)
 		assert.Equal(t, MockFeatureName, request.GetFeatureName())
 		assert.Equal(t, req, request)
 		assert.Equal(t, ocppj.GenericError, err.Code)
 		assert.Equal(t, "Request timed out", err.Description)
+		var timeoutErr *ocppj.TimeoutError
+		require.True(t, errors.As(err, &timeoutErr))
+		assert.Equal(t, MockFeatureName, timeoutErr.Action)
+		assert.Equal(t, requestID, timeoutErr.UniqueID)
 		timeout <- true
 	})
 	c.dispatcher.Start()
@@ -363,13 +629,77 @@ func (c *ClientDispatcherTestSuite) TestClientDispatcherTimeout() {
 	// Check status after sending request
 	<-writeC
 	assert.True(t, c.state.HasPendingRequest())
-	// Wait for timeout
-	_, ok := <-timeout
-	assert.True(t, ok)
+	// The timeout timer is re-armed right after the write, hence advance until it expired
+	assert.Eventually(t, func() bool {
+		fakeClock.Advance(30 * time.Second)
+		select {
+		case <-timeout:
+			return true
+		default:
+			return false
+		}
+	}, time.Second, 10*time.Millisecond)
 	assert.False(t, c.state.HasPendingRequest())
 	assert.True(t, c.queue.IsEmpty())
 }
 
+func (c *ClientDispatcherTestSuite) TestClientMinSendInterval() {
+	t := c.T()
+	// Setup
+	fakeClock := clocktest.NewFakeClock(time.Now())
+	written := make(chan time.Time, 2)
+	c.websocketClient.On("Write", mock.Anything).Run(func(args mock.Arguments) {
+		written <- fakeClock.Now()
+	}).Return(nil)
+	throttled := make(chan time.Duration, 1)
+	dispatcher := c.dispatcher.(*ocppj.DefaultClientDispatcher)
+	dispatcher.SetOnRequestThrottled(func(call *ocppj.Call, delay time.Duration) {
+		throttled <- delay
+	})
+	sentAt := make(chan time.Time, 2)
+	dispatcher.SetOnRequestSent(func(call *ocppj.Call, at time.Time) {
+		sentAt <- at
+	})
+	interval := 10 * time.Second
+	dispatcher.SetClock(fakeClock)
+	dispatcher.SetMinSendInterval(interval)
+	c.dispatcher.Start()
+	defer c.dispatcher.Stop()
+	bundles := make([]ocppj.RequestBundle, 2)
+	for i := range bundles {
+		call, err := c.endpoint.CreateCall(newMockRequest(fmt.Sprintf("value%d", i)))
+		require.NoError(t, err)
+		data, err := call.MarshalJSON()
+		require.NoError(t, err)
+		bundles[i] = ocppj.RequestBundle{Call: call, Data: data}
+		require.NoError(t, c.dispatcher.SendRequest(bundles[i]))
+	}
+	// The first request is sent right away, the second one is held back
+	sent1 := <-written
+	c.dispatcher.CompleteRequest(bundles[0].Call.UniqueId)
+	select {
+	case <-written:
+		require.Fail(t, "request sent before the minimum send interval elapsed")
+	case <-time.After(50 * time.Millisecond):
+	}
+	var sent2 time.Time
+	require.Eventually(t, func() bool {
+		fakeClock.Advance(time.Second)
+		select {
+		case sent2 = <-written:
+			return true
+		default:
+			return false
+		}
+	}, time.Second, 10*time.Millisecond)
+	assert.GreaterOrEqual(t, sent2.Sub(sent1), interval)
+	assert.GreaterOrEqual(t, <-throttled, interval)
+	// The second request is reported as sent once it was released, not when it was queued
+	first, second := <-sentAt, <-sentAt
+	assert.GreaterOrEqual(t, second.Sub(first), interval)
+	assert.True(t, c.state.HasPendingRequest())
+}
+
 func (c *ClientDispatcherTestSuite) TestClientPauseDispatcher() {
 	t := c.T()
 	// Create mock request
@@ -0,0 +1,100 @@
+package ocppj
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+)
+
+// Buffers grown beyond this size are not returned to the pool, to avoid retaining memory after occasional large messages.
+const maxPooledBufferSize = 64 * 1024
+
+// messageEncoder builds an OCPP-J message array in a reusable buffer.
+//
+// The envelope is written by hand, while every payload is encoded exactly once, directly into the buffer.
+// The output is identical to marshaling the message fields as a JSON array.
+type messageEncoder struct {
+	buf        bytes.Buffer
+	encoder    *json.Encoder
+	escapeHTML bool
+}
+
+var encoderPool = sync.Pool{
+	New: func() interface{} {
+		e := &messageEncoder{}
+		e.encoder = json.NewEncoder(&e.buf)
+		return e
+	},
+}
+
+// Retrieves an encoder from the pool and opens the message array. The encoder must be released after use.
+func newMessageEncoder(typeId MessageType) *messageEncoder {
+	e := encoderPool.Get().(*messageEncoder)
+	e.escapeHTML = EscapeHTML
+	e.encoder.SetEscapeHTML(e.escapeHTML)
+	e.buf.WriteByte('[')
+	e.buf.WriteString(strconv.Itoa(int(typeId)))
+	return e
+}
+
+// Appends a string element to the array.
+func (e *messageEncoder) writeString(s string) error {
+	if !isPlainString(s, e.escapeHTML) {
+		// Escaping is left to the JSON encoder, to keep the output consistent with payloads
+		return e.writeValue(s)
+	}
+	e.buf.WriteByte(',')
+	e.buf.WriteByte('"')
+	e.buf.WriteString(s)
+	e.buf.WriteByte('"')
+	return nil
+}
+
+// Appends an arbitrary element to the array.
+func (e *messageEncoder) writeValue(v interface{}) error {
+	e.buf.WriteByte(',')
+	if err := e.encoder.Encode(v); err != nil {
+		return err
+	}
+	// Drop the newline appended by the encoder
+	e.buf.Truncate(e.buf.Len() - 1)
+	return nil
+}
+
+// Appends a raw JSON element to the array.
+func (e *messageEncoder) writeRaw(raw string) {
+	e.buf.WriteByte(',')
+	e.buf.WriteString(raw)
+}
+
+// Closes the message array and returns a copy of the encoded message, which remains valid after releasing the encoder.
+func (e *messageEncoder) bytes() []byte {
+	e.buf.WriteByte(']')
+	data := make([]byte, e.buf.Len())
+	copy(data, e.buf.Bytes())
+	return data
+}
+
+// Returns the encoder to the pool.
+func (e *messageEncoder) release() {
+	if e.buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	e.buf.Reset()
+	encoderPool.Put(e)
+}
+
+// Returns true if s contains no characters, which the JSON encoder would escape.
+func isPlainString(s string, escapeHTML bool) bool {
+	for i := 0; i < len(s); i++ {
+		switch b := s[i]; {
+		case b < 0x20, b >= utf8.RuneSelf, b == '"', b == '\\':
+			return false
+		case escapeHTML && (b == '<' || b == '>' || b == '&'):
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+package ocppj
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+
+	"gopkg.in/go-playground/validator.v9"
+)
+
+// The errors returned by this package are part of its public API: sentinel errors and error types are kept stable,
+// so callers may rely on errors.Is and errors.As, while the error messages may change at any time.
+//
+// Errors for requests that couldn't be completed are passed to the respective callbacks as *ocpp.Error,
+// which wraps the cause (e.g. a *TimeoutError) where available.
+
+var (
+	// ErrNotStarted is returned when using an endpoint or dispatcher, which isn't running.
+	ErrNotStarted = errors.New("not started")
+	// ErrClientStopped is returned for requests, which were aborted because the client was stopped while waiting for the response.
+	ErrClientStopped = errors.New("client stopped")
+	// ErrUnsupportedFeature is returned when sending a request for a feature, which isn't supported by the endpoint.
+	ErrUnsupportedFeature = errors.New("unsupported action")
+	// ErrQueueFull is returned when a request cannot be sent, because the request queue reached its capacity.
+	ErrQueueFull = errors.New("request queue is full, cannot push new element")
+	// ErrRequestNotFound is returned when canceling a request, which isn't outstanding.
+	ErrRequestNotFound = errors.New("no such request is outstanding")
+)
+
+// TimeoutError is the cause of a request cancellation, whenever no response was received before the configured timeout expired.
+type TimeoutError struct {
+	Action   string
+	UniqueID string
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("no response received for %v request %v before the timeout expired", e.Action, e.UniqueID)
+}
+
+// ValidationError is returned when an outgoing message fails the struct validation.
+// The underlying validator.ValidationErrors are accessible via errors.As as well.
+type ValidationError struct {
+	FieldErrors validator.ValidationErrors
+}
+
+func (e *ValidationError) Error() string {
+	return e.FieldErrors.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.FieldErrors
+}
+
+// Wraps errors returned by the struct validation, leaving other errors untouched.
+func newValidationError(err error) error {
+	var fieldErrors validator.ValidationErrors
+	if errors.As(err, &fieldErrors) {
+		return &ValidationError{FieldErrors: fieldErrors}
+	}
+	return err
+}
+
+// HandlerPanicError is reported whenever a handler or callback of the application panics while being invoked by an endpoint.
+// The panic is recovered, so the connection survives.
+type HandlerPanicError struct {
+	// The ID of the charge point/charging station, the handled message or event refers to.
+	ClientID string
+	// The action of the handled message. Empty for connection events.
+	Action string
+	// The value passed to panic.
+	Value interface{}
+	// The stack trace of the panicking goroutine.
+	Stack []byte
+}
+
+// NewHandlerPanicError creates a HandlerPanicError for a recovered value, capturing the stack trace of the current goroutine.
+// Must be invoked from the deferred function, which recovered the panic.
+func NewHandlerPanicError(clientID string, action string, value interface{}) *HandlerPanicError {
+	return &HandlerPanicError{ClientID: clientID, Action: action, Value: value, Stack: debug.Stack()}
+}
+
+func (e *HandlerPanicError) Error() string {
+	if e.Action == "" {
+		return fmt.Sprintf("handler for client %v panicked: %v", e.ClientID, e.Value)
+	}
+	return fmt.Sprintf("%v handler for client %v panicked: %v", e.Action, e.ClientID, e.Value)
+}
+
+// Unwrap returns the value passed to panic, if it is an error.
+func (e *HandlerPanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
@@ -0,0 +1,197 @@
+package ocppj
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/logging"
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ws"
+)
+
+// The description of the GenericError passed to the cancel callbacks of the requests removed via DrainQueue.
+const RequestDrainedDescription = "Request was drained from the queue"
+
+// QueuedRequest is a request, which was removed from the queue of a client via DrainQueue before being sent.
+type QueuedRequest struct {
+	UniqueID   string
+	Action     string
+	Payload    json.RawMessage // The marshalled request, as sent in the payload of the CALL.
+	EnqueuedAt time.Time
+}
+
+// flushState keeps track of the requests completed by a client dispatcher, for a FlushQueue in progress.
+type flushState struct {
+	flushing  int           // The amount of running FlushQueue calls.
+	completed int           // The amount of requests, for which a response was received.
+	canceled  int           // The amount of requests, which timed out or couldn't be sent.
+	cancelErr error         // The error of the last canceled request.
+	changedC  chan struct{} // Closed and replaced whenever the state of the dispatcher changes.
+	mutex     sync.Mutex
+}
+
+func (f *flushState) active() bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.flushing > 0
+}
+
+// Records a completed request. The error is set, if the request was canceled instead.
+func (f *flushState) record(canceledErr *ocpp.Error) {
+	f.mutex.Lock()
+	if canceledErr != nil {
+		f.canceled++
+		f.cancelErr = canceledErr
+	} else {
+		f.completed++
+	}
+	f.mutex.Unlock()
+	f.notify()
+}
+
+// Wakes up all running FlushQueue calls.
+func (f *flushState) notify() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.changedC != nil {
+		close(f.changedC)
+	}
+	f.changedC = make(chan struct{})
+}
+
+// FlushQueue attempts to deliver all queued requests right away, instead of waiting for the regular request flow,
+// e.g. after an operator fixed a connectivity issue. Requests held back by the minimum send interval are sent immediately.
+//
+// The function blocks until the queue is empty, a request is canceled, the connection is lost or the context expires.
+// Since only one request may be pending at a time, every request is sent only after the response to the previous one was received.
+// Requests enqueued while flushing are delivered as well. Returns the amount of requests, for which a response was received.
+//
+// If a request times out or couldn't be sent, the error passed to the cancel callback is returned.
+// If the dispatcher is paused (i.e. the client is disconnected), an error wrapping ws.ErrNotConnected is returned.
+//
+// May be invoked concurrently to the regular request flow.
+func (d *DefaultClientDispatcher) FlushQueue(ctx context.Context) (int, error) {
+	if !d.IsRunning() {
+		return 0, fmt.Errorf("cannot flush queue, dispatcher %w", ErrNotStarted)
+	}
+	d.flush.mutex.Lock()
+	d.flush.flushing++
+	completed, canceled := d.flush.completed, d.flush.canceled
+	d.flush.mutex.Unlock()
+	defer func() {
+		d.flush.mutex.Lock()
+		d.flush.flushing--
+		d.flush.mutex.Unlock()
+	}()
+	// Wake up the message pump, in case a request is being held back
+	select {
+	case d.flushC <- struct{}{}:
+	default:
+	}
+	for {
+		d.flush.mutex.Lock()
+		changedC := d.flush.changedC
+		sent := d.flush.completed - completed
+		failed, cancelErr := d.flush.canceled > canceled, d.flush.cancelErr
+		d.flush.mutex.Unlock()
+		switch {
+		case failed:
+			return sent, cancelErr
+		case d.requestQueue.IsEmpty():
+			return sent, nil
+		case !d.IsRunning():
+			return sent, fmt.Errorf("cannot flush queue, dispatcher %w", ErrNotStarted)
+		case d.IsPaused():
+			return sent, fmt.Errorf("cannot flush queue: %w", ws.ErrNotConnected)
+		}
+		select {
+		case <-changedC:
+		case <-ctx.Done():
+			return sent, ctx.Err()
+		}
+	}
+}
+
+// DrainQueue atomically removes all queued requests, which weren't sent yet, and returns them in order.
+// A pending request (i.e. a request awaiting a response) is kept.
+//
+// Drained requests are never sent: the cancel callback is invoked for each of them with a GenericError,
+// so that senders awaiting a response are notified. The returned requests may e.g. be persisted and sent again later.
+//
+// May be invoked concurrently to the regular request flow.
+// Requires a request queue implementing RemovableQueue, such as the FIFOClientQueue, otherwise nil is returned.
+func (d *DefaultClientDispatcher) DrainQueue() []QueuedRequest {
+	queue, ok := d.requestQueue.(RemovableQueue)
+	if !ok {
+		d.getLogger().Errorf("cannot drain queue, not supported by queue %T", d.requestQueue)
+		return nil
+	}
+	d.dispatchMutex.Lock()
+	pendingID := ""
+	if d.pendingRequestState.HasPendingRequest() {
+		if bundle, ok := d.requestQueue.Peek().(RequestBundle); ok {
+			pendingID = bundle.Call.UniqueId
+		}
+	}
+	var bundles []RequestBundle
+	for {
+		el := queue.Remove(func(element interface{}) bool {
+			bundle, _ := element.(RequestBundle)
+			return bundle.Call != nil && bundle.Call.UniqueId != pendingID
+		})
+		if el == nil {
+			break
+		}
+		bundles = append(bundles, el.(RequestBundle))
+	}
+	d.dispatchMutex.Unlock()
+	if len(bundles) == 0 {
+		return nil
+	}
+	d.flush.notify()
+	drained := make([]QueuedRequest, 0, len(bundles))
+	for _, bundle := range bundles {
+		payload, _ := json.Marshal(bundle.Call.Payload)
+		drained = append(drained, QueuedRequest{UniqueID: bundle.Call.UniqueId, Action: bundle.Call.Action, Payload: payload, EnqueuedAt: bundle.EnqueuedAt})
+		d.getLogger().With(logging.Action(bundle.Call.Action), logging.UniqueID(bundle.Call.UniqueId)).Infof("drained request %s from queue", bundle.Call.UniqueId)
+		if d.onRequestCancel != nil {
+			d.onRequestCancel(bundle.Call.UniqueId, bundle.Call.Payload, ocpp.NewError(GenericError, RequestDrainedDescription, bundle.Call.UniqueId))
+		}
+	}
+	return drained
+}
+
+// FlushQueue attempts to deliver all queued requests right away and blocks until the queue is empty,
+// a request is canceled, the connection is lost or the context expires. Returns the amount of delivered requests.
+//
+// Requires a dispatcher supporting it, such as the DefaultClientDispatcher. Refer to DefaultClientDispatcher.FlushQueue for details.
+func (c *Client) FlushQueue(ctx context.Context) (int, error) {
+	d, ok := c.dispatcher.(interface {
+		FlushQueue(ctx context.Context) (int, error)
+	})
+	if !ok {
+		return 0, fmt.Errorf("cannot flush queue, not supported by dispatcher %T", c.dispatcher)
+	}
+	return d.FlushQueue(ctx)
+}
+
+// DrainQueue removes all queued requests, which weren't sent yet, and returns them without sending them,
+// e.g. for persisting them before a factory reset. The cancel callback is invoked for every drained request.
+//
+// Requires a dispatcher supporting it, such as the DefaultClientDispatcher, and a queue implementing RemovableQueue,
+// otherwise nil is returned.
+func (c *Client) DrainQueue() []QueuedRequest {
+	d, ok := c.dispatcher.(interface{ DrainQueue() []QueuedRequest })
+	if !ok {
+		return nil
+	}
+	drained := d.DrainQueue()
+	if len(drained) > 0 && !c.RequestState.HasPendingRequest() {
+		// Nothing is awaited from the server anymore
+		c.watchdog.reset()
+	}
+	return drained
+}
@@ -0,0 +1,181 @@
+package ocppj_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/clock/clocktest"
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/lorenzodonini/ocpp-go/ws"
+)
+
+func (c *ClientDispatcherTestSuite) enqueueMockRequest(value string) ocppj.RequestBundle {
+	call, err := c.endpoint.CreateCall(newMockRequest(value))
+	require.NoError(c.T(), err)
+	data, err := call.MarshalJSON()
+	require.NoError(c.T(), err)
+	bundle := ocppj.RequestBundle{Call: call, Data: data}
+	require.NoError(c.T(), c.dispatcher.SendRequest(bundle))
+	return bundle
+}
+
+// Returns the unique ID of a written CALL.
+func writtenUniqueID(args mock.Arguments) string {
+	var fields []interface{}
+	_ = json.Unmarshal(args.Get(0).([]byte), &fields)
+	return fields[1].(string)
+}
+
+func (c *ClientDispatcherTestSuite) TestClientFlushQueue() {
+	t := c.T()
+	dispatcher := c.dispatcher.(*ocppj.DefaultClientDispatcher)
+	// Without flushing, the requests would be held back for a long time
+	dispatcher.SetClock(clocktest.NewFakeClock(time.Now()))
+	dispatcher.SetMinSendInterval(time.Hour)
+	respondC := make(chan struct{})
+	c.websocketClient.On("Write", mock.Anything).Run(func(args mock.Arguments) {
+		uniqueID := writtenUniqueID(args)
+		go func() {
+			<-respondC
+			c.dispatcher.CompleteRequest(uniqueID)
+		}()
+	}).Return(nil)
+	c.dispatcher.Start()
+	defer c.dispatcher.Stop()
+	for i := 0; i < 3; i++ {
+		c.enqueueMockRequest(fmt.Sprintf("value%d", i))
+	}
+	close(respondC)
+	sent, err := dispatcher.FlushQueue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 3, sent)
+	assert.True(t, c.queue.IsEmpty())
+	assert.False(t, c.state.HasPendingRequest())
+	// Flushing an empty queue returns immediately
+	sent, err = dispatcher.FlushQueue(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+}
+
+func (c *ClientDispatcherTestSuite) TestClientFlushQueueInterrupted() {
+	t := c.T()
+	dispatcher := c.dispatcher.(*ocppj.DefaultClientDispatcher)
+	_, err := dispatcher.FlushQueue(context.Background())
+	assert.ErrorIs(t, err, ocppj.ErrNotStarted)
+	writtenC := make(chan string, 3)
+	c.websocketClient.On("Write", mock.Anything).Run(func(args mock.Arguments) {
+		writtenC <- writtenUniqueID(args)
+	}).Return(nil)
+	c.dispatcher.Start()
+	defer c.dispatcher.Stop()
+	for i := 0; i < 3; i++ {
+		c.enqueueMockRequest(fmt.Sprintf("value%d", i))
+	}
+	// The first request is answered, then the connection is lost
+	go func() {
+		c.dispatcher.CompleteRequest(<-writtenC)
+		<-writtenC
+		c.dispatcher.Pause()
+	}()
+	sent, err := dispatcher.FlushQueue(context.Background())
+	assert.ErrorIs(t, err, ws.ErrNotConnected)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, 2, c.queue.Size())
+	// The context limits the time spent flushing
+	c.dispatcher.Resume()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	sent, err = dispatcher.FlushQueue(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 0, sent)
+	assert.Equal(t, 2, c.queue.Size())
+}
+
+func (c *ClientDispatcherTestSuite) TestClientDrainQueue() {
+	t := c.T()
+	var mutex sync.Mutex
+	written := map[string]bool{}
+	c.websocketClient.On("Write", mock.Anything).Run(func(args mock.Arguments) {
+		mutex.Lock()
+		written[writtenUniqueID(args)] = true
+		mutex.Unlock()
+	}).Return(nil)
+	canceled := map[string]*ocpp.Error{}
+	c.dispatcher.SetOnRequestCanceled(func(requestID string, request ocpp.Request, err *ocpp.Error) {
+		mutex.Lock()
+		canceled[requestID] = err
+		mutex.Unlock()
+	})
+	dispatcher := c.dispatcher.(*ocppj.DefaultClientDispatcher)
+	c.dispatcher.Start()
+	defer c.dispatcher.Stop()
+	// Drain the queue, while new requests keep arriving
+	total := 50
+	enqueuedC := make(chan []string, 1)
+	go func() {
+		var enqueued []string
+		for i := 0; i < total; i++ {
+			call, err := c.endpoint.CreateCall(newMockRequest(fmt.Sprintf("value%d", i)))
+			require.NoError(t, err)
+			data, err := call.MarshalJSON()
+			require.NoError(t, err)
+			for errors.Is(c.dispatcher.SendRequest(ocppj.RequestBundle{Call: call, Data: data}), ocppj.ErrQueueFull) {
+				time.Sleep(time.Millisecond)
+			}
+			enqueued = append(enqueued, call.UniqueId)
+		}
+		enqueuedC <- enqueued
+	}()
+	var drained []ocppj.QueuedRequest
+	var enqueued []string
+	for enqueued == nil {
+		select {
+		case enqueued = <-enqueuedC:
+		default:
+		}
+		drained = append(drained, dispatcher.DrainQueue()...)
+	}
+	drained = append(drained, dispatcher.DrainQueue()...)
+	// Only the pending request is left
+	require.Equal(t, 1, c.queue.Size())
+	require.True(t, c.state.HasPendingRequest())
+	pending := c.queue.Peek().(ocppj.RequestBundle).Call.UniqueId
+	require.Len(t, drained, total-1)
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, map[string]bool{pending: true}, written)
+	previous := -1
+	for _, request := range drained {
+		// Drained requests are returned in order
+		index := indexOf(enqueued, request.UniqueID)
+		assert.Greater(t, index, previous)
+		previous = index
+		assert.Equal(t, MockFeatureName, request.Action)
+		assert.False(t, request.EnqueuedAt.IsZero())
+		var payload MockRequest
+		require.NoError(t, json.Unmarshal(request.Payload, &payload))
+		assert.Equal(t, fmt.Sprintf("value%d", index), payload.MockValue)
+		// Senders are notified
+		require.NotNil(t, canceled[request.UniqueID])
+		assert.Equal(t, ocppj.RequestDrainedDescription, canceled[request.UniqueID].Description)
+	}
+	assert.NotContains(t, canceled, pending)
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,236 @@
+package ocppj_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/firmware"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/localauth"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/remotetrigger"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/reservation"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/smartcharging"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/authorization"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/availability"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/data"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/diagnostics"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/display"
+	firmware2 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1/firmware"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/iso15118"
+	localauth2 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1/localauth"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/meter"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/provisioning"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/remotecontrol"
+	reservation2 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1/reservation"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/security"
+	smartcharging2 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1/smartcharging"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/tariffcost"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/transactions"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/lorenzodonini/ocpp-go/ws"
+)
+
+// The unique ID of the request, which is pending on fuzzed endpoints.
+const fuzzPendingID = "pending-1"
+
+// Minimal websocket server for fuzzing, which discards all written messages.
+type discardWsServer struct {
+	ws.WsServer
+	messageHandler func(ws ws.Channel, data []byte) error
+}
+
+func (s *discardWsServer) Start(port int, listenPath string)                              {}
+func (s *discardWsServer) Write(webSocketId string, data []byte) error                    { return nil }
+func (s *discardWsServer) SetNewClientHandler(handler func(ws ws.Channel))                {}
+func (s *discardWsServer) SetDisconnectedClientHandler(handler func(ws ws.Channel))       {}
+func (s *discardWsServer) SetCheckClientHandler(handler func(string, *http.Request) bool) {}
+func (s *discardWsServer) SetMessageHandler(handler func(ws ws.Channel, data []byte) error) {
+	s.messageHandler = handler
+}
+
+// Creates a started server endpoint, supporting the passed profiles and answering all requests.
+func newFuzzServer(dialect ocpp.Dialect, profiles ...*ocpp.Profile) (*ocppj.Server, *discardWsServer) {
+	wsServer := &discardWsServer{}
+	server := ocppj.NewServer(wsServer, nil, nil, profiles...)
+	server.SetDialect(dialect)
+	server.SetRequestHandler(func(client ws.Channel, request ocpp.Request, requestId string, action string) {})
+	server.Start(0, "/{ws}")
+	return server, wsServer
+}
+
+// Feeds a frame to the server endpoint. A request for the passed feature is pending, so responses may be parsed as well.
+func fuzzFrame(t *testing.T, server *ocppj.Server, wsServer *discardWsServer, feature ocpp.Feature, data []byte) {
+	channel := NewMockWebSocket("fuzz")
+	if feature != nil {
+		request := reflect.New(feature.GetRequestType()).Interface().(ocpp.Request)
+		server.RequestState.AddPendingRequest(channel.ID(), fuzzPendingID, request)
+		defer server.RequestState.DeletePendingRequest(channel.ID(), fuzzPendingID)
+	}
+	err := wsServer.messageHandler(channel, data)
+	if err == nil {
+		return
+	}
+	// Anything but malformed JSON must be reported as an OCPP error
+	if _, ok := err.(*ocpp.Error); !ok {
+		var arr []interface{}
+		if json.Unmarshal(data, &arr) == nil {
+			t.Fatalf("unexpected error type %T for %q: %v", err, data, err)
+		}
+	}
+}
+
+func FuzzParseMessage(f *testing.F) {
+	for _, seed := range []string{
+		`[2,"1234","Mock",{"mockValue":"value"}]`,
+		`[3,"pending-1",{"mockValue":"value"}]`,
+		`[4,"pending-1","GenericError","some error",{}]`,
+		`[4,"pending-1","GenericError"]`,
+		`[2,"1234","Mock"]`,
+		`[2,"","Mock",{}]`,
+		`[2.5,"1234","Mock",{}]`,
+		`[5,"1234",{}]`,
+		`[2,1234,"Mock",{}]`,
+		`[2,"1234",null,null]`,
+		`[3,"pending-1",null]`,
+		`[]`,
+		`{}`,
+		`[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]`,
+	} {
+		f.Add([]byte(seed))
+	}
+	server, wsServer := newFuzzServer(ocpp.V16, ocpp.NewProfile("mock", &MockFeature{}))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		fuzzFrame(t, server, wsServer, &MockFeature{}, data)
+		// Parse directly, bypassing the frame checks of the message handler
+		arr, err := ocppj.ParseRawJsonMessage(data)
+		if err != nil {
+			return
+		}
+		state := ocppj.NewClientState()
+		state.AddPendingRequest(fuzzPendingID, newMockRequest("value"))
+		if _, err = server.ParseMessage(arr, state); err != nil {
+			if _, ok := err.(*ocpp.Error); !ok {
+				t.Fatalf("unexpected error type %T for %q: %v", err, data, err)
+			}
+		}
+	})
+}
+
+func FuzzParseCall(f *testing.F) {
+	f.Add("1234", "Mock", []byte(`{"mockValue":"value"}`))
+	f.Add("1234", "Mock", []byte(`{"mockValue":"value","mockAny":[1,"2",{"3":null}]}`))
+	f.Add("1234", "Mock", []byte(`{"mockValue":12}`))
+	f.Add("1234", "Unknown", []byte(`{}`))
+	f.Add("9223372036854775808", "Mock", []byte(`"payload"`))
+	f.Add("-1", "Mock", []byte(`null`))
+	server, wsServer := newFuzzServer(ocpp.V2, ocpp.NewProfile("mock", &MockFeature{}))
+	f.Fuzz(func(t *testing.T, uniqueID string, action string, payload []byte) {
+		id, _ := json.Marshal(uniqueID)
+		name, _ := json.Marshal(action)
+		fuzzFrame(t, server, wsServer, nil, []byte(fmt.Sprintf(`[2,%s,%s,%s]`, id, name, payload)))
+	})
+}
+
+// Fuzzes request and response payloads of every feature of the passed profiles.
+func fuzzPayloads(f *testing.F, dialect ocpp.Dialect, seeds map[string][]string, profiles ...*ocpp.Profile) {
+	features := map[string]ocpp.Feature{}
+	for _, profile := range profiles {
+		for name, feature := range profile.Features {
+			features[name] = feature
+		}
+	}
+	for action, payloads := range seeds {
+		for _, payload := range payloads {
+			f.Add(action, []byte(payload))
+		}
+	}
+	server, wsServer := newFuzzServer(dialect, profiles...)
+	f.Fuzz(func(t *testing.T, action string, payload []byte) {
+		feature, ok := features[action]
+		if !ok {
+			return
+		}
+		name, _ := json.Marshal(action)
+		fuzzFrame(t, server, wsServer, nil, []byte(fmt.Sprintf(`[2,"1234",%s,%s]`, name, payload)))
+		fuzzFrame(t, server, wsServer, feature, []byte(fmt.Sprintf(`[3,"%s",%s]`, fuzzPendingID, payload)))
+	})
+}
+
+func FuzzV16Payload(f *testing.F) {
+	seeds := map[string][]string{
+		core.BootNotificationFeatureName: {
+			`{"chargePointVendor":"EVBox","chargePointModel":"G3","chargePointSerialNumber":"EVB-P1234","firmwareVersion":"4.2.1","iccid":"","imsi":"","meterType":"Elster","meterSerialNumber":"1234"}`,
+			`{"status":"Accepted","currentTime":"2021-03-10T10:21:44.000Z","interval":300}`,
+		},
+		core.StatusNotificationFeatureName: {
+			`{"connectorId":1,"errorCode":"NoError","status":"Preparing","timestamp":"2021-03-10T10:22:11Z","info":"","vendorId":"","vendorErrorCode":""}`,
+		},
+		core.MeterValuesFeatureName: {
+			`{"connectorId":1,"transactionId":1442,"meterValue":[{"timestamp":"2021-03-10T10:30:00Z","sampledValue":[{"value":"1234.5","context":"Sample.Periodic","format":"Raw","measurand":"Energy.Active.Import.Register","location":"Outlet","unit":"Wh"},{"value":"16.1","measurand":"Current.Import","phase":"L1","unit":"A"}]}]}`,
+		},
+		core.StartTransactionFeatureName: {
+			`{"connectorId":1,"idTag":"04A2C3D4E5F601","meterStart":0,"timestamp":"2021-03-10T10:22:15Z"}`,
+			`{"idTagInfo":{"status":"Accepted","expiryDate":"2021-04-10T10:22:15Z","parentIdTag":"PARENT"},"transactionId":1442}`,
+		},
+		core.StopTransactionFeatureName: {
+			`{"idTag":"04A2C3D4E5F601","meterStop":23400,"timestamp":"2021-03-10T12:00:00Z","transactionId":1442,"reason":"EVDisconnected","transactionData":[{"timestamp":"2021-03-10T12:00:00Z","sampledValue":[{"value":"23400"}]}]}`,
+		},
+		core.DataTransferFeatureName: {
+			`{"vendorId":"com.vendor","messageId":"config","data":"{\"nested\":[1,2,3]}"}`,
+			`{"status":"Accepted","data":{"nested":{"deeper":[true,null]}}}`,
+		},
+		smartcharging.SetChargingProfileFeatureName: {
+			`{"connectorId":1,"csChargingProfiles":{"chargingProfileId":10,"stackLevel":0,"chargingProfilePurpose":"TxDefaultProfile","chargingProfileKind":"Recurring","recurrencyKind":"Daily","chargingSchedule":{"duration":86400,"startSchedule":"2021-03-10T00:00:00Z","chargingRateUnit":"A","chargingSchedulePeriod":[{"startPeriod":0,"limit":16.0,"numberPhases":3},{"startPeriod":28800,"limit":32.0}],"minChargingRate":6.0}}}`,
+		},
+		localauth.SendLocalListFeatureName: {
+			`{"listVersion":5,"localAuthorizationList":[{"idTag":"04A2C3D4E5F601","idTagInfo":{"status":"Accepted"}}],"updateType":"Full"}`,
+		},
+		firmware.GetDiagnosticsFeatureName: {
+			`{"location":"ftp://diagnostics.example.com/","retries":3,"retryInterval":60,"startTime":"2021-03-09T00:00:00Z","stopTime":"2021-03-10T00:00:00Z"}`,
+		},
+		reservation.ReserveNowFeatureName: {
+			`{"connectorId":2,"expiryDate":"2021-03-10T11:00:00Z","idTag":"04A2C3D4E5F601","reservationId":7}`,
+		},
+		remotetrigger.TriggerMessageFeatureName: {
+			`{"requestedMessage":"MeterValues","connectorId":1}`,
+		},
+	}
+	fuzzPayloads(f, ocpp.V16, seeds, core.Profile, localauth.Profile, firmware.Profile, reservation.Profile, remotetrigger.Profile, smartcharging.Profile)
+}
+
+func FuzzV201Payload(f *testing.F) {
+	seeds := map[string][]string{
+		provisioning.BootNotificationFeatureName: {
+			`{"reason":"PowerUp","chargingStation":{"model":"Wallbox","vendorName":"Vendor","serialNumber":"SN1234","firmwareVersion":"1.0.3","modem":{"iccid":"8931","imsi":"2620"}}}`,
+			`{"currentTime":"2023-05-02T08:00:00Z","interval":300,"status":"Accepted","statusInfo":{"reasonCode":"OK"}}`,
+		},
+		transactions.TransactionEventFeatureName: {
+			`{"eventType":"Started","timestamp":"2023-05-02T08:05:00Z","triggerReason":"CablePluggedIn","seqNo":0,"transactionInfo":{"transactionId":"f3b1e2a0","chargingState":"EVConnected"},"evse":{"id":1,"connectorId":1},"meterValue":[{"timestamp":"2023-05-02T08:05:00Z","sampledValue":[{"value":0,"measurand":"Energy.Active.Import.Register","unitOfMeasure":{"unit":"Wh","multiplier":0}}]}]}`,
+			`{"totalCost":1.5,"chargingPriority":0,"idTokenInfo":{"status":"Accepted"},"updatedPersonalMessage":{"format":"UTF8","content":"Hello"}}`,
+		},
+		availability.StatusNotificationFeatureName: {
+			`{"timestamp":"2023-05-02T08:04:00Z","connectorStatus":"Occupied","evseId":1,"connectorId":1}`,
+		},
+		authorization.AuthorizeFeatureName: {
+			`{"idToken":{"idToken":"04A2C3D4E5F601","type":"ISO14443","additionalInfo":[{"additionalIdToken":"x","type":"y"}]}}`,
+		},
+		smartcharging2.SetChargingProfileFeatureName: {
+			`{"evseId":1,"chargingProfile":{"id":1,"stackLevel":0,"chargingProfilePurpose":"TxDefaultProfile","chargingProfileKind":"Absolute","chargingSchedule":[{"id":1,"startSchedule":"2023-05-02T00:00:00Z","chargingRateUnit":"W","chargingSchedulePeriod":[{"startPeriod":0,"limit":11000.0,"numberPhases":3}]}]}}`,
+		},
+		provisioning.GetVariablesFeatureName: {
+			`{"getVariableData":[{"component":{"name":"OCPPCommCtrlr"},"variable":{"name":"HeartbeatInterval"},"attributeType":"Actual"}]}`,
+			`{"getVariableResult":[{"attributeStatus":"Accepted","attributeValue":"300","component":{"name":"OCPPCommCtrlr"},"variable":{"name":"HeartbeatInterval"}}]}`,
+		},
+		data.DataTransferFeatureName: {
+			`{"vendorId":"com.vendor","messageId":"blob","data":{"nested":[{"deeper":[1,2,{"deepest":null}]}]}}`,
+		},
+		security.SignCertificateFeatureName: {
+			`{"csr":"-----BEGIN CERTIFICATE REQUEST-----\nMIIB\n-----END CERTIFICATE REQUEST-----","certificateType":"ChargingStationCertificate"}`,
+		},
+	}
+	fuzzPayloads(f, ocpp.V2, seeds, authorization.Profile, availability.Profile, data.Profile, diagnostics.Profile, display.Profile, firmware2.Profile, iso15118.Profile, localauth2.Profile, meter.Profile, provisioning.Profile, remotecontrol.Profile, reservation2.Profile, security.Profile, smartcharging2.Profile, tariffcost.Profile, transactions.Profile)
+}
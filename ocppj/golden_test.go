@@ -0,0 +1,59 @@
+package ocppj_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden frames in testdata/golden")
+
+type goldenFrame struct {
+	name       string
+	message    ocppj.Message
+	escapeHTML bool
+}
+
+func goldenFrames() []goldenFrame {
+	currentTime := types.NewDateTime(time.Date(2024, 1, 1, 12, 30, 0, 0, time.UTC))
+	specialData := "<tag attr=\"a&b\"> ünïcödé\\</tag>"
+	return []goldenFrame{
+		{"call_boot_notification", &ocppj.Call{MessageTypeId: ocppj.CALL, UniqueId: "1234", Action: "BootNotification", Payload: core.NewBootNotificationRequest("model1", "vendor1")}, true},
+		{"call_empty_payload", &ocppj.Call{MessageTypeId: ocppj.CALL, UniqueId: "1234", Action: "Heartbeat", Payload: core.NewHeartbeatRequest()}, true},
+		{"call_nil_payload", &ocppj.Call{MessageTypeId: ocppj.CALL, UniqueId: "1234", Action: "Heartbeat"}, true},
+		{"call_html_escaped", &ocppj.Call{MessageTypeId: ocppj.CALL, UniqueId: "<id\"1>", Action: "DataTransfer", Payload: &core.DataTransferRequest{VendorId: "vendor&co", Data: specialData}}, true},
+		{"call_html_unescaped", &ocppj.Call{MessageTypeId: ocppj.CALL, UniqueId: "<id\"1>", Action: "DataTransfer", Payload: &core.DataTransferRequest{VendorId: "vendor&co", Data: specialData}}, false},
+		{"call_result_boot_notification", &ocppj.CallResult{MessageTypeId: ocppj.CALL_RESULT, UniqueId: "1234", Payload: core.NewBootNotificationConfirmation(currentTime, 60, core.RegistrationStatusAccepted)}, true},
+		{"call_result_html_unescaped", &ocppj.CallResult{MessageTypeId: ocppj.CALL_RESULT, UniqueId: "1234", Payload: &core.DataTransferConfirmation{Status: core.DataTransferStatusAccepted, Data: specialData}}, false},
+		{"call_error_no_details", &ocppj.CallError{MessageTypeId: ocppj.CALL_ERROR, UniqueId: "1234", ErrorCode: ocppj.GenericError, ErrorDescription: "something <went> wrong"}, true},
+		{"call_error_details", &ocppj.CallError{MessageTypeId: ocppj.CALL_ERROR, UniqueId: "1234", ErrorCode: ocppj.NotSupported, ErrorDescription: "", ErrorDetails: map[string]interface{}{"details": specialData, "count": 2}}, false},
+	}
+}
+
+// The wire format of all messages must remain byte-for-byte identical.
+func TestGoldenFrames(t *testing.T) {
+	defer ocppj.SetHTMLEscape(true)
+	for _, frame := range goldenFrames() {
+		ocppj.SetHTMLEscape(frame.escapeHTML)
+		data, err := frame.message.MarshalJSON()
+		require.NoError(t, err, frame.name)
+		path := filepath.Join("testdata", "golden", frame.name+".json")
+		if *updateGolden {
+			require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+			require.NoError(t, os.WriteFile(path, data, 0o644))
+			continue
+		}
+		expected, err := os.ReadFile(path)
+		require.NoError(t, err, frame.name)
+		assert.Equal(t, string(expected), string(data), frame.name)
+	}
+}
@@ -0,0 +1,139 @@
+package ocppj
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/clock"
+	"github.com/lorenzodonini/ocpp-go/logging"
+	"github.com/lorenzodonini/ocpp-go/ws"
+)
+
+type heldRequest struct {
+	client ws.Channel
+	call   *Call
+	timer  clock.Timer
+}
+
+// Buffers incoming requests until the handlers of the server are ready. A zero value is disabled.
+type requestHold struct {
+	holding   bool
+	releasing bool
+	maxHold   time.Duration
+	maxQueued int
+	queue     []*heldRequest
+	mutex     sync.Mutex
+}
+
+// HoldRequestsUntilHandlersReady buffers incoming requests, until HandlersReady is invoked.
+// This is useful if the request handlers are registered asynchronously after starting the server: without holding,
+// requests received in the meantime would be answered with an error.
+//
+// Held requests are passed to the request handler in the order they were received, once HandlersReady is invoked.
+// A request is passed on right away, if it was held for longer than maxHold, or if maxQueued requests are held already.
+// In that case the request is handled as usual (typically answered with a NotImplemented error), and a message is logged.
+// A zero maxHold or maxQueued disables the respective limit.
+//
+// Only incoming requests are held: responses to requests sent by the server, as well as pings, are processed as usual.
+// Requests held for a client, which disconnects, are discarded.
+//
+// Must be invoked before starting the server.
+func (s *Server) HoldRequestsUntilHandlersReady(maxHold time.Duration, maxQueued int) {
+	s.hold.mutex.Lock()
+	defer s.hold.mutex.Unlock()
+	s.hold.holding = true
+	s.hold.maxHold = maxHold
+	s.hold.maxQueued = maxQueued
+}
+
+// HandlersReady passes all requests held since invoking HoldRequestsUntilHandlersReady to the request handler in order,
+// and stops holding further requests. The function returns once all held requests were passed on.
+//
+// Invoking HandlersReady without holding requests has no effect.
+func (s *Server) HandlersReady() {
+	s.hold.mutex.Lock()
+	if !s.hold.holding || s.hold.releasing {
+		s.hold.mutex.Unlock()
+		return
+	}
+	s.hold.releasing = true
+	s.hold.mutex.Unlock()
+	for {
+		s.hold.mutex.Lock()
+		if len(s.hold.queue) == 0 {
+			s.hold.holding = false
+			s.hold.releasing = false
+			s.hold.mutex.Unlock()
+			return
+		}
+		held := s.hold.queue[0]
+		s.hold.queue = s.hold.queue[1:]
+		if held.timer != nil {
+			held.timer.Stop()
+		}
+		s.hold.mutex.Unlock()
+		s.handleCall(held.client, held.call)
+	}
+}
+
+// Holds an incoming request, if needed. Returns false if the request should be handled right away.
+func (h *requestHold) enqueue(s *Server, client ws.Channel, call *Call) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if !h.holding {
+		return false
+	}
+	held := &heldRequest{client: client, call: call}
+	if h.releasing {
+		// Requests received while releasing are queued as well, to preserve the order
+		h.queue = append(h.queue, held)
+		return true
+	}
+	logger := s.clientLogger(client.ID()).With(logging.Action(call.Action), logging.UniqueID(call.UniqueId))
+	if h.maxQueued > 0 && len(h.queue) >= h.maxQueued {
+		logger.Errorf("cannot hold request %v until handlers are ready, %v requests are held already", call.UniqueId, len(h.queue))
+		return false
+	}
+	if h.maxHold > 0 {
+		held.timer = s.getClock().AfterFunc(h.maxHold, func() {
+			if !h.remove(held) {
+				// Released in the meantime
+				return
+			}
+			logger.Errorf("handlers not ready within %v, handling request %v", h.maxHold, call.UniqueId)
+			s.handleCall(client, call)
+		})
+	}
+	h.queue = append(h.queue, held)
+	return true
+}
+
+// Removes a held request from the queue. Returns false if it was not held anymore.
+func (h *requestHold) remove(held *heldRequest) bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for i, r := range h.queue {
+		if r == held {
+			h.queue = append(h.queue[:i], h.queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Discards all requests held for a client. An empty clientID discards the requests of all clients.
+func (h *requestHold) drop(clientID string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	queue := h.queue[:0]
+	for _, held := range h.queue {
+		if clientID != "" && held.client.ID() != clientID {
+			queue = append(queue, held)
+			continue
+		}
+		if held.timer != nil {
+			held.timer.Stop()
+		}
+	}
+	h.queue = queue
+}
@@ -0,0 +1,123 @@
+package ocppj_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/clock/clocktest"
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ws"
+)
+
+// Starts the central system with a connected client, returning the client and a channel receiving all written messages.
+func (suite *OcppJTestSuite) startHoldingServer(clientID string) (ws.Channel, chan []byte, chan string) {
+	handledC := make(chan string, 10)
+	suite.centralSystem.SetRequestHandler(func(client ws.Channel, request ocpp.Request, requestId string, action string) {
+		handledC <- requestId
+	})
+	writeC := make(chan []byte, 10)
+	suite.mockServer.On("Start", mock.AnythingOfType("int"), mock.AnythingOfType("string")).Return()
+	suite.mockServer.On("Write", clientID, mock.Anything).Run(func(args mock.Arguments) {
+		writeC <- args.Get(1).([]byte)
+	}).Return(nil)
+	suite.centralSystem.Start(8887, "somePath")
+	channel := NewMockWebSocket(clientID)
+	suite.mockServer.NewClientHandler(channel)
+	return channel, writeC, handledC
+}
+
+func (suite *OcppJTestSuite) receiveMockCall(channel ws.Channel, uniqueID string) {
+	err := suite.mockServer.MessageHandler(channel, []byte(fmt.Sprintf(`[2,"%v","%v",{"mockValue":"someValue"}]`, uniqueID, MockFeatureName)))
+	require.NoError(suite.T(), err)
+}
+
+func receiveHandled(t require.TestingT, handledC chan string, count int) []string {
+	var handled []string
+	for i := 0; i < count; i++ {
+		select {
+		case id := <-handledC:
+			handled = append(handled, id)
+		case <-time.After(time.Second):
+			require.Fail(t, "request not handled")
+		}
+	}
+	return handled
+}
+
+func (suite *OcppJTestSuite) TestServerHoldRequestsUntilHandlersReady() {
+	t := suite.T()
+	suite.centralSystem.HoldRequestsUntilHandlersReady(time.Minute, 10)
+	responseC := make(chan string, 1)
+	suite.centralSystem.SetResponseHandler(func(client ws.Channel, response ocpp.Response, requestId string) {
+		responseC <- requestId
+	})
+	channel, writeC, handledC := suite.startHoldingServer("1234")
+	for i := 1; i <= 3; i++ {
+		suite.receiveMockCall(channel, fmt.Sprint(i))
+	}
+	assert.Empty(t, handledC)
+	// Responses to our own requests are processed while holding
+	require.NoError(t, suite.centralSystem.SendRequest("1234", newMockRequest("someValue")))
+	var call []interface{}
+	require.NoError(t, json.Unmarshal(<-writeC, &call))
+	requestID := call[1].(string)
+	require.NoError(t, suite.mockServer.MessageHandler(channel, []byte(fmt.Sprintf(`[3,"%v",{"mockValue":"someValue"}]`, requestID))))
+	assert.Equal(t, requestID, <-responseC)
+	assert.Empty(t, handledC)
+	// Held requests are dispatched in order
+	suite.centralSystem.HandlersReady()
+	assert.Equal(t, []string{"1", "2", "3"}, receiveHandled(t, handledC, 3))
+	// Further requests are handled right away
+	suite.receiveMockCall(channel, "4")
+	assert.Equal(t, []string{"4"}, receiveHandled(t, handledC, 1))
+	assert.Empty(t, writeC)
+}
+
+func (suite *OcppJTestSuite) TestServerHoldRequestsOverflow() {
+	t := suite.T()
+	suite.centralSystem.HoldRequestsUntilHandlersReady(time.Minute, 2)
+	channel, _, handledC := suite.startHoldingServer("1234")
+	for i := 1; i <= 3; i++ {
+		suite.receiveMockCall(channel, fmt.Sprint(i))
+	}
+	// The request exceeding the limit is handled right away
+	assert.Equal(t, []string{"3"}, receiveHandled(t, handledC, 1))
+	suite.centralSystem.HandlersReady()
+	assert.Equal(t, []string{"1", "2"}, receiveHandled(t, handledC, 2))
+	assert.Empty(t, handledC)
+}
+
+func (suite *OcppJTestSuite) TestServerHoldRequestsTimeout() {
+	t := suite.T()
+	fakeClock := clocktest.NewFakeClock(time.Now())
+	suite.centralSystem.SetClock(fakeClock)
+	suite.centralSystem.HoldRequestsUntilHandlersReady(10*time.Second, 0)
+	channel, _, handledC := suite.startHoldingServer("1234")
+	suite.receiveMockCall(channel, "1")
+	fakeClock.Advance(5 * time.Second)
+	suite.receiveMockCall(channel, "2")
+	fakeClock.Advance(5*time.Second - time.Millisecond)
+	assert.Empty(t, handledC)
+	// Each request is held for at most maxHold
+	fakeClock.Advance(time.Millisecond)
+	assert.Equal(t, []string{"1"}, receiveHandled(t, handledC, 1))
+	suite.centralSystem.HandlersReady()
+	assert.Equal(t, []string{"2"}, receiveHandled(t, handledC, 1))
+	fakeClock.Advance(time.Minute)
+	assert.Empty(t, handledC)
+}
+
+func (suite *OcppJTestSuite) TestServerHoldRequestsDisconnect() {
+	t := suite.T()
+	suite.centralSystem.HoldRequestsUntilHandlersReady(time.Minute, 0)
+	channel, _, handledC := suite.startHoldingServer("1234")
+	suite.receiveMockCall(channel, "1")
+	suite.mockServer.DisconnectedClientHandler(channel)
+	suite.centralSystem.HandlersReady()
+	assert.Empty(t, handledC)
+}
@@ -0,0 +1,137 @@
+package ocppj
+
+import (
+	"sync"
+
+	"github.com/lorenzodonini/ocpp-go/ws"
+)
+
+// Buffers the messages received from a single client, until a worker processes them.
+type clientInbox struct {
+	channel   ws.Channel
+	messages  chan []byte
+	scheduled bool // True while the inbox is in the ready list, or being processed by a worker.
+	closed    bool
+}
+
+// Dispatches incoming messages via per-client inboxes. A zero value is disabled.
+//
+// Clients with pending messages are kept in a ready list, which is served round-robin by up to maxWorkers goroutines,
+// processing one message per turn. Workers are started on demand and exit once no client has pending messages.
+type inboundDispatcher struct {
+	inboxSize  int
+	maxWorkers int
+	inboxes    map[ws.Channel]*clientInbox
+	ready      []*clientInbox
+	workers    int
+	mutex      sync.Mutex
+}
+
+// SetClientInbox decouples the processing of incoming messages from the connection of each client.
+//
+// By default, every message is parsed, validated and passed to the handlers by the goroutine reading from the connection,
+// hence a slow handler delays reading further messages (and answering pings) of the same client.
+// With inboxes enabled, received messages are buffered in a bounded inbox per client, and processed in order by separate workers.
+// Once the inbox of a client is full, reading from its connection is paused, without affecting other clients.
+//
+// Clients with pending messages are served round-robin, one message at a time, by at most maxWorkers goroutines,
+// which are only running while messages are pending. A zero maxWorkers doesn't limit the amount of workers,
+// i.e. up to one per client. A small limit may therefore cause a slow handler to delay the messages of other clients.
+//
+// Messages still buffered when a client disconnects, or when the server is stopped, are discarded.
+// Errors occurring while processing buffered messages are logged, but not reported to the websocket server.
+//
+// Passing a zero inboxSize disables inboxes. Must be invoked before starting the server.
+func (s *Server) SetClientInbox(inboxSize int, maxWorkers int) {
+	s.inbound.mutex.Lock()
+	defer s.inbound.mutex.Unlock()
+	s.inbound.inboxSize = inboxSize
+	s.inbound.maxWorkers = maxWorkers
+}
+
+func (d *inboundDispatcher) enabled() bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.inboxSize > 0
+}
+
+// Buffers a message in the inbox of a client, blocking while the inbox is full.
+func (d *inboundDispatcher) push(s *Server, channel ws.Channel, data []byte) {
+	d.mutex.Lock()
+	inbox, ok := d.inboxes[channel]
+	if !ok {
+		if d.inboxes == nil {
+			d.inboxes = map[ws.Channel]*clientInbox{}
+		}
+		inbox = &clientInbox{channel: channel, messages: make(chan []byte, d.inboxSize)}
+		d.inboxes[channel] = inbox
+	}
+	d.mutex.Unlock()
+	inbox.messages <- data
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if !inbox.scheduled && !inbox.closed {
+		inbox.scheduled = true
+		d.schedule(s, inbox)
+	}
+}
+
+// Appends an inbox to the ready list, starting a worker if allowed. Must be invoked while holding the mutex.
+func (d *inboundDispatcher) schedule(s *Server, inbox *clientInbox) {
+	d.ready = append(d.ready, inbox)
+	if d.maxWorkers <= 0 || d.workers < d.maxWorkers {
+		d.workers++
+		go d.work(s)
+	}
+}
+
+// Processes messages of ready clients, until no client has pending messages.
+func (d *inboundDispatcher) work(s *Server) {
+	for {
+		d.mutex.Lock()
+		if len(d.ready) == 0 {
+			d.workers--
+			d.mutex.Unlock()
+			return
+		}
+		inbox := d.ready[0]
+		d.ready[0] = nil
+		d.ready = d.ready[1:]
+		var data []byte
+		select {
+		case data = <-inbox.messages:
+		default:
+		}
+		if data == nil || inbox.closed {
+			inbox.scheduled = false
+			d.mutex.Unlock()
+			continue
+		}
+		d.mutex.Unlock()
+		_ = s.handleMessage(inbox.channel, data)
+		d.mutex.Lock()
+		if len(inbox.messages) > 0 && !inbox.closed {
+			// Back to the end of the list, so that other clients are served in the meantime
+			d.ready = append(d.ready, inbox)
+		} else {
+			inbox.scheduled = false
+		}
+		d.mutex.Unlock()
+	}
+}
+
+// Discards the inbox of a client. A nil channel discards the inboxes of all clients.
+func (d *inboundDispatcher) close(channel ws.Channel) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	for c, inbox := range d.inboxes {
+		if channel != nil && c != channel {
+			continue
+		}
+		inbox.closed = true
+		delete(d.inboxes, c)
+		for len(inbox.messages) > 0 {
+			<-inbox.messages
+		}
+	}
+}
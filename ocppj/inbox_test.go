@@ -0,0 +1,157 @@
+package ocppj_test
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ws"
+)
+
+// Starts the central system, with a request handler blocking requests of the slow client until released.
+// Returns the channels receiving the IDs of handled requests, formatted as <clientID>/<requestID>,
+// signaling that the slow client is blocked, and releasing the slow client.
+func (suite *OcppJTestSuite) startInboxServer(slowClientID string) (chan string, chan struct{}, chan struct{}) {
+	handledC := make(chan string, 20)
+	blockedC := make(chan struct{}, 20)
+	releaseC := make(chan struct{})
+	suite.centralSystem.SetRequestHandler(func(client ws.Channel, request ocpp.Request, requestId string, action string) {
+		if client.ID() == slowClientID {
+			blockedC <- struct{}{}
+			<-releaseC
+		}
+		handledC <- fmt.Sprintf("%v/%v", client.ID(), requestId)
+	})
+	suite.mockServer.On("Start", mock.AnythingOfType("int"), mock.AnythingOfType("string")).Return()
+	suite.mockServer.On("Write", mock.AnythingOfType("string"), mock.Anything).Return(nil)
+	suite.centralSystem.Start(8887, "somePath")
+	return handledC, blockedC, releaseC
+}
+
+func (suite *OcppJTestSuite) connectInboxClient(clientID string) ws.Channel {
+	channel := NewMockWebSocket(clientID)
+	suite.mockServer.NewClientHandler(channel)
+	return channel
+}
+
+// Waits for the amount of running goroutines to drop to the expected maximum.
+// Polls without spawning goroutines, unlike require.Eventually.
+func requireGoroutines(t require.TestingT, max int) {
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > max {
+		if time.Now().After(deadline) {
+			require.Fail(t, "goroutines leaked", "expected at most %v goroutines, got %v", max, runtime.NumGoroutine())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (suite *OcppJTestSuite) TestServerClientInboxOrder() {
+	t := suite.T()
+	suite.centralSystem.SetClientInbox(1, 0)
+	handledC, blockedC, releaseC := suite.startInboxServer("1234")
+	channel := suite.connectInboxClient("1234")
+	suite.receiveMockCall(channel, "1")
+	<-blockedC
+	suite.receiveMockCall(channel, "2")
+	// The inbox is full, while the first request is being handled: reading further messages is paused
+	receivedC := make(chan struct{})
+	go func() {
+		suite.receiveMockCall(channel, "3")
+		close(receivedC)
+	}()
+	select {
+	case <-receivedC:
+		require.Fail(t, "message received despite full inbox")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(releaseC)
+	<-receivedC
+	assert.Equal(t, []string{"1234/1", "1234/2", "1234/3"}, receiveHandled(t, handledC, 3))
+}
+
+func (suite *OcppJTestSuite) TestServerClientInboxIsolation() {
+	t := suite.T()
+	suite.centralSystem.SetClientInbox(10, 0)
+	handledC, blockedC, releaseC := suite.startInboxServer("slow")
+	slow := suite.connectInboxClient("slow")
+	fast := suite.connectInboxClient("fast")
+	suite.receiveMockCall(slow, "1")
+	suite.receiveMockCall(slow, "2")
+	<-blockedC
+	// Requests of other clients are handled, while the slow client is busy
+	for i := 1; i <= 3; i++ {
+		suite.receiveMockCall(fast, fmt.Sprint(i))
+	}
+	assert.Equal(t, []string{"fast/1", "fast/2", "fast/3"}, receiveHandled(t, handledC, 3))
+	close(releaseC)
+	assert.Equal(t, []string{"slow/1", "slow/2"}, receiveHandled(t, handledC, 2))
+}
+
+func (suite *OcppJTestSuite) TestServerClientInboxMaxWorkers() {
+	t := suite.T()
+	suite.centralSystem.SetClientInbox(10, 1)
+	handledC, blockedC, releaseC := suite.startInboxServer("slow")
+	slow := suite.connectInboxClient("slow")
+	fast := suite.connectInboxClient("fast")
+	goroutines := runtime.NumGoroutine()
+	suite.receiveMockCall(slow, "1")
+	<-blockedC
+	suite.receiveMockCall(fast, "1")
+	suite.receiveMockCall(fast, "2")
+	// The only worker is busy with the slow client
+	select {
+	case id := <-handledC:
+		require.Fail(t, "unexpected request handled", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.LessOrEqual(t, runtime.NumGoroutine(), goroutines+1)
+	close(releaseC)
+	assert.ElementsMatch(t, []string{"slow/1", "fast/1", "fast/2"}, receiveHandled(t, handledC, 3))
+	// Workers exit once all inboxes are empty
+	requireGoroutines(t, goroutines)
+}
+
+func (suite *OcppJTestSuite) TestServerClientInboxDisconnect() {
+	t := suite.T()
+	suite.centralSystem.SetClientInbox(10, 0)
+	handledC, blockedC, releaseC := suite.startInboxServer("slow")
+	slow := suite.connectInboxClient("slow")
+	goroutines := runtime.NumGoroutine()
+	for i := 1; i <= 3; i++ {
+		suite.receiveMockCall(slow, fmt.Sprint(i))
+	}
+	<-blockedC
+	// Buffered requests are discarded on disconnect
+	suite.mockServer.DisconnectedClientHandler(slow)
+	close(releaseC)
+	assert.Equal(t, []string{"slow/1"}, receiveHandled(t, handledC, 1))
+	requireGoroutines(t, goroutines)
+	assert.Empty(t, handledC)
+}
+
+func (suite *OcppJTestSuite) TestServerClientInboxStop() {
+	t := suite.T()
+	suite.centralSystem.SetClientInbox(10, 0)
+	handledC, blockedC, releaseC := suite.startInboxServer("slow")
+	suite.mockServer.On("Stop").Return()
+	goroutines := runtime.NumGoroutine()
+	slow := suite.connectInboxClient("slow")
+	fast := suite.connectInboxClient("fast")
+	for i := 1; i <= 3; i++ {
+		suite.receiveMockCall(slow, fmt.Sprint(i))
+	}
+	<-blockedC
+	suite.receiveMockCall(fast, "1")
+	assert.Equal(t, []string{"fast/1"}, receiveHandled(t, handledC, 1))
+	suite.centralSystem.Stop()
+	close(releaseC)
+	assert.Equal(t, []string{"slow/1"}, receiveHandled(t, handledC, 1))
+	requireGoroutines(t, goroutines)
+	assert.Empty(t, handledC)
+}
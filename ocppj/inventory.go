@@ -0,0 +1,114 @@
+package ocppj
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ws"
+)
+
+// CapabilityStatus describes how far the capabilities of an endpoint may be trusted.
+type CapabilityStatus string
+
+const (
+	CapabilityUnknown CapabilityStatus = "Unknown" // The endpoint never reported its capabilities.
+	CapabilityCurrent CapabilityStatus = "Current" // The capabilities were reported during the current connection.
+	CapabilityStale   CapabilityStatus = "Stale"   // The capabilities were reported during a previous connection and may be outdated.
+)
+
+// Capabilities lists what an endpoint reported to support. Capabilities are never inferred:
+// if nothing was reported, the status is CapabilityUnknown and the list is empty.
+type Capabilities struct {
+	Status CapabilityStatus `json:"status"`
+	// Where the capabilities were read from, e.g. the SupportedFeatureProfiles configuration key.
+	Source string `json:"source,omitempty"`
+	// The supported feature profiles (OCPP 1.6) or the reported controller components (OCPP 2.0.1).
+	Profiles   []string   `json:"profiles,omitempty"`
+	ReportedAt *time.Time `json:"reportedAt,omitempty"`
+}
+
+// NewCapabilities returns the capabilities reported at the given time, marking them as stale
+// if they were reported before the current connection was established.
+func NewCapabilities(source string, profiles []string, reportedAt time.Time, connectedAt time.Time) Capabilities {
+	status := CapabilityCurrent
+	if reportedAt.Before(connectedAt) {
+		status = CapabilityStale
+	}
+	return Capabilities{Status: status, Source: source, Profiles: profiles, ReportedAt: &reportedAt}
+}
+
+// EndpointInfo describes a connected client, as returned by Server.Inventory.
+type EndpointInfo struct {
+	ID              string       `json:"id"`
+	ProtocolVersion ocpp.Dialect `json:"protocolVersion"`
+	Subprotocol     string       `json:"subprotocol"`
+	RemoteAddr      string       `json:"remoteAddr,omitempty"`
+	ConnectedAt     time.Time    `json:"connectedAt"`
+	// The time elapsed since the connection was established. Marshaled as a duration string, e.g. "1h30m0s".
+	Uptime time.Duration `json:"uptime"`
+	// The time at which the last message was received from the client.
+	LastActivity time.Time    `json:"lastActivity"`
+	Capabilities Capabilities `json:"capabilities"`
+}
+
+func (e EndpointInfo) MarshalJSON() ([]byte, error) {
+	type endpointInfo EndpointInfo
+	return json.Marshal(struct {
+		endpointInfo
+		Uptime string `json:"uptime"`
+	}{endpointInfo: endpointInfo(e), Uptime: e.Uptime.String()})
+}
+
+// Connection state of a client, as tracked by the server.
+type clientConnection struct {
+	channel      ws.Channel
+	connectedAt  time.Time
+	lastActivity time.Time
+	// Liveness monitor state
+	heartbeatInterval time.Duration
+	probedAt          time.Time
+	reaped            bool
+}
+
+// Inventory returns all connected clients, sorted by ID.
+//
+// The capabilities are always CapabilityUnknown, since the server doesn't interpret the exchanged messages.
+// The central system and the CSMS fill them in, where available.
+func (s *Server) Inventory() []EndpointInfo {
+	now := s.now()
+	s.clientsMutex.Lock()
+	inventory := make([]EndpointInfo, 0, len(s.clients))
+	for id, client := range s.clients {
+		info := EndpointInfo{
+			ID:              id,
+			ProtocolVersion: s.dialect,
+			ConnectedAt:     client.connectedAt,
+			Uptime:          now.Sub(client.connectedAt),
+			LastActivity:    client.lastActivity,
+			Capabilities:    Capabilities{Status: CapabilityUnknown},
+		}
+		if client.channel != nil {
+			info.Subprotocol = ws.ChannelSubprotocol(client.channel)
+			if addr := client.channel.RemoteAddr(); addr != nil {
+				info.RemoteAddr = addr.String()
+			}
+		}
+		inventory = append(inventory, info)
+	}
+	s.clientsMutex.Unlock()
+	sort.Slice(inventory, func(i, j int) bool {
+		return inventory[i].ID < inventory[j].ID
+	})
+	return inventory
+}
+
+// Records that a message was received from a client.
+func (s *Server) touchClient(clientID string, received time.Time) {
+	s.clientsMutex.Lock()
+	if client, ok := s.clients[clientID]; ok {
+		client.lastActivity = received
+	}
+	s.clientsMutex.Unlock()
+}
@@ -0,0 +1,160 @@
+package ocppj
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/lorenzodonini/ocpp-go/logging"
+)
+
+// CoercionKind identifies a deviation from the message schema, which was tolerated while decoding an inbound payload.
+type CoercionKind string
+
+const (
+	// A number field was sent as a string, e.g. "connectorId":"1".
+	CoercionNumberFromString CoercionKind = "NumberFromString"
+	// A boolean field was sent as a number, e.g. "enabled":1.
+	CoercionBoolFromNumber CoercionKind = "BoolFromNumber"
+	// A field was sent with a null value. The field is treated as absent.
+	CoercionNullAsAbsent CoercionKind = "NullAsAbsent"
+	// An optional string field (typically an enum) was sent as an empty string. The field is treated as absent.
+	CoercionEmptyStringAsAbsent CoercionKind = "EmptyStringAsAbsent"
+)
+
+// Coercion describes a single value, which was coerced while decoding an inbound payload in lenient mode.
+type Coercion struct {
+	Feature string       // The name of the feature, to which the payload belongs.
+	Field   string       // The path of the field within the payload, e.g. "meterValue[0].sampledValue[1].unit".
+	Kind    CoercionKind // The type of coercion that was applied.
+	Value   interface{}  // The original value, as it was received.
+}
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// Enables or disables the lenient decoding mode for inbound payloads.
+//
+// In lenient mode, common deviations from the message schema, as seen in non-conformant implementations, are tolerated:
+//
+// - numbers sent as strings are converted to numbers
+//
+// - booleans sent as 0/1 are converted to booleans
+//
+// - null values are treated as absent fields
+//
+// - empty strings for optional string fields (e.g. enums) are treated as absent fields
+//
+// The coercion is applied only to inbound requests and responses, before unmarshaling and validation.
+// Outbound messages are never altered. Every applied coercion is reported to the optional hook.
+//
+// By default, the endpoint decodes messages in strict mode.
+func (endpoint *Endpoint) SetLenientDecoding(enabled bool, hook func(coercion Coercion)) {
+	endpoint.lenientDecoding = enabled
+	endpoint.coercionHook = hook
+}
+
+func (endpoint *Endpoint) coercePayload(feature string, raw interface{}, payloadType reflect.Type) interface{} {
+	report := func(field string, kind CoercionKind, value interface{}) {
+		endpoint.getLogger().With(logging.Action(feature)).Debugf("coerced field %v of %v payload (%v): %v", field, feature, kind, value)
+		if endpoint.coercionHook != nil {
+			endpoint.coercionHook(Coercion{Feature: feature, Field: field, Kind: kind, Value: value})
+		}
+	}
+	return coerceValue(raw, payloadType, "", report)
+}
+
+func coerceValue(value interface{}, t reflect.Type, path string, report func(field string, kind CoercionKind, value interface{})) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	// Types with custom decoding logic are left untouched
+	if reflect.PtrTo(t).Implements(jsonUnmarshalerType) {
+		return value
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return value
+		}
+		coerceStruct(obj, t, path, report)
+	case reflect.Slice, reflect.Array:
+		arr, ok := value.([]interface{})
+		if !ok {
+			return value
+		}
+		for i, elem := range arr {
+			arr[i] = coerceValue(elem, t.Elem(), path+"["+strconv.Itoa(i)+"]", report)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if s, ok := value.(string); ok {
+			if n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64); err == nil {
+				report(path, CoercionNumberFromString, value)
+				return float64(n)
+			}
+		}
+	case reflect.Float32, reflect.Float64:
+		if s, ok := value.(string); ok {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil {
+				report(path, CoercionNumberFromString, value)
+				return f
+			}
+		}
+	case reflect.Bool:
+		if n, ok := value.(float64); ok && (n == 0 || n == 1) {
+			report(path, CoercionBoolFromNumber, value)
+			return n == 1
+		}
+	}
+	return value
+}
+
+func coerceStruct(obj map[string]interface{}, t reflect.Type, path string, report func(field string, kind CoercionKind, value interface{})) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			// Unexported field
+			continue
+		}
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			coerceStruct(obj, field.Type, path, report)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fieldPath := name
+		if path != "" {
+			fieldPath = path + "." + name
+		}
+		value, ok := obj[name]
+		if !ok {
+			continue
+		}
+		if value == nil {
+			report(fieldPath, CoercionNullAsAbsent, value)
+			delete(obj, name)
+			continue
+		}
+		if s, isString := value.(string); isString && s == "" && strings.Contains(opts, "omitempty") && isStringType(field.Type) {
+			report(fieldPath, CoercionEmptyStringAsAbsent, value)
+			delete(obj, name)
+			continue
+		}
+		obj[name] = coerceValue(value, field.Type, fieldPath, report)
+	}
+}
+
+func isStringType(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.String
+}
@@ -0,0 +1,126 @@
+package ocppj
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+)
+
+// The maximum length of a unique message ID, as defined by the OCPP-J specification.
+const maxUniqueIdLength = 36
+
+// Default limits applied to incoming messages, unless configured otherwise via SetParseLimits.
+const (
+	DefaultMaxNestingDepth = 32
+	DefaultMaxStringLength = 65536
+)
+
+// ParseLimits bound the structure of incoming messages.
+//
+// The limits are checked on the raw frame before it is decoded, so that pathological input
+// (e.g. deeply nested arrays or huge strings) is rejected cheaply, before any allocation or validation takes place.
+type ParseLimits struct {
+	// The maximum nesting depth of arrays and objects, including the enclosing message array. Zero disables the check.
+	MaxNestingDepth int
+	// The maximum length in bytes of any string contained in the message, as encoded in the frame. Zero disables the check.
+	MaxStringLength int
+}
+
+// DefaultParseLimits returns the limits applied to incoming messages by default.
+func DefaultParseLimits() ParseLimits {
+	return ParseLimits{MaxNestingDepth: DefaultMaxNestingDepth, MaxStringLength: DefaultMaxStringLength}
+}
+
+// SetParseLimits sets the limits for incoming messages. Refer to ParseLimits for details.
+//
+// Messages exceeding a limit are not parsed. If the message is a request and carries a valid unique ID,
+// a CallError of type FormatViolation (FormationViolation for OCPP 1.6) is sent back to the other endpoint.
+// Such messages are also passed to the invalid message hook, without a parsed representation.
+//
+// The limits must be set before starting the endpoint.
+func (endpoint *Endpoint) SetParseLimits(limits ParseLimits) {
+	endpoint.parseLimits = &limits
+}
+
+// Returns the configured limits, falling back to the default limits.
+func (endpoint *Endpoint) getParseLimits() ParseLimits {
+	if endpoint.parseLimits != nil {
+		return *endpoint.parseLimits
+	}
+	return DefaultParseLimits()
+}
+
+// Scans a raw frame and returns an error if it exceeds the configured limits.
+// The frame is not required to be valid JSON, as malformed input is rejected by the decoder later on.
+//
+// The message ID of the returned error is only set if the frame is a call, since no other message may be answered.
+func (endpoint *Endpoint) checkParseLimits(data []byte) error {
+	limits := endpoint.getParseLimits()
+	if limits.MaxNestingDepth <= 0 && limits.MaxStringLength <= 0 {
+		return nil
+	}
+	var description string
+	depth := 0
+	inString, escaped := false, false
+	stringStart := 0
+scan:
+	for i, b := range data {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case b == '\\':
+				escaped = true
+			case b == '"':
+				inString = false
+				continue
+			}
+			if limits.MaxStringLength > 0 && i-stringStart > limits.MaxStringLength {
+				description = fmt.Sprintf("Invalid message. String exceeds maximum length of %d bytes", limits.MaxStringLength)
+				break scan
+			}
+			continue
+		}
+		switch b {
+		case '"':
+			inString = true
+			stringStart = i
+		case '[', '{':
+			depth++
+			if limits.MaxNestingDepth > 0 && depth > limits.MaxNestingDepth {
+				description = fmt.Sprintf("Invalid message. Nesting depth exceeds maximum of %d", limits.MaxNestingDepth)
+				break scan
+			}
+		case ']', '}':
+			depth--
+		}
+	}
+	if description == "" {
+		return nil
+	}
+	return ocpp.NewError(FormatErrorType(endpoint), description, callUniqueId(data))
+}
+
+// Extracts the unique ID of a call from the beginning of a raw frame, without decoding the rest of the frame.
+// Returns an empty string if the frame is not a call or the ID is invalid.
+func callUniqueId(data []byte) string {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return ""
+	}
+	if token, err := decoder.Token(); err != nil || token != json.Number("2") {
+		return ""
+	}
+	token, err := decoder.Token()
+	if err != nil {
+		return ""
+	}
+	uniqueId, ok := token.(string)
+	if !ok || len(uniqueId) > maxUniqueIdLength {
+		return ""
+	}
+	return uniqueId
+}
@@ -0,0 +1,156 @@
+package ocppj_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/lorenzodonini/ocpp-go/ws"
+)
+
+func nestedJson(depth int) string {
+	return strings.Repeat("[", depth) + strings.Repeat("]", depth)
+}
+
+func (suite *OcppJTestSuite) TestServerParseLimitNestingDepth() {
+	t := suite.T()
+	mockChargePointId := "1234"
+	writtenC := make(chan []byte, 1)
+	suite.mockServer.On("Start", mock.AnythingOfType("int"), mock.AnythingOfType("string")).Return()
+	suite.mockServer.On("Write", mockChargePointId, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		writtenC <- args.Get(1).([]byte)
+	})
+	handledC := make(chan string, 1)
+	suite.centralSystem.SetRequestHandler(func(client ws.Channel, request ocpp.Request, requestId string, action string) {
+		handledC <- requestId
+	})
+	suite.centralSystem.Start(8887, "somePath")
+	channel := NewMockWebSocket(mockChargePointId)
+	// The message array counts as first level
+	err := suite.mockServer.MessageHandler(channel, []byte(fmt.Sprintf(`[2,"1234","%v",{"mockValue":"value","mockAny":%v}]`, MockFeatureName, nestedJson(ocppj.DefaultMaxNestingDepth-2))))
+	require.NoError(t, err)
+	assert.Equal(t, "1234", <-handledC)
+	err = suite.mockServer.MessageHandler(channel, []byte(fmt.Sprintf(`[2,"1235","%v",{"mockValue":"value","mockAny":%v}]`, MockFeatureName, nestedJson(ocppj.DefaultMaxNestingDepth-1))))
+	require.Error(t, err)
+	ocppErr, ok := err.(*ocpp.Error)
+	require.True(t, ok)
+	assert.Equal(t, ocppj.FormatViolationV16, ocppErr.Code)
+	assert.Equal(t, "1235", ocppErr.MessageId)
+	var callError []interface{}
+	require.NoError(t, json.Unmarshal(<-writtenC, &callError))
+	assert.Equal(t, []interface{}{float64(ocppj.CALL_ERROR), "1235", string(ocppj.FormatViolationV16), ocppErr.Description, map[string]interface{}{}}, callError)
+	assert.Empty(t, handledC)
+}
+
+func (suite *OcppJTestSuite) TestServerParseLimitStringLength() {
+	t := suite.T()
+	mockChargePointId := "1234"
+	suite.mockServer.On("Start", mock.AnythingOfType("int"), mock.AnythingOfType("string")).Return()
+	suite.mockServer.On("Write", mockChargePointId, mock.Anything).Return(nil)
+	handledC := make(chan string, 1)
+	suite.centralSystem.SetRequestHandler(func(client ws.Channel, request ocpp.Request, requestId string, action string) {
+		handledC <- requestId
+	})
+	suite.centralSystem.SetParseLimits(ocppj.ParseLimits{MaxStringLength: 16})
+	suite.centralSystem.Start(8887, "somePath")
+	channel := NewMockWebSocket(mockChargePointId)
+	// Escape sequences count with their encoded length
+	err := suite.mockServer.MessageHandler(channel, []byte(fmt.Sprintf(`[2,"1234","%v",{"mockValue":"value","mockAny":"0123456789ab\"de"}]`, MockFeatureName)))
+	require.NoError(t, err)
+	assert.Equal(t, "1234", <-handledC)
+	err = suite.mockServer.MessageHandler(channel, []byte(fmt.Sprintf(`[2,"1235","%v",{"mockValue":"value","mockAny":"0123456789ab\"def"}]`, MockFeatureName)))
+	require.Error(t, err)
+	ocppErr, ok := err.(*ocpp.Error)
+	require.True(t, ok)
+	assert.Equal(t, ocppj.FormatViolationV16, ocppErr.Code)
+	assert.Equal(t, "1235", ocppErr.MessageId)
+	// Nesting is unlimited
+	err = suite.mockServer.MessageHandler(channel, []byte(fmt.Sprintf(`[2,"1236","%v",{"mockValue":"value","mockAny":%v}]`, MockFeatureName, nestedJson(100))))
+	require.NoError(t, err)
+	assert.Equal(t, "1236", <-handledC)
+	// Disabled limits
+	suite.centralSystem.SetParseLimits(ocppj.ParseLimits{})
+	err = suite.mockServer.MessageHandler(channel, []byte(fmt.Sprintf(`[2,"1237","%v",{"mockValue":"value","mockAny":"%v"}]`, MockFeatureName, strings.Repeat("x", ocppj.DefaultMaxStringLength+1))))
+	require.NoError(t, err)
+	assert.Equal(t, "1237", <-handledC)
+}
+
+func (suite *OcppJTestSuite) TestServerParseLimitResponse() {
+	t := suite.T()
+	mockChargePointId := "1234"
+	suite.mockServer.On("Start", mock.AnythingOfType("int"), mock.AnythingOfType("string")).Return()
+	suite.mockServer.On("Write", mockChargePointId, mock.Anything).Return(nil)
+	hookC := make(chan *ocpp.Error, 1)
+	suite.centralSystem.SetInvalidMessageHook(func(client ws.Channel, err *ocpp.Error, rawJson string, parsedFields []interface{}) *ocpp.Error {
+		assert.Nil(t, parsedFields)
+		hookC <- err
+		return nil
+	})
+	suite.centralSystem.Start(8887, "somePath")
+	suite.centralSystem.RequestState.AddPendingRequest(mockChargePointId, "1234", newMockRequest("value"))
+	// Responses exceeding the limits are never answered
+	err := suite.mockServer.MessageHandler(NewMockWebSocket(mockChargePointId), []byte(fmt.Sprintf(`[3,"1234",{"mockValue":"value","mockAny":%v}]`, nestedJson(ocppj.DefaultMaxNestingDepth))))
+	require.Error(t, err)
+	ocppErr := <-hookC
+	assert.Equal(t, ocppj.FormatViolationV16, ocppErr.Code)
+	assert.Empty(t, ocppErr.MessageId)
+	suite.mockServer.AssertNotCalled(t, "Write", mock.Anything, mock.Anything)
+}
+
+func (suite *OcppJTestSuite) TestClientParseLimits() {
+	t := suite.T()
+	writtenC := make(chan []byte, 1)
+	suite.mockClient.On("Write", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		writtenC <- args.Get(0).([]byte)
+	})
+	suite.mockClient.On("Start", mock.AnythingOfType("string")).Return(nil)
+	suite.chargePoint.SetRequestHandler(func(request ocpp.Request, requestId string, action string) {
+		assert.Fail(t, "unexpected request")
+	})
+	suite.chargePoint.SetDialect(ocpp.V2)
+	suite.chargePoint.SetParseLimits(ocppj.ParseLimits{MaxNestingDepth: 3})
+	require.NoError(t, suite.chargePoint.Start("someUrl"))
+	err := suite.mockClient.MessageHandler([]byte(fmt.Sprintf(`[2,"1234","%v",{"mockValue":"value","mockAny":{"a":[]}}]`, MockFeatureName)))
+	require.Error(t, err)
+	ocppErr, ok := err.(*ocpp.Error)
+	require.True(t, ok)
+	assert.Equal(t, ocppj.FormatViolationV2, ocppErr.Code)
+	assert.Equal(t, "1234", ocppErr.MessageId)
+	var callError []interface{}
+	require.NoError(t, json.Unmarshal(<-writtenC, &callError))
+	assert.Equal(t, "1234", callError[1])
+	assert.Equal(t, string(ocppj.FormatViolationV2), callError[2])
+}
+
+// Regression: oversized unique IDs made the CallError fail validation, yielding a non-OCPP error and no reply.
+func (suite *OcppJTestSuite) TestServerInvalidUniqueIdLength() {
+	t := suite.T()
+	mockChargePointId := "1234"
+	suite.mockServer.On("Start", mock.AnythingOfType("int"), mock.AnythingOfType("string")).Return()
+	suite.mockServer.On("Write", mockChargePointId, mock.Anything).Return(nil)
+	suite.centralSystem.Start(8887, "somePath")
+	err := suite.mockServer.MessageHandler(NewMockWebSocket(mockChargePointId), []byte(fmt.Sprintf(`[2,"%v","UnknownAction",{}]`, strings.Repeat("0", 37))))
+	require.Error(t, err)
+	ocppErr, ok := err.(*ocpp.Error)
+	require.True(t, ok)
+	assert.Equal(t, ocppj.FormatViolationV16, ocppErr.Code)
+	assert.Empty(t, ocppErr.MessageId)
+	suite.mockServer.AssertNotCalled(t, "Write", mock.Anything, mock.Anything)
+}
+
+func (suite *OcppJTestSuite) TestParseMessageNonIntegralMessageType() {
+	t := suite.T()
+	state := ocppj.NewClientState()
+	message, err := suite.chargePoint.ParseMessage([]interface{}{2.5, "1234", MockFeatureName, map[string]interface{}{"mockValue": "value"}}, state)
+	require.Error(t, err)
+	assert.Nil(t, message)
+	ocppErr, ok := err.(*ocpp.Error)
+	require.True(t, ok)
+	assert.Equal(t, ocppj.FormatViolationV16, ocppErr.Code)
+}
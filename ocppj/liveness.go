@@ -0,0 +1,241 @@
+package ocppj
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/lorenzodonini/ocpp-go/clock"
+)
+
+// LivenessState describes whether a client is considered alive by the liveness monitor of a server.
+type LivenessState string
+
+const (
+	LivenessHealthy LivenessState = "Healthy" // A message was received within the expected silence budget.
+	LivenessProbing LivenessState = "Probing" // The silence budget was exceeded, and the client was probed.
+	LivenessReaped  LivenessState = "Reaped"  // The client didn't answer the probe in time, and its connection was closed.
+)
+
+// Liveness contains the liveness state of a client, as tracked by the liveness monitor of a server.
+type Liveness struct {
+	State             LivenessState `json:"state"`
+	LastMessage       time.Time     `json:"lastMessage"`       // The time at which the last message was received from the client, or the connection time.
+	HeartbeatInterval time.Duration `json:"heartbeatInterval"` // The heartbeat interval of the client, or the default interval if unknown.
+	Budget            time.Duration `json:"budget"`            // The maximum silence tolerated before probing the client.
+	ProbedAt          *time.Time    `json:"probedAt,omitempty"`
+}
+
+// LivenessConfig configures the liveness monitor of a server.
+type LivenessConfig struct {
+	// The heartbeat interval assumed for clients, until their actual interval is set via SetHeartbeatInterval. Required.
+	DefaultHeartbeatInterval time.Duration
+	// The silence tolerated on top of the heartbeat interval, before a client is probed.
+	// If zero, a whole heartbeat interval is tolerated, i.e. a single missed heartbeat doesn't trigger a probe.
+	Grace time.Duration
+	// The time a probed client has for sending any message. If zero, no probe is sent and silent clients are reaped right away.
+	ProbeTimeout time.Duration
+	// The interval at which clients are checked. Defaults to 10 seconds.
+	CheckInterval time.Duration
+}
+
+func (c LivenessConfig) budget(heartbeatInterval time.Duration) time.Duration {
+	if c.Grace > 0 {
+		return heartbeatInterval + c.Grace
+	}
+	return 2 * heartbeatInterval
+}
+
+// Detects clients, which stopped sending messages without closing their connection. A zero value is disabled.
+type livenessMonitor struct {
+	enabled bool
+	config  LivenessConfig
+	probe   func(clientID string) error
+	handler func(clientID string, liveness Liveness)
+	stopC   chan struct{}
+	mutex   sync.Mutex
+}
+
+// EnableLivenessMonitor detects connections, which died without being closed (e.g. after a network failure),
+// and closes them, so that the reconnection logic of the respective client kicks in.
+//
+// Every message received from a client (requests, responses and errors alike) proves that the client is alive.
+// A client is expected to send a message within its heartbeat interval plus a grace time, referred to as silence budget.
+// Once the budget is exceeded, the client is probed via the function passed to SetLivenessProbe, and is considered
+// alive again as soon as any message is received. If no message is received within the probe timeout,
+// or probing is disabled, the connection is closed and the client is reported as reaped.
+//
+// The heartbeat interval of each client should be set via SetHeartbeatInterval, e.g. after accepting a BootNotification.
+// Must be invoked before starting the server.
+func (s *Server) EnableLivenessMonitor(config LivenessConfig) {
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = 10 * time.Second
+	}
+	s.liveness.mutex.Lock()
+	defer s.liveness.mutex.Unlock()
+	s.liveness.enabled = true
+	s.liveness.config = config
+}
+
+// SetLivenessProbe sets the function used for probing a client, which exceeded its silence budget.
+// The probe should cause the client to send a message, e.g. by triggering a heartbeat.
+// If the probe returns an error, the client is reaped right away.
+func (s *Server) SetLivenessProbe(probe func(clientID string) error) {
+	s.liveness.mutex.Lock()
+	defer s.liveness.mutex.Unlock()
+	s.liveness.probe = probe
+}
+
+// SetLivenessHandler registers a handler, which is invoked every time the liveness state of a client changed,
+// i.e. when the client is probed, recovers after a probe, or is reaped.
+func (s *Server) SetLivenessHandler(handler func(clientID string, liveness Liveness)) {
+	s.liveness.mutex.Lock()
+	defer s.liveness.mutex.Unlock()
+	s.liveness.handler = handler
+}
+
+// SetHeartbeatInterval sets the interval at which a connected client is expected to send heartbeats,
+// replacing the default interval of the liveness monitor. Passing a zero interval restores the default.
+func (s *Server) SetHeartbeatInterval(clientID string, interval time.Duration) {
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
+	if client, ok := s.clients[clientID]; ok {
+		client.heartbeatInterval = interval
+	}
+}
+
+// Liveness returns the liveness state of a connected client. Returns false if the client isn't connected,
+// or the liveness monitor isn't enabled.
+func (s *Server) Liveness(clientID string) (Liveness, bool) {
+	s.liveness.mutex.Lock()
+	enabled, config := s.liveness.enabled, s.liveness.config
+	s.liveness.mutex.Unlock()
+	if !enabled {
+		return Liveness{}, false
+	}
+	s.clientsMutex.Lock()
+	defer s.clientsMutex.Unlock()
+	client, ok := s.clients[clientID]
+	if !ok {
+		return Liveness{}, false
+	}
+	return client.livenessInfo(config), true
+}
+
+func (c *clientConnection) livenessInfo(config LivenessConfig) Liveness {
+	interval := c.heartbeatInterval
+	if interval <= 0 {
+		interval = config.DefaultHeartbeatInterval
+	}
+	liveness := Liveness{State: LivenessHealthy, LastMessage: c.lastActivity, HeartbeatInterval: interval, Budget: config.budget(interval)}
+	if !c.probedAt.IsZero() {
+		probedAt := c.probedAt
+		liveness.ProbedAt = &probedAt
+		liveness.State = LivenessProbing
+	}
+	return liveness
+}
+
+func (m *livenessMonitor) start(s *Server) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if !m.enabled || m.stopC != nil {
+		return
+	}
+	m.stopC = make(chan struct{})
+	// The ticker is created right away, so that clients connecting after the server started are always checked
+	go m.run(s, s.getClock().NewTicker(m.config.CheckInterval), m.stopC)
+}
+
+func (m *livenessMonitor) stop() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.stopC != nil {
+		close(m.stopC)
+		m.stopC = nil
+	}
+}
+
+func (m *livenessMonitor) run(s *Server, ticker clock.Ticker, stopC chan struct{}) {
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopC:
+			return
+		case <-ticker.C():
+			// Ticks may be dropped under load, so the current time is used instead of the tick time
+			m.check(s, s.now())
+		}
+	}
+}
+
+type livenessChange struct {
+	clientID string
+	liveness Liveness
+	probe    bool
+}
+
+// Updates the liveness state of all clients, probing and reaping clients as needed.
+func (m *livenessMonitor) check(s *Server, now time.Time) {
+	m.mutex.Lock()
+	config, probe, handler := m.config, m.probe, m.handler
+	m.mutex.Unlock()
+	canProbe := probe != nil && config.ProbeTimeout > 0
+	var changes []livenessChange
+	s.clientsMutex.Lock()
+	for id, client := range s.clients {
+		if client.reaped {
+			// Waiting for the connection to be closed
+			continue
+		}
+		liveness := client.livenessInfo(config)
+		switch {
+		case liveness.State == LivenessProbing && !client.lastActivity.Before(client.probedAt):
+			// Recovered
+			client.probedAt = time.Time{}
+			changes = append(changes, livenessChange{clientID: id, liveness: client.livenessInfo(config)})
+		case liveness.State == LivenessProbing && now.Sub(client.probedAt) >= config.ProbeTimeout:
+			client.reaped = true
+			liveness.State = LivenessReaped
+			changes = append(changes, livenessChange{clientID: id, liveness: liveness})
+		case liveness.State == LivenessHealthy && now.Sub(client.lastActivity) > liveness.Budget:
+			if !canProbe {
+				client.reaped = true
+				liveness.State = LivenessReaped
+				changes = append(changes, livenessChange{clientID: id, liveness: liveness})
+				break
+			}
+			client.probedAt = now
+			changes = append(changes, livenessChange{clientID: id, liveness: client.livenessInfo(config), probe: true})
+		}
+	}
+	s.clientsMutex.Unlock()
+	for _, change := range changes {
+		logger := s.clientLogger(change.clientID)
+		if change.probe {
+			logger.Infof("no message received for %v, probing client", now.Sub(change.liveness.LastMessage))
+			if err := probe(change.clientID); err != nil {
+				logger.Errorf("couldn't probe client: %v", err)
+				change.liveness.State = LivenessReaped
+				s.clientsMutex.Lock()
+				if client, ok := s.clients[change.clientID]; ok {
+					client.reaped = true
+				}
+				s.clientsMutex.Unlock()
+			}
+		}
+		if change.liveness.State == LivenessReaped {
+			silence := now.Sub(change.liveness.LastMessage)
+			logger.Errorf("no message received for %v, closing connection", silence)
+			closeErr := websocket.CloseError{Code: websocket.CloseGoingAway, Text: fmt.Sprintf("no message received for %v", silence)}
+			if err := s.server.StopConnection(change.clientID, closeErr); err != nil {
+				logger.Errorf("couldn't close connection: %v", err)
+			}
+		}
+		if handler != nil {
+			handler(change.clientID, change.liveness)
+		}
+	}
+}
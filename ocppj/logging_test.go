@@ -0,0 +1,207 @@
+package ocppj_test
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/logging"
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/lorenzodonini/ocpp-go/ws"
+)
+
+// ---------------------- RECORDING LOGGER ----------------------
+
+type logEntry struct {
+	level   string
+	message string
+	fields  map[string]interface{}
+}
+
+type logRecorder struct {
+	entries []logEntry
+	mutex   sync.Mutex
+}
+
+// Returns all recorded entries with the exact message.
+func (r *logRecorder) find(message string) []logEntry {
+	return r.filter(func(entry logEntry) bool { return entry.message == message })
+}
+
+// Returns all recorded entries, whose message starts with the prefix.
+func (r *logRecorder) findPrefix(prefix string) []logEntry {
+	return r.filter(func(entry logEntry) bool { return strings.HasPrefix(entry.message, prefix) })
+}
+
+func (r *logRecorder) filter(match func(entry logEntry) bool) []logEntry {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var result []logEntry
+	for _, entry := range r.entries {
+		if match(entry) {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+type recordingLogger struct {
+	recorder *logRecorder
+	fields   map[string]interface{}
+}
+
+func newRecordingLogger() *recordingLogger {
+	return &recordingLogger{recorder: &logRecorder{}, fields: map[string]interface{}{}}
+}
+
+func (l *recordingLogger) record(level string, message string) {
+	l.recorder.mutex.Lock()
+	defer l.recorder.mutex.Unlock()
+	l.recorder.entries = append(l.recorder.entries, logEntry{level: level, message: message, fields: l.fields})
+}
+
+func (l *recordingLogger) Debug(args ...interface{}) { l.record("debug", fmt.Sprint(args...)) }
+func (l *recordingLogger) Debugf(format string, args ...interface{}) {
+	l.record("debug", fmt.Sprintf(format, args...))
+}
+func (l *recordingLogger) Info(args ...interface{}) { l.record("info", fmt.Sprint(args...)) }
+func (l *recordingLogger) Infof(format string, args ...interface{}) {
+	l.record("info", fmt.Sprintf(format, args...))
+}
+func (l *recordingLogger) Error(args ...interface{}) { l.record("error", fmt.Sprint(args...)) }
+func (l *recordingLogger) Errorf(format string, args ...interface{}) {
+	l.record("error", fmt.Sprintf(format, args...))
+}
+
+func (l *recordingLogger) With(fields ...logging.Field) logging.Logger {
+	merged := map[string]interface{}{}
+	for k, v := range l.fields {
+		merged[k] = v
+	}
+	for _, f := range fields {
+		merged[f.Key] = f.Value
+	}
+	return &recordingLogger{recorder: l.recorder, fields: merged}
+}
+
+// Records unstructured entries only.
+type basicRecordingLogger struct {
+	messages []string
+}
+
+func (l *basicRecordingLogger) Debug(args ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprint(args...))
+}
+func (l *basicRecordingLogger) Debugf(format string, args ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(format, args...))
+}
+func (l *basicRecordingLogger) Info(args ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprint(args...))
+}
+func (l *basicRecordingLogger) Infof(format string, args ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(format, args...))
+}
+func (l *basicRecordingLogger) Error(args ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprint(args...))
+}
+func (l *basicRecordingLogger) Errorf(format string, args ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(format, args...))
+}
+
+// ---------------------- TESTS ----------------------
+
+func (suite *OcppJTestSuite) TestServerLoggerFields() {
+	t := suite.T()
+	logger := newRecordingLogger()
+	suite.centralSystem.SetLogger(logger)
+	mockChargePointId := "1234"
+	mockUniqueId := "5678"
+	mockRequest := fmt.Sprintf(`[2,"%v","%v",{"mockValue":"someValue"}]`, mockUniqueId, MockFeatureName)
+	suite.centralSystem.SetRequestHandler(func(chargePoint ws.Channel, request ocpp.Request, requestId string, action string) {})
+	suite.mockServer.On("Start", mock.AnythingOfType("int"), mock.AnythingOfType("string")).Return()
+	suite.mockServer.On("Write", mockChargePointId, mock.Anything).Return(nil)
+	suite.centralSystem.Start(8887, "somePath")
+	suite.serverDispatcher.CreateClient(mockChargePointId)
+	// Incoming request
+	err := suite.mockServer.MessageHandler(NewMockWebSocket(mockChargePointId), []byte(mockRequest))
+	require.NoError(t, err)
+	entries := logger.recorder.find(fmt.Sprintf("handling incoming CALL [%v, %v] from %v", mockUniqueId, MockFeatureName, mockChargePointId))
+	require.Len(t, entries, 1)
+	assert.Equal(t, "debug", entries[0].level)
+	assert.Equal(t, map[string]interface{}{
+		logging.FieldChargePointID: mockChargePointId,
+		logging.FieldAction:        MockFeatureName,
+		logging.FieldUniqueID:      mockUniqueId,
+	}, entries[0].fields)
+	// Outgoing response
+	err = suite.centralSystem.SendResponse(mockChargePointId, mockUniqueId, newMockConfirmation("someValue"))
+	require.NoError(t, err)
+	entries = logger.recorder.find(fmt.Sprintf("sent CALL RESULT [%v] for %v", mockUniqueId, mockChargePointId))
+	require.Len(t, entries, 1)
+	assert.Equal(t, mockChargePointId, entries[0].fields[logging.FieldChargePointID])
+	assert.Equal(t, MockFeatureName, entries[0].fields[logging.FieldAction])
+	assert.Equal(t, mockUniqueId, entries[0].fields[logging.FieldUniqueID])
+	// Outgoing request, logged by the dispatcher
+	requestID, err := suite.centralSystem.SendRequestWithID(mockChargePointId, newMockRequest("someValue"))
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return len(logger.recorder.find(fmt.Sprintf("dispatched request %v for %v", requestID, mockChargePointId))) == 1
+	}, time.Second, 10*time.Millisecond)
+	entries = logger.recorder.find(fmt.Sprintf("dispatched request %v for %v", requestID, mockChargePointId))
+	require.Len(t, entries, 1)
+	assert.Equal(t, "info", entries[0].level)
+	assert.Equal(t, mockChargePointId, entries[0].fields[logging.FieldChargePointID])
+	assert.Equal(t, MockFeatureName, entries[0].fields[logging.FieldAction])
+	assert.Equal(t, requestID, entries[0].fields[logging.FieldUniqueID])
+}
+
+func (suite *OcppJTestSuite) TestClientLoggerFields() {
+	t := suite.T()
+	logger := newRecordingLogger()
+	suite.chargePoint.SetLogger(logger)
+	suite.mockClient.On("Write", mock.Anything).Return(nil)
+	suite.mockClient.On("Start", mock.AnythingOfType("string")).Return(nil)
+	require.NoError(t, suite.chargePoint.Start("someUrl"))
+	err := suite.chargePoint.SendRequest(newMockRequest("someValue"))
+	require.NoError(t, err)
+	enqueued := logger.recorder.findPrefix("enqueued CALL")
+	require.Len(t, enqueued, 1)
+	uniqueID := enqueued[0].fields[logging.FieldUniqueID]
+	require.NotEmpty(t, uniqueID)
+	assert.Equal(t, suite.chargePoint.Id, enqueued[0].fields[logging.FieldChargePointID])
+	assert.Equal(t, MockFeatureName, enqueued[0].fields[logging.FieldAction])
+	// The dispatcher logs with the same fields
+	assert.Eventually(t, func() bool {
+		return len(logger.recorder.find(fmt.Sprintf("dispatched request %v to server", uniqueID))) == 1
+	}, time.Second, 10*time.Millisecond)
+	dispatched := logger.recorder.find(fmt.Sprintf("dispatched request %v to server", uniqueID))
+	require.Len(t, dispatched, 1)
+	assert.Equal(t, enqueued[0].fields, dispatched[0].fields)
+}
+
+func (suite *OcppJTestSuite) TestPackageLoggerFallback() {
+	t := suite.T()
+	logger := &basicRecordingLogger{}
+	ocppj.SetLogger(logger)
+	defer ocppj.SetLogger(&logging.VoidLogger{})
+	mockChargePointId := "1234"
+	suite.mockServer.On("Write", mockChargePointId, mock.Anything).Return(nil)
+	err := suite.centralSystem.SendError(mockChargePointId, "5678", ocppj.GenericError, "error", nil)
+	require.NoError(t, err)
+	// Fields are appended to the messages of a basic logger
+	assert.Contains(t, logger.messages, "sent CALL ERROR [5678] for 1234 charge_point_id=1234 unique_id=5678")
+	// An instance logger takes precedence
+	instanceLogger := newRecordingLogger()
+	suite.centralSystem.SetLogger(instanceLogger)
+	logger.messages = nil
+	err = suite.centralSystem.SendError(mockChargePointId, "5679", ocppj.GenericError, "error", nil)
+	require.NoError(t, err)
+	assert.Empty(t, logger.messages)
+	assert.Len(t, instanceLogger.recorder.find("sent CALL ERROR [5679] for 1234"), 1)
+}
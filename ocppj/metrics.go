@@ -0,0 +1,86 @@
+package ocppj
+
+import (
+	"sort"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+)
+
+// MetricsCollector receives measurements about the requests exchanged by an endpoint.
+//
+// Methods are invoked synchronously from the goroutines processing the messages,
+// hence implementations must be thread-safe and return quickly.
+type MetricsCollector interface {
+	// RequestCompleted is invoked whenever an outgoing or incoming request was completed.
+	// The duration is measured from enqueuing (outgoing) or receiving (incoming) the request,
+	// until the response was received or sent.
+	//
+	// The error code is only set for OutcomeError.
+	RequestCompleted(info SpanInfo, outcome RequestOutcome, errorCode ocpp.ErrorCode, duration time.Duration)
+}
+
+// ThrottleCollector may additionally be implemented by a MetricsCollector,
+// to receive the time outgoing requests were held back by the minimum send interval.
+// Requests sent without delay are not reported.
+type ThrottleCollector interface {
+	RequestThrottled(info SpanInfo, delay time.Duration)
+}
+
+// ClientStats is a snapshot of the outgoing request flow towards a single client.
+type ClientStats struct {
+	ClientID        string
+	QueuedRequests  int // Requests waiting to be sent. Only available with a DefaultServerDispatcher, zero otherwise.
+	PendingRequests int // Requests sent to the client, for which no response was received yet.
+}
+
+// SetMetricsCollector sets a collector, receiving measurements about the requests exchanged with clients.
+// Passing nil disables metrics collection.
+//
+// The collector must be set before starting the server.
+func (s *Server) SetMetricsCollector(collector MetricsCollector) {
+	s.tracing.setCollector(collector)
+}
+
+// Stats returns a snapshot of the request flow towards each connected client, sorted by client ID.
+func (s *Server) Stats() []ClientStats {
+	s.clientsMutex.Lock()
+	ids := make([]string, 0, len(s.clients))
+	for id := range s.clients {
+		ids = append(ids, id)
+	}
+	s.clientsMutex.Unlock()
+	sort.Strings(ids)
+	queues, _ := s.dispatcher.(*DefaultServerDispatcher)
+	stats := make([]ClientStats, 0, len(ids))
+	for _, id := range ids {
+		entry := ClientStats{ClientID: id}
+		if s.RequestState.HasPendingRequest(id) {
+			entry.PendingRequests = 1
+		}
+		if queues != nil {
+			// The pending request is only removed from the queue once completed
+			entry.QueuedRequests = queues.queueSize(id) - entry.PendingRequests
+			if entry.QueuedRequests < 0 {
+				entry.QueuedRequests = 0
+			}
+		}
+		stats = append(stats, entry)
+	}
+	return stats
+}
+
+// SetMetricsCollector sets a collector, receiving measurements about the requests exchanged with the server.
+// Passing nil disables metrics collection.
+func (c *Client) SetMetricsCollector(collector MetricsCollector) {
+	c.tracing.setCollector(collector)
+}
+
+// Returns the amount of requests in the queue of a client, including a pending request.
+func (d *DefaultServerDispatcher) queueSize(clientID string) int {
+	q, ok := d.queueMap.Get(clientID)
+	if !ok {
+		return 0
+	}
+	return q.Size()
+}
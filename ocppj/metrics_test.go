@@ -0,0 +1,153 @@
+package ocppj_test
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/clock/clocktest"
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/lorenzodonini/ocpp-go/ws"
+)
+
+type completedRequest struct {
+	info      ocppj.SpanInfo
+	outcome   ocppj.RequestOutcome
+	errorCode ocpp.ErrorCode
+}
+
+type recordingCollector struct {
+	requests []completedRequest
+	mutex    sync.Mutex
+}
+
+func (c *recordingCollector) RequestCompleted(info ocppj.SpanInfo, outcome ocppj.RequestOutcome, errorCode ocpp.ErrorCode, duration time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.requests = append(c.requests, completedRequest{info: info, outcome: outcome, errorCode: errorCode})
+}
+
+func (c *recordingCollector) completed() []completedRequest {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]completedRequest{}, c.requests...)
+}
+
+type throttleCollector struct {
+	recordingCollector
+	delays chan time.Duration
+}
+
+func (c *throttleCollector) RequestThrottled(info ocppj.SpanInfo, delay time.Duration) {
+	c.delays <- delay
+}
+
+func (suite *OcppJTestSuite) TestServerMetricsCollector() {
+	t := suite.T()
+	collector := &recordingCollector{}
+	suite.centralSystem.SetMetricsCollector(collector)
+	mockChargePointId := "1234"
+	channel := NewMockWebSocket(mockChargePointId)
+	suite.mockServer.On("Start", mock.AnythingOfType("int"), mock.AnythingOfType("string")).Return()
+	suite.mockServer.On("Write", mockChargePointId, mock.Anything).Return(nil)
+	suite.centralSystem.SetRequestHandler(func(client ws.Channel, request ocpp.Request, requestId string, action string) {
+		assert.NoError(t, suite.centralSystem.SendResponse(client.ID(), requestId, newMockConfirmation("someValue")))
+	})
+	suite.centralSystem.Start(8887, "somePath")
+	suite.mockServer.NewClientHandler(channel)
+	// Incoming request
+	err := suite.mockServer.MessageHandler(channel, []byte(fmt.Sprintf(`[2,"5678","%v",{"mockValue":"someValue"}]`, MockFeatureName)))
+	require.NoError(t, err)
+	// Outgoing request, answered with an error
+	requestID, err := suite.centralSystem.SendRequestWithID(mockChargePointId, newMockRequest("someValue"))
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return suite.centralSystem.RequestState.HasPendingRequest(mockChargePointId)
+	}, time.Second, 5*time.Millisecond)
+	err = suite.mockServer.MessageHandler(channel, []byte(fmt.Sprintf(`[4,"%v","%v","mock error",{}]`, requestID, ocppj.InternalError)))
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		return len(collector.completed()) == 2
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []completedRequest{
+		{info: ocppj.SpanInfo{Kind: ocppj.SpanKindIncoming, ClientID: mockChargePointId, Action: MockFeatureName, UniqueID: "5678"}, outcome: ocppj.OutcomeResponse},
+		{info: ocppj.SpanInfo{Kind: ocppj.SpanKindOutgoing, ClientID: mockChargePointId, Action: MockFeatureName, UniqueID: requestID}, outcome: ocppj.OutcomeError, errorCode: ocppj.InternalError},
+	}, collector.completed())
+}
+
+func (suite *OcppJTestSuite) TestServerThrottledRequests() {
+	t := suite.T()
+	collector := &throttleCollector{delays: make(chan time.Duration, 1)}
+	fakeClock := clocktest.NewFakeClock(time.Now())
+	suite.centralSystem.SetClock(fakeClock)
+	suite.centralSystem.SetMetricsCollector(collector)
+	suite.centralSystem.SetMinSendInterval(time.Minute)
+	mockChargePointId := "1234"
+	channel := NewMockWebSocket(mockChargePointId)
+	writeC := make(chan string, 3)
+	suite.mockServer.On("Start", mock.AnythingOfType("int"), mock.AnythingOfType("string")).Return()
+	suite.mockServer.On("Write", mockChargePointId, mock.Anything).Run(func(args mock.Arguments) {
+		writeC <- string(args.Get(1).([]byte))
+	}).Return(nil)
+	suite.centralSystem.SetRequestHandler(func(client ws.Channel, request ocpp.Request, requestId string, action string) {
+		assert.NoError(t, suite.centralSystem.SendResponse(client.ID(), requestId, newMockConfirmation("someValue")))
+	})
+	suite.centralSystem.Start(8887, "somePath")
+	suite.mockServer.NewClientHandler(channel)
+	// The first request is answered right away, the second one is held back
+	requestID, err := suite.centralSystem.SendRequestWithID(mockChargePointId, newMockRequest("someValue"))
+	require.NoError(t, err)
+	assert.Contains(t, <-writeC, requestID)
+	err = suite.mockServer.MessageHandler(channel, []byte(fmt.Sprintf(`[3,"%v",{"mockValue":"someValue"}]`, requestID)))
+	require.NoError(t, err)
+	requestID, err = suite.centralSystem.SendRequestWithID(mockChargePointId, newMockRequest("someValue"))
+	require.NoError(t, err)
+	// Responses to incoming requests are not delayed
+	err = suite.mockServer.MessageHandler(channel, []byte(fmt.Sprintf(`[2,"5678","%v",{"mockValue":"someValue"}]`, MockFeatureName)))
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(<-writeC, `[3,"5678",`))
+	select {
+	case msg := <-writeC:
+		require.Fail(t, "request sent before the minimum send interval elapsed", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+	// Once the interval elapsed, the request is sent and the time spent waiting is reported
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(time.Minute)
+	assert.Contains(t, <-writeC, requestID)
+	assert.Equal(t, time.Minute, <-collector.delays)
+}
+
+func (suite *OcppJTestSuite) TestServerStats() {
+	t := suite.T()
+	mockChargePointId := "1234"
+	channel := NewMockWebSocket(mockChargePointId)
+	suite.mockServer.On("Start", mock.AnythingOfType("int"), mock.AnythingOfType("string")).Return()
+	suite.mockServer.On("Write", mockChargePointId, mock.Anything).Return(nil)
+	suite.centralSystem.Start(8887, "somePath")
+	assert.Empty(t, suite.centralSystem.Stats())
+	suite.mockServer.NewClientHandler(channel)
+	suite.mockServer.NewClientHandler(NewMockWebSocket("0001"))
+	assert.Equal(t, []ocppj.ClientStats{{ClientID: "0001"}, {ClientID: mockChargePointId}}, suite.centralSystem.Stats())
+	// One pending and two queued requests
+	for i := 0; i < 3; i++ {
+		_, err := suite.centralSystem.SendRequestWithID(mockChargePointId, newMockRequest("someValue"))
+		require.NoError(t, err)
+	}
+	assert.Eventually(t, func() bool {
+		return suite.centralSystem.RequestState.HasPendingRequest(mockChargePointId)
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, []ocppj.ClientStats{
+		{ClientID: "0001"},
+		{ClientID: mockChargePointId, QueuedRequests: 2, PendingRequests: 1},
+	}, suite.centralSystem.Stats())
+	// Disconnected clients are removed
+	suite.mockServer.DisconnectedClientHandler(channel)
+	assert.Equal(t, []ocppj.ClientStats{{ClientID: "0001"}}, suite.centralSystem.Stats())
+}
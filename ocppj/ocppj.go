@@ -2,12 +2,16 @@
 package ocppj
 
 import (
-	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"math/rand"
 	"reflect"
+	"sync"
+	"time"
 
+	"github.com/lorenzodonini/ocpp-go/clock"
 	"github.com/lorenzodonini/ocpp-go/logging"
 
 	"gopkg.in/go-playground/validator.v9"
@@ -15,8 +19,9 @@ import (
 	"github.com/lorenzodonini/ocpp-go/ocpp"
 )
 
-// The validator, used for validating incoming/outgoing OCPP messages.
-var Validate = validator.New()
+// The default validator, used for validating incoming/outgoing OCPP messages of endpoints without a dialect-specific validator.
+// The OCPP version packages own separate instances; refer to SetDialectValidator.
+var Validate = NewValidator()
 
 // The internal validation settings. Enabled by default.
 var validationEnabled bool
@@ -27,7 +32,6 @@ var log logging.Logger
 var EscapeHTML = true
 
 func init() {
-	_ = Validate.RegisterValidation("errorCode", IsErrorCodeValid)
 	log = &logging.VoidLogger{}
 	validationEnabled = true
 }
@@ -35,12 +39,15 @@ func init() {
 // Sets a custom Logger implementation, allowing the ocpp-j package to log events.
 // By default, a VoidLogger is used, so no logs will be sent to any output.
 //
+// Loggers not implementing the structured logging.Logger interface are wrapped via logging.FromBasic.
+// The package-level logger is used by all endpoints and dispatchers, which weren't assigned a logger via their SetLogger method.
+//
 // The function panics, if a nil logger is passed.
-func SetLogger(logger logging.Logger) {
+func SetLogger(logger logging.BasicLogger) {
 	if logger == nil {
 		panic("cannot set a nil logger")
 	}
-	log = logger
+	log = logging.FromBasic(logger)
 }
 
 // Allows an instance of ocppj to configure if the message is Marshaled by escaping special caracters like "<", ">", "&" etc
@@ -115,12 +122,18 @@ func (call *Call) GetUniqueId() string {
 }
 
 func (call *Call) MarshalJSON() ([]byte, error) {
-	fields := make([]interface{}, 4)
-	fields[0] = int(call.MessageTypeId)
-	fields[1] = call.UniqueId
-	fields[2] = call.Action
-	fields[3] = call.Payload
-	return jsonMarshal(fields)
+	e := newMessageEncoder(call.MessageTypeId)
+	defer e.release()
+	if err := e.writeString(call.UniqueId); err != nil {
+		return nil, err
+	}
+	if err := e.writeString(call.Action); err != nil {
+		return nil, err
+	}
+	if err := e.writeValue(call.Payload); err != nil {
+		return nil, err
+	}
+	return e.bytes(), nil
 }
 
 // -------------------- Call Result --------------------
@@ -142,11 +155,15 @@ func (callResult *CallResult) GetUniqueId() string {
 }
 
 func (callResult *CallResult) MarshalJSON() ([]byte, error) {
-	fields := make([]interface{}, 3)
-	fields[0] = int(callResult.MessageTypeId)
-	fields[1] = callResult.UniqueId
-	fields[2] = callResult.Payload
-	return jsonMarshal(fields)
+	e := newMessageEncoder(callResult.MessageTypeId)
+	defer e.release()
+	if err := e.writeString(callResult.UniqueId); err != nil {
+		return nil, err
+	}
+	if err := e.writeValue(callResult.Payload); err != nil {
+		return nil, err
+	}
+	return e.bytes(), nil
 }
 
 // -------------------- Call Error --------------------
@@ -170,17 +187,23 @@ func (callError *CallError) GetUniqueId() string {
 }
 
 func (callError *CallError) MarshalJSON() ([]byte, error) {
-	fields := make([]interface{}, 5)
-	fields[0] = int(callError.MessageTypeId)
-	fields[1] = callError.UniqueId
-	fields[2] = callError.ErrorCode
-	fields[3] = callError.ErrorDescription
+	e := newMessageEncoder(callError.MessageTypeId)
+	defer e.release()
+	if err := e.writeString(callError.UniqueId); err != nil {
+		return nil, err
+	}
+	if err := e.writeString(string(callError.ErrorCode)); err != nil {
+		return nil, err
+	}
+	if err := e.writeString(callError.ErrorDescription); err != nil {
+		return nil, err
+	}
 	if callError.ErrorDetails == nil {
-		fields[4] = struct{}{}
-	} else {
-		fields[4] = callError.ErrorDetails
+		e.writeRaw("{}")
+	} else if err := e.writeValue(callError.ErrorDetails); err != nil {
+		return nil, err
 	}
-	return ocppMessageToJson(fields)
+	return e.bytes(), nil
 }
 
 const (
@@ -242,16 +265,6 @@ func ParseJsonMessage(dataJson string) ([]interface{}, error) {
 	return ParseRawJsonMessage(rawJson)
 }
 
-func ocppMessageToJson(message interface{}) ([]byte, error) {
-	jsonData, err := jsonMarshal(message)
-	if err != nil {
-		return nil, err
-	}
-	jsonData[0] = '['
-	jsonData[len(jsonData)-1] = ']'
-	return jsonData, nil
-}
-
 func getValueLength(value interface{}) int {
 	switch value := value.(type) {
 	case int:
@@ -305,13 +318,14 @@ func errorFromValidation(validationErrors validator.ValidationErrors, messageId
 	return ocpp.NewError(GenericError, fmt.Sprintf("%v", validationErrors.Error()), messageId)
 }
 
-// Marshals data by manipulating EscapeHTML property of encoder
-func jsonMarshal(t interface{}) ([]byte, error) {
-	buffer := &bytes.Buffer{}
-	encoder := json.NewEncoder(buffer)
-	encoder.SetEscapeHTML(EscapeHTML)
-	err := encoder.Encode(t)
-	return bytes.TrimRight(buffer.Bytes(), "\n"), err
+// Converts the result of a struct validation to an OCPP error.
+// Errors other than validation errors (e.g. for invalid payload types) are reported as a generic error.
+func errorFromValidationResult(err error, messageId string, feature string) *ocpp.Error {
+	var validationErrors validator.ValidationErrors
+	if errors.As(err, &validationErrors) {
+		return errorFromValidation(validationErrors, messageId, feature)
+	}
+	return ocpp.NewError(GenericError, err.Error(), messageId)
 }
 
 // -------------------- Endpoint --------------------
@@ -319,8 +333,48 @@ func jsonMarshal(t interface{}) ([]byte, error) {
 // An OCPP-J endpoint is one of the two entities taking part in the communication.
 // The endpoint keeps state for supported OCPP profiles and current pending requests.
 type Endpoint struct {
-	dialect  ocpp.Dialect
-	Profiles []*ocpp.Profile
+	dialect          ocpp.Dialect
+	Profiles         []*ocpp.Profile
+	lenientDecoding  bool
+	coercionHook     func(coercion Coercion)
+	logger           logging.Logger
+	schemaValidation *schemaValidator
+	parseLimits      *ParseLimits
+	validator        *validator.Validate
+	clock            clock.Clock
+	panicHandler     func(err *HandlerPanicError)
+	// Suggestions for unsupported actions are enabled by default
+	noActionSuggestions bool
+	unknownActions      map[string]uint64
+	unknownActionsMutex sync.Mutex
+	// Zero means the deadline is derived from the dispatcher timeout
+	handlerDeadline          time.Duration
+	handlerDeadlineOverrides map[string]time.Duration
+	slowHandlerHandler       SlowHandlerHandler
+	handlers                 handlerTracker
+}
+
+// Returns the logger of the endpoint, falling back to the package-level logger.
+func (endpoint *Endpoint) getLogger() logging.Logger {
+	if endpoint.logger != nil {
+		return endpoint.logger
+	}
+	return log
+}
+
+// Returns the clock of the endpoint, falling back to the real clock.
+func (endpoint *Endpoint) getClock() clock.Clock {
+	return clock.OrDefault(endpoint.clock)
+}
+
+// Clock returns the clock of the endpoint, which defaults to the real clock.
+func (endpoint *Endpoint) Clock() clock.Clock {
+	return endpoint.getClock()
+}
+
+// Returns the current time, according to the clock of the endpoint.
+func (endpoint *Endpoint) now() time.Time {
+	return endpoint.getClock().Now()
 }
 
 // Sets endpoint dialect.
@@ -361,37 +415,38 @@ func (endpoint *Endpoint) GetProfileForFeature(featureName string) (*ocpp.Profil
 }
 
 func parseRawJsonRequest(raw interface{}, requestType reflect.Type) (ocpp.Request, error) {
-	if raw == nil {
-		raw = &struct{}{}
-	}
-	bytes, err := json.Marshal(raw)
+	request, err := decodeRawPayload(raw, requestType)
 	if err != nil {
 		return nil, err
 	}
-	request := reflect.New(requestType).Interface()
-	err = json.Unmarshal(bytes, &request)
+	result := request.(ocpp.Request)
+	return result, nil
+}
+
+func parseRawJsonConfirmation(raw interface{}, confirmationType reflect.Type) (ocpp.Response, error) {
+	confirmation, err := decodeRawPayload(raw, confirmationType)
 	if err != nil {
 		return nil, err
 	}
-	result := request.(ocpp.Request)
+	result := confirmation.(ocpp.Response)
 	return result, nil
 }
 
-func parseRawJsonConfirmation(raw interface{}, confirmationType reflect.Type) (ocpp.Response, error) {
+// Decodes a raw payload, as contained in a parsed message, into a new value of the given type.
+// The intermediate JSON representation is built in a pooled buffer.
+func decodeRawPayload(raw interface{}, payloadType reflect.Type) (interface{}, error) {
+	e := encoderPool.Get().(*messageEncoder)
+	defer e.release()
 	if raw == nil {
-		raw = &struct{}{}
-	}
-	bytes, err := json.Marshal(raw)
-	if err != nil {
+		e.buf.WriteString("{}")
+	} else if err := e.encoder.Encode(raw); err != nil {
 		return nil, err
 	}
-	confirmation := reflect.New(confirmationType).Interface()
-	err = json.Unmarshal(bytes, &confirmation)
-	if err != nil {
+	payload := reflect.New(payloadType).Interface()
+	if err := json.Unmarshal(e.buf.Bytes(), payload); err != nil {
 		return nil, err
 	}
-	result := confirmation.(ocpp.Response)
-	return result, nil
+	return payload, nil
 }
 
 // Parses an OCPP-J message. The function expects an array of elements, as contained in the JSON message.
@@ -406,6 +461,9 @@ func (endpoint *Endpoint) ParseMessage(arr []interface{}, pendingRequestState Cl
 	if !ok {
 		return nil, ocpp.NewError(FormatErrorType(endpoint), fmt.Sprintf("Invalid element %v at 0, expected message type (int)", arr[0]), "")
 	}
+	if rawTypeId != math.Trunc(rawTypeId) {
+		return nil, ocpp.NewError(FormatErrorType(endpoint), fmt.Sprintf("Invalid element %v at 0, expected message type (int)", arr[0]), "")
+	}
 	typeId := MessageType(rawTypeId)
 	uniqueId, ok := arr[1].(string)
 	if !ok {
@@ -414,6 +472,10 @@ func (endpoint *Endpoint) ParseMessage(arr []interface{}, pendingRequestState Cl
 	if uniqueId == "" {
 		return nil, ocpp.NewError(FormatErrorType(endpoint), "Invalid unique ID, cannot be empty", uniqueId)
 	}
+	if len(uniqueId) > maxUniqueIdLength {
+		// The ID cannot be echoed back in a CallError, hence the message is discarded
+		return nil, ocpp.NewError(FormatErrorType(endpoint), fmt.Sprintf("Invalid unique ID, exceeds maximum length of %d", maxUniqueIdLength), "")
+	}
 	// Parse message
 	if typeId == CALL {
 		if len(arr) != 4 {
@@ -426,9 +488,15 @@ func (endpoint *Endpoint) ParseMessage(arr []interface{}, pendingRequestState Cl
 
 		profile, ok := endpoint.GetProfileForFeature(action)
 		if !ok {
-			return nil, ocpp.NewError(NotSupported, fmt.Sprintf("Unsupported feature %v", action), uniqueId)
+			return nil, endpoint.unknownActionError(action, uniqueId)
+		}
+		requestParser := parseRawJsonRequest
+		if endpoint.lenientDecoding {
+			requestParser = func(raw interface{}, requestType reflect.Type) (ocpp.Request, error) {
+				return parseRawJsonRequest(endpoint.coercePayload(action, raw, requestType), requestType)
+			}
 		}
-		request, err := profile.ParseRequest(action, arr[3], parseRawJsonRequest)
+		request, err := profile.ParseRequest(action, arr[3], requestParser)
 		if err != nil {
 			return nil, ocpp.NewError(FormatErrorType(endpoint), err.Error(), uniqueId)
 		}
@@ -438,19 +506,27 @@ func (endpoint *Endpoint) ParseMessage(arr []interface{}, pendingRequestState Cl
 			Action:        action,
 			Payload:       request,
 		}
-		err = Validate.Struct(call)
-		if err != nil {
-			return nil, errorFromValidation(err.(validator.ValidationErrors), uniqueId, action)
+		if validationEnabled {
+			err = endpoint.Validator().Struct(call)
+			if err != nil {
+				return nil, errorFromValidationResult(err, uniqueId, action)
+			}
 		}
 		return &call, nil
 	} else if typeId == CALL_RESULT {
 		request, ok := pendingRequestState.GetPendingRequest(uniqueId)
 		if !ok {
-			log.Infof("No previous request %v sent. Discarding response message", uniqueId)
+			endpoint.getLogger().With(logging.UniqueID(uniqueId)).Infof("No previous request %v sent. Discarding response message", uniqueId)
 			return nil, nil
 		}
 		profile, _ := endpoint.GetProfileForFeature(request.GetFeatureName())
-		confirmation, err := profile.ParseResponse(request.GetFeatureName(), arr[2], parseRawJsonConfirmation)
+		confirmationParser := parseRawJsonConfirmation
+		if endpoint.lenientDecoding {
+			confirmationParser = func(raw interface{}, confirmationType reflect.Type) (ocpp.Response, error) {
+				return parseRawJsonConfirmation(endpoint.coercePayload(request.GetFeatureName(), raw, confirmationType), confirmationType)
+			}
+		}
+		confirmation, err := profile.ParseResponse(request.GetFeatureName(), arr[2], confirmationParser)
 		if err != nil {
 			return nil, ocpp.NewError(FormatErrorType(endpoint), err.Error(), uniqueId)
 		}
@@ -459,15 +535,17 @@ func (endpoint *Endpoint) ParseMessage(arr []interface{}, pendingRequestState Cl
 			UniqueId:      uniqueId,
 			Payload:       confirmation,
 		}
-		err = Validate.Struct(callResult)
-		if err != nil {
-			return nil, errorFromValidation(err.(validator.ValidationErrors), uniqueId, request.GetFeatureName())
+		if validationEnabled {
+			err = endpoint.Validator().Struct(callResult)
+			if err != nil {
+				return nil, errorFromValidationResult(err, uniqueId, request.GetFeatureName())
+			}
 		}
 		return &callResult, nil
 	} else if typeId == CALL_ERROR {
 		_, ok := pendingRequestState.GetPendingRequest(uniqueId)
 		if !ok {
-			log.Infof("No previous request %v sent. Discarding error message", uniqueId)
+			endpoint.getLogger().With(logging.UniqueID(uniqueId)).Infof("No previous request %v sent. Discarding error message", uniqueId)
 			return nil, nil
 		}
 		if len(arr) < 4 {
@@ -493,9 +571,9 @@ func (endpoint *Endpoint) ParseMessage(arr []interface{}, pendingRequestState Cl
 			ErrorDescription: errorDescription,
 			ErrorDetails:     details,
 		}
-		err := Validate.Struct(callError)
+		err := endpoint.Validator().Struct(callError)
 		if err != nil {
-			return nil, errorFromValidation(err.(validator.ValidationErrors), uniqueId, "")
+			return nil, errorFromValidationResult(err, uniqueId, "")
 		}
 		return &callError, nil
 	} else {
@@ -511,7 +589,7 @@ func (endpoint *Endpoint) CreateCall(request ocpp.Request) (*Call, error) {
 	action := request.GetFeatureName()
 	profile, _ := endpoint.GetProfileForFeature(action)
 	if profile == nil {
-		return nil, fmt.Errorf("Couldn't create Call for unsupported action %v", action)
+		return nil, fmt.Errorf("Couldn't create Call for %w %v", ErrUnsupportedFeature, action)
 	}
 	// TODO: handle collisions?
 	uniqueId := messageIdGenerator()
@@ -522,9 +600,9 @@ func (endpoint *Endpoint) CreateCall(request ocpp.Request) (*Call, error) {
 		Payload:       request,
 	}
 	if validationEnabled {
-		err := Validate.Struct(call)
+		err := endpoint.Validator().Struct(call)
 		if err != nil {
-			return nil, err
+			return nil, newValidationError(err)
 		}
 	}
 	return &call, nil
@@ -545,9 +623,9 @@ func (endpoint *Endpoint) CreateCallResult(confirmation ocpp.Response, uniqueId
 		Payload:       confirmation,
 	}
 	if validationEnabled {
-		err := Validate.Struct(callResult)
+		err := endpoint.Validator().Struct(callResult)
 		if err != nil {
-			return nil, err
+			return nil, newValidationError(err)
 		}
 	}
 	return &callResult, nil
@@ -563,9 +641,9 @@ func (endpoint *Endpoint) CreateCallError(uniqueId string, code ocpp.ErrorCode,
 		ErrorDetails:     details,
 	}
 	if validationEnabled {
-		err := Validate.Struct(callError)
+		err := endpoint.Validator().Struct(callError)
 		if err != nil {
-			return nil, err
+			return nil, newValidationError(err)
 		}
 	}
 	return &callError, nil
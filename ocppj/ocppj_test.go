@@ -1,7 +1,9 @@
 package ocppj_test
 
 import (
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -42,6 +44,14 @@ func (websocket MockWebSocket) TLSConnectionState() *tls.ConnectionState {
 	return nil
 }
 
+func (websocket MockWebSocket) Subprotocol() string {
+	return ""
+}
+
+func (websocket MockWebSocket) Context() context.Context {
+	return context.Background()
+}
+
 func NewMockWebSocket(id string) MockWebSocket {
 	return MockWebSocket{id: id}
 }
@@ -127,6 +137,10 @@ func (websocketClient *MockWebsocketClient) Stop() {
 	websocketClient.MethodCalled("Stop")
 }
 
+func (websocketClient *MockWebsocketClient) ForceReconnect(reason error) {
+	websocketClient.MethodCalled("ForceReconnect", reason)
+}
+
 func (websocketClient *MockWebsocketClient) SetMessageHandler(handler func(data []byte) error) {
 	websocketClient.MessageHandler = handler
 }
@@ -422,8 +436,12 @@ func (suite *OcppJTestSuite) TestCallMaxValidation() {
 	call, err := suite.chargePoint.CreateCall(request)
 	assert.Nil(t, call)
 	assert.NotNil(t, err)
-	assert.IsType(t, validator.ValidationErrors{}, err)
-	errors := err.(validator.ValidationErrors)
+	var validationErr *ocppj.ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	var fieldErrors validator.ValidationErrors
+	require.True(t, errors.As(err, &fieldErrors))
+	assert.Equal(t, validationErr.FieldErrors, fieldErrors)
+	errors := validationErr.FieldErrors
 	assert.Equal(t, 1, len(errors))
 	validationError := errors[0]
 	assert.Equal(t, "max", validationError.Tag())
@@ -437,8 +455,12 @@ func (suite *OcppJTestSuite) TestCallRequiredValidation() {
 	call, err := suite.chargePoint.CreateCall(request)
 	assert.Nil(t, call)
 	assert.NotNil(t, err)
-	assert.IsType(t, validator.ValidationErrors{}, err)
-	errors := err.(validator.ValidationErrors)
+	var validationErr *ocppj.ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	var fieldErrors validator.ValidationErrors
+	require.True(t, errors.As(err, &fieldErrors))
+	assert.Equal(t, validationErr.FieldErrors, fieldErrors)
+	errors := validationErr.FieldErrors
 	assert.Equal(t, 1, len(errors))
 	validationError := errors[0]
 	assert.Equal(t, "required", validationError.Tag())
@@ -453,8 +475,12 @@ func (suite *OcppJTestSuite) TestCallResultMinValidation() {
 	callResult, err := suite.chargePoint.CreateCallResult(confirmation, mockUniqueId)
 	assert.Nil(t, callResult)
 	assert.NotNil(t, err)
-	assert.IsType(t, validator.ValidationErrors{}, err)
-	errors := err.(validator.ValidationErrors)
+	var validationErr *ocppj.ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	var fieldErrors validator.ValidationErrors
+	require.True(t, errors.As(err, &fieldErrors))
+	assert.Equal(t, validationErr.FieldErrors, fieldErrors)
+	errors := validationErr.FieldErrors
 	assert.Equal(t, 1, len(errors))
 	validationError := errors[0]
 	assert.Equal(t, "min", validationError.Tag())
@@ -469,8 +495,12 @@ func (suite *OcppJTestSuite) TestCallResultRequiredValidation() {
 	callResult, err := suite.chargePoint.CreateCallResult(confirmation, mockUniqueId)
 	assert.Nil(t, callResult)
 	assert.NotNil(t, err)
-	assert.IsType(t, validator.ValidationErrors{}, err)
-	errors := err.(validator.ValidationErrors)
+	var validationErr *ocppj.ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	var fieldErrors validator.ValidationErrors
+	require.True(t, errors.As(err, &fieldErrors))
+	assert.Equal(t, validationErr.FieldErrors, fieldErrors)
+	errors := validationErr.FieldErrors
 	assert.Equal(t, 1, len(errors))
 	validationError := errors[0]
 	assert.Equal(t, "required", validationError.Tag())
@@ -0,0 +1,61 @@
+package ocppj
+
+import (
+	"github.com/lorenzodonini/ocpp-go/logging"
+)
+
+// Description of the InternalError sent back for requests, whose handler panicked.
+// The panic value is not disclosed to the other endpoint.
+const panicErrorDescription = "internal error while handling the request"
+
+// SetPanicHandler sets a handler, which is invoked whenever a handler or callback of the application panics.
+//
+// Panics are always recovered and logged along with their stack trace, regardless of whether a panic handler is set.
+// If a request handler panicked, an InternalError is sent back to the other endpoint, so it isn't left waiting for a response.
+func (endpoint *Endpoint) SetPanicHandler(handler func(err *HandlerPanicError)) {
+	endpoint.panicHandler = handler
+}
+
+// Logs a recovered panic and passes it to the panic handler, if any.
+func (endpoint *Endpoint) reportPanic(err *HandlerPanicError) {
+	logger := endpoint.getLogger().With(logging.ChargePointID(err.ClientID))
+	if err.Action != "" {
+		logger = logger.With(logging.Action(err.Action))
+	}
+	logger.Errorf("recovered panic in handler: %v\n%s", err.Value, err.Stack)
+	if endpoint.panicHandler != nil {
+		endpoint.panicHandler(err)
+	}
+}
+
+// Recovers from a panic of a handler, which doesn't need to be responded to.
+// Must be deferred directly, since recover has no effect otherwise.
+func (endpoint *Endpoint) recoverPanic(clientID string, action string) {
+	if r := recover(); r != nil {
+		endpoint.reportPanic(NewHandlerPanicError(clientID, action, r))
+	}
+}
+
+// HandlePanic handles a panic, which was recovered while invoking an application handler for a client.
+// The panic is logged and passed to the panic handler.
+// If a requestID is passed, an InternalError is sent to the client, so it isn't left waiting for a response.
+//
+// The function allows higher layers, which invoke handlers on separate goroutines, to recover panics consistently.
+func (s *Server) HandlePanic(clientID string, requestID string, err *HandlerPanicError) {
+	s.reportPanic(err)
+	if requestID != "" {
+		_ = s.SendError(clientID, requestID, InternalError, panicErrorDescription, nil)
+	}
+}
+
+// HandlePanic handles a panic, which was recovered while invoking an application handler.
+// The panic is logged and passed to the panic handler.
+// If a requestID is passed, an InternalError is sent to the server, so it isn't left waiting for a response.
+//
+// The function allows higher layers, which invoke handlers on separate goroutines, to recover panics consistently.
+func (c *Client) HandlePanic(requestID string, err *HandlerPanicError) {
+	c.reportPanic(err)
+	if requestID != "" {
+		_ = c.SendError(requestID, InternalError, panicErrorDescription, nil)
+	}
+}
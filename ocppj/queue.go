@@ -1,8 +1,8 @@
 package ocppj
 
 import (
-	"fmt"
 	"sync"
+	"time"
 )
 
 // RequestBundle is a convenience struct for passing a call object struct and the
@@ -10,6 +10,8 @@ import (
 type RequestBundle struct {
 	Call *Call
 	Data []byte
+	// The time the request was enqueued at. Set by the dispatcher, if empty.
+	EnqueuedAt time.Time
 }
 
 // RequestQueue can be arbitrarily implemented, as long as it conforms to the Queue interface.
@@ -34,6 +36,16 @@ type RequestQueue interface {
 	IsEmpty() bool
 }
 
+// RemovableQueue is implemented by request queues, which support removing arbitrary elements, such as FIFOClientQueue.
+//
+// It is not part of RequestQueue, so that existing RequestQueue implementations remain valid.
+// Canceling or draining queued requests is only supported by queues implementing it.
+type RemovableQueue interface {
+	// Remove removes the first element matching the given predicate from the queue and returns it.
+	// If no element matches, nil is returned.
+	Remove(match func(element interface{}) bool) interface{}
+}
+
 // FIFOClientQueue is a default queue implementation. The queue is thread-safe.
 type FIFOClientQueue struct {
 	elements []interface{}
@@ -51,7 +63,7 @@ func (q *FIFOClientQueue) Push(element interface{}) error {
 	q.mutex.Lock()
 	defer q.mutex.Unlock()
 	if len(q.elements) >= q.capacity && q.capacity > 0 {
-		return fmt.Errorf("request queue is full, cannot push new element")
+		return ErrQueueFull
 	}
 	q.elements = append(q.elements, element)
 	return nil
@@ -73,10 +85,28 @@ func (q *FIFOClientQueue) Pop() interface{} {
 		return nil
 	}
 	result := q.elements[0]
-	q.elements = q.elements[1:]
+	q.elements[0] = nil
+	if len(q.elements) == 1 {
+		// Reuse the backing array, instead of reallocating it on the next push
+		q.elements = q.elements[:0]
+	} else {
+		q.elements = q.elements[1:]
+	}
 	return result
 }
 
+func (q *FIFOClientQueue) Remove(match func(element interface{}) bool) interface{} {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for i, el := range q.elements {
+		if match(el) {
+			q.elements = append(q.elements[:i:i], q.elements[i+1:]...)
+			return el
+		}
+	}
+	return nil
+}
+
 func (q *FIFOClientQueue) Size() int {
 	q.mutex.RLock()
 	defer q.mutex.RUnlock()
@@ -0,0 +1,287 @@
+package ocppj
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+	"sync"
+
+	"github.com/xeipuuv/gojsonschema"
+
+	"github.com/lorenzodonini/ocpp-go/logging"
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+)
+
+// SchemaValidationMode defines how violations of the JSON schema of a message payload are handled.
+type SchemaValidationMode int
+
+const (
+	SchemaValidationOff     SchemaValidationMode = iota // Payloads are not validated against JSON schemas.
+	SchemaValidationWarn                                // Violations are logged and reported to the schema violation handler, but messages are processed regularly.
+	SchemaValidationEnforce                             // Messages violating their schema are rejected.
+)
+
+// SchemaDirection selects the payloads, to which a SchemaValidationMode applies.
+type SchemaDirection int
+
+const (
+	SchemaInbound        SchemaDirection = 1 << iota // Payloads received from the other endpoint.
+	SchemaOutbound                                   // Payloads sent to the other endpoint.
+	SchemaBothDirections = SchemaInbound | SchemaOutbound
+)
+
+// SchemaViolation is a single violation of a JSON schema.
+type SchemaViolation struct {
+	Pointer     string // The JSON pointer to the violating field, relative to the payload. Empty for the payload itself.
+	Type        string // The violated constraint, e.g. "required", "pattern" or "additional_property_not_allowed".
+	Description string
+}
+
+// SchemaValidationError is returned or reported, whenever a message payload violates its JSON schema.
+//
+// Inbound messages violating their schema in SchemaValidationEnforce mode are rejected with a CALLERROR,
+// outbound messages are not sent and the error is returned to the caller.
+type SchemaValidationError struct {
+	ClientID    string // The ID of the client the message was exchanged with. Empty on client endpoints.
+	Direction   SchemaDirection
+	MessageType MessageType
+	Action      string
+	UniqueID    string
+	Violations  []SchemaViolation
+}
+
+func (e *SchemaValidationError) Error() string {
+	descriptions := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		descriptions[i] = fmt.Sprintf("%s: %s", pointerOrRoot(v.Pointer), v.Description)
+	}
+	return fmt.Sprintf("schema validation failed for %v [%v]: %v", e.Action, e.UniqueID, strings.Join(descriptions, "; "))
+}
+
+// Converts the error to an OCPP error, which may be sent to the other endpoint.
+// The error code is derived from the first violation.
+func (e *SchemaValidationError) ocppError(d dialector) *ocpp.Error {
+	code := PropertyConstraintViolation
+	if len(e.Violations) > 0 {
+		switch e.Violations[0].Type {
+		case "required":
+			code = OccurrenceConstraintViolation
+		case "invalid_type":
+			code = TypeConstraintViolation
+		case "additional_property_not_allowed":
+			code = FormatErrorType(d)
+		}
+	}
+	description := e.Error()
+	if len(e.Violations) > 0 {
+		v := e.Violations[0]
+		description = fmt.Sprintf("Field %s violates schema of %s: %s", pointerOrRoot(v.Pointer), e.Action, v.Description)
+	}
+	return ocpp.NewError(code, description, e.UniqueID)
+}
+
+func pointerOrRoot(pointer string) string {
+	if pointer == "" {
+		return "/"
+	}
+	return pointer
+}
+
+// Holds the schema validation settings of an endpoint, as well as the compiled schemas.
+type schemaValidator struct {
+	inbound          SchemaValidationMode
+	outbound         SchemaValidationMode
+	schemas          fs.FS
+	violationHandler func(err *SchemaValidationError)
+	compiled         map[string]*gojsonschema.Schema // Nil entries mark missing schemas
+	mutex            sync.Mutex
+}
+
+func (v *schemaValidator) mode(direction SchemaDirection) SchemaValidationMode {
+	if v == nil || v.schemas == nil {
+		return SchemaValidationOff
+	}
+	if direction == SchemaInbound {
+		return v.inbound
+	}
+	return v.outbound
+}
+
+// Returns the compiled schema for a message payload, or nil if no schema exists.
+//
+// Schemas are looked up by file name: requests as <Action>Request.json, falling back to <Action>.json
+// (the naming used by OCPP 1.6), and responses as <Action>Response.json.
+func (v *schemaValidator) schema(action string, messageType MessageType) (*gojsonschema.Schema, error) {
+	candidates := []string{action + "Response.json"}
+	if messageType == CALL {
+		candidates = []string{action + "Request.json", action + ".json"}
+	}
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if schema, ok := v.compiled[candidates[0]]; ok {
+		return schema, nil
+	}
+	var schema *gojsonschema.Schema
+	for _, name := range candidates {
+		data, err := fs.ReadFile(v.schemas, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		schema, err = gojsonschema.NewSchema(gojsonschema.NewBytesLoader(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid schema %v: %w", name, err)
+		}
+		break
+	}
+	v.compiled[candidates[0]] = schema
+	return schema, nil
+}
+
+// Validates a payload against its schema. Returns nil if the payload is valid or no schema exists.
+func (v *schemaValidator) validate(action string, messageType MessageType, payload []byte) ([]SchemaViolation, error) {
+	schema, err := v.schema(action, messageType)
+	if err != nil || schema == nil {
+		return nil, err
+	}
+	result, err := schema.Validate(gojsonschema.NewBytesLoader(payload))
+	if err != nil {
+		return nil, err
+	}
+	if result.Valid() {
+		return nil, nil
+	}
+	violations := make([]SchemaViolation, 0, len(result.Errors()))
+	for _, resultErr := range result.Errors() {
+		pointer := strings.TrimPrefix(resultErr.Context().String("/"), "(root)")
+		switch resultErr.Type() {
+		case "required", "additional_property_not_allowed":
+			// The context refers to the parent object, point to the property instead
+			if property, ok := resultErr.Details()["property"].(string); ok {
+				pointer = pointer + "/" + property
+			}
+		}
+		violations = append(violations, SchemaViolation{Pointer: pointer, Type: resultErr.Type(), Description: resultErr.Description()})
+	}
+	return violations, nil
+}
+
+// SetSchemas sets the JSON schemas, against which message payloads are validated. Passing nil disables schema validation.
+//
+// The official schemas published with the OCPP specification may be used as is, e.g. by embedding them:
+//
+//	//go:embed schemas/*.json
+//	var schemaFiles embed.FS
+//	...
+//	schemas, _ := fs.Sub(schemaFiles, "schemas")
+//	endpoint.SetSchemas(schemas)
+//
+// Request schemas are looked up as <Action>Request.json or <Action>.json, response schemas as <Action>Response.json.
+// Actions without a schema are not validated. Schemas are compiled on first use and cached.
+func (endpoint *Endpoint) SetSchemas(schemas fs.FS) {
+	v := endpoint.getSchemaValidator()
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.schemas = schemas
+	v.compiled = map[string]*gojsonschema.Schema{}
+}
+
+// SetSchemaValidation sets the schema validation mode for the payloads of the given directions.
+// The mode of other directions is left unchanged. Schema validation is disabled by default.
+//
+// Schema validation is independent of the struct validation, which may be toggled via SetMessageValidation.
+// Either one, both or none may be enabled. Schema validation has no effect, until schemas were set via SetSchemas.
+//
+// The mode must be set before starting the endpoint.
+func (endpoint *Endpoint) SetSchemaValidation(mode SchemaValidationMode, directions SchemaDirection) {
+	v := endpoint.getSchemaValidator()
+	if directions&SchemaInbound != 0 {
+		v.inbound = mode
+	}
+	if directions&SchemaOutbound != 0 {
+		v.outbound = mode
+	}
+}
+
+// SetSchemaViolationHandler sets a handler, which is invoked for every schema violation detected in SchemaValidationWarn mode.
+// Violations detected in SchemaValidationEnforce mode are returned as errors instead.
+func (endpoint *Endpoint) SetSchemaViolationHandler(handler func(err *SchemaValidationError)) {
+	endpoint.getSchemaValidator().violationHandler = handler
+}
+
+func (endpoint *Endpoint) getSchemaValidator() *schemaValidator {
+	if endpoint.schemaValidation == nil {
+		endpoint.schemaValidation = &schemaValidator{compiled: map[string]*gojsonschema.Schema{}}
+	}
+	return endpoint.schemaValidation
+}
+
+// Validates the payload of a message against its schema, according to the validation mode of the direction.
+// A *SchemaValidationError is only returned in SchemaValidationEnforce mode, violations are reported to the handler otherwise.
+func (endpoint *Endpoint) checkSchema(clientID string, direction SchemaDirection, messageType MessageType, action string, uniqueID string, payload interface{}) error {
+	v := endpoint.schemaValidation
+	mode := v.mode(direction)
+	if mode == SchemaValidationOff {
+		return nil
+	}
+	logger := endpoint.getLogger().With(logging.Action(action), logging.UniqueID(uniqueID))
+	if clientID != "" {
+		logger = logger.With(logging.ChargePointID(clientID))
+	}
+	var data []byte
+	var err error
+	if raw, ok := payload.(json.RawMessage); ok {
+		data = raw
+	} else if data, err = json.Marshal(payload); err != nil {
+		return err
+	}
+	violations, err := v.validate(action, messageType, data)
+	if err != nil {
+		// Broken schemas must not block message processing
+		logger.Errorf("couldn't validate schema of %v [%v]: %v", action, uniqueID, err)
+		return nil
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+	schemaErr := &SchemaValidationError{
+		ClientID:    clientID,
+		Direction:   direction,
+		MessageType: messageType,
+		Action:      action,
+		UniqueID:    uniqueID,
+		Violations:  violations,
+	}
+	if mode == SchemaValidationEnforce {
+		return schemaErr
+	}
+	logger.Error(schemaErr)
+	if v.violationHandler != nil {
+		v.violationHandler(schemaErr)
+	}
+	return nil
+}
+
+// Validates the payload of an inbound CALL or CALL RESULT. In SchemaValidationEnforce mode, violations are returned as OCPP error.
+func (endpoint *Endpoint) checkInboundSchema(clientID string, message Message, arr []interface{}) *ocpp.Error {
+	var action string
+	var payload interface{}
+	switch m := message.(type) {
+	case *Call:
+		action, payload = m.Action, arr[3]
+	case *CallResult:
+		action, payload = m.Payload.GetFeatureName(), arr[2]
+	default:
+		return nil
+	}
+	err := endpoint.checkSchema(clientID, SchemaInbound, message.GetMessageTypeId(), action, message.GetUniqueId(), payload)
+	if schemaErr, ok := err.(*SchemaValidationError); ok {
+		return schemaErr.ocppError(endpoint)
+	} else if err != nil {
+		return ocpp.NewError(FormatErrorType(endpoint), err.Error(), message.GetUniqueId())
+	}
+	return nil
+}
@@ -0,0 +1,249 @@
+package ocppj_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"sync"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/lorenzodonini/ocpp-go/ws"
+)
+
+// Schemas for the mock feature. Additional properties are allowed in requests,
+// since the embedded mock fields are serialized as well.
+const mockRequestSchema = `{
+	"$schema": "http://json-schema.org/draft-04/schema#",
+	"type": "object",
+	"properties": {
+		"mockValue": {"type": "string", "pattern": "^[a-z]+$"},
+		"mockAny": {"type": ["object", "null"], "properties": {"inner": {"type": "integer"}}, "additionalProperties": false}
+	},
+	"required": ["mockValue"]
+}`
+
+const mockResponseSchema = `{
+	"$schema": "http://json-schema.org/draft-06/schema#",
+	"type": "object",
+	"properties": {
+		"mockValue": {"type": "string", "maxLength": 6}
+	},
+	"required": ["mockValue"]
+}`
+
+// Counts how often each schema file is read.
+type countingFS struct {
+	fs.FS
+	reads map[string]int
+	mutex sync.Mutex
+}
+
+func (c *countingFS) Open(name string) (fs.File, error) {
+	c.mutex.Lock()
+	c.reads[name]++
+	c.mutex.Unlock()
+	return c.FS.Open(name)
+}
+
+func newMockSchemas() *countingFS {
+	return &countingFS{FS: fstest.MapFS{
+		// OCPP 1.6 naming
+		"Mock.json":         {Data: []byte(mockRequestSchema)},
+		"MockResponse.json": {Data: []byte(mockResponseSchema)},
+	}, reads: map[string]int{}}
+}
+
+func (suite *OcppJTestSuite) TestServerInboundSchemaEnforce() {
+	t := suite.T()
+	mockChargePointId := "1234"
+	suite.centralSystem.SetSchemas(newMockSchemas())
+	suite.centralSystem.SetSchemaValidation(ocppj.SchemaValidationEnforce, ocppj.SchemaInbound)
+	writtenC := make(chan []byte, 1)
+	suite.mockServer.On("Start", mock.AnythingOfType("int"), mock.AnythingOfType("string")).Return()
+	suite.mockServer.On("Write", mockChargePointId, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		writtenC <- args.Get(1).([]byte)
+	})
+	suite.centralSystem.SetRequestHandler(func(client ws.Channel, request ocpp.Request, requestId string, action string) {
+		assert.Fail(t, "unexpected request")
+	})
+	suite.centralSystem.Start(8887, "somePath")
+	// Passes struct validation, but violates the schema pattern
+	err := suite.mockServer.MessageHandler(NewMockWebSocket(mockChargePointId), []byte(fmt.Sprintf(`[2,"1234","%v",{"mockValue":"ABC"}]`, MockFeatureName)))
+	require.Error(t, err)
+	ocppErr, ok := err.(*ocpp.Error)
+	require.True(t, ok)
+	assert.Equal(t, ocppj.PropertyConstraintViolation, ocppErr.Code)
+	assert.Contains(t, ocppErr.Description, "/mockValue")
+	var callError []interface{}
+	require.NoError(t, json.Unmarshal(<-writtenC, &callError))
+	assert.Equal(t, []interface{}{float64(ocppj.CALL_ERROR), "1234", string(ocppj.PropertyConstraintViolation), ocppErr.Description, map[string]interface{}{}}, callError)
+	// Nested type violations are reported with the full pointer
+	err = suite.mockServer.MessageHandler(NewMockWebSocket(mockChargePointId), []byte(fmt.Sprintf(`[2,"1235","%v",{"mockValue":"abc","mockAny":{"inner":"x"}}]`, MockFeatureName)))
+	require.Error(t, err)
+	ocppErr, _ = err.(*ocpp.Error)
+	require.NotNil(t, ocppErr)
+	assert.Equal(t, ocppj.TypeConstraintViolation, ocppErr.Code)
+	assert.Contains(t, ocppErr.Description, "/mockAny/inner")
+	<-writtenC
+}
+
+func (suite *OcppJTestSuite) TestServerInboundSchemaLayering() {
+	t := suite.T()
+	mockChargePointId := "1234"
+	suite.mockServer.On("Start", mock.AnythingOfType("int"), mock.AnythingOfType("string")).Return()
+	suite.mockServer.On("Write", mockChargePointId, mock.Anything).Return(nil)
+	handledC := make(chan string, 1)
+	suite.centralSystem.SetRequestHandler(func(client ws.Channel, request ocpp.Request, requestId string, action string) {
+		handledC <- requestId
+	})
+	suite.centralSystem.Start(8887, "somePath")
+	channel := NewMockWebSocket(mockChargePointId)
+	// Violates the max length of the struct validation, but not the schema
+	tooLong := fmt.Sprintf(`[2,"%%v","%v",{"mockValue":"abcdefghijklmnop"}]`, MockFeatureName)
+	// Violates the schema, but not the struct validation
+	wrongPattern := fmt.Sprintf(`[2,"%%v","%v",{"mockValue":"ABC"}]`, MockFeatureName)
+	// Struct validation only
+	err := suite.mockServer.MessageHandler(channel, []byte(fmt.Sprintf(tooLong, "1")))
+	require.Error(t, err)
+	err = suite.mockServer.MessageHandler(channel, []byte(fmt.Sprintf(wrongPattern, "2")))
+	require.NoError(t, err)
+	assert.Equal(t, "2", <-handledC)
+	// Schema validation only
+	ocppj.SetMessageValidation(false)
+	defer ocppj.SetMessageValidation(true)
+	suite.centralSystem.SetSchemas(newMockSchemas())
+	suite.centralSystem.SetSchemaValidation(ocppj.SchemaValidationEnforce, ocppj.SchemaBothDirections)
+	err = suite.mockServer.MessageHandler(channel, []byte(fmt.Sprintf(tooLong, "3")))
+	require.NoError(t, err)
+	assert.Equal(t, "3", <-handledC)
+	err = suite.mockServer.MessageHandler(channel, []byte(fmt.Sprintf(wrongPattern, "4")))
+	require.Error(t, err)
+	// Both
+	ocppj.SetMessageValidation(true)
+	err = suite.mockServer.MessageHandler(channel, []byte(fmt.Sprintf(tooLong, "5")))
+	require.Error(t, err)
+	err = suite.mockServer.MessageHandler(channel, []byte(fmt.Sprintf(wrongPattern, "6")))
+	require.Error(t, err)
+	assert.Empty(t, handledC)
+}
+
+func (suite *OcppJTestSuite) TestServerInboundSchemaWarn() {
+	t := suite.T()
+	mockChargePointId := "1234"
+	schemas := newMockSchemas()
+	suite.centralSystem.SetSchemas(schemas)
+	suite.centralSystem.SetSchemaValidation(ocppj.SchemaValidationWarn, ocppj.SchemaInbound)
+	var violations []*ocppj.SchemaValidationError
+	suite.centralSystem.SetSchemaViolationHandler(func(err *ocppj.SchemaValidationError) {
+		violations = append(violations, err)
+	})
+	suite.mockServer.On("Start", mock.AnythingOfType("int"), mock.AnythingOfType("string")).Return()
+	handledC := make(chan string, 2)
+	suite.centralSystem.SetRequestHandler(func(client ws.Channel, request ocpp.Request, requestId string, action string) {
+		handledC <- requestId
+	})
+	suite.centralSystem.Start(8887, "somePath")
+	channel := NewMockWebSocket(mockChargePointId)
+	// Violations are reported, but the request is handled regularly
+	err := suite.mockServer.MessageHandler(channel, []byte(fmt.Sprintf(`[2,"1","%v",{"mockValue":"ABC","mockAny":{"inner":1,"other":2}}]`, MockFeatureName)))
+	require.NoError(t, err)
+	assert.Equal(t, "1", <-handledC)
+	require.Len(t, violations, 1)
+	assert.Equal(t, mockChargePointId, violations[0].ClientID)
+	assert.Equal(t, ocppj.SchemaInbound, violations[0].Direction)
+	assert.Equal(t, ocppj.CALL, violations[0].MessageType)
+	assert.Equal(t, MockFeatureName, violations[0].Action)
+	assert.Equal(t, "1", violations[0].UniqueID)
+	require.Len(t, violations[0].Violations, 2)
+	pointers := map[string]string{}
+	for _, v := range violations[0].Violations {
+		pointers[v.Pointer] = v.Type
+	}
+	assert.Equal(t, map[string]string{"/mockValue": "pattern", "/mockAny/other": "additional_property_not_allowed"}, pointers)
+	// Valid payloads aren't reported
+	err = suite.mockServer.MessageHandler(channel, []byte(fmt.Sprintf(`[2,"2","%v",{"mockValue":"abc"}]`, MockFeatureName)))
+	require.NoError(t, err)
+	assert.Equal(t, "2", <-handledC)
+	assert.Len(t, violations, 1)
+	// The compiled schema is cached
+	assert.Equal(t, 1, schemas.reads["Mock.json"])
+	assert.Equal(t, 1, schemas.reads["MockRequest.json"])
+}
+
+func (suite *OcppJTestSuite) TestServerOutboundSchemaEnforce() {
+	t := suite.T()
+	mockChargePointId := "1234"
+	suite.centralSystem.SetSchemas(newMockSchemas())
+	suite.centralSystem.SetSchemaValidation(ocppj.SchemaValidationEnforce, ocppj.SchemaOutbound)
+	writtenC := make(chan []byte, 1)
+	suite.mockServer.On("Start", mock.AnythingOfType("int"), mock.AnythingOfType("string")).Return()
+	suite.mockServer.On("Write", mockChargePointId, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		writtenC <- args.Get(1).([]byte)
+	})
+	suite.centralSystem.Start(8887, "somePath")
+	suite.serverDispatcher.CreateClient(mockChargePointId)
+	// Request
+	_, err := suite.centralSystem.SendRequestWithID(mockChargePointId, newMockRequest("ABC"))
+	require.Error(t, err)
+	schemaErr, ok := err.(*ocppj.SchemaValidationError)
+	require.True(t, ok)
+	assert.Equal(t, ocppj.SchemaOutbound, schemaErr.Direction)
+	assert.Equal(t, mockChargePointId, schemaErr.ClientID)
+	require.Len(t, schemaErr.Violations, 1)
+	assert.Equal(t, "/mockValue", schemaErr.Violations[0].Pointer)
+	assert.Empty(t, writtenC)
+	// Response, passing struct validation but exceeding the schema max length
+	err = suite.centralSystem.SendResponse(mockChargePointId, "5678", newMockConfirmation("someValue"))
+	require.Error(t, err)
+	schemaErr, ok = err.(*ocppj.SchemaValidationError)
+	require.True(t, ok)
+	assert.Equal(t, ocppj.CALL_RESULT, schemaErr.MessageType)
+	assert.Equal(t, "string_lte", schemaErr.Violations[0].Type)
+	assert.Empty(t, writtenC)
+	// The failed response is replaced by a CALL ERROR
+	suite.centralSystem.HandleFailedResponseError(mockChargePointId, "5678", err, MockFeatureName)
+	var callError []interface{}
+	require.NoError(t, json.Unmarshal(<-writtenC, &callError))
+	require.Len(t, callError, 5)
+	assert.Equal(t, string(ocppj.PropertyConstraintViolation), callError[2])
+	// Valid messages are sent
+	err = suite.centralSystem.SendResponse(mockChargePointId, "5679", newMockConfirmation("value"))
+	require.NoError(t, err)
+	<-writtenC
+}
+
+func (suite *OcppJTestSuite) TestClientSchemaValidation() {
+	t := suite.T()
+	suite.chargePoint.SetSchemas(newMockSchemas())
+	suite.chargePoint.SetSchemaValidation(ocppj.SchemaValidationEnforce, ocppj.SchemaBothDirections)
+	writtenC := make(chan []byte, 1)
+	suite.mockClient.On("Write", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		writtenC <- args.Get(0).([]byte)
+	})
+	suite.mockClient.On("Start", mock.AnythingOfType("string")).Return(nil)
+	suite.chargePoint.SetRequestHandler(func(request ocpp.Request, requestId string, action string) {
+		assert.Fail(t, "unexpected request")
+	})
+	require.NoError(t, suite.chargePoint.Start("someUrl"))
+	// Outbound
+	err := suite.chargePoint.SendRequest(newMockRequest("ABC"))
+	require.Error(t, err)
+	assert.IsType(t, &ocppj.SchemaValidationError{}, err)
+	assert.Empty(t, writtenC)
+	// Inbound, missing required field
+	ocppj.SetMessageValidation(false)
+	defer ocppj.SetMessageValidation(true)
+	err = suite.mockClient.MessageHandler([]byte(fmt.Sprintf(`[2,"1234","%v",{}]`, MockFeatureName)))
+	require.Error(t, err)
+	ocppErr, ok := err.(*ocpp.Error)
+	require.True(t, ok)
+	assert.Equal(t, ocppj.OccurrenceConstraintViolation, ocppErr.Code)
+	assert.Contains(t, ocppErr.Description, "/mockValue")
+	<-writtenC
+}
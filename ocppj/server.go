@@ -1,10 +1,16 @@
 package ocppj
 
 import (
+	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	"gopkg.in/go-playground/validator.v9"
 
+	"github.com/lorenzodonini/ocpp-go/clock"
+	"github.com/lorenzodonini/ocpp-go/events"
+	"github.com/lorenzodonini/ocpp-go/logging"
 	"github.com/lorenzodonini/ocpp-go/ocpp"
 	"github.com/lorenzodonini/ocpp-go/ws"
 )
@@ -18,15 +24,28 @@ type Server struct {
 	newClientHandler          ClientHandler
 	disconnectedClientHandler ClientHandler
 	requestHandler            RequestHandler
+	requestHandlerWithContext RequestHandlerWithContext
 	responseHandler           ResponseHandler
 	errorHandler              ErrorHandler
 	invalidMessageHook        InvalidMessageHook
+	canceledRequestHandler    CanceledRequestHandler
 	dispatcher                ServerDispatcher
+	audit                     *auditor
+	tracing                   spanTracker
+	clients                   map[string]*clientConnection
+	clientsMutex              sync.Mutex
+	hold                      requestHold
+	inbound                   inboundDispatcher
+	liveness                  livenessMonitor
 	RequestState              ServerState
 }
 
 type ClientHandler func(client ws.Channel)
 type RequestHandler func(client ws.Channel, request ocpp.Request, requestId string, action string)
+
+// RequestHandlerWithContext is the context-aware variant of RequestHandler.
+// The context is derived from the base context of the client connection and carries the RequestInfo of the request.
+type RequestHandlerWithContext func(ctx context.Context, client ws.Channel, request ocpp.Request, requestId string, action string)
 type ResponseHandler func(client ws.Channel, response ocpp.Response, requestId string)
 type ErrorHandler func(client ws.Channel, err *ocpp.Error, details interface{})
 type InvalidMessageHook func(client ws.Channel, err *ocpp.Error, rawJson string, parsedFields []interface{}) *ocpp.Error
@@ -59,11 +78,86 @@ func NewServer(wsServer ws.WsServer, dispatcher ServerDispatcher, stateHandler S
 	dispatcher.SetPendingRequestState(stateHandler)
 
 	// Create server and add profiles
-	s := Server{Endpoint: Endpoint{}, server: wsServer, RequestState: stateHandler, dispatcher: dispatcher}
+	s := &Server{Endpoint: Endpoint{}, server: wsServer, RequestState: stateHandler, dispatcher: dispatcher, clients: map[string]*clientConnection{}}
 	for _, profile := range profiles {
 		s.AddProfile(profile)
 	}
-	return &s
+	dispatcher.SetOnRequestCanceled(s.onRequestCanceled)
+	if d, ok := dispatcher.(interface {
+		SetOnRequestThrottled(cb func(clientID string, call *Call, delay time.Duration))
+	}); ok {
+		d.SetOnRequestThrottled(s.onRequestThrottled)
+	}
+	return s
+}
+
+// SetLogger sets the logger used by this server and its dispatcher, overriding the package-level logger.
+// Log entries are enriched with the client ID and, where available, the action and unique ID of the processed message.
+// Passing nil restores the package-level logger.
+//
+// The websocket server keeps its own logger, refer to the ws package.
+func (s *Server) SetLogger(logger logging.Logger) {
+	s.logger = logger
+	if d, ok := s.dispatcher.(interface{ SetLogger(logger logging.Logger) }); ok {
+		d.SetLogger(logger)
+	}
+}
+
+// SetClock sets the clock used for request timeouts, audit entries and tracing. Passing nil restores the real clock.
+// The clock is passed on to the dispatcher as well, if it supports it.
+//
+// The clock must be set before starting the server.
+func (s *Server) SetClock(c clock.Clock) {
+	s.clock = c
+	s.tracing.setClock(c)
+	if d, ok := s.dispatcher.(interface{ SetClock(c clock.Clock) }); ok {
+		d.SetClock(c)
+	}
+}
+
+// SetEventBus sets a bus, on which RequestTimedOut and QueueOverflow events are published
+// for requests sent to clients. Passing nil disables publishing.
+//
+// Requires a dispatcher supporting it, such as the DefaultServerDispatcher.
+// The bus must be set before starting the server.
+func (s *Server) SetEventBus(bus *events.Bus) {
+	if d, ok := s.dispatcher.(interface{ SetEventBus(bus *events.Bus) }); ok {
+		d.SetEventBus(bus)
+	}
+}
+
+// SetMinSendInterval sets the minimum gap between sending two consecutive requests to the same client.
+// Some charge points fail to process several requests in quick succession, even though each one awaits the response
+// to the previous one. Responses to incoming requests are never delayed.
+// The time spent waiting is reported to the metrics collector, if it implements ThrottleCollector.
+//
+// Requires a dispatcher supporting it, such as the DefaultServerDispatcher. Passing zero disables throttling.
+func (s *Server) SetMinSendInterval(interval time.Duration) {
+	if d, ok := s.dispatcher.(interface{ SetMinSendInterval(interval time.Duration) }); ok {
+		d.SetMinSendInterval(interval)
+	}
+}
+
+// SetMinSendIntervalOverrides sets the minimum send interval for specific clients, by client ID,
+// overriding the interval passed to SetMinSendInterval. Passing nil removes all overrides.
+//
+// Requires a dispatcher supporting it, such as the DefaultServerDispatcher.
+func (s *Server) SetMinSendIntervalOverrides(overrides map[string]time.Duration) {
+	if d, ok := s.dispatcher.(interface {
+		SetMinSendIntervalOverrides(overrides map[string]time.Duration)
+	}); ok {
+		d.SetMinSendIntervalOverrides(overrides)
+	}
+}
+
+func (s *Server) onRequestThrottled(clientID string, call *Call, delay time.Duration) {
+	s.clientLogger(clientID).With(logging.Action(call.Action), logging.UniqueID(call.UniqueId)).Debugf("request %v was held back for %v", call.UniqueId, delay)
+	s.tracing.throttled(SpanInfo{Kind: SpanKindOutgoing, ClientID: clientID, Action: call.Action, UniqueID: call.UniqueId}, delay)
+}
+
+// Returns the logger for entries related to a specific client.
+func (s *Server) clientLogger(clientID string) logging.Logger {
+	return s.getLogger().With(logging.ChargePointID(clientID))
 }
 
 // Registers a handler for incoming requests.
@@ -71,6 +165,11 @@ func (s *Server) SetRequestHandler(handler RequestHandler) {
 	s.requestHandler = handler
 }
 
+// Registers a context-aware handler for incoming requests. If set, it is invoked instead of the RequestHandler.
+func (s *Server) SetRequestHandlerWithContext(handler RequestHandlerWithContext) {
+	s.requestHandlerWithContext = handler
+}
+
 // Registers a handler for incoming responses.
 func (s *Server) SetResponseHandler(handler ResponseHandler) {
 	s.responseHandler = handler
@@ -101,7 +200,16 @@ func (s *Server) SetInvalidMessageHook(hook InvalidMessageHook) {
 
 // Registers a handler for canceled request messages.
 func (s *Server) SetCanceledRequestHandler(handler CanceledRequestHandler) {
-	s.dispatcher.SetOnRequestCanceled(handler)
+	s.canceledRequestHandler = handler
+}
+
+// SetTracer enables tracing of the requests exchanged with clients. Passing nil disables tracing.
+//
+// A span is created for every request sent to a client, from enqueuing until the response is received,
+// and for every request received from a client, from reception until the response is sent back.
+// The tracer must be set before starting the server.
+func (s *Server) SetTracer(tracer Tracer) {
+	s.tracing.setTracer(tracer)
 }
 
 // Registers a handler for incoming client connections.
@@ -132,6 +240,7 @@ func (s *Server) Start(listenPort int, listenPath string) {
 	s.server.SetDisconnectedClientHandler(s.onClientDisconnected)
 	s.server.SetMessageHandler(s.ocppMessageHandler)
 	s.dispatcher.Start()
+	s.liveness.start(s)
 	// Serve & run
 	s.server.Start(listenPort, listenPath)
 	// TODO: return error?
@@ -141,7 +250,12 @@ func (s *Server) Start(listenPort int, listenPath string) {
 // This clears all pending requests and causes the Start function to return.
 func (s *Server) Stop() {
 	s.dispatcher.Stop()
+	s.liveness.stop()
 	s.server.Stop()
+	s.hold.drop("")
+	s.inbound.close(nil)
+	s.handlers.drop("")
+	s.tracing.endClient("", OutcomeCanceled, errEndpointStopped)
 }
 
 // Sends an OCPP Request to a client, identified by the clientID parameter.
@@ -156,23 +270,69 @@ func (s *Server) Stop() {
 //
 // - the output queue is full
 func (s *Server) SendRequest(clientID string, request ocpp.Request) error {
+	_, err := s.SendRequestWithID(clientID, request)
+	return err
+}
+
+// SendRequestWithID works like SendRequest, but additionally returns the unique message ID assigned to the request.
+//
+// The message ID may be used to cancel the request, by invoking CancelRequest.
+func (s *Server) SendRequestWithID(clientID string, request ocpp.Request) (string, error) {
+	return s.SendRequestWithContext(context.Background(), clientID, request)
+}
+
+// SendRequestWithContext works like SendRequestWithID, but additionally links the span of the request
+// to the trace contained in ctx, if a Tracer was set.
+//
+// The context is only used for tracing: canceling it doesn't cancel the request. Refer to CancelRequest instead.
+func (s *Server) SendRequestWithContext(ctx context.Context, clientID string, request ocpp.Request) (string, error) {
 	if !s.dispatcher.IsRunning() {
-		return fmt.Errorf("ocppj server is not started, couldn't send request")
+		return "", fmt.Errorf("ocppj server is %w, couldn't send request", ErrNotStarted)
 	}
 	call, err := s.CreateCall(request)
 	if err != nil {
-		return err
+		return "", err
+	}
+	if err = s.checkSchema(clientID, SchemaOutbound, CALL, call.Action, call.UniqueId, call.Payload); err != nil {
+		return "", err
 	}
 	jsonMessage, err := call.MarshalJSON()
 	if err != nil {
-		return err
+		return "", err
 	}
+	logger := s.clientLogger(clientID).With(logging.Action(call.Action), logging.UniqueID(call.UniqueId))
+	// The span must exist before the request is dispatched, as the response may arrive at any time
+	s.tracing.start(ctx, SpanInfo{Kind: SpanKindOutgoing, ClientID: clientID, Action: call.Action, UniqueID: call.UniqueId})
 	// Will not send right away. Queuing message and let it be processed by dedicated requestPump routine
-	if err = s.dispatcher.SendRequest(clientID, RequestBundle{call, jsonMessage}); err != nil {
-		log.Errorf("error dispatching request [%s, %s] to %s: %v", call.UniqueId, call.Action, clientID, err)
+	if err = s.dispatcher.SendRequest(clientID, RequestBundle{Call: call, Data: jsonMessage}); err != nil {
+		logger.Errorf("error dispatching request [%s, %s] to %s: %v", call.UniqueId, call.Action, clientID, err)
+		s.tracing.end(SpanKindOutgoing, clientID, call.UniqueId, OutcomeFailed, err)
+		return "", err
+	}
+	logger.Debugf("enqueued CALL [%s, %s] for %s", call.UniqueId, call.Action, clientID)
+	return call.UniqueId, nil
+}
+
+// Cancels an outstanding request to a client, identified by the clientID parameter.
+// The requestID is the unique message ID of the request, as returned by SendRequestWithID.
+//
+// A queued request is removed before being sent. A request that was already sent is no longer considered pending,
+// hence a late response to it will be discarded.
+// If a response to the request was already received, the response wins and an error is returned.
+//
+// The CanceledRequestHandler is not invoked for requests canceled via this function.
+//
+// Requires a dispatcher implementing RequestCanceler, such as the DefaultServerDispatcher, and a queue implementing
+// RemovableQueue, such as the FIFOClientQueue. Otherwise an error is returned.
+func (s *Server) CancelRequest(clientID string, requestID string) error {
+	canceler, ok := s.dispatcher.(RequestCanceler)
+	if !ok {
+		return fmt.Errorf("cannot cancel request %v, not supported by dispatcher %T", requestID, s.dispatcher)
+	}
+	if err := canceler.CancelRequest(clientID, requestID); err != nil {
 		return err
 	}
-	log.Debugf("enqueued CALL [%s, %s] for %s", call.UniqueId, call.Action, clientID)
+	s.tracing.end(SpanKindOutgoing, clientID, requestID, OutcomeCanceled, &RequestCanceledError{ClientID: clientID, RequestID: requestID})
 	return nil
 }
 
@@ -187,20 +347,30 @@ func (s *Server) SendRequest(clientID string, request ocpp.Request) error {
 //
 // - a network error occurred
 func (s *Server) SendResponse(clientID string, requestId string, response ocpp.Response) error {
+	if s.discardLateResponse(clientID, requestId) {
+		return nil
+	}
 	callResult, err := s.CreateCallResult(response, requestId)
 	if err != nil {
 		return err
 	}
+	if err = s.checkSchema(clientID, SchemaOutbound, CALL_RESULT, response.GetFeatureName(), requestId, response); err != nil {
+		return err
+	}
 	jsonMessage, err := callResult.MarshalJSON()
 	if err != nil {
 		return ocpp.NewError(GenericError, err.Error(), requestId)
 	}
+	s.audit.complete(clientID, requestId, response, nil)
+	logger := s.clientLogger(clientID).With(logging.Action(response.GetFeatureName()), logging.UniqueID(requestId))
 	if err = s.server.Write(clientID, jsonMessage); err != nil {
-		log.Errorf("error sending response [%s] to %s: %v", callResult.GetUniqueId(), clientID, err)
+		logger.Errorf("error sending response [%s] to %s: %v", callResult.GetUniqueId(), clientID, err)
+		s.tracing.end(SpanKindIncoming, clientID, requestId, OutcomeFailed, err)
 		return ocpp.NewError(GenericError, err.Error(), requestId)
 	}
-	log.Debugf("sent CALL RESULT [%s] for %s", callResult.GetUniqueId(), clientID)
-	log.Debugf("sent JSON message to %s: %s", clientID, string(jsonMessage))
+	s.tracing.end(SpanKindIncoming, clientID, requestId, OutcomeResponse, nil)
+	logger.Debugf("sent CALL RESULT [%s] for %s", callResult.GetUniqueId(), clientID)
+	logger.Debugf("sent JSON message to %s: %s", clientID, jsonMessage)
 	return nil
 }
 
@@ -213,6 +383,13 @@ func (s *Server) SendResponse(clientID string, requestId string, response ocpp.R
 //
 // - a network error occurred
 func (s *Server) SendError(clientID string, requestId string, errorCode ocpp.ErrorCode, description string, details interface{}) error {
+	if s.discardLateResponse(clientID, requestId) {
+		return nil
+	}
+	return s.sendError(clientID, requestId, errorCode, description, details)
+}
+
+func (s *Server) sendError(clientID string, requestId string, errorCode ocpp.ErrorCode, description string, details interface{}) error {
 	callError, err := s.CreateCallError(requestId, errorCode, description, details)
 	if err != nil {
 		return err
@@ -221,27 +398,83 @@ func (s *Server) SendError(clientID string, requestId string, errorCode ocpp.Err
 	if err != nil {
 		return ocpp.NewError(GenericError, err.Error(), requestId)
 	}
+	s.audit.complete(clientID, requestId, nil, ocpp.NewError(errorCode, description, requestId))
+	logger := s.clientLogger(clientID).With(logging.UniqueID(requestId))
 	if err = s.server.Write(clientID, jsonMessage); err != nil {
-		log.Errorf("error sending response error [%s] to %s: %v", callError.UniqueId, clientID, err)
+		logger.Errorf("error sending response error [%s] to %s: %v", callError.UniqueId, clientID, err)
+		s.tracing.end(SpanKindIncoming, clientID, requestId, OutcomeFailed, err)
 		return ocpp.NewError(GenericError, err.Error(), requestId)
 	}
-	log.Debugf("sent CALL ERROR [%s] for %s", callError.UniqueId, clientID)
+	s.tracing.end(SpanKindIncoming, clientID, requestId, OutcomeError, ocpp.NewError(errorCode, description, requestId))
+	logger.Debugf("sent CALL ERROR [%s] for %s", callError.UniqueId, clientID)
 	return nil
 }
 
 func (s *Server) ocppMessageHandler(wsChannel ws.Channel, data []byte) error {
-	parsedJson, err := ParseRawJsonMessage(data)
-	if err != nil {
-		log.Error(err)
-		return err
+	if s.inbound.enabled() {
+		s.inbound.push(s, wsChannel, data)
+		return nil
+	}
+	return s.handleMessage(wsChannel, data)
+}
+
+func (s *Server) handleMessage(wsChannel ws.Channel, data []byte) error {
+	received := s.now()
+	s.touchClient(wsChannel.ID(), received)
+	logger := s.clientLogger(wsChannel.ID())
+	// Pathological input is rejected before decoding
+	limitErr := s.checkParseLimits(data)
+	var parsedJson []interface{}
+	if limitErr == nil {
+		var err error
+		parsedJson, err = ParseRawJsonMessage(data)
+		if err != nil {
+			if s.audit != nil {
+				entry := s.newAuditEntry(wsChannel.ID(), received, data, nil)
+				entry.ParseError = ocpp.NewError(FormatErrorType(s), err.Error(), "")
+				s.audit.emit(entry)
+			}
+			logger.Error(err)
+			return err
+		}
+	}
+	logger.Debugf("received JSON message from %s: %s", wsChannel.ID(), data)
+	var auditEntry AuditEntry
+	if s.audit != nil {
+		auditEntry = s.newAuditEntry(wsChannel.ID(), received, data, parsedJson)
+	}
+	var message Message
+	err := limitErr
+	if err == nil {
+		// Get pending requests for client
+		pending := s.RequestState.GetClientState(wsChannel.ID())
+		message, err = s.ParseMessage(parsedJson, pending)
+	}
+	if err == nil && message != nil {
+		if schemaErr := s.checkInboundSchema(wsChannel.ID(), message, parsedJson); schemaErr != nil {
+			err = schemaErr
+		}
 	}
-	log.Debugf("received JSON message from %s: %s", wsChannel.ID(), string(data))
-	// Get pending requests for client
-	pending := s.RequestState.GetClientState(wsChannel.ID())
-	message, err := s.ParseMessage(parsedJson, pending)
 	if err != nil {
-		ocppErr := err.(*ocpp.Error)
+		ocppErr, ok := err.(*ocpp.Error)
+		if !ok {
+			ocppErr = ocpp.NewError(GenericError, err.Error(), "")
+		}
 		messageID := ocppErr.MessageId
+		s.tracing.unknownAction(SpanInfo{Kind: SpanKindIncoming, ClientID: wsChannel.ID(), UniqueID: messageID}, ocppErr)
+		if limitErr != nil && messageID != "" {
+			auditEntry.MessageType = CALL
+			auditEntry.UniqueID = messageID
+		}
+		if s.audit != nil {
+			auditEntry.ParseError = ocppErr
+			if auditEntry.MessageType == CALL && messageID != "" {
+				// Entry is delivered once the error was sent back
+				s.audit.await(auditEntry)
+			} else {
+				s.audit.emit(auditEntry)
+			}
+		}
 		// Support ad-hoc callback for invalid message handling
 		if s.invalidMessageHook != nil {
 			err2 := s.invalidMessageHook(wsChannel, ocppErr, string(data), parsedJson)
@@ -259,29 +492,42 @@ func (s *Server) ocppMessageHandler(wsChannel ws.Channel, data []byte) error {
 				return err2
 			}
 		}
-		log.Error(err)
+		logger.With(logging.UniqueID(ocppErr.MessageId)).Error(err)
 		return err
 	}
+	if s.audit != nil {
+		if message != nil && message.GetMessageTypeId() == CALL {
+			// Entry is delivered once the response was sent back
+			s.audit.await(auditEntry)
+		} else {
+			s.audit.emit(auditEntry)
+		}
+	}
 	if message != nil {
 		switch message.GetMessageTypeId() {
 		case CALL:
 			call := message.(*Call)
-			log.Debugf("handling incoming CALL [%s, %s] from %s", call.UniqueId, call.Action, wsChannel.ID())
-			if s.requestHandler != nil {
-				s.requestHandler(wsChannel, call.Payload, call.UniqueId, call.Action)
+			if s.hold.enqueue(s, wsChannel, call) {
+				logger.With(logging.Action(call.Action), logging.UniqueID(call.UniqueId)).Debugf("holding incoming CALL [%s, %s] from %s until handlers are ready", call.UniqueId, call.Action, wsChannel.ID())
+				return nil
 			}
+			s.handleCall(wsChannel, call)
 		case CALL_RESULT:
 			callResult := message.(*CallResult)
-			log.Debugf("handling incoming CALL RESULT [%s] from %s", callResult.UniqueId, wsChannel.ID())
+			logger.With(logging.UniqueID(callResult.UniqueId)).Debugf("handling incoming CALL RESULT [%s] from %s", callResult.UniqueId, wsChannel.ID())
 			s.dispatcher.CompleteRequest(wsChannel.ID(), callResult.GetUniqueId())
+			s.tracing.end(SpanKindOutgoing, wsChannel.ID(), callResult.UniqueId, OutcomeResponse, nil)
 			if s.responseHandler != nil {
+				defer s.recoverPanic(wsChannel.ID(), callResult.Payload.GetFeatureName())
 				s.responseHandler(wsChannel, callResult.Payload, callResult.UniqueId)
 			}
 		case CALL_ERROR:
 			callError := message.(*CallError)
-			log.Debugf("handling incoming CALL RESULT [%s] from %s", callError.UniqueId, wsChannel.ID())
+			logger.With(logging.UniqueID(callError.UniqueId)).Debugf("handling incoming CALL RESULT [%s] from %s", callError.UniqueId, wsChannel.ID())
 			s.dispatcher.CompleteRequest(wsChannel.ID(), callError.GetUniqueId())
+			s.tracing.end(SpanKindOutgoing, wsChannel.ID(), callError.UniqueId, OutcomeError, ocpp.NewError(callError.ErrorCode, callError.ErrorDescription, callError.UniqueId))
 			if s.errorHandler != nil {
+				defer s.recoverPanic(wsChannel.ID(), "")
 				s.errorHandler(wsChannel, ocpp.NewError(callError.ErrorCode, callError.ErrorDescription, callError.UniqueId), callError.ErrorDetails)
 			}
 		}
@@ -289,6 +535,26 @@ func (s *Server) ocppMessageHandler(wsChannel ws.Channel, data []byte) error {
 	return nil
 }
 
+// Passes an incoming CALL to the request handler.
+func (s *Server) handleCall(wsChannel ws.Channel, call *Call) {
+	logger := s.clientLogger(wsChannel.ID())
+	logger.With(logging.Action(call.Action), logging.UniqueID(call.UniqueId)).Debugf("handling incoming CALL [%s, %s] from %s", call.UniqueId, call.Action, wsChannel.ID())
+	ctx := NewRequestContext(ws.ChannelContext(wsChannel), RequestInfo{ClientID: wsChannel.ID(), Action: call.Action, UniqueID: call.UniqueId})
+	info := SpanInfo{Kind: SpanKindIncoming, ClientID: wsChannel.ID(), Action: call.Action, UniqueID: call.UniqueId}
+	s.tracing.start(ctx, info)
+	ctx = s.handlers.track(ctx, info, s.handlerDeadlineFor(call.Action, s.dispatcher), s.getClock(), s.onHandlerDeadlineExceeded)
+	defer func() {
+		if r := recover(); r != nil {
+			s.HandlePanic(wsChannel.ID(), call.UniqueId, NewHandlerPanicError(wsChannel.ID(), call.Action, r))
+		}
+	}()
+	if s.requestHandlerWithContext != nil {
+		s.requestHandlerWithContext(ctx, wsChannel, call.Payload, call.UniqueId, call.Action)
+	} else if s.requestHandler != nil {
+		s.requestHandler(wsChannel, call.Payload, call.UniqueId, call.Action)
+	}
+}
+
 // HandleFailedResponseError allows to handle failures while sending responses (either CALL_RESULT or CALL_ERROR).
 // It internally analyzes and creates an ocpp.Error based on the given error.
 // It will the attempt to send it to the client.
@@ -297,10 +563,13 @@ func (s *Server) ocppMessageHandler(wsChannel ws.Channel, data []byte) error {
 // The method will, however, only attempt to send a default error once.
 // If this operation fails, the other endpoint may still starve.
 func (s *Server) HandleFailedResponseError(clientID string, requestID string, err error, featureName string) {
-	log.Debugf("handling error for failed response [%s]", requestID)
+	s.clientLogger(clientID).With(logging.Action(featureName), logging.UniqueID(requestID)).Debugf("handling error for failed response [%s]", requestID)
 	var responseErr *ocpp.Error
 	// There's several possible errors: invalid profile, invalid payload or send error
 	switch err.(type) {
+	case *ValidationError:
+		// Validation error
+		responseErr = errorFromValidation(err.(*ValidationError).FieldErrors, requestID, featureName)
 	case validator.ValidationErrors:
 		// Validation error
 		validationErr := err.(validator.ValidationErrors)
@@ -308,6 +577,9 @@ func (s *Server) HandleFailedResponseError(clientID string, requestID string, er
 	case *ocpp.Error:
 		// Internal OCPP error
 		responseErr = err.(*ocpp.Error)
+	case *SchemaValidationError:
+		// Schema validation error
+		responseErr = err.(*SchemaValidationError).ocppError(s)
 	case error:
 		// Unknown error
 		responseErr = ocpp.NewError(GenericError, err.Error(), requestID)
@@ -316,21 +588,65 @@ func (s *Server) HandleFailedResponseError(clientID string, requestID string, er
 	_ = s.SendError(clientID, requestID, responseErr.Code, responseErr.Description, nil)
 }
 
+// Invoked by the dispatcher, whenever a request times out or couldn't be sent.
+func (s *Server) onRequestCanceled(clientID string, requestID string, request ocpp.Request, err *ocpp.Error) {
+	s.tracing.end(SpanKindOutgoing, clientID, requestID, canceledOutcome(err), err)
+	if s.canceledRequestHandler != nil {
+		defer s.recoverPanic(clientID, request.GetFeatureName())
+		s.canceledRequestHandler(clientID, requestID, request, err)
+	}
+}
+
 func (s *Server) onClientConnected(ws ws.Channel) {
 	// Create state for connected client
 	s.dispatcher.CreateClient(ws.ID())
+	s.clientsMutex.Lock()
+	connectedAt := s.now()
+	s.clients[ws.ID()] = &clientConnection{channel: ws, connectedAt: connectedAt, lastActivity: connectedAt}
+	s.clientsMutex.Unlock()
 	// Invoke callback
 	if s.newClientHandler != nil {
+		defer s.recoverPanic(ws.ID(), "")
 		s.newClientHandler(ws)
 	}
 }
 
 func (s *Server) onClientDisconnected(ws ws.Channel) {
 	// Clear state for disconnected client
+	s.clientsMutex.Lock()
+	delete(s.clients, ws.ID())
+	s.clientsMutex.Unlock()
 	s.dispatcher.DeleteClient(ws.ID())
 	s.RequestState.ClearClientPendingRequest(ws.ID())
+	s.hold.drop(ws.ID())
+	s.inbound.close(ws)
+	s.audit.flushClient(ws.ID())
+	s.handlers.drop(ws.ID())
+	s.tracing.endClient(ws.ID(), OutcomeCanceled, errClientDisconnected)
 	// Invoke callback
 	if s.disconnectedClientHandler != nil {
+		defer s.recoverPanic(ws.ID(), "")
 		s.disconnectedClientHandler(ws)
 	}
 }
+
+// Sends an InternalError in place of the response to a request, whose handler missed its deadline.
+func (s *Server) onHandlerDeadlineExceeded(pending *pendingHandler, elapsed time.Duration) {
+	info := pending.info
+	_ = s.sendError(info.ClientID, info.UniqueID, InternalError, HandlerDeadlineDescription, nil)
+	s.tracing.handlerDeadlineExceeded(info, pending.deadline)
+	defer s.recoverPanic(info.ClientID, info.Action)
+	s.reportSlowHandler(s.clientLogger(info.ClientID), info, elapsed)
+}
+
+// Returns true if the response to a request must be discarded, since an error was already sent in its place.
+func (s *Server) discardLateResponse(clientID string, requestID string) bool {
+	late := s.handlers.complete(clientID, requestID)
+	if late == nil {
+		return false
+	}
+	elapsed := s.getClock().Now().Sub(late.received)
+	s.clientLogger(clientID).With(logging.Action(late.info.Action), logging.UniqueID(requestID)).Errorf("discarding response to request %v, handler responded after %v", requestID, elapsed)
+	s.tracing.lateResponseDiscarded(late.info, elapsed)
+	return true
+}
@@ -0,0 +1,211 @@
+package ocppj
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/clock"
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+)
+
+// SpanKind distinguishes the spans of requests sent and received by an endpoint.
+type SpanKind int
+
+const (
+	SpanKindOutgoing SpanKind = iota // A request sent by the endpoint, from enqueuing until a response is received.
+	SpanKindIncoming                 // A request received by the endpoint, from reception until a response is sent.
+)
+
+// RequestOutcome describes how a traced request was completed.
+type RequestOutcome string
+
+const (
+	OutcomeResponse RequestOutcome = "Response" // A CALL RESULT was received (outgoing) or sent (incoming).
+	OutcomeError    RequestOutcome = "Error"    // A CALL ERROR was received (outgoing) or sent (incoming).
+	OutcomeTimeout  RequestOutcome = "Timeout"  // No response was received in time.
+	OutcomeCanceled RequestOutcome = "Canceled" // The request was canceled, or the connection/endpoint was shut down.
+	OutcomeFailed   RequestOutcome = "Failed"   // The request couldn't be dispatched.
+)
+
+var errEndpointStopped = errors.New("endpoint stopped")
+var errClientDisconnected = errors.New("client disconnected")
+
+// SpanInfo identifies the request a span refers to.
+type SpanInfo struct {
+	Kind SpanKind
+	// The ID of the charge point/charging station the request is exchanged with.
+	ClientID string
+	Action   string
+	UniqueID string
+}
+
+// Span traces the lifecycle of a single request.
+type Span interface {
+	// End completes the span. The error is nil for OutcomeResponse and set for all other outcomes.
+	// For OutcomeError, the error is the *ocpp.Error received or sent.
+	End(outcome RequestOutcome, err error)
+}
+
+// Tracer creates spans for the requests exchanged by an endpoint.
+// It allows to hook up a tracing framework of choice, without the ocppj package depending on it.
+type Tracer interface {
+	// Start creates a span for a request. For outgoing requests, ctx is the context passed by the caller
+	// (e.g. to SendRequestWithContext) and may contain a parent span. For incoming requests, ctx is the request context
+	// passed to the request handler, derived from the base context of the client connection.
+	//
+	// The span is ended exactly once, possibly from a different goroutine.
+	Start(ctx context.Context, info SpanInfo) Span
+}
+
+type spanKey struct {
+	kind     SpanKind
+	clientID string
+	uniqueID string
+}
+
+type trackedSpan struct {
+	span    Span // Nil if no tracer is set
+	info    SpanInfo
+	started time.Time
+}
+
+// Keeps track of the open spans of an endpoint, reporting them to the tracer and metrics collector.
+// A zero value doesn't track anything.
+type spanTracker struct {
+	tracer    Tracer
+	collector MetricsCollector
+	spans     map[spanKey]trackedSpan
+	clock     clock.Clock
+	mutex     sync.Mutex
+}
+
+func (t *spanTracker) setTracer(tracer Tracer) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.tracer = tracer
+}
+
+func (t *spanTracker) setCollector(collector MetricsCollector) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.collector = collector
+}
+
+func (t *spanTracker) setClock(c clock.Clock) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.clock = c
+}
+
+// Returns the current time. Must be invoked while holding the lock.
+func (t *spanTracker) now() time.Time {
+	return clock.OrDefault(t.clock).Now()
+}
+
+// Reports the time an outgoing request was held back, if the metrics collector supports it.
+func (t *spanTracker) throttled(info SpanInfo, delay time.Duration) {
+	t.mutex.Lock()
+	collector, _ := t.collector.(ThrottleCollector)
+	t.mutex.Unlock()
+	if collector != nil {
+		collector.RequestThrottled(info, delay)
+	}
+}
+
+// Reports a request with an unsupported action, if the metrics collector supports it.
+func (t *spanTracker) unknownAction(info SpanInfo, err error) {
+	var unknown *UnknownActionError
+	if !errors.As(err, &unknown) {
+		return
+	}
+	t.mutex.Lock()
+	collector, _ := t.collector.(UnknownActionCollector)
+	t.mutex.Unlock()
+	if collector != nil {
+		info.Action = unknown.counted
+		collector.UnknownAction(info)
+	}
+}
+
+func (t *spanTracker) start(ctx context.Context, info SpanInfo) {
+	t.mutex.Lock()
+	if t.tracer == nil && t.collector == nil {
+		t.mutex.Unlock()
+		return
+	}
+	if t.spans == nil {
+		t.spans = map[spanKey]trackedSpan{}
+	}
+	key := spanKey{kind: info.Kind, clientID: info.ClientID, uniqueID: info.UniqueID}
+	previous, exists := t.spans[key]
+	now := t.now()
+	tracked := trackedSpan{info: info, started: now}
+	if t.tracer != nil {
+		tracked.span = t.tracer.Start(ctx, info)
+	}
+	t.spans[key] = tracked
+	collector := t.collector
+	t.mutex.Unlock()
+	if exists {
+		// The other endpoint reused a message ID, before the previous request was completed
+		previous.complete(collector, now, OutcomeCanceled, errors.New("message ID reused"))
+	}
+}
+
+// Ends the span of a request, if any.
+func (t *spanTracker) end(kind SpanKind, clientID string, uniqueID string, outcome RequestOutcome, err error) {
+	t.mutex.Lock()
+	key := spanKey{kind: kind, clientID: clientID, uniqueID: uniqueID}
+	tracked, ok := t.spans[key]
+	delete(t.spans, key)
+	collector := t.collector
+	now := t.now()
+	t.mutex.Unlock()
+	if ok {
+		tracked.complete(collector, now, outcome, err)
+	}
+}
+
+// Ends all open spans of the client. If clientID is empty, the spans of all clients are ended.
+func (t *spanTracker) endClient(clientID string, outcome RequestOutcome, err error) {
+	t.mutex.Lock()
+	var spans []trackedSpan
+	for key, tracked := range t.spans {
+		if clientID == "" || key.clientID == clientID {
+			spans = append(spans, tracked)
+			delete(t.spans, key)
+		}
+	}
+	collector := t.collector
+	now := t.now()
+	t.mutex.Unlock()
+	for _, tracked := range spans {
+		tracked.complete(collector, now, outcome, err)
+	}
+}
+
+func (s trackedSpan) complete(collector MetricsCollector, ended time.Time, outcome RequestOutcome, err error) {
+	if s.span != nil {
+		s.span.End(outcome, err)
+	}
+	if collector != nil {
+		var errorCode ocpp.ErrorCode
+		var ocppErr *ocpp.Error
+		if outcome == OutcomeError && errors.As(err, &ocppErr) {
+			errorCode = ocppErr.Code
+		}
+		collector.RequestCompleted(s.info, outcome, errorCode, ended.Sub(s.started))
+	}
+}
+
+// Returns the outcome of a request canceled by a dispatcher.
+// Errors of custom dispatchers may not wrap a *TimeoutError, hence the description is matched as well.
+func canceledOutcome(err *ocpp.Error) RequestOutcome {
+	var timeoutErr *TimeoutError
+	if err != nil && (errors.As(err, &timeoutErr) || err.Code == GenericError && err.Description == RequestTimeoutDescription) {
+		return OutcomeTimeout
+	}
+	return OutcomeFailed
+}
@@ -0,0 +1,233 @@
+package ocppj_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/lorenzodonini/ocpp-go/ws"
+)
+
+// ---------------------- RECORDING TRACER ----------------------
+
+type traceContextKey struct{}
+
+type recordedSpan struct {
+	info    ocppj.SpanInfo
+	parent  interface{}
+	ended   bool
+	outcome ocppj.RequestOutcome
+	err     error
+	tracer  *recordingTracer
+}
+
+func (s *recordedSpan) End(outcome ocppj.RequestOutcome, err error) {
+	s.tracer.mutex.Lock()
+	defer s.tracer.mutex.Unlock()
+	if s.ended {
+		panic("span ended twice")
+	}
+	s.ended = true
+	s.outcome = outcome
+	s.err = err
+}
+
+type recordingTracer struct {
+	spans []*recordedSpan
+	mutex sync.Mutex
+}
+
+func (t *recordingTracer) Start(ctx context.Context, info ocppj.SpanInfo) ocppj.Span {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	span := &recordedSpan{info: info, parent: ctx.Value(traceContextKey{}), tracer: t}
+	t.spans = append(t.spans, span)
+	return span
+}
+
+// Returns a copy of the span for the unique ID, if it was started.
+func (t *recordingTracer) span(kind ocppj.SpanKind, uniqueID string) (recordedSpan, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for _, span := range t.spans {
+		if span.info.Kind == kind && span.info.UniqueID == uniqueID {
+			return *span, true
+		}
+	}
+	return recordedSpan{}, false
+}
+
+func (t *recordingTracer) waitForEnd(kind ocppj.SpanKind, uniqueID string) (recordedSpan, bool) {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if span, ok := t.span(kind, uniqueID); ok && span.ended {
+			return span, true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return t.span(kind, uniqueID)
+}
+
+// ---------------------- TESTS ----------------------
+
+func (suite *OcppJTestSuite) TestServerTracingOutgoingRequests() {
+	t := suite.T()
+	tracer := &recordingTracer{}
+	suite.centralSystem.SetTracer(tracer)
+	mockChargePointId := "1234"
+	channel := NewMockWebSocket(mockChargePointId)
+	writtenC := make(chan struct{}, 10)
+	suite.mockServer.On("Start", mock.AnythingOfType("int"), mock.AnythingOfType("string")).Return()
+	suite.mockServer.On("Write", mockChargePointId, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		writtenC <- struct{}{}
+	})
+	suite.serverDispatcher.SetTimeout(200 * time.Millisecond)
+	canceledC := make(chan string, 1)
+	suite.centralSystem.SetCanceledRequestHandler(func(clientID string, requestID string, request ocpp.Request, err *ocpp.Error) {
+		canceledC <- requestID
+	})
+	suite.centralSystem.Start(8887, "somePath")
+	suite.serverDispatcher.CreateClient(mockChargePointId)
+	// The span of the request is linked to the trace of the caller and completed by the response
+	ctx := context.WithValue(context.Background(), traceContextKey{}, "parent")
+	requestID, err := suite.centralSystem.SendRequestWithContext(ctx, mockChargePointId, newMockRequest("someValue"))
+	require.NoError(t, err)
+	<-writtenC
+	span, ok := tracer.span(ocppj.SpanKindOutgoing, requestID)
+	require.True(t, ok)
+	assert.False(t, span.ended)
+	assert.Equal(t, "parent", span.parent)
+	assert.Equal(t, ocppj.SpanInfo{Kind: ocppj.SpanKindOutgoing, ClientID: mockChargePointId, Action: MockFeatureName, UniqueID: requestID}, span.info)
+	err = suite.mockServer.MessageHandler(channel, []byte(fmt.Sprintf(`[3,"%v",{"mockValue":"someValue"}]`, requestID)))
+	require.NoError(t, err)
+	span, ok = tracer.waitForEnd(ocppj.SpanKindOutgoing, requestID)
+	require.True(t, ok)
+	assert.Equal(t, ocppj.OutcomeResponse, span.outcome)
+	assert.NoError(t, span.err)
+	// CALL ERROR
+	requestID, err = suite.centralSystem.SendRequestWithID(mockChargePointId, newMockRequest("someValue"))
+	require.NoError(t, err)
+	<-writtenC
+	err = suite.mockServer.MessageHandler(channel, []byte(fmt.Sprintf(`[4,"%v","%v","mock error",{}]`, requestID, ocppj.InternalError)))
+	require.NoError(t, err)
+	span, ok = tracer.waitForEnd(ocppj.SpanKindOutgoing, requestID)
+	require.True(t, ok)
+	assert.Nil(t, span.parent)
+	assert.Equal(t, ocppj.OutcomeError, span.outcome)
+	var ocppErr *ocpp.Error
+	require.ErrorAs(t, span.err, &ocppErr)
+	assert.Equal(t, ocppj.InternalError, ocppErr.Code)
+	// Timeout
+	requestID, err = suite.centralSystem.SendRequestWithID(mockChargePointId, newMockRequest("someValue"))
+	require.NoError(t, err)
+	<-writtenC
+	select {
+	case canceledID := <-canceledC:
+		assert.Equal(t, requestID, canceledID)
+	case <-time.After(time.Second):
+		require.Fail(t, "request didn't time out")
+	}
+	span, ok = tracer.waitForEnd(ocppj.SpanKindOutgoing, requestID)
+	require.True(t, ok)
+	assert.Equal(t, ocppj.OutcomeTimeout, span.outcome)
+	assert.Error(t, span.err)
+	// Explicit cancellation
+	suite.serverDispatcher.SetTimeout(time.Minute)
+	requestID, err = suite.centralSystem.SendRequestWithID(mockChargePointId, newMockRequest("someValue"))
+	require.NoError(t, err)
+	<-writtenC
+	require.NoError(t, suite.centralSystem.CancelRequest(mockChargePointId, requestID))
+	span, ok = tracer.waitForEnd(ocppj.SpanKindOutgoing, requestID)
+	require.True(t, ok)
+	assert.Equal(t, ocppj.OutcomeCanceled, span.outcome)
+	assert.IsType(t, &ocppj.RequestCanceledError{}, span.err)
+}
+
+func (suite *OcppJTestSuite) TestServerTracingIncomingRequests() {
+	t := suite.T()
+	tracer := &recordingTracer{}
+	suite.centralSystem.SetTracer(tracer)
+	mockChargePointId := "1234"
+	channel := NewMockWebSocket(mockChargePointId)
+	suite.mockServer.On("Start", mock.AnythingOfType("int"), mock.AnythingOfType("string")).Return()
+	suite.mockServer.On("Write", mockChargePointId, mock.Anything).Return(nil)
+	suite.centralSystem.SetRequestHandler(func(client ws.Channel, request ocpp.Request, requestId string, action string) {
+		// The span wraps the handler
+		span, ok := tracer.span(ocppj.SpanKindIncoming, requestId)
+		require.True(t, ok)
+		assert.False(t, span.ended)
+		if requestId == "1" {
+			assert.NoError(t, suite.centralSystem.SendResponse(client.ID(), requestId, newMockConfirmation("someValue")))
+		} else {
+			assert.NoError(t, suite.centralSystem.SendError(client.ID(), requestId, ocppj.NotImplemented, "not implemented", nil))
+		}
+	})
+	suite.centralSystem.Start(8887, "somePath")
+	suite.serverDispatcher.CreateClient(mockChargePointId)
+	for _, uniqueID := range []string{"1", "2"} {
+		err := suite.mockServer.MessageHandler(channel, []byte(fmt.Sprintf(`[2,"%v","%v",{"mockValue":"someValue"}]`, uniqueID, MockFeatureName)))
+		require.NoError(t, err)
+	}
+	span, ok := tracer.span(ocppj.SpanKindIncoming, "1")
+	require.True(t, ok)
+	assert.True(t, span.ended)
+	assert.Equal(t, ocppj.SpanInfo{Kind: ocppj.SpanKindIncoming, ClientID: mockChargePointId, Action: MockFeatureName, UniqueID: "1"}, span.info)
+	assert.Equal(t, ocppj.OutcomeResponse, span.outcome)
+	span, ok = tracer.span(ocppj.SpanKindIncoming, "2")
+	require.True(t, ok)
+	assert.True(t, span.ended)
+	assert.Equal(t, ocppj.OutcomeError, span.outcome)
+	// Open spans are ended when the client disconnects
+	suite.centralSystem.SetRequestHandler(func(client ws.Channel, request ocpp.Request, requestId string, action string) {})
+	err := suite.mockServer.MessageHandler(channel, []byte(fmt.Sprintf(`[2,"3","%v",{"mockValue":"someValue"}]`, MockFeatureName)))
+	require.NoError(t, err)
+	suite.mockServer.DisconnectedClientHandler(channel)
+	span, ok = tracer.span(ocppj.SpanKindIncoming, "3")
+	require.True(t, ok)
+	assert.True(t, span.ended)
+	assert.Equal(t, ocppj.OutcomeCanceled, span.outcome)
+}
+
+func (suite *OcppJTestSuite) TestClientTracing() {
+	t := suite.T()
+	tracer := &recordingTracer{}
+	suite.chargePoint.SetTracer(tracer)
+	writtenC := make(chan struct{}, 10)
+	suite.mockClient.On("Write", mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		writtenC <- struct{}{}
+	})
+	suite.mockClient.On("Start", mock.AnythingOfType("string")).Return(nil)
+	suite.chargePoint.SetRequestHandler(func(request ocpp.Request, requestId string, action string) {
+		assert.NoError(t, suite.chargePoint.SendResponse(requestId, newMockConfirmation("someValue")))
+	})
+	require.NoError(t, suite.chargePoint.Start("someUrl"))
+	// Outgoing
+	ctx := context.WithValue(context.Background(), traceContextKey{}, "parent")
+	require.NoError(t, suite.chargePoint.SendRequestWithContext(ctx, newMockRequest("someValue")))
+	<-writtenC
+	tracer.mutex.Lock()
+	require.Len(t, tracer.spans, 1)
+	requestID := tracer.spans[0].info.UniqueID
+	tracer.mutex.Unlock()
+	err := suite.mockClient.MessageHandler([]byte(fmt.Sprintf(`[3,"%v",{"mockValue":"someValue"}]`, requestID)))
+	require.NoError(t, err)
+	span, ok := tracer.waitForEnd(ocppj.SpanKindOutgoing, requestID)
+	require.True(t, ok)
+	assert.Equal(t, "parent", span.parent)
+	assert.Equal(t, ocppj.SpanInfo{Kind: ocppj.SpanKindOutgoing, ClientID: suite.chargePoint.Id, Action: MockFeatureName, UniqueID: requestID}, span.info)
+	assert.Equal(t, ocppj.OutcomeResponse, span.outcome)
+	// Incoming
+	err = suite.mockClient.MessageHandler([]byte(fmt.Sprintf(`[2,"5678","%v",{"mockValue":"someValue"}]`, MockFeatureName)))
+	require.NoError(t, err)
+	span, ok = tracer.span(ocppj.SpanKindIncoming, "5678")
+	require.True(t, ok)
+	assert.True(t, span.ended)
+	assert.Equal(t, ocppj.OutcomeResponse, span.outcome)
+}
@@ -0,0 +1,56 @@
+package ocppj
+
+import (
+	"sync"
+
+	"gopkg.in/go-playground/validator.v9"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+)
+
+// Validators owned by the OCPP version packages, indexed by dialect.
+var dialectValidators = map[ocpp.Dialect]*validator.Validate{}
+var dialectValidatorsMutex sync.RWMutex
+
+// NewValidator creates a new validator instance, on which the validations required by OCPP-J messages are registered.
+func NewValidator() *validator.Validate {
+	v := validator.New()
+	_ = ConfigureValidator(v)
+	return v
+}
+
+// ConfigureValidator registers the validations required by OCPP-J messages on an existing validator instance.
+func ConfigureValidator(v *validator.Validate) error {
+	return v.RegisterValidation("errorCode", IsErrorCodeValid)
+}
+
+// SetDialectValidator sets the validator used by all endpoints of the given dialect.
+// The OCPP version packages register their own instance on initialization, so there is usually no need to invoke this function.
+//
+// Endpoints of a dialect without a registered validator fall back to the package-level Validate instance.
+func SetDialectValidator(dialect ocpp.Dialect, v *validator.Validate) {
+	dialectValidatorsMutex.Lock()
+	defer dialectValidatorsMutex.Unlock()
+	dialectValidators[dialect] = v
+}
+
+// SetValidator overrides the validator used by the endpoint, e.g. for supplying an instance with custom tag name functions.
+// The instance must have all validations of the endpoint's OCPP version registered; refer to the ConfigureValidator function of the version's types package.
+//
+// Passing nil restores the validator of the endpoint's dialect. The validator must be set before starting the endpoint.
+func (endpoint *Endpoint) SetValidator(v *validator.Validate) {
+	endpoint.validator = v
+}
+
+// Validator returns the validator used by the endpoint for validating incoming and outgoing messages.
+func (endpoint *Endpoint) Validator() *validator.Validate {
+	if endpoint.validator != nil {
+		return endpoint.validator
+	}
+	dialectValidatorsMutex.RLock()
+	defer dialectValidatorsMutex.RUnlock()
+	if v, ok := dialectValidators[endpoint.dialect]; ok {
+		return v
+	}
+	return Validate
+}
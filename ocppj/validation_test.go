@@ -0,0 +1,121 @@
+package ocppj_test
+
+import (
+	"reflect"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/go-playground/validator.v9"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	types16 "github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	types2 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+)
+
+// A tag registered by both OCPP versions, with a different definition each.
+const versionTagName = "versionTag"
+
+func init() {
+	_ = types16.RegisterValidation(versionTagName, func(fl validator.FieldLevel) bool {
+		return fl.Field().String() == "v16"
+	})
+	_ = types2.RegisterValidation(versionTagName, func(fl validator.FieldLevel) bool {
+		return fl.Field().String() == "v201"
+	})
+}
+
+type VersionTagRequest struct {
+	Version string `json:"version" validate:"required,versionTag"`
+}
+
+func (r VersionTagRequest) GetFeatureName() string {
+	return "VersionTag"
+}
+
+type VersionTagFeature struct{}
+
+func (f VersionTagFeature) GetFeatureName() string {
+	return "VersionTag"
+}
+
+func (f VersionTagFeature) GetRequestType() reflect.Type {
+	return reflect.TypeOf(VersionTagRequest{})
+}
+
+func (f VersionTagFeature) GetResponseType() reflect.Type {
+	return reflect.TypeOf(MockConfirmation{})
+}
+
+// A struct owned by a user application, using a tag name also defined by OCPP 1.6.
+type userMeasurement struct {
+	Measurand string `validate:"measurand16"`
+}
+
+func (suite *OcppJTestSuite) TestDialectValidators() {
+	t := suite.T()
+	assert.NotSame(t, types16.Validator(), types2.Validator())
+	assert.NotSame(t, ocppj.Validate, types16.Validator())
+	assert.NotSame(t, ocppj.Validate, types2.Validator())
+	suite.chargePoint.SetDialect(ocpp.V16)
+	assert.Same(t, types16.Validator(), suite.chargePoint.Validator())
+	suite.chargePoint.SetDialect(ocpp.V2)
+	assert.Same(t, types2.Validator(), suite.chargePoint.Validator())
+	// Override
+	custom := ocppj.NewValidator()
+	suite.chargePoint.SetValidator(custom)
+	assert.Same(t, custom, suite.chargePoint.Validator())
+	suite.chargePoint.SetValidator(nil)
+	assert.Same(t, types2.Validator(), suite.chargePoint.Validator())
+	// Endpoints without dialect use the package-level validator
+	endpoint := ocppj.Endpoint{}
+	assert.Same(t, ocppj.Validate, endpoint.Validator())
+}
+
+func (suite *OcppJTestSuite) TestSameTagInDifferentVersions() {
+	t := suite.T()
+	assert.NoError(t, types16.Validate.Struct(VersionTagRequest{Version: "v16"}))
+	assert.Error(t, types16.Validate.Struct(VersionTagRequest{Version: "v201"}))
+	assert.NoError(t, types2.Validate.Struct(VersionTagRequest{Version: "v201"}))
+	assert.Error(t, types2.Validate.Struct(VersionTagRequest{Version: "v16"}))
+	// The endpoint picks the validator of its dialect
+	suite.chargePoint.AddProfile(ocpp.NewProfile("versionTag", VersionTagFeature{}))
+	state := ocppj.NewClientState()
+	parse := func(version string) error {
+		_, err := suite.chargePoint.ParseMessage([]interface{}{float64(ocppj.CALL), "1234", "VersionTag", map[string]interface{}{"version": version}}, state)
+		return err
+	}
+	suite.chargePoint.SetDialect(ocpp.V16)
+	assert.NoError(t, parse("v16"))
+	assert.Error(t, parse("v201"))
+	suite.chargePoint.SetDialect(ocpp.V2)
+	assert.NoError(t, parse("v201"))
+	assert.Error(t, parse("v16"))
+}
+
+func (suite *OcppJTestSuite) TestUserValidatorsUnaffected() {
+	t := suite.T()
+	// A user-owned validator may define OCPP tag names freely
+	userValidator := validator.New()
+	require.NoError(t, userValidator.RegisterValidation("measurand16", func(fl validator.FieldLevel) bool {
+		return false
+	}))
+	sampledValue := types16.SampledValue{Value: "42", Measurand: types16.MeasurandVoltage}
+	assert.NoError(t, types16.Validate.Struct(sampledValue))
+	assert.Error(t, userValidator.Struct(userMeasurement{Measurand: string(types16.MeasurandVoltage)}))
+	// OCPP validations aren't leaked to the user-owned validator
+	assert.Panics(t, func() {
+		_ = userValidator.Struct(VersionTagRequest{Version: "v16"})
+	})
+	// A user-supplied instance configured with the OCPP validations
+	configured := validator.New()
+	require.NoError(t, types16.ConfigureValidator(configured))
+	assert.NoError(t, configured.Struct(sampledValue))
+	assert.Error(t, configured.Struct(types16.SampledValue{Value: "42", Measurand: "invalid"}))
+	assert.NoError(t, configured.Struct(VersionTagRequest{Version: "v16"}))
+	assert.Error(t, configured.Struct(VersionTagRequest{Version: "v201"}))
+	suite.chargePoint.SetValidator(configured)
+	suite.chargePoint.AddProfile(ocpp.NewProfile("versionTag", VersionTagFeature{}))
+	_, err := suite.chargePoint.ParseMessage([]interface{}{float64(ocppj.CALL), "1234", "VersionTag", map[string]interface{}{"version": "v201"}}, ocppj.NewClientState())
+	assert.Error(t, err)
+}
@@ -0,0 +1,120 @@
+package ocppj
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// responseWatchdog detects connections, on which requests are being sent but no messages are received anymore.
+//
+// This typically happens with half-open TCP connections, where websocket pings are still answered
+// (e.g. by a middlebox), but OCPP messages never reach the other endpoint.
+type responseWatchdog struct {
+	maxSilence    time.Duration
+	awaitingSince time.Time
+	onTriggered   func(silence time.Duration)
+	stopC         chan struct{}
+	mutex         sync.Mutex
+}
+
+// Marks the beginning of a wait for a message from the other endpoint, unless a wait is already in progress.
+func (w *responseWatchdog) await(now time.Time) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.awaitingSince.IsZero() {
+		w.awaitingSince = now
+	}
+}
+
+// Resets the current wait. Invoked whenever a message is received from the other endpoint.
+func (w *responseWatchdog) reset() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.awaitingSince = time.Time{}
+}
+
+// Returns the time elapsed since the beginning of the current wait and whether the maximum silence was exceeded.
+// If the maximum silence was exceeded, the wait is reset.
+func (w *responseWatchdog) expired(now time.Time) (time.Duration, bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.awaitingSince.IsZero() {
+		return 0, false
+	}
+	silence := now.Sub(w.awaitingSince)
+	if silence < w.maxSilence {
+		return silence, false
+	}
+	w.awaitingSince = time.Time{}
+	return silence, true
+}
+
+func (w *responseWatchdog) setOnTriggered(handler func(silence time.Duration)) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.onTriggered = handler
+}
+
+func (w *responseWatchdog) getOnTriggered() func(silence time.Duration) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.onTriggered
+}
+
+func (w *responseWatchdog) setMaxSilence(maxSilence time.Duration) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.maxSilence = maxSilence
+}
+
+func (w *responseWatchdog) start(c *Client) {
+	w.mutex.Lock()
+	if w.stopC != nil || w.maxSilence <= 0 {
+		w.mutex.Unlock()
+		return
+	}
+	w.stopC = make(chan struct{})
+	w.awaitingSince = time.Time{}
+	stopC := w.stopC
+	interval := w.maxSilence / 4
+	w.mutex.Unlock()
+	go w.run(c, interval, stopC)
+}
+
+func (w *responseWatchdog) stop() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.stopC != nil {
+		close(w.stopC)
+		w.stopC = nil
+	}
+}
+
+func (w *responseWatchdog) run(c *Client, interval time.Duration, stopC chan struct{}) {
+	ticker := c.getClock().NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stopC:
+			return
+		case now := <-ticker.C():
+			if !c.client.IsConnected() {
+				continue
+			}
+			// Requests may be sent by the dispatcher without passing through SendRequest (e.g. queued requests)
+			if c.RequestState.HasPendingRequest() {
+				w.await(now)
+			}
+			silence, expired := w.expired(now)
+			if !expired {
+				continue
+			}
+			c.getLogger().Errorf("no message received from server for %v despite pending requests, forcing reconnection", silence)
+			c.forceReconnect(fmt.Errorf("no message received from server for %v", silence))
+			if onTriggered := w.getOnTriggered(); onTriggered != nil {
+				onTriggered(silence)
+			}
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Package oteltracing provides an OpenTelemetry implementation of the ocppj.Tracer interface.
+//
+// It is a separate module, so that the core library doesn't depend on OpenTelemetry.
+// To trace all requests exchanged by an endpoint, pass the tracer to SetTracer:
+//
+//	server := ocppj.NewServer(nil, nil, nil, profiles...)
+//	server.SetTracer(oteltracing.New(otel.Tracer("ocpp")))
+//
+// Spans of outgoing requests are children of the span contained in the context passed
+// to SendRequestWithContext, if any.
+package oteltracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+)
+
+// Attribute keys set on the spans.
+const (
+	AttributeAction        = attribute.Key("ocpp.action")
+	AttributeChargePointID = attribute.Key("ocpp.charge_point_id")
+	AttributeUniqueID      = attribute.Key("ocpp.unique_id")
+	AttributeOutcome       = attribute.Key("ocpp.outcome")
+)
+
+type tracer struct {
+	tracer trace.Tracer
+}
+
+// New creates an ocppj.Tracer, creating spans via the passed OpenTelemetry tracer.
+//
+// Spans are named after the action of the request. Outgoing requests are traced as client spans,
+// incoming requests as server spans.
+func New(t trace.Tracer) ocppj.Tracer {
+	return &tracer{tracer: t}
+}
+
+func (t *tracer) Start(ctx context.Context, info ocppj.SpanInfo) ocppj.Span {
+	kind := trace.SpanKindClient
+	if info.Kind == ocppj.SpanKindIncoming {
+		kind = trace.SpanKindServer
+	}
+	_, span := t.tracer.Start(ctx, info.Action,
+		trace.WithSpanKind(kind),
+		trace.WithAttributes(
+			AttributeAction.String(info.Action),
+			AttributeChargePointID.String(info.ClientID),
+			AttributeUniqueID.String(info.UniqueID),
+		))
+	return &otelSpan{span: span}
+}
+
+type otelSpan struct {
+	span trace.Span
+}
+
+func (s *otelSpan) End(outcome ocppj.RequestOutcome, err error) {
+	s.span.SetAttributes(AttributeOutcome.String(string(outcome)))
+	if outcome != ocppj.OutcomeResponse {
+		if err != nil {
+			s.span.RecordError(err)
+			s.span.SetStatus(codes.Error, err.Error())
+		} else {
+			s.span.SetStatus(codes.Error, string(outcome))
+		}
+	}
+	s.span.End()
+}
@@ -0,0 +1,101 @@
+package oteltracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+)
+
+func newTestTracer() (*tracetest.InMemoryExporter, *sdktrace.TracerProvider) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	return exporter, provider
+}
+
+func attributes(span tracetest.SpanStub) map[attribute.Key]string {
+	result := map[attribute.Key]string{}
+	for _, kv := range span.Attributes {
+		result[kv.Key] = kv.Value.AsString()
+	}
+	return result
+}
+
+func TestOutgoingSpanHierarchy(t *testing.T) {
+	exporter, provider := newTestTracer()
+	otelTracer := provider.Tracer("test")
+	tracer := New(otelTracer)
+	ctx, parent := otelTracer.Start(context.Background(), "parent")
+	span := tracer.Start(ctx, ocppj.SpanInfo{Kind: ocppj.SpanKindOutgoing, ClientID: "CP1", Action: "BootNotification", UniqueID: "1234"})
+	span.End(ocppj.OutcomeResponse, nil)
+	parent.End()
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	child := spans[0]
+	assert.Equal(t, "BootNotification", child.Name)
+	assert.Equal(t, trace.SpanKindClient, child.SpanKind)
+	assert.Equal(t, spans[1].SpanContext.TraceID(), child.SpanContext.TraceID())
+	assert.Equal(t, spans[1].SpanContext.SpanID(), child.Parent.SpanID())
+	assert.Equal(t, map[attribute.Key]string{
+		AttributeAction:        "BootNotification",
+		AttributeChargePointID: "CP1",
+		AttributeUniqueID:      "1234",
+		AttributeOutcome:       string(ocppj.OutcomeResponse),
+	}, attributes(child))
+	assert.Equal(t, codes.Unset, child.Status.Code)
+	assert.Empty(t, child.Events)
+}
+
+func TestIncomingSpan(t *testing.T) {
+	exporter, provider := newTestTracer()
+	tracer := New(provider.Tracer("test"))
+	span := tracer.Start(context.Background(), ocppj.SpanInfo{Kind: ocppj.SpanKindIncoming, ClientID: "CP1", Action: "Reset", UniqueID: "5678"})
+	span.End(ocppj.OutcomeResponse, nil)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "Reset", spans[0].Name)
+	assert.Equal(t, trace.SpanKindServer, spans[0].SpanKind)
+	assert.False(t, spans[0].Parent.IsValid())
+	assert.Equal(t, "5678", attributes(spans[0])[AttributeUniqueID])
+}
+
+func TestSpanErrorStatus(t *testing.T) {
+	testTable := []struct {
+		name    string
+		outcome ocppj.RequestOutcome
+		err     error
+	}{
+		{"callError", ocppj.OutcomeError, ocpp.NewError(ocppj.InternalError, "internal error", "1234")},
+		{"timeout", ocppj.OutcomeTimeout, ocpp.NewError(ocppj.GenericError, ocppj.RequestTimeoutDescription, "1234")},
+		{"canceled", ocppj.OutcomeCanceled, errors.New("client disconnected")},
+		{"failed", ocppj.OutcomeFailed, errors.New("write failed")},
+	}
+	for _, tc := range testTable {
+		t.Run(tc.name, func(t *testing.T) {
+			exporter, provider := newTestTracer()
+			tracer := New(provider.Tracer("test"))
+			span := tracer.Start(context.Background(), ocppj.SpanInfo{Kind: ocppj.SpanKindOutgoing, ClientID: "CP1", Action: "Reset", UniqueID: "1234"})
+			span.End(tc.outcome, tc.err)
+
+			spans := exporter.GetSpans()
+			require.Len(t, spans, 1)
+			assert.Equal(t, string(tc.outcome), attributes(spans[0])[AttributeOutcome])
+			assert.Equal(t, codes.Error, spans[0].Status.Code)
+			assert.Equal(t, tc.err.Error(), spans[0].Status.Description)
+			require.Len(t, spans[0].Events, 1)
+			assert.Equal(t, "exception", spans[0].Events[0].Name)
+		})
+	}
+}
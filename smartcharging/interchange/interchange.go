@@ -0,0 +1,331 @@
+// Package interchange contains a version-neutral representation of charging schedules,
+// which can be converted to and from the OCPP 1.6 and OCPP 2.0.1 ChargingSchedule types.
+//
+// Conversions are lossless: if a schedule contains a feature, which cannot be represented in the target version
+// (e.g. a 2.0.1 sales tariff or custom data), an UnsupportedFeatureError is returned instead of silently dropping the feature.
+package interchange
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	types16 "github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	types201 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// Unit of the limits of a charging schedule.
+type RateUnit string
+
+const (
+	RateUnitWatts   RateUnit = "W"
+	RateUnitAmperes RateUnit = "A"
+)
+
+// Period is a single period of a charging schedule.
+type Period struct {
+	StartPeriod  int     `json:"startPeriod"`            // Start of the period, in seconds from the start of the schedule.
+	Limit        float64 `json:"limit"`                  // Charging rate limit during the period, in the unit of the schedule.
+	NumberPhases *int    `json:"numberPhases,omitempty"` // The number of phases that can be used for charging.
+	PhaseToUse   *int    `json:"phaseToUse,omitempty"`   // The phase to use, if numberPhases is 1. Only supported by OCPP 2.0.1.
+	// Vendor-specific extensions of the period. Only supported by OCPP 2.0.1.
+	CustomData *types201.CustomData `json:"customData,omitempty"`
+}
+
+// Cost contains the details of a cost component of a sales tariff entry.
+type Cost struct {
+	Kind             string `json:"kind"`
+	Amount           int    `json:"amount"`
+	AmountMultiplier *int   `json:"amountMultiplier,omitempty"`
+	// Vendor-specific extensions of the cost. Only supported by OCPP 2.0.1.
+	CustomData *types201.CustomData `json:"customData,omitempty"`
+}
+
+// ConsumptionCost contains the costs of a consumption block of a sales tariff entry.
+type ConsumptionCost struct {
+	StartValue float64 `json:"startValue"`
+	Cost       []Cost  `json:"cost"`
+	// Vendor-specific extensions of the consumption cost. Only supported by OCPP 2.0.1.
+	CustomData *types201.CustomData `json:"customData,omitempty"`
+}
+
+// SalesTariffEntry describes a single time interval of a sales tariff.
+type SalesTariffEntry struct {
+	EPriceLevel     *int              `json:"ePriceLevel,omitempty"`
+	Start           int               `json:"start"`              // Start of the interval, in seconds from now.
+	Duration        *int              `json:"duration,omitempty"` // Duration of the interval, in seconds.
+	ConsumptionCost []ConsumptionCost `json:"consumptionCost,omitempty"`
+	// Vendor-specific extensions of the entry and of its relative time interval. Only supported by OCPP 2.0.1.
+	CustomData         *types201.CustomData `json:"customData,omitempty"`
+	IntervalCustomData *types201.CustomData `json:"intervalCustomData,omitempty"`
+}
+
+// SalesTariff is a sales tariff associated with a charging schedule. Only supported by OCPP 2.0.1.
+type SalesTariff struct {
+	ID              int                `json:"id"`
+	Description     string             `json:"description,omitempty"`
+	NumEPriceLevels *int               `json:"numEPriceLevels,omitempty"`
+	Entries         []SalesTariffEntry `json:"entries"`
+	// Vendor-specific extensions of the sales tariff.
+	CustomData *types201.CustomData `json:"customData,omitempty"`
+}
+
+// Schedule is a version-neutral charging schedule.
+type Schedule struct {
+	// Identifies the schedule. Only supported by OCPP 2.0.1: must be zero when converting to OCPP 1.6.
+	ID              int          `json:"id,omitempty"`
+	StartSchedule   *time.Time   `json:"startSchedule,omitempty"`
+	Duration        *int         `json:"duration,omitempty"`
+	RateUnit        RateUnit     `json:"rateUnit"`
+	MinChargingRate *float64     `json:"minChargingRate,omitempty"`
+	Periods         []Period     `json:"periods"`
+	SalesTariff     *SalesTariff `json:"salesTariff,omitempty"` // Only supported by OCPP 2.0.1.
+	// Vendor-specific extensions of the schedule. Only supported by OCPP 2.0.1.
+	CustomData *types201.CustomData `json:"customData,omitempty"`
+}
+
+// Protocol versions supported by the converters.
+const (
+	Version16  = "1.6"
+	Version201 = "2.0.1"
+)
+
+// UnsupportedFeatureError is returned when a schedule contains a feature, which cannot be represented
+// in the target protocol version.
+type UnsupportedFeatureError struct {
+	Feature string
+	Version string
+}
+
+func (e *UnsupportedFeatureError) Error() string {
+	return fmt.Sprintf("%v cannot be represented in OCPP %v", e.Feature, e.Version)
+}
+
+var errNilSchedule = errors.New("nil charging schedule")
+
+func isValidRateUnit(unit RateUnit) bool {
+	return unit == RateUnitWatts || unit == RateUnitAmperes
+}
+
+// FromOCPP16 converts an OCPP 1.6 charging schedule to a version-neutral schedule.
+func FromOCPP16(schedule *types16.ChargingSchedule) (*Schedule, error) {
+	if schedule == nil {
+		return nil, errNilSchedule
+	}
+	unit := RateUnit(schedule.ChargingRateUnit)
+	if !isValidRateUnit(unit) {
+		return nil, fmt.Errorf("invalid charging rate unit %v", schedule.ChargingRateUnit)
+	}
+	result := &Schedule{
+		Duration:        copyPtr(schedule.Duration),
+		RateUnit:        unit,
+		MinChargingRate: copyPtr(schedule.MinChargingRate),
+	}
+	if schedule.StartSchedule != nil {
+		result.StartSchedule = copyPtr(&schedule.StartSchedule.Time)
+	}
+	if schedule.ChargingSchedulePeriod != nil {
+		result.Periods = make([]Period, len(schedule.ChargingSchedulePeriod))
+		for i, p := range schedule.ChargingSchedulePeriod {
+			result.Periods[i] = Period{StartPeriod: p.StartPeriod, Limit: p.Limit, NumberPhases: copyPtr(p.NumberPhases)}
+		}
+	}
+	return result, nil
+}
+
+// ToOCPP16 converts a version-neutral schedule to an OCPP 1.6 charging schedule.
+// An UnsupportedFeatureError is returned if the schedule has an ID, a sales tariff, custom data or a period with phaseToUse.
+func ToOCPP16(schedule *Schedule) (*types16.ChargingSchedule, error) {
+	if schedule == nil {
+		return nil, errNilSchedule
+	}
+	if schedule.ID != 0 {
+		return nil, &UnsupportedFeatureError{Feature: "schedule id", Version: Version16}
+	}
+	if schedule.SalesTariff != nil {
+		return nil, &UnsupportedFeatureError{Feature: "salesTariff", Version: Version16}
+	}
+	if schedule.CustomData != nil {
+		return nil, &UnsupportedFeatureError{Feature: "customData", Version: Version16}
+	}
+	for _, p := range schedule.Periods {
+		if p.PhaseToUse != nil {
+			return nil, &UnsupportedFeatureError{Feature: "phaseToUse", Version: Version16}
+		}
+		if p.CustomData != nil {
+			return nil, &UnsupportedFeatureError{Feature: "period customData", Version: Version16}
+		}
+	}
+	if !isValidRateUnit(schedule.RateUnit) {
+		return nil, fmt.Errorf("invalid charging rate unit %v", schedule.RateUnit)
+	}
+	result := &types16.ChargingSchedule{
+		Duration:         copyPtr(schedule.Duration),
+		ChargingRateUnit: types16.ChargingRateUnitType(schedule.RateUnit),
+		MinChargingRate:  copyPtr(schedule.MinChargingRate),
+	}
+	if schedule.StartSchedule != nil {
+		result.StartSchedule = types16.NewDateTime(*schedule.StartSchedule)
+	}
+	if schedule.Periods != nil {
+		result.ChargingSchedulePeriod = make([]types16.ChargingSchedulePeriod, len(schedule.Periods))
+		for i, p := range schedule.Periods {
+			result.ChargingSchedulePeriod[i] = types16.ChargingSchedulePeriod{StartPeriod: p.StartPeriod, Limit: p.Limit, NumberPhases: copyPtr(p.NumberPhases)}
+		}
+	}
+	return result, nil
+}
+
+// FromOCPP201 converts an OCPP 2.0.1 charging schedule to a version-neutral schedule.
+func FromOCPP201(schedule *types201.ChargingSchedule) (*Schedule, error) {
+	if schedule == nil {
+		return nil, errNilSchedule
+	}
+	unit := RateUnit(schedule.ChargingRateUnit)
+	if !isValidRateUnit(unit) {
+		return nil, fmt.Errorf("invalid charging rate unit %v", schedule.ChargingRateUnit)
+	}
+	result := &Schedule{
+		ID:              schedule.ID,
+		Duration:        copyPtr(schedule.Duration),
+		RateUnit:        unit,
+		MinChargingRate: copyPtr(schedule.MinChargingRate),
+		CustomData:      copyCustomData(schedule.CustomData),
+	}
+	if schedule.StartSchedule != nil {
+		result.StartSchedule = copyPtr(&schedule.StartSchedule.Time)
+	}
+	if schedule.ChargingSchedulePeriod != nil {
+		result.Periods = make([]Period, len(schedule.ChargingSchedulePeriod))
+		for i, p := range schedule.ChargingSchedulePeriod {
+			result.Periods[i] = Period{StartPeriod: p.StartPeriod, Limit: p.Limit, NumberPhases: copyPtr(p.NumberPhases), PhaseToUse: copyPtr(p.PhaseToUse), CustomData: copyCustomData(p.CustomData)}
+		}
+	}
+	if schedule.SalesTariff != nil {
+		result.SalesTariff = fromSalesTariff201(schedule.SalesTariff)
+	}
+	return result, nil
+}
+
+// ToOCPP201 converts a version-neutral schedule to an OCPP 2.0.1 charging schedule.
+func ToOCPP201(schedule *Schedule) (*types201.ChargingSchedule, error) {
+	if schedule == nil {
+		return nil, errNilSchedule
+	}
+	if !isValidRateUnit(schedule.RateUnit) {
+		return nil, fmt.Errorf("invalid charging rate unit %v", schedule.RateUnit)
+	}
+	result := &types201.ChargingSchedule{
+		ID:               schedule.ID,
+		Duration:         copyPtr(schedule.Duration),
+		ChargingRateUnit: types201.ChargingRateUnitType(schedule.RateUnit),
+		MinChargingRate:  copyPtr(schedule.MinChargingRate),
+		CustomData:       copyCustomData(schedule.CustomData),
+	}
+	if schedule.StartSchedule != nil {
+		result.StartSchedule = types201.NewDateTime(*schedule.StartSchedule)
+	}
+	if schedule.Periods != nil {
+		result.ChargingSchedulePeriod = make([]types201.ChargingSchedulePeriod, len(schedule.Periods))
+		for i, p := range schedule.Periods {
+			result.ChargingSchedulePeriod[i] = types201.ChargingSchedulePeriod{StartPeriod: p.StartPeriod, Limit: p.Limit, NumberPhases: copyPtr(p.NumberPhases), PhaseToUse: copyPtr(p.PhaseToUse), CustomData: copyCustomData(p.CustomData)}
+		}
+	}
+	if schedule.SalesTariff != nil {
+		result.SalesTariff = toSalesTariff201(schedule.SalesTariff)
+	}
+	return result, nil
+}
+
+func fromSalesTariff201(tariff *types201.SalesTariff) *SalesTariff {
+	result := &SalesTariff{
+		ID:              tariff.ID,
+		Description:     tariff.SalesTariffDescription,
+		NumEPriceLevels: copyPtr(tariff.NumEPriceLevels),
+		CustomData:      copyCustomData(tariff.CustomData),
+	}
+	if tariff.SalesTariffEntry != nil {
+		result.Entries = make([]SalesTariffEntry, len(tariff.SalesTariffEntry))
+		for i, e := range tariff.SalesTariffEntry {
+			entry := SalesTariffEntry{
+				EPriceLevel:        copyPtr(e.EPriceLevel),
+				Start:              e.RelativeTimeInterval.Start,
+				Duration:           copyPtr(e.RelativeTimeInterval.Duration),
+				CustomData:         copyCustomData(e.CustomData),
+				IntervalCustomData: copyCustomData(e.RelativeTimeInterval.CustomData),
+			}
+			if e.ConsumptionCost != nil {
+				entry.ConsumptionCost = make([]ConsumptionCost, len(e.ConsumptionCost))
+				for j, c := range e.ConsumptionCost {
+					consumptionCost := ConsumptionCost{StartValue: c.StartValue, CustomData: copyCustomData(c.CustomData)}
+					if c.Cost != nil {
+						consumptionCost.Cost = make([]Cost, len(c.Cost))
+						for k, cost := range c.Cost {
+							consumptionCost.Cost[k] = Cost{Kind: string(cost.CostKind), Amount: cost.Amount, AmountMultiplier: copyPtr(cost.AmountMultiplier), CustomData: copyCustomData(cost.CustomData)}
+						}
+					}
+					entry.ConsumptionCost[j] = consumptionCost
+				}
+			}
+			result.Entries[i] = entry
+		}
+	}
+	return result
+}
+
+func toSalesTariff201(tariff *SalesTariff) *types201.SalesTariff {
+	result := &types201.SalesTariff{
+		ID:                     tariff.ID,
+		SalesTariffDescription: tariff.Description,
+		NumEPriceLevels:        copyPtr(tariff.NumEPriceLevels),
+		CustomData:             copyCustomData(tariff.CustomData),
+	}
+	if tariff.Entries != nil {
+		result.SalesTariffEntry = make([]types201.SalesTariffEntry, len(tariff.Entries))
+		for i, e := range tariff.Entries {
+			entry := types201.SalesTariffEntry{
+				EPriceLevel:          copyPtr(e.EPriceLevel),
+				RelativeTimeInterval: types201.RelativeTimeInterval{Start: e.Start, Duration: copyPtr(e.Duration), CustomData: copyCustomData(e.IntervalCustomData)},
+				CustomData:           copyCustomData(e.CustomData),
+			}
+			if e.ConsumptionCost != nil {
+				entry.ConsumptionCost = make([]types201.ConsumptionCost, len(e.ConsumptionCost))
+				for j, c := range e.ConsumptionCost {
+					consumptionCost := types201.ConsumptionCost{StartValue: c.StartValue, CustomData: copyCustomData(c.CustomData)}
+					if c.Cost != nil {
+						consumptionCost.Cost = make([]types201.CostType, len(c.Cost))
+						for k, cost := range c.Cost {
+							consumptionCost.Cost[k] = types201.CostType{CostKind: types201.CostKind(cost.Kind), Amount: cost.Amount, AmountMultiplier: copyPtr(cost.AmountMultiplier), CustomData: copyCustomData(cost.CustomData)}
+						}
+					}
+					entry.ConsumptionCost[j] = consumptionCost
+				}
+			}
+			result.SalesTariffEntry[i] = entry
+		}
+	}
+	return result
+}
+
+func copyPtr[T any](value *T) *T {
+	if value == nil {
+		return nil
+	}
+	v := *value
+	return &v
+}
+
+// Deep-copies custom data, so that converted schedules don't share the vendor-specific properties with the original.
+func copyCustomData(data *types201.CustomData) *types201.CustomData {
+	if data == nil {
+		return nil
+	}
+	result := &types201.CustomData{VendorID: data.VendorID}
+	if data.AdditionalProperties != nil {
+		result.AdditionalProperties = make(map[string]interface{}, len(data.AdditionalProperties))
+		for k, v := range data.AdditionalProperties {
+			result.AdditionalProperties[k] = v
+		}
+	}
+	return result
+}
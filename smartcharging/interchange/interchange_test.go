@@ -0,0 +1,239 @@
+package interchange_test
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	types16 "github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	types201 "github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+	"github.com/lorenzodonini/ocpp-go/smartcharging/interchange"
+)
+
+const iterations = 500
+
+func randomIntPtr(r *rand.Rand) *int {
+	if r.Intn(2) == 0 {
+		return nil
+	}
+	v := r.Intn(10000)
+	return &v
+}
+
+func randomFloatPtr(r *rand.Rand) *float64 {
+	if r.Intn(2) == 0 {
+		return nil
+	}
+	v := r.Float64() * 100
+	return &v
+}
+
+func randomStart(r *rand.Rand) *time.Time {
+	if r.Intn(2) == 0 {
+		return nil
+	}
+	t := time.Unix(r.Int63n(2000000000), 0).UTC()
+	return &t
+}
+
+func randomUnit(r *rand.Rand) string {
+	if r.Intn(2) == 0 {
+		return "W"
+	}
+	return "A"
+}
+
+func randomCustomData(r *rand.Rand) *types201.CustomData {
+	if r.Intn(4) != 0 {
+		return nil
+	}
+	data := types201.NewCustomData("com.example")
+	if r.Intn(2) == 0 {
+		data.AdditionalProperties = map[string]interface{}{"slot": float64(r.Intn(10))}
+	}
+	return data
+}
+
+func randomPhase(r *rand.Rand) *int {
+	if r.Intn(4) != 0 {
+		return nil
+	}
+	v := 1 + r.Intn(3)
+	return &v
+}
+
+func randomSchedule16(r *rand.Rand) *types16.ChargingSchedule {
+	schedule := types16.NewChargingSchedule(types16.ChargingRateUnitType(randomUnit(r)))
+	schedule.Duration = randomIntPtr(r)
+	schedule.MinChargingRate = randomFloatPtr(r)
+	if start := randomStart(r); start != nil {
+		schedule.StartSchedule = types16.NewDateTime(*start)
+	}
+	for i := 0; i < 1+r.Intn(5); i++ {
+		schedule.ChargingSchedulePeriod = append(schedule.ChargingSchedulePeriod, types16.ChargingSchedulePeriod{StartPeriod: i * 600, Limit: r.Float64() * 32, NumberPhases: randomIntPtr(r)})
+	}
+	return schedule
+}
+
+func randomSalesTariff201(r *rand.Rand) *types201.SalesTariff {
+	tariff := types201.NewSalesTariff(r.Intn(100), nil)
+	tariff.NumEPriceLevels = randomIntPtr(r)
+	tariff.CustomData = randomCustomData(r)
+	if r.Intn(2) == 0 {
+		tariff.SalesTariffDescription = "tariff"
+	}
+	for i := 0; i < 1+r.Intn(3); i++ {
+		entry := types201.SalesTariffEntry{
+			EPriceLevel:          randomIntPtr(r),
+			RelativeTimeInterval: types201.RelativeTimeInterval{Start: i * 3600, Duration: randomIntPtr(r), CustomData: randomCustomData(r)},
+			CustomData:           randomCustomData(r),
+		}
+		for j := 0; j < r.Intn(3); j++ {
+			cost := types201.CostType{CostKind: types201.CostKindCarbonDioxideEmission, Amount: r.Intn(500), AmountMultiplier: randomIntPtr(r), CustomData: randomCustomData(r)}
+			consumptionCost := types201.NewConsumptionCost(r.Float64()*10, []types201.CostType{cost})
+			consumptionCost.CustomData = randomCustomData(r)
+			entry.ConsumptionCost = append(entry.ConsumptionCost, consumptionCost)
+		}
+		tariff.SalesTariffEntry = append(tariff.SalesTariffEntry, entry)
+	}
+	return tariff
+}
+
+func randomSchedule201(r *rand.Rand) *types201.ChargingSchedule {
+	schedule := types201.NewChargingSchedule(0, types201.ChargingRateUnitType(randomUnit(r)))
+	if r.Intn(2) == 0 {
+		schedule.ID = r.Intn(100)
+	}
+	schedule.Duration = randomIntPtr(r)
+	schedule.MinChargingRate = randomFloatPtr(r)
+	if start := randomStart(r); start != nil {
+		schedule.StartSchedule = types201.NewDateTime(*start)
+	}
+	for i := 0; i < 1+r.Intn(5); i++ {
+		schedule.ChargingSchedulePeriod = append(schedule.ChargingSchedulePeriod, types201.ChargingSchedulePeriod{StartPeriod: i * 600, Limit: r.Float64() * 32, NumberPhases: randomIntPtr(r), PhaseToUse: randomPhase(r), CustomData: randomCustomData(r)})
+	}
+	if r.Intn(2) == 0 {
+		schedule.SalesTariff = randomSalesTariff201(r)
+	}
+	schedule.CustomData = randomCustomData(r)
+	return schedule
+}
+
+func TestRoundTripOCPP16(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < iterations; i++ {
+		original := randomSchedule16(r)
+		neutral, err := interchange.FromOCPP16(original)
+		require.NoError(t, err)
+		converted, err := interchange.ToOCPP16(neutral)
+		require.NoError(t, err)
+		require.Equal(t, original, converted)
+		// 1.6 schedules can always be represented in 2.0.1 and back
+		schedule201, err := interchange.ToOCPP201(neutral)
+		require.NoError(t, err)
+		neutral, err = interchange.FromOCPP201(schedule201)
+		require.NoError(t, err)
+		converted, err = interchange.ToOCPP16(neutral)
+		require.NoError(t, err)
+		require.Equal(t, original, converted)
+	}
+}
+
+func hasOCPP201Features(schedule *types201.ChargingSchedule) bool {
+	if schedule.CustomData != nil {
+		return true
+	}
+	for _, p := range schedule.ChargingSchedulePeriod {
+		if p.CustomData != nil || p.PhaseToUse != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRoundTripOCPP201(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < iterations; i++ {
+		original := randomSchedule201(r)
+		neutral, err := interchange.FromOCPP201(original)
+		require.NoError(t, err)
+		converted, err := interchange.ToOCPP201(neutral)
+		require.NoError(t, err)
+		require.Equal(t, original, converted)
+		// Going through 1.6 either fails explicitly or preserves the schedule
+		schedule16, err := interchange.ToOCPP16(neutral)
+		if original.ID != 0 || original.SalesTariff != nil || hasOCPP201Features(original) {
+			require.Error(t, err)
+			assert.IsType(t, &interchange.UnsupportedFeatureError{}, err)
+			continue
+		}
+		require.NoError(t, err)
+		neutral, err = interchange.FromOCPP16(schedule16)
+		require.NoError(t, err)
+		converted, err = interchange.ToOCPP201(neutral)
+		require.NoError(t, err)
+		require.Equal(t, original, converted)
+	}
+}
+
+func TestUnsupportedFeatures(t *testing.T) {
+	schedule := &interchange.Schedule{
+		RateUnit:    interchange.RateUnitWatts,
+		Periods:     []interchange.Period{{StartPeriod: 0, Limit: 11000}},
+		SalesTariff: &interchange.SalesTariff{ID: 1, Entries: []interchange.SalesTariffEntry{{Start: 0}}},
+	}
+	_, err := interchange.ToOCPP16(schedule)
+	require.Error(t, err)
+	assert.Equal(t, "salesTariff cannot be represented in OCPP 1.6", err.Error())
+	schedule.SalesTariff = nil
+	schedule.ID = 3
+	_, err = interchange.ToOCPP16(schedule)
+	require.Error(t, err)
+	assert.IsType(t, &interchange.UnsupportedFeatureError{}, err)
+	// Custom data is never dropped silently
+	schedule.ID = 0
+	schedule.CustomData = types201.NewCustomData("com.example")
+	_, err = interchange.ToOCPP16(schedule)
+	require.Error(t, err)
+	assert.Equal(t, "customData cannot be represented in OCPP 1.6", err.Error())
+	schedule.CustomData = nil
+	schedule.Periods[0].CustomData = types201.NewCustomData("com.example")
+	_, err = interchange.ToOCPP16(schedule)
+	require.Error(t, err)
+	assert.Equal(t, "period customData cannot be represented in OCPP 1.6", err.Error())
+	schedule.Periods[0].CustomData = nil
+	phase := 2
+	schedule.Periods[0].PhaseToUse = &phase
+	_, err = interchange.ToOCPP16(schedule)
+	require.Error(t, err)
+	assert.Equal(t, "phaseToUse cannot be represented in OCPP 1.6", err.Error())
+}
+
+func TestRoundTripPhaseToUse(t *testing.T) {
+	phases := 1
+	phase := 3
+	original := types201.NewChargingSchedule(1, types201.ChargingRateUnitAmperes,
+		types201.ChargingSchedulePeriod{StartPeriod: 0, Limit: 16, NumberPhases: &phases, PhaseToUse: &phase},
+		types201.NewChargingSchedulePeriod(3600, 32))
+	neutral, err := interchange.FromOCPP201(original)
+	require.NoError(t, err)
+	require.NotNil(t, neutral.Periods[0].PhaseToUse)
+	assert.Equal(t, 3, *neutral.Periods[0].PhaseToUse)
+	converted, err := interchange.ToOCPP201(neutral)
+	require.NoError(t, err)
+	assert.Equal(t, original, converted)
+}
+
+func TestInvalidSchedules(t *testing.T) {
+	_, err := interchange.ToOCPP16(nil)
+	assert.Error(t, err)
+	_, err = interchange.FromOCPP201(nil)
+	assert.Error(t, err)
+	_, err = interchange.ToOCPP201(&interchange.Schedule{RateUnit: "kW"})
+	assert.Error(t, err)
+	_, err = interchange.FromOCPP16(types16.NewChargingSchedule("kW"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,351 @@
+package soap
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// An XML element, stripped of namespaces and attributes.
+type node struct {
+	name     string
+	text     string
+	children []*node
+}
+
+// Parses the first element contained in data.
+func parseNode(data []byte) (*node, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var stack []*node
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil, fmt.Errorf("no element found")
+		} else if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			n := &node{name: t.Name.Local}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, n)
+			}
+			stack = append(stack, n)
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(t)
+			}
+		case xml.EndElement:
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				return n, nil
+			}
+		}
+	}
+}
+
+func (n *node) child(name string) *node {
+	for _, c := range n.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// Returns the text of the element at the passed path below n, or an empty string if it doesn't exist.
+func (n *node) textAt(path ...string) string {
+	current := n
+	for _, name := range path {
+		if current = current.child(name); current == nil {
+			return ""
+		}
+	}
+	return strings.TrimSpace(current.text)
+}
+
+// A struct field, as seen by encoding/json.
+type jsonField struct {
+	name      string
+	index     []int
+	typ       reflect.Type
+	omitEmpty bool
+}
+
+// Returns the fields of a struct in declaration order, named after their json tags. Embedded structs are flattened.
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			for _, embedded := range jsonFields(f.Type) {
+				embedded.index = append([]int{i}, embedded.index...)
+				fields = append(fields, embedded)
+			}
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, jsonField{name: name, index: []int{i}, typ: f.Type, omitEmpty: strings.Contains(options, "omitempty")})
+	}
+	return fields
+}
+
+// Converts the children of n to a JSON payload, which can be unmarshaled into a value of type t.
+func decodePayload(n *node, t reflect.Type) (json.RawMessage, error) {
+	value, err := jsonValue(n, t)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(value)
+}
+
+func jsonValue(n *node, t reflect.Type) (interface{}, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	ptr := reflect.PtrTo(t)
+	if ptr.Implements(jsonUnmarshalerType) || ptr.Implements(textUnmarshalerType) {
+		return strings.TrimSpace(n.text), nil
+	}
+	text := strings.TrimSpace(n.text)
+	switch t.Kind() {
+	case reflect.Struct:
+		object := map[string]interface{}{}
+		for _, field := range jsonFields(t) {
+			var children []*node
+			for _, c := range n.children {
+				if c.name == field.name {
+					children = append(children, c)
+				}
+			}
+			if len(children) == 0 {
+				continue
+			}
+			if field.typ.Kind() == reflect.Slice {
+				array := make([]interface{}, 0, len(children))
+				for _, c := range children {
+					value, err := jsonValue(c, field.typ.Elem())
+					if err != nil {
+						return nil, err
+					}
+					array = append(array, value)
+				}
+				object[field.name] = array
+			} else {
+				value, err := jsonValue(children[0], field.typ)
+				if err != nil {
+					return nil, err
+				}
+				object[field.name] = value
+			}
+		}
+		return object, nil
+	case reflect.String:
+		return n.text, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %v in element %v", text, n.name)
+		}
+		return i, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(text, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %v in element %v", text, n.name)
+		}
+		return u, nil
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid decimal %v in element %v", text, n.name)
+		}
+		return f, nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid boolean %v in element %v", text, n.name)
+		}
+		return b, nil
+	case reflect.Interface:
+		return n.genericValue(), nil
+	default:
+		return nil, fmt.Errorf("unsupported type %v for element %v", t, n.name)
+	}
+}
+
+// Converts n to a value without type information: elements without children become strings,
+// all others objects. Repeated child elements become arrays.
+func (n *node) genericValue() interface{} {
+	if len(n.children) == 0 {
+		return n.text
+	}
+	object := map[string]interface{}{}
+	for _, c := range n.children {
+		value := c.genericValue()
+		switch existing := object[c.name].(type) {
+		case nil:
+			object[c.name] = value
+		case []interface{}:
+			object[c.name] = append(existing, value)
+		default:
+			object[c.name] = []interface{}{existing, value}
+		}
+	}
+	return object
+}
+
+// Writes the JSON payload as an XML element with the passed name and namespace. The payload is unmarshaled into
+// a value of type t first, so that child elements follow the order of the struct fields.
+func encodePayload(buf *bytes.Buffer, name string, namespace string, payload json.RawMessage, t reflect.Type) error {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	value := reflect.New(t)
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, value.Interface()); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(buf, `<%s xmlns="%s">`, name, namespace)
+	if err := writeContent(buf, value.Elem()); err != nil {
+		return err
+	}
+	fmt.Fprintf(buf, "</%s>", name)
+	return nil
+}
+
+func writeElement(buf *bytes.Buffer, name string, v reflect.Value) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type().Implements(jsonMarshalerType) && v.Kind() == reflect.Ptr {
+			break
+		}
+		v = v.Elem()
+	}
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+		for i := 0; i < v.Len(); i++ {
+			if err := writeElement(buf, name, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	fmt.Fprintf(buf, "<%s>", name)
+	if err := writeContent(buf, v); err != nil {
+		return err
+	}
+	fmt.Fprintf(buf, "</%s>", name)
+	return nil
+}
+
+func writeContent(buf *bytes.Buffer, v reflect.Value) error {
+	if marshaler, ok := asMarshaler(v); ok {
+		data, err := marshaler.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		var text string
+		if err = json.Unmarshal(data, &text); err != nil {
+			text = string(data)
+		}
+		return xml.EscapeText(buf, []byte(text))
+	}
+	for v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		for _, field := range jsonFields(v.Type()) {
+			fv := v.FieldByIndex(field.index)
+			if field.omitEmpty && isEmptyValue(fv) {
+				continue
+			}
+			if err := writeElement(buf, field.name, fv); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		keys := make([]string, 0, v.Len())
+		for _, key := range v.MapKeys() {
+			keys = append(keys, fmt.Sprint(key.Interface()))
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err := writeElement(buf, key, v.MapIndex(reflect.ValueOf(key))); err != nil {
+				return err
+			}
+		}
+	case reflect.String:
+		return xml.EscapeText(buf, []byte(v.String()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		buf.WriteString(strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		buf.WriteString(strconv.FormatUint(v.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		buf.WriteString(strconv.FormatFloat(v.Float(), 'f', -1, 64))
+	case reflect.Bool:
+		buf.WriteString(strconv.FormatBool(v.Bool()))
+	default:
+		return fmt.Errorf("unsupported type %v", v.Type())
+	}
+	return nil
+}
+
+func asMarshaler(v reflect.Value) (json.Marshaler, bool) {
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil, false
+	}
+	if v.Type().Implements(jsonMarshalerType) {
+		return v.Interface().(json.Marshaler), true
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(jsonMarshalerType) {
+		return v.Addr().Interface().(json.Marshaler), true
+	}
+	return nil, false
+}
+
+// Same semantics as the omitempty option of encoding/json.
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
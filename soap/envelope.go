@@ -0,0 +1,207 @@
+package soap
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+)
+
+const (
+	// Namespace of the messages sent by charge points to the central system.
+	CentralSystemNamespace = "urn://Ocpp/Cs/2015/10/"
+	// Namespace of the messages sent by the central system to charge points.
+	ChargePointNamespace = "urn://Ocpp/Cp/2015/10/"
+
+	envelopeNamespace   = "http://www.w3.org/2003/05/soap-envelope"
+	addressingNamespace = "http://www.w3.org/2005/08/addressing"
+	anonymousAddress    = "http://www.w3.org/2005/08/addressing/anonymous"
+	contentType         = "application/soap+xml; charset=utf-8"
+)
+
+// The header fields relevant to OCPP, as defined by the OCPP 1.6 SOAP specification.
+type header struct {
+	ChargeBoxIdentity string
+	MessageID         string
+	RelatesTo         string
+	Action            string
+	To                string
+	From              string
+	ReplyTo           string
+}
+
+type envelope struct {
+	Header header
+	// The first element of the body, i.e. the OCPP message or a SOAP fault.
+	Body *node
+}
+
+type rawEnvelope struct {
+	XMLName xml.Name `xml:"Envelope"`
+	Header  struct {
+		ChargeBoxIdentity string `xml:"chargeBoxIdentity"`
+		MessageID         string `xml:"MessageID"`
+		RelatesTo         string `xml:"RelatesTo"`
+		Action            string `xml:"Action"`
+		To                string `xml:"To"`
+		From              string `xml:"From>Address"`
+		ReplyTo           string `xml:"ReplyTo>Address"`
+	} `xml:"Header"`
+	Body struct {
+		Content []byte `xml:",innerxml"`
+	} `xml:"Body"`
+}
+
+func parseEnvelope(data []byte) (*envelope, error) {
+	var raw rawEnvelope
+	if err := xml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid SOAP envelope: %w", err)
+	}
+	body, err := parseNode(raw.Body.Content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SOAP body: %w", err)
+	}
+	h := raw.Header
+	return &envelope{
+		Header: header{
+			ChargeBoxIdentity: strings.TrimSpace(h.ChargeBoxIdentity),
+			MessageID:         strings.TrimSpace(h.MessageID),
+			RelatesTo:         strings.TrimSpace(h.RelatesTo),
+			Action:            strings.TrimSpace(h.Action),
+			To:                strings.TrimSpace(h.To),
+			From:              strings.TrimSpace(h.From),
+			ReplyTo:           strings.TrimSpace(h.ReplyTo),
+		},
+		Body: body,
+	}, nil
+}
+
+// Returns the address, to which requests for the sender of the envelope may be sent, if any.
+func (e *envelope) callbackAddress() string {
+	for _, address := range []string{e.Header.From, e.Header.ReplyTo} {
+		if address != "" && address != anonymousAddress {
+			return address
+		}
+	}
+	return ""
+}
+
+// Returns the action of the envelope, falling back to the name of the body element if the header is missing.
+func (e *envelope) action() string {
+	if action := strings.TrimPrefix(e.Header.Action, "/"); action != "" {
+		return action
+	}
+	name := e.Body.name
+	for _, suffix := range []string{"Request", "Response"} {
+		name = strings.TrimSuffix(name, suffix)
+	}
+	return upperFirst(name)
+}
+
+func (e *envelope) isFault() bool {
+	return e.Body.name == "Fault"
+}
+
+// Converts a SOAP fault to an OCPP error. The subcode carries the OCPP error code, if it is a valid one.
+func (e *envelope) fault() *ocpp.Error {
+	code := ocpp.ErrorCode(localName(e.Body.textAt("Code", "Subcode", "Value")))
+	if !isValidErrorCode(code) {
+		code = ocppj.GenericError
+	}
+	return &ocpp.Error{Code: code, Description: e.Body.textAt("Reason", "Text")}
+}
+
+// Writes an envelope with the passed header and body. The chargeBoxIdentity header is in the passed namespace.
+func writeEnvelope(buf *bytes.Buffer, namespace string, h header, body func(buf *bytes.Buffer) error) error {
+	buf.WriteString(xml.Header)
+	fmt.Fprintf(buf, `<s:Envelope xmlns:s="%s" xmlns:a="%s" xmlns:o="%s"><s:Header>`, envelopeNamespace, addressingNamespace, namespace)
+	writeHeader(buf, "o:chargeBoxIdentity", h.ChargeBoxIdentity)
+	writeHeader(buf, "a:MessageID", h.MessageID)
+	writeHeader(buf, "a:RelatesTo", h.RelatesTo)
+	writeHeader(buf, "a:Action", h.Action)
+	writeHeader(buf, "a:To", h.To)
+	if h.From != "" {
+		buf.WriteString("<a:From>")
+		writeHeader(buf, "a:Address", h.From)
+		buf.WriteString("</a:From>")
+	}
+	if h.ReplyTo != "" {
+		buf.WriteString("<a:ReplyTo>")
+		writeHeader(buf, "a:Address", h.ReplyTo)
+		buf.WriteString("</a:ReplyTo>")
+	}
+	buf.WriteString("</s:Header><s:Body>")
+	if err := body(buf); err != nil {
+		return err
+	}
+	buf.WriteString("</s:Body></s:Envelope>")
+	return nil
+}
+
+func writeHeader(buf *bytes.Buffer, name string, value string) {
+	if value == "" {
+		return
+	}
+	fmt.Fprintf(buf, "<%s>", name)
+	_ = xml.EscapeText(buf, []byte(value))
+	fmt.Fprintf(buf, "</%s>", name)
+}
+
+// Writes a SOAP 1.2 fault. Errors caused by the sender are reported with the Sender code, all others with the Receiver code.
+func writeFault(buf *bytes.Buffer, err *ocpp.Error) {
+	fmt.Fprintf(buf, `<s:Fault><s:Code><s:Value>s:%s</s:Value><s:Subcode><s:Value>o:%s</s:Value></s:Subcode></s:Code><s:Reason><s:Text xml:lang="en">`,
+		faultCode(err.Code), err.Code)
+	_ = xml.EscapeText(buf, []byte(err.Description))
+	buf.WriteString("</s:Text></s:Reason></s:Fault>")
+}
+
+func faultCode(code ocpp.ErrorCode) string {
+	switch code {
+	case ocppj.ProtocolError, ocppj.SecurityError, ocppj.FormatViolationV16, ocppj.PropertyConstraintViolation, ocppj.OccurrenceConstraintViolation, ocppj.TypeConstraintViolation:
+		return "Sender"
+	}
+	return "Receiver"
+}
+
+func isValidErrorCode(code ocpp.ErrorCode) bool {
+	switch code {
+	case ocppj.NotImplemented, ocppj.NotSupported, ocppj.InternalError, ocppj.ProtocolError, ocppj.SecurityError, ocppj.FormatViolationV16,
+		ocppj.PropertyConstraintViolation, ocppj.OccurrenceConstraintViolation, ocppj.TypeConstraintViolation, ocppj.GenericError:
+		return true
+	}
+	return false
+}
+
+func localName(name string) string {
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// Returns the name of the body element of a message, e.g. bootNotificationRequest.
+func elementName(action string, suffix string) string {
+	if action == "" {
+		return suffix
+	}
+	return strings.ToLower(action[:1]) + action[1:] + suffix
+}
+
+func upperFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+func newMessageID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,563 @@
+// Package soap implements the OCPP 1.6 SOAP transport (OCPP 1.6-S) for central systems.
+//
+// The Server implements the ws.WsServer interface, hence the ocppj and ocpp1.6 layers run on top of it unchanged,
+// including validation and all feature types:
+//
+//	server := soap.NewServer(core.Profile, localauth.Profile, firmware.Profile, reservation.Profile, remotetrigger.Profile, smartcharging.Profile)
+//	centralSystem := ocpp16.NewCentralSystem(nil, server)
+//	centralSystem.Start(8887, "/ocpp")
+//
+// Internally, SOAP envelopes are converted to and from OCPP-J messages. Message payloads are converted according to
+// the types of the passed profiles, using the same field names as the JSON representation.
+//
+// Charge points post their requests to the listen path of the server. A charge point is considered connected from its
+// first request, until StopConnection is invoked or a request towards it fails on the network level.
+// The central system sends requests to the address advertised by the charge point via the WS-Addressing From
+// or ReplyTo headers of its latest request.
+package soap
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/lorenzodonini/ocpp-go/clock"
+	"github.com/lorenzodonini/ocpp-go/logging"
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/lorenzodonini/ocpp-go/ws"
+)
+
+const (
+	defaultResponseTimeout = 30 * time.Second
+	maxMessageSize         = 1 << 20
+)
+
+// Returned when a request couldn't be posted to a charge point.
+var errUnreachable = errors.New("charge point unreachable")
+
+// Server is an implementation of the ws.WsServer interface, which exchanges OCPP 1.6 messages via SOAP over HTTP.
+//
+// Websocket-specific settings, such as timeouts and origin checks, have no effect.
+type Server struct {
+	features            map[string]ocpp.Feature
+	stations            map[string]*station
+	pending             map[string]chan []byte
+	httpServer          *http.Server
+	httpClient          *http.Client
+	messageHandler      func(channel ws.Channel, data []byte) error
+	newClientHandler    func(channel ws.Channel)
+	disconnectedHandler func(channel ws.Channel)
+	checkClientHandler  func(id string, r *http.Request) bool
+	basicAuthHandler    func(username string, password string) bool
+	interceptor         ws.ConnectionInterceptor
+	subprotocol         string
+	responseTimeout     time.Duration
+	timeoutConfig       ws.ServerTimeoutConfig
+	logger              logging.Logger
+	clock               clock.Clock
+	addr                *net.TCPAddr
+	nextID              uint64
+	errC                chan error
+	mutex               sync.RWMutex
+}
+
+// NewServer creates a new SOAP server, supporting the features of the passed profiles.
+func NewServer(profiles ...*ocpp.Profile) *Server {
+	features := map[string]ocpp.Feature{}
+	for _, profile := range profiles {
+		for name, feature := range profile.Features {
+			features[name] = feature
+		}
+	}
+	return &Server{
+		features:        features,
+		stations:        map[string]*station{},
+		pending:         map[string]chan []byte{},
+		httpClient:      &http.Client{Timeout: defaultResponseTimeout},
+		responseTimeout: defaultResponseTimeout,
+		timeoutConfig:   ws.NewServerTimeoutConfig(),
+		logger:          &logging.VoidLogger{},
+		clock:           clock.New(),
+	}
+}
+
+// A charge point known to the server. It implements the ws.Channel interface.
+type station struct {
+	id          string
+	endpoint    string
+	remoteAddr  net.Addr
+	tlsState    *tls.ConnectionState
+	subprotocol string
+	ctx         context.Context
+	cancel      context.CancelFunc
+	// Serializes the messages passed to the message handler, like the read loop of a websocket would.
+	deliverMutex sync.Mutex
+}
+
+func (s *station) ID() string                               { return s.id }
+func (s *station) RemoteAddr() net.Addr                     { return s.remoteAddr }
+func (s *station) TLSConnectionState() *tls.ConnectionState { return s.tlsState }
+func (s *station) Subprotocol() string                      { return s.subprotocol }
+func (s *station) Context() context.Context                 { return s.ctx }
+
+// SetHTTPClient sets the client used for sending requests to charge points.
+func (s *Server) SetHTTPClient(client *http.Client) {
+	s.httpClient = client
+}
+
+// SetResponseTimeout sets how long an incoming request is kept open, while waiting for the central system to respond.
+func (s *Server) SetResponseTimeout(timeout time.Duration) {
+	s.responseTimeout = timeout
+}
+
+// SetClock sets the clock used for the response timeout of incoming requests. Passing nil restores the real clock.
+func (s *Server) SetClock(c clock.Clock) {
+	s.clock = clock.OrDefault(c)
+}
+
+// SetLogger sets the logger used by this server. Passing nil disables logging.
+func (s *Server) SetLogger(logger logging.Logger) {
+	if logger == nil {
+		logger = &logging.VoidLogger{}
+	}
+	s.logger = logger
+}
+
+// SetConnectionInterceptor sets a function, which derives the base context of every charge point, upon its first request.
+func (s *Server) SetConnectionInterceptor(interceptor ws.ConnectionInterceptor) {
+	s.interceptor = interceptor
+}
+
+func (s *Server) Start(port int, listenPath string) {
+	router := http.NewServeMux()
+	router.HandleFunc(listenPath, s.handleHTTP)
+	s.mutex.Lock()
+	s.httpServer = &http.Server{Handler: router}
+	httpServer := s.httpServer
+	s.mutex.Unlock()
+	addr := fmt.Sprintf(":%v", port)
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		s.error(fmt.Errorf("failed to listen: %w", err))
+		return
+	}
+	defer ln.Close()
+	s.mutex.Lock()
+	s.addr = ln.Addr().(*net.TCPAddr)
+	s.mutex.Unlock()
+	s.logger.Infof("listening on tcp network %v", addr)
+	err = httpServer.Serve(ln)
+	if err != http.ErrServerClosed {
+		s.error(fmt.Errorf("failed to listen: %w", err))
+	}
+}
+
+func (s *Server) Stop() {
+	s.mutex.Lock()
+	httpServer := s.httpServer
+	stations := s.stations
+	s.stations = map[string]*station{}
+	s.mutex.Unlock()
+	if httpServer != nil {
+		if err := httpServer.Shutdown(context.TODO()); err != nil {
+			s.error(fmt.Errorf("shutdown failed: %w", err))
+		}
+	}
+	for _, st := range stations {
+		s.disconnected(st)
+	}
+	s.mutex.Lock()
+	if s.errC != nil {
+		close(s.errC)
+		s.errC = nil
+	}
+	s.mutex.Unlock()
+}
+
+// StopConnection forgets the charge point. It is considered connected again with its next request.
+func (s *Server) StopConnection(id string, closeError websocket.CloseError) error {
+	s.mutex.Lock()
+	st, ok := s.stations[id]
+	delete(s.stations, id)
+	s.mutex.Unlock()
+	if !ok {
+		return fmt.Errorf("couldn't stop connection %s: %w", id, ws.ErrNotConnected)
+	}
+	s.disconnected(st)
+	return nil
+}
+
+func (s *Server) Errors() <-chan error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.errC == nil {
+		s.errC = make(chan error, 1)
+	}
+	return s.errC
+}
+
+func (s *Server) SetMessageHandler(handler func(channel ws.Channel, data []byte) error) {
+	s.messageHandler = handler
+}
+
+func (s *Server) SetNewClientHandler(handler func(channel ws.Channel)) {
+	s.newClientHandler = handler
+}
+
+func (s *Server) SetDisconnectedClientHandler(handler func(channel ws.Channel)) {
+	s.disconnectedHandler = handler
+}
+
+func (s *Server) SetTimeoutConfig(config ws.ServerTimeoutConfig) {
+	s.timeoutConfig = config
+}
+
+// Write delivers an OCPP-J message to a charge point. Requests are posted to the charge point asynchronously,
+// while responses complete the pending HTTP request of the charge point.
+func (s *Server) Write(webSocketId string, data []byte) error {
+	s.mutex.RLock()
+	st, ok := s.stations[webSocketId]
+	var endpoint string
+	if ok {
+		endpoint = st.endpoint
+	}
+	s.mutex.RUnlock()
+	if !ok {
+		return fmt.Errorf("couldn't write to %v: %w", webSocketId, ws.ErrNotConnected)
+	}
+	var message []json.RawMessage
+	var messageType ocppj.MessageType
+	var uniqueID string
+	if err := json.Unmarshal(data, &message); err != nil || len(message) < 3 {
+		return fmt.Errorf("invalid OCPP-J message for %v", webSocketId)
+	}
+	if err := json.Unmarshal(message[0], &messageType); err != nil {
+		return fmt.Errorf("invalid message type for %v: %w", webSocketId, err)
+	}
+	if err := json.Unmarshal(message[1], &uniqueID); err != nil {
+		return fmt.Errorf("invalid unique ID for %v: %w", webSocketId, err)
+	}
+	if messageType == ocppj.CALL {
+		var action string
+		if err := json.Unmarshal(message[2], &action); err != nil || len(message) < 4 {
+			return fmt.Errorf("invalid request for %v", webSocketId)
+		}
+		if endpoint == "" {
+			return fmt.Errorf("couldn't write to %v: no endpoint advertised", webSocketId)
+		}
+		go s.sendRequest(st, endpoint, uniqueID, action, message[3])
+		return nil
+	}
+	s.mutex.Lock()
+	responseC, ok := s.pending[uniqueID]
+	delete(s.pending, uniqueID)
+	s.mutex.Unlock()
+	if !ok {
+		return fmt.Errorf("couldn't write to %v: no pending request %v", webSocketId, uniqueID)
+	}
+	responseC <- data
+	return nil
+}
+
+// AddSupportedSubprotocol sets the subprotocol reported by all channels.
+func (s *Server) AddSupportedSubprotocol(subProto string) {
+	if s.subprotocol == "" {
+		s.subprotocol = subProto
+	}
+}
+
+func (s *Server) SetBasicAuthHandler(handler func(username string, password string) bool) {
+	s.basicAuthHandler = handler
+}
+
+// SetCheckOriginHandler has no effect, since no websocket handshake takes place.
+func (s *Server) SetCheckOriginHandler(handler func(r *http.Request) bool) {}
+
+func (s *Server) SetCheckClientHandler(handler func(id string, r *http.Request) bool) {
+	s.checkClientHandler = handler
+}
+
+func (s *Server) Addr() *net.TCPAddr {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.addr
+}
+
+func (s *Server) error(err error) {
+	s.logger.Error(err)
+	s.mutex.RLock()
+	errC := s.errC
+	s.mutex.RUnlock()
+	if errC != nil {
+		errC <- err
+	}
+}
+
+// Handles a request posted by a charge point.
+func (s *Server) handleHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.basicAuthHandler != nil {
+		username, password, ok := r.BasicAuth()
+		if ok {
+			ok = s.basicAuthHandler(username, password)
+		}
+		if !ok {
+			s.error(fmt.Errorf("basic auth failed: credentials invalid"))
+			w.Header().Set("WWW-Authenticate", `Basic realm="Restricted"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxMessageSize))
+	if err != nil {
+		s.error(fmt.Errorf("couldn't read request from %v: %w", r.RemoteAddr, err))
+		return
+	}
+	request, err := parseEnvelope(data)
+	if err != nil {
+		s.writeFault(w, header{}, ocpp.NewError(ocppj.ProtocolError, err.Error(), ""))
+		return
+	}
+	replyHeader := header{RelatesTo: request.Header.MessageID}
+	id := request.Header.ChargeBoxIdentity
+	if id == "" {
+		s.writeFault(w, replyHeader, ocpp.NewError(ocppj.ProtocolError, "missing chargeBoxIdentity header", ""))
+		return
+	}
+	action := request.action()
+	feature, ok := s.features[action]
+	if !ok {
+		s.writeFault(w, replyHeader, ocpp.NewError(ocppj.NotImplemented, fmt.Sprintf("unsupported action %v", action), ""))
+		return
+	}
+	payload, err := decodePayload(request.Body, feature.GetRequestType())
+	if err != nil {
+		s.writeFault(w, replyHeader, ocpp.NewError(ocppj.FormatViolationV16, err.Error(), ""))
+		return
+	}
+	st, err := s.station(id, r, request.callbackAddress())
+	if err != nil {
+		s.error(err)
+		s.writeFault(w, replyHeader, ocpp.NewError(ocppj.SecurityError, "invalid client", ""))
+		return
+	}
+	// Pass the request to the ocppj layer and wait for its response
+	uniqueID, responseC := s.addPending()
+	frame, _ := json.Marshal([]interface{}{ocppj.CALL, uniqueID, action, payload})
+	s.deliver(st, frame)
+	var response []byte
+	select {
+	case response = <-responseC:
+	case <-s.clock.After(s.responseTimeout):
+		s.removePending(uniqueID)
+		s.writeFault(w, replyHeader, ocpp.NewError(ocppj.InternalError, "no response from central system", ""))
+		return
+	case <-r.Context().Done():
+		s.removePending(uniqueID)
+		return
+	}
+	var message []json.RawMessage
+	if err = json.Unmarshal(response, &message); err != nil || len(message) < 3 {
+		s.writeFault(w, replyHeader, ocpp.NewError(ocppj.InternalError, "invalid response from central system", ""))
+		return
+	}
+	var messageType ocppj.MessageType
+	_ = json.Unmarshal(message[0], &messageType)
+	if messageType == ocppj.CALL_ERROR && len(message) >= 4 {
+		var code, description string
+		_ = json.Unmarshal(message[2], &code)
+		_ = json.Unmarshal(message[3], &description)
+		s.writeFault(w, replyHeader, ocpp.NewError(ocpp.ErrorCode(code), description, ""))
+		return
+	}
+	replyHeader.MessageID = newMessageID()
+	replyHeader.Action = "/" + action + "Response"
+	var buf bytes.Buffer
+	err = writeEnvelope(&buf, CentralSystemNamespace, replyHeader, func(buf *bytes.Buffer) error {
+		return encodePayload(buf, elementName(action, "Response"), CentralSystemNamespace, message[2], feature.GetResponseType())
+	})
+	if err != nil {
+		s.writeFault(w, replyHeader, ocpp.NewError(ocppj.InternalError, err.Error(), ""))
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	_, _ = w.Write(buf.Bytes())
+}
+
+func (s *Server) writeFault(w http.ResponseWriter, h header, err *ocpp.Error) {
+	s.logger.Debugf("replying with SOAP fault %v: %v", err.Code, err.Description)
+	h.MessageID = newMessageID()
+	h.Action = "http://www.w3.org/2005/08/addressing/soap/fault"
+	var buf bytes.Buffer
+	_ = writeEnvelope(&buf, CentralSystemNamespace, h, func(buf *bytes.Buffer) error {
+		writeFault(buf, err)
+		return nil
+	})
+	w.Header().Set("Content-Type", contentType)
+	if faultCode(err.Code) == "Sender" {
+		w.WriteHeader(http.StatusBadRequest)
+	} else {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	_, _ = w.Write(buf.Bytes())
+}
+
+// Returns the charge point with the passed ID, registering it if it is not known yet.
+// The callback address is updated, whenever a new one is advertised.
+func (s *Server) station(id string, r *http.Request, endpoint string) (*station, error) {
+	s.mutex.Lock()
+	st, ok := s.stations[id]
+	if ok {
+		if endpoint != "" {
+			st.endpoint = endpoint
+		}
+		s.mutex.Unlock()
+		return st, nil
+	}
+	s.mutex.Unlock()
+	if s.checkClientHandler != nil && !s.checkClientHandler(id, r) {
+		return nil, fmt.Errorf("client validation: invalid client %v", id)
+	}
+	ctx := context.Background()
+	if s.interceptor != nil {
+		if interceptedCtx := s.interceptor(ctx, id, r); interceptedCtx != nil {
+			ctx = interceptedCtx
+		}
+	}
+	st = &station{
+		id:          id,
+		endpoint:    endpoint,
+		tlsState:    r.TLS,
+		subprotocol: s.subprotocol,
+	}
+	st.remoteAddr, _ = net.ResolveTCPAddr("tcp", r.RemoteAddr)
+	st.ctx, st.cancel = context.WithCancel(ctx)
+	s.mutex.Lock()
+	if existing, ok := s.stations[id]; ok {
+		// Registered concurrently
+		s.mutex.Unlock()
+		st.cancel()
+		return existing, nil
+	}
+	s.stations[id] = st
+	s.mutex.Unlock()
+	s.logger.Debugf("new charge point %v with endpoint %v", id, endpoint)
+	if s.newClientHandler != nil {
+		s.newClientHandler(st)
+	}
+	return st, nil
+}
+
+func (s *Server) disconnected(st *station) {
+	st.cancel()
+	if s.disconnectedHandler != nil {
+		s.disconnectedHandler(st)
+	}
+}
+
+func (s *Server) deliver(st *station, frame []byte) {
+	st.deliverMutex.Lock()
+	defer st.deliverMutex.Unlock()
+	if s.messageHandler == nil {
+		return
+	}
+	if err := s.messageHandler(st, frame); err != nil {
+		s.error(fmt.Errorf("message handler for %v: %w", st.id, err))
+	}
+}
+
+func (s *Server) addPending() (string, chan []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.nextID++
+	uniqueID := "soap-" + strconv.FormatUint(s.nextID, 10)
+	responseC := make(chan []byte, 1)
+	s.pending[uniqueID] = responseC
+	return uniqueID, responseC
+}
+
+func (s *Server) removePending(uniqueID string) {
+	s.mutex.Lock()
+	delete(s.pending, uniqueID)
+	s.mutex.Unlock()
+}
+
+// Posts a request to a charge point and passes its response to the ocppj layer.
+// If the charge point can't be reached, it is considered disconnected.
+func (s *Server) sendRequest(st *station, endpoint string, uniqueID string, action string, payload json.RawMessage) {
+	response, err := s.post(st.id, endpoint, action, payload)
+	if errors.Is(err, errUnreachable) {
+		s.error(fmt.Errorf("couldn't send %v to %v: %w", action, st.id, err))
+		_ = s.StopConnection(st.id, websocket.CloseError{Code: websocket.CloseAbnormalClosure})
+		return
+	}
+	var frame []byte
+	if err != nil {
+		s.error(fmt.Errorf("invalid response to %v from %v: %w", action, st.id, err))
+		frame, _ = json.Marshal([]interface{}{ocppj.CALL_ERROR, uniqueID, ocppj.GenericError, err.Error(), map[string]interface{}{}})
+	} else if response.isFault() {
+		fault := response.fault()
+		frame, _ = json.Marshal([]interface{}{ocppj.CALL_ERROR, uniqueID, fault.Code, fault.Description, map[string]interface{}{}})
+	} else {
+		responsePayload, err := decodePayload(response.Body, s.features[action].GetResponseType())
+		if err != nil {
+			s.error(fmt.Errorf("invalid response to %v from %v: %w", action, st.id, err))
+			frame, _ = json.Marshal([]interface{}{ocppj.CALL_ERROR, uniqueID, ocppj.FormatViolationV16, err.Error(), map[string]interface{}{}})
+		} else {
+			frame, _ = json.Marshal([]interface{}{ocppj.CALL_RESULT, uniqueID, responsePayload})
+		}
+	}
+	s.deliver(st, frame)
+}
+
+func (s *Server) post(id string, endpoint string, action string, payload json.RawMessage) (*envelope, error) {
+	feature, ok := s.features[action]
+	if !ok {
+		return nil, fmt.Errorf("unsupported action %v", action)
+	}
+	h := header{
+		ChargeBoxIdentity: id,
+		MessageID:         newMessageID(),
+		Action:            "/" + action,
+		To:                endpoint,
+		ReplyTo:           anonymousAddress,
+	}
+	var buf bytes.Buffer
+	err := writeEnvelope(&buf, ChargePointNamespace, h, func(buf *bytes.Buffer) error {
+		return encodePayload(buf, elementName(action, "Request"), ChargePointNamespace, payload, feature.GetRequestType())
+	})
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequest(http.MethodPost, endpoint, &buf)
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", fmt.Sprintf(`%s; action="%s"`, contentType, h.Action))
+	s.logger.Debugf("posting %v to %v", action, endpoint)
+	httpResponse, err := s.httpClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errUnreachable, err)
+	}
+	defer httpResponse.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(httpResponse.Body, maxMessageSize))
+	if err != nil {
+		return nil, err
+	}
+	return parseEnvelope(data)
+}
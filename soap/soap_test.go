@@ -0,0 +1,81 @@
+package soap
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+)
+
+func TestPayloadRoundTrip(t *testing.T) {
+	transactionID := 42
+	request := core.NewMeterValuesRequest(1, []types.MeterValue{
+		{
+			Timestamp: types.NewDateTime(time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)),
+			SampledValue: []types.SampledValue{
+				{Value: "100", Measurand: types.MeasurandEnergyActiveImportRegister, Unit: types.UnitOfMeasureWh},
+				{Value: "16 & more", Phase: types.PhaseL1},
+			},
+		},
+	})
+	request.TransactionId = &transactionID
+	payload, err := json.Marshal(request)
+	require.NoError(t, err)
+	requestType := reflect.TypeOf(core.MeterValuesRequest{})
+
+	var buf bytes.Buffer
+	require.NoError(t, encodePayload(&buf, elementName(core.MeterValuesFeatureName, "Request"), CentralSystemNamespace, payload, requestType))
+	assert.Contains(t, buf.String(), `<meterValuesRequest xmlns="urn://Ocpp/Cs/2015/10/"><connectorId>1</connectorId><transactionId>42</transactionId><meterValue>`)
+	assert.Contains(t, buf.String(), "<value>16 &amp; more</value>")
+	assert.NotContains(t, buf.String(), "<context>")
+
+	n, err := parseNode(buf.Bytes())
+	require.NoError(t, err)
+	decoded, err := decodePayload(n, requestType)
+	require.NoError(t, err)
+	var result core.MeterValuesRequest
+	require.NoError(t, json.Unmarshal(decoded, &result))
+	require.NotNil(t, result.TransactionId)
+	assert.Equal(t, transactionID, *result.TransactionId)
+	require.Len(t, result.MeterValue, 1)
+	assert.True(t, request.MeterValue[0].Timestamp.Equal(result.MeterValue[0].Timestamp.Time))
+	assert.Equal(t, request.MeterValue[0].SampledValue, result.MeterValue[0].SampledValue)
+}
+
+func TestDecodeInvalidPayload(t *testing.T) {
+	n, err := parseNode([]byte(`<meterValuesRequest><connectorId>one</connectorId></meterValuesRequest>`))
+	require.NoError(t, err)
+	_, err = decodePayload(n, reflect.TypeOf(core.MeterValuesRequest{}))
+	assert.Error(t, err)
+}
+
+func TestEnvelope(t *testing.T) {
+	h := header{ChargeBoxIdentity: "cp1", MessageID: "urn:uuid:1", Action: "/Reset", To: "http://cp1", ReplyTo: anonymousAddress}
+	var buf bytes.Buffer
+	require.NoError(t, writeEnvelope(&buf, ChargePointNamespace, h, func(buf *bytes.Buffer) error {
+		writeFault(buf, ocpp.NewError(ocppj.NotSupported, "not <supported>", ""))
+		return nil
+	}))
+	e, err := parseEnvelope(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, h, e.Header)
+	assert.Equal(t, "Reset", e.action())
+	assert.Equal(t, "", e.callbackAddress())
+	require.True(t, e.isFault())
+	fault := e.fault()
+	assert.Equal(t, ocppj.NotSupported, fault.Code)
+	assert.Equal(t, "not <supported>", fault.Description)
+	// Unknown subcodes are reported as generic errors
+	e, err = parseEnvelope([]byte(`<Envelope><Body><Fault><Code><Value>Sender</Value><Subcode><Value>IdentityMismatch</Value></Subcode></Code></Fault></Body></Envelope>`))
+	require.NoError(t, err)
+	assert.Equal(t, ocppj.GenericError, e.fault().Code)
+}
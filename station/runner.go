@@ -0,0 +1,348 @@
+// Package station coordinates the lifecycle of the helper managers, which make up a charging station application.
+//
+// A charging station built from several managers (boot, availability, transaction queue, log upload, ...) has
+// non-trivial shutdown ordering requirements: it must stop accepting new work, finish or persist queued transaction
+// events and send a final notification, before closing the websocket connection gracefully.
+// A Runner starts the registered managers in registration order and stops them in reverse order,
+// while the connection is always opened first and closed last:
+//
+//	runner := station.NewRunner()
+//	runner.SetConnection(station.Connection(chargingStation, csmsUrl))
+//	runner.Register("security", station.Funcs{StopFunc: func(ctx context.Context) error {
+//		_, err := chargingStation.SecurityEventNotification("ResetOrReboot", types.Now())
+//		return err
+//	}})
+//	runner.Register("transactions", station.Funcs{StopFunc: offlineQueue.Flush})
+//	runner.Register("boot", station.Background(bootManager))
+//	runner.Register("availability", station.Background(stationManager))
+//	if err := runner.Start(ctx); err != nil {
+//		log.Fatal(err)
+//	}
+//	...
+//	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+//	defer cancel()
+//	err := runner.Stop(ctx)
+//
+// On Stop, the availability and boot managers stop accepting new work first, then the queued transaction events
+// are flushed and the final SecurityEventNotification is sent, before the connection is closed.
+package station
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/clock"
+)
+
+// ErrRunning is returned by Start, if the runner was already started.
+var ErrRunning = errors.New("station runner already started")
+
+// Lifecycle needs to be implemented by all managers, which are registered with a Runner.
+//
+// Both methods should return once the passed context is done. A method that doesn't return in time
+// is abandoned by the runner, which continues with the remaining managers.
+type Lifecycle interface {
+	// Start starts the manager. Long-running work is expected to be performed in the background.
+	Start(ctx context.Context) error
+	// Stop stops the manager, after finishing or persisting any pending work.
+	Stop(ctx context.Context) error
+}
+
+// Funcs implements Lifecycle through functions. Nil functions have no effect.
+type Funcs struct {
+	StartFunc func(ctx context.Context) error
+	StopFunc  func(ctx context.Context) error
+}
+
+func (f Funcs) Start(ctx context.Context) error {
+	if f.StartFunc == nil {
+		return nil
+	}
+	return f.StartFunc(ctx)
+}
+
+func (f Funcs) Stop(ctx context.Context) error {
+	if f.StopFunc == nil {
+		return nil
+	}
+	return f.StopFunc(ctx)
+}
+
+// Background adapts a manager running in the background, such as provisioning.BootManager,
+// availability.StationManager or tariffcost.CostUpdater, to the Lifecycle interface.
+func Background(manager interface {
+	Start()
+	Stop()
+}) Lifecycle {
+	return Funcs{
+		StartFunc: func(ctx context.Context) error {
+			manager.Start()
+			return nil
+		},
+		StopFunc: func(ctx context.Context) error {
+			manager.Stop()
+			return nil
+		},
+	}
+}
+
+// Connection adapts the websocket connection of a charging station to the Lifecycle interface.
+// Start connects to the CSMS at the passed URL, while Stop closes the connection gracefully
+// and returns once the close handshake completed.
+func Connection(client interface {
+	Start(url string) error
+	Stop()
+}, url string) Lifecycle {
+	return Funcs{
+		StartFunc: func(ctx context.Context) error {
+			return client.Start(url)
+		},
+		StopFunc: func(ctx context.Context) error {
+			client.Stop()
+			return nil
+		},
+	}
+}
+
+// ManagerError is the error returned by a single registered manager, while starting or stopping it.
+// If the manager didn't return before the context was done, Err is the error of the context.
+type ManagerError struct {
+	Name      string
+	Err       error
+	Abandoned bool // Whether the manager was abandoned, because it didn't return before the context was done.
+}
+
+func (e *ManagerError) Error() string {
+	if e.Abandoned {
+		return fmt.Sprintf("%v abandoned: %v", e.Name, e.Err)
+	}
+	return fmt.Sprintf("%v: %v", e.Name, e.Err)
+}
+
+func (e *ManagerError) Unwrap() error {
+	return e.Err
+}
+
+// StopError is returned by Stop, if one or more managers failed to stop.
+// Errors are listed in the order the managers were stopped in.
+type StopError struct {
+	Errors []*ManagerError
+}
+
+func (e *StopError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("failed to stop %d manager(s): %v", len(e.Errors), strings.Join(messages, "; "))
+}
+
+// Is reports whether any of the manager errors matches target.
+// Together with As, it allows inspecting all manager errors via errors.Is and errors.As on Go versions,
+// which don't support unwrapping multiple errors.
+func (e *StopError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first manager error, which matches target. Refer to Is.
+func (e *StopError) As(target interface{}) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *StopError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// The name under which the connection is reported in errors.
+const connectionName = "connection"
+
+type registration struct {
+	name      string
+	lifecycle Lifecycle
+}
+
+// Runner owns the lifecycle of the managers of a charging station.
+//
+// A Runner is safe for concurrent use.
+type Runner struct {
+	mutex         sync.Mutex
+	connection    *registration
+	registrations []registration
+	started       []registration
+	running       bool
+	stopTimeout   time.Duration
+	clock         clock.Clock
+}
+
+// NewRunner creates a new Runner, without any registered managers.
+func NewRunner() *Runner {
+	return &Runner{clock: clock.New()}
+}
+
+// SetClock sets the clock used for measuring the stop timeout. Passing nil restores the real clock.
+func (r *Runner) SetClock(c clock.Clock) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.clock = clock.OrDefault(c)
+}
+
+// SetConnection sets the websocket connection of the charging station, typically created via Connection.
+// The connection is started before and stopped after all registered managers, so that the close frame goes out last.
+func (r *Runner) SetConnection(connection Lifecycle) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.connection = &registration{name: connectionName, lifecycle: connection}
+}
+
+// SetStopTimeout sets the maximum duration, for which the runner waits for a single manager to stop.
+// A manager that doesn't stop in time is abandoned, while the remaining managers are stopped with the
+// time left until the deadline of the context passed to Stop. A zero duration (the default) disables the timeout,
+// so that each manager may take until the context passed to Stop is done.
+func (r *Runner) SetStopTimeout(timeout time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.stopTimeout = timeout
+}
+
+// Register adds a manager to the runner. Managers are started in registration order and stopped in reverse order.
+// Managers registered while the runner is running are only started by the next call to Start.
+func (r *Runner) Register(name string, lifecycle Lifecycle) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.registrations = append(r.registrations, registration{name: name, lifecycle: lifecycle})
+}
+
+// Start starts the connection and all registered managers in order.
+//
+// If a manager fails to start, or doesn't return before the context is done, the managers started so far
+// are stopped in reverse order and a *ManagerError for the failed manager is returned.
+func (r *Runner) Start(ctx context.Context) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.running {
+		return ErrRunning
+	}
+	registrations := r.registrations
+	if r.connection != nil {
+		registrations = append([]registration{*r.connection}, registrations...)
+	}
+	r.started = nil
+	for _, reg := range registrations {
+		err := run(ctx, reg, reg.lifecycle.Start)
+		if err != nil {
+			if err.Abandoned {
+				// The manager may still start in the background, hence it is stopped as well
+				r.started = append(r.started, reg)
+			}
+			_ = r.stop(ctx)
+			return err
+		}
+		r.started = append(r.started, reg)
+	}
+	r.running = true
+	return nil
+}
+
+// Stop stops all started managers in reverse order, and finally closes the connection.
+//
+// Each manager is passed a context, which is done at the earlier of the stop timeout (see SetStopTimeout)
+// and the deadline of the passed context. A manager that doesn't return in time is abandoned, while the remaining
+// managers are still stopped. Once the passed context is done, the remaining managers are still invoked,
+// but are abandoned unless they return right away.
+//
+// The errors of all managers are collected and returned as a *StopError.
+// Calling Stop on a runner, which isn't running, has no effect.
+func (r *Runner) Stop(ctx context.Context) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if !r.running {
+		return nil
+	}
+	r.running = false
+	return r.stop(ctx)
+}
+
+// IsRunning returns true, if the runner was started and not stopped yet.
+func (r *Runner) IsRunning() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.running
+}
+
+func (r *Runner) stop(ctx context.Context) error {
+	var errs []*ManagerError
+	for i := len(r.started) - 1; i >= 0; i-- {
+		reg := r.started[i]
+		var err *ManagerError
+		if r.stopTimeout > 0 {
+			err = r.runWithTimeout(ctx, reg, reg.lifecycle.Stop)
+		} else {
+			err = run(ctx, reg, reg.lifecycle.Stop)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	r.started = nil
+	if len(errs) > 0 {
+		return &StopError{Errors: errs}
+	}
+	return nil
+}
+
+// runWithTimeout invokes the passed lifecycle method like run, but abandons it once the stop timeout expired.
+// The timeout is measured by the clock of the runner, in which case context.DeadlineExceeded is reported.
+func (r *Runner) runWithTimeout(ctx context.Context, reg registration, method func(ctx context.Context) error) *ManagerError {
+	timeoutCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	timer := clock.OrDefault(r.clock).AfterFunc(r.stopTimeout, cancel)
+	err := run(timeoutCtx, reg, method)
+	if !timer.Stop() && err != nil && err.Abandoned && ctx.Err() == nil {
+		err.Err = context.DeadlineExceeded
+	}
+	return err
+}
+
+// run invokes the passed lifecycle method and waits until it returns or the context is done, whichever happens first.
+func run(ctx context.Context, reg registration, method func(ctx context.Context) error) *ManagerError {
+	doneC := make(chan error, 1)
+	go func() {
+		doneC <- method(ctx)
+	}()
+	select {
+	case err := <-doneC:
+		if err != nil {
+			return &ManagerError{Name: reg.name, Err: err}
+		}
+		return nil
+	case <-ctx.Done():
+		// The method may have returned concurrently with the context being done
+		select {
+		case err := <-doneC:
+			if err != nil {
+				return &ManagerError{Name: reg.name, Err: err}
+			}
+			return nil
+		default:
+		}
+		return &ManagerError{Name: reg.name, Err: ctx.Err(), Abandoned: true}
+	}
+}
@@ -0,0 +1,217 @@
+package station_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/clock/clocktest"
+	"github.com/lorenzodonini/ocpp-go/station"
+)
+
+type recorder struct {
+	mutex sync.Mutex
+	calls []string
+}
+
+func (r *recorder) record(call string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.calls = append(r.calls, call)
+}
+
+func (r *recorder) recorded() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]string{}, r.calls...)
+}
+
+type fakeManager struct {
+	name     string
+	recorder *recorder
+	startErr error
+	stopErr  error
+	hangC    chan struct{} // If set, Stop blocks until the channel is closed.
+}
+
+func (m *fakeManager) Start(ctx context.Context) error {
+	m.recorder.record("start " + m.name)
+	return m.startErr
+}
+
+func (m *fakeManager) Stop(ctx context.Context) error {
+	m.recorder.record("stop " + m.name)
+	if m.hangC != nil {
+		<-m.hangC
+	}
+	return m.stopErr
+}
+
+func newRunner(rec *recorder, managers ...*fakeManager) *station.Runner {
+	runner := station.NewRunner()
+	for _, m := range managers {
+		m.recorder = rec
+		runner.Register(m.name, m)
+	}
+	return runner
+}
+
+func TestRunnerOrdering(t *testing.T) {
+	rec := &recorder{}
+	runner := newRunner(rec, &fakeManager{name: "security"}, &fakeManager{name: "transactions"}, &fakeManager{name: "boot"})
+	// The connection is started first and stopped last, regardless of when it was set
+	runner.SetConnection(&fakeManager{name: "connection", recorder: rec})
+	require.NoError(t, runner.Start(context.Background()))
+	assert.True(t, runner.IsRunning())
+	assert.ErrorIs(t, runner.Start(context.Background()), station.ErrRunning)
+	require.NoError(t, runner.Stop(context.Background()))
+	assert.False(t, runner.IsRunning())
+	assert.Equal(t, []string{
+		"start connection", "start security", "start transactions", "start boot",
+		"stop boot", "stop transactions", "stop security", "stop connection",
+	}, rec.recorded())
+	// Stopping a stopped runner has no effect
+	require.NoError(t, runner.Stop(context.Background()))
+	assert.Len(t, rec.recorded(), 8)
+}
+
+func TestRunnerStartFailure(t *testing.T) {
+	rec := &recorder{}
+	startErr := errors.New("no storage")
+	runner := newRunner(rec, &fakeManager{name: "a"}, &fakeManager{name: "b", startErr: startErr}, &fakeManager{name: "c"})
+	err := runner.Start(context.Background())
+	require.Error(t, err)
+	assert.ErrorIs(t, err, startErr)
+	var managerErr *station.ManagerError
+	require.True(t, errors.As(err, &managerErr))
+	assert.Equal(t, "b", managerErr.Name)
+	assert.False(t, managerErr.Abandoned)
+	assert.False(t, runner.IsRunning())
+	// Managers started so far are rolled back, the failed manager is not stopped
+	assert.Equal(t, []string{"start a", "start b", "stop a"}, rec.recorded())
+}
+
+func TestRunnerCollectsStopErrors(t *testing.T) {
+	rec := &recorder{}
+	errA := errors.New("a failed")
+	errC := errors.New("c failed")
+	runner := newRunner(rec, &fakeManager{name: "a", stopErr: errA}, &fakeManager{name: "b"}, &fakeManager{name: "c", stopErr: errC})
+	require.NoError(t, runner.Start(context.Background()))
+	err := runner.Stop(context.Background())
+	require.Error(t, err)
+	var stopErr *station.StopError
+	require.True(t, errors.As(err, &stopErr))
+	require.Len(t, stopErr.Errors, 2)
+	assert.Equal(t, "c", stopErr.Errors[0].Name)
+	assert.Equal(t, errC, stopErr.Errors[0].Err)
+	assert.Equal(t, "a", stopErr.Errors[1].Name)
+	assert.Equal(t, errA, stopErr.Errors[1].Err)
+	assert.ErrorIs(t, err, errA)
+	assert.ErrorIs(t, err, errC)
+	var managerErr *station.ManagerError
+	require.True(t, errors.As(err, &managerErr))
+	assert.Equal(t, "c", managerErr.Name)
+	assert.Equal(t, "failed to stop 2 manager(s): c: c failed; a: a failed", err.Error())
+	// All managers were stopped, despite the errors
+	assert.Equal(t, []string{"start a", "start b", "start c", "stop c", "stop b", "stop a"}, rec.recorded())
+}
+
+func TestRunnerAbandonsHungManager(t *testing.T) {
+	rec := &recorder{}
+	hangC := make(chan struct{})
+	defer close(hangC)
+	runner := newRunner(rec, &fakeManager{name: "a"}, &fakeManager{name: "hung", hangC: hangC}, &fakeManager{name: "c"})
+	runner.SetConnection(&fakeManager{name: "connection", recorder: rec})
+	fakeClock := clocktest.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	runner.SetClock(fakeClock)
+	runner.SetStopTimeout(10 * time.Second)
+	require.NoError(t, runner.Start(context.Background()))
+	errC := make(chan error, 1)
+	go func() {
+		errC <- runner.Stop(context.Background())
+	}()
+	// The hung manager is only abandoned once the stop timeout expired
+	require.Eventually(t, func() bool {
+		calls := rec.recorded()
+		return calls[len(calls)-1] == "stop hung"
+	}, time.Second, time.Millisecond)
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(9 * time.Second)
+	select {
+	case <-errC:
+		require.Fail(t, "hung manager abandoned before the stop timeout expired")
+	case <-time.After(50 * time.Millisecond):
+	}
+	fakeClock.Advance(time.Second)
+	var err error
+	select {
+	case err = <-errC:
+	case <-time.After(time.Second):
+		require.FailNow(t, "hung manager not abandoned")
+	}
+	require.Error(t, err)
+	var stopErr *station.StopError
+	require.True(t, errors.As(err, &stopErr))
+	require.Len(t, stopErr.Errors, 1)
+	assert.Equal(t, "hung", stopErr.Errors[0].Name)
+	assert.True(t, stopErr.Errors[0].Abandoned)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	// The remaining managers are still stopped in order, with the connection being closed last
+	assert.Equal(t, []string{
+		"start connection", "start a", "start hung", "start c",
+		"stop c", "stop hung", "stop a", "stop connection",
+	}, rec.recorded())
+	assert.False(t, runner.IsRunning())
+}
+
+func TestRunnerStopDeadline(t *testing.T) {
+	rec := &recorder{}
+	hangC := make(chan struct{})
+	defer close(hangC)
+	runner := newRunner(rec, &fakeManager{name: "a"}, &fakeManager{name: "hung", hangC: hangC})
+	runner.SetConnection(&fakeManager{name: "connection", recorder: rec})
+	require.NoError(t, runner.Start(context.Background()))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	begin := time.Now()
+	err := runner.Stop(ctx)
+	assert.Less(t, time.Since(begin), time.Second)
+	require.Error(t, err)
+	var stopErr *station.StopError
+	require.True(t, errors.As(err, &stopErr))
+	require.NotEmpty(t, stopErr.Errors)
+	assert.Equal(t, "hung", stopErr.Errors[0].Name)
+	assert.True(t, stopErr.Errors[0].Abandoned)
+	assert.ErrorIs(t, stopErr.Errors[0], context.DeadlineExceeded)
+	// The remaining managers are still invoked, once the deadline expired
+	assert.Eventually(t, func() bool {
+		return len(rec.recorded()) == 6
+	}, time.Second, 10*time.Millisecond)
+	assert.ElementsMatch(t, []string{"stop a", "stop connection"}, rec.recorded()[4:])
+}
+
+func TestBackground(t *testing.T) {
+	manager := &backgroundManager{}
+	lifecycle := station.Background(manager)
+	require.NoError(t, lifecycle.Start(context.Background()))
+	assert.True(t, manager.running)
+	require.NoError(t, lifecycle.Stop(context.Background()))
+	assert.False(t, manager.running)
+}
+
+type backgroundManager struct {
+	running bool
+}
+
+func (m *backgroundManager) Start() {
+	m.running = true
+}
+
+func (m *backgroundManager) Stop() {
+	m.running = false
+}
@@ -0,0 +1,212 @@
+// Package unlock implements the charge point side of UnlockConnector requests, for OCPP 1.6 and OCPP 2.0.1.
+//
+// The decision functions encode the rules of the respective specification, while the Unlocker types combine them
+// with range validation and an actuator callback, so that handlers reduce to a single line:
+//
+//	unlocker := unlock.NewUnlocker16(2, func(connectorID int) error {
+//		return hardware.ReleaseLatch(connectorID)
+//	})
+//
+//	func (h *handler) OnUnlockConnector(request *core.UnlockConnectorRequest) (*core.UnlockConnectorConfirmation, error) {
+//		return h.unlocker.OnUnlockConnector(request)
+//	}
+package unlock
+
+import (
+	"sync"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/remotecontrol"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+)
+
+// ConnectorSnapshot contains the state of a connector, at the time an UnlockConnector request is processed.
+type ConnectorSnapshot struct {
+	TransactionOngoing bool // Whether a transaction is ongoing on the connector.
+	Authorized         bool // Whether the ongoing transaction is authorized. Only considered in OCPP 2.0.1.
+	LockFaulted        bool // Whether the connector lock is known to be broken, e.g. after a failed self-test.
+}
+
+// Decide16 returns the status a charge point should reply with, when receiving an UnlockConnectorRequest for the passed connector.
+//
+// The decision follows the OCPP 1.6 specification:
+//
+// - NotSupported, if the connector ID is not a valid physical connector, or the connector has no lock (e.g. a fixed cable)
+//
+// - UnlockFailed, if the lock is known to be faulted, or a transaction is still ongoing. UnlockConnector must not be used
+// to stop charging: the charge point has to finish the transaction first (with reason UnlockCommand), then decide again.
+//
+// - Unlocked, if the connector may be unlocked. The actual outcome depends on the actuator.
+func Decide16(connectorID int, state ConnectorSnapshot, hasLatch bool) core.UnlockStatus {
+	if connectorID <= 0 || !hasLatch {
+		return core.UnlockStatusNotSupported
+	}
+	if state.LockFaulted || state.TransactionOngoing {
+		return core.UnlockStatusUnlockFailed
+	}
+	return core.UnlockStatusUnlocked
+}
+
+// Decide201 returns the status a charging station should reply with, when receiving an UnlockConnectorRequest
+// for the passed EVSE and connector.
+//
+// The decision follows the OCPP 2.0.1 specification (use case F05):
+//
+// - UnknownConnector, if the EVSE or connector ID is not a valid physical connector
+//
+// - OngoingAuthorizedTransaction, if an authorized transaction is ongoing on the connector. Unauthorized transactions
+// (e.g. an EV plugged in, waiting for authorization) don't prevent unlocking.
+//
+// - UnlockFailed, if the connector has no lock (e.g. a fixed cable), or the lock is known to be faulted
+//
+// - Unlocked, if the connector may be unlocked. The actual outcome depends on the actuator.
+func Decide201(evseID int, connectorID int, state ConnectorSnapshot, hasLatch bool) remotecontrol.UnlockStatus {
+	if evseID <= 0 || connectorID <= 0 {
+		return remotecontrol.UnlockStatusUnknownConnector
+	}
+	if state.TransactionOngoing && state.Authorized {
+		return remotecontrol.UnlockStatusOngoingAuthorizedTransaction
+	}
+	if !hasLatch || state.LockFaulted {
+		return remotecontrol.UnlockStatusUnlockFailed
+	}
+	return remotecontrol.UnlockStatusUnlocked
+}
+
+// Unlocker16 handles UnlockConnector requests on an OCPP 1.6 charge point.
+//
+// Requests for connectors out of range are answered with NotSupported. For all other connectors, the decision is
+// taken via Decide16. If a transaction is ongoing, it is stopped via StopTransaction first. Once the connector may
+// be unlocked, the actuator is invoked: the connector is reported as Unlocked, unless the actuator returns an error.
+//
+// An Unlocker16 is safe for concurrent use, as long as the callbacks are.
+type Unlocker16 struct {
+	// Returns the current state of a connector. If nil, connectors are assumed to be idle.
+	Snapshot func(connectorID int) ConnectorSnapshot
+	// Returns whether a connector has a lock. If nil, all connectors are assumed to have a lock.
+	HasLatch func(connectorID int) bool
+	// Stops the transaction ongoing on a connector with reason UnlockCommand, returning once it was stopped.
+	// If nil, or if stopping fails, the connector is reported as UnlockFailed.
+	StopTransaction func(connectorID int) error
+	numConnectors   int
+	actuate         func(connectorID int) error
+	mutex           sync.Mutex
+}
+
+// NewUnlocker16 creates an Unlocker16 for a charge point with the passed amount of connectors.
+// A zero numConnectors disables the range validation. The actuator unlocks a connector, returning an error on failure.
+func NewUnlocker16(numConnectors int, actuate func(connectorID int) error) *Unlocker16 {
+	return &Unlocker16{numConnectors: numConnectors, actuate: actuate}
+}
+
+// ValidConnector16 returns whether a connector ID refers to a physical connector of a charge point with numConnectors connectors.
+// A zero numConnectors accepts all positive connector IDs.
+func ValidConnector16(numConnectors int, connectorID int) bool {
+	return connectorID > 0 && (numConnectors <= 0 || connectorID <= numConnectors)
+}
+
+// OnUnlockConnector handles an UnlockConnectorRequest, matching the signature of the core.ChargePointHandler method.
+func (u *Unlocker16) OnUnlockConnector(request *core.UnlockConnectorRequest) (*core.UnlockConnectorConfirmation, error) {
+	return core.NewUnlockConnectorConfirmation(u.Unlock(request.ConnectorId)), nil
+}
+
+// Unlock decides on and performs unlocking a connector, returning the resulting status.
+// Requests for the same charge point are serialized, so that the connector state doesn't change in the meantime.
+func (u *Unlocker16) Unlock(connectorID int) core.UnlockStatus {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if !ValidConnector16(u.numConnectors, connectorID) {
+		return core.UnlockStatusNotSupported
+	}
+	state := u.snapshot(connectorID)
+	hasLatch := u.HasLatch == nil || u.HasLatch(connectorID)
+	status := Decide16(connectorID, state, hasLatch)
+	if status == core.UnlockStatusUnlockFailed && state.TransactionOngoing && !state.LockFaulted {
+		// The transaction has to be finished first
+		if u.StopTransaction == nil || u.StopTransaction(connectorID) != nil {
+			return core.UnlockStatusUnlockFailed
+		}
+		status = Decide16(connectorID, u.snapshot(connectorID), hasLatch)
+	}
+	if status != core.UnlockStatusUnlocked {
+		return status
+	}
+	if err := u.actuate(connectorID); err != nil {
+		return core.UnlockStatusUnlockFailed
+	}
+	return core.UnlockStatusUnlocked
+}
+
+func (u *Unlocker16) snapshot(connectorID int) ConnectorSnapshot {
+	if u.Snapshot == nil {
+		return ConnectorSnapshot{}
+	}
+	return u.Snapshot(connectorID)
+}
+
+// Unlocker201 handles UnlockConnector requests on an OCPP 2.0.1 charging station.
+//
+// Requests for EVSEs or connectors unknown to the topology are answered with UnknownConnector. For all other
+// connectors, the decision is taken via Decide201. Once the connector may be unlocked, the actuator is invoked:
+// the connector is reported as Unlocked, unless the actuator returns an error.
+//
+// An Unlocker201 is safe for concurrent use, as long as the callbacks are.
+type Unlocker201 struct {
+	// Returns the current state of a connector. If nil, connectors are assumed to be idle.
+	Snapshot func(evseID int, connectorID int) ConnectorSnapshot
+	// Returns whether a connector has a lock. If nil, all connectors are assumed to have a lock.
+	HasLatch func(evseID int, connectorID int) bool
+	topology *types.StationTopology
+	actuate  func(evseID int, connectorID int) error
+	mutex    sync.Mutex
+}
+
+// NewUnlocker201 creates an Unlocker201 for a charging station with the passed topology.
+// A nil topology disables the range validation. The actuator unlocks a connector, returning an error on failure.
+func NewUnlocker201(topology *types.StationTopology, actuate func(evseID int, connectorID int) error) *Unlocker201 {
+	return &Unlocker201{topology: topology, actuate: actuate}
+}
+
+// SetTopology replaces the topology, against which requested EVSEs and connectors are validated.
+func (u *Unlocker201) SetTopology(topology *types.StationTopology) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.topology = topology
+}
+
+// ValidConnector201 returns whether an EVSE and connector ID refer to a physical connector of the topology.
+// A nil topology accepts all positive IDs.
+func ValidConnector201(topology *types.StationTopology, evseID int, connectorID int) bool {
+	if evseID <= 0 || connectorID <= 0 {
+		return false
+	}
+	return topology == nil || topology.ValidateConnector(evseID, connectorID) == ""
+}
+
+// OnUnlockConnector handles an UnlockConnectorRequest, matching the signature of the remotecontrol.ChargingStationHandler method.
+func (u *Unlocker201) OnUnlockConnector(request *remotecontrol.UnlockConnectorRequest) (*remotecontrol.UnlockConnectorResponse, error) {
+	return remotecontrol.NewUnlockConnectorResponse(u.Unlock(request.EvseID, request.ConnectorID)), nil
+}
+
+// Unlock decides on and performs unlocking a connector, returning the resulting status.
+// Requests for the same charging station are serialized, so that the connector state doesn't change in the meantime.
+func (u *Unlocker201) Unlock(evseID int, connectorID int) remotecontrol.UnlockStatus {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if !ValidConnector201(u.topology, evseID, connectorID) {
+		return remotecontrol.UnlockStatusUnknownConnector
+	}
+	var state ConnectorSnapshot
+	if u.Snapshot != nil {
+		state = u.Snapshot(evseID, connectorID)
+	}
+	hasLatch := u.HasLatch == nil || u.HasLatch(evseID, connectorID)
+	status := Decide201(evseID, connectorID, state, hasLatch)
+	if status != remotecontrol.UnlockStatusUnlocked {
+		return status
+	}
+	if err := u.actuate(evseID, connectorID); err != nil {
+		return remotecontrol.UnlockStatusUnlockFailed
+	}
+	return remotecontrol.UnlockStatusUnlocked
+}
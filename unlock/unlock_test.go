@@ -0,0 +1,161 @@
+package unlock_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/remotecontrol"
+	"github.com/lorenzodonini/ocpp-go/ocpp2.0.1/types"
+	"github.com/lorenzodonini/ocpp-go/unlock"
+)
+
+func TestDecide16(t *testing.T) {
+	var testTable = []struct {
+		name        string
+		connectorID int
+		state       unlock.ConnectorSnapshot
+		hasLatch    bool
+		expected    core.UnlockStatus
+	}{
+		{"idle connector", 1, unlock.ConnectorSnapshot{}, true, core.UnlockStatusUnlocked},
+		{"authorization ignored", 2, unlock.ConnectorSnapshot{Authorized: true}, true, core.UnlockStatusUnlocked},
+		{"connector zero", 0, unlock.ConnectorSnapshot{}, true, core.UnlockStatusNotSupported},
+		{"negative connector", -1, unlock.ConnectorSnapshot{}, true, core.UnlockStatusNotSupported},
+		{"fixed cable", 1, unlock.ConnectorSnapshot{}, false, core.UnlockStatusNotSupported},
+		{"fixed cable with transaction", 1, unlock.ConnectorSnapshot{TransactionOngoing: true}, false, core.UnlockStatusNotSupported},
+		{"ongoing transaction", 1, unlock.ConnectorSnapshot{TransactionOngoing: true, Authorized: true}, true, core.UnlockStatusUnlockFailed},
+		{"faulted lock", 1, unlock.ConnectorSnapshot{LockFaulted: true}, true, core.UnlockStatusUnlockFailed},
+	}
+	for _, tc := range testTable {
+		assert.Equal(t, tc.expected, unlock.Decide16(tc.connectorID, tc.state, tc.hasLatch), tc.name)
+	}
+}
+
+func TestDecide201(t *testing.T) {
+	var testTable = []struct {
+		name        string
+		evseID      int
+		connectorID int
+		state       unlock.ConnectorSnapshot
+		hasLatch    bool
+		expected    remotecontrol.UnlockStatus
+	}{
+		{"idle connector", 1, 1, unlock.ConnectorSnapshot{}, true, remotecontrol.UnlockStatusUnlocked},
+		{"unauthorized transaction", 1, 1, unlock.ConnectorSnapshot{TransactionOngoing: true}, true, remotecontrol.UnlockStatusUnlocked},
+		{"evse zero", 0, 1, unlock.ConnectorSnapshot{}, true, remotecontrol.UnlockStatusUnknownConnector},
+		{"connector zero", 1, 0, unlock.ConnectorSnapshot{}, true, remotecontrol.UnlockStatusUnknownConnector},
+		{"authorized transaction", 1, 1, unlock.ConnectorSnapshot{TransactionOngoing: true, Authorized: true}, true, remotecontrol.UnlockStatusOngoingAuthorizedTransaction},
+		{"authorized transaction on fixed cable", 1, 1, unlock.ConnectorSnapshot{TransactionOngoing: true, Authorized: true}, false, remotecontrol.UnlockStatusOngoingAuthorizedTransaction},
+		{"fixed cable", 1, 1, unlock.ConnectorSnapshot{}, false, remotecontrol.UnlockStatusUnlockFailed},
+		{"faulted lock", 2, 1, unlock.ConnectorSnapshot{LockFaulted: true}, true, remotecontrol.UnlockStatusUnlockFailed},
+	}
+	for _, tc := range testTable {
+		assert.Equal(t, tc.expected, unlock.Decide201(tc.evseID, tc.connectorID, tc.state, tc.hasLatch), tc.name)
+	}
+}
+
+func TestUnlocker16(t *testing.T) {
+	var unlocked []int
+	transactions := map[int]bool{2: true}
+	unlocker := unlock.NewUnlocker16(3, func(connectorID int) error {
+		if connectorID == 3 {
+			return errors.New("latch stuck")
+		}
+		unlocked = append(unlocked, connectorID)
+		return nil
+	})
+	unlocker.Snapshot = func(connectorID int) unlock.ConnectorSnapshot {
+		return unlock.ConnectorSnapshot{TransactionOngoing: transactions[connectorID]}
+	}
+	// Without a way to stop the transaction, the connector stays locked
+	assert.Equal(t, core.UnlockStatusUnlockFailed, unlocker.Unlock(2))
+	var testTable = []struct {
+		name        string
+		connectorID int
+		expected    core.UnlockStatus
+	}{
+		{"idle connector", 1, core.UnlockStatusUnlocked},
+		{"transaction stopped first", 2, core.UnlockStatusUnlocked},
+		{"actuator failure", 3, core.UnlockStatusUnlockFailed},
+		{"out of range", 4, core.UnlockStatusNotSupported},
+		{"connector zero", 0, core.UnlockStatusNotSupported},
+	}
+	var stopped []int
+	unlocker.StopTransaction = func(connectorID int) error {
+		stopped = append(stopped, connectorID)
+		delete(transactions, connectorID)
+		return nil
+	}
+	for _, tc := range testTable {
+		confirmation, err := unlocker.OnUnlockConnector(core.NewUnlockConnectorRequest(tc.connectorID))
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, confirmation.Status, tc.name)
+	}
+	assert.Equal(t, []int{2}, stopped)
+	assert.Equal(t, []int{1, 2}, unlocked)
+	// Failing to stop the transaction
+	transactions[1] = true
+	unlocker.StopTransaction = func(connectorID int) error {
+		return errors.New("meter unavailable")
+	}
+	assert.Equal(t, core.UnlockStatusUnlockFailed, unlocker.Unlock(1))
+	// Connectors without lock
+	unlocker.HasLatch = func(connectorID int) bool { return false }
+	assert.Equal(t, core.UnlockStatusNotSupported, unlocker.Unlock(1))
+	assert.Equal(t, []int{1, 2}, unlocked)
+}
+
+func TestUnlocker201(t *testing.T) {
+	topology, err := types.NewStationTopology(
+		types.TopologyEVSE{ID: 1, Connectors: []types.TopologyConnector{{ID: 1, Type: types.ConnectorTypeSType2, Phases: 3}}},
+		types.TopologyEVSE{ID: 2, Connectors: []types.TopologyConnector{{ID: 1, Type: types.ConnectorTypeCCS2, Phases: 3}, {ID: 2, Type: types.ConnectorTypeSType2, Phases: 3}}},
+		types.TopologyEVSE{ID: 3, Connectors: []types.TopologyConnector{{ID: 1, Type: types.ConnectorTypeSType2, Phases: 3}}},
+	)
+	require.NoError(t, err)
+	type connector struct{ evseID, connectorID int }
+	var unlocked []connector
+	unlocker := unlock.NewUnlocker201(topology, func(evseID int, connectorID int) error {
+		if evseID == 3 {
+			return errors.New("latch stuck")
+		}
+		unlocked = append(unlocked, connector{evseID, connectorID})
+		return nil
+	})
+	unlocker.Snapshot = func(evseID int, connectorID int) unlock.ConnectorSnapshot {
+		return unlock.ConnectorSnapshot{TransactionOngoing: evseID == 1, Authorized: evseID == 1}
+	}
+	unlocker.HasLatch = func(evseID int, connectorID int) bool {
+		// The CCS connector has a captive cable
+		return evseID != 2 || connectorID != 1
+	}
+	var testTable = []struct {
+		name        string
+		evseID      int
+		connectorID int
+		expected    remotecontrol.UnlockStatus
+	}{
+		{"idle connector", 2, 2, remotecontrol.UnlockStatusUnlocked},
+		{"authorized transaction", 1, 1, remotecontrol.UnlockStatusOngoingAuthorizedTransaction},
+		{"fixed cable", 2, 1, remotecontrol.UnlockStatusUnlockFailed},
+		{"actuator failure", 3, 1, remotecontrol.UnlockStatusUnlockFailed},
+		{"unknown evse", 4, 1, remotecontrol.UnlockStatusUnknownConnector},
+		{"unknown connector", 1, 2, remotecontrol.UnlockStatusUnknownConnector},
+		{"whole evse", 2, 0, remotecontrol.UnlockStatusUnknownConnector},
+	}
+	for _, tc := range testTable {
+		response, err := unlocker.OnUnlockConnector(remotecontrol.NewUnlockConnectorRequest(tc.evseID, tc.connectorID))
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, response.Status, tc.name)
+	}
+	assert.Equal(t, []connector{{2, 2}}, unlocked)
+	// Without topology, all positive IDs are accepted
+	unlocker.SetTopology(nil)
+	unlocker.Snapshot = nil
+	unlocker.HasLatch = nil
+	assert.Equal(t, remotecontrol.UnlockStatusUnlocked, unlocker.Unlock(4, 5))
+	assert.Equal(t, remotecontrol.UnlockStatusUnknownConnector, unlocker.Unlock(0, 1))
+}
@@ -0,0 +1,82 @@
+package ws
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// ChargePointIDMode determines how the server derives the ID of a connecting client.
+type ChargePointIDMode int
+
+const (
+	// ChargePointIDFromPath uses the final element of the URL path as ID. This is the default mode.
+	ChargePointIDFromPath ChargePointIDMode = iota
+	// ChargePointIDFromBasicAuth uses the final element of the URL path as ID, if it is a valid identifier.
+	// Otherwise, e.g. when clients connect to the base path itself, the basic auth username is used instead.
+	// IDs from both sources are validated, and the upgrade is rejected if neither yields a valid one.
+	ChargePointIDFromBasicAuth
+)
+
+// ChargePointIDSource describes where the ID of a connected client was taken from.
+type ChargePointIDSource string
+
+const (
+	ChargePointIDSourcePath      ChargePointIDSource = "path"
+	ChargePointIDSourceBasicAuth ChargePointIDSource = "basicAuth"
+)
+
+// Maximum length of an identifierString, as defined by the OCPP security whitepaper and OCPP 2.0.1.
+const maxChargePointIDLength = 48
+
+type idSourceKey struct{}
+
+// ChargePointIDSourceFromContext returns the source of the client ID, which is stored in the base context of every connection.
+func ChargePointIDSourceFromContext(ctx context.Context) (ChargePointIDSource, bool) {
+	source, ok := ctx.Value(idSourceKey{}).(ChargePointIDSource)
+	return source, ok
+}
+
+// SetChargePointIDMode sets how the ID of a connecting client is derived.
+// The basePath is the path clients connect to when their ID is not part of the URL, e.g. "/ocpp".
+// It is only relevant to ChargePointIDFromBasicAuth.
+//
+// This function must be called before starting the server, otherwise it may lead to unexpected behavior.
+func (server *Server) SetChargePointIDMode(mode ChargePointIDMode, basePath string) {
+	server.idMode = mode
+	server.idBasePath = path.Base(path.Clean("/" + basePath))
+}
+
+// Resolves the ID of the client connecting with r, according to the configured mode.
+func (server *Server) resolveChargePointID(r *http.Request) (string, ChargePointIDSource, error) {
+	id := path.Base(r.URL.Path)
+	if server.idMode != ChargePointIDFromBasicAuth {
+		return id, ChargePointIDSourcePath, nil
+	}
+	if id != "/" && id != "." && id != server.idBasePath && isValidChargePointID(id) {
+		return id, ChargePointIDSourcePath, nil
+	}
+	username, _, ok := r.BasicAuth()
+	if ok && isValidChargePointID(username) {
+		return username, ChargePointIDSourceBasicAuth, nil
+	}
+	return "", "", fmt.Errorf("no valid charge point ID in path %v or basic auth username", r.URL.Path)
+}
+
+// Checks whether id is a valid identifierString: at most 48 characters out of a-z, A-Z, 0-9 and *-_=:+|@.
+func isValidChargePointID(id string) bool {
+	if id == "" || len(id) > maxChargePointIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.ContainsRune("*-_=:+|@.", c):
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -8,6 +8,7 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand"
@@ -15,11 +16,15 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
+	"github.com/lorenzodonini/ocpp-go/clock"
+	"github.com/lorenzodonini/ocpp-go/events"
 	"github.com/lorenzodonini/ocpp-go/logging"
 )
 
@@ -47,20 +52,37 @@ const (
 	// When the Charging Station is reconnecting, after a connection loss, it will use this variable as the minimum backoff
 	// time, the first time it tries to reconnect.
 	defaultRetryBackOffWaitMinimum = 10 * time.Second
+	// Each write accounts for 1/writeAverageWeight of the rolling average write duration of a connection.
+	writeAverageWeight = 8
 )
 
 // The internal verbose logger
 var log logging.Logger
 
+// Write buffers are shared by all connections and only held while a message is being written,
+// instead of keeping a dedicated buffer alive for every connection.
+// Buffers of different sizes must not be mixed, hence a separate pool is kept for every write buffer size.
+var writeBufferPools sync.Map
+
+// writeBufferPool returns the pool shared by all connections with the given write buffer size.
+// A size of 0 stands for the default buffer size, as used by the server upgrader.
+func writeBufferPool(size int) *sync.Pool {
+	pool, _ := writeBufferPools.LoadOrStore(size, &sync.Pool{})
+	return pool.(*sync.Pool)
+}
+
 // Sets a custom Logger implementation, allowing the package to log events.
 // By default, a VoidLogger is used, so no logs will be sent to any output.
 //
+// Loggers not implementing the structured logging.Logger interface are wrapped via logging.FromBasic.
+// The package-level logger is used by all servers and clients, which weren't assigned a logger via their SetLogger method.
+//
 // The function panics, if a nil logger is passed.
-func SetLogger(logger logging.Logger) {
+func SetLogger(logger logging.BasicLogger) {
 	if logger == nil {
 		panic("cannot set a nil logger")
 	}
-	log = logger
+	log = logging.FromBasic(logger)
 }
 
 // Config contains optional configuration parameters for a websocket server.
@@ -117,18 +139,63 @@ type Channel interface {
 	TLSConnectionState() *tls.ConnectionState
 }
 
+// SubprotocolChannel is implemented by channels, which expose the subprotocol negotiated with the peer, such as WebSocket.
+//
+// It is not part of Channel, so that existing Channel implementations remain valid.
+// Callers should check for it with a type assertion, or use ChannelSubprotocol.
+type SubprotocolChannel interface {
+	// Subprotocol returns the subprotocol negotiated during the websocket handshake, if any.
+	Subprotocol() string
+}
+
+// ChannelSubprotocol returns the subprotocol negotiated by the channel.
+// An empty string is returned, if the channel doesn't implement SubprotocolChannel.
+func ChannelSubprotocol(channel Channel) string {
+	if c, ok := channel.(SubprotocolChannel); ok {
+		return c.Subprotocol()
+	}
+	return ""
+}
+
+// ContextChannel is implemented by channels, which expose a base context bound to the connection, such as WebSocket.
+//
+// It is not part of Channel, so that existing Channel implementations remain valid.
+// Callers should check for it with a type assertion, or use ChannelContext.
+type ContextChannel interface {
+	// Context returns the base context of the connection, which is canceled once the connection is closed.
+	// On servers, it carries the values attached by the ConnectionInterceptor, if any.
+	Context() context.Context
+}
+
+// ChannelContext returns the base context of the channel.
+// The background context is returned, if the channel doesn't implement ContextChannel.
+func ChannelContext(channel Channel) context.Context {
+	if c, ok := channel.(ContextChannel); ok {
+		return c.Context()
+	}
+	return context.Background()
+}
+
 // WebSocket is a wrapper for a single websocket channel.
 // The connection itself is provided by the gorilla websocket package.
 //
 // Don't use a websocket directly, but refer to WsServer and WsClient.
 type WebSocket struct {
+	writeAverage       int64 // rolling average write duration in nanoseconds, accessed atomically. Kept first for alignment.
+	slow               bool  // whether the average exceeded the slow client threshold, only accessed by the writePump.
 	connection         *websocket.Conn
 	id                 string
 	outQueue           chan []byte
 	closeC             chan websocket.CloseError // used to gracefully close a websocket connection.
 	forceCloseC        chan error                // used by the readPump to notify a forcefully closed connection to the writePump.
+	pingPeriodC        chan struct{}             // used to notify the writePump of a changed ping period.
 	pingMessage        chan []byte
+	readDone           chan struct{} // closed once the readPump of a client connection exited.
 	tlsConnectionState *tls.ConnectionState
+	subprotocol        string
+	ctx                context.Context
+	cancel             context.CancelFunc
+	logger             logging.Logger
 }
 
 // Retrieves the unique Identifier of the websocket (typically, the URL suffix).
@@ -146,22 +213,62 @@ func (websocket *WebSocket) TLSConnectionState() *tls.ConnectionState {
 	return websocket.tlsConnectionState
 }
 
-// ConnectionError is a websocket
+// Returns the subprotocol negotiated with the peer.
+func (websocket *WebSocket) Subprotocol() string {
+	return websocket.subprotocol
+}
+
+// Returns the base context of the connection, which is canceled once the connection is closed.
+func (websocket *WebSocket) Context() context.Context {
+	if websocket.ctx == nil {
+		return context.Background()
+	}
+	return websocket.ctx
+}
+
+// WriteAverage returns the rolling average duration of writing a data message to the connection.
+// Only measured on server connections, zero if nothing was written yet.
+func (websocket *WebSocket) WriteAverage() time.Duration {
+	return time.Duration(atomic.LoadInt64(&websocket.writeAverage))
+}
+
+// ---------------------- ERRORS ----------------------
+
+// The errors returned by this package are part of its public API: sentinel errors and error types are kept stable,
+// so callers may rely on errors.Is and errors.As, while the error messages may change at any time.
+
+// ErrNotConnected is returned when writing to, or stopping, a connection which is currently not open.
+var ErrNotConnected = errors.New("client is currently not connected, cannot send data")
+
+// HttpConnectionError is returned by a client, whenever the websocket handshake was rejected by the server.
+// The underlying error of the dialer (e.g. websocket.ErrBadHandshake) is accessible via errors.Is and errors.As.
 type HttpConnectionError struct {
 	Message    string
 	HttpStatus string
 	HttpCode   int
 	Details    string
+	Err        error
 }
 
 func (e HttpConnectionError) Error() string {
 	return fmt.Sprintf("%v, http status: %v", e.Message, e.HttpStatus)
 }
 
+func (e HttpConnectionError) Unwrap() error {
+	return e.Err
+}
+
 // ---------------------- SERVER ----------------------
 
 type CheckClientHandler func(id string, r *http.Request) bool
 
+// ConnectionInterceptor is invoked for every accepted client connection, after all client checks passed.
+// The returned context becomes the base context of the connection (refer to Channel.Context),
+// allowing to attach per-connection values, such as a tenant or authentication claims, derived from the HTTP upgrade request.
+//
+// The passed context is empty. Returning nil keeps the passed context.
+type ConnectionInterceptor func(ctx context.Context, id string, r *http.Request) context.Context
+
 // WsServer defines a websocket server, which passively listens for incoming connections on ws or wss protocol.
 // The offered API are of asynchronous nature, and each incoming connection/message is handled using callbacks.
 //
@@ -255,6 +362,28 @@ type WsServer interface {
 	Addr() *net.TCPAddr
 }
 
+// MetricsCollector receives measurements about the connections handled by a websocket server.
+//
+// Methods are invoked synchronously from the goroutines handling the connections,
+// hence implementations must be thread-safe and return quickly.
+type MetricsCollector interface {
+	// ConnectionOpened is invoked after a new client connection was accepted.
+	ConnectionOpened(id string)
+	// ConnectionClosed is invoked after a client connection was closed, for whatever reason.
+	ConnectionClosed(id string)
+	// MessageReceived is invoked for every data message read from a client connection.
+	MessageReceived(id string, size int)
+	// MessageSent is invoked for every data message successfully written to a client connection.
+	MessageSent(id string, size int)
+}
+
+// WriteDurationCollector may additionally be implemented by a MetricsCollector,
+// to receive the time it took to write each data message to a client connection.
+// Failed writes are not reported.
+type WriteDurationCollector interface {
+	MessageWriteDuration(id string, duration time.Duration)
+}
+
 // Default implementation of a Websocket server.
 //
 // Use the NewServer or NewTLSServer functions to create a new server.
@@ -263,6 +392,7 @@ type Server struct {
 	httpServer          *http.Server
 	messageHandler      func(ws Channel, data []byte) error
 	checkClientHandler  func(id string, r *http.Request) bool
+	interceptor         ConnectionInterceptor
 	newClientHandler    func(ws Channel)
 	disconnectedHandler func(ws Channel)
 	basicAuthHandler    func(username string, password string) bool
@@ -274,6 +404,15 @@ type Server struct {
 	connMutex           sync.RWMutex
 	addr                *net.TCPAddr
 	httpHandler         *mux.Router
+	logger              logging.Logger
+	metrics             MetricsCollector
+	bus                 *events.Bus
+	idMode              ChargePointIDMode
+	idBasePath          string
+	writeWaits          map[string]time.Duration
+	slowThreshold       time.Duration
+	slowClientHandler   func(id string, average time.Duration)
+	writeWaitMutex      sync.RWMutex
 }
 
 // Creates a new simple websocket server (the websockets are not secured).
@@ -282,7 +421,7 @@ func NewServer() *Server {
 	return &Server{
 		httpServer:    &http.Server{},
 		timeoutConfig: NewServerTimeoutConfig(),
-		upgrader:      websocket.Upgrader{Subprotocols: []string{}},
+		upgrader:      websocket.Upgrader{Subprotocols: []string{}, WriteBufferPool: writeBufferPool(0)},
 		httpHandler:   router,
 	}
 }
@@ -310,7 +449,7 @@ func NewTLSServer(certificatePath string, certificateKey string, tlsConfig *tls.
 			TLSConfig: tlsConfig,
 		},
 		timeoutConfig: NewServerTimeoutConfig(),
-		upgrader:      websocket.Upgrader{Subprotocols: []string{}},
+		upgrader:      websocket.Upgrader{Subprotocols: []string{}, WriteBufferPool: writeBufferPool(0)},
 		httpHandler:   router,
 	}
 }
@@ -353,8 +492,127 @@ func (server *Server) SetCheckOriginHandler(handler func(r *http.Request) bool)
 	server.upgrader.CheckOrigin = handler
 }
 
+// SetLogger sets the logger used by this server, overriding the package-level logger.
+// Log entries related to a connection are enriched with the ID of the connected client.
+// Passing nil restores the package-level logger.
+//
+// This function must be called before starting the server, otherwise it may lead to unexpected behavior.
+func (server *Server) SetLogger(logger logging.Logger) {
+	server.logger = logger
+}
+
+func (server *Server) getLogger() logging.Logger {
+	if server.logger != nil {
+		return server.logger
+	}
+	return log
+}
+
+// SetMetricsCollector sets a collector, receiving measurements about client connections and exchanged messages.
+// Passing nil disables metrics collection.
+//
+// This function must be called before starting the server, otherwise it may lead to unexpected behavior.
+func (server *Server) SetMetricsCollector(collector MetricsCollector) {
+	server.metrics = collector
+}
+
+// SetEventBus sets a bus, on which ConnectionUp and ConnectionDown events are published for every client.
+// Passing nil disables publishing.
+//
+// This function must be called before starting the server, otherwise it may lead to unexpected behavior.
+func (server *Server) SetEventBus(bus *events.Bus) {
+	server.bus = bus
+}
+
+// SetConnectionInterceptor sets a function, which derives the base context of every new client connection.
+// Passing nil removes the interceptor.
+//
+// This function must be called before starting the server, otherwise it may lead to unexpected behavior.
+func (server *Server) SetConnectionInterceptor(interceptor ConnectionInterceptor) {
+	server.interceptor = interceptor
+}
+
+// SetWriteWaitOverrides sets the write deadline for specific clients, by client ID,
+// overriding the WriteWait of the timeout configuration. Passing nil removes all overrides.
+//
+// Overrides apply to open connections as well, starting with the next write.
+func (server *Server) SetWriteWaitOverrides(overrides map[string]time.Duration) {
+	writeWaits := make(map[string]time.Duration, len(overrides))
+	for id, writeWait := range overrides {
+		writeWaits[id] = writeWait
+	}
+	server.writeWaitMutex.Lock()
+	defer server.writeWaitMutex.Unlock()
+	server.writeWaits = writeWaits
+}
+
+// Returns the write deadline for the next write to a client.
+func (server *Server) getWriteTimeout(id string) time.Time {
+	server.writeWaitMutex.RLock()
+	writeWait, ok := server.writeWaits[id]
+	server.writeWaitMutex.RUnlock()
+	if !ok {
+		writeWait = server.timeoutConfig.WriteWait
+	}
+	return time.Now().Add(writeWait)
+}
+
+// SetSlowClientHandler sets a handler, invoked whenever the average write duration of a connection
+// rises above the threshold. The handler is invoked again only after the average dropped below the threshold in between.
+// Passing a nil handler disables the notification.
+//
+// The handler is invoked from the goroutine writing to the connection, hence it must return quickly.
+// It may stop the connection via StopConnection.
+func (server *Server) SetSlowClientHandler(threshold time.Duration, handler func(id string, average time.Duration)) {
+	server.writeWaitMutex.Lock()
+	defer server.writeWaitMutex.Unlock()
+	server.slowThreshold = threshold
+	server.slowClientHandler = handler
+}
+
+// SlowClients returns the sorted IDs of all connected clients, whose average write duration is above the threshold.
+//
+// The average is a rolling average over the data messages written to a connection,
+// in which each new write weighs one eighth, so that a single slow write doesn't dominate a longer history.
+func (server *Server) SlowClients(threshold time.Duration) []string {
+	server.connMutex.RLock()
+	var ids []string
+	for id, ws := range server.connections {
+		if ws.WriteAverage() > threshold {
+			ids = append(ids, id)
+		}
+	}
+	server.connMutex.RUnlock()
+	sort.Strings(ids)
+	return ids
+}
+
+// Records the duration of a successful write to a client and notifies the slow client handler, if needed.
+func (server *Server) recordWrite(ws *WebSocket, duration time.Duration) {
+	average := duration
+	if previous := ws.WriteAverage(); previous > 0 {
+		average = previous + (duration-previous)/writeAverageWeight
+	}
+	atomic.StoreInt64(&ws.writeAverage, int64(average))
+	if collector, ok := server.metrics.(WriteDurationCollector); ok {
+		collector.MessageWriteDuration(ws.id, duration)
+	}
+	server.writeWaitMutex.RLock()
+	threshold, handler := server.slowThreshold, server.slowClientHandler
+	server.writeWaitMutex.RUnlock()
+	if handler == nil {
+		return
+	}
+	slow := average > threshold
+	if slow && !ws.slow {
+		ws.logger.Infof("average write duration to %s is %v, exceeding %v", ws.ID(), average, threshold)
+		handler(ws.id, average)
+	}
+	ws.slow = slow
+}
+
 func (server *Server) error(err error) {
-	log.Error(err)
+	server.getLogger().Error(err)
 	if server.errC != nil {
 		server.errC <- err
 	}
@@ -402,7 +660,7 @@ func (server *Server) Start(port int, listenPath string) {
 
 	defer ln.Close()
 
-	log.Infof("listening on tcp network %v", addr)
+	server.getLogger().Infof("listening on tcp network %v", addr)
 	server.httpServer.RegisterOnShutdown(server.stopConnections)
 	if server.tlsCertificatePath != "" && server.tlsCertificateKey != "" {
 		err = server.httpServer.ServeTLS(ln, server.tlsCertificatePath, server.tlsCertificateKey)
@@ -416,7 +674,7 @@ func (server *Server) Start(port int, listenPath string) {
 }
 
 func (server *Server) Stop() {
-	log.Info("stopping websocket server")
+	server.getLogger().Info("stopping websocket server")
 	err := server.httpServer.Shutdown(context.TODO())
 	if err != nil {
 		server.error(fmt.Errorf("shutdown failed: %w", err))
@@ -434,9 +692,9 @@ func (server *Server) StopConnection(id string, closeError websocket.CloseError)
 	server.connMutex.RUnlock()
 
 	if !ok {
-		return fmt.Errorf("couldn't stop websocket connection. No connection with id %s is open", id)
+		return fmt.Errorf("couldn't stop websocket connection %s: %w", id, ErrNotConnected)
 	}
-	log.Debugf("sending stop signal for websocket %s", ws.ID())
+	server.getLogger().Debugf("sending stop signal for websocket %s", ws.ID())
 	ws.closeC <- closeError
 	return nil
 }
@@ -454,18 +712,23 @@ func (server *Server) Write(webSocketId string, data []byte) error {
 	defer server.connMutex.RUnlock()
 	ws, ok := server.connections[webSocketId]
 	if !ok {
-		return fmt.Errorf("couldn't write to websocket. No socket with id %v is open", webSocketId)
+		return fmt.Errorf("couldn't write to websocket %v: %w", webSocketId, ErrNotConnected)
 	}
-	log.Debugf("queuing data for websocket %s", webSocketId)
+	server.getLogger().Debugf("queuing data for websocket %s", webSocketId)
 	ws.outQueue <- data
 	return nil
 }
 
 func (server *Server) wsHandler(w http.ResponseWriter, r *http.Request) {
 	responseHeader := http.Header{}
-	url := r.URL
-	id := path.Base(url.Path)
-	log.Debugf("handling new connection for %s from %s", id, r.RemoteAddr)
+	id, idSource, err := server.resolveChargePointID(r)
+	if err != nil {
+		server.error(err)
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	logger := server.getLogger().With(logging.ChargePointID(id))
+	logger.Debugf("handling new connection for %s from %s", id, r.RemoteAddr)
 	// Negotiate sub-protocol
 	clientSubprotocols := websocket.Subprotocols(r)
 	negotiatedSuprotocol := ""
@@ -509,6 +772,13 @@ out:
 			return
 		}
 	}
+	// The request context ends with the handler, hence the connection uses a detached one
+	baseCtx := context.WithValue(context.Background(), idSourceKey{}, idSource)
+	if server.interceptor != nil {
+		if ctx := server.interceptor(baseCtx, id, r); ctx != nil {
+			baseCtx = ctx
+		}
+	}
 
 	// Upgrade websocket
 	conn, err := server.upgrader.Upgrade(w, r, responseHeader)
@@ -517,7 +787,6 @@ out:
 		return
 	}
 
-	// The id of the charge point is the final path element
 	ws := WebSocket{
 		connection:         conn,
 		id:                 id,
@@ -526,8 +795,10 @@ out:
 		forceCloseC:        make(chan error, 1),
 		pingMessage:        make(chan []byte, 1),
 		tlsConnectionState: r.TLS,
+		subprotocol:        negotiatedSuprotocol,
+		logger:             logger,
 	}
-	log.Debugf("upgraded websocket connection for %s from %s", id, conn.RemoteAddr().String())
+	logger.Debugf("upgraded websocket connection for %s from %s", id, conn.RemoteAddr().String())
 	// If unsupported subprotocol, terminate the connection immediately
 	if negotiatedSuprotocol == "" {
 		server.error(fmt.Errorf("unsupported subprotocols %v for new client %v (%v)", clientSubprotocols, id, r.RemoteAddr))
@@ -550,8 +821,13 @@ out:
 		return
 	}
 	// Add new client
+	ws.ctx, ws.cancel = context.WithCancel(baseCtx)
 	server.connections[ws.id] = &ws
 	server.connMutex.Unlock()
+	if server.metrics != nil {
+		server.metrics.ConnectionOpened(ws.id)
+	}
+	server.bus.Publish(events.ConnectionUpEvent{Peer: ws.id})
 	// Read and write routines are started in separate goroutines and function will return immediately
 	go server.writePump(&ws)
 	go server.readPump(&ws)
@@ -572,7 +848,7 @@ func (server *Server) readPump(ws *WebSocket) {
 	conn := ws.connection
 
 	conn.SetPingHandler(func(appData string) error {
-		log.Debugf("ping received from %s", ws.ID())
+		ws.logger.Debugf("ping received from %s", ws.ID())
 		ws.pingMessage <- []byte(appData)
 		err := conn.SetReadDeadline(server.getReadTimeout())
 		return err
@@ -585,11 +861,14 @@ func (server *Server) readPump(ws *WebSocket) {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure, websocket.CloseNormalClosure) {
 				server.error(fmt.Errorf("read failed unexpectedly for %s: %w", ws.ID(), err))
 			}
-			log.Debugf("handling read error for %s: %v", ws.ID(), err.Error())
+			ws.logger.Debugf("handling read error for %s: %v", ws.ID(), err.Error())
 			// Notify writePump of error. Force close will be handled there
 			ws.forceCloseC <- err
 			return
 		}
+		if server.metrics != nil {
+			server.metrics.MessageReceived(ws.id, len(message))
+		}
 
 		if server.messageHandler != nil {
 			var channel Channel = ws
@@ -609,13 +888,14 @@ func (server *Server) writePump(ws *WebSocket) {
 	for {
 		select {
 		case data, ok := <-ws.outQueue:
-			_ = conn.SetWriteDeadline(time.Now().Add(server.timeoutConfig.WriteWait))
 			if !ok {
 				// Unexpected closed queue, should never happen
 				server.error(fmt.Errorf("output queue for socket %v was closed, forcefully closing", ws.id))
 				// Don't invoke cleanup
 				return
 			}
+			start := time.Now()
+			_ = conn.SetWriteDeadline(server.getWriteTimeout(ws.id))
 			// Send data
 			err := conn.WriteMessage(websocket.TextMessage, data)
 			if err != nil {
@@ -624,9 +904,13 @@ func (server *Server) writePump(ws *WebSocket) {
 				server.cleanupConnection(ws)
 				return
 			}
-			log.Debugf("written %d bytes to %s", len(data), ws.ID())
+			server.recordWrite(ws, time.Since(start))
+			ws.logger.Debugf("written %d bytes to %s", len(data), ws.ID())
+			if server.metrics != nil {
+				server.metrics.MessageSent(ws.id, len(data))
+			}
 		case ping := <-ws.pingMessage:
-			_ = conn.SetWriteDeadline(time.Now().Add(server.timeoutConfig.WriteWait))
+			_ = conn.SetWriteDeadline(server.getWriteTimeout(ws.id))
 			err := conn.WriteMessage(websocket.PongMessage, ping)
 			if err != nil {
 				server.error(fmt.Errorf("write failed for %s: %w", ws.ID(), err))
@@ -634,14 +918,14 @@ func (server *Server) writePump(ws *WebSocket) {
 				server.cleanupConnection(ws)
 				return
 			}
-			log.Debugf("pong sent to %s", ws.ID())
+			ws.logger.Debugf("pong sent to %s", ws.ID())
 		case closeErr := <-ws.closeC:
-			log.Debugf("closing connection to %s", ws.ID())
+			ws.logger.Debugf("closing connection to %s", ws.ID())
 			// Closing connection gracefully
 			if err := conn.WriteControl(
 				websocket.CloseMessage,
 				websocket.FormatCloseMessage(closeErr.Code, closeErr.Text),
-				time.Now().Add(server.timeoutConfig.WriteWait),
+				server.getWriteTimeout(ws.id),
 			); err != nil {
 				server.error(fmt.Errorf("failed to write close message for connection %s: %w", ws.id, err))
 			}
@@ -651,7 +935,7 @@ func (server *Server) writePump(ws *WebSocket) {
 		case closed, ok := <-ws.forceCloseC:
 			if !ok || closed != nil {
 				// Connection was forcefully closed, invoke cleanup
-				log.Debugf("handling forced close signal for %s", ws.ID())
+				ws.logger.Debugf("handling forced close signal for %s", ws.ID())
 				server.cleanupConnection(ws)
 			}
 			return
@@ -668,7 +952,12 @@ func (server *Server) cleanupConnection(ws *WebSocket) {
 	close(ws.closeC)
 	delete(server.connections, ws.id)
 	server.connMutex.Unlock()
-	log.Infof("closed connection to %s", ws.ID())
+	ws.cancel()
+	ws.logger.Infof("closed connection to %s", ws.ID())
+	if server.metrics != nil {
+		server.metrics.ConnectionClosed(ws.id)
+	}
+	server.bus.Publish(events.ConnectionDownEvent{Peer: ws.id})
 	if server.disconnectedHandler != nil {
 		server.disconnectedHandler(ws)
 	}
@@ -676,6 +965,48 @@ func (server *Server) cleanupConnection(ws *WebSocket) {
 
 // ---------------------- CLIENT ----------------------
 
+// Reconnector is implemented by websocket clients, which support aborting the current connection
+// in favor of the automatic reconnection mechanism, such as Client.
+//
+// It is not part of WsClient, so that existing WsClient implementations remain valid.
+// Callers should check for it with a type assertion.
+type Reconnector interface {
+	// Aborts the current connection to the server without a closing handshake and starts the automatic reconnection mechanism.
+	// The DisconnectedHandler is invoked with the passed reason, exactly as for an unexpected disconnection.
+	//
+	// If the client is not currently connected, the call has no effect.
+	ForceReconnect(reason error)
+}
+
+// PingPeriodSetter is implemented by websocket clients, which support changing the ping period of a live connection,
+// such as Client.
+//
+// It is not part of WsClient, so that existing WsClient implementations remain valid.
+// Callers should check for it with a type assertion.
+type PingPeriodSetter interface {
+	// Changes the interval at which pings are sent to the server. Passing 0 disables client pings.
+	//
+	// Unlike SetTimeoutConfig, the function may be invoked at any time: the new period is applied to the current connection
+	// right away, without reconnecting. If the configured PongWait doesn't exceed the new period,
+	// it is extended accordingly. While pings are disabled, no read deadline is enforced on the connection.
+	SetPingPeriod(period time.Duration)
+}
+
+// FailoverClient is implemented by websocket clients, which support reconnecting to alternative URLs, such as Client.
+//
+// It is not part of WsClient, so that existing WsClient implementations remain valid.
+// Callers should check for it with a type assertion.
+type FailoverClient interface {
+	// Sets a function returning the ordered list of URLs to connect to, when reconnecting after a connection loss.
+	// The function is invoked before every reconnection attempt, and each attempt uses the next URL in the list,
+	// starting from the first one. This allows to fail over to alternative servers, and to pick up reconfigured URLs.
+	//
+	// If no function is set, or it returns an empty list, the client reconnects to the URL it was last connected to.
+	//
+	// This function must be called before connecting to the server, otherwise it may lead to unexpected behavior.
+	SetFailoverURLs(provider func() []string)
+}
+
 // WsClient defines a websocket client, needed to connect to a websocket server.
 // The offered API are of asynchronous nature, and each incoming message is handled using callbacks.
 //
@@ -781,7 +1112,7 @@ type WsClient interface {
 //
 // Use the NewClient or NewTLSClient functions to create a new client.
 type Client struct {
-	webSocket      WebSocket
+	webSocket      *WebSocket
 	url            url.URL
 	messageHandler func(data []byte) error
 	dialOptions    []func(*websocket.Dialer)
@@ -790,9 +1121,13 @@ type Client struct {
 	connected      bool
 	onDisconnected func(err error)
 	onReconnected  func()
+	failoverURLs   func() []string
 	mutex          sync.Mutex
 	errC           chan error
 	reconnectC     chan struct{} // used for signaling, that a reconnection attempt should be interrupted
+	logger         logging.Logger
+	clock          clock.Clock
+	bus            *events.Bus
 }
 
 // Creates a new simple websocket client (the channel is not secured).
@@ -808,6 +1143,7 @@ func NewClient() *Client {
 		dialOptions:   []func(*websocket.Dialer){},
 		timeoutConfig: NewClientTimeoutConfig(),
 		header:        http.Header{},
+		clock:         clock.New(),
 	}
 }
 
@@ -831,7 +1167,7 @@ func NewClient() *Client {
 //
 //	InsecureSkipVerify: true
 func NewTLSClient(tlsConfig *tls.Config) *Client {
-	client := &Client{dialOptions: []func(*websocket.Dialer){}, timeoutConfig: NewClientTimeoutConfig(), header: http.Header{}}
+	client := &Client{dialOptions: []func(*websocket.Dialer){}, timeoutConfig: NewClientTimeoutConfig(), header: http.Header{}, clock: clock.New()}
 	client.dialOptions = append(client.dialOptions, func(dialer *websocket.Dialer) {
 		dialer.TLSClientConfig = tlsConfig
 	})
@@ -846,6 +1182,23 @@ func (client *Client) SetTimeoutConfig(config ClientTimeoutConfig) {
 	client.timeoutConfig = config
 }
 
+func (client *Client) SetPingPeriod(period time.Duration) {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.timeoutConfig.PingPeriod = period
+	if period > 0 && client.timeoutConfig.PongWait > 0 && client.timeoutConfig.PongWait <= period {
+		client.timeoutConfig.PongWait = (period * 10) / 9
+	}
+	if !client.connected {
+		return
+	}
+	// Notify the write pump, so the new period is applied to the current connection
+	select {
+	case client.webSocket.pingPeriodC <- struct{}{}:
+	default:
+	}
+}
+
 func (client *Client) SetDisconnectedHandler(handler func(err error)) {
 	client.onDisconnected = handler
 }
@@ -854,6 +1207,10 @@ func (client *Client) SetReconnectedHandler(handler func()) {
 	client.onReconnected = handler
 }
 
+func (client *Client) SetFailoverURLs(provider func() []string) {
+	client.failoverURLs = provider
+}
+
 func (client *Client) AddOption(option interface{}) {
 	dialOption, ok := option.(func(*websocket.Dialer))
 	if ok {
@@ -885,20 +1242,74 @@ func (client *Client) SetHeaderValue(key string, value string) {
 	client.header.Set(key, value)
 }
 
+// SetLogger sets the logger used by this client, overriding the package-level logger.
+// Passing nil restores the package-level logger.
+//
+// This function must be called before starting the client, otherwise it may lead to unexpected behavior.
+func (client *Client) SetLogger(logger logging.Logger) {
+	client.logger = logger
+}
+
+func (client *Client) getLogger() logging.Logger {
+	if client.logger != nil {
+		return client.logger
+	}
+	return log
+}
+
+// SetClock sets the clock used for sending periodic pings and for the back-off delays between reconnection attempts.
+// Passing nil restores the real clock.
+//
+// Read and write deadlines are enforced by the network connection and always refer to the real time.
+//
+// This function must be called before starting the client, otherwise it may lead to unexpected behavior.
+func (client *Client) SetClock(c clock.Clock) {
+	client.clock = clock.OrDefault(c)
+}
+
+// SetEventBus sets a bus, on which ConnectionUp and ConnectionDown events are published, including for reconnections.
+// Passing nil disables publishing.
+//
+// This function must be called before starting the client, otherwise it may lead to unexpected behavior.
+func (client *Client) SetEventBus(bus *events.Bus) {
+	client.bus = bus
+}
+
 func (client *Client) getReadTimeout() time.Time {
-	if client.timeoutConfig.PongWait == 0 {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if client.timeoutConfig.PongWait == 0 || client.timeoutConfig.PingPeriod == 0 {
 		return time.Time{}
 	}
 	return time.Now().Add(client.timeoutConfig.PongWait)
 }
 
-func (client *Client) writePump() {
-	ticker := time.NewTicker(client.timeoutConfig.PingPeriod)
-	conn := client.webSocket.connection
+func (client *Client) getPingPeriod() time.Duration {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	return client.timeoutConfig.PingPeriod
+}
+
+// Creates a ticker for sending periodic pings. If pings are disabled, no ticker is returned.
+func (client *Client) newPingTicker() (clock.Ticker, <-chan time.Time) {
+	period := client.getPingPeriod()
+	if period <= 0 {
+		return nil, nil
+	}
+	ticker := client.clock.NewTicker(period)
+	return ticker, ticker.C()
+}
+
+func (client *Client) writePump(ws *WebSocket) {
+	ticker, tickerC := client.newPingTicker()
+	conn := ws.connection
 	// Closure function correctly closes the current connection
 	closure := func(err error) {
-		ticker.Stop()
-		client.cleanup()
+		if ticker != nil {
+			ticker.Stop()
+		}
+		client.cleanup(ws)
+		client.bus.Publish(events.ConnectionDownEvent{Err: err})
 		// Invoke callback
 		if client.onDisconnected != nil {
 			client.onDisconnected(err)
@@ -907,9 +1318,9 @@ func (client *Client) writePump() {
 
 	for {
 		select {
-		case data := <-client.webSocket.outQueue:
+		case data := <-ws.outQueue:
 			// Send data
-			log.Debugf("sending data")
+			client.getLogger().Debugf("sending data")
 			_ = conn.SetWriteDeadline(time.Now().Add(client.timeoutConfig.WriteWait))
 			err := conn.WriteMessage(websocket.TextMessage, data)
 			if err != nil {
@@ -918,8 +1329,16 @@ func (client *Client) writePump() {
 				client.handleReconnection()
 				return
 			}
-			log.Debugf("written %d bytes", len(data))
-		case <-ticker.C:
+			client.getLogger().Debugf("written %d bytes", len(data))
+		case <-ws.pingPeriodC:
+			// Apply new ping period to the current connection
+			if ticker != nil {
+				ticker.Stop()
+			}
+			ticker, tickerC = client.newPingTicker()
+			_ = conn.SetReadDeadline(client.getReadTimeout())
+			client.getLogger().Debugf("ping period changed to %v", client.getPingPeriod())
+		case <-tickerC:
 			// Send periodic ping
 			_ = conn.SetWriteDeadline(time.Now().Add(client.timeoutConfig.WriteWait))
 			if err := conn.WriteMessage(websocket.PingMessage, []byte{}); err != nil {
@@ -928,9 +1347,9 @@ func (client *Client) writePump() {
 				client.handleReconnection()
 				return
 			}
-			log.Debugf("ping sent")
-		case closeErr := <-client.webSocket.closeC:
-			log.Debugf("closing connection")
+			client.getLogger().Debugf("ping sent")
+		case closeErr := <-ws.closeC:
+			client.getLogger().Debugf("closing connection")
 			// Closing connection gracefully
 			if err := conn.WriteControl(
 				websocket.CloseMessage,
@@ -943,8 +1362,8 @@ func (client *Client) writePump() {
 			// Passing nil will also not call onDisconnected.
 			closure(nil)
 			return
-		case closed, ok := <-client.webSocket.forceCloseC:
-			log.Debugf("handling forced close signal")
+		case closed, ok := <-ws.forceCloseC:
+			client.getLogger().Debugf("handling forced close signal")
 			// Read pump sent a forceClose signal (reading failed -> aborting the connection)
 			if !ok || closed != nil {
 				closure(closed)
@@ -955,11 +1374,12 @@ func (client *Client) writePump() {
 	}
 }
 
-func (client *Client) readPump() {
-	conn := client.webSocket.connection
+func (client *Client) readPump(ws *WebSocket) {
+	defer close(ws.readDone)
+	conn := ws.connection
 	_ = conn.SetReadDeadline(client.getReadTimeout())
 	conn.SetPongHandler(func(string) error {
-		log.Debugf("pong received")
+		client.getLogger().Debugf("pong received")
 		return conn.SetReadDeadline(client.getReadTimeout())
 	})
 	for {
@@ -968,12 +1388,15 @@ func (client *Client) readPump() {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure, websocket.CloseNormalClosure) {
 				client.error(fmt.Errorf("read failed: %w", err))
 			}
-			// Notify writePump of error. Forced close will be handled there
-			client.webSocket.forceCloseC <- err
+			// Notify writePump of error. Forced close will be handled there, unless a forced close is already pending
+			select {
+			case ws.forceCloseC <- err:
+			default:
+			}
 			return
 		}
 
-		log.Debugf("received %v bytes", len(message))
+		client.getLogger().Debugf("received %v bytes", len(message))
 		if client.messageHandler != nil {
 			err = client.messageHandler(message)
 			if err != nil {
@@ -986,33 +1409,41 @@ func (client *Client) readPump() {
 
 // Frees internal resources after a websocket connection was signaled to be closed.
 // From this moment onwards, no new messages may be sent.
-func (client *Client) cleanup() {
+func (client *Client) cleanup(ws *WebSocket) {
 	client.setConnected(false)
-	ws := client.webSocket
 	_ = ws.connection.Close()
 	client.mutex.Lock()
 	defer client.mutex.Unlock()
 	close(ws.outQueue)
 	close(ws.closeC)
+	ws.cancel()
 }
 
 func (client *Client) handleReconnection() {
-	log.Info("started automatic reconnection handler")
+	client.getLogger().Info("started automatic reconnection handler")
 	delay := client.timeoutConfig.RetryBackOffWaitMinimum + time.Duration(rand.Intn(client.timeoutConfig.RetryBackOffRandomRange+1))*time.Second
 	reconnectionAttempts := 1
 	for {
 		// Wait before reconnecting
+		timer := client.clock.NewTimer(delay)
 		select {
-		case <-time.After(delay):
+		case <-timer.C():
 		case <-client.reconnectC:
+			timer.Stop()
 			return
 		}
 
-		log.Info("reconnecting... attempt", reconnectionAttempts)
-		err := client.Start(client.url.String())
+		client.getLogger().Info("reconnecting... attempt", reconnectionAttempts)
+		urlStr := client.url.String()
+		if client.failoverURLs != nil {
+			if candidates := client.failoverURLs(); len(candidates) > 0 {
+				urlStr = candidates[(reconnectionAttempts-1)%len(candidates)]
+			}
+		}
+		err := client.Start(urlStr)
 		if err == nil {
 			// Re-connection was successful
-			log.Info("reconnected successfully to server")
+			client.getLogger().Info("reconnected successfully to server")
 			if client.onReconnected != nil {
 				client.onReconnected()
 			}
@@ -1042,28 +1473,39 @@ func (client *Client) IsConnected() bool {
 }
 
 func (client *Client) Write(data []byte) error {
-	if !client.IsConnected() {
-		return fmt.Errorf("client is currently not connected, cannot send data")
+	client.mutex.Lock()
+	connected, ws := client.connected, client.webSocket
+	client.mutex.Unlock()
+	if !connected {
+		return ErrNotConnected
 	}
-	log.Debugf("queuing data for server")
-	client.webSocket.outQueue <- data
+	client.getLogger().Debugf("queuing data for server")
+	ws.outQueue <- data
 	return nil
 }
 
 func (client *Client) StartWithRetries(urlStr string) {
 	err := client.Start(urlStr)
 	if err != nil {
-		log.Info("Connection error:", err)
+		client.getLogger().Info("Connection error:", err)
 		client.handleReconnection()
 	}
 }
 
 func (client *Client) Start(urlStr string) error {
 	url, err := url.Parse(urlStr)
-	client.url = *url
 	if err != nil {
 		return err
 	}
+	client.url = *url
+	// The read pump of a previous connection may still be running after the connection was closed.
+	// Wait for it to exit, so it can't signal a stale read error to the new connection.
+	client.mutex.Lock()
+	previous, connected := client.webSocket, client.connected
+	client.mutex.Unlock()
+	if previous != nil && !connected {
+		<-previous.readDone
+	}
 
 	dialer := websocket.Dialer{
 		ReadBufferSize:   1024,
@@ -1074,12 +1516,16 @@ func (client *Client) Start(urlStr string) error {
 	for _, option := range client.dialOptions {
 		option(&dialer)
 	}
+	if dialer.WriteBufferPool == nil {
+		// Set after the dial options, which may change the write buffer size
+		dialer.WriteBufferPool = writeBufferPool(dialer.WriteBufferSize)
+	}
 	// Connect
-	log.Info("connecting to server")
+	client.getLogger().Info("connecting to server")
 	ws, resp, err := dialer.Dial(urlStr, client.header)
 	if err != nil {
 		if resp != nil {
-			httpError := HttpConnectionError{Message: err.Error(), HttpStatus: resp.Status, HttpCode: resp.StatusCode}
+			httpError := HttpConnectionError{Message: err.Error(), HttpStatus: resp.Status, HttpCode: resp.StatusCode, Err: err}
 			// Parse http response details
 			defer resp.Body.Close()
 			body, _ := io.ReadAll(resp.Body)
@@ -1094,25 +1540,34 @@ func (client *Client) Start(urlStr string) error {
 	// The id of the charge point is the final path element
 	id := path.Base(url.Path)
 
-	client.webSocket = WebSocket{
+	ctx, cancel := context.WithCancel(context.Background())
+	webSocket := &WebSocket{
 		connection:         ws,
 		id:                 id,
 		outQueue:           make(chan []byte, 1),
 		closeC:             make(chan websocket.CloseError, 1),
 		forceCloseC:        make(chan error, 1),
+		pingPeriodC:        make(chan struct{}, 1),
+		readDone:           make(chan struct{}),
 		tlsConnectionState: resp.TLS,
+		ctx:                ctx,
+		cancel:             cancel,
 	}
-	log.Infof("connected to server as %s", id)
+	client.getLogger().Infof("connected to server as %s", id)
 	client.reconnectC = make(chan struct{})
-	client.setConnected(true)
+	client.mutex.Lock()
+	client.webSocket = webSocket
+	client.connected = true
+	client.mutex.Unlock()
+	client.bus.Publish(events.ConnectionUpEvent{})
 	// Start reader and write routine
-	go client.writePump()
-	go client.readPump()
+	go client.writePump(webSocket)
+	go client.readPump(webSocket)
 	return nil
 }
 
 func (client *Client) Stop() {
-	log.Infof("closing connection to server")
+	client.getLogger().Infof("closing connection to server")
 	client.mutex.Lock()
 	if client.connected {
 		client.connected = false
@@ -1134,8 +1589,25 @@ func (client *Client) Stop() {
 	// Wait for connection to actually close
 }
 
+func (client *Client) ForceReconnect(reason error) {
+	if reason == nil {
+		reason = fmt.Errorf("forced reconnection")
+	}
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if !client.connected {
+		return
+	}
+	client.getLogger().Infof("forcing reconnection to server: %v", reason)
+	// The write pump will close the connection and handle the reconnection
+	select {
+	case client.webSocket.forceCloseC <- reason:
+	default:
+	}
+}
+
 func (client *Client) error(err error) {
-	log.Error(err)
+	client.getLogger().Error(err)
 	if client.errC != nil {
 		client.errC <- err
 	}
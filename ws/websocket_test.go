@@ -2,6 +2,7 @@ package ws
 
 import (
 	"bytes"
+	"context"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
@@ -9,6 +10,7 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"math/big"
 	"net"
@@ -17,6 +19,7 @@ import (
 	"os"
 	"path"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -24,6 +27,8 @@ import (
 
 	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/lorenzodonini/ocpp-go/clock/clocktest"
 )
 
 const (
@@ -166,23 +171,211 @@ func TestWebsocketEcho(t *testing.T) {
 	wsServer.Stop()
 }
 
-func TestWebsocketBootRetries(t *testing.T) {
-	verifyConnection := func(client *Client, connected bool) {
-		maxAttempts := 20
-		for i := 0; i <= maxAttempts; i++ {
-			if client.IsConnected() != connected {
-				time.Sleep(time.Duration(2) * time.Second)
-				continue
+type metricsEvent struct {
+	event string
+	id    string
+	size  int
+}
+
+type mockMetricsCollector struct {
+	events []metricsEvent
+	mutex  sync.Mutex
+}
+
+func (c *mockMetricsCollector) record(event string, id string, size int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.events = append(c.events, metricsEvent{event: event, id: id, size: size})
+}
+
+func (c *mockMetricsCollector) ConnectionOpened(id string) { c.record("opened", id, 0) }
+func (c *mockMetricsCollector) ConnectionClosed(id string) { c.record("closed", id, 0) }
+func (c *mockMetricsCollector) MessageReceived(id string, size int) {
+	c.record("received", id, size)
+}
+func (c *mockMetricsCollector) MessageSent(id string, size int) { c.record("sent", id, size) }
+
+func TestServerMetricsCollector(t *testing.T) {
+	message := []byte("Hello WebSocket!")
+	disconnectedC := make(chan struct{}, 1)
+	wsServer := newWebsocketServer(t, func(data []byte) ([]byte, error) {
+		return data, nil
+	})
+	collector := &mockMetricsCollector{}
+	wsServer.SetMetricsCollector(collector)
+	wsServer.SetDisconnectedClientHandler(func(ws Channel) {
+		disconnectedC <- struct{}{}
+	})
+	echoC := make(chan struct{}, 1)
+	wsClient := newWebsocketClient(t, func(data []byte) ([]byte, error) {
+		echoC <- struct{}{}
+		return nil, nil
+	})
+	go wsServer.Start(serverPort, serverPath)
+	time.Sleep(100 * time.Millisecond)
+	host := fmt.Sprintf("localhost:%v", serverPort)
+	u := url.URL{Scheme: "ws", Host: host, Path: testPath}
+	err := wsClient.Start(u.String())
+	require.NoError(t, err)
+	err = wsClient.Write(message)
+	require.NoError(t, err)
+	select {
+	case <-echoC:
+	case <-time.After(time.Second):
+		require.Fail(t, "echo not received")
+	}
+	wsClient.Stop()
+	select {
+	case <-disconnectedC:
+	case <-time.After(time.Second):
+		require.Fail(t, "client not disconnected")
+	}
+	id := path.Base(testPath)
+	collector.mutex.Lock()
+	assert.Equal(t, []metricsEvent{
+		{event: "opened", id: id},
+		{event: "received", id: id, size: len(message)},
+		{event: "sent", id: id, size: len(message)},
+		{event: "closed", id: id},
+	}, collector.events)
+	collector.mutex.Unlock()
+	wsServer.Stop()
+}
+
+type writeDurationCollector struct {
+	mockMetricsCollector
+	durations map[string][]time.Duration
+}
+
+func (c *writeDurationCollector) MessageWriteDuration(id string, duration time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.durations[id] = append(c.durations[id], duration)
+}
+
+// slowConn simulates a station on a congested link, reading at most 16 KB every millisecond.
+type slowConn struct {
+	net.Conn
+}
+
+func (c *slowConn) Read(b []byte) (int, error) {
+	time.Sleep(time.Millisecond)
+	if len(b) > 16*1024 {
+		b = b[:16*1024]
+	}
+	return c.Conn.Read(b)
+}
+
+// Creates a client, whose connection is slowed down. The small receive buffer lets writes to the client block earlier.
+func newSlowWebsocketClient(t *testing.T, onMessage func(data []byte) ([]byte, error)) *Client {
+	wsClient := newWebsocketClient(t, onMessage)
+	wsClient.AddOption(func(dialer *websocket.Dialer) {
+		dialer.NetDialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+			if err != nil {
+				return nil, err
 			}
+			_ = conn.(*net.TCPConn).SetReadBuffer(64 * 1024)
+			return &slowConn{Conn: conn}, nil
 		}
-		assert.Equal(t, connected, client.IsConnected())
+	})
+	return wsClient
+}
+
+func TestServerSlowClients(t *testing.T) {
+	threshold := 20 * time.Millisecond
+	wsServer := newWebsocketServer(t, nil)
+	collector := &writeDurationCollector{durations: map[string][]time.Duration{}}
+	wsServer.SetMetricsCollector(collector)
+	slowC := make(chan string, 1)
+	wsServer.SetSlowClientHandler(threshold, func(id string, average time.Duration) {
+		assert.Greater(t, average, threshold)
+		slowC <- id
+	})
+	go wsServer.Start(serverPort, serverPath)
+	time.Sleep(100 * time.Millisecond)
+	receivedC := make(chan int, 2)
+	onMessage := func(data []byte) ([]byte, error) {
+		receivedC <- len(data)
+		return nil, nil
+	}
+	fastClient := newWebsocketClient(t, onMessage)
+	slowClient := newSlowWebsocketClient(t, onMessage)
+	for id, client := range map[string]*Client{"fast": fastClient, "slow": slowClient} {
+		u := url.URL{Scheme: "ws", Host: fmt.Sprintf("localhost:%v", serverPort), Path: "/ws/" + id}
+		require.NoError(t, client.Start(u.String()))
 	}
+	time.Sleep(100 * time.Millisecond)
+	// Small messages are written quickly to both clients
+	for _, id := range []string{"fast", "slow"} {
+		require.NoError(t, wsServer.Write(id, []byte("hello")))
+		assert.Equal(t, 5, <-receivedC)
+	}
+	assert.Empty(t, wsServer.SlowClients(threshold))
+	// A message exceeding the socket buffers takes long to be written to the slow client
+	message := bytes.Repeat([]byte("a"), 8*1024*1024)
+	require.NoError(t, wsServer.Write("fast", message))
+	require.NoError(t, wsServer.Write("slow", message))
+	for i := 0; i < 2; i++ {
+		select {
+		case size := <-receivedC:
+			assert.Equal(t, len(message), size)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "message not received")
+		}
+	}
+	select {
+	case id := <-slowC:
+		assert.Equal(t, "slow", id)
+	case <-time.After(time.Second):
+		require.Fail(t, "slow client not detected")
+	}
+	assert.Equal(t, []string{"slow"}, wsServer.SlowClients(threshold))
+	assert.Empty(t, slowC)
+	collector.mutex.Lock()
+	assert.Len(t, collector.durations["fast"], 2)
+	assert.Len(t, collector.durations["slow"], 2)
+	collector.mutex.Unlock()
+	fastClient.Stop()
+	slowClient.Stop()
+	wsServer.Stop()
+}
+
+func TestServerWriteWaitOverrides(t *testing.T) {
+	wsServer := newWebsocketServer(t, nil)
+	wsServer.SetWriteWaitOverrides(map[string]time.Duration{"slow": 50 * time.Millisecond})
+	disconnectedC := make(chan string, 1)
+	wsServer.SetDisconnectedClientHandler(func(ws Channel) {
+		disconnectedC <- ws.ID()
+	})
+	go wsServer.Start(serverPort, serverPath)
+	time.Sleep(100 * time.Millisecond)
+	slowClient := newSlowWebsocketClient(t, nil)
+	u := url.URL{Scheme: "ws", Host: fmt.Sprintf("localhost:%v", serverPort), Path: "/ws/slow"}
+	require.NoError(t, slowClient.Start(u.String()))
+	time.Sleep(100 * time.Millisecond)
+	// The write exceeds the overridden deadline, closing the connection
+	require.NoError(t, wsServer.Write("slow", bytes.Repeat([]byte("a"), 8*1024*1024)))
+	select {
+	case id := <-disconnectedC:
+		assert.Equal(t, "slow", id)
+	case <-time.After(2 * time.Second):
+		require.Fail(t, "slow client not disconnected")
+	}
+	slowClient.Stop()
+	wsServer.Stop()
+}
+
+func TestWebsocketBootRetries(t *testing.T) {
 	wsServer := newWebsocketServer(t, func(data []byte) ([]byte, error) {
 		return data, nil
 	})
 	wsClient := newWebsocketClient(t, func(data []byte) ([]byte, error) {
 		return nil, nil
 	})
+	// Back-off delays are driven by a fake clock
+	fakeClock := clocktest.NewFakeClock(time.Now())
+	wsClient.SetClock(fakeClock)
 
 	go func() {
 		// Start websocket client
@@ -191,15 +384,21 @@ func TestWebsocketBootRetries(t *testing.T) {
 		wsClient.StartWithRetries(u.String())
 	}()
 
-	assert.Equal(t, wsClient.IsConnected(), false)
-
-	time.Sleep(time.Duration(3) * time.Second)
+	// Wait for the first back-off timer, after the initial connection attempt failed
+	fakeClock.BlockUntil(1)
+	assert.False(t, wsClient.IsConnected())
 
 	go wsServer.Start(serverPort, serverPath)
-	verifyConnection(wsClient, true)
+	// Keep advancing past the back-off delay, until the client reconnected
+	assert.Eventually(t, func() bool {
+		fakeClock.Advance(time.Hour)
+		return wsClient.IsConnected()
+	}, 5*time.Second, 50*time.Millisecond)
 
 	wsServer.Stop()
-	verifyConnection(wsClient, false)
+	assert.Eventually(t, func() bool {
+		return !wsClient.IsConnected()
+	}, 5*time.Second, 50*time.Millisecond)
 
 	wsServer.Stop()
 	wsClient.Stop()
@@ -474,7 +673,10 @@ func TestWebsocketClientConnectionBreak(t *testing.T) {
 	go func() {
 		timer := time.NewTimer(1 * time.Second)
 		<-timer.C
-		err := wsClient.webSocket.connection.Close()
+		wsClient.mutex.Lock()
+		conn := wsClient.webSocket.connection
+		wsClient.mutex.Unlock()
+		err := conn.Close()
 		assert.Nil(t, err)
 	}()
 	err := wsClient.Start(u.String())
@@ -516,6 +718,195 @@ func TestWebsocketServerConnectionBreak(t *testing.T) {
 	wsServer.Stop()
 }
 
+func TestWriteBufferPoolPerSize(t *testing.T) {
+	server := NewServer()
+	assert.Same(t, writeBufferPool(0), server.upgrader.WriteBufferPool)
+	assert.Same(t, writeBufferPool(1024), writeBufferPool(1024))
+	assert.NotSame(t, writeBufferPool(0), writeBufferPool(1024))
+	assert.NotSame(t, writeBufferPool(1024), writeBufferPool(2048))
+}
+
+var _ SubprotocolChannel = (*WebSocket)(nil)
+
+// basicChannel implements only the methods required by the Channel interface.
+type basicChannel struct {
+	Channel
+}
+
+func TestChannelSubprotocol(t *testing.T) {
+	assert.Equal(t, "ocpp1.6", ChannelSubprotocol(&WebSocket{subprotocol: "ocpp1.6"}))
+	assert.Equal(t, "", ChannelSubprotocol(basicChannel{}))
+}
+
+var _ ContextChannel = (*WebSocket)(nil)
+
+func TestChannelContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.Equal(t, ctx, ChannelContext(&WebSocket{ctx: ctx}))
+	assert.Equal(t, context.Background(), ChannelContext(basicChannel{}))
+}
+
+var _ Reconnector = (*Client)(nil)
+var _ PingPeriodSetter = (*Client)(nil)
+var _ FailoverClient = (*Client)(nil)
+
+func TestWebsocketClientForceReconnect(t *testing.T) {
+	newClient := make(chan bool, 2)
+	disconnected := make(chan error, 1)
+	reconnected := make(chan bool, 1)
+	wsServer := newWebsocketServer(t, nil)
+	wsServer.SetNewClientHandler(func(ws Channel) {
+		newClient <- true
+	})
+	go wsServer.Start(serverPort, serverPath)
+	time.Sleep(1 * time.Second)
+
+	// Test
+	wsClient := newWebsocketClient(t, nil)
+	wsClient.SetTimeoutConfig(ClientTimeoutConfig{
+		WriteWait:               defaultWriteWait,
+		HandshakeTimeout:        defaultHandshakeTimeout,
+		PongWait:                defaultPongWait,
+		PingPeriod:              defaultPingPeriod,
+		RetryBackOffRepeatTimes: 1,
+		RetryBackOffRandomRange: 0,
+		RetryBackOffWaitMinimum: 100 * time.Millisecond,
+	})
+	wsClient.SetDisconnectedHandler(func(err error) {
+		disconnected <- err
+	})
+	wsClient.SetReconnectedHandler(func() {
+		reconnected <- true
+	})
+	host := fmt.Sprintf("localhost:%v", serverPort)
+	u := url.URL{Scheme: "ws", Host: host, Path: testPath}
+	err := wsClient.Start(u.String())
+	require.NoError(t, err)
+	result := <-newClient
+	assert.True(t, result)
+	// Force reconnection
+	reason := fmt.Errorf("no response")
+	wsClient.ForceReconnect(reason)
+	err = <-disconnected
+	assert.Equal(t, reason, err)
+	result = <-reconnected
+	assert.True(t, result)
+	assert.True(t, wsClient.IsConnected())
+	result = <-newClient
+	assert.True(t, result)
+	// Cleanup
+	wsClient.Stop()
+	wsServer.Stop()
+}
+
+func TestWebsocketClientForceReconnectKeepsNewConnection(t *testing.T) {
+	newClient := make(chan bool, 2)
+	disconnected := make(chan error, 2)
+	reconnected := make(chan bool, 1)
+	echoed := make(chan []byte, 1)
+	wsServer := newWebsocketServer(t, func(data []byte) ([]byte, error) {
+		return data, nil
+	})
+	wsServer.SetNewClientHandler(func(ws Channel) {
+		newClient <- true
+	})
+	go wsServer.Start(serverPort, serverPath)
+	time.Sleep(1 * time.Second)
+
+	// Test
+	wsClient := newWebsocketClient(t, func(data []byte) ([]byte, error) {
+		echoed <- data
+		return nil, nil
+	})
+	wsClient.SetTimeoutConfig(ClientTimeoutConfig{
+		WriteWait:               defaultWriteWait,
+		HandshakeTimeout:        defaultHandshakeTimeout,
+		PongWait:                defaultPongWait,
+		PingPeriod:              defaultPingPeriod,
+		RetryBackOffRepeatTimes: 1,
+		RetryBackOffRandomRange: 0,
+		RetryBackOffWaitMinimum: time.Millisecond,
+	})
+	wsClient.SetDisconnectedHandler(func(err error) {
+		disconnected <- err
+	})
+	wsClient.SetReconnectedHandler(func() {
+		reconnected <- true
+	})
+	host := fmt.Sprintf("localhost:%v", serverPort)
+	u := url.URL{Scheme: "ws", Host: host, Path: testPath}
+	err := wsClient.Start(u.String())
+	require.NoError(t, err)
+	require.True(t, <-newClient)
+	// Reconnect right away, while the read pump of the old connection is failing
+	reason := fmt.Errorf("no response")
+	wsClient.ForceReconnect(reason)
+	assert.Equal(t, reason, <-disconnected)
+	require.True(t, <-reconnected)
+	require.True(t, <-newClient)
+	// The read error of the old connection must not tear down the new one
+	select {
+	case err = <-disconnected:
+		require.Fail(t, "new connection was closed", err)
+	case <-time.After(500 * time.Millisecond):
+	}
+	assert.True(t, wsClient.IsConnected())
+	message := []byte("hello")
+	require.NoError(t, wsClient.Write(message))
+	assert.Equal(t, message, <-echoed)
+	// Cleanup
+	wsClient.Stop()
+	wsServer.Stop()
+}
+
+func TestWebsocketClientFailoverURLs(t *testing.T) {
+	newClient := make(chan string, 2)
+	reconnected := make(chan bool, 1)
+	wsServer := newWebsocketServer(t, nil)
+	wsServer.SetNewClientHandler(func(ws Channel) {
+		newClient <- ws.ID()
+	})
+	go wsServer.Start(serverPort, serverPath)
+	time.Sleep(1 * time.Second)
+
+	// Test
+	wsClient := newWebsocketClient(t, nil)
+	wsClient.SetTimeoutConfig(ClientTimeoutConfig{
+		WriteWait:               defaultWriteWait,
+		HandshakeTimeout:        defaultHandshakeTimeout,
+		PongWait:                defaultPongWait,
+		PingPeriod:              defaultPingPeriod,
+		RetryBackOffRepeatTimes: 1,
+		RetryBackOffRandomRange: 0,
+		RetryBackOffWaitMinimum: 100 * time.Millisecond,
+	})
+	wsClient.SetReconnectedHandler(func() {
+		reconnected <- true
+	})
+	host := fmt.Sprintf("localhost:%v", serverPort)
+	// The first candidate is unreachable, so the client fails over to the second one
+	unreachable := url.URL{Scheme: "ws", Host: "localhost:1", Path: "/ws/unreachable"}
+	failover := url.URL{Scheme: "ws", Host: host, Path: "/ws/failover"}
+	wsClient.SetFailoverURLs(func() []string {
+		return []string{unreachable.String(), failover.String()}
+	})
+	u := url.URL{Scheme: "ws", Host: host, Path: testPath}
+	err := wsClient.Start(u.String())
+	require.NoError(t, err)
+	assert.Equal(t, "testws", <-newClient)
+	wsClient.ForceReconnect(nil)
+	select {
+	case <-reconnected:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "client didn't reconnect")
+	}
+	assert.Equal(t, "failover", <-newClient)
+	// Cleanup
+	wsClient.Stop()
+	wsServer.Stop()
+}
+
 func TestValidBasicAuth(t *testing.T) {
 	authUsername := "testUsername"
 	authPassword := "testPassword"
@@ -628,6 +1019,68 @@ func TestInvalidBasicAuth(t *testing.T) {
 	wsServer.Stop()
 }
 
+func TestChargePointIDFromBasicAuth(t *testing.T) {
+	type connection struct {
+		id     string
+		source ChargePointIDSource
+	}
+	wsServer := newWebsocketServer(t, nil)
+	wsServer.SetChargePointIDMode(ChargePointIDFromBasicAuth, "/ws")
+	connected := make(chan connection, 1)
+	wsServer.SetNewClientHandler(func(ws Channel) {
+		source, _ := ChargePointIDSourceFromContext(ChannelContext(ws))
+		connected <- connection{id: ws.ID(), source: source}
+	})
+	disconnected := make(chan string, 1)
+	wsServer.SetDisconnectedClientHandler(func(ws Channel) {
+		disconnected <- ws.ID()
+	})
+	go wsServer.Start(serverPort, "/ws/{id:.*}")
+	time.Sleep(500 * time.Millisecond)
+
+	host := fmt.Sprintf("localhost:%v", serverPort)
+	testCases := []struct {
+		name     string
+		path     string
+		username string
+		expected connection
+	}{
+		{"path only", "/ws/cp1", "", connection{"cp1", ChargePointIDSourcePath}},
+		{"basic auth only", "/ws/", "cp2", connection{"cp2", ChargePointIDSourceBasicAuth}},
+		{"conflicting", "/ws/cp1", "cp2", connection{"cp1", ChargePointIDSourcePath}},
+		{"invalid path", "/ws/cp%20invalid", "cp3", connection{"cp3", ChargePointIDSourceBasicAuth}},
+	}
+	for _, tc := range testCases {
+		wsClient := newWebsocketClient(t, nil)
+		if tc.username != "" {
+			wsClient.SetBasicAuth(tc.username, "password")
+		}
+		u := url.URL{Scheme: "ws", Host: host, Path: tc.path}
+		err := wsClient.Start(u.String())
+		require.NoError(t, err, tc.name)
+		result := <-connected
+		assert.Equal(t, tc.expected, result, tc.name)
+		wsClient.Stop()
+		// Wait for the server to drop the connection, before reusing the ID
+		assert.Equal(t, result.id, <-disconnected, tc.name)
+	}
+	// Neither source yields a valid ID
+	for _, username := range []string{"", "invalid id", strings.Repeat("a", 49)} {
+		wsClient := newWebsocketClient(t, nil)
+		if username != "" {
+			wsClient.SetBasicAuth(username, "password")
+		}
+		u := url.URL{Scheme: "ws", Host: host, Path: "/ws/"}
+		err := wsClient.Start(u.String())
+		require.Error(t, err)
+		httpErr, ok := err.(HttpConnectionError)
+		require.True(t, ok)
+		assert.Equal(t, http.StatusBadRequest, httpErr.HttpCode)
+	}
+	// Cleanup
+	wsServer.Stop()
+}
+
 func TestInvalidOriginHeader(t *testing.T) {
 	wsServer := newWebsocketServer(t, func(data []byte) ([]byte, error) {
 		assert.Fail(t, "no message should be received from client!")
@@ -748,6 +1201,43 @@ func TestCustomCheckClientHandler(t *testing.T) {
 	wsServer.Stop()
 }
 
+type tenantKey struct{}
+
+func TestConnectionContext(t *testing.T) {
+	connected := make(chan Channel, 1)
+	wsServer := newWebsocketServer(t, func(data []byte) ([]byte, error) {
+		return nil, nil
+	})
+	wsServer.SetConnectionInterceptor(func(ctx context.Context, id string, r *http.Request) context.Context {
+		return context.WithValue(ctx, tenantKey{}, r.Header.Get("X-Tenant"))
+	})
+	wsServer.SetNewClientHandler(func(ws Channel) {
+		connected <- ws
+	})
+	go wsServer.Start(serverPort, serverPath)
+	time.Sleep(500 * time.Millisecond)
+	defer wsServer.Stop()
+
+	wsClient := newWebsocketClient(t, func(data []byte) ([]byte, error) {
+		return nil, nil
+	})
+	wsClient.SetHeaderValue("X-Tenant", "tenant1")
+	u := url.URL{Scheme: "ws", Host: fmt.Sprintf("localhost:%v", serverPort), Path: testPath}
+	require.NoError(t, wsClient.Start(u.String()))
+	channel := <-connected
+	ctx := ChannelContext(channel)
+	assert.Equal(t, "tenant1", ctx.Value(tenantKey{}))
+	require.NoError(t, ctx.Err())
+	// The context of the connection ends with the connection
+	wsClient.Stop()
+	select {
+	case <-ctx.Done():
+		assert.ErrorIs(t, ctx.Err(), context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("connection context wasn't canceled on disconnect")
+	}
+}
+
 func TestValidClientTLSCertificate(t *testing.T) {
 	// Create self-signed TLS certificate
 	clientCertFilename := "/tmp/client.pem"
@@ -1238,3 +1728,29 @@ func createTLSCertificate(certificateFilename string, keyFilename string, cn str
 	}
 	return nil
 }
+
+func TestErrorTypes(t *testing.T) {
+	wsServer := newWebsocketServer(t, nil)
+	wsServer.SetNewClientHandler(func(ws Channel) {
+		assert.Fail(t, "no new connection should be received from client!")
+	})
+	go wsServer.Start(serverPort, serverPath)
+	time.Sleep(200 * time.Millisecond)
+	defer wsServer.Stop()
+	wsClient := newWebsocketClient(t, nil)
+	// Not connected
+	err := wsClient.Write([]byte("dummy message"))
+	assert.True(t, errors.Is(err, ErrNotConnected))
+	err = wsServer.Write("fakeId", []byte("dummy message"))
+	assert.True(t, errors.Is(err, ErrNotConnected))
+	err = wsServer.StopConnection("fakeId", websocket.CloseError{Code: websocket.CloseNormalClosure})
+	assert.True(t, errors.Is(err, ErrNotConnected))
+	// Rejected handshake
+	wsClient.SetHeaderValue("Origin", "example.org")
+	u := url.URL{Scheme: "ws", Host: fmt.Sprintf("localhost:%v", serverPort), Path: testPath}
+	err = wsClient.Start(u.String())
+	var httpErr HttpConnectionError
+	require.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, http.StatusForbidden, httpErr.HttpCode)
+	assert.True(t, errors.Is(err, websocket.ErrBadHandshake))
+}
@@ -0,0 +1,306 @@
+package wstest
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/lorenzodonini/ocpp-go/clock"
+	"github.com/lorenzodonini/ocpp-go/events"
+	"github.com/lorenzodonini/ocpp-go/ws"
+)
+
+// The default interval between reconnection attempts of in-memory clients.
+const defaultReconnectInterval = 50 * time.Millisecond
+
+// Client is an in-memory implementation of the ws.WsClient interface, connecting to a Server.
+//
+// The client behaves like ws.Client, including the automatic reconnection after an unexpected disconnection.
+// To keep tests deterministic, reconnection attempts are made at the fixed interval RetryBackOffWaitMinimum
+// of the timeout configuration (50ms by default), without random delays or exponential backoff.
+// Pings, dial options and TLS have no effect.
+type Client struct {
+	server         *Server
+	conn           *Conn
+	url            url.URL
+	connected      bool
+	stopped        bool
+	messageHandler func(data []byte) error
+	onDisconnected func(err error)
+	onReconnected  func()
+	failoverURLs   func() []string
+	timeoutConfig  ws.ClientTimeoutConfig
+	header         http.Header
+	subProtocols   []string
+	reconnectC     chan struct{}
+	errC           chan error
+	clock          clock.Clock
+	bus            *events.Bus
+	mutex          sync.Mutex
+}
+
+// NewClient creates a new in-memory client, which connects to the passed server.
+func NewClient(server *Server) *Client {
+	timeoutConfig := ws.NewClientTimeoutConfig()
+	timeoutConfig.RetryBackOffWaitMinimum = defaultReconnectInterval
+	timeoutConfig.RetryBackOffRandomRange = 0
+	return &Client{
+		server:        server,
+		timeoutConfig: timeoutConfig,
+		header:        http.Header{},
+		clock:         clock.New(),
+	}
+}
+
+// Connection returns the client end of the current pipe to the server, or nil if the client is not connected.
+// Conditions set on it apply to messages sent to the server.
+func (c *Client) Connection() *Conn {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.conn
+}
+
+func (c *Client) Start(urlStr string) error {
+	c.mutex.Lock()
+	c.stopped = false
+	c.reconnectC = make(chan struct{})
+	c.mutex.Unlock()
+	return c.connect(urlStr)
+}
+
+func (c *Client) StartWithRetries(urlStr string) {
+	if err := c.Start(urlStr); err != nil {
+		c.handleReconnection()
+	}
+}
+
+func (c *Client) Stop() {
+	c.mutex.Lock()
+	conn := c.conn
+	c.connected = false
+	c.stopped = true
+	if c.reconnectC != nil {
+		close(c.reconnectC)
+		c.reconnectC = nil
+	}
+	errC := c.errC
+	c.errC = nil
+	c.mutex.Unlock()
+	if conn != nil {
+		conn.Close(&websocket.CloseError{Code: websocket.CloseNormalClosure})
+	}
+	if errC != nil {
+		close(errC)
+	}
+}
+
+func (c *Client) ForceReconnect(reason error) {
+	if reason == nil {
+		reason = fmt.Errorf("forced reconnection")
+	}
+	c.mutex.Lock()
+	conn := c.conn
+	connected := c.connected
+	c.mutex.Unlock()
+	if !connected {
+		return
+	}
+	conn.Close(reason)
+}
+
+func (c *Client) Errors() <-chan error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.errC == nil {
+		c.errC = make(chan error, 1)
+	}
+	return c.errC
+}
+
+func (c *Client) SetMessageHandler(handler func(data []byte) error) {
+	c.messageHandler = handler
+}
+
+func (c *Client) SetTimeoutConfig(config ws.ClientTimeoutConfig) {
+	c.timeoutConfig = config
+}
+
+func (c *Client) SetPingPeriod(period time.Duration) {
+}
+
+func (c *Client) SetDisconnectedHandler(handler func(err error)) {
+	c.onDisconnected = handler
+}
+
+func (c *Client) SetReconnectedHandler(handler func()) {
+	c.onReconnected = handler
+}
+
+func (c *Client) SetFailoverURLs(provider func() []string) {
+	c.failoverURLs = provider
+}
+
+// SetClock sets the clock used for the interval between reconnection attempts. Passing nil restores the real clock.
+func (c *Client) SetClock(clk clock.Clock) {
+	c.clock = clock.OrDefault(clk)
+}
+
+// SetEventBus sets a bus, on which ConnectionUp and ConnectionDown events are published, like ws.Client.SetEventBus.
+func (c *Client) SetEventBus(bus *events.Bus) {
+	c.bus = bus
+}
+
+func (c *Client) IsConnected() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.connected
+}
+
+func (c *Client) Write(data []byte) error {
+	c.mutex.Lock()
+	conn := c.conn
+	connected := c.connected
+	c.mutex.Unlock()
+	if !connected {
+		return ws.ErrNotConnected
+	}
+	return conn.Write(data)
+}
+
+func (c *Client) AddOption(option interface{}) {
+}
+
+func (c *Client) SetRequestedSubProtocol(subProto string) {
+	for _, sub := range c.subProtocols {
+		if sub == subProto {
+			return
+		}
+	}
+	c.subProtocols = append(c.subProtocols, subProto)
+}
+
+func (c *Client) SetBasicAuth(username string, password string) {
+	c.header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(username+":"+password)))
+}
+
+func (c *Client) SetHeaderValue(key string, value string) {
+	c.header.Set(key, value)
+}
+
+func (c *Client) error(err error) {
+	c.mutex.Lock()
+	errC := c.errC
+	c.mutex.Unlock()
+	if errC != nil {
+		errC <- err
+	}
+}
+
+// Connects to the server, by performing the equivalent of a websocket handshake.
+func (c *Client) connect(urlStr string) error {
+	u, err := url.Parse(urlStr)
+	if err != nil {
+		return err
+	}
+	r, err := http.NewRequest(http.MethodGet, urlStr, nil)
+	if err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	c.url = *u
+	c.mutex.Unlock()
+	r.Header = c.header.Clone()
+	if len(c.subProtocols) > 0 {
+		r.Header.Set("Sec-Websocket-Protocol", strings.Join(c.subProtocols, ", "))
+	}
+	// The id of the charge point is the final path element
+	id := path.Base(u.Path)
+	_, err = c.server.connect(id, r, func(conn *Conn) {
+		conn.SetMessageHandler(func(data []byte) {
+			if c.messageHandler == nil {
+				return
+			}
+			if err := c.messageHandler(data); err != nil {
+				c.error(fmt.Errorf("handle failed: %w", err))
+			}
+		})
+		conn.SetCloseHandler(func(err error) {
+			c.handleClose(conn, err)
+		})
+		c.mutex.Lock()
+		c.conn = conn
+		c.connected = true
+		c.mutex.Unlock()
+	})
+	if err == nil {
+		c.bus.Publish(events.ConnectionUpEvent{})
+	}
+	return err
+}
+
+// Invoked whenever a pipe of the client was closed.
+func (c *Client) handleClose(conn *Conn, err error) {
+	c.mutex.Lock()
+	if c.conn != conn {
+		c.mutex.Unlock()
+		return
+	}
+	c.conn = nil
+	c.connected = false
+	stopped := c.stopped
+	c.mutex.Unlock()
+	if stopped {
+		// Disconnected by user command, no reconnection
+		c.bus.Publish(events.ConnectionDownEvent{})
+		if c.onDisconnected != nil {
+			c.onDisconnected(nil)
+		}
+		return
+	}
+	c.bus.Publish(events.ConnectionDownEvent{Err: err})
+	if c.onDisconnected != nil {
+		c.onDisconnected(err)
+	}
+	c.handleReconnection()
+}
+
+func (c *Client) handleReconnection() {
+	c.mutex.Lock()
+	reconnectC := c.reconnectC
+	c.mutex.Unlock()
+	if reconnectC == nil {
+		return
+	}
+	for attempt := 0; ; attempt++ {
+		timer := c.clock.NewTimer(c.timeoutConfig.RetryBackOffWaitMinimum)
+		select {
+		case <-timer.C():
+		case <-reconnectC:
+			timer.Stop()
+			return
+		}
+		c.mutex.Lock()
+		urlStr := c.url.String()
+		c.mutex.Unlock()
+		if c.failoverURLs != nil {
+			if candidates := c.failoverURLs(); len(candidates) > 0 {
+				urlStr = candidates[attempt%len(candidates)]
+			}
+		}
+		err := c.connect(urlStr)
+		if err == nil {
+			if c.onReconnected != nil {
+				c.onReconnected()
+			}
+			return
+		}
+		c.error(fmt.Errorf("reconnection failed: %w", err))
+	}
+}
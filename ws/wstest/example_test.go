@@ -0,0 +1,80 @@
+package wstest_test
+
+import (
+	"fmt"
+	"time"
+
+	ocpp16 "github.com/lorenzodonini/ocpp-go/ocpp1.6"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+	"github.com/lorenzodonini/ocpp-go/ws/wstest"
+)
+
+// Minimal central system handler, accepting boot notifications and heartbeats.
+type coreHandler struct {
+	onHeartbeat func(chargePointId string)
+}
+
+func (h *coreHandler) OnAuthorize(chargePointId string, request *core.AuthorizeRequest) (*core.AuthorizeConfirmation, error) {
+	return core.NewAuthorizationConfirmation(types.NewIdTagInfo(types.AuthorizationStatusAccepted)), nil
+}
+
+func (h *coreHandler) OnBootNotification(chargePointId string, request *core.BootNotificationRequest) (*core.BootNotificationConfirmation, error) {
+	return core.NewBootNotificationConfirmation(types.NewDateTime(time.Now()), 300, core.RegistrationStatusAccepted), nil
+}
+
+func (h *coreHandler) OnDataTransfer(chargePointId string, request *core.DataTransferRequest) (*core.DataTransferConfirmation, error) {
+	return core.NewDataTransferConfirmation(core.DataTransferStatusRejected), nil
+}
+
+func (h *coreHandler) OnHeartbeat(chargePointId string, request *core.HeartbeatRequest) (*core.HeartbeatConfirmation, error) {
+	if h.onHeartbeat != nil {
+		h.onHeartbeat(chargePointId)
+	}
+	return core.NewHeartbeatConfirmation(types.NewDateTime(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))), nil
+}
+
+func (h *coreHandler) OnMeterValues(chargePointId string, request *core.MeterValuesRequest) (*core.MeterValuesConfirmation, error) {
+	return core.NewMeterValuesConfirmation(), nil
+}
+
+func (h *coreHandler) OnStatusNotification(chargePointId string, request *core.StatusNotificationRequest) (*core.StatusNotificationConfirmation, error) {
+	return core.NewStatusNotificationConfirmation(), nil
+}
+
+func (h *coreHandler) OnStartTransaction(chargePointId string, request *core.StartTransactionRequest) (*core.StartTransactionConfirmation, error) {
+	return core.NewStartTransactionConfirmation(types.NewIdTagInfo(types.AuthorizationStatusAccepted), 1), nil
+}
+
+func (h *coreHandler) OnStopTransaction(chargePointId string, request *core.StopTransactionRequest) (*core.StopTransactionConfirmation, error) {
+	return core.NewStopTransactionConfirmation(), nil
+}
+
+func ExampleConnectInMemory() {
+	server := wstest.NewServer()
+	centralSystem := ocpp16.NewCentralSystem(nil, server)
+	centralSystem.SetCoreHandler(&coreHandler{})
+	chargePoint := ocpp16.NewChargePoint("cp0001", nil, wstest.NewClient(server))
+	if err := wstest.ConnectInMemory(server, centralSystem, chargePoint); err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer centralSystem.Stop()
+	defer chargePoint.Stop()
+
+	boot, err := chargePoint.BootNotification("model", "vendor")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(boot.Status, boot.Interval)
+	heartbeat, err := chargePoint.Heartbeat()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(heartbeat.CurrentTime.UTC().Format(time.RFC3339))
+	// Output:
+	// Accepted 300
+	// 2024-01-01T12:00:00Z
+}
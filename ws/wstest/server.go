@@ -0,0 +1,303 @@
+package wstest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/lorenzodonini/ocpp-go/events"
+	"github.com/lorenzodonini/ocpp-go/ws"
+)
+
+// Server is an in-memory implementation of the ws.WsServer interface.
+//
+// Clients created via NewClient connect to the server as long as it is running.
+// Basic authentication, client checks and subprotocol negotiation behave like in ws.Server,
+// while timeouts, origin checks and TLS have no effect.
+type Server struct {
+	connections               map[string]*Conn
+	running                   bool
+	refuse                    bool
+	readyC                    chan struct{}
+	stopC                     chan struct{}
+	addr                      *net.TCPAddr
+	messageHandler            func(channel ws.Channel, data []byte) error
+	newClientHandler          func(channel ws.Channel)
+	disconnectedClientHandler func(channel ws.Channel)
+	checkClientHandler        func(id string, r *http.Request) bool
+	interceptor               ws.ConnectionInterceptor
+	basicAuthHandler          func(username string, password string) bool
+	subProtocols              []string
+	timeoutConfig             ws.ServerTimeoutConfig
+	bus                       *events.Bus
+	errC                      chan error
+	mutex                     sync.RWMutex
+}
+
+// NewServer creates a new in-memory server. The server accepts connections after Start was invoked.
+func NewServer() *Server {
+	return &Server{
+		connections:   map[string]*Conn{},
+		readyC:        make(chan struct{}),
+		timeoutConfig: ws.NewServerTimeoutConfig(),
+	}
+}
+
+// Ready returns a channel, which is closed once the server was started.
+func (s *Server) Ready() <-chan struct{} {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.readyC
+}
+
+// Start runs the server until Stop is invoked. The port and listen path are ignored.
+func (s *Server) Start(port int, listenPath string) {
+	s.mutex.Lock()
+	if s.running {
+		s.mutex.Unlock()
+		s.error(fmt.Errorf("in-memory server already running"))
+		return
+	}
+	s.running = true
+	s.connections = map[string]*Conn{}
+	s.stopC = make(chan struct{})
+	s.addr = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+	stopC := s.stopC
+	close(s.readyC)
+	s.mutex.Unlock()
+	<-stopC
+}
+
+// Stop closes all connections with a normal closure and lets the previously called Start function return.
+func (s *Server) Stop() {
+	s.mutex.Lock()
+	if !s.running {
+		s.mutex.Unlock()
+		return
+	}
+	s.running = false
+	close(s.stopC)
+	s.readyC = make(chan struct{})
+	connections := s.connections
+	s.connections = map[string]*Conn{}
+	errC := s.errC
+	s.errC = nil
+	s.mutex.Unlock()
+	for _, conn := range connections {
+		conn.Close(&websocket.CloseError{Code: websocket.CloseNormalClosure})
+	}
+	if errC != nil {
+		close(errC)
+	}
+}
+
+func (s *Server) StopConnection(id string, closeError websocket.CloseError) error {
+	conn, ok := s.Connection(id)
+	if !ok {
+		return fmt.Errorf("couldn't stop websocket connection %s: %w", id, ws.ErrNotConnected)
+	}
+	conn.Close(&closeError)
+	return nil
+}
+
+// Disconnect aborts the connection of a client without a closing handshake, as in case of a network failure.
+// The client is notified with the passed reason and starts its reconnection mechanism.
+func (s *Server) Disconnect(id string, reason error) error {
+	conn, ok := s.Connection(id)
+	if !ok {
+		return fmt.Errorf("couldn't disconnect client %s: %w", id, ws.ErrNotConnected)
+	}
+	if reason == nil {
+		reason = fmt.Errorf("connection aborted")
+	}
+	conn.Close(reason)
+	return nil
+}
+
+// RefuseConnections lets all following connection attempts fail, until it is invoked with false.
+// Existing connections are unaffected.
+func (s *Server) RefuseConnections(refuse bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.refuse = refuse
+}
+
+// Connection returns the server end of the pipe to the client with the given ID.
+// Conditions set on it apply to messages sent to the client.
+func (s *Server) Connection(id string) (*Conn, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	conn, ok := s.connections[id]
+	return conn, ok
+}
+
+func (s *Server) Errors() <-chan error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.errC == nil {
+		s.errC = make(chan error, 1)
+	}
+	return s.errC
+}
+
+func (s *Server) SetMessageHandler(handler func(channel ws.Channel, data []byte) error) {
+	s.messageHandler = handler
+}
+
+func (s *Server) SetNewClientHandler(handler func(channel ws.Channel)) {
+	s.newClientHandler = handler
+}
+
+func (s *Server) SetDisconnectedClientHandler(handler func(channel ws.Channel)) {
+	s.disconnectedClientHandler = handler
+}
+
+func (s *Server) SetTimeoutConfig(config ws.ServerTimeoutConfig) {
+	s.timeoutConfig = config
+}
+
+func (s *Server) Write(webSocketId string, data []byte) error {
+	conn, ok := s.Connection(webSocketId)
+	if !ok {
+		return fmt.Errorf("couldn't write to websocket %v: %w", webSocketId, ws.ErrNotConnected)
+	}
+	return conn.Write(data)
+}
+
+func (s *Server) AddSupportedSubprotocol(subProto string) {
+	for _, sub := range s.subProtocols {
+		if sub == subProto {
+			return
+		}
+	}
+	s.subProtocols = append(s.subProtocols, subProto)
+}
+
+func (s *Server) SetBasicAuthHandler(handler func(username string, password string) bool) {
+	s.basicAuthHandler = handler
+}
+
+func (s *Server) SetCheckOriginHandler(handler func(r *http.Request) bool) {
+}
+
+func (s *Server) SetCheckClientHandler(handler func(id string, r *http.Request) bool) {
+	s.checkClientHandler = handler
+}
+
+// SetConnectionInterceptor sets a function, which derives the base context of every new client connection,
+// like ws.Server.SetConnectionInterceptor.
+func (s *Server) SetConnectionInterceptor(interceptor ws.ConnectionInterceptor) {
+	s.interceptor = interceptor
+}
+
+// SetEventBus sets a bus, on which ConnectionUp and ConnectionDown events are published, like ws.Server.SetEventBus.
+func (s *Server) SetEventBus(bus *events.Bus) {
+	s.bus = bus
+}
+
+func (s *Server) Addr() *net.TCPAddr {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.addr
+}
+
+func (s *Server) error(err error) {
+	s.mutex.RLock()
+	errC := s.errC
+	s.mutex.RUnlock()
+	if errC != nil {
+		errC <- err
+	}
+}
+
+func (s *Server) negotiateSubProtocol(requested []string) string {
+	for _, requestedProto := range requested {
+		if len(s.subProtocols) == 0 {
+			return requestedProto
+		}
+		for _, supportedProto := range s.subProtocols {
+			if requestedProto == supportedProto {
+				return requestedProto
+			}
+		}
+	}
+	return ""
+}
+
+// Performs the equivalent of a websocket handshake for a new client and returns the client end of a new pipe.
+// The setup function is invoked on the client end, before the server is notified of the new client.
+func (s *Server) connect(id string, r *http.Request, setup func(conn *Conn)) (*Conn, error) {
+	s.mutex.RLock()
+	running, refuse := s.running, s.refuse
+	s.mutex.RUnlock()
+	if !running || refuse {
+		return nil, fmt.Errorf("connection to in-memory server refused")
+	}
+	if s.basicAuthHandler != nil {
+		username, password, ok := r.BasicAuth()
+		if ok {
+			ok = s.basicAuthHandler(username, password)
+		}
+		if !ok {
+			s.error(fmt.Errorf("basic auth failed: credentials invalid"))
+			return nil, ws.HttpConnectionError{Message: "websocket: bad handshake", HttpStatus: "401 Unauthorized", HttpCode: http.StatusUnauthorized, Err: websocket.ErrBadHandshake}
+		}
+	}
+	if s.checkClientHandler != nil && !s.checkClientHandler(id, r) {
+		s.error(fmt.Errorf("client validation: invalid client"))
+		return nil, ws.HttpConnectionError{Message: "websocket: bad handshake", HttpStatus: "401 Unauthorized", HttpCode: http.StatusUnauthorized, Err: websocket.ErrBadHandshake}
+	}
+	requested := websocket.Subprotocols(r)
+	subprotocol := s.negotiateSubProtocol(requested)
+	if subprotocol == "" {
+		s.error(fmt.Errorf("unsupported subprotocols %v for new client %v", requested, id))
+		return nil, fmt.Errorf("invalid or unsupported subprotocol")
+	}
+	clientConn, serverConn := NewPipe(id)
+	clientConn.subprotocol = subprotocol
+	serverConn.subprotocol = subprotocol
+	if s.interceptor != nil {
+		if ctx := s.interceptor(context.Background(), id, r); ctx != nil {
+			serverConn.cancel()
+			serverConn.ctx, serverConn.cancel = context.WithCancel(ctx)
+		}
+	}
+	s.mutex.Lock()
+	if _, exists := s.connections[id]; exists {
+		s.mutex.Unlock()
+		clientConn.Close(nil)
+		s.error(fmt.Errorf("client %s already exists, closing duplicate client", id))
+		return nil, fmt.Errorf("a connection with ID %s already exists", id)
+	}
+	s.connections[id] = serverConn
+	s.mutex.Unlock()
+	setup(clientConn)
+	serverConn.SetMessageHandler(func(data []byte) {
+		if s.messageHandler == nil {
+			return
+		}
+		if err := s.messageHandler(serverConn, data); err != nil {
+			s.error(fmt.Errorf("handling failed for %s: %w", id, err))
+		}
+	})
+	serverConn.SetCloseHandler(func(err error) {
+		s.mutex.Lock()
+		if s.connections[id] == serverConn {
+			delete(s.connections, id)
+		}
+		s.mutex.Unlock()
+		s.bus.Publish(events.ConnectionDownEvent{Peer: id})
+		if s.disconnectedClientHandler != nil {
+			s.disconnectedClientHandler(serverConn)
+		}
+	})
+	s.bus.Publish(events.ConnectionUpEvent{Peer: id})
+	if s.newClientHandler != nil {
+		s.newClientHandler(serverConn)
+	}
+	return clientConn, nil
+}
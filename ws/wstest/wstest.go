@@ -0,0 +1,263 @@
+// Package wstest provides in-memory implementations of the ws.WsServer and ws.WsClient interfaces,
+// for testing OCPP endpoints without opening network sockets.
+//
+// Clients are bound to a server on creation, and connect to it through an in-process pipe.
+// The ocppj and version layers run on top of them unchanged:
+//
+//	server := wstest.NewServer()
+//	centralSystem := ocpp16.NewCentralSystem(nil, server)
+//	chargePoint := ocpp16.NewChargePoint("cp0001", nil, wstest.NewClient(server))
+//	err := wstest.ConnectInMemory(server, centralSystem, chargePoint)
+//
+// Every connection may be degraded by setting Conditions on either end of its pipe (refer to Server.Connection and Client.Connection),
+// and may be terminated at any time via Server.Disconnect or Client.ForceReconnect, to test reconnection logic deterministically.
+package wstest
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// URL is the server URL to pass to in-memory clients. Any URL is accepted, since only the final path element,
+// i.e. the ID of the client, is relevant.
+const URL = "ws://in-memory"
+
+// CentralSystem is the subset of the ocpp16.CentralSystem and ocpp2.CSMS interfaces, required for starting a server endpoint.
+type CentralSystem interface {
+	Start(listenPort int, listenPath string)
+}
+
+// ChargePoint is the subset of the ocpp16.ChargePoint and ocpp2.ChargingStation interfaces, required for connecting a client endpoint.
+type ChargePoint interface {
+	Start(url string) error
+}
+
+// ConnectInMemory starts the central system in the background, unless the server is already running,
+// and connects all charge points to it.
+//
+// The central system must have been created with the passed server, and the charge points with clients of the same server.
+func ConnectInMemory(server *Server, centralSystem CentralSystem, chargePoints ...ChargePoint) error {
+	select {
+	case <-server.Ready():
+	default:
+		go centralSystem.Start(0, "/{ws}")
+		select {
+		case <-server.Ready():
+		case <-time.After(time.Second):
+			return fmt.Errorf("central system didn't start the in-memory server")
+		}
+	}
+	for _, chargePoint := range chargePoints {
+		if err := chargePoint.Start(URL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Conditions control the delivery of the messages written to one end of a pipe.
+type Conditions struct {
+	// Latency delays the delivery of every message. Messages are delivered in order regardless.
+	Latency time.Duration
+	// Drop is invoked for every written message. If it returns true, the message is silently discarded.
+	Drop func(data []byte) bool
+}
+
+// Address of an in-memory pipe end.
+type pipeAddr string
+
+func (a pipeAddr) Network() string {
+	return "memory"
+}
+
+func (a pipeAddr) String() string {
+	return string(a)
+}
+
+// Shared state of both ends of a pipe.
+type pipe struct {
+	closeOnce sync.Once
+	closedC   chan struct{}
+}
+
+type envelope struct {
+	data      []byte
+	deliverAt time.Time
+}
+
+// Conn is one end of an in-memory pipe and implements the ws.Channel interface.
+//
+// Messages written to one end are delivered sequentially to the message handler of the other end,
+// in a dedicated goroutine. Messages received before a message handler was set are held back.
+type Conn struct {
+	id             string
+	remoteAddr     net.Addr
+	subprotocol    string
+	ctx            context.Context
+	cancel         context.CancelFunc
+	pipe           *pipe
+	peer           *Conn
+	conditions     Conditions
+	inbox          []envelope
+	notifyC        chan struct{}
+	messageHandler func(data []byte)
+	closeHandler   func(err error)
+	mutex          sync.Mutex
+}
+
+// NewPipe creates a connected pair of pipe ends for a client with the given ID.
+// Both ends return the ID via their ID function.
+func NewPipe(id string) (clientConn *Conn, serverConn *Conn) {
+	p := &pipe{closedC: make(chan struct{})}
+	clientConn = &Conn{id: id, remoteAddr: pipeAddr("server"), pipe: p, notifyC: make(chan struct{}, 1)}
+	serverConn = &Conn{id: id, remoteAddr: pipeAddr(id), pipe: p, notifyC: make(chan struct{}, 1)}
+	clientConn.ctx, clientConn.cancel = context.WithCancel(context.Background())
+	serverConn.ctx, serverConn.cancel = context.WithCancel(context.Background())
+	clientConn.peer = serverConn
+	serverConn.peer = clientConn
+	go clientConn.run()
+	go serverConn.run()
+	return clientConn, serverConn
+}
+
+func (c *Conn) ID() string {
+	return c.id
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+func (c *Conn) TLSConnectionState() *tls.ConnectionState {
+	return nil
+}
+
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// Context returns the base context of this end of the pipe, which is canceled once the pipe is closed.
+func (c *Conn) Context() context.Context {
+	return c.ctx
+}
+
+// SetMessageHandler sets the handler for the messages received on this end of the pipe.
+func (c *Conn) SetMessageHandler(handler func(data []byte)) {
+	c.mutex.Lock()
+	c.messageHandler = handler
+	c.mutex.Unlock()
+	c.notify()
+}
+
+// SetCloseHandler sets a handler, which is invoked once the pipe is closed by either end.
+// The handler receives the reason passed to Close.
+func (c *Conn) SetCloseHandler(handler func(err error)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.closeHandler = handler
+}
+
+// SetConditions sets the conditions for all messages written to this end of the pipe from now on.
+// Messages that are already in transit are unaffected.
+func (c *Conn) SetConditions(conditions Conditions) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.conditions = conditions
+}
+
+// Write sends a message to the other end of the pipe. The data is copied and delivered asynchronously.
+//
+// An error is returned if the pipe was closed. Messages discarded according to the Conditions don't yield an error.
+func (c *Conn) Write(data []byte) error {
+	if c.Closed() {
+		return fmt.Errorf("pipe %v is closed", c.id)
+	}
+	c.mutex.Lock()
+	conditions := c.conditions
+	c.mutex.Unlock()
+	if conditions.Drop != nil && conditions.Drop(data) {
+		return nil
+	}
+	c.peer.enqueue(envelope{data: append([]byte(nil), data...), deliverAt: time.Now().Add(conditions.Latency)})
+	return nil
+}
+
+// Close terminates the pipe. The close handlers of both ends are invoked with the passed reason,
+// which may be nil. Messages in transit are discarded.
+//
+// Closing an already closed pipe has no effect.
+func (c *Conn) Close(reason error) {
+	c.pipe.closeOnce.Do(func() {
+		close(c.pipe.closedC)
+		for _, end := range []*Conn{c, c.peer} {
+			end.cancel()
+			end.mutex.Lock()
+			handler := end.closeHandler
+			end.mutex.Unlock()
+			if handler != nil {
+				go handler(reason)
+			}
+		}
+	})
+}
+
+// Closed returns true if the pipe was closed by either end.
+func (c *Conn) Closed() bool {
+	select {
+	case <-c.pipe.closedC:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *Conn) enqueue(e envelope) {
+	c.mutex.Lock()
+	c.inbox = append(c.inbox, e)
+	c.mutex.Unlock()
+	c.notify()
+}
+
+func (c *Conn) notify() {
+	select {
+	case c.notifyC <- struct{}{}:
+	default:
+	}
+}
+
+// Delivers received messages to the message handler, until the pipe is closed.
+func (c *Conn) run() {
+	for {
+		c.mutex.Lock()
+		handler := c.messageHandler
+		if handler == nil || len(c.inbox) == 0 {
+			c.mutex.Unlock()
+			select {
+			case <-c.notifyC:
+				continue
+			case <-c.pipe.closedC:
+				return
+			}
+		}
+		e := c.inbox[0]
+		c.inbox = c.inbox[1:]
+		c.mutex.Unlock()
+		if wait := time.Until(e.deliverAt); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-c.pipe.closedC:
+				timer.Stop()
+				return
+			}
+		}
+		if c.Closed() {
+			return
+		}
+		handler(e.data)
+	}
+}
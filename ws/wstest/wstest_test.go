@@ -0,0 +1,210 @@
+package wstest_test
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ocpp16 "github.com/lorenzodonini/ocpp-go/ocpp1.6"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocppj"
+	"github.com/lorenzodonini/ocpp-go/ws"
+	"github.com/lorenzodonini/ocpp-go/ws/wstest"
+)
+
+func TestPipe(t *testing.T) {
+	clientConn, serverConn := wstest.NewPipe("cp1")
+	assert.Equal(t, "cp1", clientConn.ID())
+	assert.Equal(t, "cp1", serverConn.ID())
+	receivedC := make(chan string, 10)
+	serverConn.SetMessageHandler(func(data []byte) {
+		receivedC <- string(data)
+	})
+	closedC := make(chan error, 2)
+	clientConn.SetCloseHandler(func(err error) { closedC <- err })
+	serverConn.SetCloseHandler(func(err error) { closedC <- err })
+	// Messages are delayed, dropped and delivered in order
+	clientConn.SetConditions(wstest.Conditions{
+		Latency: 50 * time.Millisecond,
+		Drop: func(data []byte) bool {
+			return strings.HasPrefix(string(data), "drop")
+		},
+	})
+	start := time.Now()
+	for _, msg := range []string{"first", "drop me", "second"} {
+		require.NoError(t, clientConn.Write([]byte(msg)))
+	}
+	assert.Equal(t, "first", <-receivedC)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	assert.Equal(t, "second", <-receivedC)
+	assert.Empty(t, receivedC)
+	// Closing notifies both ends and discards messages in transit
+	clientConn.SetConditions(wstest.Conditions{Latency: time.Second})
+	require.NoError(t, clientConn.Write([]byte("lost")))
+	reason := errors.New("cable cut")
+	serverConn.Close(reason)
+	assert.Equal(t, reason, <-closedC)
+	assert.Equal(t, reason, <-closedC)
+	assert.True(t, clientConn.Closed())
+	assert.Error(t, clientConn.Write([]byte("closed")))
+	time.Sleep(50 * time.Millisecond)
+	assert.Empty(t, receivedC)
+}
+
+func TestPipeHoldsMessagesUntilHandlerIsSet(t *testing.T) {
+	clientConn, serverConn := wstest.NewPipe("cp1")
+	require.NoError(t, serverConn.Write([]byte("early")))
+	receivedC := make(chan string, 1)
+	clientConn.SetMessageHandler(func(data []byte) {
+		receivedC <- string(data)
+	})
+	assert.Equal(t, "early", <-receivedC)
+}
+
+func TestHandshake(t *testing.T) {
+	server := wstest.NewServer()
+	server.AddSupportedSubprotocol("ocpp1.6")
+	server.SetBasicAuthHandler(func(username string, password string) bool {
+		return username == "cp1" && password == "secret"
+	})
+	client := wstest.NewClient(server)
+	client.SetRequestedSubProtocol("ocpp1.6")
+	client.SetBasicAuth("cp1", "secret")
+	// Server not running
+	require.Error(t, client.Start(wstest.URL+"/cp1"))
+	go server.Start(0, "/{ws}")
+	<-server.Ready()
+	defer server.Stop()
+	// Wrong credentials
+	invalid := wstest.NewClient(server)
+	invalid.SetRequestedSubProtocol("ocpp1.6")
+	invalid.SetBasicAuth("cp1", "wrong")
+	err := invalid.Start(wstest.URL + "/cp1")
+	httpErr, ok := err.(ws.HttpConnectionError)
+	require.True(t, ok)
+	assert.Equal(t, 401, httpErr.HttpCode)
+	assert.True(t, errors.Is(err, websocket.ErrBadHandshake))
+	// Unsupported subprotocol
+	invalid = wstest.NewClient(server)
+	invalid.SetRequestedSubProtocol("ocpp2.0.1")
+	invalid.SetBasicAuth("cp1", "secret")
+	assert.Error(t, invalid.Start(wstest.URL+"/cp1"))
+	// Refused connections
+	server.RefuseConnections(true)
+	assert.Error(t, client.Start(wstest.URL+"/cp1"))
+	server.RefuseConnections(false)
+	// Valid client
+	connectedC := make(chan string, 1)
+	server.SetNewClientHandler(func(channel ws.Channel) {
+		connectedC <- channel.ID()
+	})
+	require.NoError(t, client.Start(wstest.URL+"/cp1"))
+	assert.Equal(t, "cp1", <-connectedC)
+	assert.True(t, client.IsConnected())
+	// Duplicate ID
+	duplicate := wstest.NewClient(server)
+	duplicate.SetRequestedSubProtocol("ocpp1.6")
+	duplicate.SetBasicAuth("cp1", "secret")
+	assert.Error(t, duplicate.Start(wstest.URL+"/cp1"))
+}
+
+func TestReconnection(t *testing.T) {
+	server := wstest.NewServer()
+	var events []string
+	var mutex sync.Mutex
+	record := func(event string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		events = append(events, event)
+	}
+	server.SetNewClientHandler(func(channel ws.Channel) { record("server connected") })
+	server.SetDisconnectedClientHandler(func(channel ws.Channel) { record("server disconnected") })
+	go server.Start(0, "/{ws}")
+	<-server.Ready()
+	defer server.Stop()
+	client := wstest.NewClient(server)
+	client.SetRequestedSubProtocol("ocpp1.6")
+	reconnectedC := make(chan struct{}, 1)
+	client.SetDisconnectedHandler(func(err error) { record("client disconnected: " + err.Error()) })
+	client.SetReconnectedHandler(func() {
+		record("client reconnected")
+		reconnectedC <- struct{}{}
+	})
+	require.NoError(t, client.Start(wstest.URL+"/cp1"))
+	// Reconnection fails, as long as the server refuses connections
+	server.RefuseConnections(true)
+	require.NoError(t, server.Disconnect("cp1", errors.New("cable cut")))
+	assert.Eventually(t, func() bool { return !client.IsConnected() }, time.Second, time.Millisecond)
+	time.Sleep(150 * time.Millisecond)
+	assert.False(t, client.IsConnected())
+	server.RefuseConnections(false)
+	<-reconnectedC
+	assert.True(t, client.IsConnected())
+	_, ok := server.Connection("cp1")
+	assert.True(t, ok)
+	mutex.Lock()
+	assert.ElementsMatch(t, []string{"server connected", "server disconnected", "client disconnected: cable cut", "server connected", "client reconnected"}, events)
+	mutex.Unlock()
+	// Stopping the client doesn't trigger a reconnection
+	disconnectedC := make(chan error, 1)
+	client.SetDisconnectedHandler(func(err error) { disconnectedC <- err })
+	client.Stop()
+	assert.Nil(t, <-disconnectedC)
+	time.Sleep(100 * time.Millisecond)
+	assert.False(t, client.IsConnected())
+	_, ok = server.Connection("cp1")
+	assert.False(t, ok)
+}
+
+func TestMidRequestDisconnect(t *testing.T) {
+	chargePointID := "cp0001"
+	server := wstest.NewServer()
+	centralSystem := ocpp16.NewCentralSystem(nil, server)
+	var heartbeats int
+	var mutex sync.Mutex
+	centralSystem.SetCoreHandler(&coreHandler{onHeartbeat: func(chargePointId string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		heartbeats++
+		if heartbeats == 1 {
+			// The connection breaks while the request is being processed, so the response is lost
+			assert.NoError(t, server.Disconnect(chargePointId, errors.New("cable cut")))
+		}
+	}})
+	connectionsC := make(chan string, 4)
+	centralSystem.SetNewChargePointHandler(func(chargePoint ocpp16.ChargePointConnection) {
+		connectionsC <- "connected"
+	})
+	centralSystem.SetChargePointDisconnectedHandler(func(chargePoint ocpp16.ChargePointConnection) {
+		connectionsC <- "disconnected"
+	})
+	client := wstest.NewClient(server)
+	dispatcher := ocppj.NewDefaultClientDispatcher(ocppj.NewFIFOClientQueue(0))
+	dispatcher.SetTimeout(200 * time.Millisecond)
+	endpoint := ocppj.NewClient(chargePointID, client, dispatcher, nil, core.Profile)
+	chargePoint := ocpp16.NewChargePoint(chargePointID, endpoint, client)
+	require.NoError(t, wstest.ConnectInMemory(server, centralSystem, chargePoint))
+	defer centralSystem.Stop()
+	defer chargePoint.Stop()
+	assert.Equal(t, "connected", <-connectionsC)
+
+	// The pending request times out after the charge point reconnected
+	_, err := chargePoint.Heartbeat()
+	require.Error(t, err)
+	assert.Equal(t, "disconnected", <-connectionsC)
+	assert.Equal(t, "connected", <-connectionsC)
+	assert.True(t, chargePoint.IsConnected())
+	// Following requests are processed regularly
+	confirmation, err := chargePoint.Heartbeat()
+	require.NoError(t, err)
+	assert.NotNil(t, confirmation.CurrentTime)
+	mutex.Lock()
+	assert.Equal(t, 2, heartbeats)
+	mutex.Unlock()
+}